@@ -0,0 +1,65 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/pkg/errors"
+)
+
+// runExportCommand converts the archived blockfiles of a channel found under the root
+// directory of the configuration into a portable format
+func runExportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	configPath := flags.String("config", "blkarchiver-repo.yaml", "path to the configuration file of the repository")
+	dir := flags.String("dir", "/blkstore", "directory of the archived blockfiles below the root directory, i.e. ledger.blockArchiver.dir")
+	channel := flags.String("channel", "", "channel whose blocks are exported")
+	format := flags.String("format", repository.ExportFormatNDJSON, "export format, tar or ndjson")
+	start := flags.Uint64("start", 0, "first block to export")
+	end := flags.Uint64("end", math.MaxUint64, "last block to export")
+	output := flags.String("output", "", "file the export is written to, the standard output if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *channel == "" {
+		return errors.New("-channel is required")
+	}
+
+	config, err := repository.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if config.RootDir == "" {
+		return errors.New("rootDir is not configured")
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return errors.Wrapf(err, "error creating %s", *output)
+		}
+		defer f.Close()
+		w = f
+	}
+	exported, err := repository.Export(config.RootDir, &repository.ExportRequest{
+		Dir:        *dir,
+		Channel:    *channel,
+		Format:     *format,
+		StartBlock: *start,
+		EndBlock:   *end,
+	}, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d blocks of channel %s\n", exported, *channel)
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/pkg/errors"
+)
+
+const holdUsage = "usage: blkarchiver-repo hold place|release|list|audit [flags]"
+
+// runHoldCommand places, releases and lists the legal holds on the archived blockfiles.
+// The holds are written to the metadata store of the configuration, where a running
+// server picks up the changes on the next deletion.
+func runHoldCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(holdUsage)
+	}
+	command := args[0]
+	flags := flag.NewFlagSet("hold "+command, flag.ContinueOnError)
+	configPath := flags.String("config", "blkarchiver-repo.yaml", "path to the configuration file of the repository")
+	channel := flags.String("channel", "", "channel of the blocks to hold (place)")
+	startBlock := flags.Uint64("start", 0, "first block to hold (place)")
+	endBlock := flags.Uint64("end", 0, "last block to hold (place)")
+	reason := flags.String("reason", "", "reason of the legal hold, e.g. the reference of the case (place)")
+	id := flags.String("id", "", "identifier of the legal hold (release)")
+	actor := flags.String("actor", os.Getenv("USER"), "person placing or releasing the legal hold (place, release)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	config, err := repository.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	store, err := repository.OpenHoldStore(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch command {
+	case "place":
+		if *channel == "" {
+			return errors.New("-channel is required")
+		}
+		hold, err := store.Place(*channel, *startBlock, *endBlock, *reason, *actor)
+		if err != nil {
+			return err
+		}
+		fmt.Println(hold.ID)
+	case "release":
+		if *id == "" {
+			return errors.New("-id is required")
+		}
+		return store.Release(*id, *actor)
+	case "list":
+		holds, err := store.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCHANNEL\tBLOCKS\tPLACED BY\tCREATED\tREASON")
+		for _, h := range holds {
+			fmt.Fprintf(w, "%s\t%s\t%d-%d\t%s\t%s\t%s\n", h.ID, h.Channel, h.StartBlock, h.EndBlock, h.PlacedBy, h.CreatedAt.Format(time.RFC3339), h.Reason)
+		}
+		return w.Flush()
+	case "audit":
+		entries, err := store.Audit()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTION\tACTOR\tID\tCHANNEL\tBLOCKS")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d-%d\n", e.Time.Format(time.RFC3339), e.Action, e.Actor, e.Hold.ID, e.Hold.Channel, e.Hold.StartBlock, e.Hold.EndBlock)
+		}
+		return w.Flush()
+	default:
+		return errors.New(holdUsage)
+	}
+	return nil
+}
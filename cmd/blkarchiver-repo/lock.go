@@ -0,0 +1,65 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/pkg/errors"
+)
+
+const lockUsage = "usage: blkarchiver-repo lock show|remove -path <path of the blockfile> [flags]"
+
+// runLockCommand shows and removes the object locks of the archived blockfiles.
+// Only the locks in governance mode can be removed before they expire.
+func runLockCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(lockUsage)
+	}
+	command := args[0]
+	flags := flag.NewFlagSet("lock "+command, flag.ContinueOnError)
+	configPath := flags.String("config", "blkarchiver-repo.yaml", "path to the configuration file of the repository")
+	path := flags.String("path", "", "path of the blockfile in the repository")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-path is required")
+	}
+
+	config, err := repository.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if config.RootDir == "" {
+		return errors.New("rootDir is not configured")
+	}
+	locker := repository.NewObjectLocker(config.RootDir, config.ObjectLock)
+
+	switch command {
+	case "show":
+		lock, err := locker.Get(*path)
+		if err != nil {
+			return err
+		}
+		if lock == nil {
+			fmt.Printf("%s is not locked\n", *path)
+			return nil
+		}
+		fmt.Printf("mode:         %s\n", lock.Mode)
+		fmt.Printf("locked at:    %s\n", lock.LockedAt.Format(time.RFC3339))
+		fmt.Printf("retain until: %s\n", lock.RetainUntil.Format(time.RFC3339))
+		fmt.Printf("size:         %d\n", lock.Size)
+		fmt.Printf("active:       %t\n", lock.IsActive(time.Now()))
+	case "remove":
+		return locker.Remove(*path)
+	default:
+		return errors.New(lockUsage)
+	}
+	return nil
+}
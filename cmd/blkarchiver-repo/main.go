@@ -0,0 +1,93 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// blkarchiver-repo runs the repository of archived blockfiles shared by the
+// peers of a consortium, enforcing the storage quotas of the channels and organizations.
+//
+// "blkarchiver-repo token issue|rotate|revoke|list" manages the API tokens with which
+// the peers without an account authenticate to the repository.
+//
+// "blkarchiver-repo hold place|release|list|audit" manages the legal holds which prevent the
+// archived blocks of a channel from being deleted or overwritten, with an audit log of the actions.
+//
+// "blkarchiver-repo lock show|remove" shows the object lock of an archived blockfile, and removes
+// the locks in governance mode.
+//
+// "blkarchiver-repo owners show" shows the organizations which have uploaded or referenced an archived
+// blockfile, whose retention policies must all allow its deletion.
+//
+// "blkarchiver-repo export" converts the archived blockfiles of a channel into a tarball of
+// blocks or newline-delimited JSON.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runTokenCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "hold" {
+		if err := runHoldCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lock" {
+		if err := runLockCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "owners" {
+		if err := runOwnersCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExportCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+
+	configPath := flag.String("config", "blkarchiver-repo.yaml", "path to the configuration file of the repository")
+	flag.Parse()
+
+	config, err := repository.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	server, err := repository.NewServer(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if err := server.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	server.Stop()
+}
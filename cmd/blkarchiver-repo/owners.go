@@ -0,0 +1,55 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/pkg/errors"
+)
+
+const ownersUsage = "usage: blkarchiver-repo owners show -path <path of the blockfile> [flags]"
+
+// runOwnersCommand shows the organizations which have uploaded or referenced an archived blockfile
+func runOwnersCommand(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return errors.New(ownersUsage)
+	}
+	flags := flag.NewFlagSet("owners show", flag.ContinueOnError)
+	configPath := flags.String("config", "blkarchiver-repo.yaml", "path to the configuration file of the repository")
+	path := flags.String("path", "", "path of the blockfile in the repository")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-path is required")
+	}
+
+	config, err := repository.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if config.RootDir == "" {
+		return errors.New("rootDir is not configured")
+	}
+	owners, err := repository.NewOwnerTracker(config.RootDir, config.Retention).Get(*path)
+	if err != nil {
+		return err
+	}
+	if len(owners) == 0 {
+		fmt.Printf("%s has no recorded owner\n", *path)
+		return nil
+	}
+	for _, owner := range owners {
+		retainUntil := owner.Since.Add(config.Retention.Orgs[owner.Org])
+		fmt.Printf("%-20s since %s  retained until %s  refs [%s]\n", owner.Org,
+			owner.Since.Format(time.RFC3339), retainUntil.Format(time.RFC3339), strings.Join(owner.Refs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/pkg/errors"
+)
+
+const tokenUsage = "usage: blkarchiver-repo token issue|rotate|revoke|list [flags]"
+
+// runTokenCommand issues, rotates, revokes and lists the API tokens of the repository.
+// The tokens are written to the metadata store of the configuration, where a running
+// server picks up the changes on the next authentication.
+func runTokenCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(tokenUsage)
+	}
+	command := args[0]
+	flags := flag.NewFlagSet("token "+command, flag.ContinueOnError)
+	configPath := flags.String("config", "blkarchiver-repo.yaml", "path to the configuration file of the repository")
+	name := flags.String("name", "", "name of the token holder, e.g. the peer the token is issued to")
+	org := flags.String("org", "", "organization the uploads made with the token are accounted to (issue)")
+	ttl := flags.Duration("ttl", 0, "validity of the new token, forever if 0 (issue, rotate)")
+	grace := flags.Duration("grace", 24*time.Hour, "how long the current tokens remain valid (rotate)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	config, err := repository.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	store, err := repository.OpenTokenStore(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch command {
+	case "issue":
+		token, err := store.Issue(*name, *org, *ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Println(token)
+	case "rotate":
+		if *name == "" {
+			return errors.New("-name is required")
+		}
+		token, err := store.Rotate(*name, *grace, *ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Println(token)
+	case "revoke":
+		if *name == "" {
+			return errors.New("-name is required")
+		}
+		return store.Revoke(*name)
+	case "list":
+		tokens, err := store.List()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tORG\tISSUED\tEXPIRES\tSTATUS")
+		for _, t := range tokens {
+			expires, status := "never", "valid"
+			if t.ExpiresAt != nil {
+				expires = t.ExpiresAt.Format(time.RFC3339)
+			}
+			if t.Expired(now) {
+				status = "expired"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Name, t.Org, t.IssuedAt.Format(time.RFC3339), expires, status)
+		}
+		return w.Flush()
+	default:
+		return errors.New(tokenUsage)
+	}
+	if !config.TokenAuth {
+		fmt.Fprintln(os.Stderr, "warning: tokenAuth is disabled in the configuration of the repository")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// blockarchive-agent archives the blockfiles of the file ledgers of an ordering
+// service node, or of any other process storing its ledgers with fsblkstorage,
+// to the repository of archived blockfiles.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/fabric/core/archiver/agent"
+)
+
+func main() {
+	configPath := flag.String("config", "blockarchive-agent.yaml", "path to the configuration file of the agent")
+	once := flag.Bool("once", false, "archive the eligible blockfiles once and exit")
+	flag.Parse()
+
+	config, err := agent.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	a, err := agent.New(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if *once {
+		archived, err := a.ArchiveOnce()
+		a.Stop()
+		fmt.Printf("archived: %d\n", archived)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+	a.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	a.Stop()
+}
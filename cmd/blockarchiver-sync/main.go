@@ -0,0 +1,131 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// blockarchiver-sync replicates the archived blockfiles between two repositories,
+// or between a repository and a local directory, for disaster recovery and for
+// migrations between storage backends.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver/reposync"
+	"github.com/pkg/errors"
+)
+
+type syncCommand struct {
+	source          string
+	destination     string
+	channels        string
+	excludeChannels string
+	fromBlock       uint64
+	toBlock         uint64
+	dryRun          bool
+	keyDir          string
+}
+
+// ReadConfiguration read configuration parameters
+func (c *syncCommand) ReadConfiguration() error {
+	flag.StringVar(&c.source, "source", "", "source repository (sftp://<user>:<password>@<host>:<port>/<dir>) or local directory")
+	flag.StringVar(&c.destination, "destination", "", "destination repository (sftp://<user>:<password>@<host>:<port>/<dir>) or local directory")
+	flag.StringVar(&c.channels, "channels", "", "comma separated list of the channels to replicate, all the channels if empty")
+	flag.StringVar(&c.excludeChannels, "excludeChannels", "", "comma separated list of the channels not to replicate")
+	flag.Uint64Var(&c.fromBlock, "fromBlock", 0, "first block of the range to replicate")
+	flag.Uint64Var(&c.toBlock, "toBlock", math.MaxUint64, "last block of the range to replicate")
+	flag.BoolVar(&c.dryRun, "dryRun", false, "only report what would be replicated")
+	flag.StringVar(&c.keyDir, "encryptionKeyDir", "", "directory of the keys of the encrypted blockfiles, which are verified once decrypted")
+	flag.Parse()
+
+	if c.source == "" {
+		return errors.New("source was not provided")
+	}
+	if c.destination == "" {
+		return errors.New("destination was not provided")
+	}
+	if c.fromBlock > c.toBlock {
+		return errors.Errorf("invalid block range [%d-%d]", c.fromBlock, c.toBlock)
+	}
+	return nil
+}
+
+// Run replicates the source to the destination and returns whether all the blockfiles were replicated
+func (c *syncCommand) Run() (bool, error) {
+	if c.keyDir != "" {
+		keyring, err := blockarchive.LoadEncryptionKeyring(c.keyDir)
+		if err != nil {
+			return false, err
+		}
+		blockarchive.EncryptionKeys = keyring
+	}
+	source, err := reposync.OpenStore(c.source)
+	if err != nil {
+		return false, err
+	}
+	defer source.Close()
+	destination, err := reposync.OpenStore(c.destination)
+	if err != nil {
+		return false, err
+	}
+	defer destination.Close()
+
+	syncer := &reposync.Syncer{
+		Source:      source,
+		Destination: destination,
+		Filter: &reposync.Filter{
+			Channels:        splitList(c.channels),
+			ExcludeChannels: splitList(c.excludeChannels),
+			FromBlock:       c.fromBlock,
+			ToBlock:         c.toBlock,
+		},
+		DryRun: c.dryRun,
+	}
+	report, err := syncer.Sync()
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("copied:     %d\n", report.Copied)
+	fmt.Printf("up to date: %d\n", report.UpToDate)
+	fmt.Printf("filtered:   %d\n", report.Filtered)
+	fmt.Printf("failed:     %d\n", len(report.Failed))
+	for _, path := range report.Failed {
+		fmt.Printf("  %s\n", path)
+	}
+	return len(report.Failed) == 0, nil
+}
+
+func splitList(list string) []string {
+	var elements []string
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+func main() {
+	c := &syncCommand{}
+	// Read configuration parameters
+	if err := c.ReadConfiguration(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	ok, err := c.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if !ok {
+		fmt.Println("FAIL")
+		os.Exit(-1)
+	}
+	fmt.Println("PASS")
+}
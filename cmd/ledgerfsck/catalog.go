@@ -0,0 +1,48 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+)
+
+// VerifyArchiveCatalog checks the archive catalog of the channel against the local blockfiles and the
+// repository, since a corrupted catalog silently breaks the retrieval of the archived blocks: the blocks
+// neither held locally nor archived, the records overlapping each other, the records not matching the
+// local blockfile or the archived blockfile, and the records of blockfiles not completed by the ledger
+// or missing from the repository.
+func (fsck *ledgerFsck) VerifyArchiveCatalog() {
+	blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+	archiver.InitRepositoryAccess()
+	check, err := fsblkstorage.CheckArchiveCatalog(ledgerconfig.GetBlockStorePath(), fsck.channelName)
+	if err != nil {
+		logger.Debugf("archive catalog check of channel %s has failed, %s", fsck.channelName, err)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	for _, gap := range check.Gaps {
+		logger.Debugf("blocks [%d-%d] are neither in the local blockfiles nor in the archive catalog", gap.FirstBlockNum, gap.LastBlockNum)
+	}
+	for _, overlap := range check.Overlaps {
+		logger.Debugf("blockfile [%d]: overlapping record, %s", overlap.BlockfileNo, overlap.Reason)
+	}
+	for _, mismatch := range check.Mismatched {
+		logger.Debugf("blockfile [%d]: mismatched record, %s", mismatch.BlockfileNo, mismatch.Reason)
+	}
+	for _, dangling := range check.Dangling {
+		logger.Debugf("blockfile [%d]: dangling record, %s", dangling.BlockfileNo, dangling.Reason)
+	}
+	if !check.IsConsistent() {
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	logger.Debugf("the %d record(s) of the archive catalog of channel %s are consistent", check.Checked, fsck.channelName)
+	logger.Infof("PASS")
+}
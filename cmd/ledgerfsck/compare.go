@@ -0,0 +1,127 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// compareWithArchive is the value of compareWith selecting the archive repository as the other block source
+const compareWithArchive = "archive"
+
+// CompareLedgers compares the blocks of the channel in the local blockfiles with the blocks of another source,
+// for the investigation of forks and corruptions: the ledgersData directory of another peer, the archive
+// repository, or an archive snapshot written by peer node archive snapshot. The blocks held by both sources
+// are compared on their header hash and, except for a snapshot which only holds digests, on their content,
+// and the first divergence is reported.
+func (fsck *ledgerFsck) CompareLedgers() {
+	comparison, err := fsck.compareLedgers(ledgerconfig.GetBlockStorePath())
+	if err != nil {
+		logger.Debugf("comparison of channel %s with %s has failed, %s", fsck.channelName, fsck.compareWith, err)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	if comparison.Compared == 0 {
+		logger.Debugf("channel %s and %s hold no block in common", fsck.channelName, fsck.compareWith)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	if divergence := comparison.Divergence; divergence != nil {
+		logger.Debugf("block number [%d]: first divergence with %s, %s", divergence.BlockNum, fsck.compareWith, divergence.Reason)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	logger.Debugf("blocks [%d-%d] of channel %s are identical in %s", comparison.FirstBlockNum, comparison.LastBlockNum, fsck.channelName, fsck.compareWith)
+	logger.Infof("PASS")
+}
+
+func (fsck *ledgerFsck) compareLedgers(blockStorePath string) (*fsblkstorage.BlockComparison, error) {
+	digests, err := fsblkstorage.LocalBlockDigests(blockStorePath, fsck.channelName)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("blocks [%d-%d] found in the local blockfiles", digests[0].BlockNum, digests[len(digests)-1].BlockNum)
+
+	if fsck.comparesWithSnapshot() {
+		snapshot, err := fsck.readArchiveSnapshot()
+		if err != nil {
+			return nil, err
+		}
+		return fsblkstorage.CompareBlockDigestsWithSnapshot(digests, snapshot), nil
+	}
+	var others []*fsblkstorage.BlockDigest
+	if fsck.compareWith == compareWithArchive {
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		archiver.InitRepositoryAccess()
+		others, err = fsblkstorage.ArchivedBlockDigests(blockStorePath, fsck.channelName)
+	} else {
+		others, err = fsblkstorage.LocalBlockDigests(otherBlockStorePath(fsck.compareWith, fsck.channelName), fsck.channelName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("blocks [%d-%d] found in %s", others[0].BlockNum, others[len(others)-1].BlockNum, fsck.compareWith)
+	return fsblkstorage.CompareBlockDigests(digests, others), nil
+}
+
+// comparesWithSnapshot tells whether compareWith is an archive snapshot file rather than the archive
+// repository or the ledgersData directory of another peer
+func (fsck *ledgerFsck) comparesWithSnapshot() bool {
+	if fsck.compareWith == compareWithArchive {
+		return false
+	}
+	fileInfo, err := os.Stat(fsck.compareWith)
+	return err == nil && fileInfo.Mode().IsRegular()
+}
+
+// readArchiveSnapshot reads the archive snapshot of compareWith. Its signature is verified against the local
+// MSP unless noSignatureCheck is set.
+func (fsck *ledgerFsck) readArchiveSnapshot() (*archive.ArchiveSnapshot, error) {
+	signedBytes, err := ioutil.ReadFile(fsck.compareWith)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", fsck.compareWith)
+	}
+	signed := &archive.SignedArchiveSnapshot{}
+	if err := proto.Unmarshal(signedBytes, signed); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling %s", fsck.compareWith)
+	}
+	var snapshot *archive.ArchiveSnapshot
+	if fsck.noSignatureCheck {
+		logger.Warningf("the signature of the archive snapshot %s is not verified", fsck.compareWith)
+		snapshot = &archive.ArchiveSnapshot{}
+		if err := proto.Unmarshal(signed.Snapshot, snapshot); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling the archive snapshot of %s", fsck.compareWith)
+		}
+		if err := blockarchive.ValidateSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+	} else if snapshot, _, err = blockarchive.VerifySnapshot(signed, mgmt.GetLocalMSP()); err != nil {
+		return nil, err
+	}
+	if snapshot.ChannelID != fsck.channelName {
+		return nil, errors.Errorf("%s is the archive snapshot of channel %s", fsck.compareWith, snapshot.ChannelID)
+	}
+	return snapshot, nil
+}
+
+// otherBlockStorePath returns the block store path of the ledgersData directory of another peer,
+// or the path itself if it is already the block store path holding the blockfiles of the channel
+func otherBlockStorePath(path, channelName string) string {
+	if _, err := os.Stat(filepath.Join(path, fsblkstorage.ChainsDir, channelName)); err == nil {
+		return path
+	}
+	return filepath.Join(path, fsblkstorage.ChainsDir)
+}
@@ -0,0 +1,265 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/common/ccprovider"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/core/ledger/util"
+	"github.com/hyperledger/fabric/core/policy"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+	lb "github.com/hyperledger/fabric/protos/peer/lifecycle"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// legacyLifecycleNamespace is the namespace of the chaincode definitions of the legacy lifecycle
+const legacyLifecycleNamespace = "lscc"
+
+// builtinValidationPlugin is the name of the validation plugin evaluating the endorsement policies
+const builtinValidationPlugin = "vscc"
+
+// endorsementFailure describes a valid transaction whose endorsements don't satisfy the endorsement policy of its chaincode
+type endorsementFailure struct {
+	txIndex   int
+	txID      string
+	chaincode string
+	err       error
+}
+
+// endorsementChecker evaluates the endorsements of the valid transactions against the endorsement policies
+// of their chaincodes as they were defined at the height of the transactions. It follows the config blocks
+// and the writes of the valid transactions to the chaincode definitions while the blocks are scanned in order,
+// so the channel config and the chaincode definitions don't have to be read back from the state DB.
+// The key-level endorsement policies and the transactions of custom validation plugins are not checked.
+type endorsementChecker struct {
+	channelID string
+	// bundle is the channel config at the height of the block being checked
+	bundle *channelconfig.Bundle
+	// definitions are the values of the keys of the _lifecycle and lscc namespaces, by namespace and key
+	definitions map[string][]byte
+}
+
+// newEndorsementChecker creates a checker starting with the config of the genesis block of the channel
+func newEndorsementChecker(channelID string, genesis *pb.Block) (*endorsementChecker, error) {
+	configEnv, err := configEnvelopeOfBlock(genesis)
+	if err != nil {
+		return nil, errors.WithMessage(err, "genesis block is not a valid config block")
+	}
+	bundle, err := channelconfig.NewBundle(channelID, configEnv.Config)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid genesis config")
+	}
+	return &endorsementChecker{channelID: channelID, bundle: bundle, definitions: map[string][]byte{}}, nil
+}
+
+// GetStableChannelConfig returns the channel config at the height of the block being checked
+func (c *endorsementChecker) GetStableChannelConfig(channelID string) channelconfig.Resources {
+	return c.bundle
+}
+
+// Manager returns the policy manager of the channel config at the height of the block being checked
+func (c *endorsementChecker) Manager(channelID string) (policies.Manager, bool) {
+	return c.bundle.PolicyManager(), true
+}
+
+// GetState returns the value of a key of the _lifecycle namespace
+func (c *endorsementChecker) GetState(key string) ([]byte, error) {
+	return c.definitions[definitionKey(lifecycle.LifecycleNamespace, key)], nil
+}
+
+// check returns the valid transactions of the block whose endorsements don't satisfy the endorsement policy of
+// their chaincode, then applies the config and the chaincode definitions committed by the block
+func (c *endorsementChecker) check(block *pb.Block) ([]*endorsementFailure, error) {
+	if protoutil.IsConfigBlock(block) {
+		configEnv, err := configEnvelopeOfBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		if c.bundle, err = channelconfig.NewBundle(c.channelID, configEnv.Config); err != nil {
+			return nil, errors.WithMessage(err, "invalid config")
+		}
+		return nil, nil
+	}
+
+	evaluator, err := policy.New(c.bundle.MSPManager(), c.channelID, c)
+	if err != nil {
+		return nil, err
+	}
+	var failures []*endorsementFailure
+	var txRwSets []*rwsetutil.TxRwSet
+	txsFilter := util.TxValidationFlags(block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER])
+	for txIndex, envBytes := range block.Data.Data {
+		if txsFilter.IsInvalid(txIndex) {
+			continue
+		}
+		env, err := protoutil.GetEnvelopeFromBlock(envBytes)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to read transaction [%d]", txIndex)
+		}
+		payload, err := protoutil.UnmarshalPayload(env.Payload)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to read transaction [%d]", txIndex)
+		}
+		chdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to read transaction [%d]", txIndex)
+		}
+		if pb.HeaderType(chdr.Type) != pb.HeaderType_ENDORSER_TRANSACTION {
+			continue
+		}
+		tx, err := protoutil.GetTransaction(payload.Data)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to read transaction [%d]", txIndex)
+		}
+		for _, action := range tx.Actions {
+			ccPayload, ccAction, err := protoutil.GetPayloads(action)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "failed to read the chaincode action of transaction [%d]", txIndex)
+			}
+			if ccAction.ChaincodeId == nil {
+				return nil, errors.Errorf("chaincode action of transaction [%d] has no chaincode ID", txIndex)
+			}
+			chaincode := ccAction.ChaincodeId.Name
+			if err := c.evaluate(evaluator, chaincode, ccPayload); err != nil {
+				failures = append(failures, &endorsementFailure{txIndex: txIndex, txID: chdr.TxId, chaincode: chaincode, err: err})
+			}
+			txRwSet := &rwsetutil.TxRwSet{}
+			if err := txRwSet.FromProtoBytes(ccAction.Results); err != nil {
+				return nil, errors.WithMessagef(err, "failed to extract read-write set of transaction [%d]", txIndex)
+			}
+			txRwSets = append(txRwSets, txRwSet)
+		}
+	}
+
+	// The transactions of a block are validated against the definitions committed by the previous blocks
+	for _, txRwSet := range txRwSets {
+		c.applyDefinitions(txRwSet)
+	}
+	return failures, nil
+}
+
+// evaluate evaluates the endorsements of a chaincode action against the endorsement policy of the chaincode
+func (c *endorsementChecker) evaluate(evaluator *policy.ApplicationPolicyEvaluator, chaincode string, ccPayload *peer.ChaincodeActionPayload) error {
+	policyBytes, err := c.endorsementPolicy(chaincode)
+	if err != nil || policyBytes == nil {
+		return err
+	}
+	signatureSet, err := endorsementSignatureSet(ccPayload.Action)
+	if err != nil {
+		return err
+	}
+	return evaluator.Evaluate(policyBytes, signatureSet)
+}
+
+// endorsementPolicy returns the endorsement policy of a chaincode as an application policy,
+// nil if the endorsements of the chaincode are not evaluated against a policy by the builtin plugin
+func (c *endorsementChecker) endorsementPolicy(chaincode string) ([]byte, error) {
+	switch chaincode {
+	case lifecycle.LifecycleNamespace:
+		vc := &lifecycle.ValidatorCommitter{Resources: &lifecycle.Resources{ChannelConfigSource: c}}
+		return vc.LifecycleEndorsementPolicyAsBytes(c.channelID)
+	case legacyLifecycleNamespace:
+		// The deployments of the legacy lifecycle are validated against the policy of the deployed chaincode
+		return nil, nil
+	}
+
+	validationInfo := &lb.ChaincodeValidationInfo{}
+	serializer := &lifecycle.Serializer{}
+	if err := serializer.DeserializeFieldAsProto(lifecycle.NamespacesName, chaincode, "ValidationInfo", c, validationInfo); err != nil {
+		return nil, errors.WithMessagef(err, "invalid definition of chaincode %s", chaincode)
+	}
+	if validationInfo.ValidationPlugin != "" {
+		if validationInfo.ValidationPlugin != builtinValidationPlugin {
+			logger.Debugf("chaincode %s is validated by plugin %s, its endorsements are not checked", chaincode, validationInfo.ValidationPlugin)
+			return nil, nil
+		}
+		return validationInfo.ValidationParameter, nil
+	}
+
+	chaincodeDataBytes, ok := c.definitions[definitionKey(legacyLifecycleNamespace, chaincode)]
+	if !ok {
+		return nil, errors.Errorf("chaincode %s is not defined", chaincode)
+	}
+	chaincodeData := &ccprovider.ChaincodeData{}
+	if err := proto.Unmarshal(chaincodeDataBytes, chaincodeData); err != nil {
+		return nil, errors.Wrapf(err, "invalid definition of chaincode %s", chaincode)
+	}
+	if chaincodeData.Vscc != builtinValidationPlugin {
+		logger.Debugf("chaincode %s is validated by plugin %s, its endorsements are not checked", chaincode, chaincodeData.Vscc)
+		return nil, nil
+	}
+	// The legacy lifecycle records the policy as a signature policy envelope
+	signaturePolicy := &pb.SignaturePolicyEnvelope{}
+	if err := proto.Unmarshal(chaincodeData.Policy, signaturePolicy); err != nil {
+		return nil, errors.Wrapf(err, "invalid endorsement policy of chaincode %s", chaincode)
+	}
+	return protoutil.MarshalOrPanic(&peer.ApplicationPolicy{
+		Type: &peer.ApplicationPolicy_SignaturePolicy{SignaturePolicy: signaturePolicy},
+	}), nil
+}
+
+// applyDefinitions records the writes of a transaction to the chaincode definitions
+func (c *endorsementChecker) applyDefinitions(txRwSet *rwsetutil.TxRwSet) {
+	for _, nsRwSet := range txRwSet.NsRwSets {
+		if nsRwSet.NameSpace != lifecycle.LifecycleNamespace && nsRwSet.NameSpace != legacyLifecycleNamespace {
+			continue
+		}
+		for _, write := range nsRwSet.KvRwSet.Writes {
+			key := definitionKey(nsRwSet.NameSpace, write.Key)
+			if write.IsDelete {
+				delete(c.definitions, key)
+				continue
+			}
+			c.definitions[key] = write.Value
+		}
+	}
+}
+
+// endorsementSignatureSet builds the signature set of the endorsements of a chaincode action,
+// ignoring the duplicated endorsers like the builtin validation plugin
+func endorsementSignatureSet(action *peer.ChaincodeEndorsedAction) ([]*protoutil.SignedData, error) {
+	var signatureSet []*protoutil.SignedData
+	endorsers := map[string]bool{}
+	for _, endorsement := range action.Endorsements {
+		identity := &msp.SerializedIdentity{}
+		if err := proto.Unmarshal(endorsement.Endorser, identity); err != nil {
+			return nil, errors.Wrap(err, "invalid endorser")
+		}
+		if endorsers[identity.Mspid+string(identity.IdBytes)] {
+			continue
+		}
+		endorsers[identity.Mspid+string(identity.IdBytes)] = true
+		// The endorsers sign the proposal response payload followed by their identity
+		data := make([]byte, 0, len(action.ProposalResponsePayload)+len(endorsement.Endorser))
+		data = append(append(data, action.ProposalResponsePayload...), endorsement.Endorser...)
+		signatureSet = append(signatureSet, &protoutil.SignedData{
+			Data:      data,
+			Identity:  endorsement.Endorser,
+			Signature: endorsement.Signature,
+		})
+	}
+	return signatureSet, nil
+}
+
+func definitionKey(namespace, key string) string {
+	return namespace + "\x00" + key
+}
+
+// logEndorsementFailures logs the transactions of a block whose endorsements don't satisfy the policy
+// and returns their number
+func logEndorsementFailures(blockNum uint64, failures []*endorsementFailure) int {
+	for _, failure := range failures {
+		logger.Warningf("block number [%d]: transaction [%d] %s of chaincode %s doesn't satisfy the endorsement policy, %s",
+			blockNum, failure.txIndex, failure.txID, failure.chaincode, failure.err)
+	}
+	return len(failures)
+}
@@ -0,0 +1,139 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/cauthdsl"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/common/ccprovider"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chaincodeTx returns a transaction of the chaincode with the results, endorsed by the local signer
+// if signed, by an identity unknown to the channel otherwise
+func chaincodeTx(t *testing.T, chaincode string, results *rwsetutil.RWSetBuilder, signed bool) *pb.Envelope {
+	simulationResults, err := results.GetTxSimulationResults()
+	require.NoError(t, err)
+	pubResults, err := simulationResults.GetPubSimulationBytes()
+	require.NoError(t, err)
+	env, _, err := testutil.ConstructTransactionFromTxDetails(&testutil.TxDetails{
+		ChaincodeName:     chaincode,
+		ChaincodeVersion:  "v1",
+		SimulationResults: pubResults,
+	}, signed)
+	require.NoError(t, err)
+	return env
+}
+
+// legacyDefinitions returns the results of the deployments of chaincodes with the legacy lifecycle
+func legacyDefinitions(definitions ...*ccprovider.ChaincodeData) *rwsetutil.RWSetBuilder {
+	results := rwsetutil.NewRWSetBuilder()
+	for _, definition := range definitions {
+		results.AddToWriteSet(legacyLifecycleNamespace, definition.Name, protoutil.MarshalOrPanic(definition))
+	}
+	return results
+}
+
+func TestEndorsementChecker(t *testing.T) {
+	l := newFixtureLedger(t)
+	checker, err := newEndorsementChecker(util.GetTestChainID(), l.blocks[0])
+	require.NoError(t, err)
+
+	// The deployment of the chaincodes is not evaluated, the chaincodes are defined for the next blocks
+	deploy := l.add(t, []*pb.Envelope{chaincodeTx(t, legacyLifecycleNamespace, legacyDefinitions(
+		&ccprovider.ChaincodeData{Name: "mycc", Vscc: "vscc", Policy: protoutil.MarshalOrPanic(cauthdsl.SignedByMspMember("SampleOrg"))},
+		&ccprovider.ChaincodeData{Name: "othercc", Vscc: "vscc", Policy: protoutil.MarshalOrPanic(cauthdsl.SignedByMspMember("OtherOrg"))},
+		&ccprovider.ChaincodeData{Name: "customcc", Vscc: "custom"},
+	), false)}, 0)
+	failures, err := checker.check(deploy)
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+
+	block := l.add(t, []*pb.Envelope{
+		chaincodeTx(t, "mycc", writes("mycc", "key", "value"), true),
+		chaincodeTx(t, "mycc", writes("mycc", "key", "value"), false),
+		chaincodeTx(t, "othercc", writes("othercc", "key", "value"), true),
+		chaincodeTx(t, "undefinedcc", writes("undefinedcc", "key", "value"), true),
+		chaincodeTx(t, "customcc", writes("customcc", "key", "value"), false),
+		chaincodeTx(t, "mycc", writes("mycc", "key", "value"), false),
+	}, 0)
+	// The invalid transactions are not evaluated
+	block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER][5] = uint8(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE)
+	failures, err = checker.check(block)
+	require.NoError(t, err)
+	require.Len(t, failures, 3)
+	assert.Equal(t, 1, failures[0].txIndex)
+	assert.Equal(t, "mycc", failures[0].chaincode)
+	assert.Equal(t, 2, failures[1].txIndex)
+	assert.Equal(t, "othercc", failures[1].chaincode)
+	assert.Equal(t, 3, failures[2].txIndex)
+	assert.EqualError(t, failures[2].err, "chaincode undefinedcc is not defined")
+	assert.Equal(t, 3, logEndorsementFailures(block.Header.Number, failures))
+
+	// A chaincode whose definition has been deleted is no longer defined
+	undeploy := rwsetutil.NewRWSetBuilder()
+	undeploy.AddToWriteSet(legacyLifecycleNamespace, "mycc", nil)
+	failures, err = checker.check(l.add(t, []*pb.Envelope{chaincodeTx(t, legacyLifecycleNamespace, undeploy, true)}, 0))
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+	failures, err = checker.check(l.add(t, []*pb.Envelope{chaincodeTx(t, "mycc", writes("mycc", "key", "value"), true)}, 0))
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.EqualError(t, failures[0].err, "chaincode mycc is not defined")
+
+	// A config block updates the channel config against which the endorsements are evaluated
+	configBlock := l.add(t, []*pb.Envelope{configTx(t, batchSizeUpdate(t, checker.bundle, 20))}, 5)
+	failures, err = checker.check(configBlock)
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+	orderer, ok := checker.bundle.OrdererConfig()
+	require.True(t, ok)
+	assert.Equal(t, uint32(20), orderer.BatchSize().MaxMessageCount)
+
+	// A valid transaction which cannot be parsed fails the check
+	garbage, _, err := testutil.ConstructTransactionFromTxDetails(&testutil.TxDetails{
+		ChaincodeName:     "othercc",
+		SimulationResults: []byte("garbage"),
+	}, true)
+	require.NoError(t, err)
+	_, err = checker.check(l.add(t, []*pb.Envelope{garbage}, 5))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to extract read-write set of transaction [0]")
+
+	// The checker starts from the config of the genesis block
+	_, err = newEndorsementChecker(util.GetTestChainID(), block)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "genesis block is not a valid config block")
+}
+
+func TestVerifyEndorsementPolicies(t *testing.T) {
+	l := newFixtureLedger(t)
+	l.add(t, []*pb.Envelope{chaincodeTx(t, legacyLifecycleNamespace, legacyDefinitions(
+		&ccprovider.ChaincodeData{Name: "mycc", Vscc: "vscc", Policy: protoutil.MarshalOrPanic(cauthdsl.SignedByMspMember("SampleOrg"))},
+	), true)}, 0)
+	l.add(t, []*pb.Envelope{chaincodeTx(t, "mycc", writes("mycc", "key", "value"), true)}, 0)
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), noSignatureCheck: true, checkEndorsementPolicies: true, ledger: l}
+	assert.NoError(t, fsck.verify())
+
+	// The transactions of all the blocks are checked before the verification fails
+	l.add(t, []*pb.Envelope{chaincodeTx(t, "mycc", writes("mycc", "key", "value"), false)}, 0)
+	l.add(t, []*pb.Envelope{chaincodeTx(t, "mycc", writes("mycc", "key", "value"), false)}, 0)
+	err := fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 transactions don't satisfy the endorsement policy of their chaincode")
+
+	l.blocks[0] = l.blocks[1]
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "block number [0]")
+}
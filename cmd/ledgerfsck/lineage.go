@@ -0,0 +1,241 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/configtx"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// configHistoryEntry describes a config block of the channel
+type configHistoryEntry struct {
+	blockNum  uint64
+	sequence  uint64
+	timestamp time.Time
+	// signers are the MSP IDs of the signers of the config update
+	signers []string
+	// updated are the paths of the config elements added, modified or removed by the update
+	updated []string
+}
+
+// VerifyConfigLineage walks all the config blocks of the channel from the genesis block forward,
+// following the LAST_CONFIG pointers of the blocks, validates each config update against the
+// policies of the previous config and reports the config history of the channel
+func (fsck *ledgerFsck) VerifyConfigLineage() {
+	history, err := fsck.verifyConfigLineage()
+	for _, entry := range history {
+		logger.Infof("config block [%d]: sequence [%d], created at %s, signed by %v, updated %v",
+			entry.blockNum, entry.sequence, entry.timestamp.Format(time.RFC3339), entry.signers, entry.updated)
+	}
+	if err != nil {
+		logger.Debugf("config lineage verification of channel %s has failed, %s", fsck.channelName, err)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	logger.Debugf("config lineage of channel %s verified, %d config blocks", fsck.channelName, len(history))
+}
+
+func (fsck *ledgerFsck) verifyConfigLineage() ([]*configHistoryEntry, error) {
+	blockchainInfo, err := fsck.ledger.GetBlockchainInfo()
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := fsck.ledger.GetBlockByNumber(0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read the genesis block")
+	}
+	genesisEnv, err := configEnvelopeOfBlock(genesis)
+	if err != nil {
+		return nil, errors.WithMessage(err, "genesis block is not a valid config block")
+	}
+	bundle, err := channelconfig.NewBundle(fsck.channelName, genesisEnv.Config)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid genesis config")
+	}
+	entry, err := newConfigHistoryEntry(genesis, nil, genesisEnv)
+	if err != nil {
+		return nil, err
+	}
+	history := []*configHistoryEntry{entry}
+
+	lastConfig := uint64(0)
+	for blockNum := uint64(1); blockNum < blockchainInfo.Height; blockNum++ {
+		block, err := fsck.ledger.GetBlockByNumber(blockNum)
+		if err != nil {
+			return history, errors.WithMessagef(err, "failed to read block number %d", blockNum)
+		}
+		index, err := protoutil.GetLastConfigIndexFromBlock(block)
+		if err != nil {
+			return history, errors.WithMessagef(err, "block number [%d] has no LAST_CONFIG pointer", blockNum)
+		}
+		isConfig := protoutil.IsConfigBlock(block)
+		switch {
+		case index == lastConfig && !isConfig:
+			continue
+		case index != blockNum:
+			return history, errors.Errorf("block number [%d] points to config block [%d] while the last config block is [%d]", blockNum, index, lastConfig)
+		case !isConfig:
+			return history, errors.Errorf("block number [%d] points to itself as config block but has no config transaction", blockNum)
+		}
+
+		configEnv, err := configEnvelopeOfBlock(block)
+		if err != nil {
+			return history, errors.WithMessagef(err, "config block [%d] is invalid", blockNum)
+		}
+		// Validate recomputes the config from the config update under the policies of the previous
+		// config and checks that it is the config of the block, so a tampered config is detected
+		if err := bundle.ConfigtxValidator().Validate(configEnv); err != nil {
+			return history, errors.WithMessagef(err, "config update of block [%d] is not valid against the config of block [%d]", blockNum, lastConfig)
+		}
+		entry, err := newConfigHistoryEntry(block, bundle.ConfigtxValidator().ConfigProto(), configEnv)
+		if err != nil {
+			return history, err
+		}
+		history = append(history, entry)
+		if bundle, err = channelconfig.NewBundle(fsck.channelName, configEnv.Config); err != nil {
+			return history, errors.WithMessagef(err, "invalid config in block [%d]", blockNum)
+		}
+		lastConfig = blockNum
+	}
+	return history, nil
+}
+
+// configEnvelopeOfBlock extracts the config envelope of a config block
+func configEnvelopeOfBlock(block *pb.Block) (*pb.ConfigEnvelope, error) {
+	env, err := protoutil.ExtractEnvelope(block, 0)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return configtx.UnmarshalConfigEnvelope(payload.Data)
+}
+
+func newConfigHistoryEntry(block *pb.Block, prev *pb.Config, configEnv *pb.ConfigEnvelope) (*configHistoryEntry, error) {
+	entry := &configHistoryEntry{blockNum: block.Header.Number, sequence: configEnv.Config.Sequence}
+	env, err := protoutil.ExtractEnvelope(block, 0)
+	if err != nil {
+		return nil, err
+	}
+	chdr, err := protoutil.ChannelHeader(env)
+	if err != nil {
+		return nil, err
+	}
+	if chdr.Timestamp != nil {
+		entry.timestamp, _ = ptypes.Timestamp(chdr.Timestamp)
+	}
+	if configEnv.LastUpdate != nil {
+		entry.signers = configUpdateSigners(configEnv.LastUpdate)
+	}
+	var prevGroup *pb.ConfigGroup
+	if prev != nil {
+		prevGroup = prev.ChannelGroup
+	}
+	entry.updated = diffConfigGroups("Channel", prevGroup, configEnv.Config.ChannelGroup, nil)
+	return entry, nil
+}
+
+// configUpdateSigners returns the MSP IDs of the signers of a config update
+func configUpdateSigners(lastUpdate *pb.Envelope) []string {
+	payload, err := protoutil.UnmarshalPayload(lastUpdate.Payload)
+	if err != nil {
+		return nil
+	}
+	updateEnv, err := configtx.UnmarshalConfigUpdateEnvelope(payload.Data)
+	if err != nil {
+		return nil
+	}
+	var signers []string
+	for _, signature := range updateEnv.Signatures {
+		header, err := protoutil.GetSignatureHeader(signature.SignatureHeader)
+		if err != nil {
+			continue
+		}
+		identity := &msp.SerializedIdentity{}
+		if err := proto.Unmarshal(header.Creator, identity); err != nil {
+			continue
+		}
+		signers = append(signers, identity.Mspid)
+	}
+	return signers
+}
+
+// diffConfigGroups lists the paths of the elements of the config groups whose version differs
+func diffConfigGroups(path string, prev, next *pb.ConfigGroup, updated []string) []string {
+	if prev == nil {
+		return append(updated, path)
+	}
+	if next == nil {
+		return append(updated, path+" (removed)")
+	}
+	if prev.Version != next.Version || prev.ModPolicy != next.ModPolicy {
+		updated = append(updated, path)
+	}
+	for _, key := range unionKeys(prev.Groups, next.Groups) {
+		updated = diffConfigGroups(path+"/"+key, prev.Groups[key], next.Groups[key], updated)
+	}
+	for _, key := range unionKeys(prev.Values, next.Values) {
+		p, n := prev.Values[key], next.Values[key]
+		switch {
+		case p == nil:
+			updated = append(updated, path+"/Values/"+key)
+		case n == nil:
+			updated = append(updated, path+"/Values/"+key+" (removed)")
+		case p.Version != n.Version:
+			updated = append(updated, path+"/Values/"+key)
+		}
+	}
+	for _, key := range unionKeys(prev.Policies, next.Policies) {
+		p, n := prev.Policies[key], next.Policies[key]
+		switch {
+		case p == nil:
+			updated = append(updated, path+"/Policies/"+key)
+		case n == nil:
+			updated = append(updated, path+"/Policies/"+key+" (removed)")
+		case p.Version != n.Version:
+			updated = append(updated, path+"/Policies/"+key)
+		}
+	}
+	return updated
+}
+
+// unionKeys returns the sorted union of the keys of two maps of config elements
+func unionKeys(prev, next interface{}) []string {
+	keys := map[string]bool{}
+	for _, m := range []interface{}{prev, next} {
+		switch m := m.(type) {
+		case map[string]*pb.ConfigGroup:
+			for k := range m {
+				keys[k] = true
+			}
+		case map[string]*pb.ConfigValue:
+			for k := range m {
+				keys[k] = true
+			}
+		case map[string]*pb.ConfigPolicy:
+			for k := range m {
+				keys[k] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
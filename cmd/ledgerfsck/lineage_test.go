@@ -0,0 +1,160 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/internal/configtxlator/update"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	pb "github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric/protos/orderer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// genesisBundle returns the channel config of the genesis block of the ledger
+func genesisBundle(t *testing.T, l *fixtureLedger) *channelconfig.Bundle {
+	configEnv, err := configEnvelopeOfBlock(l.blocks[0])
+	require.NoError(t, err)
+	bundle, err := channelconfig.NewBundle(util.GetTestChainID(), configEnv.Config)
+	require.NoError(t, err)
+	return bundle
+}
+
+// batchSizeUpdate returns the config envelope setting the maximum message count of the orderers,
+// proposed by the admin of the sample MSP against the channel config
+func batchSizeUpdate(t *testing.T, bundle *channelconfig.Bundle, maxMessageCount uint32) *pb.ConfigEnvelope {
+	signer := mgmt.GetLocalSigningIdentityOrPanic()
+	original := bundle.ConfigtxValidator().ConfigProto()
+	updated := proto.Clone(original).(*pb.Config)
+	batchSizeValue := updated.ChannelGroup.Groups[channelconfig.OrdererGroupKey].Values[channelconfig.BatchSizeKey]
+	batchSize := &ab.BatchSize{}
+	require.NoError(t, proto.Unmarshal(batchSizeValue.Value, batchSize))
+	batchSize.MaxMessageCount = maxMessageCount
+	batchSizeValue.Value = protoutil.MarshalOrPanic(batchSize)
+
+	configUpdate, err := update.Compute(original, updated)
+	require.NoError(t, err)
+	configUpdate.ChannelId = util.GetTestChainID()
+	configUpdateEnv := &pb.ConfigUpdateEnvelope{ConfigUpdate: protoutil.MarshalOrPanic(configUpdate)}
+	sigHeader := protoutil.MarshalOrPanic(protoutil.NewSignatureHeaderOrPanic(signer))
+	signature, err := signer.Sign(util.ConcatenateBytes(sigHeader, configUpdateEnv.ConfigUpdate))
+	require.NoError(t, err)
+	configUpdateEnv.Signatures = []*pb.ConfigSignature{{SignatureHeader: sigHeader, Signature: signature}}
+	updateTx, err := protoutil.CreateSignedEnvelope(pb.HeaderType_CONFIG_UPDATE, util.GetTestChainID(), signer, configUpdateEnv, 0, 0)
+	require.NoError(t, err)
+	configEnv, err := bundle.ConfigtxValidator().ProposeConfigUpdate(updateTx)
+	require.NoError(t, err)
+	return configEnv
+}
+
+// configTx returns the config transaction of a config envelope
+func configTx(t *testing.T, configEnv *pb.ConfigEnvelope) *pb.Envelope {
+	tx, err := protoutil.CreateSignedEnvelope(pb.HeaderType_CONFIG, util.GetTestChainID(), mgmt.GetLocalSigningIdentityOrPanic(), configEnv, 0, 0)
+	require.NoError(t, err)
+	return tx
+}
+
+func TestVerifyConfigLineage(t *testing.T) {
+	l := newFixtureLedger(t)
+	bundle := genesisBundle(t, l)
+	l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+	l.add(t, []*pb.Envelope{configTx(t, batchSizeUpdate(t, bundle, 20))}, 2)
+	l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 2)
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), ledger: l}
+
+	history, err := fsck.verifyConfigLineage()
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, uint64(0), history[0].blockNum)
+	assert.Equal(t, []string{"Channel"}, history[0].updated)
+	assert.Equal(t, uint64(2), history[1].blockNum)
+	assert.Equal(t, history[0].sequence+1, history[1].sequence)
+	assert.Equal(t, []string{"SampleOrg"}, history[1].signers)
+	assert.Equal(t, []string{"Channel/Orderer/Values/BatchSize"}, history[1].updated)
+	assert.False(t, history[1].timestamp.IsZero())
+
+	// A config which is not the one produced by the config update of the block is detected
+	configEnv := batchSizeUpdate(t, bundle, 20)
+	tamperedConfig := batchSizeUpdate(t, bundle, 30)
+	configEnv.Config = tamperedConfig.Config
+	l.blocks = l.blocks[:2]
+	l.add(t, []*pb.Envelope{configTx(t, configEnv)}, 2)
+	history, err = fsck.verifyConfigLineage()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "config update of block [2] is not valid against the config of block [0]")
+	assert.Len(t, history, 1)
+
+	// A config update which was valid against a config other than the previous one is detected
+	l.blocks = l.blocks[:2]
+	l.add(t, []*pb.Envelope{configTx(t, batchSizeUpdate(t, bundle, 20))}, 2)
+	l.add(t, []*pb.Envelope{configTx(t, batchSizeUpdate(t, bundle, 30))}, 3)
+	history, err = fsck.verifyConfigLineage()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "config update of block [3] is not valid against the config of block [2]")
+	assert.Len(t, history, 2)
+}
+
+func TestVerifyConfigLineageFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(t *testing.T, l *fixtureLedger)
+		err   string
+	}{
+		{
+			name: "block pointing to a stale config block",
+			build: func(t *testing.T, l *fixtureLedger) {
+				l.add(t, []*pb.Envelope{configTx(t, batchSizeUpdate(t, genesisBundle(t, l), 20))}, 1)
+				l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+			},
+			err: "block number [2] points to config block [0] while the last config block is [1]",
+		},
+		{
+			name: "config block not pointing to itself",
+			build: func(t *testing.T, l *fixtureLedger) {
+				l.add(t, []*pb.Envelope{configTx(t, batchSizeUpdate(t, genesisBundle(t, l), 20))}, 0)
+			},
+			err: "block number [1] points to config block [0] while the last config block is [0]",
+		},
+		{
+			name: "block pointing to itself without a config transaction",
+			build: func(t *testing.T, l *fixtureLedger) {
+				l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 1)
+			},
+			err: "block number [1] points to itself as config block but has no config transaction",
+		},
+		{
+			name: "block without LAST_CONFIG pointer",
+			build: func(t *testing.T, l *fixtureLedger) {
+				block := l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+				block.Metadata.Metadata[pb.BlockMetadataIndex_LAST_CONFIG] = []byte("garbage")
+			},
+			err: "block number [1] has no LAST_CONFIG pointer",
+		},
+		{
+			name: "genesis block without config",
+			build: func(t *testing.T, l *fixtureLedger) {
+				l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+				l.blocks = l.blocks[1:]
+			},
+			err: "genesis block is not a valid config block",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := newFixtureLedger(t)
+			test.build(t, l)
+			fsck := &ledgerFsck{channelName: util.GetTestChainID(), ledger: l}
+			_, err := fsck.verifyConfigLineage()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/hyperledger/fabric/core/operations"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/scc/lscc"
+	"github.com/hyperledger/fabric/gossip/api"
 	gossipCommon "github.com/hyperledger/fabric/gossip/common"
 	"github.com/hyperledger/fabric/internal/peer/common"
 	"github.com/hyperledger/fabric/internal/peer/gossip"
@@ -41,6 +42,28 @@ type ledgerFsck struct {
 	mspConfigPath string
 	mspID         string
 	mspType       string
+	checkPvtData  bool
+	// checkConfigLineage validates every config update of the channel against the previous config
+	checkConfigLineage bool
+	// checkEndorsementPolicies evaluates the endorsements of every valid transaction against the
+	// endorsement policy of its chaincode at the height of the transaction
+	checkEndorsementPolicies bool
+	// noSignatureCheck restricts the verification to the hash chain and the block structure,
+	// so that neither the MSP configuration nor the channel configuration is required
+	noSignatureCheck bool
+	// rawBlockfiles parses the blockfiles directly instead of reading the blocks through the ledger,
+	// so that file-level corruption is detected even if the block index is damaged
+	rawBlockfiles bool
+	// checkArchiveCatalog checks the archive catalog against the local blockfiles and the repository instead of verifying the ledger
+	checkArchiveCatalog bool
+	// rebuildIndex reconstructs the block index from the blockfiles and the archive catalog instead of verifying the ledger
+	rebuildIndex bool
+	// snapshotDir receives a copy of the ledger data of the channel, which is verified instead of the
+	// ledger of the peer so that the peer can keep running
+	snapshotDir string
+	// compareWith is the other block source the local blockfiles are compared with: the ledgersData directory
+	// of another peer, "archive" for the archive repository or an archive snapshot file
+	compareWith string
 
 	ledger ledger.PeerLedger
 	bundle *channelconfig.Bundle
@@ -87,8 +110,74 @@ func (fsck *ledgerFsck) ReadConfiguration() error {
 	flag.StringVar(&fsck.mspConfigPath, "mspPath", "", "path to the msp folder")
 	flag.StringVar(&fsck.mspID, "mspID", "", "the MSP identity of the organization")
 	flag.StringVar(&fsck.mspType, "mspType", "bccsp", "the type of the MSP provider, default bccsp")
+	flag.BoolVar(&fsck.noSignatureCheck, "noSignatureCheck", false, "verify only the hash chain and the block structure, no MSP configuration is required")
+	flag.BoolVar(&fsck.rawBlockfiles, "rawBlockfiles", false, "parse the blockfiles directly without the ledger and its indexes, implies noSignatureCheck")
+	flag.BoolVar(&fsck.rebuildIndex, "rebuildIndex", false, "rebuild the block index from the local blockfiles and the archive catalog, the peer must be stopped")
+	flag.BoolVar(&fsck.checkArchiveCatalog, "checkArchiveCatalog", false, "check the archive catalog against the local blockfiles and the repository for gaps, overlaps, mismatched and dangling records, the peer must be stopped unless snapshotDir is set")
+	flag.BoolVar(&fsck.checkPvtData, "checkPvtData", false, "cross-check the private data hashes in transactions against the pvtdata store")
+	flag.BoolVar(&fsck.checkConfigLineage, "checkConfigLineage", false, "validate every config update against the policies of the previous config and report the config history")
+	flag.BoolVar(&fsck.checkEndorsementPolicies, "checkEndorsementPolicies", false, "evaluate the endorsements of every valid transaction against the endorsement policy of its chaincode at the height of the transaction")
+	flag.StringVar(&fsck.snapshotDir, "snapshotDir", "", "copy the ledger data of the channel into this empty directory and verify the copy, so that the peer can keep running")
+	flag.StringVar(&fsck.compareWith, "compareWith", "", "compare the local blockfiles block by block with the ledgersData directory of another peer, \"archive\" for the archive repository (the peer must be stopped) or an archive snapshot file, and report the first divergence")
 	flag.Parse()
 
+	if fsck.checkConfigLineage && (fsck.noSignatureCheck || fsck.rawBlockfiles || fsck.rebuildIndex) {
+		errMsg := "checkConfigLineage requires the MSP configuration and is not supported with noSignatureCheck, rawBlockfiles and rebuildIndex"
+		logger.Error(errMsg)
+		return errors.New(errMsg)
+	}
+	if fsck.checkEndorsementPolicies && (fsck.noSignatureCheck || fsck.rawBlockfiles || fsck.rebuildIndex) {
+		errMsg := "checkEndorsementPolicies requires the MSP configuration and is not supported with noSignatureCheck, rawBlockfiles and rebuildIndex"
+		logger.Error(errMsg)
+		return errors.New(errMsg)
+	}
+	if fsck.snapshotDir != "" && (fsck.rawBlockfiles || fsck.rebuildIndex) {
+		errMsg := "snapshotDir is not supported with rawBlockfiles, which reads the blockfiles without locking them, and rebuildIndex"
+		logger.Error(errMsg)
+		return errors.New(errMsg)
+	}
+	if fsck.checkArchiveCatalog {
+		if fsck.rawBlockfiles || fsck.rebuildIndex || fsck.compareWith != "" || fsck.checkPvtData || fsck.checkConfigLineage || fsck.checkEndorsementPolicies {
+			errMsg := "checkArchiveCatalog checks the archive catalog only and is not supported with the other modes and checks"
+			logger.Error(errMsg)
+			return errors.New(errMsg)
+		}
+		fsck.noSignatureCheck = true
+	}
+	if fsck.compareWith != "" {
+		if fsck.rawBlockfiles || fsck.rebuildIndex || fsck.snapshotDir != "" || fsck.checkPvtData || fsck.checkConfigLineage || fsck.checkEndorsementPolicies {
+			errMsg := "compareWith reads the local blockfiles directly and is not supported with the other modes and checks"
+			logger.Error(errMsg)
+			return errors.New(errMsg)
+		}
+		// Only the signature of an archive snapshot is verified
+		if !fsck.comparesWithSnapshot() {
+			fsck.noSignatureCheck = true
+		}
+	}
+	if fsck.rawBlockfiles || fsck.rebuildIndex {
+		if fsck.checkPvtData {
+			errMsg := "checkPvtData is not supported with rawBlockfiles and rebuildIndex"
+			logger.Error(errMsg)
+			return errors.New(errMsg)
+		}
+		fsck.noSignatureCheck = true
+	}
+
+	logger.Debugf("channel name = %s", fsck.channelName)
+	logger.Debugf("no signature check = %t", fsck.noSignatureCheck)
+	logger.Debugf("raw blockfiles = %t", fsck.rawBlockfiles)
+	logger.Debugf("rebuild index = %t", fsck.rebuildIndex)
+	logger.Debugf("snapshot directory = %s", fsck.snapshotDir)
+	logger.Debugf("compare with = %s", fsck.compareWith)
+	logger.Debugf("check archive catalog = %t", fsck.checkArchiveCatalog)
+	logger.Debugf("check private data = %t", fsck.checkPvtData)
+	logger.Debugf("check config lineage = %t", fsck.checkConfigLineage)
+	logger.Debugf("check endorsement policies = %t", fsck.checkEndorsementPolicies)
+	if fsck.noSignatureCheck {
+		return nil
+	}
+
 	if fsck.mspConfigPath == "" {
 		errMsg := "MSP folder not configured"
 		logger.Error(errMsg)
@@ -101,7 +190,6 @@ func (fsck *ledgerFsck) ReadConfiguration() error {
 		return errors.New(errMsg)
 	}
 
-	logger.Debugf("channel name = %s", fsck.channelName)
 	logger.Debugf("MSP folder path = %s", fsck.mspConfigPath)
 	logger.Debugf("MSPID = %s", fsck.mspID)
 	logger.Debugf("MSP type = %s", fsck.mspType)
@@ -189,7 +277,12 @@ func (fsck *ledgerFsck) OpenLedger() error {
 	identityDeserializerFactory := func(chainID string) msp.IdentityDeserializer {
 		return mgmt.GetManagerForChain(chainID)
 	}
-	membershipInfoProvider := privdata.NewMembershipInfoProvider(createSelfSignedData(), identityDeserializerFactory)
+	// Without MSP, no local identity is available to sign the data
+	selfSignedData := protoutil.SignedData{}
+	if !fsck.noSignatureCheck {
+		selfSignedData = createSelfSignedData()
+	}
+	membershipInfoProvider := privdata.NewMembershipInfoProvider(selfSignedData, identityDeserializerFactory)
 	opsSystem := newOperationsSystem()
 	err := opsSystem.Start()
 	if err != nil {
@@ -295,29 +388,55 @@ func (fsck *ledgerFsck) GetLatestChannelConfigBundle() error {
 	return nil
 }
 
+// Verify verifies the blocks of the ledger and exits with a failure status if any check fails
 func (fsck *ledgerFsck) Verify() {
-	blockchainInfo, err := fsck.ledger.GetBlockchainInfo()
-	if err != nil {
-		logger.Debugf("could not obtain blockchain information "+
-			"channel name %s, due to %s", fsck.channelName, err)
+	if err := fsck.verify(); err != nil {
+		logger.Debugf("%s", err)
 		logger.Infof("FAIL")
 		os.Exit(-1)
 	}
+	logger.Infof("PASS")
+}
+
+// verify scans the blocks of the ledger and checks their hash chain, their structure and their metadata,
+// and their signatures, private data and endorsements when enabled
+func (fsck *ledgerFsck) verify() error {
+	blockchainInfo, err := fsck.ledger.GetBlockchainInfo()
+	if err != nil {
+		return errors.Errorf("could not obtain blockchain information channel name %s, due to %s", fsck.channelName, err)
+	}
 
 	logger.Debugf("ledger height of channel %s, is %d\n", fsck.channelName, blockchainInfo.Height)
 
-	signer := mgmt.GetLocalSigningIdentityOrPanic()
+	var mcs api.MessageCryptoService
+	metadata := &metadataChecker{}
+	if !fsck.noSignatureCheck {
+		signer := mgmt.GetLocalSigningIdentityOrPanic()
 
-	mcs := gossip.NewMCS(
-		fsck,
-		signer,
-		mgmt.NewDeserializersManager())
+		mcs = gossip.NewMCS(
+			fsck,
+			signer,
+			mgmt.NewDeserializersManager())
+		metadata.policy, _ = fsck.bundle.PolicyManager().GetPolicy(policies.BlockValidation)
+	}
+	anomalies := 0
 
 	block, err := fsck.ledger.GetBlockByNumber(uint64(0))
 	if err != nil {
-		logger.Debugf("failed to read genesis block number, with error", err)
-		logger.Infof("FAIL")
-		os.Exit(-1)
+		return errors.Errorf("failed to read genesis block number, with error %s", err)
+	}
+
+	if err := verifyBlockStructure(block, 0); err != nil {
+		return errors.Errorf("block number [0]: structure check has failed, %s", err)
+	}
+	anomalies += logMetadataAnomalies(0, metadata.check(block))
+
+	var endorsements *endorsementChecker
+	unsatisfied := 0
+	if fsck.checkEndorsementPolicies {
+		if endorsements, err = newEndorsementChecker(fsck.channelName, block); err != nil {
+			return errors.Errorf("block number [0]: endorsement policy check has failed, %s", err)
+		}
 	}
 
 	// Get hash of genesis block
@@ -327,38 +446,68 @@ func (fsck *ledgerFsck) Verify() {
 	for blockIndex := uint64(1); blockIndex < blockchainInfo.Height; blockIndex++ {
 		block, err := fsck.ledger.GetBlockByNumber(blockIndex)
 		if err != nil {
-			logger.Debugf("failed to read block number %d from ledger, with error", blockIndex, err)
-			logger.Infof("FAIL")
-			os.Exit(-1)
+			return errors.Errorf("failed to read block number %d from ledger, with error %s", blockIndex, err)
 		}
 
 		if !bytes.Equal(prevHash, block.Header.PreviousHash) {
-			logger.Debugf("block number [%d]: hash comparison has failed, previous block hash %x doesn't"+
+			return errors.Errorf("block number [%d]: hash comparison has failed, previous block hash %x doesn't"+
 				" equal to hash claimed within block header %x", blockIndex, prevHash, block.Header.PreviousHash)
-			logger.Infof("FAIL")
-			os.Exit(-1)
-		} else {
-			logger.Debugf("block number [%d]: previous hash matched", blockIndex)
 		}
+		logger.Debugf("block number [%d]: previous hash matched", blockIndex)
 
-		signedBlock, err := proto.Marshal(block)
-		if err != nil {
-			logger.Debugf("failed marshaling block, due to", err)
-			logger.Infof("FAIL")
-			os.Exit(-1)
+		if err := verifyBlockStructure(block, blockIndex); err != nil {
+			return errors.Errorf("block number [%d]: structure check has failed, %s", blockIndex, err)
 		}
+		anomalies += logMetadataAnomalies(blockIndex, metadata.check(block))
 
-		if err := mcs.VerifyBlock(gossipCommon.ChainID(fsck.channelName), block.Header.Number, signedBlock); err != nil {
-			logger.Debugf("failed to verify block with sequence number %d. %s", blockIndex, err)
-			logger.Infof("FAIL")
-			os.Exit(-1)
-		} else {
-			logger.Debugf("Block [seq = %d], hash = [%x], previous hash = [%x], VERIFICATION PASSED",
-				blockIndex, protoutil.BlockHeaderHash(block.Header), block.Header.PreviousHash)
+		if mcs != nil {
+			signedBlock, err := proto.Marshal(block)
+			if err != nil {
+				return errors.Errorf("failed marshaling block, due to %s", err)
+			}
+
+			if err := mcs.VerifyBlock(gossipCommon.ChainID(fsck.channelName), block.Header.Number, signedBlock); err != nil {
+				return errors.Errorf("failed to verify block with sequence number %d. %s", blockIndex, err)
+			}
+		}
+		logger.Debugf("Block [seq = %d], hash = [%x], previous hash = [%x], VERIFICATION PASSED",
+			blockIndex, protoutil.BlockHeaderHash(block.Header), block.Header.PreviousHash)
+
+		if endorsements != nil {
+			failures, err := endorsements.check(block)
+			if err != nil {
+				return errors.Errorf("block number [%d]: endorsement policy check has failed, %s", blockIndex, err)
+			}
+			unsatisfied += logEndorsementFailures(blockIndex, failures)
+		}
+
+		if fsck.checkPvtData {
+			report, err := fsck.verifyPvtData(block)
+			if err != nil {
+				return errors.Errorf("failed to check private data of block number %d, due to %s", blockIndex, err)
+			}
+			for _, name := range report.missing {
+				logger.Warningf("block number [%d]: private data of %s is missing in the pvtdata store", blockIndex, name)
+			}
+			for _, name := range report.extra {
+				logger.Debugf("block number [%d]: private data of %s is in the pvtdata store but not in the block", blockIndex, name)
+			}
+			for _, name := range report.mismatched {
+				logger.Debugf("block number [%d]: private data of %s doesn't match the hash in the transaction", blockIndex, name)
+			}
+			if report.failed() {
+				return errors.Errorf("block number [%d]: private data check has failed, %d extra and %d mismatched collections",
+					blockIndex, len(report.extra), len(report.mismatched))
+			}
+			logger.Debugf("block number [%d]: private data hashes matched", blockIndex)
 		}
 		prevHash = protoutil.BlockHeaderHash(block.Header)
 	}
-	logger.Infof("PASS")
+	if anomalies > 0 || unsatisfied > 0 {
+		return errors.Errorf("%d metadata anomalies found, %d transactions don't satisfy the endorsement policy of their chaincode",
+			anomalies, unsatisfied)
+	}
+	return nil
 }
 
 func main() {
@@ -371,17 +520,46 @@ func main() {
 	if err := fsck.ReadConfiguration(); err != nil {
 		os.Exit(-1)
 	}
+	if fsck.rebuildIndex {
+		fsck.RebuildIndex()
+		return
+	}
+	if fsck.rawBlockfiles {
+		fsck.VerifyRawBlockfiles()
+		return
+	}
 	// Init crypto & MSP
-	if err := fsck.InitCrypto(); err != nil {
-		os.Exit(-1)
+	if !fsck.noSignatureCheck {
+		if err := fsck.InitCrypto(); err != nil {
+			os.Exit(-1)
+		}
+	}
+	if fsck.compareWith != "" {
+		fsck.CompareLedgers()
+		return
+	}
+	// Verify a copy of the ledger of a running peer
+	if fsck.snapshotDir != "" {
+		if err := fsck.SnapshotLedger(); err != nil {
+			os.Exit(-1)
+		}
+	}
+	if fsck.checkArchiveCatalog {
+		fsck.VerifyArchiveCatalog()
+		return
 	}
 	// OpenLedger
 	if err := fsck.OpenLedger(); err != nil {
 		os.Exit(-1)
 	}
 	// GetLatestChannelConfigBundle
-	if err := fsck.GetLatestChannelConfigBundle(); err != nil {
-		os.Exit(-1)
+	if !fsck.noSignatureCheck {
+		if err := fsck.GetLatestChannelConfigBundle(); err != nil {
+			os.Exit(-1)
+		}
+	}
+	if fsck.checkConfigLineage {
+		fsck.VerifyConfigLineage()
 	}
 
 	fsck.Verify()
@@ -0,0 +1,233 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureLedger serves the blocks and the private data of the test channel in place of a ledger
+// opened by ledgermgmt. The MSP of the channel is the sample MSP, which is loaded as the local MSP
+// by testutil so that the local signer endorses the transactions and signs the blocks.
+type fixtureLedger struct {
+	ledger.PeerLedger
+	blocks  []*pb.Block
+	pvtData map[uint64][]*ledger.TxPvtData
+}
+
+// newFixtureLedger returns a ledger holding the genesis block of the test channel
+func newFixtureLedger(t *testing.T) *fixtureLedger {
+	_, genesis := testutil.NewBlockGenerator(t, util.GetTestChainID(), true)
+	return &fixtureLedger{blocks: []*pb.Block{genesis}, pvtData: map[uint64][]*ledger.TxPvtData{}}
+}
+
+// add appends a block of the envelopes to the ledger, signed with the LAST_CONFIG index
+func (l *fixtureLedger) add(t *testing.T, envs []*pb.Envelope, lastConfig uint64) *pb.Block {
+	last := l.blocks[len(l.blocks)-1]
+	block := testutil.NewBlock(envs, last.Header.Number+1, protoutil.BlockHeaderHash(last.Header))
+	signBlock(t, block, lastConfig)
+	l.blocks = append(l.blocks, block)
+	return block
+}
+
+func (l *fixtureLedger) GetBlockchainInfo() (*pb.BlockchainInfo, error) {
+	return &pb.BlockchainInfo{Height: uint64(len(l.blocks))}, nil
+}
+
+func (l *fixtureLedger) GetBlockByNumber(blockNum uint64) (*pb.Block, error) {
+	if blockNum >= uint64(len(l.blocks)) || l.blocks[blockNum] == nil {
+		return nil, errors.Errorf("block [%d] not found", blockNum)
+	}
+	return l.blocks[blockNum], nil
+}
+
+func (l *fixtureLedger) GetPvtDataByNum(blockNum uint64, filter ledger.PvtNsCollFilter) ([]*ledger.TxPvtData, error) {
+	return l.pvtData[blockNum], nil
+}
+
+// signBlock writes the SIGNATURES and LAST_CONFIG metadata of a block, signed by the local signer as by an orderer
+func signBlock(t *testing.T, block *pb.Block, lastConfig uint64) {
+	signer := mgmt.GetLocalSigningIdentityOrPanic()
+	sign := func(value []byte) []byte {
+		sigHeader := protoutil.MarshalOrPanic(protoutil.NewSignatureHeaderOrPanic(signer))
+		signature, err := signer.Sign(util.ConcatenateBytes(value, sigHeader, protoutil.BlockHeaderBytes(block.Header)))
+		require.NoError(t, err)
+		return protoutil.MarshalOrPanic(&pb.Metadata{
+			Value:      value,
+			Signatures: []*pb.MetadataSignature{{SignatureHeader: sigHeader, Signature: signature}},
+		})
+	}
+	block.Metadata.Metadata[pb.BlockMetadataIndex_SIGNATURES] = sign(protoutil.MarshalOrPanic(&pb.OrdererBlockMetadata{
+		LastConfig: &pb.LastConfig{Index: lastConfig},
+	}))
+	block.Metadata.Metadata[pb.BlockMetadataIndex_LAST_CONFIG] = sign(protoutil.MarshalOrPanic(&pb.LastConfig{Index: lastConfig}))
+}
+
+// endorserTx returns a transaction of the chaincode with the results, endorsed by the local signer
+func endorserTx(t *testing.T, results *rwsetutil.RWSetBuilder) *pb.Envelope {
+	simulationResults, err := results.GetTxSimulationResults()
+	require.NoError(t, err)
+	pubResults, err := simulationResults.GetPubSimulationBytes()
+	require.NoError(t, err)
+	env, _, err := testutil.ConstructTransaction(t, pubResults, "", true)
+	require.NoError(t, err)
+	return env
+}
+
+// writes returns the results of a transaction writing the value of a key
+func writes(ns, key, value string) *rwsetutil.RWSetBuilder {
+	results := rwsetutil.NewRWSetBuilder()
+	results.AddToWriteSet(ns, key, []byte(value))
+	return results
+}
+
+// readConfiguration parses the command line arguments into a new ledgerFsck
+func readConfiguration(args ...string) (*ledgerFsck, error) {
+	defer func(args []string, commandLine *flag.FlagSet) {
+		os.Args, flag.CommandLine = args, commandLine
+	}(os.Args, flag.CommandLine)
+	os.Args = append([]string{"ledgerfsck"}, args...)
+	flag.CommandLine = flag.NewFlagSet("ledgerfsck", flag.ContinueOnError)
+	fsck := &ledgerFsck{}
+	return fsck, fsck.ReadConfiguration()
+}
+
+func TestReadConfiguration(t *testing.T) {
+	// The MSP configuration is only required to verify the signatures
+	fsck, err := readConfiguration("-channelName", "mychannel")
+	assert.EqualError(t, err, "MSP folder not configured")
+	fsck, err = readConfiguration("-mspPath", "msp")
+	assert.EqualError(t, err, "MSPID was not provided")
+	fsck, err = readConfiguration("-mspPath", "msp", "-mspID", "SampleOrg")
+	assert.NoError(t, err)
+	assert.False(t, fsck.noSignatureCheck)
+
+	fsck, err = readConfiguration("-channelName", "mychannel", "-noSignatureCheck")
+	assert.NoError(t, err)
+	assert.Equal(t, "mychannel", fsck.channelName)
+	assert.True(t, fsck.noSignatureCheck)
+
+	// The modes which read the blockfiles or the catalog only don't check the signatures
+	for _, mode := range []string{"-rawBlockfiles", "-rebuildIndex", "-checkArchiveCatalog", "-compareWith=/var/hyperledger/peer1"} {
+		fsck, err = readConfiguration(mode)
+		assert.NoError(t, err, mode)
+		assert.True(t, fsck.noSignatureCheck, mode)
+	}
+
+	for _, args := range [][]string{
+		{"-checkConfigLineage", "-noSignatureCheck"},
+		{"-checkEndorsementPolicies", "-rawBlockfiles"},
+		{"-snapshotDir", "/tmp/snapshot", "-rebuildIndex"},
+		{"-checkArchiveCatalog", "-checkPvtData"},
+		{"-compareWith", "archive", "-snapshotDir", "/tmp/snapshot"},
+		{"-checkPvtData", "-rawBlockfiles"},
+	} {
+		_, err = readConfiguration(args...)
+		assert.Error(t, err, "%v", args)
+	}
+}
+
+func TestVerifyWithoutSignatureCheck(t *testing.T) {
+	l := newFixtureLedger(t)
+	for i := 0; i < 3; i++ {
+		l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+	}
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), noSignatureCheck: true, ledger: l}
+	assert.NoError(t, fsck.verify())
+
+	// A block whose previous hash doesn't match breaks the hash chain
+	previousHash := l.blocks[2].Header.PreviousHash
+	l.blocks[2].Header.PreviousHash = []byte("tampered")
+	err := fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "block number [2]: hash comparison has failed")
+	l.blocks[2].Header.PreviousHash = previousHash
+
+	// A transaction replaced in a block doesn't match the data hash of the header
+	data := l.blocks[3].Data.Data[0]
+	l.blocks[3].Data.Data[0] = protoutil.MarshalOrPanic(endorserTx(t, writes("foo", "key", "tampered")))
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "block number [3]: structure check has failed, hash of block data")
+	l.blocks[3].Data.Data[0] = data
+
+	// A block which is not well formed
+	l.blocks[1].Data.Data = append(l.blocks[1].Data.Data, []byte("not an envelope"))
+	l.blocks[1].Header.DataHash = protoutil.BlockDataHash(l.blocks[1].Data)
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "block number [1]: structure check has failed, transaction [1] is not a valid envelope")
+
+	// A block which cannot be read
+	l.blocks[1] = nil
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read block number 1 from ledger")
+}
+
+func TestVerifyWithSignatureCheck(t *testing.T) {
+	l := newFixtureLedger(t)
+	for i := 0; i < 2; i++ {
+		l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+	}
+	configEnv, err := configEnvelopeOfBlock(l.blocks[0])
+	require.NoError(t, err)
+	bundle, err := channelconfig.NewBundle(util.GetTestChainID(), configEnv.Config)
+	require.NoError(t, err)
+	mgmt.XXXSetMSPManager(util.GetTestChainID(), bundle.MSPManager())
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), ledger: l, bundle: bundle}
+	assert.NoError(t, fsck.verify())
+
+	// The orderer signature of a block must satisfy the block validation policy
+	metadata, err := protoutil.GetMetadataFromBlock(l.blocks[2], pb.BlockMetadataIndex_SIGNATURES)
+	require.NoError(t, err)
+	metadata.Signatures[0].Signature[len(metadata.Signatures[0].Signature)-1] ^= 0xff
+	l.blocks[2].Metadata.Metadata[pb.BlockMetadataIndex_SIGNATURES] = protoutil.MarshalOrPanic(metadata)
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to verify block with sequence number 2")
+
+	// The LAST_CONFIG metadata is verified against the block validation policy as well
+	signBlock(t, l.blocks[2], 0)
+	metadata, err = protoutil.GetMetadataFromBlock(l.blocks[2], pb.BlockMetadataIndex_LAST_CONFIG)
+	require.NoError(t, err)
+	metadata.Signatures[0].Signature = []byte("forged")
+	l.blocks[2].Metadata.Metadata[pb.BlockMetadataIndex_LAST_CONFIG] = protoutil.MarshalOrPanic(metadata)
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 metadata anomalies found")
+}
+
+func TestVerifyBlockStructure(t *testing.T) {
+	l := newFixtureLedger(t)
+	block := l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+	assert.NoError(t, verifyBlockStructure(block, 1))
+	assert.EqualError(t, verifyBlockStructure(block, 2), "block number in header [1] doesn't match the expected one [2]")
+
+	for name, tamper := range map[string]func(*pb.Block){
+		"block header is missing":   func(b *pb.Block) { b.Header = nil },
+		"block data is missing":     func(b *pb.Block) { b.Data = nil },
+		"block metadata is missing": func(b *pb.Block) { b.Metadata.Metadata = b.Metadata.Metadata[:1] },
+	} {
+		tampered := proto.Clone(block).(*pb.Block)
+		tamper(tampered)
+		assert.EqualError(t, verifyBlockStructure(tampered, 1), name)
+	}
+}
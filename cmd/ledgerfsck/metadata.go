@@ -0,0 +1,135 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/common/util"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric/protoutil"
+)
+
+// metadataChecker verifies the metadata of consecutive blocks: the transaction validation codes,
+// the LAST_CONFIG index and the orderer signatures. It reports all the anomalies of a block instead
+// of stopping at the first one.
+type metadataChecker struct {
+	// lastConfig is the LAST_CONFIG index of the previous block, valid if started
+	lastConfig uint64
+	started    bool
+	// policy verifies the signatures of the LAST_CONFIG metadata written by the orderers which sign it,
+	// nil without MSP. The signatures of the SIGNATURES metadata are verified along with the block by
+	// the message crypto service.
+	policy policies.Policy
+}
+
+// check returns the anomalies of the metadata of the block
+func (c *metadataChecker) check(block *pb.Block) []string {
+	var anomalies []string
+	report := func(format string, args ...interface{}) {
+		anomalies = append(anomalies, fmt.Sprintf(format, args...))
+	}
+	blockNum := block.Header.Number
+
+	txFilter := block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	if len(txFilter) != len(block.Data.Data) {
+		report("transactions filter has [%d] validation codes for [%d] transactions", len(txFilter), len(block.Data.Data))
+	}
+	for txIndex, code := range txFilter {
+		if _, ok := peer.TxValidationCode_name[int32(code)]; !ok {
+			report("transaction [%d] has an unknown validation code [%d]", txIndex, code)
+		}
+	}
+
+	signaturesMetadata, err := c.checkSignatures(block, pb.BlockMetadataIndex_SIGNATURES, true, nil)
+	if err != nil {
+		report("%s", err)
+	}
+	lastConfigMetadata, err := c.checkSignatures(block, pb.BlockMetadataIndex_LAST_CONFIG, false, c.policy)
+	if err != nil {
+		report("%s", err)
+	}
+	if lastConfigMetadata == nil {
+		return anomalies
+	}
+	lastConfig := &pb.LastConfig{}
+	if err := proto.Unmarshal(lastConfigMetadata.Value, lastConfig); err != nil {
+		report("LAST_CONFIG metadata has an invalid value: %s", err)
+		return anomalies
+	}
+	// The orderers sign the LAST_CONFIG index as part of the SIGNATURES metadata
+	if signaturesMetadata != nil && len(signaturesMetadata.Value) > 0 {
+		ordererMetadata := &pb.OrdererBlockMetadata{}
+		if err := proto.Unmarshal(signaturesMetadata.Value, ordererMetadata); err != nil {
+			report("SIGNATURES metadata has an invalid value: %s", err)
+		} else if ordererMetadata.LastConfig != nil && ordererMetadata.LastConfig.Index != lastConfig.Index {
+			report("LAST_CONFIG index [%d] doesn't match the signed one [%d]", lastConfig.Index, ordererMetadata.LastConfig.Index)
+		}
+	}
+	switch {
+	case lastConfig.Index > blockNum:
+		report("LAST_CONFIG index [%d] points to a later block", lastConfig.Index)
+	case protoutil.IsConfigBlock(block) && lastConfig.Index != blockNum:
+		report("LAST_CONFIG index [%d] of a config block doesn't point to the block itself", lastConfig.Index)
+	case !protoutil.IsConfigBlock(block) && c.started && lastConfig.Index != c.lastConfig:
+		report("LAST_CONFIG index [%d] differs from the one of the previous block [%d] without a config update", lastConfig.Index, c.lastConfig)
+	}
+	c.lastConfig = lastConfig.Index
+	c.started = true
+	return anomalies
+}
+
+// checkSignatures parses the metadata at the index and checks that its signatures are well formed,
+// and satisfy the policy if not nil. The genesis block is not signed.
+func (c *metadataChecker) checkSignatures(block *pb.Block, index pb.BlockMetadataIndex, signed bool, policy policies.Policy) (*pb.Metadata, error) {
+	metadata, err := protoutil.GetMetadataFromBlock(block, index)
+	if err != nil {
+		return nil, fmt.Errorf("%s metadata is invalid: %s", index, err)
+	}
+	if block.Header.Number == 0 {
+		return metadata, nil
+	}
+	if len(metadata.Signatures) == 0 {
+		if signed {
+			return metadata, fmt.Errorf("%s metadata is not signed", index)
+		}
+		return metadata, nil
+	}
+	var signatureSet []*protoutil.SignedData
+	for i, signature := range metadata.Signatures {
+		header, err := protoutil.GetSignatureHeader(signature.SignatureHeader)
+		if err != nil {
+			return metadata, fmt.Errorf("signature [%d] of %s metadata has an invalid header: %s", i, index, err)
+		}
+		if len(header.Creator) == 0 || len(header.Nonce) == 0 {
+			return metadata, fmt.Errorf("signature [%d] of %s metadata has no creator or nonce", i, index)
+		}
+		if len(signature.Signature) == 0 {
+			return metadata, fmt.Errorf("signature [%d] of %s metadata is empty", i, index)
+		}
+		signatureSet = append(signatureSet, &protoutil.SignedData{
+			Identity:  header.Creator,
+			Data:      util.ConcatenateBytes(metadata.Value, signature.SignatureHeader, protoutil.BlockHeaderBytes(block.Header)),
+			Signature: signature.Signature,
+		})
+	}
+	if policy != nil {
+		if err := policy.Evaluate(signatureSet); err != nil {
+			return metadata, fmt.Errorf("signatures of %s metadata don't satisfy the block validation policy: %s", index, err)
+		}
+	}
+	return metadata, nil
+}
+
+// logMetadataAnomalies logs the anomalies of the metadata of a block and returns their number
+func logMetadataAnomalies(blockNum uint64, anomalies []string) int {
+	for _, anomaly := range anomalies {
+		logger.Warningf("block number [%d]: metadata anomaly: %s", blockNum, anomaly)
+	}
+	return len(anomalies)
+}
@@ -0,0 +1,158 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/util"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rejectingPolicy is a block validation policy which no signature satisfies
+type rejectingPolicy struct{}
+
+func (rejectingPolicy) Evaluate(signatureSet []*protoutil.SignedData) error {
+	return errors.New("signature set did not satisfy policy")
+}
+
+func TestMetadataChecker(t *testing.T) {
+	l := newFixtureLedger(t)
+	block := l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+
+	checker := &metadataChecker{}
+	assert.Empty(t, checker.check(l.blocks[0]))
+	assert.Empty(t, checker.check(block))
+
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, block *pb.Block)
+		started bool
+		anomaly string
+	}{
+		{
+			name: "missing validation code",
+			tamper: func(t *testing.T, block *pb.Block) {
+				block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{}
+			},
+			anomaly: "transactions filter has [0] validation codes for [1] transactions",
+		},
+		{
+			name: "unknown validation code",
+			tamper: func(t *testing.T, block *pb.Block) {
+				block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{200}
+			},
+			anomaly: "transaction [0] has an unknown validation code [200]",
+		},
+		{
+			name: "unsigned block",
+			tamper: func(t *testing.T, block *pb.Block) {
+				block.Metadata.Metadata[pb.BlockMetadataIndex_SIGNATURES] = nil
+			},
+			anomaly: "SIGNATURES metadata is not signed",
+		},
+		{
+			name: "empty signature",
+			tamper: func(t *testing.T, block *pb.Block) {
+				metadata, err := protoutil.GetMetadataFromBlock(block, pb.BlockMetadataIndex_SIGNATURES)
+				require.NoError(t, err)
+				metadata.Signatures[0].Signature = nil
+				block.Metadata.Metadata[pb.BlockMetadataIndex_SIGNATURES] = protoutil.MarshalOrPanic(metadata)
+			},
+			anomaly: "signature [0] of SIGNATURES metadata is empty",
+		},
+		{
+			name: "signature without creator",
+			tamper: func(t *testing.T, block *pb.Block) {
+				metadata, err := protoutil.GetMetadataFromBlock(block, pb.BlockMetadataIndex_LAST_CONFIG)
+				require.NoError(t, err)
+				metadata.Signatures[0].SignatureHeader = protoutil.MarshalOrPanic(&pb.SignatureHeader{Nonce: []byte("nonce")})
+				block.Metadata.Metadata[pb.BlockMetadataIndex_LAST_CONFIG] = protoutil.MarshalOrPanic(metadata)
+			},
+			anomaly: "signature [0] of LAST_CONFIG metadata has no creator or nonce",
+		},
+		{
+			name: "LAST_CONFIG pointing to a later block",
+			tamper: func(t *testing.T, block *pb.Block) {
+				signBlock(t, block, 5)
+			},
+			anomaly: "LAST_CONFIG index [5] points to a later block",
+		},
+		{
+			name: "LAST_CONFIG changed without a config update",
+			tamper: func(t *testing.T, block *pb.Block) {
+				signBlock(t, block, 1)
+			},
+			started: true,
+			anomaly: "LAST_CONFIG index [1] differs from the one of the previous block [0] without a config update",
+		},
+		{
+			name: "LAST_CONFIG not matching the signed index",
+			tamper: func(t *testing.T, block *pb.Block) {
+				metadata, err := protoutil.GetMetadataFromBlock(block, pb.BlockMetadataIndex_LAST_CONFIG)
+				require.NoError(t, err)
+				metadata.Value = protoutil.MarshalOrPanic(&pb.LastConfig{Index: 1})
+				block.Metadata.Metadata[pb.BlockMetadataIndex_LAST_CONFIG] = protoutil.MarshalOrPanic(metadata)
+			},
+			anomaly: "LAST_CONFIG index [1] doesn't match the signed one [0]",
+		},
+		{
+			name: "invalid LAST_CONFIG value",
+			tamper: func(t *testing.T, block *pb.Block) {
+				block.Metadata.Metadata[pb.BlockMetadataIndex_LAST_CONFIG] = protoutil.MarshalOrPanic(&pb.Metadata{Value: []byte("garbage")})
+			},
+			anomaly: "LAST_CONFIG metadata has an invalid value",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tampered := proto.Clone(block).(*pb.Block)
+			test.tamper(t, tampered)
+			checker := &metadataChecker{started: test.started}
+			anomalies := checker.check(tampered)
+			require.Len(t, anomalies, 1)
+			assert.Contains(t, anomalies[0], test.anomaly)
+		})
+	}
+
+	// A config block must point to itself
+	configBlock := proto.Clone(l.blocks[0]).(*pb.Block)
+	configBlock.Header.Number = 1
+	signBlock(t, configBlock, 0)
+	anomalies := (&metadataChecker{}).check(configBlock)
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, "LAST_CONFIG index [0] of a config block doesn't point to the block itself", anomalies[0])
+
+	// The signatures of the LAST_CONFIG metadata must satisfy the block validation policy
+	anomalies = (&metadataChecker{policy: rejectingPolicy{}}).check(block)
+	require.Len(t, anomalies, 1)
+	assert.Contains(t, anomalies[0], "signatures of LAST_CONFIG metadata don't satisfy the block validation policy")
+
+	// Several anomalies of a block are all reported
+	tampered := proto.Clone(block).(*pb.Block)
+	tampered.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{}
+	tampered.Metadata.Metadata[pb.BlockMetadataIndex_SIGNATURES] = nil
+	assert.Len(t, (&metadataChecker{}).check(tampered), 2)
+	assert.Equal(t, 2, logMetadataAnomalies(1, (&metadataChecker{}).check(tampered)))
+}
+
+func TestVerifyReportsMetadataAnomalies(t *testing.T) {
+	l := newFixtureLedger(t)
+	for i := 0; i < 3; i++ {
+		l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "value"))}, 0)
+	}
+	// The anomalies of all the blocks are reported at the end of the scan
+	l.blocks[1].Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{200}
+	l.blocks[3].Metadata.Metadata[pb.BlockMetadataIndex_SIGNATURES] = nil
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), noSignatureCheck: true, ledger: l}
+	err := fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 metadata anomalies found")
+}
@@ -0,0 +1,139 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/core/ledger/util"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// pvtDataReport summarizes the result of cross-checking the private data hashes
+// of the transactions in a block against the contents of the pvtdata store
+type pvtDataReport struct {
+	// Collections whose hash is in a transaction but which are not in the pvtdata store.
+	// They are expected for collections the peer is not a member of, or whose data has been purged.
+	missing []string
+	// Collections which are in the pvtdata store but not referenced by any valid transaction
+	extra []string
+	// Collections whose content in the pvtdata store doesn't match the hash in the transaction
+	mismatched []string
+}
+
+func (r *pvtDataReport) failed() bool {
+	return len(r.extra) > 0 || len(r.mismatched) > 0
+}
+
+// verifyPvtData reads the private data of the block from the pvtdata store
+// and cross-checks it against the private data hashes in the transactions
+func (fsck *ledgerFsck) verifyPvtData(block *pb.Block) (*pvtDataReport, error) {
+	pvtData, err := fsck.ledger.GetPvtDataByNum(block.Header.Number, nil)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read private data from the pvtdata store")
+	}
+	return checkPvtDataHashes(block, pvtData)
+}
+
+// checkPvtDataHashes compares the collections in the private data of each valid transaction
+// of the block with the collection hashes in its public read-write set
+func checkPvtDataHashes(block *pb.Block, pvtData []*ledger.TxPvtData) (*pvtDataReport, error) {
+	pvtDataBySeq := make(map[uint64]*ledger.TxPvtData)
+	for _, txPvtData := range pvtData {
+		pvtDataBySeq[txPvtData.SeqInBlock] = txPvtData
+	}
+
+	report := &pvtDataReport{}
+	txsFilter := util.TxValidationFlags(block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER])
+	for txIndex, envBytes := range block.Data.Data {
+		seqInBlock := uint64(txIndex)
+		txPvtData := pvtDataBySeq[seqInBlock]
+		delete(pvtDataBySeq, seqInBlock)
+
+		var txRwSet *rwsetutil.TxRwSet
+		if !txsFilter.IsInvalid(txIndex) {
+			var err error
+			if txRwSet, err = extractTxRwSet(envBytes); err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("failed to extract read-write set of transaction [%d]", txIndex))
+			}
+		}
+		compareTxPvtData(report, seqInBlock, txRwSet, txPvtData)
+	}
+
+	// Private data of transactions which are not in the block
+	for seqInBlock, txPvtData := range pvtDataBySeq {
+		compareTxPvtData(report, seqInBlock, nil, txPvtData)
+	}
+	return report, nil
+}
+
+func compareTxPvtData(report *pvtDataReport, seqInBlock uint64, txRwSet *rwsetutil.TxRwSet, txPvtData *ledger.TxPvtData) {
+	pvtHashes := make(map[string][]byte)
+	if txPvtData != nil && txPvtData.WriteSet != nil {
+		for _, nsPvtRwSet := range txPvtData.WriteSet.NsPvtRwset {
+			for _, collPvtRwSet := range nsPvtRwSet.CollectionPvtRwset {
+				pvtHashes[collectionName(seqInBlock, nsPvtRwSet.Namespace, collPvtRwSet.CollectionName)] =
+					util.ComputeHash(collPvtRwSet.Rwset)
+			}
+		}
+	}
+
+	if txRwSet != nil {
+		for _, nsRwSet := range txRwSet.NsRwSets {
+			for _, collHashedRwSet := range nsRwSet.CollHashedRwSets {
+				name := collectionName(seqInBlock, nsRwSet.NameSpace, collHashedRwSet.CollectionName)
+				pvtHash, ok := pvtHashes[name]
+				delete(pvtHashes, name)
+				switch {
+				case !ok:
+					report.missing = append(report.missing, name)
+				case !bytes.Equal(pvtHash, collHashedRwSet.PvtRwSetHash):
+					report.mismatched = append(report.mismatched, name)
+				}
+			}
+		}
+	}
+
+	for name := range pvtHashes {
+		report.extra = append(report.extra, name)
+	}
+}
+
+// extractTxRwSet returns the public read-write set of an endorser transaction, nil for the other transaction types
+func extractTxRwSet(envBytes []byte) (*rwsetutil.TxRwSet, error) {
+	env, err := protoutil.GetEnvelopeFromBlock(envBytes)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := protoutil.GetPayload(env)
+	if err != nil {
+		return nil, err
+	}
+	chdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil {
+		return nil, err
+	}
+	if pb.HeaderType(chdr.Type) != pb.HeaderType_ENDORSER_TRANSACTION {
+		return nil, nil
+	}
+	respPayload, err := protoutil.GetActionFromEnvelope(envBytes)
+	if err != nil {
+		return nil, err
+	}
+	txRwSet := &rwsetutil.TxRwSet{}
+	if err := txRwSet.FromProtoBytes(respPayload.Results); err != nil {
+		return nil, err
+	}
+	return txRwSet, nil
+}
+
+func collectionName(seqInBlock uint64, ns, coll string) string {
+	return fmt.Sprintf("tx[%d] %s:%s", seqInBlock, ns, coll)
+}
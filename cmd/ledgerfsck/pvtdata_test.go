@@ -0,0 +1,118 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	lutil "github.com/hyperledger/fabric/core/ledger/util"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/rwset"
+	"github.com/hyperledger/fabric/protos/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pvtWrites returns the results of a transaction writing the value of a key of a collection,
+// along with the private data of the transaction
+func pvtWrites(t *testing.T, ns, coll, key, value string) (*rwsetutil.RWSetBuilder, *rwset.TxPvtReadWriteSet) {
+	results := rwsetutil.NewRWSetBuilder()
+	results.AddToPvtAndHashedWriteSet(ns, coll, key, []byte(value))
+	simulationResults, err := results.GetTxSimulationResults()
+	require.NoError(t, err)
+	return results, simulationResults.PvtSimulationResults
+}
+
+func TestCheckPvtDataHashes(t *testing.T) {
+	l := newFixtureLedger(t)
+	results1, pvt1 := pvtWrites(t, "foo", "coll1", "key", "value1")
+	results2, pvt2 := pvtWrites(t, "foo", "coll2", "key", "value2")
+	_, otherPvt := pvtWrites(t, "foo", "coll2", "key", "other")
+	block := l.add(t, []*pb.Envelope{
+		endorserTx(t, results1),
+		endorserTx(t, results2),
+		endorserTx(t, writes("foo", "key", "public")),
+	}, 0)
+
+	report, err := checkPvtDataHashes(block, []*ledger.TxPvtData{
+		{SeqInBlock: 0, WriteSet: pvt1},
+		{SeqInBlock: 1, WriteSet: pvt2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &pvtDataReport{}, report)
+	assert.False(t, report.failed())
+
+	// The private data of a collection the peer is not a member of is missing
+	report, err = checkPvtDataHashes(block, []*ledger.TxPvtData{{SeqInBlock: 0, WriteSet: pvt1}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx[1] foo:coll2"}, report.missing)
+	assert.False(t, report.failed())
+
+	// The private data which doesn't match the hash of the transaction
+	report, err = checkPvtDataHashes(block, []*ledger.TxPvtData{
+		{SeqInBlock: 0, WriteSet: pvt1},
+		{SeqInBlock: 1, WriteSet: otherPvt},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx[1] foo:coll2"}, report.mismatched)
+	assert.True(t, report.failed())
+
+	// The private data of a transaction without collections, or which is not in the block
+	report, err = checkPvtDataHashes(block, []*ledger.TxPvtData{
+		{SeqInBlock: 0, WriteSet: pvt1},
+		{SeqInBlock: 1, WriteSet: pvt2},
+		{SeqInBlock: 2, WriteSet: pvt2},
+		{SeqInBlock: 7, WriteSet: pvt1},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tx[2] foo:coll2", "tx[7] foo:coll1"}, report.extra)
+	assert.True(t, report.failed())
+
+	// The private data of an invalid transaction is not expected
+	block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER][0] = uint8(peer.TxValidationCode_MVCC_READ_CONFLICT)
+	report, err = checkPvtDataHashes(block, []*ledger.TxPvtData{
+		{SeqInBlock: 0, WriteSet: pvt1},
+		{SeqInBlock: 1, WriteSet: pvt2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tx[0] foo:coll1"}, report.extra)
+
+	// A valid transaction without a read-write set
+	block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER] = lutil.NewTxValidationFlagsSetValue(4, peer.TxValidationCode_VALID)
+	block.Data.Data = append(block.Data.Data, []byte("not an envelope"))
+	_, err = checkPvtDataHashes(block, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to extract read-write set of transaction [3]")
+}
+
+func TestVerifyPvtData(t *testing.T) {
+	l := newFixtureLedger(t)
+	results, pvt := pvtWrites(t, "foo", "coll1", "key", "value")
+	_, otherPvt := pvtWrites(t, "foo", "coll1", "key", "other")
+	l.add(t, []*pb.Envelope{endorserTx(t, results)}, 0)
+	l.add(t, []*pb.Envelope{endorserTx(t, writes("foo", "key", "public"))}, 0)
+	l.pvtData[1] = []*ledger.TxPvtData{{SeqInBlock: 0, WriteSet: pvt}}
+
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), noSignatureCheck: true, checkPvtData: true, ledger: l}
+	assert.NoError(t, fsck.verify())
+
+	// Missing private data is reported without failing the verification
+	delete(l.pvtData, 1)
+	assert.NoError(t, fsck.verify())
+
+	l.pvtData[1] = []*ledger.TxPvtData{{SeqInBlock: 0, WriteSet: otherPvt}}
+	err := fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "block number [1]: private data check has failed, 0 extra and 1 mismatched collections")
+
+	l.pvtData[1] = []*ledger.TxPvtData{{SeqInBlock: 0, WriteSet: pvt}}
+	l.pvtData[2] = []*ledger.TxPvtData{{SeqInBlock: 0, WriteSet: pvt}}
+	err = fsck.verify()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "block number [2]: private data check has failed, 1 extra and 0 mismatched collections")
+}
@@ -0,0 +1,88 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// VerifyRawBlockfiles parses the blockfiles of the channel directly, bypassing the ledger management
+// and the block index, and verifies the framing and the structure of the blocks and the hash chain.
+// Blockfiles which have been discarded after archiving are not present locally, so the hash chain
+// starts from the first block found on the local file system.
+func (fsck *ledgerFsck) VerifyRawBlockfiles() {
+	if err := fsck.verifyRawBlockfiles(ledgerconfig.GetBlockStorePath()); err != nil {
+		logger.Debugf("raw blockfile verification of channel %s has failed, %s", fsck.channelName, err)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	logger.Infof("PASS")
+}
+
+func (fsck *ledgerFsck) verifyRawBlockfiles(blockStorePath string) error {
+	fileNums, err := fsblkstorage.ListRawBlockfiles(blockStorePath, fsck.channelName)
+	if err != nil {
+		return err
+	}
+	if len(fileNums) == 0 {
+		return errors.Errorf("no blockfile found for channel %s", fsck.channelName)
+	}
+
+	var prevBlock *pb.Block
+	metadata := &metadataChecker{}
+	anomalies := 0
+	for i, fileNum := range fileNums {
+		if i > 0 && fileNum != fileNums[i-1]+1 {
+			return errors.Errorf("blockfiles [%d] to [%d] are missing", fileNums[i-1]+1, fileNum-1)
+		}
+		filePath := fsblkstorage.RawBlockfilePath(blockStorePath, fsck.channelName, fileNum)
+		logger.Debugf("scanning blockfile %s", filePath)
+
+		err := fsblkstorage.ScanRawBlockfile(filePath, func(offset int64, block *pb.Block) error {
+			if block.Header == nil {
+				return errors.Errorf("block at offset [%d] of blockfile %s has no header", offset, filePath)
+			}
+			blockNum := block.Header.Number
+			if prevBlock == nil {
+				if blockNum != 0 {
+					logger.Warningf("first local block is block number [%d], the previous blocks are not verified", blockNum)
+				}
+			} else {
+				blockNum = prevBlock.Header.Number + 1
+				if prevHash := protoutil.BlockHeaderHash(prevBlock.Header); !bytes.Equal(prevHash, block.Header.PreviousHash) {
+					return errors.Errorf("block number [%d]: hash comparison has failed, previous block hash %x doesn't"+
+						" equal to hash claimed within block header %x", blockNum, prevHash, block.Header.PreviousHash)
+				}
+			}
+			if err := verifyBlockStructure(block, blockNum); err != nil {
+				return errors.WithMessagef(err, "block number [%d] at offset [%d] of blockfile %s", blockNum, offset, filePath)
+			}
+			anomalies += logMetadataAnomalies(blockNum, metadata.check(block))
+			logger.Debugf("block number [%d] at offset [%d] of blockfile %s, VERIFICATION PASSED", blockNum, offset, filePath)
+			prevBlock = block
+			return nil
+		})
+
+		if rawErr, ok := err.(*fsblkstorage.RawBlockfileError); ok && rawErr.IsTruncated() && i == len(fileNums)-1 {
+			// The peer discards a partially written block at the end of the last blockfile when it restarts
+			logger.Warningf("%s, the partially written block is ignored", rawErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if anomalies > 0 {
+		return errors.Errorf("%d metadata anomalies found", anomalies)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+)
+
+// RebuildIndex reconstructs the block index of the channel from the local blockfiles and the archive
+// catalog, for recovery after a corruption of the index db. The blocks of the archived blockfiles
+// which have been discarded are read from the repository.
+func (fsck *ledgerFsck) RebuildIndex() {
+	conf := fsblkstorage.NewConf(
+		ledgerconfig.GetBlockStorePath(),
+		ledgerconfig.GetMaxBlockfileSize(),
+		ledgerconfig.GetBlockArchiverURL(),
+		ledgerconfig.GetBlockArchiverDir(),
+	).WithChannelMaxBlockfileSize(ledgerconfig.GetChannelMaxBlockfileSize)
+	// Same attributes as the ones indexed by the peer
+	indexConfig := &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{
+		blkstorage.IndexableAttrBlockHash,
+		blkstorage.IndexableAttrBlockNum,
+		blkstorage.IndexableAttrTxID,
+		blkstorage.IndexableAttrBlockNumTranNum,
+		blkstorage.IndexableAttrBlockTxID,
+		blkstorage.IndexableAttrTxValidationCode,
+	}}
+	if err := fsblkstorage.RebuildIndex(conf, indexConfig, fsck.channelName); err != nil {
+		logger.Debugf("failed to rebuild the block index of channel %s, due to %s", fsck.channelName, err)
+		logger.Infof("FAIL")
+		os.Exit(-1)
+	}
+	logger.Infof("PASS")
+}
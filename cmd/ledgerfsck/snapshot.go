@@ -0,0 +1,178 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// snapshotAttempts is the number of times the ledger data is copied again when
+// the peer deletes a file being copied, by a compaction or by the archiver
+const snapshotAttempts = 5
+
+// levelDBLockFile is locked by the process which opens a leveldb, it is not copied
+const levelDBLockFile = "LOCK"
+
+// SnapshotLedger copies the ledger data of the channel into the snapshot directory and points the
+// ledger configuration to the copy, so that the ledger is verified while the peer is running. The
+// databases of a running peer are locked, and the recovery done when the ledger is opened writes
+// to them, so the ledger of the peer itself is never opened.
+//
+// The databases are copied before the blockfiles, so that the copy is like the ledger of a peer which
+// has stopped abruptly: the state and history are brought up to the blocks when the copy is opened, and
+// the private data of the blocks committed while the ledger is copied is reported missing. The copy is
+// kept to be inspected or verified again, and is removed by the operator.
+func (fsck *ledgerFsck) SnapshotLedger() error {
+	if ledgerconfig.IsCouchDBEnabled() {
+		errMsg := "snapshotDir is not supported with a CouchDB state database"
+		logger.Error(errMsg)
+		return errors.New(errMsg)
+	}
+	if entries, err := ioutil.ReadDir(fsck.snapshotDir); err == nil && len(entries) > 0 {
+		errMsg := "snapshot directory " + fsck.snapshotDir + " is not empty"
+		logger.Error(errMsg)
+		return errors.New(errMsg)
+	}
+
+	rootPath := ledgerconfig.GetRootPath()
+	snapshotRootPath := filepath.Join(fsck.snapshotDir, filepath.Base(rootPath))
+	var err error
+	for attempt := 1; attempt <= snapshotAttempts; attempt++ {
+		if err = os.RemoveAll(snapshotRootPath); err != nil {
+			break
+		}
+		if err = fsck.copyLedgerData(rootPath, snapshotRootPath); err == nil || !os.IsNotExist(errors.Cause(err)) {
+			break
+		}
+		logger.Debugf("ledger data changed while it was copied (attempt %d), %s", attempt, err)
+	}
+	if err != nil {
+		logger.Errorf("failed to copy the ledger data of channel %s into %s, because of %s", fsck.channelName, fsck.snapshotDir, err)
+		return err
+	}
+
+	// The ledger paths are all derived from the file system path of the peer
+	viper.Set("peer.fileSystemPath", fsck.snapshotDir)
+	logger.Debugf("ledger data of channel %s copied into %s", fsck.channelName, snapshotRootPath)
+	return nil
+}
+
+func (fsck *ledgerFsck) copyLedgerData(rootPath, snapshotRootPath string) error {
+	relative := func(path string) string {
+		return strings.TrimPrefix(path, rootPath+string(filepath.Separator))
+	}
+	blockStorePath := ledgerconfig.GetBlockStorePath()
+	// The state, history and bookkeeping databases are updated after the block is added
+	// to the block store, and the private data is prepared before it
+	dbPaths := []string{
+		ledgerconfig.GetLedgerProviderPath(),
+		ledgerconfig.GetStateLevelDBPath(),
+		ledgerconfig.GetHistoryLevelDBPath(),
+		ledgerconfig.GetInternalBookkeeperPath(),
+		ledgerconfig.GetConfigHistoryPath(),
+		ledgerconfig.GetPvtdataStorePath(),
+		filepath.Join(blockStorePath, fsblkstorage.IndexDir),
+	}
+	for _, dbPath := range dbPaths {
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyLevelDB(dbPath, filepath.Join(snapshotRootPath, relative(dbPath))); err != nil {
+			return err
+		}
+	}
+
+	// The blockfiles are appended to, so they hold at least the blocks of the index copied before.
+	// A block partially written at the end of the last blockfile is discarded when the copy is opened.
+	blockDir := filepath.Join(blockStorePath, fsblkstorage.ChainsDir, fsck.channelName)
+	return copyDir(blockDir, filepath.Join(snapshotRootPath, relative(blockDir)))
+}
+
+// copyLevelDB copies a leveldb which may be open by another process. CURRENT names the manifest,
+// which lists the table and journal files of the database, so they are copied first. The tables are
+// never modified, they are linked instead of copied when possible so that the journals listed by the
+// manifest are copied before a compaction deletes them. The deletion of a file listed by the manifest
+// before it is copied fails the copy with a not exist error.
+func copyLevelDB(src, dst string) error {
+	current, err := ioutil.ReadFile(filepath.Join(src, "CURRENT"))
+	if err != nil {
+		return errors.Wrapf(err, "error reading the manifest of %s", src)
+	}
+	manifest := strings.TrimSpace(string(current))
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return errors.Wrapf(err, "error creating %s", dst)
+	}
+	for _, name := range []string{"CURRENT", manifest} {
+		if err := copyFile(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+	fileInfos, err := ioutil.ReadDir(src)
+	if err != nil {
+		return errors.Wrapf(err, "error reading directory %s", src)
+	}
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		if !fileInfo.Mode().IsRegular() || name == levelDBLockFile || name == "CURRENT" || strings.HasPrefix(name, "MANIFEST-") {
+			continue
+		}
+		srcFile, dstFile := filepath.Join(src, name), filepath.Join(dst, name)
+		if filepath.Ext(name) == ".ldb" || filepath.Ext(name) == ".sst" {
+			if err := os.Link(srcFile, dstFile); err == nil {
+				continue
+			}
+		}
+		if err := copyFile(srcFile, dstFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyDir copies the regular files of a directory
+func copyDir(src, dst string) error {
+	fileInfos, err := ioutil.ReadDir(src)
+	if err != nil {
+		return errors.Wrapf(err, "error reading directory %s", src)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return errors.Wrapf(err, "error creating %s", dst)
+	}
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, fileInfo.Name()), filepath.Join(dst, fileInfo.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", src)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "error creating %s", dst)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return errors.Wrapf(err, "error copying %s", src)
+	}
+	return out.Close()
+}
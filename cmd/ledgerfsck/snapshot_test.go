@@ -0,0 +1,147 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setFileSystemPath points the ledger configuration to a new temporary directory,
+// the returned function restores the configuration and removes the directory
+func setFileSystemPath(t *testing.T) func() {
+	fileSystemPath, err := ioutil.TempDir("", "ledgerfsck")
+	require.NoError(t, err)
+	previous := viper.Get("peer.fileSystemPath")
+	viper.Set("peer.fileSystemPath", fileSystemPath)
+	return func() {
+		viper.Set("peer.fileSystemPath", previous)
+		os.RemoveAll(fileSystemPath)
+	}
+}
+
+// openLevelDB opens a leveldb holding the key, which stays open like the databases of a running peer
+func openLevelDB(t *testing.T, path string, key, value string) *leveldbhelper.DB {
+	db := leveldbhelper.CreateDB(&leveldbhelper.Conf{DBPath: path})
+	db.Open()
+	require.NoError(t, db.Put([]byte(key), []byte(value), true))
+	return db
+}
+
+// readLevelDB returns the value of a key of a leveldb
+func readLevelDB(t *testing.T, path string, key string) string {
+	db := leveldbhelper.CreateDB(&leveldbhelper.Conf{DBPath: path})
+	db.Open()
+	defer db.Close()
+	value, err := db.Get([]byte(key))
+	require.NoError(t, err)
+	return string(value)
+}
+
+func TestSnapshotLedger(t *testing.T) {
+	defer setFileSystemPath(t)()
+	stateDB := openLevelDB(t, ledgerconfig.GetStateLevelDBPath(), "state", "value")
+	defer stateDB.Close()
+	indexDB := openLevelDB(t, filepath.Join(ledgerconfig.GetBlockStorePath(), fsblkstorage.IndexDir), "index", "value")
+	defer indexDB.Close()
+	blockDir := filepath.Join(ledgerconfig.GetBlockStorePath(), fsblkstorage.ChainsDir, util.GetTestChainID())
+	require.NoError(t, os.MkdirAll(blockDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(blockDir, "blockfile_000000"), []byte("blocks"), 0644))
+	// The blockfiles of the other channels are not copied
+	otherBlockDir := filepath.Join(ledgerconfig.GetBlockStorePath(), fsblkstorage.ChainsDir, "otherchannel")
+	require.NoError(t, os.MkdirAll(otherBlockDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(otherBlockDir, "blockfile_000000"), []byte("blocks"), 0644))
+
+	snapshotDir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(snapshotDir)
+	rootPath := ledgerconfig.GetRootPath()
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), snapshotDir: snapshotDir}
+	require.NoError(t, fsck.SnapshotLedger())
+
+	// The ledger configuration points to the copy
+	assert.Equal(t, filepath.Join(snapshotDir, filepath.Base(rootPath)), ledgerconfig.GetRootPath())
+	assert.Equal(t, "value", readLevelDB(t, ledgerconfig.GetStateLevelDBPath(), "state"))
+	assert.Equal(t, "value", readLevelDB(t, filepath.Join(ledgerconfig.GetBlockStorePath(), fsblkstorage.IndexDir), "index"))
+	blocks, err := ioutil.ReadFile(filepath.Join(ledgerconfig.GetBlockStorePath(), fsblkstorage.ChainsDir, util.GetTestChainID(), "blockfile_000000"))
+	require.NoError(t, err)
+	assert.Equal(t, "blocks", string(blocks))
+	_, err = os.Stat(filepath.Join(ledgerconfig.GetBlockStorePath(), fsblkstorage.ChainsDir, "otherchannel"))
+	assert.True(t, os.IsNotExist(err))
+	// The databases which don't exist are not created
+	_, err = os.Stat(ledgerconfig.GetHistoryLevelDBPath())
+	assert.True(t, os.IsNotExist(err))
+
+	// The databases of the peer are still open and unchanged
+	value, err := stateDB.Get([]byte("state"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(value))
+}
+
+func TestCopyLevelDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leveldb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := openLevelDB(t, filepath.Join(dir, "src"), "key", "value")
+	defer db.Close()
+
+	// The lock of the process which opened the database is not copied
+	require.NoError(t, copyLevelDB(filepath.Join(dir, "src"), filepath.Join(dir, "dst")))
+	_, err = os.Stat(filepath.Join(dir, "dst", levelDBLockFile))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, "value", readLevelDB(t, filepath.Join(dir, "dst"), "key"))
+
+	// A database is copied from the manifest named by CURRENT
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nocurrent"), 0755))
+	err = copyLevelDB(filepath.Join(dir, "nocurrent"), filepath.Join(dir, "dst2"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading the manifest of")
+
+	// The deletion of the manifest before it is copied is reported as a not exist error, so that the copy is retried
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "deleted"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "deleted", "CURRENT"), []byte("MANIFEST-000099\n"), 0644))
+	err = copyLevelDB(filepath.Join(dir, "deleted"), filepath.Join(dir, "dst3"))
+	require.Error(t, err)
+	assert.True(t, os.IsNotExist(errors.Cause(err)))
+}
+
+func TestSnapshotLedgerFailures(t *testing.T) {
+	defer setFileSystemPath(t)()
+	snapshotDir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(snapshotDir)
+	fsck := &ledgerFsck{channelName: util.GetTestChainID(), snapshotDir: snapshotDir}
+
+	// The ledger of the channel must have blockfiles
+	err = fsck.SnapshotLedger()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading directory")
+
+	// A state database with a manifest which cannot be read fails the copy
+	require.NoError(t, os.MkdirAll(ledgerconfig.GetStateLevelDBPath(), 0755))
+	err = fsck.SnapshotLedger()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading the manifest of "+ledgerconfig.GetStateLevelDBPath())
+
+	// The snapshot directory must be empty
+	require.NoError(t, ioutil.WriteFile(filepath.Join(snapshotDir, "file"), []byte("data"), 0644))
+	assert.EqualError(t, fsck.SnapshotLedger(), "snapshot directory "+snapshotDir+" is not empty")
+
+	// The CouchDB state database cannot be copied
+	viper.Set("ledger.state.stateDatabase", "CouchDB")
+	defer viper.Set("ledger.state.stateDatabase", "goleveldb")
+	assert.EqualError(t, fsck.SnapshotLedger(), "snapshotDir is not supported with a CouchDB state database")
+}
@@ -0,0 +1,51 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package main
+
+import (
+	"bytes"
+
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// verifyBlockStructure checks that the block is well formed, i.e. that it has the expected number,
+// that its data hash matches the header and that all the transactions are valid envelopes.
+// It doesn't require any MSP configuration.
+func verifyBlockStructure(block *pb.Block, blockNum uint64) error {
+	if block.Header == nil {
+		return errors.New("block header is missing")
+	}
+	if block.Data == nil {
+		return errors.New("block data is missing")
+	}
+	if block.Metadata == nil || len(block.Metadata.Metadata) < len(pb.BlockMetadataIndex_name) {
+		return errors.New("block metadata is missing")
+	}
+	if block.Header.Number != blockNum {
+		return errors.Errorf("block number in header [%d] doesn't match the expected one [%d]", block.Header.Number, blockNum)
+	}
+	if dataHash := protoutil.BlockDataHash(block.Data); !bytes.Equal(dataHash, block.Header.DataHash) {
+		return errors.Errorf("hash of block data [%x] doesn't match the data hash in header [%x]", dataHash, block.Header.DataHash)
+	}
+	for txIndex, envBytes := range block.Data.Data {
+		env, err := protoutil.GetEnvelopeFromBlock(envBytes)
+		if err != nil {
+			return errors.WithMessagef(err, "transaction [%d] is not a valid envelope", txIndex)
+		}
+		payload, err := protoutil.GetPayload(env)
+		if err != nil {
+			return errors.WithMessagef(err, "transaction [%d] has an invalid payload", txIndex)
+		}
+		if payload.Header == nil {
+			return errors.Errorf("transaction [%d] has no header", txIndex)
+		}
+		if _, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader); err != nil {
+			return errors.WithMessagef(err, "transaction [%d] has an invalid channel header", txIndex)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// peer-archiver is the sidecar of an archiver peer configured with peer.archiver.sidecar.enabled.
+// It archives the blockfiles of the ledgers of the peer to the repository out of process, reading
+// the ledgers data directory of the peer only, and reports the archived blockfiles to the peer,
+// which records them in its archive catalogs and discards its local copies.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/fabric/core/archiver/agent"
+)
+
+func main() {
+	configPath := flag.String("config", "peer-archiver.yaml", "path to the configuration file of the sidecar")
+	once := flag.Bool("once", false, "archive and report the eligible blockfiles once and exit")
+	flag.Parse()
+
+	config, err := agent.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if config.Peer == nil {
+		fmt.Fprintf(os.Stderr, "peer is not configured in %s\n", *configPath)
+		os.Exit(-1)
+	}
+	a, err := agent.New(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	if *once {
+		archived, err := a.ArchiveOnce()
+		a.Stop()
+		fmt.Printf("archived: %d\n", archived)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		return
+	}
+	a.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	a.Stop()
+}
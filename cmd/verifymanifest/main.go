@@ -0,0 +1,214 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// verifymanifest proves when and by whom blockfiles were archived
+// by verifying the signed manifests produced by the archiver peer.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	mspprotos "github.com/hyperledger/fabric/protos/msp"
+	"github.com/pkg/errors"
+)
+
+type manifestVerifier struct {
+	manifestPath  string
+	blockfilePath string
+	summaryPath   string
+	mspConfigPath string
+	mspID         string
+	mspType       string
+
+	msp msp.MSP
+}
+
+// ReadConfiguration read configuration parameters
+func (v *manifestVerifier) ReadConfiguration() error {
+	flag.StringVar(&v.manifestPath, "manifest", "", "path to the manifest file, or to a directory of manifest files")
+	flag.StringVar(&v.blockfilePath, "blockfile", "", "path to the blockfile to check against the manifest (optional)")
+	flag.StringVar(&v.summaryPath, "summary", "", "path to the summary of the blockfile to check against the manifest (optional)")
+	flag.StringVar(&v.mspConfigPath, "mspPath", "", "path to the msp folder of the organization of the archiver peer")
+	flag.StringVar(&v.mspID, "mspID", "", "the MSP identity of the organization of the archiver peer")
+	flag.StringVar(&v.mspType, "mspType", "bccsp", "the type of the MSP provider, default bccsp")
+	flag.Parse()
+
+	if v.manifestPath == "" {
+		return errors.New("manifest was not provided")
+	}
+	if v.mspConfigPath == "" {
+		return errors.New("MSP folder not configured")
+	}
+	if v.mspID == "" {
+		return errors.New("MSPID was not provided")
+	}
+	return nil
+}
+
+// InitMSP sets up the MSP which verifies the signer of the manifests
+func (v *manifestVerifier) InitMSP() error {
+	mspType := v.mspType
+	if mspType == "bccsp" {
+		mspType = msp.ProviderTypeToString(msp.FABRIC)
+	}
+	conf, err := msp.GetVerifyingMspConfig(v.mspConfigPath, v.mspID, mspType)
+	if err != nil {
+		return errors.WithMessage(err, "failed to load MSP configuration")
+	}
+	v.msp, err = msp.New(&msp.BCCSPNewOpts{NewBaseOpts: msp.NewBaseOpts{Version: msp.MSPv1_3}})
+	if err != nil {
+		return errors.WithMessage(err, "failed to create MSP")
+	}
+	if err := v.msp.Setup(conf); err != nil {
+		return errors.WithMessage(err, "failed to set up MSP")
+	}
+	return nil
+}
+
+// Verify verifies all the manifests and returns whether they all passed
+func (v *manifestVerifier) Verify() bool {
+	paths, err := v.listManifests()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if (v.blockfilePath != "" || v.summaryPath != "") && len(paths) != 1 {
+		fmt.Fprintln(os.Stderr, "a blockfile or a summary can be checked against a single manifest only")
+		return false
+	}
+
+	passed := true
+	for _, path := range paths {
+		if err := v.verifyManifest(path); err != nil {
+			fmt.Printf("%s: FAIL (%s)\n", path, err)
+			passed = false
+		}
+	}
+	return passed
+}
+
+func (v *manifestVerifier) listManifests() ([]string, error) {
+	info, err := os.Stat(v.manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{v.manifestPath}, nil
+	}
+	files, err := ioutil.ReadDir(v.manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), blockarchive.ManifestSuffix) {
+			paths = append(paths, filepath.Join(v.manifestPath, file.Name()))
+		}
+	}
+	return paths, nil
+}
+
+func (v *manifestVerifier) verifyManifest(path string) error {
+	signedBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	signed := &archive.SignedArchiveManifest{}
+	if err := proto.Unmarshal(signedBytes, signed); err != nil {
+		return errors.Wrap(err, "error unmarshaling manifest")
+	}
+	manifest, identity, err := blockarchive.VerifyManifest(signed, v.msp)
+	if err != nil {
+		return err
+	}
+	if v.blockfilePath != "" {
+		if err := blockarchive.VerifyBlockfileAgainstManifest(manifest, v.blockfilePath); err != nil {
+			return err
+		}
+	}
+	if v.summaryPath != "" {
+		if err := verifySummary(manifest, v.summaryPath); err != nil {
+			return err
+		}
+	}
+
+	archivedAt := "unknown"
+	if ts, err := ptypes.Timestamp(manifest.Timestamp); err == nil {
+		archivedAt = ts.UTC().String()
+	}
+	fmt.Printf("%s: PASS\n", path)
+	fmt.Printf("  channel:     %s\n", manifest.ChannelID)
+	fmt.Printf("  blockfile:   %d\n", manifest.BlockfileNo)
+	fmt.Printf("  blocks:      %d-%d\n", manifest.FirstBlockNum, manifest.LastBlockNum)
+	fmt.Printf("  hash:        %x\n", manifest.BlockfileHash)
+	if len(manifest.MerkleRoot) > 0 {
+		fmt.Printf("  merkle root: %x\n", manifest.MerkleRoot)
+	}
+	fmt.Printf("  archived at: %s\n", archivedAt)
+	fmt.Printf("  archived by: %s (%s)\n", describeSigner(signed.Creator), identity.GetMSPIdentifier())
+	fmt.Printf("  location:    %s%s\n", manifest.Repository, manifest.Location)
+	return nil
+}
+
+// verifySummary checks that the summary of the blocks of the blockfile matches the manifest
+func verifySummary(manifest *archive.ArchiveManifest, path string) error {
+	summaryBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	summary := &archive.BlockfileSummary{}
+	if err := proto.Unmarshal(summaryBytes, summary); err != nil {
+		return errors.Wrap(err, "error unmarshaling summary")
+	}
+	return blockarchive.VerifySummaryAgainstManifest(summary, manifest)
+}
+
+// describeSigner returns the subject of the certificate of the signer if available
+func describeSigner(creator []byte) string {
+	sID := &mspprotos.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, sID); err != nil {
+		return "unknown"
+	}
+	block, _ := pem.Decode(sID.IdBytes)
+	if block == nil {
+		return "unknown"
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "unknown"
+	}
+	return cert.Subject.String()
+}
+
+func main() {
+	v := &manifestVerifier{}
+	// Read configuration parameters
+	if err := v.ReadConfiguration(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+	// Init MSP
+	if err := v.InitMSP(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	if !v.Verify() {
+		fmt.Println("FAIL")
+		os.Exit(-1)
+	}
+	fmt.Println("PASS")
+}
@@ -52,6 +52,9 @@ type Application interface {
 
 	// Capabilities defines the capabilities for the application portion of a channel
 	Capabilities() ApplicationCapabilities
+
+	// BlockArchiving returns the block archiving policy agreed in the channel, nil if the channel has none
+	BlockArchiving() *pb.BlockArchiving
 }
 
 // Channel gives read only access to the channel configuration
@@ -19,18 +19,23 @@ const (
 
 	// ACLsKey is the name of the ACLs config
 	ACLsKey = "ACLs"
+
+	// BlockArchivingKey is the name of the block archiving policy config
+	BlockArchivingKey = "BlockArchiving"
 )
 
 // ApplicationProtos is used as the source of the ApplicationConfig
 type ApplicationProtos struct {
-	ACLs         *pb.ACLs
-	Capabilities *cb.Capabilities
+	ACLs           *pb.ACLs
+	Capabilities   *cb.Capabilities
+	BlockArchiving *pb.BlockArchiving
 }
 
 // ApplicationConfig implements the Application interface
 type ApplicationConfig struct {
-	applicationOrgs map[string]ApplicationOrg
-	protos          *ApplicationProtos
+	applicationOrgs   map[string]ApplicationOrg
+	protos            *ApplicationProtos
+	hasBlockArchiving bool
 }
 
 // NewApplicationConfig creates config from an Application config group
@@ -50,6 +55,8 @@ func NewApplicationConfig(appGroup *cb.ConfigGroup, mspConfig *MSPConfigHandler)
 		}
 	}
 
+	_, ac.hasBlockArchiving = appGroup.Values[BlockArchivingKey]
+
 	var err error
 	for orgName, orgGroup := range appGroup.Groups {
 		ac.applicationOrgs[orgName], err = NewApplicationOrgConfig(orgName, orgGroup, mspConfig)
@@ -77,3 +84,11 @@ func (ac *ApplicationConfig) APIPolicyMapper() PolicyMapper {
 
 	return pm
 }
+
+// BlockArchiving returns the block archiving policy of the channel, nil if the channel has none
+func (ac *ApplicationConfig) BlockArchiving() *pb.BlockArchiving {
+	if !ac.hasBlockArchiving {
+		return nil
+	}
+	return ac.protos.BlockArchiving
+}
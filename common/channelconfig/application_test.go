@@ -12,6 +12,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/common/capabilities"
 	cb "github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/hyperledger/fabric/protoutil"
 	. "github.com/onsi/gomega"
 )
@@ -52,3 +53,22 @@ func TestACL(t *testing.T) {
 		g.Expect(err).To(MatchError("ACLs may not be specified without the required capability"))
 	})
 }
+
+func TestBlockArchiving(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ac, err := NewApplicationConfig(&cb.ConfigGroup{}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ac.BlockArchiving()).To(BeNil())
+
+	policy := &pb.BlockArchiving{MinLocalBlockfiles: 3, MinBlockAgeSeconds: 86400, ObjectLockRequired: true}
+	ac, err = NewApplicationConfig(&cb.ConfigGroup{
+		Values: map[string]*cb.ConfigValue{
+			BlockArchivingKey: {
+				Value: protoutil.MarshalOrPanic(BlockArchivingValue(policy).Value()),
+			},
+		},
+	}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(proto.Equal(ac.BlockArchiving(), policy)).To(BeTrue())
+}
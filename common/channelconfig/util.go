@@ -228,6 +228,15 @@ func ACLValues(acls map[string]string) *StandardConfigValue {
 	}
 }
 
+// BlockArchivingValue returns the config definition for the block archiving policy of the channel.
+// It is a value for the /Channel/Application/.
+func BlockArchivingValue(policy *pb.BlockArchiving) *StandardConfigValue {
+	return &StandardConfigValue{
+		key:   BlockArchivingKey,
+		value: policy,
+	}
+}
+
 // ValidateCapabilities validates whether the peer can meet the capabilities requirement in the given config block
 func ValidateCapabilities(block *cb.Block) error {
 	envelopeConfig, err := protoutil.ExtractEnvelope(block, 0)
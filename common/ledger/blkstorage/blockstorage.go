@@ -7,7 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package blkstorage
 
 import (
+	"time"
+
 	"github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	l "github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/protos/common"
 	"github.com/hyperledger/fabric/protos/peer"
@@ -64,4 +67,7 @@ type BlockStore interface {
 	RetrieveTxValidationCodeByTxID(txID string) (peer.TxValidationCode, error)
 	Shutdown()
 	SetBlockArchived(blockFileNo int, deleteTheFile bool) error
+	GetArchiveCatalog() blockarchive.Catalog
+	AddDiscardListener(listener blockarchive.DiscardListener)
+	RestoreRange(firstBlockNum, lastBlockNum uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error
 }
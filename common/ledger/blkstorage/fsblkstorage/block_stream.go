@@ -10,14 +10,13 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"net"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/pkg/errors"
-	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
 )
 
 // ErrUnexpectedEndOfBlockfile error used to indicate an unexpected end of a file segment
@@ -54,44 +53,56 @@ type blockPlacementInfo struct {
 	blockBytesOffset int64
 }
 
+// archivedFile is an archived blockfile opened on the repository, or its verified copy
+type archivedFile interface {
+	io.ReadSeeker
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
 type sftpConnInfo struct {
-	file   *sftp.File
-	client *ssh.Client
+	file   archivedFile
+	remote *remoteBlockfile
 }
 
-func openFileThroughSFTP(path string, archiveConf *ArchiveConf) (*sftpConnInfo, error) {
-
-	logger.Info("openFileThroughSFTP")
-	config := &ssh.ClientConfig{
-		User: "root",
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		},
-		Auth: []ssh.AuthMethod{
-			ssh.Password("blkstore"),
-		},
-	}
-	config.SetDefaults()
-	sshConn, err := ssh.Dial("tcp", archiveConf.archiveURL, config)
-	if err != nil {
-		return nil, err
-	}
-	// defer sshConn.Close()
+// openFileThroughSFTP opens an archived blockfile on the repository. The retrieval is subject
+// to the retrieval scheduler, which bounds the number of blockfiles read from the repository.
+func openFileThroughSFTP(path string, fileNum int, archiveConf *ArchiveConf, priority retrievalPriority) (*sftpConnInfo, error) {
+	dstFilePath := archiveConf.archivedBlockfilePath(path, fileNum)
+	log := loggerRetrieve.With(blockfileLogFields(filepath.Base(filepath.Dir(path)), fileNum)...).
+		With(blockarchive.LogKeyRepository, archiveConf.archiveURL)
+	log.Debugw("Opening archived blockfile on the repository", "location", dstFilePath)
+	start := time.Now()
 
-	client, err := sftp.NewClient(sshConn)
+	scheduler := getRetrievalScheduler()
+	remote, err := scheduler.acquire(archiveConf.archiveURL, dstFilePath, priority)
 	if err != nil {
 		return nil, err
 	}
-	// defer client.Close()
-
-	dstFilePath := filepath.Join(archiveConf.archiveDir, path)
-	dstFile, err := client.Open(dstFilePath)
+	dstFile, err := openArchivedBlockfile(remote.client, filepath.Base(filepath.Dir(path)), fileNum, dstFilePath)
 	if err != nil {
+		scheduler.release(remote)
+		if blockarchive.IsRestoreInProgress(err) {
+			log.Infow("Archived blockfile is being restored from a deep archive tier of the repository, retry later", "location", dstFilePath)
+			return nil, err
+		}
+		log.Warnw("Failed opening archived blockfile", "location", dstFilePath, "error", err)
 		return nil, err
 	}
-	// defer dstFile.Close()
+	log.Infow("Opened archived blockfile", "location", dstFilePath, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
 
-	return &sftpConnInfo{dstFile, sshConn}, nil
+	return &sftpConnInfo{dstFile, remote}, nil
+}
+
+// archivedBlockfilePath returns the path of a blockfile on the repository as recorded in the catalog
+// when it was archived, or the local path of the blockfile under the archive directory otherwise
+func (archiveConf *ArchiveConf) archivedBlockfilePath(localPath string, fileNum int) string {
+	if archiveConf.catalog != nil {
+		if info, err := archiveConf.catalog.getArchivedBlockfile(uint64(fileNum)); err == nil && info != nil && info.Location != "" {
+			return info.Location
+		}
+	}
+	return filepath.Join(archiveConf.archiveDir, localPath)
 }
 
 func fileSeek(s io.Seeker, startOffset int64) (int64, error) {
@@ -102,16 +113,28 @@ func fileSeek(s io.Seeker, startOffset int64) (int64, error) {
 // blockfileStream functions
 ////////////////////////////////////
 func newBlockfileStream(rootDir string, fileNum int, startOffset int64, archiveConf *ArchiveConf) (*blockfileStream, error) {
+	return newBlockfileStreamWithPriority(rootDir, fileNum, startOffset, archiveConf, retrievalForQuery)
+}
+
+// newBlockfileStreamWithPriority opens a blockfile stream. The priority applies when the blockfile has been
+// discarded and must be retrieved from the repository.
+func newBlockfileStreamWithPriority(rootDir string, fileNum int, startOffset int64, archiveConf *ArchiveConf, priority retrievalPriority) (*blockfileStream, error) {
 	filePath := deriveBlockfilePath(rootDir, fileNum)
 	logger.Debugf("newBlockfileStream(): filePath=[%s], startOffset=[%d]", filePath, startOffset)
 	var file *os.File
 	var connInfo *sftpConnInfo
 	var err error
 	if file, err = os.OpenFile(filePath, os.O_RDONLY, 0600); err != nil {
-		if connInfo, err = openFileThroughSFTP(filePath, archiveConf); err != nil {
+		if ledgerID := filepath.Base(rootDir); !blockarchive.IsFetchEnabled(ledgerID) {
+			return nil, errors.Wrapf(errFetchDisabled(ledgerID), "error opening block file %s", filePath)
+		}
+		// The discarded blockfile is retrieved through the stages of the retrieval order
+		retrieved, err := openDiscardedBlockfile(rootDir, fileNum, archiveConf, priority)
+		if err != nil {
 			logger.Error(err)
 			return nil, errors.Wrapf(err, "error opening block file %s", filePath)
 		}
+		file, connInfo = retrieved.file, retrieved.connInfo
 	}
 
 	var newPosition int64
@@ -122,6 +145,9 @@ func newBlockfileStream(rootDir string, fileNum int, startOffset int64, archiveC
 		seeker = connInfo.file
 	}
 	if newPosition, err = fileSeek(seeker, startOffset); err != nil {
+		if connInfo != nil {
+			connInfo.Close()
+		}
 		return nil, errors.Wrapf(err, "error seeking block file [%s] to startOffset [%d]", filePath, startOffset)
 	}
 	if newPosition != startOffset {
@@ -213,21 +239,38 @@ func (s *blockfileStream) nextBlockBytesAndPlacementInfo() ([]byte, *blockPlacem
 func (s *blockfileStream) close() error {
 	if s.sftpConnInfo != nil {
 		// Close the BlockArchiver connection
-		if err := errors.WithStack(s.sftpConnInfo.file.Close()); err != nil {
+		if err := s.sftpConnInfo.Close(); err != nil {
 			logger.Error(err.Error())
 			return err
 		}
-		return errors.WithStack(s.sftpConnInfo.client.Close())
+		return nil
 	}
 
 	return errors.WithStack(s.file.Close())
 }
 
+// Read reads from the archived blockfile
+func (c *sftpConnInfo) Read(p []byte) (int, error) {
+	return c.file.Read(p)
+}
+
+// Seek sets the offset of the next read of the archived blockfile
+func (c *sftpConnInfo) Seek(offset int64, whence int) (int64, error) {
+	return c.file.Seek(offset, whence)
+}
+
+// Close closes the archived blockfile and releases the repository session
+func (c *sftpConnInfo) Close() error {
+	err := errors.WithStack(c.file.Close())
+	getRetrievalScheduler().release(c.remote)
+	return err
+}
+
 ///////////////////////////////////
 // blockStream functions
 ////////////////////////////////////
 func newBlockStream(rootDir string, startFileNum int, startOffset int64, endFileNum int, archiveConf *ArchiveConf) (*blockStream, error) {
-	startFileStream, err := newBlockfileStream(rootDir, startFileNum, startOffset, archiveConf)
+	startFileStream, err := newBlockfileStreamWithPriority(rootDir, startFileNum, startOffset, archiveConf, retrievalForDeliver)
 	if err != nil {
 		return nil, err
 	}
@@ -240,7 +283,7 @@ func (s *blockStream) moveToNextBlockfileStream() error {
 		return err
 	}
 	s.currentFileNum++
-	if s.currentFileStream, err = newBlockfileStream(s.rootDir, s.currentFileNum, 0, s.archiveConf); err != nil {
+	if s.currentFileStream, err = newBlockfileStreamWithPriority(s.rootDir, s.currentFileNum, 0, s.archiveConf, retrievalForDeliver); err != nil {
 		return err
 	}
 	return nil
@@ -32,7 +32,7 @@ func TestBlockfileStream(t *testing.T) {
 }
 
 func testBlockfileStream(t *testing.T, numBlocks int) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	ledgerid := "testledger"
 	w := newTestBlockfileWrapper(env, ledgerid)
@@ -40,7 +40,7 @@ func testBlockfileStream(t *testing.T, numBlocks int) {
 	w.addBlocks(blocks)
 	w.close()
 
-	s, err := newBlockfileStream(w.blockfileMgr.rootDir, 0, 0)
+	s, err := newBlockfileStream(w.blockfileMgr.rootDir, 0, 0, &ArchiveConf{})
 	defer s.close()
 	assert.NoError(t, err, "Error in constructing blockfile stream")
 
@@ -73,7 +73,7 @@ func TestBlockFileStreamUnexpectedEOF(t *testing.T) {
 }
 
 func testBlockFileStreamUnexpectedEOF(t *testing.T, numBlocks int, partialBlockBytes []byte) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	w := newTestBlockfileWrapper(env, "testLedger")
 	blockfileMgr := w.blockfileMgr
@@ -81,7 +81,7 @@ func testBlockFileStreamUnexpectedEOF(t *testing.T, numBlocks int, partialBlockB
 	w.addBlocks(blocks)
 	blockfileMgr.currentFileWriter.append(partialBlockBytes, true)
 	w.close()
-	s, err := newBlockfileStream(blockfileMgr.rootDir, 0, 0)
+	s, err := newBlockfileStream(blockfileMgr.rootDir, 0, 0, &ArchiveConf{})
 	defer s.close()
 	assert.NoError(t, err, "Error in constructing blockfile stream")
 
@@ -103,7 +103,7 @@ func TestBlockStream(t *testing.T) {
 
 func testBlockStream(t *testing.T, numFiles int) {
 	ledgerID := "testLedger"
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	w := newTestBlockfileWrapper(env, ledgerID)
 	defer w.close()
@@ -122,7 +122,7 @@ func testBlockStream(t *testing.T, numFiles int) {
 		w.addBlocks(blocks)
 		blockfileMgr.moveToNextFile()
 	}
-	s, err := newBlockStream(blockfileMgr.rootDir, 0, 0, numFiles-1)
+	s, err := newBlockStream(blockfileMgr.rootDir, 0, 0, numFiles-1, &ArchiveConf{})
 	defer s.close()
 	assert.NoError(t, err, "Error in constructing new block stream")
 	blockCount := 0
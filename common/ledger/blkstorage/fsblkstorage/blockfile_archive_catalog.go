@@ -0,0 +1,447 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Key prefix of the records of archived blockfiles in the index db
+	archivedBlockfileKeyPrefix = 'r'
+	// Key prefix of the records of the blocks of the archived blockfiles, by blockfile and block number
+	archivedBlockKeyPrefix = 'o'
+	// Key prefix of the keys of the records of archived blockfiles by the number of their last block
+	archivedBlockfileRangeKeyPrefix = 'l'
+	// Key prefix of the keys of the records of the archived blocks by header hash
+	archivedBlockHashKeyPrefix = 'k'
+)
+
+// archivedBlockfileRangeIndexKey marks the catalogs whose records of the archived blockfiles are indexed by
+// the number of their last block, so that the catalogs recorded before are indexed once
+var archivedBlockfileRangeIndexKey = []byte("archivedBlockfileRangeIndex")
+
+// archiveCatalog keeps the records of the blockfiles which have been archived into the repository.
+// The records are persisted by default in the same db as the block index so that they survive restarts
+// and remain consistent with the index, or in CouchDB to be queried by the operators. The journal of
+// the discards is always kept in the db of the block index.
+type archiveCatalog struct {
+	chainID string
+	db      *leveldbhelper.DBHandle
+	store   catalogStore
+}
+
+// catalogStore persists the records of the archived blockfiles and of their blocks
+type catalogStore interface {
+	putBlockfile(info *archive.ArchivedBlockfileInfo) error
+	// putBlockfiles persists the records of several blockfiles at once
+	putBlockfiles(infos []*archive.ArchivedBlockfileInfo) error
+	// putBlockfileWithBlocks persists the records of the blocks before the one of their blockfile
+	putBlockfileWithBlocks(info *archive.ArchivedBlockfileInfo, blocks []*archive.ArchivedBlockInfo) error
+	getBlockfile(fileNum uint64) (*archive.ArchivedBlockfileInfo, error)
+	// getBlockfileContaining returns the record of the blockfile whose block range contains the block,
+	// nil if there is none
+	getBlockfileContaining(blockNum uint64) (*archive.ArchivedBlockfileInfo, error)
+	// listBlockfiles returns the records of the archived blockfiles in ascending order
+	listBlockfiles() ([]*archive.ArchivedBlockfileInfo, error)
+	getBlock(fileNum, blockNum uint64) (*archive.ArchivedBlockInfo, error)
+	getBlockByHash(headerHash []byte) (*archive.ArchivedBlockInfo, error)
+	getBlockAt(fileNum, offset uint64) (*archive.ArchivedBlockInfo, error)
+}
+
+func newArchiveCatalog(chainID string, db *leveldbhelper.DBHandle) *archiveCatalog {
+	var store catalogStore = &levelDBCatalogStore{db: db}
+	if blockarchive.CatalogDatabase == blockarchive.CatalogDatabaseCouchDB {
+		store = newCouchDBCatalogStore(chainID)
+	}
+	return &archiveCatalog{chainID, db, store}
+}
+
+// recordArchivedBlockfile persists the record of an archived blockfile
+func (c *archiveCatalog) recordArchivedBlockfile(info *archive.ArchivedBlockfileInfo) error {
+	return c.store.putBlockfile(info)
+}
+
+// recordArchivedBlockfiles persists the records of several archived blockfiles in a single batch
+func (c *archiveCatalog) recordArchivedBlockfiles(infos []*archive.ArchivedBlockfileInfo) error {
+	return c.store.putBlockfiles(infos)
+}
+
+// recordArchivedBlockfileWithBlocks persists the record of an archived blockfile along with the records
+// of its blocks, which locate them in the blockfile
+func (c *archiveCatalog) recordArchivedBlockfileWithBlocks(info *archive.ArchivedBlockfileInfo, blocks []*archive.ArchivedBlockInfo) error {
+	return c.store.putBlockfileWithBlocks(info, blocks)
+}
+
+// GetArchivedBlock returns the record of an archived block, nil if the block has not been archived or if its
+// blockfile was archived before the blocks were recorded
+func (c *archiveCatalog) GetArchivedBlock(blockNum uint64) (*archive.ArchivedBlockInfo, error) {
+	info, err := c.GetArchiveLocation(blockNum)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	return c.store.getBlock(info.BlockfileNo, blockNum)
+}
+
+// GetArchivedBlockByHash returns the record of the archived block with the header hash, nil if there is none
+func (c *archiveCatalog) GetArchivedBlockByHash(headerHash []byte) (*archive.ArchivedBlockInfo, error) {
+	return c.store.getBlockByHash(headerHash)
+}
+
+// getArchivedBlockAt returns the record of the block at an offset of an archived blockfile, nil if there is none
+func (c *archiveCatalog) getArchivedBlockAt(fileNum uint64, offset uint64) (*archive.ArchivedBlockInfo, error) {
+	return c.store.getBlockAt(fileNum, offset)
+}
+
+// getArchivedBlockfile returns the record of an archived blockfile or nil if it has not been archived
+func (c *archiveCatalog) getArchivedBlockfile(fileNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	return c.store.getBlockfile(fileNum)
+}
+
+// ListArchivedBlockfiles returns the records of all the archived blockfiles in ascending order
+func (c *archiveCatalog) ListArchivedBlockfiles() ([]*archive.ArchivedBlockfileInfo, error) {
+	return c.store.listBlockfiles()
+}
+
+// IsBlockArchived returns whether the block has been archived into the repository
+func (c *archiveCatalog) IsBlockArchived(blockNum uint64) (bool, error) {
+	info, err := c.GetArchiveLocation(blockNum)
+	if err != nil {
+		return false, err
+	}
+	return info != nil, nil
+}
+
+// GetArchiveLocation returns the record of the archived blockfile which contains the block
+func (c *archiveCatalog) GetArchiveLocation(blockNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	return c.store.getBlockfileContaining(blockNum)
+}
+
+// GetArchivedRanges returns the contiguous ranges of archived blocks in ascending order
+func (c *archiveCatalog) GetArchivedRanges() ([]*archive.ArchivedBlockRange, error) {
+	infos, err := c.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	return mergeBlockRanges(infos), nil
+}
+
+// GetDiscardedRanges returns the contiguous ranges of archived blocks which have been
+// discarded from the local file system in ascending order
+func (c *archiveCatalog) GetDiscardedRanges() ([]*archive.ArchivedBlockRange, error) {
+	infos, err := c.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	var discarded []*archive.ArchivedBlockfileInfo
+	for _, info := range infos {
+		if info.Discarded {
+			discarded = append(discarded, info)
+		}
+	}
+	return mergeBlockRanges(discarded), nil
+}
+
+// mergeBlockRanges merges the block ranges of the blockfiles sorted in ascending order into contiguous ranges
+func mergeBlockRanges(infos []*archive.ArchivedBlockfileInfo) []*archive.ArchivedBlockRange {
+	var ranges []*archive.ArchivedBlockRange
+	for _, info := range infos {
+		if n := len(ranges); n > 0 && ranges[n-1].LastBlockNum+1 == info.FirstBlockNum {
+			ranges[n-1].LastBlockNum = info.LastBlockNum
+			continue
+		}
+		ranges = append(ranges, &archive.ArchivedBlockRange{
+			FirstBlockNum: info.FirstBlockNum,
+			LastBlockNum:  info.LastBlockNum,
+		})
+	}
+	return ranges
+}
+
+// levelDBCatalogStore persists the records of the catalog in the db of the block index. The records
+// of the blockfiles are indexed by the number of their last block, the block ranges of the blockfiles
+// being disjoint.
+type levelDBCatalogStore struct {
+	db *leveldbhelper.DBHandle
+
+	indexLock sync.Mutex
+	indexed   bool
+}
+
+func (s *levelDBCatalogStore) putBlockfile(info *archive.ArchivedBlockfileInfo) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	if err := s.addBlockfile(batch, info); err != nil {
+		return err
+	}
+	return s.db.WriteBatch(batch, true)
+}
+
+func (s *levelDBCatalogStore) putBlockfiles(infos []*archive.ArchivedBlockfileInfo) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	for _, info := range infos {
+		if err := s.addBlockfile(batch, info); err != nil {
+			return err
+		}
+	}
+	return s.db.WriteBatch(batch, true)
+}
+
+func (s *levelDBCatalogStore) putBlockfileWithBlocks(info *archive.ArchivedBlockfileInfo, blocks []*archive.ArchivedBlockInfo) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	if err := s.addBlockfile(batch, info); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		b, err := proto.Marshal(block)
+		if err != nil {
+			return errors.Wrapf(err, "error marshaling archive record of block [%d]", block.BlockNum)
+		}
+		key := constructArchivedBlockKey(block.BlockfileNo, block.BlockNum)
+		batch.Put(key, b)
+		batch.Put(constructArchivedBlockHashKey(block.HeaderHash), key)
+	}
+	return s.db.WriteBatch(batch, true)
+}
+
+func (s *levelDBCatalogStore) getBlockfile(fileNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	b, err := s.db.Get(constructArchivedBlockfileKey(fileNum))
+	if err != nil || b == nil {
+		return nil, err
+	}
+	info := &archive.ArchivedBlockfileInfo{}
+	if err := proto.Unmarshal(b, info); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling archive record of blockfile [%d]", fileNum)
+	}
+	return info, nil
+}
+
+// addBlockfile adds the record of a blockfile and its key by last block to a batch, replacing the key of
+// the previous record of the blockfile if its block range was different
+func (s *levelDBCatalogStore) addBlockfile(batch *leveldbhelper.UpdateBatch, info *archive.ArchivedBlockfileInfo) error {
+	b, err := proto.Marshal(info)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling archive record of blockfile [%d]", info.BlockfileNo)
+	}
+	previous, err := s.getBlockfile(info.BlockfileNo)
+	if err != nil {
+		return err
+	}
+	if previous != nil && previous.LastBlockNum != info.LastBlockNum {
+		batch.Delete(constructArchivedBlockfileRangeKey(previous.LastBlockNum))
+	}
+	batch.Put(constructArchivedBlockfileKey(info.BlockfileNo), b)
+	addArchivedBlockfileRangeKey(batch, info)
+	return nil
+}
+
+// getBlockfileContaining looks up the first blockfile whose last block is not before the block
+func (s *levelDBCatalogStore) getBlockfileContaining(blockNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	if err := s.indexBlockfileRanges(); err != nil {
+		return nil, err
+	}
+	itr := s.db.GetIterator(constructArchivedBlockfileRangeKey(blockNum), []byte{archivedBlockfileRangeKeyPrefix + 1})
+	defer itr.Release()
+	if !itr.Next() {
+		if err := itr.Error(); err != nil {
+			return nil, errors.Wrap(err, "error iterating archive records by block range")
+		}
+		return nil, nil
+	}
+	fileNum, _ := util.DecodeOrderPreservingVarUint64(itr.Value())
+	info, err := s.getBlockfile(fileNum)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	if info.FirstBlockNum > blockNum || info.LastBlockNum < blockNum {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// indexBlockfileRanges indexes the records of the blockfiles by their last block, unless they are already
+func (s *levelDBCatalogStore) indexBlockfileRanges() error {
+	s.indexLock.Lock()
+	defer s.indexLock.Unlock()
+	if s.indexed {
+		return nil
+	}
+	marker, err := s.db.Get(archivedBlockfileRangeIndexKey)
+	if err != nil {
+		return err
+	}
+	if marker == nil {
+		infos, err := s.listBlockfiles()
+		if err != nil {
+			return err
+		}
+		batch := leveldbhelper.NewUpdateBatch()
+		for _, info := range infos {
+			addArchivedBlockfileRangeKey(batch, info)
+		}
+		batch.Put(archivedBlockfileRangeIndexKey, []byte{1})
+		if err := s.db.WriteBatch(batch, true); err != nil {
+			return errors.Wrap(err, "error indexing archive records by block range")
+		}
+	}
+	s.indexed = true
+	return nil
+}
+
+func (s *levelDBCatalogStore) listBlockfiles() ([]*archive.ArchivedBlockfileInfo, error) {
+	itr := s.db.GetIterator([]byte{archivedBlockfileKeyPrefix}, []byte{archivedBlockfileKeyPrefix + 1})
+	defer itr.Release()
+
+	var infos []*archive.ArchivedBlockfileInfo
+	for itr.Next() {
+		info := &archive.ArchivedBlockfileInfo{}
+		if err := proto.Unmarshal(itr.Value(), info); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling archive record")
+		}
+		infos = append(infos, info)
+	}
+	if err := itr.Error(); err != nil {
+		return nil, errors.Wrap(err, "error iterating archive records")
+	}
+	return infos, nil
+}
+
+func (s *levelDBCatalogStore) getBlock(fileNum, blockNum uint64) (*archive.ArchivedBlockInfo, error) {
+	return s.getBlockByKey(constructArchivedBlockKey(fileNum, blockNum))
+}
+
+func (s *levelDBCatalogStore) getBlockByHash(headerHash []byte) (*archive.ArchivedBlockInfo, error) {
+	key, err := s.db.Get(constructArchivedBlockHashKey(headerHash))
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return s.getBlockByKey(key)
+}
+
+func (s *levelDBCatalogStore) getBlockAt(fileNum, offset uint64) (*archive.ArchivedBlockInfo, error) {
+	prefix := constructArchivedBlockKeyPrefix(fileNum)
+	itr := s.db.GetIterator(prefix, append(prefix, 0xff))
+	defer itr.Release()
+	for itr.Next() {
+		block := &archive.ArchivedBlockInfo{}
+		if err := proto.Unmarshal(itr.Value(), block); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling archive record of a block of blockfile [%d]", fileNum)
+		}
+		if block.Offset == offset {
+			return block, nil
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return nil, errors.Wrapf(err, "error iterating archive records of the blocks of blockfile [%d]", fileNum)
+	}
+	return nil, nil
+}
+
+func (s *levelDBCatalogStore) getBlockByKey(key []byte) (*archive.ArchivedBlockInfo, error) {
+	b, err := s.db.Get(key)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	block := &archive.ArchivedBlockInfo{}
+	if err := proto.Unmarshal(b, block); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling archive record of block")
+	}
+	return block, nil
+}
+
+func constructArchivedBlockfileKey(fileNum uint64) []byte {
+	return append([]byte{archivedBlockfileKeyPrefix}, util.EncodeOrderPreservingVarUint64(fileNum)...)
+}
+
+func constructArchivedBlockfileRangeKey(lastBlockNum uint64) []byte {
+	return append([]byte{archivedBlockfileRangeKeyPrefix}, util.EncodeOrderPreservingVarUint64(lastBlockNum)...)
+}
+
+// addArchivedBlockfileRangeKey adds the key of the record of a blockfile by its last block to a batch
+func addArchivedBlockfileRangeKey(batch *leveldbhelper.UpdateBatch, info *archive.ArchivedBlockfileInfo) {
+	batch.Put(constructArchivedBlockfileRangeKey(info.LastBlockNum), util.EncodeOrderPreservingVarUint64(info.BlockfileNo))
+}
+
+func constructArchivedBlockKeyPrefix(fileNum uint64) []byte {
+	return append([]byte{archivedBlockKeyPrefix}, util.EncodeOrderPreservingVarUint64(fileNum)...)
+}
+
+func constructArchivedBlockKey(fileNum, blockNum uint64) []byte {
+	return append(constructArchivedBlockKeyPrefix(fileNum), util.EncodeOrderPreservingVarUint64(blockNum)...)
+}
+
+func constructArchivedBlockHashKey(headerHash []byte) []byte {
+	return append([]byte{archivedBlockHashKeyPrefix}, headerHash...)
+}
+
+// blockfileSummary holds the numbers and the header hashes of the first and the last block in a blockfile,
+// along with the header hashes, the previous hashes and the locations of all its blocks
+type blockfileSummary struct {
+	firstBlockNum  uint64
+	lastBlockNum   uint64
+	firstBlockHash []byte
+	lastBlockHash  []byte
+	blockHashes    [][]byte
+	previousHashes [][]byte
+	blocks         []*archive.ArchivedBlockInfo
+	// lastBlockTime is the creation time of the last block, nil if it could not be read
+	lastBlockTime *timestamp.Timestamp
+}
+
+// scanBlockfile returns the summary of the blocks stored in a local blockfile
+func scanBlockfile(rootDir string, fileNum int) (*blockfileSummary, error) {
+	stream, err := newBlockfileStream(rootDir, fileNum, 0, &ArchiveConf{})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.close()
+
+	var summary *blockfileSummary
+	var lastBlockBytes []byte
+	for {
+		blockBytes, placement, err := stream.nextBlockBytesAndPlacementInfo()
+		if err != nil {
+			return nil, err
+		}
+		if blockBytes == nil {
+			break
+		}
+		info, err := extractSerializedBlockInfo(blockBytes)
+		if err != nil {
+			return nil, err
+		}
+		hash := protoutil.BlockHeaderHash(info.blockHeader)
+		lastBlockBytes = blockBytes
+		if summary == nil {
+			summary = &blockfileSummary{firstBlockNum: info.blockHeader.Number, firstBlockHash: hash}
+		}
+		summary.lastBlockNum = info.blockHeader.Number
+		summary.lastBlockHash = hash
+		summary.blockHashes = append(summary.blockHashes, hash)
+		summary.previousHashes = append(summary.previousHashes, info.blockHeader.PreviousHash)
+		summary.blocks = append(summary.blocks, &archive.ArchivedBlockInfo{
+			BlockNum:    info.blockHeader.Number,
+			BlockfileNo: uint64(fileNum),
+			Offset:      uint64(placement.blockStartOffset),
+			Length:      uint64(placement.blockBytesOffset-placement.blockStartOffset) + uint64(len(blockBytes)),
+			HeaderHash:  hash,
+		})
+	}
+	if summary == nil {
+		return nil, errors.Errorf("no block found in blockfile [%d]", fileNum)
+	}
+	if block, err := deserializeBlock(lastBlockBytes); err == nil {
+		summary.lastBlockTime, _ = blockTime(block)
+	}
+	return summary, nil
+}
@@ -0,0 +1,175 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// CatalogCheck lists the inconsistencies of the archive catalog of a ledger with its local blockfiles and the
+// repository. The retrieval of the archived blocks relies on the catalog, so they would silently break it.
+type CatalogCheck struct {
+	LedgerID string
+	// Checked is the number of records of the catalog which have been checked
+	Checked int
+	// Gaps are the ranges of blocks neither held by the local blockfiles nor recorded in the catalog
+	Gaps []*archive.ArchivedBlockRange
+	// Overlaps are the records whose block range overlaps the one of the previous record
+	Overlaps []*CatalogInconsistency
+	// Mismatched are the records which don't match the block range or the checksum of the local blockfile,
+	// or whose archived blockfile doesn't match their checksum on the repository
+	Mismatched []*CatalogInconsistency
+	// Dangling are the records of a blockfile which the ledger is still writing or hasn't written yet,
+	// or which is missing from the repository
+	Dangling []*CatalogInconsistency
+}
+
+// CatalogInconsistency is a record of the archive catalog which is inconsistent with the ledger or the repository
+type CatalogInconsistency struct {
+	BlockfileNo uint64
+	Reason      string
+}
+
+// IsConsistent tells if no inconsistency has been found
+func (c *CatalogCheck) IsConsistent() bool {
+	return len(c.Gaps) == 0 && len(c.Overlaps) == 0 && len(c.Mismatched) == 0 && len(c.Dangling) == 0
+}
+
+// CheckArchiveCatalog checks the archive catalog of a ledger stored in blockStorePath against the local
+// blockfiles of the ledger and the archived blockfiles on the repository. It must not be called while the
+// peer is running.
+func CheckArchiveCatalog(blockStorePath, ledgerID string) (*CatalogCheck, error) {
+	var check *CatalogCheck
+	err := withArchiver(blockStorePath, ledgerID, func(arch *blockfileArchiver) (err error) {
+		check, err = arch.checkCatalog()
+		return err
+	})
+	return check, err
+}
+
+func (arch *blockfileArchiver) checkCatalog() (*CatalogCheck, error) {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	check := &CatalogCheck{LedgerID: arch.chainID, Checked: len(infos)}
+
+	// Block ranges held by the local blockfiles, the blockfile being written may be empty
+	fileNums, sizes, err := listLocalBlockfiles(arch.blockfileDir)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []*archive.ArchivedBlockRange
+	local := make(map[uint64]*blockfileSummary)
+	for _, fileNum := range fileNums {
+		if sizes[fileNum] == 0 {
+			continue
+		}
+		summary, err := scanBlockfile(arch.blockfileDir, fileNum)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "error scanning local blockfile [%d]", fileNum)
+		}
+		local[uint64(fileNum)] = summary
+		ranges = append(ranges, &archive.ArchivedBlockRange{FirstBlockNum: summary.firstBlockNum, LastBlockNum: summary.lastBlockNum})
+	}
+	if len(infos) == 0 {
+		check.Gaps = blockRangeGaps(ranges)
+		return check, nil
+	}
+
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+	currentFileNum, _ := arch.mgr.currentFileNumAndSize()
+	for i, info := range infos {
+		inconsistency := func(format string, args ...interface{}) *CatalogInconsistency {
+			return &CatalogInconsistency{BlockfileNo: info.BlockfileNo, Reason: fmt.Sprintf(format, args...)}
+		}
+		ranges = append(ranges, &archive.ArchivedBlockRange{FirstBlockNum: info.FirstBlockNum, LastBlockNum: info.LastBlockNum})
+		if i > 0 {
+			if prev := infos[i-1]; info.FirstBlockNum <= prev.LastBlockNum {
+				check.Overlaps = append(check.Overlaps, inconsistency("blocks [%d-%d] overlap blocks [%d-%d] of blockfile [%d]",
+					info.FirstBlockNum, info.LastBlockNum, prev.FirstBlockNum, prev.LastBlockNum, prev.BlockfileNo))
+			}
+		}
+		if int(info.BlockfileNo) >= currentFileNum {
+			check.Dangling = append(check.Dangling, inconsistency("the ledger is writing blockfile [%d]", currentFileNum))
+			continue
+		}
+
+		if summary, ok := local[info.BlockfileNo]; ok {
+			if summary.firstBlockNum != info.FirstBlockNum || summary.lastBlockNum != info.LastBlockNum {
+				check.Mismatched = append(check.Mismatched, inconsistency("blocks [%d-%d] are recorded, but the local blockfile holds blocks [%d-%d]",
+					info.FirstBlockNum, info.LastBlockNum, summary.firstBlockNum, summary.lastBlockNum))
+			} else if reason, err := arch.localChecksumMismatch(info); err != nil {
+				return nil, err
+			} else if reason != "" {
+				check.Mismatched = append(check.Mismatched, inconsistency("%s", reason))
+			}
+		}
+
+		if _, err := client.Stat(info.Location); os.IsNotExist(err) {
+			check.Dangling = append(check.Dangling, inconsistency("the archived blockfile is missing from the repository at %s", info.Location))
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "error reading archived blockfile %s", info.Location)
+		}
+		matched, err := matchesChecksum(client, info)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			check.Mismatched = append(check.Mismatched, inconsistency("the archived blockfile at %s doesn't match checksum %s", info.Location, info.Checksum))
+		}
+	}
+	check.Gaps = blockRangeGaps(ranges)
+	return check, nil
+}
+
+// localChecksumMismatch compares the checksum recorded for an archived blockfile with the one of the local
+// blockfile, and returns the reason of the mismatch, empty if they match or no checksum was recorded
+func (arch *blockfileArchiver) localChecksumMismatch(info *archive.ArchivedBlockfileInfo) (string, error) {
+	if info.Checksum == "" {
+		return "", nil
+	}
+	expected, err := blockarchive.ParseChecksum(info.Checksum)
+	if err != nil {
+		return err.Error(), nil
+	}
+	actual, err := blockarchive.ComputeBlockfileChecksum(deriveBlockfilePath(arch.blockfileDir, int(info.BlockfileNo)), expected.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(actual.Digest, expected.Digest) {
+		return fmt.Sprintf("checksum %s is recorded, but the local blockfile has checksum %s", expected, actual), nil
+	}
+	return "", nil
+}
+
+// blockRangeGaps returns the ranges of blocks from block 0 to the last block of the ranges which none of them holds
+func blockRangeGaps(ranges []*archive.ArchivedBlockRange) []*archive.ArchivedBlockRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].FirstBlockNum < ranges[j].FirstBlockNum })
+	var gaps []*archive.ArchivedBlockRange
+	var next uint64
+	for _, r := range ranges {
+		if r.FirstBlockNum > next {
+			gaps = append(gaps, &archive.ArchivedBlockRange{FirstBlockNum: next, LastBlockNum: r.FirstBlockNum - 1})
+		}
+		if r.LastBlockNum+1 > next {
+			next = r.LastBlockNum + 1
+		}
+	}
+	return gaps
+}
@@ -0,0 +1,114 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckArchiveCatalog(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	blockStorePath := testPath()
+	prevSigner, prevBlockStorePath := blockarchive.ManifestSigner, blockarchive.BlockStorePath
+	defer func() { blockarchive.ManifestSigner, blockarchive.BlockStorePath = prevSigner, prevBlockStorePath }()
+	blockarchive.ManifestSigner, blockarchive.BlockStorePath = nil, blockStorePath
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+
+	check, err := arch.checkCatalog()
+	require.NoError(t, err)
+	assert.True(t, check.IsConsistent())
+	assert.Equal(t, 0, check.Checked)
+
+	// Blockfile 0 is discarded and blockfile 1 is kept
+	for fileNum, discard := range []bool{true, false} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.publishManifest(fileNum, location))
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, discard))
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	check, err = arch.checkCatalog()
+	require.NoError(t, err)
+	assert.True(t, check.IsConsistent())
+	assert.Equal(t, 2, check.Checked)
+
+	// The record of blockfile 0 lost the range of its last blocks, which are no longer held locally,
+	// blockfile 1 is recorded with a wrong checksum and a blockfile is recorded ahead of the ledger
+	shrunk := proto.Clone(infos[0]).(*archive.ArchivedBlockfileInfo)
+	shrunk.LastBlockNum = 4
+	altered := proto.Clone(infos[1]).(*archive.ArchivedBlockfileInfo)
+	altered.Checksum = "sha256:00"
+	ahead := proto.Clone(infos[1]).(*archive.ArchivedBlockfileInfo)
+	ahead.BlockfileNo, ahead.FirstBlockNum, ahead.LastBlockNum = 5, 15, 50
+	require.NoError(t, arch.catalog.recordArchivedBlockfiles([]*archive.ArchivedBlockfileInfo{shrunk, altered, ahead}))
+	check, err = arch.checkCatalog()
+	require.NoError(t, err)
+	assert.False(t, check.IsConsistent())
+	assert.Equal(t, []*archive.ArchivedBlockRange{{FirstBlockNum: 5, LastBlockNum: infos[0].LastBlockNum}}, check.Gaps)
+	assert.Equal(t, []*CatalogInconsistency{{BlockfileNo: 5, Reason: fmt.Sprintf("blocks [15-50] overlap blocks [%d-%d] of blockfile [1]",
+		infos[1].FirstBlockNum, infos[1].LastBlockNum)}}, check.Overlaps)
+	require.Len(t, check.Mismatched, 2)
+	assert.Equal(t, uint64(1), check.Mismatched[0].BlockfileNo)
+	assert.Contains(t, check.Mismatched[0].Reason, "checksum sha256:00 is recorded")
+	assert.Contains(t, check.Mismatched[1].Reason, "doesn't match checksum sha256:00")
+	require.Len(t, check.Dangling, 1)
+	assert.Equal(t, uint64(5), check.Dangling[0].BlockfileNo)
+	assert.Contains(t, check.Dangling[0].Reason, "the ledger is writing blockfile")
+
+	// An archived blockfile missing from the repository is dangling
+	require.NoError(t, arch.catalog.recordArchivedBlockfiles(infos))
+	require.NoError(t, os.Remove(filepath.Join(repoRootDir, infos[0].Location)))
+	check, err = arch.checkCatalog()
+	require.NoError(t, err)
+	assert.Empty(t, check.Gaps)
+	assert.Empty(t, check.Mismatched)
+	assert.Equal(t, []*CatalogInconsistency{
+		{BlockfileNo: 0, Reason: "the archived blockfile is missing from the repository at " + infos[0].Location},
+		{BlockfileNo: 5, Reason: "the ledger is writing blockfile [2]"},
+	}, check.Dangling)
+}
+
+func TestBlockRangeGaps(t *testing.T) {
+	assert.Empty(t, blockRangeGaps(nil))
+	assert.Equal(t, []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 2}, {FirstBlockNum: 11, LastBlockNum: 11}},
+		blockRangeGaps([]*archive.ArchivedBlockRange{
+			{FirstBlockNum: 12, LastBlockNum: 20},
+			{FirstBlockNum: 3, LastBlockNum: 10},
+			{FirstBlockNum: 5, LastBlockNum: 6},
+		}))
+}
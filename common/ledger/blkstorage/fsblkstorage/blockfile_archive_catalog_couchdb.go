@@ -0,0 +1,461 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/core/ledger/util/couchdb"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+const (
+	// catalogCouchDBNamespace names the CouchDB database of the archive catalog of a channel like
+	// a namespace of the channel, which no chaincode can take as it starts with an underscore
+	catalogCouchDBNamespace = "_archivecatalog"
+
+	blockfileDocType = "blockfile"
+	blockDocType     = "block"
+
+	// catalogCouchDBPageSize is the number of documents read or written in one request
+	catalogCouchDBPageSize = 1000
+)
+
+// catalogCouchDBIndexes are the Mango indexes locating the records of the blocks by hash and by offset,
+// and the records of the blockfiles by their last block
+var catalogCouchDBIndexes = []string{
+	`{"index":{"fields":["docType","headerHash"]},"name":"by_header_hash","ddoc":"archive_catalog","type":"json"}`,
+	`{"index":{"fields":["docType","blockfileNo","offset"]},"name":"by_offset","ddoc":"archive_catalog","type":"json"}`,
+	`{"index":{"fields":["docType","lastBlockNum"]},"name":"by_last_block","ddoc":"archive_catalog","type":"json"}`,
+}
+
+// couchDBCatalog is the part of a CouchDB database used by the archive catalog
+type couchDBCatalog interface {
+	ReadDoc(id string) (*couchdb.CouchDoc, string, error)
+	SaveDoc(id string, rev string, couchDoc *couchdb.CouchDoc) (string, error)
+	ReadDocRange(startKey, endKey string, limit int32) ([]*couchdb.QueryResult, string, error)
+	QueryDocuments(query string) ([]*couchdb.QueryResult, string, error)
+	BatchRetrieveDocumentMetadata(keys []string) ([]*couchdb.DocMetadata, error)
+	BatchUpdateDocuments(documents []*couchdb.CouchDoc) ([]*couchdb.BatchUpdateResponse, error)
+}
+
+// blockfileDoc is the CouchDB document of the record of an archived blockfile. The fields besides
+// the record are for the Mango queries of the operators, the record is read back from Record.
+type blockfileDoc struct {
+	ID              string `json:"_id"`
+	Rev             string `json:"_rev,omitempty"`
+	DocType         string `json:"docType"`
+	ChannelID       string `json:"channelId"`
+	BlockfileNo     uint64 `json:"blockfileNo"`
+	FirstBlockNum   uint64 `json:"firstBlockNum"`
+	LastBlockNum    uint64 `json:"lastBlockNum"`
+	Repository      string `json:"repository"`
+	Location        string `json:"location"`
+	Discarded       bool   `json:"discarded"`
+	Checksum        string `json:"checksum,omitempty"`
+	EncryptionKeyID string `json:"encryptionKeyId,omitempty"`
+	Record          []byte `json:"record"`
+}
+
+// blockDoc is the CouchDB document of the record of an archived block
+type blockDoc struct {
+	ID          string `json:"_id"`
+	Rev         string `json:"_rev,omitempty"`
+	DocType     string `json:"docType"`
+	BlockNum    uint64 `json:"blockNum"`
+	BlockfileNo uint64 `json:"blockfileNo"`
+	Offset      uint64 `json:"offset"`
+	HeaderHash  string `json:"headerHash"`
+	Record      []byte `json:"record"`
+}
+
+// The ids are zero padded so that the documents are listed in the order of the numbers
+func blockfileDocID(fileNum uint64) string {
+	return fmt.Sprintf("%s_%020d", blockfileDocType, fileNum)
+}
+
+func blockDocID(blockNum uint64) string {
+	return fmt.Sprintf("%s_%020d", blockDocType, blockNum)
+}
+
+var (
+	catalogCouchInstance     *couchdb.CouchInstance
+	catalogCouchInstanceLock sync.Mutex
+)
+
+// getCatalogCouchInstance returns the CouchDB instance shared by the archive catalogs of the channels,
+// connected on first use with the configuration of the CouchDB state database
+func getCatalogCouchInstance() (*couchdb.CouchInstance, error) {
+	catalogCouchInstanceLock.Lock()
+	defer catalogCouchInstanceLock.Unlock()
+	if catalogCouchInstance == nil {
+		var p metrics.Provider = &disabled.Provider{}
+		if blockarchive.MetricsProvider != nil {
+			p = blockarchive.MetricsProvider
+		}
+		instance, err := couchdb.CreateCouchInstance(couchdb.GetCouchDBDefinition(), p)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error connecting to the CouchDB of the archive catalog")
+		}
+		catalogCouchInstance = instance
+	}
+	return catalogCouchInstance, nil
+}
+
+// couchDBCatalogStore persists the records of the catalog of a channel in CouchDB. The database is created
+// on first use, and connected again on the next use if CouchDB cannot be reached.
+type couchDBCatalogStore struct {
+	chainID string
+	lock    sync.Mutex
+	db      couchDBCatalog
+}
+
+func newCouchDBCatalogStore(chainID string) *couchDBCatalogStore {
+	return &couchDBCatalogStore{chainID: chainID}
+}
+
+func (s *couchDBCatalogStore) database() (couchDBCatalog, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.db != nil {
+		return s.db, nil
+	}
+	instance, err := getCatalogCouchInstance()
+	if err != nil {
+		return nil, err
+	}
+	db, err := couchdb.CreateCouchDatabase(instance, couchdb.ConstructNamespaceDBName(s.chainID, catalogCouchDBNamespace))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error creating the archive catalog of ledger [%s] in CouchDB", s.chainID)
+	}
+	for _, index := range catalogCouchDBIndexes {
+		if _, err := db.CreateIndex(index); err != nil {
+			return nil, errors.WithMessagef(err, "error creating an index of the archive catalog of ledger [%s] in CouchDB", s.chainID)
+		}
+	}
+	s.db = db
+	return db, nil
+}
+
+func newBlockfileDoc(info *archive.ArchivedBlockfileInfo) (*blockfileDoc, error) {
+	record, err := proto.Marshal(info)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error marshaling archive record of blockfile [%d]", info.BlockfileNo)
+	}
+	return &blockfileDoc{
+		ID:              blockfileDocID(info.BlockfileNo),
+		DocType:         blockfileDocType,
+		ChannelID:       info.ChannelID,
+		BlockfileNo:     info.BlockfileNo,
+		FirstBlockNum:   info.FirstBlockNum,
+		LastBlockNum:    info.LastBlockNum,
+		Repository:      info.Repository,
+		Location:        info.Location,
+		Discarded:       info.Discarded,
+		Checksum:        info.Checksum,
+		EncryptionKeyID: info.EncryptionKeyId,
+		Record:          record,
+	}, nil
+}
+
+func newBlockDoc(block *archive.ArchivedBlockInfo) (*blockDoc, error) {
+	record, err := proto.Marshal(block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error marshaling archive record of block [%d]", block.BlockNum)
+	}
+	return &blockDoc{
+		ID:          blockDocID(block.BlockNum),
+		DocType:     blockDocType,
+		BlockNum:    block.BlockNum,
+		BlockfileNo: block.BlockfileNo,
+		Offset:      block.Offset,
+		HeaderHash:  hex.EncodeToString(block.HeaderHash),
+		Record:      record,
+	}, nil
+}
+
+func unmarshalBlockfileDoc(value []byte) (*archive.ArchivedBlockfileInfo, error) {
+	doc := &blockfileDoc{}
+	if err := json.Unmarshal(value, doc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling archive record document")
+	}
+	info := &archive.ArchivedBlockfileInfo{}
+	if err := proto.Unmarshal(doc.Record, info); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling archive record of blockfile [%d]", doc.BlockfileNo)
+	}
+	return info, nil
+}
+
+func unmarshalBlockDoc(value []byte) (*archive.ArchivedBlockInfo, error) {
+	doc := &blockDoc{}
+	if err := json.Unmarshal(value, doc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling archive record document")
+	}
+	block := &archive.ArchivedBlockInfo{}
+	if err := proto.Unmarshal(doc.Record, block); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling archive record of block [%d]", doc.BlockNum)
+	}
+	return block, nil
+}
+
+func (s *couchDBCatalogStore) putBlockfile(info *archive.ArchivedBlockfileInfo) error {
+	db, err := s.database()
+	if err != nil {
+		return err
+	}
+	doc, err := newBlockfileDoc(info)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling archive record of blockfile [%d]", info.BlockfileNo)
+	}
+	// The revision of the existing document is read by SaveDoc
+	if _, err := db.SaveDoc(doc.ID, "", &couchdb.CouchDoc{JSONValue: value}); err != nil {
+		return errors.WithMessagef(err, "error saving archive record of blockfile [%d]", info.BlockfileNo)
+	}
+	return nil
+}
+
+// putBlockfiles saves the documents of the blockfiles in batches
+func (s *couchDBCatalogStore) putBlockfiles(infos []*archive.ArchivedBlockfileInfo) error {
+	db, err := s.database()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(infos); start += catalogCouchDBPageSize {
+		end := start + catalogCouchDBPageSize
+		if end > len(infos) {
+			end = len(infos)
+		}
+		if err := saveBlockfileDocs(db, infos[start:end]); err != nil {
+			return errors.WithMessage(err, "error saving archive records of blockfiles")
+		}
+	}
+	return nil
+}
+
+func saveBlockfileDocs(db couchDBCatalog, infos []*archive.ArchivedBlockfileInfo) error {
+	docs := make([]*blockfileDoc, 0, len(infos))
+	ids := make([]string, 0, len(infos))
+	for _, info := range infos {
+		doc, err := newBlockfileDoc(info)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+		ids = append(ids, doc.ID)
+	}
+	metadata, err := db.BatchRetrieveDocumentMetadata(ids)
+	if err != nil {
+		return err
+	}
+	revs := make(map[string]string)
+	for _, m := range metadata {
+		revs[m.ID] = m.Rev
+	}
+	couchDocs := make([]*couchdb.CouchDoc, 0, len(docs))
+	for _, doc := range docs {
+		doc.Rev = revs[doc.ID]
+		value, err := json.Marshal(doc)
+		if err != nil {
+			return errors.Wrapf(err, "error marshaling archive record of blockfile [%d]", doc.BlockfileNo)
+		}
+		couchDocs = append(couchDocs, &couchdb.CouchDoc{JSONValue: value})
+	}
+	return saveCouchDocs(db, couchDocs)
+}
+
+// putBlockfileWithBlocks saves the documents of the blocks in batches, then the one of the blockfile.
+// The blocks of a blockfile are only looked up once the blockfile is recorded, so a failure part way
+// leaves documents which are overwritten when the blockfile is recorded again.
+func (s *couchDBCatalogStore) putBlockfileWithBlocks(info *archive.ArchivedBlockfileInfo, blocks []*archive.ArchivedBlockInfo) error {
+	db, err := s.database()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(blocks); start += catalogCouchDBPageSize {
+		end := start + catalogCouchDBPageSize
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		if err := saveBlockDocs(db, blocks[start:end]); err != nil {
+			return errors.WithMessagef(err, "error saving archive records of the blocks of blockfile [%d]", info.BlockfileNo)
+		}
+	}
+	return s.putBlockfile(info)
+}
+
+func saveBlockDocs(db couchDBCatalog, blocks []*archive.ArchivedBlockInfo) error {
+	docs := make([]*blockDoc, 0, len(blocks))
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		doc, err := newBlockDoc(block)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+		ids = append(ids, doc.ID)
+	}
+	// The documents of the blocks recorded before are updated from their revision
+	metadata, err := db.BatchRetrieveDocumentMetadata(ids)
+	if err != nil {
+		return err
+	}
+	revs := make(map[string]string)
+	for _, m := range metadata {
+		revs[m.ID] = m.Rev
+	}
+	couchDocs := make([]*couchdb.CouchDoc, 0, len(docs))
+	for _, doc := range docs {
+		doc.Rev = revs[doc.ID]
+		value, err := json.Marshal(doc)
+		if err != nil {
+			return errors.Wrapf(err, "error marshaling archive record of block [%d]", doc.BlockNum)
+		}
+		couchDocs = append(couchDocs, &couchdb.CouchDoc{JSONValue: value})
+	}
+	return saveCouchDocs(db, couchDocs)
+}
+
+// saveCouchDocs saves documents in a single bulk update
+func saveCouchDocs(db couchDBCatalog, couchDocs []*couchdb.CouchDoc) error {
+	responses, err := db.BatchUpdateDocuments(couchDocs)
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		if !response.Ok {
+			return errors.Errorf("error saving document %s: %s %s", response.ID, response.Error, response.Reason)
+		}
+	}
+	return nil
+}
+
+func (s *couchDBCatalogStore) getBlockfile(fileNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	db, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	doc, _, err := db.ReadDoc(blockfileDocID(fileNum))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error reading archive record of blockfile [%d]", fileNum)
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	return unmarshalBlockfileDoc(doc.JSONValue)
+}
+
+// getBlockfileContaining queries the first blockfile whose last block is not before the block
+func (s *couchDBCatalogStore) getBlockfileContaining(blockNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	db, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	query, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{"docType": blockfileDocType, "lastBlockNum": map[string]interface{}{"$gte": blockNum}},
+		"sort":     []map[string]string{{"docType": "asc"}, {"lastBlockNum": "asc"}},
+		"limit":    1,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling archive record query")
+	}
+	results, _, err := db.QueryDocuments(string(query))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error querying archive record of block [%d]", blockNum)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	info, err := unmarshalBlockfileDoc(results[0].Value)
+	if err != nil || info.FirstBlockNum > blockNum {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *couchDBCatalogStore) listBlockfiles() ([]*archive.ArchivedBlockfileInfo, error) {
+	db, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	var infos []*archive.ArchivedBlockfileInfo
+	startKey, endKey := blockfileDocID(0), blockfileDocID(math.MaxUint64)
+	for {
+		results, nextStartKey, err := db.ReadDocRange(startKey, endKey, catalogCouchDBPageSize)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error listing archive records")
+		}
+		for _, result := range results {
+			info, err := unmarshalBlockfileDoc(result.Value)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		if nextStartKey == endKey || len(results) == 0 {
+			return infos, nil
+		}
+		startKey = nextStartKey
+	}
+}
+
+func (s *couchDBCatalogStore) getBlock(fileNum, blockNum uint64) (*archive.ArchivedBlockInfo, error) {
+	db, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	doc, _, err := db.ReadDoc(blockDocID(blockNum))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error reading archive record of block [%d]", blockNum)
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	block, err := unmarshalBlockDoc(doc.JSONValue)
+	if err != nil || block.BlockfileNo != fileNum {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (s *couchDBCatalogStore) getBlockByHash(headerHash []byte) (*archive.ArchivedBlockInfo, error) {
+	return s.queryBlock(map[string]interface{}{"docType": blockDocType, "headerHash": hex.EncodeToString(headerHash)})
+}
+
+func (s *couchDBCatalogStore) getBlockAt(fileNum, offset uint64) (*archive.ArchivedBlockInfo, error) {
+	return s.queryBlock(map[string]interface{}{"docType": blockDocType, "blockfileNo": fileNum, "offset": offset})
+}
+
+// queryBlock returns the record of the block matching a Mango selector, nil if there is none
+func (s *couchDBCatalogStore) queryBlock(selector map[string]interface{}) (*archive.ArchivedBlockInfo, error) {
+	db, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	query, err := json.Marshal(map[string]interface{}{"selector": selector, "limit": 1})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling archive record query")
+	}
+	results, _, err := db.QueryDocuments(string(query))
+	if err != nil {
+		return nil, errors.WithMessage(err, "error querying archive records")
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return unmarshalBlockDoc(results[0].Value)
+}
@@ -0,0 +1,245 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/util/couchdb"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memCouchDB keeps the documents of a CouchDB database in memory, with the equality and $gte selectors
+// of Mango. The query results are sorted by id, which sorts the documents of the blockfiles by block range.
+type memCouchDB struct {
+	docs map[string]map[string]interface{}
+}
+
+func newMemCouchDB() *memCouchDB {
+	return &memCouchDB{docs: map[string]map[string]interface{}{}}
+}
+
+func (db *memCouchDB) save(value []byte) (string, error) {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return "", err
+	}
+	id := doc["_id"].(string)
+	rev := 1
+	if existing, ok := db.docs[id]; ok {
+		fmt.Sscanf(existing["_rev"].(string), "%d-", &rev)
+		rev++
+	}
+	doc["_rev"] = fmt.Sprintf("%d-rev", rev)
+	db.docs[id] = doc
+	return doc["_rev"].(string), nil
+}
+
+func (db *memCouchDB) value(id string) []byte {
+	value, _ := json.Marshal(db.docs[id])
+	return value
+}
+
+func (db *memCouchDB) ReadDoc(id string) (*couchdb.CouchDoc, string, error) {
+	doc, ok := db.docs[id]
+	if !ok {
+		return nil, "", nil
+	}
+	return &couchdb.CouchDoc{JSONValue: db.value(id)}, doc["_rev"].(string), nil
+}
+
+func (db *memCouchDB) SaveDoc(id string, rev string, couchDoc *couchdb.CouchDoc) (string, error) {
+	return db.save(couchDoc.JSONValue)
+}
+
+func (db *memCouchDB) ReadDocRange(startKey, endKey string, limit int32) ([]*couchdb.QueryResult, string, error) {
+	var ids []string
+	for id := range db.docs {
+		if id >= startKey && id < endKey {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	nextStartKey := endKey
+	if len(ids) > int(limit) {
+		nextStartKey = ids[limit]
+		ids = ids[:limit]
+	}
+	var results []*couchdb.QueryResult
+	for _, id := range ids {
+		results = append(results, &couchdb.QueryResult{ID: id, Value: db.value(id)})
+	}
+	return results, nextStartKey, nil
+}
+
+func (db *memCouchDB) QueryDocuments(query string) ([]*couchdb.QueryResult, string, error) {
+	q := struct {
+		Selector map[string]interface{} `json:"selector"`
+		Limit    int                    `json:"limit"`
+	}{}
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		return nil, "", err
+	}
+	var ids []string
+	for id, doc := range db.docs {
+		matched := true
+		for field, value := range q.Selector {
+			if operator, ok := value.(map[string]interface{}); ok {
+				number, isNumber := doc[field].(float64)
+				matched = matched && isNumber && number >= operator["$gte"].(float64)
+				continue
+			}
+			matched = matched && reflect.DeepEqual(doc[field], value)
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	var results []*couchdb.QueryResult
+	for _, id := range ids {
+		if q.Limit > 0 && len(results) == q.Limit {
+			break
+		}
+		results = append(results, &couchdb.QueryResult{ID: id, Value: db.value(id)})
+	}
+	return results, "", nil
+}
+
+func (db *memCouchDB) BatchRetrieveDocumentMetadata(keys []string) ([]*couchdb.DocMetadata, error) {
+	var metadata []*couchdb.DocMetadata
+	for _, key := range keys {
+		if doc, ok := db.docs[key]; ok {
+			metadata = append(metadata, &couchdb.DocMetadata{ID: key, Rev: doc["_rev"].(string)})
+		}
+	}
+	return metadata, nil
+}
+
+func (db *memCouchDB) BatchUpdateDocuments(documents []*couchdb.CouchDoc) ([]*couchdb.BatchUpdateResponse, error) {
+	var responses []*couchdb.BatchUpdateResponse
+	for _, document := range documents {
+		rev, err := db.save(document.JSONValue)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, &couchdb.BatchUpdateResponse{Ok: true, Rev: rev})
+	}
+	return responses, nil
+}
+
+func TestCouchDBArchiveCatalog(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	rootDir := arch.mgr.rootDir
+
+	// The records are kept in CouchDB, the journal of the discards in the db of the block index
+	couchDB := newMemCouchDB()
+	arch.catalog.store = &couchDBCatalogStore{chainID: "testLedger", db: couchDB}
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	require.NoError(t, arch.recordArchivedBlockfile(1, false))
+	summary, err := scanBlockfile(rootDir, 1)
+	require.NoError(t, err)
+
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, uint64(0), infos[0].BlockfileNo)
+	assert.Equal(t, uint64(1), infos[1].BlockfileNo)
+	assert.Equal(t, summary.firstBlockNum, infos[1].FirstBlockNum)
+	assert.Equal(t, summary.lastBlockNum, infos[1].LastBlockNum)
+
+	block, err := arch.catalog.GetArchivedBlock(summary.firstBlockNum)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(summary.blocks[0], block))
+	block, err = arch.catalog.GetArchivedBlockByHash(summary.lastBlockHash)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(summary.blocks[len(summary.blocks)-1], block))
+	block, err = arch.catalog.getArchivedBlockAt(1, summary.blocks[1].Offset)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(summary.blocks[1], block))
+	block, err = arch.catalog.GetArchivedBlockByHash([]byte("unknown"))
+	require.NoError(t, err)
+	assert.Nil(t, block)
+
+	// The blockfile of a block is located by a query on the last blocks of the blockfiles
+	for _, archived := range infos {
+		for _, blockNum := range []uint64{archived.FirstBlockNum, archived.LastBlockNum} {
+			info, err := arch.catalog.GetArchiveLocation(blockNum)
+			require.NoError(t, err)
+			require.NotNil(t, info)
+			assert.Equal(t, archived.BlockfileNo, info.BlockfileNo)
+		}
+	}
+	location, err := arch.catalog.GetArchiveLocation(summary.lastBlockNum + 1)
+	require.NoError(t, err)
+	assert.Nil(t, location)
+
+	// The documents are queryable by their fields
+	doc := couchDB.docs[blockfileDocID(1)]
+	assert.Equal(t, blockfileDocType, doc["docType"])
+	assert.Equal(t, "testLedger", doc["channelId"])
+	assert.Equal(t, float64(summary.lastBlockNum), doc["lastBlockNum"])
+	assert.Equal(t, false, doc["discarded"])
+
+	// A discard whose record was not marked in CouchDB is completed by the recovery
+	require.NoError(t, arch.catalog.db.Put(constructDiscardJournalKey(1), []byte(rootDir), true))
+	recovered, err := arch.catalog.recoverDiscards()
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, recovered)
+	_, err = os.Stat(deriveBlockfilePath(rootDir, 1))
+	assert.True(t, os.IsNotExist(err))
+	info, err := arch.catalog.getArchivedBlockfile(1)
+	require.NoError(t, err)
+	assert.True(t, info.Discarded)
+	assert.Equal(t, true, couchDB.docs[blockfileDocID(1)]["discarded"])
+
+	info, err = arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(rootDir, info))
+	ranges, err := arch.catalog.GetDiscardedRanges()
+	require.NoError(t, err)
+	assert.Equal(t, []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: summary.lastBlockNum}}, ranges)
+
+	// Recording a blockfile again updates its documents
+	require.NoError(t, arch.catalog.recordArchivedBlockfileWithBlocks(infos[1], summary.blocks))
+	assert.Equal(t, "2-rev", couchDB.docs[blockDocID(summary.firstBlockNum)]["_rev"])
+
+	// The records of several blockfiles are updated at once
+	infos, err = arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	for _, info := range infos {
+		info.Discarded = false
+	}
+	require.NoError(t, arch.catalog.recordArchivedBlockfiles(infos))
+	ranges, err = arch.catalog.GetDiscardedRanges()
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+	assert.Equal(t, false, couchDB.docs[blockfileDocID(0)]["discarded"])
+}
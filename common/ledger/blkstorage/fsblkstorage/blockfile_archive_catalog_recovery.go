@@ -0,0 +1,453 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CatalogSnapshotsDir is the name of the directory containing the snapshots of the archive catalogs of all channels
+	CatalogSnapshotsDir   = "catalogSnapshots"
+	catalogSnapshotPrefix = "catalog_"
+	// defaultCatalogSnapshotsKept is the number of snapshots kept per channel when CatalogSnapshotsKept is not positive
+	defaultCatalogSnapshotsKept = 3
+)
+
+// StartCatalogSnapshots takes a snapshot of the archive catalogs of the open ledgers every interval, from
+// which the catalogs are recovered at the startup of the peer if they have been corrupted
+func StartCatalogSnapshots(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, arch := range openArchivers() {
+				if _, err := arch.snapshotCatalog(); err != nil {
+					loggerArchive.Errorf("[%s] Failed taking a snapshot of the archive catalog: %s", arch.chainID, err)
+				}
+			}
+		}
+	}()
+}
+
+// catalogSnapshotDir returns the directory of the snapshots of the archive catalog of the ledger
+func (arch *blockfileArchiver) catalogSnapshotDir() string {
+	return filepath.Join(arch.mgr.conf.blockStorageDir, CatalogSnapshotsDir, arch.chainID)
+}
+
+// snapshotCatalog writes the records of the archive catalog, along with the next blockfile to be archived,
+// to a new snapshot and removes the oldest ones beyond CatalogSnapshotsKept. A snapshot starts with the
+// checksum of its content, and is renamed into place once synced, so that a crash leaves either a complete
+// snapshot or none. It returns the path of the snapshot.
+func (arch *blockfileArchiver) snapshotCatalog() (string, error) {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return "", err
+	}
+	// The archiver starts with blockfile 1 when it has no checkpoint, see newBlockfileArchiver
+	next := uint64(1)
+	if cp, err := readArchiverCheckpoint(arch.mgr.db); err != nil {
+		return "", err
+	} else if cp != nil {
+		next = uint64(cp.nextBlockfileNum)
+	}
+	if n := len(infos); n > 0 && infos[n-1].BlockfileNo+1 > next {
+		next = infos[n-1].BlockfileNo + 1
+	}
+	content, err := proto.Marshal(&archive.ChannelArchiverState{ChannelId: arch.chainID, NextBlockfileNo: next, Blockfiles: infos})
+	if err != nil {
+		return "", errors.Wrap(err, "error marshaling archive catalog snapshot")
+	}
+	checksum, err := blockarchive.ComputeChecksum(bytes.NewReader(content), blockarchive.ChecksumAlgorithm)
+	if err != nil {
+		return "", err
+	}
+
+	dir := arch.catalogSnapshotDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "error creating %s", dir)
+	}
+	snapshotPath := filepath.Join(dir, fmt.Sprintf("%s%020d", catalogSnapshotPrefix, time.Now().UnixNano()))
+	if err := writeFileSynced(snapshotPath, append([]byte(checksum.String()+"\n"), content...)); err != nil {
+		return "", err
+	}
+	loggerArchive.Debugf("[%s] Took a snapshot of the %d record(s) of the archive catalog to %s", arch.chainID, len(infos), snapshotPath)
+
+	kept := blockarchive.CatalogSnapshotsKept
+	if kept <= 0 {
+		kept = defaultCatalogSnapshotsKept
+	}
+	snapshots, err := listCatalogSnapshots(dir)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < len(snapshots)-kept; i++ {
+		if err := os.Remove(snapshots[i]); err != nil {
+			loggerArchive.Warningf("[%s] Could not remove the archive catalog snapshot %s: %s", arch.chainID, snapshots[i], err)
+		}
+	}
+	return snapshotPath, nil
+}
+
+// writeFileSynced writes a file through a temporary file synced to disk and renamed, then syncs its directory
+func writeFileSynced(filePath string, content []byte) error {
+	tmpPath := filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "error creating %s", tmpPath)
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return errors.Wrapf(err, "error writing %s", tmpPath)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return errors.Wrapf(err, "error syncing %s", tmpPath)
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrapf(err, "error closing %s", tmpPath)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return errors.Wrapf(err, "error renaming %s", tmpPath)
+	}
+	dir, err := os.Open(filepath.Dir(filePath))
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", filepath.Dir(filePath))
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// listCatalogSnapshots returns the paths of the snapshots in a directory, from the oldest to the newest
+func listCatalogSnapshots(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", dir)
+	}
+	var snapshots []string
+	for _, file := range files {
+		// The temporary files of the snapshots interrupted by a crash are left out
+		if file.IsDir() || !strings.HasPrefix(file.Name(), catalogSnapshotPrefix) || filepath.Ext(file.Name()) != "" {
+			continue
+		}
+		snapshots = append(snapshots, filepath.Join(dir, file.Name()))
+	}
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// readCatalogSnapshot reads a snapshot of the archive catalog and checks it against its checksum
+func readCatalogSnapshot(snapshotPath string) (*archive.ChannelArchiverState, error) {
+	b, err := ioutil.ReadFile(snapshotPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", snapshotPath)
+	}
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return nil, errors.Errorf("no checksum in snapshot %s", snapshotPath)
+	}
+	expected, err := blockarchive.ParseChecksum(string(b[:i]))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid checksum of snapshot %s", snapshotPath)
+	}
+	content := b[i+1:]
+	actual, err := blockarchive.ComputeChecksum(bytes.NewReader(content), expected.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if actual.String() != expected.String() {
+		return nil, errors.Errorf("snapshot %s does not match its checksum %s", snapshotPath, expected)
+	}
+	state := &archive.ChannelArchiverState{}
+	if err := proto.Unmarshal(content, state); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling snapshot %s", snapshotPath)
+	}
+	if err := validateArchiveCatalog(state); err != nil {
+		return nil, errors.WithMessagef(err, "invalid snapshot %s", snapshotPath)
+	}
+	return state, nil
+}
+
+// inspectCatalog checks that the records of the archive catalog stored in the db of the block index can all be
+// read, that each is stored under the key of its blockfile, and that they describe distinct blockfiles of the
+// channel with ascending block ranges. It also checks that the checkpoint of the archiver can be read. It
+// returns the reason why the catalog is corrupted, or an empty string.
+func (arch *blockfileArchiver) inspectCatalog() (string, error) {
+	if _, err := readArchiverCheckpoint(arch.mgr.db); err != nil {
+		return err.Error(), nil
+	}
+	itr := arch.mgr.db.GetIterator([]byte{archivedBlockfileKeyPrefix}, []byte{archivedBlockfileKeyPrefix + 1})
+	defer itr.Release()
+
+	state := &archive.ChannelArchiverState{ChannelId: arch.chainID}
+	for itr.Next() {
+		info := &archive.ArchivedBlockfileInfo{}
+		if err := proto.Unmarshal(itr.Value(), info); err != nil {
+			return fmt.Sprintf("unreadable record under key %x: %s", itr.Key(), err), nil
+		}
+		if !bytes.Equal(itr.Key(), constructArchivedBlockfileKey(info.BlockfileNo)) {
+			return fmt.Sprintf("record of blockfile [%d] under key %x", info.BlockfileNo, itr.Key()), nil
+		}
+		state.Blockfiles = append(state.Blockfiles, info)
+	}
+	if err := itr.Error(); err != nil {
+		return "", errors.Wrap(err, "error iterating archive records")
+	}
+	if err := validateArchiveCatalog(state); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// recoverCatalog is called at the startup of the peer, before the archiver resumes. If the archive catalog is
+// corrupted, its records are replaced with those of the newest snapshot which matches its checksum, and the
+// records archived since then, or all of them without a snapshot, are rebuilt from the listing of the repository.
+// Only the catalogs stored in the db of the block index are checked, the CouchDB ones are backed up and restored
+// with the CouchDB tooling.
+func (arch *blockfileArchiver) recoverCatalog() error {
+	if blockarchive.CatalogDatabase == blockarchive.CatalogDatabaseCouchDB {
+		return nil
+	}
+	reason, err := arch.inspectCatalog()
+	if err != nil || reason == "" {
+		return err
+	}
+	loggerAudit.Errorf("[%s] The archive catalog is corrupted: %s", arch.chainID, reason)
+	blockarchive.RaiseAlert(blockarchive.AlertVerificationFailure, arch.chainID, "the archive catalog is corrupted: %s", reason)
+
+	state := &archive.ChannelArchiverState{ChannelId: arch.chainID, NextBlockfileNo: 1}
+	snapshots, err := listCatalogSnapshots(arch.catalogSnapshotDir())
+	if err != nil {
+		return err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot, err := readCatalogSnapshot(snapshots[i])
+		if err != nil {
+			loggerArchive.Warningf("[%s] Skipped archive catalog snapshot: %s", arch.chainID, err)
+			continue
+		}
+		loggerAudit.Warningf("[%s] Restoring the %d record(s) of the archive catalog from snapshot %s", arch.chainID, len(snapshot.Blockfiles), snapshots[i])
+		state = snapshot
+		break
+	}
+	if len(state.Blockfiles) == 0 {
+		loggerAudit.Warningf("[%s] No valid snapshot of the archive catalog, all its records are rebuilt from the repository", arch.chainID)
+	}
+	if err := arch.restoreCatalog(state); err != nil {
+		return err
+	}
+
+	rebuilt, err := arch.rebuildLostRecords()
+	if err != nil {
+		return errors.WithMessage(err, "error rebuilding the records of the archive catalog from the repository")
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return err
+	}
+	loggerAudit.Warningf("[%s] Recovered the archive catalog: %d record(s), %d of them rebuilt from the repository", arch.chainID, len(infos), rebuilt)
+	if _, err := arch.snapshotCatalog(); err != nil {
+		loggerArchive.Errorf("[%s] Failed taking a snapshot of the recovered archive catalog: %s", arch.chainID, err)
+	}
+	return nil
+}
+
+// restoreCatalog replaces the records of the catalog and the checkpoint of the archiver with those of a snapshot
+// in a single batch. The blockfiles still on the local file system are recorded as not discarded, the others as
+// discarded, and the archiver resumes from the next blockfile of the snapshot with no blockfile in flight.
+func (arch *blockfileArchiver) restoreCatalog(state *archive.ChannelArchiverState) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	for _, prefix := range []byte{archivedBlockfileKeyPrefix, archivedBlockfileRangeKeyPrefix} {
+		itr := arch.mgr.db.GetIterator([]byte{prefix}, []byte{prefix + 1})
+		for itr.Next() {
+			batch.Delete(append([]byte{}, itr.Key()...))
+		}
+		err := itr.Error()
+		itr.Release()
+		if err != nil {
+			return errors.Wrap(err, "error iterating archive records")
+		}
+	}
+
+	for _, info := range state.Blockfiles {
+		record := proto.Clone(info).(*archive.ArchivedBlockfileInfo)
+		_, err := os.Stat(deriveBlockfilePath(arch.mgr.rootDir, int(info.BlockfileNo)))
+		if record.Discarded = os.IsNotExist(err); record.Discarded {
+			record.RestoreExpiry = nil
+		}
+		b, err := proto.Marshal(record)
+		if err != nil {
+			return errors.Wrapf(err, "error marshaling archive record of blockfile [%d]", record.BlockfileNo)
+		}
+		batch.Put(constructArchivedBlockfileKey(record.BlockfileNo), b)
+		addArchivedBlockfileRangeKey(batch, record)
+	}
+	batch.Put(archivedBlockfileRangeIndexKey, []byte{1})
+	cp := &archiverCheckpoint{nextBlockfileNum: int(state.NextBlockfileNo), inFlightBlockfileNum: noInFlightBlockfile}
+	b, err := cp.marshal()
+	if err != nil {
+		return errors.Wrap(err, "error marshaling archiver checkpoint")
+	}
+	batch.Put(archiverCheckpointKey, b)
+	return arch.mgr.db.WriteBatch(batch, true)
+}
+
+// rebuildLostRecords records the blockfiles of the ledger found on the repository which are not in the catalog,
+// and moves the checkpoint of the archiver past them. The block range of a blockfile is read from the local
+// blockfile, or from the archived one downloaded if it has been discarded. Only the blockfiles archived under
+// their local paths are found, the others are only known to the catalog. It returns the number of records rebuilt.
+func (arch *blockfileArchiver) rebuildLostRecords() (int, error) {
+	if blockarchive.ContentAddressed || blockarchive.ObjectKeyTemplate != "" {
+		loggerArchive.Warningf("[%s] The blockfiles are not archived under their local paths, the records lost are not rebuilt", arch.chainID)
+		return 0, nil
+	}
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return 0, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	dir := path.Dir(deriveArchivedBlockfilePath(arch.blockfileDir, 0))
+	fileInfos, err := client.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing %s on the repository", dir)
+	}
+	var fileNums []int
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || isBlockfileSidecar(fileInfo.Name()) || !strings.HasPrefix(fileInfo.Name(), blockfilePrefix) {
+			continue
+		}
+		fileNum, err := strconv.Atoi(strings.TrimPrefix(fileInfo.Name(), blockfilePrefix))
+		if err != nil {
+			continue
+		}
+		if info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum)); err != nil {
+			return 0, err
+		} else if info == nil {
+			fileNums = append(fileNums, fileNum)
+		}
+	}
+	sort.Ints(fileNums)
+
+	rebuilt := 0
+	for _, fileNum := range fileNums {
+		if err := arch.rebuildRecord(client, fileNum); err != nil {
+			loggerAudit.Errorf("[%s] Could not rebuild the record of archived blockfile [%d]: %s", arch.chainID, fileNum, err)
+			continue
+		}
+		loggerAudit.Warningf("[%s] Rebuilt the record of archived blockfile [%d] from the repository", arch.chainID, fileNum)
+		rebuilt++
+	}
+
+	cp, err := readArchiverCheckpoint(arch.mgr.db)
+	if err != nil {
+		return rebuilt, err
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return rebuilt, err
+	}
+	if n := len(infos); n > 0 && (cp == nil || uint64(cp.nextBlockfileNum) <= infos[n-1].BlockfileNo) {
+		cp = &archiverCheckpoint{nextBlockfileNum: int(infos[n-1].BlockfileNo) + 1, inFlightBlockfileNum: noInFlightBlockfile}
+		b, err := cp.marshal()
+		if err != nil {
+			return rebuilt, errors.Wrap(err, "error marshaling archiver checkpoint")
+		}
+		if err := arch.mgr.db.Put(archiverCheckpointKey, b, true); err != nil {
+			return rebuilt, errors.Wrap(err, "error writing archiver checkpoint")
+		}
+	}
+	return rebuilt, nil
+}
+
+// rebuildRecord records a blockfile archived on the repository, along with its blocks
+func (arch *blockfileArchiver) rebuildRecord(client repositoryClient, fileNum int) error {
+	location := deriveArchivedBlockfilePath(arch.blockfileDir, fileNum)
+	remoteInfo, err := client.Stat(location)
+	if err != nil {
+		return errors.Wrapf(err, "error reading archived blockfile %s", location)
+	}
+	rootDir := arch.mgr.rootDir
+	_, err = os.Stat(deriveBlockfilePath(rootDir, fileNum))
+	discarded := os.IsNotExist(err)
+	if discarded {
+		tmpDir, err := ioutil.TempDir("", "catalog-recovery")
+		if err != nil {
+			return errors.Wrap(err, "error creating temporary directory")
+		}
+		defer os.RemoveAll(tmpDir)
+		if _, err := fetchBlockfileFromRepo(arch.chainID, location, deriveBlockfilePath(tmpDir, fileNum), ""); err != nil {
+			return err
+		}
+		rootDir = tmpDir
+	}
+	summary, err := scanBlockfile(rootDir, fileNum)
+	if err != nil {
+		return err
+	}
+	checksum, err := blockarchive.ComputeBlockfileChecksum(deriveBlockfilePath(rootDir, fileNum), blockarchive.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+	// The local blockfile must be the one archived, as checked against the checksum stored next to it
+	if !discarded {
+		expected, err := remoteChecksum(client, location, "")
+		if err != nil {
+			return errors.WithMessagef(err, "error reading the checksum of %s", location)
+		}
+		if expected != nil {
+			actual, err := blockarchive.ComputeBlockfileChecksum(deriveBlockfilePath(rootDir, fileNum), expected.Algorithm)
+			if err != nil {
+				return err
+			}
+			if actual.String() != expected.String() {
+				return errors.Errorf("local blockfile [%d] does not match the checksum %s of the archived one", fileNum, expected)
+			}
+		}
+	}
+	keyID, err := remoteEncryptionKeyID(client, location)
+	if err != nil {
+		return err
+	}
+	archivedAt, _ := ptypes.TimestampProto(remoteInfo.ModTime())
+	return arch.catalog.recordArchivedBlockfileWithBlocks(&archive.ArchivedBlockfileInfo{
+		ChannelID:       arch.chainID,
+		BlockfileNo:     uint64(fileNum),
+		FirstBlockNum:   summary.firstBlockNum,
+		LastBlockNum:    summary.lastBlockNum,
+		Repository:      blockarchive.RepositoryURLOf(arch.chainID),
+		Location:        location,
+		Discarded:       discarded,
+		Checksum:        checksum.String(),
+		NetworkID:       blockarchive.NetworkID,
+		Environment:     blockarchive.Environment,
+		ArchivedAt:      archivedAt,
+		LastBlockTime:   summary.lastBlockTime,
+		EncryptionKeyId: keyID,
+	}, summary.blocks)
+}
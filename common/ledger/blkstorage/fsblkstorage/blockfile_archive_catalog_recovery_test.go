@@ -0,0 +1,201 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testArchivedBlockfile(fileNum, firstBlockNum, lastBlockNum uint64) *archive.ArchivedBlockfileInfo {
+	return &archive.ArchivedBlockfileInfo{
+		ChannelID:     "testLedger",
+		BlockfileNo:   fileNum,
+		FirstBlockNum: firstBlockNum,
+		LastBlockNum:  lastBlockNum,
+		Location:      deriveArchivedBlockfilePath("testLedger", int(fileNum)),
+	}
+}
+
+func TestCatalogSnapshots(t *testing.T) {
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	arch := store.(*fsBlockStore).archiver
+	defer func(kept int) { blockarchive.CatalogSnapshotsKept = kept }(blockarchive.CatalogSnapshotsKept)
+	blockarchive.CatalogSnapshotsKept = 2
+
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(testArchivedBlockfile(0, 0, 9)))
+	first, err := arch.snapshotCatalog()
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(testArchivedBlockfile(1, 10, 19)))
+	_, err = arch.snapshotCatalog()
+	require.NoError(t, err)
+	last, err := arch.snapshotCatalog()
+	require.NoError(t, err)
+
+	// The oldest snapshot is removed, as are the temporary files left by a crash
+	require.NoError(t, ioutil.WriteFile(last+".tmp", []byte("partial"), 0644))
+	snapshots, err := listCatalogSnapshots(arch.catalogSnapshotDir())
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.NotContains(t, snapshots, first)
+	assert.Equal(t, last, snapshots[1])
+
+	state, err := readCatalogSnapshot(last)
+	require.NoError(t, err)
+	assert.Equal(t, "testLedger", state.ChannelId)
+	assert.Equal(t, uint64(2), state.NextBlockfileNo)
+	require.Len(t, state.Blockfiles, 2)
+	assert.Equal(t, uint64(19), state.Blockfiles[1].LastBlockNum)
+
+	// A snapshot which doesn't match its checksum is refused
+	content, err := ioutil.ReadFile(last)
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(last, content, 0644))
+	_, err = readCatalogSnapshot(last)
+	assert.Contains(t, err.Error(), "does not match its checksum")
+}
+
+func TestInspectCatalog(t *testing.T) {
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	arch := store.(*fsBlockStore).archiver
+
+	reason, err := arch.inspectCatalog()
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(testArchivedBlockfile(0, 0, 9)))
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(testArchivedBlockfile(1, 10, 19)))
+	reason, err = arch.inspectCatalog()
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+
+	// Overlapping block ranges
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(testArchivedBlockfile(2, 15, 29)))
+	reason, err = arch.inspectCatalog()
+	require.NoError(t, err)
+	assert.Contains(t, reason, "blockfile [2] with blocks [15-29] is out of order")
+	require.NoError(t, arch.mgr.db.Delete(constructArchivedBlockfileKey(2), true))
+
+	// A record stored under the key of another blockfile
+	b, err := proto.Marshal(testArchivedBlockfile(3, 20, 29))
+	require.NoError(t, err)
+	require.NoError(t, arch.mgr.db.Put(constructArchivedBlockfileKey(2), b, true))
+	reason, err = arch.inspectCatalog()
+	require.NoError(t, err)
+	assert.Contains(t, reason, "record of blockfile [3] under key")
+
+	// An unreadable record
+	require.NoError(t, arch.mgr.db.Put(constructArchivedBlockfileKey(2), []byte{0xff, 0xff, 0xff}, true))
+	reason, err = arch.inspectCatalog()
+	require.NoError(t, err)
+	assert.Contains(t, reason, "unreadable record")
+}
+
+func TestRecoverCatalog(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	archiveBlockfile := func(fileNum int, discard bool) {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, discard))
+	}
+	corrupt := func() {
+		require.NoError(t, arch.mgr.db.Put(constructArchivedBlockfileKey(0), []byte{0xff, 0xff, 0xff}, true))
+	}
+
+	// Blockfile 0 is discarded and in the snapshot, blockfile 1 is archived after the snapshot
+	archiveBlockfile(0, true)
+	_, err = arch.snapshotCatalog()
+	require.NoError(t, err)
+	archiveBlockfile(1, false)
+	expected, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, expected, 2)
+
+	// A sound catalog is left as is
+	require.NoError(t, arch.recoverCatalog())
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	assert.Equal(t, expected, infos)
+
+	checkRecovered := func() {
+		infos, err := arch.catalog.ListArchivedBlockfiles()
+		require.NoError(t, err)
+		require.Len(t, infos, 2)
+		for i, info := range infos {
+			assert.Equal(t, expected[i].BlockfileNo, info.BlockfileNo)
+			assert.Equal(t, expected[i].FirstBlockNum, info.FirstBlockNum)
+			assert.Equal(t, expected[i].LastBlockNum, info.LastBlockNum)
+			assert.Equal(t, expected[i].Location, info.Location)
+			assert.Equal(t, expected[i].Checksum, info.Checksum)
+			assert.Equal(t, expected[i].Discarded, info.Discarded)
+		}
+		// The blocks of the rebuilt records are recorded
+		for fileNum, blockNum := range []uint64{5, 15} {
+			block, err := arch.catalog.GetArchivedBlock(blockNum)
+			require.NoError(t, err)
+			require.NotNil(t, block)
+			assert.Equal(t, uint64(fileNum), block.BlockfileNo)
+		}
+		cp, err := readArchiverCheckpoint(arch.mgr.db)
+		require.NoError(t, err)
+		assert.Equal(t, 2, cp.nextBlockfileNum)
+		assert.Equal(t, noInFlightBlockfile, cp.inFlightBlockfileNum)
+	}
+
+	// The snapshot restores blockfile 0, the listing of the repository blockfile 1
+	corrupt()
+	require.NoError(t, arch.recoverCatalog())
+	checkRecovered()
+
+	// Without a snapshot, the discarded blockfile is downloaded to rebuild its record
+	require.NoError(t, os.RemoveAll(arch.catalogSnapshotDir()))
+	corrupt()
+	require.NoError(t, arch.recoverCatalog())
+	checkRecovered()
+	// The recovered catalog is snapshotted
+	snapshots, err := listCatalogSnapshots(arch.catalogSnapshotDir())
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+}
@@ -0,0 +1,246 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveCatalog(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	catalog := store.GetArchiveCatalog()
+
+	archived, err := catalog.IsBlockArchived(0)
+	assert.NoError(t, err)
+	assert.False(t, archived)
+	ranges, err := catalog.GetArchivedRanges()
+	assert.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	require.NoError(t, arch.recordArchivedBlockfile(1, false))
+
+	infos, err := catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, uint64(0), infos[0].FirstBlockNum)
+	assert.Equal(t, infos[0].LastBlockNum+1, infos[1].FirstBlockNum)
+	assert.False(t, infos[1].Discarded)
+
+	ranges, err = catalog.GetArchivedRanges()
+	assert.NoError(t, err)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, uint64(0), ranges[0].FirstBlockNum)
+	assert.Equal(t, infos[1].LastBlockNum, ranges[0].LastBlockNum)
+
+	archived, err = catalog.IsBlockArchived(infos[1].LastBlockNum)
+	assert.NoError(t, err)
+	assert.True(t, archived)
+	archived, err = catalog.IsBlockArchived(infos[1].LastBlockNum + 1)
+	assert.NoError(t, err)
+	assert.False(t, archived)
+
+	// Discarding an already archived blockfile updates its record
+	require.NoError(t, arch.recordArchivedBlockfile(1, true))
+	info, err := catalog.GetArchiveLocation(infos[1].FirstBlockNum)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), info.BlockfileNo)
+	assert.True(t, info.Discarded)
+	ranges, err = catalog.GetDiscardedRanges()
+	assert.NoError(t, err)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, infos[1].FirstBlockNum, ranges[0].FirstBlockNum)
+	assert.Equal(t, infos[1].LastBlockNum, ranges[0].LastBlockNum)
+}
+
+func TestArchiveLocation(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	catalog := store.GetArchiveCatalog()
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	require.NoError(t, arch.recordArchivedBlockfile(1, false))
+	infos, err := catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+
+	assertLocation := func(blockNum uint64, fileNum int) {
+		info, err := catalog.GetArchiveLocation(blockNum)
+		require.NoError(t, err)
+		if fileNum < 0 {
+			assert.Nil(t, info, "block [%d]", blockNum)
+			return
+		}
+		require.NotNil(t, info, "block [%d]", blockNum)
+		assert.Equal(t, uint64(fileNum), info.BlockfileNo, "block [%d]", blockNum)
+	}
+	for _, info := range infos {
+		assertLocation(info.FirstBlockNum, int(info.BlockfileNo))
+		assertLocation(info.LastBlockNum, int(info.BlockfileNo))
+	}
+	assertLocation(infos[1].LastBlockNum+1, -1)
+
+	// A block in a gap between the archived blockfiles is not located
+	levelDBStore := arch.catalog.store.(*levelDBCatalogStore)
+	require.NoError(t, levelDBStore.putBlockfile(&archive.ArchivedBlockfileInfo{BlockfileNo: 5, FirstBlockNum: 100, LastBlockNum: 109}))
+	assertLocation(50, -1)
+	assertLocation(105, 5)
+
+	// A record whose block range changed is located by its new range only
+	require.NoError(t, levelDBStore.putBlockfile(&archive.ArchivedBlockfileInfo{BlockfileNo: 5, FirstBlockNum: 100, LastBlockNum: 104}))
+	assertLocation(104, 5)
+	assertLocation(107, -1)
+
+	// The records of a catalog written before the index by block range are indexed on the first lookup
+	batch := leveldbhelper.NewUpdateBatch()
+	batch.Delete(archivedBlockfileRangeIndexKey)
+	for _, lastBlockNum := range []uint64{infos[0].LastBlockNum, infos[1].LastBlockNum, 104} {
+		batch.Delete(constructArchivedBlockfileRangeKey(lastBlockNum))
+	}
+	require.NoError(t, levelDBStore.db.WriteBatch(batch, true))
+	arch.catalog.store = &levelDBCatalogStore{db: levelDBStore.db}
+	assertLocation(infos[0].FirstBlockNum, 0)
+	assertLocation(infos[1].LastBlockNum, 1)
+	assertLocation(102, 5)
+	marker, err := levelDBStore.db.Get(archivedBlockfileRangeIndexKey)
+	require.NoError(t, err)
+	assert.NotNil(t, marker)
+}
+
+func TestArchivedBlockRecords(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 20)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	blockStorePath := testPath()
+	env := newTestEnv(t, NewConf(blockStorePath, size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	content, err := ioutil.ReadFile(RawBlockfilePath(blockStorePath, "testLedger", 0))
+	require.NoError(t, err)
+
+	arch := store.(*fsBlockStore).archiver
+	catalog := store.GetArchiveCatalog()
+	record, err := catalog.GetArchivedBlock(0)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	offset := uint64(0)
+	for blockNum := uint64(0); blockNum < 10; blockNum++ {
+		record, err := catalog.GetArchivedBlock(blockNum)
+		require.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, blockNum, record.BlockNum)
+		assert.Equal(t, uint64(0), record.BlockfileNo)
+		assert.Equal(t, offset, record.Offset)
+		hash := protoutil.BlockHeaderHash(blocks[blockNum].Header)
+		assert.Equal(t, hash, record.HeaderHash)
+
+		// The recorded range of the blockfile holds the block preceded by its length
+		length, n := proto.DecodeVarint(content[record.Offset:])
+		assert.Equal(t, uint64(n)+length, record.Length)
+		assert.NoError(t, verifyArchivedBlockBytes(content[record.Offset+uint64(n):record.Offset+record.Length], record))
+		offset += record.Length
+
+		byHash, err := catalog.GetArchivedBlockByHash(hash)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(record, byHash))
+		at, err := arch.catalog.getArchivedBlockAt(0, record.Offset)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(record, at))
+	}
+	assert.Equal(t, uint64(len(content)), offset)
+
+	record, err = catalog.GetArchivedBlock(10)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+	record, err = catalog.GetArchivedBlockByHash(protoutil.BlockHeaderHash(blocks[10].Header))
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+	record, err = arch.catalog.getArchivedBlockAt(0, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	// A block found at the recorded offset of another block is detected
+	record, err = catalog.GetArchivedBlock(1)
+	require.NoError(t, err)
+	other, err := catalog.GetArchivedBlock(2)
+	require.NoError(t, err)
+	blockBytes := content[other.Offset : other.Offset+other.Length]
+	_, n := proto.DecodeVarint(blockBytes)
+	assert.Contains(t, verifyArchivedBlockBytes(blockBytes[n:], record).Error(), "block [2] found at offset")
+}
+
+func TestRetrieveArchivedBlockByHashWithoutIndex(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 10)
+	env := newTestEnvSelectiveIndexing(t, NewConf(testPath(), 0, "", ""), []blkstorage.IndexableAttr{blkstorage.IndexableAttrBlockNum})
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	hash := protoutil.BlockHeaderHash(blocks[3].Header)
+	_, err = store.RetrieveBlockByHash(hash)
+	assert.Equal(t, blkstorage.ErrAttrNotIndexed, err)
+
+	// The blocks of the archived blockfiles are located by hash through the catalog
+	require.NoError(t, store.(*fsBlockStore).archiver.recordArchivedBlockfile(0, false))
+	block, err := store.RetrieveBlockByHash(hash)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[3], block))
+}
@@ -0,0 +1,152 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// ExportArchiveCatalog returns the archive catalog of a ledger stored in blockStorePath, along with the
+// next blockfile to be archived, so that a peer replacing this one knows right away which blocks are
+// archived and where. It must not be called while the peer is running.
+func ExportArchiveCatalog(blockStorePath, ledgerID string) (*archive.ChannelArchiverState, error) {
+	conf := NewConf(blockStorePath, 0, "", "")
+	if _, err := os.Stat(conf.getLedgerBlockDir(ledgerID)); err != nil {
+		return nil, errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	dbProvider := leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: conf.getIndexDir()})
+	defer dbProvider.Close()
+	db := dbProvider.GetDBHandle(ledgerID)
+
+	infos, err := newArchiveCatalog(ledgerID, db).ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	cp, err := readArchiverCheckpoint(db)
+	if err != nil {
+		return nil, err
+	}
+	// The archiver starts with blockfile 1 when it has no checkpoint, see newBlockfileArchiver
+	next := uint64(1)
+	if cp != nil {
+		next = uint64(cp.nextBlockfileNum)
+	}
+	if n := len(infos); n > 0 && infos[n-1].BlockfileNo+1 > next {
+		next = infos[n-1].BlockfileNo + 1
+	}
+	return &archive.ChannelArchiverState{ChannelId: ledgerID, NextBlockfileNo: next, Blockfiles: infos}, nil
+}
+
+// ImportArchiveCatalog records the blockfiles of an archive catalog exported from another peer in the
+// archive catalog of the same ledger stored in blockStorePath, and returns the number of records imported.
+// The blockfiles still on the local file system must hold the blocks of their record, and are recorded as
+// not discarded; the existing records keep whether their blockfile has been discarded. The archiver resumes
+// from where the other peer stopped. It must not be called while the peer is running.
+func ImportArchiveCatalog(blockStorePath string, state *archive.ChannelArchiverState) (int, error) {
+	if err := validateArchiveCatalog(state); err != nil {
+		return 0, err
+	}
+	ledgerID := state.ChannelId
+	conf := NewConf(blockStorePath, 0, "", "")
+	rootDir := conf.getLedgerBlockDir(ledgerID)
+	if _, err := os.Stat(rootDir); err != nil {
+		return 0, errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	for _, info := range state.Blockfiles {
+		if _, err := os.Stat(deriveBlockfilePath(rootDir, int(info.BlockfileNo))); err != nil {
+			continue
+		}
+		summary, err := scanBlockfile(rootDir, int(info.BlockfileNo))
+		if err != nil {
+			return 0, err
+		}
+		if summary.firstBlockNum != info.FirstBlockNum || summary.lastBlockNum != info.LastBlockNum {
+			return 0, errors.Errorf("blockfile [%d] of ledger [%s] holds blocks [%d-%d], but blocks [%d-%d] have been archived",
+				info.BlockfileNo, ledgerID, summary.firstBlockNum, summary.lastBlockNum, info.FirstBlockNum, info.LastBlockNum)
+		}
+	}
+
+	dbProvider := leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: conf.getIndexDir()})
+	defer dbProvider.Close()
+	db := dbProvider.GetDBHandle(ledgerID)
+	catalog := newArchiveCatalog(ledgerID, db)
+	for _, info := range state.Blockfiles {
+		record := proto.Clone(info).(*archive.ArchivedBlockfileInfo)
+		record.ChannelID = ledgerID
+		local, err := catalog.getArchivedBlockfile(info.BlockfileNo)
+		if err != nil {
+			return 0, err
+		}
+		if local != nil {
+			record.Discarded, record.RestoreExpiry = local.Discarded, local.RestoreExpiry
+		} else {
+			_, err := os.Stat(deriveBlockfilePath(rootDir, int(info.BlockfileNo)))
+			record.Discarded, record.RestoreExpiry = os.IsNotExist(err), nil
+		}
+		if err := catalog.recordArchivedBlockfile(record); err != nil {
+			return 0, err
+		}
+	}
+
+	cp, err := readArchiverCheckpoint(db)
+	if err != nil {
+		return 0, err
+	}
+	if cp == nil {
+		cp = &archiverCheckpoint{nextBlockfileNum: 1, inFlightBlockfileNum: noInFlightBlockfile}
+	}
+	if uint64(cp.nextBlockfileNum) < state.NextBlockfileNo {
+		cp = &archiverCheckpoint{nextBlockfileNum: int(state.NextBlockfileNo), inFlightBlockfileNum: noInFlightBlockfile}
+		b, err := cp.marshal()
+		if err != nil {
+			return 0, errors.Wrap(err, "error marshaling archiver checkpoint")
+		}
+		if err := db.Put(archiverCheckpointKey, b, true); err != nil {
+			return 0, errors.Wrap(err, "error writing archiver checkpoint")
+		}
+	}
+	logger.Infof("Imported %d archived blockfile(s) of ledger [%s], next blockfile to be archived: %d",
+		len(state.Blockfiles), ledgerID, cp.nextBlockfileNum)
+	return len(state.Blockfiles), nil
+}
+
+// validateArchiveCatalog checks that the records of an exported archive catalog are those of
+// distinct blockfiles of the channel, in ascending order, with valid block ranges and locations
+func validateArchiveCatalog(state *archive.ChannelArchiverState) error {
+	if state.ChannelId == "" {
+		return errors.New("the channel of the archive catalog is empty")
+	}
+	for i, info := range state.Blockfiles {
+		if info.ChannelID != "" && info.ChannelID != state.ChannelId {
+			return errors.Errorf("blockfile [%d] of channel [%s] in the archive catalog of channel [%s]",
+				info.BlockfileNo, info.ChannelID, state.ChannelId)
+		}
+		// The catalogs of the networks sharing the repository and the channel names are not interchangeable
+		if err := blockarchive.CheckNetwork(info); err != nil {
+			return err
+		}
+		if info.FirstBlockNum > info.LastBlockNum {
+			return errors.Errorf("invalid block range [%d-%d] of blockfile [%d]", info.FirstBlockNum, info.LastBlockNum, info.BlockfileNo)
+		}
+		if info.Location == "" {
+			return errors.Errorf("the location of blockfile [%d] is empty", info.BlockfileNo)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := state.Blockfiles[i-1]
+		if info.BlockfileNo <= prev.BlockfileNo || info.FirstBlockNum <= prev.LastBlockNum {
+			return errors.Errorf("blockfile [%d] with blocks [%d-%d] is out of order after blockfile [%d] with blocks [%d-%d]",
+				info.BlockfileNo, info.FirstBlockNum, info.LastBlockNum, prev.BlockfileNo, prev.FirstBlockNum, prev.LastBlockNum)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveCatalogTransfer(t *testing.T) {
+	ledgerid := "testLedger"
+	blocks := testutil.ConstructTestBlocks(t, 10)
+
+	// The old peer has archived blockfile 0 and discarded blockfile 1
+	srcConf := NewConf(testPath(), 0, "", "")
+	srcEnv := newTestEnv(t, srcConf)
+	defer srcEnv.Cleanup()
+	w := newTestBlockfileWrapper(srcEnv, ledgerid)
+	w.addBlocks(blocks)
+	catalog := w.blockfileMgr.archiveConf.catalog
+	require.NoError(t, catalog.recordArchivedBlockfile(&archive.ArchivedBlockfileInfo{
+		ChannelID: ledgerid, BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9,
+		Location: "/blkstore/testLedger/blockfile_000000", Checksum: "sha256:00",
+	}))
+	require.NoError(t, catalog.recordArchivedBlockfile(&archive.ArchivedBlockfileInfo{
+		ChannelID: ledgerid, BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19,
+		Location: "/blkstore/testLedger/blockfile_000001", Discarded: true,
+	}))
+	w.close()
+	srcEnv.provider.Close()
+
+	state, err := ExportArchiveCatalog(srcConf.blockStorageDir, ledgerid)
+	require.NoError(t, err)
+	assert.Equal(t, ledgerid, state.ChannelId)
+	assert.Equal(t, uint64(2), state.NextBlockfileNo)
+	require.Len(t, state.Blockfiles, 2)
+	_, err = ExportArchiveCatalog(srcConf.blockStorageDir, "unknown")
+	assert.EqualError(t, err, "ledger [unknown] not found in "+srcConf.blockStorageDir)
+
+	// The new peer has received the blocks of blockfile 0 only
+	dstConf := NewConf(testPath(), 0, "", "")
+	dstEnv := newTestEnv(t, dstConf)
+	defer dstEnv.Cleanup()
+	w = newTestBlockfileWrapper(dstEnv, ledgerid)
+	w.addBlocks(blocks)
+	w.close()
+	dstEnv.provider.Close()
+
+	// The records must match the local blockfiles
+	invalid := proto.Clone(state).(*archive.ChannelArchiverState)
+	invalid.Blockfiles[0].LastBlockNum = 8
+	invalid.Blockfiles[1].FirstBlockNum = 9
+	_, err = ImportArchiveCatalog(dstConf.blockStorageDir, invalid)
+	assert.EqualError(t, err, "blockfile [0] of ledger [testLedger] holds blocks [0-9], but blocks [0-8] have been archived")
+	invalid.Blockfiles[0], invalid.Blockfiles[1] = invalid.Blockfiles[1], invalid.Blockfiles[0]
+	_, err = ImportArchiveCatalog(dstConf.blockStorageDir, invalid)
+	assert.EqualError(t, err, "blockfile [0] with blocks [0-8] is out of order after blockfile [1] with blocks [9-19]")
+
+	// The records of the peers of another network are rejected
+	invalid = proto.Clone(state).(*archive.ChannelArchiverState)
+	invalid.Blockfiles[0].NetworkID = "test"
+	_, err = ImportArchiveCatalog(dstConf.blockStorageDir, invalid)
+	assert.EqualError(t, err, "blockfile [0] of channel [testLedger] has been archived by network [test] environment [], this peer belongs to network [] environment []")
+
+	n, err := ImportArchiveCatalog(dstConf.blockStorageDir, state)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	dbProvider := leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: dstConf.getIndexDir()})
+	db := dbProvider.GetDBHandle(ledgerid)
+	infos, err := newArchiveCatalog(ledgerid, db).ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "/blkstore/testLedger/blockfile_000000", infos[0].Location)
+	assert.Equal(t, "sha256:00", infos[0].Checksum)
+	assert.False(t, infos[0].Discarded)
+	assert.True(t, infos[1].Discarded)
+	cp, err := readArchiverCheckpoint(db)
+	require.NoError(t, err)
+	assert.Equal(t, &archiverCheckpoint{nextBlockfileNum: 2, inFlightBlockfileNum: noInFlightBlockfile}, cp)
+	dbProvider.Close()
+}
@@ -0,0 +1,243 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+)
+
+// catchUpBacklog returns the blockfiles the archiver lags behind with, oldest first, when they are at least
+// threshold beyond the kept ones, and nil otherwise. The blockfile being written is never part of it.
+func (arch *blockfileArchiver) catchUpBacklog(threshold, keep int) []int {
+	current := arch.mgr.currentFileNum()
+	fileNums, _, err := listLocalBlockfiles(arch.blockfileDir)
+	if err != nil {
+		loggerArchive.Error(err)
+		return nil
+	}
+	var candidates []int
+	for _, fileNum := range fileNums {
+		if fileNum >= arch.checkpoint.nextBlockfileNum && fileNum < current {
+			candidates = append(candidates, fileNum)
+		}
+	}
+	if len(candidates)-keep < threshold {
+		return nil
+	}
+	return candidates[:len(candidates)-keep]
+}
+
+// catchUp archives the backlog of blockfiles accumulated while the peer was not archiving the channel, e.g.
+// after a prolonged downtime, once it reaches blockarchive.CatchUpThreshold. The blockfiles are uploaded oldest
+// first, blockarchive.CatchUpParallelism at a time sharing blockarchive.CatchUpBandwidth, and are then recorded
+// and discarded in order like the other blockfiles, so that the checkpoint of the archiver advances with each of
+// them and a catch-up interrupted by a restart resumes where it stopped. The progress is logged after each round
+// of uploads. The catch-up stops at the first failure or at a blockfile too recent to be discarded, and leaves
+// the remaining blockfiles to the archiving batches. It returns the number of blockfiles archived.
+func (arch *blockfileArchiver) catchUp(stop chan struct{}) int {
+	if blockarchive.CatchUpThreshold <= 0 {
+		return 0
+	}
+	// The blockfile interrupted by the last shutdown resumes its upload
+	if arch.hasInFlightBlockfile() && !arch.archiveInFlightBlockfile() {
+		return 0
+	}
+	backlog := arch.catchUpBacklog(blockarchive.CatchUpThreshold, blockarchive.KeepLatestBlockfilesOf(arch.chainID))
+	if len(backlog) == 0 {
+		return 0
+	}
+	parallelism := blockarchive.CatchUpParallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	limiter := newBandwidthLimiter(blockarchive.CatchUpBandwidth)
+	log := loggerUpload.With(blockarchive.LogKeyChannel, arch.chainID)
+	log.Infow("Catching up with the archiving backlog", "blockfiles", len(backlog),
+		"first", backlog[0], "last", backlog[len(backlog)-1], "parallelism", parallelism, "bandwidth", blockarchive.CatchUpBandwidth)
+
+	start := time.Now()
+	archived := 0
+	var bytes int64
+	for len(backlog) > 0 {
+		select {
+		case <-stop:
+			log.Infow("Stopped catching up with the archiving backlog", "archived", archived, "remaining", len(backlog))
+			return archived
+		default:
+		}
+		round := backlog
+		if len(round) > parallelism {
+			round = round[:parallelism]
+		}
+		eligible := arch.discardableBlockfiles(round)
+		uploaded, size := arch.uploadConcurrently(eligible, limiter)
+		bytes += size
+		recorded := 0
+		for _, fileNum := range eligible[:uploaded] {
+			if !arch.archiveUploadedBlockfile(fileNum) {
+				break
+			}
+			recorded++
+		}
+		archived += recorded
+		if recorded < len(round) {
+			log.Infow("Left the rest of the archiving backlog to the archiving batches", "archived", archived,
+				"remaining", len(backlog)-recorded, blockarchive.LogKeyBytes, bytes)
+			return archived
+		}
+		backlog = backlog[len(round):]
+		elapsed := time.Since(start)
+		log.Infow("Archiving backlog progress", "archived", archived, "remaining", len(backlog),
+			blockarchive.LogKeyBytes, bytes, "bytesPerSecond", int64(float64(bytes)/elapsed.Seconds()),
+			blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+	}
+	log.Infow("Caught up with the archiving backlog", "archived", archived, blockarchive.LogKeyBytes, bytes,
+		blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+	return archived
+}
+
+// archiveInFlightBlockfile archives the blockfile interrupted by the last shutdown, and returns whether it succeeded
+func (arch *blockfileArchiver) archiveInFlightBlockfile() bool {
+	if !transfers.begin() {
+		return false
+	}
+	fileNum := arch.checkpoint.inFlightBlockfileNum
+	_, err := arch.archiveNextBlockfile(fileNum)
+	transfers.end()
+	arch.recordArchiveOutcome(fileNum, err)
+	return err == nil
+}
+
+// discardableBlockfiles returns the leading blockfiles which are old enough to be discarded. The age
+// is checked by the discard instead when it is deferred.
+func (arch *blockfileArchiver) discardableBlockfiles(fileNums []int) []int {
+	if blockarchive.IsDiscardDeferred() {
+		return fileNums
+	}
+	for i, fileNum := range fileNums {
+		if recent, err := arch.isTooRecentToDiscard(fileNum); err != nil || recent {
+			return fileNums[:i]
+		}
+	}
+	return fileNums
+}
+
+// uploadConcurrently uploads the blockfiles to the repository at the same time. It returns the number of
+// leading blockfiles uploaded, which are archived in order, and the number of bytes of all the uploaded ones.
+func (arch *blockfileArchiver) uploadConcurrently(fileNums []int, limiter *bandwidthLimiter) (int, int64) {
+	errs := make([]error, len(fileNums))
+	sizes := make([]int64, len(fileNums))
+	var wg sync.WaitGroup
+	for i, fileNum := range fileNums {
+		// The shutdown of the peer waits for the blockfiles being uploaded
+		if !transfers.begin() {
+			errs[i] = errTransferAborted
+			continue
+		}
+		wg.Add(1)
+		go func(i, fileNum int) {
+			defer wg.Done()
+			defer transfers.end()
+			if info, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum)); err == nil {
+				sizes[i] = info.Size()
+			}
+			location, err := arch.archiveLocation(fileNum)
+			if err == nil {
+				_, err = arch.uploadBlockfile(fileNum, location, nil, limiter)
+			}
+			errs[i] = err
+		}(i, fileNum)
+	}
+	wg.Wait()
+
+	uploaded := len(fileNums)
+	var bytes int64
+	for i, err := range errs {
+		if err != nil {
+			loggerUpload.Warnw("Failed uploading blockfile of the archiving backlog", append(blockfileLogFields(arch.chainID, fileNums[i]), "error", err)...)
+			arch.recordArchiveOutcome(fileNums[i], err)
+			if i < uploaded {
+				uploaded = i
+			}
+			continue
+		}
+		bytes += sizes[i]
+	}
+	return uploaded, bytes
+}
+
+// archiveUploadedBlockfile records and discards a blockfile uploaded by the catch-up, and advances the
+// checkpoint of the archiver past it, like the archiving of a blockfile resumed after its upload
+func (arch *blockfileArchiver) archiveUploadedBlockfile(fileNum int) bool {
+	if !transfers.begin() {
+		return false
+	}
+	defer transfers.end()
+	err := arch.saveCheckpoint(fileNum, fileNum, true)
+	if err == nil {
+		_, err = arch.archiveNextBlockfile(fileNum)
+	}
+	arch.recordArchiveOutcome(fileNum, err)
+	if err != nil {
+		loggerArchive.Error(err)
+		return false
+	}
+	return true
+}
+
+// bandwidthLimiter limits the bandwidth shared by several transfers. A nil limiter is unlimited.
+type bandwidthLimiter struct {
+	bytesPerSecond int64
+	lock           sync.Mutex
+	// The time at which the bytes read so far have been transferred at the limit
+	next time.Time
+}
+
+// newBandwidthLimiter returns a limiter of bytesPerSecond, nil if it is not positive
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// wait delays the caller until n more bytes can be transferred within the limit
+func (l *bandwidthLimiter) wait(n int) {
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+	delay := l.next.Sub(now)
+	l.lock.Unlock()
+	time.Sleep(delay)
+}
+
+// reader wraps the source of a transfer so that it is read within the limit
+func (l *bandwidthLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{r: r, limiter: l}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
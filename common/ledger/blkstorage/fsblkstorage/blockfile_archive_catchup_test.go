@@ -0,0 +1,117 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatchUp(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevIsArchiver, prevEach, prevKeep := blockarchive.IsArchiver, blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks
+	prevThreshold, prevParallelism, prevBandwidth := blockarchive.CatchUpThreshold, blockarchive.CatchUpParallelism, blockarchive.CatchUpBandwidth
+	defer func() {
+		blockarchive.IsArchiver, blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = prevIsArchiver, prevEach, prevKeep
+		blockarchive.CatchUpThreshold, blockarchive.CatchUpParallelism, blockarchive.CatchUpBandwidth = prevThreshold, prevParallelism, prevBandwidth
+	}()
+	blockarchive.IsArchiver = true
+	// The archiving is triggered by the test only
+	blockarchive.NumBlockfileEachArchiving = 1000
+	blockarchive.CatchUpThreshold = 0
+	blockarchive.NumKeepLatestBlocks = 1
+	blockarchive.CatchUpParallelism = 3
+	blockarchive.CatchUpBandwidth = 10 * 1024 * 1024
+
+	blocks := testutil.ConstructTestBlocks(t, 80)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	require.Equal(t, 6, arch.mgr.currentFileNum())
+	discarded := func(fileNum int) bool {
+		_, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+		return os.IsNotExist(err)
+	}
+
+	// Blockfiles [1-5] are eligible, one of them is kept
+	blockarchive.CatchUpThreshold = 5
+	assert.Equal(t, 0, arch.catchUp(make(chan struct{})))
+	blockarchive.CatchUpThreshold = 0
+	assert.Equal(t, 0, arch.catchUp(make(chan struct{})))
+
+	// The backlog is archived oldest first, in two rounds
+	blockarchive.CatchUpThreshold = 3
+	assert.Equal(t, []int{1, 2, 3, 4}, arch.catchUpBacklog(3, 1))
+	assert.Equal(t, 4, arch.catchUp(make(chan struct{})))
+	for fileNum := 1; fileNum <= 4; fileNum++ {
+		assert.True(t, discarded(fileNum), "blockfile [%d] is not discarded", fileNum)
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		require.NoError(t, err)
+		require.NotNil(t, info)
+		assert.True(t, info.Discarded)
+	}
+	assert.False(t, discarded(5))
+	assert.Equal(t, 5, arch.checkpoint.nextBlockfileNum)
+	assert.False(t, arch.hasInFlightBlockfile())
+	block, err := store.RetrieveBlockByNumber(25)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[25], block)
+
+	// The backlog is now below the threshold
+	assert.Nil(t, arch.catchUpBacklog(3, 1))
+}
+
+func TestBandwidthLimiter(t *testing.T) {
+	assert.Nil(t, newBandwidthLimiter(0))
+	r := bytes.NewReader(make([]byte, 100))
+	var limiter *bandwidthLimiter
+	assert.Equal(t, r, limiter.reader(r))
+
+	// 200 KB are read in about 200ms at 1 MB/s, shared by two readers
+	limiter = newBandwidthLimiter(1000 * 1000)
+	start := time.Now()
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			content, err := ioutil.ReadAll(limiter.reader(bytes.NewReader(make([]byte, 100*1000))))
+			if err == nil && len(content) != 100*1000 {
+				err = io.ErrShortWrite
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-done)
+	}
+	assert.True(t, time.Since(start) >= 190*time.Millisecond, "read in %s", time.Since(start))
+}
@@ -0,0 +1,77 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveToChannelRepository(t *testing.T) {
+	var defaultRootDir, channelRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { defaultRootDir = config.RootDir })
+	defer cleanup()
+	channelServer, channelCleanup := startConfiguredTestRepository(t, func(config *repository.Config) { channelRootDir = config.RootDir })
+	defer channelCleanup()
+	// The second repository became the default one when started
+	blockarchive.BlockArchiverURL = server.Addr().String()
+	blockarchive.SetChannelRepository("euLedger", &blockarchive.ChannelRepository{URL: channelServer.Addr().String()})
+	defer blockarchive.SetChannelRepository("euLedger", nil)
+	blockStorePath := testPath()
+	prevSigner, prevBlockStorePath := blockarchive.ManifestSigner, blockarchive.BlockStorePath
+	defer func() { blockarchive.ManifestSigner, blockarchive.BlockStorePath = prevSigner, prevBlockStorePath }()
+	blockarchive.ManifestSigner, blockarchive.BlockStorePath = nil, blockStorePath
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+
+	for _, ledgerID := range []string{"euLedger", "testLedger"} {
+		store, err := env.provider.OpenBlockStore(ledgerID)
+		require.NoError(t, err)
+		defer store.Shutdown()
+		for _, block := range blocks {
+			require.NoError(t, store.AddBlock(block))
+		}
+		arch := store.(*fsBlockStore).archiver
+		location, err := arch.archiveLocation(0)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.handleArchivedBlockfile(0, true))
+
+		// The blockfile is archived to the repository of the channel if any, and read back from it
+		rootDir, url := defaultRootDir, server.Addr().String()
+		otherRootDir := channelRootDir
+		if ledgerID == "euLedger" {
+			rootDir, url = channelRootDir, channelServer.Addr().String()
+			otherRootDir = defaultRootDir
+		}
+		_, err = os.Stat(filepath.Join(rootDir, location))
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(otherRootDir, location))
+		assert.True(t, os.IsNotExist(err))
+		info, err := store.GetArchiveCatalog().GetArchiveLocation(5)
+		require.NoError(t, err)
+		assert.Equal(t, url, info.Repository)
+		block, err := store.RetrieveBlockByNumber(5)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(blocks[5], block))
+	}
+}
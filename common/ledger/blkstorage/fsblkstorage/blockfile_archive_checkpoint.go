@@ -0,0 +1,137 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/pkg/errors"
+)
+
+var archiverCheckpointKey = []byte("archiverCheckpoint")
+
+// noInFlightBlockfile indicates that no blockfile is being archived
+const noInFlightBlockfile = -1
+
+// archiverCheckpoint records the progress of the archiver so that it resumes where it stopped after a restart.
+// It is persisted in the same db as the block index and the archive catalog.
+type archiverCheckpoint struct {
+	// Postfix number of the blockfile which should be archived next
+	nextBlockfileNum int
+	// Postfix number of the blockfile being archived, noInFlightBlockfile if none
+	inFlightBlockfileNum int
+	// Whether the in-flight blockfile has been completely uploaded along with its manifest
+	uploaded bool
+}
+
+func (cp *archiverCheckpoint) marshal() ([]byte, error) {
+	buffer := proto.NewBuffer([]byte{})
+	if err := buffer.EncodeVarint(uint64(cp.nextBlockfileNum)); err != nil {
+		return nil, err
+	}
+	// The in-flight blockfile number is shifted by one so that noInFlightBlockfile is encoded as 0
+	if err := buffer.EncodeVarint(uint64(cp.inFlightBlockfileNum + 1)); err != nil {
+		return nil, err
+	}
+	var uploadedMarker uint64
+	if cp.uploaded {
+		uploadedMarker = 1
+	}
+	if err := buffer.EncodeVarint(uploadedMarker); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (cp *archiverCheckpoint) unmarshal(b []byte) error {
+	buffer := proto.NewBuffer(b)
+	var val uint64
+	var err error
+
+	if val, err = buffer.DecodeVarint(); err != nil {
+		return err
+	}
+	cp.nextBlockfileNum = int(val)
+
+	if val, err = buffer.DecodeVarint(); err != nil {
+		return err
+	}
+	cp.inFlightBlockfileNum = int(val) - 1
+
+	if val, err = buffer.DecodeVarint(); err != nil {
+		return err
+	}
+	cp.uploaded = val == 1
+	return nil
+}
+
+// loadCheckpoint restores the progress of the archiver. When no checkpoint has been saved yet,
+// the archiver starts after the last blockfile recorded in the archive catalog.
+func (arch *blockfileArchiver) loadCheckpoint() error {
+	cp, err := readArchiverCheckpoint(arch.mgr.db)
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		arch.checkpoint = cp
+		return nil
+	}
+
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return err
+	}
+	if len(infos) > 0 {
+		if next := int(infos[len(infos)-1].BlockfileNo) + 1; next > arch.checkpoint.nextBlockfileNum {
+			arch.checkpoint.nextBlockfileNum = next
+		}
+	}
+	return nil
+}
+
+// readArchiverCheckpoint returns the checkpoint of the archiver of a ledger from its index db, nil if none
+func readArchiverCheckpoint(db *leveldbhelper.DBHandle) (*archiverCheckpoint, error) {
+	b, err := db.Get(archiverCheckpointKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading archiver checkpoint")
+	}
+	if b == nil {
+		return nil, nil
+	}
+	cp := &archiverCheckpoint{}
+	if err := cp.unmarshal(b); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling archiver checkpoint")
+	}
+	return cp, nil
+}
+
+// saveCheckpoint persists the progress of the archiver
+func (arch *blockfileArchiver) saveCheckpoint(nextBlockfileNum, inFlightBlockfileNum int, uploaded bool) error {
+	cp := &archiverCheckpoint{
+		nextBlockfileNum:     nextBlockfileNum,
+		inFlightBlockfileNum: inFlightBlockfileNum,
+		uploaded:             uploaded,
+	}
+	b, err := cp.marshal()
+	if err != nil {
+		return errors.Wrap(err, "error marshaling archiver checkpoint")
+	}
+	if err := arch.mgr.db.Put(archiverCheckpointKey, b, true); err != nil {
+		return errors.Wrap(err, "error writing archiver checkpoint")
+	}
+	arch.checkpoint = cp
+	return nil
+}
+
+// isUploaded returns whether the blockfile has been completely uploaded before
+// the archiver was interrupted, in which case the upload doesn't need to be done again
+func (arch *blockfileArchiver) isUploaded(fileNum int) bool {
+	return arch.checkpoint.inFlightBlockfileNum == fileNum && arch.checkpoint.uploaded
+}
+
+// hasInFlightBlockfile returns whether the archiver was interrupted while archiving a blockfile
+func (arch *blockfileArchiver) hasInFlightBlockfile() bool {
+	return arch.checkpoint.inFlightBlockfileNum != noInFlightBlockfile
+}
@@ -0,0 +1,91 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiverCheckpointMarshal(t *testing.T) {
+	for _, cp := range []*archiverCheckpoint{
+		{nextBlockfileNum: 1, inFlightBlockfileNum: noInFlightBlockfile},
+		{nextBlockfileNum: 5, inFlightBlockfileNum: 5, uploaded: false},
+		{nextBlockfileNum: 5, inFlightBlockfileNum: 5, uploaded: true},
+	} {
+		b, err := cp.marshal()
+		require.NoError(t, err)
+		unmarshaled := &archiverCheckpoint{}
+		require.NoError(t, unmarshaled.unmarshal(b))
+		assert.Equal(t, cp, unmarshaled)
+	}
+}
+
+func TestArchiverCheckpointAcrossRestarts(t *testing.T) {
+	prevIsArchiver, prevNumBlockfileEachArchiving := blockarchive.IsArchiver, blockarchive.NumBlockfileEachArchiving
+	blockarchive.IsArchiver = true
+	// Make sure that the archiving is never triggered
+	blockarchive.NumBlockfileEachArchiving = 1000
+	defer func() {
+		blockarchive.IsArchiver, blockarchive.NumBlockfileEachArchiving = prevIsArchiver, prevNumBlockfileEachArchiving
+	}()
+
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	w := newTestBlockfileWrapper(env, "testLedger")
+	defer w.close()
+
+	arch := newBlockfileArchiver("testLedger", w.blockfileMgr)
+	assert.Equal(t, 1, arch.checkpoint.nextBlockfileNum)
+	assert.False(t, arch.hasInFlightBlockfile())
+
+	// The archiver is interrupted after uploading blockfile 2
+	require.NoError(t, arch.saveCheckpoint(2, 2, true))
+
+	arch = newBlockfileArchiver("testLedger", w.blockfileMgr)
+	assert.Equal(t, 2, arch.checkpoint.nextBlockfileNum)
+	assert.True(t, arch.hasInFlightBlockfile())
+	assert.True(t, arch.isUploaded(2))
+	assert.False(t, arch.isUploaded(3))
+
+	require.NoError(t, arch.saveCheckpoint(3, noInFlightBlockfile, false))
+	arch = newBlockfileArchiver("testLedger", w.blockfileMgr)
+	assert.Equal(t, 3, arch.checkpoint.nextBlockfileNum)
+	assert.False(t, arch.hasInFlightBlockfile())
+}
+
+func TestArchiverCheckpointFromCatalog(t *testing.T) {
+	prevIsArchiver, prevNumBlockfileEachArchiving := blockarchive.IsArchiver, blockarchive.NumBlockfileEachArchiving
+	blockarchive.IsArchiver = true
+	blockarchive.NumBlockfileEachArchiving = 1000
+	defer func() {
+		blockarchive.IsArchiver, blockarchive.NumBlockfileEachArchiving = prevIsArchiver, prevNumBlockfileEachArchiving
+	}()
+
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	w := newTestBlockfileWrapper(env, "testLedger")
+	defer w.close()
+
+	// Blockfiles archived by a version of the archiver which didn't save any checkpoint
+	catalog := w.blockfileMgr.archiveConf.catalog
+	for fileNum := uint64(1); fileNum <= 3; fileNum++ {
+		require.NoError(t, catalog.recordArchivedBlockfile(&archive.ArchivedBlockfileInfo{
+			ChannelID:     "testLedger",
+			BlockfileNo:   fileNum,
+			FirstBlockNum: fileNum * 10,
+			LastBlockNum:  fileNum*10 + 9,
+			Discarded:     true,
+		}))
+	}
+
+	arch := newBlockfileArchiver("testLedger", w.blockfileMgr)
+	assert.Equal(t, 4, arch.checkpoint.nextBlockfileNum)
+	assert.False(t, arch.hasInFlightBlockfile())
+}
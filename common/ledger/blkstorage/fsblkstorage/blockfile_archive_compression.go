@@ -0,0 +1,223 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+var compressionRatioOpts = metrics.HistogramOpts{
+	Namespace:    "archiver",
+	Subsystem:    "compression",
+	Name:         "ratio",
+	Help:         "The size of the compressed blockfiles uploaded to the repository relative to the size of the blockfiles.",
+	Buckets:      []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	LabelNames:   []string{"channel", "algorithm"},
+	StatsdFormat: "%{#fqname}.%{channel}.%{algorithm}",
+}
+
+var compressionLevelOpts = metrics.GaugeOpts{
+	Namespace:    "archiver",
+	Subsystem:    "compression",
+	Name:         "level",
+	Help:         "The compression level picked for the last blockfile of the channels compressed with the auto level.",
+	LabelNames:   []string{"channel"},
+	StatsdFormat: "%{#fqname}.%{channel}",
+}
+
+var (
+	compressionRatio       metrics.Histogram
+	compressionLevel       metrics.Gauge
+	compressionMetricsOnce sync.Once
+)
+
+// getCompressionMetrics returns the compression metrics shared by the channels, which are created on first use
+func getCompressionMetrics() (metrics.Histogram, metrics.Gauge) {
+	compressionMetricsOnce.Do(func() {
+		var p metrics.Provider = &disabled.Provider{}
+		if blockarchive.MetricsProvider != nil {
+			p = blockarchive.MetricsProvider
+		}
+		compressionRatio = p.NewHistogram(compressionRatioOpts)
+		compressionLevel = p.NewGauge(compressionLevelOpts)
+	})
+	return compressionRatio, compressionLevel
+}
+
+// compressionSampleSize is the number of bytes of a blockfile compressed at each candidate level
+// to measure the speed and the ratio of the levels before the auto level is picked
+var compressionSampleSize int64 = 1024 * 1024
+
+// defaultCompressionLevel is the gzip level of the blockfiles until the auto level can be picked,
+// which is the level gzip compresses with by default
+const defaultCompressionLevel = 6
+
+// compressionCandidateLevels are the gzip levels among which the auto level is picked
+var compressionCandidateLevels = []int{gzip.BestSpeed, 3, defaultCompressionLevel, gzip.BestCompression}
+
+// compressionTuner picks the level of the blockfiles of a ledger compressed with the auto level. The upload and the
+// compression of a blockfile run together, so the upload of a blockfile takes as long as the slower of the two: the
+// level picked is the one which minimizes it, given the bandwidth of the previous uploads of the ledger.
+type compressionTuner struct {
+	lock sync.Mutex
+	// bandwidth is the moving average of the bandwidth of the uploads in bytes per second, 0 until measured
+	bandwidth float64
+}
+
+var (
+	compressionTuners     = map[string]*compressionTuner{}
+	compressionTunersLock sync.Mutex
+)
+
+// compressionTunerOf returns the tuner of the compression level of a ledger
+func compressionTunerOf(ledgerID string) *compressionTuner {
+	compressionTunersLock.Lock()
+	defer compressionTunersLock.Unlock()
+	tuner, ok := compressionTuners[ledgerID]
+	if !ok {
+		tuner = &compressionTuner{}
+		compressionTuners[ledgerID] = tuner
+	}
+	return tuner
+}
+
+// recordUpload updates the bandwidth with an upload of n bytes which took elapsed
+func (t *compressionTuner) recordUpload(n int64, elapsed time.Duration) {
+	if n <= 0 || elapsed <= 0 {
+		return
+	}
+	bandwidth := float64(n) / elapsed.Seconds()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.bandwidth == 0 {
+		t.bandwidth = bandwidth
+	} else {
+		t.bandwidth = 0.7*t.bandwidth + 0.3*bandwidth
+	}
+}
+
+// pickLevel returns the gzip level of a blockfile of size bytes. The candidate levels compress a sample of the
+// blockfile to measure their speed and ratio on this peer. The default level is picked until the bandwidth of the
+// uploads is known.
+func (t *compressionTuner) pickLevel(srcFile *os.File, size int64) (int, error) {
+	t.lock.Lock()
+	bandwidth := t.bandwidth
+	t.lock.Unlock()
+	if bandwidth == 0 || size == 0 {
+		return defaultCompressionLevel, nil
+	}
+	sampleSize := compressionSampleSize
+	if sampleSize > size {
+		sampleSize = size
+	}
+	sample := make([]byte, sampleSize)
+	if _, err := srcFile.ReadAt(sample, (size-sampleSize)/2); err != nil && err != io.EOF {
+		return 0, errors.Wrap(err, "error reading the sample of the blockfile")
+	}
+
+	picked, fastest := 0, time.Duration(0)
+	for _, level := range compressionCandidateLevels {
+		counter := &countingWriter{w: ioutil.Discard}
+		start := time.Now()
+		compressor, err := gzip.NewWriterLevel(counter, level)
+		if err != nil {
+			return 0, err
+		}
+		compressor.Write(sample)
+		compressor.Close()
+		elapsed := time.Since(start)
+		if elapsed <= 0 {
+			elapsed = time.Nanosecond
+		}
+		// The time to compress and to upload the blockfile at the speed and the ratio of the sample
+		compressTime := time.Duration(float64(elapsed) * float64(size) / float64(sampleSize))
+		uploadTime := time.Duration(float64(time.Second) * float64(size) * float64(counter.n) / float64(sampleSize) / bandwidth)
+		estimated := compressTime
+		if uploadTime > estimated {
+			estimated = uploadTime
+		}
+		if picked == 0 || estimated < fastest {
+			picked, fastest = level, estimated
+		}
+	}
+	return picked, nil
+}
+
+// countingWriter counts the bytes written through it and the time spent writing them
+type countingWriter struct {
+	w       io.Writer
+	n       int64
+	elapsed time.Duration
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := c.w.Write(p)
+	c.elapsed += time.Since(start)
+	c.n += int64(n)
+	return n, err
+}
+
+// encodeBlockfile uploads to dst the local blockfile encoded as an object: the header, holding the checksum of the
+// blockfile computed with checksumWriter, followed by the blockfile compressed with the compression settings of its
+// ledger, if not nil, then encrypted with the key keyID, if not empty. It returns the number of bytes uploaded and
+// the level of the compression.
+func encodeBlockfile(dst io.Writer, srcFile *os.File, ledgerID string, settings *blockarchive.CompressionSettings, keyID string,
+	checksumWriter *blockarchive.ChecksumWriter, limiter *bandwidthLimiter) (int64, int, error) {
+	size, err := io.Copy(checksumWriter, srcFile)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "error reading the blockfile")
+	}
+	if _, err := srcFile.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	tuner := compressionTunerOf(ledgerID)
+	header := &blockarchive.ObjectHeader{Size: size, Checksum: checksumWriter.Checksum().String()}
+	level := 0
+	if settings != nil {
+		header.Compression, level = settings.Algorithm, settings.Level
+		if settings.Auto {
+			if level, err = tuner.pickLevel(srcFile, size); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	upload := &countingWriter{w: dst}
+	encoder, err := blockarchive.NewBlockfileWriter(upload, header, level, keyID)
+	if err != nil {
+		return upload.n, level, err
+	}
+	if _, err := io.Copy(encoder, limiter.reader(transfers.reader(srcFile))); err != nil {
+		encoder.Close()
+		return upload.n, level, err
+	}
+	if err := encoder.Close(); err != nil {
+		return upload.n, level, err
+	}
+	if settings == nil {
+		return upload.n, level, nil
+	}
+
+	tuner.recordUpload(upload.n, upload.elapsed)
+	ratio, levelGauge := getCompressionMetrics()
+	if size > 0 {
+		ratio.With("channel", ledgerID, "algorithm", settings.Algorithm).Observe(float64(upload.n) / float64(size))
+	}
+	if settings.Auto {
+		levelGauge.With("channel", ledgerID).Set(float64(level))
+	}
+	return upload.n, level, nil
+}
@@ -0,0 +1,222 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedBlockfileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *blockarchive.CompressionSettings
+		keyID    string
+	}{
+		{name: "gzip level 9", settings: &blockarchive.CompressionSettings{Algorithm: blockarchive.CompressionGzip, Level: 9}},
+		{name: "gzip auto level", settings: &blockarchive.CompressionSettings{Algorithm: blockarchive.CompressionGzip, Auto: true}},
+		{name: "snappy", settings: &blockarchive.CompressionSettings{Algorithm: blockarchive.CompressionSnappy}},
+		{name: "encrypted", keyID: "key1"},
+		{name: "gzip encrypted", settings: &blockarchive.CompressionSettings{Algorithm: blockarchive.CompressionGzip}, keyID: "key2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testCompressedBlockfileRoundTrip(t, test.settings, test.keyID)
+		})
+	}
+}
+
+func testCompressedBlockfileRoundTrip(t *testing.T, settings *blockarchive.CompressionSettings, keyID string) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevCompression := blockarchive.Compression
+	blockarchive.Compression = func(string) *blockarchive.CompressionSettings { return settings }
+	defer func() { blockarchive.Compression = prevCompression }()
+	defer setTestEncryptionKeys(t, keyID, "key1", "key2")()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	localPath := deriveBlockfilePath(arch.mgr.rootDir, 0)
+	content, err := ioutil.ReadFile(localPath)
+	require.NoError(t, err)
+	checksum, err := blockarchive.ComputeChecksum(bytes.NewReader(content), blockarchive.ChecksumAlgorithm)
+	require.NoError(t, err)
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+
+	// The blockfile is stored compressed after its header, and its checksum is the one of the blockfile
+	sshConn, client, err := connectToRepo(arch.chainID)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	file, err := client.Open(location)
+	require.NoError(t, err)
+	header, err := blockarchive.ReadObjectHeader(bufio.NewReader(file))
+	file.Close()
+	require.NoError(t, err)
+	require.NotNil(t, header)
+	if settings != nil {
+		assert.Equal(t, settings.Algorithm, header.Compression)
+	} else {
+		assert.Empty(t, header.Compression)
+	}
+	assert.Equal(t, int64(len(content)), header.Size)
+	assert.Equal(t, checksum.String(), header.Checksum)
+	assert.Equal(t, keyID, header.KeyID)
+	stored, err := remoteChecksum(client, location, "")
+	require.NoError(t, err)
+	assert.Equal(t, checksum, stored)
+	verification, err := server.VerifyBlockfile(location, blockarchive.ChecksumAlgorithm, false)
+	require.NoError(t, err)
+	assert.Equal(t, checksum.String(), verification.Checksum)
+	assert.True(t, isBlockfileStored(client, location, int64(len(content))))
+
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+	_, err = os.Stat(localPath)
+	require.True(t, os.IsNotExist(err))
+	info, err := store.GetArchiveCatalog().GetArchiveLocation(0)
+	require.NoError(t, err)
+	assert.Equal(t, keyID, info.EncryptionKeyId)
+	matched, err := matchesChecksumBy(client, info, false)
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	// The encoded blockfile cannot be read from an offset, so no byte range of it is served
+	_, _, err = OpenBlockfileRangeForProxy("testLedger", 0, 10, store.GetArchiveCatalog())
+	assert.Equal(t, ErrEncodedBlockfileRange, err)
+
+	// The blocks of the discarded blockfile are read from the decoded blockfile
+	block, err := store.RetrieveBlockByNumber(1)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[1], block)
+
+	// The blockfile is restored decoded
+	require.NoError(t, store.RestoreRange(0, 5, 0, nil))
+	restored, err := ioutil.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+}
+
+// setTestEncryptionKeys sets a keyring holding keys of the IDs, the first of which the blockfiles are encrypted with
+// if not empty, and returns the function restoring the previous keyring
+func setTestEncryptionKeys(t *testing.T, activeKeyID string, keyIDs ...string) func() {
+	keys := make(map[string][]byte)
+	for i, keyID := range keyIDs {
+		keys[keyID] = bytes.Repeat([]byte{byte(i + 1)}, blockarchive.EncryptionKeySize)
+	}
+	keyring, err := blockarchive.NewEncryptionKeyring(keys)
+	require.NoError(t, err)
+	prevKeys, prevKeyID := blockarchive.EncryptionKeys, blockarchive.EncryptionKeyID
+	blockarchive.EncryptionKeys, blockarchive.EncryptionKeyID = keyring, activeKeyID
+	return func() { blockarchive.EncryptionKeys, blockarchive.EncryptionKeyID = prevKeys, prevKeyID }
+}
+
+func TestCompressedUploadIsNotResumed(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+	prevCompression := blockarchive.Compression
+	blockarchive.Compression = func(string) *blockarchive.CompressionSettings {
+		return &blockarchive.CompressionSettings{Algorithm: blockarchive.CompressionGzip}
+	}
+	defer func() { blockarchive.Compression = prevCompression }()
+
+	// The token of an upload of the blockfile stored as is is discarded
+	location := deriveArchivedBlockfilePath(arch.blockfileDir, 1)
+	resumer := &recordingResumer{blockfileUploadResumer: &blockfileUploadResumer{arch: arch, fileNum: 1}}
+	require.NoError(t, resumer.saveResumeOffset(location+uploadingSuffix, 10))
+	resumer.saved = nil
+	_, err := sendResumableBlockfileToRepo(arch.blockfileDir, 1, location, resumer, nil)
+	require.NoError(t, err)
+	assert.Empty(t, resumer.saved)
+	assert.Zero(t, resumer.resumeOffset(location+uploadingSuffix))
+}
+
+func TestCompressionTuner(t *testing.T) {
+	tuner := &compressionTuner{}
+	tuner.recordUpload(0, time.Second)
+	assert.Zero(t, tuner.bandwidth)
+	tuner.recordUpload(1000, time.Second)
+	assert.Equal(t, float64(1000), tuner.bandwidth)
+	tuner.recordUpload(2000, time.Second)
+	assert.InDelta(t, 1300, tuner.bandwidth, 0.001)
+
+	blockfile, err := ioutil.TempFile("", "blockfile")
+	require.NoError(t, err)
+	defer os.Remove(blockfile.Name())
+	defer blockfile.Close()
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(blockfile, "tx %d of block %d transfers %d from account %d\n", i, i/100, i*7919%1000, i*104729%5000)
+	}
+	info, err := blockfile.Stat()
+	require.NoError(t, err)
+	sizes := map[int]int{}
+	for _, level := range compressionCandidateLevels {
+		compressed := &bytes.Buffer{}
+		compressor, err := gzip.NewWriterLevel(compressed, level)
+		require.NoError(t, err)
+		sample := make([]byte, compressionSampleSize)
+		n, _ := blockfile.ReadAt(sample, (info.Size()-int64(len(sample)))/2)
+		compressor.Write(sample[:n])
+		compressor.Close()
+		sizes[level] = compressed.Len()
+	}
+
+	// The default level is picked until the bandwidth is known
+	level, err := (&compressionTuner{}).pickLevel(blockfile, info.Size())
+	require.NoError(t, err)
+	assert.Equal(t, defaultCompressionLevel, level)
+
+	// The level with the best ratio is picked when the upload is the bottleneck
+	level, err = (&compressionTuner{bandwidth: 1}).pickLevel(blockfile, info.Size())
+	require.NoError(t, err)
+	for _, candidate := range compressionCandidateLevels {
+		assert.True(t, sizes[level] <= sizes[candidate], "level %d compresses more than level %d", candidate, level)
+	}
+
+	// The fastest level is picked when the compression is the bottleneck, which is never the best ratio
+	level, err = (&compressionTuner{bandwidth: 1e15}).pickLevel(blockfile, info.Size())
+	require.NoError(t, err)
+	assert.NotEqual(t, gzip.BestCompression, level)
+}
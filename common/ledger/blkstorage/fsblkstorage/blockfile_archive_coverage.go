@@ -0,0 +1,172 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+var coverageMissingBlocks = metrics.GaugeOpts{
+	Namespace:    "archiver",
+	Subsystem:    "coverage",
+	Name:         "missing_blocks",
+	Help:         "The number of blocks of the channel found neither in the archive catalog nor in the local blockfiles when it was opened.",
+	LabelNames:   []string{"channel"},
+	StatsdFormat: "%{#fqname}.%{channel}",
+}
+
+var (
+	coverageGauge     metrics.Gauge
+	coverageGaugeOnce sync.Once
+)
+
+// getCoverageGauge returns the gauge of the missing blocks shared by the channels, which is created on first use
+func getCoverageGauge() metrics.Gauge {
+	coverageGaugeOnce.Do(func() {
+		var p metrics.Provider = &disabled.Provider{}
+		if blockarchive.MetricsProvider != nil {
+			p = blockarchive.MetricsProvider
+		}
+		coverageGauge = p.NewGauge(coverageMissingBlocks)
+	})
+	return coverageGauge
+}
+
+// coverageGap is a range of blocks of a ledger which are neither recorded in the archive catalog nor
+// stored in a local blockfile, e.g. because their blockfile was discarded but never archived
+type coverageGap struct {
+	// blockfiles are the numbers of the blockfiles missing from both
+	blockfiles    []int
+	firstBlockNum uint64
+	lastBlockNum  uint64
+}
+
+// verifyCoverage checks that the archive catalog and the local blockfiles cover all the blocks of the ledger.
+// The gaps are logged and exported as the number of missing blocks. An error is returned if there is a gap
+// and the coverage is strict, so that the ledger is not opened.
+func (arch *blockfileArchiver) verifyCoverage() error {
+	gaps, err := arch.checkCoverage()
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed checking the coverage of the blocks by the archive catalog and the local blockfiles: %s", arch.chainID, err)
+		if blockarchive.StrictCoverage {
+			return errors.WithMessagef(err, "could not check the coverage of the blocks of ledger [%s]", arch.chainID)
+		}
+		return nil
+	}
+
+	var missing uint64
+	for _, gap := range gaps {
+		missing += gap.lastBlockNum - gap.firstBlockNum + 1
+		loggerArchive.Errorf("[%s] Blocks [%d-%d] are neither in the archive catalog nor in the local blockfiles, blockfile(s) %v are missing",
+			arch.chainID, gap.firstBlockNum, gap.lastBlockNum, gap.blockfiles)
+	}
+	getCoverageGauge().With("channel", arch.chainID).Set(float64(missing))
+	if len(gaps) > 0 && blockarchive.StrictCoverage {
+		return errors.Errorf("%d block(s) of ledger [%s] are neither in the archive catalog nor in the local blockfiles", missing, arch.chainID)
+	}
+	return nil
+}
+
+// checkCoverage returns the ranges of blocks below the height of the ledger which are neither recorded in the
+// archive catalog nor stored in a local blockfile. The blocks of a blockfile follow the ones of the previous
+// blockfile, so a gap is where a blockfile is missing from both. Only the first block of the local blockfiles
+// is read, and the local blockfile before a gap is scanned entirely to find where the gap starts.
+func (arch *blockfileArchiver) checkCoverage() ([]*coverageGap, error) {
+	height := arch.mgr.getBlockchainInfo().Height
+	if height == 0 {
+		return nil, nil
+	}
+	currentFileNum := arch.mgr.currentFileNum()
+
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[int]*archive.ArchivedBlockfileInfo)
+	for _, info := range infos {
+		archived[int(info.BlockfileNo)] = info
+	}
+	fileNums, _, err := listLocalBlockfiles(arch.mgr.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	local := make(map[int]bool)
+	for _, fileNum := range fileNums {
+		local[fileNum] = true
+	}
+
+	var gaps []*coverageGap
+	var gap *coverageGap
+	// nextBlockNum is the block following the blockfiles before fileNum, unknown when the previous one is local
+	nextBlockNum, nextKnown := uint64(0), true
+	for fileNum := 0; fileNum <= currentFileNum; fileNum++ {
+		info := archived[fileNum]
+		if info == nil && !local[fileNum] {
+			if gap == nil {
+				if !nextKnown {
+					summary, err := scanBlockfile(arch.mgr.rootDir, fileNum-1)
+					if err != nil {
+						return nil, err
+					}
+					nextBlockNum = summary.lastBlockNum + 1
+				}
+				gap = &coverageGap{firstBlockNum: nextBlockNum}
+			}
+			gap.blockfiles = append(gap.blockfiles, fileNum)
+			continue
+		}
+
+		if gap != nil {
+			// The blockfile being written may not hold a block yet
+			endBlockNum := height
+			if info != nil {
+				endBlockNum = info.FirstBlockNum
+			} else if firstBlockNum, found, err := firstLocalBlockNum(arch.mgr.rootDir, fileNum); err != nil {
+				return nil, err
+			} else if found {
+				endBlockNum = firstBlockNum
+			}
+			if endBlockNum > gap.firstBlockNum {
+				gap.lastBlockNum = endBlockNum - 1
+				gaps = append(gaps, gap)
+			}
+			gap = nil
+		}
+		if info != nil {
+			nextBlockNum, nextKnown = info.LastBlockNum+1, true
+		} else {
+			nextKnown = false
+		}
+	}
+	if gap != nil && height > gap.firstBlockNum {
+		gap.lastBlockNum = height - 1
+		gaps = append(gaps, gap)
+	}
+	return gaps, nil
+}
+
+// firstLocalBlockNum returns the number of the first block of a local blockfile, and false if it holds no block
+func firstLocalBlockNum(rootDir string, fileNum int) (uint64, bool, error) {
+	stream, err := newBlockfileStream(rootDir, fileNum, 0, &ArchiveConf{})
+	if err != nil {
+		return 0, false, err
+	}
+	defer stream.close()
+	blockBytes, err := stream.nextBlockBytes()
+	if err != nil || blockBytes == nil {
+		return 0, false, err
+	}
+	info, err := extractSerializedBlockInfo(blockBytes)
+	if err != nil {
+		return 0, false, err
+	}
+	return info.blockHeader.Number, true, nil
+}
@@ -0,0 +1,83 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverageCheck(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	rootDir := arch.mgr.rootDir
+
+	// The discarded blockfile is covered by the catalog
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(rootDir, info))
+	gaps, err := arch.checkCoverage()
+	require.NoError(t, err)
+	assert.Empty(t, gaps)
+
+	summary1, err := scanBlockfile(rootDir, 1)
+	require.NoError(t, err)
+	summary2, err := scanBlockfile(rootDir, 2)
+	require.NoError(t, err)
+
+	// A blockfile deleted without having been archived leaves a gap after the local blockfile before it
+	require.NoError(t, os.Remove(deriveBlockfilePath(rootDir, 2)))
+	gaps, err = arch.checkCoverage()
+	require.NoError(t, err)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, []int{2}, gaps[0].blockfiles)
+	assert.Equal(t, summary2.firstBlockNum, gaps[0].firstBlockNum)
+	assert.Equal(t, summary2.lastBlockNum, gaps[0].lastBlockNum)
+
+	// A gap after the archived blockfile starts with the block following its last one
+	require.NoError(t, os.Remove(deriveBlockfilePath(rootDir, 1)))
+	gaps, err = arch.checkCoverage()
+	require.NoError(t, err)
+	require.Len(t, gaps, 1)
+	assert.Equal(t, []int{1, 2}, gaps[0].blockfiles)
+	assert.Equal(t, summary1.firstBlockNum, gaps[0].firstBlockNum)
+	assert.Equal(t, summary2.lastBlockNum, gaps[0].lastBlockNum)
+	store.Shutdown()
+
+	prevCheck, prevStrict := blockarchive.CheckCoverage, blockarchive.StrictCoverage
+	defer func() { blockarchive.CheckCoverage, blockarchive.StrictCoverage = prevCheck, prevStrict }()
+
+	// The ledger is opened with the gap reported unless the coverage is strict
+	blockarchive.CheckCoverage = true
+	store, err = env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	store.Shutdown()
+
+	blockarchive.StrictCoverage = true
+	_, err = env.provider.OpenBlockStore("testLedger")
+	assert.EqualError(t, err, fmt.Sprintf("%d block(s) of ledger [testLedger] are neither in the archive catalog nor in the local blockfiles", summary2.lastBlockNum-summary1.firstBlockNum+1))
+}
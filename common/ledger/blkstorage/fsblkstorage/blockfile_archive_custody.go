@@ -0,0 +1,385 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// Residence of a block or a blockfile in a chain-of-custody report
+const (
+	// ResidenceLocal is on the local file system only
+	ResidenceLocal = "local"
+	// ResidenceArchive is on the repository only, the local copy has been discarded
+	ResidenceArchive = "archive"
+	// ResidenceLocalAndArchive is on the repository with a local copy
+	ResidenceLocalAndArchive = "local+archive"
+)
+
+// Status of a check of a chain-of-custody report
+const (
+	CustodyPassed     = "PASS"
+	CustodyFailed     = "FAIL"
+	CustodyNotChecked = "NOT_CHECKED"
+)
+
+// CustodyReport is the chain of custody of a range of blocks of a ledger: where each block and its blockfile
+// reside, their digests, the signed manifests of the archived blockfiles, the audit of the archived blockfiles
+// on the repository and the verification of the hash chain of the blocks, as ledgerfsck does
+type CustodyReport struct {
+	LedgerID    string    `json:"channel"`
+	FromBlock   uint64    `json:"fromBlock"`
+	ToBlock     uint64    `json:"toBlock"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Repository is the URL of the repository of the archived blockfiles
+	Repository   string               `json:"repository,omitempty"`
+	Blockfiles   []*CustodyBlockfile  `json:"blockfiles"`
+	Blocks       []*CustodyBlock      `json:"blocks"`
+	Verification *CustodyVerification `json:"verification"`
+}
+
+// CustodyBlock is the custody of a block
+type CustodyBlock struct {
+	BlockNum     uint64 `json:"blockNum"`
+	BlockfileNo  uint64 `json:"blockfileNo"`
+	Residence    string `json:"residence"`
+	HeaderHash   string `json:"headerHash"`
+	PreviousHash string `json:"previousHash"`
+	DataHash     string `json:"dataHash"`
+}
+
+// CustodyBlockfile is the custody of a blockfile holding blocks of the range
+type CustodyBlockfile struct {
+	BlockfileNo   uint64 `json:"blockfileNo"`
+	FirstBlockNum uint64 `json:"firstBlockNum"`
+	LastBlockNum  uint64 `json:"lastBlockNum"`
+	Residence     string `json:"residence"`
+	// LocalHash is the SHA-256 hash of the local copy of the blockfile, empty if it has been discarded
+	LocalHash string `json:"localHash,omitempty"`
+	// Location and Checksum are the path and the checksum of the archived blockfile on the repository
+	Location   string     `json:"location,omitempty"`
+	Checksum   string     `json:"checksum,omitempty"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	// Manifest is the signed manifest of the archived blockfile, nil if the peer has none
+	Manifest *CustodyManifest `json:"manifest,omitempty"`
+	// Audit is the verification of the content of the archived blockfile on the repository against its checksum
+	Audit *CustodyAudit `json:"audit,omitempty"`
+}
+
+// CustodyManifest is the signed manifest of an archived blockfile stored by the peer
+type CustodyManifest struct {
+	Path          string     `json:"path"`
+	BlockfileHash string     `json:"blockfileHash,omitempty"`
+	MerkleRoot    string     `json:"merkleRoot,omitempty"`
+	ArchivedAt    *time.Time `json:"archivedAt,omitempty"`
+	// SignerMSPID is the MSP of the signer of the manifest, when its signature is verified
+	SignerMSPID string `json:"signerMspId,omitempty"`
+	// Status is CustodyPassed when the signature of the manifest is valid and the manifest matches the
+	// blockfile, CustodyNotChecked when no MSP is configured to verify it
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CustodyAudit is the audit of an archived blockfile on the repository
+type CustodyAudit struct {
+	// Status is CustodyPassed when the archived blockfile is present and matches its checksum
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CustodyVerification is the verification of the blocks of the range
+type CustodyVerification struct {
+	// Status is CustodyPassed when the data hash of every block matches its header and every block
+	// refers to the header hash of the previous block
+	Status string `json:"status"`
+	// Checked is the number of verified blocks
+	Checked int    `json:"checked"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Passed tells if the checks of the report have all passed, the ones not run aside
+func (r *CustodyReport) Passed() bool {
+	if r.Verification.Status != CustodyPassed {
+		return false
+	}
+	for _, blockfile := range r.Blockfiles {
+		if blockfile.Manifest != nil && blockfile.Manifest.Status == CustodyFailed {
+			return false
+		}
+		if blockfile.Audit != nil && blockfile.Audit.Status == CustodyFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// CustodyReportOf produces the chain-of-custody report of the blocks fromBlock to toBlock of a ledger stored
+// in blockStorePath. The discarded blocks are read from the repository. When audit is set, the archived
+// blockfiles are verified on the repository against their checksum. It must not be called while the peer
+// is running.
+func CustodyReportOf(blockStorePath, ledgerID string, fromBlock, toBlock uint64, audit bool) (*CustodyReport, error) {
+	conf := NewConf(blockStorePath, 0, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir)
+	if _, err := os.Stat(conf.getLedgerBlockDir(ledgerID)); err != nil {
+		return nil, errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	provider := NewProvider(conf, &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{blkstorage.IndexableAttrBlockNum}})
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Shutdown()
+	return store.(*fsBlockStore).archiver.custodyReport(fromBlock, toBlock, audit)
+}
+
+func (arch *blockfileArchiver) custodyReport(fromBlock, toBlock uint64, audit bool) (*CustodyReport, error) {
+	height := arch.mgr.getBlockchainInfo().Height
+	if fromBlock >= height {
+		return nil, errors.Errorf("block [%d] is beyond the last block [%d] of ledger [%s]", fromBlock, int64(height)-1, arch.chainID)
+	}
+	if toBlock >= height {
+		toBlock = height - 1
+	}
+	if toBlock < fromBlock {
+		return nil, errors.Errorf("invalid block range [%d-%d]", fromBlock, toBlock)
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[uint64]*archive.ArchivedBlockfileInfo)
+	for _, info := range infos {
+		archived[info.BlockfileNo] = info
+	}
+
+	report := &CustodyReport{
+		LedgerID:     arch.chainID,
+		FromBlock:    fromBlock,
+		ToBlock:      toBlock,
+		GeneratedAt:  time.Now().UTC(),
+		Repository:   blockarchive.RepositoryURLOf(arch.chainID),
+		Blockfiles:   []*CustodyBlockfile{},
+		Blocks:       []*CustodyBlock{},
+		Verification: &CustodyVerification{Status: CustodyPassed},
+	}
+	blockfiles := make(map[uint64]*CustodyBlockfile)
+	var prevHash []byte
+	if fromBlock > 0 {
+		if prevHash, err = arch.blockHeaderHash(fromBlock - 1); err != nil {
+			return nil, err
+		}
+	}
+	for blockNum := fromBlock; blockNum <= toBlock; blockNum++ {
+		loc, err := arch.mgr.index.getBlockLocByBlockNum(blockNum)
+		if err != nil {
+			return nil, err
+		}
+		block, err := arch.mgr.fetchBlock(loc)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "error reading block [%d]", blockNum)
+		}
+		fileNum := uint64(loc.fileSuffixNum)
+		blockfile, ok := blockfiles[fileNum]
+		if !ok {
+			if blockfile, err = arch.custodyBlockfile(fileNum, archived[fileNum]); err != nil {
+				return nil, err
+			}
+			blockfiles[fileNum] = blockfile
+			report.Blockfiles = append(report.Blockfiles, blockfile)
+		}
+		headerHash := protoutil.BlockHeaderHash(block.Header)
+		report.Blocks = append(report.Blocks, &CustodyBlock{
+			BlockNum:     blockNum,
+			BlockfileNo:  fileNum,
+			Residence:    blockfile.Residence,
+			HeaderHash:   hex.EncodeToString(headerHash),
+			PreviousHash: hex.EncodeToString(block.Header.PreviousHash),
+			DataHash:     hex.EncodeToString(block.Header.DataHash),
+		})
+		if report.Verification.Status == CustodyPassed {
+			if err := verifyCustodyBlock(block, blockNum, prevHash); err != nil {
+				report.Verification.Status, report.Verification.Error = CustodyFailed, err.Error()
+			} else {
+				report.Verification.Checked++
+			}
+		}
+		prevHash = headerHash
+	}
+
+	if audit {
+		if err := auditCustodyBlockfiles(arch.chainID, report.Blockfiles); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// verifyCustodyBlock checks that a block has the expected number, the hash of its data and the header hash
+// of the previous block, nil for the genesis block
+func verifyCustodyBlock(block *common.Block, blockNum uint64, prevHash []byte) error {
+	if block.Header == nil || block.Data == nil {
+		return errors.Errorf("block [%d] has no header or no data", blockNum)
+	}
+	if block.Header.Number != blockNum {
+		return errors.Errorf("block [%d] is numbered [%d]", blockNum, block.Header.Number)
+	}
+	if !bytes.Equal(protoutil.BlockDataHash(block.Data), block.Header.DataHash) {
+		return errors.Errorf("block [%d]: the hash of its data doesn't match the data hash of its header", blockNum)
+	}
+	if prevHash != nil && !bytes.Equal(prevHash, block.Header.PreviousHash) {
+		return errors.Errorf("block [%d]: the header hash of the previous block %x doesn't match the previous hash %x of its header",
+			blockNum, prevHash, block.Header.PreviousHash)
+	}
+	return nil
+}
+
+// custodyBlockfile describes where a blockfile resides, with its digests and its signed manifest
+func (arch *blockfileArchiver) custodyBlockfile(fileNum uint64, info *archive.ArchivedBlockfileInfo) (*CustodyBlockfile, error) {
+	blockfile := &CustodyBlockfile{BlockfileNo: fileNum, Residence: ResidenceArchive}
+	filePath := deriveBlockfilePath(arch.blockfileDir, int(fileNum))
+	if _, err := os.Stat(filePath); err == nil {
+		blockfile.Residence = ResidenceLocal
+		hash, err := blockarchive.ComputeBlockfileHash(filePath)
+		if err != nil {
+			return nil, err
+		}
+		blockfile.LocalHash = hex.EncodeToString(hash)
+	}
+	if info == nil {
+		if blockfile.Residence != ResidenceLocal {
+			return nil, errors.Errorf("blockfile [%d] of ledger [%s] is neither on the local file system nor in the archive catalog", fileNum, arch.chainID)
+		}
+		summary, err := scanBlockfile(arch.mgr.rootDir, int(fileNum))
+		if err != nil {
+			return nil, err
+		}
+		blockfile.FirstBlockNum, blockfile.LastBlockNum = summary.firstBlockNum, summary.lastBlockNum
+		return blockfile, nil
+	}
+	if blockfile.Residence == ResidenceLocal {
+		blockfile.Residence = ResidenceLocalAndArchive
+	}
+	blockfile.FirstBlockNum, blockfile.LastBlockNum = info.FirstBlockNum, info.LastBlockNum
+	blockfile.Location, blockfile.Checksum = info.Location, info.Checksum
+	if archivedAt, err := ptypes.Timestamp(info.ArchivedAt); err == nil {
+		archivedAt = archivedAt.UTC()
+		blockfile.ArchivedAt = &archivedAt
+	}
+	blockfile.Manifest = arch.custodyManifest(blockfile, info)
+	return blockfile, nil
+}
+
+// custodyManifest reads and verifies the signed manifest of an archived blockfile stored by the peer,
+// nil if the peer has none
+func (arch *blockfileArchiver) custodyManifest(blockfile *CustodyBlockfile, info *archive.ArchivedBlockfileInfo) *CustodyManifest {
+	manifestPath := deriveManifestPath(arch.mgr.conf, arch.chainID, int(info.BlockfileNo))
+	signedBytes, err := ioutil.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	custody := &CustodyManifest{Path: manifestPath, Status: CustodyFailed}
+	if err != nil {
+		custody.Error = err.Error()
+		return custody
+	}
+	signed := &archive.SignedArchiveManifest{}
+	if err := proto.Unmarshal(signedBytes, signed); err != nil {
+		custody.Error = errors.Wrap(err, "error unmarshaling manifest").Error()
+		return custody
+	}
+	manifest := &archive.ArchiveManifest{}
+	if blockarchive.ManifestVerifier == nil {
+		custody.Status = CustodyNotChecked
+		if err := proto.Unmarshal(signed.Manifest, manifest); err != nil {
+			custody.Status, custody.Error = CustodyFailed, errors.Wrap(err, "error unmarshaling manifest").Error()
+			return custody
+		}
+	} else {
+		verified, identity, err := blockarchive.VerifyManifest(signed, blockarchive.ManifestVerifier)
+		if err != nil {
+			custody.Error = err.Error()
+			return custody
+		}
+		manifest = verified
+		custody.SignerMSPID = identity.GetMSPIdentifier()
+	}
+	custody.BlockfileHash = hex.EncodeToString(manifest.BlockfileHash)
+	custody.MerkleRoot = hex.EncodeToString(manifest.MerkleRoot)
+	if archivedAt, err := ptypes.Timestamp(manifest.Timestamp); err == nil {
+		archivedAt = archivedAt.UTC()
+		custody.ArchivedAt = &archivedAt
+	}
+
+	switch {
+	case manifest.ChannelID != arch.chainID || manifest.BlockfileNo != info.BlockfileNo || manifest.Location != info.Location:
+		custody.Status = CustodyFailed
+		custody.Error = errors.Errorf("the manifest is the one of blockfile [%d] of ledger [%s] at %s",
+			manifest.BlockfileNo, manifest.ChannelID, manifest.Location).Error()
+	case manifest.FirstBlockNum != info.FirstBlockNum || manifest.LastBlockNum != info.LastBlockNum:
+		custody.Status = CustodyFailed
+		custody.Error = errors.Errorf("the manifest holds blocks [%d-%d], but blocks [%d-%d] have been archived",
+			manifest.FirstBlockNum, manifest.LastBlockNum, info.FirstBlockNum, info.LastBlockNum).Error()
+	case blockfile.LocalHash != "" && blockfile.LocalHash != custody.BlockfileHash:
+		custody.Status = CustodyFailed
+		custody.Error = errors.Errorf("the hash of the local blockfile %s does not match the one in the manifest", blockfile.LocalHash).Error()
+	case custody.Status != CustodyNotChecked:
+		custody.Status = CustodyPassed
+	}
+	return custody
+}
+
+// auditCustodyBlockfiles verifies the archived blockfiles of a report on the repository against their checksum
+func auditCustodyBlockfiles(ledgerID string, blockfiles []*CustodyBlockfile) error {
+	var client repositoryClient
+	for _, blockfile := range blockfiles {
+		if blockfile.Location == "" {
+			continue
+		}
+		if client == nil {
+			sshConn, c, err := connectToRepo(ledgerID)
+			if err != nil {
+				return errors.WithMessage(err, "error connecting to the repository")
+			}
+			defer sshConn.Close()
+			defer c.Close()
+			client = c
+		}
+		blockfile.Audit = auditCustodyBlockfile(client, blockfile)
+	}
+	return nil
+}
+
+func auditCustodyBlockfile(client repositoryClient, blockfile *CustodyBlockfile) *CustodyAudit {
+	if _, err := client.Stat(blockfile.Location); os.IsNotExist(err) {
+		return &CustodyAudit{Status: CustodyFailed, Error: "the archived blockfile is missing from the repository"}
+	} else if err != nil {
+		return &CustodyAudit{Status: CustodyFailed, Error: err.Error()}
+	}
+	if blockfile.Checksum == "" {
+		return &CustodyAudit{Status: CustodyNotChecked, Error: "no checksum was recorded when the blockfile was archived"}
+	}
+	info := &archive.ArchivedBlockfileInfo{BlockfileNo: blockfile.BlockfileNo, Location: blockfile.Location, Checksum: blockfile.Checksum}
+	matched, err := matchesChecksum(client, info)
+	if err != nil {
+		return &CustodyAudit{Status: CustodyFailed, Error: err.Error()}
+	}
+	if !matched {
+		return &CustodyAudit{Status: CustodyFailed, Error: "the archived blockfile does not match its checksum"}
+	}
+	return &CustodyAudit{Status: CustodyPassed}
+}
@@ -0,0 +1,147 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustodyReport(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	require.NoError(t, msptesttools.LoadMSPSetupForTesting())
+	blockStorePath := testPath()
+	prevVerifier, prevSigner, prevBlockStorePath := blockarchive.ManifestVerifier, blockarchive.ManifestSigner, blockarchive.BlockStorePath
+	defer func() {
+		blockarchive.ManifestVerifier, blockarchive.ManifestSigner, blockarchive.BlockStorePath = prevVerifier, prevSigner, prevBlockStorePath
+	}()
+	blockarchive.ManifestVerifier, blockarchive.ManifestSigner = mgmt.GetLocalMSP(), mgmt.GetLocalSigningIdentityOrPanic()
+	blockarchive.BlockStorePath = blockStorePath
+
+	// Blockfile 0 is discarded, blockfile 1 is archived and kept, blockfile 2 is being written
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	var locations []string
+	for fileNum, discard := range []bool{true, false} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.publishManifest(fileNum, location))
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, discard))
+		locations = append(locations, location)
+	}
+
+	report, err := arch.custodyReport(5, 100, true)
+	require.NoError(t, err)
+	assert.True(t, report.Passed())
+	assert.Equal(t, "testLedger", report.LedgerID)
+	assert.Equal(t, uint64(5), report.FromBlock)
+	assert.Equal(t, uint64(29), report.ToBlock)
+	assert.Equal(t, &CustodyVerification{Status: CustodyPassed, Checked: 25}, report.Verification)
+
+	require.Len(t, report.Blocks, 25)
+	for i, block := range report.Blocks {
+		blockNum := uint64(i + 5)
+		loc, err := arch.mgr.index.getBlockLocByBlockNum(blockNum)
+		require.NoError(t, err)
+		assert.Equal(t, blockNum, block.BlockNum)
+		assert.Equal(t, uint64(loc.fileSuffixNum), block.BlockfileNo)
+		assert.Equal(t, []string{ResidenceArchive, ResidenceLocalAndArchive, ResidenceLocal}[loc.fileSuffixNum], block.Residence, "block [%d]", blockNum)
+		assert.Equal(t, hex.EncodeToString(protoutil.BlockHeaderHash(blocks[blockNum].Header)), block.HeaderHash)
+		assert.Equal(t, hex.EncodeToString(blocks[blockNum].Header.PreviousHash), block.PreviousHash)
+		assert.Equal(t, hex.EncodeToString(blocks[blockNum].Header.DataHash), block.DataHash)
+	}
+
+	require.Len(t, report.Blockfiles, 3)
+	discarded, kept, current := report.Blockfiles[0], report.Blockfiles[1], report.Blockfiles[2]
+	assert.Equal(t, ResidenceArchive, discarded.Residence)
+	assert.Empty(t, discarded.LocalHash)
+	assert.Equal(t, locations[0], discarded.Location)
+	require.NotNil(t, discarded.Manifest)
+	assert.Equal(t, CustodyPassed, discarded.Manifest.Status)
+	assert.Equal(t, "SampleOrg", discarded.Manifest.SignerMSPID)
+	assert.Equal(t, &CustodyAudit{Status: CustodyPassed}, discarded.Audit)
+
+	localHash, err := blockarchive.ComputeBlockfileHash(deriveBlockfilePath(arch.blockfileDir, 1))
+	require.NoError(t, err)
+	assert.Equal(t, ResidenceLocalAndArchive, kept.Residence)
+	assert.Equal(t, hex.EncodeToString(localHash), kept.LocalHash)
+	require.NotNil(t, kept.Manifest)
+	assert.Equal(t, kept.LocalHash, kept.Manifest.BlockfileHash)
+	assert.Equal(t, CustodyPassed, kept.Manifest.Status)
+	assert.Equal(t, &CustodyAudit{Status: CustodyPassed}, kept.Audit)
+
+	assert.Equal(t, ResidenceLocal, current.Residence)
+	assert.Equal(t, kept.LastBlockNum+1, current.FirstBlockNum)
+	assert.Equal(t, uint64(29), current.LastBlockNum)
+	assert.Empty(t, current.Location)
+	assert.Nil(t, current.Manifest)
+	assert.Nil(t, current.Audit)
+
+	// The tampered manifests and the blockfiles altered on the repository fail the report
+	manifestPath := deriveManifestPath(arch.mgr.conf, "testLedger", 1)
+	signedBytes, err := ioutil.ReadFile(manifestPath)
+	require.NoError(t, err)
+	signed := &archive.SignedArchiveManifest{}
+	require.NoError(t, proto.Unmarshal(signedBytes, signed))
+	signed.Signature[len(signed.Signature)-1] ^= 0xff
+	signedBytes, err = proto.Marshal(signed)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(manifestPath, signedBytes, 0644))
+	remotePath := filepath.Join(repoRootDir, locations[1])
+	content, err := ioutil.ReadFile(remotePath)
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(remotePath, content, 0644))
+	report, err = arch.custodyReport(kept.FirstBlockNum, kept.LastBlockNum, true)
+	require.NoError(t, err)
+	assert.False(t, report.Passed())
+	assert.Equal(t, CustodyPassed, report.Verification.Status)
+	require.Len(t, report.Blockfiles, 1)
+	assert.Equal(t, CustodyFailed, report.Blockfiles[0].Manifest.Status)
+	assert.Contains(t, report.Blockfiles[0].Manifest.Error, "signature of the archive manifest is not valid")
+	assert.Equal(t, &CustodyAudit{Status: CustodyFailed, Error: "the archived blockfile does not match its checksum"}, report.Blockfiles[0].Audit)
+
+	// The report is not audited unless required
+	report, err = arch.custodyReport(0, 0, false)
+	require.NoError(t, err)
+	assert.Nil(t, report.Blockfiles[0].Audit)
+	assert.Equal(t, 1, report.Verification.Checked)
+
+	_, err = arch.custodyReport(30, 40, false)
+	assert.EqualError(t, err, "block [30] is beyond the last block [29] of ledger [testLedger]")
+	_, err = CustodyReportOf(blockStorePath, "unknownLedger", 0, 10, false)
+	assert.EqualError(t, err, "ledger [unknownLedger] not found in "+blockStorePath)
+}
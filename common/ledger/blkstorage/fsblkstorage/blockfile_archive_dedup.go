@@ -0,0 +1,134 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// contentAddressedLocation returns the path on the repository of a local blockfile
+// derived from the hash of its content
+func (arch *blockfileArchiver) contentAddressedLocation(fileNum int) (string, error) {
+	hash, err := blockarchive.ComputeBlockfileHash(deriveBlockfilePath(arch.mgr.rootDir, fileNum))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(blockarchive.ArchiveRootDir(), blockarchive.ContentAddressedKey(hash)), nil
+}
+
+// refName returns the name of the reference of this peer to the content-addressed blockfile
+func (arch *blockfileArchiver) refName(fileNum int) string {
+	return blockarchive.BlockfileRefName(blockarchive.ArchiverID, arch.chainID, uint64(fileNum))
+}
+
+// remoteManifestPath returns the path on the repository of the manifest, or of the summary
+// depending on the suffix, of an archived blockfile. The manifest of a content-addressed blockfile
+// is stored next to the reference of this peer, since the blockfile may have been archived by several peers.
+func (arch *blockfileArchiver) remoteManifestPath(fileNum int, location, suffix string) string {
+	if blockarchive.ContentAddressed {
+		return blockarchive.BlockfileRefPath(location, arch.refName(fileNum)) + suffix
+	}
+	return location + suffix
+}
+
+// isBlockfileStored returns whether the repository already holds a blockfile of the size at the path, stored
+// as is or encoded. Since the path of a content-addressed blockfile is derived from its content, the upload is
+// then skipped.
+func isBlockfileStored(client repositoryClient, path string, size int64) bool {
+	info, err := client.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.Size() == size {
+		return true
+	}
+	file, err := client.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header, err := blockarchive.ReadObjectHeader(bufio.NewReader(file))
+	return err == nil && header != nil && header.Size == size
+}
+
+// addBlockfileRef records the reference of this peer to the content-addressed blockfile at location
+func addBlockfileRef(ledgerID, location, refName string) error {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return err
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	refPath := blockarchive.BlockfileRefPath(location, refName)
+	if err := client.MkdirAll(filepath.Dir(refPath)); err != nil {
+		return errors.Wrapf(err, "error creating the references of %s", location)
+	}
+	ref, err := client.Create(refPath)
+	if err != nil {
+		return errors.Wrapf(err, "error creating reference %s", refPath)
+	}
+	if err := ref.Close(); err != nil {
+		return errors.Wrapf(err, "error creating reference %s", refPath)
+	}
+	loggerArchive.Infof("Recorded reference %s", refPath)
+	return nil
+}
+
+// countBlockfileRefs returns the number of references to the content-addressed blockfile at location
+func countBlockfileRefs(client repositoryClient, location string) (int, error) {
+	entries, err := client.ReadDir(location + blockarchive.RefsSuffix)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing the references of %s", location)
+	}
+	refs := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), blockarchive.ManifestSuffix) && !strings.HasSuffix(entry.Name(), blockarchive.SummarySuffix) {
+			refs++
+		}
+	}
+	return refs, nil
+}
+
+// releaseBlockfileRef removes the reference to the content-addressed blockfile at location, and deletes
+// the blockfile from the repository once it is no longer referenced. It returns whether the blockfile
+// has been deleted. The repository refuses the deletion while the retention policy of an organization
+// which has referenced the blockfile retains it, and collects it later. The releases must not run concurrently with the archiving of the same blockfile,
+// which could otherwise skip the upload of a blockfile which is about to be deleted.
+func releaseBlockfileRef(ledgerID, location, refName string) (bool, error) {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return false, err
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	refPath := blockarchive.BlockfileRefPath(location, refName)
+	if err := client.Remove(refPath); err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "error removing reference %s", refPath)
+	}
+	client.Remove(refPath + blockarchive.ManifestSuffix)
+	client.Remove(refPath + blockarchive.SummarySuffix)
+
+	refs, err := countBlockfileRefs(client, location)
+	if err != nil || refs > 0 {
+		return false, err
+	}
+	if err := client.Remove(location); err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "error removing unreferenced blockfile %s", location)
+	}
+	client.RemoveDirectory(location + blockarchive.RefsSuffix)
+	loggerArchive.Infof("Removed unreferenced blockfile %s from the repository", location)
+	return true, nil
+}
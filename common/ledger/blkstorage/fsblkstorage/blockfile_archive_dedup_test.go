@@ -0,0 +1,93 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentAddressedArchiving(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevContentAddressed, prevArchiverID := blockarchive.ContentAddressed, blockarchive.ArchiverID
+	blockarchive.ContentAddressed = true
+	blockarchive.ArchiverID = "peer0.org1"
+	defer func() { blockarchive.ContentAddressed, blockarchive.ArchiverID = prevContentAddressed, prevArchiverID }()
+
+	blocks := testutil.ConstructTestBlocks(t, 20)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+
+	// The same blocks are archived for two ledgers, as if by the peers of two organizations
+	var locations []string
+	for _, ledgerID := range []string{"ledger1", "ledger2"} {
+		store, err := env.provider.OpenBlockStore(ledgerID)
+		require.NoError(t, err)
+		defer store.Shutdown()
+		for _, block := range blocks {
+			require.NoError(t, store.AddBlock(block))
+		}
+		arch := store.(*fsBlockStore).archiver
+		location, err := arch.archiveLocation(0)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+		require.NoError(t, err)
+		require.NoError(t, addBlockfileRef(ledgerID, location, arch.refName(0)))
+		require.NoError(t, arch.handleArchivedBlockfile(0, true))
+
+		info, err := store.GetArchiveCatalog().GetArchiveLocation(5)
+		require.NoError(t, err)
+		locations = append(locations, info.Location)
+
+		// The discarded blocks are read from the content-addressed blockfile
+		block, err := store.RetrieveBlockByNumber(5)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(blocks[5], block))
+	}
+	require.Equal(t, locations[0], locations[1])
+	location := locations[0]
+	assert.Contains(t, location, "/blkstore/"+blockarchive.ObjectsDir+"/")
+
+	sshConn, client, err := connectToRepo("ledger1")
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	refs, err := countBlockfileRefs(client, location)
+	require.NoError(t, err)
+	assert.Equal(t, 2, refs)
+
+	deleted, err := releaseBlockfileRef("ledger1", location, blockarchive.BlockfileRefName("peer0.org1", "ledger1", 0))
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	_, err = client.Stat(location)
+	assert.NoError(t, err)
+
+	deleted, err = releaseBlockfileRef("ledger2", location, blockarchive.BlockfileRefName("peer0.org1", "ledger2", 0))
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = client.Stat(location)
+	assert.True(t, os.IsNotExist(err))
+	_, err = client.Stat(filepath.Dir(blockarchive.BlockfileRefPath(location, "ref")))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,126 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+)
+
+// usedDiskSpace returns the usage in percent of the file system of a path
+var usedDiskSpace = diskUsage
+
+// discardScheduledPass discards the archived blockfiles kept on the local file system, all of them, or
+// while the disk usage is above blockarchive.DiscardDiskUsageThreshold if set
+func (arch *blockfileArchiver) discardScheduledPass() {
+	loggerDiscard.Infow("Scheduled discard pass", blockarchive.LogKeyChannel, arch.chainID)
+	threshold := blockarchive.DiscardDiskUsageThreshold
+	arch.discardArchivedBlockfiles(func() bool {
+		return threshold == 0 || arch.needsDiskSpace(threshold)
+	})
+}
+
+// discardIfNecessary is called after each archiving while the discard is deferred. It discards the archived
+// blockfiles kept on the local file system while the disk usage is above blockarchive.DiscardDiskUsageThreshold,
+// unless the usage is checked by the passes of blockarchive.DiscardSchedule, or while the free disk space is
+// below blockarchive.MinFreeDiskSpace, so that the commits never wait for the next pass.
+func (arch *blockfileArchiver) discardIfNecessary() {
+	if !blockarchive.IsDiscardDeferred() {
+		return
+	}
+	threshold := blockarchive.DiscardDiskUsageThreshold
+	if blockarchive.DiscardSchedule != nil {
+		threshold = 0
+	}
+	arch.discardArchivedBlockfiles(func() bool {
+		return arch.needsDiskSpace(threshold)
+	})
+}
+
+// checkDiskHighWatermark raises an alert when the usage of the file system of the block store is still at or above
+// blockarchive.DiskHighWatermark after an archiving, i.e. the discard of the archived blockfiles doesn't free enough space
+func (arch *blockfileArchiver) checkDiskHighWatermark() {
+	if blockarchive.DiskHighWatermark <= 0 {
+		return
+	}
+	usage, err := usedDiskSpace(arch.blockfileDir)
+	if err != nil {
+		loggerDiscard.Warnw("Failed checking the disk usage", blockarchive.LogKeyChannel, arch.chainID, "error", err)
+		return
+	}
+	if usage >= blockarchive.DiskHighWatermark {
+		blockarchive.RaiseAlert(blockarchive.AlertDiskHighWatermark, arch.chainID,
+			"the usage of the file system of the block store is %d%%, at or above %d%%", usage, blockarchive.DiskHighWatermark)
+	}
+}
+
+// needsDiskSpace tells whether the usage of the file system of the block store is above the threshold,
+// ignored when 0, or its free space below blockarchive.MinFreeDiskSpace
+func (arch *blockfileArchiver) needsDiskSpace(threshold int) bool {
+	if threshold > 0 {
+		usage, err := usedDiskSpace(arch.blockfileDir)
+		if err != nil {
+			loggerDiscard.Warnw("Failed checking the disk usage", blockarchive.LogKeyChannel, arch.chainID, "error", err)
+		} else if usage > threshold {
+			return true
+		}
+	}
+	if blockarchive.MinFreeDiskSpace > 0 {
+		free, err := freeDiskSpace(arch.blockfileDir)
+		if err != nil {
+			loggerDiscard.Warnw("Failed checking the free disk space", blockarchive.LogKeyChannel, arch.chainID, "error", err)
+		} else if free < blockarchive.MinFreeDiskSpace {
+			return true
+		}
+	}
+	return false
+}
+
+// discardArchivedBlockfiles discards the blockfiles archived by the archiving passes and kept on the local file
+// system, the oldest first, as long as needed tells so. The blockfiles restored temporarily are left to their
+// expiry. A blockfile too recent to be discarded stops the discard, as the later ones are more recent still.
+// It returns the number of blockfiles discarded.
+func (arch *blockfileArchiver) discardArchivedBlockfiles(needed func() bool) int {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		loggerDiscard.Errorw("Failed listing the archived blockfiles to discard", blockarchive.LogKeyChannel, arch.chainID, "error", err)
+		return 0
+	}
+	discarded := 0
+	for _, info := range infos {
+		fileNum := int(info.BlockfileNo)
+		if info.Discarded || info.RestoreExpiry != nil || fileNum >= arch.checkpoint.nextBlockfileNum {
+			continue
+		}
+		if _, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum)); os.IsNotExist(err) {
+			continue
+		}
+		if !needed() {
+			break
+		}
+		if recent, err := arch.isTooRecentToDiscard(fileNum); err != nil || recent {
+			if err != nil {
+				loggerDiscard.Error(err)
+			}
+			break
+		}
+		// The shutdown of the peer waits for the blockfile being discarded
+		if !transfers.begin() {
+			break
+		}
+		err := arch.deleteArchivedBlockfile(fileNum)
+		transfers.end()
+		if err != nil {
+			break
+		}
+		arch.notifyDiscarded(fileNum)
+		discarded++
+	}
+	if discarded > 0 {
+		arch.advertiseArchiveInfo()
+	}
+	return discarded
+}
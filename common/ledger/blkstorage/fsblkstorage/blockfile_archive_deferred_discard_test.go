@@ -0,0 +1,83 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeferredDiscard(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 60)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	prevSchedule, prevThreshold := blockarchive.DiscardSchedule, blockarchive.DiscardDiskUsageThreshold
+	defer func() {
+		blockarchive.DiscardSchedule, blockarchive.DiscardDiskUsageThreshold = prevSchedule, prevThreshold
+		usedDiskSpace = diskUsage
+	}()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+
+	// The disk usage grows by 10% with each local blockfile
+	usedDiskSpace = func(path string) (int, error) {
+		fileNums, _, err := listLocalBlockfiles(path)
+		return 10 * len(fileNums), err
+	}
+	localBlockfiles := func() int {
+		usage, err := usedDiskSpace(arch.blockfileDir)
+		require.NoError(t, err)
+		return usage / 10
+	}
+	assertDiscarded := func(fileNum int, discarded bool) {
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		require.NoError(t, err)
+		require.NotNil(t, info, "blockfile [%d] is not archived", fileNum)
+		assert.Equal(t, discarded, info.Discarded, "blockfile [%d]", fileNum)
+		_, err = os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+		assert.Equal(t, discarded, os.IsNotExist(err), "blockfile [%d]", fileNum)
+	}
+
+	// The archived blockfiles are kept while the disk usage is below the threshold
+	blockarchive.DiscardDiskUsageThreshold = 99
+	blockarchive.NumBlockfileEachArchiving = 2
+	arch.archiveScheduledPass(make(chan struct{}))
+	arch.discardIfNecessary()
+	require.Equal(t, 5, arch.checkpoint.nextBlockfileNum)
+	for fileNum := 1; fileNum < 5; fileNum++ {
+		assertDiscarded(fileNum, false)
+	}
+
+	// and discarded, the oldest first, until it is below the threshold again
+	blockarchive.DiscardDiskUsageThreshold = 10 * (localBlockfiles() - 2)
+	arch.discardIfNecessary()
+	assertDiscarded(1, true)
+	assertDiscarded(2, true)
+	assertDiscarded(3, false)
+	assertDiscarded(4, false)
+
+	// The passes of the discard schedule discard all of them, but the blockfiles not archived yet
+	schedule, err := blockarchive.ParseSchedule("@daily")
+	require.NoError(t, err)
+	blockarchive.DiscardSchedule, blockarchive.DiscardDiskUsageThreshold = schedule, 0
+	arch.discardIfNecessary()
+	assertDiscarded(3, false)
+	arch.discardScheduledPass()
+	assertDiscarded(3, true)
+	assertDiscarded(4, true)
+	_, err = os.Stat(deriveBlockfilePath(arch.blockfileDir, 5))
+	assert.NoError(t, err)
+	ranges, err := store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	require.Len(t, ranges, 1)
+}
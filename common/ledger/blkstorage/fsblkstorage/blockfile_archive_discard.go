@@ -0,0 +1,120 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Key prefix of the journal entries of the discards in progress in the index db
+	discardJournalKeyPrefix = 'j'
+)
+
+// discardBlockfile deletes an archived blockfile from the local file system as a journaled
+// two-phase operation, so that a crash never leaves the catalog and the local file system apart:
+//  1. a journal entry of the discard is written, then the record of the blockfile is marked as
+//     discarded in the catalog
+//  2. the blockfile is deleted from the local file system
+//  3. the journal entry is removed, which commits the discard
+//
+// When RetainConfigBlocks is set, the config blocks of the blockfile are kept in the db in the write of
+// the journal entry. A discard interrupted between 1 and 3 is completed by recoverDiscards on the next start.
+func (c *archiveCatalog) discardBlockfile(blockfileDir string, info *archive.ArchivedBlockfileInfo) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	batch.Put(constructDiscardJournalKey(info.BlockfileNo), []byte(blockfileDir))
+	if blockarchive.RetainConfigBlocks {
+		retained, err := retainConfigBlocks(batch, blockfileDir, int(info.BlockfileNo))
+		if err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+		if retained > 0 {
+			loggerDiscard.Debugw("Retained config blocks", append(archivedBlockfileLogFields(info), "blocks", retained)...)
+		}
+	}
+	if err := c.db.WriteBatch(batch, true); err != nil {
+		return errors.Wrapf(err, "error journaling the discard of blockfile [%d]", info.BlockfileNo)
+	}
+	// The record is marked again by the recovery if the catalog is not written
+	if err := c.markDiscarded(info); err != nil {
+		return err
+	}
+
+	if err := os.Remove(deriveBlockfilePath(blockfileDir, int(info.BlockfileNo))); err != nil && !os.IsNotExist(err) {
+		// The journal entry is left for the recovery to retry the deletion
+		return errors.Wrapf(err, "error deleting blockfile [%d]", info.BlockfileNo)
+	}
+	return c.commitDiscard(info.BlockfileNo)
+}
+
+// markDiscarded records in the catalog that an archived blockfile has been discarded
+func (c *archiveCatalog) markDiscarded(info *archive.ArchivedBlockfileInfo) error {
+	info.Discarded = true
+	info.RestoreExpiry = nil
+	if err := c.store.putBlockfile(info); err != nil {
+		return errors.WithMessagef(err, "error marking blockfile [%d] as discarded", info.BlockfileNo)
+	}
+	return nil
+}
+
+// commitDiscard removes the journal entry of a completed discard
+func (c *archiveCatalog) commitDiscard(fileNum uint64) error {
+	if err := c.db.Delete(constructDiscardJournalKey(fileNum), true); err != nil {
+		return errors.Wrapf(err, "error committing the discard of blockfile [%d]", fileNum)
+	}
+	return nil
+}
+
+// recoverDiscards completes the discards interrupted by a crash, marking the blockfiles of the journal
+// as discarded in the catalog and deleting them if they are still on the local file system. It returns the
+// postfix numbers of the blockfiles whose discard has been completed.
+func (c *archiveCatalog) recoverDiscards() ([]uint64, error) {
+	type pendingDiscard struct {
+		fileNum      uint64
+		blockfileDir string
+	}
+	var pending []pendingDiscard
+	itr := c.db.GetIterator([]byte{discardJournalKeyPrefix}, []byte{discardJournalKeyPrefix + 1})
+	for itr.Next() {
+		fileNum, _ := util.DecodeOrderPreservingVarUint64(itr.Key()[1:])
+		pending = append(pending, pendingDiscard{fileNum, string(itr.Value())})
+	}
+	err := itr.Error()
+	itr.Release()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the discard journal")
+	}
+
+	var recovered []uint64
+	for _, p := range pending {
+		info, err := c.store.getBlockfile(p.fileNum)
+		if err != nil {
+			return recovered, err
+		}
+		if info != nil && !info.Discarded {
+			if err := c.markDiscarded(info); err != nil {
+				return recovered, err
+			}
+		}
+		if err := os.Remove(deriveBlockfilePath(p.blockfileDir, int(p.fileNum))); err != nil && !os.IsNotExist(err) {
+			return recovered, errors.Wrapf(err, "error deleting blockfile [%d]", p.fileNum)
+		}
+		if err := c.commitDiscard(p.fileNum); err != nil {
+			return recovered, err
+		}
+		recovered = append(recovered, p.fileNum)
+	}
+	return recovered, nil
+}
+
+func constructDiscardJournalKey(fileNum uint64) []byte {
+	return append([]byte{discardJournalKeyPrefix}, util.EncodeOrderPreservingVarUint64(fileNum)...)
+}
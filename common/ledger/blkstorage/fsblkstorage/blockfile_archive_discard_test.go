@@ -0,0 +1,140 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscardBlockfileRecovery(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	rootDir := arch.mgr.rootDir
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	require.NoError(t, arch.recordArchivedBlockfile(1, false))
+
+	// A completed discard leaves no journal entry
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(rootDir, info))
+	_, err = os.Stat(deriveBlockfilePath(rootDir, 0))
+	assert.True(t, os.IsNotExist(err))
+	recovered, err := arch.catalog.recoverDiscards()
+	require.NoError(t, err)
+	assert.Empty(t, recovered)
+
+	// Crash after the record has been marked as discarded, before the blockfile has been deleted
+	info, err = arch.catalog.getArchivedBlockfile(1)
+	require.NoError(t, err)
+	info.Discarded = true
+	b, err := proto.Marshal(info)
+	require.NoError(t, err)
+	batch := leveldbhelper.NewUpdateBatch()
+	batch.Put(constructArchivedBlockfileKey(1), b)
+	batch.Put(constructDiscardJournalKey(1), []byte(rootDir))
+	require.NoError(t, arch.catalog.db.WriteBatch(batch, true))
+	store.Shutdown()
+
+	// The discard is completed when the ledger is opened again
+	store, err = env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	_, err = os.Stat(deriveBlockfilePath(rootDir, 1))
+	assert.True(t, os.IsNotExist(err))
+	recovered, err = store.(*fsBlockStore).archiver.catalog.recoverDiscards()
+	require.NoError(t, err)
+	assert.Empty(t, recovered)
+	ranges, err := store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, uint64(0), ranges[0].FirstBlockNum)
+	assert.Equal(t, info.LastBlockNum, ranges[0].LastBlockNum)
+}
+
+func TestMinBlockAgeBeforeDiscard(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevIsArchiver, prevMinBlockAge := blockarchive.IsArchiver, blockarchive.MinBlockAgeBeforeDiscard
+	prevEach, prevKeep := blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.IsArchiver = true
+	// The archiving is triggered by the test only
+	blockarchive.NumBlockfileEachArchiving = 1000
+	defer func() {
+		blockarchive.IsArchiver, blockarchive.MinBlockAgeBeforeDiscard = prevIsArchiver, prevMinBlockAge
+		blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = prevEach, prevKeep
+		blockarchive.BlockStorePath = prevBlockStorePath
+	}()
+
+	// Blockfiles of 10 blocks after the genesis block
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[10:20] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	blockarchive.BlockStorePath = blockStorePath
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), "/blkstore"))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	blockfilePath := deriveBlockfilePath(arch.blockfileDir, 1)
+	// The notifications of the finalized blockfiles are not handled concurrently with the test
+	arch.stopArchivingAndWait()
+
+	// A blockfile whose blocks are too recent is archived but kept
+	blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = 1, 0
+	blockarchive.MinBlockAgeBeforeDiscard = time.Hour
+	arch.archiveChannelIfNecessary()
+	info, err := arch.catalog.getArchivedBlockfile(1)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.False(t, info.Discarded)
+	_, err = os.Stat(blockfilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, arch.checkpoint.nextBlockfileNum)
+	assert.True(t, arch.isUploaded(1))
+
+	// and discarded once they are old enough
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(blockfilePath, old, old))
+	arch.archiveChannelIfNecessary()
+	info, err = arch.catalog.getArchivedBlockfile(1)
+	require.NoError(t, err)
+	assert.True(t, info.Discarded)
+	_, err = os.Stat(blockfilePath)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, 2, arch.checkpoint.nextBlockfileNum)
+}
@@ -0,0 +1,53 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// verifyBeforeDiscard verifies an archived blockfile against the checksum recorded in the catalog before its
+// local copy is discarded, as sampled by blockarchive.DiscardVerificationOf: in full by downloading it, or with
+// the digest computed by the repository, which downloads the encrypted ones. The blockfiles archived before the checksums were recorded are not
+// verified. A mismatch is an incident reported to the audit log, and an error so that the local copy is kept.
+func (arch *blockfileArchiver) verifyBeforeDiscard(info *archive.ArchivedBlockfileInfo) error {
+	mode := blockarchive.DiscardVerificationOf(arch.chainID, info.BlockfileNo)
+	if mode == "" || info.Checksum == "" {
+		return nil
+	}
+	if mode == blockarchive.DiscardVerificationDigest && info.EncryptionKeyId != "" {
+		// The repository doesn't hold the keys of the encrypted blockfiles
+		mode = blockarchive.DiscardVerificationFull
+	}
+	start := time.Now()
+	var matched bool
+	var err error
+	if mode == blockarchive.DiscardVerificationDigest {
+		matched, err = matchesChecksumBy(nil, info, true)
+	} else {
+		sshConn, client, cerr := connectToRepo(arch.chainID)
+		if cerr != nil {
+			return errors.WithMessage(cerr, "error connecting to the repository")
+		}
+		defer sshConn.Close()
+		defer client.Close()
+		matched, err = matchesChecksumBy(client, info, false)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "error verifying archived blockfile [%d]", info.BlockfileNo)
+	}
+	if !matched {
+		loggerAudit.Errorf("[%s] Archived blockfile [%d] at %s doesn't match its checksum %s, its local copy is kept",
+			arch.chainID, info.BlockfileNo, info.Location, info.Checksum)
+		return errors.Errorf("archived blockfile [%d] doesn't match its checksum", info.BlockfileNo)
+	}
+	loggerDiscard.Debugw("Verified archived blockfile before its discard", append(archivedBlockfileLogFields(info),
+		"verification", mode, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))...)
+	return nil
+}
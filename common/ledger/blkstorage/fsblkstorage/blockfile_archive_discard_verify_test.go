@@ -0,0 +1,126 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBeforeDiscard(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+	defer func(prev func(string) (bool, int), prevAPIURL string) {
+		blockarchive.DiscardVerification, blockarchive.RepositoryAPIURL = prev, prevAPIURL
+	}(blockarchive.DiscardVerification, blockarchive.RepositoryAPIURL)
+	defer setTestEncryptionKeys(t, "", "key1")()
+
+	// The API of the repository computes the digests from the stored content
+	var mutex sync.Mutex
+	var verified []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, blockarchive.VerifyPath)
+		mutex.Lock()
+		verified = append(verified, p)
+		mutex.Unlock()
+		verification, err := server.VerifyBlockfile(p, r.URL.Query().Get("algorithm"), false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(verification)
+	}))
+	defer api.Close()
+
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	// Blockfiles [0-2] are archived and kept on the local file system, blockfile 2 encrypted with key1
+	arch := store.(*fsBlockStore).archiver
+	var locations []string
+	for fileNum := 0; fileNum < 3; fileNum++ {
+		if fileNum == 2 {
+			blockarchive.EncryptionKeyID = "key1"
+		}
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, false))
+		locations = append(locations, location)
+	}
+	blockarchive.EncryptionKeyID = ""
+	remotePath := func(location string) string { return filepath.Join(repoRootDir, location) }
+	corrupt := func(fileNum int) {
+		content, err := ioutil.ReadFile(remotePath(locations[fileNum]))
+		require.NoError(t, err)
+		content[len(content)-1] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(remotePath(locations[fileNum]), content, 0644))
+	}
+	discarded := func(fileNum int) bool {
+		_, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+		return os.IsNotExist(err)
+	}
+	// Blockfiles 0 and 2 are verified in full, blockfile 1 by the digest of the repository
+	blockarchive.DiscardVerification = func(string) (bool, int) { return true, 2 }
+	blockarchive.RepositoryAPIURL = api.URL
+
+	// A corrupted blockfile is not discarded, whichever the verification
+	corrupt(0)
+	corrupt(1)
+	assert.Error(t, arch.deleteArchivedBlockfile(0))
+	assert.Error(t, arch.deleteArchivedBlockfile(1))
+	for fileNum := 0; fileNum < 2; fileNum++ {
+		assert.False(t, discarded(fileNum))
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		require.NoError(t, err)
+		assert.False(t, info.Discarded)
+	}
+
+	// An intact blockfile is discarded, an encrypted one being downloaded to be verified since the repository
+	// doesn't hold its key
+	blockarchive.DiscardVerification = func(string) (bool, int) { return true, 0 }
+	require.NoError(t, arch.deleteArchivedBlockfile(2))
+	assert.True(t, discarded(2))
+	mutex.Lock()
+	assert.Equal(t, []string{locations[1]}, verified)
+	mutex.Unlock()
+
+	// The corrupted blockfiles are discarded once the verification is disabled
+	blockarchive.DiscardVerification = func(string) (bool, int) { return false, 2 }
+	require.NoError(t, arch.deleteArchivedBlockfile(0))
+	assert.True(t, discarded(0))
+}
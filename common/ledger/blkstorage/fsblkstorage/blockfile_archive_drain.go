@@ -0,0 +1,116 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// errTransferAborted is returned by the transfers aborted by the shutdown of the peer
+var errTransferAborted = errors.New("blockfile transfer aborted by the shutdown")
+
+// abortGracePeriod is how long the draining waits for the aborted transfers to clean up
+const abortGracePeriod = 5 * time.Second
+
+// transferTracker keeps track of the blockfiles being archived, so that the shutdown of the peer can
+// wait for them to finish instead of killing the transfers in the middle of a blockfile
+type transferTracker struct {
+	lock     sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+	aborted  chan struct{}
+}
+
+var transfers = newTransferTracker()
+
+func newTransferTracker() *transferTracker {
+	return &transferTracker{aborted: make(chan struct{})}
+}
+
+// begin registers the archiving of a blockfile. It returns false once the draining has started,
+// in which case the blockfile must be left for the next start of the peer.
+func (t *transferTracker) begin() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.draining {
+		return false
+	}
+	t.inFlight.Add(1)
+	return true
+}
+
+// end unregisters the archiving of a blockfile
+func (t *transferTracker) end() {
+	t.inFlight.Done()
+}
+
+// drain prevents the archiving of more blockfiles and waits up to timeout for the ones in flight.
+// The transfers still in flight are then aborted. It returns false if some were aborted.
+func (t *transferTracker) drain(timeout time.Duration) bool {
+	t.lock.Lock()
+	if t.draining {
+		t.lock.Unlock()
+		return true
+	}
+	t.draining = true
+	t.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+	}
+
+	close(t.aborted)
+	select {
+	case <-done:
+	case <-time.After(abortGracePeriod):
+		loggerUpload.Warnw("Aborted blockfile transfers did not complete", "gracePeriod", abortGracePeriod.String())
+	}
+	return false
+}
+
+// reader wraps the source of a transfer so that the transfer fails once aborted
+func (t *transferTracker) reader(r io.Reader) io.Reader {
+	return &abortableReader{r: r, aborted: t.aborted}
+}
+
+type abortableReader struct {
+	r       io.Reader
+	aborted <-chan struct{}
+}
+
+func (r *abortableReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.aborted:
+		return 0, errTransferAborted
+	default:
+	}
+	return r.r.Read(p)
+}
+
+// DrainTransfers is called on the shutdown of the peer. It stops the archiving of more blockfiles
+// and waits up to timeout for the blockfiles being archived. The transfers still in flight are then
+// aborted: their partial uploads are removed from the repository, but the ones recorded by a resume
+// token, and their archiving is resumed from the checkpoint of the archiver on the next start.
+// It returns false if some were aborted.
+func DrainTransfers(timeout time.Duration) bool {
+	loggerUpload.Infow("Draining blockfile transfers", "timeout", timeout.String())
+	if !transfers.drain(timeout) {
+		loggerUpload.Warnw("Aborted blockfile transfers still in flight after the drain timeout", "timeout", timeout.String())
+		return false
+	}
+	loggerUpload.Infow("Drained blockfile transfers")
+	return true
+}
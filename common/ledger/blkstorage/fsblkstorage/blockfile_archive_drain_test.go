@@ -0,0 +1,76 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTrackerDrain(t *testing.T) {
+	tracker := newTransferTracker()
+	require.True(t, tracker.begin())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		tracker.end()
+	}()
+	assert.True(t, tracker.drain(time.Minute))
+
+	// No more blockfile is archived once the draining has started
+	assert.False(t, tracker.begin())
+	assert.True(t, tracker.drain(time.Minute))
+}
+
+func TestTransferTrackerAbort(t *testing.T) {
+	tracker := newTransferTracker()
+	require.True(t, tracker.begin())
+	reader := tracker.reader(strings.NewReader("blockfile"))
+
+	aborted := make(chan struct{})
+	go func() {
+		<-tracker.aborted
+		_, err := ioutil.ReadAll(reader)
+		assert.Equal(t, errTransferAborted, err)
+		tracker.end()
+		close(aborted)
+	}()
+	assert.False(t, tracker.drain(10*time.Millisecond))
+	<-aborted
+}
+
+func TestSendBlockfileToRepoAborted(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevTransfers := transfers
+	transfers = newTransferTracker()
+	defer func() { transfers = prevTransfers }()
+
+	env := newTestEnv(t, NewConf(testPath(), 0, server.Addr().String(), "/blkstore"))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	arch := store.(*fsBlockStore).archiver
+
+	close(transfers.aborted)
+	location := deriveArchivedBlockfilePath(arch.mgr.rootDir, 0)
+	_, err = sendBlockfileToRepo(arch.mgr.rootDir, 0, location)
+	assert.Equal(t, errTransferAborted, err)
+
+	// The partial upload is not left on the repository
+	sshConn, client, err := connectToRepo(arch.chainID)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	_, err = client.Stat(location)
+	assert.Error(t, err)
+	_, err = client.Stat(location + uploadingSuffix)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs/webhdfstest"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveToHDFS(t *testing.T) {
+	server, cleanup := startConfiguredTestRepository(t, func(*repository.Config) {})
+	defer cleanup()
+	hdfsRootDir, err := ioutil.TempDir("", "hdfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(hdfsRootDir)
+	namenode := webhdfstest.NewServer(hdfsRootDir)
+	defer namenode.Close()
+	namenode.User = "fabric"
+	prevHDFS := blockarchive.HDFS
+	defer func() { blockarchive.HDFS = prevHDFS }()
+	blockarchive.HDFS = &webhdfs.Config{Namenodes: []string{namenode.URL}, BasePath: "/data/fabric", User: "fabric"}
+	blockarchive.SetChannelRepository("hdfsLedger", &blockarchive.ChannelRepository{Type: blockarchive.RepositoryTypeHDFS})
+	defer blockarchive.SetChannelRepository("hdfsLedger", nil)
+	blockStorePath := testPath()
+	prevSigner, prevBlockStorePath := blockarchive.ManifestSigner, blockarchive.BlockStorePath
+	defer func() { blockarchive.ManifestSigner, blockarchive.BlockStorePath = prevSigner, prevBlockStorePath }()
+	blockarchive.ManifestSigner, blockarchive.BlockStorePath = nil, blockStorePath
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("hdfsLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	// The blockfile is uploaded to HDFS under the base path, and its local copy is discarded
+	arch := store.(*fsBlockStore).archiver
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+	_, err = os.Stat(filepath.Join(hdfsRootDir, "data", "fabric", location))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(hdfsRootDir, "data", "fabric", location+uploadingSuffix))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(deriveBlockfilePath(arch.blockfileDir, 0))
+	assert.True(t, os.IsNotExist(err))
+
+	// The catalog records the cluster as the repository, and the blocks are retrieved from it
+	info, err := store.GetArchiveCatalog().GetArchiveLocation(5)
+	require.NoError(t, err)
+	assert.Equal(t, blockarchive.HDFS.URL(), info.Repository)
+	opened := namenode.Operations()["OPEN"]
+	block, err := store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[5], block))
+	assert.True(t, namenode.Operations()["OPEN"] > opened)
+
+	// The archived blockfile is verified by downloading it from the cluster
+	sshConn, client, err := connectToRepo("hdfsLedger")
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	matched, err := matchesChecksum(client, info)
+	require.NoError(t, err)
+	assert.True(t, matched)
+
+	// The cluster is probed with the credentials of the peer
+	assert.NoError(t, ProbeRepository(blockarchive.HDFS.URL()))
+	namenode.User = "other"
+	assert.Error(t, ProbeRepository(blockarchive.HDFS.URL()))
+}
@@ -0,0 +1,149 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"math"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+)
+
+// Status of a listed blockfile on the repository
+const (
+	RepositoryStatusPresent = "present"
+	RepositoryStatusMissing = "missing"
+)
+
+// ArchiveListFilter selects the archived blockfiles of the catalog listed by ListArchivedBlockfiles
+type ArchiveListFilter struct {
+	// FromBlock and ToBlock select the blockfiles holding blocks of the range. ToBlock is math.MaxUint64
+	// for no upper bound.
+	FromBlock, ToBlock uint64
+	// Since and Until select the blockfiles archived within the period, the zero time for no bound.
+	// The blockfiles archived before their archive time was recorded in the catalog are left out
+	// when either is set.
+	Since, Until time.Time
+	// StartAfter is the number of the blockfile after which the listing starts, -1 from the first one
+	StartAfter int64
+	// Limit is the maximum number of blockfiles listed, 0 for no limit
+	Limit int
+}
+
+// AllArchivedBlockfiles is the filter selecting all the archived blockfiles
+func AllArchivedBlockfiles() *ArchiveListFilter {
+	return &ArchiveListFilter{ToBlock: math.MaxUint64, StartAfter: -1}
+}
+
+// matches tells if the archived blockfile is selected by the filter, regardless of the paging
+func (f *ArchiveListFilter) matches(info *archive.ArchivedBlockfileInfo) bool {
+	if info.LastBlockNum < f.FromBlock || info.FirstBlockNum > f.ToBlock {
+		return false
+	}
+	if f.Since.IsZero() && f.Until.IsZero() {
+		return true
+	}
+	archivedAt, err := ptypes.Timestamp(info.ArchivedAt)
+	if err != nil {
+		return false
+	}
+	return !archivedAt.Before(f.Since) && (f.Until.IsZero() || !archivedAt.After(f.Until))
+}
+
+// ListedBlockfile is an archived blockfile listed by ListArchivedBlockfiles
+type ListedBlockfile struct {
+	BlockfileNo   uint64 `json:"blockfileNo"`
+	FirstBlockNum uint64 `json:"firstBlockNum"`
+	LastBlockNum  uint64 `json:"lastBlockNum"`
+	Repository    string `json:"repository"`
+	Location      string `json:"location"`
+	Checksum      string `json:"checksum,omitempty"`
+	// EncryptionKeyID is the ID of the key the blockfile is encrypted with, empty if it is not encrypted
+	EncryptionKeyID string `json:"encryptionKeyId,omitempty"`
+	// Discarded tells whether the local copy of the blockfile has been discarded
+	Discarded bool `json:"discarded"`
+	// ArchivedAt is the time the blockfile was archived, nil if not recorded
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	// RepositoryStatus is the status of the blockfile on the repository, RepositoryStatusPresent or
+	// RepositoryStatusMissing, when the listing is checked against the repository
+	RepositoryStatus string `json:"repositoryStatus,omitempty"`
+}
+
+// ArchiveListing is a page of the archived blockfiles of a ledger
+type ArchiveListing struct {
+	LedgerID   string             `json:"channel"`
+	Blockfiles []*ListedBlockfile `json:"blockfiles"`
+	// NextStartAfter is the StartAfter of the next page, -1 if this page is the last one
+	NextStartAfter int64 `json:"nextStartAfter"`
+}
+
+// ListArchivedBlockfiles lists the archived blockfiles of the archive catalog of a ledger stored in blockStorePath
+// which are selected by the filter, in the order of their numbers. When check is set, the presence of each listed
+// blockfile on the repository is checked. It must not be called while the peer is running.
+func ListArchivedBlockfiles(blockStorePath, ledgerID string, filter *ArchiveListFilter, check bool) (*ArchiveListing, error) {
+	state, err := ExportArchiveCatalog(blockStorePath, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	listing := &ArchiveListing{LedgerID: ledgerID, Blockfiles: []*ListedBlockfile{}, NextStartAfter: -1}
+	for _, info := range state.Blockfiles {
+		if int64(info.BlockfileNo) <= filter.StartAfter || !filter.matches(info) {
+			continue
+		}
+		if filter.Limit > 0 && len(listing.Blockfiles) == filter.Limit {
+			listing.NextStartAfter = int64(listing.Blockfiles[len(listing.Blockfiles)-1].BlockfileNo)
+			break
+		}
+		listing.Blockfiles = append(listing.Blockfiles, newListedBlockfile(info))
+	}
+	if check && len(listing.Blockfiles) > 0 {
+		if err := checkListedBlockfiles(ledgerID, listing.Blockfiles); err != nil {
+			return nil, err
+		}
+	}
+	return listing, nil
+}
+
+func newListedBlockfile(info *archive.ArchivedBlockfileInfo) *ListedBlockfile {
+	listed := &ListedBlockfile{
+		BlockfileNo:     info.BlockfileNo,
+		FirstBlockNum:   info.FirstBlockNum,
+		LastBlockNum:    info.LastBlockNum,
+		Repository:      info.Repository,
+		Location:        info.Location,
+		Checksum:        info.Checksum,
+		EncryptionKeyID: info.EncryptionKeyId,
+		Discarded:       info.Discarded,
+	}
+	if archivedAt, err := ptypes.Timestamp(info.ArchivedAt); err == nil {
+		archivedAt = archivedAt.UTC()
+		listed.ArchivedAt = &archivedAt
+	}
+	return listed
+}
+
+// checkListedBlockfiles records whether the listed blockfiles are on the repository
+func checkListedBlockfiles(ledgerID string, blockfiles []*ListedBlockfile) error {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return err
+	}
+	defer sshConn.Close()
+	defer client.Close()
+	for _, listed := range blockfiles {
+		_, err := client.Stat(listed.Location)
+		switch {
+		case err == nil:
+			listed.RepositoryStatus = RepositoryStatusPresent
+		case os.IsNotExist(err):
+			listed.RepositoryStatus = RepositoryStatusMissing
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListArchivedBlockfiles(t *testing.T) {
+	var repoRootDir string
+	_, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+
+	ledgerid := "testLedger"
+	conf := NewConf(testPath(), 0, "", "")
+	env := newTestEnv(t, conf)
+	defer env.Cleanup()
+	w := newTestBlockfileWrapper(env, ledgerid)
+	w.addBlocks(testutil.ConstructTestBlocks(t, 10))
+	// Blockfile 0 has been archived before the archive time was recorded
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	catalog := w.blockfileMgr.archiveConf.catalog
+	for fileNum := uint64(0); fileNum < 4; fileNum++ {
+		info := &archive.ArchivedBlockfileInfo{
+			ChannelID: ledgerid, BlockfileNo: fileNum, FirstBlockNum: fileNum * 10, LastBlockNum: fileNum*10 + 9,
+			Location: fmt.Sprintf("/blkstore/testLedger/blockfile_%06d", fileNum), Discarded: fileNum < 2,
+		}
+		if fileNum > 0 {
+			archivedAt, err := ptypes.TimestampProto(day.AddDate(0, 0, int(fileNum)))
+			require.NoError(t, err)
+			info.ArchivedAt = archivedAt
+		}
+		require.NoError(t, catalog.recordArchivedBlockfile(info))
+	}
+	w.close()
+	env.provider.Close()
+
+	blockfileNums := func(listing *ArchiveListing) []uint64 {
+		nums := []uint64{}
+		for _, listed := range listing.Blockfiles {
+			nums = append(nums, listed.BlockfileNo)
+		}
+		return nums
+	}
+
+	listing, err := ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, AllArchivedBlockfiles(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 3}, blockfileNums(listing))
+	assert.Equal(t, int64(-1), listing.NextStartAfter)
+	assert.Nil(t, listing.Blockfiles[0].ArchivedAt)
+	require.NotNil(t, listing.Blockfiles[1].ArchivedAt)
+	assert.Equal(t, day.AddDate(0, 0, 1), *listing.Blockfiles[1].ArchivedAt)
+	assert.True(t, listing.Blockfiles[1].Discarded)
+	assert.Empty(t, listing.Blockfiles[1].RepositoryStatus)
+
+	// The blockfiles holding blocks of the range
+	filter := AllArchivedBlockfiles()
+	filter.FromBlock, filter.ToBlock = 15, 20
+	listing, err = ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, blockfileNums(listing))
+
+	// The blockfile without archive time is left out by a time filter
+	filter = AllArchivedBlockfiles()
+	filter.Until = day.AddDate(0, 0, 2)
+	listing, err = ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, blockfileNums(listing))
+	filter.Since, filter.Until = day.AddDate(0, 0, 2), time.Time{}
+	listing, err = ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 3}, blockfileNums(listing))
+
+	// Paging
+	filter = AllArchivedBlockfiles()
+	filter.Limit = 3
+	listing, err = ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2}, blockfileNums(listing))
+	assert.Equal(t, int64(2), listing.NextStartAfter)
+	filter.StartAfter = listing.NextStartAfter
+	listing, err = ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, filter, false)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, blockfileNums(listing))
+	assert.Equal(t, int64(-1), listing.NextStartAfter)
+
+	// Only blockfile 1 is on the repository
+	remotePath := filepath.Join(repoRootDir, "blkstore", ledgerid, "blockfile_000001")
+	require.NoError(t, os.MkdirAll(filepath.Dir(remotePath), 0755))
+	require.NoError(t, ioutil.WriteFile(remotePath, []byte("blocks"), 0644))
+	filter = AllArchivedBlockfiles()
+	filter.ToBlock = 19
+	listing, err = ListArchivedBlockfiles(conf.blockStorageDir, ledgerid, filter, true)
+	require.NoError(t, err)
+	require.Len(t, listing.Blockfiles, 2)
+	assert.Equal(t, RepositoryStatusMissing, listing.Blockfiles[0].RepositoryStatus)
+	assert.Equal(t, RepositoryStatusPresent, listing.Blockfiles[1].RepositoryStatus)
+
+	_, err = ListArchivedBlockfiles(conf.blockStorageDir, "unknown", AllArchivedBlockfiles(), false)
+	assert.EqualError(t, err, "ledger [unknown] not found in "+conf.blockStorageDir)
+}
@@ -0,0 +1,107 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestsDir is the name of the directory containing the archive manifests of all channels
+	ManifestsDir = "manifests"
+)
+
+// publishManifest stores the summary of the blocks of a blockfile which has just been archived next to
+// it in the repository. It then produces the signed manifest of the archive operation and stores it both
+// on the local file system and in the repository.
+func (arch *blockfileArchiver) publishManifest(fileNum int, location string) error {
+	summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+	if err != nil {
+		return err
+	}
+	blockfileHash, err := blockarchive.ComputeBlockfileHash(deriveBlockfilePath(arch.mgr.rootDir, fileNum))
+	if err != nil {
+		return err
+	}
+	blockfileSummary := blockarchive.NewBlockfileSummary(arch.chainID, uint64(fileNum), summary.firstBlockNum, summary.blockHashes, blockfileHash)
+	summaryBytes, err := proto.Marshal(blockfileSummary)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling blockfile summary")
+	}
+	// The summary and the manifest written by a previous attempt are kept if the blockfile is locked
+	summaryPath := arch.remoteManifestPath(fileNum, location, blockarchive.SummarySuffix)
+	if err := sendManifestToRepo(arch.chainID, summaryPath, summaryBytes); err != nil && !isKeptByObjectLock(arch.chainID, location, summaryPath) {
+		return errors.Wrapf(err, "error sending summary of blockfile [%d] to repository", fileNum)
+	}
+
+	signer := blockarchive.ManifestSigner
+	if signer == nil {
+		loggerArchive.Warningf("[%s] No signer configured, skip producing the manifest of blockfile [%d]", arch.chainID, fileNum)
+		return nil
+	}
+	manifest := arch.createManifest(blockfileSummary, location)
+	signed, err := blockarchive.SignManifest(manifest, signer)
+	if err != nil {
+		return err
+	}
+	signedBytes, err := proto.Marshal(signed)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling signed archive manifest")
+	}
+
+	if err := arch.storeManifest(fileNum, signedBytes); err != nil {
+		return err
+	}
+	manifestPath := arch.remoteManifestPath(fileNum, location, blockarchive.ManifestSuffix)
+	if err := sendManifestToRepo(arch.chainID, manifestPath, signedBytes); err != nil && !isKeptByObjectLock(arch.chainID, location, manifestPath) {
+		return errors.Wrapf(err, "error sending manifest of blockfile [%d] to repository", fileNum)
+	}
+	return nil
+}
+
+// createManifest builds the manifest of the local blockfile which has just been archived from its summary
+func (arch *blockfileArchiver) createManifest(summary *archive.BlockfileSummary, location string) *archive.ArchiveManifest {
+	return &archive.ArchiveManifest{
+		ChannelID:      summary.ChannelID,
+		BlockfileNo:    summary.BlockfileNo,
+		FirstBlockNum:  summary.FirstBlockNum,
+		LastBlockNum:   summary.LastBlockNum,
+		BlockfileHash:  summary.BlockfileHash,
+		FirstBlockHash: summary.FirstBlockHash,
+		LastBlockHash:  summary.LastBlockHash,
+		Timestamp:      ptypes.TimestampNow(),
+		Repository:     blockarchive.RepositoryURLOf(arch.chainID),
+		Location:       location,
+		MerkleRoot:     summary.MerkleRoot,
+	}
+}
+
+// storeManifest writes the signed manifest on the local file system
+func (arch *blockfileArchiver) storeManifest(fileNum int, signedBytes []byte) error {
+	manifestPath := deriveManifestPath(arch.mgr.conf, arch.chainID, fileNum)
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		return errors.Wrapf(err, "error creating directory for manifest %s", manifestPath)
+	}
+	if err := ioutil.WriteFile(manifestPath, signedBytes, 0644); err != nil {
+		return errors.Wrapf(err, "error writing manifest %s", manifestPath)
+	}
+	loggerArchive.Infof("[%s] Stored manifest of blockfile [%d] at %s", arch.chainID, fileNum, manifestPath)
+	return nil
+}
+
+// deriveManifestPath returns the path to the manifest of a blockfile on the local file system
+func deriveManifestPath(conf *Conf, chainID string, fileNum int) string {
+	return filepath.Join(conf.blockStorageDir, ManifestsDir, chainID,
+		blockfilePrefix+fmt.Sprintf("%06d", fileNum)+blockarchive.ManifestSuffix)
+}
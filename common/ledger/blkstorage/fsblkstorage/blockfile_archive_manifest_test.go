@@ -0,0 +1,84 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockManifestSigner struct{}
+
+func (s *mockManifestSigner) Sign(message []byte) ([]byte, error) { return []byte("signature"), nil }
+func (s *mockManifestSigner) Serialize() ([]byte, error)          { return []byte("creator"), nil }
+
+func TestArchiveManifest(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+	blocks := testutil.ConstructTestBlocks(t, 10)
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	prevSigner := blockarchive.ManifestSigner
+	blockarchive.ManifestSigner = &mockManifestSigner{}
+	defer func() { blockarchive.ManifestSigner = prevSigner }()
+	location := "/blkstore/testLedger/0-9.blk"
+	require.NoError(t, arch.publishManifest(0, location))
+
+	storedBytes, err := ioutil.ReadFile(deriveManifestPath(arch.mgr.conf, "testLedger", 0))
+	require.NoError(t, err)
+	stored := &archive.SignedArchiveManifest{}
+	require.NoError(t, proto.Unmarshal(storedBytes, stored))
+	assert.Equal(t, []byte("signature"), stored.Signature)
+	assert.Equal(t, []byte("creator"), stored.Creator)
+	manifest := &archive.ArchiveManifest{}
+	require.NoError(t, proto.Unmarshal(stored.Manifest, manifest))
+	assert.Equal(t, "testLedger", manifest.ChannelID)
+	assert.Equal(t, location, manifest.Location)
+	assert.Equal(t, uint64(0), manifest.FirstBlockNum)
+	assert.Equal(t, uint64(9), manifest.LastBlockNum)
+	assert.Equal(t, protoutil.BlockHeaderHash(blocks[0].Header), manifest.FirstBlockHash)
+	assert.Equal(t, protoutil.BlockHeaderHash(blocks[9].Header), manifest.LastBlockHash)
+	assert.NotNil(t, manifest.Timestamp)
+	assert.NoError(t, blockarchive.VerifyBlockfileAgainstManifest(manifest, deriveBlockfilePath(arch.mgr.rootDir, 0)))
+
+	// The summary of the blocks is stored next to the blockfile in the repository
+	sshConn, client, err := connectToRepo(arch.chainID)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	for _, path := range []string{location + blockarchive.ManifestSuffix, location + blockarchive.SummarySuffix} {
+		_, err := client.Stat(path)
+		require.NoError(t, err)
+	}
+	f, err := client.Open(location + blockarchive.SummarySuffix)
+	require.NoError(t, err)
+	summaryBytes, err := ioutil.ReadAll(f)
+	f.Close()
+	require.NoError(t, err)
+	summary := &archive.BlockfileSummary{}
+	require.NoError(t, proto.Unmarshal(summaryBytes, summary))
+	require.Len(t, summary.BlockHashes, 10)
+	for i, block := range blocks {
+		assert.NoError(t, blockarchive.VerifyBlockHeaderAgainstSummary(summary, block.Header), "block %d", i)
+	}
+	assert.NotNil(t, manifest.MerkleRoot)
+	assert.NoError(t, blockarchive.VerifySummaryAgainstManifest(summary, manifest))
+}
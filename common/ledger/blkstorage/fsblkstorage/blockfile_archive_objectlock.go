@@ -0,0 +1,103 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// readObjectLock returns the lock of the archived blockfile at location on the repository, nil if it is not locked
+func readObjectLock(client repositoryClient, location string) (*blockarchive.ObjectLock, error) {
+	file, err := client.Open(location + blockarchive.ObjectLockSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the object lock of %s", location)
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the object lock of %s", location)
+	}
+	return blockarchive.ParseObjectLock(b)
+}
+
+// verifyObjectLock checks that the repository has locked the archived blockfile at location, of the size
+// of the local blockfile, for at least ObjectLockMinRetention, before the local blockfile is discarded
+func verifyObjectLock(ledgerID, location string, size int64) error {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return err
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	lock, err := readObjectLock(client, location)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return errors.Errorf("%s is not locked on the repository", location)
+	}
+	if err := lock.Verify(size, blockarchive.ObjectLockMinRetention, time.Now()); err != nil {
+		return errors.WithMessagef(err, "invalid object lock of %s", location)
+	}
+	return nil
+}
+
+// isLockedBlockfileStored returns whether the blockfile at dstFilePath on the repository has been locked
+// by a previous attempt to upload the local blockfile. Since a locked blockfile cannot be overwritten,
+// its content must then be the one of the local blockfile.
+func isLockedBlockfileStored(client repositoryClient, dstFilePath, srcFilePath string) (bool, error) {
+	lock, err := readObjectLock(client, dstFilePath)
+	if err != nil || lock == nil || !lock.IsActive(time.Now()) {
+		return false, err
+	}
+	file, err := client.Open(dstFilePath + blockarchive.ChecksumSuffix)
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading the checksum of locked blockfile %s", dstFilePath)
+	}
+	content, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading the checksum of locked blockfile %s", dstFilePath)
+	}
+	expected, err := blockarchive.ParseChecksum(string(content))
+	if err != nil {
+		return false, err
+	}
+	actual, err := blockarchive.ComputeBlockfileChecksum(srcFilePath, expected.Algorithm)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(expected.Digest, actual.Digest) {
+		return false, errors.Errorf("%s is locked on the repository with another content: %s",
+			dstFilePath, &blockarchive.ChecksumMismatchError{Expected: expected, Actual: actual})
+	}
+	return true, nil
+}
+
+// isKeptByObjectLock returns whether the file at path, attached to the archived blockfile at location,
+// has been written by a previous attempt and cannot be overwritten since the blockfile is locked
+func isKeptByObjectLock(ledgerID, location, path string) bool {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return false
+	}
+	defer sshConn.Close()
+	defer client.Close()
+	if _, err := client.Stat(path); err != nil {
+		return false
+	}
+	lock, err := readObjectLock(client, location)
+	return err == nil && lock != nil && lock.IsActive(time.Now())
+}
@@ -0,0 +1,75 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectLockVerifiedBeforeDiscard(t *testing.T) {
+	_, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) {
+		config.ObjectLock = repository.ObjectLockConfig{Retention: time.Hour}
+	})
+	defer cleanup()
+	blockStorePath := testPath()
+	prevRequired, prevMinRetention := blockarchive.ObjectLockRequired, blockarchive.ObjectLockMinRetention
+	prevBlockStorePath, prevIsClient := blockarchive.BlockStorePath, blockarchive.IsClient
+	defer func() {
+		blockarchive.ObjectLockRequired, blockarchive.ObjectLockMinRetention = prevRequired, prevMinRetention
+		blockarchive.BlockStorePath, blockarchive.IsClient = prevBlockStorePath, prevIsClient
+	}()
+	blockarchive.ObjectLockRequired, blockarchive.ObjectLockMinRetention = true, 2*time.Hour
+	blockarchive.BlockStorePath, blockarchive.IsClient = blockStorePath, true
+
+	// Blockfile 0 holds 10 blocks, and is finalized by the rollover to blockfile 1
+	blocks := testutil.ConstructTestBlocks(t, 11)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	blockfilePath := deriveBlockfilePath(arch.blockfileDir, 0)
+
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.publishManifest(0, location))
+	// The upload and the publication of the summary are retried after a restart, the locked blockfile is kept
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.publishManifest(0, location))
+
+	// The blockfile is not locked for long enough to be discarded
+	err = arch.SetBlockfileArchived(0, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "less than 2h0m0s from now")
+	_, err = os.Stat(blockfilePath)
+	require.NoError(t, err)
+
+	blockarchive.ObjectLockMinRetention = 30 * time.Minute
+	require.NoError(t, arch.SetBlockfileArchived(0, true))
+	_, err = os.Stat(blockfilePath)
+	assert.True(t, os.IsNotExist(err))
+}
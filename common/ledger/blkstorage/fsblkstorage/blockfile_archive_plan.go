@@ -0,0 +1,94 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ArchivePlan is the projection of the archiving of the blockfiles of a ledger on the local file system,
+// once the archiver has caught up with the blockfiles which are eligible for archiving
+type ArchivePlan struct {
+	LedgerID string
+	// The number of blockfiles archived on each archiving opportunity, as bytes of blockfiles of the
+	// current maximum size when the sizes of the blockfiles differ
+	Each int
+	// The least number of blockfiles kept on the local file system, as bytes like Each
+	Keep int
+
+	// The blockfiles currently on the local file system, including the one being written
+	NumLocalBlockfiles int
+	LocalSize          int64
+
+	// The blockfiles which would be archived and discarded
+	NumToArchive       int
+	SizeToArchive      int64
+	FirstToArchive     int
+	LastToArchive      int
+	ProjectedLocalSize int64
+
+	// The local disk usage of the blockfiles in the steady state, between two archiving opportunities
+	MinSteadyLocalSize int64
+	MaxSteadyLocalSize int64
+}
+
+// PlanArchiving projects the archiving of the blockfiles of a ledger stored in blockStorePath, when
+// each blockfiles of maxBlockfileSize are archived at once as soon as more than each+keep complete
+// blockfiles are on the local file system, the blockfiles being counted by their sizes.
+// The first blockfile and the one being written are never archived.
+func PlanArchiving(blockStorePath, ledgerID string, each, keep, maxBlockfileSize int) (*ArchivePlan, error) {
+	if each <= 0 {
+		return nil, errors.Errorf("the number of blockfiles archived at once must be positive, got %d", each)
+	}
+	if keep < 0 {
+		return nil, errors.Errorf("the number of blockfiles kept must not be negative, got %d", keep)
+	}
+	dir := filepath.Join(blockStorePath, ChainsDir, ledgerID)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	fileNums, sizes, err := listLocalBlockfiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ArchivePlan{
+		LedgerID:           ledgerID,
+		Each:               each,
+		Keep:               keep,
+		NumLocalBlockfiles: len(fileNums),
+		FirstToArchive:     -1,
+		LastToArchive:      -1,
+		MinSteadyLocalSize: int64(keep+1) * int64(maxBlockfileSize),
+		MaxSteadyLocalSize: int64(each+keep+1) * int64(maxBlockfileSize),
+	}
+	var candidates []int
+	for i, fileNum := range fileNums {
+		plan.LocalSize += sizes[fileNum]
+		if fileNum > 0 && i < len(fileNums)-1 {
+			candidates = append(candidates, fileNum)
+		}
+	}
+
+	for {
+		batch := selectArchiveBatch(candidates[plan.NumToArchive:], sizes, each, keep, maxBlockfileSize)
+		if len(batch) == 0 {
+			break
+		}
+		for _, fileNum := range batch {
+			plan.SizeToArchive += sizes[fileNum]
+		}
+		plan.NumToArchive += len(batch)
+	}
+	if plan.NumToArchive > 0 {
+		plan.FirstToArchive = candidates[0]
+		plan.LastToArchive = candidates[plan.NumToArchive-1]
+	}
+	plan.ProjectedLocalSize = plan.LocalSize - plan.SizeToArchive
+	return plan, nil
+}
@@ -0,0 +1,96 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanArchiving(t *testing.T) {
+	blockStorePath, err := ioutil.TempDir("", "archiveplan")
+	require.NoError(t, err)
+	defer os.RemoveAll(blockStorePath)
+
+	dir := filepath.Join(blockStorePath, ChainsDir, "testLedger")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ioutil.WriteFile(deriveBlockfilePath(dir, i), make([]byte, 190+i), 0644))
+	}
+
+	plan, err := PlanArchiving(blockStorePath, "testLedger", 3, 2, 200)
+	require.NoError(t, err)
+	assert.Equal(t, &ArchivePlan{
+		LedgerID:           "testLedger",
+		Each:               3,
+		Keep:               2,
+		NumLocalBlockfiles: 10,
+		LocalSize:          1945,
+		NumToArchive:       6,
+		SizeToArchive:      1161,
+		FirstToArchive:     1,
+		LastToArchive:      6,
+		ProjectedLocalSize: 784,
+		MinSteadyLocalSize: 600,
+		MaxSteadyLocalSize: 1200,
+	}, plan)
+
+	// Nothing to archive while few blockfiles are on the local file system
+	plan, err = PlanArchiving(blockStorePath, "testLedger", 3, 7, 200)
+	require.NoError(t, err)
+	assert.Equal(t, 0, plan.NumToArchive)
+	assert.Equal(t, -1, plan.FirstToArchive)
+	assert.Equal(t, plan.LocalSize, plan.ProjectedLocalSize)
+
+	// The first blockfile and the one being written are never archived
+	plan, err = PlanArchiving(blockStorePath, "testLedger", 2, 0, 200)
+	require.NoError(t, err)
+	assert.Equal(t, 8, plan.NumToArchive)
+	assert.Equal(t, 8, plan.LastToArchive)
+
+	_, err = PlanArchiving(blockStorePath, "testLedger", 0, 2, 200)
+	assert.EqualError(t, err, "the number of blockfiles archived at once must be positive, got 0")
+	_, err = PlanArchiving(blockStorePath, "unknown", 3, 2, 200)
+	assert.Contains(t, err.Error(), "ledger [unknown] not found")
+}
+
+func TestPlanArchivingHeterogeneousBlockfiles(t *testing.T) {
+	blockStorePath, err := ioutil.TempDir("", "archiveplan")
+	require.NoError(t, err)
+	defer os.RemoveAll(blockStorePath)
+
+	// The blockfiles 0 to 3 were written with a maximum size of 400, the next ones with 100,
+	// and the maximum size is now 200
+	dir := filepath.Join(blockStorePath, ChainsDir, "testLedger")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for i := 0; i <= 10; i++ {
+		size := 95
+		if i <= 3 {
+			size = 390
+		}
+		require.NoError(t, ioutil.WriteFile(deriveBlockfilePath(dir, i), make([]byte, size), 0644))
+	}
+
+	plan, err := PlanArchiving(blockStorePath, "testLedger", 2, 1, 200)
+	require.NoError(t, err)
+	// The large blockfiles are archived one at a time and the small ones four at a time,
+	// the size of two blockfiles of the current maximum size
+	assert.Equal(t, 7, plan.NumToArchive)
+	assert.Equal(t, int64(3*390+4*95), plan.SizeToArchive)
+	assert.Equal(t, 1, plan.FirstToArchive)
+	assert.Equal(t, 7, plan.LastToArchive)
+
+	assert.Equal(t, []int{1}, selectArchiveBatch([]int{1, 2, 3, 4, 5, 6, 7, 8, 9}, map[int]int64{
+		1: 390, 2: 390, 3: 390, 4: 95, 5: 95, 6: 95, 7: 95, 8: 95, 9: 95}, 2, 1, 200))
+	assert.Equal(t, []int{4, 5, 6, 7}, selectArchiveBatch([]int{4, 5, 6, 7, 8, 9}, map[int]int64{
+		4: 95, 5: 95, 6: 95, 7: 95, 8: 95, 9: 95}, 2, 1, 200))
+	// Not enough bytes beyond the kept ones
+	assert.Nil(t, selectArchiveBatch([]int{8, 9}, map[int]int64{8: 95, 9: 95}, 2, 1, 200))
+}
@@ -0,0 +1,172 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// verifyingReader verifies the content of a blockfile against its checksum once it has been read entirely.
+// A mismatch is returned in place of io.EOF, so that a corrupted blockfile is never served as complete.
+type verifyingReader struct {
+	io.ReadCloser
+	verifier *blockarchive.ChecksumWriter
+	expected *blockarchive.Checksum
+}
+
+func newVerifyingReader(reader io.ReadCloser, expected *blockarchive.Checksum) (*verifyingReader, error) {
+	verifier, err := blockarchive.NewChecksumWriter(expected.Algorithm)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &verifyingReader{ReadCloser: reader, verifier: verifier, expected: expected}, nil
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.verifier.Write(p[:n])
+	if err == io.EOF {
+		if verifyErr := r.verifier.Verify(r.expected); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+// ErrBlockfileNotFound is returned when a blockfile is neither on the local file system nor in the repository
+var ErrBlockfileNotFound = errors.New("blockfile not found")
+
+// ErrEncodedBlockfileRange is returned when a byte range is requested of an archived blockfile stored compressed or
+// encrypted on the repository. Its object is a single stream which cannot be read from an offset, so that serving
+// a range would download and decode the entire blockfile: the blockfile is to be retrieved entirely instead.
+var ErrEncodedBlockfileRange = errors.New("byte ranges of the compressed or encrypted archived blockfiles are not served")
+
+// OpenBlockfileForProxy opens a blockfile of a ledger of the archiver peer, to serve it to the client peers
+// of the organization. The local blockfile is opened if present, otherwise the archived blockfile is read
+// from the repository through the retrieval scheduler.
+func OpenBlockfileForProxy(ledgerID string, fileNum int, catalog blockarchive.Catalog) (io.ReadCloser, error) {
+	file, err := openLocalBlockfileForProxy(ledgerID, fileNum)
+	if file != nil || err != nil {
+		return file, err
+	}
+	remote, info, err := openArchivedBlockfileForProxy(ledgerID, fileNum, catalog, false)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := remoteChecksum(remote.remote.client, info.Location, info.Checksum)
+	if err != nil {
+		remote.Close()
+		return nil, errors.WithMessagef(err, "error reading the checksum of %s", info.Location)
+	}
+	if expected == nil {
+		return remote, nil
+	}
+	return newVerifyingReader(remote, expected)
+}
+
+// OpenBlockfileRangeForProxy opens a blockfile of a ledger of the archiver peer like OpenBlockfileForProxy,
+// positioned at offset, to serve a byte range of it to a client peer reading a single block. It returns the
+// size of the blockfile. The checksum of the blockfile is not verified since it is not read entirely. It returns
+// ErrEncodedBlockfileRange if the blockfile is archived compressed or encrypted.
+func OpenBlockfileRangeForProxy(ledgerID string, fileNum int, offset int64, catalog blockarchive.Catalog) (io.ReadCloser, int64, error) {
+	var blockfile interface {
+		io.ReadSeeker
+		io.Closer
+	}
+	var size int64
+	if file, err := openLocalBlockfileForProxy(ledgerID, fileNum); err != nil {
+		return nil, 0, err
+	} else if file != nil {
+		fileInfo, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, errors.Wrapf(err, "error reading blockfile [%d] of ledger [%s]", fileNum, ledgerID)
+		}
+		blockfile, size = file, fileInfo.Size()
+	} else {
+		remote, info, err := openArchivedBlockfileForProxy(ledgerID, fileNum, catalog, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		fileInfo, err := remote.file.Stat()
+		if err != nil {
+			remote.Close()
+			return nil, 0, errors.Wrapf(err, "error reading archived blockfile %s", info.Location)
+		}
+		blockfile, size = remote, fileInfo.Size()
+	}
+	if _, err := blockfile.Seek(offset, io.SeekStart); err != nil {
+		blockfile.Close()
+		return nil, 0, errors.Wrapf(err, "error seeking blockfile [%d] of ledger [%s] to offset [%d]", fileNum, ledgerID, offset)
+	}
+	return blockfile, size, nil
+}
+
+// openLocalBlockfileForProxy opens a blockfile of a ledger on the local file system, nil if it has been discarded
+func openLocalBlockfileForProxy(ledgerID string, fileNum int) (*os.File, error) {
+	file, err := os.Open(RawBlockfilePath(blockarchive.BlockStorePath, ledgerID, fileNum))
+	if err == nil {
+		return file, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "error opening blockfile [%d] of ledger [%s]", fileNum, ledgerID)
+	}
+	return nil, nil
+}
+
+// openArchivedBlockfileForProxy opens an archived blockfile of a ledger on the repository through the
+// retrieval scheduler, verified against its signed manifest when VerifyBlockfileSignatures is set. The
+// blockfiles archived compressed or encrypted are refused with ErrEncodedBlockfileRange if ranged is set.
+func openArchivedBlockfileForProxy(ledgerID string, fileNum int, catalog blockarchive.Catalog, ranged bool) (*sftpConnInfo, *archive.ArchivedBlockfileInfo, error) {
+	infos, err := catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, info := range infos {
+		if info.BlockfileNo != uint64(fileNum) {
+			continue
+		}
+		scheduler := getRetrievalScheduler()
+		remote, err := scheduler.acquire(blockarchive.RepositoryURLOf(ledgerID), info.Location, retrievalForQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ranged {
+			if encoded, err := isEncodedBlockfile(remote.client, info.Location); err != nil || encoded {
+				scheduler.release(remote)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "error opening archived blockfile %s", info.Location)
+				}
+				return nil, nil, ErrEncodedBlockfileRange
+			}
+		}
+		remoteFile, err := openArchivedBlockfile(remote.client, ledgerID, fileNum, info.Location)
+		if err != nil {
+			scheduler.release(remote)
+			return nil, nil, errors.Wrapf(err, "error opening archived blockfile %s", info.Location)
+		}
+		return &sftpConnInfo{remoteFile, remote}, info, nil
+	}
+	return nil, nil, ErrBlockfileNotFound
+}
+
+// isEncodedBlockfile tells if the archived blockfile at location on the repository is stored encoded, reading only
+// the start of its object
+func isEncodedBlockfile(client repositoryClient, location string) (bool, error) {
+	file, err := client.Open(location)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	header, err := blockarchive.ReadObjectHeader(bufio.NewReader(file))
+	return header != nil, err
+}
@@ -0,0 +1,203 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// PruneCandidate is a local blockfile whose blocks are all older than the blocks retained by a prune
+type PruneCandidate struct {
+	BlockfileNo   int
+	FirstBlockNum uint64
+	LastBlockNum  uint64
+	Size          int64
+	// Archived indicates if the blockfile has already been archived into the repository
+	Archived bool
+}
+
+// PrunePlan lists the local blockfiles of a channel which are discarded to retain the latest blocks only
+type PrunePlan struct {
+	LedgerID     string
+	Height       uint64
+	RetainLatest uint64
+	// Blockfiles holds the blockfiles to discard, oldest first
+	Blockfiles []*PruneCandidate
+	// Size is the total size of the blockfiles to discard
+	Size int64
+	// OldestLocalBlock is the oldest block left on the local file system after the first blockfile
+	OldestLocalBlock uint64
+}
+
+// PruneReport is the outcome of a prune
+type PruneReport struct {
+	LedgerID string
+	// Archived holds the blockfiles which have been archived by the prune
+	Archived []*archive.ArchivedBlockfileInfo
+	// Discarded holds the blockfiles which have been discarded from the local file system
+	Discarded []*archive.ArchivedBlockfileInfo
+	// FreedSize is the local disk space freed by the discarded blockfiles
+	FreedSize int64
+	// OldestLocalBlock is the oldest block left on the local file system after the first blockfile
+	OldestLocalBlock uint64
+}
+
+// PlanPrune lists the local blockfiles of a channel holding only blocks older than the latest retainLatest
+// blocks. The first blockfile, which holds the genesis block, and the blockfile being written are never
+// discarded. The peer must be stopped.
+func PlanPrune(blockStorePath, ledgerID string, retainLatest uint64) (*PrunePlan, error) {
+	var plan *PrunePlan
+	err := withStoppedArchiver(blockStorePath, ledgerID, func(arch *blockfileArchiver) error {
+		var err error
+		plan, err = arch.planPrune(retainLatest)
+		return err
+	})
+	return plan, err
+}
+
+// Prune archives the blockfiles of a plan which have not been archived yet, verifies the archived
+// blockfiles against their checksum on the repository, and discards them from the local file system,
+// oldest first. A client peer only discards the blockfiles already archived by the archiver peer.
+// It stops at the first blockfile which fails, and reports what has been done so far. The peer must
+// be stopped.
+func Prune(blockStorePath string, plan *PrunePlan) (*PruneReport, error) {
+	if !blockarchive.IsArchiver && !blockarchive.IsClient {
+		return nil, errors.New("the blockfiles are discarded by the archiver peer and the client peers only, " +
+			"neither peer.archiver.enabled nor peer.archiving.enabled is set")
+	}
+	report := &PruneReport{LedgerID: plan.LedgerID}
+	err := withStoppedArchiver(blockStorePath, plan.LedgerID, func(arch *blockfileArchiver) error {
+		return arch.prune(plan, report)
+	})
+	return report, err
+}
+
+// withStoppedArchiver opens the block store of a ledger while the peer is stopped, and calls f with its
+// archiver once any archiving resumed on opening has completed
+func withStoppedArchiver(blockStorePath, ledgerID string, f func(arch *blockfileArchiver) error) error {
+	conf := NewConf(blockStorePath, 0, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir)
+	if _, err := os.Stat(conf.getLedgerBlockDir(ledgerID)); err != nil {
+		return errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	// The blockfile manager locates the last block by its number on opening
+	provider := NewProvider(conf, &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{blkstorage.IndexableAttrBlockNum}})
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(ledgerID)
+	if err != nil {
+		return err
+	}
+	defer store.Shutdown()
+	arch := store.(*fsBlockStore).archiver
+	arch.stopArchivingAndWait()
+	return f(arch)
+}
+
+func (arch *blockfileArchiver) planPrune(retainLatest uint64) (*PrunePlan, error) {
+	height := arch.mgr.getBlockchainInfo().Height
+	plan := &PrunePlan{LedgerID: arch.chainID, Height: height, RetainLatest: retainLatest}
+	if height <= retainLatest {
+		return plan, nil
+	}
+	firstRetained := height - retainLatest
+
+	fileNums, sizes, err := listLocalBlockfiles(arch.mgr.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	for i, fileNum := range fileNums {
+		if fileNum == 0 {
+			continue
+		}
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		if err != nil {
+			return nil, err
+		}
+		candidate := &PruneCandidate{BlockfileNo: fileNum, Size: sizes[fileNum], Archived: info != nil}
+		if info != nil {
+			candidate.FirstBlockNum, candidate.LastBlockNum = info.FirstBlockNum, info.LastBlockNum
+		} else {
+			summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "error scanning blockfile [%d]", fileNum)
+			}
+			candidate.FirstBlockNum, candidate.LastBlockNum = summary.firstBlockNum, summary.lastBlockNum
+		}
+		if i == len(fileNums)-1 || candidate.LastBlockNum >= firstRetained {
+			plan.OldestLocalBlock = candidate.FirstBlockNum
+			break
+		}
+		plan.Blockfiles = append(plan.Blockfiles, candidate)
+		plan.Size += candidate.Size
+	}
+	return plan, nil
+}
+
+func (arch *blockfileArchiver) prune(plan *PrunePlan, report *PruneReport) error {
+	if len(plan.Blockfiles) == 0 {
+		report.OldestLocalBlock = plan.OldestLocalBlock
+		return nil
+	}
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	for _, candidate := range plan.Blockfiles {
+		fileNum := candidate.BlockfileNo
+		if _, err := os.Stat(deriveBlockfilePath(arch.mgr.rootDir, fileNum)); os.IsNotExist(err) {
+			// Discarded by a previous prune
+			report.OldestLocalBlock = candidate.LastBlockNum + 1
+			continue
+		}
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			if !blockarchive.IsArchiver {
+				return errors.Errorf("blockfile [%d] has not been archived by the archiver peer yet", fileNum)
+			}
+			if fileNum < arch.checkpoint.nextBlockfileNum {
+				return errors.Errorf("blockfile [%d] is behind the checkpoint of the archiver but has not been archived", fileNum)
+			}
+			if _, err := arch.archiveBlockfile(fileNum, false); err != nil {
+				return errors.WithMessagef(err, "error archiving blockfile [%d]", fileNum)
+			}
+			if err := arch.saveCheckpoint(fileNum+1, noInFlightBlockfile, false); err != nil {
+				return err
+			}
+			if info, err = arch.catalog.getArchivedBlockfile(uint64(fileNum)); err != nil {
+				return err
+			} else if info == nil {
+				return errors.Errorf("blockfile [%d] has not been recorded in the archive catalog", fileNum)
+			}
+			report.Archived = append(report.Archived, info)
+		}
+
+		// The local blockfile is the last copy of its blocks once discarded
+		matched, err := matchesChecksum(client, info)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return errors.Errorf("archived blockfile [%d] at %s does not match its checksum %s", fileNum, info.Location, info.Checksum)
+		}
+		if err := arch.deleteArchivedBlockfile(fileNum); err != nil {
+			return errors.WithMessagef(err, "error discarding blockfile [%d]", fileNum)
+		}
+		arch.notifyDiscarded(fileNum)
+		report.Discarded = append(report.Discarded, info)
+		report.FreedSize += candidate.Size
+		report.OldestLocalBlock = info.LastBlockNum + 1
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevIsArchiver, prevIsClient := blockarchive.IsArchiver, blockarchive.IsClient
+	prevEach, prevBlockStorePath := blockarchive.NumBlockfileEachArchiving, blockarchive.BlockStorePath
+	blockarchive.IsArchiver, blockarchive.IsClient = true, false
+	// The archiving is triggered by the test only
+	blockarchive.NumBlockfileEachArchiving = 1000
+	defer func() {
+		blockarchive.IsArchiver, blockarchive.IsClient = prevIsArchiver, prevIsClient
+		blockarchive.NumBlockfileEachArchiving, blockarchive.BlockStorePath = prevEach, prevBlockStorePath
+	}()
+
+	// Blockfiles of 10 blocks after the first one
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	size := 0
+	for _, block := range blocks[10:20] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	blockarchive.BlockStorePath = blockStorePath
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), "/blkstore"))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	// Blockfile 1 has been archived but kept
+	arch := store.(*fsBlockStore).archiver
+	arch.stopArchivingAndWait()
+	_, err = arch.archiveBlockfile(1, false)
+	require.NoError(t, err)
+	require.NoError(t, arch.saveCheckpoint(2, noInFlightBlockfile, false))
+	blockfileDir := arch.blockfileDir
+	store.Shutdown()
+	env.provider.Close()
+
+	plan, err := PlanPrune(blockStorePath, "testLedger", 1000)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Blockfiles)
+
+	plan, err = PlanPrune(blockStorePath, "testLedger", 13)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(40), plan.Height)
+	require.Len(t, plan.Blockfiles, 2)
+	assert.Equal(t, &PruneCandidate{BlockfileNo: 1, FirstBlockNum: 7, LastBlockNum: 16, Size: plan.Blockfiles[0].Size, Archived: true}, plan.Blockfiles[0])
+	assert.Equal(t, &PruneCandidate{BlockfileNo: 2, FirstBlockNum: 17, LastBlockNum: 26, Size: plan.Blockfiles[1].Size}, plan.Blockfiles[1])
+	assert.Equal(t, plan.Blockfiles[0].Size+plan.Blockfiles[1].Size, plan.Size)
+	assert.Equal(t, uint64(27), plan.OldestLocalBlock)
+
+	// A client peer discards the archived blockfiles only
+	blockarchive.IsArchiver, blockarchive.IsClient = false, true
+	report, err := Prune(blockStorePath, plan)
+	assert.EqualError(t, err, "blockfile [2] has not been archived by the archiver peer yet")
+	require.Len(t, report.Discarded, 1)
+	assert.Equal(t, uint64(1), report.Discarded[0].BlockfileNo)
+	assert.Equal(t, plan.Blockfiles[0].Size, report.FreedSize)
+	assert.Equal(t, uint64(17), report.OldestLocalBlock)
+	_, err = os.Stat(deriveBlockfilePath(blockfileDir, 1))
+	assert.True(t, os.IsNotExist(err))
+
+	// The archiver peer archives the other ones first
+	blockarchive.IsArchiver, blockarchive.IsClient = true, false
+	report, err = Prune(blockStorePath, plan)
+	require.NoError(t, err)
+	require.Len(t, report.Archived, 1)
+	assert.Equal(t, uint64(2), report.Archived[0].BlockfileNo)
+	require.Len(t, report.Discarded, 1)
+	assert.Equal(t, uint64(2), report.Discarded[0].BlockfileNo)
+	assert.Equal(t, uint64(27), report.OldestLocalBlock)
+	_, err = os.Stat(deriveBlockfilePath(blockfileDir, 2))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(deriveBlockfilePath(blockfileDir, 3))
+	assert.NoError(t, err)
+
+	// The archiving resumes after the blockfiles archived by the prune
+	env = newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), "/blkstore"))
+	store, err = env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	arch = store.(*fsBlockStore).archiver
+	assert.Equal(t, 3, arch.checkpoint.nextBlockfileNum)
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.True(t, infos[0].Discarded)
+	assert.True(t, infos[1].Discarded)
+
+	blockarchive.IsArchiver = false
+	_, err = Prune(blockStorePath, plan)
+	assert.EqualError(t, err, "the blockfiles are discarded by the archiver peer and the client peers only, "+
+		"neither peer.archiver.enabled nor peer.archiving.enabled is set")
+	_, err = PlanPrune(blockStorePath, "unknown", 10)
+	assert.Contains(t, err.Error(), "ledger [unknown] not found")
+}
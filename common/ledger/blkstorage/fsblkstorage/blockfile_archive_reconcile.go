@@ -0,0 +1,287 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// ReconcileReport lists the differences found between the archive catalog of a ledger and the repository
+type ReconcileReport struct {
+	LedgerID string
+	// Checked is the number of archived blockfiles of the catalog which have been checked
+	Checked int
+	// Missing are the archived blockfiles of the catalog which are not on the repository
+	Missing []*archive.ArchivedBlockfileInfo
+	// Mismatched are the archived blockfiles whose content on the repository doesn't match their checksum
+	Mismatched []*archive.ArchivedBlockfileInfo
+	// Orphaned are the blockfiles on the repository, next to the archived blockfiles of the ledger,
+	// which are not recorded in the catalog
+	Orphaned []string
+	// Healed are the missing blockfiles which have been uploaded again from the local file system
+	Healed []*archive.ArchivedBlockfileInfo
+	// Repaired are the mismatched blockfiles which have been uploaded again from the local file system
+	// and match their checksum on the repository again
+	Repaired []*archive.ArchivedBlockfileInfo
+}
+
+// IsConsistent tells if the catalog and the repository match, once the missing blockfiles have been healed
+func (r *ReconcileReport) IsConsistent() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0 && len(r.Orphaned) == 0
+}
+
+// ReconcileArchive compares the archive catalog of a ledger stored in blockStorePath with the content of the
+// repository. If heal is set, the missing blockfiles which are still on the local file system are uploaded
+// again, and so are the blockfiles whose content on the repository doesn't match their checksum. It must not
+// be called while the peer is running.
+func ReconcileArchive(blockStorePath, ledgerID string, heal bool) (*ReconcileReport, error) {
+	conf := NewConf(blockStorePath, 0, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir)
+	if _, err := os.Stat(conf.getLedgerBlockDir(ledgerID)); err != nil {
+		return nil, errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	provider := NewProvider(conf, &blkstorage.IndexConfig{})
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Shutdown()
+	return store.(*fsBlockStore).archiver.reconcile(heal)
+}
+
+// StartReconciliation reconciles the archive catalogs of the open ledgers with the repository every
+// interval, as long as the peer is the archiver. If heal is set, the missing and mismatched blockfiles
+// which are still on the local file system are uploaded again.
+func StartReconciliation(interval time.Duration, heal bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !blockarchive.IsArchiver {
+				continue
+			}
+			for _, arch := range openArchivers() {
+				report, err := arch.reconcile(heal)
+				if err != nil {
+					loggerArchive.Errorf("[%s] Failed reconciling the archive catalog with the repository: %s", arch.chainID, err)
+					continue
+				}
+				arch.logReconcileReport(report)
+			}
+		}
+	}()
+}
+
+// logReconcileReport logs the differences between the catalog and the repository
+func (arch *blockfileArchiver) logReconcileReport(report *ReconcileReport) {
+	for _, info := range report.Healed {
+		loggerArchive.Warningf("[%s] Uploaded again blockfile [%d] missing from the repository at %s", arch.chainID, info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Repaired {
+		loggerArchive.Warningf("[%s] Uploaded again blockfile [%d] corrupted on the repository at %s", arch.chainID, info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Missing {
+		loggerArchive.Errorf("[%s] Archived blockfile [%d] is missing from the repository at %s", arch.chainID, info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Mismatched {
+		loggerArchive.Errorf("[%s] Archived blockfile [%d] at %s does not match its checksum %s", arch.chainID, info.BlockfileNo, info.Location, info.Checksum)
+		blockarchive.RaiseAlert(blockarchive.AlertVerificationFailure, arch.chainID,
+			"archived blockfile [%d] at %s does not match its checksum %s", info.BlockfileNo, info.Location, info.Checksum)
+	}
+	for _, location := range report.Orphaned {
+		loggerArchive.Warningf("[%s] Blockfile %s on the repository is not in the archive catalog", arch.chainID, location)
+	}
+	if report.IsConsistent() {
+		loggerArchive.Infof("[%s] The %d archived blockfile(s) of the catalog match the repository", arch.chainID, report.Checked)
+	}
+}
+
+// reconcile lists the archived blockfiles of the catalog which are missing from the repository or whose
+// content doesn't match their checksum, and the blockfiles next to them on the repository which are not
+// in the catalog. The missing and mismatched blockfiles still on the local file system are uploaded again
+// if heal is set.
+func (arch *blockfileArchiver) reconcile(heal bool) (*ReconcileReport, error) {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	report := &ReconcileReport{LedgerID: arch.chainID}
+	known := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, info := range infos {
+		known[info.Location] = true
+		dirs[path.Dir(info.Location)] = true
+		report.Checked++
+
+		if _, err := client.Stat(info.Location); os.IsNotExist(err) {
+			if heal && arch.healBlockfile(info) {
+				report.Healed = append(report.Healed, info)
+			} else {
+				report.Missing = append(report.Missing, info)
+			}
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "error reading archived blockfile %s", info.Location)
+		}
+		matched, err := matchesChecksum(client, info)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			if heal && arch.repairBlockfile(client, info) {
+				report.Repaired = append(report.Repaired, info)
+			} else {
+				report.Mismatched = append(report.Mismatched, info)
+			}
+		}
+	}
+
+	// The objects of the content-addressed blockfiles are shared with other peers and ledgers
+	if blockarchive.ContentAddressed {
+		return report, nil
+	}
+	// The blockfile being archived is not recorded yet
+	if cp, err := readArchiverCheckpoint(arch.mgr.db); err != nil {
+		return nil, err
+	} else if cp != nil && cp.inFlightBlockfileNum != noInFlightBlockfile {
+		if location, err := arch.archiveLocation(cp.inFlightBlockfileNum); err == nil {
+			known[location] = true
+		}
+	}
+	for dir := range dirs {
+		orphaned, err := orphanedBlockfiles(client, dir, known)
+		if err != nil {
+			return nil, err
+		}
+		report.Orphaned = append(report.Orphaned, orphaned...)
+	}
+	sort.Strings(report.Orphaned)
+	return report, nil
+}
+
+// matchesChecksum tells if the content of an archived blockfile on the repository matches the checksum
+// recorded in the catalog. The checksum is computed by the repository server if its API is configured, otherwise
+// the blockfile is downloaded, as it is from HDFS. The encrypted blockfiles are downloaded, since the repository doesn't hold
+// their keys. The blockfiles archived before the checksums were recorded are not checked.
+func matchesChecksum(client repositoryClient, info *archive.ArchivedBlockfileInfo) (bool, error) {
+	return matchesChecksumBy(client, info, hasRepositoryAPI(client) && info.EncryptionKeyId == "")
+}
+
+// matchesChecksumBy tells if the content of an archived blockfile on the repository matches the checksum
+// recorded in the catalog, with the checksum computed by the repository if byRepository is set, in which
+// case client is not used, and by downloading the blockfile otherwise. The checksum is the one of the blockfile
+// once decoded when it is stored encoded.
+func matchesChecksumBy(client repositoryClient, info *archive.ArchivedBlockfileInfo, byRepository bool) (bool, error) {
+	if info.Checksum == "" {
+		return true, nil
+	}
+	expected, err := blockarchive.ParseChecksum(info.Checksum)
+	if err != nil {
+		return false, errors.WithMessagef(err, "invalid checksum of archived blockfile [%d]", info.BlockfileNo)
+	}
+	if byRepository {
+		verification, err := blockarchive.VerifyBlockfile(info.Location, expected.Algorithm, false)
+		if err != nil {
+			return false, err
+		}
+		return verification.Checksum == expected.String(), nil
+	}
+	file, err := client.Open(info.Location)
+	if err != nil {
+		return false, errors.Wrapf(err, "error opening archived blockfile %s", info.Location)
+	}
+	defer file.Close()
+	actual, err := blockarchive.ComputeBlockfileContentChecksum(file, expected.Algorithm)
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading archived blockfile %s", info.Location)
+	}
+	return actual.String() == expected.String(), nil
+}
+
+// orphanedBlockfiles returns the blockfiles in a directory of the repository which are not known,
+// leaving out the files kept next to the blockfiles and the uploads in progress
+func orphanedBlockfiles(client repositoryClient, dir string, known map[string]bool) ([]string, error) {
+	fileInfos, err := client.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing %s on the repository", dir)
+	}
+	var orphaned []string
+	for _, fileInfo := range fileInfos {
+		location := path.Join(dir, fileInfo.Name())
+		if fileInfo.IsDir() || known[location] || isBlockfileSidecar(location) {
+			continue
+		}
+		orphaned = append(orphaned, location)
+	}
+	return orphaned, nil
+}
+
+func isBlockfileSidecar(location string) bool {
+	for _, suffix := range []string{blockarchive.ManifestSuffix, blockarchive.SummarySuffix, blockarchive.ChecksumSuffix,
+		blockarchive.ObjectLockSuffix, blockarchive.RefsSuffix, blockarchive.OwnersSuffix, blockarchive.TailSuffix, uploadingSuffix} {
+		if strings.HasSuffix(location, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// healBlockfile uploads again an archived blockfile missing from the repository, along with its summary
+// and manifest, if the local blockfile is still there and matches the checksum recorded in the catalog
+func (arch *blockfileArchiver) healBlockfile(info *archive.ArchivedBlockfileInfo) bool {
+	fileNum := int(info.BlockfileNo)
+	localPath := deriveBlockfilePath(arch.blockfileDir, fileNum)
+	if _, err := os.Stat(localPath); err != nil {
+		return false
+	}
+	if info.Checksum != "" {
+		expected, err := blockarchive.ParseChecksum(info.Checksum)
+		if err != nil {
+			return false
+		}
+		actual, err := blockarchive.ComputeBlockfileChecksum(localPath, expected.Algorithm)
+		if err != nil || actual.String() != expected.String() {
+			loggerArchive.Errorf("[%s] Local blockfile [%d] does not match its checksum %s, not uploaded again", arch.chainID, fileNum, info.Checksum)
+			return false
+		}
+	}
+
+	// The shutdown of the peer waits for the upload
+	if !transfers.begin() {
+		return false
+	}
+	defer transfers.end()
+	if _, err := sendBlockfileToRepo(arch.blockfileDir, fileNum, info.Location); err != nil {
+		loggerArchive.Errorf("[%s] Failed uploading again blockfile [%d]: %s", arch.chainID, fileNum, err)
+		return false
+	}
+	if blockarchive.ContentAddressed {
+		if err := addBlockfileRef(arch.chainID, info.Location, arch.refName(fileNum)); err != nil {
+			loggerArchive.Errorf("[%s] Failed referencing again blockfile [%d]: %s", arch.chainID, fileNum, err)
+			return false
+		}
+	}
+	if err := arch.publishManifest(fileNum, info.Location); err != nil {
+		loggerArchive.Errorf("[%s] Failed publishing again the manifest of blockfile [%d]: %s", arch.chainID, fileNum, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	// Blockfile 0 is discarded, blockfile 1 is kept on the local file system
+	arch := store.(*fsBlockStore).archiver
+	var locations []string
+	for fileNum, discard := range []bool{true, false} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.publishManifest(fileNum, location))
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, discard))
+		locations = append(locations, location)
+	}
+	remotePath := func(location string) string { return filepath.Join(repoRootDir, location) }
+
+	report, err := arch.reconcile(false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Checked)
+	assert.True(t, report.IsConsistent())
+
+	// A blockfile still on the local file system is uploaded again
+	require.NoError(t, os.Remove(remotePath(locations[1])))
+	report, err = arch.reconcile(false)
+	require.NoError(t, err)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, uint64(1), report.Missing[0].BlockfileNo)
+	report, err = arch.reconcile(true)
+	require.NoError(t, err)
+	require.Len(t, report.Healed, 1)
+	assert.Equal(t, uint64(1), report.Healed[0].BlockfileNo)
+	assert.True(t, report.IsConsistent())
+	healed, err := ioutil.ReadFile(remotePath(locations[1]))
+	require.NoError(t, err)
+	local, err := ioutil.ReadFile(deriveBlockfilePath(arch.blockfileDir, 1))
+	require.NoError(t, err)
+	assert.Equal(t, local, healed)
+
+	// A corrupted blockfile still on the local file system is uploaded again and verified
+	counter := &metricsfakes.Counter{}
+	counter.WithReturns(counter)
+	repairCounterOnce.Do(func() {})
+	repairCounter = counter
+	defer func() { repairCounter, repairCounterOnce = nil, sync.Once{} }()
+	corrupted := append([]byte{}, local...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(remotePath(locations[1]), corrupted, 0644))
+	report, err = arch.reconcile(false)
+	require.NoError(t, err)
+	require.Len(t, report.Mismatched, 1)
+	assert.Empty(t, report.Repaired)
+	report, err = arch.reconcile(true)
+	require.NoError(t, err)
+	require.Len(t, report.Repaired, 1)
+	assert.Equal(t, uint64(1), report.Repaired[0].BlockfileNo)
+	assert.True(t, report.IsConsistent())
+	repaired, err := ioutil.ReadFile(remotePath(locations[1]))
+	require.NoError(t, err)
+	assert.Equal(t, local, repaired)
+	require.Equal(t, 1, counter.WithCallCount())
+	assert.Equal(t, []string{"channel", "testLedger", "outcome", repairOutcomeRepaired}, counter.WithArgsForCall(0))
+
+	// The digest mismatches and the unknown blockfiles are reported
+	content, err := ioutil.ReadFile(remotePath(locations[0]))
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(remotePath(locations[0]), content, 0644))
+	orphan := filepath.Join(filepath.Dir(locations[0]), "blockfile_000009")
+	require.NoError(t, ioutil.WriteFile(remotePath(orphan), []byte("orphan"), 0644))
+	report, err = arch.reconcile(true)
+	require.NoError(t, err)
+	require.Len(t, report.Mismatched, 1)
+	assert.Equal(t, uint64(0), report.Mismatched[0].BlockfileNo)
+	assert.Equal(t, []string{orphan}, report.Orphaned)
+	assert.False(t, report.IsConsistent())
+	// The discarded blockfile cannot be repaired
+	require.Equal(t, 2, counter.WithCallCount())
+	assert.Equal(t, []string{"channel", "testLedger", "outcome", repairOutcomeNoLocalCopy}, counter.WithArgsForCall(1))
+
+	// A discarded blockfile cannot be uploaded again
+	require.NoError(t, os.Remove(remotePath(locations[0])))
+	report, err = arch.reconcile(true)
+	require.NoError(t, err)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, uint64(0), report.Missing[0].BlockfileNo)
+	assert.Empty(t, report.Healed)
+
+	_, err = ReconcileArchive(blockStorePath, "unknown", false)
+	assert.EqualError(t, err, "ledger [unknown] not found in "+blockStorePath)
+}
@@ -0,0 +1,271 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// RekeyReport lists the archived blockfiles of a ledger encrypted again with a new key
+type RekeyReport struct {
+	LedgerID string
+	KeyID    string
+	// Checked is the number of archived blockfiles of the catalog which have been checked
+	Checked int
+	// Rekeyed are the archived blockfiles which have been encrypted again with the key
+	Rekeyed []*archive.ArchivedBlockfileInfo
+	// UpToDate is the number of archived blockfiles already encrypted with the key, such as by an interrupted run
+	UpToDate int
+	// Locked are the archived blockfiles which cannot be encrypted again since an object lock keeps them
+	Locked []*archive.ArchivedBlockfileInfo
+	// Missing are the archived blockfiles of the catalog which are not on the repository
+	Missing []*archive.ArchivedBlockfileInfo
+}
+
+// IsComplete tells if all the archived blockfiles of the ledger are encrypted with the key
+func (r *RekeyReport) IsComplete() bool {
+	return len(r.Locked) == 0 && len(r.Missing) == 0
+}
+
+// RekeyArchive encrypts again the archived blockfiles of a ledger stored in blockStorePath with the key keyID of
+// the keyring, and records the key in their catalog records. Each blockfile is streamed from the repository,
+// decrypted, encrypted with the key, uploaded next to the blockfile and verified before it replaces the blockfile.
+// The blockfiles already encrypted with the key are skipped, so that an interrupted run is resumed by running it
+// again. It must not be called while the peer is running.
+func RekeyArchive(blockStorePath, ledgerID, keyID string) (*RekeyReport, error) {
+	if _, err := blockarchive.EncryptionKeys.Key(keyID); err != nil {
+		return nil, err
+	}
+	conf := NewConf(blockStorePath, 0, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir)
+	if _, err := os.Stat(conf.getLedgerBlockDir(ledgerID)); err != nil {
+		return nil, errors.Errorf("ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	provider := NewProvider(conf, &blkstorage.IndexConfig{})
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Shutdown()
+	return store.(*fsBlockStore).archiver.rekey(keyID)
+}
+
+// rekey encrypts again the archived blockfiles of the catalog with the key keyID
+func (arch *blockfileArchiver) rekey(keyID string) (*RekeyReport, error) {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	report := &RekeyReport{LedgerID: arch.chainID, KeyID: keyID}
+	for _, info := range infos {
+		report.Checked++
+		rekeyed, err := arch.rekeyBlockfile(client, info, keyID)
+		switch {
+		case os.IsNotExist(errors.Cause(err)):
+			report.Missing = append(report.Missing, info)
+		case err == errObjectLocked:
+			report.Locked = append(report.Locked, info)
+		case err != nil:
+			return report, errors.WithMessagef(err, "error encrypting again blockfile [%d] at %s", info.BlockfileNo, info.Location)
+		case rekeyed:
+			report.Rekeyed = append(report.Rekeyed, info)
+		default:
+			report.UpToDate++
+		}
+		if err != nil || info.EncryptionKeyId == keyID {
+			continue
+		}
+		// The record is updated once the blockfile is encrypted with the key, so that it is updated
+		// by the next run if the run is interrupted in between
+		info.EncryptionKeyId = keyID
+		if err := arch.catalog.recordArchivedBlockfile(info); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// errObjectLocked is returned when an archived blockfile kept by an object lock would be replaced
+var errObjectLocked = errors.New("the blockfile is locked on the repository")
+
+// rekeyBlockfile encrypts again an archived blockfile with the key keyID, and returns whether it had to be encrypted
+// again. A blockfile encrypted again by an interrupted run, which was not renamed to the blockfile yet, is renamed.
+func (arch *blockfileArchiver) rekeyBlockfile(client repositoryClient, info *archive.ArchivedBlockfileInfo, keyID string) (bool, error) {
+	tmpPath := info.Location + uploadingSuffix
+	header, size, err := readRemoteObjectHeader(client, info.Location)
+	if os.IsNotExist(errors.Cause(err)) {
+		// The blockfile was removed before the one encrypted again was renamed to it
+		if tmpHeader, _, tmpErr := readRemoteObjectHeader(client, tmpPath); tmpErr == nil && tmpHeader != nil && tmpHeader.KeyID == keyID {
+			if err := verifyRekeyedBlockfile(client, tmpPath, info, tmpHeader); err != nil {
+				return false, err
+			}
+			return true, client.Rename(tmpPath, info.Location)
+		}
+		return false, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if header != nil && header.KeyID == keyID {
+		return false, nil
+	}
+	if lock, err := readObjectLock(client, info.Location); err != nil {
+		return false, err
+	} else if lock != nil && lock.IsActive(time.Now()) {
+		return false, errObjectLocked
+	}
+
+	if header == nil {
+		// The blockfile stored as is is encrypted as an object of its own
+		checksum, err := remoteChecksum(client, info.Location, info.Checksum)
+		if err != nil {
+			return false, err
+		}
+		if checksum == nil {
+			if checksum, err = remoteContentChecksum(client, info.Location); err != nil {
+				return false, err
+			}
+		}
+		header = &blockarchive.ObjectHeader{Size: size, Checksum: checksum.String()}
+	}
+	rekeyed := *header
+	if err := reencryptRemoteObject(client, info.Location, tmpPath, &rekeyed, keyID); err != nil {
+		client.Remove(tmpPath)
+		return false, err
+	}
+	if err := verifyRekeyedBlockfile(client, tmpPath, info, &rekeyed); err != nil {
+		client.Remove(tmpPath)
+		return false, err
+	}
+	// Replace the blockfile, the blockfile encrypted again is renamed by the next run if it is interrupted in between
+	if err := client.Remove(info.Location); err != nil {
+		return false, errors.Wrapf(err, "error replacing %s", info.Location)
+	}
+	if err := client.Rename(tmpPath, info.Location); err != nil {
+		return false, errors.Wrapf(err, "error replacing %s", info.Location)
+	}
+	loggerArchive.Infof("[%s] Encrypted again blockfile [%d] at %s with key %s", arch.chainID, info.BlockfileNo, info.Location, keyID)
+	return true, nil
+}
+
+// reencryptRemoteObject streams the archived object at location to tmpPath, decrypting its content and encrypting it
+// again with the key keyID under the header. The content stays compressed if it is.
+func reencryptRemoteObject(client repositoryClient, location, tmpPath string, header *blockarchive.ObjectHeader, keyID string) error {
+	src, err := client.Open(location)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", location)
+	}
+	defer src.Close()
+	buffered := bufio.NewReader(src)
+	var content io.Reader = buffered
+	previous, err := blockarchive.ReadObjectHeader(buffered)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		if content, err = blockarchive.NewDecrypterOf(buffered, previous); err != nil {
+			return err
+		}
+	}
+
+	dst, err := client.Create(tmpPath)
+	if err != nil {
+		return errors.Wrapf(err, "error creating %s", tmpPath)
+	}
+	encrypter, err := blockarchive.NewEncrypterOf(dst, header, keyID)
+	if err == nil {
+		if _, err = io.Copy(encrypter, content); err == nil {
+			err = encrypter.Close()
+		}
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "error writing %s", tmpPath)
+	}
+	return nil
+}
+
+// verifyRekeyedBlockfile checks that the blockfile encrypted again at tmpPath is decrypted with its new key into
+// the content of the checksum of the archived blockfile
+func verifyRekeyedBlockfile(client repositoryClient, tmpPath string, info *archive.ArchivedBlockfileInfo, header *blockarchive.ObjectHeader) error {
+	recorded := info.Checksum
+	if recorded == "" {
+		recorded = header.Checksum
+	}
+	expected, err := blockarchive.ParseChecksum(recorded)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid checksum of archived blockfile [%d]", info.BlockfileNo)
+	}
+	file, err := client.Open(tmpPath)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", tmpPath)
+	}
+	defer file.Close()
+	actual, err := blockarchive.ComputeBlockfileContentChecksum(file, expected.Algorithm)
+	if err != nil {
+		return errors.WithMessagef(err, "error reading %s", tmpPath)
+	}
+	if actual.String() != expected.String() {
+		return errors.Errorf("blockfile encrypted again at %s does not match the checksum of the archived blockfile: %s",
+			tmpPath, &blockarchive.ChecksumMismatchError{Expected: expected, Actual: actual})
+	}
+	return nil
+}
+
+// readRemoteObjectHeader returns the header of the archived object at location, nil if it is a blockfile stored
+// as is, along with the size of the object
+func readRemoteObjectHeader(client repositoryClient, location string) (*blockarchive.ObjectHeader, int64, error) {
+	file, err := client.Open(location)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error opening %s", location)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error reading %s", location)
+	}
+	header, err := blockarchive.ReadObjectHeader(bufio.NewReader(file))
+	if err != nil {
+		return nil, 0, errors.WithMessagef(err, "error reading %s", location)
+	}
+	return header, info.Size(), nil
+}
+
+// remoteContentChecksum computes the checksum of the blockfile of the archived object at location
+func remoteContentChecksum(client repositoryClient, location string) (*blockarchive.Checksum, error) {
+	file, err := client.Open(location)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening %s", location)
+	}
+	defer file.Close()
+	return blockarchive.ComputeBlockfileContentChecksum(file, blockarchive.ChecksumAlgorithm)
+}
+
+// remoteEncryptionKeyID returns the ID of the key the archived object at location is encrypted with,
+// empty if it is not encrypted
+func remoteEncryptionKeyID(client repositoryClient, location string) (string, error) {
+	header, _, err := readRemoteObjectHeader(client, location)
+	if err != nil || header == nil {
+		return "", err
+	}
+	return header.KeyID, nil
+}
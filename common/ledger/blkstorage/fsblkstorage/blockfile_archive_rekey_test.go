@@ -0,0 +1,130 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRekeyArchive(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+	defer setTestEncryptionKeys(t, "", "key1", "key2")()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	// Blockfile 0 is stored as is and discarded, blockfile 1 is encrypted with key1 and kept on the local file system
+	arch := store.(*fsBlockStore).archiver
+	var locations []string
+	for fileNum, keyID := range []string{"", "key1"} {
+		blockarchive.EncryptionKeyID = keyID
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, fileNum == 0))
+		locations = append(locations, location)
+	}
+	blockarchive.EncryptionKeyID = ""
+	remotePath := func(location string) string { return filepath.Join(repoRootDir, location) }
+	sshConn, client, err := connectToRepo(arch.chainID)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	assertEncryptedWith := func(keyID string) {
+		for fileNum, location := range locations {
+			header, _, err := readRemoteObjectHeader(client, location)
+			require.NoError(t, err)
+			require.NotNil(t, header)
+			assert.Equal(t, keyID, header.KeyID)
+			info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+			require.NoError(t, err)
+			assert.Equal(t, keyID, info.EncryptionKeyId)
+		}
+		report, err := arch.reconcile(false)
+		require.NoError(t, err)
+		assert.True(t, report.IsConsistent())
+	}
+
+	_, err = RekeyArchive(blockStorePath, "testLedger", "key3")
+	assert.EqualError(t, err, "encryption key key3 is not in the keyring")
+
+	report, err := arch.rekey("key2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Checked)
+	require.Len(t, report.Rekeyed, 2)
+	assert.Zero(t, report.UpToDate)
+	assert.True(t, report.IsComplete())
+	assertEncryptedWith("key2")
+	// The blocks of the discarded blockfile are read from the blockfile encrypted again
+	block, err := store.RetrieveBlockByNumber(1)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[1], block)
+
+	// The blockfiles already encrypted with the key are skipped
+	report, err = arch.rekey("key2")
+	require.NoError(t, err)
+	assert.Empty(t, report.Rekeyed)
+	assert.Equal(t, 2, report.UpToDate)
+
+	// A run interrupted once a blockfile was encrypted again and the previous one removed is resumed
+	require.NoError(t, os.Rename(remotePath(locations[1]), remotePath(locations[1])+uploadingSuffix))
+	info, err := arch.catalog.getArchivedBlockfile(1)
+	require.NoError(t, err)
+	info.EncryptionKeyId = "key1"
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(info))
+	report, err = arch.rekey("key2")
+	require.NoError(t, err)
+	require.Len(t, report.Rekeyed, 1)
+	assert.Equal(t, uint64(1), report.Rekeyed[0].BlockfileNo)
+	assert.Equal(t, 1, report.UpToDate)
+	assertEncryptedWith("key2")
+
+	// A blockfile kept by an object lock cannot be encrypted again, a missing one is reported
+	lock, err := json.Marshal(&blockarchive.ObjectLock{Mode: blockarchive.ObjectLockCompliance, RetainUntil: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(remotePath(locations[0])+blockarchive.ObjectLockSuffix, lock, 0644))
+	require.NoError(t, os.Remove(remotePath(locations[1])))
+	report, err = arch.rekey("key1")
+	require.NoError(t, err)
+	require.Len(t, report.Locked, 1)
+	assert.Equal(t, uint64(0), report.Locked[0].BlockfileNo)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, uint64(1), report.Missing[0].BlockfileNo)
+	assert.False(t, report.IsComplete())
+	info, err = arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	assert.Equal(t, "key2", info.EncryptionKeyId)
+}
@@ -0,0 +1,82 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+)
+
+// The outcomes of the repair of a corrupted archived blockfile
+const (
+	// repairOutcomeRepaired is a blockfile uploaded again which matches its checksum on the repository
+	repairOutcomeRepaired = "repaired"
+	// repairOutcomeFailed is a blockfile which could not be uploaded again or still doesn't match its checksum
+	repairOutcomeFailed = "failed"
+	// repairOutcomeNoLocalCopy is a blockfile which has been discarded from the local file system
+	repairOutcomeNoLocalCopy = "no_local_copy"
+)
+
+var corruptedArchivedBlockfiles = metrics.CounterOpts{
+	Namespace:    "archiver",
+	Subsystem:    "repair",
+	Name:         "corrupted_blockfiles",
+	Help:         "The number of archived blockfiles found not to match their checksum on the repository, by outcome of their repair.",
+	LabelNames:   []string{"channel", "outcome"},
+	StatsdFormat: "%{#fqname}.%{channel}.%{outcome}",
+}
+
+var (
+	repairCounter     metrics.Counter
+	repairCounterOnce sync.Once
+)
+
+// getRepairCounter returns the counter of the corrupted archived blockfiles shared by the channels, which is created on first use
+func getRepairCounter() metrics.Counter {
+	repairCounterOnce.Do(func() {
+		var p metrics.Provider = &disabled.Provider{}
+		if blockarchive.MetricsProvider != nil {
+			p = blockarchive.MetricsProvider
+		}
+		repairCounter = p.NewCounter(corruptedArchivedBlockfiles)
+	})
+	return repairCounter
+}
+
+// repairBlockfile uploads again an archived blockfile whose content on the repository doesn't match the checksum
+// recorded in the catalog, if the local blockfile is still there, and verifies it again on the repository.
+// The incident and its outcome are counted and recorded in the audit log. It returns whether the blockfile
+// on the repository matches its checksum again.
+func (arch *blockfileArchiver) repairBlockfile(client repositoryClient, info *archive.ArchivedBlockfileInfo) bool {
+	fields := append(archivedBlockfileLogFields(info), "location", info.Location, "checksum", info.Checksum)
+	loggerAudit.Errorw("Archived blockfile does not match its checksum on the repository", fields...)
+
+	outcome := repairOutcomeFailed
+	defer func() {
+		getRepairCounter().With("channel", arch.chainID, "outcome", outcome).Add(1)
+	}()
+	if _, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, int(info.BlockfileNo))); err != nil {
+		outcome = repairOutcomeNoLocalCopy
+		loggerAudit.Errorw("Corrupted archived blockfile cannot be repaired, it has been discarded from the local file system", fields...)
+		return false
+	}
+	if !arch.healBlockfile(info) {
+		loggerAudit.Errorw("Failed uploading again the corrupted archived blockfile", fields...)
+		return false
+	}
+	matched, err := matchesChecksum(client, info)
+	if err != nil || !matched {
+		loggerAudit.Errorw("Archived blockfile uploaded again still does not match its checksum on the repository", append(fields, "error", err)...)
+		return false
+	}
+	outcome = repairOutcomeRepaired
+	loggerAudit.Warnw("Corrupted archived blockfile uploaded again and verified", fields...)
+	return true
+}
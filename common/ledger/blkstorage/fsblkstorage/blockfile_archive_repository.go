@@ -0,0 +1,138 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+)
+
+// repositoryClient is a session to the repository of a ledger: an SFTP session to the repository server, or a
+// client of the WebHDFS API of the HDFS cluster. The paths are the ones of the repository, which are under the
+// base path of the cluster on HDFS.
+type repositoryClient interface {
+	Open(path string) (repositoryFile, error)
+	Create(path string) (repositoryFile, error)
+	OpenFile(path string, flags int) (repositoryFile, error)
+	Stat(path string) (os.FileInfo, error)
+	ReadDir(path string) ([]os.FileInfo, error)
+	MkdirAll(path string) error
+	Remove(path string) error
+	RemoveDirectory(path string) error
+	Rename(oldPath, newPath string) error
+	Getwd() (string, error)
+	Close() error
+}
+
+// repositoryFile is a file of the repository opened by a repositoryClient
+type repositoryFile interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// sftpRepositoryClient is a repositoryClient over an SFTP session
+type sftpRepositoryClient struct {
+	*sftp.Client
+}
+
+func (c *sftpRepositoryClient) Open(path string) (repositoryFile, error) {
+	file, err := c.Client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *sftpRepositoryClient) Create(path string) (repositoryFile, error) {
+	file, err := c.Client.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *sftpRepositoryClient) OpenFile(path string, flags int) (repositoryFile, error) {
+	file, err := c.Client.OpenFile(path, flags)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// hdfsRepositoryClient is a repositoryClient of the HDFS cluster. The WebHDFS client is shared by the sessions,
+// so closing a session leaves it open.
+type hdfsRepositoryClient struct {
+	*webhdfs.Client
+}
+
+func (c *hdfsRepositoryClient) Open(path string) (repositoryFile, error) {
+	file, err := c.Client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *hdfsRepositoryClient) Create(path string) (repositoryFile, error) {
+	file, err := c.Client.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *hdfsRepositoryClient) OpenFile(path string, flags int) (repositoryFile, error) {
+	file, err := c.Client.OpenFile(path, flags)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *hdfsRepositoryClient) Close() error {
+	return nil
+}
+
+var (
+	hdfsClientLock sync.Mutex
+	hdfsClient     *webhdfs.Client
+	// hdfsClientConfig is the configuration of the cluster hdfsClient has been created for
+	hdfsClientConfig *webhdfs.Config
+)
+
+// connectToHDFS returns a session to the HDFS cluster of blockarchive.HDFS. The WebHDFS client, which logs in
+// to Kerberos when the cluster requires it, is created on first use and shared by the sessions.
+func connectToHDFS() (*hdfsRepositoryClient, error) {
+	hdfsClientLock.Lock()
+	defer hdfsClientLock.Unlock()
+	if blockarchive.HDFS == nil {
+		return nil, errors.New("no HDFS cluster configured")
+	}
+	if hdfsClient == nil || hdfsClientConfig != blockarchive.HDFS {
+		client, err := webhdfs.NewClient(blockarchive.HDFSClientConfig())
+		if err != nil {
+			loggerArchive.Warningf("HDFS cluster [%s] is unreachable [%s]", blockarchive.HDFS.URL(), err)
+			return nil, err
+		}
+		hdfsClient, hdfsClientConfig = client, blockarchive.HDFS
+	}
+	return &hdfsRepositoryClient{Client: hdfsClient}, nil
+}
+
+// hasRepositoryAPI tells if the repository of a session serves the API of blockarchive.RepositoryAPIURL,
+// which is the one of the repository server accessed over SFTP
+func hasRepositoryAPI(client repositoryClient) bool {
+	_, overSFTP := client.(*sftpRepositoryClient)
+	return blockarchive.RepositoryAPIURL != "" && overSFTP
+}
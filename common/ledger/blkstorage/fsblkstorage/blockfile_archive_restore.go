@@ -0,0 +1,318 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// restoreRecordBatchSize is the largest number of restored blockfiles whose records are updated at once
+const restoreRecordBatchSize = 16
+
+// restoreRange brings back onto the local file system the archived blockfiles which
+// contain blocks of the range and have been discarded, and records them as not discarded anymore.
+// If ttl is not zero, the restored blockfiles are discarded again once it has elapsed.
+// progress, if not nil, is notified as the blockfiles are restored.
+func (arch *blockfileArchiver) restoreRange(firstBlockNum, lastBlockNum uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error {
+	if firstBlockNum > lastBlockNum {
+		return errors.Errorf("invalid block range [%d-%d]", firstBlockNum, lastBlockNum)
+	}
+	if ttl < 0 {
+		return errors.Errorf("invalid restore TTL %s", ttl)
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return err
+	}
+	defer arch.scheduleRestoreExpiry()
+	var discarded []*archive.ArchivedBlockfileInfo
+	for _, info := range infos {
+		if info.LastBlockNum < firstBlockNum || info.FirstBlockNum > lastBlockNum {
+			continue
+		}
+		if !info.Discarded {
+			// A blockfile restored temporarily before is kept as long as the longest of the restores requires
+			if info.RestoreExpiry != nil {
+				if err := arch.extendRestoreExpiry(info, ttl); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		discarded = append(discarded, info)
+	}
+	return arch.restoreBlockfiles(discarded, ttl, progress)
+}
+
+// blockfileDownload is the outcome of the download of a blockfile being restored
+type blockfileDownload struct {
+	// downloaded is false if the blockfile was already on the local file system
+	downloaded bool
+	written    int64
+	start      time.Time
+	err        error
+}
+
+// restoreBlockfiles downloads the discarded blockfiles of a range, sorted by block number, with up to
+// blockarchive.RestoreParallelism downloads at the same time. The downloaded blockfiles are recorded as
+// restored in order and in batches, so the restored blockfiles are always the first ones of the range,
+// even when a download fails part way.
+func (arch *blockfileArchiver) restoreBlockfiles(infos []*archive.ArchivedBlockfileInfo, ttl time.Duration, progress blockarchive.RestoreProgress) error {
+	if progress == nil {
+		progress = func(restored, total int) {}
+	}
+	total := len(infos)
+	progress(0, total)
+	if total == 0 {
+		return nil
+	}
+	parallelism := blockarchive.RestoreParallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	if parallelism > total {
+		parallelism = total
+	}
+
+	downloads := make([]chan *blockfileDownload, total)
+	next := make(chan int, total)
+	for i := range infos {
+		downloads[i] = make(chan *blockfileDownload, 1)
+		next <- i
+	}
+	close(next)
+	abort := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				select {
+				case <-abort:
+					return
+				default:
+				}
+				downloads[i] <- arch.downloadBlockfile(infos[i])
+			}
+		}()
+	}
+
+	results := make([]*blockfileDownload, total)
+	restored := 0
+	// record records the blockfiles downloaded since the last batch as restored
+	record := func(upTo int) error {
+		if upTo == restored {
+			return nil
+		}
+		if err := arch.recordRestoredBlockfiles(infos[restored:upTo], ttl); err != nil {
+			return err
+		}
+		for i := restored; i < upTo; i++ {
+			loggerRetrieve.Infow("Restored blockfile", append(archivedBlockfileLogFields(infos[i]),
+				blockarchive.LogKeyBytes, results[i].written, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(results[i].start), "ttl", ttl.String())...)
+		}
+		restored = upTo
+		progress(restored, total)
+		return nil
+	}
+
+	var err error
+	for i := range infos {
+		select {
+		case results[i] = <-downloads[i]:
+		default:
+			// The blockfiles downloaded so far are made available while the next one is downloaded
+			if err = record(i); err == nil {
+				results[i] = <-downloads[i]
+			}
+		}
+		if err == nil {
+			if err = results[i].err; err != nil {
+				// The blockfiles downloaded before the failure are restored nonetheless
+				if recordErr := record(i); recordErr != nil {
+					loggerRetrieve.Errorf("[%s] Failed recording the restored blockfiles: %s", arch.chainID, recordErr)
+				}
+			}
+		}
+		if err == nil && i+1-restored >= restoreRecordBatchSize {
+			err = record(i + 1)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		return record(total)
+	}
+
+	// The blockfiles following the failure are not restored, the ones already downloaded are removed
+	close(abort)
+	wg.Wait()
+	for i := restored; i < total; i++ {
+		if results[i] == nil {
+			select {
+			case results[i] = <-downloads[i]:
+			default:
+			}
+		}
+		if results[i] != nil && results[i].downloaded {
+			if err := os.Remove(deriveBlockfilePath(arch.mgr.rootDir, int(infos[i].BlockfileNo))); err != nil {
+				loggerRetrieve.Warnw("Failed removing blockfile of failed restore", append(archivedBlockfileLogFields(infos[i]), "error", err)...)
+			}
+		}
+	}
+	return err
+}
+
+// downloadBlockfile downloads an archived blockfile from the repository into the local file system,
+// unless it is already there
+func (arch *blockfileArchiver) downloadBlockfile(info *archive.ArchivedBlockfileInfo) *blockfileDownload {
+	fileNum := int(info.BlockfileNo)
+	localPath := deriveBlockfilePath(arch.mgr.rootDir, fileNum)
+	download := &blockfileDownload{start: time.Now()}
+	if _, err := os.Stat(localPath); err == nil {
+		return download
+	}
+	if !blockarchive.IsFetchEnabled(arch.chainID) {
+		download.err = errFetchDisabled(arch.chainID)
+		return download
+	}
+	written, err := fetchBlockfileFromRepo(arch.chainID, info.Location, localPath, info.Checksum)
+	if err != nil {
+		loggerRetrieve.Errorw("Failed restoring blockfile", append(archivedBlockfileLogFields(info), "error", err)...)
+		download.err = errors.WithMessagef(err, "error restoring blockfile [%d] of channel [%s]", fileNum, arch.chainID)
+		return download
+	}
+	download.downloaded, download.written = true, written
+	return download
+}
+
+// recordRestoredBlockfiles records the downloaded blockfiles as not discarded anymore
+func (arch *blockfileArchiver) recordRestoredBlockfiles(infos []*archive.ArchivedBlockfileInfo, ttl time.Duration) error {
+	var expiry *timestamp.Timestamp
+	if ttl > 0 {
+		var err error
+		if expiry, err = ptypes.TimestampProto(time.Now().Add(ttl)); err != nil {
+			return err
+		}
+	}
+	for _, info := range infos {
+		info.Discarded = false
+		info.RestoreExpiry = expiry
+	}
+	if err := arch.catalog.recordArchivedBlockfiles(infos); err != nil {
+		return err
+	}
+	arch.updateOldestLocalBlockGauge()
+	return nil
+}
+
+// extendRestoreExpiry postpones the expiry of a restored blockfile to the end of ttl if it is later,
+// or removes it if ttl is zero
+func (arch *blockfileArchiver) extendRestoreExpiry(info *archive.ArchivedBlockfileInfo, ttl time.Duration) error {
+	if ttl == 0 {
+		info.RestoreExpiry = nil
+		return arch.catalog.recordArchivedBlockfile(info)
+	}
+	current, err := ptypes.Timestamp(info.RestoreExpiry)
+	if err != nil {
+		return err
+	}
+	if expiry := time.Now().Add(ttl); expiry.After(current) {
+		if info.RestoreExpiry, err = ptypes.TimestampProto(expiry); err != nil {
+			return err
+		}
+		return arch.catalog.recordArchivedBlockfile(info)
+	}
+	return nil
+}
+
+// scheduleRestoreExpiry arms the timer which discards the restored blockfiles at the earliest expiry
+func (arch *blockfileArchiver) scheduleRestoreExpiry() {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed reading the archive catalog to schedule the restore expiry: %s", arch.chainID, err)
+		return
+	}
+	var earliest time.Time
+	for _, info := range infos {
+		if info.Discarded || info.RestoreExpiry == nil {
+			continue
+		}
+		expiry, err := ptypes.Timestamp(info.RestoreExpiry)
+		if err != nil {
+			continue
+		}
+		if earliest.IsZero() || expiry.Before(earliest) {
+			earliest = expiry
+		}
+	}
+
+	arch.expiryLock.Lock()
+	defer arch.expiryLock.Unlock()
+	if arch.expiryTimer != nil {
+		arch.expiryTimer.Stop()
+		arch.expiryTimer = nil
+	}
+	if arch.closed || earliest.IsZero() {
+		return
+	}
+	arch.expiryTimer = time.AfterFunc(time.Until(earliest), arch.expireRestoredBlockfiles)
+}
+
+// expireRestoredBlockfiles discards again the restored blockfiles whose expiry has passed.
+// They are still archived on the repository.
+func (arch *blockfileArchiver) expireRestoredBlockfiles() {
+	defer arch.scheduleRestoreExpiry()
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed reading the archive catalog to expire the restored blockfiles: %s", arch.chainID, err)
+		return
+	}
+	now := time.Now()
+	for _, info := range infos {
+		if info.Discarded || info.RestoreExpiry == nil {
+			continue
+		}
+		if expiry, err := ptypes.Timestamp(info.RestoreExpiry); err != nil || expiry.After(now) {
+			continue
+		}
+		fileNum := int(info.BlockfileNo)
+		if err := arch.catalog.discardBlockfile(arch.mgr.rootDir, info); err != nil {
+			loggerDiscard.Errorw("Failed discarding restored blockfile", append(archivedBlockfileLogFields(info), "error", err)...)
+			continue
+		}
+		loggerDiscard.Infow("Discarded restored blockfile, its restore has expired", archivedBlockfileLogFields(info)...)
+		arch.notifyDiscarded(fileNum)
+	}
+}
+
+// close stops the archiving and the expiry of the restored blockfiles
+func (arch *blockfileArchiver) close() {
+	unregisterArchiver(arch)
+	arch.archivingLock.Lock()
+	if arch.stopArchiving != nil {
+		close(arch.stopArchiving)
+		arch.stopArchiving, arch.archivingStopped = nil, nil
+	}
+	arch.archivingLock.Unlock()
+
+	arch.expiryLock.Lock()
+	defer arch.expiryLock.Unlock()
+	arch.closed = true
+	if arch.expiryTimer != nil {
+		arch.expiryTimer.Stop()
+		arch.expiryTimer = nil
+	}
+}
@@ -0,0 +1,286 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestRepository(t *testing.T) (*repository.Server, func()) {
+	return startConfiguredTestRepository(t, func(*repository.Config) {})
+}
+
+// startConfiguredTestRepository starts a repository whose configuration is adjusted by configure
+func startConfiguredTestRepository(t *testing.T, configure func(*repository.Config)) (*repository.Server, func()) {
+	repoDir, err := ioutil.TempDir("", "blkarchiver-repo")
+	require.NoError(t, err)
+	rootDir := filepath.Join(repoDir, "root")
+	require.NoError(t, os.MkdirAll(rootDir, 0755))
+	config := &repository.Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       rootDir,
+		DataDir:       filepath.Join(repoDir, "data"),
+		Users:         []repository.User{{Name: "root", Password: "blkstore"}},
+	}
+	configure(config)
+	server, err := repository.NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+
+	prevURL, prevDir := blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir
+	blockarchive.BlockArchiverURL = server.Addr().String()
+	blockarchive.BlockArchiverDir = "/blkstore"
+	return server, func() {
+		blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir = prevURL, prevDir
+		server.Stop()
+		os.RemoveAll(repoDir)
+	}
+}
+
+func TestRestoreRange(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	localPath := deriveBlockfilePath(arch.mgr.rootDir, 0)
+	content, err := ioutil.ReadFile(localPath)
+	require.NoError(t, err)
+
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	alreadyArchived, err := sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.False(t, alreadyArchived)
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+	_, err = os.Stat(localPath)
+	require.True(t, os.IsNotExist(err))
+
+	ranges, err := store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	require.Len(t, ranges, 1)
+
+	assert.Error(t, store.RestoreRange(5, 2, 0, nil))
+	require.NoError(t, store.RestoreRange(ranges[0].LastBlockNum, ranges[0].LastBlockNum+5, 0, nil))
+
+	restored, err := ioutil.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, content, restored)
+	ranges, err = store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+
+	block, err := store.RetrieveBlockByNumber(0)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[0], block)
+
+	// Nothing to restore
+	assert.NoError(t, store.RestoreRange(0, 29, 0, nil))
+}
+
+func TestRestoreRangeTTL(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	for _, fileNum := range []int{0, 1} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, true))
+	}
+	discarded := func(fileNum int) bool {
+		_, err := os.Stat(deriveBlockfilePath(arch.mgr.rootDir, fileNum))
+		return os.IsNotExist(err)
+	}
+
+	assert.EqualError(t, store.RestoreRange(0, 19, -time.Second, nil), "invalid restore TTL -1s")
+
+	require.NoError(t, store.RestoreRange(0, 19, 500*time.Millisecond, nil))
+	require.False(t, discarded(0))
+	require.False(t, discarded(1))
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	assert.NotNil(t, info.RestoreExpiry)
+
+	// Restoring blockfile [1] again without TTL keeps it
+	require.NoError(t, store.RestoreRange(10, 19, 0, nil))
+	info, err = arch.catalog.getArchivedBlockfile(1)
+	require.NoError(t, err)
+	assert.Nil(t, info.RestoreExpiry)
+
+	var ranges []*archive.ArchivedBlockRange
+	deadline := time.Now().Add(10 * time.Second)
+	for len(ranges) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		ranges, err = store.GetArchiveCatalog().GetDiscardedRanges()
+		require.NoError(t, err)
+	}
+	require.Len(t, ranges, 1)
+	assert.True(t, discarded(0))
+	assert.False(t, discarded(1))
+	assert.Equal(t, uint64(0), ranges[0].FirstBlockNum)
+	assert.Equal(t, uint64(9), ranges[0].LastBlockNum)
+
+	// The discarded block is still retrieved from the repository
+	block, err := store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[5], block)
+}
+
+func TestRestoreRangeInParallel(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+	defer func(parallelism int) { blockarchive.RestoreParallelism = parallelism }(blockarchive.RestoreParallelism)
+	blockarchive.RestoreParallelism = 3
+
+	blocks := testutil.ConstructTestBlocks(t, 60)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	archiveAndDiscard := func() {
+		for fileNum := 0; fileNum < 5; fileNum++ {
+			location, err := arch.archiveLocation(fileNum)
+			require.NoError(t, err)
+			_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+			require.NoError(t, err)
+			require.NoError(t, arch.handleArchivedBlockfile(fileNum, true))
+		}
+	}
+	discarded := func(fileNum int) bool {
+		_, err := os.Stat(deriveBlockfilePath(arch.mgr.rootDir, fileNum))
+		return os.IsNotExist(err)
+	}
+	archiveAndDiscard()
+
+	// The progress is reported in the order of the blockfiles
+	var progress [][2]int
+	require.NoError(t, store.RestoreRange(0, 49, 0, func(restored, total int) {
+		progress = append(progress, [2]int{restored, total})
+	}))
+	require.NotEmpty(t, progress)
+	assert.Equal(t, [2]int{0, 5}, progress[0])
+	assert.Equal(t, [2]int{5, 5}, progress[len(progress)-1])
+	for i := 1; i < len(progress); i++ {
+		assert.True(t, progress[i][0] > progress[i-1][0])
+	}
+	ranges, err := store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+	for blockNum := 0; blockNum < 50; blockNum += 7 {
+		block, err := store.RetrieveBlockByNumber(uint64(blockNum))
+		require.NoError(t, err)
+		assert.Equal(t, blocks[blockNum], block)
+	}
+
+	// A failed download leaves the blockfiles before it restored and the ones after it discarded
+	for fileNum := 0; fileNum < 5; fileNum++ {
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		require.NoError(t, err)
+		require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+	}
+	info, err := arch.catalog.getArchivedBlockfile(2)
+	require.NoError(t, err)
+	checksum := info.Checksum
+	info.Checksum = "sha256:" + strings.Repeat("0", 64)
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(info))
+
+	progress = nil
+	assert.Error(t, store.RestoreRange(0, 49, 0, func(restored, total int) {
+		progress = append(progress, [2]int{restored, total})
+	}))
+	assert.Equal(t, [2]int{2, 5}, progress[len(progress)-1])
+	for fileNum := 0; fileNum < 5; fileNum++ {
+		assert.Equal(t, fileNum >= 2, discarded(fileNum), "blockfile [%d]", fileNum)
+	}
+	ranges, err = store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, info.FirstBlockNum, ranges[0].FirstBlockNum)
+
+	// The restore completes once the blockfile can be downloaded
+	info.Checksum = checksum
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(info))
+	require.NoError(t, store.RestoreRange(0, 49, 0, nil))
+	ranges, err = store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+}
@@ -0,0 +1,186 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+var uploadResumeTokenKey = []byte("archiverUploadResume")
+
+// uploadResumeInterval is the number of bytes uploaded between the saves of the resume token of an upload,
+// so that only the uploads of the blockfiles larger than it are resumed
+var uploadResumeInterval int64 = 8 * 1024 * 1024
+
+// uploadResumeToken records how far the upload of a blockfile to its temporary file on the repository went,
+// so that a restart of the peer resumes the upload from there rather than from the beginning of the blockfile.
+// It is persisted in the same db as the checkpoint of the archiver.
+type uploadResumeToken struct {
+	// Postfix number of the blockfile being uploaded
+	fileNum int
+	// Path on the repository of the temporary file the blockfile is uploaded to
+	tmpFilePath string
+	// Number of bytes of the blockfile written to the temporary file
+	offset int64
+}
+
+func (t *uploadResumeToken) marshal() ([]byte, error) {
+	buffer := proto.NewBuffer([]byte{})
+	if err := buffer.EncodeVarint(uint64(t.fileNum)); err != nil {
+		return nil, err
+	}
+	if err := buffer.EncodeStringBytes(t.tmpFilePath); err != nil {
+		return nil, err
+	}
+	if err := buffer.EncodeVarint(uint64(t.offset)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (t *uploadResumeToken) unmarshal(b []byte) error {
+	buffer := proto.NewBuffer(b)
+	var val uint64
+	var err error
+
+	if val, err = buffer.DecodeVarint(); err != nil {
+		return err
+	}
+	t.fileNum = int(val)
+
+	if t.tmpFilePath, err = buffer.DecodeStringBytes(); err != nil {
+		return err
+	}
+
+	if val, err = buffer.DecodeVarint(); err != nil {
+		return err
+	}
+	t.offset = int64(val)
+	return nil
+}
+
+// uploadResumer persists the progress of the upload of a blockfile so that it can be resumed
+type uploadResumer interface {
+	// resumeOffset returns the number of bytes already written to the temporary file, 0 if none
+	resumeOffset(tmpFilePath string) int64
+	// saveResumeOffset records the number of bytes written to the temporary file
+	saveResumeOffset(tmpFilePath string, offset int64) error
+	// clearResumeOffset forgets the progress once the upload has completed or has to restart
+	clearResumeOffset()
+}
+
+// blockfileUploadResumer persists the progress of the upload of a blockfile of the archiver
+type blockfileUploadResumer struct {
+	arch    *blockfileArchiver
+	fileNum int
+}
+
+func (r *blockfileUploadResumer) resumeOffset(tmpFilePath string) int64 {
+	b, err := r.arch.mgr.db.Get(uploadResumeTokenKey)
+	if err != nil || b == nil {
+		return 0
+	}
+	token := &uploadResumeToken{}
+	if err := token.unmarshal(b); err != nil {
+		loggerUpload.Warnw("Ignored the corrupted resume token of the upload", "error", err)
+		return 0
+	}
+	if token.fileNum != r.fileNum || token.tmpFilePath != tmpFilePath {
+		return 0
+	}
+	return token.offset
+}
+
+func (r *blockfileUploadResumer) saveResumeOffset(tmpFilePath string, offset int64) error {
+	token := &uploadResumeToken{fileNum: r.fileNum, tmpFilePath: tmpFilePath, offset: offset}
+	b, err := token.marshal()
+	if err != nil {
+		return errors.Wrap(err, "error marshaling upload resume token")
+	}
+	if err := r.arch.mgr.db.Put(uploadResumeTokenKey, b, true); err != nil {
+		return errors.Wrap(err, "error writing upload resume token")
+	}
+	return nil
+}
+
+func (r *blockfileUploadResumer) clearResumeOffset() {
+	if err := r.arch.mgr.db.Delete(uploadResumeTokenKey, true); err != nil {
+		loggerUpload.Warnw("Failed clearing the resume token of the upload", "error", err)
+	}
+}
+
+// openUploadFile opens the temporary file a blockfile is uploaded to on the repository, and returns the offset
+// in the blockfile where the upload starts. An upload recorded by the resumer is resumed where it stopped, after
+// the checksum writer has been fed with the part of the local blockfile already uploaded. Otherwise the temporary
+// file is created, replacing a previous partial upload if any.
+func openUploadFile(client repositoryClient, srcFile *os.File, tmpFilePath string, checksumWriter io.Writer, resumer uploadResumer) (repositoryFile, int64, error) {
+	if resumer != nil {
+		if offset := resumer.resumeOffset(tmpFilePath); offset > 0 {
+			dstFile, err := seekUploadFile(client, srcFile, tmpFilePath, offset)
+			if err == nil {
+				if _, err := io.CopyN(checksumWriter, srcFile, offset); err != nil {
+					dstFile.Close()
+					return nil, 0, err
+				}
+				return dstFile, offset, nil
+			}
+			loggerUpload.Warnw("Failed resuming the upload, restarting it", "location", tmpFilePath, "offset", offset, "error", err)
+			resumer.clearResumeOffset()
+		}
+	}
+	dstFile, err := client.Create(tmpFilePath)
+	return dstFile, 0, err
+}
+
+// seekUploadFile opens a partial upload on the repository at the offset where it resumes. The upload is
+// the beginning of the finalized blockfile, so the bytes written past the offset before the interruption,
+// if any, are overwritten with the same content.
+func seekUploadFile(client repositoryClient, srcFile *os.File, tmpFilePath string, offset int64) (repositoryFile, error) {
+	if info, err := srcFile.Stat(); err != nil {
+		return nil, err
+	} else if info.Size() < offset {
+		return nil, errors.Errorf("blockfile shorter than the resume offset")
+	}
+	if info, err := client.Stat(tmpFilePath); err != nil {
+		return nil, err
+	} else if info.Size() < offset {
+		return nil, errors.Errorf("partial upload of %d bytes shorter than the resume offset", info.Size())
+	}
+	dstFile, err := client.OpenFile(tmpFilePath, os.O_WRONLY)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dstFile.Seek(offset, io.SeekStart); err != nil {
+		dstFile.Close()
+		return nil, err
+	}
+	return dstFile, nil
+}
+
+// copyResumable copies src to dst, saving with the resumer the offset reached in the blockfile after each
+// uploadResumeInterval bytes when the resumer is not nil. It returns the number of bytes copied.
+func copyResumable(dst io.Writer, src io.Reader, tmpFilePath string, offset int64, resumer uploadResumer) (int64, error) {
+	if resumer == nil {
+		return io.Copy(dst, src)
+	}
+	var written int64
+	for {
+		n, err := io.CopyN(dst, src, uploadResumeInterval)
+		written += n
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		if err := resumer.saveResumeOffset(tmpFilePath, offset+written); err != nil {
+			return written, err
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingResumer records the offsets saved by an upload, and fails the save after maxSaves of them
+type recordingResumer struct {
+	*blockfileUploadResumer
+	saved    []int64
+	maxSaves int
+}
+
+func (r *recordingResumer) saveResumeOffset(tmpFilePath string, offset int64) error {
+	if r.maxSaves > 0 && len(r.saved) == r.maxSaves {
+		return errors.New("peer stopped")
+	}
+	r.saved = append(r.saved, offset)
+	return r.blockfileUploadResumer.saveResumeOffset(tmpFilePath, offset)
+}
+
+func TestResumeUpload(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+
+	localBytes, err := ioutil.ReadFile(deriveBlockfilePath(arch.blockfileDir, 1))
+	require.NoError(t, err)
+	prevInterval := uploadResumeInterval
+	defer func() { uploadResumeInterval = prevInterval }()
+	uploadResumeInterval = int64(len(localBytes) / 5)
+	location := deriveArchivedBlockfilePath(arch.blockfileDir, 1)
+	tmpFilePath := location + uploadingSuffix
+
+	// The upload interrupted after two intervals is kept on the repository along with its resume token
+	interrupted := &recordingResumer{blockfileUploadResumer: &blockfileUploadResumer{arch: arch, fileNum: 1}, maxSaves: 2}
+	_, err = sendResumableBlockfileToRepo(arch.blockfileDir, 1, location, interrupted, nil)
+	require.Error(t, err)
+	sshConn, client, err := connectToRepo(arch.chainID)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	_, err = client.Stat(tmpFilePath)
+	require.NoError(t, err)
+	resumer := &recordingResumer{blockfileUploadResumer: &blockfileUploadResumer{arch: arch, fileNum: 1}}
+	assert.Equal(t, 2*uploadResumeInterval, resumer.resumeOffset(tmpFilePath))
+	// The token is only valid for the same blockfile
+	assert.Zero(t, (&blockfileUploadResumer{arch: arch, fileNum: 2}).resumeOffset(tmpFilePath))
+
+	// The next upload resumes from the token
+	_, err = sendResumableBlockfileToRepo(arch.blockfileDir, 1, location, resumer, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, resumer.saved)
+	assert.Equal(t, 3*uploadResumeInterval, resumer.saved[0])
+	file, err := client.Open(location)
+	require.NoError(t, err)
+	remoteBytes, err := ioutil.ReadAll(file)
+	file.Close()
+	require.NoError(t, err)
+	assert.Equal(t, localBytes, remoteBytes)
+	_, err = client.Stat(tmpFilePath)
+	assert.True(t, os.IsNotExist(err))
+	assert.Zero(t, resumer.resumeOffset(tmpFilePath))
+
+	// A token whose partial upload is gone restarts the upload from the beginning
+	require.NoError(t, resumer.saveResumeOffset(tmpFilePath, uploadResumeInterval))
+	require.NoError(t, client.Remove(location))
+	resumer.saved = nil
+	_, err = sendResumableBlockfileToRepo(arch.blockfileDir, 1, location, resumer, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, resumer.saved)
+	assert.Equal(t, uploadResumeInterval, resumer.saved[0])
+}
@@ -0,0 +1,66 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Key prefix of the config blocks of the discarded blockfiles in the index db
+	retainedBlockKeyPrefix = 'c'
+)
+
+// retainConfigBlocks adds to the batch discarding a blockfile its config blocks and the genesis block,
+// keyed by their location, so that they are still read from the local file system once the blockfile has
+// been discarded. The channel config is then retrieved without accessing the repository. It returns the
+// number of retained blocks.
+func retainConfigBlocks(batch *leveldbhelper.UpdateBatch, blockfileDir string, fileNum int) (int, error) {
+	retained := 0
+	err := ScanRawBlockfile(deriveBlockfilePath(blockfileDir, fileNum), func(offset int64, block *common.Block) error {
+		if block.Header.Number != 0 && !protoutil.IsConfigBlock(block) {
+			return nil
+		}
+		b, _, err := serializeBlock(block)
+		if err != nil {
+			return errors.WithMessagef(err, "error serializing config block [%d]", block.Header.Number)
+		}
+		batch.Put(constructRetainedBlockKey(fileNum, offset), b)
+		retained++
+		return nil
+	})
+	if err != nil {
+		return 0, errors.WithMessagef(err, "error reading the config blocks of blockfile [%d]", fileNum)
+	}
+	return retained, nil
+}
+
+// retainedBlockBytes returns the block at the location if its blockfile has been discarded
+// and the block has been retained, nil otherwise
+func (mgr *blockfileMgr) retainedBlockBytes(lp *fileLocPointer) ([]byte, error) {
+	if !blockarchive.IsArchiver && !blockarchive.IsClient {
+		return nil, nil
+	}
+	if _, err := os.Stat(deriveBlockfilePath(mgr.rootDir, lp.fileSuffixNum)); !os.IsNotExist(err) {
+		return nil, nil
+	}
+	b, err := mgr.db.Get(constructRetainedBlockKey(lp.fileSuffixNum, int64(lp.offset)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading retained block at %s", lp)
+	}
+	return b, nil
+}
+
+func constructRetainedBlockKey(fileNum int, offset int64) []byte {
+	key := append([]byte{retainedBlockKeyPrefix}, util.EncodeOrderPreservingVarUint64(uint64(fileNum))...)
+	return append(key, util.EncodeOrderPreservingVarUint64(uint64(offset))...)
+}
@@ -0,0 +1,60 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetainConfigBlocks(t *testing.T) {
+	prevIsArchiver, prevRetainConfigBlocks := blockarchive.IsArchiver, blockarchive.RetainConfigBlocks
+	blockarchive.IsArchiver, blockarchive.RetainConfigBlocks = true, true
+	defer func() { blockarchive.IsArchiver, blockarchive.RetainConfigBlocks = prevIsArchiver, prevRetainConfigBlocks }()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	for _, fileNum := range []int{0, 1} {
+		require.NoError(t, arch.recordArchivedBlockfile(fileNum, false))
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		require.NoError(t, err)
+		require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+	}
+
+	// The genesis block is read locally, whereas the other discarded blocks are read from the repository,
+	// which is not configured
+	block, err := store.RetrieveBlockByNumber(0)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[0], block))
+	header, err := store.(*fsBlockStore).fileMgr.retrieveBlockHeaderByNumber(0)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[0].Header, header))
+	_, err = store.RetrieveBlockByNumber(1)
+	assert.Error(t, err)
+	_, err = store.RetrieveBlockByNumber(12)
+	assert.Error(t, err)
+	block, err = store.RetrieveBlockByNumber(25)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[25], block))
+}
@@ -0,0 +1,89 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// RecordSidecarArchived records the blockfiles of a ledger archived by the peer-archiver sidecar in the archive
+// catalog of the ledger, once they are verified against the local blockfiles, and lets the client peers know.
+// The archived blockfiles are then discarded as if the peer had archived them, except the latest ones kept on
+// the local file system. It returns the numbers of the reported blockfiles recorded in the catalog, along with
+// the ones recorded by an earlier report, and the number of blockfiles discarded.
+func RecordSidecarArchived(ledgerID string, infos []*archive.ArchivedBlockfileInfo) ([]uint64, int, error) {
+	arch := getArchiver(ledgerID)
+	if arch == nil {
+		return nil, 0, errors.Errorf("ledger [%s] is not open on this peer", ledgerID)
+	}
+	return arch.recordSidecarArchived(infos)
+}
+
+func (arch *blockfileArchiver) recordSidecarArchived(infos []*archive.ArchivedBlockfileInfo) ([]uint64, int, error) {
+	arch.archivingLock.Lock()
+	defer arch.archivingLock.Unlock()
+	if err := arch.loadCheckpoint(); err != nil {
+		return nil, 0, err
+	}
+
+	infos = append([]*archive.ArchivedBlockfileInfo(nil), infos...)
+	sort.Slice(infos, func(i, j int) bool { return infos[i].BlockfileNo < infos[j].BlockfileNo })
+	var recorded []uint64
+	next := arch.checkpoint.nextBlockfileNum
+	for _, info := range infos {
+		fileNum := int(info.BlockfileNo)
+		if info.ChannelID != arch.chainID {
+			return recorded, 0, errors.Errorf("blockfile [%d] of ledger [%s] reported for ledger [%s]", fileNum, info.ChannelID, arch.chainID)
+		}
+		if err := arch.checkFinalized(fileNum); err != nil {
+			return recorded, 0, err
+		}
+		local, err := arch.catalog.getArchivedBlockfile(info.BlockfileNo)
+		if err != nil {
+			return recorded, 0, err
+		}
+		if local == nil {
+			if err := arch.verifyArchivedBlockfile(info); err != nil {
+				return recorded, 0, err
+			}
+			record := proto.Clone(info).(*archive.ArchivedBlockfileInfo)
+			record.Discarded, record.RestoreExpiry = false, nil
+			if err := arch.catalog.recordArchivedBlockfile(record); err != nil {
+				return recorded, 0, err
+			}
+			loggerUpload.Infow("Recorded blockfile archived by the sidecar", append(archivedBlockfileLogFields(record), "location", record.Location)...)
+			arch.sendArchivedMessage(fileNum)
+			arch.updateNewestArchivedBlockGauge(record)
+		}
+		recorded = append(recorded, info.BlockfileNo)
+		if fileNum+1 > next {
+			next = fileNum + 1
+		}
+	}
+
+	// The latest blockfiles are kept whatever the sidecar has archived
+	if limit := arch.mgr.currentFileNum() - blockarchive.KeepLatestBlockfilesOf(arch.chainID); next > limit {
+		next = limit
+	}
+	if next > arch.checkpoint.nextBlockfileNum {
+		if err := arch.saveCheckpoint(next, noInFlightBlockfile, false); err != nil {
+			return recorded, 0, err
+		}
+	}
+	arch.advertiseArchiveInfo()
+
+	// The discard is deferred while the disk usage allows it, the discard schedule applies to the archiving passes of the peer only
+	needed := func() bool { return true }
+	if blockarchive.IsDiscardDeferred() {
+		threshold := blockarchive.DiscardDiskUsageThreshold
+		needed = func() bool { return arch.needsDiskSpace(threshold) }
+	}
+	return recorded, arch.discardArchivedBlockfiles(needed), nil
+}
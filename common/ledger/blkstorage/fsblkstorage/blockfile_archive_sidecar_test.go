@@ -0,0 +1,109 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSidecarArchived(t *testing.T) {
+	prevSidecar, prevKeep := blockarchive.IsSidecarArchiving, blockarchive.NumKeepLatestBlocks
+	defer func() { blockarchive.IsSidecarArchiving, blockarchive.NumKeepLatestBlocks = prevSidecar, prevKeep }()
+	blockarchive.IsSidecarArchiving, blockarchive.NumKeepLatestBlocks = true, 1
+
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	require.Equal(t, 4, arch.mgr.currentFileNum())
+
+	// The peer doesn't archive the blockfiles itself
+	nextBlockfileNum := arch.checkpoint.nextBlockfileNum
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	// The blockfiles as archived by the sidecar
+	scan := func(fileNum int) *archive.ArchivedBlockfileInfo {
+		summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+		require.NoError(t, err)
+		checksum, err := blockarchive.ComputeBlockfileChecksum(deriveBlockfilePath(arch.blockfileDir, fileNum), blockarchive.ChecksumSHA256)
+		require.NoError(t, err)
+		return &archive.ArchivedBlockfileInfo{
+			ChannelID:     "testLedger",
+			BlockfileNo:   uint64(fileNum),
+			FirstBlockNum: summary.firstBlockNum,
+			LastBlockNum:  summary.lastBlockNum,
+			Checksum:      checksum.String(),
+			Location:      fmt.Sprintf("/blkstore/chains/testLedger/blockfile_%06d", fileNum),
+		}
+	}
+	reported := map[int]*archive.ArchivedBlockfileInfo{}
+	for fileNum := 1; fileNum <= 4; fileNum++ {
+		reported[fileNum] = scan(fileNum)
+	}
+	archived := func(fileNum int) *archive.ArchivedBlockfileInfo {
+		return proto.Clone(reported[fileNum]).(*archive.ArchivedBlockfileInfo)
+	}
+
+	// Blockfiles which don't match the local ones are rejected
+	other := archived(1)
+	other.ChannelID = "otherLedger"
+	_, _, err = RecordSidecarArchived("testLedger", []*archive.ArchivedBlockfileInfo{other})
+	assert.EqualError(t, err, "blockfile [1] of ledger [otherLedger] reported for ledger [testLedger]")
+	shifted := archived(1)
+	shifted.LastBlockNum++
+	_, _, err = RecordSidecarArchived("testLedger", []*archive.ArchivedBlockfileInfo{shifted})
+	assert.Error(t, err)
+	corrupted := archived(1)
+	corrupted.Checksum = archived(2).Checksum
+	_, _, err = RecordSidecarArchived("testLedger", []*archive.ArchivedBlockfileInfo{corrupted})
+	assert.Contains(t, err.Error(), "blockfile [1] of ledger [testLedger] differs from the archived one")
+	_, _, err = RecordSidecarArchived("testLedger", []*archive.ArchivedBlockfileInfo{archived(4)})
+	assert.EqualError(t, err, "blockfile [4] of ledger [testLedger] is not finalized, blockfile [4] is being written")
+	_, _, err = RecordSidecarArchived("unknownLedger", []*archive.ArchivedBlockfileInfo{archived(1)})
+	assert.EqualError(t, err, "ledger [unknownLedger] is not open on this peer")
+	assert.Equal(t, nextBlockfileNum, arch.checkpoint.nextBlockfileNum)
+
+	// The reported blockfiles are recorded and discarded, but the latest one
+	recorded, discarded, err := RecordSidecarArchived("testLedger", []*archive.ArchivedBlockfileInfo{archived(3), archived(1), archived(2)})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3}, recorded)
+	assert.Equal(t, 2, discarded)
+	assert.Equal(t, 3, arch.checkpoint.nextBlockfileNum)
+	for fileNum := 1; fileNum <= 3; fileNum++ {
+		info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+		require.NoError(t, err)
+		require.NotNil(t, info)
+		expected := archived(fileNum)
+		assert.Equal(t, expected.Checksum, info.Checksum)
+		assert.Equal(t, expected.Location, info.Location)
+		assert.Equal(t, fileNum < 3, info.Discarded, "blockfile [%d]", fileNum)
+		_, err = os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+		assert.Equal(t, fileNum < 3, os.IsNotExist(err), "blockfile [%d]", fileNum)
+	}
+
+	// A blockfile reported again is left as it is
+	recorded, discarded, err = RecordSidecarArchived("testLedger", []*archive.ArchivedBlockfileInfo{archived(3)})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, recorded)
+	assert.Equal(t, 0, discarded)
+	info, err := arch.catalog.getArchivedBlockfile(3)
+	require.NoError(t, err)
+	assert.False(t, info.Discarded)
+	assert.Equal(t, 3, arch.checkpoint.nextBlockfileNum)
+}
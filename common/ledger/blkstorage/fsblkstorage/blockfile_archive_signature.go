@@ -0,0 +1,125 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// openArchivedBlockfile opens the archived blockfile of a ledger at location on the repository. When
+// VerifyBlockfileSignatures is set, the blockfile is copied through a single handle into a temporary file while
+// its hash is computed, and the copy is served once the hash matches the one of its manifest, signed by a member
+// of the organization for this very blockfile. The blocks served are thus the very bytes verified, which detects
+// the blockfiles tampered with or substituted on the repository, even while they are read. An encoded blockfile,
+// e.g. compressed, is decoded into the temporary file as well, and verified against the checksum of its header.
+func openArchivedBlockfile(client repositoryClient, ledgerID string, fileNum int, location string) (archivedFile, error) {
+	var manifest *archive.ArchiveManifest
+	if blockarchive.VerifyBlockfileSignatures {
+		var err error
+		if manifest, err = readSignedManifest(client, location); err != nil {
+			return nil, err
+		}
+		if manifest.ChannelID != ledgerID || manifest.BlockfileNo != uint64(fileNum) || manifest.Location != location {
+			return nil, errors.Errorf("the manifest of %s is the one of blockfile [%d] of ledger [%s] at %s",
+				location, manifest.BlockfileNo, manifest.ChannelID, manifest.Location)
+		}
+	}
+	remoteFile, err := client.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	content, header, err := blockarchive.NewBlockfileReader(remoteFile)
+	if err != nil {
+		remoteFile.Close()
+		return nil, errors.WithMessagef(err, "error decoding archived blockfile %s", location)
+	}
+	if manifest == nil && header == nil {
+		// The blockfile stored as is is read in place
+		if _, err := remoteFile.Seek(0, io.SeekStart); err != nil {
+			remoteFile.Close()
+			return nil, err
+		}
+		return remoteFile, nil
+	}
+	defer remoteFile.Close()
+	defer content.Close()
+	// The copy is unlinked right away, it is removed from the file system once closed
+	copied, err := ioutil.TempFile("", "archived-blockfile-")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating the copy of archived blockfile %s", location)
+	}
+	os.Remove(copied.Name())
+	var checksumWriter *blockarchive.ChecksumWriter
+	var expected *blockarchive.Checksum
+	var dst io.Writer = copied
+	if header != nil {
+		if expected, err = blockarchive.ParseChecksum(header.Checksum); err == nil {
+			checksumWriter, err = blockarchive.NewChecksumWriter(expected.Algorithm)
+		}
+		if err != nil {
+			copied.Close()
+			return nil, errors.WithMessagef(err, "invalid header of archived blockfile %s", location)
+		}
+		dst = io.MultiWriter(copied, checksumWriter)
+	}
+	hash, err := blockarchive.ComputeHash(io.TeeReader(content, dst))
+	if err != nil {
+		copied.Close()
+		return nil, errors.Wrapf(err, "error reading archived blockfile %s", location)
+	}
+	if checksumWriter != nil {
+		if err := checksumWriter.Verify(expected); err != nil {
+			copied.Close()
+			return nil, errors.WithMessagef(err, "archived blockfile %s is corrupted", location)
+		}
+	}
+	if manifest != nil && !bytes.Equal(hash, manifest.BlockfileHash) {
+		copied.Close()
+		return nil, errors.Errorf("hash of archived blockfile %s [%x] does not match the one in its signed manifest [%x]",
+			location, hash, manifest.BlockfileHash)
+	}
+	if _, err := copied.Seek(0, io.SeekStart); err != nil {
+		copied.Close()
+		return nil, errors.Wrapf(err, "error reading the copy of archived blockfile %s", location)
+	}
+	if manifest != nil {
+		loggerRetrieve.Debugw("Verified the signed manifest of archived blockfile", append(blockfileLogFields(ledgerID, fileNum), "location", location)...)
+	}
+	return copied, nil
+}
+
+// readSignedManifest reads the manifest of the archived blockfile at location on the repository,
+// and verifies its signature against the MSP of the organization
+func readSignedManifest(client repositoryClient, location string) (*archive.ArchiveManifest, error) {
+	file, err := client.Open(location + blockarchive.ManifestSuffix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening the manifest of archived blockfile %s", location)
+	}
+	b, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the manifest of archived blockfile %s", location)
+	}
+	signed := &archive.SignedArchiveManifest{}
+	if err := proto.Unmarshal(b, signed); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling the manifest of archived blockfile %s", location)
+	}
+	if blockarchive.ManifestVerifier == nil {
+		return nil, errors.New("no MSP is configured to verify the signed manifests")
+	}
+	manifest, _, err := blockarchive.VerifyManifest(signed, blockarchive.ManifestVerifier)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid manifest of archived blockfile %s", location)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,120 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyArchivedBlockfileSignature(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	require.NoError(t, msptesttools.LoadMSPSetupForTesting())
+	blockStorePath := testPath()
+	prevVerify, prevVerifier, prevSigner := blockarchive.VerifyBlockfileSignatures, blockarchive.ManifestVerifier, blockarchive.ManifestSigner
+	prevBlockStorePath, prevIsArchiver := blockarchive.BlockStorePath, blockarchive.IsArchiver
+	prevEach := blockarchive.NumBlockfileEachArchiving
+	defer func() {
+		blockarchive.VerifyBlockfileSignatures, blockarchive.ManifestVerifier, blockarchive.ManifestSigner = prevVerify, prevVerifier, prevSigner
+		blockarchive.BlockStorePath, blockarchive.IsArchiver = prevBlockStorePath, prevIsArchiver
+		blockarchive.NumBlockfileEachArchiving = prevEach
+	}()
+	blockarchive.VerifyBlockfileSignatures, blockarchive.ManifestVerifier = true, mgmt.GetLocalMSP()
+	blockarchive.ManifestSigner = mgmt.GetLocalSigningIdentityOrPanic()
+	blockarchive.BlockStorePath, blockarchive.IsArchiver = blockStorePath, true
+	// The archiving is triggered by the test only
+	blockarchive.NumBlockfileEachArchiving = 1000
+
+	// Blockfile 2 is being written, the blockfiles 0 and 1 are archived
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	for _, fileNum := range []int{0, 1} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.publishManifest(fileNum, location))
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, true))
+	}
+
+	// The blockfile read from the repository matches its signed manifest
+	block, err := store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[5], block))
+
+	// A blockfile tampered with in place is refused, even with the size and the modification time it was verified with
+	location0, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	path0 := filepath.Join(repoRootDir, location0)
+	original, err := ioutil.ReadFile(path0)
+	require.NoError(t, err)
+	fileInfo, err := os.Stat(path0)
+	require.NoError(t, err)
+	tampered := append([]byte(nil), original...)
+	tampered[len(tampered)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path0, tampered, 0644))
+	require.NoError(t, os.Chtimes(path0, fileInfo.ModTime(), fileInfo.ModTime()))
+	_, err = store.RetrieveBlockByNumber(5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the one in its signed manifest")
+	require.NoError(t, ioutil.WriteFile(path0, original, 0644))
+
+	// A blockfile substituted with another one on the repository is refused
+	location1, err := arch.archiveLocation(1)
+	require.NoError(t, err)
+	content, err := ioutil.ReadFile(filepath.Join(repoRootDir, location1))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repoRootDir, location0), content, 0644))
+	_, err = store.RetrieveBlockByNumber(5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the one in its signed manifest")
+
+	// Along with its manifest
+	manifest, err := ioutil.ReadFile(filepath.Join(repoRootDir, location1+blockarchive.ManifestSuffix))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repoRootDir, location0+blockarchive.ManifestSuffix), manifest, 0644))
+	_, err = store.RetrieveBlockByNumber(5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is the one of blockfile [1] of ledger [testLedger]")
+
+	// A tampered manifest is refused
+	manifest[len(manifest)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repoRootDir, location1+blockarchive.ManifestSuffix), manifest, 0644))
+	_, err = store.RetrieveBlockByNumber(15)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid manifest of archived blockfile")
+
+	require.NoError(t, os.Remove(filepath.Join(repoRootDir, location1+blockarchive.ManifestSuffix)))
+	_, err = store.RetrieveBlockByNumber(15)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error opening the manifest of archived blockfile")
+}
@@ -0,0 +1,248 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// SnapshotVerification is the verification of an archive snapshot against the peer and the repository
+type SnapshotVerification struct {
+	// Catalog compares the records of the objects in the archive catalog of the peer with the catalog hash
+	// of the snapshot. Its status is CustodyNotChecked when the peer has no ledger of the channel.
+	Catalog *CustodyAudit
+	// Objects are the audits of the objects on the repository against their checksum by blockfile, nil
+	// unless audited
+	Objects map[uint64]*CustodyAudit
+}
+
+// Passed tells if the checks of the verification have all passed, the ones not run aside
+func (v *SnapshotVerification) Passed() bool {
+	if v.Catalog.Status == CustodyFailed {
+		return false
+	}
+	for _, audit := range v.Objects {
+		if audit.Status == CustodyFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// ArchiveSnapshotOf takes the snapshot of the archived history of a ledger stored in blockStorePath: the
+// archived blockfiles recorded in its archive catalog, with the digests of their summaries read from the
+// repository. It must not be called while the peer is running.
+func ArchiveSnapshotOf(blockStorePath, ledgerID string) (*archive.ArchiveSnapshot, error) {
+	var snapshot *archive.ArchiveSnapshot
+	err := withArchiver(blockStorePath, ledgerID, func(arch *blockfileArchiver) (err error) {
+		snapshot, err = arch.archiveSnapshot()
+		return err
+	})
+	return snapshot, err
+}
+
+// VerifyArchiveSnapshot verifies an archive snapshot against the archive catalog of the same ledger stored in
+// blockStorePath, if any. When audit is set, the objects of the snapshot are verified on the repository against
+// their checksum. It must not be called while the peer is running.
+func VerifyArchiveSnapshot(blockStorePath string, snapshot *archive.ArchiveSnapshot, audit bool) (*SnapshotVerification, error) {
+	verification := &SnapshotVerification{Catalog: &CustodyAudit{Status: CustodyNotChecked, Error: "the peer has no ledger of the channel"}}
+	err := withArchiver(blockStorePath, snapshot.ChannelID, func(arch *blockfileArchiver) error {
+		infos, err := arch.catalog.ListArchivedBlockfiles()
+		if err != nil {
+			return err
+		}
+		verification.Catalog = compareSnapshotWithCatalog(snapshot, infos)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, err
+	}
+	if audit {
+		if verification.Objects, err = auditSnapshotObjects(snapshot.ChannelID, snapshot.Objects); err != nil {
+			return nil, err
+		}
+	}
+	return verification, nil
+}
+
+// withArchiver opens the block store of a ledger stored in blockStorePath and calls f with its archiver.
+// The error is a not-exist error when the ledger is not found.
+func withArchiver(blockStorePath, ledgerID string, f func(arch *blockfileArchiver) error) error {
+	conf := NewConf(blockStorePath, 0, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir)
+	if _, err := os.Stat(conf.getLedgerBlockDir(ledgerID)); err != nil {
+		return errors.Wrapf(err, "ledger [%s] not found in %s", ledgerID, blockStorePath)
+	}
+	provider := NewProvider(conf, &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{blkstorage.IndexableAttrBlockNum}})
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(ledgerID)
+	if err != nil {
+		return err
+	}
+	defer store.Shutdown()
+	return f(store.(*fsBlockStore).archiver)
+}
+
+func (arch *blockfileArchiver) archiveSnapshot() (*archive.ArchiveSnapshot, error) {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, errors.Errorf("no blockfile of ledger [%s] has been archived", arch.chainID)
+	}
+	catalogHash, err := blockarchive.ComputeCatalogHash(infos)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &archive.ArchiveSnapshot{
+		ChannelID:     arch.chainID,
+		Timestamp:     ptypes.TimestampNow(),
+		Repository:    blockarchive.RepositoryURLOf(arch.chainID),
+		NetworkID:     blockarchive.NetworkID,
+		Environment:   blockarchive.Environment,
+		FirstBlockNum: infos[0].FirstBlockNum,
+		LastBlockNum:  infos[len(infos)-1].LastBlockNum,
+		CatalogHash:   catalogHash,
+	}
+
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+	for _, info := range infos {
+		object := &archive.ArchiveSnapshotObject{
+			BlockfileNo:   info.BlockfileNo,
+			FirstBlockNum: info.FirstBlockNum,
+			LastBlockNum:  info.LastBlockNum,
+			Location:      info.Location,
+			Checksum:      info.Checksum,
+			ArchivedAt:    info.ArchivedAt,
+		}
+		summary, err := arch.archivedBlockfileSummary(client, info)
+		if err != nil {
+			return nil, err
+		}
+		if summary != nil {
+			object.BlockfileHash, object.MerkleRoot = summary.BlockfileHash, summary.MerkleRoot
+			object.FirstBlockHash, object.LastBlockHash = summary.FirstBlockHash, summary.LastBlockHash
+		} else {
+			loggerArchive.Warningf("[%s] No summary of archived blockfile [%d], its digests are left out of the snapshot", arch.chainID, info.BlockfileNo)
+		}
+		snapshot.Objects = append(snapshot.Objects, object)
+	}
+	if err := blockarchive.ValidateSnapshot(snapshot); err != nil {
+		return nil, errors.WithMessagef(err, "the archive catalog of ledger [%s] doesn't describe a contiguous history", arch.chainID)
+	}
+	return snapshot, nil
+}
+
+// archivedBlockfileSummary reads the summary stored next to an archived blockfile on the repository, or computes
+// it from the local blockfile if it has not been discarded. It returns nil if neither exists, e.g. for the
+// blockfiles archived before the summaries were produced.
+func (arch *blockfileArchiver) archivedBlockfileSummary(client repositoryClient, info *archive.ArchivedBlockfileInfo) (*archive.BlockfileSummary, error) {
+	fileNum := int(info.BlockfileNo)
+	var summary *archive.BlockfileSummary
+	for _, path := range []string{arch.remoteManifestPath(fileNum, info.Location, blockarchive.SummarySuffix), info.Location + blockarchive.SummarySuffix} {
+		file, err := client.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error opening summary %s", path)
+		}
+		summaryBytes, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading summary %s", path)
+		}
+		summary = &archive.BlockfileSummary{}
+		if err := proto.Unmarshal(summaryBytes, summary); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling summary %s", path)
+		}
+		break
+	}
+	if summary == nil {
+		if _, err := os.Stat(deriveBlockfilePath(arch.mgr.rootDir, fileNum)); err != nil {
+			return nil, nil
+		}
+		scanned, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := blockarchive.ComputeBlockfileHash(deriveBlockfilePath(arch.mgr.rootDir, fileNum))
+		if err != nil {
+			return nil, err
+		}
+		summary = blockarchive.NewBlockfileSummary(arch.chainID, info.BlockfileNo, scanned.firstBlockNum, scanned.blockHashes, hash)
+	}
+	if err := blockarchive.VerifySummary(summary); err != nil {
+		return nil, errors.WithMessagef(err, "invalid summary of archived blockfile [%d]", info.BlockfileNo)
+	}
+	if summary.FirstBlockNum != info.FirstBlockNum || summary.LastBlockNum != info.LastBlockNum {
+		return nil, errors.Errorf("the summary of archived blockfile [%d] holds blocks [%d-%d], but blocks [%d-%d] have been archived",
+			info.BlockfileNo, summary.FirstBlockNum, summary.LastBlockNum, info.FirstBlockNum, info.LastBlockNum)
+	}
+	return summary, nil
+}
+
+// compareSnapshotWithCatalog checks that the archive catalog holds the records of the objects of the snapshot,
+// which hash to the catalog hash of the snapshot
+func compareSnapshotWithCatalog(snapshot *archive.ArchiveSnapshot, infos []*archive.ArchivedBlockfileInfo) *CustodyAudit {
+	records := make(map[uint64]*archive.ArchivedBlockfileInfo)
+	for _, info := range infos {
+		records[info.BlockfileNo] = info
+	}
+	var snapshotRecords []*archive.ArchivedBlockfileInfo
+	for _, object := range snapshot.Objects {
+		record, ok := records[object.BlockfileNo]
+		if !ok {
+			return &CustodyAudit{Status: CustodyFailed, Error: errors.Errorf("blockfile [%d] is not in the archive catalog", object.BlockfileNo).Error()}
+		}
+		if record.Location != object.Location || record.Checksum != object.Checksum {
+			return &CustodyAudit{Status: CustodyFailed, Error: errors.Errorf("blockfile [%d] is archived at %s with checksum [%s] in the archive catalog",
+				object.BlockfileNo, record.Location, record.Checksum).Error()}
+		}
+		snapshotRecords = append(snapshotRecords, record)
+	}
+	hash, err := blockarchive.ComputeCatalogHash(snapshotRecords)
+	if err != nil {
+		return &CustodyAudit{Status: CustodyFailed, Error: err.Error()}
+	}
+	if !bytes.Equal(hash, snapshot.CatalogHash) {
+		return &CustodyAudit{Status: CustodyFailed, Error: errors.Errorf("the records of the archive catalog hash to %x, not to the catalog hash of the snapshot",
+			hash).Error()}
+	}
+	return &CustodyAudit{Status: CustodyPassed}
+}
+
+// auditSnapshotObjects verifies the objects of a snapshot on the repository against their checksum
+func auditSnapshotObjects(ledgerID string, objects []*archive.ArchiveSnapshotObject) (map[uint64]*CustodyAudit, error) {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+	audits := make(map[uint64]*CustodyAudit)
+	for _, object := range objects {
+		audits[object.BlockfileNo] = auditCustodyBlockfile(client, &CustodyBlockfile{
+			BlockfileNo: object.BlockfileNo,
+			Location:    object.Location,
+			Checksum:    object.Checksum,
+		})
+	}
+	return audits, nil
+}
@@ -0,0 +1,109 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveSnapshot(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	blockStorePath := testPath()
+	prevSigner, prevBlockStorePath := blockarchive.ManifestSigner, blockarchive.BlockStorePath
+	defer func() { blockarchive.ManifestSigner, blockarchive.BlockStorePath = prevSigner, prevBlockStorePath }()
+	blockarchive.ManifestSigner, blockarchive.BlockStorePath = nil, blockStorePath
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+
+	_, err = arch.archiveSnapshot()
+	assert.EqualError(t, err, "no blockfile of ledger [testLedger] has been archived")
+
+	// Blockfile 0 is discarded and blockfile 1 is kept
+	for fileNum, discard := range []bool{true, false} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.publishManifest(fileNum, location))
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, discard))
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+
+	snapshot, err := arch.archiveSnapshot()
+	require.NoError(t, err)
+	assert.Equal(t, "testLedger", snapshot.ChannelID)
+	assert.Equal(t, uint64(0), snapshot.FirstBlockNum)
+	assert.Equal(t, infos[1].LastBlockNum, snapshot.LastBlockNum)
+	catalogHash, err := blockarchive.ComputeCatalogHash(infos)
+	require.NoError(t, err)
+	assert.Equal(t, catalogHash, snapshot.CatalogHash)
+	require.Len(t, snapshot.Objects, 2)
+	for i, object := range snapshot.Objects {
+		assert.Equal(t, infos[i].Location, object.Location)
+		assert.Equal(t, infos[i].Checksum, object.Checksum)
+		assert.Equal(t, infos[i].FirstBlockNum, object.FirstBlockNum)
+		assert.Equal(t, protoutil.BlockHeaderHash(blocks[object.FirstBlockNum].Header), object.FirstBlockHash)
+		assert.Equal(t, protoutil.BlockHeaderHash(blocks[object.LastBlockNum].Header), object.LastBlockHash)
+		assert.NotEmpty(t, object.MerkleRoot)
+	}
+	localHash, err := blockarchive.ComputeBlockfileHash(deriveBlockfilePath(arch.blockfileDir, 1))
+	require.NoError(t, err)
+	assert.Equal(t, localHash, snapshot.Objects[1].BlockfileHash)
+
+	// The snapshot matches the archive catalog and the repository
+	assert.Equal(t, &CustodyAudit{Status: CustodyPassed}, compareSnapshotWithCatalog(snapshot, infos))
+	audits, err := auditSnapshotObjects(snapshot.ChannelID, snapshot.Objects)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64]*CustodyAudit{0: {Status: CustodyPassed}, 1: {Status: CustodyPassed}}, audits)
+
+	// A record changed in the archive catalog or a blockfile altered on the repository fails the verification
+	changed := proto.Clone(infos[1]).(*archive.ArchivedBlockfileInfo)
+	changed.ArchivedAt = nil
+	assert.Contains(t, compareSnapshotWithCatalog(snapshot, []*archive.ArchivedBlockfileInfo{infos[0], changed}).Error,
+		"not to the catalog hash of the snapshot")
+	assert.Equal(t, &CustodyAudit{Status: CustodyFailed, Error: "blockfile [1] is not in the archive catalog"},
+		compareSnapshotWithCatalog(snapshot, infos[:1]))
+	remotePath := filepath.Join(repoRootDir, infos[0].Location)
+	content, err := ioutil.ReadFile(remotePath)
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(remotePath, content, 0644))
+	verification, err := VerifyArchiveSnapshot(blockStorePath, &archive.ArchiveSnapshot{ChannelID: "otherLedger", Objects: snapshot.Objects}, true)
+	require.NoError(t, err)
+	assert.False(t, verification.Passed())
+	assert.Equal(t, CustodyNotChecked, verification.Catalog.Status)
+	assert.Equal(t, &CustodyAudit{Status: CustodyFailed, Error: "the archived blockfile does not match its checksum"}, verification.Objects[0])
+	assert.Equal(t, &CustodyAudit{Status: CustodyPassed}, verification.Objects[1])
+}
@@ -0,0 +1,133 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// tailLocation returns the path on the repository of the snapshot of a blockfile still being written
+func (arch *blockfileArchiver) tailLocation(fileNum int) string {
+	return deriveArchivedBlockfilePath(arch.blockfileDir, fileNum) + blockarchive.TailSuffix
+}
+
+// currentFileNumAndSize returns the number of the blockfile being written and the size of the blocks
+// checkpointed in it, so that a snapshot of the blockfile never ends in the middle of a block
+func (mgr *blockfileMgr) currentFileNumAndSize() (int, int) {
+	mgr.cpInfoCond.L.Lock()
+	defer mgr.cpInfoCond.L.Unlock()
+	return mgr.cpInfo.latestFileChunkSuffixNum, mgr.cpInfo.latestFileChunksize
+}
+
+// archiveIdleTail archives a snapshot of the blockfile being written once no block has been appended to it
+// for blockarchive.TailIdleTime, so that the archive holds the last blocks of a channel which stopped producing
+// blocks before filling the blockfile. The snapshot is neither recorded in the catalog nor discarded locally,
+// it is replaced as the blockfile grows, and removed once the blockfile is finalized and archived.
+func (arch *blockfileArchiver) archiveIdleTail() {
+	fileNum, size := arch.mgr.currentFileNumAndSize()
+	if size == 0 || (fileNum == arch.tailFileNum && size == arch.tailSize) {
+		return
+	}
+	info, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+	if err != nil || time.Since(info.ModTime()) < blockarchive.TailIdleTime {
+		return
+	}
+	if !transfers.begin() {
+		return
+	}
+	defer transfers.end()
+
+	location := arch.tailLocation(fileNum)
+	if err := sendBlockfileTailToRepo(arch.blockfileDir, fileNum, int64(size), location); err != nil {
+		loggerUpload.With(blockfileLogFields(arch.chainID, fileNum)...).Warnw("Failed archiving the tail of the idle blockfile", "error", err)
+		return
+	}
+	arch.tailFileNum, arch.tailSize = fileNum, size
+}
+
+// removeIdleTail removes from the repository the snapshot of a blockfile which has been archived
+func (arch *blockfileArchiver) removeIdleTail(fileNum int) {
+	if blockarchive.TailIdleTime == 0 {
+		return
+	}
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	location := arch.tailLocation(fileNum)
+	if err := client.Remove(location); err == nil {
+		client.Remove(location + blockarchive.ChecksumSuffix)
+		loggerUpload.Infow("Removed the tail of the archived blockfile", "location", location)
+	}
+}
+
+// sendBlockfileTailToRepo uploads the first size bytes of a blockfile still being written to the repository,
+// replacing the previous snapshot of the blockfile if any
+func sendBlockfileTailToRepo(blockfileDir string, fileNum int, size int64, dstFilePath string) error {
+	log := loggerUpload.With(blockfileLogFields(filepath.Base(blockfileDir), fileNum)...).
+		With(blockarchive.LogKeyRepository, blockarchive.RepositoryURLOf(filepath.Base(blockfileDir)))
+	start := time.Now()
+
+	srcFile, err := os.Open(deriveBlockfilePath(blockfileDir, fileNum))
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	sshConn, client, err := connectToRepo(filepath.Base(blockfileDir))
+	if err != nil {
+		return errors.New("Server unreachable")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	tmpFilePath := dstFilePath + uploadingSuffix
+	client.MkdirAll(filepath.Dir(dstFilePath))
+	dstFile, err := client.Create(tmpFilePath)
+	if err != nil {
+		log.Warnw("Failed creating the tail of the blockfile on the repository", "location", tmpFilePath, "error", err)
+		return err
+	}
+
+	checksumWriter, err := blockarchive.NewChecksumWriter(blockarchive.ChecksumAlgorithm)
+	if err != nil {
+		client.Remove(tmpFilePath)
+		return err
+	}
+	written, err := io.Copy(io.MultiWriter(dstFile, checksumWriter), transfers.reader(io.LimitReader(srcFile, size)))
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written != size {
+		err = errors.Errorf("blockfile shorter than its checkpointed size %d", size)
+	}
+	if err == nil {
+		err = writeRemoteFile(client, dstFilePath+blockarchive.ChecksumSuffix, []byte(checksumWriter.Checksum().String()))
+	}
+	if err != nil {
+		log.Warnw("Failed uploading the tail of the blockfile", blockarchive.LogKeyBytes, written, "error", err)
+		client.Remove(tmpFilePath)
+		return err
+	}
+
+	client.Remove(dstFilePath)
+	if err := client.Rename(tmpFilePath, dstFilePath); err != nil {
+		log.Warnw("Failed renaming the tail of the blockfile on the repository", "location", tmpFilePath, "error", err)
+		return err
+	}
+
+	log.Infow("Uploaded the tail of the idle blockfile", "location", dstFilePath,
+		blockarchive.LogKeyBytes, written, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+	return nil
+}
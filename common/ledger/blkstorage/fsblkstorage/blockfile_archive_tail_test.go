@@ -0,0 +1,79 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveIdleTail(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 60)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	prevIdleTime := blockarchive.TailIdleTime
+	defer func() { blockarchive.TailIdleTime = prevIdleTime }()
+	blockarchive.TailIdleTime = time.Hour
+	for _, block := range blocks[:35] {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+	fileNum, size := arch.mgr.currentFileNumAndSize()
+	require.Equal(t, 3, fileNum)
+	require.NotZero(t, size)
+
+	sshConn, client, err := connectToRepo(arch.chainID)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	defer client.Close()
+	location := arch.tailLocation(fileNum)
+
+	// The blockfile being written is not archived while blocks are appended to it
+	arch.archiveIdleTail()
+	_, err = client.Stat(location)
+	assert.True(t, os.IsNotExist(err))
+
+	// but once it has been idle for long enough, up to its last checkpointed block
+	localPath := deriveBlockfilePath(arch.blockfileDir, fileNum)
+	idleSince := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(localPath, idleSince, idleSince))
+	arch.archiveIdleTail()
+	tail, err := client.Open(location)
+	require.NoError(t, err)
+	tailBytes, err := ioutil.ReadAll(tail)
+	tail.Close()
+	require.NoError(t, err)
+	localBytes, err := ioutil.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, localBytes[:size], tailBytes)
+	_, err = client.Stat(location + blockarchive.ChecksumSuffix)
+	assert.NoError(t, err)
+
+	// The blockfile is kept locally and left out of the catalog
+	_, err = os.Stat(localPath)
+	assert.NoError(t, err)
+	info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	// The snapshot is removed once the finalized blockfile is archived
+	for _, block := range blocks[35:] {
+		require.NoError(t, store.AddBlock(block))
+	}
+	_, err = arch.archiveBlockfile(fileNum, false)
+	require.NoError(t, err)
+	_, err = client.Stat(location)
+	assert.True(t, os.IsNotExist(err))
+	_, err = client.Stat(deriveArchivedBlockfilePath(arch.blockfileDir, fileNum))
+	assert.NoError(t, err)
+}
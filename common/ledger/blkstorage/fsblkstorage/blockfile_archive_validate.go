@@ -0,0 +1,107 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+var corruptedBlockfiles = metrics.CounterOpts{
+	Namespace:    "archiver",
+	Subsystem:    "validation",
+	Name:         "corrupted_blockfiles",
+	Help:         "The number of times a blockfile was refused for archiving because its blocks are not chained by their hashes.",
+	LabelNames:   []string{"channel"},
+	StatsdFormat: "%{#fqname}.%{channel}",
+}
+
+var (
+	corruptionCounter     metrics.Counter
+	corruptionCounterOnce sync.Once
+)
+
+// getCorruptionCounter returns the counter of the corrupted blockfiles shared by the channels, which is created on first use
+func getCorruptionCounter() metrics.Counter {
+	corruptionCounterOnce.Do(func() {
+		var p metrics.Provider = &disabled.Provider{}
+		if blockarchive.MetricsProvider != nil {
+			p = blockarchive.MetricsProvider
+		}
+		corruptionCounter = p.NewCounter(corruptedBlockfiles)
+	})
+	return corruptionCounter
+}
+
+// validateBlockfile verifies a blockfile before it is uploaded, so that a corrupted blockfile never
+// reaches the archive of record: its blocks must be consecutive, each one chained to the previous one
+// by its previous hash, and its first block chained to the last block of the previous blockfile.
+// A corrupted blockfile is counted and logged as an error.
+func (arch *blockfileArchiver) validateBlockfile(fileNum int) error {
+	err := arch.checkBlockfileChain(fileNum)
+	if err != nil {
+		getCorruptionCounter().With("channel", arch.chainID).Add(1)
+		loggerUpload.Errorw("Refused archiving corrupted blockfile", append(blockfileLogFields(arch.chainID, fileNum), "error", err)...)
+		blockarchive.RaiseAlert(blockarchive.AlertVerificationFailure, arch.chainID, "refused archiving corrupted blockfile [%d]: %s", fileNum, err)
+	}
+	return err
+}
+
+func (arch *blockfileArchiver) checkBlockfileChain(fileNum int) error {
+	summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+	if err != nil {
+		return errors.WithMessagef(err, "blockfile [%d] of ledger [%s] could not be parsed", fileNum, arch.chainID)
+	}
+	for i, block := range summary.blocks {
+		if expected := summary.firstBlockNum + uint64(i); block.BlockNum != expected {
+			return errors.Errorf("blockfile [%d] of ledger [%s] holds block [%d] where block [%d] is expected",
+				fileNum, arch.chainID, block.BlockNum, expected)
+		}
+		if i > 0 && !bytes.Equal(summary.previousHashes[i], summary.blockHashes[i-1]) {
+			return errors.Errorf("block [%d] of blockfile [%d] of ledger [%s] is not chained to block [%d]: previous hash [%x], expected [%x]",
+				block.BlockNum, fileNum, arch.chainID, block.BlockNum-1, summary.previousHashes[i], summary.blockHashes[i-1])
+		}
+	}
+	if summary.firstBlockNum == 0 {
+		return nil
+	}
+	previousHash, err := arch.blockHeaderHash(summary.firstBlockNum - 1)
+	if err != nil {
+		return err
+	}
+	if previousHash == nil {
+		loggerUpload.Warnw("The continuity of the blockfile with the previous one could not be verified, the previous block is unknown",
+			append(blockfileLogFields(arch.chainID, fileNum), "block", summary.firstBlockNum-1)...)
+		return nil
+	}
+	if !bytes.Equal(summary.previousHashes[0], previousHash) {
+		return errors.Errorf("block [%d] of blockfile [%d] of ledger [%s] is not chained to the last block of the previous blockfile: previous hash [%x], expected [%x]",
+			summary.firstBlockNum, fileNum, arch.chainID, summary.previousHashes[0], previousHash)
+	}
+	return nil
+}
+
+// blockHeaderHash returns the header hash of a block, from the archive catalog if it has been archived,
+// or else from the local blockfiles, nil if it is found in neither
+func (arch *blockfileArchiver) blockHeaderHash(blockNum uint64) ([]byte, error) {
+	block, err := arch.catalog.GetArchivedBlock(blockNum)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error reading block [%d] of ledger [%s] from the archive catalog", blockNum, arch.chainID)
+	}
+	if block != nil && len(block.HeaderHash) > 0 {
+		return block.HeaderHash, nil
+	}
+	header, err := arch.mgr.retrieveBlockHeaderByNumber(blockNum)
+	if err != nil {
+		return nil, nil
+	}
+	return protoutil.BlockHeaderHash(header), nil
+}
@@ -0,0 +1,59 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBlockfileBeforeArchiving(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 50)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+
+	// corruptHash flips the first byte of a hash recorded in a blockfile
+	corruptHash := func(fileNum int, hash []byte) {
+		path := deriveBlockfilePath(arch.blockfileDir, fileNum)
+		content, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		i := bytes.Index(content, hash)
+		require.True(t, i >= 0)
+		content[i] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(path, content, 0644))
+	}
+
+	assert.NoError(t, arch.validateBlockfile(1))
+	_, err := arch.archiveBlockfile(1, true)
+	require.NoError(t, err)
+	// The continuity with a discarded blockfile is verified against the archive catalog
+	assert.NoError(t, arch.validateBlockfile(2))
+
+	// A block not chained to the previous one within the blockfile
+	summary, err := scanBlockfile(arch.mgr.rootDir, 2)
+	require.NoError(t, err)
+	corruptHash(2, summary.blockHashes[3])
+	_, err = arch.archiveBlockfile(2, true)
+	assert.Contains(t, err.Error(), "is not chained to block")
+	info, err := arch.catalog.getArchivedBlockfile(2)
+	require.NoError(t, err)
+	assert.Nil(t, info)
+	assert.False(t, arch.isUploaded(2))
+
+	// A blockfile not chained to the previous blockfile, whose last block has been altered
+	corruptHash(2, blocks[summary.lastBlockNum].Header.DataHash)
+	err = arch.validateBlockfile(3)
+	assert.Contains(t, err.Error(), "is not chained to the last block of the previous blockfile")
+}
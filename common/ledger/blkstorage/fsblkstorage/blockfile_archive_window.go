@@ -0,0 +1,146 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+)
+
+var oldestLocalBlockTimestamp = metrics.GaugeOpts{
+	Namespace:    "archiver",
+	Subsystem:    "window",
+	Name:         "oldest_local_block_timestamp",
+	Help:         "The creation time, in seconds since the epoch, of the oldest block of the channel held in the local blockfiles. Its age is the current time minus the gauge.",
+	LabelNames:   []string{"channel"},
+	StatsdFormat: "%{#fqname}.%{channel}",
+}
+
+var newestArchivedBlockTimestamp = metrics.GaugeOpts{
+	Namespace:    "archiver",
+	Subsystem:    "window",
+	Name:         "newest_archived_block_timestamp",
+	Help:         "The creation time, in seconds since the epoch, of the newest block of the channel recorded in the archive catalog. Its age is the current time minus the gauge.",
+	LabelNames:   []string{"channel"},
+	StatsdFormat: "%{#fqname}.%{channel}",
+}
+
+var (
+	oldestLocalGauge    metrics.Gauge
+	newestArchivedGauge metrics.Gauge
+	windowGaugesOnce    sync.Once
+)
+
+// getWindowGauges returns the gauges of the oldest local block and the newest archived block shared by
+// the channels, which are created on first use
+func getWindowGauges() (metrics.Gauge, metrics.Gauge) {
+	windowGaugesOnce.Do(func() {
+		var p metrics.Provider = &disabled.Provider{}
+		if blockarchive.MetricsProvider != nil {
+			p = blockarchive.MetricsProvider
+		}
+		oldestLocalGauge = p.NewGauge(oldestLocalBlockTimestamp)
+		newestArchivedGauge = p.NewGauge(newestArchivedBlockTimestamp)
+	})
+	return oldestLocalGauge, newestArchivedGauge
+}
+
+// blockTime returns the creation time of a block, from the timestamp of the channel header of its first transaction
+func blockTime(block *common.Block) (*timestamp.Timestamp, error) {
+	env, err := protoutil.ExtractEnvelope(block, 0)
+	if err != nil {
+		return nil, err
+	}
+	chdr, err := protoutil.ChannelHeader(env)
+	if err != nil {
+		return nil, err
+	}
+	return chdr.Timestamp, nil
+}
+
+// firstLocalBlockTime returns the creation time of the first block of a local blockfile, nil if it holds no block
+func firstLocalBlockTime(rootDir string, fileNum int) (*timestamp.Timestamp, error) {
+	stream, err := newBlockfileStream(rootDir, fileNum, 0, &ArchiveConf{})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.close()
+	blockBytes, err := stream.nextBlockBytes()
+	if err != nil || blockBytes == nil {
+		return nil, err
+	}
+	block, err := deserializeBlock(blockBytes)
+	if err != nil {
+		return nil, err
+	}
+	return blockTime(block)
+}
+
+// setTimestampGauge sets the gauge of the channel to the time in seconds since the epoch
+func setTimestampGauge(gauge metrics.Gauge, chainID string, ts *timestamp.Timestamp) {
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return
+	}
+	gauge.With("channel", chainID).Set(float64(t.UnixNano()) / float64(time.Second))
+}
+
+// updateWindowGauges exports the creation time of the oldest block held locally and of the newest block
+// archived, so that an unexpected shrink of the local window or a stalled archiving is alerted on
+func (arch *blockfileArchiver) updateWindowGauges() {
+	arch.updateOldestLocalBlockGauge()
+
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		loggerArchive.Warningf("[%s] Failed reading the archive catalog for the newest archived block: %s", arch.chainID, err)
+		return
+	}
+	var newest *archive.ArchivedBlockfileInfo
+	for _, info := range infos {
+		if newest == nil || info.LastBlockNum > newest.LastBlockNum {
+			newest = info
+		}
+	}
+	if newest != nil {
+		arch.updateNewestArchivedBlockGauge(newest)
+	}
+}
+
+// updateOldestLocalBlockGauge exports the creation time of the first block of the oldest local blockfile.
+// It is called as the local blockfiles are discarded or restored.
+func (arch *blockfileArchiver) updateOldestLocalBlockGauge() {
+	fileNums, _, err := listLocalBlockfiles(arch.mgr.rootDir)
+	if err != nil || len(fileNums) == 0 {
+		return
+	}
+	ts, err := firstLocalBlockTime(arch.mgr.rootDir, fileNums[0])
+	if err != nil {
+		loggerArchive.Warningf("[%s] Failed reading the time of the oldest local block: %s", arch.chainID, err)
+		return
+	}
+	if ts != nil {
+		oldest, _ := getWindowGauges()
+		setTimestampGauge(oldest, arch.chainID, ts)
+	}
+}
+
+// updateNewestArchivedBlockGauge exports the creation time of the last block of an archived blockfile,
+// the newest one recorded in the catalog. The blockfiles archived before the time was recorded are skipped.
+func (arch *blockfileArchiver) updateNewestArchivedBlockGauge(info *archive.ArchivedBlockfileInfo) {
+	if info.LastBlockTime == nil {
+		return
+	}
+	_, newest := getWindowGauges()
+	setTimestampGauge(newest, arch.chainID, info.LastBlockTime)
+}
@@ -0,0 +1,83 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowGauges(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+
+	oldest, newest := &metricsfakes.Gauge{}, &metricsfakes.Gauge{}
+	oldest.WithReturns(oldest)
+	newest.WithReturns(newest)
+	windowGaugesOnce.Do(func() {})
+	oldestLocalGauge, newestArchivedGauge = oldest, newest
+	defer func() { oldestLocalGauge, newestArchivedGauge, windowGaugesOnce = nil, nil, sync.Once{} }()
+
+	seconds := func(block *common.Block) float64 {
+		ts, err := blockTime(block)
+		require.NoError(t, err)
+		tm, err := ptypes.Timestamp(ts)
+		require.NoError(t, err)
+		return float64(tm.UnixNano()) / float64(time.Second)
+	}
+
+	// Nothing archived yet
+	arch.updateWindowGauges()
+	require.Equal(t, 1, oldest.SetCallCount())
+	assert.Equal(t, []string{"channel", "testLedger"}, oldest.WithArgsForCall(0))
+	assert.Equal(t, seconds(blocks[0]), oldest.SetArgsForCall(0))
+	assert.Equal(t, 0, newest.SetCallCount())
+
+	// The time of the last block is recorded in the catalog when the blockfile is archived
+	require.NoError(t, arch.handleArchivedBlockfile(0, false))
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	expected, err := blockTime(blocks[9])
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(expected, info.LastBlockTime))
+	require.Equal(t, 1, newest.SetCallCount())
+	assert.Equal(t, seconds(blocks[9]), newest.SetArgsForCall(0))
+
+	// The oldest local block moves forward as the blockfile is discarded
+	require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+	arch.notifyDiscarded(0)
+	require.Equal(t, 2, oldest.SetCallCount())
+	assert.Equal(t, seconds(blocks[10]), oldest.SetArgsForCall(1))
+
+	// An archived blockfile recorded before the time was recorded is skipped
+	info.LastBlockTime = nil
+	require.NoError(t, arch.catalog.recordArchivedBlockfile(info))
+	arch.updateWindowGauges()
+	assert.Equal(t, 1, newest.SetCallCount())
+}
@@ -5,20 +5,65 @@ COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
 package fsblkstorage
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	gossiparchive "github.com/hyperledger/fabric/gossip/archive"
+	gossipcommon "github.com/hyperledger/fabric/gossip/common"
 	"github.com/hyperledger/fabric/gossip/service"
 	gossip_proto "github.com/hyperledger/fabric/protos/gossip"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
 )
 
 var loggerArchive = flogging.MustGetLogger("archiver.archive")
 var loggerArchiveCmn = flogging.MustGetLogger("archiver.common")
 
-//
+// The uploads, discards and retrievals of blockfiles are logged to distinct modules with structured
+// fields, so that the log pipelines can filter and alert on them per channel. The integrity incidents
+// of the archived blockfiles and their repairs are the audit log of the archive.
+var (
+	loggerUpload   = flogging.MustGetLogger("archiver.upload")
+	loggerDiscard  = flogging.MustGetLogger("archiver.discard")
+	loggerRetrieve = flogging.MustGetLogger("archiver.retrieve")
+	loggerAudit    = flogging.MustGetLogger("archiver.audit")
+)
+
+// blockfileLogFields returns the structured log fields identifying a blockfile of a channel
+func blockfileLogFields(ledgerID string, fileNum int) []interface{} {
+	return []interface{}{
+		blockarchive.LogKeyChannel, ledgerID,
+		blockarchive.LogKeyBlockfile, fileNum,
+	}
+}
+
+// archivedBlockfileLogFields returns the structured log fields describing an archived blockfile
+func archivedBlockfileLogFields(info *archive.ArchivedBlockfileInfo) []interface{} {
+	return append(blockfileLogFields(info.ChannelID, int(info.BlockfileNo)),
+		blockarchive.LogKeyBlockRange, blockarchive.LogBlockRange(info.FirstBlockNum, info.LastBlockNum),
+		blockarchive.LogKeyRepository, info.Repository,
+	)
+}
+
+// logFields returns the structured log fields describing a blockfile of the channel, including
+// its block range once it has been recorded in the catalog
+func (arch *blockfileArchiver) logFields(fileNum int) []interface{} {
+	if info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum)); err == nil && info != nil {
+		return archivedBlockfileLogFields(info)
+	}
+	return blockfileLogFields(arch.chainID, fileNum)
+}
+
 type blockfileArchiver struct {
 	// Chain ID
 	chainID string
@@ -26,15 +71,29 @@ type blockfileArchiver struct {
 	mgr *blockfileMgr
 	// PATH to where blockfiles are stored on the local file system
 	blockfileDir string
-	// Postfix number of the blockfile which should be archived next
-	nextBlockfileNum int
+	// Progress of the archiver, persisted across restarts
+	checkpoint *archiverCheckpoint
+	// Records of the blockfiles which have been archived
+	catalog *archiveCatalog
+	// Listeners notified when an archived blockfile has been discarded
+	discardListeners []blockarchive.DiscardListener
+	listenersLock    sync.RWMutex
+	// Timer which discards again the blockfiles restored temporarily
+	expiryTimer *time.Timer
+	expiryLock  sync.Mutex
+	closed      bool
+	// Closed to stop the archiving when the peer hands the archiver role off, nil while not archiving
+	stopArchiving    chan struct{}
+	archivingStopped chan struct{}
+	archivingLock    sync.Mutex
+	// Blockfile and size of the last snapshot archived of the blockfile being written
+	tailFileNum int
+	tailSize    int
+	// Consecutive failures of the archiving of the blockfile failingFileNum
+	archiveFailures int
+	failingFileNum  int
 }
 
-const (
-	// Maximum of retries for catching up with the latest blockfile to be actually archived
-	maxRetryForCatchUp = 1000
-)
-
 // newBlockfileArchiver create a blockfile archiver instance
 // If peer runs in archiver mode, also do the following steps:
 // - Create a channel to receive a notification when blockfile is finalized
@@ -43,28 +102,80 @@ func newBlockfileArchiver(id string, mgr *blockfileMgr) *blockfileArchiver {
 	loggerArchive.Info("newBlockfileArchiver: ", id)
 
 	blockfileDir := filepath.Join(blockarchive.BlockStorePath, ChainsDir, id)
-	arch := &blockfileArchiver{id, mgr, blockfileDir, 1}
+	arch := &blockfileArchiver{
+		chainID:      id,
+		mgr:          mgr,
+		blockfileDir: blockfileDir,
+		checkpoint:   &archiverCheckpoint{nextBlockfileNum: 1, inFlightBlockfileNum: noInFlightBlockfile},
+		catalog:      mgr.archiveConf.catalog,
+	}
+
+	// Recover a corrupted catalog before the archiver resumes from its checkpoint
+	if blockarchive.CatalogRecovery {
+		if err := arch.recoverCatalog(); err != nil {
+			panic(fmt.Sprintf("Could not recover the archive catalog of ledger [%s]: %s", id, err))
+		}
+	}
 
 	if blockarchive.IsArchiver {
-		loggerArchive.Info("newBlockfileArchiver - creating archiverChan...")
-		// Create a new channel to allow the blockfileMgr to send messages to the archiver
-		archiverChan := make(chan blockarchive.ArchiverMessage, 5)
-		arch.mgr.SetArchiverChan(archiverChan)
+		if err := arch.startArchiving(false); err != nil {
+			panic(fmt.Sprintf("Could not load the archiver checkpoint of ledger [%s]: %s", id, err))
+		}
+	}
+	registerArchiver(arch)
 
-		// Start listening for messages from blockfileMgr
-		go arch.listenForBlockfiles(archiverChan)
+	// Complete the discards interrupted by a crash, so that no blockfile marked as discarded is left behind
+	if err := arch.recoverDiscards(); err != nil {
+		panic(fmt.Sprintf("Could not recover the discards of ledger [%s]: %s", id, err))
 	}
 
+	// Resume the expiry of the blockfiles restored temporarily before the restart
+	arch.scheduleRestoreExpiry()
+
+	arch.updateWindowGauges()
+
 	return arch
 }
 
 // listenForBlockfiles listens to a notificationalso create a channel to receive a notification
-// The check routine to see if archiving is necessary is triggered here.
-func (arch *blockfileArchiver) listenForBlockfiles(archiverChan chan blockarchive.ArchiverMessage) {
+// The check routine to see if archiving is necessary is triggered here, and by the scheduled archiving passes,
+// as are the scheduled discard passes when the discard is deferred.
+func (arch *blockfileArchiver) listenForBlockfiles(archiverChan chan blockarchive.ArchiverMessage, stop, stopped chan struct{}) {
 	loggerArchive.Info("listenForBlockfiles...")
+	defer close(stopped)
+
+	schedule, discardSchedule := blockarchive.ArchivingSchedule, blockarchive.DiscardSchedule
+	scheduled, timer := nextScheduledPass("archiving", schedule)
+	discardScheduled, discardTimer := nextScheduledPass("discard", discardSchedule)
+	defer func() {
+		for _, t := range []*time.Timer{timer, discardTimer} {
+			if t != nil {
+				t.Stop()
+			}
+		}
+	}()
+	// The blockfile being written is checked for idleness every TailIdleTime
+	var idleTail <-chan time.Time
+	if blockarchive.TailIdleTime > 0 {
+		ticker := time.NewTicker(blockarchive.TailIdleTime)
+		defer ticker.Stop()
+		idleTail = ticker.C
+	}
+
+	// The backlog accumulated while the peer was not archiving is caught up with first, unless
+	// the blockfiles are archived by the scheduled passes only
+	if schedule == nil || !blockarchive.ScheduleOnly {
+		if arch.catchUp(stop) > 0 {
+			arch.discardIfNecessary()
+			arch.checkDiskHighWatermark()
+		}
+	}
 
 	for {
 		select {
+		case <-stop:
+			loggerArchive.Infof("[%s] listenForBlockfiles - archiving stopped", arch.chainID)
+			return
 		case msg, ok := <-archiverChan:
 			if !ok {
 				loggerArchive.Info("listenForBlockfiles - channel closed")
@@ -75,57 +186,195 @@ func (arch *blockfileArchiver) listenForBlockfiles(archiverChan chan blockarchiv
 			if arch.chainID != msg.ChainID {
 				loggerArchive.Errorf("listenForBlockfiles - incorrect channel [%s] - [%s]! ", arch.chainID, msg.ChainID)
 			}
+			if schedule != nil && blockarchive.ScheduleOnly {
+				loggerArchive.Debugf("[%s] Blockfile [%d] is archived by the next scheduled pass", arch.chainID, msg.BlockfileNum)
+				continue
+			}
 			arch.archiveChannelIfNecessary()
+			arch.discardIfNecessary()
+			arch.checkDiskHighWatermark()
+		case <-scheduled:
+			arch.archiveScheduledPass(stop)
+			arch.discardIfNecessary()
+			arch.checkDiskHighWatermark()
+			scheduled, timer = nextScheduledPass("archiving", schedule)
+		case <-discardScheduled:
+			arch.discardScheduledPass()
+			discardScheduled, discardTimer = nextScheduledPass("discard", discardSchedule)
+		case <-idleTail:
+			arch.archiveIdleTail()
 		}
 	}
 
 }
 
+// nextScheduledPass returns a timer firing at the next pass of the schedule,
+// and a nil channel if there is none
+func nextScheduledPass(kind string, schedule *blockarchive.Schedule) (<-chan time.Time, *time.Timer) {
+	if schedule == nil {
+		return nil, nil
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		loggerArchive.Warningf("The %s schedule [%s] has no next pass", kind, schedule)
+		return nil, nil
+	}
+	loggerArchive.Debugf("Next scheduled %s pass at %s", kind, next)
+	timer := time.NewTimer(time.Until(next))
+	return timer.C, timer
+}
+
+// archiveScheduledPass archives the blockfiles batch after batch, until fewer than peer.archiver.each
+// are eligible beyond the kept ones, so that the pass catches up with the blockfiles finalized since
+// the previous one. It stops between batches when the archiving is stopped.
+func (arch *blockfileArchiver) archiveScheduledPass(stop chan struct{}) {
+	loggerArchive.Infof("[%s] Scheduled archiving pass", arch.chainID)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if !arch.archiveChannelIfNecessary() {
+			return
+		}
+	}
+}
+
 // archiveChannelIfNecessary is called every time a blockfile is finalized (reached the maximum size of data chunk).
 // If there are enough amount of blockfiles on local file system to be archived, the actual archiving routine will be triggered.
-func (arch *blockfileArchiver) archiveChannelIfNecessary() {
+// It returns whether a whole batch of blockfiles has been archived.
+func (arch *blockfileArchiver) archiveChannelIfNecessary() bool {
 
 	chainID := arch.chainID
 	loggerArchive.Infof("ArchiveChannelIfNecessary [%s]", chainID)
 
 	numBlockfileEachArchiving := blockarchive.NumBlockfileEachArchiving
-	numKeepLatestBlocks := blockarchive.NumKeepLatestBlocks
-
-	if isNeedArchiving(arch.blockfileDir, numBlockfileEachArchiving+numKeepLatestBlocks) {
-		for i := 0; i < numBlockfileEachArchiving; i++ {
-			for j := 0; j < maxRetryForCatchUp; j++ {
-				// alreadyArchived == true means the blockfile has already been archived.
-				// When returning alreadyArchived = true, then retrying to the next blockfile
-				// until occuring the actual archiving within the maximum retry count
-				if alreadyArchived, err := arch.archiveBlockfile(arch.nextBlockfileNum, true); err != nil && alreadyArchived != true {
-					loggerArchive.Info("Failed: Archiver")
-					break
+	numKeepLatestBlocks := blockarchive.KeepLatestBlockfilesOf(chainID)
+	deferred := blockarchive.IsDiscardDeferred()
+
+	batch := arch.nextArchiveBatch(numBlockfileEachArchiving, numKeepLatestBlocks)
+	if len(batch) > 0 {
+		loggerArchive.Infof("[%s] Archiving blockfiles [%d-%d]", chainID, batch[0], batch[len(batch)-1])
+		for _, fileNum := range batch {
+			// The shutdown of the peer waits for the blockfile being archived
+			if !transfers.begin() {
+				loggerArchive.Infof("[%s] Shutting down, the archiving resumes with blockfile [%d] on the next start", chainID, fileNum)
+				return false
+			}
+			// A blockfile too recent to be discarded is archived, and kept until a later archiving opportunity.
+			// The age is checked by the discard instead when it is deferred.
+			if recent, err := arch.isTooRecentToDiscard(fileNum); !deferred && (err != nil || recent) {
+				if err == nil && !arch.isUploaded(fileNum) {
+					_, err = arch.archiveBlockfile(fileNum, false)
+				}
+				transfers.end()
+				arch.recordArchiveOutcome(fileNum, err)
+				if err != nil {
+					loggerArchive.Error(err)
 				} else {
-					loggerArchive.Info("Succeeded: Archiver")
-					arch.nextBlockfileNum++
-					if alreadyArchived == false {
-						break
-					}
+					loggerDiscard.Infow("Kept archived blockfile, its blocks are too recent to be discarded",
+						append(arch.logFields(fileNum), "minBlockAge", blockarchive.MinBlockAgeBeforeDiscardOf(chainID).String())...)
 				}
+				return false
+			}
+			_, err := arch.archiveNextBlockfile(fileNum)
+			transfers.end()
+			arch.recordArchiveOutcome(fileNum, err)
+			if err != nil {
+				return false
 			}
 		}
-	} else {
-		loggerArchive.Infof("[%s] There is no candidate to be deleted", chainID)
+		return true
 	}
+	loggerArchive.Infof("[%s] There is no candidate to be deleted", chainID)
+	return false
+}
+
+// recordArchiveOutcome counts the consecutive failures of the archiving of a blockfile, which is retried on the
+// next archiving opportunity, and raises an alert once it has failed blockarchive.ArchiveFailureAlertThreshold times
+func (arch *blockfileArchiver) recordArchiveOutcome(fileNum int, err error) {
+	if err == nil {
+		arch.archiveFailures = 0
+		return
+	}
+	if fileNum != arch.failingFileNum {
+		arch.failingFileNum, arch.archiveFailures = fileNum, 0
+	}
+	arch.archiveFailures++
+	if arch.archiveFailures == blockarchive.ArchiveFailureAlertThreshold {
+		blockarchive.RaiseAlert(blockarchive.AlertArchiveFailure, arch.chainID,
+			"the archiving of blockfile [%d] failed %d times in a row: %s", fileNum, arch.archiveFailures, err)
+	}
+}
+
+// archiveNextBlockfile archives the next blockfile and advances the checkpoint of the archiver past it.
+// The blockfile is discarded at once unless the discard is deferred.
+func (arch *blockfileArchiver) archiveNextBlockfile(fileNum int) (bool, error) {
+	alreadyArchived, err := arch.archiveBlockfile(fileNum, !blockarchive.IsDiscardDeferred())
+	if err != nil && alreadyArchived != true {
+		loggerArchive.Info("Failed: Archiver")
+		return alreadyArchived, err
+	}
+	loggerArchive.Info("Succeeded: Archiver")
+	if err := arch.saveCheckpoint(fileNum+1, noInFlightBlockfile, false); err != nil {
+		loggerArchive.Error(err)
+		return alreadyArchived, err
+	}
+	return alreadyArchived, nil
+}
+
+// checkFinalized returns an error if the blockfile is the one being written or a later one,
+// whose content is not final yet
+func (arch *blockfileArchiver) checkFinalized(fileNum int) error {
+	if current := arch.mgr.currentFileNum(); fileNum >= current {
+		return errors.Errorf("blockfile [%d] of ledger [%s] is not finalized, blockfile [%d] is being written",
+			fileNum, arch.chainID, current)
+	}
+	return nil
 }
 
 // archiveBlockfile sends a blockfile to the Block Archiver repository and deletes it if required
 func (arch *blockfileArchiver) archiveBlockfile(fileNum int, deleteTheFile bool) (bool, error) {
 
 	loggerArchive.Info("Archiving: archiveBlockfile  deleteTheFile=", deleteTheFile)
+	start := time.Now()
 
-	// Send the blockfile to the repository
-	if alreadyArchived, err := sendBlockfileToRepo(arch.blockfileDir, fileNum); err != nil && alreadyArchived == false {
+	if err := arch.checkFinalized(fileNum); err != nil {
 		loggerArchive.Error(err)
-		return alreadyArchived, err
-	} else if alreadyArchived == true {
+		return false, err
+	}
+
+	if _, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum)); os.IsNotExist(err) {
 		loggerArchive.Infof("[blockfile_%06d] Already archived. Skip...", fileNum)
-		return alreadyArchived, nil
+		return true, nil
+	}
+	location, err := arch.archiveLocation(fileNum)
+	if err != nil {
+		loggerArchive.Error(err)
+		return false, err
+	}
+
+	if arch.isUploaded(fileNum) {
+		loggerArchive.Infof("[blockfile_%06d] Already uploaded before the restart. Skip the upload...", fileNum)
+	} else {
+		if err := arch.saveCheckpoint(fileNum, fileNum, false); err != nil {
+			loggerArchive.Error(err)
+			return false, err
+		}
+
+		if alreadyArchived, err := arch.uploadBlockfile(fileNum, location,
+			&blockfileUploadResumer{arch: arch, fileNum: fileNum}, nil); err != nil || alreadyArchived {
+			return alreadyArchived, err
+		}
+
+		if err := arch.saveCheckpoint(fileNum, fileNum, true); err != nil {
+			loggerArchive.Error(err)
+			return false, err
+		}
+
+		// The snapshot archived while the blockfile was being written is superseded
+		arch.removeIdleTail(fileNum)
 	}
 
 	// Initiate and send a gossip message to let the other peers know...
@@ -137,12 +386,68 @@ func (arch *blockfileArchiver) archiveBlockfile(fileNum int, deleteTheFile bool)
 		return false, err
 	}
 
+	// Let the other peers know which ranges of blocks are now available from the archiver
+	arch.advertiseArchiveInfo()
+
+	loggerUpload.Infow("Archived blockfile", append(arch.logFields(fileNum),
+		blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))...)
+
+	return false, nil
+}
+
+// uploadBlockfile sends a blockfile to its location on the repository, along with its reference when the
+// blockfiles are content-addressed and the manifest of the archive operation, at the bandwidth of the limiter
+// if not nil. It returns whether the blockfile had already been archived.
+func (arch *blockfileArchiver) uploadBlockfile(fileNum int, location string, resumer uploadResumer, limiter *bandwidthLimiter) (bool, error) {
+	// A corrupted blockfile is not archived, it would propagate to the archive of record
+	if err := arch.validateBlockfile(fileNum); err != nil {
+		return false, err
+	}
+
+	// Send the blockfile to the repository
+	if alreadyArchived, err := sendResumableBlockfileToRepo(arch.blockfileDir, fileNum, location, resumer, limiter); err != nil && alreadyArchived == false {
+		loggerArchive.Error(err)
+		return alreadyArchived, err
+	} else if alreadyArchived == true {
+		loggerArchive.Infof("[blockfile_%06d] Already archived. Skip...", fileNum)
+		return alreadyArchived, nil
+	}
+
+	// Reference the content-addressed blockfile, which may be shared with other peers
+	if blockarchive.ContentAddressed {
+		if err := addBlockfileRef(arch.chainID, location, arch.refName(fileNum)); err != nil {
+			loggerArchive.Error(err)
+			return false, err
+		}
+	}
+
+	// Leave the signed manifest of the archive operation as an audit trail
+	if err := arch.publishManifest(fileNum, location); err != nil {
+		loggerArchive.Error(err)
+		return false, err
+	}
 	return false, nil
 }
 
+// advertiseArchiveInfo publishes the archived block ranges in the StateInfo of this peer
+func (arch *blockfileArchiver) advertiseArchiveInfo() {
+	if !service.IsGossipServiceInitialized() {
+		return
+	}
+	info, err := gossiparchive.NewArchiveInfo(arch.chainID, arch.catalog)
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed retrieving the archived block ranges: %s", arch.chainID, err)
+		return
+	}
+	service.GetGossipService().UpdateArchiveInfo(info, gossipcommon.ChainID(arch.chainID))
+}
+
 // sendArchivedMessage initiates and sends a gossip message to let the other peers know...
 func (arch *blockfileArchiver) sendArchivedMessage(fileNum int) {
 	loggerArchive.Info("sendArchivedMessage...")
+	if !service.IsGossipServiceInitialized() {
+		return
+	}
 
 	// Tell the other nodes about the archived blockfile
 	gossipMsg := arch.createGossipMsg(fileNum)
@@ -170,7 +475,7 @@ func (arch *blockfileArchiver) SetBlockfileArchived(blockFileNo int, deleteTheFi
 	loggerArchiveCmn.Info("blockfileArchiver.SetBlockfileArchived... blockFileNo = ", blockFileNo)
 
 	if blockarchive.IsClient || blockarchive.IsArchiver {
-		arch.handleArchivedBlockfile(blockFileNo, deleteTheFile)
+		return arch.handleArchivedBlockfile(blockFileNo, deleteTheFile)
 	}
 
 	return nil
@@ -181,45 +486,243 @@ func (arch *blockfileArchiver) handleArchivedBlockfile(fileNum int, deleteTheFil
 
 	loggerArchiveCmn.Info("blockfileArchiver.handleArchivedBlockfile...")
 
+	// The catalog would record the partial block range of the blockfile being written
+	if err := arch.checkFinalized(fileNum); err != nil {
+		loggerArchiveCmn.Error(err)
+		return err
+	}
+
+	// Leave a persist record which indicates that the blockfile has been archived.
+	// It is marked as discarded along with the deletion of the local blockfile.
+	if err := arch.recordArchivedBlockfile(fileNum, false); err != nil {
+		loggerArchiveCmn.Error(err)
+		return err
+	}
+	if info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum)); err == nil && info != nil {
+		arch.updateNewestArchivedBlockGauge(info)
+	}
+
 	// Delete the local blockfile if required
 	if deleteTheFile {
 		if err := arch.deleteArchivedBlockfile(fileNum); err != nil {
 			return err
 		}
+		arch.notifyDiscarded(fileNum)
 	}
 
 	return nil
 }
 
-// deleteArchivedBlockfile - Called once a blockfile has been archived to delete it from the local filesystem
+// addDiscardListener registers a listener to be notified when an archived blockfile has been discarded
+func (arch *blockfileArchiver) addDiscardListener(listener blockarchive.DiscardListener) {
+	arch.listenersLock.Lock()
+	defer arch.listenersLock.Unlock()
+	arch.discardListeners = append(arch.discardListeners, listener)
+}
+
+// notifyDiscarded notifies the registered listeners that the blockfile has been discarded
+func (arch *blockfileArchiver) notifyDiscarded(fileNum int) {
+	arch.updateOldestLocalBlockGauge()
+
+	info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+	if err != nil || info == nil {
+		loggerArchiveCmn.Errorf("notifyDiscarded: no archive record for blockfile [%d]: %v", fileNum, err)
+		return
+	}
+
+	arch.listenersLock.RLock()
+	defer arch.listenersLock.RUnlock()
+	for _, listener := range arch.discardListeners {
+		listener.HandleBlockfileDiscarded(info)
+	}
+	if listener := blockarchive.PeerDiscardListener; listener != nil {
+		listener.HandleBlockfileDiscarded(info)
+	}
+}
+
+// recordArchivedBlockfile - Records the block range of an archived blockfile in the catalog, along with
+// the location and the header hash of each of its blocks. It needs to be called before the local blockfile is deleted.
+func (arch *blockfileArchiver) recordArchivedBlockfile(fileNum int, discarded bool) error {
+	if info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum)); err != nil {
+		return err
+	} else if info != nil {
+		if info.Discarded || !discarded {
+			return nil
+		}
+		info.Discarded = true
+		info.RestoreExpiry = nil
+		return arch.catalog.recordArchivedBlockfile(info)
+	}
+
+	summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+	if err != nil {
+		return err
+	}
+	location, err := arch.deriveArchiveLocation(fileNum, summary)
+	if err != nil {
+		return err
+	}
+	checksum, err := blockarchive.ComputeBlockfileChecksum(deriveBlockfilePath(arch.mgr.rootDir, fileNum), blockarchive.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+	return arch.catalog.recordArchivedBlockfileWithBlocks(&archive.ArchivedBlockfileInfo{
+		ChannelID:       arch.chainID,
+		BlockfileNo:     uint64(fileNum),
+		FirstBlockNum:   summary.firstBlockNum,
+		LastBlockNum:    summary.lastBlockNum,
+		Repository:      blockarchive.RepositoryURLOf(arch.chainID),
+		Location:        location,
+		Discarded:       discarded,
+		Checksum:        checksum.String(),
+		NetworkID:       blockarchive.NetworkID,
+		Environment:     blockarchive.Environment,
+		ArchivedAt:      ptypes.TimestampNow(),
+		LastBlockTime:   summary.lastBlockTime,
+		EncryptionKeyId: blockarchive.EncryptionKeyID,
+	}, summary.blocks)
+}
+
+// deleteArchivedBlockfile - Called once a blockfile has been archived to delete it from the local filesystem.
+// The blockfile is marked as discarded in the catalog in the same journaled operation.
 func (arch *blockfileArchiver) deleteArchivedBlockfile(fileNum int) error {
+	info, err := arch.catalog.getArchivedBlockfile(uint64(fileNum))
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return errors.Errorf("blockfile [%d] has not been archived", fileNum)
+	}
 	removeFilePath := deriveBlockfilePath(arch.blockfileDir, fileNum)
-	err := os.Remove(removeFilePath)
+	fileInfo, err := os.Stat(removeFilePath)
 	if err != nil {
-		loggerArchiveCmn.Info("deleteArchivedBlockfile: Failed to remove: ", fileNum, " Error: ", err.Error())
+		loggerDiscard.Errorw("Failed discarding archived blockfile", append(archivedBlockfileLogFields(info), "error", err)...)
+		return err
+	}
+	// The local blockfile is kept until the repository has locked the archived one
+	if blockarchive.IsObjectLockRequiredFor(arch.chainID) {
+		if err := verifyObjectLock(arch.chainID, info.Location, fileInfo.Size()); err != nil {
+			loggerDiscard.Warnw("Kept archived blockfile, its object lock could not be verified", append(archivedBlockfileLogFields(info), "error", err)...)
+			return err
+		}
+	}
+	// The local blockfile is kept until the archived one is verified, if it is sampled
+	if err := arch.verifyBeforeDiscard(info); err != nil {
+		loggerDiscard.Warnw("Kept archived blockfile, its verification failed", append(archivedBlockfileLogFields(info), "error", err)...)
+		return err
+	}
+	if err := arch.catalog.discardBlockfile(arch.blockfileDir, info); err != nil {
+		loggerDiscard.Errorw("Failed discarding archived blockfile", append(archivedBlockfileLogFields(info), "error", err)...)
 		return err
 	}
 
-	loggerArchiveCmn.Info("deleteArchivedBlockfile - deleted local blockfile: ", fileNum)
+	loggerDiscard.Infow("Discarded archived blockfile", append(archivedBlockfileLogFields(info), blockarchive.LogKeyBytes, fileInfo.Size())...)
 
 	return nil
 }
 
-// isNeedArchiving - returns whether archiving should be triggered or not
-func isNeedArchiving(blockfileFolder string, keepFileNum int) bool {
-	loggerArchive.Debugf("blockfileFolder=%s, keepFileNum=%d", blockfileFolder, keepFileNum)
+// isTooRecentToDiscard returns whether the last block of a local blockfile was committed less than
+// the least block age of the channel ago. The last modification of the blockfile is the commit of its last block.
+func (arch *blockfileArchiver) isTooRecentToDiscard(fileNum int) (bool, error) {
+	minBlockAge := blockarchive.MinBlockAgeBeforeDiscardOf(arch.chainID)
+	if minBlockAge <= 0 {
+		return false, nil
+	}
+	fileInfo, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting the stat of blockfile [%d]", fileNum)
+	}
+	return time.Since(fileInfo.ModTime()) < minBlockAge, nil
+}
+
+// recoverDiscards completes the discards of the channel interrupted by a crash
+func (arch *blockfileArchiver) recoverDiscards() error {
+	recovered, err := arch.catalog.recoverDiscards()
+	for _, fileNum := range recovered {
+		loggerDiscard.Infow("Completed the interrupted discard of archived blockfile", arch.logFields(int(fileNum))...)
+	}
+	return err
+}
 
-	files, err := ioutil.ReadDir(blockfileFolder)
+// nextArchiveBatch returns the blockfiles to archive on this archiving opportunity, oldest first, or nil
+// if there are not enough blockfiles on the local file system yet. The blockfile being written is never archived:
+// it is the one of the checkpoint info read before the listing, so that a rollover during the listing, or a next
+// blockfile left by a crash in the middle of a rollover, doesn't make it a candidate.
+func (arch *blockfileArchiver) nextArchiveBatch(each, keep int) []int {
+	current := arch.mgr.currentFileNum()
+	fileNums, sizes, err := listLocalBlockfiles(arch.blockfileDir)
 	if err != nil {
 		loggerArchive.Error(err)
-		return false
+		return nil
 	}
+	var candidates []int
+	for _, fileNum := range fileNums {
+		if fileNum >= arch.checkpoint.nextBlockfileNum && fileNum < current {
+			candidates = append(candidates, fileNum)
+		}
+	}
+	batch := selectArchiveBatch(candidates, sizes, each, keep, arch.mgr.maxBlockfileSize)
+	loggerArchive.Debugf("[%s] %d blockfile(s) eligible for archiving, %d selected", arch.chainID, len(candidates), len(batch))
+	return batch
+}
 
-	if len(files) > keepFileNum {
-		loggerArchive.Debugf("%d blockfile(s) should be archived", len(files)-keepFileNum)
-		return true
+// selectArchiveBatch selects the blockfiles to archive at once among the complete blockfiles eligible for
+// archiving, oldest first. The blockfiles of a chain have different sizes when the maximum size of the
+// blockfiles has changed, so the number of blockfiles archived at once and kept on the local file system
+// are turned into bytes with the current maximum size: a batch is archived once the eligible blockfiles
+// exceed the kept bytes by the bytes of each blockfiles, and holds the oldest blockfiles whose sizes add
+// up the closest to it. As a blockfile is finalized before it reaches the maximum size, the sizes are
+// compared with a tolerance of half a blockfile.
+func selectArchiveBatch(candidates []int, sizes map[int]int64, each, keep, maxBlockfileSize int) []int {
+	if each <= 0 {
+		return nil
 	}
+	half := int64(maxBlockfileSize) / 2
+	batchBytes := int64(each) * int64(maxBlockfileSize)
+	keepBytes := int64(keep) * int64(maxBlockfileSize)
 
-	loggerArchive.Debug("There is no blockfile to be archived yet")
-	return false
+	var total int64
+	for _, fileNum := range candidates {
+		total += sizes[fileNum]
+	}
+	if total-keepBytes+half < batchBytes {
+		return nil
+	}
+	var batch []int
+	var selected int64
+	for _, fileNum := range candidates {
+		size := sizes[fileNum]
+		if len(batch) > 0 && (selected+size/2 > batchBytes || total-selected-size+half < keepBytes) {
+			break
+		}
+		batch = append(batch, fileNum)
+		selected += size
+	}
+	return batch
+}
+
+// listLocalBlockfiles returns the numbers of the blockfiles in the directory in ascending order, with their sizes
+func listLocalBlockfiles(dir string) ([]int, map[int]int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error reading %s", dir)
+	}
+	sizes := map[int]int64{}
+	var fileNums []int
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), blockfilePrefix) {
+			continue
+		}
+		fileNum, err := strconv.Atoi(strings.TrimPrefix(file.Name(), blockfilePrefix))
+		if err != nil {
+			continue
+		}
+		sizes[fileNum] = file.Size()
+		fileNums = append(fileNums, fileNum)
+	}
+	sort.Ints(fileNums)
+	return fileNums, sizes, nil
 }
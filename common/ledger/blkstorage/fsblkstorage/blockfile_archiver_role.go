@@ -0,0 +1,246 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// archivers are the archivers of the open ledgers, whose archiving is started and stopped
+// when the archiver role is handed off between the peers without restarting them
+var archivers = struct {
+	sync.Mutex
+	m map[string]*blockfileArchiver
+}{m: map[string]*blockfileArchiver{}}
+
+func registerArchiver(arch *blockfileArchiver) {
+	archivers.Lock()
+	defer archivers.Unlock()
+	archivers.m[arch.chainID] = arch
+}
+
+func unregisterArchiver(arch *blockfileArchiver) {
+	archivers.Lock()
+	defer archivers.Unlock()
+	if archivers.m[arch.chainID] == arch {
+		delete(archivers.m, arch.chainID)
+	}
+}
+
+// openArchivers returns the archivers of the open ledgers sorted by ledger ID
+func openArchivers() []*blockfileArchiver {
+	archivers.Lock()
+	defer archivers.Unlock()
+	var open []*blockfileArchiver
+	for _, arch := range archivers.m {
+		open = append(open, arch)
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].chainID < open[j].chainID })
+	return open
+}
+
+func getArchiver(ledgerID string) *blockfileArchiver {
+	archivers.Lock()
+	defer archivers.Unlock()
+	return archivers.m[ledgerID]
+}
+
+// startArchiving starts archiving the blockfiles of the ledger as they are finalized, from the
+// checkpoint of the archiver. If check is true, the blockfiles already finalized are archived right away.
+func (arch *blockfileArchiver) startArchiving(check bool) error {
+	arch.archivingLock.Lock()
+	defer arch.archivingLock.Unlock()
+	if arch.stopArchiving != nil {
+		return nil
+	}
+	if err := arch.loadCheckpoint(); err != nil {
+		return err
+	}
+	loggerArchive.Infof("[%s] Next blockfile to be archived: %d", arch.chainID, arch.checkpoint.nextBlockfileNum)
+	// The sidecar archives the blockfiles and reports them through RecordSidecarArchived
+	if blockarchive.IsSidecarArchiving {
+		loggerArchive.Infof("[%s] The blockfiles are archived by the peer-archiver sidecar", arch.chainID)
+		return nil
+	}
+
+	loggerArchive.Info("startArchiving - creating archiverChan...")
+	// Create a new channel to allow the blockfileMgr to send messages to the archiver
+	archiverChan := make(chan blockarchive.ArchiverMessage, 5)
+	arch.mgr.SetArchiverChan(archiverChan)
+
+	// Resume the archiving of the blockfile which was interrupted by the last shutdown
+	if arch.hasInFlightBlockfile() {
+		loggerArchive.Infof("[%s] Resuming the archiving of blockfile [%d]", arch.chainID, arch.checkpoint.inFlightBlockfileNum)
+		archiverChan <- blockarchive.ArchiverMessage{ChainID: arch.chainID, BlockfileNum: arch.checkpoint.inFlightBlockfileNum}
+	} else if check {
+		archiverChan <- blockarchive.ArchiverMessage{ChainID: arch.chainID, BlockfileNum: arch.checkpoint.nextBlockfileNum}
+	}
+
+	// Start listening for messages from blockfileMgr
+	arch.stopArchiving, arch.archivingStopped = make(chan struct{}), make(chan struct{})
+	go arch.listenForBlockfiles(archiverChan, arch.stopArchiving, arch.archivingStopped)
+	return nil
+}
+
+// stopArchivingAndWait stops the archiving of the ledger. It waits for the blockfiles being archived,
+// so that the checkpoint of the archiver is final once it returns.
+func (arch *blockfileArchiver) stopArchivingAndWait() {
+	arch.archivingLock.Lock()
+	defer arch.archivingLock.Unlock()
+	if arch.stopArchiving == nil {
+		return
+	}
+	arch.mgr.SetArchiverChan(nil)
+	close(arch.stopArchiving)
+	<-arch.archivingStopped
+	arch.stopArchiving, arch.archivingStopped = nil, nil
+}
+
+// StartArchiving starts the archiving of all the open ledgers, when the peer becomes the archiver.
+// The blockfiles finalized while the peer was not the archiver are archived right away.
+func StartArchiving() error {
+	for _, arch := range openArchivers() {
+		if err := arch.startArchiving(true); err != nil {
+			return errors.WithMessagef(err, "error starting the archiving of ledger [%s]", arch.chainID)
+		}
+	}
+	return nil
+}
+
+// StopArchiving stops the archiving of all the open ledgers, when the peer hands the archiver role off.
+// It waits for the blockfiles being archived.
+func StopArchiving() {
+	for _, arch := range openArchivers() {
+		arch.stopArchivingAndWait()
+	}
+}
+
+// ExportArchiverState returns the state of the archiving of all the open ledgers, which the peer
+// taking the archiver role over resumes from. The archiving must have been stopped.
+func ExportArchiverState() ([]*archive.ChannelArchiverState, error) {
+	var states []*archive.ChannelArchiverState
+	for _, arch := range openArchivers() {
+		if err := arch.loadCheckpoint(); err != nil {
+			return nil, err
+		}
+		infos, err := arch.catalog.ListArchivedBlockfiles()
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, &archive.ChannelArchiverState{
+			ChannelId:       arch.chainID,
+			NextBlockfileNo: uint64(arch.checkpoint.nextBlockfileNum),
+			Blockfiles:      infos,
+		})
+	}
+	return states, nil
+}
+
+// VerifyArchiverState checks that the state of the archiving of a ledger exported by another peer
+// is consistent with the repository and with this peer: every archived blockfile is stored in the
+// repository, and holds the same blocks as the blockfile of the same number on this peer.
+func VerifyArchiverState(state *archive.ChannelArchiverState) error {
+	arch := getArchiver(state.ChannelId)
+	if arch == nil {
+		return errors.Errorf("ledger [%s] is not open on this peer", state.ChannelId)
+	}
+	if len(state.Blockfiles) == 0 {
+		return nil
+	}
+	sshConn, client, err := connectToRepo(state.ChannelId)
+	if err != nil {
+		return errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	for _, info := range state.Blockfiles {
+		if _, err := client.Stat(info.Location); err != nil {
+			return errors.Wrapf(err, "archived blockfile [%d] of ledger [%s] is not in the repository at %s",
+				info.BlockfileNo, state.ChannelId, info.Location)
+		}
+		if err := arch.verifyArchivedBlockfile(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyArchivedBlockfile checks that the record of a blockfile archived by another peer
+// matches the local blockfile, or the local record if the blockfile has been discarded
+func (arch *blockfileArchiver) verifyArchivedBlockfile(info *archive.ArchivedBlockfileInfo) error {
+	fileNum := int(info.BlockfileNo)
+	local, err := arch.catalog.getArchivedBlockfile(info.BlockfileNo)
+	if err != nil {
+		return err
+	}
+	filePath := deriveBlockfilePath(arch.blockfileDir, fileNum)
+	if _, err := os.Stat(filePath); err == nil {
+		summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
+		if err != nil {
+			return err
+		}
+		local = &archive.ArchivedBlockfileInfo{FirstBlockNum: summary.firstBlockNum, LastBlockNum: summary.lastBlockNum}
+		if info.Checksum != "" {
+			expected, err := blockarchive.ParseChecksum(info.Checksum)
+			if err != nil {
+				return err
+			}
+			actual, err := blockarchive.ComputeBlockfileChecksum(filePath, expected.Algorithm)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(expected.Digest, actual.Digest) {
+				return errors.Errorf("blockfile [%d] of ledger [%s] differs from the archived one: %s",
+					fileNum, arch.chainID, &blockarchive.ChecksumMismatchError{Expected: expected, Actual: actual})
+			}
+		}
+	} else if local == nil {
+		return errors.Errorf("blockfile [%d] of ledger [%s] is neither on the local file system nor in the archive catalog",
+			fileNum, arch.chainID)
+	}
+	if local.FirstBlockNum != info.FirstBlockNum || local.LastBlockNum != info.LastBlockNum {
+		return errors.Errorf("blockfile [%d] of ledger [%s] holds blocks [%d-%d], but blocks [%d-%d] have been archived",
+			fileNum, arch.chainID, local.FirstBlockNum, local.LastBlockNum, info.FirstBlockNum, info.LastBlockNum)
+	}
+	return nil
+}
+
+// ImportArchiverState records the blockfiles archived by another peer in the catalog of a ledger,
+// and moves the checkpoint of the archiver of the ledger to where the other peer stopped.
+// The records keep whether the blockfiles have been discarded from this peer.
+func ImportArchiverState(state *archive.ChannelArchiverState) error {
+	arch := getArchiver(state.ChannelId)
+	if arch == nil {
+		return errors.Errorf("ledger [%s] is not open on this peer", state.ChannelId)
+	}
+	for _, info := range state.Blockfiles {
+		record := proto.Clone(info).(*archive.ArchivedBlockfileInfo)
+		local, err := arch.catalog.getArchivedBlockfile(info.BlockfileNo)
+		if err != nil {
+			return err
+		}
+		if local != nil {
+			record.Discarded, record.RestoreExpiry = local.Discarded, local.RestoreExpiry
+		} else {
+			_, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, int(info.BlockfileNo)))
+			record.Discarded, record.RestoreExpiry = os.IsNotExist(err), nil
+		}
+		if err := arch.catalog.recordArchivedBlockfile(record); err != nil {
+			return err
+		}
+	}
+	loggerArchive.Infof("[%s] Imported %d archived blockfile(s), next blockfile to be archived: %d",
+		state.ChannelId, len(state.Blockfiles), state.NextBlockfileNo)
+	return arch.saveCheckpoint(int(state.NextBlockfileNo), noInFlightBlockfile, false)
+}
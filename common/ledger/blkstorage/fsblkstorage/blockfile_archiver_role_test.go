@@ -0,0 +1,103 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiverRoleHandoff(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath, prevIsArchiver, prevIsClient := blockarchive.BlockStorePath, blockarchive.IsArchiver, blockarchive.IsClient
+	blockarchive.BlockStorePath = blockStorePath
+	blockarchive.IsArchiver, blockarchive.IsClient = false, true
+	defer func() {
+		blockarchive.BlockStorePath, blockarchive.IsArchiver, blockarchive.IsClient = prevBlockStorePath, prevIsArchiver, prevIsClient
+	}()
+
+	// Leave out the ledgers left open by the other tests
+	prevArchivers := archivers.m
+	archivers.m = map[string]*blockfileArchiver{}
+	defer func() { archivers.m = prevArchivers }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, "", ""))
+	defer env.Cleanup()
+	var archs []*blockfileArchiver
+	for _, ledgerID := range []string{"source", "target", "other"} {
+		store, err := env.provider.OpenBlockStore(ledgerID)
+		require.NoError(t, err)
+		defer store.Shutdown()
+		ledgerBlocks := blocks
+		if ledgerID == "other" {
+			// The blockfiles of this ledger don't hold the same blocks
+			ledgerBlocks = testutil.ConstructTestBlocks(t, 30)[:5]
+		}
+		for _, block := range ledgerBlocks {
+			require.NoError(t, store.AddBlock(block))
+		}
+		archs = append(archs, store.(*fsBlockStore).archiver)
+	}
+	source, target, other := archs[0], archs[1], archs[2]
+
+	// The source peer has archived and discarded blockfile [0]
+	location, err := source.archiveLocation(0)
+	require.NoError(t, err)
+	_, err = sendBlockfileToRepo(source.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, source.SetBlockfileArchived(0, true))
+	require.NoError(t, source.saveCheckpoint(1, noInFlightBlockfile, false))
+
+	// The archiving starts and stops with the role
+	require.NoError(t, StartArchiving())
+	assert.NotNil(t, source.mgr.archiverChan)
+	StopArchiving()
+	assert.Nil(t, source.mgr.archiverChan)
+
+	states, err := ExportArchiverState()
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+	// The states are sorted by channel
+	state := states[1]
+	assert.Equal(t, "source", state.ChannelId)
+	assert.Equal(t, uint64(1), state.NextBlockfileNo)
+	require.Len(t, state.Blockfiles, 1)
+	assert.True(t, state.Blockfiles[0].Discarded)
+
+	// The target holds the same blocks in its blockfile [0]
+	state.ChannelId = "target"
+	require.NoError(t, VerifyArchiverState(state))
+	require.NoError(t, ImportArchiverState(state))
+	info, err := target.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, location, info.Location)
+	assert.False(t, info.Discarded)
+	assert.Equal(t, 1, target.checkpoint.nextBlockfileNum)
+
+	// The blockfiles of the other ledger differ
+	state.ChannelId = "other"
+	assert.Error(t, VerifyArchiverState(state))
+	info, err = other.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	assert.Nil(t, info)
+	state.ChannelId = "unknown"
+	assert.EqualError(t, VerifyArchiverState(state), "ledger [unknown] is not open on this peer")
+}
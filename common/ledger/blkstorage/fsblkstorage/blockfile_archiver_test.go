@@ -1,16 +1,22 @@
 package fsblkstorage
 
 import (
+	"os"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestAttrs tests attributes
 func TestBlockfileArchiver(t *testing.T) {
 	blockarchive.IsArchiver = true
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	ledgerid := "testledger"
 	w := newTestBlockfileWrapper(env, ledgerid)
@@ -26,3 +32,192 @@ func TestBlockfileArchiver(t *testing.T) {
 
 	// InitBlockArchiver(provider.fakeProvider)
 }
+
+// openArchivingTestStore opens a block store of an archiver peer whose blockfiles hold 10 blocks after the first one.
+// The archiving is triggered by the test only.
+func openArchivingTestStore(t *testing.T, blocks []*common.Block) (*fsBlockStore, func()) {
+	server, cleanupRepo := startTestRepository(t)
+	prevIsArchiver, prevIsClient := blockarchive.IsArchiver, blockarchive.IsClient
+	prevEach, prevBlockStorePath := blockarchive.NumBlockfileEachArchiving, blockarchive.BlockStorePath
+	blockarchive.IsArchiver, blockarchive.IsClient = true, false
+	blockarchive.NumBlockfileEachArchiving = 1000
+
+	size := 0
+	for _, block := range blocks[10:20] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockarchive.BlockStorePath = testPath()
+	env := newTestEnv(t, NewConf(blockarchive.BlockStorePath, size, server.Addr().String(), "/blkstore"))
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	return store.(*fsBlockStore), func() {
+		store.Shutdown()
+		env.Cleanup()
+		cleanupRepo()
+		blockarchive.IsArchiver, blockarchive.IsClient = prevIsArchiver, prevIsClient
+		blockarchive.NumBlockfileEachArchiving, blockarchive.BlockStorePath = prevEach, prevBlockStorePath
+	}
+}
+
+func TestArchiverSkipsCurrentBlockfile(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+	current := arch.mgr.currentFileNum()
+	require.Equal(t, 4, current)
+
+	// A crash in the middle of a rollover leaves the next blockfile, which is not checkpointed
+	file, err := os.Create(deriveBlockfilePath(arch.blockfileDir, current+1))
+	require.NoError(t, err)
+	file.Close()
+	assert.Equal(t, []int{1, 2, 3}, arch.nextArchiveBatch(3, 0))
+	assert.Empty(t, arch.nextArchiveBatch(4, 0))
+
+	_, err = arch.archiveBlockfile(current, false)
+	assert.EqualError(t, err, "blockfile [4] of ledger [testLedger] is not finalized, blockfile [4] is being written")
+	assert.EqualError(t, arch.SetBlockfileArchived(current, true), "blockfile [4] of ledger [testLedger] is not finalized, blockfile [4] is being written")
+	info, err := arch.catalog.getArchivedBlockfile(uint64(current))
+	require.NoError(t, err)
+	assert.Nil(t, info)
+	_, err = os.Stat(deriveBlockfilePath(arch.blockfileDir, current))
+	assert.NoError(t, err)
+}
+
+func TestArchiveBatchDuringRollover(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 100)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+
+	// The batches are selected while the blocks are committed, so that blockfiles roll over during the scans
+	done := make(chan struct{})
+	selected := map[int]int64{}
+	go func() {
+		defer close(done)
+		for _, block := range blocks {
+			if err := store.AddBlock(block); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		for _, fileNum := range arch.nextArchiveBatch(1, 0) {
+			fileInfo, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+			require.NoError(t, err)
+			if _, ok := selected[fileNum]; !ok {
+				selected[fileNum] = fileInfo.Size()
+			}
+		}
+	}
+
+	// A selected blockfile was complete: no block has been appended to it afterwards
+	require.NotEmpty(t, selected)
+	for fileNum, size := range selected {
+		assert.True(t, fileNum < arch.mgr.currentFileNum())
+		fileInfo, err := os.Stat(deriveBlockfilePath(arch.blockfileDir, fileNum))
+		require.NoError(t, err)
+		assert.Equal(t, size, fileInfo.Size(), "blockfile [%d] grew after being selected for archiving", fileNum)
+	}
+}
+
+func TestArchiveScheduledPass(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 60)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+	require.Equal(t, 6, arch.mgr.currentFileNum())
+
+	// A stopped archiving runs no batch
+	stop := make(chan struct{})
+	close(stop)
+	blockarchive.NumBlockfileEachArchiving = 2
+	arch.archiveScheduledPass(stop)
+	assert.Equal(t, 1, arch.checkpoint.nextBlockfileNum)
+
+	// The pass archives batches until fewer than 2 blockfiles are left before the current one
+	arch.archiveScheduledPass(make(chan struct{}))
+	assert.Equal(t, 5, arch.checkpoint.nextBlockfileNum)
+	for fileNum := uint64(1); fileNum <= 5; fileNum++ {
+		info, err := arch.catalog.getArchivedBlockfile(fileNum)
+		require.NoError(t, err)
+		assert.Equal(t, fileNum < 5, info != nil, "blockfile [%d]", fileNum)
+	}
+}
+
+func TestNextScheduledPass(t *testing.T) {
+	scheduled, timer := nextScheduledPass("archiving", nil)
+	assert.Nil(t, scheduled)
+	assert.Nil(t, timer)
+
+	never, err := blockarchive.ParseSchedule("0 0 30 2 *")
+	require.NoError(t, err)
+	scheduled, timer = nextScheduledPass("archiving", never)
+	assert.Nil(t, scheduled)
+	assert.Nil(t, timer)
+
+	everyMinute, err := blockarchive.ParseSchedule("* * * * *")
+	require.NoError(t, err)
+	scheduled, timer = nextScheduledPass("archiving", everyMinute)
+	require.NotNil(t, timer)
+	defer timer.Stop()
+	assert.NotNil(t, scheduled)
+}
+
+func TestArchiveAlerts(t *testing.T) {
+	prevNotifier, prevThreshold, prevWatermark := blockarchive.AlertNotifier, blockarchive.ArchiveFailureAlertThreshold, blockarchive.DiskHighWatermark
+	defer func() {
+		blockarchive.AlertNotifier, blockarchive.ArchiveFailureAlertThreshold, blockarchive.DiskHighWatermark = prevNotifier, prevThreshold, prevWatermark
+		usedDiskSpace = diskUsage
+	}()
+	var alerts []*blockarchive.Alert
+	blockarchive.AlertNotifier = func(alert *blockarchive.Alert) { alerts = append(alerts, alert) }
+	blockarchive.ArchiveFailureAlertThreshold = 2
+	arch := &blockfileArchiver{chainID: "testledger"}
+
+	// The alert is raised once the same blockfile has failed the threshold times in a row
+	failure := errors.New("Server unreachable")
+	arch.recordArchiveOutcome(1, failure)
+	arch.recordArchiveOutcome(2, failure)
+	assert.Empty(t, alerts)
+	arch.recordArchiveOutcome(2, failure)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, blockarchive.AlertArchiveFailure, alerts[0].Kind)
+	assert.Equal(t, "testledger", alerts[0].Channel)
+	assert.Equal(t, "the archiving of blockfile [2] failed 2 times in a row: Server unreachable", alerts[0].Message)
+	arch.recordArchiveOutcome(2, failure)
+	assert.Len(t, alerts, 1)
+	arch.recordArchiveOutcome(2, nil)
+	arch.recordArchiveOutcome(3, failure)
+	assert.Len(t, alerts, 1)
+
+	// The disk usage is checked once a watermark is set
+	alerts = nil
+	usage := 80
+	usedDiskSpace = func(path string) (int, error) { return usage, nil }
+	arch.checkDiskHighWatermark()
+	blockarchive.DiskHighWatermark = 90
+	arch.checkDiskHighWatermark()
+	assert.Empty(t, alerts)
+	usage = 90
+	arch.checkDiskHighWatermark()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, blockarchive.AlertDiskHighWatermark, alerts[0].Kind)
+}
@@ -0,0 +1,94 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// diskSpacePollInterval is how often the free disk space is checked again while a commit is paused
+var diskSpacePollInterval = time.Second
+
+// freeDiskSpace returns the bytes available to the peer on the file system of a path
+var freeDiskSpace = availableDiskSpace
+
+// pausedCommits is the number of the commits currently paused for the free disk space
+var pausedCommits int32
+
+// CheckDiskHealth returns an error if the free space of the file system of the block store is below
+// blockarchive.MinFreeDiskSpace, i.e. the archiving and discarding of the blockfiles doesn't keep up
+// with the commits. It backs the health check of the peer.
+func CheckDiskHealth() error {
+	if blockarchive.MinFreeDiskSpace <= 0 {
+		return nil
+	}
+	free, err := freeDiskSpace(blockarchive.BlockStorePath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to check the free disk space of the block store")
+	}
+	if free >= blockarchive.MinFreeDiskSpace {
+		return nil
+	}
+	msg := fmt.Sprintf("the free disk space of the block store is %d bytes, below %d bytes", free, blockarchive.MinFreeDiskSpace)
+	if paused := atomic.LoadInt32(&pausedCommits); paused > 0 {
+		msg += fmt.Sprintf(", %d commit(s) paused", paused)
+	}
+	return errors.New(msg)
+}
+
+// waitForDiskSpace pauses the commit of a block while appending it would leave less free disk space than
+// blockarchive.MinFreeDiskSpace, and signals the archiver to archive and discard the blockfiles in the meantime.
+// It returns an error once the commit has paused for blockarchive.MaxCommitPause, so that the block is not
+// written to a full disk. It returns at once unless blockarchive.ThrottleCommit is set.
+func (mgr *blockfileMgr) waitForDiskSpace(blockNum uint64, bytesToAppend int) error {
+	if !blockarchive.ThrottleCommit || blockarchive.MinFreeDiskSpace <= 0 {
+		return nil
+	}
+	maxPause := blockarchive.MaxCommitPause
+	if maxPause <= 0 {
+		maxPause = blockarchive.DefaultMaxCommitPause
+	}
+	start := time.Now()
+	paused := false
+	for {
+		free, err := freeDiskSpace(mgr.rootDir)
+		if err != nil {
+			loggerArchive.Warningf("Failed to check the free disk space before committing block [%d]: %s", blockNum, err)
+			return nil
+		}
+		if free-int64(bytesToAppend) >= blockarchive.MinFreeDiskSpace {
+			if paused {
+				loggerArchive.Infof("Resuming the commit of block [%d] after %s, %d bytes of free disk space", blockNum, time.Since(start), free)
+			}
+			return nil
+		}
+		if !paused {
+			paused = true
+			atomic.AddInt32(&pausedCommits, 1)
+			defer atomic.AddInt32(&pausedCommits, -1)
+			loggerArchive.Warningf("Pausing the commit of block [%d], %d bytes of free disk space is below %d bytes and the archiving doesn't keep up",
+				blockNum, free, blockarchive.MinFreeDiskSpace)
+			blockarchive.RaiseAlert(blockarchive.AlertDiskHighWatermark, mgr.chainID,
+				"pausing the commit of block [%d], %d bytes of free disk space is below %d bytes", blockNum, free, blockarchive.MinFreeDiskSpace)
+			// The blockfile being written doesn't roll over while the commit is paused, so the archiver is not
+			// notified of a finalized blockfile and is signaled once instead, unless no blockfile is finalized yet
+			if mgr.cpInfo.latestFileChunkSuffixNum > 0 {
+				mgr.notifyArchiver(mgr.cpInfo.latestFileChunkSuffixNum - 1)
+			}
+		}
+		if time.Since(start) >= maxPause {
+			loggerArchive.Errorf("Failing the commit of block [%d] after the maximum pause of %s, %d bytes of free disk space is below %d bytes",
+				blockNum, maxPause, free, blockarchive.MinFreeDiskSpace)
+			return errors.Errorf("commit of block [%d] failed after pausing %s for the free disk space: %d bytes are free, "+
+				"below the minimum of %d bytes, and archiving didn't free enough space", blockNum, maxPause, free, blockarchive.MinFreeDiskSpace)
+		}
+		time.Sleep(diskSpacePollInterval)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setFreeDiskSpace replaces the free disk space of the file systems with the value of free
+func setFreeDiskSpace(free *int64) func() {
+	prevFreeDiskSpace, prevPollInterval := freeDiskSpace, diskSpacePollInterval
+	freeDiskSpace = func(string) (int64, error) { return atomic.LoadInt64(free), nil }
+	diskSpacePollInterval = 10 * time.Millisecond
+	prevMin, prevThrottle, prevPause := blockarchive.MinFreeDiskSpace, blockarchive.ThrottleCommit, blockarchive.MaxCommitPause
+	return func() {
+		freeDiskSpace, diskSpacePollInterval = prevFreeDiskSpace, prevPollInterval
+		blockarchive.MinFreeDiskSpace, blockarchive.ThrottleCommit, blockarchive.MaxCommitPause = prevMin, prevThrottle, prevPause
+	}
+}
+
+func TestCheckDiskHealth(t *testing.T) {
+	free := int64(1000)
+	defer setFreeDiskSpace(&free)()
+
+	// Disabled
+	blockarchive.MinFreeDiskSpace = 0
+	assert.NoError(t, CheckDiskHealth())
+
+	blockarchive.MinFreeDiskSpace = 500
+	assert.NoError(t, CheckDiskHealth())
+	atomic.StoreInt64(&free, 100)
+	assert.EqualError(t, CheckDiskHealth(), "the free disk space of the block store is 100 bytes, below 500 bytes")
+}
+
+func TestCommitPausesForDiskSpace(t *testing.T) {
+	free := int64(1024 * 1024)
+	defer setFreeDiskSpace(&free)()
+	blockarchive.MinFreeDiskSpace = 500
+	blockarchive.ThrottleCommit = true
+
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	w := newTestBlockfileWrapper(env, "testLedger")
+	defer w.close()
+	blocks := testutil.ConstructTestBlocks(t, 2)
+	require.NoError(t, w.blockfileMgr.addBlock(blocks[0]))
+
+	atomic.StoreInt64(&free, 100)
+	committed := make(chan error, 1)
+	go func() { committed <- w.blockfileMgr.addBlock(blocks[1]) }()
+
+	// The commit waits for the free disk space, which the health check reports
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&pausedCommits) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.EqualError(t, CheckDiskHealth(), "the free disk space of the block store is 100 bytes, below 500 bytes, 1 commit(s) paused")
+	select {
+	case <-committed:
+		t.Fatal("the commit should be paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Discarding the archived blockfiles frees space
+	atomic.StoreInt64(&free, 1024*1024)
+	select {
+	case err := <-committed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the commit should have resumed")
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pausedCommits))
+	assert.Equal(t, uint64(2), w.blockfileMgr.getBlockchainInfo().Height)
+}
+
+func TestCommitPauseIsLimited(t *testing.T) {
+	free := int64(100)
+	defer setFreeDiskSpace(&free)()
+	blockarchive.MinFreeDiskSpace = 500
+	blockarchive.ThrottleCommit = true
+	blockarchive.MaxCommitPause = 50 * time.Millisecond
+
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	w := newTestBlockfileWrapper(env, "testLedger")
+	defer w.close()
+	start := time.Now()
+	err := w.blockfileMgr.addBlock(testutil.ConstructTestBlocks(t, 1)[0])
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// The commit fails rather than writing the block to a full disk
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "commit of block [0] failed after pausing 50ms for the free disk space: 100 bytes are free, below the minimum of 500 bytes")
+	assert.Equal(t, uint64(0), w.blockfileMgr.getBlockchainInfo().Height)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pausedCommits))
+
+	// Without throttling, the commits don't pause
+	blockarchive.ThrottleCommit = false
+	blockarchive.MaxCommitPause = time.Hour
+	assert.NoError(t, w.blockfileMgr.waitForDiskSpace(1, 100))
+}
+
+func TestPausedCommitSignalsArchiverOnce(t *testing.T) {
+	free := int64(100)
+	defer setFreeDiskSpace(&free)()
+	blockarchive.MinFreeDiskSpace = 500
+	blockarchive.ThrottleCommit = true
+	blockarchive.MaxCommitPause = 100 * time.Millisecond
+
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	w := newTestBlockfileWrapper(env, "testLedger")
+	defer w.close()
+	archiverChan := make(chan blockarchive.ArchiverMessage, 100)
+	w.blockfileMgr.SetArchiverChan(archiverChan)
+
+	// No blockfile is finalized yet to be archived
+	assert.Error(t, w.blockfileMgr.waitForDiskSpace(0, 100))
+	assert.Len(t, archiverChan, 0)
+
+	// The archiver is signaled once however long the commit is paused
+	w.blockfileMgr.cpInfo.latestFileChunkSuffixNum = 3
+	assert.Error(t, w.blockfileMgr.waitForDiskSpace(0, 100))
+	require.Len(t, archiverChan, 1)
+	assert.Equal(t, blockarchive.ArchiverMessage{ChainID: "testLedger", BlockfileNum: 2}, <-archiverChan)
+}
+
+func TestPausedCommitSignalsArchiver(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 41)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks[:40] {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+
+	// The free disk space is freed only by the archiver discarding the blockfiles
+	usage := func() int64 {
+		fileNums, sizes, err := listLocalBlockfiles(arch.blockfileDir)
+		require.NoError(t, err)
+		var used int64
+		for _, fileNum := range fileNums {
+			used += sizes[fileNum]
+		}
+		return used
+	}
+	capacity := usage() + 499
+	defer setFreeDiskSpace(new(int64))()
+	freeDiskSpace = func(string) (int64, error) { return capacity - usage(), nil }
+	blockarchive.MinFreeDiskSpace = 500
+	blockarchive.ThrottleCommit = true
+	blockarchive.MaxCommitPause = 5 * time.Second
+
+	// No blockfile rolls over to trigger the archiver, which is signaled by the paused commit
+	prevEach, prevKeep := blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks
+	defer func() { blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = prevEach, prevKeep }()
+	blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = 1, 0
+	require.NoError(t, store.AddBlock(blocks[40]))
+	arch.stopArchivingAndWait()
+
+	ranges, err := store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	assert.NotEmpty(t, ranges)
+	assert.Equal(t, uint64(41), store.archiver.mgr.getBlockchainInfo().Height)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pausedCommits))
+}
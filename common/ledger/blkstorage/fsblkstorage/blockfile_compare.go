@@ -0,0 +1,267 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// BlockDigest is the digest of a block held by a block source
+type BlockDigest struct {
+	BlockNum uint64
+	// HeaderHash is the hash of the header of the block, which the next block chains to
+	HeaderHash []byte
+	// ContentHash is the SHA-256 hash of the block as serialized in its blockfile
+	ContentHash []byte
+}
+
+// BlockComparison is the comparison of two block sources on the blocks they both hold
+type BlockComparison struct {
+	// Blocks compared, Compared is 0 when the sources hold no block in common
+	FirstBlockNum uint64
+	LastBlockNum  uint64
+	Compared      uint64
+	// Divergence is the first block at which the sources differ, nil if they are identical
+	Divergence *BlockDivergence
+}
+
+// BlockDivergence is the first block which differs between two block sources
+type BlockDivergence struct {
+	BlockNum uint64
+	Reason   string
+}
+
+// LocalBlockDigests returns the digests of the blocks of a ledger in the blockfiles present on the local file
+// system of blockStorePath, in ascending order. Like ScanRawBlockfile, it reads the blockfiles directly so that
+// it works while the peer is running. A partially written block at the end of the last blockfile is ignored.
+func LocalBlockDigests(blockStorePath, ledgerID string) ([]*BlockDigest, error) {
+	fileNums, err := ListRawBlockfiles(blockStorePath, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileNums) == 0 {
+		return nil, errors.Errorf("no blockfile of ledger [%s] found in %s", ledgerID, blockStorePath)
+	}
+	var digests []*BlockDigest
+	for i, fileNum := range fileNums {
+		filePath := RawBlockfilePath(blockStorePath, ledgerID, fileNum)
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading blockfile %s", filePath)
+		}
+		err = scanRawBlocks(filePath, content, func(offset int64, blockBytes []byte, block *common.Block) error {
+			digests = append(digests, newBlockDigest(blockBytes, block))
+			return nil
+		})
+		if rawErr, ok := err.(*RawBlockfileError); ok && rawErr.IsTruncated() && i == len(fileNums)-1 {
+			logger.Warningf("%s, the partially written block is ignored", rawErr)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := checkBlockDigestSequence(digests); err != nil {
+		return nil, errors.WithMessagef(err, "the blockfiles of ledger [%s] in %s don't hold a contiguous range of blocks", ledgerID, blockStorePath)
+	}
+	return digests, nil
+}
+
+// ArchivedBlockDigests returns the digests of the blocks of the archived blockfiles of a ledger, in ascending
+// order. The archived blockfiles recorded in the archive catalog of the ledger stored in blockStorePath are read
+// from the repository and verified against their checksum. It must not be called while the peer is running.
+func ArchivedBlockDigests(blockStorePath, ledgerID string) ([]*BlockDigest, error) {
+	var digests []*BlockDigest
+	err := withArchiver(blockStorePath, ledgerID, func(arch *blockfileArchiver) (err error) {
+		digests, err = arch.archivedBlockDigests()
+		return err
+	})
+	return digests, err
+}
+
+func (arch *blockfileArchiver) archivedBlockDigests() ([]*BlockDigest, error) {
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, errors.Errorf("no blockfile of ledger [%s] has been archived", arch.chainID)
+	}
+
+	sshConn, client, err := connectToRepo(arch.chainID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error connecting to the repository")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+	var digests []*BlockDigest
+	for _, info := range infos {
+		content, err := readArchivedBlockfile(client, info)
+		if err != nil {
+			return nil, err
+		}
+		first := len(digests)
+		err = scanRawBlocks(info.Location, content, func(offset int64, blockBytes []byte, block *common.Block) error {
+			digests = append(digests, newBlockDigest(blockBytes, block))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(digests) == first || digests[first].BlockNum != info.FirstBlockNum || digests[len(digests)-1].BlockNum != info.LastBlockNum {
+			return nil, errors.Errorf("archived blockfile [%d] doesn't hold blocks [%d-%d] recorded in the archive catalog",
+				info.BlockfileNo, info.FirstBlockNum, info.LastBlockNum)
+		}
+	}
+	if err := checkBlockDigestSequence(digests); err != nil {
+		return nil, errors.WithMessagef(err, "the archived blockfiles of ledger [%s] don't hold a contiguous range of blocks", arch.chainID)
+	}
+	return digests, nil
+}
+
+// readArchivedBlockfile reads the whole content of an archived blockfile from the repository,
+// verified against its checksum if any
+func readArchivedBlockfile(client repositoryClient, info *archive.ArchivedBlockfileInfo) ([]byte, error) {
+	file, err := client.Open(info.Location)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening archived blockfile %s", info.Location)
+	}
+	content, err := readBlockfileContent(file)
+	file.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading archived blockfile %s", info.Location)
+	}
+	expected, err := remoteChecksum(client, info.Location, info.Checksum)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error reading the checksum of %s", info.Location)
+	}
+	if expected == nil {
+		loggerArchive.Warningf("No checksum of archived blockfile [%d], its content is not verified", info.BlockfileNo)
+		return content, nil
+	}
+	verifier, err := blockarchive.NewChecksumWriter(expected.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	verifier.Write(content)
+	if err := verifier.Verify(expected); err != nil {
+		return nil, errors.WithMessagef(err, "archived blockfile %s is corrupted", info.Location)
+	}
+	return content, nil
+}
+
+func newBlockDigest(blockBytes []byte, block *common.Block) *BlockDigest {
+	contentHash := sha256.Sum256(blockBytes)
+	return &BlockDigest{
+		BlockNum:    block.Header.Number,
+		HeaderHash:  protoutil.BlockHeaderHash(block.Header),
+		ContentHash: contentHash[:],
+	}
+}
+
+// checkBlockDigestSequence checks that each digest is the one of the block following the previous one
+func checkBlockDigestSequence(digests []*BlockDigest) error {
+	for i := 1; i < len(digests); i++ {
+		if digests[i].BlockNum != digests[i-1].BlockNum+1 {
+			return errors.Errorf("block [%d] follows block [%d]", digests[i].BlockNum, digests[i-1].BlockNum)
+		}
+	}
+	return nil
+}
+
+// CompareBlockDigests compares two ascending sequences of block digests on the blocks they both hold, and
+// stops at the first block whose header or serialized content differs
+func CompareBlockDigests(digests, others []*BlockDigest) *BlockComparison {
+	comparison := &BlockComparison{}
+	for i, j := 0, 0; i < len(digests) && j < len(others); {
+		digest, other := digests[i], others[j]
+		if digest.BlockNum < other.BlockNum {
+			i++
+			continue
+		}
+		if digest.BlockNum > other.BlockNum {
+			j++
+			continue
+		}
+		comparison.compared(digest.BlockNum, digest.BlockNum)
+		if !bytes.Equal(digest.HeaderHash, other.HeaderHash) {
+			comparison.Divergence = &BlockDivergence{BlockNum: digest.BlockNum,
+				Reason: fmt.Sprintf("the header hashes %x and %x differ", digest.HeaderHash, other.HeaderHash)}
+			return comparison
+		}
+		if !bytes.Equal(digest.ContentHash, other.ContentHash) {
+			comparison.Divergence = &BlockDivergence{BlockNum: digest.BlockNum,
+				Reason: fmt.Sprintf("the headers are identical but the contents hash to %x and %x", digest.ContentHash, other.ContentHash)}
+			return comparison
+		}
+		i++
+		j++
+	}
+	return comparison
+}
+
+// CompareBlockDigestsWithSnapshot compares an ascending sequence of block digests with the digests of the
+// objects of an archive snapshot: the header hashes of their first block and the Merkle root of the header
+// hashes of their blocks. Only the objects whose blocks are all held by the digests and whose summary was
+// available when the snapshot was taken are compared. The Merkle root locates a divergence to the object
+// only, so the divergence is then reported at the first block of the object.
+func CompareBlockDigestsWithSnapshot(digests []*BlockDigest, snapshot *archive.ArchiveSnapshot) *BlockComparison {
+	comparison := &BlockComparison{}
+	if len(digests) == 0 {
+		return comparison
+	}
+	base, last := digests[0].BlockNum, digests[len(digests)-1].BlockNum
+	for _, object := range snapshot.Objects {
+		if object.FirstBlockNum < base || object.LastBlockNum > last || len(object.MerkleRoot) == 0 {
+			logger.Debugf("Blocks [%d-%d] of archived blockfile [%d] are not compared", object.FirstBlockNum, object.LastBlockNum, object.BlockfileNo)
+			continue
+		}
+		var headerHashes [][]byte
+		for _, digest := range digests[object.FirstBlockNum-base : object.LastBlockNum-base+1] {
+			headerHashes = append(headerHashes, digest.HeaderHash)
+		}
+		comparison.compared(object.FirstBlockNum, object.LastBlockNum)
+		if !bytes.Equal(headerHashes[0], object.FirstBlockHash) {
+			comparison.Divergence = &BlockDivergence{BlockNum: object.FirstBlockNum,
+				Reason: fmt.Sprintf("the header hash %x differs from %x in archived blockfile [%d]", headerHashes[0], object.FirstBlockHash, object.BlockfileNo)}
+			return comparison
+		}
+		if root := blockarchive.ComputeMerkleRoot(headerHashes); !bytes.Equal(root, object.MerkleRoot) {
+			comparison.Divergence = &BlockDivergence{BlockNum: object.FirstBlockNum,
+				Reason: fmt.Sprintf("blocks [%d-%d] have Merkle root %x, but archived blockfile [%d] has Merkle root %x",
+					object.FirstBlockNum, object.LastBlockNum, root, object.BlockfileNo, object.MerkleRoot)}
+			return comparison
+		}
+	}
+	return comparison
+}
+
+func (c *BlockComparison) compared(firstBlockNum, lastBlockNum uint64) {
+	if c.Compared == 0 {
+		c.FirstBlockNum = firstBlockNum
+	}
+	c.LastBlockNum = lastBlockNum
+	c.Compared += lastBlockNum - firstBlockNum + 1
+}
+
+// readBlockfileContent reads the whole blockfile of an archived object, decoding it if it is encoded
+func readBlockfileContent(r io.Reader) ([]byte, error) {
+	content, _, err := blockarchive.NewBlockfileReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return ioutil.ReadAll(content)
+}
@@ -0,0 +1,119 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareBlockDigests(t *testing.T) {
+	var repoRootDir string
+	server, cleanup := startConfiguredTestRepository(t, func(config *repository.Config) { repoRootDir = config.RootDir })
+	defer cleanup()
+	blockStorePath := testPath()
+	prevSigner, prevBlockStorePath := blockarchive.ManifestSigner, blockarchive.BlockStorePath
+	defer func() { blockarchive.ManifestSigner, blockarchive.BlockStorePath = prevSigner, prevBlockStorePath }()
+	blockarchive.ManifestSigner, blockarchive.BlockStorePath = nil, blockStorePath
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+
+	local, err := LocalBlockDigests(blockStorePath, "testLedger")
+	require.NoError(t, err)
+	require.Len(t, local, len(blocks))
+	for i, digest := range local {
+		assert.Equal(t, uint64(i), digest.BlockNum)
+		assert.Equal(t, protoutil.BlockHeaderHash(blocks[i].Header), digest.HeaderHash)
+	}
+	_, err = LocalBlockDigests(blockStorePath, "otherLedger")
+	assert.Error(t, err)
+
+	// Blockfile 0 is discarded and blockfile 1 is kept
+	for fileNum, discard := range []bool{true, false} {
+		location, err := arch.archiveLocation(fileNum)
+		require.NoError(t, err)
+		_, err = sendBlockfileToRepo(arch.blockfileDir, fileNum, location)
+		require.NoError(t, err)
+		require.NoError(t, arch.publishManifest(fileNum, location))
+		require.NoError(t, arch.handleArchivedBlockfile(fileNum, discard))
+	}
+	infos, err := arch.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+
+	// The local blockfiles and the archived blockfiles hold blocks of blockfile 1 in common
+	remaining, err := LocalBlockDigests(blockStorePath, "testLedger")
+	require.NoError(t, err)
+	assert.Equal(t, infos[1].FirstBlockNum, remaining[0].BlockNum)
+	archived, err := arch.archivedBlockDigests()
+	require.NoError(t, err)
+	require.Len(t, archived, int(infos[1].LastBlockNum)+1)
+	assert.Equal(t, local[:len(archived)], archived)
+	assert.Equal(t, &BlockComparison{FirstBlockNum: infos[1].FirstBlockNum, LastBlockNum: infos[1].LastBlockNum,
+		Compared: infos[1].LastBlockNum - infos[1].FirstBlockNum + 1}, CompareBlockDigests(remaining, archived))
+
+	// The first block whose header or content differs is reported
+	altered := make([]*BlockDigest, len(local))
+	for i, digest := range local {
+		copied := *digest
+		altered[i] = &copied
+	}
+	altered[12].ContentHash = []byte("content")
+	altered[15].HeaderHash = []byte("header")
+	comparison := CompareBlockDigests(local, altered)
+	assert.Equal(t, uint64(13), comparison.Compared)
+	require.NotNil(t, comparison.Divergence)
+	assert.Equal(t, uint64(12), comparison.Divergence.BlockNum)
+	assert.Contains(t, comparison.Divergence.Reason, "the headers are identical")
+	comparison = CompareBlockDigests(altered[14:], local)
+	require.NotNil(t, comparison.Divergence)
+	assert.Equal(t, uint64(15), comparison.Divergence.BlockNum)
+	assert.Contains(t, comparison.Divergence.Reason, "the header hashes")
+	assert.Equal(t, uint64(0), CompareBlockDigests(local[:5], local[5:]).Compared)
+
+	// The snapshot is compared on the archived blockfiles whose blocks are all held
+	snapshot, err := arch.archiveSnapshot()
+	require.NoError(t, err)
+	assert.Equal(t, &BlockComparison{FirstBlockNum: 0, LastBlockNum: infos[1].LastBlockNum, Compared: infos[1].LastBlockNum + 1},
+		CompareBlockDigestsWithSnapshot(local, snapshot))
+	assert.Equal(t, infos[1].FirstBlockNum, CompareBlockDigestsWithSnapshot(remaining, snapshot).FirstBlockNum)
+	comparison = CompareBlockDigestsWithSnapshot(altered, snapshot)
+	require.NotNil(t, comparison.Divergence)
+	assert.Equal(t, infos[1].FirstBlockNum, comparison.Divergence.BlockNum)
+	assert.Contains(t, comparison.Divergence.Reason, "Merkle root")
+
+	// An archived blockfile altered on the repository is not compared
+	remotePath := filepath.Join(repoRootDir, infos[1].Location)
+	content, err := ioutil.ReadFile(remotePath)
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(remotePath, content, 0644))
+	_, err = arch.archivedBlockDigests()
+	assert.Contains(t, err.Error(), "is corrupted")
+}
@@ -0,0 +1,332 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FetchCacheDir is the name of the directory containing the blockfiles
+	// which a client peer has retrieved through the archiver peer of its organization
+	FetchCacheDir = "fetchcache"
+
+	defaultFetchCacheSize = 8
+	fetchingSuffix        = ".fetching"
+)
+
+// fetchCache keeps on the local file system the most recently used blockfiles which a client peer
+// has retrieved through the archiver peer of its organization. Concurrent retrievals of the same
+// blockfile are coalesced into a single download.
+type fetchCache struct {
+	dir      string
+	capacity int
+	download blockfileDownloader
+
+	// prefetching of the blockfiles following a retrieved one, disabled when window is 0
+	window      int
+	isDiscarded func(ledgerID string, fileNum int) bool
+	metrics     *fetchMetrics
+
+	mutex    sync.Mutex
+	lru      *list.List
+	elements map[string]*list.Element
+	inflight map[string]*fetchCall
+	// the prefetched blockfiles which have not been read yet
+	prefetched map[string]bool
+}
+
+// blockfileDownloader writes a blockfile of a ledger retrieved through the archiver peer
+type blockfileDownloader func(ledgerID string, fileNum int, w io.Writer) error
+
+type fetchCall struct {
+	done chan struct{}
+	err  error
+	// prefetch tells whether the blockfile is retrieved in the background, and read whether
+	// a reader has joined the retrieval
+	prefetch bool
+	read     bool
+}
+
+var (
+	clientFetchCache     *fetchCache
+	clientFetchCacheOnce sync.Once
+	proxyClient          *http.Client
+	proxyClientOnce      sync.Once
+)
+
+// isFetchThroughProxyEnabled returns whether the discarded blockfiles are retrieved through the archiver peer
+func isFetchThroughProxyEnabled() bool {
+	return blockarchive.IsClient && (blockarchive.FetchBlockfile != nil || blockarchive.ProxyEndpoint != "")
+}
+
+// errFetchDisabled is the error of the reads of the discarded blockfiles of a ledger
+// whose retrieval is disabled on this peer
+func errFetchDisabled(ledgerID string) error {
+	return errors.Errorf("the retrieval of the archived blocks of channel [%s] is disabled on this peer", ledgerID)
+}
+
+// openFileThroughProxy opens a discarded blockfile of the ledger whose blockfiles are stored in rootDir,
+// after retrieving it through the archiver peer if it is not in the fetch cache
+func openFileThroughProxy(rootDir string, fileNum int) (*os.File, error) {
+	path, err := getClientFetchCache(rootDir).get(filepath.Base(rootDir), fileNum)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// getClientFetchCache returns the fetch cache of the client peer, created next to the ledger whose
+// blockfiles are stored in rootDir on the first retrieval through the archiver peer
+func getClientFetchCache(rootDir string) *fetchCache {
+	clientFetchCacheOnce.Do(func() {
+		// rootDir is <blockStorageDir>/chains/<ledgerID>
+		dir := filepath.Join(filepath.Dir(filepath.Dir(rootDir)), FetchCacheDir)
+		download := blockarchive.FetchBlockfile
+		if download == nil {
+			download = httpDownloader(blockarchive.ProxyEndpoint, getProxyClient())
+		}
+		clientFetchCache = newFetchCache(dir, blockarchive.FetchCacheSize, download)
+		if blockarchive.MetricsProvider != nil {
+			clientFetchCache.metrics = newFetchMetrics(blockarchive.MetricsProvider)
+		}
+		// The discarded blockfiles are the ones missing from the local file system
+		chainsDir := filepath.Dir(rootDir)
+		clientFetchCache.withPrefetch(blockarchive.PrefetchWindow, func(ledgerID string, fileNum int) bool {
+			_, err := os.Stat(deriveBlockfilePath(filepath.Join(chainsDir, ledgerID), fileNum))
+			return os.IsNotExist(err)
+		})
+	})
+	return clientFetchCache
+}
+
+// getProxyClient returns the HTTP client connecting to the archiver peer, shared by the retrievals
+// so that they reuse the connections
+func getProxyClient() *http.Client {
+	proxyClientOnce.Do(func() {
+		proxyClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: blockarchive.ProxyTLSConfig},
+		}
+	})
+	return proxyClient
+}
+
+// newFetchCache creates a fetch cache in dir. The blockfiles left by a previous run are removed.
+func newFetchCache(dir string, capacity int, download blockfileDownloader) *fetchCache {
+	if capacity <= 0 {
+		capacity = defaultFetchCacheSize
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Warningf("Failed to clean up the fetch cache %s: %s", dir, err)
+	}
+	return &fetchCache{
+		dir:        dir,
+		capacity:   capacity,
+		download:   download,
+		metrics:    newFetchMetrics(&disabled.Provider{}),
+		lru:        list.New(),
+		elements:   make(map[string]*list.Element),
+		inflight:   make(map[string]*fetchCall),
+		prefetched: make(map[string]bool),
+	}
+}
+
+// get returns the path to the blockfile in the cache, retrieving it through the archiver peer if needed.
+// The retrieval of a blockfile which was not prefetched, or the read of a prefetched one, triggers the
+// prefetching of the following blockfiles.
+func (c *fetchCache) get(ledgerID string, fileNum int) (string, error) {
+	path := c.path(ledgerID, fileNum)
+
+	c.mutex.Lock()
+	if element, ok := c.elements[path]; ok {
+		c.lru.MoveToFront(element)
+		prefetched := c.prefetched[path]
+		delete(c.prefetched, path)
+		c.mutex.Unlock()
+		c.metrics.hit(ledgerID, prefetched)
+		if prefetched {
+			c.prefetchAfter(ledgerID, fileNum)
+		}
+		return path, nil
+	}
+	if call, ok := c.inflight[path]; ok {
+		prefetched := call.prefetch && !call.read
+		call.read = true
+		c.mutex.Unlock()
+		c.metrics.hit(ledgerID, prefetched)
+		<-call.done
+		if prefetched && call.err == nil {
+			c.prefetchAfter(ledgerID, fileNum)
+		}
+		return path, call.err
+	}
+	call := &fetchCall{done: make(chan struct{}), read: true}
+	c.inflight[path] = call
+	c.mutex.Unlock()
+	c.metrics.misses.With("channel", ledgerID).Add(1)
+
+	if err := c.load(ledgerID, fileNum, path, call); err != nil {
+		return path, err
+	}
+	c.prefetchAfter(ledgerID, fileNum)
+	return path, nil
+}
+
+// load retrieves a blockfile for an in-flight call, and records it in the cache if retrieved
+func (c *fetchCache) load(ledgerID string, fileNum int, path string, call *fetchCall) error {
+	call.err = c.fetch(ledgerID, fileNum, path)
+
+	c.mutex.Lock()
+	delete(c.inflight, path)
+	if call.err == nil {
+		c.add(path)
+		if !call.read {
+			c.prefetched[path] = true
+		}
+	}
+	c.mutex.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// contains tells if a blockfile is in the cache or being retrieved
+func (c *fetchCache) contains(ledgerID string, fileNum int) bool {
+	path := c.path(ledgerID, fileNum)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, cached := c.elements[path]
+	_, inflight := c.inflight[path]
+	return cached || inflight
+}
+
+// path returns the path to a blockfile in the cache
+func (c *fetchCache) path(ledgerID string, fileNum int) string {
+	return filepath.Join(c.dir, ledgerID, blockfilePrefix+fmt.Sprintf("%06d", fileNum))
+}
+
+// add records a blockfile in the cache and evicts the least recently used ones beyond the capacity
+func (c *fetchCache) add(path string) {
+	c.elements[path] = c.lru.PushFront(path)
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		evicted := oldest.Value.(string)
+		delete(c.elements, evicted)
+		if c.prefetched[evicted] {
+			delete(c.prefetched, evicted)
+			c.metrics.prefetchUnused.With("channel", filepath.Base(filepath.Dir(evicted))).Add(1)
+		}
+		// The readers which still have the blockfile open keep reading it
+		if err := os.Remove(evicted); err != nil {
+			logger.Warningf("Failed to evict %s from the fetch cache: %s", evicted, err)
+		}
+	}
+}
+
+// httpDownloader downloads the blockfiles from the operations endpoint of the archiver peer
+func httpDownloader(endpoint string, client *http.Client) blockfileDownloader {
+	endpoint = strings.TrimRight(endpoint, "/")
+	return func(ledgerID string, fileNum int, w io.Writer) error {
+		url := fmt.Sprintf("%s%s%s/%d", endpoint, blockarchive.ProxyBlockfilesPath, ledgerID, fileNum)
+		logger.Debugf("Requesting %s", url)
+		req, err := newProxyRequest(url, ledgerID)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(blockarchive.ProxyChecksumAlgorithmsHeader, strings.Join(blockarchive.PreferredChecksumAlgorithms(), ", "))
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			return errors.Errorf("archiver peer failed to serve the blockfile: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+		// The checksum computed with the negotiated algorithm follows the blockfile
+		verifier, err := blockarchive.NewChecksumWriter(resp.Header.Get(blockarchive.ProxyChecksumAlgorithmHeader))
+		if err != nil {
+			return errors.WithMessage(err, "archiver peer negotiated no valid checksum algorithm")
+		}
+		buffers := blockarchive.RetrievalBuffers()
+		buf := buffers.Get()
+		defer buffers.Put(buf)
+		if _, err := io.CopyBuffer(io.MultiWriter(w, verifier), resp.Body, buf); err != nil {
+			return err
+		}
+		expected, err := blockarchive.ParseChecksum(resp.Trailer.Get(blockarchive.ProxyChecksumTrailer))
+		if err != nil {
+			return errors.WithMessage(err, "archiver peer sent no valid checksum of the blockfile")
+		}
+		return verifier.Verify(expected)
+	}
+}
+
+// newProxyRequest creates a request of a blockfile of a ledger to the archiver peer, signed by the
+// client peer so that the archiver peer authorizes it against the policy of the channel
+func newProxyRequest(url, ledgerID string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if blockarchive.SignProxyRequest != nil {
+		if err := blockarchive.SignProxyRequest(req, ledgerID); err != nil {
+			return nil, errors.WithMessage(err, "error signing the request to the archiver peer")
+		}
+	}
+	return req, nil
+}
+
+// fetch downloads a blockfile from the archiver peer. The blockfile is validated before it is
+// made available in the cache, so that a truncated download is never read.
+func (c *fetchCache) fetch(ledgerID string, fileNum int, path string) error {
+	log := loggerRetrieve.With(blockfileLogFields(ledgerID, fileNum)...).With(blockarchive.LogKeyRepository, blockarchive.ProxyEndpoint)
+	log.Debugw("Retrieving blockfile through the archiver peer")
+	start := time.Now()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "error creating directory for %s", path)
+	}
+	tmpPath := path + fetchingSuffix
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Wrapf(err, "error creating %s", tmpPath)
+	}
+	err = c.download(ledgerID, fileNum, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ScanRawBlockfile(tmpPath, func(int64, *common.Block) error { return nil })
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		log.Warnw("Failed retrieving blockfile through the archiver peer", "error", err)
+		return errors.WithMessagef(err, "error retrieving blockfile [%d] of ledger [%s] through the archiver peer", fileNum, ledgerID)
+	}
+	var size int64
+	if fileInfo, err := os.Stat(path); err == nil {
+		size = fileInfo.Size()
+	}
+	log.Infow("Retrieved blockfile through the archiver peer",
+		blockarchive.LogKeyBytes, size, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+	return nil
+}
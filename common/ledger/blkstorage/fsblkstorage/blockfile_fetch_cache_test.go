@@ -0,0 +1,204 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchCache(t *testing.T) {
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	ledgerid := "testLedger"
+	w := newTestBlockfileWrapper(env, ledgerid)
+	w.addBlocks(testutil.ConstructTestBlocks(t, 5))
+	w.close()
+	content, err := ioutil.ReadFile(RawBlockfilePath(env.provider.conf.blockStorageDir, ledgerid, 0))
+	require.NoError(t, err)
+
+	var requests int32
+	var authorization atomic.Value
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		authorization.Store(r.Header.Get(blockarchive.RequestAuthorizationHeader))
+		switch r.URL.Path {
+		case blockarchive.ProxyBlockfilesPath + ledgerid + "/4":
+			serveTestBlockfile(rw, content, "sha256:"+strings.Repeat("00", 32))
+		case blockarchive.ProxyBlockfilesPath + ledgerid + "/3":
+			http.Error(rw, "blockfile not found", http.StatusNotFound)
+		case blockarchive.ProxyBlockfilesPath + ledgerid + "/2":
+			serveTestBlockfile(rw, content[:len(content)-3], "")
+		default:
+			serveTestBlockfile(rw, content, "")
+		}
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(testPath(), FetchCacheDir)
+	c := newFetchCache(dir, 1, httpDownloader(server.URL+"/", server.Client()))
+
+	t.Run("coalescing", func(t *testing.T) {
+		var wg sync.WaitGroup
+		paths := make([]string, 3)
+		for i := range paths {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				path, err := c.get(ledgerid, 0)
+				assert.NoError(t, err)
+				paths[i] = path
+			}(i)
+		}
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		fetched, err := ioutil.ReadFile(paths[0])
+		require.NoError(t, err)
+		assert.Equal(t, content, fetched)
+		assert.Equal(t, paths[0], paths[1])
+		assert.Equal(t, paths[0], paths[2])
+
+		// Served from the cache
+		_, err = c.get(ledgerid, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		evicted := filepath.Join(dir, ledgerid, blockfilePrefix+"000000")
+		_, err := c.get(ledgerid, 1)
+		require.NoError(t, err)
+		_, err = os.Stat(evicted)
+		assert.True(t, os.IsNotExist(err))
+
+		_, err = c.get(ledgerid, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := c.get(ledgerid, 2)
+		_, ok := errors.Cause(err).(*RawBlockfileError)
+		assert.True(t, ok, "unexpected error: %v", err)
+		_, err = os.Stat(filepath.Join(dir, ledgerid, blockfilePrefix+"000002"+fetchingSuffix))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := c.get(ledgerid, 3)
+		assert.Contains(t, err.Error(), "404 Not Found: blockfile not found")
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		_, err := c.get(ledgerid, 4)
+		_, ok := errors.Cause(err).(*blockarchive.ChecksumMismatchError)
+		assert.True(t, ok, "unexpected error: %v", err)
+		_, err = os.Stat(filepath.Join(dir, ledgerid, blockfilePrefix+"000004"+fetchingSuffix))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		assert.Equal(t, "", authorization.Load())
+		blockarchive.SignProxyRequest = func(req *http.Request, channelID string) error {
+			req.Header.Set(blockarchive.RequestAuthorizationHeader, "signed for "+channelID)
+			return nil
+		}
+		defer func() { blockarchive.SignProxyRequest = nil }()
+		_, err := c.get(ledgerid, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "signed for "+ledgerid, authorization.Load())
+
+		blockarchive.SignProxyRequest = func(req *http.Request, channelID string) error {
+			return errors.New("no signing identity")
+		}
+		_, err = c.get(ledgerid, 6)
+		assert.Contains(t, err.Error(), "error signing the request to the archiver peer: no signing identity")
+	})
+}
+
+// serveTestBlockfile serves content the way the archiver peer does, followed by checksum,
+// or by the checksum of content if empty
+func serveTestBlockfile(rw http.ResponseWriter, content []byte, checksum string) {
+	rw.Header().Set(blockarchive.ProxyChecksumAlgorithmHeader, blockarchive.ChecksumSHA256)
+	rw.Header().Set("Trailer", blockarchive.ProxyChecksumTrailer)
+	rw.Write(content)
+	if checksum == "" {
+		sum := sha256.Sum256(content)
+		checksum = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	rw.Header().Set(blockarchive.ProxyChecksumTrailer, checksum)
+}
+
+func TestFetchDisabled(t *testing.T) {
+	prevIsClient, prevRetainConfigBlocks := blockarchive.IsClient, blockarchive.RetainConfigBlocks
+	prevFetchEnabled, prevFetchBlockfile := blockarchive.FetchEnabled, blockarchive.FetchBlockfile
+	defer func() {
+		blockarchive.IsClient, blockarchive.RetainConfigBlocks = prevIsClient, prevRetainConfigBlocks
+		blockarchive.FetchEnabled, blockarchive.FetchBlockfile = prevFetchEnabled, prevFetchBlockfile
+	}()
+	blockarchive.IsClient, blockarchive.RetainConfigBlocks = true, true
+	blockarchive.FetchEnabled = func(ledgerID string) bool { return ledgerID != "testLedger" }
+	var fetched int32
+	blockarchive.FetchBlockfile = func(ledgerID string, fileNum int, w io.Writer) error {
+		atomic.AddInt32(&fetched, 1)
+		return errors.New("unexpected fetch")
+	}
+
+	blocks := testutil.ConstructTestBlocks(t, 20)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	env := newTestEnv(t, NewConf(testPath(), size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.(*fsBlockStore).archiver
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+
+	// The queries for the discarded blocks fail without retrieving them, except for the retained genesis block
+	_, err = store.RetrieveBlockByNumber(5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the retrieval of the archived blocks of channel [testLedger] is disabled on this peer")
+	err = store.RestoreRange(0, 9, 0, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is disabled on this peer")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fetched))
+	block, err := store.RetrieveBlockByNumber(0)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[0], block))
+	block, err = store.RetrieveBlockByNumber(15)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[15], block))
+}
@@ -19,14 +19,14 @@ import (
 func TestConstructCheckpointInfoFromBlockFiles(t *testing.T) {
 	testPath := "/tmp/tests/fabric/common/ledger/blkstorage/fsblkstorage"
 	ledgerid := "testLedger"
-	conf := NewConf(testPath, 0)
+	conf := NewConf(testPath, 0, "", "")
 	blkStoreDir := conf.getLedgerBlockDir(ledgerid)
 	env := newTestEnv(t, conf)
 	util.CreateDirIfMissing(blkStoreDir)
 	defer env.Cleanup()
 
 	// checkpoint constructed on an empty block folder should return CPInfo with isChainEmpty: true
-	cpInfo, err := constructCheckpointInfoFromBlockFiles(blkStoreDir)
+	cpInfo, err := constructCheckpointInfoFromBlockFiles(blkStoreDir, &ArchiveConf{})
 	assert.NoError(t, err)
 	assert.Equal(t, &checkpointInfo{isChainEmpty: true, lastBlockNumber: 0, latestFileChunksize: 0, latestFileChunkSuffixNum: 0}, cpInfo)
 
@@ -82,7 +82,7 @@ func TestConstructCheckpointInfoFromBlockFiles(t *testing.T) {
 }
 
 func checkCPInfoFromFile(t *testing.T, blkStoreDir string, expectedCPInfo *checkpointInfo) {
-	cpInfo, err := constructCheckpointInfoFromBlockFiles(blkStoreDir)
+	cpInfo, err := constructCheckpointInfoFromBlockFiles(blkStoreDir, &ArchiveConf{})
 	assert.NoError(t, err)
 	assert.Equal(t, expectedCPInfo, cpInfo)
 }
@@ -0,0 +1,160 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// RebuildIndex reconstructs the block index of a ledger after a corruption of the index db.
+// The archive catalog is recovered from the index db when it is still readable, and completed with
+// the archive manifests stored on the local file system. Archived blockfiles which are not present
+// locally are recorded as discarded, so that their blocks are read from the repository.
+// All the other entries of the ledger in the index db are dropped and the index is rebuilt
+// by scanning the blockfiles. It must not be called while the peer is running.
+func RebuildIndex(conf *Conf, indexConfig *blkstorage.IndexConfig, ledgerID string) error {
+	dbProvider := leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: conf.getIndexDir()})
+	db := dbProvider.GetDBHandle(ledgerID)
+	infos, err := recoverArchiveCatalog(conf, ledgerID, db)
+	if err == nil {
+		err = resetIndex(ledgerID, db, infos)
+	}
+	dbProvider.Close()
+	if err != nil {
+		return err
+	}
+
+	// Opening the block store rebuilds the index from the blockfiles
+	provider := NewProvider(conf, indexConfig)
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(ledgerID)
+	if err != nil {
+		return errors.WithMessagef(err, "error opening the block store of ledger [%s]", ledgerID)
+	}
+	defer store.Shutdown()
+	bcInfo, err := store.GetBlockchainInfo()
+	if err != nil {
+		return err
+	}
+	logger.Infof("Rebuilt the block index of ledger [%s] up to block [%d]", ledgerID, bcInfo.Height-1)
+	return nil
+}
+
+// recoverArchiveCatalog returns the records of the archived blockfiles of a ledger from the
+// archive catalog and from the archive manifests stored on the local file system
+func recoverArchiveCatalog(conf *Conf, ledgerID string, db *leveldbhelper.DBHandle) ([]*archive.ArchivedBlockfileInfo, error) {
+	infosByNum := make(map[uint64]*archive.ArchivedBlockfileInfo)
+	infos, err := newArchiveCatalog(ledgerID, db).ListArchivedBlockfiles()
+	if err != nil {
+		logger.Warningf("Failed to read the archive catalog of ledger [%s], recovering it from the manifests: %s", ledgerID, err)
+	}
+	for _, info := range infos {
+		infosByNum[info.BlockfileNo] = info
+	}
+
+	manifests, err := readLocalManifests(conf, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, manifest := range manifests {
+		if _, ok := infosByNum[manifest.BlockfileNo]; ok {
+			continue
+		}
+		infosByNum[manifest.BlockfileNo] = &archive.ArchivedBlockfileInfo{
+			ChannelID:     ledgerID,
+			BlockfileNo:   manifest.BlockfileNo,
+			FirstBlockNum: manifest.FirstBlockNum,
+			LastBlockNum:  manifest.LastBlockNum,
+			Repository:    manifest.Repository,
+			Location:      manifest.Location,
+			Checksum:      (&blockarchive.Checksum{Algorithm: blockarchive.ChecksumSHA256, Digest: manifest.BlockfileHash}).String(),
+			ArchivedAt:    manifest.Timestamp,
+		}
+	}
+
+	rootDir := conf.getLedgerBlockDir(ledgerID)
+	recovered := make([]*archive.ArchivedBlockfileInfo, 0, len(infosByNum))
+	for _, info := range infosByNum {
+		_, err := os.Stat(deriveBlockfilePath(rootDir, int(info.BlockfileNo)))
+		info.Discarded = os.IsNotExist(err)
+		recovered = append(recovered, info)
+	}
+	sort.Slice(recovered, func(i, j int) bool { return recovered[i].BlockfileNo < recovered[j].BlockfileNo })
+	return recovered, nil
+}
+
+// readLocalManifests returns the archive manifests of a ledger stored on the local file system.
+// Their signatures are not verified since they have been produced by this peer.
+func readLocalManifests(conf *Conf, ledgerID string) ([]*archive.ArchiveManifest, error) {
+	manifestsDir := filepath.Join(conf.blockStorageDir, ManifestsDir, ledgerID)
+	fileInfos, err := ioutil.ReadDir(manifestsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading directory %s", manifestsDir)
+	}
+
+	var manifests []*archive.ArchiveManifest
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		if !strings.HasPrefix(name, blockfilePrefix) || !strings.HasSuffix(name, blockarchive.ManifestSuffix) {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, blockfilePrefix), blockarchive.ManifestSuffix)); err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(manifestsDir, name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading manifest %s", name)
+		}
+		signed := &archive.SignedArchiveManifest{}
+		manifest := &archive.ArchiveManifest{}
+		if err := proto.Unmarshal(b, signed); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling manifest %s", name)
+		}
+		if err := proto.Unmarshal(signed.Manifest, manifest); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling manifest %s", name)
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// resetIndex drops all the entries of a ledger from the index db and records the archived blockfiles again
+func resetIndex(ledgerID string, db *leveldbhelper.DBHandle, infos []*archive.ArchivedBlockfileInfo) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	itr := db.GetIterator(nil, nil)
+	for itr.Next() {
+		batch.Delete(append([]byte{}, itr.Key()...))
+	}
+	itr.Release()
+	if err := db.WriteBatch(batch, true); err != nil {
+		return errors.Wrapf(err, "error dropping the block index of ledger [%s]", ledgerID)
+	}
+	logger.Infof("Dropped [%d] entries from the block index of ledger [%s]", batch.Len(), ledgerID)
+
+	catalog := newArchiveCatalog(ledgerID, db)
+	for _, info := range infos {
+		if err := catalog.recordArchivedBlockfile(info); err != nil {
+			return err
+		}
+		logger.Infof("Recorded blockfile [%d] with blocks [%d-%d] of ledger [%s] as archived, discarded=[%t]",
+			info.BlockfileNo, info.FirstBlockNum, info.LastBlockNum, ledgerID, info.Discarded)
+	}
+	return nil
+}
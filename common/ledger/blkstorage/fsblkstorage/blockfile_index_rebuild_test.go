@@ -0,0 +1,80 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuildIndex(t *testing.T) {
+	conf := NewConf(testPath(), 0, "", "")
+	env := newTestEnv(t, conf)
+	defer func() { env.Cleanup() }()
+	ledgerid := "testLedger"
+	w := newTestBlockfileWrapper(env, ledgerid)
+	blocks := testutil.ConstructTestBlocks(t, 10)
+	w.addBlocks(blocks)
+
+	// Blockfile 0 is recorded in the catalog
+	require.NoError(t, w.blockfileMgr.archiveConf.catalog.recordArchivedBlockfile(&archive.ArchivedBlockfileInfo{
+		ChannelID:     ledgerid,
+		BlockfileNo:   0,
+		FirstBlockNum: 0,
+		LastBlockNum:  9,
+		Location:      "/blkstore/testLedger/blockfile_000000",
+	}))
+	// Blockfile 1 is only known by its manifest and is not present locally
+	manifestBytes, err := proto.Marshal(&archive.ArchiveManifest{
+		ChannelID:     ledgerid,
+		BlockfileNo:   1,
+		FirstBlockNum: 10,
+		LastBlockNum:  19,
+		Location:      "/blkstore/testLedger/blockfile_000001",
+	})
+	require.NoError(t, err)
+	signedBytes, err := proto.Marshal(&archive.SignedArchiveManifest{Manifest: manifestBytes})
+	require.NoError(t, err)
+	manifestPath := deriveManifestPath(conf, ledgerid, 1)
+	require.NoError(t, os.MkdirAll(filepath.Dir(manifestPath), 0755))
+	require.NoError(t, ioutil.WriteFile(manifestPath, signedBytes, 0644))
+
+	// Corrupt the index by dropping the index of block 5
+	require.NoError(t, w.blockfileMgr.db.Delete(constructBlockNumKey(5), true))
+	w.close()
+	env.provider.Close()
+
+	indexConfig := &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{
+		blkstorage.IndexableAttrBlockHash,
+		blkstorage.IndexableAttrBlockNum,
+		blkstorage.IndexableAttrTxID,
+		blkstorage.IndexableAttrBlockNumTranNum,
+	}}
+	require.NoError(t, RebuildIndex(conf, indexConfig, ledgerid))
+
+	env = newTestEnvSelectiveIndexing(t, conf, indexConfig.AttrsToIndex)
+	w = newTestBlockfileWrapper(env, ledgerid)
+	defer w.close()
+	w.testGetBlockByNumber(blocks, 0)
+	w.testGetBlockByHash(blocks)
+
+	infos, err := w.blockfileMgr.archiveConf.catalog.ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "/blkstore/testLedger/blockfile_000000", infos[0].Location)
+	assert.False(t, infos[0].Discarded)
+	assert.Equal(t, uint64(10), infos[1].FirstBlockNum)
+	assert.Equal(t, "/blkstore/testLedger/blockfile_000001", infos[1].Location)
+	assert.True(t, infos[1].Discarded)
+}
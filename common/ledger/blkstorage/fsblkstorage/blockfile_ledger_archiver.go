@@ -0,0 +1,192 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// LedgerArchiverConf is the configuration of a LedgerArchiver
+type LedgerArchiverConf struct {
+	// BlockStorageDir is the directory of the ledgers, which contains the ChainsDir directory
+	BlockStorageDir string
+	// StateDir is the directory where the archiver keeps the archive catalogs of the ledgers
+	StateDir string
+	// Each is the number of blockfiles archived on each archiving opportunity
+	Each int
+	// Keep is the least number of blockfiles kept on the local file system
+	Keep int
+	// Discard indicates if the archived blockfiles are deleted from the local file system
+	Discard bool
+}
+
+// LedgerArchiver archives the blockfiles of any ledger stored by fsblkstorage, such as the ledgers of an
+// orderer, independently of the process which writes the ledger. It never opens the block index of the
+// ledgers, which may be in use, and keeps the archive catalogs of the ledgers in its own db instead.
+//
+// The blockfiles are archived with the path they have on the local file system, below the archive
+// directory of the repository, so that a ledger configured with the repository reads the discarded
+// blockfiles from there. The repository is configured with the blockarchive package variables.
+type LedgerArchiver struct {
+	conf       *LedgerArchiverConf
+	dbProvider *leveldbhelper.Provider
+}
+
+// NewLedgerArchiver creates a LedgerArchiver
+func NewLedgerArchiver(conf *LedgerArchiverConf) (*LedgerArchiver, error) {
+	if conf.Each <= 0 {
+		return nil, errors.Errorf("the number of blockfiles archived at once must be positive, got %d", conf.Each)
+	}
+	if conf.Keep < 0 {
+		return nil, errors.Errorf("the number of blockfiles kept must not be negative, got %d", conf.Keep)
+	}
+	if err := os.MkdirAll(conf.StateDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "error creating state directory %s", conf.StateDir)
+	}
+	return &LedgerArchiver{
+		conf:       conf,
+		dbProvider: leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: conf.StateDir}),
+	}, nil
+}
+
+// Close releases the resources of the archiver
+func (a *LedgerArchiver) Close() {
+	a.dbProvider.Close()
+}
+
+// ListLedgers returns the IDs of the ledgers stored in the directory of the ledgers
+func (a *LedgerArchiver) ListLedgers() ([]string, error) {
+	chainsDir := filepath.Join(a.conf.BlockStorageDir, ChainsDir)
+	files, err := ioutil.ReadDir(chainsDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading directory %s", chainsDir)
+	}
+	var ledgerIDs []string
+	for _, file := range files {
+		if file.IsDir() {
+			ledgerIDs = append(ledgerIDs, file.Name())
+		}
+	}
+	return ledgerIDs, nil
+}
+
+// Catalog returns the records of the blockfiles of a ledger archived by the archiver
+func (a *LedgerArchiver) Catalog(ledgerID string) blockarchive.Catalog {
+	return a.catalog(ledgerID)
+}
+
+func (a *LedgerArchiver) catalog(ledgerID string) *archiveCatalog {
+	return newArchiveCatalog(ledgerID, a.dbProvider.GetDBHandle(ledgerID))
+}
+
+// ArchiveLedger archives the blockfiles of a ledger until no more than Each+Keep blockfiles are left
+// on the local file system, Each blockfiles at a time, and returns the number of archived blockfiles.
+// The first blockfile and the one being written are never archived.
+func (a *LedgerArchiver) ArchiveLedger(ledgerID string) (int, error) {
+	catalog := a.catalog(ledgerID)
+	// Complete the discards interrupted by a crash of the archiver first
+	if _, err := catalog.recoverDiscards(); err != nil {
+		return 0, err
+	}
+	fileNums, err := ListRawBlockfiles(a.conf.BlockStorageDir, ledgerID)
+	if err != nil {
+		return 0, err
+	}
+	infos, err := catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return 0, err
+	}
+	next := 1
+	if len(infos) > 0 {
+		next = int(infos[len(infos)-1].BlockfileNo) + 1
+	}
+
+	var candidates []int
+	for i, fileNum := range fileNums {
+		if fileNum >= next && i < len(fileNums)-1 {
+			candidates = append(candidates, fileNum)
+		}
+	}
+	// The archived blockfiles which are not discarded remain on the local file system
+	local := len(fileNums)
+	if !a.conf.Discard {
+		local = len(candidates) + 1
+	}
+
+	archived := 0
+	for local > a.conf.Each+a.conf.Keep && archived < len(candidates) {
+		for i := 0; i < a.conf.Each && archived < len(candidates); i++ {
+			if err := a.archiveBlockfile(catalog, ledgerID, candidates[archived]); err != nil {
+				return archived, err
+			}
+			archived++
+			local--
+		}
+	}
+	return archived, nil
+}
+
+// archiveBlockfile sends a blockfile of a ledger to the repository, records it in the catalog,
+// and deletes it from the local file system if required
+func (a *LedgerArchiver) archiveBlockfile(catalog *archiveCatalog, ledgerID string, fileNum int) error {
+	blockfileDir := filepath.Join(a.conf.BlockStorageDir, ChainsDir, ledgerID)
+	summary, err := scanBlockfile(blockfileDir, fileNum)
+	if err != nil {
+		return errors.WithMessagef(err, "error scanning blockfile [%d] of ledger [%s]", fileNum, ledgerID)
+	}
+	checksum, err := blockarchive.ComputeBlockfileChecksum(deriveBlockfilePath(blockfileDir, fileNum), blockarchive.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+	location := deriveArchivedBlockfilePath(blockfileDir, fileNum)
+	if _, err := sendBlockfileToRepo(blockfileDir, fileNum, location); err != nil {
+		return errors.WithMessagef(err, "error sending blockfile [%d] of ledger [%s] to the repository", fileNum, ledgerID)
+	}
+
+	info := &archive.ArchivedBlockfileInfo{
+		ChannelID:       ledgerID,
+		BlockfileNo:     uint64(fileNum),
+		FirstBlockNum:   summary.firstBlockNum,
+		LastBlockNum:    summary.lastBlockNum,
+		Repository:      blockarchive.RepositoryURLOf(ledgerID),
+		Location:        location,
+		Checksum:        checksum.String(),
+		NetworkID:       blockarchive.NetworkID,
+		Environment:     blockarchive.Environment,
+		ArchivedAt:      ptypes.TimestampNow(),
+		LastBlockTime:   summary.lastBlockTime,
+		EncryptionKeyId: blockarchive.EncryptionKeyID,
+	}
+	if err := catalog.recordArchivedBlockfileWithBlocks(info, summary.blocks); err != nil {
+		return err
+	}
+	loggerUpload.Infow("Archived blockfile", append(archivedBlockfileLogFields(info), "location", location)...)
+	if !a.conf.Discard {
+		return nil
+	}
+
+	if blockarchive.IsObjectLockRequiredFor(ledgerID) {
+		fileInfo, err := os.Stat(deriveBlockfilePath(blockfileDir, fileNum))
+		if err != nil {
+			return err
+		}
+		if err := verifyObjectLock(ledgerID, location, fileInfo.Size()); err != nil {
+			return errors.WithMessagef(err, "error verifying the object lock of blockfile [%d] of ledger [%s]", fileNum, ledgerID)
+		}
+	}
+	if err := catalog.discardBlockfile(blockfileDir, info); err != nil {
+		return errors.WithMessagef(err, "error discarding blockfile [%d] of ledger [%s]", fileNum, ledgerID)
+	}
+	loggerDiscard.Infow("Discarded archived blockfile", archivedBlockfileLogFields(info)...)
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerArchiver(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+
+	blocks := testutil.ConstructTestBlocks(t, 50)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	// The ledgers are written by another process, which reads the discarded blockfiles from the repository
+	blockStorePath := testPath()
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	for _, ledgerID := range []string{"discarded", "kept"} {
+		store, err := env.provider.OpenBlockStore(ledgerID)
+		require.NoError(t, err)
+		defer store.Shutdown()
+		for _, block := range blocks {
+			require.NoError(t, store.AddBlock(block))
+		}
+	}
+	fileNums, err := ListRawBlockfiles(blockStorePath, "discarded")
+	require.NoError(t, err)
+	require.True(t, len(fileNums) >= 5)
+
+	stateDir := filepath.Join(testPath(), "state")
+	defer os.RemoveAll(filepath.Dir(stateDir))
+
+	t.Run("discard", func(t *testing.T) {
+		archiver, err := NewLedgerArchiver(&LedgerArchiverConf{BlockStorageDir: blockStorePath, StateDir: stateDir, Each: 1, Keep: 1, Discard: true})
+		require.NoError(t, err)
+		defer archiver.Close()
+
+		ledgerIDs, err := archiver.ListLedgers()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"discarded", "kept"}, ledgerIDs)
+
+		archived, err := archiver.ArchiveLedger("discarded")
+		require.NoError(t, err)
+		assert.Equal(t, len(fileNums)-2, archived)
+		remaining, err := ListRawBlockfiles(blockStorePath, "discarded")
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, fileNums[len(fileNums)-1]}, remaining)
+
+		infos, err := archiver.Catalog("discarded").ListArchivedBlockfiles()
+		require.NoError(t, err)
+		require.Len(t, infos, archived)
+		assert.Equal(t, uint64(1), infos[0].BlockfileNo)
+		assert.Equal(t, uint64(10), infos[0].FirstBlockNum)
+		assert.True(t, infos[0].Discarded)
+		assert.NotEmpty(t, infos[0].Checksum)
+
+		// Nothing left to archive
+		archived, err = archiver.ArchiveLedger("discarded")
+		require.NoError(t, err)
+		assert.Equal(t, 0, archived)
+
+		store, err := env.provider.OpenBlockStore("discarded")
+		require.NoError(t, err)
+		block, err := store.RetrieveBlockByNumber(15)
+		require.NoError(t, err)
+		assert.Equal(t, blocks[15], block)
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		archiver, err := NewLedgerArchiver(&LedgerArchiverConf{BlockStorageDir: blockStorePath, StateDir: stateDir, Each: 1, Keep: 0})
+		require.NoError(t, err)
+		defer archiver.Close()
+
+		archived, err := archiver.ArchiveLedger("kept")
+		require.NoError(t, err)
+		assert.Equal(t, len(fileNums)-2, archived)
+		remaining, err := ListRawBlockfiles(blockStorePath, "kept")
+		require.NoError(t, err)
+		assert.Equal(t, fileNums, remaining)
+
+		archived, err = archiver.ArchiveLedger("kept")
+		require.NoError(t, err)
+		assert.Equal(t, 0, archived)
+	})
+
+	_, err = NewLedgerArchiver(&LedgerArchiverConf{BlockStorageDir: blockStorePath, StateDir: stateDir, Each: 0})
+	assert.EqualError(t, err, "the number of blockfiles archived at once must be positive, got 0")
+}
@@ -47,6 +47,10 @@ type blockfileMgr struct {
 	currentFileWriter *blockfileWriter
 	bcInfo            atomic.Value
 	archiverChan      chan blockarchive.ArchiverMessage
+	archiveConf       *ArchiveConf
+	// maxBlockfileSize is the size above which the blocks are appended to a new blockfile.
+	// It may have been different when the older blockfiles were written.
+	maxBlockfileSize int
 }
 
 /*
@@ -99,8 +103,13 @@ func newBlockfileMgr(id string, conf *Conf, indexConfig *blkstorage.IndexConfig,
 		panic(fmt.Sprintf("Error creating block storage root dir [%s]: %s", rootDir, err))
 	}
 	// Instantiate the manager, i.e. blockFileMgr structure
-	mgr := &blockfileMgr{rootDir: rootDir, conf: conf, db: indexStore}
+	mgr := &blockfileMgr{rootDir: rootDir, conf: conf, db: indexStore, maxBlockfileSize: conf.maxBlockfileSizeOf(id)}
 	mgr.chainID = id
+	mgr.archiveConf = &ArchiveConf{
+		archiveURL: conf.archiveURLOf(id),
+		archiveDir: conf.archiveConf.archiveDir,
+		catalog:    newArchiveCatalog(id, indexStore),
+	}
 
 	// cp = checkpointInfo, retrieve from the database the file suffix or number of where blocks were stored.
 	// It also retrieves the current size of that file and the last block number that was written to that file.
@@ -238,8 +247,11 @@ func (mgr *blockfileMgr) moveToNextFile() {
 		panic(fmt.Sprintf("Could not save next block file info to db: %s", err))
 	}
 	mgr.currentFileWriter = nextFileWriter
-	mgr.notifyArchiver(mgr.cpInfo.latestFileChunkSuffixNum)
+	// The archiver is notified once the checkpoint has moved past the finalized blockfile,
+	// as it never archives the blockfile of the checkpoint
+	finalized := mgr.cpInfo.latestFileChunkSuffixNum
 	mgr.updateCheckpoint(cpInfo)
+	mgr.notifyArchiver(finalized)
 }
 
 func (mgr *blockfileMgr) addBlock(block *common.Block) error {
@@ -273,10 +285,13 @@ func (mgr *blockfileMgr) addBlock(block *common.Block) error {
 	blockBytesLen := len(blockBytes)
 	blockBytesEncodedLen := proto.EncodeVarint(uint64(blockBytesLen))
 	totalBytesToAppend := blockBytesLen + len(blockBytesEncodedLen)
+	if err := mgr.waitForDiskSpace(block.Header.Number, totalBytesToAppend); err != nil {
+		return err
+	}
 
 	//Determine if we need to start a new file since the size of this block
 	//exceeds the amount of space left in the current file
-	if currentOffset+totalBytesToAppend > mgr.conf.maxBlockfileSize {
+	if currentOffset+totalBytesToAppend > mgr.maxBlockfileSize {
 		mgr.moveToNextFile()
 		currentOffset = 0
 	}
@@ -373,7 +388,7 @@ func (mgr *blockfileMgr) syncIndex() error {
 
 	//open a blockstream to the file location that was stored in the index
 	var stream *blockStream
-	if stream, err = newBlockStream(mgr.rootDir, startFileNum, int64(startOffset), endFileNum, mgr.conf.archiveConf); err != nil {
+	if stream, err = newBlockStream(mgr.rootDir, startFileNum, int64(startOffset), endFileNum, mgr.archiveConf); err != nil {
 		return err
 	}
 	var blockBytes []byte
@@ -457,6 +472,14 @@ func (mgr *blockfileMgr) updateBlockchainInfo(latestBlockHash []byte, latestBloc
 func (mgr *blockfileMgr) retrieveBlockByHash(blockHash []byte) (*common.Block, error) {
 	logger.Debugf("retrieveBlockByHash() - blockHash = [%#v]", blockHash)
 	loc, err := mgr.index.getBlockLocByHash(blockHash)
+	if err == blkstorage.ErrNotFoundInIndex || err == blkstorage.ErrAttrNotIndexed {
+		// The catalog locates the archived blocks by hash as well
+		if record, cerr := mgr.archiveConf.catalog.GetArchivedBlockByHash(blockHash); cerr != nil {
+			return nil, cerr
+		} else if record != nil {
+			return mgr.retrieveBlockByNumber(record.BlockNum)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -557,7 +580,13 @@ func (mgr *blockfileMgr) fetchTransactionEnvelope(lp *fileLocPointer) (*common.E
 }
 
 func (mgr *blockfileMgr) fetchBlockBytes(lp *fileLocPointer) ([]byte, error) {
-	stream, err := newBlockfileStream(mgr.rootDir, lp.fileSuffixNum, int64(lp.offset), mgr.conf.archiveConf)
+	if b, err := mgr.retainedBlockBytes(lp); b != nil || err != nil {
+		return b, err
+	}
+	if b, err := mgr.fetchBlockBytesByRange(lp); b != nil || err != nil {
+		return b, err
+	}
+	stream, err := newBlockfileStream(mgr.rootDir, lp.fileSuffixNum, int64(lp.offset), mgr.archiveConf)
 	if err != nil {
 		return nil, err
 	}
@@ -19,7 +19,7 @@ import (
 )
 
 func TestBlockfileMgrBlockReadWrite(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -30,7 +30,7 @@ func TestBlockfileMgrBlockReadWrite(t *testing.T) {
 }
 
 func TestAddBlockWithWrongHash(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -54,7 +54,7 @@ func TestBlockfileMgrCrashDuringWriting(t *testing.T) {
 
 func testBlockfileMgrCrashDuringWriting(t *testing.T, numBlocksBeforeCheckpoint int,
 	numBlocksAfterCheckpoint int, numLastBlockBytes int, numPartialBytesToWrite int) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	ledgerid := "testLedger"
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, ledgerid)
@@ -112,7 +112,7 @@ func testBlockfileMgrCrashDuringWriting(t *testing.T, numBlocksBeforeCheckpoint
 }
 
 func TestBlockfileMgrBlockIterator(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -140,7 +140,7 @@ func testBlockfileMgrBlockIterator(t *testing.T, blockfileMgr *blockfileMgr,
 }
 
 func TestBlockfileMgrBlockchainInfo(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -155,7 +155,7 @@ func TestBlockfileMgrBlockchainInfo(t *testing.T) {
 }
 
 func TestBlockfileMgrGetTxById(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -178,7 +178,7 @@ func TestBlockfileMgrGetTxById(t *testing.T) {
 // TestBlockfileMgrGetTxByIdDuplicateTxid tests that a transaction with an existing txid
 // (within same block or a different block) should not over-write the index by-txid (FAB-8557)
 func TestBlockfileMgrGetTxByIdDuplicateTxid(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkStore, err := env.provider.OpenBlockStore("testLedger")
 	assert.NoError(env.t, err)
@@ -244,7 +244,7 @@ func TestBlockfileMgrGetTxByIdDuplicateTxid(t *testing.T) {
 }
 
 func TestBlockfileMgrGetTxByBlockNumTranNum(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -263,7 +263,7 @@ func TestBlockfileMgrGetTxByBlockNumTranNum(t *testing.T) {
 }
 
 func TestBlockfileMgrRestart(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	ledgerid := "testLedger"
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, ledgerid)
@@ -292,7 +292,7 @@ func TestBlockfileMgrFileRolling(t *testing.T) {
 	}
 
 	maxFileSie := int(0.75 * float64(size))
-	env := newTestEnv(t, NewConf(testPath(), maxFileSie))
+	env := newTestEnv(t, NewConf(testPath(), maxFileSie, "", ""))
 	defer env.Cleanup()
 	ledgerid := "testLedger"
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, ledgerid)
@@ -308,8 +308,35 @@ func TestBlockfileMgrFileRolling(t *testing.T) {
 	blkfileMgrWrapper.testGetBlockByHash(blocks[100:])
 }
 
+func TestBlockfileMgrChannelMaxBlockfileSize(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 100)
+	size := 0
+	for _, block := range blocks {
+		by, _, err := serializeBlock(block)
+		assert.NoError(t, err, "Error while serializing block")
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	// The blocks of testLedger roll over to a new blockfile, not the ones of the other ledgers
+	conf := NewConf(testPath(), size, "", "").WithChannelMaxBlockfileSize(func(ledgerID string) int {
+		if ledgerID == "testLedger" {
+			return int(0.75 * float64(size))
+		}
+		return 0
+	})
+	env := newTestEnv(t, conf)
+	defer env.Cleanup()
+	for ledgerID, latestFileNum := range map[string]int{"testLedger": 1, "otherLedger": 0} {
+		blkfileMgrWrapper := newTestBlockfileWrapper(env, ledgerID)
+		blkfileMgrWrapper.addBlocks(blocks)
+		assert.Equal(t, latestFileNum, blkfileMgrWrapper.blockfileMgr.cpInfo.latestFileChunkSuffixNum, ledgerID)
+		blkfileMgrWrapper.testGetBlockByHash(blocks)
+		blkfileMgrWrapper.close()
+	}
+}
+
 func TestBlockfileMgrGetBlockByTxID(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
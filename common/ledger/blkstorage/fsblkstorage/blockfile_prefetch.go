@@ -0,0 +1,134 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	fetchCacheHits = metrics.CounterOpts{
+		Namespace:    "archiver",
+		Subsystem:    "fetch_cache",
+		Name:         "hits",
+		Help:         "The number of reads of discarded blockfiles served by the fetch cache, including the prefetched blockfiles.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+	fetchCacheMisses = metrics.CounterOpts{
+		Namespace:    "archiver",
+		Subsystem:    "fetch_cache",
+		Name:         "misses",
+		Help:         "The number of reads of discarded blockfiles which waited for the retrieval through the archiver peer.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+	prefetchedBlockfiles = metrics.CounterOpts{
+		Namespace:    "archiver",
+		Subsystem:    "prefetch",
+		Name:         "blockfiles",
+		Help:         "The number of blockfiles retrieved through the archiver peer in the background.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+	prefetchHits = metrics.CounterOpts{
+		Namespace:    "archiver",
+		Subsystem:    "prefetch",
+		Name:         "hits",
+		Help:         "The number of prefetched blockfiles which have been read.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+	prefetchUnused = metrics.CounterOpts{
+		Namespace:    "archiver",
+		Subsystem:    "prefetch",
+		Name:         "unused",
+		Help:         "The number of prefetched blockfiles evicted from the fetch cache before being read.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+)
+
+// fetchMetrics measure how often the reads of the discarded blockfiles are served by the fetch cache,
+// and how many prefetched blockfiles are read, to tune the size of the cache and the prefetch window
+type fetchMetrics struct {
+	hits           metrics.Counter
+	misses         metrics.Counter
+	prefetched     metrics.Counter
+	prefetchHits   metrics.Counter
+	prefetchUnused metrics.Counter
+}
+
+func newFetchMetrics(p metrics.Provider) *fetchMetrics {
+	return &fetchMetrics{
+		hits:           p.NewCounter(fetchCacheHits),
+		misses:         p.NewCounter(fetchCacheMisses),
+		prefetched:     p.NewCounter(prefetchedBlockfiles),
+		prefetchHits:   p.NewCounter(prefetchHits),
+		prefetchUnused: p.NewCounter(prefetchUnused),
+	}
+}
+
+// hit records a read served by the cache, and whether it is the first read of a prefetched blockfile
+func (m *fetchMetrics) hit(ledgerID string, prefetched bool) {
+	m.hits.With("channel", ledgerID).Add(1)
+	if prefetched {
+		m.prefetchHits.With("channel", ledgerID).Add(1)
+	}
+}
+
+// withPrefetch enables the prefetching of the window discarded blockfiles following a retrieved blockfile,
+// as the historical scans, such as the ones of explorers and audits, read the blocks sequentially.
+// The window is bounded by the capacity of the cache so that the prefetched blockfiles don't evict
+// the blockfile being read.
+func (c *fetchCache) withPrefetch(window int, isDiscarded func(ledgerID string, fileNum int) bool) *fetchCache {
+	if window >= c.capacity {
+		window = c.capacity - 1
+	}
+	if window < 0 {
+		window = 0
+	}
+	c.window = window
+	c.isDiscarded = isDiscarded
+	return c
+}
+
+// prefetchAfter retrieves in the background the blockfiles of the window following a blockfile,
+// up to the first one which is on the local file system
+func (c *fetchCache) prefetchAfter(ledgerID string, fileNum int) {
+	if c.window == 0 {
+		return
+	}
+	go func() {
+		for n := fileNum + 1; n <= fileNum+c.window; n++ {
+			if !c.isDiscarded(ledgerID, n) {
+				return
+			}
+			if err := c.prefetch(ledgerID, n); err != nil {
+				logger.Debugf("Stopped prefetching the blockfiles of ledger [%s]: %s", ledgerID, err)
+				return
+			}
+		}
+	}()
+}
+
+// prefetch retrieves a blockfile unless it is in the cache or being retrieved
+func (c *fetchCache) prefetch(ledgerID string, fileNum int) error {
+	path := c.path(ledgerID, fileNum)
+	c.mutex.Lock()
+	if _, ok := c.elements[path]; ok || c.inflight[path] != nil {
+		c.mutex.Unlock()
+		return nil
+	}
+	call := &fetchCall{done: make(chan struct{}), prefetch: true}
+	c.inflight[path] = call
+	c.mutex.Unlock()
+
+	if err := c.load(ledgerID, fileNum, path, call); err != nil {
+		return err
+	}
+	c.metrics.prefetched.With("channel", ledgerID).Add(1)
+	return nil
+}
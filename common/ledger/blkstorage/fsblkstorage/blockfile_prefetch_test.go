@@ -0,0 +1,98 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchCachePrefetch(t *testing.T) {
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	ledgerid := "testLedger"
+	w := newTestBlockfileWrapper(env, ledgerid)
+	w.addBlocks(testutil.ConstructTestBlocks(t, 5))
+	w.close()
+	content, err := ioutil.ReadFile(RawBlockfilePath(env.provider.conf.blockStorageDir, ledgerid, 0))
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested = append(requested, filepath.Base(r.URL.Path))
+		mutex.Unlock()
+		serveTestBlockfile(rw, content, "")
+	}))
+	defer server.Close()
+	waitForRequests := func(n int) []string {
+		for i := 0; i < 500; i++ {
+			mutex.Lock()
+			if len(requested) >= n {
+				defer mutex.Unlock()
+				return append([]string{}, requested...)
+			}
+			mutex.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d requests, got %v", n, requested)
+		return nil
+	}
+
+	counters := make([]*metricsfakes.Counter, 5)
+	for i := range counters {
+		counters[i] = &metricsfakes.Counter{}
+		counters[i].WithReturns(counters[i])
+	}
+	hits, misses, prefetched, prefetchHits, prefetchUnused := counters[0], counters[1], counters[2], counters[3], counters[4]
+
+	// Blockfiles 0 to 5 are discarded, the window is bounded by the capacity
+	c := newFetchCache(filepath.Join(testPath(), FetchCacheDir), 3, httpDownloader(server.URL, server.Client()))
+	c.withPrefetch(5, func(ledgerID string, fileNum int) bool { return fileNum <= 5 })
+	assert.Equal(t, 2, c.window)
+	c.metrics = &fetchMetrics{hits: hits, misses: misses, prefetched: prefetched, prefetchHits: prefetchHits, prefetchUnused: prefetchUnused}
+
+	// A scan prefetches the following blockfiles, and the window slides as they are read
+	_, err = c.get(ledgerid, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"0", "1", "2"}, waitForRequests(3))
+	_, err = c.get(ledgerid, 1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"0", "1", "2", "3"}, waitForRequests(4))
+	_, err = c.get(ledgerid, 2)
+	require.NoError(t, err)
+	_, err = c.get(ledgerid, 3)
+	require.NoError(t, err)
+	// The prefetching stops at the first local blockfile
+	assert.ElementsMatch(t, []string{"0", "1", "2", "3", "4", "5"}, waitForRequests(6))
+
+	assert.Equal(t, 1, misses.AddCallCount())
+	assert.Equal(t, 3, hits.AddCallCount())
+	assert.Equal(t, 3, prefetchHits.AddCallCount())
+	assert.Equal(t, []string{"channel", ledgerid}, prefetchHits.WithArgsForCall(0))
+
+	// A random read evicts the prefetched blockfiles which are not read
+	for i := 0; i < 500 && prefetched.AddCallCount() < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 5, prefetched.AddCallCount())
+	for _, fileNum := range []int{10, 11, 12} {
+		_, err = c.get(ledgerid, fileNum)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, misses.AddCallCount())
+	assert.Equal(t, 2, prefetchUnused.AddCallCount())
+}
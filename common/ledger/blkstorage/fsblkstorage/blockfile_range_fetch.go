@@ -0,0 +1,166 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// isRangeRetrievalEnabled returns whether the single blocks of the discarded blockfiles are retrieved
+// as byte ranges through the operations endpoint of the archiver peer
+func isRangeRetrievalEnabled() bool {
+	return blockarchive.IsClient && blockarchive.RangeRetrieval &&
+		blockarchive.FetchBlockfile == nil && blockarchive.ProxyEndpoint != "" &&
+		hasRetrievalStage(blockarchive.RetrievalStageArchiver)
+}
+
+// fetchBlockBytesByRange retrieves through the archiver peer only the bytes of the block at the location,
+// when its blockfile has been discarded and is not in the fetch cache. It returns nil if the block is to be
+// read from its blockfile instead, in particular if the archiver peer doesn't serve byte ranges or if the
+// block has no record in the catalog to verify it against, its blockfile being verified as a whole.
+func (mgr *blockfileMgr) fetchBlockBytesByRange(lp *fileLocPointer) ([]byte, error) {
+	if !isRangeRetrievalEnabled() {
+		return nil, nil
+	}
+	if _, err := os.Stat(deriveBlockfilePath(mgr.rootDir, lp.fileSuffixNum)); !os.IsNotExist(err) {
+		return nil, nil
+	}
+	if !blockarchive.IsFetchEnabled(mgr.chainID) {
+		return nil, errFetchDisabled(mgr.chainID)
+	}
+	// The blockfile retrieved for a previous read serves the following ones
+	if getClientFetchCache(mgr.rootDir).contains(mgr.chainID, lp.fileSuffixNum) {
+		return nil, nil
+	}
+
+	// The record of the block in the catalog locates the block and identifies the bytes served
+	record, err := mgr.archiveConf.catalog.getArchivedBlockAt(uint64(lp.fileSuffixNum), uint64(lp.offset))
+	if err != nil || record == nil {
+		return nil, err
+	}
+	log := loggerRetrieve.With(blockfileLogFields(mgr.chainID, lp.fileSuffixNum)...).With(blockarchive.LogKeyRepository, blockarchive.ProxyEndpoint)
+	start := time.Now()
+	offset := int64(lp.offset)
+	// The bytes are read into a pooled buffer, which bounds the memory of concurrent retrievals
+	buffers := blockarchive.RetrievalBuffers()
+	buf := buffers.Get()
+	defer buffers.Put(buf)
+	client := rangeRetrievalClient()
+	// The byte ranges share the circuit of the archiver stage with the blockfiles retrieved through the archiver peer
+	breaker := getRetrievalBreaker(blockarchive.RetrievalStageArchiver)
+	if err := breaker.allow(); err != nil {
+		return nil, rangeRetrievalError(errors.WithMessage(err, "retrieval stage [archiver] failed"))
+	}
+	b, served, err := fetchByteRange(blockarchive.ProxyEndpoint, client, mgr.chainID, lp.fileSuffixNum, offset, int64(record.Length), buf[:0])
+	breaker.record(err)
+	if err != nil || !served {
+		if err != nil {
+			log.Warnw("Failed retrieving block through the archiver peer", "offset", offset, "error", err)
+			return nil, rangeRetrievalError(err)
+		}
+		return nil, nil
+	}
+	// A block is preceded by its length
+	length, n := proto.DecodeVarint(b)
+	if n == 0 {
+		return nil, errors.Errorf("invalid block length at offset [%d] of blockfile [%d]", offset, lp.fileSuffixNum)
+	}
+	end := int64(n) + int64(length)
+	if int64(len(b)) < end {
+		return nil, errors.Wrapf(ErrUnexpectedEndOfBlockfile, "block at offset [%d] of blockfile [%d] is truncated", offset, lp.fileSuffixNum)
+	}
+	if err := verifyArchivedBlockBytes(b[n:end], record); err != nil {
+		log.Warnw("Block retrieved through the archiver peer doesn't match the catalog", "offset", offset, "error", err)
+		return nil, err
+	}
+	log.Debugw("Retrieved block through the archiver peer", "offset", offset,
+		blockarchive.LogKeyBytes, end, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+	return append([]byte(nil), b[n:end]...), nil
+}
+
+// verifyArchivedBlockBytes checks the number and the header hash of a block against its record in the catalog,
+// and its data against the data hash of its header
+func verifyArchivedBlockBytes(blockBytes []byte, record *archive.ArchivedBlockInfo) error {
+	block, err := deserializeBlock(blockBytes)
+	if err != nil {
+		return err
+	}
+	if block.Header.Number != record.BlockNum {
+		return errors.Errorf("block [%d] found at offset [%d] of blockfile [%d], block [%d] expected",
+			block.Header.Number, record.Offset, record.BlockfileNo, record.BlockNum)
+	}
+	if !bytes.Equal(protoutil.BlockHeaderHash(block.Header), record.HeaderHash) {
+		return errors.Errorf("header hash of block [%d] doesn't match the hash recorded at archive time", record.BlockNum)
+	}
+	if !bytes.Equal(protoutil.BlockDataHash(block.Data), block.Header.DataHash) {
+		return errors.Errorf("data of block [%d] doesn't match the data hash of its header", record.BlockNum)
+	}
+	return nil
+}
+
+// fetchByteRange retrieves at most length bytes of a blockfile from offset through the operations endpoint
+// of the archiver peer, and appends them to buf. Fewer bytes are returned at the end of the blockfile.
+// served is false if the archiver peer doesn't serve byte ranges, or not the ones of this blockfile.
+func fetchByteRange(endpoint string, client *http.Client, ledgerID string, fileNum int, offset, length int64, buf []byte) (b []byte, served bool, err error) {
+	url := fmt.Sprintf("%s%s%s/%d", strings.TrimRight(endpoint, "/"), blockarchive.ProxyBlockfilesPath, ledgerID, fileNum)
+	req, err := newProxyRequest(url, ledgerID)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// The archiver peer predates the byte ranges and sends the entire blockfile
+		return nil, false, nil
+	case http.StatusNotImplemented:
+		// The blockfile is archived compressed or encrypted, and is retrieved entirely
+		return nil, false, nil
+	default:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, false, errors.Errorf("archiver peer failed to serve bytes [%d-%d] of the blockfile: %s: %s",
+			offset, offset+length-1, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	b, err = appendAll(buf, io.LimitReader(resp.Body, length))
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// appendAll appends the content of the reader to b, which grows only when its capacity is exhausted
+func appendAll(b []byte, r io.Reader) ([]byte, error) {
+	for {
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return b, err
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	lutils "github.com/hyperledger/fabric/core/ledger/util"
+	"github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchBlockBytesByRange(t *testing.T) {
+	prevIsClient, prevRangeRetrieval := blockarchive.IsClient, blockarchive.RangeRetrieval
+	prevProxyEndpoint, prevFetchBlockfile := blockarchive.ProxyEndpoint, blockarchive.FetchBlockfile
+	prevBlockStorePath := blockarchive.BlockStorePath
+	defer func() {
+		blockarchive.IsClient, blockarchive.RangeRetrieval = prevIsClient, prevRangeRetrieval
+		blockarchive.ProxyEndpoint, blockarchive.FetchBlockfile = prevProxyEndpoint, prevFetchBlockfile
+		blockarchive.BlockStorePath = prevBlockStorePath
+		clientFetchCache, clientFetchCacheOnce = nil, sync.Once{}
+		proxyClient, proxyClientOnce = nil, sync.Once{}
+	}()
+	clientFetchCache, clientFetchCacheOnce = nil, sync.Once{}
+	proxyClient, proxyClientOnce = nil, sync.Once{}
+
+	// Blockfile [0] holds small blocks and a block larger than the first range requested
+	bg, genesisBlock := testutil.NewBlockGenerator(t, "testLedger", false)
+	blocks := append([]*common.Block{genesisBlock}, bg.NextTestBlocks(4)...)
+	largeBlock := bg.NextTestBlock(2, 80*1024)
+	largeBlock.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = lutils.NewTxValidationFlagsSetValue(2, pb.TxValidationCode_VALID)
+	blocks = append(blocks, largeBlock)
+	size := 0
+	for _, block := range blocks {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blocks = append(blocks, bg.NextTestBlocks(5)...)
+
+	blockStorePath := testPath()
+	blockarchive.BlockStorePath = blockStorePath
+	env := newTestEnv(t, NewConf(blockStorePath, size, "", ""))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	content, err := ioutil.ReadFile(RawBlockfilePath(blockStorePath, "testLedger", 0))
+	require.NoError(t, err)
+	require.Equal(t, size, len(content))
+
+	// The blocks of a blockfile of the archiver peer are served from any offset
+	blockfile, fileSize, err := OpenBlockfileRangeForProxy("testLedger", 0, 10, store.GetArchiveCatalog())
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(blockfile)
+	blockfile.Close()
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), fileSize)
+	assert.Equal(t, content[10:], b)
+
+	arch := store.(*fsBlockStore).archiver
+	require.NoError(t, arch.recordArchivedBlockfile(0, false))
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+
+	var lock sync.Mutex
+	var ranges []string
+	legacy := false
+	served := content
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		ignoreRange, servedContent := legacy, served
+		lock.Unlock()
+		if r.Header.Get("Range") != "" && !ignoreRange {
+			http.ServeContent(rw, r, "", time.Time{}, bytes.NewReader(servedContent))
+			return
+		}
+		serveTestBlockfile(rw, content, "")
+	}))
+	defer server.Close()
+	blockarchive.IsClient, blockarchive.RangeRetrieval, blockarchive.ProxyEndpoint = true, true, server.URL
+	requested := func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		r := ranges
+		ranges = nil
+		return r
+	}
+
+	// A small block is retrieved in a single range
+	block, err := store.RetrieveBlockByNumber(2)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[2], block))
+	assert.Len(t, requested(), 1)
+
+	// A large block is retrieved in a single range as well, its length being recorded in the catalog
+	block, err = store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[5], block))
+	assert.Len(t, requested(), 1)
+	assert.False(t, getClientFetchCache(arch.mgr.rootDir).contains("testLedger", 0))
+
+	// Without the record of a block, the block is read from its blockfile, verified as a whole
+	loc, err := arch.mgr.index.getBlockLocByBlockNum(5)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.db.Delete(constructArchivedBlockKey(0, 5), true))
+	b, err = arch.mgr.fetchBlockBytesByRange(loc)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+	assert.Empty(t, requested())
+
+	// A block whose data doesn't match the data hash of its header is rejected
+	record, err := arch.catalog.GetArchivedBlock(2)
+	require.NoError(t, err)
+	tampered := append([]byte(nil), content...)
+	i := bytes.Index(tampered[record.Offset:], blocks[2].Data.Data[0])
+	require.True(t, i >= 0)
+	tampered[int(record.Offset)+i+len(blocks[2].Data.Data[0])/2] ^= 0xff
+	lock.Lock()
+	served = tampered
+	lock.Unlock()
+	_, err = store.RetrieveBlockByNumber(2)
+	assert.EqualError(t, err, "data of block [2] doesn't match the data hash of its header")
+	requested()
+	lock.Lock()
+	served = content
+	lock.Unlock()
+
+	// A block which doesn't match its record in the catalog is rejected
+	record, err = arch.catalog.GetArchivedBlock(1)
+	require.NoError(t, err)
+	record.HeaderHash = []byte("wrong hash")
+	b, err = proto.Marshal(record)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.db.Put(constructArchivedBlockKey(0, 1), b, true))
+	_, err = store.RetrieveBlockByNumber(1)
+	assert.EqualError(t, err, "header hash of block [1] doesn't match the hash recorded at archive time")
+	requested()
+
+	// The blockfile is retrieved entirely from an archiver peer which doesn't serve byte ranges
+	lock.Lock()
+	legacy = true
+	lock.Unlock()
+	block, err = store.RetrieveBlockByNumber(3)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[3], block))
+	r := requested()
+	require.Len(t, r, 2)
+	assert.NotEmpty(t, r[0])
+	assert.Empty(t, r[1])
+
+	// and serves the following reads
+	block, err = store.RetrieveBlockByNumber(4)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(blocks[4], block))
+	assert.Empty(t, requested())
+
+	// The byte ranges of a blockfile archived compressed or encrypted are refused, it is retrieved entirely
+	refusing := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, ErrEncodedBlockfileRange.Error(), http.StatusNotImplemented)
+	}))
+	defer refusing.Close()
+	b, servedRange, err := fetchByteRange(refusing.URL, rangeRetrievalClient(), "testLedger", 0, 10, 100, nil)
+	assert.NoError(t, err)
+	assert.False(t, servedRange)
+	assert.Nil(t, b)
+}
@@ -0,0 +1,133 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	ledgerutil "github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+// binaryVarintMaxLen is the maximum length of the varint prefixing each block in a blockfile
+const binaryVarintMaxLen = 10
+
+// RawBlockfileError reports a corruption detected while parsing a blockfile directly from the file system
+type RawBlockfileError struct {
+	FilePath string
+	// Offset of the length prefix of the corrupted block
+	Offset int64
+	Err    error
+}
+
+func (e *RawBlockfileError) Error() string {
+	return fmt.Sprintf("blockfile %s is corrupted at offset [%d]: %s", e.FilePath, e.Offset, e.Err)
+}
+
+// IsTruncated returns whether the corruption is a partially written block at the end of the blockfile,
+// which is possible if a crash occurred while appending a block
+func (e *RawBlockfileError) IsTruncated() bool {
+	return e.Err == ErrUnexpectedEndOfBlockfile
+}
+
+// ListRawBlockfiles returns the numbers of the blockfiles of a ledger present on the local
+// file system in ascending order. It reads the chains directory only, not the block index.
+func ListRawBlockfiles(blockStorePath, ledgerID string) ([]int, error) {
+	ledgerDir := filepath.Join(blockStorePath, ChainsDir, ledgerID)
+	fileInfos, err := ioutil.ReadDir(ledgerDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading directory %s", ledgerDir)
+	}
+	var fileNums []int
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		if fileInfo.IsDir() || !strings.HasPrefix(name, blockfilePrefix) {
+			continue
+		}
+		fileNum, err := strconv.Atoi(strings.TrimPrefix(name, blockfilePrefix))
+		if err != nil {
+			continue
+		}
+		fileNums = append(fileNums, fileNum)
+	}
+	sort.Ints(fileNums)
+	return fileNums, nil
+}
+
+// RawBlockfilePath returns the path to a blockfile of a ledger on the local file system
+func RawBlockfilePath(blockStorePath, ledgerID string, fileNum int) string {
+	return deriveBlockfilePath(filepath.Join(blockStorePath, ChainsDir, ledgerID), fileNum)
+}
+
+// ScanRawBlockfile parses a blockfile directly from the file system, validating the length-prefixed framing
+// of each block, and invokes handle with the offset and the content of each block in order.
+// It doesn't rely on the block index so that it works even if the index is damaged.
+// A corruption of the blockfile is reported as a *RawBlockfileError.
+func ScanRawBlockfile(filePath string, handle func(offset int64, block *common.Block) error) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "error reading blockfile %s", filePath)
+	}
+	return scanRawBlocks(filePath, content, func(offset int64, _ []byte, block *common.Block) error {
+		return handle(offset, block)
+	})
+}
+
+// scanRawBlocks parses the content of a blockfile like ScanRawBlockfile, and also passes the serialized
+// block to handle
+func scanRawBlocks(filePath string, content []byte, handle func(offset int64, blockBytes []byte, block *common.Block) error) error {
+	var offset int64
+	for offset < int64(len(content)) {
+		remaining := content[offset:]
+		length, n := proto.DecodeVarint(remaining)
+		if n == 0 {
+			// A varint is at most 10 bytes long, fewer remaining bytes means a partially written length prefix
+			if len(remaining) < binaryVarintMaxLen {
+				return &RawBlockfileError{filePath, offset, ErrUnexpectedEndOfBlockfile}
+			}
+			return &RawBlockfileError{filePath, offset, errors.Errorf("invalid length prefix [%#v]", remaining[:binaryVarintMaxLen])}
+		}
+		if uint64(len(remaining)-n) < length {
+			return &RawBlockfileError{filePath, offset, ErrUnexpectedEndOfBlockfile}
+		}
+		blockBytes := remaining[n : n+int(length)]
+		block, err := deserializeRawBlock(blockBytes)
+		if err != nil {
+			return &RawBlockfileError{filePath, offset, err}
+		}
+		if err := handle(offset, blockBytes, block); err != nil {
+			return err
+		}
+		offset += int64(n) + int64(length)
+	}
+	return nil
+}
+
+// deserializeRawBlock is the same as deserializeBlock but also checks that the whole content is consumed
+func deserializeRawBlock(serializedBlockBytes []byte) (*common.Block, error) {
+	block := &common.Block{}
+	var err error
+	b := ledgerutil.NewBuffer(serializedBlockBytes)
+	if block.Header, err = extractHeader(b); err != nil {
+		return nil, err
+	}
+	if block.Data, _, err = extractData(b); err != nil {
+		return nil, err
+	}
+	if block.Metadata, err = extractMetadata(b); err != nil {
+		return nil, err
+	}
+	if consumed := b.GetBytesConsumed(); consumed != len(serializedBlockBytes) {
+		return nil, errors.Errorf("[%d] unexpected trailing bytes after block [%d]", len(serializedBlockBytes)-consumed, block.Header.Number)
+	}
+	return block, nil
+}
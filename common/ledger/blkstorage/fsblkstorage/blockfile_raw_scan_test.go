@@ -0,0 +1,72 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanRawBlockfile(t *testing.T) {
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
+	defer env.Cleanup()
+	ledgerid := "testLedger"
+	w := newTestBlockfileWrapper(env, ledgerid)
+	blocks := testutil.ConstructTestBlocks(t, 5)
+	w.addBlocks(blocks)
+	w.close()
+
+	fileNums, err := ListRawBlockfiles(env.provider.conf.blockStorageDir, ledgerid)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, fileNums)
+
+	filePath := RawBlockfilePath(env.provider.conf.blockStorageDir, ledgerid, 0)
+	var scanned []*common.Block
+	err = ScanRawBlockfile(filePath, func(offset int64, block *common.Block) error {
+		scanned = append(scanned, block)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, scanned, len(blocks))
+	for i, block := range blocks {
+		assert.True(t, proto.Equal(block, scanned[i]), "block [%d] doesn't match", i)
+	}
+
+	content, err := ioutil.ReadFile(filePath)
+	require.NoError(t, err)
+
+	t.Run("truncated", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(filePath, content[:len(content)-3], 0600))
+		err := ScanRawBlockfile(filePath, func(int64, *common.Block) error { return nil })
+		rawErr, ok := err.(*RawBlockfileError)
+		require.True(t, ok)
+		assert.True(t, rawErr.IsTruncated())
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		corrupted := append([]byte{}, content...)
+		// Make the length prefix of the first block cover one byte less
+		corrupted[0]--
+		require.NoError(t, ioutil.WriteFile(filePath, corrupted, 0600))
+		err := ScanRawBlockfile(filePath, func(int64, *common.Block) error { return nil })
+		rawErr, ok := err.(*RawBlockfileError)
+		require.True(t, ok)
+		assert.False(t, rawErr.IsTruncated())
+		assert.Equal(t, int64(0), rawErr.Offset)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		require.NoError(t, os.Remove(filePath))
+		err := ScanRawBlockfile(filePath, func(int64, *common.Block) error { return nil })
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,140 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+)
+
+// retrievalBreaker is the circuit breaker of a stage of the retrieval reaching a remote service, the archiver
+// peer or the repository. The circuit opens after blockarchive.RetrievalBreakerThreshold consecutive failures
+// of the service, and the stage then fails immediately instead of every retrieval waiting for its timeout.
+// Once blockarchive.RetrievalBreakerCooldown has elapsed, a single retrieval probes the service: the circuit
+// closes when it succeeds and opens again otherwise.
+type retrievalBreaker struct {
+	stage    string
+	mutex    sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	retrievalBreakersLock sync.Mutex
+	retrievalBreakers     = map[string]*retrievalBreaker{}
+)
+
+// getRetrievalBreaker returns the circuit breaker of a stage, nil for the cache stage which reads local files
+func getRetrievalBreaker(stage string) *retrievalBreaker {
+	if stage != blockarchive.RetrievalStageArchiver && stage != blockarchive.RetrievalStageRepository {
+		return nil
+	}
+	retrievalBreakersLock.Lock()
+	defer retrievalBreakersLock.Unlock()
+	breaker, ok := retrievalBreakers[stage]
+	if !ok {
+		breaker = &retrievalBreaker{stage: stage}
+		retrievalBreakers[stage] = breaker
+	}
+	return breaker
+}
+
+// allow returns an error when the circuit is open, in which case the stage is not tried
+func (b *retrievalBreaker) allow() error {
+	if b == nil || blockarchive.RetrievalBreakerThreshold <= 0 {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failures < blockarchive.RetrievalBreakerThreshold {
+		return nil
+	}
+	if b.probing {
+		return errors.Errorf("circuit open after %d consecutive failures, being probed", b.failures)
+	}
+	if remaining := blockarchive.RetrievalBreakerCooldown - time.Since(b.openedAt); remaining > 0 {
+		return errors.Errorf("circuit open after %d consecutive failures, retried in %s", b.failures, remaining.Round(time.Millisecond))
+	}
+	b.probing = true
+	return nil
+}
+
+// record records the outcome of a retrieval let through by allow. Only the failures of the service count,
+// a blockfile missing from the repository or being restored from its storage tier is an answer of the service.
+func (b *retrievalBreaker) record(err error) {
+	if b == nil || blockarchive.RetrievalBreakerThreshold <= 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+	if !isServiceFailure(err) {
+		if b.failures >= blockarchive.RetrievalBreakerThreshold {
+			loggerRetrieve.Infow("Retrieval stage is available again, closing its circuit", "stage", b.stage)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= blockarchive.RetrievalBreakerThreshold {
+		if b.failures == blockarchive.RetrievalBreakerThreshold {
+			loggerRetrieve.Warnw("Retrieval stage keeps failing, opening its circuit", "stage", b.stage,
+				"failures", b.failures, "cooldown", blockarchive.RetrievalBreakerCooldown, "error", err)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// isServiceFailure tells whether a retrieval failed because the archiver peer or the repository is unavailable
+// or too slow, rather than because of the blockfile itself
+func isServiceFailure(err error) bool {
+	if err == nil || blockarchive.IsRestoreInProgress(err) {
+		return false
+	}
+	cause := errors.Cause(err)
+	if os.IsNotExist(cause) {
+		return false
+	}
+	switch cause := cause.(type) {
+	case *sftp.StatusError:
+		// The repository server answered the request
+		return false
+	case *webhdfs.RemoteException:
+		// The namenodes of HDFS answer in standby when none of them is active
+		return cause.Unavailable()
+	}
+	return true
+}
+
+// openAtStageWithRetries opens a discarded blockfile through a stage, which gives up after its timeout and is
+// tried again blockarchive.RetrievalRetries times when its service fails, unless its circuit is open
+func openAtStageWithRetries(stage, rootDir string, fileNum int, archiveConf *ArchiveConf, priority retrievalPriority) (*retrievedBlockfile, error) {
+	log := loggerRetrieve.With(blockfileLogFields(filepath.Base(rootDir), fileNum)...)
+	breaker := getRetrievalBreaker(stage)
+	backoff := blockarchive.RetrievalRetryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := breaker.allow(); err != nil {
+			return nil, err
+		}
+		r, err := openWithTimeout(blockarchive.RetrievalTimeouts[stage], func() (*retrievedBlockfile, error) {
+			return openBlockfileAtStage(stage, rootDir, fileNum, archiveConf, priority)
+		})
+		breaker.record(err)
+		if err == nil || breaker == nil || attempt >= blockarchive.RetrievalRetries || !isServiceFailure(err) {
+			return r, err
+		}
+		log.Debugw("Retrying retrieval stage", "stage", stage, "attempt", attempt+1, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,114 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrievalRetriesAndCircuitBreaker(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+	_, err := arch.archiveBlockfile(0, false)
+	require.NoError(t, err)
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+
+	prevFetchBlockfile := blockarchive.FetchBlockfile
+	prevOrder, prevTimeouts := blockarchive.RetrievalOrder, blockarchive.RetrievalTimeouts
+	prevRetries, prevBackoff := blockarchive.RetrievalRetries, blockarchive.RetrievalRetryBackoff
+	prevThreshold, prevCooldown := blockarchive.RetrievalBreakerThreshold, blockarchive.RetrievalBreakerCooldown
+	defer func() {
+		blockarchive.FetchBlockfile = prevFetchBlockfile
+		blockarchive.RetrievalOrder, blockarchive.RetrievalTimeouts = prevOrder, prevTimeouts
+		blockarchive.RetrievalRetries, blockarchive.RetrievalRetryBackoff = prevRetries, prevBackoff
+		blockarchive.RetrievalBreakerThreshold, blockarchive.RetrievalBreakerCooldown = prevThreshold, prevCooldown
+		clientFetchCache, clientFetchCacheOnce = nil, sync.Once{}
+		retrievalBreakers = map[string]*retrievalBreaker{}
+	}()
+	clientFetchCache, clientFetchCacheOnce = nil, sync.Once{}
+	retrievalBreakers = map[string]*retrievalBreaker{}
+	// The archiver peer is down
+	var fetched int32
+	blockarchive.IsClient = true
+	blockarchive.FetchBlockfile = func(ledgerID string, fileNum int, w io.Writer) error {
+		atomic.AddInt32(&fetched, 1)
+		return errors.New("archiver peer unavailable")
+	}
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageArchiver}
+	blockarchive.RetrievalTimeouts = nil
+	blockarchive.RetrievalRetries, blockarchive.RetrievalRetryBackoff = 2, time.Millisecond
+
+	// The failed stage is retried before the read fails
+	_, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "through the archiver peer: archiver peer unavailable")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetched))
+
+	// The circuit opens after consecutive failures, and the stage then fails without reaching the archiver peer
+	blockarchive.RetrievalBreakerThreshold, blockarchive.RetrievalBreakerCooldown = 3, time.Hour
+	_, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.Error(t, err)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&fetched))
+	_, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retrieval stage [archiver] failed: circuit open after 3 consecutive failures")
+	assert.Equal(t, int32(6), atomic.LoadInt32(&fetched))
+
+	// The retrieval falls back to the repository right away
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageArchiver, blockarchive.RetrievalStageRepository}
+	stream, err := newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.NoError(t, err)
+	stream.close()
+	assert.Equal(t, int32(6), atomic.LoadInt32(&fetched))
+
+	// Once the cooldown has elapsed, a single retrieval probes the archiver peer and opens the circuit again
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageArchiver}
+	breaker := getRetrievalBreaker(blockarchive.RetrievalStageArchiver)
+	breaker.openedAt = time.Now().Add(-time.Hour)
+	_, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circuit open after 4 consecutive failures")
+	assert.Equal(t, int32(7), atomic.LoadInt32(&fetched))
+
+	// A successful probe closes the circuit
+	breaker.openedAt = time.Now().Add(-time.Hour)
+	require.NoError(t, breaker.allow())
+	assert.Error(t, breaker.allow(), "a single retrieval probes the service")
+	breaker.record(nil)
+	assert.NoError(t, breaker.allow())
+	assert.Equal(t, 0, breaker.failures)
+}
+
+func TestIsServiceFailure(t *testing.T) {
+	assert.False(t, isServiceFailure(nil))
+	assert.False(t, isServiceFailure(errors.Wrap(os.ErrNotExist, "error opening blockfile")))
+	assert.False(t, isServiceFailure(&sftp.StatusError{Code: 3}))
+	assert.False(t, isServiceFailure(&webhdfs.RemoteException{StatusCode: 403, Exception: "AccessControlException"}))
+	assert.True(t, isServiceFailure(&webhdfs.RemoteException{StatusCode: 403, Exception: "StandbyException"}))
+	assert.False(t, isServiceFailure(errors.New(blockarchive.RestoreInProgressMessage)))
+	assert.True(t, isServiceFailure(errors.New("timed out after 1s")))
+	assert.True(t, isServiceFailure(errors.New("ssh: handshake failed: EOF")))
+}
@@ -0,0 +1,153 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// retrievedBlockfile is a discarded blockfile opened by a stage of the retrieval, either a local file
+// of the fetch cache or an archived blockfile on the repository
+type retrievedBlockfile struct {
+	file     *os.File
+	connInfo *sftpConnInfo
+}
+
+func (r *retrievedBlockfile) close() {
+	if r.file != nil {
+		r.file.Close()
+	} else {
+		r.connInfo.Close()
+	}
+}
+
+// retrievalOrder returns the stages tried in order to retrieve a discarded blockfile
+func retrievalOrder() []string {
+	if len(blockarchive.RetrievalOrder) > 0 {
+		return blockarchive.RetrievalOrder
+	}
+	if isFetchThroughProxyEnabled() {
+		return []string{blockarchive.RetrievalStageCache, blockarchive.RetrievalStageArchiver}
+	}
+	return []string{blockarchive.RetrievalStageRepository}
+}
+
+// hasRetrievalStage tells whether the stage is part of the retrieval order
+func hasRetrievalStage(stage string) bool {
+	for _, s := range retrievalOrder() {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// openDiscardedBlockfile opens a discarded blockfile through the stages of the retrieval order, falling back to
+// the next stage when a stage fails, times out after its retries or has its circuit open. It returns the error
+// of the last stage when all of them fail.
+func openDiscardedBlockfile(rootDir string, fileNum int, archiveConf *ArchiveConf, priority retrievalPriority) (*retrievedBlockfile, error) {
+	log := loggerRetrieve.With(blockfileLogFields(filepath.Base(rootDir), fileNum)...)
+	err := errors.New("no retrieval stage configured")
+	for _, stage := range retrievalOrder() {
+		var r *retrievedBlockfile
+		if r, err = openAtStageWithRetries(stage, rootDir, fileNum, archiveConf, priority); err == nil {
+			return r, nil
+		}
+		err = errors.WithMessagef(err, "retrieval stage [%s] failed", stage)
+		log.Debugw("Falling back to the next retrieval stage", "stage", stage, "error", err)
+	}
+	return nil, err
+}
+
+// openBlockfileAtStage opens a discarded blockfile through a single stage of the retrieval
+func openBlockfileAtStage(stage, rootDir string, fileNum int, archiveConf *ArchiveConf, priority retrievalPriority) (*retrievedBlockfile, error) {
+	switch stage {
+	case blockarchive.RetrievalStageCache:
+		if !isFetchThroughProxyEnabled() {
+			return nil, errors.New("no archiver peer configured")
+		}
+		// Only the blockfiles in the cache or already being retrieved are read, the retrieval is left to the archiver stage
+		if !getClientFetchCache(rootDir).contains(filepath.Base(rootDir), fileNum) {
+			return nil, errors.New("blockfile not in the fetch cache")
+		}
+		fallthrough
+	case blockarchive.RetrievalStageArchiver:
+		if !isFetchThroughProxyEnabled() {
+			return nil, errors.New("no archiver peer configured")
+		}
+		file, err := openFileThroughProxy(rootDir, fileNum)
+		if err != nil {
+			return nil, err
+		}
+		return &retrievedBlockfile{file: file}, nil
+	case blockarchive.RetrievalStageRepository:
+		connInfo, err := openFileThroughSFTP(deriveBlockfilePath(rootDir, fileNum), fileNum, archiveConf, priority)
+		if err != nil {
+			return nil, err
+		}
+		return &retrievedBlockfile{connInfo: connInfo}, nil
+	}
+	return nil, errors.Errorf("unknown retrieval stage [%s]", stage)
+}
+
+// openWithTimeout opens a blockfile, giving up after the timeout unless it is 0. The retrieval given up goes on
+// in the background, so that a blockfile retrieved through the archiver peer still lands in the fetch cache for
+// the next reads, and the blockfile it opens is closed.
+func openWithTimeout(timeout time.Duration, open func() (*retrievedBlockfile, error)) (*retrievedBlockfile, error) {
+	if timeout <= 0 {
+		return open()
+	}
+	type result struct {
+		r   *retrievedBlockfile
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		r, err := open()
+		results <- result{r, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-results:
+		return res.r, res.err
+	case <-timer.C:
+		go func() {
+			if res := <-results; res.err == nil {
+				res.r.close()
+			}
+		}()
+		return nil, errors.Errorf("timed out after %s", timeout)
+	}
+}
+
+// rangeRetrievalClient returns the HTTP client retrieving the byte ranges through the archiver peer, which gives up
+// after the timeout of the archiver stage if any
+func rangeRetrievalClient() *http.Client {
+	client := getProxyClient()
+	if timeout := blockarchive.RetrievalTimeouts[blockarchive.RetrievalStageArchiver]; timeout > 0 {
+		return &http.Client{Transport: client.Transport, Timeout: timeout}
+	}
+	return client
+}
+
+// rangeRetrievalError returns the error of a failed retrieval of a byte range through the archiver peer,
+// nil when the retrieval order falls back to a stage after the archiver peer, which then reads the block
+// from its blockfile
+func rangeRetrievalError(err error) error {
+	order := retrievalOrder()
+	for i, stage := range order {
+		if stage == blockarchive.RetrievalStageArchiver && i < len(order)-1 {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrievalOrder(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	store, cleanup := openArchivingTestStore(t, blocks)
+	defer cleanup()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	arch := store.archiver
+	arch.stopArchivingAndWait()
+	_, err := arch.archiveBlockfile(0, false)
+	require.NoError(t, err)
+	info, err := arch.catalog.getArchivedBlockfile(0)
+	require.NoError(t, err)
+	require.NoError(t, arch.catalog.discardBlockfile(arch.mgr.rootDir, info))
+
+	prevFetchBlockfile := blockarchive.FetchBlockfile
+	prevOrder, prevTimeouts := blockarchive.RetrievalOrder, blockarchive.RetrievalTimeouts
+	release := make(chan struct{})
+	defer func() {
+		close(release)
+		blockarchive.FetchBlockfile = prevFetchBlockfile
+		blockarchive.RetrievalOrder, blockarchive.RetrievalTimeouts = prevOrder, prevTimeouts
+		clientFetchCache, clientFetchCacheOnce = nil, sync.Once{}
+	}()
+	clientFetchCache, clientFetchCacheOnce = nil, sync.Once{}
+	// The archiver peer doesn't answer
+	var fetched int32
+	blockarchive.IsClient = true
+	blockarchive.FetchBlockfile = func(ledgerID string, fileNum int, w io.Writer) error {
+		atomic.AddInt32(&fetched, 1)
+		<-release
+		return errors.New("archiver peer unavailable")
+	}
+	blockarchive.RetrievalTimeouts = map[string]time.Duration{
+		blockarchive.RetrievalStageCache:    50 * time.Millisecond,
+		blockarchive.RetrievalStageArchiver: 50 * time.Millisecond,
+	}
+	expected, _, err := serializeBlock(blocks[0])
+	require.NoError(t, err)
+
+	// The stages give up after their timeouts, and the read fails without the repository
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageCache, blockarchive.RetrievalStageArchiver}
+	_, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retrieval stage [archiver] failed: timed out after 50ms")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// The retrieval falls back to the repository, without retrieving again the blockfile being retrieved
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageCache, blockarchive.RetrievalStageArchiver, blockarchive.RetrievalStageRepository}
+	stream, err := newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.NoError(t, err)
+	assert.NotNil(t, stream.sftpConnInfo)
+	b, err := stream.nextBlockBytes()
+	stream.close()
+	require.NoError(t, err)
+	assert.Equal(t, expected, b)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// The stages left out are never tried
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageRepository}
+	stream, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.NoError(t, err)
+	stream.close()
+	blockarchive.RetrievalOrder = []string{blockarchive.RetrievalStageCache}
+	stream, err = newBlockfileStream(arch.mgr.rootDir, 1, 0, arch.mgr.archiveConf)
+	require.NoError(t, err, "blockfile [1] is still local")
+	stream.close()
+	_, err = newBlockfileStream(arch.mgr.rootDir, 0, 0, arch.mgr.archiveConf)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// Without any order configured, a client peer retrieves through the archiver peer only
+	blockarchive.RetrievalOrder = nil
+	assert.Equal(t, []string{blockarchive.RetrievalStageCache, blockarchive.RetrievalStageArchiver}, retrievalOrder())
+	blockarchive.IsClient = false
+	assert.Equal(t, []string{blockarchive.RetrievalStageRepository}, retrievalOrder())
+}
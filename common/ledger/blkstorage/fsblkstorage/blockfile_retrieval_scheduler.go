@@ -0,0 +1,153 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"io"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+const defaultMaxConcurrentRetrievals = 4
+
+// retrievalPriority orders the retrievals of archived blockfiles waiting for the repository
+type retrievalPriority int
+
+const (
+	// retrievalForQuery is the priority of the ad-hoc queries of blocks and transactions
+	retrievalForQuery retrievalPriority = iota
+	// retrievalForDeliver is the priority of the block streams, such as the ones of the deliver service.
+	// They are served before the ad-hoc queries.
+	retrievalForDeliver
+	numRetrievalPriorities
+)
+
+// remoteBlockfile is a repository session opened to read an archived blockfile.
+// It is shared by all the concurrent readers of the same blockfile.
+type remoteBlockfile struct {
+	path   string
+	conn   io.Closer
+	client repositoryClient
+	refs   int
+	// closed once the session is opened or failed to open
+	ready chan struct{}
+	err   error
+}
+
+// retrievalScheduler bounds the number of archived blockfiles read from the repository at the same time,
+// so that concurrent historical queries don't overload the repository. Concurrent retrievals of the same
+// blockfile are coalesced into a single session. When the bound is reached, the waiting retrievals are
+// served by priority.
+type retrievalScheduler struct {
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	maxActive int
+	active    int
+	waiting   [numRetrievalPriorities]int
+	sessions  map[string]*remoteBlockfile
+	// connect opens a session to the repository
+	connect func(archiveURL string) (io.Closer, repositoryClient, error)
+}
+
+var (
+	retrievals     *retrievalScheduler
+	retrievalsOnce sync.Once
+)
+
+// getRetrievalScheduler returns the scheduler shared by all the channels, which is created on first use
+func getRetrievalScheduler() *retrievalScheduler {
+	retrievalsOnce.Do(func() {
+		maxActive := blockarchive.MaxConcurrentRetrievals
+		if maxActive <= 0 {
+			maxActive = defaultMaxConcurrentRetrievals
+		}
+		retrievals = newRetrievalScheduler(maxActive, connectToRepoAt)
+	})
+	return retrievals
+}
+
+func newRetrievalScheduler(maxActive int, connect func(archiveURL string) (io.Closer, repositoryClient, error)) *retrievalScheduler {
+	s := &retrievalScheduler{
+		maxActive: maxActive,
+		sessions:  make(map[string]*remoteBlockfile),
+		connect:   connect,
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+// acquire returns a session to read the archived blockfile at the path on the repository.
+// It joins the session of a concurrent retrieval of the same blockfile if any, otherwise it waits
+// until fewer than maxActive blockfiles are being read and no retrieval of higher priority is waiting.
+// The session must be released once the blockfile has been read.
+func (s *retrievalScheduler) acquire(archiveURL, path string, priority retrievalPriority) (*remoteBlockfile, error) {
+	s.mutex.Lock()
+	s.waiting[priority]++
+	for s.sessions[path] == nil && !s.canStart(priority) {
+		s.cond.Wait()
+	}
+	s.waiting[priority]--
+
+	if rf, ok := s.sessions[path]; ok {
+		rf.refs++
+		s.mutex.Unlock()
+		<-rf.ready
+		if rf.err != nil {
+			s.release(rf)
+			return nil, rf.err
+		}
+		logger.Debugf("Joined the retrieval of archived blockfile %s", path)
+		return rf, nil
+	}
+
+	rf := &remoteBlockfile{path: path, refs: 1, ready: make(chan struct{})}
+	s.sessions[path] = rf
+	s.active++
+	s.mutex.Unlock()
+
+	rf.conn, rf.client, rf.err = s.connect(archiveURL)
+	if rf.err != nil {
+		rf.err = errors.WithMessagef(rf.err, "error connecting to the repository to retrieve %s", path)
+	}
+	close(rf.ready)
+	if rf.err != nil {
+		s.release(rf)
+		return nil, rf.err
+	}
+	return rf, nil
+}
+
+func (s *retrievalScheduler) canStart(priority retrievalPriority) bool {
+	if s.active >= s.maxActive {
+		return false
+	}
+	for p := priority + 1; p < numRetrievalPriorities; p++ {
+		if s.waiting[p] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// release closes the session once all the readers of the blockfile have released it
+func (s *retrievalScheduler) release(rf *remoteBlockfile) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rf.refs--
+	if rf.refs > 0 {
+		return
+	}
+	delete(s.sessions, rf.path)
+	s.active--
+	s.cond.Broadcast()
+	if rf.client != nil {
+		rf.client.Close()
+	}
+	if rf.conn != nil {
+		rf.conn.Close()
+	}
+}
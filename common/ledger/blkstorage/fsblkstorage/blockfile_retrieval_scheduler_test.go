@@ -0,0 +1,101 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRetrievalScheduler(maxActive int, connections *int32) *retrievalScheduler {
+	return newRetrievalScheduler(maxActive, func(string) (io.Closer, repositoryClient, error) {
+		atomic.AddInt32(connections, 1)
+		return nil, nil, nil
+	})
+}
+
+func waitForWaiting(t *testing.T, s *retrievalScheduler, priority retrievalPriority, expected int) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mutex.Lock()
+		waiting := s.waiting[priority]
+		s.mutex.Unlock()
+		if waiting == expected {
+			return
+		}
+	}
+	t.Fatalf("expected [%d] retrievals waiting with priority [%d]", expected, priority)
+}
+
+func TestRetrievalSchedulerCoalescing(t *testing.T) {
+	var connections int32
+	s := newTestRetrievalScheduler(1, &connections)
+
+	rf1, err := s.acquire("repo", "/blkstore/blockfile_000000", retrievalForQuery)
+	require.NoError(t, err)
+	rf2, err := s.acquire("repo", "/blkstore/blockfile_000000", retrievalForQuery)
+	require.NoError(t, err)
+	assert.Equal(t, rf1, rf2)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+	assert.Equal(t, 1, s.active)
+
+	s.release(rf1)
+	assert.Equal(t, 1, s.active)
+	s.release(rf2)
+	assert.Equal(t, 0, s.active)
+	assert.Empty(t, s.sessions)
+}
+
+func TestRetrievalSchedulerBoundAndPriority(t *testing.T) {
+	var connections int32
+	s := newTestRetrievalScheduler(1, &connections)
+
+	held, err := s.acquire("repo", "/blkstore/blockfile_000000", retrievalForQuery)
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	retrieve := func(path string, priority retrievalPriority) {
+		defer wg.Done()
+		rf, err := s.acquire("repo", path, priority)
+		assert.NoError(t, err)
+		mutex.Lock()
+		order = append(order, path)
+		mutex.Unlock()
+		s.release(rf)
+	}
+
+	wg.Add(2)
+	go retrieve("/blkstore/blockfile_000001", retrievalForQuery)
+	waitForWaiting(t, s, retrievalForQuery, 1)
+	go retrieve("/blkstore/blockfile_000002", retrievalForDeliver)
+	waitForWaiting(t, s, retrievalForDeliver, 1)
+
+	// Nothing is retrieved while the bound is reached
+	assert.Empty(t, order)
+	s.release(held)
+	wg.Wait()
+
+	assert.Equal(t, []string{"/blkstore/blockfile_000002", "/blkstore/blockfile_000001"}, order)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&connections))
+	assert.Equal(t, 0, s.active)
+}
+
+func TestRetrievalSchedulerConnectionFailure(t *testing.T) {
+	s := newRetrievalScheduler(1, func(string) (io.Closer, repositoryClient, error) {
+		return nil, nil, errors.New("unreachable")
+	})
+	_, err := s.acquire("repo", "/blkstore/blockfile_000000", retrievalForDeliver)
+	assert.EqualError(t, err, "error connecting to the repository to retrieve /blkstore/blockfile_000000: unreachable")
+	assert.Equal(t, 0, s.active)
+	assert.Empty(t, s.sessions)
+}
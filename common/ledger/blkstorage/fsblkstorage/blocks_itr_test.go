@@ -27,7 +27,7 @@ import (
 )
 
 func TestBlocksItrBlockingNext(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -51,7 +51,7 @@ func TestBlocksItrBlockingNext(t *testing.T) {
 }
 
 func TestBlockItrClose(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -73,7 +73,7 @@ func TestBlockItrClose(t *testing.T) {
 }
 
 func TestRaceToDeadlock(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -106,7 +106,7 @@ func TestRaceToDeadlock(t *testing.T) {
 }
 
 func TestBlockItrCloseWithoutRetrieve(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -120,7 +120,7 @@ func TestBlockItrCloseWithoutRetrieve(t *testing.T) {
 }
 
 func TestCloseMultipleItrsWaitForFutureBlock(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 	blkfileMgrWrapper := newTestBlockfileWrapper(env, "testLedger")
 	defer blkfileMgrWrapper.close()
@@ -16,7 +16,11 @@ limitations under the License.
 
 package fsblkstorage
 
-import "path/filepath"
+import (
+	"path/filepath"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+)
 
 const (
 	// ChainsDir is the name of the directory containing the channel ledgers.
@@ -31,11 +35,16 @@ type Conf struct {
 	blockStorageDir  string
 	maxBlockfileSize int
 	archiveConf      *ArchiveConf
+	// channelMaxBlockfileSize returns the maximum size of the blockfiles of a channel,
+	// which overrides maxBlockfileSize when positive
+	channelMaxBlockfileSize func(ledgerID string) int
 }
 
 type ArchiveConf struct {
 	archiveURL string
 	archiveDir string
+	// Records of the archived blockfiles of a channel, which locate them on the repository
+	catalog *archiveCatalog
 }
 
 // NewConf constructs new `Conf`.
@@ -44,7 +53,36 @@ func NewConf(blockStorageDir string, maxBlockfileSize int, blockArchiveURL strin
 	if maxBlockfileSize <= 0 {
 		maxBlockfileSize = defaultMaxBlockfileSize
 	}
-	return &Conf{blockStorageDir, maxBlockfileSize, &ArchiveConf{blockArchiveURL, blockArchiveDir}}
+	return &Conf{blockStorageDir: blockStorageDir, maxBlockfileSize: maxBlockfileSize, archiveConf: &ArchiveConf{archiveURL: blockArchiveURL, archiveDir: blockArchiveDir}}
+}
+
+// WithChannelMaxBlockfileSize sets the function returning the maximum size of the blockfiles of a
+// channel, which overrides the maximum size for all the channels when it returns a positive size
+func (conf *Conf) WithChannelMaxBlockfileSize(channelMaxBlockfileSize func(ledgerID string) int) *Conf {
+	conf.channelMaxBlockfileSize = channelMaxBlockfileSize
+	return conf
+}
+
+// maxBlockfileSizeOf returns the maximum size of the blockfiles of a ledger
+func (conf *Conf) maxBlockfileSizeOf(ledgerID string) int {
+	if conf.channelMaxBlockfileSize != nil {
+		if size := conf.channelMaxBlockfileSize(ledgerID); size > 0 {
+			return size
+		}
+	}
+	return conf.maxBlockfileSize
+}
+
+// archiveURLOf returns the URL of the repository of a ledger, which is the one of its channel if configured,
+// or the one of the HDFS cluster when the ledger is archived to HDFS
+func (conf *Conf) archiveURLOf(ledgerID string) string {
+	if blockarchive.RepositoryTypeOf(ledgerID) == blockarchive.RepositoryTypeHDFS {
+		return blockarchive.RepositoryURLOf(ledgerID)
+	}
+	if repository := blockarchive.ChannelRepositoryOf(ledgerID); repository != nil {
+		return repository.URL
+	}
+	return conf.archiveConf.archiveURL
 }
 
 func (conf *Conf) getIndexDir() string {
@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// availableDiskSpace returns the bytes available to unprivileged users on the file system of a path
+func availableDiskSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "error reading the file system statistics of %s", path)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// diskUsage returns the usage in percent of the file system of a path, rounded up as by df
+func diskUsage(path string) (int, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "error reading the file system statistics of %s", path)
+	}
+	used := stat.Blocks - stat.Bfree
+	total := used + stat.Bavail
+	if total == 0 {
+		return 0, nil
+	}
+	return int((used*100 + total - 1) / total), nil
+}
@@ -0,0 +1,17 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package fsblkstorage
+
+import "github.com/pkg/errors"
+
+// availableDiskSpace is not supported on Windows
+func availableDiskSpace(path string) (int64, error) {
+	return 0, errors.New("the free disk space is not available on Windows")
+}
+
+// diskUsage is not supported on Windows
+func diskUsage(path string) (int, error) {
+	return 0, errors.New("the disk usage is not available on Windows")
+}
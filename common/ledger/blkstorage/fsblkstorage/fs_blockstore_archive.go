@@ -7,72 +7,408 @@ Provides public entry points to some previously private methods
 package fsblkstorage
 
 import (
-	"errors"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
 
 	"path/filepath"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
 )
 
+// uploadingSuffix is appended to the path of a blockfile on the repository while it is uploaded
+const uploadingSuffix = ".uploading"
+
 // sendBlockfileToRepo - Moves a blockfile into the repository via ssh
-func sendBlockfileToRepo(blockfileDir string, fileNum int) (bool, error) {
+func sendBlockfileToRepo(blockfileDir string, fileNum int, dstFilePath string) (bool, error) {
+	return sendResumableBlockfileToRepo(blockfileDir, fileNum, dstFilePath, nil, nil)
+}
+
+// sendResumableBlockfileToRepo uploads a blockfile like sendBlockfileToRepo, persisting the progress of the
+// upload with the resumer if not nil, so that an upload interrupted by a restart of the peer is resumed
+// where it stopped rather than from the beginning of the blockfile. The upload is limited to the bandwidth of
+// the limiter if not nil. The blockfiles of the ledgers with a compression are uploaded compressed, and the
+// blockfiles are uploaded encrypted with the active key if any, from the beginning of the blockfile.
+func sendResumableBlockfileToRepo(blockfileDir string, fileNum int, dstFilePath string, resumer uploadResumer, limiter *bandwidthLimiter) (bool, error) {
+	log := loggerUpload.With(blockfileLogFields(filepath.Base(blockfileDir), fileNum)...).
+		With(blockarchive.LogKeyRepository, blockarchive.RepositoryURLOf(filepath.Base(blockfileDir)))
+	start := time.Now()
 
 	srcFilePath := deriveBlockfilePath(blockfileDir, fileNum)
 	srcFile, err := os.Open(srcFilePath)
 	if err != nil {
-		loggerArchive.Warningf("Already archived : blockfileDir [%s] fileNum [%d]", blockfileDir, fileNum)
+		log.Warnw("Blockfile not found locally, already archived", "path", srcFilePath)
 		return true, errors.New("Already archived")
 	}
 	defer srcFile.Close()
 
+	sshConn, client, err := connectToRepo(filepath.Base(blockfileDir))
+	if err != nil {
+		return false, errors.New("Server unreachable")
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	if blockarchive.ContentAddressed {
+		srcInfo, err := srcFile.Stat()
+		if err != nil {
+			return false, err
+		}
+		if isBlockfileStored(client, dstFilePath, srcInfo.Size()) {
+			log.Infow("Blockfile already stored on the repository, skipped the upload", "location", dstFilePath)
+			return false, nil
+		}
+	} else if stored, err := isLockedBlockfileStored(client, dstFilePath, srcFilePath); err != nil {
+		log.Warnw("Failed checking the object lock of the blockfile on the repository", "location", dstFilePath, "error", err)
+		return false, err
+	} else if stored {
+		// The upload completed before a restart, and the locked blockfile cannot be overwritten
+		log.Infow("Blockfile already stored and locked on the repository, skipped the upload", "location", dstFilePath)
+		return false, nil
+	}
+
+	// The blockfile is uploaded to a temporary file first so that an upload interrupted
+	// by a failure or a restart is never taken for the archived blockfile
+	tmpFilePath := dstFilePath + uploadingSuffix
+	if blockarchive.ContentAddressed {
+		// Several peers may upload the same content-addressed blockfile at the same time
+		tmpFilePath = dstFilePath + "." + blockarchive.BlockfileRefName(blockarchive.ArchiverID, filepath.Base(blockfileDir), uint64(fileNum)) + uploadingSuffix
+	}
+	client.MkdirAll(filepath.Dir(dstFilePath))
+	checksumWriter, err := blockarchive.NewChecksumWriter(blockarchive.ChecksumAlgorithm)
+	if err != nil {
+		return false, err
+	}
+	compression := blockarchive.CompressionOf(filepath.Base(blockfileDir))
+	keyID := blockarchive.EncryptionKeyID
+	encoded := compression != nil || keyID != ""
+	if encoded && resumer != nil {
+		// The offset reached in an encoded upload doesn't tell the offset reached in the blockfile,
+		// so an encoded upload is not resumed
+		log.Warnw("The upload of the blockfile is compressed or encrypted and is not resumable, "+
+			"it starts again from the beginning if it is interrupted", "location", tmpFilePath)
+		resumer.clearResumeOffset()
+		resumer = nil
+	}
+	dstFile, offset, err := openUploadFile(client, srcFile, tmpFilePath, checksumWriter, resumer)
+	if err != nil {
+		log.Warnw("Failed creating the blockfile on the repository", "location", tmpFilePath, "error", err)
+		return false, err
+	}
+	if offset > 0 {
+		log.Infow("Resuming the upload of the blockfile", "location", tmpFilePath, "offset", offset)
+	}
+
+	var written int64
+	var level int
+	if encoded {
+		// The checksum of the blockfile is computed before it is encoded, it is the one of the blockfile once decoded
+		written, level, err = encodeBlockfile(dstFile, srcFile, filepath.Base(blockfileDir), compression, keyID, checksumWriter, limiter)
+	} else {
+		// The upload is aborted if it hasn't completed within the drain timeout of the shutdown, and shares the
+		// bandwidth of the limiter with the other uploads of the catch-up
+		written, err = copyResumable(io.MultiWriter(dstFile, checksumWriter), limiter.reader(transfers.reader(srcFile)), tmpFilePath, offset, resumer)
+		written += offset
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// The checksum is stored next to the blockfile before the blockfile is renamed, so that
+		// the repository can verify the upload and the downloads of the blockfile can be verified
+		err = writeRemoteFile(client, dstFilePath+blockarchive.ChecksumSuffix, []byte(checksumWriter.Checksum().String()))
+	}
+	if err != nil {
+		// The repository rejects the upload when it would exceed a storage quota
+		log.Warnw("Failed uploading blockfile", blockarchive.LogKeyBytes, written, "error", err)
+		// The partial upload is kept to be resumed once part of it has been recorded
+		if resumer == nil || resumer.resumeOffset(tmpFilePath) == 0 {
+			client.Remove(tmpFilePath)
+		}
+		return false, err
+	}
+
+	// Replace the blockfile left by a previous attempt if any
+	client.Remove(dstFilePath)
+	if err := client.Rename(tmpFilePath, dstFilePath); err != nil {
+		log.Warnw("Failed renaming the blockfile on the repository", "location", tmpFilePath, "error", err)
+		// A temporary file rejected by the repository is uploaded again from the beginning
+		if resumer != nil {
+			resumer.clearResumeOffset()
+		}
+		return false, err
+	}
+	if resumer != nil {
+		resumer.clearResumeOffset()
+	}
+
+	if compression != nil {
+		log = log.With("compression", compression.Algorithm, "level", level)
+	}
+	if keyID != "" {
+		log = log.With("encryptionKey", keyID)
+	}
+	log.Infow("Uploaded blockfile", "location", dstFilePath,
+		blockarchive.LogKeyBytes, written, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+
+	return false, nil
+}
+
+// sendManifestToRepo - Stores the manifest of an archived blockfile next to the blockfile in the repository
+func sendManifestToRepo(ledgerID, dstFilePath string, manifestBytes []byte) error {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return err
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	client.MkdirAll(filepath.Dir(dstFilePath))
+	dstFile, err := client.Create(dstFilePath)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if _, err := dstFile.Write(manifestBytes); err != nil {
+		return err
+	}
+
+	loggerArchive.Info("sendManifestToRepo - sent manifest to repository: ", dstFilePath)
+
+	return nil
+}
+
+// writeRemoteFile writes a small file on the repository
+func writeRemoteFile(client repositoryClient, path string, content []byte) error {
+	file, err := client.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// remoteChecksum returns the checksum of an archived blockfile, which is the one recorded in the catalog
+// if any, otherwise the one stored next to the blockfile on the repository. It returns nil for the
+// blockfiles archived before the checksums were introduced.
+func remoteChecksum(client repositoryClient, remotePath string, recorded string) (*blockarchive.Checksum, error) {
+	if recorded != "" {
+		return blockarchive.ParseChecksum(recorded)
+	}
+	file, err := client.Open(remotePath + blockarchive.ChecksumSuffix)
+	if os.IsNotExist(err) {
+		loggerArchive.Debugf("No checksum of archived blockfile [%s]", remotePath)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return blockarchive.ParseChecksum(string(content))
+}
+
+// fetchBlockfileFromRepo downloads an archived blockfile from the repository to the local file system,
+// and verifies it against its checksum. The blockfile is written to a temporary file first so that
+// a partial or corrupted download is never taken for the blockfile. It returns the number of bytes restored.
+func fetchBlockfileFromRepo(ledgerID, remotePath string, localPath string, checksum string) (int64, error) {
+	sshConn, client, err := connectToRepo(ledgerID)
+	if err != nil {
+		return 0, err
+	}
+	defer sshConn.Close()
+	defer client.Close()
+
+	expected, err := remoteChecksum(client, remotePath, checksum)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "error reading the checksum of %s", remotePath)
+	}
+
+	srcFile, err := client.Open(remotePath)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	tmpPath := localPath + ".restoring"
+	dstFile, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	var verifier *blockarchive.ChecksumWriter
+	var dst io.Writer = dstFile
+	if expected != nil {
+		if verifier, err = blockarchive.NewChecksumWriter(expected.Algorithm); err != nil {
+			dstFile.Close()
+			os.Remove(tmpPath)
+			return 0, err
+		}
+		dst = io.MultiWriter(dstFile, verifier)
+	}
+	// An encoded blockfile is decoded before it is verified and restored
+	content, _, err := blockarchive.NewBlockfileReader(srcFile)
+	var written int64
+	if err == nil {
+		written, err = io.Copy(dst, content)
+		content.Close()
+	}
+	if err == nil && verifier != nil {
+		err = verifier.Verify(expected)
+	}
+	if err == nil {
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, localPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	return written, nil
+}
+
+// connectToRepo opens a session to the repository of a ledger, raising an alert when it fails
+func connectToRepo(ledgerID string) (io.Closer, repositoryClient, error) {
+	repositoryURL := blockarchive.RepositoryURLOf(ledgerID)
+	sshConn, client, err := connectToRepoAt(repositoryURL)
+	if err != nil {
+		blockarchive.RaiseAlert(blockarchive.AlertRepositoryUnreachable, ledgerID, "repository [%s] is unreachable: %s", repositoryURL, err)
+	}
+	return sshConn, client, err
+}
+
+// connectToRepoAt opens a session to the repository at the URL: a session to the HDFS cluster for a
+// webhdfs:// or swebhdfs:// URL, or an SFTP session otherwise. The connection returned is closed with the session.
+func connectToRepoAt(blockArchiverURL string) (io.Closer, repositoryClient, error) {
+	if webhdfs.IsURL(blockArchiverURL) {
+		client, err := connectToHDFS()
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, client, nil
+	}
+	user, password, err := blockarchive.RepositoryCredentials()
+	if err != nil {
+		return nil, nil, err
+	}
 	config := &ssh.ClientConfig{
-		User: "root",
+		User: user,
 		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
 			return nil
 		},
 		Auth: []ssh.AuthMethod{
-			ssh.Password("blkstore"),
+			ssh.Password(password),
 		},
 	}
 	config.SetDefaults()
-	blockArchiverURL := blockarchive.BlockArchiverURL
-	sshConn, err := ssh.Dial("tcp", blockArchiverURL, config)
+	// The repository may be reached through an egress proxy
+	conn, err := blockarchive.DialRepository(blockArchiverURL)
 	if err != nil {
 		loggerArchive.Warningf("Block store server [%s] is unreachable [%s]", blockArchiverURL, err.Error())
-		return false, errors.New("Server unreachable")
+		return nil, nil, err
 	}
-	defer sshConn.Close()
+	c, chans, reqs, err := ssh.NewClientConn(conn, blockArchiverURL, config)
+	if err != nil {
+		conn.Close()
+		loggerArchive.Warningf("Block store server [%s] is unreachable [%s]", blockArchiverURL, err.Error())
+		return nil, nil, err
+	}
+	sshConn := ssh.NewClient(c, chans, reqs)
 
 	client, err := sftp.NewClient(sshConn)
 	if err != nil {
-		panic(err)
+		sshConn.Close()
+		return nil, nil, err
 	}
-	defer client.Close()
 
-	blockArchiverDir := blockarchive.BlockArchiverDir
-	dstDirPath := filepath.Join(blockArchiverDir, filepath.Dir(srcFilePath))
-	dstFilePath := filepath.Join(blockArchiverDir, srcFilePath)
-	client.MkdirAll(dstDirPath)
-	dstFile, err := client.Create(dstFilePath)
+	return sshConn, &sftpRepositoryClient{Client: client}, nil
+}
+
+// ProbeRepository opens a session to the repository at the URL with the credentials of the peer,
+// so that an unreachable repository or refused credentials are reported when the peer starts
+func ProbeRepository(repositoryURL string) error {
+	sshConn, client, err := connectToRepoAt(repositoryURL)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer dstFile.Close()
+	defer sshConn.Close()
+	defer client.Close()
+	_, err = client.Getwd()
+	return err
+}
+
+// deriveArchivedBlockfilePath returns the path to the blockfile on the repository
+func deriveArchivedBlockfilePath(blockfileDir string, fileNum int) string {
+	return filepath.Join(blockarchive.ArchiveRootDir(), deriveBlockfilePath(blockfileDir, fileNum))
+}
 
-	written, err := io.Copy(dstFile, srcFile)
+// archiveLocation returns the path on the repository of a local blockfile to be archived
+func (arch *blockfileArchiver) archiveLocation(fileNum int) (string, error) {
+	if blockarchive.ContentAddressed {
+		return arch.contentAddressedLocation(fileNum)
+	}
+	if blockarchive.ObjectKeyTemplate == "" {
+		return deriveArchivedBlockfilePath(arch.blockfileDir, fileNum), nil
+	}
+	summary, err := scanBlockfile(arch.mgr.rootDir, fileNum)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
+	return arch.deriveArchiveLocation(fileNum, summary)
+}
 
-	loggerArchive.Info("sendBlockfileToRepo - sent blockfile to repository: ", fileNum, " written=", written)
+// deriveArchiveLocation returns the path on the repository of a blockfile. It is derived from the content
+// of the blockfile if content addressing is enabled. Otherwise it follows the object key template if one
+// is configured, or reuses the path of the blockfile on the local file system.
+func (arch *blockfileArchiver) deriveArchiveLocation(fileNum int, summary *blockfileSummary) (string, error) {
+	if blockarchive.ContentAddressed {
+		return arch.contentAddressedLocation(fileNum)
+	}
+	template := blockarchive.ObjectKeyTemplate
+	if template == "" {
+		return deriveArchivedBlockfilePath(arch.blockfileDir, fileNum), nil
+	}
+	key, err := blockarchive.ExpandObjectKey(template, &blockarchive.ObjectKeyParams{
+		NetworkID:     blockarchive.NetworkID,
+		Environment:   blockarchive.Environment,
+		ChannelID:     arch.chainID,
+		BlockfileNo:   uint64(fileNum),
+		FirstBlockNum: summary.firstBlockNum,
+		LastBlockNum:  summary.lastBlockNum,
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(blockarchive.ArchiveRootDir(), key), nil
+}
 
-	return false, nil
+// currentFileNum returns the number of the blockfile being written, as recorded in the checkpoint info.
+// The blockfiles below it are complete, while the next one may have been created on the file system
+// by a rollover which has not been checkpointed yet.
+func (mgr *blockfileMgr) currentFileNum() int {
+	mgr.cpInfoCond.L.Lock()
+	defer mgr.cpInfoCond.L.Unlock()
+	return mgr.cpInfo.latestFileChunkSuffixNum
 }
 
 // notifyArchiver notifies the finalization of blockfile via channel. It's called blockfile manager.
@@ -97,3 +433,20 @@ func (mgr *blockfileMgr) SetArchiverChan(ch chan blockarchive.ArchiverMessage) {
 func (store *fsBlockStore) SetBlockArchived(blockFileNo int, deleteTheFile bool) error {
 	return store.archiver.SetBlockfileArchived(blockFileNo, deleteTheFile)
 }
+
+// GetArchiveCatalog returns the records of the blockfiles archived for this block store
+func (store *fsBlockStore) GetArchiveCatalog() blockarchive.Catalog {
+	return store.archiver.catalog
+}
+
+// RestoreRange brings back onto the local file system the archived blockfiles which contain
+// blocks of the range and have been discarded. If ttl is not zero, they are discarded again
+// once it has elapsed.
+func (store *fsBlockStore) RestoreRange(firstBlockNum, lastBlockNum uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error {
+	return store.archiver.restoreRange(firstBlockNum, lastBlockNum, ttl, progress)
+}
+
+// AddDiscardListener registers a listener to be notified when an archived blockfile has been discarded
+func (store *fsBlockStore) AddDiscardListener(listener blockarchive.DiscardListener) {
+	store.archiver.addDiscardListener(listener)
+}
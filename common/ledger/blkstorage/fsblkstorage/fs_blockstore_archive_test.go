@@ -7,14 +7,20 @@ Provides public entry points to some previously private methods
 package fsblkstorage
 
 import (
+	"bytes"
+	"os"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/ledger/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSendBlockfileToRepo(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 
 	provider := env.provider
@@ -27,5 +33,194 @@ func TestSendBlockfileToRepo(t *testing.T) {
 		assert.NoError(t, err)
 	}
 
-	sendBlockfileToRepo("testLedger", 0)
+	sendBlockfileToRepo("testLedger", 0, deriveArchivedBlockfilePath("testLedger", 0))
+}
+
+func TestArchiveWithObjectKeyTemplate(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevTemplate, prevNetworkID := blockarchive.ObjectKeyTemplate, blockarchive.NetworkID
+	blockarchive.ObjectKeyTemplate = "{networkId}/{channel}/{firstBlock}-{lastBlock}.blk"
+	blockarchive.NetworkID = "dev"
+	defer func() { blockarchive.ObjectKeyTemplate, blockarchive.NetworkID = prevTemplate, prevNetworkID }()
+
+	blocks := testutil.ConstructTestBlocks(t, 20)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	assert.Equal(t, "/blkstore/dev/testLedger/0-9.blk", location)
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+
+	info, err := store.GetArchiveCatalog().GetArchiveLocation(5)
+	require.NoError(t, err)
+	assert.Equal(t, location, info.Location)
+
+	// The discarded blocks are read from the repository at the location recorded in the catalog
+	block, err := store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[5], block)
+}
+
+func TestArchiveUnderNetworkNamespace(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	prevTemplate, prevNetworkID, prevEnvironment := blockarchive.ObjectKeyTemplate, blockarchive.NetworkID, blockarchive.Environment
+	blockarchive.ObjectKeyTemplate = "{channel}/{blockfileNo}.blk"
+	blockarchive.NetworkID, blockarchive.Environment, blockarchive.NamespaceByNetwork = "dev", "eu", true
+	defer func() {
+		blockarchive.ObjectKeyTemplate, blockarchive.NetworkID, blockarchive.Environment = prevTemplate, prevNetworkID, prevEnvironment
+		blockarchive.NamespaceByNetwork = false
+	}()
+
+	blocks := testutil.ConstructTestBlocks(t, 20)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	assert.Equal(t, "/blkstore/dev/eu/testLedger/0.blk", location)
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+
+	// The catalog records the network of the archived blockfile
+	info, err := store.GetArchiveCatalog().GetArchiveLocation(5)
+	require.NoError(t, err)
+	assert.Equal(t, location, info.Location)
+	assert.Equal(t, "dev", info.NetworkID)
+	assert.Equal(t, "eu", info.Environment)
+
+	block, err := store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[5], block)
+}
+
+func TestRetrieveDiscardedBlocksFromRepository(t *testing.T) {
+	_, cleanup := startTestRepository(t)
+	defer cleanup()
+
+	blocks := testutil.ConstructTestBlocks(t, 30)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+
+	ranges, err := store.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	require.Len(t, ranges, 1)
+
+	// The discarded blocks are still served, for instance to the peers
+	// catching up through state transfer, by fetching them from the repository
+	for blockNum := ranges[0].FirstBlockNum; blockNum <= ranges[0].LastBlockNum; blockNum++ {
+		block, err := store.RetrieveBlockByNumber(blockNum)
+		require.NoError(t, err)
+		assert.Equal(t, blocks[blockNum], block)
+	}
+}
+
+func TestArchiverStructuredLogs(t *testing.T) {
+	server, cleanup := startTestRepository(t)
+	defer cleanup()
+	buf := &bytes.Buffer{}
+	flogging.Global.SetWriter(buf)
+	defer flogging.Global.SetWriter(os.Stderr)
+
+	blocks := testutil.ConstructTestBlocks(t, 20)
+	size := 0
+	for _, block := range blocks[:10] {
+		by, _, err := serializeBlock(block)
+		require.NoError(t, err)
+		size += len(by) + len(proto.EncodeVarint(uint64(len(by))))
+	}
+	blockStorePath := testPath()
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = blockStorePath
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+
+	env := newTestEnv(t, NewConf(blockStorePath, size, server.Addr().String(), blockarchive.BlockArchiverDir))
+	defer env.Cleanup()
+	store, err := env.provider.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	arch := store.(*fsBlockStore).archiver
+	location, err := arch.archiveLocation(0)
+	require.NoError(t, err)
+	_, err = sendBlockfileToRepo(arch.blockfileDir, 0, location)
+	require.NoError(t, err)
+	require.NoError(t, arch.handleArchivedBlockfile(0, true))
+
+	_, err = store.RetrieveBlockByNumber(5)
+	require.NoError(t, err)
+
+	logs := buf.String()
+	assert.Regexp(t, `\[archiver\.upload\] .* Uploaded blockfile channel=testLedger blockfile=0 repository=\S+ location=\S+ bytes=\d+ durationMs=\d+`, logs)
+	assert.Regexp(t, `\[archiver\.discard\] .* Discarded archived blockfile channel=testLedger blockfile=0 blockRange=0-9 repository=\S+ bytes=\d+`, logs)
+	assert.Regexp(t, `\[archiver\.retrieve\] .* Opened archived blockfile channel=testLedger blockfile=0 repository=\S+ location=\S+ durationMs=\d+`, logs)
 }
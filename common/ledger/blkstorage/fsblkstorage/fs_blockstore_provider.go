@@ -18,6 +18,7 @@ package fsblkstorage
 
 import (
 	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/ledger/util"
 	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
 )
@@ -45,7 +46,14 @@ func (p *FsBlockstoreProvider) CreateBlockStore(ledgerid string) (blkstorage.Blo
 // This method should be invoked only once for a particular ledgerid
 func (p *FsBlockstoreProvider) OpenBlockStore(ledgerid string) (blkstorage.BlockStore, error) {
 	indexStoreHandle := p.leveldbProvider.GetDBHandle(ledgerid)
-	return newFsBlockStore(ledgerid, p.conf, p.indexConfig, indexStoreHandle), nil
+	store := newFsBlockStore(ledgerid, p.conf, p.indexConfig, indexStoreHandle)
+	if blockarchive.CheckCoverage {
+		if err := store.archiver.verifyCoverage(); err != nil {
+			store.Shutdown()
+			return nil, err
+		}
+	}
+	return store, nil
 }
 
 // Exists tells whether the BlockStore with given id exists
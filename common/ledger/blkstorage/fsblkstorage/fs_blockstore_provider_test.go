@@ -20,7 +20,7 @@ import (
 )
 
 func TestMultipleBlockStores(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 
 	provider := env.provider
@@ -110,7 +110,7 @@ func checkWithWrongInputs(t *testing.T, store blkstorage.BlockStore, numBlocks i
 }
 
 func TestBlockStoreProvider(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 
 	provider := env.provider
@@ -24,7 +24,7 @@ import (
 )
 
 func TestWrongBlockNumber(t *testing.T) {
-	env := newTestEnv(t, NewConf(testPath(), 0))
+	env := newTestEnv(t, NewConf(testPath(), 0, "", ""))
 	defer env.Cleanup()
 
 	provider := env.provider
@@ -0,0 +1,71 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"fmt"
+	"time"
+)
+
+// The kinds of the alerts raised by the archiver on the events which need the attention of an operator
+const (
+	// AlertArchiveFailure is raised when the archiving of a blockfile has failed ArchiveFailureAlertThreshold times in a row
+	AlertArchiveFailure = "archiveFailure"
+	// AlertVerificationFailure is raised when a blockfile is refused for archiving because it is corrupted,
+	// or when an archived blockfile doesn't match its checksum on the repository
+	AlertVerificationFailure = "verificationFailure"
+	// AlertDiskHighWatermark is raised when the usage of the file system of the block store reaches
+	// DiskHighWatermark, or when the commits pause for the free disk space
+	AlertDiskHighWatermark = "diskHighWatermark"
+	// AlertRepositoryUnreachable is raised when the peer fails to open a session to the repository
+	AlertRepositoryUnreachable = "repositoryUnreachable"
+	// AlertRestoreCompleted is raised when a restore of archived blocks has completed, successfully or not
+	AlertRestoreCompleted = "restoreCompleted"
+)
+
+// AlertKinds are the kinds of the alerts raised by the archiver
+var AlertKinds = []string{
+	AlertArchiveFailure,
+	AlertVerificationFailure,
+	AlertDiskHighWatermark,
+	AlertRepositoryUnreachable,
+	AlertRestoreCompleted,
+}
+
+// Alert is an event of the archiver which needs the attention of an operator
+type Alert struct {
+	Kind string `json:"kind"`
+	// Channel is the channel concerned, empty for the alerts about the whole peer
+	Channel string    `json:"channel,omitempty"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// AlertNotifier is notified of the alerts raised by the archiver, e.g. to post them to webhooks.
+// The alerts are dropped when it is nil. The notification is delivered synchronously, so that
+// implementations should not block.
+var AlertNotifier func(alert *Alert)
+
+// ArchiveFailureAlertThreshold is the number of consecutive failures of the archiving of a blockfile
+// after which AlertArchiveFailure is raised. The alert is never raised when it is 0.
+var ArchiveFailureAlertThreshold int
+
+// DiskHighWatermark is the usage in percent of the file system of the block store from which
+// AlertDiskHighWatermark is raised after an archiving. The usage is not checked when it is 0.
+var DiskHighWatermark int
+
+// RaiseAlert notifies AlertNotifier of an alert about a channel, or about the whole peer if channelID is empty
+func RaiseAlert(kind, channelID, format string, args ...interface{}) {
+	notifier := AlertNotifier
+	if notifier == nil {
+		return
+	}
+	notifier(&Alert{
+		Kind:    kind,
+		Channel: channelID,
+		Message: fmt.Sprintf(format, args...),
+		Time:    time.Now().UTC(),
+	})
+}
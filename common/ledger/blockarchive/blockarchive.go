@@ -6,6 +6,19 @@ COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
 // client peer node to running a network with archiving feature.
 package blockarchive
 
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
 // IsArchiver indicates whether archiver mode is enabled or not.
 // Archiver mode and client mode are mutually exclusive.
 var IsArchiver bool
@@ -13,6 +26,10 @@ var IsArchiver bool
 // IsClient indicates whether client mode is enabled or not.
 var IsClient bool
 
+// IsSidecarArchiving indicates whether the blockfiles of the archiver peer are archived by the peer-archiver
+// sidecar rather than by the peer itself. The peer then records and discards the blockfiles the sidecar reports.
+var IsSidecarArchiving bool
+
 // BlockStorePath is the absolute path to the root directory
 // where blockfiles of all channels are stored.
 var BlockStorePath string
@@ -24,6 +41,151 @@ var BlockArchiverDir string
 // BlockArchiverURL is URL of the repository
 var BlockArchiverURL string
 
+// RepositoryTokenFile is the file holding the API token with which the peer authenticates to the
+// repository as TokenUser. It is read on every connection so that a rotated token is picked up.
+// The default account of the repository is used when it is empty.
+var RepositoryTokenFile string
+
+// RepositoryTokenUser is the user name with which the API tokens are passed to the repository
+const RepositoryTokenUser = "token"
+
+// NetworkID is the logical network the peer belongs to.
+// It can be used to lay out the archived blockfiles of several networks on the same repository.
+var NetworkID string
+
+// Environment is the optional logical environment of the network, e.g. dev, test or prod, which tells
+// apart the networks sharing a repository under the same network ID
+var Environment string
+
+// NamespaceByNetwork indicates whether the blockfiles are archived under <NetworkID>/<Environment> below
+// BlockArchiverDir, so that the networks sharing a repository never mix blockfiles of channels with the same name
+var NamespaceByNetwork bool
+
+// ObjectKeyTemplate is the template of the paths of the archived blockfiles on the repository,
+// relative to ArchiveRootDir. The paths of the local blockfiles are reused when it is empty.
+var ObjectKeyTemplate string
+
+// ProxyEndpoint is the URL of the operations endpoint of the archiver peer of the organization.
+// When it is set, a client peer retrieves the blockfiles it has discarded through the archiver peer
+// instead of accessing the repository.
+var ProxyEndpoint string
+
+// ProxyTLSConfig is the TLS configuration used to connect to ProxyEndpoint
+var ProxyTLSConfig *tls.Config
+
+// FetchCacheSize is the maximum number of blockfiles retrieved through the archiver peer
+// which a client peer keeps on its local file system
+var FetchCacheSize int
+
+// PrefetchWindow is the number of discarded blockfiles following a blockfile retrieved through the
+// archiver peer which a client peer retrieves in the background. 0 disables the prefetching.
+var PrefetchWindow int
+
+// RangeRetrieval tells if a client peer reading a single block of a discarded blockfile retrieves only the
+// bytes of the block through ProxyEndpoint, instead of the entire blockfile
+var RangeRetrieval bool
+
+// MetricsProvider provides the metrics of the retrieval of the discarded blockfiles, which are
+// not reported when it is nil
+var MetricsProvider metrics.Provider
+
+// FetchBlockfile writes a blockfile of a ledger which a client peer retrieves through the
+// ArchivedBlockProvider service of the archiver peer of the organization. It is set when the
+// service is configured, and is then preferred to ProxyEndpoint.
+var FetchBlockfile func(ledgerID string, fileNum int, w io.Writer) error
+
+// FetchEnabled tells if a client peer may retrieve the discarded blockfiles of a ledger from the
+// repository or through the archiver peer. The retrieval is enabled for all the ledgers when it is nil.
+var FetchEnabled func(ledgerID string) bool
+
+// IsFetchEnabled tells if the discarded blockfiles of a ledger may be retrieved by this peer.
+// It is always the case on the archiver peer.
+func IsFetchEnabled(ledgerID string) bool {
+	return !IsClient || FetchEnabled == nil || FetchEnabled(ledgerID)
+}
+
+// ProxyBlockfilesPath is the path of the endpoint of the archiver peer which serves the blockfiles,
+// followed by <channel>/<blockfileNo>
+const ProxyBlockfilesPath = "/archiver/blockfiles/"
+
+const (
+	// ProxyChecksumAlgorithmsHeader is the header of a request of a blockfile to ProxyEndpoint
+	// which lists the checksum algorithms accepted by the client peer, in order of preference
+	ProxyChecksumAlgorithmsHeader = "X-Checksum-Algorithms"
+	// ProxyChecksumAlgorithmHeader is the header of the response which gives the negotiated checksum algorithm
+	ProxyChecksumAlgorithmHeader = "X-Checksum-Algorithm"
+	// ProxyChecksumTrailer is the trailer of the response carrying the checksum of the blockfile
+	ProxyChecksumTrailer = "X-Blockfile-Checksum"
+	// RequestAuthorizationHeader is the header of the requests of the archived blocks to the operations endpoint
+	// of a peer. It carries an envelope of the channel of the request, signed by the requester, whose payload
+	// data is given by RequestAuthorizationData.
+	RequestAuthorizationHeader = "X-Archive-Authorization"
+)
+
+// SignProxyRequest sets the RequestAuthorizationHeader of a request of a client peer to ProxyEndpoint,
+// signed by the identity of the peer. It is set along with ProxyEndpoint.
+var SignProxyRequest func(req *http.Request, channelID string) error
+
+// RequestAuthorizationData returns the payload data of the envelope authorizing a request to the operations
+// endpoint, which binds the signature of the requester to the method, the URI and the body of the request
+func RequestAuthorizationData(method, requestURI string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	return []byte(fmt.Sprintf("%s %s %s", method, requestURI, hex.EncodeToString(digest[:])))
+}
+
+// RestoreInProgressMessage is the error reported by the repository when a blockfile stored in a
+// deep archive tier is read. The blockfile is being restored and can be read again later.
+const RestoreInProgressMessage = "restore in progress"
+
+// IsRestoreInProgress tells if the retrieval of a blockfile failed because the repository is
+// restoring it from a deep archive tier
+func IsRestoreInProgress(err error) bool {
+	return err != nil && strings.Contains(err.Error(), RestoreInProgressMessage)
+}
+
+// RetainConfigBlocks indicates whether the config blocks and the genesis block of a blockfile are kept
+// locally when the blockfile is discarded, so that the channel config is read without the repository
+var RetainConfigBlocks bool
+
+// CheckCoverage indicates whether the archive catalog and the local blockfiles of a channel are checked
+// to cover all its blocks when the channel is opened
+var CheckCoverage bool
+
+// StrictCoverage indicates whether a channel whose blocks are not all covered is refused to open
+var StrictCoverage bool
+
+// MaxConcurrentRetrievals is the maximum number of archived blockfiles
+// which are read from the repository at the same time
+var MaxConcurrentRetrievals int
+
+// RestoreParallelism is the number of discarded blockfiles of a range being restored
+// which are downloaded from the repository at the same time
+var RestoreParallelism int
+
+// RestoreProgress is notified as the blockfiles of a range are restored, with the number
+// of blockfiles restored so far and the number of blockfiles of the range to restore
+type RestoreProgress func(restored, total int)
+
+// MinFreeDiskSpace is the free space in bytes of the file system of BlockStorePath below which the
+// health of the peer is degraded, as the archiving doesn't keep up with the commits. 0 disables the check.
+var MinFreeDiskSpace int64
+
+// ThrottleCommit indicates whether the commit of the blocks pauses while the free disk space is below
+// MinFreeDiskSpace, until the discard of the archived blockfiles frees space, rather than failing
+// with ENOSPC in the middle of a commit
+var ThrottleCommit bool
+
+// DefaultMaxCommitPause is the longest a commit pauses for the free disk space unless configured otherwise
+const DefaultMaxCommitPause = 5 * time.Minute
+
+// MaxCommitPause is the longest a commit pauses for the free disk space, after which the commit fails
+var MaxCommitPause = DefaultMaxCommitPause
+
+// MinBlockAgeBeforeDiscard is the least time since the last block of an archived blockfile was committed
+// for the local blockfile to be discarded, so that the recent blocks stay local for the state transfer and
+// the event replays whatever the number of blockfiles. 0 discards the blockfiles as soon as archived.
+var MinBlockAgeBeforeDiscard time.Duration
+
 // NumBlockfileEachArchiving is the number of data chunks archived
 // on each archiving opportunity at once
 var NumBlockfileEachArchiving int
@@ -32,6 +194,25 @@ var NumBlockfileEachArchiving int
 // which a peer node should keep on local file system
 var NumKeepLatestBlocks int
 
+// CatchUpThreshold is the number of blockfiles eligible for archiving beyond the kept ones from which the
+// archiver peer catches up with its backlog when it starts archiving, e.g. after a prolonged downtime,
+// rather than batch after batch. 0 disables the catch-up.
+var CatchUpThreshold int
+
+// CatchUpParallelism is the number of blockfiles uploaded to the repository at the same time while catching up
+var CatchUpParallelism int
+
+// CatchUpBandwidth is the bandwidth in bytes per second shared by the uploads of the catch-up, unlimited if 0
+var CatchUpBandwidth int64
+
+// TailIdleTime is the time without any block appended to the blockfile being written after which a snapshot
+// of the blockfile is archived, so that the archive holds the last blocks of a dormant channel. 0 disables it.
+var TailIdleTime time.Duration
+
+// TailSuffix is appended to the path of an archived blockfile to derive the path of the snapshot
+// of the blockfile archived while it was still being written
+const TailSuffix = ".tail"
+
 // ArchiverMessage is the message that contains which blockfile is archived
 type ArchiverMessage struct {
 	ChainID      string
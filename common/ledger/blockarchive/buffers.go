@@ -0,0 +1,125 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+)
+
+const (
+	// RetrievalBufferSize is the size of the buffers of the retrievals of the archived blockfiles
+	RetrievalBufferSize = 1024 * 1024
+
+	defaultMaxBufferedRetrievals = 8
+)
+
+var (
+	retrievalBuffersInUse = metrics.GaugeOpts{
+		Namespace: "archiver",
+		Subsystem: "retrieval_buffers",
+		Name:      "in_use",
+		Help:      "The number of retrieval buffers in use.",
+	}
+	retrievalBuffersAllocated = metrics.CounterOpts{
+		Namespace: "archiver",
+		Subsystem: "retrieval_buffers",
+		Name:      "allocated",
+		Help:      "The number of retrieval buffers allocated because none was free in the pool.",
+	}
+	retrievalBuffersWaits = metrics.CounterOpts{
+		Namespace: "archiver",
+		Subsystem: "retrieval_buffers",
+		Name:      "waits",
+		Help:      "The number of retrievals which waited for a buffer as maxBufferedRetrievals were in progress.",
+	}
+)
+
+// MaxBufferedRetrievals is the maximum number of retrievals of archived blockfiles and blocks
+// which buffer their content in memory at the same time
+var MaxBufferedRetrievals int
+
+// BufferPool recycles the buffers of the retrievals of the archived blockfiles, and bounds the number
+// of buffers in use so that the memory of many concurrent retrievals stays bounded. A retrieval waits
+// for a buffer while the limit is reached.
+type BufferPool struct {
+	size  int
+	slots chan struct{}
+	pool  sync.Pool
+
+	inUse     metrics.Gauge
+	allocated metrics.Counter
+	waits     metrics.Counter
+}
+
+var (
+	retrievalBuffers     *BufferPool
+	retrievalBuffersOnce sync.Once
+)
+
+// RetrievalBuffers returns the pool of buffers shared by all the retrievals of this peer,
+// which is created on first use
+func RetrievalBuffers() *BufferPool {
+	retrievalBuffersOnce.Do(func() {
+		maxInUse := MaxBufferedRetrievals
+		if maxInUse <= 0 {
+			maxInUse = defaultMaxBufferedRetrievals
+		}
+		var p metrics.Provider = &disabled.Provider{}
+		if MetricsProvider != nil {
+			p = MetricsProvider
+		}
+		retrievalBuffers = NewBufferPool(RetrievalBufferSize, maxInUse, p)
+	})
+	return retrievalBuffers
+}
+
+// NewBufferPool creates a pool of buffers of size bytes, at most maxInUse of which are in use at the same time
+func NewBufferPool(size, maxInUse int, p metrics.Provider) *BufferPool {
+	bp := &BufferPool{
+		size:      size,
+		slots:     make(chan struct{}, maxInUse),
+		inUse:     p.NewGauge(retrievalBuffersInUse),
+		allocated: p.NewCounter(retrievalBuffersAllocated),
+		waits:     p.NewCounter(retrievalBuffersWaits),
+	}
+	bp.pool.New = func() interface{} {
+		bp.allocated.Add(1)
+		b := make([]byte, size)
+		return &b
+	}
+	return bp
+}
+
+// Get returns a buffer of the size of the pool, waiting while the maximum number of buffers are in use.
+// The buffer must be returned with Put once the retrieval is complete.
+func (bp *BufferPool) Get() []byte {
+	select {
+	case bp.slots <- struct{}{}:
+	default:
+		bp.waits.Add(1)
+		bp.slots <- struct{}{}
+	}
+	bp.inUse.Set(float64(len(bp.slots)))
+	return *bp.pool.Get().(*[]byte)
+}
+
+// Put returns a buffer obtained with Get to the pool. Its content must no longer be referenced.
+func (bp *BufferPool) Put(b []byte) {
+	// A buffer grown by append is not recycled, so that a large block doesn't pin its memory
+	if cap(b) == bp.size {
+		b = b[:bp.size]
+		bp.pool.Put(&b)
+	}
+	<-bp.slots
+	bp.inUse.Set(float64(len(bp.slots)))
+}
+
+// Size returns the size of the buffers of the pool
+func (bp *BufferPool) Size() int {
+	return bp.size
+}
@@ -0,0 +1,52 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferPool(t *testing.T) {
+	provider := &metricsfakes.Provider{}
+	inUse := &metricsfakes.Gauge{}
+	allocated := &metricsfakes.Counter{}
+	waits := &metricsfakes.Counter{}
+	provider.NewGaugeReturns(inUse)
+	provider.NewCounterReturnsOnCall(0, allocated)
+	provider.NewCounterReturnsOnCall(1, waits)
+
+	pool := NewBufferPool(16, 2, provider)
+	b1 := pool.Get()
+	b2 := pool.Get()
+	assert.Len(t, b1, 16)
+	assert.Equal(t, 2, allocated.AddCallCount())
+	assert.Equal(t, float64(2), inUse.SetArgsForCall(inUse.SetCallCount()-1))
+
+	// A third retrieval waits for a buffer to be returned
+	got := make(chan []byte)
+	go func() { got <- pool.Get() }()
+	select {
+	case <-got:
+		t.Fatal("the number of buffers in use exceeds the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	pool.Put(b1)
+	select {
+	case b3 := <-got:
+		assert.Len(t, b3, 16)
+		pool.Put(b3)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the retrieval waiting for a buffer wasn't served")
+	}
+	assert.Equal(t, 1, waits.AddCallCount())
+
+	// A buffer grown beyond the size of the pool is not recycled
+	pool.Put(append(b2, 0))
+	assert.Equal(t, float64(0), inUse.SetArgsForCall(inUse.SetCallCount()-1))
+}
@@ -0,0 +1,141 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CatalogDatabaseLevelDB stores the archive catalog in the db of the block index
+	CatalogDatabaseLevelDB = "goleveldb"
+	// CatalogDatabaseCouchDB stores the archive catalog in the CouchDB of ledger.state.couchDBConfig
+	CatalogDatabaseCouchDB = "CouchDB"
+)
+
+// CatalogDatabase is the database storing the records of the archive catalog, CatalogDatabaseLevelDB when empty
+var CatalogDatabase string
+
+// CatalogRecovery tells if the archive catalog of a channel is checked when the channel is opened, and recovered
+// from its last snapshot and the listing of the repository if it is corrupted
+var CatalogRecovery bool
+
+// CatalogSnapshotsKept is the number of snapshots of the archive catalog kept per channel
+var CatalogSnapshotsKept int
+
+// ValidateCatalogDatabase checks that the database of the archive catalog is supported
+func ValidateCatalogDatabase(database string) error {
+	switch database {
+	case "", CatalogDatabaseLevelDB, CatalogDatabaseCouchDB:
+		return nil
+	}
+	return errors.Errorf("unsupported archive catalog database %s, must be %s or %s", database, CatalogDatabaseLevelDB, CatalogDatabaseCouchDB)
+}
+
+// Catalog provides access to the records of blockfiles which have been archived
+// into the repository for a channel
+type Catalog interface {
+	// IsBlockArchived returns whether the block has been archived into the repository
+	IsBlockArchived(blockNum uint64) (bool, error)
+	// GetArchiveLocation returns the record of the archived blockfile which contains the block.
+	// nil is returned if the block has not been archived.
+	GetArchiveLocation(blockNum uint64) (*archive.ArchivedBlockfileInfo, error)
+	// GetArchivedRanges returns the contiguous ranges of archived blocks in ascending order
+	GetArchivedRanges() ([]*archive.ArchivedBlockRange, error)
+	// GetDiscardedRanges returns the contiguous ranges of archived blocks which have been
+	// discarded from the local file system in ascending order
+	GetDiscardedRanges() ([]*archive.ArchivedBlockRange, error)
+	// ListArchivedBlockfiles returns the records of all the archived blockfiles in ascending order
+	ListArchivedBlockfiles() ([]*archive.ArchivedBlockfileInfo, error)
+	// GetArchivedBlock returns the location of an archived block in its blockfile and its header hash.
+	// nil is returned if the block has not been archived or if its location has not been recorded.
+	GetArchivedBlock(blockNum uint64) (*archive.ArchivedBlockInfo, error)
+	// GetArchivedBlockByHash returns the record of the archived block with the header hash, nil if there is none
+	GetArchivedBlockByHash(headerHash []byte) (*archive.ArchivedBlockInfo, error)
+}
+
+// NewBlockchainArchiveInfo returns the archiving statistics of a ledger reported with its BlockchainInfo.
+// When archiving is enabled on this peer, they tell clients whether the discarded blocks are fetched from
+// the archive. Otherwise, nil is returned if the blocks from the genesis block on have not been archived yet.
+func NewBlockchainArchiveInfo(ledgerID string, catalog Catalog) (*common.BlockchainArchiveInfo, error) {
+	info := &common.BlockchainArchiveInfo{}
+	if IsArchiver || IsClient {
+		info.ArchivingEnabled = true
+		info.FetchEnabled = IsFetchEnabled(ledgerID)
+	}
+	archived, err := catalog.GetArchivedRanges()
+	if err != nil {
+		return nil, err
+	}
+	archivedUpTo, ok := leadingRangeEnd(archived)
+	if !ok {
+		if !info.ArchivingEnabled {
+			return nil, nil
+		}
+		return info, nil
+	}
+	discarded, err := catalog.GetDiscardedRanges()
+	if err != nil {
+		return nil, err
+	}
+	info.ArchivedUpTo = archivedUpTo
+	if discardedUpTo, ok := leadingRangeEnd(discarded); ok {
+		info.OldestLocalBlock = discardedUpTo + 1
+	}
+	return info, nil
+}
+
+// DigestCatalog returns a digest of the archive catalog of a channel: the last block such that it and all
+// the blocks before it have been archived, and the SHA-256 of the block ranges and the checksums of the
+// archived blockfiles, nil while nothing has been archived. The state of the local blockfiles is left out,
+// so that the catalogs of the peers of an organization have the same digest once they are in sync.
+func DigestCatalog(catalog Catalog) (uint64, []byte, error) {
+	infos, err := catalog.ListArchivedBlockfiles()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(infos) == 0 {
+		return 0, nil, nil
+	}
+	archived, err := catalog.GetArchivedRanges()
+	if err != nil {
+		return 0, nil, err
+	}
+	archivedUpTo, _ := leadingRangeEnd(archived)
+
+	h := sha256.New()
+	record := make([]byte, 24)
+	for _, info := range infos {
+		binary.BigEndian.PutUint64(record[0:], info.BlockfileNo)
+		binary.BigEndian.PutUint64(record[8:], info.FirstBlockNum)
+		binary.BigEndian.PutUint64(record[16:], info.LastBlockNum)
+		h.Write(record)
+		h.Write(append([]byte(info.Checksum), 0))
+	}
+	return archivedUpTo, h.Sum(nil), nil
+}
+
+// leadingRangeEnd returns the last block of the contiguous ranges starting from the genesis block,
+// the ranges being sorted, and false if the first range doesn't start from the genesis block
+func leadingRangeEnd(ranges []*archive.ArchivedBlockRange) (uint64, bool) {
+	if len(ranges) == 0 || ranges[0].FirstBlockNum != 0 {
+		return 0, false
+	}
+	end := ranges[0].LastBlockNum
+	for _, r := range ranges[1:] {
+		if r.FirstBlockNum > end+1 {
+			break
+		}
+		if r.LastBlockNum > end {
+			end = r.LastBlockNum
+		}
+	}
+	return end, true
+}
@@ -0,0 +1,128 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCatalog struct {
+	Catalog
+	archived, discarded []*archive.ArchivedBlockRange
+	blockfiles          []*archive.ArchivedBlockfileInfo
+}
+
+func (c *testCatalog) GetArchivedRanges() ([]*archive.ArchivedBlockRange, error) {
+	return c.archived, nil
+}
+
+func (c *testCatalog) GetDiscardedRanges() ([]*archive.ArchivedBlockRange, error) {
+	return c.discarded, nil
+}
+
+func (c *testCatalog) ListArchivedBlockfiles() ([]*archive.ArchivedBlockfileInfo, error) {
+	return c.blockfiles, nil
+}
+
+func TestNewBlockchainArchiveInfo(t *testing.T) {
+	blockRange := func(first, last uint64) *archive.ArchivedBlockRange {
+		return &archive.ArchivedBlockRange{FirstBlockNum: first, LastBlockNum: last}
+	}
+	tests := []struct {
+		name                string
+		archived, discarded []*archive.ArchivedBlockRange
+		expected            *common.BlockchainArchiveInfo
+	}{
+		{name: "nothing archived"},
+		{name: "tail archived only", archived: []*archive.ArchivedBlockRange{blockRange(10, 19)}},
+		{
+			name:     "nothing discarded",
+			archived: []*archive.ArchivedBlockRange{blockRange(0, 9)},
+			expected: &common.BlockchainArchiveInfo{ArchivedUpTo: 9},
+		},
+		{
+			name:      "leading ranges",
+			archived:  []*archive.ArchivedBlockRange{blockRange(0, 9), blockRange(10, 29), blockRange(40, 49)},
+			discarded: []*archive.ArchivedBlockRange{blockRange(0, 19), blockRange(40, 49)},
+			expected:  &common.BlockchainArchiveInfo{OldestLocalBlock: 20, ArchivedUpTo: 29},
+		},
+		{
+			name:      "restored genesis blockfile",
+			archived:  []*archive.ArchivedBlockRange{blockRange(0, 29)},
+			discarded: []*archive.ArchivedBlockRange{blockRange(10, 29)},
+			expected:  &common.BlockchainArchiveInfo{ArchivedUpTo: 29},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := NewBlockchainArchiveInfo("ch1", &testCatalog{archived: test.archived, discarded: test.discarded})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, info)
+		})
+	}
+}
+
+func TestBlockchainArchiveInfoCapabilities(t *testing.T) {
+	defer func(isArchiver, isClient bool, fetchEnabled func(string) bool) {
+		IsArchiver, IsClient, FetchEnabled = isArchiver, isClient, fetchEnabled
+	}(IsArchiver, IsClient, FetchEnabled)
+	IsArchiver, IsClient = false, true
+	FetchEnabled = func(ledgerID string) bool { return ledgerID == "ch1" }
+
+	// The capabilities are reported before any block has been archived
+	info, err := NewBlockchainArchiveInfo("ch1", &testCatalog{})
+	require.NoError(t, err)
+	assert.Equal(t, &common.BlockchainArchiveInfo{ArchivingEnabled: true, FetchEnabled: true}, info)
+
+	info, err = NewBlockchainArchiveInfo("ch2", &testCatalog{
+		archived:  []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 19}},
+		discarded: []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 9}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &common.BlockchainArchiveInfo{OldestLocalBlock: 10, ArchivedUpTo: 19, ArchivingEnabled: true}, info)
+}
+
+func TestDigestCatalog(t *testing.T) {
+	archivedUpTo, digest, err := DigestCatalog(&testCatalog{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), archivedUpTo)
+	assert.Nil(t, digest)
+
+	blockfiles := func(discarded bool) []*archive.ArchivedBlockfileInfo {
+		return []*archive.ArchivedBlockfileInfo{
+			{BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9, Checksum: "sha256:aa", Discarded: discarded},
+			{BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19, Checksum: "sha256:bb"},
+		}
+	}
+	archived := []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 19}}
+	archivedUpTo, digest, err = DigestCatalog(&testCatalog{archived: archived, blockfiles: blockfiles(false)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(19), archivedUpTo)
+	assert.Len(t, digest, 32)
+
+	// The catalogs of peers which have discarded different blockfiles have the same digest
+	_, other, err := DigestCatalog(&testCatalog{archived: archived, blockfiles: blockfiles(true)})
+	require.NoError(t, err)
+	assert.Equal(t, digest, other)
+
+	// but not the ones of peers which have recorded different blockfiles
+	diverged := blockfiles(false)
+	diverged[1].Checksum = "sha256:cc"
+	_, other, err = DigestCatalog(&testCatalog{archived: archived, blockfiles: diverged})
+	require.NoError(t, err)
+	assert.NotEqual(t, digest, other)
+}
+
+func TestValidateCatalogDatabase(t *testing.T) {
+	assert.NoError(t, ValidateCatalogDatabase(""))
+	assert.NoError(t, ValidateCatalogDatabase(CatalogDatabaseLevelDB))
+	assert.NoError(t, ValidateCatalogDatabase(CatalogDatabaseCouchDB))
+	assert.EqualError(t, ValidateCatalogDatabase("mongodb"), "unsupported archive catalog database mongodb, must be goleveldb or CouchDB")
+}
@@ -0,0 +1,80 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"sync"
+	"time"
+)
+
+// ChannelPolicy is the block archiving policy agreed by the organizations of a channel in its configuration.
+// It only tightens the local settings of the peer: the peer keeps the larger number of blockfiles, waits the
+// longer block age and requires the object lock if either the channel or the peer does.
+type ChannelPolicy struct {
+	// MinLocalBlockfiles is the least number of the latest blockfiles kept on the local file system
+	MinLocalBlockfiles int
+	// MinBlockAge is the least time since the last block of an archived blockfile was committed for its local copy to be discarded
+	MinBlockAge time.Duration
+	// ObjectLockRequired indicates whether the local copy of an archived blockfile is discarded only once the repository has locked it
+	ObjectLockRequired bool
+}
+
+var (
+	channelPoliciesLock sync.RWMutex
+	channelPolicies     = map[string]*ChannelPolicy{}
+)
+
+// SetChannelPolicy records the block archiving policy of the channel configuration of a ledger,
+// which is removed when the policy is nil
+func SetChannelPolicy(ledgerID string, policy *ChannelPolicy) {
+	channelPoliciesLock.Lock()
+	defer channelPoliciesLock.Unlock()
+	if policy == nil {
+		delete(channelPolicies, ledgerID)
+		return
+	}
+	p := *policy
+	channelPolicies[ledgerID] = &p
+}
+
+// ChannelPolicyOf returns the block archiving policy of the channel configuration of a ledger, nil if it has none
+func ChannelPolicyOf(ledgerID string) *ChannelPolicy {
+	channelPoliciesLock.RLock()
+	defer channelPoliciesLock.RUnlock()
+	policy, ok := channelPolicies[ledgerID]
+	if !ok {
+		return nil
+	}
+	p := *policy
+	return &p
+}
+
+// KeepLatestBlockfilesOf returns the least number of the latest blockfiles of a ledger kept on the local file system,
+// NumKeepLatestBlocks unless the policy of the channel requires more
+func KeepLatestBlockfilesOf(ledgerID string) int {
+	if policy := ChannelPolicyOf(ledgerID); policy != nil && policy.MinLocalBlockfiles > NumKeepLatestBlocks {
+		return policy.MinLocalBlockfiles
+	}
+	return NumKeepLatestBlocks
+}
+
+// MinBlockAgeBeforeDiscardOf returns the least block age of the archived blockfiles of a ledger for their
+// local copy to be discarded, MinBlockAgeBeforeDiscard unless the policy of the channel requires longer
+func MinBlockAgeBeforeDiscardOf(ledgerID string) time.Duration {
+	if policy := ChannelPolicyOf(ledgerID); policy != nil && policy.MinBlockAge > MinBlockAgeBeforeDiscard {
+		return policy.MinBlockAge
+	}
+	return MinBlockAgeBeforeDiscard
+}
+
+// IsObjectLockRequiredFor tells if the local copy of an archived blockfile of a ledger is discarded only once
+// the repository has locked it, as required by the peer or by the policy of the channel
+func IsObjectLockRequiredFor(ledgerID string) bool {
+	if ObjectLockRequired {
+		return true
+	}
+	policy := ChannelPolicyOf(ledgerID)
+	return policy != nil && policy.ObjectLockRequired
+}
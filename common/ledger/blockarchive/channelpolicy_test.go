@@ -0,0 +1,44 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelPolicy(t *testing.T) {
+	defer func(keep int, age time.Duration, lock bool) {
+		NumKeepLatestBlocks, MinBlockAgeBeforeDiscard, ObjectLockRequired = keep, age, lock
+	}(NumKeepLatestBlocks, MinBlockAgeBeforeDiscard, ObjectLockRequired)
+	NumKeepLatestBlocks, MinBlockAgeBeforeDiscard, ObjectLockRequired = 2, time.Hour, false
+	defer SetChannelPolicy("ch1", nil)
+
+	// No policy in the channel configuration
+	assert.Nil(t, ChannelPolicyOf("ch1"))
+	assert.Equal(t, 2, KeepLatestBlockfilesOf("ch1"))
+	assert.Equal(t, time.Hour, MinBlockAgeBeforeDiscardOf("ch1"))
+	assert.False(t, IsObjectLockRequiredFor("ch1"))
+
+	// The policy tightens the local settings
+	SetChannelPolicy("ch1", &ChannelPolicy{MinLocalBlockfiles: 5, MinBlockAge: 24 * time.Hour, ObjectLockRequired: true})
+	assert.Equal(t, 5, KeepLatestBlockfilesOf("ch1"))
+	assert.Equal(t, 24*time.Hour, MinBlockAgeBeforeDiscardOf("ch1"))
+	assert.True(t, IsObjectLockRequiredFor("ch1"))
+	assert.Equal(t, 2, KeepLatestBlockfilesOf("ch2"))
+	assert.False(t, IsObjectLockRequiredFor("ch2"))
+
+	// The policy never loosens them
+	SetChannelPolicy("ch1", &ChannelPolicy{MinLocalBlockfiles: 1, MinBlockAge: time.Minute})
+	assert.Equal(t, 2, KeepLatestBlockfilesOf("ch1"))
+	assert.Equal(t, time.Hour, MinBlockAgeBeforeDiscardOf("ch1"))
+	ObjectLockRequired = true
+	assert.True(t, IsObjectLockRequiredFor("ch1"))
+
+	SetChannelPolicy("ch1", nil)
+	assert.Nil(t, ChannelPolicyOf("ch1"))
+}
@@ -0,0 +1,121 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RepositoryTypeSFTP is the type of the repositories accessed over SFTP, the default one
+	RepositoryTypeSFTP = "sftp"
+	// RepositoryTypeHDFS is the type of the repository on the HDFS cluster configured by HDFS, accessed over WebHDFS
+	RepositoryTypeHDFS = "hdfs"
+)
+
+// RepositoryType is the type of the repository of the channels without their own, RepositoryTypeSFTP when empty.
+// The blockfiles are archived to the repository of BlockArchiverURL with RepositoryTypeSFTP, and to the HDFS
+// cluster of HDFS with RepositoryTypeHDFS.
+var RepositoryType string
+
+// ChannelRepository is the repository to which the blockfiles of a channel are archived instead of the one
+// of BlockArchiverURL, so that the channels with different data residency requirements are archived to
+// different regions or storage systems from the same peer
+type ChannelRepository struct {
+	// URL of the repository, of the same forms as BlockArchiverURL, empty for RepositoryTypeHDFS
+	URL string
+	// Type of the repository, RepositoryTypeSFTP when empty
+	Type string
+}
+
+var (
+	channelRepositoriesLock sync.RWMutex
+	channelRepositories     = map[string]*ChannelRepository{}
+)
+
+// ValidateChannelRepository checks the URL and the type of the repository of a channel
+func ValidateChannelRepository(repository *ChannelRepository) error {
+	switch t := strings.ToLower(repository.Type); t {
+	case "", RepositoryTypeSFTP:
+		_, err := ParseRepositoryURL(repository.URL)
+		return err
+	case RepositoryTypeHDFS:
+		if repository.URL != "" {
+			return errors.Errorf("invalid repository URL %s, the HDFS cluster is configured separately", repository.URL)
+		}
+		if HDFS == nil {
+			return errors.New("no HDFS cluster configured")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported repository type %s, the supported types are %s and %s", repository.Type, RepositoryTypeSFTP, RepositoryTypeHDFS)
+	}
+}
+
+// ValidateRepositoryType checks the type of the repository of the channels without their own
+func ValidateRepositoryType(repositoryType string) error {
+	switch strings.ToLower(repositoryType) {
+	case "", RepositoryTypeSFTP:
+		return nil
+	case RepositoryTypeHDFS:
+		if HDFS == nil {
+			return errors.New("no HDFS cluster configured")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported repository type %s, the supported types are %s and %s", repositoryType, RepositoryTypeSFTP, RepositoryTypeHDFS)
+	}
+}
+
+// SetChannelRepository records the repository of a ledger, which is removed when the repository is nil
+func SetChannelRepository(ledgerID string, repository *ChannelRepository) {
+	channelRepositoriesLock.Lock()
+	defer channelRepositoriesLock.Unlock()
+	if repository == nil {
+		delete(channelRepositories, ledgerID)
+		return
+	}
+	r := *repository
+	channelRepositories[ledgerID] = &r
+}
+
+// ChannelRepositoryOf returns the repository of a ledger, nil if it is archived to the one of BlockArchiverURL
+func ChannelRepositoryOf(ledgerID string) *ChannelRepository {
+	channelRepositoriesLock.RLock()
+	defer channelRepositoriesLock.RUnlock()
+	repository, ok := channelRepositories[ledgerID]
+	if !ok {
+		return nil
+	}
+	r := *repository
+	return &r
+}
+
+// RepositoryTypeOf returns the type of the repository of a ledger, RepositoryType unless the channel has its own
+func RepositoryTypeOf(ledgerID string) string {
+	repositoryType := RepositoryType
+	if repository := ChannelRepositoryOf(ledgerID); repository != nil {
+		repositoryType = repository.Type
+	}
+	if repositoryType == "" {
+		return RepositoryTypeSFTP
+	}
+	return strings.ToLower(repositoryType)
+}
+
+// RepositoryURLOf returns the URL of the repository of a ledger, BlockArchiverURL unless the channel has its own,
+// or the webhdfs:// URL of the HDFS cluster for RepositoryTypeHDFS
+func RepositoryURLOf(ledgerID string) string {
+	if RepositoryTypeOf(ledgerID) == RepositoryTypeHDFS && HDFS != nil {
+		return HDFS.URL()
+	}
+	if repository := ChannelRepositoryOf(ledgerID); repository != nil {
+		return repository.URL
+	}
+	return BlockArchiverURL
+}
@@ -0,0 +1,83 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelRepository(t *testing.T) {
+	defer func(url string) { BlockArchiverURL = url }(BlockArchiverURL)
+	BlockArchiverURL = "ledger-bank:222"
+	defer SetChannelRepository("ch1", nil)
+
+	assert.Nil(t, ChannelRepositoryOf("ch1"))
+	assert.Equal(t, "ledger-bank:222", RepositoryURLOf("ch1"))
+
+	SetChannelRepository("ch1", &ChannelRepository{URL: "eu-bank:222", Type: RepositoryTypeSFTP})
+	assert.Equal(t, &ChannelRepository{URL: "eu-bank:222", Type: RepositoryTypeSFTP}, ChannelRepositoryOf("ch1"))
+	assert.Equal(t, "eu-bank:222", RepositoryURLOf("ch1"))
+	assert.Equal(t, "ledger-bank:222", RepositoryURLOf("ch2"))
+
+	SetChannelRepository("ch1", nil)
+	assert.Equal(t, "ledger-bank:222", RepositoryURLOf("ch1"))
+}
+
+func TestValidateChannelRepository(t *testing.T) {
+	assert.NoError(t, ValidateChannelRepository(&ChannelRepository{URL: "eu-bank:222"}))
+	assert.NoError(t, ValidateChannelRepository(&ChannelRepository{URL: "sftp://eu-bank:222", Type: "SFTP"}))
+	assert.EqualError(t, ValidateChannelRepository(&ChannelRepository{}), "empty repository URL")
+	assert.EqualError(t, ValidateChannelRepository(&ChannelRepository{URL: "eu-bank:222", Type: "s3"}),
+		"unsupported repository type s3, the supported types are sftp and hdfs")
+
+	defer func(config *webhdfs.Config) { HDFS = config }(HDFS)
+	HDFS = nil
+	assert.EqualError(t, ValidateChannelRepository(&ChannelRepository{Type: "hdfs"}), "no HDFS cluster configured")
+	assert.EqualError(t, ValidateRepositoryType("HDFS"), "no HDFS cluster configured")
+	HDFS = &webhdfs.Config{Namenodes: []string{"http://nn1:9870"}}
+	assert.NoError(t, ValidateChannelRepository(&ChannelRepository{Type: "hdfs"}))
+	assert.NoError(t, ValidateRepositoryType("HDFS"))
+	assert.EqualError(t, ValidateChannelRepository(&ChannelRepository{URL: "nn1:9870", Type: "hdfs"}),
+		"invalid repository URL nn1:9870, the HDFS cluster is configured separately")
+	assert.EqualError(t, ValidateRepositoryType("s3"), "unsupported repository type s3, the supported types are sftp and hdfs")
+}
+
+func TestHDFSRepository(t *testing.T) {
+	defer func(url, repositoryType string, config *webhdfs.Config) {
+		BlockArchiverURL, RepositoryType, HDFS = url, repositoryType, config
+	}(BlockArchiverURL, RepositoryType, HDFS)
+	BlockArchiverURL = "ledger-bank:222"
+	HDFS = &webhdfs.Config{Namenodes: []string{"https://nn1:9871", "https://nn2:9871"}, BasePath: "/fabric"}
+	defer SetChannelRepository("ch1", nil)
+
+	// A channel is archived to the HDFS cluster while the others are archived to the repository of BlockArchiverURL
+	SetChannelRepository("ch1", &ChannelRepository{Type: "HDFS"})
+	assert.Equal(t, RepositoryTypeHDFS, RepositoryTypeOf("ch1"))
+	assert.Equal(t, "swebhdfs://nn1:9871,nn2:9871/fabric", RepositoryURLOf("ch1"))
+	assert.Equal(t, RepositoryTypeSFTP, RepositoryTypeOf("ch2"))
+	assert.Equal(t, "ledger-bank:222", RepositoryURLOf("ch2"))
+
+	// All the channels are archived to the HDFS cluster, except the ones with a repository of their own
+	RepositoryType = RepositoryTypeHDFS
+	SetChannelRepository("ch1", &ChannelRepository{URL: "eu-bank:222"})
+	assert.Equal(t, RepositoryTypeSFTP, RepositoryTypeOf("ch1"))
+	assert.Equal(t, "eu-bank:222", RepositoryURLOf("ch1"))
+	assert.Equal(t, RepositoryTypeHDFS, RepositoryTypeOf("ch2"))
+	assert.Equal(t, "swebhdfs://nn1:9871,nn2:9871/fabric", RepositoryURLOf("ch2"))
+
+	// The clients of the cluster connect like the ones of the repository
+	prevTLSConfig := RepositoryTLSConfig
+	defer func() { RepositoryTLSConfig = prevTLSConfig }()
+	RepositoryTLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	config := HDFSClientConfig()
+	assert.Equal(t, HDFS.Namenodes, config.Namenodes)
+	assert.Equal(t, RepositoryTLSConfig, config.TLSConfig)
+	assert.NotNil(t, config.Dial)
+	assert.Nil(t, HDFS.Dial)
+}
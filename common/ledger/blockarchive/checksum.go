@@ -0,0 +1,173 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"lukechampine.com/blake3"
+)
+
+const (
+	// ChecksumSHA256 is the default checksum algorithm of the transfers of blockfiles
+	ChecksumSHA256 = "sha256"
+	// ChecksumBLAKE3 is a faster checksum algorithm of the transfers of blockfiles
+	ChecksumBLAKE3 = "blake3"
+
+	// ChecksumSuffix is appended to the path of an archived blockfile to derive
+	// the path of its checksum on the repository
+	ChecksumSuffix = ".checksum"
+)
+
+// ChecksumAlgorithm is the algorithm of the checksums computed by this peer,
+// and the preferred one when it negotiates the checksum of a transfer
+var ChecksumAlgorithm = ChecksumSHA256
+
+// SupportedChecksumAlgorithms returns the supported checksum algorithms
+func SupportedChecksumAlgorithms() []string {
+	return []string{ChecksumSHA256, ChecksumBLAKE3}
+}
+
+// PreferredChecksumAlgorithms returns the supported checksum algorithms offered by this peer
+// when it negotiates the checksum of a transfer, in order of preference
+func PreferredChecksumAlgorithms() []string {
+	preferred := []string{ChecksumAlgorithm}
+	for _, algorithm := range SupportedChecksumAlgorithms() {
+		if algorithm != ChecksumAlgorithm {
+			preferred = append(preferred, algorithm)
+		}
+	}
+	return preferred
+}
+
+// NewChecksumHash returns a hash computing the checksums of the algorithm
+func NewChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumBLAKE3:
+		return blake3.New(32, nil), nil
+	}
+	return nil, errors.Errorf("unsupported checksum algorithm %s", algorithm)
+}
+
+// NegotiateChecksumAlgorithm returns the first supported algorithm among the algorithms offered by
+// the other end of a transfer in order of preference, or SHA-256 if none of them is supported
+func NegotiateChecksumAlgorithm(offered []string) string {
+	for _, algorithm := range offered {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if _, err := NewChecksumHash(algorithm); err == nil {
+			return algorithm
+		}
+	}
+	return ChecksumSHA256
+}
+
+// Checksum is a checksum of a blockfile together with its algorithm. It is exchanged and recorded
+// in the form "<algorithm>:<hex digest>".
+type Checksum struct {
+	Algorithm string
+	Digest    []byte
+}
+
+func (c *Checksum) String() string {
+	return c.Algorithm + ":" + hex.EncodeToString(c.Digest)
+}
+
+// ParseChecksum parses a checksum of the form "<algorithm>:<hex digest>"
+func ParseChecksum(s string) (*Checksum, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return nil, errors.Errorf("invalid checksum %s", s)
+	}
+	algorithm := s[:i]
+	if _, err := NewChecksumHash(algorithm); err != nil {
+		return nil, err
+	}
+	digest, err := hex.DecodeString(strings.TrimSpace(s[i+1:]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid checksum %s", s)
+	}
+	return &Checksum{Algorithm: algorithm, Digest: digest}, nil
+}
+
+// ChecksumMismatchError is returned when the content of a transferred blockfile doesn't match its checksum
+type ChecksumMismatchError struct {
+	Expected *Checksum
+	Actual   *Checksum
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// ChecksumWriter computes the checksum of the content written to it
+type ChecksumWriter struct {
+	algorithm string
+	hash      hash.Hash
+}
+
+// NewChecksumWriter returns a ChecksumWriter computing the checksum of the algorithm
+func NewChecksumWriter(algorithm string) (*ChecksumWriter, error) {
+	h, err := NewChecksumHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &ChecksumWriter{algorithm: algorithm, hash: h}, nil
+}
+
+func (w *ChecksumWriter) Write(p []byte) (int, error) {
+	return w.hash.Write(p)
+}
+
+// Checksum returns the checksum of the content written so far
+func (w *ChecksumWriter) Checksum() *Checksum {
+	return &Checksum{Algorithm: w.algorithm, Digest: w.hash.Sum(nil)}
+}
+
+// Verify checks the checksum of the content written so far against the expected checksum,
+// which must be of the same algorithm
+func (w *ChecksumWriter) Verify(expected *Checksum) error {
+	actual := w.Checksum()
+	if expected.Algorithm != actual.Algorithm || !bytes.Equal(expected.Digest, actual.Digest) {
+		return &ChecksumMismatchError{Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// ComputeChecksum returns the checksum of the whole content of the reader
+func ComputeChecksum(reader io.Reader, algorithm string) (*Checksum, error) {
+	w, err := NewChecksumWriter(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		return nil, err
+	}
+	return w.Checksum(), nil
+}
+
+// ComputeBlockfileChecksum returns the checksum of the whole content of the blockfile
+func ComputeBlockfileChecksum(blockfilePath string, algorithm string) (*Checksum, error) {
+	file, err := os.Open(blockfilePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening blockfile %s", blockfilePath)
+	}
+	defer file.Close()
+
+	checksum, err := ComputeChecksum(file, algorithm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading blockfile %s", blockfilePath)
+	}
+	return checksum, nil
+}
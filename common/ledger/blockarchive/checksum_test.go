@@ -0,0 +1,76 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	for _, algorithm := range SupportedChecksumAlgorithms() {
+		checksum, err := ComputeChecksum(bytes.NewReader([]byte("blockfile")), algorithm)
+		require.NoError(t, err)
+		parsed, err := ParseChecksum(checksum.String())
+		require.NoError(t, err)
+		assert.Equal(t, checksum, parsed)
+
+		w, err := NewChecksumWriter(algorithm)
+		require.NoError(t, err)
+		w.Write([]byte("blockfile"))
+		assert.NoError(t, w.Verify(checksum))
+		w.Write([]byte("tampered"))
+		err = w.Verify(checksum)
+		assert.IsType(t, &ChecksumMismatchError{}, err)
+	}
+
+	checksum, err := ComputeChecksum(bytes.NewReader([]byte("blockfile")), ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:6ee36565dbb77c144c863f8ea24c5ba212902b50918b9acebe8e460c2fdaff14", checksum.String())
+	// The official test vector of an empty input
+	checksum, err = ComputeChecksum(bytes.NewReader(nil), ChecksumBLAKE3)
+	require.NoError(t, err)
+	assert.Equal(t, "blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262", checksum.String())
+
+	_, err = ParseChecksum("md5:00")
+	assert.EqualError(t, err, "unsupported checksum algorithm md5")
+	_, err = ParseChecksum("sha256")
+	assert.EqualError(t, err, "invalid checksum sha256")
+}
+
+func TestPreferredChecksumAlgorithms(t *testing.T) {
+	assert.Equal(t, []string{ChecksumSHA256, ChecksumBLAKE3}, PreferredChecksumAlgorithms())
+	ChecksumAlgorithm = ChecksumBLAKE3
+	defer func() { ChecksumAlgorithm = ChecksumSHA256 }()
+	assert.Equal(t, []string{ChecksumBLAKE3, ChecksumSHA256}, PreferredChecksumAlgorithms())
+}
+
+func TestNegotiateChecksumAlgorithm(t *testing.T) {
+	assert.Equal(t, ChecksumBLAKE3, NegotiateChecksumAlgorithm([]string{"md5", " BLAKE3", "sha256"}))
+	assert.Equal(t, ChecksumSHA256, NegotiateChecksumAlgorithm([]string{"sha256", "blake3"}))
+	assert.Equal(t, ChecksumSHA256, NegotiateChecksumAlgorithm([]string{"md5"}))
+	assert.Equal(t, ChecksumSHA256, NegotiateChecksumAlgorithm(nil))
+}
+
+func BenchmarkChecksum(b *testing.B) {
+	// A blockfile is 64MB by default, a megabyte keeps the benchmark short
+	blockfile := make([]byte, 1024*1024)
+	for i := range blockfile {
+		blockfile[i] = byte(i % 251)
+	}
+	for _, algorithm := range SupportedChecksumAlgorithms() {
+		b.Run(algorithm, func(b *testing.B) {
+			b.SetBytes(int64(len(blockfile)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ComputeChecksum(bytes.NewReader(blockfile), algorithm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CompressionNone stores the archived blockfiles as is
+	CompressionNone = "none"
+	// CompressionGzip compresses the archived blockfiles with gzip, at a level from 1 (fastest) to 9 (smallest)
+	CompressionGzip = "gzip"
+	// CompressionSnappy compresses the archived blockfiles with snappy, which has no level
+	CompressionSnappy = "snappy"
+
+	// CompressionLevelAuto picks the level of each blockfile from the upload bandwidth and
+	// the compression speed measured by the peer
+	CompressionLevelAuto = "auto"
+)
+
+// CompressionSettings are the algorithm and the level with which the blockfiles of a channel are compressed
+// before they are uploaded to the repository
+type CompressionSettings struct {
+	Algorithm string
+	// Level is the level of the algorithm, the default one of the algorithm when 0
+	Level int
+	// Auto tells whether the level is picked for each blockfile rather than fixed
+	Auto bool
+}
+
+// Compression returns the compression settings of the blockfiles of a ledger, nil if they are stored as is.
+// The blockfiles are not compressed when it is nil.
+var Compression func(ledgerID string) *CompressionSettings
+
+// CompressionOf returns the compression settings of the blockfiles of a ledger, nil if they are stored as is
+func CompressionOf(ledgerID string) *CompressionSettings {
+	if Compression == nil {
+		return nil
+	}
+	settings := Compression(ledgerID)
+	if settings == nil || settings.Algorithm == CompressionNone {
+		return nil
+	}
+	return settings
+}
+
+// ParseCompression parses the compression algorithm and level of the configuration. The blockfiles are stored
+// as is when the algorithm is empty. The level is the default one of the algorithm when it is empty.
+func ParseCompression(algorithm, level string) (*CompressionSettings, error) {
+	settings := &CompressionSettings{Algorithm: strings.ToLower(strings.TrimSpace(algorithm))}
+	if settings.Algorithm == "" {
+		settings.Algorithm = CompressionNone
+	}
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch settings.Algorithm {
+	case CompressionNone:
+		return settings, nil
+	case CompressionGzip:
+	case CompressionSnappy:
+		if level != "" {
+			return nil, errors.Errorf("compression algorithm %s has no level", settings.Algorithm)
+		}
+		return settings, nil
+	default:
+		return nil, errors.Errorf("unsupported compression algorithm %s", algorithm)
+	}
+	switch level {
+	case "":
+	case CompressionLevelAuto:
+		settings.Auto = true
+	default:
+		n, err := strconv.Atoi(level)
+		if err != nil || n < gzip.BestSpeed || n > gzip.BestCompression {
+			return nil, errors.Errorf("invalid compression level %s, expected %s or a level from %d to %d",
+				level, CompressionLevelAuto, gzip.BestSpeed, gzip.BestCompression)
+		}
+		settings.Level = n
+	}
+	return settings, nil
+}
+
+// CompressionLevels returns the levels of a compression algorithm from the fastest to the smallest, nil if it has none
+func CompressionLevels(algorithm string) []int {
+	if algorithm != CompressionGzip {
+		return nil
+	}
+	levels := make([]int, 0, gzip.BestCompression)
+	for level := gzip.BestSpeed; level <= gzip.BestCompression; level++ {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// NewCompressor returns a writer compressing the content written to it into w with the algorithm at the level,
+// the default level of the algorithm when it is 0. The compressed content is complete once the writer is closed.
+func NewCompressor(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionSnappy:
+		return snappy.NewBufferedWriter(w), nil
+	}
+	return nil, errors.Errorf("unsupported compression algorithm %s", algorithm)
+}
+
+// NewDecompressor returns a reader of the content compressed with the algorithm read from r
+func NewDecompressor(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		decompressor, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading gzip compressed blockfile")
+		}
+		return decompressor, nil
+	case CompressionSnappy:
+		return ioutil.NopCloser(snappy.NewReader(r)), nil
+	}
+	return nil, errors.Errorf("unsupported compression algorithm %s", algorithm)
+}
@@ -0,0 +1,90 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		algorithm, level string
+		expected         *CompressionSettings
+		err              string
+	}{
+		{algorithm: "", expected: &CompressionSettings{Algorithm: CompressionNone}},
+		{algorithm: "none", level: "9", expected: &CompressionSettings{Algorithm: CompressionNone}},
+		{algorithm: "gzip", expected: &CompressionSettings{Algorithm: CompressionGzip}},
+		{algorithm: " GZIP ", level: "9", expected: &CompressionSettings{Algorithm: CompressionGzip, Level: 9}},
+		{algorithm: "gzip", level: "Auto", expected: &CompressionSettings{Algorithm: CompressionGzip, Auto: true}},
+		{algorithm: "snappy", expected: &CompressionSettings{Algorithm: CompressionSnappy}},
+		{algorithm: "gzip", level: "0", err: "invalid compression level 0, expected auto or a level from 1 to 9"},
+		{algorithm: "gzip", level: "fast", err: "invalid compression level fast, expected auto or a level from 1 to 9"},
+		{algorithm: "snappy", level: "auto", err: "compression algorithm snappy has no level"},
+		{algorithm: "zstd", err: "unsupported compression algorithm zstd"},
+	}
+	for _, test := range tests {
+		settings, err := ParseCompression(test.algorithm, test.level)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, settings)
+	}
+}
+
+func TestCompressionOf(t *testing.T) {
+	defer func(prev func(string) *CompressionSettings) { Compression = prev }(Compression)
+	Compression = nil
+	assert.Nil(t, CompressionOf("testLedger"))
+	Compression = func(ledgerID string) *CompressionSettings {
+		if ledgerID == "testLedger" {
+			return &CompressionSettings{Algorithm: CompressionGzip}
+		}
+		return &CompressionSettings{Algorithm: CompressionNone}
+	}
+	assert.Equal(t, &CompressionSettings{Algorithm: CompressionGzip}, CompressionOf("testLedger"))
+	assert.Nil(t, CompressionOf("otherLedger"))
+}
+
+func TestCompressionLevels(t *testing.T) {
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, CompressionLevels(CompressionGzip))
+	assert.Nil(t, CompressionLevels(CompressionSnappy))
+}
+
+func TestCompressorRoundTrip(t *testing.T) {
+	content := bytes.Repeat([]byte("block content "), 1000)
+	for _, algorithm := range []string{CompressionGzip, CompressionSnappy} {
+		for _, level := range []int{0, 1, 9} {
+			compressed := &bytes.Buffer{}
+			compressor, err := NewCompressor(compressed, algorithm, level)
+			require.NoError(t, err)
+			_, err = compressor.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, compressor.Close())
+			assert.True(t, compressed.Len() < len(content))
+
+			decompressor, err := NewDecompressor(compressed, algorithm)
+			require.NoError(t, err)
+			decompressed, err := ioutil.ReadAll(decompressor)
+			require.NoError(t, err)
+			require.NoError(t, decompressor.Close())
+			assert.Equal(t, content, decompressed)
+		}
+	}
+
+	_, err := NewCompressor(&bytes.Buffer{}, CompressionNone, 0)
+	assert.EqualError(t, err, "unsupported compression algorithm none")
+	_, err = NewDecompressor(&bytes.Buffer{}, "zstd")
+	assert.EqualError(t, err, "unsupported compression algorithm zstd")
+	_, err = NewDecompressor(bytes.NewReader([]byte("not gzip")), CompressionGzip)
+	assert.Contains(t, err.Error(), "error reading gzip compressed blockfile")
+}
@@ -0,0 +1,50 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// ObjectsDir is the directory, relative to ArchiveRootDir, containing the content-addressed blockfiles
+	ObjectsDir = "objects"
+	// RefsSuffix is appended to the path of a content-addressed blockfile to derive the path
+	// of the directory containing its references
+	RefsSuffix = ".refs"
+	// OwnersSuffix is appended to the path of an archived blockfile to derive the path of the record,
+	// written by the repository, of the organizations which have uploaded or referenced the blockfile
+	OwnersSuffix = ".owners"
+)
+
+// ContentAddressed indicates whether the archived blockfiles are stored on the repository under the
+// SHA-256 hash of their content, so that the identical blockfiles archived by several peers are stored once.
+// Each archiving peer then records a reference to the blockfile, and the blockfile is kept as long as
+// it is referenced.
+var ContentAddressed bool
+
+// ArchiverID identifies the archiver peer in the references to the content-addressed blockfiles
+var ArchiverID string
+
+// ContentAddressedKey returns the key of a blockfile on the repository from the SHA-256 hash of its content,
+// relative to ArchiveRootDir. The keys are spread over subdirectories by the first byte of the hash.
+func ContentAddressedKey(hash []byte) string {
+	h := hex.EncodeToString(hash)
+	return path.Join(ObjectsDir, h[:2], h)
+}
+
+// BlockfileRefName returns the name of the reference of an archiver peer to a content-addressed blockfile,
+// which it has archived as the blockfile of a channel
+func BlockfileRefName(archiverID, channelID string, blockfileNo uint64) string {
+	return fmt.Sprintf("%s_%s_%06d", strings.Replace(archiverID, "/", "_", -1), channelID, blockfileNo)
+}
+
+// BlockfileRefPath returns the path on the repository of a reference to the content-addressed blockfile at location
+func BlockfileRefPath(location, refName string) string {
+	return path.Join(location+RefsSuffix, refName)
+}
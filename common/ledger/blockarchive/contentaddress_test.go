@@ -0,0 +1,25 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentAddressedKey(t *testing.T) {
+	hash := sha256.Sum256([]byte("blockfile"))
+	key := ContentAddressedKey(hash[:])
+	assert.Equal(t, "objects/6e/6ee36565dbb77c144c863f8ea24c5ba212902b50918b9acebe8e460c2fdaff14", key)
+}
+
+func TestBlockfileRef(t *testing.T) {
+	name := BlockfileRefName("peer0.org1.example.com", "mychannel", 3)
+	assert.Equal(t, "peer0.org1.example.com_mychannel_000003", name)
+	assert.Equal(t, "/blkstore/objects/ab/abcd.refs/"+name, BlockfileRefPath("/blkstore/objects/ab/abcd", name))
+	assert.Equal(t, "org1_peer0_mychannel_000000", BlockfileRefName("org1/peer0", "mychannel", 0))
+}
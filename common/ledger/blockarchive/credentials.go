@@ -0,0 +1,26 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RepositoryCredentials returns the user and password to authenticate to the repository with, over SFTP
+// and to its HTTP API: the API token of RepositoryTokenFile if set, which is read again on every connection
+// to pick up the rotations of the token, or the default account
+func RepositoryCredentials() (string, string, error) {
+	if RepositoryTokenFile == "" {
+		return "root", "blkstore", nil
+	}
+	token, err := ioutil.ReadFile(RepositoryTokenFile)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "error reading repository token file %s", RepositoryTokenFile)
+	}
+	return RepositoryTokenUser, strings.TrimSpace(string(token)), nil
+}
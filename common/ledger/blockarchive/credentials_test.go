@@ -0,0 +1,41 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryCredentials(t *testing.T) {
+	defer func() { RepositoryTokenFile = "" }()
+
+	user, password, err := RepositoryCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "root", user)
+	assert.Equal(t, "blkstore", password)
+
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "repo.token")
+	RepositoryTokenFile = tokenFile
+	_, _, err = RepositoryCredentials()
+	assert.Error(t, err)
+
+	// The token file is read on every connection, so that a rotated token is picked up
+	for _, token := range []string{"bat_first", "bat_rotated"} {
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0600))
+		user, password, err = RepositoryCredentials()
+		require.NoError(t, err)
+		assert.Equal(t, RepositoryTokenUser, user)
+		assert.Equal(t, token, password)
+	}
+}
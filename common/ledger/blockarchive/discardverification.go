@@ -0,0 +1,39 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+const (
+	// DiscardVerificationFull verifies an archived blockfile by downloading it from the repository
+	DiscardVerificationFull = "full"
+	// DiscardVerificationDigest verifies an archived blockfile with the digest computed by the repository
+	// through its API at RepositoryAPIURL, without downloading it
+	DiscardVerificationDigest = "digest"
+)
+
+// DiscardVerification tells, for a ledger, whether its archived blockfiles are verified against the checksums
+// recorded in the catalog before their local copy is discarded, and the interval fullEvery of the blockfiles
+// verified in full: every fullEvery-th blockfile, by blockfile number, is downloaded, and the others are verified
+// with the digest of the repository. None is downloaded when fullEvery is 0. The blockfiles are not verified
+// when it is nil.
+var DiscardVerification func(ledgerID string) (enabled bool, fullEvery int)
+
+// DiscardVerificationOf returns how an archived blockfile of a ledger is verified before its local copy is
+// discarded, DiscardVerificationFull or DiscardVerificationDigest, and an empty string if it is not verified.
+// The blockfiles are verified in full when the API of the repository is not configured, and when the ledger
+// is archived to HDFS, since the API is the one of the repository server.
+func DiscardVerificationOf(ledgerID string, fileNum uint64) string {
+	if DiscardVerification == nil {
+		return ""
+	}
+	enabled, fullEvery := DiscardVerification(ledgerID)
+	switch {
+	case !enabled:
+		return ""
+	case RepositoryAPIURL == "", RepositoryTypeOf(ledgerID) == RepositoryTypeHDFS, fullEvery > 0 && fileNum%uint64(fullEvery) == 0:
+		return DiscardVerificationFull
+	default:
+		return DiscardVerificationDigest
+	}
+}
@@ -0,0 +1,42 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscardVerificationOf(t *testing.T) {
+	defer func(prev func(string) (bool, int), prevAPIURL, prevType string) {
+		DiscardVerification, RepositoryAPIURL, RepositoryType = prev, prevAPIURL, prevType
+	}(DiscardVerification, RepositoryAPIURL, RepositoryType)
+	DiscardVerification = nil
+	assert.Equal(t, "", DiscardVerificationOf("testLedger", 0))
+
+	enabled, fullEvery := true, 3
+	DiscardVerification = func(string) (bool, int) { return enabled, fullEvery }
+	RepositoryAPIURL = "https://blkarchiver-repo:9445"
+	var modes []string
+	for fileNum := uint64(0); fileNum < 4; fileNum++ {
+		modes = append(modes, DiscardVerificationOf("testLedger", fileNum))
+	}
+	assert.Equal(t, []string{DiscardVerificationFull, DiscardVerificationDigest,
+		DiscardVerificationDigest, DiscardVerificationFull}, modes)
+	fullEvery = 0
+	assert.Equal(t, DiscardVerificationDigest, DiscardVerificationOf("testLedger", 0))
+
+	// The blockfiles archived to HDFS are downloaded, the API being the one of the repository server
+	RepositoryType = RepositoryTypeHDFS
+	assert.Equal(t, DiscardVerificationFull, DiscardVerificationOf("testLedger", 1))
+	RepositoryType = ""
+
+	// The blockfiles are downloaded without the API of the repository
+	RepositoryAPIURL = ""
+	assert.Equal(t, DiscardVerificationFull, DiscardVerificationOf("testLedger", 1))
+	enabled = false
+	assert.Equal(t, "", DiscardVerificationOf("testLedger", 1))
+}
@@ -0,0 +1,279 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EncryptionAES256GCM encrypts the archived blockfiles with AES-256 in GCM mode, by chunks
+	EncryptionAES256GCM = "aes-256-gcm"
+
+	// EncryptionKeySize is the size of the keys the blockfiles are encrypted with
+	EncryptionKeySize = 32
+
+	// encryptionChunkSize is the number of bytes of the blockfile encrypted and authenticated together, so that
+	// an object is decrypted while it is streamed without holding the whole blockfile in memory
+	encryptionChunkSize = 64 * 1024
+	// encryptionNoncePrefixSize is the size of the random part of the nonces of an object, which is stored in
+	// its header. The nonce of a chunk is the prefix followed by the 4-byte number of the chunk.
+	encryptionNoncePrefixSize = 8
+	// encryptionOverhead is the size of the authentication tag GCM appends to a chunk
+	encryptionOverhead = 16
+)
+
+// EncryptionKeys are the keys the archived blockfiles are encrypted and decrypted with, by key ID.
+// The encrypted blockfiles cannot be read when it is nil.
+var EncryptionKeys *EncryptionKeyring
+
+// EncryptionKeyID is the ID of the key of EncryptionKeys the blockfiles are encrypted with before they are
+// uploaded to the repository. The blockfiles are not encrypted when it is empty.
+var EncryptionKeyID string
+
+// EncryptionKeyring holds the keys of the archived blockfiles by key ID. The keys rotated out are kept in the
+// keyring until the blockfiles encrypted with them have been encrypted again with the new key.
+type EncryptionKeyring struct {
+	keys map[string][]byte
+}
+
+// MissingEncryptionKeyError is returned when an archived object is encrypted with a key which is not in the keyring
+type MissingEncryptionKeyError struct {
+	KeyID string
+}
+
+func (e *MissingEncryptionKeyError) Error() string {
+	return "encryption key " + e.KeyID + " is not in the keyring"
+}
+
+// IsEncryptionKeyMissing tells if err is caused by an archived object encrypted with a key which is not in the
+// keyring, such as when the repository reads an object which only the peers can decrypt
+func IsEncryptionKeyMissing(err error) bool {
+	_, ok := errors.Cause(err).(*MissingEncryptionKeyError)
+	return ok
+}
+
+// NewEncryptionKeyring returns a keyring holding the keys by key ID
+func NewEncryptionKeyring(keys map[string][]byte) (*EncryptionKeyring, error) {
+	keyring := &EncryptionKeyring{keys: make(map[string][]byte, len(keys))}
+	for keyID, key := range keys {
+		if keyID == "" {
+			return nil, errors.New("encryption key with an empty ID")
+		}
+		if len(key) != EncryptionKeySize {
+			return nil, errors.Errorf("encryption key %s has %d bytes, expected %d", keyID, len(key), EncryptionKeySize)
+		}
+		keyring.keys[keyID] = key
+	}
+	return keyring, nil
+}
+
+// LoadEncryptionKeyring reads the keys of a directory, holding a file per key named by the key ID whose content is
+// the base64 encoded key. The hidden files are left out, such as the ones of the secrets mounted by Kubernetes.
+func LoadEncryptionKeyring(dir string) (*EncryptionKeyring, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading encryption key directory %s", dir)
+	}
+	keys := make(map[string][]byte)
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading encryption key %s", file.Name())
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding encryption key %s", file.Name())
+		}
+		keys[file.Name()] = key
+	}
+	return NewEncryptionKeyring(keys)
+}
+
+// Key returns the key of the ID
+func (k *EncryptionKeyring) Key(keyID string) ([]byte, error) {
+	if k != nil {
+		if key, ok := k.keys[keyID]; ok {
+			return key, nil
+		}
+	}
+	return nil, &MissingEncryptionKeyError{KeyID: keyID}
+}
+
+// NewNoncePrefix returns the random prefix of the nonces of a new encrypted object
+func NewNoncePrefix() ([]byte, error) {
+	prefix := make([]byte, encryptionNoncePrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, errors.Wrap(err, "error generating the nonce of the encryption")
+	}
+	return prefix, nil
+}
+
+// chunkCipher seals and opens the chunks of an encrypted object. The chunks authenticate the additional data of the
+// object, and the last chunk is authenticated as the last one, so that an object truncated at a chunk boundary is
+// not taken for a complete one.
+type chunkCipher struct {
+	aead   cipher.AEAD
+	nonce  []byte
+	number uint32
+	// chunkData and lastChunkData are the additional data of the chunks and of the last chunk
+	chunkData     []byte
+	lastChunkData []byte
+}
+
+func newChunkCipher(key, noncePrefix, additionalData []byte) (*chunkCipher, error) {
+	if len(noncePrefix) != encryptionNoncePrefixSize {
+		return nil, errors.Errorf("invalid nonce of %d bytes, expected %d", len(noncePrefix), encryptionNoncePrefixSize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid encryption key")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, noncePrefix)
+	return &chunkCipher{
+		aead:          aead,
+		nonce:         nonce,
+		chunkData:     append(append([]byte{}, additionalData...), 0),
+		lastChunkData: append(append([]byte{}, additionalData...), 1),
+	}, nil
+}
+
+// next returns the nonce and the additional data of the next chunk
+func (c *chunkCipher) next(last bool) ([]byte, []byte) {
+	binary.BigEndian.PutUint32(c.nonce[encryptionNoncePrefixSize:], c.number)
+	c.number++
+	if last {
+		return c.nonce, c.lastChunkData
+	}
+	return c.nonce, c.chunkData
+}
+
+// encrypter encrypts the content written to it by chunks
+type encrypter struct {
+	w      io.Writer
+	cipher *chunkCipher
+	buf    []byte
+	sealed []byte
+}
+
+// NewEncrypter returns a writer encrypting the content written to it into w with the key, with the nonces starting
+// with noncePrefix, authenticating additionalData with each chunk. The encrypted content is complete once the writer
+// is closed.
+func NewEncrypter(w io.Writer, key, noncePrefix, additionalData []byte) (io.WriteCloser, error) {
+	c, err := newChunkCipher(key, noncePrefix, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypter{w: w, cipher: c, buf: make([]byte, 0, encryptionChunkSize)}, nil
+}
+
+func (e *encrypter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is sealed once more content follows it, the last chunk is sealed by Close
+		if len(e.buf) == encryptionChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encryptionChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encrypter) seal(last bool) error {
+	nonce, additionalData := e.cipher.next(last)
+	e.sealed = e.cipher.aead.Seal(e.sealed[:0], nonce, e.buf, additionalData)
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(e.sealed)
+	return err
+}
+
+// Close seals the last chunk, which is empty for an empty content
+func (e *encrypter) Close() error {
+	return e.seal(true)
+}
+
+// decrypter decrypts an encrypted content by chunks
+type decrypter struct {
+	r      *bufio.Reader
+	cipher *chunkCipher
+	sealed []byte
+	chunk  []byte
+	done   bool
+}
+
+// NewDecrypter returns a reader of the content encrypted with the key and the nonces starting with noncePrefix read
+// from r, whose chunks authenticate additionalData. The chunks which fail their authentication, or an incomplete
+// content, fail the read.
+func NewDecrypter(r io.Reader, key, noncePrefix, additionalData []byte) (io.Reader, error) {
+	return newDecrypter(r, key, noncePrefix, additionalData, make([]byte, encryptionChunkSize+encryptionOverhead))
+}
+
+// newDecrypter returns a reader like NewDecrypter reading the chunks into sealed, of the size of a sealed chunk
+func newDecrypter(r io.Reader, key, noncePrefix, additionalData, sealed []byte) (io.Reader, error) {
+	c, err := newChunkCipher(key, noncePrefix, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypter{r: bufio.NewReader(r), cipher: c, sealed: sealed}, nil
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.chunk) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.chunk)
+	d.chunk = d.chunk[n:]
+	return n, nil
+}
+
+// open reads and opens the next chunk, the last one if no content follows it
+func (d *decrypter) open() error {
+	n, err := io.ReadFull(d.r, d.sealed)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return errors.Wrap(err, "error reading encrypted blockfile")
+	}
+	last := err != nil
+	if !last {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+	nonce, additionalData := d.cipher.next(last)
+	chunk, err := d.cipher.aead.Open(d.sealed[:0], nonce, d.sealed[:n], additionalData)
+	if err != nil {
+		return errors.Errorf("chunk %d of encrypted blockfile failed its authentication", d.cipher.number-1)
+	}
+	d.chunk, d.done = chunk, last
+	return nil
+}
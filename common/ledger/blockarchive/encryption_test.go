@@ -0,0 +1,210 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setTestEncryptionKeys sets a keyring holding keys of the IDs and returns the function restoring the previous one
+func setTestEncryptionKeys(t *testing.T, keyIDs ...string) func() {
+	keys := make(map[string][]byte)
+	for i, keyID := range keyIDs {
+		keys[keyID] = bytes.Repeat([]byte{byte(i + 1)}, EncryptionKeySize)
+	}
+	keyring, err := NewEncryptionKeyring(keys)
+	require.NoError(t, err)
+	prev := EncryptionKeys
+	EncryptionKeys = keyring
+	return func() { EncryptionKeys = prev }
+}
+
+func TestLoadEncryptionKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encryptionkeys")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	key := bytes.Repeat([]byte{7}, EncryptionKeySize)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key-2026"), []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+	// The hidden files and the directories of a mounted secret are left out
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".hidden"), []byte("not a key"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0700))
+
+	keyring, err := LoadEncryptionKeyring(dir)
+	require.NoError(t, err)
+	loaded, err := keyring.Key("key-2026")
+	require.NoError(t, err)
+	assert.Equal(t, key, loaded)
+	_, err = keyring.Key("key-2025")
+	assert.EqualError(t, err, "encryption key key-2025 is not in the keyring")
+	assert.True(t, IsEncryptionKeyMissing(err))
+	_, err = (*EncryptionKeyring)(nil).Key("key-2026")
+	assert.True(t, IsEncryptionKeyMissing(err))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "short"), []byte(base64.StdEncoding.EncodeToString(key[:16])), 0600))
+	_, err = LoadEncryptionKeyring(dir)
+	assert.EqualError(t, err, "encryption key short has 16 bytes, expected 32")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "short"), []byte("not base64!"), 0600))
+	_, err = LoadEncryptionKeyring(dir)
+	assert.Contains(t, err.Error(), "error decoding encryption key short")
+	_, err = LoadEncryptionKeyring(filepath.Join(dir, "missing"))
+	assert.Contains(t, err.Error(), "error reading encryption key directory")
+}
+
+func TestEncrypterRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, EncryptionKeySize)
+	nonce, err := NewNoncePrefix()
+	require.NoError(t, err)
+	for _, size := range []int{0, 1, encryptionChunkSize, encryptionChunkSize + 1, 3*encryptionChunkSize - 7} {
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = byte(i * 31)
+		}
+		encrypted := &bytes.Buffer{}
+		encrypter, err := NewEncrypter(encrypted, key, nonce, []byte("header"))
+		require.NoError(t, err)
+		// The content is written in pieces which are not aligned on the chunks
+		for i := 0; i < len(content); i += 10000 {
+			end := i + 10000
+			if end > len(content) {
+				end = len(content)
+			}
+			_, err := encrypter.Write(content[i:end])
+			require.NoError(t, err)
+		}
+		require.NoError(t, encrypter.Close())
+		assert.False(t, size > 1 && bytes.Contains(encrypted.Bytes(), content), "content of %d bytes is not encrypted", size)
+
+		decrypter, err := NewDecrypter(bytes.NewReader(encrypted.Bytes()), key, nonce, []byte("header"))
+		require.NoError(t, err)
+		decrypted, err := ioutil.ReadAll(decrypter)
+		require.NoError(t, err, "content of %d bytes", size)
+		assert.Equal(t, content, decrypted)
+	}
+}
+
+func TestDecrypterRejectsTamperedContent(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, EncryptionKeySize)
+	nonce, err := NewNoncePrefix()
+	require.NoError(t, err)
+	content := bytes.Repeat([]byte("block content "), encryptionChunkSize/5)
+	encrypted := &bytes.Buffer{}
+	encrypter, err := NewEncrypter(encrypted, key, nonce, []byte("header"))
+	require.NoError(t, err)
+	_, err = encrypter.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, encrypter.Close())
+	sealedChunkSize := encryptionChunkSize + 16
+
+	decrypt := func(encrypted []byte, key []byte) error {
+		decrypter, err := NewDecrypter(bytes.NewReader(encrypted), key, nonce, []byte("header"))
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(decrypter)
+		return err
+	}
+	require.NoError(t, decrypt(encrypted.Bytes(), key))
+
+	// A modified chunk fails its authentication
+	tampered := append([]byte{}, encrypted.Bytes()...)
+	tampered[sealedChunkSize+10] ^= 0xff
+	assert.EqualError(t, decrypt(tampered, key), "chunk 1 of encrypted blockfile failed its authentication")
+
+	// A content truncated at a chunk boundary ends with a chunk which is not the last one
+	assert.EqualError(t, decrypt(encrypted.Bytes()[:sealedChunkSize], key), "chunk 0 of encrypted blockfile failed its authentication")
+	assert.EqualError(t, decrypt(nil, key), "chunk 0 of encrypted blockfile failed its authentication")
+
+	// Another key fails the authentication
+	assert.Error(t, decrypt(encrypted.Bytes(), bytes.Repeat([]byte{2}, EncryptionKeySize)))
+
+	// So does another header
+	decrypter, err := NewDecrypter(bytes.NewReader(encrypted.Bytes()), key, nonce, []byte("other header"))
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(decrypter)
+	assert.EqualError(t, err, "chunk 0 of encrypted blockfile failed its authentication")
+
+	_, err = NewEncrypter(&bytes.Buffer{}, key[:10], nonce, nil)
+	assert.Contains(t, err.Error(), "invalid encryption key")
+	_, err = NewDecrypter(&bytes.Buffer{}, key, nonce[:4], nil)
+	assert.EqualError(t, err, "invalid nonce of 4 bytes, expected 8")
+}
+
+func TestEncryptedBlockfileReader(t *testing.T) {
+	defer setTestEncryptionKeys(t, "key1", "key2")()
+	blockfile := bytes.Repeat([]byte("\x0ablock content"), 10000)
+	for _, algorithm := range []string{"", CompressionGzip, CompressionSnappy} {
+		object := &bytes.Buffer{}
+		header := &ObjectHeader{Compression: algorithm, Size: int64(len(blockfile)), Checksum: "sha256:00"}
+		w, err := NewBlockfileWriter(object, header, 0, "key2")
+		require.NoError(t, err)
+		_, err = w.Write(blockfile)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Equal(t, EncryptionAES256GCM, header.Encryption)
+		assert.Equal(t, "key2", header.KeyID)
+		assert.Len(t, header.Nonce, encryptionNoncePrefixSize)
+
+		content, read, err := NewBlockfileReader(bytes.NewReader(object.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, header, read)
+		decoded, err := ioutil.ReadAll(content)
+		require.NoError(t, err)
+		assert.Equal(t, blockfile, decoded)
+		require.NoError(t, content.Close())
+
+		// The header is authenticated, a modified checksum fails the decryption
+		tampered := bytes.Replace(object.Bytes(), []byte(`"sha256:00"`), []byte(`"sha256:11"`), 1)
+		content, _, err = NewBlockfileReader(bytes.NewReader(tampered))
+		if err == nil {
+			_, err = ioutil.ReadAll(content)
+			content.Close()
+		}
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "chunk 0 of encrypted blockfile failed its authentication")
+	}
+
+	// The blockfile is not encrypted without a key
+	object := &bytes.Buffer{}
+	header := &ObjectHeader{Compression: CompressionGzip, Size: int64(len(blockfile))}
+	w, err := NewBlockfileWriter(object, header, 0, "")
+	require.NoError(t, err)
+	_, err = w.Write(blockfile)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Empty(t, header.Encryption)
+	assert.Empty(t, header.KeyID)
+	_, err = NewBlockfileWriter(&bytes.Buffer{}, &ObjectHeader{}, 0, "key3")
+	assert.EqualError(t, err, "encryption key key3 is not in the keyring")
+}
+
+func TestDecodingWriterWithoutKey(t *testing.T) {
+	restore := setTestEncryptionKeys(t, "key1")
+	blockfile := bytes.Repeat([]byte("\x0ablock content"), 10000)
+	object := &bytes.Buffer{}
+	w, err := NewBlockfileWriter(object, &ObjectHeader{Size: int64(len(blockfile))}, 0, "key1")
+	require.NoError(t, err)
+	_, err = w.Write(blockfile)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	restore()
+
+	// The object is consumed by the copy, and the missing key is reported once the writer is closed
+	_, _, err = NewBlockfileReader(bytes.NewReader(object.Bytes()))
+	assert.True(t, IsEncryptionKeyMissing(err))
+	copied := &bytes.Buffer{}
+	decoder := NewDecodingWriter(ioutil.Discard)
+	_, err = copied.ReadFrom(io.TeeReader(bytes.NewReader(object.Bytes()), decoder))
+	require.NoError(t, err)
+	err = decoder.Close()
+	assert.True(t, IsEncryptionKeyMissing(err))
+	assert.Equal(t, object.Bytes(), copied.Bytes())
+}
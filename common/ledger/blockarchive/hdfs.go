@@ -0,0 +1,27 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"net"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+)
+
+// HDFS is the HDFS cluster to which the blockfiles of the channels of RepositoryTypeHDFS are archived through its
+// WebHDFS REST API, under its base path. Nil if no cluster is configured.
+var HDFS *webhdfs.Config
+
+// HDFSClientConfig returns the configuration of the clients of the HDFS cluster, whose connections are established
+// with the TLS settings of the repository client transport and through RepositoryProxyURL like the ones to the
+// repository
+func HDFSClientConfig() *webhdfs.Config {
+	config := *HDFS
+	config.TLSConfig = RepositoryTLSConfig
+	config.Dial = func(_, address string) (net.Conn, error) {
+		return dialRepositoryAddress(address)
+	}
+	return &config
+}
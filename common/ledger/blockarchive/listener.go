@@ -0,0 +1,20 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+)
+
+// DiscardListener is notified each time the local copy of an archived blockfile
+// has been discarded, so that the resources bound to the discarded blocks can be reclaimed.
+// The notification is delivered synchronously, so that implementations should not block.
+type DiscardListener interface {
+	HandleBlockfileDiscarded(info *archive.ArchivedBlockfileInfo)
+}
+
+// PeerDiscardListener is notified of the blockfiles discarded by the ledgers of all the channels of
+// the peer, after the listeners of the ledger. The peer sets it to advertise the blocks it still has locally.
+var PeerDiscardListener DiscardListener
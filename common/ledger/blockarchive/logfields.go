@@ -0,0 +1,31 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"fmt"
+	"time"
+)
+
+// The keys of the structured fields of the archiver logs, so that the log pipelines can filter
+// the entries of a channel or a blockfile and alert on the sizes and durations of the transfers
+const (
+	LogKeyChannel    = "channel"
+	LogKeyBlockfile  = "blockfile"
+	LogKeyBlockRange = "blockRange"
+	LogKeyRepository = "repository"
+	LogKeyBytes      = "bytes"
+	LogKeyDurationMs = "durationMs"
+)
+
+// LogBlockRange formats a range of blocks for the blockRange log field
+func LogBlockRange(first, last uint64) string {
+	return fmt.Sprintf("%d-%d", first, last)
+}
+
+// LogDurationMs returns the number of milliseconds elapsed since start for the durationMs log field
+func LogDurationMs(start time.Time) int64 {
+	return int64(time.Since(start) / time.Millisecond)
+}
@@ -0,0 +1,119 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// ManifestSuffix is appended to the path of an archived blockfile
+// to derive the path of its manifest
+const ManifestSuffix = ".manifest"
+
+// Signer signs the archive manifests with the identity of the archiver peer
+type Signer interface {
+	// Sign signs the message
+	Sign(message []byte) ([]byte, error)
+	// Serialize returns the serialized identity of the signer
+	Serialize() ([]byte, error)
+}
+
+// ManifestSigner is the signer of the archive manifests.
+// No manifest is produced when it is nil.
+var ManifestSigner Signer
+
+// VerifyBlockfileSignatures indicates whether the archived blockfiles read from the repository are verified
+// against their manifest, which must have been signed by a member of the MSP of ManifestVerifier
+var VerifyBlockfileSignatures bool
+
+// ManifestVerifier deserializes the identities which sign the manifests of the archived blockfiles read
+// from the repository, usually the local MSP, as the blockfiles are archived by the organization
+var ManifestVerifier msp.IdentityDeserializer
+
+// SignManifest marshals and signs the manifest with the signer
+func SignManifest(manifest *archive.ArchiveManifest, signer Signer) (*archive.SignedArchiveManifest, error) {
+	manifestBytes, err := proto.Marshal(manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling archive manifest")
+	}
+	signature, err := signer.Sign(manifestBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing archive manifest")
+	}
+	creator, err := signer.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing the identity of the signer")
+	}
+	return &archive.SignedArchiveManifest{
+		Manifest:  manifestBytes,
+		Signature: signature,
+		Creator:   creator,
+	}, nil
+}
+
+// VerifyManifest checks that the manifest has been signed by a valid identity
+// of the MSP of the deserializer, and returns the manifest and the signer identity
+func VerifyManifest(signed *archive.SignedArchiveManifest, deserializer msp.IdentityDeserializer) (*archive.ArchiveManifest, msp.Identity, error) {
+	identity, err := deserializer.DeserializeIdentity(signed.Creator)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to deserialize the signer of the archive manifest")
+	}
+	if err := identity.Validate(); err != nil {
+		return nil, nil, errors.WithMessage(err, "the signer of the archive manifest is not valid")
+	}
+	if err := identity.Verify(signed.Manifest, signed.Signature); err != nil {
+		return nil, nil, errors.WithMessage(err, "the signature of the archive manifest is not valid")
+	}
+	manifest := &archive.ArchiveManifest{}
+	if err := proto.Unmarshal(signed.Manifest, manifest); err != nil {
+		return nil, nil, errors.Wrap(err, "error unmarshaling archive manifest")
+	}
+	return manifest, identity, nil
+}
+
+// VerifyBlockfileAgainstManifest checks that the content of the blockfile matches the hash in the manifest
+func VerifyBlockfileAgainstManifest(manifest *archive.ArchiveManifest, blockfilePath string) error {
+	hash, err := ComputeBlockfileHash(blockfilePath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, manifest.BlockfileHash) {
+		return errors.Errorf("hash of blockfile %s [%x] does not match the one in the manifest [%x]",
+			blockfilePath, hash, manifest.BlockfileHash)
+	}
+	return nil
+}
+
+// ComputeBlockfileHash returns the SHA-256 hash of the whole content of the blockfile
+func ComputeBlockfileHash(blockfilePath string) ([]byte, error) {
+	file, err := os.Open(blockfilePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening blockfile %s", blockfilePath)
+	}
+	defer file.Close()
+
+	hash, err := ComputeHash(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading blockfile %s", blockfilePath)
+	}
+	return hash, nil
+}
+
+// ComputeHash returns the SHA-256 hash of the whole content of the reader
+func ComputeHash(reader io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
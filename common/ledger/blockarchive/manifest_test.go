@@ -0,0 +1,72 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyManifest(t *testing.T) {
+	require.NoError(t, msptesttools.LoadMSPSetupForTesting())
+	signer := mgmt.GetLocalSigningIdentityOrPanic()
+
+	manifest := &archive.ArchiveManifest{
+		ChannelID:     "testchannel",
+		BlockfileNo:   1,
+		FirstBlockNum: 10,
+		LastBlockNum:  19,
+		BlockfileHash: []byte("hash"),
+		Timestamp:     ptypes.TimestampNow(),
+	}
+	signed, err := SignManifest(manifest, signer)
+	require.NoError(t, err)
+
+	verified, identity, err := VerifyManifest(signed, mgmt.GetLocalMSP())
+	require.NoError(t, err)
+	assert.Equal(t, manifest.ChannelID, verified.ChannelID)
+	assert.Equal(t, manifest.LastBlockNum, verified.LastBlockNum)
+	assert.Equal(t, signer.GetMSPIdentifier(), identity.GetMSPIdentifier())
+
+	// A tampered manifest is rejected
+	signed.Manifest[len(signed.Manifest)-1] ^= 0xff
+	_, _, err = VerifyManifest(signed, mgmt.GetLocalMSP())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "signature of the archive manifest is not valid")
+
+	// A manifest signed by an unknown identity is rejected
+	signed.Creator = []byte("unknown")
+	_, _, err = VerifyManifest(signed, mgmt.GetLocalMSP())
+	assert.Error(t, err)
+}
+
+func TestVerifyBlockfileAgainstManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blockarchive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := []byte("blockfile content")
+	blockfilePath := filepath.Join(dir, "blockfile_000000")
+	require.NoError(t, ioutil.WriteFile(blockfilePath, content, 0644))
+
+	hash := sha256.Sum256(content)
+	manifest := &archive.ArchiveManifest{BlockfileHash: hash[:]}
+	assert.NoError(t, VerifyBlockfileAgainstManifest(manifest, blockfilePath))
+
+	manifest.BlockfileHash = []byte("wrong hash")
+	assert.Error(t, VerifyBlockfileAgainstManifest(manifest, blockfilePath))
+
+	assert.Error(t, VerifyBlockfileAgainstManifest(manifest, filepath.Join(dir, "missing")))
+}
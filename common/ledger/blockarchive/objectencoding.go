@@ -0,0 +1,308 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// objectMagic starts the archived objects whose blockfile is encoded, e.g. compressed, rather than stored as is.
+// A blockfile starts with the varint length of its first block, which is never 0, so that a blockfile stored
+// as is is never taken for an encoded object.
+var objectMagic = []byte{0, 'F', 'B', 'A'}
+
+// maxObjectHeaderSize bounds the size of the header read from an archived object
+const maxObjectHeaderSize = 64 * 1024
+
+// ObjectHeader describes how the blockfile of an archived object is encoded. It precedes the encoded blockfile
+// on the repository, so that the object is decoded by any reader, without the catalog of its channel. The header
+// of an encrypted object is not encrypted, but it is authenticated along with each encrypted chunk.
+type ObjectHeader struct {
+	// Compression is the algorithm the blockfile is compressed with
+	Compression string `json:"compression,omitempty"`
+	// Size is the number of bytes of the blockfile
+	Size int64 `json:"size"`
+	// Checksum is the checksum of the blockfile, against which it is verified once decoded
+	Checksum string `json:"checksum"`
+	// Encryption is the algorithm the blockfile is encrypted with, once compressed
+	Encryption string `json:"encryption,omitempty"`
+	// KeyID is the ID of the key the blockfile is encrypted with
+	KeyID string `json:"keyId,omitempty"`
+	// Nonce is the random prefix of the nonces of the encrypted chunks
+	Nonce []byte `json:"nonce,omitempty"`
+
+	// raw is the header as written on the repository, once written or read
+	raw []byte
+}
+
+// WriteObjectHeader writes the header of an encoded archived object
+func WriteObjectHeader(w io.Writer, header *ObjectHeader) error {
+	content, err := json.Marshal(header)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling archived object header")
+	}
+	b := make([]byte, len(objectMagic)+4, len(objectMagic)+4+len(content))
+	copy(b, objectMagic)
+	binary.BigEndian.PutUint32(b[len(objectMagic):], uint32(len(content)))
+	if _, err := w.Write(append(b, content...)); err != nil {
+		return err
+	}
+	header.raw = content
+	return nil
+}
+
+// ReadObjectHeader reads the header of an archived object, nil if the object is a blockfile stored as is.
+// The reader is then positioned at the start of the encoded blockfile, or of the blockfile stored as is.
+func ReadObjectHeader(r *bufio.Reader) (*ObjectHeader, error) {
+	magic, err := r.Peek(len(objectMagic))
+	if err == io.EOF || (err == nil && !bytes.Equal(magic, objectMagic)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading archived object header")
+	}
+	prefix := make([]byte, len(objectMagic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, errors.Wrap(err, "error reading archived object header")
+	}
+	size := binary.BigEndian.Uint32(prefix[len(objectMagic):])
+	if size > maxObjectHeaderSize {
+		return nil, errors.Errorf("archived object header of %d bytes exceeds %d bytes", size, maxObjectHeaderSize)
+	}
+	content := make([]byte, size)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, errors.Wrap(err, "error reading archived object header")
+	}
+	header := &ObjectHeader{}
+	if err := json.Unmarshal(content, header); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling archived object header")
+	}
+	header.raw = content
+	return header, nil
+}
+
+// NewBlockfileReader returns a reader of the blockfile of an archived object, decoding it if the object is encoded,
+// along with the header of the object, nil if the blockfile is stored as is. An encrypted blockfile is decrypted
+// with the key of EncryptionKeys named by its header. The decoding takes a buffer of RetrievalBuffers, waiting while
+// MaxBufferedRetrievals are in use, which returns to the pool once the reader is closed.
+func NewBlockfileReader(r io.Reader) (io.ReadCloser, *ObjectHeader, error) {
+	buffered := bufio.NewReader(r)
+	header, err := ReadObjectHeader(buffered)
+	if err != nil || header == nil {
+		return ioutil.NopCloser(buffered), nil, err
+	}
+	// The key is looked up first, so that an object which cannot be decrypted doesn't wait for a buffer
+	key, err := decryptionKeyOf(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	buffers := RetrievalBuffers()
+	decoded := &decodedBlockfile{buffers: buffers, buf: buffers.Get()}
+	// The encrypted chunks are read into the start of the buffer, and the blockfile is copied through the rest
+	decoded.content, decoded.copyBuf = buffered, decoded.buf
+	if key != nil {
+		sealed := decoded.buf[:encryptionChunkSize+encryptionOverhead]
+		if decoded.content, err = newDecrypter(buffered, key, header.Nonce, header.raw, sealed); err != nil {
+			decoded.Close()
+			return nil, nil, err
+		}
+		decoded.copyBuf = decoded.buf[len(sealed):]
+	}
+	if header.Compression == "" {
+		return decoded, header, nil
+	}
+	decompressor, err := NewDecompressor(decoded.content, header.Compression)
+	if err != nil {
+		decoded.Close()
+		return nil, nil, err
+	}
+	decoded.content, decoded.decompressor = decompressor, decompressor
+	return decoded, header, nil
+}
+
+// decodedBlockfile reads the blockfile of an encoded object, decoded with a buffer of the retrieval pool
+type decodedBlockfile struct {
+	content      io.Reader
+	decompressor io.Closer
+	buffers      *BufferPool
+	buf          []byte
+	copyBuf      []byte
+}
+
+func (d *decodedBlockfile) Read(p []byte) (int, error) {
+	return d.content.Read(p)
+}
+
+// WriteTo copies the blockfile through the part of the pooled buffer the decryption leaves, so that io.Copy
+// doesn't allocate a buffer of its own
+func (d *decodedBlockfile) WriteTo(w io.Writer) (int64, error) {
+	return io.CopyBuffer(w, struct{ io.Reader }{d.content}, d.copyBuf)
+}
+
+// Close closes the decompressor and returns the buffer to the pool
+func (d *decodedBlockfile) Close() error {
+	var err error
+	if d.decompressor != nil {
+		err = d.decompressor.Close()
+	}
+	if d.buf != nil {
+		d.buffers.Put(d.buf)
+		d.buf, d.copyBuf = nil, nil
+	}
+	return err
+}
+
+// NewDecrypterOf returns a reader of the content of an encoded object read from r, after its header, decrypted
+// if the header tells that it is encrypted. The header must have been read by ReadObjectHeader, as the chunks fail
+// their authentication if it has been modified. The content is still compressed if the blockfile is.
+func NewDecrypterOf(r io.Reader, header *ObjectHeader) (io.Reader, error) {
+	key, err := decryptionKeyOf(header)
+	if err != nil || key == nil {
+		return r, err
+	}
+	return NewDecrypter(r, key, header.Nonce, header.raw)
+}
+
+// decryptionKeyOf returns the key of EncryptionKeys the content of an encoded object is encrypted with, nil if
+// the header tells that it is not encrypted
+func decryptionKeyOf(header *ObjectHeader) ([]byte, error) {
+	switch header.Encryption {
+	case "":
+		return nil, nil
+	case EncryptionAES256GCM:
+		return EncryptionKeys.Key(header.KeyID)
+	}
+	return nil, errors.Errorf("unsupported encryption algorithm %s", header.Encryption)
+}
+
+// NewEncrypterOf writes the header of an encoded object to w, encrypting the content of the object with the key
+// of EncryptionKeys keyID if it is not empty, with a new nonce recorded in the header, and returns the writer of
+// the content, compressed or not, which is complete once the writer is closed
+func NewEncrypterOf(w io.Writer, header *ObjectHeader, keyID string) (io.WriteCloser, error) {
+	var key []byte
+	header.Encryption, header.KeyID, header.Nonce = "", "", nil
+	if keyID != "" {
+		var err error
+		if key, err = EncryptionKeys.Key(keyID); err != nil {
+			return nil, err
+		}
+		if header.Nonce, err = NewNoncePrefix(); err != nil {
+			return nil, err
+		}
+		header.Encryption, header.KeyID = EncryptionAES256GCM, keyID
+	}
+	if err := WriteObjectHeader(w, header); err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return NewEncrypter(w, key, header.Nonce, header.raw)
+}
+
+// NewBlockfileWriter writes the header of an encoded object to w and returns the writer encoding the blockfile
+// written to it: compressed with the algorithm of the header, if any, at the level, then encrypted with the key
+// keyID, if not empty. The object is complete once the writer is closed.
+func NewBlockfileWriter(w io.Writer, header *ObjectHeader, level int, keyID string) (io.WriteCloser, error) {
+	encrypter, err := NewEncrypterOf(w, header, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if header.Compression == "" {
+		return encrypter, nil
+	}
+	compressor, err := NewCompressor(encrypter, header.Compression, level)
+	if err != nil {
+		return nil, err
+	}
+	return &chainedWriteCloser{WriteCloser: compressor, next: encrypter}, nil
+}
+
+// nopWriteCloser is a writer with a Close doing nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// chainedWriteCloser closes the writer it writes into once it is closed itself
+type chainedWriteCloser struct {
+	io.WriteCloser
+	next io.WriteCloser
+}
+
+func (c *chainedWriteCloser) Close() error {
+	err := c.WriteCloser.Close()
+	if closeErr := c.next.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ComputeBlockfileContentChecksum returns the checksum of the blockfile of an archived object,
+// decoding it if the object is encoded
+func ComputeBlockfileContentChecksum(r io.Reader, algorithm string) (*Checksum, error) {
+	content, _, err := NewBlockfileReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return ComputeChecksum(content, algorithm)
+}
+
+// decodingWriter decodes the archived object written to it into a writer
+type decodingWriter struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+// NewDecodingWriter returns a writer decoding the archived object written to it, if it is encoded, and writing
+// its blockfile to w. The blockfile is fully written once the writer is closed, which returns the decoding errors.
+// An object encrypted with a key which is not in the keyring is consumed without being decoded, and the writer
+// returns a MissingEncryptionKeyError once closed.
+func NewDecodingWriter(w io.Writer) io.WriteCloser {
+	pr, pw := io.Pipe()
+	d := &decodingWriter{pipe: pw, done: make(chan error, 1)}
+	go func() {
+		content, _, err := NewBlockfileReader(pr)
+		if err == nil {
+			_, err = io.Copy(w, content)
+			content.Close()
+		}
+		if err == nil {
+			// An encoded object must end with its blockfile
+			if n, _ := io.Copy(ioutil.Discard, pr); n > 0 {
+				err = errors.Errorf("%d trailing bytes after the encoded blockfile", n)
+			}
+		}
+		if IsEncryptionKeyMissing(err) {
+			// The object is consumed so that the copy it is written by goes on
+			io.Copy(ioutil.Discard, pr)
+		}
+		if err != nil {
+			pr.CloseWithError(err)
+		}
+		d.done <- err
+	}()
+	return d
+}
+
+func (d *decodingWriter) Write(p []byte) (int, error) {
+	return d.pipe.Write(p)
+}
+
+func (d *decodingWriter) Close() error {
+	d.pipe.Close()
+	return <-d.done
+}
@@ -0,0 +1,133 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeBlockfile returns the encoded object of a blockfile compressed with the algorithm
+func encodeBlockfile(t *testing.T, blockfile []byte, algorithm string) []byte {
+	checksum, err := ComputeChecksum(bytes.NewReader(blockfile), ChecksumSHA256)
+	require.NoError(t, err)
+	object := &bytes.Buffer{}
+	require.NoError(t, WriteObjectHeader(object, &ObjectHeader{Compression: algorithm, Size: int64(len(blockfile)), Checksum: checksum.String()}))
+	compressor, err := NewCompressor(object, algorithm, 0)
+	require.NoError(t, err)
+	_, err = compressor.Write(blockfile)
+	require.NoError(t, err)
+	require.NoError(t, compressor.Close())
+	return object.Bytes()
+}
+
+func TestObjectHeader(t *testing.T) {
+	header := &ObjectHeader{Compression: CompressionGzip, Size: 1024, Checksum: "sha256:00"}
+	object := &bytes.Buffer{}
+	require.NoError(t, WriteObjectHeader(object, header))
+	object.WriteString("content")
+	r := bufio.NewReader(object)
+	read, err := ReadObjectHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, header, read)
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(rest))
+
+	// A blockfile stored as is has no header, and is read from its beginning
+	for _, blockfile := range []string{"", "\x0ablock", "\x00FB"} {
+		r := bufio.NewReader(bytes.NewReader([]byte(blockfile)))
+		read, err := ReadObjectHeader(r)
+		require.NoError(t, err)
+		assert.Nil(t, read)
+		rest, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, blockfile, string(rest))
+	}
+
+	// A truncated or invalid header is an error
+	encoded := &bytes.Buffer{}
+	require.NoError(t, WriteObjectHeader(encoded, header))
+	_, err = ReadObjectHeader(bufio.NewReader(bytes.NewReader(encoded.Bytes()[:10])))
+	assert.Contains(t, err.Error(), "error reading archived object header")
+	_, err = ReadObjectHeader(bufio.NewReader(bytes.NewReader(append([]byte{0, 'F', 'B', 'A', 0, 0, 0, 2}, "{["...))))
+	assert.Contains(t, err.Error(), "error unmarshaling archived object header")
+	_, err = ReadObjectHeader(bufio.NewReader(bytes.NewReader([]byte{0, 'F', 'B', 'A', 0, 1, 0, 1})))
+	assert.EqualError(t, err, "archived object header of 65537 bytes exceeds 65536 bytes")
+}
+
+func TestNewBlockfileReader(t *testing.T) {
+	blockfile := bytes.Repeat([]byte("\x0ablock content"), 1000)
+	for _, algorithm := range []string{CompressionGzip, CompressionSnappy} {
+		object := encodeBlockfile(t, blockfile, algorithm)
+		assert.True(t, len(object) < len(blockfile))
+		inUse := len(RetrievalBuffers().slots)
+		content, header, err := NewBlockfileReader(bytes.NewReader(object))
+		require.NoError(t, err)
+		assert.Equal(t, algorithm, header.Compression)
+		assert.Equal(t, int64(len(blockfile)), header.Size)
+		decoded := &bytes.Buffer{}
+		_, err = io.Copy(decoded, content)
+		require.NoError(t, err)
+		assert.Equal(t, blockfile, decoded.Bytes())
+		// The decoding holds a retrieval buffer until the reader is closed
+		assert.Equal(t, inUse+1, len(RetrievalBuffers().slots))
+		require.NoError(t, content.Close())
+		assert.Equal(t, inUse, len(RetrievalBuffers().slots))
+
+		checksum, err := ComputeBlockfileContentChecksum(bytes.NewReader(object), ChecksumSHA256)
+		require.NoError(t, err)
+		assert.Equal(t, header.Checksum, checksum.String())
+	}
+
+	// A blockfile stored as is is read as is
+	content, header, err := NewBlockfileReader(bytes.NewReader(blockfile))
+	require.NoError(t, err)
+	assert.Nil(t, header)
+	decoded, err := ioutil.ReadAll(content)
+	require.NoError(t, err)
+	assert.Equal(t, blockfile, decoded)
+
+	// An object encoded with an unknown algorithm cannot be read
+	object := &bytes.Buffer{}
+	require.NoError(t, WriteObjectHeader(object, &ObjectHeader{Compression: "zstd"}))
+	_, _, err = NewBlockfileReader(object)
+	assert.EqualError(t, err, "unsupported compression algorithm zstd")
+}
+
+func TestDecodingWriter(t *testing.T) {
+	blockfile := bytes.Repeat([]byte("\x0ablock content"), 1000)
+	for _, object := range [][]byte{blockfile, encodeBlockfile(t, blockfile, CompressionGzip)} {
+		decoded := &bytes.Buffer{}
+		w := NewDecodingWriter(decoded)
+		// The object is written in small chunks, like a transfer
+		for i := 0; i < len(object); i += 100 {
+			end := i + 100
+			if end > len(object) {
+				end = len(object)
+			}
+			_, err := w.Write(object[i:end])
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		assert.Equal(t, blockfile, decoded.Bytes())
+	}
+
+	// A corrupted encoded blockfile fails the decoding
+	object := encodeBlockfile(t, blockfile, CompressionGzip)
+	object[len(object)-10] ^= 0xff
+	w := NewDecodingWriter(ioutil.Discard)
+	_, err := w.Write(object)
+	if err == nil {
+		err = w.Close()
+	}
+	assert.Error(t, err)
+}
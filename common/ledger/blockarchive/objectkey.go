@@ -0,0 +1,119 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// ObjectKeyParams are the attributes of an archived blockfile which can be
+// referred to from an object key template
+type ObjectKeyParams struct {
+	NetworkID     string
+	Environment   string
+	ChannelID     string
+	BlockfileNo   uint64
+	FirstBlockNum uint64
+	LastBlockNum  uint64
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ExpandObjectKey builds the key of an archived blockfile on the repository from a template like
+// "{networkId}/{channel}/{firstBlock}-{lastBlock}.blk". The supported placeholders are
+// {networkId}, {environment}, {channel}, {blockfileNo}, {firstBlock} and {lastBlock}.
+func ExpandObjectKey(template string, params *ObjectKeyParams) (string, error) {
+	var unknown []string
+	key := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch placeholder {
+		case "{networkId}":
+			return params.NetworkID
+		case "{environment}":
+			return params.Environment
+		case "{channel}":
+			return params.ChannelID
+		case "{blockfileNo}":
+			return strconv.FormatUint(params.BlockfileNo, 10)
+		case "{firstBlock}":
+			return strconv.FormatUint(params.FirstBlockNum, 10)
+		case "{lastBlock}":
+			return strconv.FormatUint(params.LastBlockNum, 10)
+		}
+		unknown = append(unknown, placeholder)
+		return placeholder
+	})
+	if len(unknown) > 0 {
+		return "", errors.Errorf("unknown placeholder(s) %s in object key template [%s]", strings.Join(unknown, ", "), template)
+	}
+
+	relativeKey := key
+	if strings.HasPrefix(template, "/") {
+		relativeKey = key[1:]
+	}
+	for _, element := range strings.Split(relativeKey, "/") {
+		if element == "" {
+			return "", errors.Errorf("object key [%s] built from template [%s] has an empty element", key, template)
+		}
+	}
+	key = path.Clean("/" + key)
+	if key == "/" {
+		return "", errors.Errorf("object key template [%s] does not name a file", template)
+	}
+	return key[1:], nil
+}
+
+// ValidateObjectKeyTemplate checks that a template can be expanded into the keys of archived blockfiles
+func ValidateObjectKeyTemplate(template string) error {
+	_, err := ExpandObjectKey(template, &ObjectKeyParams{NetworkID: "n", Environment: "e", ChannelID: "c"})
+	return err
+}
+
+// RepositoryNamespace returns the directory, relative to BlockArchiverDir, under which this peer archives
+// the blockfiles: <NetworkID>/<Environment> when NamespaceByNetwork is set, the environment being optional,
+// and the root of BlockArchiverDir otherwise
+func RepositoryNamespace() string {
+	if !NamespaceByNetwork {
+		return ""
+	}
+	return path.Join(NetworkID, Environment)
+}
+
+// ArchiveRootDir returns the directory of the repository under which this peer archives the blockfiles
+func ArchiveRootDir() string {
+	return filepath.Join(BlockArchiverDir, RepositoryNamespace())
+}
+
+// ValidateNamespace checks that the network is named when the blockfiles are archived under its namespace
+func ValidateNamespace() error {
+	if !NamespaceByNetwork {
+		return nil
+	}
+	if NetworkID == "" {
+		return errors.New("the network ID must be set to archive the blockfiles under the namespace of the network")
+	}
+	for _, name := range []string{NetworkID, Environment} {
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return errors.Errorf("invalid namespace element [%s], it must be a single path element", name)
+		}
+	}
+	return nil
+}
+
+// CheckNetwork returns an error if an archived blockfile has been recorded by a peer of another network or
+// environment than this peer. The records predating the network attributes belong to any network.
+func CheckNetwork(info *archive.ArchivedBlockfileInfo) error {
+	if info.NetworkID == "" || (info.NetworkID == NetworkID && info.Environment == Environment) {
+		return nil
+	}
+	return errors.Errorf("blockfile [%d] of channel [%s] has been archived by network [%s] environment [%s], this peer belongs to network [%s] environment [%s]",
+		info.BlockfileNo, info.ChannelID, info.NetworkID, info.Environment, NetworkID, Environment)
+}
@@ -0,0 +1,96 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandObjectKey(t *testing.T) {
+	params := &ObjectKeyParams{
+		NetworkID:     "prod",
+		Environment:   "eu",
+		ChannelID:     "mychannel",
+		BlockfileNo:   3,
+		FirstBlockNum: 1200,
+		LastBlockNum:  1799,
+	}
+
+	key, err := ExpandObjectKey("{networkId}/{channel}/{firstBlock}-{lastBlock}.blk", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod/mychannel/1200-1799.blk", key)
+
+	key, err = ExpandObjectKey("{environment}/{channel}/{blockfileNo}.blk", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu/mychannel/3.blk", key)
+
+	key, err = ExpandObjectKey("/archive/{channel}/blockfile_{blockfileNo}", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "archive/mychannel/blockfile_3", key)
+
+	// The key never escapes the directory of the repository
+	key, err = ExpandObjectKey("../../{channel}.blk", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "mychannel.blk", key)
+
+	_, err = ExpandObjectKey("{channel}/{unknown}.blk", params)
+	assert.EqualError(t, err, "unknown placeholder(s) {unknown} in object key template [{channel}/{unknown}.blk]")
+
+	_, err = ExpandObjectKey("{channel}/", params)
+	assert.Error(t, err)
+
+	_, err = ExpandObjectKey("{networkId}/{channel}.blk", &ObjectKeyParams{ChannelID: "mychannel"})
+	assert.Error(t, err)
+}
+
+func TestValidateObjectKeyTemplate(t *testing.T) {
+	assert.NoError(t, ValidateObjectKeyTemplate("{networkId}/{channel}/{firstBlock}-{lastBlock}.blk"))
+	assert.Error(t, ValidateObjectKeyTemplate("{channel}/{first}.blk"))
+	assert.Error(t, ValidateObjectKeyTemplate(""))
+}
+
+func TestRepositoryNamespace(t *testing.T) {
+	defer func(dir, network, environment string, byNetwork bool) {
+		BlockArchiverDir, NetworkID, Environment, NamespaceByNetwork = dir, network, environment, byNetwork
+	}(BlockArchiverDir, NetworkID, Environment, NamespaceByNetwork)
+	BlockArchiverDir, NetworkID, Environment = "/archive", "prod", ""
+
+	NamespaceByNetwork = false
+	assert.Equal(t, "", RepositoryNamespace())
+	assert.Equal(t, "/archive", ArchiveRootDir())
+	assert.NoError(t, ValidateNamespace())
+
+	NamespaceByNetwork = true
+	assert.Equal(t, "prod", RepositoryNamespace())
+	assert.Equal(t, filepath.Join("/archive", "prod"), ArchiveRootDir())
+	assert.NoError(t, ValidateNamespace())
+
+	Environment = "eu"
+	assert.Equal(t, "prod/eu", RepositoryNamespace())
+	assert.Equal(t, filepath.Join("/archive", "prod", "eu"), ArchiveRootDir())
+	assert.NoError(t, ValidateNamespace())
+
+	Environment = "../eu"
+	assert.Error(t, ValidateNamespace())
+	NetworkID, Environment = "", ""
+	assert.EqualError(t, ValidateNamespace(), "the network ID must be set to archive the blockfiles under the namespace of the network")
+}
+
+func TestCheckNetwork(t *testing.T) {
+	defer func(network, environment string) {
+		NetworkID, Environment = network, environment
+	}(NetworkID, Environment)
+	NetworkID, Environment = "prod", "eu"
+
+	assert.NoError(t, CheckNetwork(&archive.ArchivedBlockfileInfo{ChannelID: "mychannel"}))
+	assert.NoError(t, CheckNetwork(&archive.ArchivedBlockfileInfo{ChannelID: "mychannel", NetworkID: "prod", Environment: "eu"}))
+	assert.EqualError(t, CheckNetwork(&archive.ArchivedBlockfileInfo{ChannelID: "mychannel", BlockfileNo: 2, NetworkID: "test", Environment: "eu"}),
+		"blockfile [2] of channel [mychannel] has been archived by network [test] environment [eu], this peer belongs to network [prod] environment [eu]")
+	assert.Error(t, CheckNetwork(&archive.ArchivedBlockfileInfo{ChannelID: "mychannel", NetworkID: "prod"}))
+}
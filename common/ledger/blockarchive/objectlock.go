@@ -0,0 +1,69 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ObjectLockSuffix is appended to the path of an archived blockfile to derive the path of the record
+// of its object lock, which the repository writes once the blockfile is stored immutably
+const ObjectLockSuffix = ".lock"
+
+const (
+	// ObjectLockGovernance is the mode of the locks which the administrator of the repository can remove
+	ObjectLockGovernance = "governance"
+	// ObjectLockCompliance is the mode of the locks which nobody can remove or shorten
+	ObjectLockCompliance = "compliance"
+)
+
+// ObjectLock is the record of the lock of an archived blockfile: until RetainUntil, the repository
+// neither deletes nor overwrites the blockfile, its checksum, its manifest and its summary
+type ObjectLock struct {
+	Mode        string    `json:"mode"`
+	RetainUntil time.Time `json:"retainUntil"`
+	LockedAt    time.Time `json:"lockedAt"`
+	// Size of the blockfile when it was locked
+	Size int64 `json:"size"`
+}
+
+// ObjectLockRequired indicates whether the local copy of an archived blockfile is discarded only
+// once the repository has locked the blockfile for at least ObjectLockMinRetention
+var ObjectLockRequired bool
+
+// ObjectLockMinRetention is the least time an archived blockfile must remain locked on the repository
+// for its local copy to be discarded
+var ObjectLockMinRetention time.Duration
+
+// ParseObjectLock parses the record of an object lock
+func ParseObjectLock(b []byte) (*ObjectLock, error) {
+	lock := &ObjectLock{}
+	if err := json.Unmarshal(b, lock); err != nil {
+		return nil, errors.Wrap(err, "error parsing the object lock")
+	}
+	return lock, nil
+}
+
+// IsActive tells if the lock still retains the blockfile at the time
+func (l *ObjectLock) IsActive(now time.Time) bool {
+	return now.Before(l.RetainUntil)
+}
+
+// Verify checks that the lock retains a blockfile of the size for at least minRetention from now
+func (l *ObjectLock) Verify(size int64, minRetention time.Duration, now time.Time) error {
+	if l.Size != size {
+		return errors.Errorf("the locked object holds %d bytes, the blockfile %d", l.Size, size)
+	}
+	if !l.IsActive(now) {
+		return errors.Errorf("the object lock expired at %s", l.RetainUntil.Format(time.RFC3339))
+	}
+	if l.RetainUntil.Before(now.Add(minRetention)) {
+		return errors.Errorf("the object is locked until %s, less than %s from now", l.RetainUntil.Format(time.RFC3339), minRetention)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectLock(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	lock, err := ParseObjectLock([]byte(`{"mode":"compliance","retainUntil":"2019-01-31T00:00:00Z","size":100}`))
+	require.NoError(t, err)
+	assert.Equal(t, ObjectLockCompliance, lock.Mode)
+	assert.True(t, lock.IsActive(now))
+
+	assert.NoError(t, lock.Verify(100, 0, now))
+	assert.NoError(t, lock.Verify(100, 30*24*time.Hour, now))
+	assert.EqualError(t, lock.Verify(100, 31*24*time.Hour, now),
+		"the object is locked until 2019-01-31T00:00:00Z, less than 744h0m0s from now")
+	assert.EqualError(t, lock.Verify(99, 0, now), "the locked object holds 100 bytes, the blockfile 99")
+	assert.EqualError(t, lock.Verify(100, 0, now.Add(30*24*time.Hour)), "the object lock expired at 2019-01-31T00:00:00Z")
+
+	_, err = ParseObjectLock([]byte("garbage"))
+	assert.Error(t, err)
+}
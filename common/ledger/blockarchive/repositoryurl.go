@@ -0,0 +1,106 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultRepositoryPort is the port of the SFTP server of a repository whose URL has none
+const defaultRepositoryPort = "22"
+
+// srvScheme is the scheme of the URLs of the repositories whose SFTP servers are discovered through
+// the DNS SRV records _sftp._tcp.<domain>
+const srvScheme = "sftp+srv"
+
+// lookupSRV resolves the DNS SRV records of a service, replaced by the tests
+var lookupSRV = net.LookupSRV
+
+// RepositoryEndpoint is the parsed URL of the repository, which is host:port, e.g. ledger-bank:222, or host
+// alone for the port 22, [ipv6]:port or a bare IPv6 literal, e.g. [fd00::10]:222 or fd00::10, sftp://host:port
+// or ssh://host:port, or sftp+srv://domain whose SFTP servers are listed by the SRV records _sftp._tcp.<domain>
+type RepositoryEndpoint struct {
+	// Host is the host name or the IP address of the SFTP server, without brackets,
+	// or the domain of its SRV records
+	Host string
+	// Port is the port of the SFTP server, empty for the SRV records
+	Port string
+	// SRV tells whether the SFTP servers are discovered through DNS SRV records
+	SRV bool
+}
+
+// ParseRepositoryURL parses the URL of the repository. The blockfiles are transferred over SFTP,
+// the URLs of the other transports are refused.
+func ParseRepositoryURL(rawURL string) (*RepositoryEndpoint, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return nil, errors.New("empty repository URL")
+	}
+	if !strings.Contains(rawURL, "://") {
+		return parseRepositoryAddress(rawURL, rawURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid repository URL %s", rawURL)
+	}
+	if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return nil, errors.Errorf("invalid repository URL %s, expected a host and a port only, the credentials and the directory are configured separately", rawURL)
+	}
+	switch scheme := strings.ToLower(u.Scheme); scheme {
+	case "sftp", "ssh":
+		return parseRepositoryAddress(rawURL, u.Host)
+	case srvScheme:
+		if u.Hostname() == "" || u.Port() != "" {
+			return nil, errors.Errorf("invalid repository URL %s, expected %s://<domain>", rawURL, srvScheme)
+		}
+		return &RepositoryEndpoint{Host: u.Hostname(), SRV: true}, nil
+	default:
+		return nil, errors.Errorf("unsupported repository URL %s, the blockfiles are transferred over sftp", rawURL)
+	}
+}
+
+// parseRepositoryAddress parses host:port, [ipv6]:port, host or a bare IPv6 literal
+func parseRepositoryAddress(rawURL, address string) (*RepositoryEndpoint, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// No port, the brackets of an IPv6 literal are optional
+		host, port = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"), defaultRepositoryPort
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+			return nil, errors.Errorf("invalid repository URL %s", rawURL)
+		}
+	}
+	if host == "" {
+		return nil, errors.Errorf("invalid repository URL %s, no host", rawURL)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return nil, errors.Errorf("invalid repository URL %s, invalid port [%s]", rawURL, port)
+	}
+	return &RepositoryEndpoint{Host: host, Port: port}, nil
+}
+
+// Addresses returns the addresses, host:port, of the SFTP servers of the repository in the order they are tried:
+// the SRV records by priority, randomized by weight within a priority, or the single address of the URL
+func (e *RepositoryEndpoint) Addresses() ([]string, error) {
+	if !e.SRV {
+		return []string{net.JoinHostPort(e.Host, e.Port)}, nil
+	}
+	_, records, err := lookupSRV("sftp", "tcp", e.Host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error looking up the SFTP servers of repository %s", e.Host)
+	}
+	if len(records) == 0 {
+		return nil, errors.Errorf("no SFTP server found for repository %s", e.Host)
+	}
+	var addresses []string
+	for _, record := range records {
+		addresses = append(addresses, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+	}
+	return addresses, nil
+}
@@ -0,0 +1,73 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The stages through which a discarded blockfile is retrieved once it is missing from the local block store
+const (
+	// RetrievalStageCache reads the blockfiles previously retrieved through the archiver peer, which a client
+	// peer keeps in its fetch cache
+	RetrievalStageCache = "cache"
+	// RetrievalStageArchiver retrieves the blockfile, or the byte range of a single block, through the archiver
+	// peer of the organization
+	RetrievalStageArchiver = "archiver"
+	// RetrievalStageRepository reads the archived blockfile directly from the repository
+	RetrievalStageRepository = "repository"
+)
+
+// RetrievalOrder is the order of the stages tried to retrieve a discarded blockfile, each stage falling back
+// to the next one when it fails or times out. The stages left out are never tried, e.g. a client peer without
+// the repository stage never accesses the repository directly. When it is empty, a client peer configured to
+// retrieve through the archiver peer tries its cache and then the archiver peer, and the other peers read
+// from the repository.
+var RetrievalOrder []string
+
+// RetrievalTimeouts are the times after which each stage of RetrievalOrder gives up and falls back to the
+// next one, by stage. A stage without a timeout waits for the end of the retrieval.
+var RetrievalTimeouts map[string]time.Duration
+
+// RetrievalRetries is the number of times a stage of RetrievalOrder reaching the archiver peer or the repository
+// is tried again after it failed or timed out, before falling back to the next stage
+var RetrievalRetries int
+
+// RetrievalRetryBackoff is the time waited before the first retry of a stage, doubled before each following one
+var RetrievalRetryBackoff time.Duration
+
+// RetrievalBreakerThreshold is the number of consecutive failures of the archiver peer or the repository after
+// which the circuit of its stage opens: the stage then fails immediately, without waiting for its timeout, until
+// RetrievalBreakerCooldown has elapsed. The circuit never opens when it is 0.
+var RetrievalBreakerThreshold int
+
+// RetrievalBreakerCooldown is the time the circuit of a stage stays open, after which a single retrieval is let
+// through to probe the service again
+var RetrievalBreakerCooldown time.Duration
+
+// ParseRetrievalOrder parses the names of the stages of a retrieval order, which must be stages
+// of the retrieval and appear only once
+func ParseRetrievalOrder(stages []string) ([]string, error) {
+	var order []string
+	for _, stage := range stages {
+		stage = strings.ToLower(strings.TrimSpace(stage))
+		switch stage {
+		case RetrievalStageCache, RetrievalStageArchiver, RetrievalStageRepository:
+		default:
+			return nil, errors.Errorf("unknown retrieval stage [%s], expected %s, %s or %s",
+				stage, RetrievalStageCache, RetrievalStageArchiver, RetrievalStageRepository)
+		}
+		for _, existing := range order {
+			if existing == stage {
+				return nil, errors.Errorf("retrieval stage [%s] appears more than once", stage)
+			}
+		}
+		order = append(order, stage)
+	}
+	return order, nil
+}
@@ -0,0 +1,27 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetrievalOrder(t *testing.T) {
+	order, err := ParseRetrievalOrder([]string{"Cache", " archiver "})
+	require.NoError(t, err)
+	assert.Equal(t, []string{RetrievalStageCache, RetrievalStageArchiver}, order)
+
+	order, err = ParseRetrievalOrder(nil)
+	require.NoError(t, err)
+	assert.Empty(t, order)
+
+	_, err = ParseRetrievalOrder([]string{"cache", "peer"})
+	assert.EqualError(t, err, "unknown retrieval stage [peer], expected cache, archiver or repository")
+	_, err = ParseRetrievalOrder([]string{"repository", "cache", "repository"})
+	assert.EqualError(t, err, "retrieval stage [repository] appears more than once")
+}
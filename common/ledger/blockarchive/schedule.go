@@ -0,0 +1,170 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchivingSchedule is the schedule of the archiving passes of the archiver peer, nil if the
+// blockfiles are only archived as they are finalized
+var ArchivingSchedule *Schedule
+
+// ScheduleOnly indicates whether the blockfiles are archived only by the passes of ArchivingSchedule,
+// rather than also each time a blockfile is finalized
+var ScheduleOnly bool
+
+// DiscardSchedule is the schedule of the discards of the archived blockfiles, nil if they are not
+// discarded on a schedule
+var DiscardSchedule *Schedule
+
+// DiscardDiskUsageThreshold is the usage in percent of the file system of the block store above which
+// the archived blockfiles are discarded, 0 if the discard doesn't depend on the disk usage
+var DiscardDiskUsageThreshold int
+
+// IsDiscardDeferred tells whether the archived blockfiles are kept on the local file system after their
+// archiving, until DiscardSchedule or DiscardDiskUsageThreshold discards them
+func IsDiscardDeferred() bool {
+	return DiscardSchedule != nil || DiscardDiskUsageThreshold > 0
+}
+
+// scheduleDescriptors are the shorthands of the common schedules
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// scheduleHorizon is how far ahead the next time of a schedule is looked for,
+// beyond which a schedule like "0 0 30 2 *" never fires
+const scheduleHorizon = 5 * 366 * 24 * time.Hour
+
+// Schedule is a cron schedule in the standard five-field syntax, "minute hour day-of-month month day-of-week",
+// e.g. "30 2 * * 1-5" for 02:30 on weekdays, evaluated in the local time of the peer. The fields hold
+// values, ranges "1-5", lists "1,3,5", steps "*/15" or "0-30/10", and "*". Sunday is 0 or 7. As in cron,
+// a time matches when either the day of the month or the day of the week matches, if both are restricted.
+type Schedule struct {
+	expr                                   string
+	minutes, hours, days, months, weekdays uint64
+	daysRestricted, weekdaysRestricted     bool
+}
+
+// ParseSchedule parses a cron expression, or one of the descriptors @hourly, @daily, @weekly, @monthly and @yearly
+func ParseSchedule(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if descriptor, ok := scheduleDescriptors[spec]; ok {
+		spec = descriptor
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("invalid schedule [%s], expected the 5 fields minute hour day-of-month month day-of-week", expr)
+	}
+	s := &Schedule{expr: expr}
+	for i, field := range []struct {
+		name     string
+		min, max int
+		bits     *uint64
+	}{
+		{"minute", 0, 59, &s.minutes},
+		{"hour", 0, 23, &s.hours},
+		{"day-of-month", 1, 31, &s.days},
+		{"month", 1, 12, &s.months},
+		{"day-of-week", 0, 7, &s.weekdays},
+	} {
+		bits, err := parseScheduleField(fields[i], field.min, field.max)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid %s of schedule [%s]", field.name, expr)
+		}
+		*field.bits = bits
+	}
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+	s.daysRestricted = fields[2] != "*"
+	s.weekdaysRestricted = fields[4] != "*"
+	return s, nil
+}
+
+// parseScheduleField returns the bits of the values matched by a field of a cron expression
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		values, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			values = part[:i]
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.Errorf("invalid step [%s]", part)
+			}
+			step = n
+		}
+		first, last := min, max
+		if values != "*" {
+			bounds := strings.SplitN(values, "-", 2)
+			var err error
+			if first, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.Errorf("invalid value [%s]", part)
+			}
+			switch {
+			case len(bounds) == 2:
+				if last, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.Errorf("invalid value [%s]", part)
+				}
+			case step == 1:
+				last = first
+			}
+		}
+		if first < min || last > max || first > last {
+			return 0, errors.Errorf("[%s] is out of the range %d-%d", part, min, max)
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time of the schedule after t, the zero time if it never fires
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	horizon := t.Add(scheduleHorizon)
+	for t.Before(horizon) {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.daysRestricted && s.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// String returns the expression of the schedule
+func (s *Schedule) String() string {
+	return s.expr
+}
@@ -0,0 +1,68 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Thursday
+	now := time.Date(2019, 3, 14, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2019, 3, 14, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2019, 3, 15, 2, 30, 0, 0, time.UTC)},
+		{"0 22-23 * * 1-5", time.Date(2019, 3, 14, 22, 0, 0, 0, time.UTC)},
+		{"0 1 * * 0,6", time.Date(2019, 3, 16, 1, 0, 0, 0, time.UTC)},
+		{"0 1 * * 7", time.Date(2019, 3, 17, 1, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)},
+		// Either the day of the month or the day of the week matches when both are restricted
+		{"0 0 20 * 5", time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2019, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2019, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			s, err := ParseSchedule(test.expr)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, s.Next(now))
+			assert.Equal(t, test.expr, s.String())
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"@often",
+	} {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+	_, err := ParseSchedule("61 * * * *")
+	assert.EqualError(t, err, "invalid minute of schedule [61 * * * *]: [61] is out of the range 0-59")
+}
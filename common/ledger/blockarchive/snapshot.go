@@ -0,0 +1,117 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/msp"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// SignSnapshot marshals and signs the archive snapshot with the signer
+func SignSnapshot(snapshot *archive.ArchiveSnapshot, signer Signer) (*archive.SignedArchiveSnapshot, error) {
+	snapshotBytes, err := proto.Marshal(snapshot)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling archive snapshot")
+	}
+	signature, err := signer.Sign(snapshotBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing archive snapshot")
+	}
+	creator, err := signer.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing the identity of the signer")
+	}
+	return &archive.SignedArchiveSnapshot{
+		Snapshot:  snapshotBytes,
+		Signature: signature,
+		Creator:   creator,
+	}, nil
+}
+
+// VerifySnapshot checks that the archive snapshot has been signed by a valid identity
+// of the MSP of the deserializer, and returns the snapshot and the signer identity
+func VerifySnapshot(signed *archive.SignedArchiveSnapshot, deserializer msp.IdentityDeserializer) (*archive.ArchiveSnapshot, msp.Identity, error) {
+	identity, err := deserializer.DeserializeIdentity(signed.Creator)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to deserialize the signer of the archive snapshot")
+	}
+	if err := identity.Validate(); err != nil {
+		return nil, nil, errors.WithMessage(err, "the signer of the archive snapshot is not valid")
+	}
+	if err := identity.Verify(signed.Snapshot, signed.Signature); err != nil {
+		return nil, nil, errors.WithMessage(err, "the signature of the archive snapshot is not valid")
+	}
+	snapshot := &archive.ArchiveSnapshot{}
+	if err := proto.Unmarshal(signed.Snapshot, snapshot); err != nil {
+		return nil, nil, errors.Wrap(err, "error unmarshaling archive snapshot")
+	}
+	if err := ValidateSnapshot(snapshot); err != nil {
+		return nil, nil, err
+	}
+	return snapshot, identity, nil
+}
+
+// ValidateSnapshot checks that the objects of the snapshot are distinct blockfiles in ascending order,
+// whose block ranges follow each other from the first to the last block of the snapshot
+func ValidateSnapshot(snapshot *archive.ArchiveSnapshot) error {
+	if snapshot.ChannelID == "" {
+		return errors.New("the channel of the archive snapshot is empty")
+	}
+	if len(snapshot.Objects) == 0 {
+		return errors.Errorf("the archive snapshot of channel [%s] holds no archived blockfile", snapshot.ChannelID)
+	}
+	for i, object := range snapshot.Objects {
+		if object.Location == "" {
+			return errors.Errorf("the location of blockfile [%d] is empty", object.BlockfileNo)
+		}
+		if object.FirstBlockNum > object.LastBlockNum {
+			return errors.Errorf("invalid block range [%d-%d] of blockfile [%d]", object.FirstBlockNum, object.LastBlockNum, object.BlockfileNo)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := snapshot.Objects[i-1]
+		if object.BlockfileNo <= prev.BlockfileNo {
+			return errors.Errorf("blockfile [%d] follows blockfile [%d]", object.BlockfileNo, prev.BlockfileNo)
+		}
+		if object.FirstBlockNum != prev.LastBlockNum+1 {
+			return errors.Errorf("blockfile [%d] starts at block [%d], but blockfile [%d] ends at block [%d]",
+				object.BlockfileNo, object.FirstBlockNum, prev.BlockfileNo, prev.LastBlockNum)
+		}
+	}
+	first, last := snapshot.Objects[0], snapshot.Objects[len(snapshot.Objects)-1]
+	if snapshot.FirstBlockNum != first.FirstBlockNum || snapshot.LastBlockNum != last.LastBlockNum {
+		return errors.Errorf("the archive snapshot holds blocks [%d-%d], but its blockfiles hold blocks [%d-%d]",
+			snapshot.FirstBlockNum, snapshot.LastBlockNum, first.FirstBlockNum, last.LastBlockNum)
+	}
+	return nil
+}
+
+// ComputeCatalogHash returns the SHA-256 hash of the records of the archive catalog, in their order. Whether
+// a blockfile is discarded and until when it is restored change over time on a peer and differ between
+// peers, so they are left out: the hash only changes when a blockfile is archived again, moved or lost.
+func ComputeCatalogHash(infos []*archive.ArchivedBlockfileInfo) ([]byte, error) {
+	h := sha256.New()
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	for _, info := range infos {
+		record := proto.Clone(info).(*archive.ArchivedBlockfileInfo)
+		record.Discarded, record.RestoreExpiry = false, nil
+		buf.Reset()
+		if err := buf.Marshal(record); err != nil {
+			return nil, errors.Wrapf(err, "error marshaling the record of blockfile [%d]", info.BlockfileNo)
+		}
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(buf.Bytes())))
+		h.Write(length[:])
+		h.Write(buf.Bytes())
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,87 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/msp/mgmt"
+	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSnapshot() *archive.ArchiveSnapshot {
+	return &archive.ArchiveSnapshot{
+		ChannelID:     "testchannel",
+		Timestamp:     ptypes.TimestampNow(),
+		FirstBlockNum: 0,
+		LastBlockNum:  19,
+		Objects: []*archive.ArchiveSnapshotObject{
+			{BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9, Location: "/blkstore/testchannel/blockfile_000000"},
+			{BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19, Location: "/blkstore/testchannel/blockfile_000001"},
+		},
+	}
+}
+
+func TestSignAndVerifySnapshot(t *testing.T) {
+	require.NoError(t, msptesttools.LoadMSPSetupForTesting())
+	signer := mgmt.GetLocalSigningIdentityOrPanic()
+
+	snapshot := testSnapshot()
+	signed, err := SignSnapshot(snapshot, signer)
+	require.NoError(t, err)
+	verified, identity, err := VerifySnapshot(signed, mgmt.GetLocalMSP())
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(snapshot, verified))
+	assert.Equal(t, signer.GetMSPIdentifier(), identity.GetMSPIdentifier())
+
+	// A tampered snapshot is rejected
+	signed.Snapshot[len(signed.Snapshot)-1] ^= 0xff
+	_, _, err = VerifySnapshot(signed, mgmt.GetLocalMSP())
+	assert.Contains(t, err.Error(), "signature of the archive snapshot is not valid")
+}
+
+func TestValidateSnapshot(t *testing.T) {
+	require.NoError(t, ValidateSnapshot(testSnapshot()))
+
+	snapshot := testSnapshot()
+	snapshot.Objects[1].FirstBlockNum = 11
+	assert.EqualError(t, ValidateSnapshot(snapshot), "blockfile [1] starts at block [11], but blockfile [0] ends at block [9]")
+	snapshot = testSnapshot()
+	snapshot.Objects[1].BlockfileNo = 0
+	assert.EqualError(t, ValidateSnapshot(snapshot), "blockfile [0] follows blockfile [0]")
+	snapshot = testSnapshot()
+	snapshot.LastBlockNum = 29
+	assert.EqualError(t, ValidateSnapshot(snapshot), "the archive snapshot holds blocks [0-29], but its blockfiles hold blocks [0-19]")
+	snapshot = testSnapshot()
+	snapshot.Objects = nil
+	assert.EqualError(t, ValidateSnapshot(snapshot), "the archive snapshot of channel [testchannel] holds no archived blockfile")
+}
+
+func TestComputeCatalogHash(t *testing.T) {
+	infos := []*archive.ArchivedBlockfileInfo{
+		{ChannelID: "testchannel", BlockfileNo: 0, LastBlockNum: 9, Location: "/blkstore/blockfile_000000", Checksum: "sha256:00"},
+		{ChannelID: "testchannel", BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19, Location: "/blkstore/blockfile_000001"},
+	}
+	hash, err := ComputeCatalogHash(infos)
+	require.NoError(t, err)
+	assert.Len(t, hash, 32)
+
+	// Discarding or restoring a blockfile leaves the hash unchanged
+	infos[0].Discarded, infos[0].RestoreExpiry = true, ptypes.TimestampNow()
+	same, err := ComputeCatalogHash(infos)
+	require.NoError(t, err)
+	assert.Equal(t, hash, same)
+
+	// Moving a blockfile changes it
+	infos[1].Location = "/elsewhere/blockfile_000001"
+	moved, err := ComputeCatalogHash(infos)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, moved)
+}
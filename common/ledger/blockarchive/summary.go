@@ -0,0 +1,170 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// SummarySuffix is appended to the path of an archived blockfile
+// to derive the path of its summary
+const SummarySuffix = ".summary"
+
+// NewBlockfileSummary builds the summary of a blockfile from the header hashes of its blocks
+func NewBlockfileSummary(channelID string, blockfileNo, firstBlockNum uint64, blockHashes [][]byte, blockfileHash []byte) *archive.BlockfileSummary {
+	summary := &archive.BlockfileSummary{
+		ChannelID:     channelID,
+		BlockfileNo:   blockfileNo,
+		FirstBlockNum: firstBlockNum,
+		BlockHashes:   blockHashes,
+		MerkleRoot:    ComputeMerkleRoot(blockHashes),
+		BlockfileHash: blockfileHash,
+	}
+	if len(blockHashes) > 0 {
+		summary.LastBlockNum = firstBlockNum + uint64(len(blockHashes)) - 1
+		summary.FirstBlockHash = blockHashes[0]
+		summary.LastBlockHash = blockHashes[len(blockHashes)-1]
+	}
+	return summary
+}
+
+// ComputeMerkleRoot returns the root of the binary Merkle tree whose leaves are the header hashes
+// of the blocks. Each node is the SHA-256 hash of the concatenation of its two children. The last
+// node of a level without a sibling is promoted to the next level as is, rather than paired with
+// itself, so that no two lists of leaves share a root. It returns nil if there is no leaf.
+func ComputeMerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, hashMerkleNodes(level[i], level[i+1]))
+	}
+	return next
+}
+
+func hashMerkleNodes(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// ComputeMerkleProof returns the hashes of the siblings on the path from the leaf at index
+// to the root, from the bottom up, with which a light client verifies a single block
+func ComputeMerkleProof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, errors.Errorf("leaf %d out of range [0-%d)", index, len(leaves))
+	}
+	var proof [][]byte
+	level := leaves
+	for len(level) > 1 {
+		if sibling := index ^ 1; sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		level = nextMerkleLevel(level)
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof checks that the leaf is at index among numLeaves leaves of the tree of the root
+func VerifyMerkleProof(root, leaf []byte, index, numLeaves int, proof [][]byte) bool {
+	if index < 0 || index >= numLeaves {
+		return false
+	}
+	hash := leaf
+	for n := numLeaves; n > 1; n = (n + 1) / 2 {
+		if sibling := index ^ 1; sibling < n {
+			if len(proof) == 0 {
+				return false
+			}
+			if index%2 == 0 {
+				hash = hashMerkleNodes(hash, proof[0])
+			} else {
+				hash = hashMerkleNodes(proof[0], hash)
+			}
+			proof = proof[1:]
+		}
+		index /= 2
+	}
+	return len(proof) == 0 && bytes.Equal(hash, root)
+}
+
+// VerifySummary checks that the summary is consistent: it holds one hash per block of its range,
+// the first and last hashes match, and the Merkle root is the one of the block hashes
+func VerifySummary(summary *archive.BlockfileSummary) error {
+	n := uint64(len(summary.BlockHashes))
+	if n == 0 || summary.LastBlockNum < summary.FirstBlockNum || summary.LastBlockNum-summary.FirstBlockNum+1 != n {
+		return errors.Errorf("the summary holds %d block hash(es) for blocks [%d-%d]", n, summary.FirstBlockNum, summary.LastBlockNum)
+	}
+	if !bytes.Equal(summary.FirstBlockHash, summary.BlockHashes[0]) {
+		return errors.Errorf("the hash of the first block [%d] does not match the block hashes", summary.FirstBlockNum)
+	}
+	if !bytes.Equal(summary.LastBlockHash, summary.BlockHashes[n-1]) {
+		return errors.Errorf("the hash of the last block [%d] does not match the block hashes", summary.LastBlockNum)
+	}
+	if !bytes.Equal(summary.MerkleRoot, ComputeMerkleRoot(summary.BlockHashes)) {
+		return errors.New("the Merkle root does not match the block hashes")
+	}
+	return nil
+}
+
+// VerifySummaryAgainstManifest checks that the summary is consistent and describes
+// the same blockfile as the signed manifest
+func VerifySummaryAgainstManifest(summary *archive.BlockfileSummary, manifest *archive.ArchiveManifest) error {
+	if err := VerifySummary(summary); err != nil {
+		return err
+	}
+	if summary.ChannelID != manifest.ChannelID || summary.BlockfileNo != manifest.BlockfileNo {
+		return errors.Errorf("the summary of blockfile [%d] of channel [%s] does not match the manifest of blockfile [%d] of channel [%s]",
+			summary.BlockfileNo, summary.ChannelID, manifest.BlockfileNo, manifest.ChannelID)
+	}
+	if summary.FirstBlockNum != manifest.FirstBlockNum || summary.LastBlockNum != manifest.LastBlockNum {
+		return errors.Errorf("the summary holds blocks [%d-%d], but the manifest blocks [%d-%d]",
+			summary.FirstBlockNum, summary.LastBlockNum, manifest.FirstBlockNum, manifest.LastBlockNum)
+	}
+	if !bytes.Equal(summary.FirstBlockHash, manifest.FirstBlockHash) || !bytes.Equal(summary.LastBlockHash, manifest.LastBlockHash) {
+		return errors.New("the block hashes of the summary do not match the manifest")
+	}
+	if !bytes.Equal(summary.BlockfileHash, manifest.BlockfileHash) {
+		return errors.New("the blockfile hash of the summary does not match the manifest")
+	}
+	// The manifests produced before the summaries were introduced have no Merkle root
+	if len(manifest.MerkleRoot) > 0 && !bytes.Equal(summary.MerkleRoot, manifest.MerkleRoot) {
+		return errors.New("the Merkle root of the summary does not match the manifest")
+	}
+	return nil
+}
+
+// VerifyBlockHeaderAgainstSummary checks that the header of a block is the one summarized
+func VerifyBlockHeaderAgainstSummary(summary *archive.BlockfileSummary, header *common.BlockHeader) error {
+	if header.Number < summary.FirstBlockNum || header.Number > summary.LastBlockNum ||
+		header.Number-summary.FirstBlockNum >= uint64(len(summary.BlockHashes)) {
+		return errors.Errorf("block [%d] is not in the summary of blocks [%d-%d]", header.Number, summary.FirstBlockNum, summary.LastBlockNum)
+	}
+	if !bytes.Equal(protoutil.BlockHeaderHash(header), summary.BlockHashes[header.Number-summary.FirstBlockNum]) {
+		return errors.Errorf("the header of block [%d] does not match the summary", header.Number)
+	}
+	return nil
+}
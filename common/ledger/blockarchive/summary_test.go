@@ -0,0 +1,97 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLeaves(n int) [][]byte {
+	var leaves [][]byte
+	for i := 0; i < n; i++ {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("block %d", i)))
+		leaves = append(leaves, hash[:])
+	}
+	return leaves
+}
+
+func TestComputeMerkleRoot(t *testing.T) {
+	assert.Nil(t, ComputeMerkleRoot(nil))
+	leaves := testLeaves(3)
+	assert.Equal(t, leaves[0], ComputeMerkleRoot(leaves[:1]))
+	assert.Equal(t, hashMerkleNodes(leaves[0], leaves[1]), ComputeMerkleRoot(leaves[:2]))
+	// The last leaf is promoted rather than paired with itself
+	assert.Equal(t, hashMerkleNodes(hashMerkleNodes(leaves[0], leaves[1]), leaves[2]), ComputeMerkleRoot(leaves))
+	assert.NotEqual(t, ComputeMerkleRoot(leaves), ComputeMerkleRoot(append(leaves, leaves[2])))
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 8, 13} {
+		leaves := testLeaves(n)
+		root := ComputeMerkleRoot(leaves)
+		for i := range leaves {
+			proof, err := ComputeMerkleProof(leaves, i)
+			require.NoError(t, err)
+			assert.True(t, VerifyMerkleProof(root, leaves[i], i, n, proof), "leaf %d of %d", i, n)
+			if n > 1 {
+				assert.False(t, VerifyMerkleProof(root, leaves[(i+1)%n], i, n, proof), "leaf %d of %d", i, n)
+				assert.False(t, VerifyMerkleProof(root, leaves[i], (i+1)%n, n, proof), "leaf %d of %d", i, n)
+			}
+		}
+	}
+	_, err := ComputeMerkleProof(testLeaves(2), 2)
+	assert.EqualError(t, err, "leaf 2 out of range [0-2)")
+}
+
+func TestVerifySummary(t *testing.T) {
+	var headers []*common.BlockHeader
+	var hashes [][]byte
+	for i := uint64(10); i < 15; i++ {
+		header := &common.BlockHeader{Number: i, DataHash: []byte{byte(i)}}
+		headers = append(headers, header)
+		hashes = append(hashes, protoutil.BlockHeaderHash(header))
+	}
+	summary := NewBlockfileSummary("ch1", 2, 10, hashes, []byte("blockfile hash"))
+	require.NoError(t, VerifySummary(summary))
+	assert.Equal(t, uint64(14), summary.LastBlockNum)
+
+	manifest := &archive.ArchiveManifest{
+		ChannelID:      "ch1",
+		BlockfileNo:    2,
+		FirstBlockNum:  10,
+		LastBlockNum:   14,
+		FirstBlockHash: hashes[0],
+		LastBlockHash:  hashes[4],
+		BlockfileHash:  []byte("blockfile hash"),
+		MerkleRoot:     summary.MerkleRoot,
+	}
+	require.NoError(t, VerifySummaryAgainstManifest(summary, manifest))
+	manifest.MerkleRoot = []byte("other")
+	assert.EqualError(t, VerifySummaryAgainstManifest(summary, manifest), "the Merkle root of the summary does not match the manifest")
+	manifest.MerkleRoot = nil
+	require.NoError(t, VerifySummaryAgainstManifest(summary, manifest))
+	manifest.LastBlockNum = 15
+	assert.EqualError(t, VerifySummaryAgainstManifest(summary, manifest), "the summary holds blocks [10-14], but the manifest blocks [10-15]")
+
+	require.NoError(t, VerifyBlockHeaderAgainstSummary(summary, headers[3]))
+	assert.EqualError(t, VerifyBlockHeaderAgainstSummary(summary, &common.BlockHeader{Number: 13}),
+		"the header of block [13] does not match the summary")
+	assert.EqualError(t, VerifyBlockHeaderAgainstSummary(summary, &common.BlockHeader{Number: 15}),
+		"block [15] is not in the summary of blocks [10-14]")
+
+	// A tampered block hash no longer matches the Merkle root
+	summary.BlockHashes[2] = []byte("tampered")
+	assert.EqualError(t, VerifySummary(summary), "the Merkle root does not match the block hashes")
+	summary.BlockHashes = summary.BlockHashes[:4]
+	assert.EqualError(t, VerifySummary(summary), "the summary holds 4 block hash(es) for blocks [10-14]")
+}
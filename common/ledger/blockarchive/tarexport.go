@@ -0,0 +1,39 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"archive/tar"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+// TarBlockName returns the name of the entry of a block in the tar exports of the blocks of a channel
+func TarBlockName(channelID string, blockNum uint64) string {
+	return fmt.Sprintf("%s/%020d.block", channelID, blockNum)
+}
+
+// WriteTarBlock writes a block to a tar export as an entry TarBlockName holding its protobuf encoding
+func WriteTarBlock(tw *tar.Writer, channelID string, block *common.Block) error {
+	b, err := proto.Marshal(block)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling block [%d]", block.Header.Number)
+	}
+	header := &tar.Header{
+		Name:    TarBlockName(channelID, block.Header.Number),
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(b)
+	return err
+}
@@ -0,0 +1,44 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTarBlock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	var blocks []*common.Block
+	for number := uint64(9); number <= 10; number++ {
+		block := protoutil.NewBlock(number, []byte("previous hash"))
+		block.Data.Data = [][]byte{[]byte("tx")}
+		blocks = append(blocks, block)
+		require.NoError(t, WriteTarBlock(tw, "mychannel", block))
+	}
+	require.NoError(t, tw.Close())
+
+	tr := tar.NewReader(buf)
+	for _, block := range blocks {
+		header, err := tr.Next()
+		require.NoError(t, err)
+		assert.Equal(t, TarBlockName("mychannel", block.Header.Number), header.Name)
+		b, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		read := &common.Block{}
+		require.NoError(t, proto.Unmarshal(b, read))
+		assert.True(t, proto.Equal(block, read))
+	}
+	assert.Equal(t, "mychannel/00000000000000000010.block", TarBlockName("mychannel", 10))
+}
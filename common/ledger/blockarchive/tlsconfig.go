@@ -0,0 +1,96 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/tls"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultRepositoryTLSMinVersion is the minimum TLS version of the repository client transport when none is configured
+const defaultRepositoryTLSMinVersion = tls.VersionTLS12
+
+// tlsVersions are the names of the TLS versions accepted as minimum version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// RepositoryTLSSettings are the TLS settings of the repository client transport, such as the connection to an
+// https proxy, which are independent of the TLS settings of the peer
+type RepositoryTLSSettings struct {
+	// MinVersion is the minimum TLS version, 1.0, 1.1, 1.2 or 1.3, 1.2 when empty
+	MinVersion string
+	// CipherSuites are the names of the cipher suites of TLS 1.2 and below, e.g.
+	// TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, the secure defaults of Go when empty.
+	// The cipher suites of TLS 1.3 are not configurable.
+	CipherSuites []string
+	// ClientCertFile and ClientKeyFile are the PEM encoded certificate and key with which the
+	// transport authenticates, none when empty
+	ClientCertFile string
+	ClientKeyFile  string
+	// CABundle is the file holding the PEM encoded root CAs trusted by the transport,
+	// the system roots when empty
+	CABundle string
+}
+
+// TLSConfig builds the TLS configuration of the repository client transport
+func (s *RepositoryTLSSettings) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: defaultRepositoryTLSMinVersion}
+	if s.MinVersion != "" {
+		version, ok := tlsVersions[strings.TrimSpace(s.MinVersion)]
+		if !ok {
+			return nil, errors.Errorf("unsupported TLS version [%s], expected 1.0, 1.1, 1.2 or 1.3", s.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if len(s.CipherSuites) > 0 {
+		suites, err := parseCipherSuites(s.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = suites
+	}
+	if s.ClientCertFile != "" || s.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.ClientCertFile, s.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if s.CABundle != "" {
+		rootCAs, err := LoadRootCAs(s.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = rootCAs
+	}
+	return config, nil
+}
+
+// parseCipherSuites returns the IDs of the cipher suites with the names. The insecure cipher suites and the
+// cipher suites of TLS 1.3, which are not configurable, are refused.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]*tls.CipherSuite)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite
+	}
+	var suites []uint16
+	for _, name := range names {
+		suite, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, errors.Errorf("unsupported cipher suite [%s]", name)
+		}
+		if len(suite.SupportedVersions) == 1 && suite.SupportedVersions[0] == tls.VersionTLS13 {
+			return nil, errors.Errorf("cipher suite [%s] of TLS 1.3 is not configurable", name)
+		}
+		suites = append(suites, suite.ID)
+	}
+	return suites, nil
+}
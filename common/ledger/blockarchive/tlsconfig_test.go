@@ -0,0 +1,82 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryTLSConfig(t *testing.T) {
+	config, err := (&RepositoryTLSSettings{}).TLSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Nil(t, config.CipherSuites)
+	assert.Nil(t, config.RootCAs)
+	assert.Empty(t, config.Certificates)
+
+	config, err = (&RepositoryTLSSettings{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", " TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}).TLSConfig()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, config.CipherSuites)
+
+	for _, tc := range []struct {
+		settings RepositoryTLSSettings
+		err      string
+	}{
+		{RepositoryTLSSettings{MinVersion: "1.4"}, "unsupported TLS version [1.4], expected 1.0, 1.1, 1.2 or 1.3"},
+		{RepositoryTLSSettings{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, "unsupported cipher suite [TLS_RSA_WITH_RC4_128_SHA]"},
+		{RepositoryTLSSettings{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, "cipher suite [TLS_AES_128_GCM_SHA256] of TLS 1.3 is not configurable"},
+		{RepositoryTLSSettings{ClientCertFile: "/nonexistent/client.crt"}, "error loading client certificate"},
+		{RepositoryTLSSettings{CABundle: "/nonexistent/ca.pem"}, "error reading CA bundle"},
+	} {
+		_, err := tc.settings.TLSConfig()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.err)
+	}
+}
+
+func TestDialRepositoryWithTLSSettings(t *testing.T) {
+	defer resetTransportParams()()
+	repo := startTestRepository(t)
+	defer repo.Close()
+	handler := &connectHandler{tunneled: make(chan string, 10)}
+	// The proxy doesn't support TLS 1.3
+	proxy := httptest.NewUnstartedServer(handler)
+	proxy.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	proxy.StartTLS()
+	defer proxy.Close()
+	RepositoryProxyURL = proxy.URL
+
+	testDir, err := ioutil.TempDir("", "transport")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	bundle := filepath.Join(testDir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: proxy.Certificate().Raw}), 0600))
+
+	RepositoryTLSConfig, err = (&RepositoryTLSSettings{MinVersion: "1.3", CABundle: bundle}).TLSConfig()
+	require.NoError(t, err)
+	_, err = DialRepository(repo.Addr().String())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error establishing TLS with proxy")
+
+	RepositoryTLSConfig, err = (&RepositoryTLSSettings{MinVersion: "1.2", CABundle: bundle}).TLSConfig()
+	require.NoError(t, err)
+	conn, err := DialRepository(repo.Addr().String())
+	require.NoError(t, err)
+	assertRepositoryReached(t, conn)
+	assert.Equal(t, repo.Addr().String(), <-handler.tunneled)
+}
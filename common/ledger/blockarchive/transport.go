@@ -0,0 +1,182 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RepositoryProxyURL is the URL of the HTTP proxy through which the repository is reached by tunneling,
+// http://[user:password@]host:port or https://[user:password@]host:port. The repository is reached
+// directly when it is empty.
+var RepositoryProxyURL string
+
+// RepositoryNoProxy lists the hosts of the repositories which are reached directly even if
+// RepositoryProxyURL is set. An entry starting with a dot matches the subdomains.
+var RepositoryNoProxy []string
+
+// RepositoryTLSConfig is the TLS configuration of the connections of the repository client transport, such as
+// the one to an HTTPS proxy, built from RepositoryTLSSettings. The defaults of Go apply when it is nil.
+var RepositoryTLSConfig *tls.Config
+
+// repositoryDialTimeout bounds the establishment of a connection to the repository or the proxy
+const repositoryDialTimeout = 30 * time.Second
+
+// LoadRootCAs reads a bundle of PEM encoded root CA certificates
+func LoadRootCAs(bundleFile string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(bundleFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading CA bundle %s", bundleFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("no certificate found in CA bundle %s", bundleFile)
+	}
+	return pool, nil
+}
+
+// ValidateProxyURL checks that the URL of a proxy is an http or https URL with a host
+func ValidateProxyURL(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid proxy URL %s", proxyURL)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid proxy URL %s, expected http://host:port or https://host:port", proxyURL)
+	}
+	return nil
+}
+
+// DialRepository opens a connection to the repository at the URL parsed by ParseRepositoryURL, trying its
+// addresses in order until one is reached. The connections are tunneled through RepositoryProxyURL with the
+// CONNECT method unless the host is listed in RepositoryNoProxy.
+func DialRepository(repositoryURL string) (net.Conn, error) {
+	endpoint, err := ParseRepositoryURL(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := endpoint.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range addresses {
+		var conn net.Conn
+		if conn, err = dialRepositoryAddress(address); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// dialRepositoryAddress opens a connection to an address of the repository, host:port
+func dialRepositoryAddress(address string) (net.Conn, error) {
+	if RepositoryProxyURL == "" || bypassesProxy(address) {
+		return net.DialTimeout("tcp", address, repositoryDialTimeout)
+	}
+	return dialThroughProxy(RepositoryProxyURL, address)
+}
+
+// bypassesProxy tells if the host of address is listed in RepositoryNoProxy
+func bypassesProxy(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	for _, entry := range RepositoryNoProxy {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if host == entry || (strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialThroughProxy opens a tunnel to address through the proxy
+func dialThroughProxy(proxyURL, address string) (net.Conn, error) {
+	if err := ValidateProxyURL(proxyURL); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(proxyURL)
+	proxyAddress := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			proxyAddress = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			proxyAddress = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddress, repositoryDialTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error connecting to proxy %s", u.Host)
+	}
+	if u.Scheme == "https" {
+		tlsConfig := &tls.Config{}
+		if RepositoryTLSConfig != nil {
+			tlsConfig = RepositoryTLSConfig.Clone()
+		}
+		tlsConfig.ServerName = u.Hostname()
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, errors.Wrapf(err, "error establishing TLS with proxy %s", u.Host)
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	conn.SetDeadline(time.Now().Add(repositoryDialTimeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "error sending CONNECT to proxy %s", u.Host)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "error reading the response of proxy %s", u.Host)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.Errorf("proxy %s refused the tunnel to %s: %s", u.Host, address, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	// The repository may have sent data, such as its SSH banner, which has been buffered with the response
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn is a connection whose first bytes have been buffered by a reader
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
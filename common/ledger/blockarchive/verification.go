@@ -0,0 +1,102 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package blockarchive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// VerifyPath is the path of the API of the repository verifying the archived blockfiles
+const VerifyPath = "/verify"
+
+// verifyTimeout bounds the verification of a blockfile, which is read in whole by the repository
+const verifyTimeout = 2 * time.Minute
+
+// RepositoryAPIURL is the URL of the HTTP API of the repository, e.g. https://blkarchiver-repo:9445, through which
+// the archived blockfiles are verified by the repository rather than downloaded. Empty if the API is not used.
+// The API is served over TLS, and authenticates the peer with the credentials of RepositoryCredentials.
+var RepositoryAPIURL string
+
+// ValidateAPIURL checks that the URL of the API of the repository is an https URL with a host
+func ValidateAPIURL(apiURL string) error {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid API URL %s", apiURL)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("invalid API URL %s, expected https://host:port", apiURL)
+	}
+	return nil
+}
+
+// BlockfileVerification is the digest of an archived blockfile computed by the repository from the stored content
+type BlockfileVerification struct {
+	Path string `json:"path"`
+	// Size is the number of bytes stored, which are fewer than the ones of a compressed blockfile once decoded
+	Size int64 `json:"size"`
+	// Checksum is the checksum of the stored content in the form "<algorithm>:<hex digest>", computed on the
+	// blockfile once decoded when it is stored encoded
+	Checksum string `json:"checksum"`
+	// Blocks are the header hashes of the summary of the blockfile, when requested and the blockfile has a summary
+	Blocks *VerifiedBlockHashes `json:"blocks,omitempty"`
+}
+
+// VerifiedBlockHashes are the header hashes of the blocks of an archived blockfile, read from its summary
+type VerifiedBlockHashes struct {
+	FirstBlockNum uint64   `json:"firstBlockNum"`
+	LastBlockNum  uint64   `json:"lastBlockNum"`
+	BlockHashes   [][]byte `json:"blockHashes"`
+	MerkleRoot    []byte   `json:"merkleRoot"`
+}
+
+// VerifyBlockfile asks the repository at RepositoryAPIURL to compute the checksum of the archived blockfile at the
+// location with the algorithm, along with the block hashes of its summary if withBlockHashes is set, so that the
+// integrity of the archive is audited without downloading the blockfile
+func VerifyBlockfile(location, algorithm string, withBlockHashes bool) (*BlockfileVerification, error) {
+	if RepositoryAPIURL == "" {
+		return nil, errors.New("the API of the repository is not configured")
+	}
+	query := url.Values{"algorithm": {algorithm}}
+	if withBlockHashes {
+		query.Set("blocks", "true")
+	}
+	reqURL := fmt.Sprintf("%s%s/%s?%s", strings.TrimSuffix(RepositoryAPIURL, "/"), VerifyPath,
+		strings.TrimPrefix(location, "/"), query.Encode())
+	user, password, err := RepositoryCredentials()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error verifying archived blockfile %s", location)
+	}
+	req.SetBasicAuth(user, password)
+	client := &http.Client{Timeout: verifyTimeout}
+	if RepositoryTLSConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: RepositoryTLSConfig.Clone()}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error verifying archived blockfile %s", location)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.Errorf("error verifying archived blockfile %s: %s: %s", location, resp.Status, strings.TrimSpace(string(body)))
+	}
+	verification := &BlockfileVerification{}
+	if err := json.NewDecoder(resp.Body).Decode(verification); err != nil {
+		return nil, errors.Wrapf(err, "error parsing the verification of archived blockfile %s", location)
+	}
+	return verification, nil
+}
@@ -0,0 +1,469 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package webhdfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("archiver.hdfs")
+
+const (
+	// apiPrefix is the path prefix of the WebHDFS REST API
+	apiPrefix = "/webhdfs/v1"
+	// dialTimeout bounds the establishment of a connection to a namenode or a datanode
+	dialTimeout = 30 * time.Second
+	// responseTimeout bounds the wait for the response to a request, the transfers of file content not included
+	responseTimeout = 2 * time.Minute
+)
+
+// RemoteException is an error returned by a namenode or a datanode
+type RemoteException struct {
+	StatusCode    int    `json:"-"`
+	Exception     string `json:"exception"`
+	JavaClassName string `json:"javaClassName"`
+	Message       string `json:"message"`
+}
+
+func (e *RemoteException) Error() string {
+	if e.Exception == "" {
+		return fmt.Sprintf("WebHDFS request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Exception, e.Message)
+}
+
+// Unavailable tells if the namenode which returned the exception does not serve the requests,
+// in which case the request is sent to the next namenode
+func (e *RemoteException) Unavailable() bool {
+	return e.Exception == "StandbyException" || e.Exception == "RetriableException"
+}
+
+// Client accesses the files of an HDFS cluster through its WebHDFS REST API. It is safe for concurrent use.
+type Client struct {
+	config *Config
+	http   *http.Client
+	krb    *client.Client
+
+	mutex sync.Mutex
+	// active is the index of the namenode which answered last
+	active int
+}
+
+// NewClient returns a client of the cluster of the configuration, which has logged in to Kerberos
+// when the cluster requires it
+func NewClient(config *Config) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	dial := config.Dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: dialTimeout}).Dial
+	}
+	transport := &http.Transport{
+		DialContext: func(_ context.Context, network, address string) (net.Conn, error) {
+			return dial(network, address)
+		},
+		TLSHandshakeTimeout:   dialTimeout,
+		ResponseHeaderTimeout: responseTimeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig.Clone()
+	}
+	c := &Client{
+		config: config,
+		http: &http.Client{
+			Transport: transport,
+			// The redirections to the datanodes are followed by the client, the body is then sent to the datanode
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+	if config.Kerberos != nil {
+		krb, err := login(config.Kerberos)
+		if err != nil {
+			return nil, err
+		}
+		c.krb = krb
+	}
+	return c, nil
+}
+
+// login logs in to Kerberos with the key of the principal, which is renewed by the client until it is destroyed
+func login(k *KerberosConfig) (*client.Client, error) {
+	krb5Conf := k.Krb5Conf
+	if krb5Conf == "" {
+		krb5Conf = defaultKrb5Conf
+	}
+	cfg, err := config.Load(krb5Conf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading Kerberos configuration %s", krb5Conf)
+	}
+	kt, err := keytab.Load(k.Keytab)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading keytab %s", k.Keytab)
+	}
+	name, realm, err := splitPrincipal(k.Principal)
+	if err != nil {
+		return nil, err
+	}
+	krb := client.NewWithKeytab(name, realm, kt, cfg, client.DisablePAFXFAST(true))
+	if err := krb.Login(); err != nil {
+		return nil, errors.Wrapf(err, "error logging in to Kerberos as %s", k.Principal)
+	}
+	return krb, nil
+}
+
+// Close releases the connections of the client and logs it out of Kerberos
+func (c *Client) Close() error {
+	c.http.CloseIdleConnections()
+	if c.krb != nil {
+		c.krb.Destroy()
+	}
+	return nil
+}
+
+// fullPath returns the path of HDFS of a path of the client, which is relative to the base path
+func (c *Client) fullPath(p string) string {
+	return path.Join("/", c.config.BasePath, p)
+}
+
+// namenode sends an operation on the path to the namenode which answered last, failing over to the other
+// namenodes when it is unreachable or in standby. The response is returned when its status is 2xx or 307,
+// the other statuses are returned as a *RemoteException.
+func (c *Client) namenode(method, p, op string, params url.Values) (*http.Response, error) {
+	c.mutex.Lock()
+	first := c.active
+	c.mutex.Unlock()
+
+	var err error
+	for i := range c.config.Namenodes {
+		index := (first + i) % len(c.config.Namenodes)
+		namenode := c.config.Namenodes[index]
+		var resp *http.Response
+		resp, err = c.sendToNamenode(namenode, method, p, op, params)
+		if err == nil {
+			if err = checkResponse(resp); err == nil {
+				if index != first {
+					logger.Infof("Failed over to namenode %s", namenode)
+					c.mutex.Lock()
+					c.active = index
+					c.mutex.Unlock()
+				}
+				return resp, nil
+			}
+			if remote, ok := err.(*RemoteException); !ok || !remote.Unavailable() {
+				return nil, err
+			}
+		}
+		logger.Debugf("Namenode %s did not serve %s %s: %s", namenode, op, p, err)
+	}
+	return nil, err
+}
+
+// sendToNamenode sends an operation on the path to a namenode, authenticated with SPNEGO or as the user
+func (c *Client) sendToNamenode(namenode, method, p, op string, params url.Values) (*http.Response, error) {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("op", op)
+	if c.config.User != "" {
+		query.Set("user.name", c.config.User)
+	}
+	u := strings.TrimSuffix(namenode, "/") + apiPrefix + (&url.URL{Path: c.fullPath(p)}).EscapedPath() + "?" + query.Encode()
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid WebHDFS request %s", u)
+	}
+	if c.krb != nil {
+		if err := spnego.SetSPNEGOHeader(c.krb, req, c.servicePrincipal(req.URL.Hostname())); err != nil {
+			return nil, errors.Wrapf(err, "error authenticating to namenode %s", namenode)
+		}
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error sending %s %s to namenode %s", op, p, namenode)
+	}
+	return resp, nil
+}
+
+// servicePrincipal returns the principal of the namenode of the host, empty to derive it from the request
+func (c *Client) servicePrincipal(host string) string {
+	return strings.Replace(c.config.Kerberos.ServicePrincipal, "_HOST", host, -1)
+}
+
+// checkResponse returns the RemoteException of a response whose status is neither 2xx nor 307,
+// closing its body
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusTemporaryRedirect {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	remote := &struct {
+		RemoteException *RemoteException `json:"RemoteException"`
+	}{}
+	if json.Unmarshal(body, remote) != nil || remote.RemoteException == nil {
+		return &RemoteException{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	remote.RemoteException.StatusCode = resp.StatusCode
+	return remote.RemoteException
+}
+
+// pathError returns the error of an operation which failed with the RemoteException, in the form of the errors
+// of the os package for the missing and existing files and the refused permissions
+func pathError(op, p string, err error) error {
+	remote, ok := err.(*RemoteException)
+	if !ok {
+		return err
+	}
+	switch remote.Exception {
+	case "FileNotFoundException":
+		return &os.PathError{Op: op, Path: p, Err: os.ErrNotExist}
+	case "FileAlreadyExistsException":
+		return &os.PathError{Op: op, Path: p, Err: os.ErrExist}
+	case "AccessControlException", "SecurityException":
+		return &os.PathError{Op: op, Path: p, Err: os.ErrPermission}
+	}
+	return errors.WithMessagef(err, "%s %s", op, p)
+}
+
+// call sends an operation to the namenodes and decodes its JSON response into result
+func (c *Client) call(method, p, op string, params url.Values, result interface{}) error {
+	resp, err := c.namenode(method, p, op, params)
+	if err != nil {
+		return pathError(strings.ToLower(op), p, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return errors.Wrapf(err, "error decoding the response to %s %s", op, p)
+	}
+	return nil
+}
+
+// callBoolean sends an operation returning a boolean to the namenodes
+func (c *Client) callBoolean(method, p, op string, params url.Values) (bool, error) {
+	result := &struct {
+		Boolean bool `json:"boolean"`
+	}{}
+	err := c.call(method, p, op, params, result)
+	return result.Boolean, err
+}
+
+// redirect sends an operation to the namenodes, which redirect it to a datanode, and returns the URL of the datanode
+func (c *Client) redirect(method, p, op string, params url.Values) (string, error) {
+	resp, err := c.namenode(method, p, op, params)
+	if err != nil {
+		return "", pathError(strings.ToLower(op), p, err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusTemporaryRedirect || location == "" {
+		return "", errors.Errorf("namenode did not redirect %s %s to a datanode, status %d", op, p, resp.StatusCode)
+	}
+	return location, nil
+}
+
+// sendToDatanode sends a request to the URL of a datanode returned by redirect, and returns the response
+// when its status is the expected one
+func (c *Client) sendToDatanode(method, location string, body io.Reader, expected int) (*http.Response, error) {
+	req, err := http.NewRequest(method, location, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid datanode URL %s", location)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error sending request to datanode %s", req.URL.Host)
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		resp.Body.Close()
+		return nil, errors.Errorf("unexpected status %d of datanode %s", resp.StatusCode, req.URL.Host)
+	}
+	return resp, nil
+}
+
+// fileStatus is the status of a file returned by GETFILESTATUS and LISTSTATUS
+type fileStatus struct {
+	PathSuffix       string `json:"pathSuffix"`
+	Type             string `json:"type"`
+	Length           int64  `json:"length"`
+	Permission       string `json:"permission"`
+	ModificationTime int64  `json:"modificationTime"`
+	Owner            string `json:"owner"`
+	Group            string `json:"group"`
+}
+
+// fileInfo is the os.FileInfo of a file of HDFS
+type fileInfo struct {
+	name   string
+	status *fileStatus
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+func (fi *fileInfo) Size() int64  { return fi.status.Length }
+func (fi *fileInfo) Mode() os.FileMode {
+	perm, _ := strconv.ParseUint(fi.status.Permission, 8, 32)
+	mode := os.FileMode(perm) & os.ModePerm
+	if fi.IsDir() {
+		mode |= os.ModeDir
+	}
+	return mode
+}
+func (fi *fileInfo) ModTime() time.Time {
+	return time.Unix(0, fi.status.ModificationTime*int64(time.Millisecond))
+}
+func (fi *fileInfo) IsDir() bool      { return fi.status.Type == "DIRECTORY" }
+func (fi *fileInfo) Sys() interface{} { return fi.status }
+
+// Stat returns the status of the file at the path
+func (c *Client) Stat(p string) (os.FileInfo, error) {
+	result := &struct {
+		FileStatus *fileStatus `json:"FileStatus"`
+	}{}
+	if err := c.call(http.MethodGet, p, "GETFILESTATUS", nil, result); err != nil {
+		return nil, err
+	}
+	if result.FileStatus == nil {
+		return nil, errors.Errorf("no status of %s returned", p)
+	}
+	return &fileInfo{name: path.Base(p), status: result.FileStatus}, nil
+}
+
+// ReadDir returns the statuses of the files of the directory at the path
+func (c *Client) ReadDir(p string) ([]os.FileInfo, error) {
+	result := &struct {
+		FileStatuses struct {
+			FileStatus []*fileStatus `json:"FileStatus"`
+		} `json:"FileStatuses"`
+	}{}
+	if err := c.call(http.MethodGet, p, "LISTSTATUS", nil, result); err != nil {
+		return nil, err
+	}
+	var infos []os.FileInfo
+	for _, status := range result.FileStatuses.FileStatus {
+		infos = append(infos, &fileInfo{name: status.PathSuffix, status: status})
+	}
+	return infos, nil
+}
+
+// MkdirAll creates the directory at the path along with its missing parents
+func (c *Client) MkdirAll(p string) error {
+	created, err := c.callBoolean(http.MethodPut, p, "MKDIRS", nil)
+	if err == nil && !created {
+		err = errors.Errorf("directory %s not created", p)
+	}
+	return err
+}
+
+// Remove removes the file or the empty directory at the path
+func (c *Client) Remove(p string) error {
+	deleted, err := c.callBoolean(http.MethodDelete, p, "DELETE", url.Values{"recursive": {"false"}})
+	if err == nil && !deleted {
+		// The namenode does not tell a missing file apart from one it did not delete
+		err = &os.PathError{Op: "remove", Path: p, Err: os.ErrNotExist}
+	}
+	return err
+}
+
+// RemoveDirectory removes the empty directory at the path
+func (c *Client) RemoveDirectory(p string) error {
+	return c.Remove(p)
+}
+
+// Rename renames the file at oldPath to newPath, which must not exist
+func (c *Client) Rename(oldPath, newPath string) error {
+	renamed, err := c.callBoolean(http.MethodPut, oldPath, "RENAME", url.Values{"destination": {c.fullPath(newPath)}})
+	if err != nil || renamed {
+		return err
+	}
+	if _, err := c.Stat(oldPath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrNotExist}
+	}
+	return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: os.ErrExist}
+}
+
+// Getwd checks that the namenodes accept the credentials of the client, and returns the base path
+func (c *Client) Getwd() (string, error) {
+	result := &struct {
+		Path string `json:"Path"`
+	}{}
+	if err := c.call(http.MethodGet, "/", "GETHOMEDIRECTORY", nil, result); err != nil {
+		return "", err
+	}
+	return c.fullPath("/"), nil
+}
+
+// Open opens the file at the path for reading
+func (c *Client) Open(p string) (*File, error) {
+	info, err := c.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return &File{client: c, path: p, info: info}, nil
+}
+
+// Create creates the file at the path for writing, replacing the existing one. The file is complete once
+// the File is closed.
+func (c *Client) Create(p string) (*File, error) {
+	location, err := c.redirect(http.MethodPut, p, "CREATE", url.Values{"overwrite": {"true"}})
+	if err != nil {
+		return nil, err
+	}
+	f := &File{client: c, path: p, writing: true}
+	f.startWrite(http.MethodPut, location, http.StatusCreated)
+	return f, nil
+}
+
+// OpenFile opens the file at the path with the flags of os.OpenFile. A file opened for writing without
+// os.O_CREATE or os.O_TRUNC must exist, and is written from the offset it is seeked to, which is at most its
+// size: the part after the offset is truncated before the content written is appended, since HDFS does not
+// overwrite the files in place.
+func (c *Client) OpenFile(p string, flags int) (*File, error) {
+	if flags&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return c.Open(p)
+	}
+	if flags&os.O_RDWR != 0 {
+		return nil, errors.Errorf("opening %s for reading and writing is not supported by HDFS", p)
+	}
+	if flags&(os.O_CREATE|os.O_TRUNC) != 0 {
+		return c.Create(p)
+	}
+	info, err := c.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	offset := int64(0)
+	if flags&os.O_APPEND != 0 {
+		offset = info.Size()
+	}
+	return &File{client: c, path: p, info: info, offset: offset, writing: true}, nil
+}
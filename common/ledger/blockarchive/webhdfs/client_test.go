@@ -0,0 +1,272 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package webhdfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs/webhdfstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config *Config
+		err    string
+	}{
+		{config: &Config{Namenodes: []string{"http://nn1:9870", "http://nn2:9870"}, BasePath: "/fabric"}},
+		{config: &Config{Namenodes: []string{"https://nn1:9871"}, Kerberos: &KerberosConfig{Keytab: "peer.keytab", Principal: "peer/host@EXAMPLE.COM"}}},
+		{config: &Config{}, err: "no namenode configured"},
+		{config: &Config{Namenodes: []string{"nn1:9870"}}, err: "invalid namenode nn1:9870, expected http://host:port or https://host:port"},
+		{config: &Config{Namenodes: []string{"http://nn1:9870/webhdfs"}}, err: "invalid namenode http://nn1:9870/webhdfs, expected http://host:port or https://host:port"},
+		{config: &Config{Namenodes: []string{"https://nn1:9871", "http://nn2:9870"}}, err: "invalid namenode http://nn2:9870, the namenodes are all reached over https"},
+		{config: &Config{Namenodes: []string{"http://nn1:9870"}, BasePath: "fabric"}, err: "invalid base path fabric, expected an absolute path"},
+		{config: &Config{Namenodes: []string{"http://nn1:9870"}, Kerberos: &KerberosConfig{Principal: "peer@EXAMPLE.COM"}}, err: "the Kerberos authentication requires a keytab and a principal"},
+		{config: &Config{Namenodes: []string{"http://nn1:9870"}, Kerberos: &KerberosConfig{Keytab: "peer.keytab", Principal: "peer"}}, err: "invalid Kerberos principal peer, expected name@REALM"},
+		{config: &Config{Namenodes: []string{"http://nn1:9870"}, User: "fabric", Kerberos: &KerberosConfig{Keytab: "peer.keytab", Principal: "peer@EXAMPLE.COM"}},
+			err: "the user is taken from the Kerberos principal, it cannot be set along with the Kerberos authentication"},
+	}
+	for _, test := range tests {
+		err := test.config.Validate()
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+
+	assert.Equal(t, "webhdfs://nn1:9870,nn2:9870/fabric", (&Config{Namenodes: []string{"http://nn1:9870", "http://nn2:9870"}, BasePath: "/fabric/"}).URL())
+	assert.Equal(t, "swebhdfs://nn1:9871/", (&Config{Namenodes: []string{"https://nn1:9871"}}).URL())
+	assert.True(t, IsURL("swebhdfs://nn1:9871/"))
+	assert.False(t, IsURL("sftp://ledger-bank:222"))
+}
+
+func newTestClient(t *testing.T, config *Config) (*Client, *webhdfstest.Server, func()) {
+	rootDir, err := ioutil.TempDir("", "webhdfs")
+	require.NoError(t, err)
+	server := webhdfstest.NewServer(rootDir)
+	if len(config.Namenodes) == 0 {
+		config.Namenodes = []string{server.URL}
+	}
+	client, err := NewClient(config)
+	require.NoError(t, err)
+	return client, server, func() {
+		client.Close()
+		server.Close()
+		os.RemoveAll(rootDir)
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	client, server, cleanup := newTestClient(t, &Config{BasePath: "/fabric", User: "peer"})
+	defer cleanup()
+	server.User = "peer"
+
+	content := []byte("the content of an archived blockfile")
+	require.NoError(t, client.MkdirAll("/chains/ch1"))
+	f, err := client.Create("/chains/ch1/blockfile_000000")
+	require.NoError(t, err)
+	_, err = f.Write(content[:10])
+	require.NoError(t, err)
+	_, err = f.Write(content[10:])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	stored, err := ioutil.ReadFile(filepath.Join(server.RootDir, "fabric", "chains", "ch1", "blockfile_000000"))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+
+	info, err := client.Stat("/chains/ch1/blockfile_000000")
+	require.NoError(t, err)
+	assert.Equal(t, "blockfile_000000", info.Name())
+	assert.Equal(t, int64(len(content)), info.Size())
+	assert.False(t, info.IsDir())
+	infos, err := client.ReadDir("/chains/ch1")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "blockfile_000000", infos[0].Name())
+
+	f, err = client.Open("/chains/ch1/blockfile_000000")
+	require.NoError(t, err)
+	defer f.Close()
+	read, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, content, read)
+	part := make([]byte, 7)
+	n, err := f.ReadAt(part, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(part[:n]))
+	n, err = f.ReadAt(make([]byte, 10), int64(len(content))-3)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 3, n)
+	offset, err := f.Seek(-9, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content))-9, offset)
+	read, err = ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "blockfile", string(read))
+
+	// An empty file is created once closed
+	f, err = client.Create("/chains/ch1/empty")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	info, err = client.Stat("/chains/ch1/empty")
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestFileOperations(t *testing.T) {
+	client, _, cleanup := newTestClient(t, &Config{BasePath: "/fabric"})
+	defer cleanup()
+
+	write := func(p, content string) {
+		f, err := client.Create(p)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	read := func(p string) string {
+		f, err := client.Open(p)
+		require.NoError(t, err)
+		defer f.Close()
+		content, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		return string(content)
+	}
+	require.NoError(t, client.MkdirAll("/dir"))
+	write("/dir/file.uploading", "content")
+
+	// The missing files are reported like the ones of the local file system
+	_, err := client.Stat("/dir/missing")
+	assert.True(t, os.IsNotExist(err))
+	_, err = client.Open("/dir/missing")
+	assert.True(t, os.IsNotExist(err))
+	_, err = client.ReadDir("/missing")
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, os.IsNotExist(client.Remove("/dir/missing")))
+	assert.True(t, os.IsNotExist(client.Rename("/dir/missing", "/dir/file")))
+
+	require.NoError(t, client.Rename("/dir/file.uploading", "/dir/file"))
+	assert.Equal(t, "content", read("/dir/file"))
+	write("/dir/other", "other")
+	assert.True(t, os.IsExist(client.Rename("/dir/other", "/dir/file")))
+	assert.Equal(t, "content", read("/dir/file"))
+
+	// A non empty directory is not removed
+	assert.Error(t, client.RemoveDirectory("/dir"))
+	require.NoError(t, client.Remove("/dir/file"))
+	require.NoError(t, client.Remove("/dir/other"))
+	require.NoError(t, client.RemoveDirectory("/dir"))
+
+	wd, err := client.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, "/fabric", wd)
+}
+
+func TestOpenFileForResume(t *testing.T) {
+	client, server, cleanup := newTestClient(t, &Config{})
+	defer cleanup()
+	f, err := client.Create("/upload")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// The part written past the offset is truncated before the content is appended
+	f, err = client.OpenFile("/upload", os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = f.Seek(6, io.SeekStart)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("6789abc"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	stored, err := ioutil.ReadFile(filepath.Join(server.RootDir, "upload"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abc", string(stored))
+	assert.Equal(t, 1, server.Operations()["TRUNCATE"])
+
+	// The offset of an append is the end of the file, which is not truncated
+	f, err = client.OpenFile("/upload", os.O_WRONLY|os.O_APPEND)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("def"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	stored, err = ioutil.ReadFile(filepath.Join(server.RootDir, "upload"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", string(stored))
+	assert.Equal(t, 1, server.Operations()["TRUNCATE"])
+
+	f, err = client.OpenFile("/upload", os.O_WRONLY)
+	require.NoError(t, err)
+	_, err = f.Seek(100, io.SeekStart)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("x"))
+	assert.EqualError(t, err, "offset 100 is past the end of /upload of 16 bytes")
+	_, err = client.OpenFile("/missing", os.O_WRONLY)
+	assert.True(t, os.IsNotExist(err))
+	_, err = client.OpenFile("/upload", os.O_RDWR)
+	assert.Error(t, err)
+}
+
+func TestNamenodeFailover(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "webhdfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+	nn1 := webhdfstest.NewServer(rootDir)
+	defer nn1.Close()
+	nn2 := webhdfstest.NewServer(rootDir)
+	defer nn2.Close()
+	nn1.SetStandby(true)
+
+	client, err := NewClient(&Config{Namenodes: []string{nn1.URL, nn2.URL}})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The standby namenode is failed over to the active one, which then serves the requests
+	require.NoError(t, client.MkdirAll("/dir"))
+	assert.Equal(t, 1, nn2.Operations()["MKDIRS"])
+	_, err = client.Stat("/dir")
+	require.NoError(t, err)
+	assert.Equal(t, 1, nn2.Operations()["GETFILESTATUS"])
+
+	// The namenodes fail over again once the active one goes in standby
+	nn1.SetStandby(false)
+	nn2.SetStandby(true)
+	_, err = client.Stat("/dir")
+	require.NoError(t, err)
+	assert.Equal(t, 1, nn1.Operations()["GETFILESTATUS"])
+
+	// An unreachable namenode is failed over too, the request fails once no namenode serves it
+	nn2.Close()
+	_, err = client.Stat("/dir")
+	require.NoError(t, err)
+	nn1.SetStandby(true)
+	_, err = client.Stat("/dir")
+	assert.Contains(t, err.Error(), "error sending GETFILESTATUS /dir to namenode "+nn2.URL)
+}
+
+func TestKerberosLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhdfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	krb5Conf := filepath.Join(dir, "krb5.conf")
+	require.NoError(t, ioutil.WriteFile(krb5Conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0644))
+
+	config := &Config{Namenodes: []string{"http://nn1:9870"}, Kerberos: &KerberosConfig{
+		Keytab:    filepath.Join(dir, "peer.keytab"),
+		Principal: "peer@EXAMPLE.COM",
+		Krb5Conf:  filepath.Join(dir, "missing.conf"),
+	}}
+	_, err = NewClient(config)
+	assert.Contains(t, err.Error(), "error reading Kerberos configuration "+filepath.Join(dir, "missing.conf"))
+	config.Kerberos.Krb5Conf = krb5Conf
+	_, err = NewClient(config)
+	assert.Contains(t, err.Error(), "error reading keytab "+filepath.Join(dir, "peer.keytab"))
+}
@@ -0,0 +1,118 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package webhdfs
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Config is the configuration of a client of the WebHDFS REST API of an HDFS cluster
+type Config struct {
+	// Namenodes are the HTTP endpoints of the namenodes, http://host:port or https://host:port, e.g. the ones
+	// of the active and the standby namenodes of a highly available cluster. The requests are sent to the
+	// namenode which answered last, and fail over to the next one when it is unreachable or in standby.
+	Namenodes []string
+	// BasePath is the directory of HDFS under which the paths of the client are, / when empty
+	BasePath string
+	// User is the user the requests are made as on a cluster with the simple authentication, i.e. without
+	// Kerberos. The namenodes take their default user when it is empty.
+	User string
+	// Kerberos is the Kerberos authentication of the client, nil on a cluster with the simple authentication
+	Kerberos *KerberosConfig
+	// TLSConfig is the TLS configuration of the https endpoints, the defaults of Go when nil
+	TLSConfig *tls.Config
+	// Dial opens the connections to the namenodes and the datanodes, such as through a proxy, net.Dial when nil
+	Dial func(network, address string) (net.Conn, error)
+}
+
+// KerberosConfig is the Kerberos authentication of the client, which logs in with the key of its principal
+// read from a keytab and authenticates to the namenodes with SPNEGO. The datanodes are accessed with the
+// delegation tokens issued by the namenodes.
+type KerberosConfig struct {
+	// Keytab is the keytab file holding the key of the principal
+	Keytab string
+	// Principal is the principal the client logs in as, user@REALM or service/host@REALM
+	Principal string
+	// Krb5Conf is the Kerberos configuration file, /etc/krb5.conf when empty
+	Krb5Conf string
+	// ServicePrincipal is the principal of the namenodes, in which _HOST is replaced with the host of the
+	// namenode, e.g. HTTP/_HOST@EXAMPLE.COM. HTTP/<host of the namenode> when empty.
+	ServicePrincipal string
+}
+
+// defaultKrb5Conf is the Kerberos configuration file read when KerberosConfig.Krb5Conf is empty
+const defaultKrb5Conf = "/etc/krb5.conf"
+
+// Validate checks the namenodes, the base path and the Kerberos settings
+func (c *Config) Validate() error {
+	if len(c.Namenodes) == 0 {
+		return errors.New("no namenode configured")
+	}
+	var scheme string
+	for _, namenode := range c.Namenodes {
+		u, err := url.Parse(namenode)
+		if err != nil {
+			return errors.Wrapf(err, "invalid namenode %s", namenode)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return errors.Errorf("invalid namenode %s, expected http://host:port or https://host:port", namenode)
+		}
+		if scheme != "" && u.Scheme != scheme {
+			return errors.Errorf("invalid namenode %s, the namenodes are all reached over %s", namenode, scheme)
+		}
+		scheme = u.Scheme
+	}
+	if c.BasePath != "" && !path.IsAbs(c.BasePath) {
+		return errors.Errorf("invalid base path %s, expected an absolute path", c.BasePath)
+	}
+	if k := c.Kerberos; k != nil {
+		if k.Keytab == "" || k.Principal == "" {
+			return errors.New("the Kerberos authentication requires a keytab and a principal")
+		}
+		if _, _, err := splitPrincipal(k.Principal); err != nil {
+			return err
+		}
+		if c.User != "" {
+			return errors.New("the user is taken from the Kerberos principal, it cannot be set along with the Kerberos authentication")
+		}
+	}
+	return nil
+}
+
+// URL returns the URL of the base path of the cluster in the form of the HDFS clients, webhdfs://host:port/path
+// or swebhdfs://host:port/path over https, listing the hosts of all the namenodes
+func (c *Config) URL() string {
+	scheme := "webhdfs"
+	var hosts []string
+	for _, namenode := range c.Namenodes {
+		if u, err := url.Parse(namenode); err == nil {
+			if u.Scheme == "https" {
+				scheme = "swebhdfs"
+			}
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return scheme + "://" + strings.Join(hosts, ",") + path.Join("/", c.BasePath)
+}
+
+// IsURL tells if a repository URL is the one of a cluster returned by URL
+func IsURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "webhdfs://") || strings.HasPrefix(rawURL, "swebhdfs://")
+}
+
+// splitPrincipal splits a principal into its name and its realm
+func splitPrincipal(principal string) (string, string, error) {
+	i := strings.LastIndex(principal, "@")
+	if i <= 0 || i == len(principal)-1 {
+		return "", "", errors.Errorf("invalid Kerberos principal %s, expected name@REALM", principal)
+	}
+	return principal[:i], principal[i+1:], nil
+}
@@ -0,0 +1,208 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package webhdfs
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// File is a file of HDFS opened for reading or for writing. The content of a file opened for reading is streamed
+// from a datanode from the offset it is seeked to, and the content written is streamed to a datanode until the
+// file is closed.
+type File struct {
+	client *Client
+	path   string
+	info   os.FileInfo
+	offset int64
+	// body is the content read from the datanode from offset
+	body io.ReadCloser
+
+	writing bool
+	// pipe is the content streamed to the datanode, nil until the first write of a file opened by OpenFile
+	pipe *io.PipeWriter
+	// written is closed with err set once the datanode has answered
+	written chan struct{}
+	err     error
+}
+
+// Name returns the path of the file
+func (f *File) Name() string {
+	return f.path
+}
+
+// Stat returns the status of the file
+func (f *File) Stat() (os.FileInfo, error) {
+	if f.info == nil || f.writing {
+		return f.client.Stat(f.path)
+	}
+	return f.info, nil
+}
+
+// Read reads the content of the file from the current offset
+func (f *File) Read(p []byte) (int, error) {
+	if f.writing {
+		return 0, errors.Errorf("%s is open for writing", f.path)
+	}
+	if f.body == nil {
+		if f.offset >= f.info.Size() {
+			return 0, io.EOF
+		}
+		body, err := f.open(f.offset, 0)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt reads len(p) bytes of the file from the offset off
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.writing {
+		return 0, errors.Errorf("%s is open for writing", f.path)
+	}
+	if off >= f.info.Size() {
+		return 0, io.EOF
+	}
+	body, err := f.open(off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// open streams the content of the file from the offset, length bytes of it or up to the end when length is 0
+func (f *File) open(offset, length int64) (io.ReadCloser, error) {
+	params := url.Values{"offset": {strconv.FormatInt(offset, 10)}}
+	if length > 0 {
+		params.Set("length", strconv.FormatInt(length, 10))
+	}
+	location, err := f.client.redirect(http.MethodGet, f.path, "OPEN", params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.sendToDatanode(http.MethodGet, location, nil, http.StatusOK)
+	if err != nil {
+		return nil, pathError("open", f.path, err)
+	}
+	return resp.Body, nil
+}
+
+// Seek sets the offset from which the file is read, or from which it is written before the first write
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if f.pipe != nil {
+		return 0, errors.Errorf("%s cannot be seeked once written", f.path)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += info.Size()
+	default:
+		return 0, errors.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.Errorf("invalid offset %d", offset)
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Write streams the content to the datanode
+func (f *File) Write(p []byte) (int, error) {
+	if !f.writing {
+		return 0, errors.Errorf("%s is open for reading", f.path)
+	}
+	if f.pipe == nil {
+		if err := f.append(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.pipe.Write(p)
+	if err != nil {
+		<-f.written
+		if f.err != nil {
+			err = f.err
+		}
+	}
+	return n, err
+}
+
+// append starts writing a file opened by OpenFile at its offset, truncating the content past the offset first
+func (f *File) append() error {
+	if f.offset > f.info.Size() {
+		return errors.Errorf("offset %d is past the end of %s of %d bytes", f.offset, f.path, f.info.Size())
+	}
+	if f.offset < f.info.Size() {
+		truncated, err := f.client.callBoolean(http.MethodPost, f.path, "TRUNCATE", url.Values{"newlength": {strconv.FormatInt(f.offset, 10)}})
+		if err != nil {
+			return err
+		}
+		if !truncated {
+			// The last block is recovered in the background before the file can be appended
+			return errors.Errorf("truncating %s to %d bytes is in progress", f.path, f.offset)
+		}
+	}
+	location, err := f.client.redirect(http.MethodPost, f.path, "APPEND", nil)
+	if err != nil {
+		return err
+	}
+	f.startWrite(http.MethodPost, location, http.StatusOK)
+	return nil
+}
+
+// startWrite streams the content written to the datanode
+func (f *File) startWrite(method, location string, expected int) {
+	reader, writer := io.Pipe()
+	f.pipe = writer
+	f.written = make(chan struct{})
+	go func() {
+		defer close(f.written)
+		resp, err := f.client.sendToDatanode(method, location, reader, expected)
+		if err != nil {
+			f.err = pathError("write", f.path, err)
+			reader.CloseWithError(f.err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// Close closes the file, and completes the content written to the datanode
+func (f *File) Close() error {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	if f.pipe == nil {
+		return nil
+	}
+	f.pipe.Close()
+	<-f.written
+	f.pipe = nil
+	return f.err
+}
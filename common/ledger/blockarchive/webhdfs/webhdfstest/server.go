@@ -0,0 +1,251 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// Package webhdfstest provides a WebHDFS server backed by a local directory, serving as both the namenode and
+// the datanode of a cluster, for the tests of the clients of the WebHDFS REST API
+package webhdfstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	namenodePrefix = "/webhdfs/v1"
+	datanodePrefix = "/datanode/v1"
+)
+
+// Server is a WebHDFS server storing the files of HDFS under RootDir
+type Server struct {
+	*httptest.Server
+	RootDir string
+	// User is the user.name the requests must be made as when not empty
+	User string
+
+	mutex   sync.Mutex
+	standby bool
+	ops     map[string]int
+}
+
+// NewServer starts a server storing the files under rootDir
+func NewServer(rootDir string) *Server {
+	s := &Server{RootDir: rootDir, ops: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// SetStandby sets whether the namenode of the server is in standby, refusing the requests with a StandbyException
+func (s *Server) SetStandby(standby bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.standby = standby
+}
+
+// Operations returns the number of requests served by the namenode by operation
+func (s *Server) Operations() map[string]int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ops := map[string]int{}
+	for op, n := range s.ops {
+		ops[op] = n
+	}
+	return ops
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, namenodePrefix):
+		s.serveNamenode(w, r, strings.TrimPrefix(r.URL.Path, namenodePrefix))
+	case strings.HasPrefix(r.URL.Path, datanodePrefix):
+		s.serveDatanode(w, r, strings.TrimPrefix(r.URL.Path, datanodePrefix))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveNamenode(w http.ResponseWriter, r *http.Request, p string) {
+	query := r.URL.Query()
+	op := strings.ToUpper(query.Get("op"))
+	s.mutex.Lock()
+	standby := s.standby
+	if !standby {
+		s.ops[op]++
+	}
+	s.mutex.Unlock()
+	if standby {
+		remoteException(w, http.StatusForbidden, "StandbyException", "Operation category READ is not supported in state standby")
+		return
+	}
+	if s.User != "" && query.Get("user.name") != s.User {
+		remoteException(w, http.StatusUnauthorized, "SecurityException", "Failed to obtain user group information")
+		return
+	}
+	local := s.localPath(p)
+	switch op {
+	case "GETFILESTATUS":
+		info, err := os.Stat(local)
+		if err != nil {
+			fileNotFound(w, p)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"FileStatus": fileStatus(info, "")})
+	case "LISTSTATUS":
+		infos, err := ioutil.ReadDir(local)
+		if err != nil {
+			fileNotFound(w, p)
+			return
+		}
+		statuses := []interface{}{}
+		for _, info := range infos {
+			statuses = append(statuses, fileStatus(info, info.Name()))
+		}
+		writeJSON(w, map[string]interface{}{"FileStatuses": map[string]interface{}{"FileStatus": statuses}})
+	case "GETHOMEDIRECTORY":
+		writeJSON(w, map[string]interface{}{"Path": "/user/" + query.Get("user.name")})
+	case "MKDIRS":
+		writeJSON(w, map[string]interface{}{"boolean": os.MkdirAll(local, 0755) == nil})
+	case "DELETE":
+		err := os.Remove(local)
+		if err != nil && !os.IsNotExist(err) {
+			remoteException(w, http.StatusForbidden, "PathIsNotEmptyDirectoryException", fmt.Sprintf("%s is non empty", p))
+			return
+		}
+		writeJSON(w, map[string]interface{}{"boolean": err == nil})
+	case "RENAME":
+		destination := s.localPath(query.Get("destination"))
+		_, srcErr := os.Stat(local)
+		_, dstErr := os.Stat(destination)
+		renamed := srcErr == nil && os.IsNotExist(dstErr) && os.Rename(local, destination) == nil
+		writeJSON(w, map[string]interface{}{"boolean": renamed})
+	case "TRUNCATE":
+		length, err := strconv.ParseInt(query.Get("newlength"), 10, 64)
+		if err != nil {
+			remoteException(w, http.StatusBadRequest, "IllegalArgumentException", "invalid newlength")
+			return
+		}
+		if err := os.Truncate(local, length); err != nil {
+			fileNotFound(w, p)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"boolean": true})
+	case "OPEN", "APPEND":
+		if _, err := os.Stat(local); err != nil {
+			fileNotFound(w, p)
+			return
+		}
+		s.redirectToDatanode(w, r, p)
+	case "CREATE":
+		s.redirectToDatanode(w, r, p)
+	default:
+		remoteException(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid value for webhdfs parameter \"op\": "+op)
+	}
+}
+
+// redirectToDatanode redirects a request to the datanode, which is the server itself
+func (s *Server) redirectToDatanode(w http.ResponseWriter, r *http.Request, p string) {
+	query := r.URL.Query()
+	query.Set("namenoderpcaddress", "localhost:8020")
+	w.Header().Set("Location", s.URL+datanodePrefix+r.URL.EscapedPath()[len(namenodePrefix):]+"?"+query.Encode())
+	w.WriteHeader(http.StatusTemporaryRedirect)
+}
+
+func (s *Server) serveDatanode(w http.ResponseWriter, r *http.Request, p string) {
+	query := r.URL.Query()
+	local := s.localPath(p)
+	switch op := strings.ToUpper(query.Get("op")); op {
+	case "OPEN":
+		file, err := os.Open(local)
+		if err != nil {
+			fileNotFound(w, p)
+			return
+		}
+		defer file.Close()
+		offset, _ := strconv.ParseInt(query.Get("offset"), 10, 64)
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			remoteException(w, http.StatusBadRequest, "IOException", err.Error())
+			return
+		}
+		var content io.Reader = file
+		if length, err := strconv.ParseInt(query.Get("length"), 10, 64); err == nil {
+			content = io.LimitReader(file, length)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.Copy(w, content)
+	case "CREATE", "APPEND":
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		status := http.StatusCreated
+		if op == "APPEND" {
+			flags, status = os.O_WRONLY|os.O_APPEND, http.StatusOK
+		}
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			remoteException(w, http.StatusInternalServerError, "IOException", err.Error())
+			return
+		}
+		file, err := os.OpenFile(local, flags, 0644)
+		if err != nil {
+			fileNotFound(w, p)
+			return
+		}
+		_, err = io.Copy(file, r.Body)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			remoteException(w, http.StatusInternalServerError, "IOException", err.Error())
+			return
+		}
+		w.WriteHeader(status)
+	default:
+		remoteException(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid value for webhdfs parameter \"op\": "+op)
+	}
+}
+
+// localPath returns the path under RootDir of a path of HDFS
+func (s *Server) localPath(p string) string {
+	return filepath.Join(s.RootDir, filepath.FromSlash(filepath.Clean("/"+p)))
+}
+
+func fileStatus(info os.FileInfo, pathSuffix string) map[string]interface{} {
+	fileType := "FILE"
+	if info.IsDir() {
+		fileType = "DIRECTORY"
+	}
+	return map[string]interface{}{
+		"pathSuffix":       pathSuffix,
+		"type":             fileType,
+		"length":           info.Size(),
+		"permission":       strconv.FormatUint(uint64(info.Mode().Perm()), 8),
+		"modificationTime": info.ModTime().UnixNano() / 1e6,
+		"owner":            "hdfs",
+		"group":            "supergroup",
+	}
+}
+
+func fileNotFound(w http.ResponseWriter, p string) {
+	remoteException(w, http.StatusNotFound, "FileNotFoundException", "File does not exist: "+p)
+}
+
+func remoteException(w http.ResponseWriter, status int, exception, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"RemoteException": map[string]string{
+		"exception":     exception,
+		"javaClassName": "org.apache.hadoop." + exception,
+		"message":       message,
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
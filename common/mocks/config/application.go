@@ -8,11 +8,13 @@ package config
 
 import (
 	"github.com/hyperledger/fabric/common/channelconfig"
+	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
 type MockApplication struct {
-	CapabilitiesRv channelconfig.ApplicationCapabilities
-	Acls           map[string]string
+	CapabilitiesRv   channelconfig.ApplicationCapabilities
+	Acls             map[string]string
+	BlockArchivingRv *pb.BlockArchiving
 }
 
 func (m *MockApplication) Organizations() map[string]channelconfig.ApplicationOrg {
@@ -23,6 +25,10 @@ func (m *MockApplication) Capabilities() channelconfig.ApplicationCapabilities {
 	return m.CapabilitiesRv
 }
 
+func (m *MockApplication) BlockArchiving() *pb.BlockArchiving {
+	return m.BlockArchivingRv
+}
+
 func (m *MockApplication) PolicyRefForAPI(apiName string) string {
 	if m.Acls == nil {
 		return ""
@@ -105,6 +105,8 @@ func (ccv *DynamicApplicationConfigValue) StaticallyOpaqueFieldProto(name string
 		return &common.Capabilities{}, nil
 	case "ACLs":
 		return &peer.ACLs{}, nil
+	case "BlockArchiving":
+		return &peer.BlockArchiving{}, nil
 	default:
 		return nil, fmt.Errorf("Unknown Application ConfigValue name: %s", ccv.name)
 	}
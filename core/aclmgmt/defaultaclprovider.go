@@ -93,6 +93,14 @@ func (d *defaultACLProviderImpl) initialize() {
 	d.cResourcePolicyMap[resources.Qscc_GetTransactionByID] = CHANNELREADERS
 	d.cResourcePolicyMap[resources.Qscc_GetBlockByTxID] = CHANNELREADERS
 
+	//-------------- ASCC --------------
+	//p resources (none)
+
+	//c resources
+	d.cResourcePolicyMap[resources.Ascc_IsBlockArchived] = CHANNELREADERS
+	d.cResourcePolicyMap[resources.Ascc_GetArchiveLocation] = CHANNELREADERS
+	d.cResourcePolicyMap[resources.Ascc_GetArchivedRanges] = CHANNELREADERS
+
 	//--------------- CSCC resources -----------
 	//p resources (implemented by the chaincode currently)
 	d.pResourcePolicyMap[resources.Cscc_JoinChain] = mgmt.Admins
@@ -39,6 +39,11 @@ const (
 	Qscc_GetTransactionByID = "qscc/GetTransactionByID"
 	Qscc_GetBlockByTxID     = "qscc/GetBlockByTxID"
 
+	//Ascc resources
+	Ascc_IsBlockArchived    = "ascc/IsBlockArchived"
+	Ascc_GetArchiveLocation = "ascc/GetArchiveLocation"
+	Ascc_GetArchivedRanges  = "ascc/GetArchivedRanges"
+
 	//Cscc resources
 	Cscc_JoinChain                = "cscc/JoinChain"
 	Cscc_GetConfigBlock           = "cscc/GetConfigBlock"
@@ -0,0 +1,456 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/common/metrics/disabled"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// loggerAccess logs the retrievals recorded in the access audit log, when they are emitted
+var loggerAccess = flogging.MustGetLogger("archiver.access")
+
+// rotatedAccessLogTimeFormat is the suffix of the rotated access audit logs, which sorts them by time
+const rotatedAccessLogTimeFormat = "20060102T150405.000000000Z"
+
+var accessRetrievals = metrics.CounterOpts{
+	Namespace:    "archiver",
+	Subsystem:    "access",
+	Name:         "retrievals",
+	Help:         "The number of retrievals of archived blocks and blockfiles served by the peer, by API and outcome.",
+	LabelNames:   []string{"channel", "api", "status"},
+	StatsdFormat: "%{#fqname}.%{channel}.%{api}.%{status}",
+}
+
+var accessBytes = metrics.CounterOpts{
+	Namespace:    "archiver",
+	Subsystem:    "access",
+	Name:         "bytes",
+	Help:         "The number of bytes of archived blocks and blockfiles sent by the peer, by API.",
+	LabelNames:   []string{"channel", "api"},
+	StatsdFormat: "%{#fqname}.%{channel}.%{api}",
+}
+
+// AccessAuditEntry records a retrieval of archived blocks or of a blockfile served by the peer
+type AccessAuditEntry struct {
+	// Time is the time at which the request was received
+	Time time.Time `json:"time"`
+	// API is the gRPC method or the path of the operations endpoint of the request
+	API string `json:"api"`
+	// Requester is the MSP ID of the identity which signed the request and the subject of its certificate,
+	// or the subject of the client certificate on the operations endpoint
+	Requester string `json:"requester,omitempty"`
+	// Address is the address of the requester
+	Address   string `json:"address"`
+	ChannelID string `json:"channel,omitempty"`
+	// Blockfile is the number of the blockfile requested, and ByteRange its range of bytes if not all of them
+	Blockfile *uint64 `json:"blockfile,omitempty"`
+	ByteRange string  `json:"byteRange,omitempty"`
+	// FirstBlock and LastBlock are the range of blocks requested
+	FirstBlock *uint64 `json:"firstBlock,omitempty"`
+	LastBlock  *uint64 `json:"lastBlock,omitempty"`
+	// Bytes is the number of bytes sent
+	Bytes      int64 `json:"bytes"`
+	DurationMs int64 `json:"durationMs"`
+	// Status is the gRPC status code or the HTTP status of the response
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (e *AccessAuditEntry) setBlockfile(fileNum uint64) {
+	e.Blockfile = &fileNum
+}
+
+func (e *AccessAuditEntry) setBlocks(first, last uint64) {
+	e.FirstBlock, e.LastBlock = &first, &last
+}
+
+// AccessAuditConfig is the configuration of an access audit log
+type AccessAuditConfig struct {
+	// File is the file the entries are appended to
+	File string
+	// MaxSize is the size in bytes beyond which the file is rotated, never when 0
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept, all of them when 0
+	MaxBackups int
+	// Emit is whether the entries are also logged and counted by the metrics
+	Emit bool
+}
+
+// AccessAuditLog records the retrievals of the archived blocks and blockfiles served by the peer, for the
+// security reviews of the access to the historical data. The entries are appended to a local file, one JSON
+// entry per line, which is rotated by size. The retrievals are recorded by the interceptors of the gRPC server
+// and by the handlers of the operations endpoint; a nil log records nothing.
+type AccessAuditLog struct {
+	config     AccessAuditConfig
+	retrievals metrics.Counter
+	bytes      metrics.Counter
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenAccessAuditLog opens the access audit log configured by ledger.blockArchiver.accessAudit, emitting to
+// the metrics of provider if requested. It returns nil if the access audit is disabled.
+func OpenAccessAuditLog(provider metrics.Provider) (*AccessAuditLog, error) {
+	if !ledgerconfig.IsAccessAuditEnabled() {
+		return nil, nil
+	}
+	maxSize, maxBackups := ledgerconfig.GetAccessAuditRotation()
+	return NewAccessAuditLog(AccessAuditConfig{
+		File:       ledgerconfig.GetAccessAuditFile(),
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+		Emit:       ledgerconfig.IsAccessAuditEmitEnabled(),
+	}, provider)
+}
+
+// NewAccessAuditLog opens the access audit log of the configuration, creating its directory if needed
+func NewAccessAuditLog(config AccessAuditConfig, provider metrics.Provider) (*AccessAuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(config.File), 0750); err != nil {
+		return nil, errors.Wrapf(err, "error creating directory of access audit log %s", config.File)
+	}
+	l := &AccessAuditLog{config: config}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	if config.Emit {
+		if provider == nil {
+			provider = &disabled.Provider{}
+		}
+		l.retrievals = provider.NewCounter(accessRetrievals)
+		l.bytes = provider.NewCounter(accessBytes)
+	}
+	return l, nil
+}
+
+func (l *AccessAuditLog) open() error {
+	file, err := os.OpenFile(l.config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "error opening access audit log %s", l.config.File)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrapf(err, "error reading access audit log %s", l.config.File)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Record appends an entry to the log. The retrieval is not failed by its audit: the errors are logged.
+func (l *AccessAuditLog) Record(entry *AccessAuditEntry) {
+	if l == nil {
+		return
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		loggerArchive.Errorf("Could not marshal access audit entry: %s", err)
+		return
+	}
+	l.lock.Lock()
+	err = l.write(append(b, '\n'))
+	l.lock.Unlock()
+	if err != nil {
+		loggerArchive.Errorf("Could not record the retrieval of %s by [%s] in the access audit log: %s", entry.API, entry.Requester, err)
+	}
+	if !l.config.Emit {
+		return
+	}
+	fields := []interface{}{"api", entry.API, "requester", entry.Requester, "address", entry.Address,
+		blockarchive.LogKeyChannel, entry.ChannelID, blockarchive.LogKeyBytes, entry.Bytes,
+		blockarchive.LogKeyDurationMs, entry.DurationMs, "status", entry.Status}
+	if entry.Blockfile != nil {
+		fields = append(fields, blockarchive.LogKeyBlockfile, *entry.Blockfile)
+	}
+	if entry.FirstBlock != nil {
+		fields = append(fields, "firstBlock", *entry.FirstBlock, "lastBlock", *entry.LastBlock)
+	}
+	if entry.Error != "" {
+		fields = append(fields, "error", entry.Error)
+	}
+	loggerAccess.Infow("Archived data retrieved", fields...)
+	l.retrievals.With("channel", entry.ChannelID, "api", entry.API, "status", entry.Status).Add(1)
+	l.bytes.With("channel", entry.ChannelID, "api", entry.API).Add(float64(entry.Bytes))
+}
+
+func (l *AccessAuditLog) write(b []byte) error {
+	if l.file == nil {
+		return errors.New("the access audit log is closed")
+	}
+	if l.config.MaxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.config.MaxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return errors.Wrapf(err, "error writing access audit log %s", l.config.File)
+	}
+	return nil
+}
+
+// rotate renames the file with the time of the rotation, starts a new one, and removes the oldest
+// rotated files beyond the ones kept. The current file is appended to if it could not be renamed.
+func (l *AccessAuditLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		loggerArchive.Warningf("Error closing access audit log %s: %s", l.config.File, err)
+	}
+	l.file = nil
+	rotated := l.config.File + "." + time.Now().UTC().Format(rotatedAccessLogTimeFormat)
+	renameErr := os.Rename(l.config.File, rotated)
+	if err := l.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		loggerArchive.Warningf("Could not rotate access audit log %s: %s", l.config.File, renameErr)
+		return nil
+	}
+	if err := l.removeOldBackups(); err != nil {
+		loggerArchive.Warningf("Could not remove the old rotated access audit logs: %s", err)
+	}
+	return nil
+}
+
+// Backups returns the rotated files of the log, oldest first
+func (l *AccessAuditLog) Backups() ([]string, error) {
+	matches, err := filepath.Glob(l.config.File + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, match := range matches {
+		if _, err := time.Parse(rotatedAccessLogTimeFormat, strings.TrimPrefix(match, l.config.File+".")); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (l *AccessAuditLog) removeOldBackups() error {
+	if l.config.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := l.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > l.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Close syncs and closes the log, after which the retrievals are no longer recorded
+func (l *AccessAuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Sync()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file = nil
+	return err
+}
+
+// UnaryServerInterceptor returns the interceptor recording the retrievals of the archived blocks on the
+// gRPC server. It must precede the ChannelAuthorizer so that the denied requests are recorded as well.
+func (l *AccessAuditLog) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l == nil || !channelAuthorizedMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		entry := newGRPCAccessEntry(ctx, info.FullMethod, start)
+		request := &archive.ArchivedBlockRequest{}
+		if entry.setRequest(req, request) {
+			entry.setBlocks(request.BlockNumber, request.BlockNumber)
+		}
+		resp, err := handler(ctx, req)
+		if block, ok := resp.(*common.Block); ok && block != nil {
+			entry.Bytes = int64(proto.Size(block))
+		}
+		l.Record(entry.finish(start, err))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns the interceptor recording the retrievals of the archived blockfiles
+// on the gRPC server. It must precede the ChannelAuthorizer so that the denied requests are recorded as well.
+func (l *AccessAuditLog) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if l == nil || !channelAuthorizedMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		stream := &auditedStream{ServerStream: ss, entry: newGRPCAccessEntry(ss.Context(), info.FullMethod, start)}
+		err := handler(srv, stream)
+		l.Record(stream.entry.finish(start, err))
+		return err
+	}
+}
+
+func newGRPCAccessEntry(ctx context.Context, method string, start time.Time) *AccessAuditEntry {
+	return &AccessAuditEntry{Time: start.UTC(), API: method, Address: util.ExtractRemoteAddress(ctx)}
+}
+
+// setRequest fills the channel and the requester of the entry from the envelope of a request, and unmarshals
+// the request. It returns whether the request could be read.
+func (e *AccessAuditEntry) setRequest(req interface{}, request proto.Message) bool {
+	env, ok := req.(*common.Envelope)
+	if !ok || env == nil {
+		return false
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil || payload.Header == nil {
+		return false
+	}
+	if ch, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader); err == nil {
+		e.ChannelID = ch.ChannelId
+	}
+	if sh, err := protoutil.GetSignatureHeader(payload.Header.SignatureHeader); err == nil {
+		e.Requester = requesterOf(sh.Creator)
+	}
+	return proto.Unmarshal(payload.Data, request) == nil
+}
+
+func (e *AccessAuditEntry) finish(start time.Time, err error) *AccessAuditEntry {
+	e.DurationMs = blockarchive.LogDurationMs(start)
+	e.Status = status.Code(err).String()
+	if err != nil {
+		e.Error = status.Convert(err).Message()
+	}
+	return e
+}
+
+// requesterOf returns the MSP ID of a serialized identity, followed by the subject of its certificate
+func requesterOf(creator []byte) string {
+	identity := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, identity); err != nil {
+		return ""
+	}
+	if block, _ := pem.Decode(identity.IdBytes); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			return identity.Mspid + ":" + cert.Subject.String()
+		}
+	}
+	return identity.Mspid
+}
+
+// auditedStream records the request and the chunks sent on the server stream of a blockfile
+type auditedStream struct {
+	grpc.ServerStream
+	entry *AccessAuditEntry
+}
+
+func (s *auditedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	request := &archive.ArchivedBlockfileRequest{}
+	if s.entry.setRequest(m, request) {
+		s.entry.setBlockfile(request.BlockfileNo)
+	}
+	return nil
+}
+
+func (s *auditedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if chunk, ok := m.(*archive.BlockfileChunk); ok && err == nil {
+		s.entry.Bytes += int64(len(chunk.Content))
+	}
+	return err
+}
+
+// httpAccess records a retrieval served on the operations endpoint, with the status and the size of its response
+type httpAccess struct {
+	http.ResponseWriter
+	log    *AccessAuditLog
+	entry  *AccessAuditEntry
+	start  time.Time
+	status int
+}
+
+// beginHTTP starts recording a request of the operations endpoint, whose response is written to the returned
+// writer. The requester is the subject of the client certificate, when the endpoint requires one.
+func (l *AccessAuditLog) beginHTTP(w http.ResponseWriter, r *http.Request, api string) *httpAccess {
+	start := time.Now()
+	entry := &AccessAuditEntry{Time: start.UTC(), API: api, Address: r.RemoteAddr}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		entry.Requester = r.TLS.PeerCertificates[0].Subject.String()
+	}
+	return &httpAccess{ResponseWriter: w, log: l, entry: entry, start: start}
+}
+
+func (a *httpAccess) WriteHeader(status int) {
+	if a.status == 0 {
+		a.status = status
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *httpAccess) Write(b []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(b)
+	a.entry.Bytes += int64(n)
+	return n, err
+}
+
+// fail records that the response was interrupted after its status was sent
+func (a *httpAccess) fail(reason string) {
+	a.entry.Error = reason
+}
+
+// end records the retrieval once the response is complete
+func (a *httpAccess) end() {
+	if a.log == nil {
+		return
+	}
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	a.entry.Status = strconv.Itoa(a.status)
+	a.entry.DurationMs = blockarchive.LogDurationMs(a.start)
+	a.log.Record(a.entry)
+}
@@ -0,0 +1,219 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	archivev1 "github.com/hyperledger/fabric/protos/ledger/archive/v1"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mspSigner signs the requests with an identity of an MSP
+type mspSigner struct{ mspID string }
+
+func (mspSigner) Sign(message []byte) ([]byte, error) { return []byte("signature"), nil }
+func (s mspSigner) Serialize() ([]byte, error) {
+	return protoutil.MarshalOrPanic(&msp.SerializedIdentity{Mspid: s.mspID}), nil
+}
+
+// readAccessAuditLog returns the entries of an access audit log file
+func readAccessAuditLog(t *testing.T, file string) []*AccessAuditEntry {
+	content, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	var entries []*AccessAuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := &AccessAuditEntry{}
+		require.NoError(t, json.Unmarshal([]byte(line), entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAccessAuditLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "access-audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "audit", "access.log")
+	entry := &AccessAuditEntry{API: BlockExportPath, Address: "127.0.0.1:5000", ChannelID: "mychannel", Status: "200"}
+	b, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	// Each log holds two entries
+	log, err := NewAccessAuditLog(AccessAuditConfig{File: file, MaxSize: int64(2*len(b) + 2), MaxBackups: 2}, nil)
+	require.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		log.Record(entry)
+	}
+	require.NoError(t, log.Close())
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Len(t, readAccessAuditLog(t, file), 1)
+	backups, err := log.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	for _, backup := range backups {
+		assert.Len(t, readAccessAuditLog(t, backup), 2)
+	}
+
+	// The log is appended to when it is opened again, and nothing is recorded once it is closed
+	log, err = NewAccessAuditLog(AccessAuditConfig{File: file, MaxSize: 1024 * 1024}, nil)
+	require.NoError(t, err)
+	log.Record(entry)
+	require.NoError(t, log.Close())
+	log.Record(entry)
+	assert.Len(t, readAccessAuditLog(t, file), 2)
+
+	// A nil log records nothing
+	var disabled *AccessAuditLog
+	disabled.Record(entry)
+	assert.NoError(t, disabled.Close())
+}
+
+func TestAccessAuditInterceptors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "access-audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "access.log")
+	log, err := NewAccessAuditLog(AccessAuditConfig{File: file}, nil)
+	require.NoError(t, err)
+
+	authorizer := NewChannelAuthorizer(func(env *common.Envelope, channelID string) error {
+		if channelID != "mychannel" {
+			return errors.Errorf("not a reader of channel %s", channelID)
+		}
+		return nil
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(log.UnaryServerInterceptor(), authorizer.UnaryServerInterceptor())),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(log.StreamServerInterceptor(), authorizer.StreamServerInterceptor())),
+	)
+	blockfiles := &fakeBlockfileServer{content: []byte("blockfile content")}
+	archive.RegisterArchivedBlockProviderServer(server, fakeProvider{blockfiles})
+	archivev1.RegisterArchiverServiceServer(server, fakeArchiverService{NewArchiverService(nil), blockfiles})
+	go server.Serve(listener)
+	defer server.Stop()
+	address := listener.Addr().String()
+	dialOpts := func() []grpc.DialOption { return []grpc.DialOption{grpc.WithInsecure()} }
+	signer := mspSigner{mspID: "Org1MSP"}
+
+	// A blockfile served, and one denied
+	require.NoError(t, NewBlockfileFetcher(address, dialOpts, signer)("mychannel", 3, &bytes.Buffer{}))
+	err = NewBlockfileFetcher(address, dialOpts, signer)("otherchannel", 4, &bytes.Buffer{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// A block, and the handshake which is not recorded
+	conn, err := grpc.Dial(address, dialOpts()...)
+	require.NoError(t, err)
+	defer conn.Close()
+	env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, "mychannel", signer, &archive.ArchivedBlockRequest{BlockNumber: 7}, 0, 0)
+	require.NoError(t, err)
+	_, err = archive.NewArchivedBlockProviderClient(conn).GetBlock(context.Background(), env)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	_, err = archivev1.NewArchiverServiceClient(conn).Handshake(context.Background(), &archivev1.HandshakeRequest{
+		ProtocolVersions: []string{ProtocolVersionV1},
+	})
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	entries := readAccessAuditLog(t, file)
+	require.Len(t, entries, 3)
+	served := entries[0]
+	assert.Equal(t, "/archive.v1.ArchiverService/GetBlockfile", served.API)
+	assert.Equal(t, "Org1MSP", served.Requester)
+	assert.NotEmpty(t, served.Address)
+	assert.Equal(t, "mychannel", served.ChannelID)
+	require.NotNil(t, served.Blockfile)
+	assert.Equal(t, uint64(3), *served.Blockfile)
+	assert.Equal(t, int64(len("blockfile content")), served.Bytes)
+	assert.Equal(t, "OK", served.Status)
+
+	denied := entries[1]
+	assert.Equal(t, "otherchannel", denied.ChannelID)
+	require.NotNil(t, denied.Blockfile)
+	assert.Equal(t, uint64(4), *denied.Blockfile)
+	assert.Equal(t, int64(0), denied.Bytes)
+	assert.Equal(t, "PermissionDenied", denied.Status)
+	assert.Equal(t, "access denied", denied.Error)
+
+	block := entries[2]
+	assert.Equal(t, "/archive.ArchivedBlockProvider/GetBlock", block.API)
+	require.NotNil(t, block.FirstBlock)
+	assert.Equal(t, uint64(7), *block.FirstBlock)
+	assert.Equal(t, uint64(7), *block.LastBlock)
+	assert.Equal(t, "Unimplemented", block.Status)
+}
+
+func TestAccessAuditHTTPHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "access-audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "access.log")
+	// The retrievals are counted by the metrics as well
+	provider := &metricsfakes.Provider{}
+	counter := &metricsfakes.Counter{}
+	counter.WithReturns(counter)
+	provider.NewCounterReturns(counter)
+	log, err := NewAccessAuditLog(AccessAuditConfig{File: file, Emit: true}, provider)
+	require.NoError(t, err)
+
+	handler := &RangeExportHandler{
+		GetLedger:   func(channelID string) ledger.PeerLedger { return nil },
+		AccessAudit: log,
+		Authorizer:  NewChannelAuthorizer(func(env *common.Envelope, channelID string) error { return nil }),
+	}
+	req := httptest.NewRequest(http.MethodGet, RangeExportPath+"?channel=mychannel&from=2&to=5", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "operator"}}}}
+	require.NoError(t, SignHTTPRequest(req, "mychannel", mspSigner{mspID: "Org1MSP"}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	require.NoError(t, log.Close())
+
+	entries := readAccessAuditLog(t, file)
+	require.Len(t, entries, 1)
+	assert.Equal(t, RangeExportPath, entries[0].API)
+	// The requester who signed the request takes over from the TLS client
+	assert.Equal(t, "Org1MSP", entries[0].Requester)
+	assert.Equal(t, req.RemoteAddr, entries[0].Address)
+	assert.Equal(t, "mychannel", entries[0].ChannelID)
+	assert.Equal(t, "404", entries[0].Status)
+	assert.Equal(t, int64(rec.Body.Len()), entries[0].Bytes)
+	require.Equal(t, 2, counter.WithCallCount())
+	assert.Equal(t, []string{"channel", "mychannel", "api", RangeExportPath, "status", "404"}, counter.WithArgsForCall(0))
+	assert.Equal(t, float64(1), counter.AddArgsForCall(0))
+	assert.Equal(t, float64(rec.Body.Len()), counter.AddArgsForCall(1))
+}
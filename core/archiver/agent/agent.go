@@ -0,0 +1,163 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// Package agent archives the blockfiles of file ledgers written by another process, such as
+// the ledgers of an orderer, to the repository. It is also the library of the peer-archiver
+// sidecar, which archives the ledgers of a peer and reports the archived blockfiles to the peer.
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("archiver.agent")
+
+// Agent watches a ledger directory and archives the blockfiles of its ledgers
+type Agent struct {
+	config   *Config
+	archiver *fsblkstorage.LedgerArchiver
+	// reporter reports the archived blockfiles to the peer when the agent runs as its sidecar, nil otherwise
+	reporter reporter
+	// reported are the numbers of the blockfiles from which the archived blockfiles are still to be reported, by ledger
+	reported map[string]uint64
+
+	started  bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates an agent. The repository configuration applies to the whole process.
+func New(config *Config) (*Agent, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	blockarchive.BlockArchiverURL = config.Repository.URL
+	blockarchive.BlockArchiverDir = config.Repository.Dir
+	blockarchive.RepositoryTokenFile = config.Repository.TokenFile
+	blockarchive.ChecksumAlgorithm = config.ChecksumAlgorithm
+
+	archiver, err := fsblkstorage.NewLedgerArchiver(&fsblkstorage.LedgerArchiverConf{
+		BlockStorageDir: config.LedgerDir,
+		StateDir:        config.StateDir,
+		Each:            config.Each,
+		Keep:            config.Keep,
+		Discard:         config.Discard,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a := &Agent{
+		config:   config,
+		archiver: archiver,
+		reported: map[string]uint64{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if config.Peer != nil {
+		if a.reporter, err = newPeerReporter(config.Peer); err != nil {
+			archiver.Close()
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// ArchiveOnce archives the eligible blockfiles of the configured ledgers and returns
+// the number of archived blockfiles. The failure of a ledger doesn't prevent the
+// archiving of the others; the first failure is returned.
+func (a *Agent) ArchiveOnce() (int, error) {
+	ledgerIDs := a.config.Channels
+	if len(ledgerIDs) == 0 {
+		var err error
+		if ledgerIDs, err = a.archiver.ListLedgers(); err != nil {
+			return 0, err
+		}
+	}
+	total := 0
+	var firstErr error
+	for _, ledgerID := range ledgerIDs {
+		archived, err := a.archiver.ArchiveLedger(ledgerID)
+		total += archived
+		if err == nil && a.reporter != nil {
+			err = a.report(ledgerID)
+		}
+		if err != nil {
+			logger.Errorf("[%s] Failed archiving: %s", ledgerID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return total, firstErr
+}
+
+// report reports to the peer the archived blockfiles of a ledger it has not recorded yet. The blockfiles are
+// reported again after a restart of the agent, which the peer ignores.
+func (a *Agent) report(ledgerID string) error {
+	infos, err := a.archiver.Catalog(ledgerID).ListArchivedBlockfiles()
+	if err != nil {
+		return err
+	}
+	var pending []*archive.ArchivedBlockfileInfo
+	for _, info := range infos {
+		if info.BlockfileNo >= a.reported[ledgerID] {
+			pending = append(pending, info)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	resp, err := a.reporter.ReportArchived(ledgerID, pending)
+	if err != nil {
+		return errors.WithMessage(err, "error reporting the archived blockfiles")
+	}
+	for _, fileNum := range resp.Recorded {
+		if fileNum >= a.reported[ledgerID] {
+			a.reported[ledgerID] = fileNum + 1
+		}
+	}
+	logger.Infof("[%s] Reported %d archived blockfile(s) to the peer, %d recorded and %d discarded",
+		ledgerID, len(pending), len(resp.Recorded), resp.Discarded)
+	return nil
+}
+
+// Start starts checking the ledger directory periodically
+func (a *Agent) Start() {
+	a.started = true
+	logger.Infof("Archiving the ledgers in %s to %s every %s", a.config.LedgerDir, a.config.Repository.URL, a.config.Interval)
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.config.Interval)
+		defer ticker.Stop()
+		for {
+			if archived, err := a.ArchiveOnce(); err == nil && archived > 0 {
+				logger.Infof("Archived %d blockfile(s)", archived)
+			}
+			select {
+			case <-ticker.C:
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the agent, after the archiving in progress if any, and releases its resources
+func (a *Agent) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+		if a.started {
+			<-a.done
+		}
+		a.archiver.Close()
+	})
+}
@@ -0,0 +1,160 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package agent
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	defaultEach     = 30
+	defaultKeep     = 10
+	defaultInterval = time.Minute
+)
+
+// Config is the configuration of the block archiving agent
+type Config struct {
+	// LedgerDir is the directory of the file ledgers, e.g. FileLedger.Location of an orderer
+	LedgerDir string `yaml:"ledgerDir"`
+	// StateDir is the directory where the agent keeps the archive catalogs of the ledgers
+	StateDir string `yaml:"stateDir"`
+	// Channels are the channels whose ledgers are archived, all of them if empty
+	Channels []string `yaml:"channels"`
+	// Each is the number of blockfiles archived at once
+	Each int `yaml:"each"`
+	// Keep is the least number of blockfiles kept on the local file system
+	Keep int `yaml:"keep"`
+	// Discard indicates if the archived blockfiles are deleted from the local file system
+	Discard bool `yaml:"discard"`
+	// Interval is the period of the checks of the ledger directory
+	Interval time.Duration `yaml:"interval"`
+	// ChecksumAlgorithm is the algorithm of the checksums of the archived blockfiles
+	ChecksumAlgorithm string `yaml:"checksumAlgorithm"`
+	// Repository is the repository the blockfiles are archived to
+	Repository RepositoryConfig `yaml:"repository"`
+	// Peer is the archiver peer the archived blockfiles are reported to when the agent runs as the
+	// peer-archiver sidecar of the peer, nil otherwise
+	Peer *PeerConfig `yaml:"peer"`
+}
+
+// RepositoryConfig locates the repository
+type RepositoryConfig struct {
+	// URL is the address of the SFTP server of the repository, host:port, [ipv6]:port, sftp://host:port,
+	// or sftp+srv://domain to discover the servers through DNS SRV records
+	URL string `yaml:"url"`
+	// Dir is the directory below which the blockfiles are archived
+	Dir string `yaml:"dir"`
+	// TokenFile is the file holding the API token with which the agent authenticates
+	// to the repository, the default account is used if empty
+	TokenFile string `yaml:"tokenFile"`
+}
+
+// PeerConfig locates the archiver peer the peer-archiver sidecar reports to, and the identity the reports are signed with
+type PeerConfig struct {
+	// Address is the address of the peer, host:port
+	Address string `yaml:"address"`
+	// MSPConfigPath is the directory of the local MSP signing the reports, which must be an admin of the organization of the peer
+	MSPConfigPath string `yaml:"mspConfigPath"`
+	// MSPID is the identifier of the local MSP
+	MSPID string `yaml:"mspID"`
+	// TLS is the TLS configuration of the connection to the peer
+	TLS PeerTLSConfig `yaml:"tls"`
+}
+
+// PeerTLSConfig is the TLS configuration of the connection to the peer
+type PeerTLSConfig struct {
+	// Enabled indicates if the peer is reached over TLS
+	Enabled bool `yaml:"enabled"`
+	// RootCert is the file of the root certificate of the TLS CA of the peer
+	RootCert string `yaml:"rootCert"`
+	// ClientCert and ClientKey are the files of the TLS client certificate and key, when the peer requires client authentication
+	ClientCert string `yaml:"clientCert"`
+	ClientKey  string `yaml:"clientKey"`
+	// ServerNameOverride overrides the host name expected in the TLS certificate of the peer
+	ServerNameOverride string `yaml:"serverNameOverride"`
+}
+
+// LoadConfig reads the configuration of the agent from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	configBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading configuration file %s", path)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(configBytes, config); err != nil {
+		return nil, errors.Wrapf(err, "error parsing configuration file %s", path)
+	}
+	return config, nil
+}
+
+// validate checks the configuration and fills in the defaults
+func (c *Config) validate() error {
+	if c.LedgerDir == "" {
+		return errors.New("ledgerDir is not configured")
+	}
+	if c.StateDir == "" {
+		return errors.New("stateDir is not configured")
+	}
+	if c.Repository.URL == "" {
+		return errors.New("repository.url is not configured")
+	}
+	if _, err := blockarchive.ParseRepositoryURL(c.Repository.URL); err != nil {
+		return errors.WithMessage(err, "invalid repository.url")
+	}
+	if c.Each == 0 {
+		c.Each = defaultEach
+	}
+	if c.Each < 0 {
+		return errors.Errorf("invalid each: %d", c.Each)
+	}
+	if c.Keep == 0 {
+		c.Keep = defaultKeep
+	}
+	if c.Keep < 0 {
+		return errors.Errorf("invalid keep: %d", c.Keep)
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.ChecksumAlgorithm == "" {
+		c.ChecksumAlgorithm = blockarchive.ChecksumSHA256
+	}
+	if _, err := blockarchive.NewChecksumHash(c.ChecksumAlgorithm); err != nil {
+		return err
+	}
+	if c.Peer != nil {
+		return c.Peer.validate(c.Discard)
+	}
+	return nil
+}
+
+// validate checks the configuration of the peer the sidecar reports to. The sidecar only reads the ledger
+// directory of the peer, which discards the archived blockfiles itself.
+func (p *PeerConfig) validate(discard bool) error {
+	if discard {
+		return errors.New("discard must be false when reporting to a peer, the peer discards its archived blockfiles")
+	}
+	if p.Address == "" {
+		return errors.New("peer.address is not configured")
+	}
+	if p.MSPConfigPath == "" {
+		return errors.New("peer.mspConfigPath is not configured")
+	}
+	if p.MSPID == "" {
+		return errors.New("peer.mspID is not configured")
+	}
+	if p.TLS.Enabled && p.TLS.RootCert == "" {
+		return errors.New("peer.tls.rootCert is not configured")
+	}
+	if (p.TLS.ClientCert == "") != (p.TLS.ClientKey == "") {
+		return errors.New("peer.tls.clientCert and peer.tls.clientKey must be configured together")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	path := filepath.Join(testDir, "blockarchive-agent.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+ledgerDir: /var/hyperledger/production/orderer
+stateDir: /var/hyperledger/blockarchive-agent
+channels: [ch1]
+keep: 5
+discard: true
+interval: 30s
+repository:
+  url: repo:222
+  dir: /blkstore
+`), 0644))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.NoError(t, config.validate())
+	assert.Equal(t, &Config{
+		LedgerDir:         "/var/hyperledger/production/orderer",
+		StateDir:          "/var/hyperledger/blockarchive-agent",
+		Channels:          []string{"ch1"},
+		Each:              30,
+		Keep:              5,
+		Discard:           true,
+		Interval:          30 * time.Second,
+		ChecksumAlgorithm: "sha256",
+		Repository:        RepositoryConfig{URL: "repo:222", Dir: "/blkstore"},
+	}, config)
+
+	_, err = LoadConfig(filepath.Join(testDir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestConfigValidation(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			LedgerDir:  "/ledger",
+			StateDir:   "/state",
+			Repository: RepositoryConfig{URL: "repo:222"},
+			Peer:       &PeerConfig{Address: "peer0:7051", MSPConfigPath: "/msp", MSPID: "Org1MSP"},
+		}
+	}
+	require.NoError(t, valid().validate())
+	for _, tc := range []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{"no ledger dir", func(c *Config) { c.LedgerDir = "" }, "ledgerDir is not configured"},
+		{"no state dir", func(c *Config) { c.StateDir = "" }, "stateDir is not configured"},
+		{"no repository", func(c *Config) { c.Repository.URL = "" }, "repository.url is not configured"},
+		{"unsupported repository", func(c *Config) { c.Repository.URL = "s3://bucket" }, "invalid repository.url: unsupported repository URL s3://bucket"},
+		{"negative each", func(c *Config) { c.Each = -1 }, "invalid each: -1"},
+		{"negative keep", func(c *Config) { c.Keep = -1 }, "invalid keep: -1"},
+		{"unknown checksum", func(c *Config) { c.ChecksumAlgorithm = "md5" }, "unsupported checksum algorithm"},
+		{"discard by sidecar", func(c *Config) { c.Discard = true }, "discard must be false when reporting to a peer"},
+		{"no peer address", func(c *Config) { c.Peer.Address = "" }, "peer.address is not configured"},
+		{"no peer msp", func(c *Config) { c.Peer.MSPConfigPath = "" }, "peer.mspConfigPath is not configured"},
+		{"no peer msp id", func(c *Config) { c.Peer.MSPID = "" }, "peer.mspID is not configured"},
+		{"no peer tls root cert", func(c *Config) { c.Peer.TLS.Enabled = true }, "peer.tls.rootCert is not configured"},
+		{"no peer tls client key", func(c *Config) { c.Peer.TLS.ClientCert = "client.crt" }, "peer.tls.clientCert and peer.tls.clientKey must be configured together"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := valid()
+			tc.modify(config)
+			err := config.validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	peerConnectionTimeout = 10 * time.Second
+	peerReportTimeout     = time.Minute
+)
+
+// reporter reports the blockfiles archived by the agent to the peer whose ledgers it archives
+type reporter interface {
+	ReportArchived(ledgerID string, infos []*archive.ArchivedBlockfileInfo) (*archive.ReportArchivedResponse, error)
+}
+
+// peerReporter reports the archived blockfiles over the ArchiverSidecar service of the peer
+type peerReporter struct {
+	config *PeerConfig
+	client *comm.GRPCClient
+	signer identity.SignerSerializer
+}
+
+// newPeerReporter creates a reporter signing the reports with the local MSP of the configuration, which
+// applies to the whole process
+func newPeerReporter(config *PeerConfig) (*peerReporter, error) {
+	if err := mspmgmt.LoadLocalMsp(config.MSPConfigPath, nil, config.MSPID); err != nil {
+		return nil, errors.WithMessagef(err, "error loading local MSP %s from %s", config.MSPID, config.MSPConfigPath)
+	}
+	signer, err := mspmgmt.GetLocalMSP().GetDefaultSigningIdentity()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error obtaining the signing identity of the local MSP")
+	}
+	secOpts := &comm.SecureOptions{}
+	if config.TLS.Enabled {
+		secOpts.UseTLS = true
+		rootCert, err := ioutil.ReadFile(config.TLS.RootCert)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading peer.tls.rootCert %s", config.TLS.RootCert)
+		}
+		secOpts.ServerRootCAs = [][]byte{rootCert}
+		if config.TLS.ClientCert != "" {
+			secOpts.RequireClientCert = true
+			if secOpts.Certificate, err = ioutil.ReadFile(config.TLS.ClientCert); err != nil {
+				return nil, errors.Wrapf(err, "error reading peer.tls.clientCert %s", config.TLS.ClientCert)
+			}
+			if secOpts.Key, err = ioutil.ReadFile(config.TLS.ClientKey); err != nil {
+				return nil, errors.Wrapf(err, "error reading peer.tls.clientKey %s", config.TLS.ClientKey)
+			}
+		}
+	}
+	client, err := comm.NewGRPCClient(comm.ClientConfig{SecOpts: secOpts, Timeout: peerConnectionTimeout})
+	if err != nil {
+		return nil, errors.WithMessage(err, "error creating the client of the peer")
+	}
+	return &peerReporter{config: config, client: client, signer: signer}, nil
+}
+
+// ReportArchived reports the archived blockfiles of a ledger to the peer, which records them in its archive catalog
+func (r *peerReporter) ReportArchived(ledgerID string, infos []*archive.ArchivedBlockfileInfo) (*archive.ReportArchivedResponse, error) {
+	env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, ledgerID, r.signer, &archive.ReportArchivedRequest{Blockfiles: infos}, 0, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error creating the report")
+	}
+	conn, err := r.client.NewConnection(r.config.Address, r.config.TLS.ServerNameOverride)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error connecting to peer %s", r.config.Address)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), peerReportTimeout)
+	defer cancel()
+	resp, err := archive.NewArchiverSidecarClient(conn).ReportArchived(ctx, env)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reporting the archived blockfiles to peer %s", r.config.Address)
+	}
+	return resp, nil
+}
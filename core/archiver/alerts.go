@@ -0,0 +1,249 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultAlertTimeout  = 5 * time.Second
+	defaultAlertInterval = 10 * time.Minute
+	// alertQueueSize is the number of alerts waiting for their delivery to a webhook, beyond which they are dropped
+	alertQueueSize = 100
+)
+
+// AlertWebhook is an incoming webhook to which the alerts of the archiver are posted, e.g. of Slack,
+// Mattermost or Microsoft Teams, or of a relay forwarding them by email
+type AlertWebhook struct {
+	// URL is the http or https endpoint the alerts are posted to
+	URL string `mapstructure:"url"`
+	// Events are the kinds of the alerts posted, all of them when empty
+	Events []string `mapstructure:"events"`
+}
+
+// AlertPayload is the JSON payload posted to the webhooks. The text field is the message displayed
+// by the Slack-compatible webhooks, the other fields are there for the webhooks processing the alerts.
+type AlertPayload struct {
+	Text string `json:"text"`
+	*blockarchive.Alert
+	Peer string `json:"peer,omitempty"`
+}
+
+// AlertNotifier posts the alerts of the archiver to webhooks. Each webhook has its own queue, delivered in
+// the background, so that a slow endpoint delays neither the archiving nor the other webhooks. An alert of
+// the same kind about the same channel is posted at most once per interval, so that a repository down for
+// hours doesn't flood the webhooks; the completions of the restores are always posted.
+type AlertNotifier struct {
+	peerID   string
+	interval time.Duration
+	webhooks []*alertWebhook
+
+	lock   sync.Mutex
+	raised map[string]time.Time
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type alertWebhook struct {
+	config AlertWebhook
+	client *http.Client
+	alerts chan *blockarchive.Alert
+}
+
+// NewAlertNotifier returns a notifier posting the alerts of the peer to the webhooks, each attempt giving up
+// after the timeout, and the same alert about the same channel at most once per interval
+func NewAlertNotifier(peerID string, webhooks []AlertWebhook, timeout, interval time.Duration) (*AlertNotifier, error) {
+	n := &AlertNotifier{
+		peerID:   peerID,
+		interval: interval,
+		raised:   make(map[string]time.Time),
+	}
+	for _, config := range webhooks {
+		if err := validateAlertWebhook(config); err != nil {
+			return nil, err
+		}
+		w := &alertWebhook{
+			config: config,
+			client: &http.Client{Timeout: timeout},
+			alerts: make(chan *blockarchive.Alert, alertQueueSize),
+		}
+		n.webhooks = append(n.webhooks, w)
+	}
+	for _, w := range n.webhooks {
+		n.wg.Add(1)
+		go func(w *alertWebhook) {
+			defer n.wg.Done()
+			for alert := range w.alerts {
+				if err := w.post(n.payload(alert)); err != nil {
+					loggerArchive.Warningf("Could not post the %s alert to webhook [%s]: %s", alert.Kind, w.config.URL, err)
+				}
+			}
+		}(w)
+	}
+	return n, nil
+}
+
+func validateAlertWebhook(config AlertWebhook) error {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid webhook url [%s]", config.URL)
+	}
+	for _, event := range config.Events {
+		if !isAlertKind(event) {
+			return errors.Errorf("invalid event [%s] of webhook [%s], must be one of %v", event, config.URL, blockarchive.AlertKinds)
+		}
+	}
+	return nil
+}
+
+func isAlertKind(kind string) bool {
+	for _, k := range blockarchive.AlertKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify queues an alert for the webhooks subscribed to its kind. It is the blockarchive.AlertNotifier of the peer.
+func (n *AlertNotifier) Notify(alert *blockarchive.Alert) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.closed {
+		return
+	}
+	loggerArchive.Warningf("Alert %s: %s", alert.Kind, n.payload(alert).Text)
+	if alert.Kind != blockarchive.AlertRestoreCompleted {
+		key := alert.Kind + "/" + alert.Channel
+		if last, ok := n.raised[key]; ok && alert.Time.Sub(last) < n.interval {
+			return
+		}
+		n.raised[key] = alert.Time
+	}
+	for _, w := range n.webhooks {
+		if !w.subscribed(alert.Kind) {
+			continue
+		}
+		select {
+		case w.alerts <- alert:
+		default:
+			loggerArchive.Warningf("Dropped the %s alert, the queue of webhook [%s] is full", alert.Kind, w.config.URL)
+		}
+	}
+}
+
+// Close delivers the queued alerts and stops the notifier
+func (n *AlertNotifier) Close() {
+	n.lock.Lock()
+	if n.closed {
+		n.lock.Unlock()
+		return
+	}
+	n.closed = true
+	for _, w := range n.webhooks {
+		close(w.alerts)
+	}
+	n.lock.Unlock()
+	n.wg.Wait()
+}
+
+// payload returns the payload posted to the webhooks for an alert
+func (n *AlertNotifier) payload(alert *blockarchive.Alert) *AlertPayload {
+	text := fmt.Sprintf("[%s] %s", alert.Kind, alert.Message)
+	if alert.Channel != "" {
+		text = fmt.Sprintf("[%s] channel %s: %s", alert.Kind, alert.Channel, alert.Message)
+	}
+	if n.peerID != "" {
+		text = n.peerID + " " + text
+	}
+	return &AlertPayload{Text: text, Alert: alert, Peer: n.peerID}
+}
+
+// subscribed tells if the alerts of a kind are posted to the webhook
+func (w *alertWebhook) subscribed(kind string) bool {
+	if len(w.config.Events) == 0 {
+		return true
+	}
+	for _, event := range w.config.Events {
+		if event == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *alertWebhook) post(payload *AlertPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.config.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// alertNotifier is the notifier of the peer, closed when the configuration is loaded again
+var alertNotifier *AlertNotifier
+
+// initAlerts initializes the alerts of the archiver from peer.archiver.alerts
+func initAlerts() {
+	blockarchive.ArchiveFailureAlertThreshold = viper.GetInt("peer.archiver.alerts.archiveFailures")
+	if blockarchive.ArchiveFailureAlertThreshold < 0 {
+		loggerArchive.Panicf("Invalid peer.archiver.alerts.archiveFailures: %d", blockarchive.ArchiveFailureAlertThreshold)
+	}
+	blockarchive.DiskHighWatermark = viper.GetInt("peer.archiver.alerts.diskHighWatermark")
+	if blockarchive.DiskHighWatermark < 0 || blockarchive.DiskHighWatermark > 100 {
+		loggerArchive.Panicf("Invalid peer.archiver.alerts.diskHighWatermark: %d is not a percentage between 0 and 100",
+			blockarchive.DiskHighWatermark)
+	}
+
+	if alertNotifier != nil {
+		alertNotifier.Close()
+		alertNotifier = nil
+	}
+	blockarchive.AlertNotifier = nil
+	var webhooks []AlertWebhook
+	if err := viper.UnmarshalKey("peer.archiver.alerts.webhooks", &webhooks); err != nil {
+		loggerArchive.Panicf("Invalid peer.archiver.alerts.webhooks: %s", err)
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+	timeout := viper.GetDuration("peer.archiver.alerts.timeout")
+	if timeout <= 0 {
+		timeout = defaultAlertTimeout
+	}
+	interval := defaultAlertInterval
+	if viper.IsSet("peer.archiver.alerts.interval") {
+		interval = viper.GetDuration("peer.archiver.alerts.interval")
+	}
+	notifier, err := NewAlertNotifier(viper.GetString("peer.id"), webhooks, timeout, interval)
+	if err != nil {
+		loggerArchive.Panicf("Invalid peer.archiver.alerts.webhooks: %s", err)
+	}
+	alertNotifier = notifier
+	blockarchive.AlertNotifier = notifier.Notify
+	loggerArchive.Infof("The alerts of the archiver are posted to %d webhook(s)", len(webhooks))
+}
@@ -0,0 +1,94 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// alertReceiver records the alerts posted to it
+type alertReceiver struct {
+	t        *testing.T
+	lock     sync.Mutex
+	payloads []map[string]interface{}
+}
+
+func (r *alertReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	payload := map[string]interface{}{}
+	require.NoError(r.t, json.NewDecoder(req.Body).Decode(&payload))
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.payloads = append(r.payloads, payload)
+}
+
+func (r *alertReceiver) kinds() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var kinds []string
+	for _, payload := range r.payloads {
+		kinds = append(kinds, payload["kind"].(string))
+	}
+	return kinds
+}
+
+func TestAlertNotifier(t *testing.T) {
+	all := &alertReceiver{t: t}
+	allServer := httptest.NewServer(all)
+	defer allServer.Close()
+	restores := &alertReceiver{t: t}
+	restoresServer := httptest.NewServer(restores)
+	defer restoresServer.Close()
+
+	notifier, err := NewAlertNotifier("peer0", []AlertWebhook{
+		{URL: allServer.URL},
+		{URL: restoresServer.URL, Events: []string{blockarchive.AlertRestoreCompleted}},
+	}, time.Second, time.Hour)
+	require.NoError(t, err)
+	defer func(notifier func(alert *blockarchive.Alert)) { blockarchive.AlertNotifier = notifier }(blockarchive.AlertNotifier)
+	blockarchive.AlertNotifier = notifier.Notify
+
+	blockarchive.RaiseAlert(blockarchive.AlertRepositoryUnreachable, "ch1", "repository [%s] is unreachable", "repo:222")
+	// The same alert about the same channel is posted once per interval, unlike the completions of the restores
+	blockarchive.RaiseAlert(blockarchive.AlertRepositoryUnreachable, "ch1", "repository [%s] is unreachable", "repo:222")
+	blockarchive.RaiseAlert(blockarchive.AlertRepositoryUnreachable, "ch2", "repository [%s] is unreachable", "repo:222")
+	blockarchive.RaiseAlert(blockarchive.AlertRestoreCompleted, "ch1", "restore 1 of blocks [0-9] succeeded")
+	blockarchive.RaiseAlert(blockarchive.AlertRestoreCompleted, "ch1", "restore 2 of blocks [0-9] succeeded")
+	notifier.Close()
+
+	assert.Equal(t, []string{
+		blockarchive.AlertRepositoryUnreachable,
+		blockarchive.AlertRepositoryUnreachable,
+		blockarchive.AlertRestoreCompleted,
+		blockarchive.AlertRestoreCompleted,
+	}, all.kinds())
+	assert.Equal(t, []string{blockarchive.AlertRestoreCompleted, blockarchive.AlertRestoreCompleted}, restores.kinds())
+
+	// The payload is displayed by the Slack-compatible webhooks
+	first := all.payloads[0]
+	assert.Equal(t, "peer0 [repositoryUnreachable] channel ch1: repository [repo:222] is unreachable", first["text"])
+	assert.Equal(t, "ch1", first["channel"])
+	assert.Equal(t, "peer0", first["peer"])
+	assert.Equal(t, "ch2", all.payloads[1]["channel"])
+
+	// The alerts raised once the notifier is closed are dropped
+	blockarchive.RaiseAlert(blockarchive.AlertRestoreCompleted, "ch1", "restore 3 of blocks [0-9] succeeded")
+	assert.Len(t, all.kinds(), 4)
+}
+
+func TestAlertNotifierInvalidWebhooks(t *testing.T) {
+	_, err := NewAlertNotifier("peer0", []AlertWebhook{{URL: "ftp://example.com"}}, time.Second, time.Hour)
+	assert.EqualError(t, err, "invalid webhook url [ftp://example.com]")
+	_, err = NewAlertNotifier("peer0", []AlertWebhook{{URL: "http://example.com", Events: []string{"upload"}}}, time.Second, time.Hour)
+	assert.Contains(t, err.Error(), "invalid event [upload] of webhook [http://example.com]")
+}
@@ -6,34 +6,280 @@ COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
 package archiver
 
 import (
+	"context"
+	"strings"
+
 	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
 	"github.com/spf13/viper"
 
 	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
 	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive/webhdfs"
+	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
 )
 
 var loggerArchive = flogging.MustGetLogger("archiver.common")
 
+// loggerRetrieve logs the blockfiles served to the other peers with structured fields
+var loggerRetrieve = flogging.MustGetLogger("archiver.retrieve")
+
 // InitBlockArchiver initializes the BlockArchiver functions
 func InitBlockArchiver() {
 	loggerArchive.Info("Archiver.InitBlockArchiver...")
 
 	initBlockArchiverParams()
+	initAlerts()
+	// Fail fast rather than misbehave once the channels are open
+	if err := ValidateConfig(); err != nil {
+		loggerArchive.Panicf("Invalid archiver configuration: %s", err)
+	}
+	if interval := ledgerconfig.GetReconciliationInterval(); interval > 0 {
+		fsblkstorage.StartReconciliation(interval, ledgerconfig.IsReconciliationAutoHealEnabled())
+	}
+	if interval := ledgerconfig.GetCatalogSnapshotInterval(); interval > 0 {
+		fsblkstorage.StartCatalogSnapshots(interval)
+	}
 
 	loggerArchive.Info("Archiver.InitBlockArchiver isArchiver=", blockarchive.IsArchiver, " isClient-", blockarchive.IsClient)
 }
 
-func initBlockArchiverParams() {
-	blockarchive.IsArchiver = viper.GetBool("peer.archiver.enabled")
+// Shutdown is called on the shutdown of the peer. It waits, up to the drain timeout, for the blockfiles
+// being archived, so that their transfers are not killed in the middle of a blockfile.
+func Shutdown() {
 	if blockarchive.IsArchiver {
-		blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = ledgerconfig.GetArchivingParameters()
-	} else {
-		blockarchive.IsClient = viper.GetBool("peer.archiving.enabled")
+		fsblkstorage.DrainTransfers(ledgerconfig.GetDrainTimeout())
+	}
+	// The alerts raised by the transfers drained are still posted
+	if alertNotifier != nil {
+		alertNotifier.Close()
 	}
+}
+
+// DiskHealthChecker reports a degraded health when the free disk space of the block store is below
+// ledger.blockArchiver.backpressure.minFreeDiskSpace, i.e. the archiving doesn't keep up with the commits
+type DiskHealthChecker struct{}
+
+// HealthCheck implements healthz.HealthChecker
+func (DiskHealthChecker) HealthCheck(ctx context.Context) error {
+	return fsblkstorage.CheckDiskHealth()
+}
+
+// InitRepositoryAccess initializes the parameters with which the tools of the peer command access the
+// block store and the repository while the peer is stopped, without taking on the archiver or client role
+func InitRepositoryAccess() {
+	initRepositoryParams()
+	// The manifests of the blockfiles uploaded again are signed like the ones of the archiver peer
+	if signer, err := mspmgmt.GetLocalMSP().GetDefaultSigningIdentity(); err == nil {
+		blockarchive.ManifestSigner = signer
+	}
+}
 
+// InitPeerRole initializes the archiver or client role of the peer along with the access to the repository,
+// for the tools of the peer command which archive and discard the blockfiles while the peer is stopped
+func InitPeerRole() {
+	initBlockArchiverParams()
+}
+
+func initBlockArchiverParams() {
+	initArchiverRole(roleFromConfig())
+	initRepositoryParams()
+}
+
+// initCompression sets the compression of the blockfiles of the channels from the settings of the peer,
+// overridden by the ones of the channels
+func initCompression() {
+	compression, err := blockarchive.ParseCompression(ledgerconfig.GetCompression(""))
+	if err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.compression: %s", err)
+	}
+	channelCompressions := map[string]*blockarchive.CompressionSettings{}
+	for _, channelID := range ledgerconfig.GetCompressionChannels() {
+		settings, err := blockarchive.ParseCompression(ledgerconfig.GetCompression(channelID))
+		if err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.channels.%s.compression: %s", channelID, err)
+		}
+		channelCompressions[channelID] = settings
+	}
+	blockarchive.Compression = func(ledgerID string) *blockarchive.CompressionSettings {
+		if settings, ok := channelCompressions[ledgerID]; ok {
+			return settings
+		}
+		return compression
+	}
+}
+
+// initEncryption loads the keys of the archived blockfiles and sets the key the blockfiles are encrypted with
+func initEncryption() {
+	blockarchive.EncryptionKeys, blockarchive.EncryptionKeyID = nil, ""
+	if dir := ledgerconfig.GetEncryptionKeyDir(); dir != "" {
+		keyring, err := blockarchive.LoadEncryptionKeyring(dir)
+		if err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.encryption.keyDir: %s", err)
+		}
+		blockarchive.EncryptionKeys = keyring
+	}
+	if keyID := ledgerconfig.GetEncryptionActiveKey(); keyID != "" {
+		if _, err := blockarchive.EncryptionKeys.Key(keyID); err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.encryption.activeKey: %s", err)
+		}
+		blockarchive.EncryptionKeyID = keyID
+	}
+}
+
+// initHDFS sets the HDFS cluster of ledger.blockArchiver.hdfs, if its namenodes are configured
+func initHDFS() {
+	blockarchive.HDFS = nil
+	namenodes := ledgerconfig.GetBlockArchiverHDFSNamenodes()
+	if len(namenodes) == 0 {
+		return
+	}
+	hdfs := &webhdfs.Config{
+		Namenodes: namenodes,
+		BasePath:  ledgerconfig.GetBlockArchiverHDFSBasePath(),
+		User:      ledgerconfig.GetBlockArchiverHDFSUser(),
+	}
+	keytab, principal, krb5Conf, servicePrincipal := ledgerconfig.GetBlockArchiverHDFSKerberos()
+	if keytab != "" || principal != "" {
+		hdfs.Kerberos = &webhdfs.KerberosConfig{
+			Keytab:           keytab,
+			Principal:        principal,
+			Krb5Conf:         krb5Conf,
+			ServicePrincipal: servicePrincipal,
+		}
+	}
+	if err := hdfs.Validate(); err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.hdfs: %s", err)
+	}
+	blockarchive.HDFS = hdfs
+}
+
+func initRepositoryParams() {
 	blockarchive.BlockArchiverDir = ledgerconfig.GetBlockArchiverDir()
 	blockarchive.BlockArchiverURL = ledgerconfig.GetBlockArchiverURL()
+	initHDFS()
+	blockarchive.RepositoryType = ledgerconfig.GetBlockArchiverType()
+	if err := blockarchive.ValidateRepositoryType(blockarchive.RepositoryType); err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.type: %s", err)
+	}
+	// ledger.blockArchiver.url is not used when the channels are archived to HDFS by default
+	usesHDFS := strings.ToLower(blockarchive.RepositoryType) == blockarchive.RepositoryTypeHDFS
+	if !usesHDFS {
+		if _, err := blockarchive.ParseRepositoryURL(blockarchive.BlockArchiverURL); err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.url: %s", err)
+		}
+	}
+	for _, channelID := range ledgerconfig.GetRepositoryChannels() {
+		url, repositoryType := ledgerconfig.GetChannelRepository(channelID)
+		repository := &blockarchive.ChannelRepository{URL: url, Type: repositoryType}
+		if err := blockarchive.ValidateChannelRepository(repository); err != nil {
+			loggerArchive.Panicf("Invalid repository of ledger.blockArchiver.channels.%s: %s", channelID, err)
+		}
+		blockarchive.SetChannelRepository(channelID, repository)
+		usesHDFS = usesHDFS || strings.ToLower(repositoryType) == blockarchive.RepositoryTypeHDFS
+	}
+	blockarchive.RepositoryTokenFile = ledgerconfig.GetBlockArchiverTokenFile()
+	blockarchive.RepositoryProxyURL = ledgerconfig.GetBlockArchiverProxyURL()
+	blockarchive.RepositoryNoProxy = ledgerconfig.GetBlockArchiverNoProxy()
+	if blockarchive.RepositoryProxyURL != "" {
+		if err := blockarchive.ValidateProxyURL(blockarchive.RepositoryProxyURL); err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.proxy.url: %s", err)
+		}
+	}
+	blockarchive.RepositoryAPIURL = ledgerconfig.GetBlockArchiverAPIURL()
+	if blockarchive.RepositoryAPIURL != "" {
+		if err := blockarchive.ValidateAPIURL(blockarchive.RepositoryAPIURL); err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.apiURL: %s", err)
+		}
+	}
+	tlsSettings := &blockarchive.RepositoryTLSSettings{
+		MinVersion:   ledgerconfig.GetBlockArchiverTLSMinVersion(),
+		CipherSuites: ledgerconfig.GetBlockArchiverTLSCipherSuites(),
+		CABundle:     ledgerconfig.GetBlockArchiverCABundle(),
+	}
+	tlsSettings.ClientCertFile, tlsSettings.ClientKeyFile = ledgerconfig.GetBlockArchiverTLSClientCert()
+	tlsConfig, err := tlsSettings.TLSConfig()
+	if err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.tls: %s", err)
+	}
+	blockarchive.RepositoryTLSConfig = tlsConfig
 	blockarchive.BlockStorePath = ledgerconfig.GetBlockStorePath()
-
+	blockarchive.NetworkID = viper.GetString("peer.networkId")
+	blockarchive.Environment = ledgerconfig.GetBlockArchiverEnvironment()
+	blockarchive.NamespaceByNetwork = ledgerconfig.IsNamespaceByNetworkEnabled()
+	if err := blockarchive.ValidateNamespace(); err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.namespaceByNetwork: %s", err)
+	}
+	blockarchive.MaxConcurrentRetrievals = ledgerconfig.GetMaxConcurrentRetrievals()
+	blockarchive.MaxBufferedRetrievals = ledgerconfig.GetMaxBufferedRetrievals()
+	blockarchive.RestoreParallelism = ledgerconfig.GetRestoreParallelism()
+	blockarchive.CatchUpThreshold = ledgerconfig.GetCatchUpThreshold()
+	blockarchive.CatchUpParallelism = ledgerconfig.GetCatchUpParallelism()
+	blockarchive.CatchUpBandwidth = ledgerconfig.GetCatchUpMaxBandwidth()
+	blockarchive.DiscardVerification = ledgerconfig.GetDiscardVerification
+	initCompression()
+	initEncryption()
+	blockarchive.MinFreeDiskSpace = ledgerconfig.GetMinFreeDiskSpace()
+	blockarchive.ThrottleCommit = ledgerconfig.IsCommitThrottlingEnabled()
+	blockarchive.MaxCommitPause = ledgerconfig.GetMaxCommitPause()
+	blockarchive.MinBlockAgeBeforeDiscard = ledgerconfig.GetMinBlockAgeBeforeDiscard()
+	if ledgerconfig.IsObjectLockRequired() && usesHDFS {
+		// The object locks are enforced by the repository server
+		loggerArchive.Panic("Invalid ledger.blockArchiver.objectLock.required: the object locks are not supported on HDFS")
+	}
+	blockarchive.ObjectLockRequired = ledgerconfig.IsObjectLockRequired()
+	blockarchive.ObjectLockMinRetention = ledgerconfig.GetObjectLockMinRetention()
+	blockarchive.RetainConfigBlocks = ledgerconfig.IsRetainConfigBlocksEnabled()
+	blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+	if err := blockarchive.ValidateCatalogDatabase(blockarchive.CatalogDatabase); err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.catalog.database: %s", err)
+	}
+	blockarchive.CatalogRecovery = ledgerconfig.IsCatalogRecoveryEnabled()
+	blockarchive.CatalogSnapshotsKept = ledgerconfig.GetCatalogSnapshotsKept()
+	blockarchive.ArchivingSchedule = nil
+	if expr := ledgerconfig.GetArchivingSchedule(); expr != "" {
+		schedule, err := blockarchive.ParseSchedule(expr)
+		if err != nil {
+			loggerArchive.Panicf("Invalid peer.archiver.schedule: %s", err)
+		}
+		blockarchive.ArchivingSchedule = schedule
+	}
+	blockarchive.ScheduleOnly = ledgerconfig.IsArchivingScheduleOnly()
+	if blockarchive.ScheduleOnly && blockarchive.ArchivingSchedule == nil {
+		loggerArchive.Panic("Invalid peer.archiver.scheduleOnly: peer.archiver.schedule is not set")
+	}
+	blockarchive.DiscardSchedule = nil
+	if expr := ledgerconfig.GetDiscardSchedule(); expr != "" {
+		schedule, err := blockarchive.ParseSchedule(expr)
+		if err != nil {
+			loggerArchive.Panicf("Invalid peer.archiver.discard.schedule: %s", err)
+		}
+		blockarchive.DiscardSchedule = schedule
+	}
+	blockarchive.DiscardDiskUsageThreshold = ledgerconfig.GetDiscardDiskUsageThreshold()
+	if blockarchive.DiscardDiskUsageThreshold < 0 || blockarchive.DiscardDiskUsageThreshold >= 100 {
+		loggerArchive.Panicf("Invalid peer.archiver.discard.diskUsageThreshold: %d is not a percentage between 0 and 99",
+			blockarchive.DiscardDiskUsageThreshold)
+	}
+	blockarchive.TailIdleTime = ledgerconfig.GetTailIdleTime()
+	blockarchive.CheckCoverage = ledgerconfig.IsCoverageCheckEnabled()
+	blockarchive.StrictCoverage = ledgerconfig.IsCoverageCheckStrict()
+	blockarchive.VerifyBlockfileSignatures = ledgerconfig.IsSignatureVerificationEnabled()
+	if blockarchive.VerifyBlockfileSignatures {
+		// The blockfiles are archived by a peer of the organization
+		blockarchive.ManifestVerifier = mspmgmt.GetLocalMSP()
+	}
+	blockarchive.FetchEnabled = ledgerconfig.IsChannelFetchEnabled
+	blockarchive.ObjectKeyTemplate = ledgerconfig.GetBlockArchiverObjectKeyTemplate()
+	blockarchive.ContentAddressed = ledgerconfig.IsContentAddressedEnabled()
+	blockarchive.ArchiverID = viper.GetString("peer.id")
+	blockarchive.ChecksumAlgorithm = ledgerconfig.GetChecksumAlgorithm()
+	if _, err := blockarchive.NewChecksumHash(blockarchive.ChecksumAlgorithm); err != nil {
+		loggerArchive.Panicf("Invalid ledger.blockArchiver.checksumAlgorithm: %s", err)
+	}
+	if blockarchive.ObjectKeyTemplate != "" {
+		if err := blockarchive.ValidateObjectKeyTemplate(blockarchive.ObjectKeyTemplate); err != nil {
+			loggerArchive.Panicf("Invalid ledger.blockArchiver.objectKeyTemplate: %s", err)
+		}
+	}
 }
@@ -1,72 +1,110 @@
 package archiver
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/hyperledger/fabric/common/ledger/blockarchive"
-	"github.com/hyperledger/fabric/common/metrics"
-	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestAttrs tests attributes
-func TestInitblockArchiverArchiver(t *testing.T) {
-	provider := testutilConstructMetricProvider()
+func TestInitBlockArchiverArchiver(t *testing.T) {
+	cleanup := setupInitBlockArchiver(t)
+	defer cleanup()
 	viper.Set("peer.archiver.enabled", true)
 	viper.Set("peer.archiving.enabled", false)
 
-	InitblockArchiver(provider.fakeProvider)
+	InitBlockArchiver()
+	assert.True(t, blockarchive.IsArchiver)
+	assert.False(t, blockarchive.IsClient)
 }
 
-func TestInitblockArchiverArchiving(t *testing.T) {
-	provider := testutilConstructMetricProvider()
+func TestInitBlockArchiverArchiving(t *testing.T) {
+	cleanup := setupInitBlockArchiver(t)
+	defer cleanup()
 	viper.Set("peer.archiver.enabled", false)
 	viper.Set("peer.archiving.enabled", true)
 
-	InitblockArchiver(provider.fakeProvider)
+	InitBlockArchiver()
+	assert.False(t, blockarchive.IsArchiver)
+	assert.True(t, blockarchive.IsClient)
 }
 
-func TestInitblockArchiverBoth(t *testing.T) {
-	provider := testutilConstructMetricProvider()
+func TestInitBlockArchiverBoth(t *testing.T) {
+	cleanup := setupInitBlockArchiver(t)
+	defer cleanup()
 	viper.Set("peer.archiver.enabled", true)
 	viper.Set("peer.archiving.enabled", true)
 
-	InitblockArchiver(provider.fakeProvider)
+	// The archiver peer is never a client peer
+	InitBlockArchiver()
+	assert.True(t, blockarchive.IsArchiver)
+	assert.False(t, blockarchive.IsClient)
 }
 
-func TestInitblockArchiverNone(t *testing.T) {
-	provider := testutilConstructMetricProvider()
+func TestInitBlockArchiverNone(t *testing.T) {
+	cleanup := setupInitBlockArchiver(t)
+	defer cleanup()
 	viper.Set("peer.archiver.enabled", false)
 	viper.Set("peer.archiving.enabled", false)
 
-	InitblockArchiver(provider.fakeProvider)
+	InitBlockArchiver()
+	assert.False(t, blockarchive.IsArchiver)
+	assert.False(t, blockarchive.IsClient)
 }
 
-type testMetricProvider struct {
-	fakeProvider                  *metricsfakes.Provider
-	fakeArchiveBlockfileSizeGauge *metricsfakes.Gauge
-}
+func TestInitBlockArchiverHDFS(t *testing.T) {
+	cleanup := setupInitBlockArchiver(t)
+	defer cleanup()
+	defer blockarchive.SetChannelRepository("eu-channel", nil)
+	viper.Set("peer.archiver.enabled", true)
+	viper.Set("ledger.blockArchiver.url", "blkarchiver-repo:22")
+	viper.Set("ledger.blockArchiver.hdfs.namenodes", []string{"http://nn1.example.com:9870", "http://nn2.example.com:9870"})
+	viper.Set("ledger.blockArchiver.hdfs.basePath", "/data/fabric")
+	viper.Set("ledger.blockArchiver.hdfs.user", "fabric")
+	viper.Set("ledger.blockArchiver.channels", map[string]interface{}{
+		"eu-channel": map[interface{}]interface{}{"type": "hdfs"},
+	})
+	viper.Set("ledger.blockArchiver.channels.eu-channel.type", "hdfs")
 
-func testutilConstructMetricProvider() *testMetricProvider {
-	fakeProvider := &metricsfakes.Provider{}
-	fakeArchiveBlockfileSizeGauge := testutilConstructGuage()
-	fakeProvider.NewGaugeStub = func(opts metrics.GaugeOpts) metrics.Gauge {
-		switch opts.Name {
-		case blockarchive.ArchivedBlockfileSizeOpts.Name:
-			return fakeArchiveBlockfileSizeGauge
-		}
-		return nil
-	}
-	return &testMetricProvider{
-		fakeProvider,
-		fakeArchiveBlockfileSizeGauge,
-	}
+	// A channel is archived to HDFS while the others are archived to the repository server
+	InitBlockArchiver()
+	require.NotNil(t, blockarchive.HDFS)
+	assert.Equal(t, "webhdfs://nn1.example.com:9870,nn2.example.com:9870/data/fabric", blockarchive.RepositoryURLOf("eu-channel"))
+	assert.Equal(t, "blkarchiver-repo:22", blockarchive.RepositoryURLOf("us-channel"))
+
+	// All the channels are archived to HDFS, without ledger.blockArchiver.url
+	viper.Set("ledger.blockArchiver.type", "hdfs")
+	viper.Set("ledger.blockArchiver.url", "")
+	InitBlockArchiver()
+	assert.Equal(t, blockarchive.RepositoryTypeHDFS, blockarchive.RepositoryTypeOf("us-channel"))
+
+	// The object locks are enforced by the repository server only
+	viper.Set("ledger.blockArchiver.objectLock.required", true)
+	assert.Panics(t, InitBlockArchiver)
+	viper.Set("ledger.blockArchiver.objectLock.required", false)
+
+	// The HDFS cluster must be configured for the channels archived to it
+	viper.Set("ledger.blockArchiver.hdfs.namenodes", []string{})
+	assert.Panics(t, InitBlockArchiver)
 }
 
-func testutilConstructGuage() *metricsfakes.Gauge {
-	fakeGauge := &metricsfakes.Gauge{}
-	fakeGauge.WithStub = func(lableValues ...string) metrics.Gauge {
-		return fakeGauge
+// setupInitBlockArchiver configures a peer file system path and the local MSP signing the manifests,
+// and returns the function restoring the configuration
+func setupInitBlockArchiver(t *testing.T) func() {
+	require.NoError(t, msptesttools.LoadMSPSetupForTesting())
+	dir, err := ioutil.TempDir("", "archiver")
+	require.NoError(t, err)
+	viper.Set("peer.fileSystemPath", dir)
+	return func() {
+		blockarchive.IsArchiver, blockarchive.IsClient = false, false
+		blockarchive.HDFS, blockarchive.RepositoryType = nil, ""
+		viper.Reset()
+		os.RemoveAll(dir)
 	}
-	return fakeGauge
 }
@@ -0,0 +1,305 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/crypto"
+	"github.com/hyperledger/fabric/common/deliver"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The methods of the gRPC services serving the archived blocks, whose requests are authorized against
+// the policies of their channel. The handshake carries no channel and is left to the services.
+var channelAuthorizedMethods = map[string]bool{
+	"/archive.ArchivedBlockProvider/GetBlock":     true,
+	"/archive.ArchivedBlockProvider/GetBlockfile": true,
+	"/archive.ArchivedBlockProvider/GetBlocks":    true,
+	"/archive.v1.ArchiverService/GetBlock":        true,
+	"/archive.v1.ArchiverService/GetBlockfile":    true,
+}
+
+// ChannelAuthorizer authorizes the requests of the archived blocks of a channel with the policy checker
+// of the deliver service, so that only the members of the channel allowed to receive its blocks, its
+// readers by default, retrieve its archived blocks. The requests are signed envelopes, whose creator
+// must not have expired, like on the deliver service. The authorizer is installed on the gRPC server
+// of the peer with its interceptors, which leave the requests of the other services as they are, and
+// authorizes the requests of the handlers of the operations endpoint with AuthorizeHTTP.
+type ChannelAuthorizer struct {
+	checkPolicy deliver.PolicyCheckerFunc
+}
+
+// NewChannelAuthorizer creates a ChannelAuthorizer checking the requests with checkPolicy
+func NewChannelAuthorizer(checkPolicy deliver.PolicyCheckerFunc) *ChannelAuthorizer {
+	return &ChannelAuthorizer{checkPolicy: checkPolicy}
+}
+
+// UnaryServerInterceptor returns the interceptor authorizing the requests of the archived blocks
+func (a *ChannelAuthorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if channelAuthorizedMethods[info.FullMethod] {
+			if err := a.authorize(ctx, info.FullMethod, req); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor authorizing the requests of the archived blockfiles.
+// The request of a server stream is received by the handler, so it is authorized as it is received.
+func (a *ChannelAuthorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if channelAuthorizedMethods[info.FullMethod] {
+			ss = &authorizedStream{ServerStream: ss, authorizer: a, method: info.FullMethod}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorize checks that a request is a recent signed envelope of a channel, whose creator has not expired
+// and satisfies the policy of the channel
+func (a *ChannelAuthorizer) authorize(ctx context.Context, method string, req interface{}) error {
+	addr := util.ExtractRemoteAddress(ctx)
+	env, ok := req.(*common.Envelope)
+	if !ok || env == nil {
+		return status.Error(codes.InvalidArgument, "nil envelope")
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil || payload.Header == nil {
+		return status.Error(codes.InvalidArgument, "bad request, malformed payload")
+	}
+	ch, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil || ch.ChannelId == "" {
+		return status.Error(codes.InvalidArgument, "bad request, missing channel")
+	}
+	if ch.Timestamp == nil {
+		return status.Error(codes.InvalidArgument, "bad request, empty timestamp")
+	}
+	// A request replayed outside the time window of the requests is refused, as on the operations endpoint
+	reqTs := time.Unix(ch.Timestamp.Seconds, int64(ch.Timestamp.Nanos))
+	if now := time.Now(); reqTs.Add(requestTimeDiff).Before(now) || reqTs.Add(-requestTimeDiff).After(now) {
+		loggerArchive.Warningf("[%s] Request %s from %s unauthorized due to incorrect time: %s", ch.ChannelId, method, addr, reqTs)
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+	sh, err := protoutil.GetSignatureHeader(payload.Header.SignatureHeader)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "bad request, malformed signature header")
+	}
+	if err := a.checkEnvelope(env, ch.ChannelId, sh.Creator, method, addr); err != nil {
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+	return nil
+}
+
+// checkEnvelope checks that the creator of a request of a channel has not expired and satisfies the
+// policy of the channel
+func (a *ChannelAuthorizer) checkEnvelope(env *common.Envelope, channelID string, creator []byte, method, addr string) error {
+	if expiresAt := crypto.ExpiresAt(creator); !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		loggerArchive.Warningf("[%s] Request %s from %s unauthorized: the identity of the requester expired at %s", channelID, method, addr, expiresAt)
+		return errors.Errorf("the identity of the requester expired at %s", expiresAt)
+	}
+	if err := a.checkPolicy(env, channelID); err != nil {
+		loggerArchive.Warningf("[%s] Request %s from %s unauthorized by the policy of the channel: %s", channelID, method, addr, err)
+		return err
+	}
+	return nil
+}
+
+// AuthorizeHTTP authorizes a request of the archived blocks of a channel on the operations endpoint, which
+// is not authenticated by the endpoint itself. The request carries in its RequestAuthorizationHeader an
+// envelope of the channel signed by the requester, which is checked like the requests of the gRPC services.
+// It returns the identity of the requester, or an httpAuthError. A nil authorizer denies all the requests.
+func (a *ChannelAuthorizer) AuthorizeHTTP(r *http.Request, channelID string) ([]byte, error) {
+	if a == nil {
+		loggerArchive.Warningf("[%s] Request %s from %s denied: no authorizer is configured", channelID, r.URL.Path, r.RemoteAddr)
+		return nil, &httpAuthError{status: http.StatusForbidden, msg: "access denied"}
+	}
+	env, ch, sh, err := openHTTPEnvelope(r)
+	if err != nil {
+		return nil, err
+	}
+	if ch.ChannelId != channelID {
+		loggerArchive.Warningf("[%s] Request %s from %s unauthorized: it is signed for channel [%s]", channelID, r.URL.Path, r.RemoteAddr, ch.ChannelId)
+		return nil, &httpAuthError{status: http.StatusForbidden, msg: "access denied"}
+	}
+	if err := a.checkEnvelope(env, channelID, sh.Creator, r.URL.Path, r.RemoteAddr); err != nil {
+		return nil, &httpAuthError{status: http.StatusForbidden, msg: "access denied"}
+	}
+	return sh.Creator, nil
+}
+
+// authorizeAdminHTTP authorizes a request to the operations endpoint signed by an administrator of the peer,
+// as accepted by admins, and returns the identity of the requester. A nil admins denies all the requests.
+func authorizeAdminHTTP(r *http.Request, admins AccessControlEvaluator) ([]byte, error) {
+	if admins == nil {
+		loggerArchive.Warningf("Request %s from %s denied: no administrators are configured", r.URL.Path, r.RemoteAddr)
+		return nil, &httpAuthError{status: http.StatusForbidden, msg: "access denied"}
+	}
+	env, _, sh, err := openHTTPEnvelope(r)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := protoutil.EnvelopeAsSignedData(env)
+	if err != nil {
+		loggerArchive.Warningf("Request %s from %s unauthorized: %s", r.URL.Path, r.RemoteAddr, err)
+		return nil, &httpAuthError{status: http.StatusUnauthorized, msg: "malformed envelope"}
+	}
+	if err := admins.Evaluate(sd); err != nil {
+		loggerArchive.Warningf("Request %s from %s unauthorized: %s", r.URL.Path, r.RemoteAddr, err)
+		return nil, &httpAuthError{status: http.StatusForbidden, msg: "access denied"}
+	}
+	return sh.Creator, nil
+}
+
+// maxAuthorizedBodySize is the largest body of a request to the operations endpoint which is signed
+const maxAuthorizedBodySize = 1024 * 1024
+
+// httpAuthError is the error of a request to the operations endpoint which is not authorized
+type httpAuthError struct {
+	status int
+	msg    string
+}
+
+func (e *httpAuthError) Error() string {
+	return e.msg
+}
+
+// replyUnauthorized replies to a request which is not authorized with the status of the error
+func replyUnauthorized(w http.ResponseWriter, err error) {
+	if authErr, ok := err.(*httpAuthError); ok {
+		if authErr.status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", blockarchive.RequestAuthorizationHeader)
+		}
+		http.Error(w, authErr.msg, authErr.status)
+		return
+	}
+	http.Error(w, "access denied", http.StatusForbidden)
+}
+
+// openHTTPEnvelope returns the envelope of the RequestAuthorizationHeader of a request, once it is checked to
+// be recent and to sign the request. The body of the request is read to be checked, and can be read again.
+func openHTTPEnvelope(r *http.Request) (*common.Envelope, *common.ChannelHeader, *common.SignatureHeader, error) {
+	unauthorized := func(format string, args ...interface{}) error {
+		msg := fmt.Sprintf(format, args...)
+		loggerArchive.Warningf("Request %s from %s unauthorized: %s", r.URL.Path, r.RemoteAddr, msg)
+		return &httpAuthError{status: http.StatusUnauthorized, msg: msg}
+	}
+	header := r.Header.Get(blockarchive.RequestAuthorizationHeader)
+	if header == "" {
+		return nil, nil, nil, unauthorized("missing %s header", blockarchive.RequestAuthorizationHeader)
+	}
+	b, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, nil, nil, unauthorized("malformed %s header", blockarchive.RequestAuthorizationHeader)
+	}
+	env := &common.Envelope{}
+	if err := proto.Unmarshal(b, env); err != nil {
+		return nil, nil, nil, unauthorized("malformed envelope")
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil || payload.Header == nil {
+		return nil, nil, nil, unauthorized("malformed payload")
+	}
+	ch, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	if err != nil || ch.Timestamp == nil {
+		return nil, nil, nil, unauthorized("malformed channel header")
+	}
+	sh, err := protoutil.GetSignatureHeader(payload.Header.SignatureHeader)
+	if err != nil {
+		return nil, nil, nil, unauthorized("malformed signature header")
+	}
+	reqTs := time.Unix(ch.Timestamp.Seconds, int64(ch.Timestamp.Nanos))
+	if now := time.Now(); reqTs.Add(requestTimeDiff).Before(now) || reqTs.Add(-requestTimeDiff).After(now) {
+		return nil, nil, nil, unauthorized("incorrect time %s", reqTs)
+	}
+	var body []byte
+	if r.Body != nil {
+		if body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxAuthorizedBodySize+1)); err != nil {
+			return nil, nil, nil, unauthorized("error reading the body: %s", err)
+		}
+		if len(body) > maxAuthorizedBodySize {
+			return nil, nil, nil, &httpAuthError{status: http.StatusRequestEntityTooLarge, msg: "request body too large"}
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if !bytes.Equal(payload.Data, blockarchive.RequestAuthorizationData(r.Method, r.URL.RequestURI(), body)) {
+		return nil, nil, nil, unauthorized("the envelope signs another request")
+	}
+	return env, ch, sh, nil
+}
+
+// SignHTTPRequest sets the RequestAuthorizationHeader of a request to the operations endpoint of a peer, with an
+// envelope of the channel signed by signer. The body of the request is read to be signed, and can be read again.
+func SignHTTPRequest(req *http.Request, channelID string, signer identity.SignerSerializer) error {
+	creator, err := signer.Serialize()
+	if err != nil {
+		return errors.WithMessage(err, "error serializing the identity of the requester")
+	}
+	nonce, err := crypto.GetRandomNonce()
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return errors.Wrap(err, "error reading the body of the request")
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	payload := &common.Payload{
+		Header: protoutil.MakePayloadHeader(
+			protoutil.MakeChannelHeader(common.HeaderType_MESSAGE, 0, channelID, 0),
+			protoutil.MakeSignatureHeader(creator, nonce),
+		),
+		Data: blockarchive.RequestAuthorizationData(req.Method, req.URL.RequestURI(), body),
+	}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the payload")
+	}
+	signature, err := signer.Sign(payloadBytes)
+	if err != nil {
+		return errors.WithMessage(err, "error signing the request")
+	}
+	env, err := proto.Marshal(&common.Envelope{Payload: payloadBytes, Signature: signature})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the envelope")
+	}
+	req.Header.Set(blockarchive.RequestAuthorizationHeader, base64.StdEncoding.EncodeToString(env))
+	return nil
+}
+
+// authorizedStream authorizes the request received on a server stream
+type authorizedStream struct {
+	grpc.ServerStream
+	authorizer *ChannelAuthorizer
+	method     string
+}
+
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.authorizer.authorize(s.Context(), s.method, m)
+}
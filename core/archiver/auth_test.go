@@ -0,0 +1,160 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	archivev1 "github.com/hyperledger/fabric/protos/ledger/archive/v1"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChannelAuthorizer(t *testing.T) {
+	var checked []string
+	authorizer := NewChannelAuthorizer(func(env *common.Envelope, channelID string) error {
+		checked = append(checked, channelID)
+		if channelID != "mychannel" {
+			return errors.Errorf("not a reader of channel %s", channelID)
+		}
+		return nil
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(authorizer.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(authorizer.StreamServerInterceptor()),
+	)
+	blockfiles := &fakeBlockfileServer{content: []byte("blockfile content")}
+	archive.RegisterArchivedBlockProviderServer(server, fakeProvider{blockfiles})
+	archivev1.RegisterArchiverServiceServer(server, fakeArchiverService{NewArchiverService(nil), blockfiles})
+	go server.Serve(listener)
+	defer server.Stop()
+	address := listener.Addr().String()
+	dialOpts := func() []grpc.DialOption { return []grpc.DialOption{grpc.WithInsecure()} }
+
+	// The blockfiles of a channel are streamed to its readers only
+	buf := &bytes.Buffer{}
+	require.NoError(t, NewBlockfileFetcher(address, dialOpts, fakeSigner{})("mychannel", 0, buf))
+	assert.Equal(t, []byte("blockfile content"), buf.Bytes())
+	err = NewBlockfileFetcher(address, dialOpts, fakeSigner{})("otherchannel", 0, &bytes.Buffer{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Len(t, blockfiles.served, 1)
+
+	// The same goes for the blocks
+	conn, err := grpc.Dial(address, dialOpts()...)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := archive.NewArchivedBlockProviderClient(conn)
+	getBlock := func(channelID string) error {
+		env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, channelID, fakeSigner{}, &archive.ArchivedBlockRequest{}, 0, 0)
+		require.NoError(t, err)
+		_, err = client.GetBlock(context.Background(), env)
+		return err
+	}
+	assert.Equal(t, codes.Unimplemented, status.Code(getBlock("mychannel")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(getBlock("otherchannel")))
+	_, err = client.GetBlock(context.Background(), &common.Envelope{Payload: []byte("garbage")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// A request signed outside the time window of the requests is refused before the policy is checked
+	checked = nil
+	env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, "mychannel", fakeSigner{}, &archive.ArchivedBlockRequest{}, 0, 0)
+	require.NoError(t, err)
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	require.NoError(t, err)
+	ch, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+	require.NoError(t, err)
+	ch.Timestamp.Seconds -= int64(2 * requestTimeDiff / time.Second)
+	payload.Header.ChannelHeader = protoutil.MarshalOrPanic(ch)
+	env.Payload = protoutil.MarshalOrPanic(payload)
+	_, err = client.GetBlock(context.Background(), env)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Empty(t, checked)
+
+	// The handshake carries no channel and is not authorized by the interceptors
+	checked = nil
+	_, err = archivev1.NewArchiverServiceClient(conn).Handshake(context.Background(), &archivev1.HandshakeRequest{
+		ProtocolVersions: []string{ProtocolVersionV1},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, checked)
+}
+
+func TestChannelAuthorizerHTTP(t *testing.T) {
+	authorizer := NewChannelAuthorizer(func(env *common.Envelope, channelID string) error {
+		if channelID != "mychannel" {
+			return errors.Errorf("not a reader of channel %s", channelID)
+		}
+		return nil
+	})
+	newRequest := func(method, target, body, channelID string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if channelID != "" {
+			require.NoError(t, SignHTTPRequest(req, channelID, fakeSigner{}))
+		}
+		return req
+	}
+	authStatus := func(err error) int {
+		require.IsType(t, &httpAuthError{}, err)
+		return err.(*httpAuthError).status
+	}
+
+	// The requests signed by a reader of the channel are authorized, and their body can be read again
+	req := newRequest(http.MethodPost, "/archiver/restore", `{"channel":"mychannel"}`, "mychannel")
+	creator, err := authorizer.AuthorizeHTTP(req, "mychannel")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("creator"), creator)
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"channel":"mychannel"}`, string(body))
+
+	// The unsigned requests are not authenticated
+	_, err = authorizer.AuthorizeHTTP(newRequest(http.MethodGet, "/archiver/blockfiles/mychannel/0", "", ""), "mychannel")
+	assert.Equal(t, http.StatusUnauthorized, authStatus(err))
+	req = newRequest(http.MethodGet, "/archiver/blockfiles/mychannel/0", "", "")
+	req.Header.Set(blockarchive.RequestAuthorizationHeader, "garbage")
+	_, err = authorizer.AuthorizeHTTP(req, "mychannel")
+	assert.Equal(t, http.StatusUnauthorized, authStatus(err))
+
+	// The signature covers the URI and the body of the request
+	req = newRequest(http.MethodGet, "/archiver/blockfiles/mychannel/0", "", "mychannel")
+	req.URL, _ = url.Parse("/archiver/blockfiles/mychannel/1")
+	req.RequestURI = req.URL.RequestURI()
+	_, err = authorizer.AuthorizeHTTP(req, "mychannel")
+	assert.Equal(t, http.StatusUnauthorized, authStatus(err))
+	req = newRequest(http.MethodPost, "/archiver/restore", `{"channel":"mychannel"}`, "mychannel")
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"channel":"otherchannel"}`))
+	_, err = authorizer.AuthorizeHTTP(req, "mychannel")
+	assert.Equal(t, http.StatusUnauthorized, authStatus(err))
+
+	// The requests of a channel must be signed for the channel, by a reader of the channel
+	_, err = authorizer.AuthorizeHTTP(newRequest(http.MethodGet, "/archiver/blockfiles/mychannel/0", "", "otherchannel"), "mychannel")
+	assert.Equal(t, http.StatusForbidden, authStatus(err))
+	_, err = authorizer.AuthorizeHTTP(newRequest(http.MethodGet, "/archiver/blockfiles/otherchannel/0", "", "otherchannel"), "otherchannel")
+	assert.Equal(t, http.StatusForbidden, authStatus(err))
+
+	rec := httptest.NewRecorder()
+	replyUnauthorized(rec, &httpAuthError{status: http.StatusUnauthorized, msg: "missing header"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, blockarchive.RequestAuthorizationHeader, rec.Header().Get("WWW-Authenticate"))
+}
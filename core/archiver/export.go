@@ -0,0 +1,244 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+// BlockExportPath is the path of the operations endpoint which streams the blocks of a channel,
+// followed by <channel>
+const BlockExportPath = "/archiver/blocks/"
+
+const (
+	// BlockExportNDJSON is newline-delimited JSON, one block per line in the protobuf JSON mapping
+	BlockExportNDJSON = "ndjson"
+	// BlockExportProtobuf is a sequence of blocks in the protobuf encoding, each one prefixed with
+	// its length as a varint, like the blocks of a blockfile
+	BlockExportProtobuf = "protobuf"
+)
+
+const (
+	// ExportBlocksTrailer is the trailer of an export holding the number of exported blocks
+	ExportBlocksTrailer = "Archiver-Export-Blocks"
+	// ExportSHA256Trailer is the trailer of an export holding the hex SHA-256 of its body. The trailers are
+	// only sent once the export is complete, so that a truncated export is detected by their absence.
+	ExportSHA256Trailer = "Archiver-Export-Sha256"
+)
+
+// BlockExportHandler streams the decoded blocks of a range of a channel on the operations endpoint,
+// so that the data-lake ingestion jobs read the blocks without the plumbing of a Fabric SDK:
+//
+//	GET /archiver/blocks/<channel>?format=ndjson|protobuf&start=<block>&end=<block>
+//
+// The blocks are read through the ledger, from the local blockfiles or from the archive for the
+// discarded ones. The range ends with the last committed block when end is omitted. The requests are
+// authorized against the channel, and the export ends with the ExportBlocksTrailer and ExportSHA256Trailer.
+type BlockExportHandler struct {
+	// GetLedger returns the ledger of a channel, nil if the peer has not joined the channel
+	GetLedger func(channelID string) ledger.PeerLedger
+	// AccessAudit records the exports, nil if they are not audited
+	AccessAudit *AccessAuditLog
+	// Authorizer authorizes the requests, which are all denied if it is nil
+	Authorizer *ChannelAuthorizer
+}
+
+// ServeHTTP serves GET <BlockExportPath><channel>
+func (h *BlockExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	access := h.AccessAudit.beginHTTP(w, r, BlockExportPath)
+	defer access.end()
+	w = access
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	channelID := strings.TrimPrefix(r.URL.Path, BlockExportPath)
+	if channelID == "" || strings.Contains(channelID, "/") {
+		http.Error(w, "expected "+BlockExportPath+"<channel>", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = BlockExportNDJSON
+	}
+	var start, end uint64 = 0, math.MaxUint64
+	var err error
+	if s := query.Get("start"); s != "" {
+		if start, err = strconv.ParseUint(s, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid start block [%s]", s), http.StatusBadRequest)
+			return
+		}
+	}
+	if e := query.Get("end"); e != "" {
+		if end, err = strconv.ParseUint(e, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid end block [%s]", e), http.StatusBadRequest)
+			return
+		}
+	}
+	if start > end {
+		http.Error(w, fmt.Sprintf("invalid block range [%d-%d]", start, end), http.StatusBadRequest)
+		return
+	}
+
+	var write func(bw *bufio.Writer, block *common.Block) error
+	switch format {
+	case BlockExportNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		marshaler := &jsonpb.Marshaler{OrigName: true}
+		write = func(bw *bufio.Writer, block *common.Block) error {
+			if err := marshaler.Marshal(bw, block); err != nil {
+				return errors.Wrapf(err, "error marshaling block [%d]", block.Header.Number)
+			}
+			return bw.WriteByte('\n')
+		}
+	case BlockExportProtobuf:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		write = func(bw *bufio.Writer, block *common.Block) error {
+			b, err := proto.Marshal(block)
+			if err != nil {
+				return errors.Wrapf(err, "error marshaling block [%d]", block.Header.Number)
+			}
+			if _, err := bw.Write(proto.EncodeVarint(uint64(len(b)))); err != nil {
+				return err
+			}
+			_, err = bw.Write(b)
+			return err
+		}
+	default:
+		http.Error(w, fmt.Sprintf("invalid export format [%s], must be either %s or %s",
+			format, BlockExportNDJSON, BlockExportProtobuf), http.StatusBadRequest)
+		return
+	}
+
+	access.entry.ChannelID = channelID
+	creator, err := h.Authorizer.AuthorizeHTTP(r, channelID)
+	if err != nil {
+		replyUnauthorized(w, err)
+		return
+	}
+	access.entry.Requester = requesterOf(creator)
+
+	itr, end, ok := openBlockRange(w, h.GetLedger, channelID, start, end)
+	if !ok {
+		return
+	}
+	defer itr.Close()
+	access.entry.setBlocks(start, end)
+
+	// The errors can only be logged once the blocks are being streamed
+	trailer := newExportTrailer(w)
+	bw := bufio.NewWriter(trailer.writer(w))
+	if !exportBlockRange(r, itr, channelID, start, end, func(block *common.Block) error {
+		trailer.blocks++
+		return write(bw, block)
+	}) {
+		access.fail("the export was interrupted")
+		return
+	}
+	if err := bw.Flush(); err != nil {
+		loggerArchive.Warningf("[%s] Export of blocks [%d-%d] failed: %s", channelID, start, end, err)
+		access.fail(err.Error())
+		return
+	}
+	trailer.send(w)
+	loggerArchive.Infof("[%s] Exported blocks [%d-%d] as %s", channelID, start, end, format)
+}
+
+// exportTrailer counts the blocks of an export and hashes its body, to be sent in the trailers of the response
+type exportTrailer struct {
+	blocks uint64
+	hash   hash.Hash
+}
+
+// newExportTrailer declares the trailers of an export, which must be done before its body is written
+func newExportTrailer(w http.ResponseWriter) *exportTrailer {
+	w.Header().Set("Trailer", ExportBlocksTrailer+", "+ExportSHA256Trailer)
+	return &exportTrailer{hash: sha256.New()}
+}
+
+// writer returns a writer hashing the body written to w
+func (t *exportTrailer) writer(w io.Writer) io.Writer {
+	return io.MultiWriter(w, t.hash)
+}
+
+// send sets the trailers once the export is complete
+func (t *exportTrailer) send(w http.ResponseWriter) {
+	w.Header().Set(ExportBlocksTrailer, strconv.FormatUint(t.blocks, 10))
+	w.Header().Set(ExportSHA256Trailer, hex.EncodeToString(t.hash.Sum(nil)))
+}
+
+// openBlockRange returns an iterator over the blocks of a channel from start, along with the end of the range
+// capped to the last committed block. It replies with an error and returns false if the range can't be read.
+func openBlockRange(w http.ResponseWriter, getLedger func(string) ledger.PeerLedger, channelID string, start, end uint64) (commonledger.ResultsIterator, uint64, bool) {
+	l := getLedger(channelID)
+	if l == nil {
+		http.Error(w, "channel "+channelID+" not found", http.StatusNotFound)
+		return nil, 0, false
+	}
+	info, err := l.GetBlockchainInfo()
+	if err != nil {
+		http.Error(w, "failed to read the blockchain info", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	if start >= info.Height {
+		http.Error(w, fmt.Sprintf("block [%d] not found, the height is %d", start, info.Height), http.StatusNotFound)
+		return nil, 0, false
+	}
+	// The iterator waits for the blocks to come past the last committed one
+	if end >= info.Height {
+		end = info.Height - 1
+	}
+	itr, err := l.GetBlocksIterator(start)
+	if err != nil {
+		http.Error(w, "failed to read the blocks: "+err.Error(), http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	return itr, end, true
+}
+
+// exportBlockRange writes the blocks [start-end] read from the iterator until the request is canceled. The
+// response is being streamed, so that the errors are logged. It returns false if the range was not exported.
+func exportBlockRange(r *http.Request, itr commonledger.ResultsIterator, channelID string, start, end uint64, write func(*common.Block) error) bool {
+	exported := 0
+	for number := start; number <= end; number++ {
+		if r.Context().Err() != nil {
+			loggerArchive.Infof("[%s] Export of blocks [%d-%d] canceled after %d blocks", channelID, start, end, exported)
+			return false
+		}
+		result, err := itr.Next()
+		if err != nil {
+			loggerArchive.Errorf("[%s] Export of blocks [%d-%d] failed reading block [%d]: %s", channelID, start, end, number, err)
+			return false
+		}
+		block, ok := result.(*common.Block)
+		if !ok || block == nil {
+			loggerArchive.Errorf("[%s] Export of blocks [%d-%d] failed, no block [%d]", channelID, start, end, number)
+			return false
+		}
+		if err := write(block); err != nil {
+			loggerArchive.Warningf("[%s] Export of blocks [%d-%d] failed writing block [%d]: %s", channelID, start, end, number, err)
+			return false
+		}
+		exported++
+	}
+	return true
+}
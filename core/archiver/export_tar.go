@@ -0,0 +1,116 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+// RangeExportPath is the path of the operations endpoint which streams a range of blocks of a channel as a tar.gz
+const RangeExportPath = "/archiver/export"
+
+// RangeExportHandler streams a range of blocks of a channel as a gzipped tarball, so that the operators
+// extract blocks with tar rather than with a custom client:
+//
+//	GET /archiver/export?channel=<channel>&from=<block>&to=<block>
+//
+// The tarball has an entry <channel>/<block number>.block per block, holding the protobuf encoding of
+// the block, like the tar export of the repository. The blocks are read through the ledger, from the
+// local blockfiles or from the archive for the discarded ones. The range must be given, and ends with
+// the last committed block when to is past it. The requests are authorized against the channel, and
+// the export ends with the ExportBlocksTrailer and ExportSHA256Trailer of the tarball.
+type RangeExportHandler struct {
+	// GetLedger returns the ledger of a channel, nil if the peer has not joined the channel
+	GetLedger func(channelID string) ledger.PeerLedger
+	// AccessAudit records the exports, nil if they are not audited
+	AccessAudit *AccessAuditLog
+	// Authorizer authorizes the requests, which are all denied if it is nil
+	Authorizer *ChannelAuthorizer
+}
+
+// ServeHTTP serves GET <RangeExportPath>
+func (h *RangeExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	access := h.AccessAudit.beginHTTP(w, r, RangeExportPath)
+	defer access.end()
+	w = access
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	channelID := query.Get("channel")
+	if channelID == "" || strings.ContainsAny(channelID, `/\`) {
+		http.Error(w, "expected "+RangeExportPath+"?channel=<channel>", http.StatusBadRequest)
+		return
+	}
+	var from, to uint64
+	var err error
+	f, t := query.Get("from"), query.Get("to")
+	if f == "" || t == "" {
+		http.Error(w, "the block range must be given with from=<block>&to=<block>", http.StatusBadRequest)
+		return
+	}
+	if from, err = strconv.ParseUint(f, 10, 64); err != nil {
+		http.Error(w, fmt.Sprintf("invalid from block [%s]", f), http.StatusBadRequest)
+		return
+	}
+	if to, err = strconv.ParseUint(t, 10, 64); err != nil {
+		http.Error(w, fmt.Sprintf("invalid to block [%s]", t), http.StatusBadRequest)
+		return
+	}
+	if from > to {
+		http.Error(w, fmt.Sprintf("invalid block range [%d-%d]", from, to), http.StatusBadRequest)
+		return
+	}
+
+	access.entry.ChannelID = channelID
+	creator, err := h.Authorizer.AuthorizeHTTP(r, channelID)
+	if err != nil {
+		replyUnauthorized(w, err)
+		return
+	}
+	access.entry.Requester = requesterOf(creator)
+
+	itr, to, ok := openBlockRange(w, h.GetLedger, channelID, from, to)
+	if !ok {
+		return
+	}
+	defer itr.Close()
+	access.entry.setBlocks(from, to)
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_%d-%d.tar.gz"`, channelID, from, to))
+	trailer := newExportTrailer(w)
+	gw := gzip.NewWriter(trailer.writer(w))
+	tw := tar.NewWriter(gw)
+	if !exportBlockRange(r, itr, channelID, from, to, func(block *common.Block) error {
+		trailer.blocks++
+		return blockarchive.WriteTarBlock(tw, channelID, block)
+	}) {
+		access.fail("the export was interrupted")
+		return
+	}
+	if err := tw.Close(); err != nil {
+		loggerArchive.Warningf("[%s] Export of blocks [%d-%d] failed: %s", channelID, from, to, err)
+		access.fail(err.Error())
+		return
+	}
+	if err := gw.Close(); err != nil {
+		loggerArchive.Warningf("[%s] Export of blocks [%d-%d] failed: %s", channelID, from, to, err)
+		access.fail(err.Error())
+		return
+	}
+	trailer.send(w)
+	loggerArchive.Infof("[%s] Exported blocks [%d-%d] as tar.gz", channelID, from, to)
+}
@@ -0,0 +1,262 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	archivertest "github.com/hyperledger/fabric/core/archiver/testutil"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeLedger reads the blocks of a ledger from a block store of the harness
+type storeLedger struct {
+	ledger.PeerLedger
+	store blkstorage.BlockStore
+}
+
+func (l *storeLedger) GetBlockchainInfo() (*common.BlockchainInfo, error) {
+	return l.store.GetBlockchainInfo()
+}
+
+func (l *storeLedger) GetBlocksIterator(startBlockNumber uint64) (commonledger.ResultsIterator, error) {
+	return l.store.RetrieveBlocks(startBlockNumber)
+}
+
+func (l *storeLedger) GetArchiveCatalog() (blockarchive.Catalog, error) {
+	return l.store.GetArchiveCatalog(), nil
+}
+
+func TestBlockExportHandler(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+	h, err := archivertest.NewHarness(archivertest.HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	info, err := h.WaitForArchived(store, 1, true, 10*time.Second)
+	require.NoError(t, err)
+
+	var rejected bool
+	authorizer := NewChannelAuthorizer(func(env *common.Envelope, channelID string) error {
+		if rejected {
+			return errors.Errorf("not a reader of channel %s", channelID)
+		}
+		return nil
+	})
+	handler := &BlockExportHandler{Authorizer: authorizer, GetLedger: func(channelID string) ledger.PeerLedger {
+		if channelID != "testLedger" {
+			return nil
+		}
+		return &storeLedger{store: store}
+	}}
+	getSigned := func(url, channelID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if channelID != "" {
+			require.NoError(t, SignHTTPRequest(req, channelID, fakeSigner{}))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		return getSigned(url, "testLedger")
+	}
+
+	// The range spans the discarded blockfile and the local ones
+	rec := get(BlockExportPath + "testLedger?start=5&end=25")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	// The trailers hold the number of blocks and the hash of the body
+	sum := sha256.Sum256(rec.Body.Bytes())
+	trailer := rec.Result().Trailer
+	assert.Equal(t, "21", trailer.Get(ExportBlocksTrailer))
+	assert.Equal(t, hex.EncodeToString(sum[:]), trailer.Get(ExportSHA256Trailer))
+	scanner := bufio.NewScanner(rec.Body)
+	scanner.Buffer(nil, 10*1024*1024)
+	number := uint64(5)
+	for scanner.Scan() {
+		block := &common.Block{}
+		require.NoError(t, jsonpb.UnmarshalString(scanner.Text(), block))
+		assert.True(t, proto.Equal(blocks[number], block))
+		number++
+	}
+	assert.Equal(t, uint64(26), number)
+	assert.True(t, 5 < info.FirstBlockNum && info.LastBlockNum < 25)
+
+	// The range ends with the last committed block
+	rec = get(BlockExportPath + "testLedger?format=protobuf&start=20")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	reader := bufio.NewReader(rec.Body)
+	number = 20
+	for {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b := make([]byte, length)
+		_, err = io.ReadFull(reader, b)
+		require.NoError(t, err)
+		block := &common.Block{}
+		require.NoError(t, proto.Unmarshal(b, block))
+		assert.True(t, proto.Equal(blocks[number], block))
+		number++
+	}
+	assert.Equal(t, uint64(len(blocks)), number)
+
+	assert.Equal(t, http.StatusNotFound, getSigned(BlockExportPath+"otherLedger", "otherLedger").Code)
+	assert.Equal(t, http.StatusNotFound, get(BlockExportPath+"testLedger?start=40").Code)
+	assert.Equal(t, http.StatusBadRequest, get(BlockExportPath+"testLedger?start=10&end=5").Code)
+	assert.Equal(t, http.StatusBadRequest, get(BlockExportPath+"testLedger?format=xml").Code)
+	assert.True(t, bytes.Contains(get(BlockExportPath+"testLedger?format=xml").Body.Bytes(), []byte("invalid export format")))
+
+	// The exports are only served to the readers of the channel
+	assert.Equal(t, http.StatusUnauthorized, getSigned(BlockExportPath+"testLedger", "").Code)
+	assert.Equal(t, http.StatusForbidden, getSigned(BlockExportPath+"testLedger", "otherLedger").Code)
+	rejected = true
+	assert.Equal(t, http.StatusForbidden, get(BlockExportPath+"testLedger").Code)
+	rejected = false
+	handler.Authorizer = nil
+	assert.Equal(t, http.StatusForbidden, get(BlockExportPath+"testLedger").Code)
+}
+
+func TestRangeExportHandler(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+	h, err := archivertest.NewHarness(archivertest.HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	info, err := h.WaitForArchived(store, 1, true, 10*time.Second)
+	require.NoError(t, err)
+
+	var rejected bool
+	authorizer := NewChannelAuthorizer(func(env *common.Envelope, channelID string) error {
+		if rejected {
+			return errors.Errorf("not a reader of channel %s", channelID)
+		}
+		return nil
+	})
+	handler := &RangeExportHandler{Authorizer: authorizer, GetLedger: func(channelID string) ledger.PeerLedger {
+		if channelID != "testLedger" {
+			return nil
+		}
+		return &storeLedger{store: store}
+	}}
+	getSigned := func(url, channelID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if channelID != "" {
+			require.NoError(t, SignHTTPRequest(req, channelID, fakeSigner{}))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		return getSigned(url, "testLedger")
+	}
+	untar := func(rec *httptest.ResponseRecorder) []string {
+		gr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		require.NoError(t, err)
+		tr := tar.NewReader(gr)
+		var names []string
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(tr)
+			require.NoError(t, err)
+			block := &common.Block{}
+			require.NoError(t, proto.Unmarshal(b, block))
+			assert.True(t, proto.Equal(blocks[block.Header.Number], block))
+			names = append(names, header.Name)
+		}
+		return names
+	}
+
+	// The range spans the discarded blockfile and the local ones
+	rec := get(RangeExportPath + "?channel=testLedger&from=5&to=25")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="testLedger_5-25.tar.gz"`, rec.Header().Get("Content-Disposition"))
+	names := untar(rec)
+	require.Len(t, names, 21)
+	sum := sha256.Sum256(rec.Body.Bytes())
+	trailer := rec.Result().Trailer
+	assert.Equal(t, "21", trailer.Get(ExportBlocksTrailer))
+	assert.Equal(t, hex.EncodeToString(sum[:]), trailer.Get(ExportSHA256Trailer))
+	assert.Equal(t, "testLedger/00000000000000000005.block", names[0])
+	assert.Equal(t, "testLedger/00000000000000000025.block", names[20])
+	assert.True(t, 5 < info.FirstBlockNum && info.LastBlockNum < 25)
+
+	// The range ends with the last committed block
+	rec = get(RangeExportPath + "?channel=testLedger&from=30&to=100")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Len(t, untar(rec), 10)
+
+	assert.Equal(t, http.StatusBadRequest, get(RangeExportPath).Code)
+	assert.Equal(t, http.StatusNotFound, getSigned(RangeExportPath+"?channel=otherLedger&from=0&to=10", "otherLedger").Code)
+	assert.Equal(t, http.StatusNotFound, get(RangeExportPath+"?channel=testLedger&from=40&to=50").Code)
+	assert.Equal(t, http.StatusBadRequest, get(RangeExportPath+"?channel=testLedger&from=10&to=5").Code)
+	assert.Equal(t, http.StatusBadRequest, get(RangeExportPath+"?channel=testLedger&from=0&to=x").Code)
+	// The range must be given rather than defaulting to the whole channel
+	assert.Equal(t, http.StatusBadRequest, get(RangeExportPath+"?channel=testLedger").Code)
+	assert.Equal(t, http.StatusBadRequest, get(RangeExportPath+"?channel=testLedger&from=5").Code)
+	assert.Equal(t, http.StatusBadRequest, get(RangeExportPath+"?channel=testLedger&to=5").Code)
+
+	// The exports are only served to the readers of the channel
+	url := RangeExportPath + "?channel=testLedger&from=5&to=25"
+	assert.Equal(t, http.StatusUnauthorized, getSigned(url, "").Code)
+	assert.Equal(t, http.StatusForbidden, getSigned(url, "otherLedger").Code)
+	rejected = true
+	assert.Equal(t, http.StatusForbidden, get(url).Code)
+	rejected = false
+	handler.Authorizer = nil
+	assert.Equal(t, http.StatusForbidden, get(url).Code)
+}
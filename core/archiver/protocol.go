@@ -0,0 +1,161 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	archivev1 "github.com/hyperledger/fabric/protos/ledger/archive/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ProtocolVersionV1 is the version of the protocol served by ArchiverService
+	ProtocolVersionV1 = "v1"
+	// CapabilityBlock is the retrieval of single blocks with GetBlock
+	CapabilityBlock = "block"
+	// checksumCapabilityPrefix prefixes the checksum algorithms in the capabilities, e.g. "checksum:sha256"
+	checksumCapabilityPrefix = "checksum:"
+
+	handshakeTimeout = 30 * time.Second
+)
+
+// SupportedProtocolVersions returns the versions of the protocol supported by this peer, in order of preference
+func SupportedProtocolVersions() []string {
+	return []string{ProtocolVersionV1}
+}
+
+// SupportedCapabilities returns the capabilities of the protocol supported by this peer, the checksum
+// algorithms in order of preference
+func SupportedCapabilities() []string {
+	capabilities := []string{CapabilityBlock}
+	for _, algorithm := range blockarchive.PreferredChecksumAlgorithms() {
+		capabilities = append(capabilities, checksumCapabilityPrefix+algorithm)
+	}
+	return capabilities
+}
+
+// checksumAlgorithms returns the checksum algorithms among the capabilities, in their order
+func checksumAlgorithms(capabilities []string) []string {
+	var algorithms []string
+	for _, capability := range capabilities {
+		if strings.HasPrefix(capability, checksumCapabilityPrefix) {
+			algorithms = append(algorithms, strings.TrimPrefix(capability, checksumCapabilityPrefix))
+		}
+	}
+	return algorithms
+}
+
+// negotiate returns the preferred protocol version of the requester which is supported by this peer,
+// with the capabilities supported by both ends
+func negotiate(request *archivev1.HandshakeRequest) (*archivev1.HandshakeResponse, error) {
+	supported := make(map[string]bool)
+	for _, capability := range SupportedCapabilities() {
+		supported[capability] = true
+	}
+	for _, version := range request.ProtocolVersions {
+		for _, v := range SupportedProtocolVersions() {
+			if version != v {
+				continue
+			}
+			response := &archivev1.HandshakeResponse{ProtocolVersion: version}
+			for _, capability := range request.Capabilities {
+				if supported[capability] {
+					response.Capabilities = append(response.Capabilities, capability)
+				}
+			}
+			return response, nil
+		}
+	}
+	return nil, errors.Errorf("no supported protocol version among %s, supported versions are %s",
+		request.ProtocolVersions, SupportedProtocolVersions())
+}
+
+// ArchiverService serves the version 1 of the protocol of the archiver peers, which opens with a handshake
+// negotiating the capabilities of the exchange. The blocks and blockfiles are served by the ArchivedBlockProvider,
+// which keeps serving the peers predating the handshake through the unversioned service.
+type ArchiverService struct {
+	provider *ArchivedBlockProvider
+}
+
+// NewArchiverService creates an ArchiverService serving the blocks and blockfiles of the provider
+func NewArchiverService(provider *ArchivedBlockProvider) *ArchiverService {
+	return &ArchiverService{provider: provider}
+}
+
+// Handshake negotiates the protocol version and the capabilities of the exchange
+func (s *ArchiverService) Handshake(ctx context.Context, request *archivev1.HandshakeRequest) (*archivev1.HandshakeResponse, error) {
+	response, err := negotiate(request)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return response, nil
+}
+
+// GetBlockfile streams a blockfile of the channel of the request
+func (s *ArchiverService) GetBlockfile(env *common.Envelope, stream archivev1.ArchiverService_GetBlockfileServer) error {
+	return s.provider.sendBlockfile(env, stream)
+}
+
+// GetBlock returns a block of the channel of the request
+func (s *ArchiverService) GetBlock(ctx context.Context, env *common.Envelope) (*common.Block, error) {
+	return s.provider.GetBlock(ctx, env)
+}
+
+// blockfileReceiver is the client stream of a blockfile, of any version of the protocol
+type blockfileReceiver interface {
+	Recv() (*archive.BlockfileChunk, error)
+}
+
+// blockfileClient requests the blockfiles with the version of the protocol negotiated with the archiver peer
+type blockfileClient struct {
+	getBlockfile func(ctx context.Context, env *common.Envelope) (blockfileReceiver, error)
+	// checksumAlgorithms are the checksum algorithms supported by both ends, in order of preference
+	checksumAlgorithms []string
+}
+
+// newBlockfileClient negotiates the protocol with the archiver peer at the other end of the connection.
+// It falls back to the unversioned service of the archiver peers predating the handshake.
+func newBlockfileClient(conn *grpc.ClientConn) (*blockfileClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	client := archivev1.NewArchiverServiceClient(conn)
+	response, err := client.Handshake(ctx, &archivev1.HandshakeRequest{
+		ProtocolVersions: SupportedProtocolVersions(),
+		Capabilities:     SupportedCapabilities(),
+	})
+	if status.Code(err) == codes.Unimplemented {
+		loggerRetrieve.Infof("Archiver peer at %s doesn't support the versioned protocol, using the unversioned one", conn.Target())
+		legacy := archive.NewArchivedBlockProviderClient(conn)
+		return &blockfileClient{
+			getBlockfile: func(ctx context.Context, env *common.Envelope) (blockfileReceiver, error) {
+				return legacy.GetBlockfile(ctx, env)
+			},
+			checksumAlgorithms: blockarchive.PreferredChecksumAlgorithms(),
+		}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error negotiating the protocol with the archiver peer at %s", conn.Target())
+	}
+	if response.ProtocolVersion != ProtocolVersionV1 {
+		return nil, errors.Errorf("archiver peer at %s negotiated unsupported protocol version [%s]", conn.Target(), response.ProtocolVersion)
+	}
+	loggerRetrieve.Infof("Negotiated protocol %s with capabilities %s with the archiver peer at %s",
+		response.ProtocolVersion, response.Capabilities, conn.Target())
+	return &blockfileClient{
+		getBlockfile: func(ctx context.Context, env *common.Envelope) (blockfileReceiver, error) {
+			return client.GetBlockfile(ctx, env)
+		},
+		checksumAlgorithms: checksumAlgorithms(response.Capabilities),
+	}, nil
+}
@@ -0,0 +1,125 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	archivev1 "github.com/hyperledger/fabric/protos/ledger/archive/v1"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSigner struct{}
+
+func (fakeSigner) Sign(message []byte) ([]byte, error) { return []byte("signature"), nil }
+func (fakeSigner) Serialize() ([]byte, error)          { return []byte("creator"), nil }
+
+// fakeBlockfileServer serves a fixed blockfile with the checksum algorithm negotiated with the requester
+type fakeBlockfileServer struct {
+	content []byte
+	// served records the version of the protocol of the requests
+	served []string
+}
+
+func (s *fakeBlockfileServer) send(version string, env *common.Envelope, send func(*archive.BlockfileChunk) error) error {
+	s.served = append(s.served, version)
+	request := &archive.ArchivedBlockfileRequest{}
+	if _, err := protoutil.UnmarshalEnvelopeOfType(env, common.HeaderType_MESSAGE, request); err != nil {
+		return err
+	}
+	algorithm := blockarchive.NegotiateChecksumAlgorithm(request.ChecksumAlgorithms)
+	checksumWriter, err := blockarchive.NewChecksumWriter(algorithm)
+	if err != nil {
+		return err
+	}
+	checksumWriter.Write(s.content)
+	return send(&archive.BlockfileChunk{Content: s.content, ChecksumAlgorithm: algorithm, Checksum: checksumWriter.Checksum().String()})
+}
+
+type fakeProvider struct{ *fakeBlockfileServer }
+
+func (p fakeProvider) GetBlockfile(env *common.Envelope, stream archive.ArchivedBlockProvider_GetBlockfileServer) error {
+	return p.send("unversioned", env, stream.Send)
+}
+
+func (p fakeProvider) GetBlock(ctx context.Context, env *common.Envelope) (*common.Block, error) {
+	return nil, status.Error(codes.Unimplemented, "not implemented")
+}
+
+func (p fakeProvider) GetBlocks(env *common.Envelope, stream archive.ArchivedBlockProvider_GetBlocksServer) error {
+	return status.Error(codes.Unimplemented, "not implemented")
+}
+
+// fakeArchiverService negotiates the protocol like an archiver peer
+type fakeArchiverService struct {
+	*ArchiverService
+	*fakeBlockfileServer
+}
+
+func (s fakeArchiverService) GetBlockfile(env *common.Envelope, stream archivev1.ArchiverService_GetBlockfileServer) error {
+	return s.send(ProtocolVersionV1, env, stream.Send)
+}
+
+func startServer(t *testing.T, register func(*grpc.Server)) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(listener)
+	return listener.Addr().String(), server.Stop
+}
+
+func TestArchiverServiceHandshake(t *testing.T) {
+	service := NewArchiverService(nil)
+	response, err := service.Handshake(context.Background(), &archivev1.HandshakeRequest{
+		ProtocolVersions: []string{"v2", ProtocolVersionV1},
+		Capabilities:     []string{"compression:zstd", "checksum:blake3", CapabilityBlock},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolVersionV1, response.ProtocolVersion)
+	assert.Equal(t, []string{"checksum:blake3", CapabilityBlock}, response.Capabilities)
+
+	_, err = service.Handshake(context.Background(), &archivev1.HandshakeRequest{ProtocolVersions: []string{"v2"}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestBlockfileFetcherProtocolVersions(t *testing.T) {
+	dialOpts := func() []grpc.DialOption { return []grpc.DialOption{grpc.WithInsecure()} }
+	content := []byte("blockfile content")
+
+	// An archiver peer serving the versioned protocol
+	blockfiles := &fakeBlockfileServer{content: content}
+	address, stop := startServer(t, func(server *grpc.Server) {
+		archivev1.RegisterArchiverServiceServer(server, fakeArchiverService{NewArchiverService(nil), blockfiles})
+		archive.RegisterArchivedBlockProviderServer(server, fakeProvider{blockfiles})
+	})
+	defer stop()
+	buf := &bytes.Buffer{}
+	require.NoError(t, NewBlockfileFetcher(address, dialOpts, fakeSigner{})("mychannel", 0, buf))
+	assert.Equal(t, content, buf.Bytes())
+	assert.Equal(t, []string{ProtocolVersionV1}, blockfiles.served)
+
+	// An archiver peer predating the handshake
+	blockfiles = &fakeBlockfileServer{content: content}
+	address, stop = startServer(t, func(server *grpc.Server) {
+		archive.RegisterArchivedBlockProviderServer(server, fakeProvider{blockfiles})
+	})
+	defer stop()
+	buf.Reset()
+	require.NoError(t, NewBlockfileFetcher(address, dialOpts, fakeSigner{})("mychannel", 0, buf))
+	assert.Equal(t, content, buf.Bytes())
+	assert.Equal(t, []string{"unversioned"}, blockfiles.served)
+}
@@ -0,0 +1,317 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const requestTimeDiff = 15 * time.Minute
+
+// maxRequestedBlocks is the number of blocks served at most for a single GetBlocks request
+const maxRequestedBlocks = 1000
+
+// AccessControlEvaluator evaluates whether the creator of the given SignedData
+// is eligible of fetching archived blocks
+type AccessControlEvaluator interface {
+	// Evaluate evaluates the eligibility of the creator of the given SignedData
+	Evaluate(signatureSet []*protoutil.SignedData) error
+}
+
+// ArchivedBlockProvider serves the blocks and blockfiles of an archiver peer to the other peers of its
+// organization, so that only the archiver peer needs access to the repository. The requests must be
+// signed by a member of the organization, and are authorized against the policies of their channel
+// by the ChannelAuthorizer of the gRPC server.
+type ArchivedBlockProvider struct {
+	getLedger func(channelID string) ledger.PeerLedger
+	ace       AccessControlEvaluator
+}
+
+// NewArchivedBlockProvider creates an ArchivedBlockProvider serving the ledgers returned by getLedger
+// to the requesters accepted by ace
+func NewArchivedBlockProvider(getLedger func(channelID string) ledger.PeerLedger, ace AccessControlEvaluator) *ArchivedBlockProvider {
+	return &ArchivedBlockProvider{getLedger: getLedger, ace: ace}
+}
+
+// GetBlockfile streams a blockfile of the channel of the request
+func (p *ArchivedBlockProvider) GetBlockfile(env *common.Envelope, stream archive.ArchivedBlockProvider_GetBlockfileServer) error {
+	return p.sendBlockfile(env, stream)
+}
+
+// blockfileSender is the server stream of a blockfile, of any version of the protocol
+type blockfileSender interface {
+	Send(*archive.BlockfileChunk) error
+	Context() context.Context
+}
+
+// sendBlockfile streams the blockfile of the request
+func (p *ArchivedBlockProvider) sendBlockfile(env *common.Envelope, stream blockfileSender) error {
+	request := &archive.ArchivedBlockfileRequest{}
+	l, channelID, err := p.validate(stream.Context(), env, request)
+	if err != nil {
+		return err
+	}
+	catalog, err := l.GetArchiveCatalog()
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed to read the archive catalog: %s", channelID, err)
+		return status.Error(codes.Internal, "failed to read the archive catalog")
+	}
+	blockfile, err := fsblkstorage.OpenBlockfileForProxy(channelID, int(request.BlockfileNo), catalog)
+	if err == fsblkstorage.ErrBlockfileNotFound {
+		return status.Errorf(codes.NotFound, "blockfile [%d] not found", request.BlockfileNo)
+	}
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed to open blockfile [%d] for a peer: %s", channelID, request.BlockfileNo, err)
+		return status.Error(codes.Internal, "failed to open the blockfile")
+	}
+	defer blockfile.Close()
+
+	// The first chunk carries the checksum algorithm negotiated with the requester,
+	// and the last one the checksum of the whole blockfile
+	algorithm := blockarchive.NegotiateChecksumAlgorithm(request.ChecksumAlgorithms)
+	checksumWriter, err := blockarchive.NewChecksumWriter(algorithm)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	addr := util.ExtractRemoteAddress(stream.Context())
+	log := loggerRetrieve.With(blockarchive.LogKeyChannel, channelID, blockarchive.LogKeyBlockfile, request.BlockfileNo, "peer", addr)
+	start := time.Now()
+	// The chunks are read into a pooled buffer, which bounds the memory of the concurrent transfers
+	buffers := blockarchive.RetrievalBuffers()
+	buf := buffers.Get()
+	defer buffers.Put(buf)
+	written := 0
+	chunk := &archive.BlockfileChunk{ChecksumAlgorithm: algorithm}
+	for {
+		n, err := io.ReadFull(blockfile, buf)
+		if n > 0 {
+			checksumWriter.Write(buf[:n])
+			chunk.Content = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				log.Warnw("Failed sending blockfile", blockarchive.LogKeyBytes, written, "error", err)
+				return err
+			}
+			chunk = &archive.BlockfileChunk{}
+			written += n
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			log.Errorw("Failed reading blockfile", blockarchive.LogKeyBytes, written, "error", err)
+			return status.Error(codes.Internal, "failed to read the blockfile")
+		}
+	}
+	chunk.Checksum = checksumWriter.Checksum().String()
+	if err := stream.Send(chunk); err != nil {
+		return err
+	}
+	log.Infow("Sent blockfile", blockarchive.LogKeyBytes, written, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+	return nil
+}
+
+// GetBlock returns a block of the channel of the request
+func (p *ArchivedBlockProvider) GetBlock(ctx context.Context, env *common.Envelope) (*common.Block, error) {
+	request := &archive.ArchivedBlockRequest{}
+	l, channelID, err := p.validate(ctx, env, request)
+	if err != nil {
+		return nil, err
+	}
+	info, err := l.GetBlockchainInfo()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to read the blockchain info")
+	}
+	if request.BlockNumber >= info.Height {
+		return nil, status.Errorf(codes.NotFound, "block [%d] not found", request.BlockNumber)
+	}
+	block, err := l.GetBlockByNumber(request.BlockNumber)
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed to read block [%d] for a peer: %s", channelID, request.BlockNumber, err)
+		return nil, status.Error(codes.Internal, "failed to read the block")
+	}
+	return block, nil
+}
+
+// GetBlocks streams a range of blocks of the channel of the request
+func (p *ArchivedBlockProvider) GetBlocks(env *common.Envelope, stream archive.ArchivedBlockProvider_GetBlocksServer) error {
+	request := &archive.ArchivedBlockRangeRequest{}
+	l, channelID, err := p.validate(stream.Context(), env, request)
+	if err != nil {
+		return err
+	}
+	info, err := l.GetBlockchainInfo()
+	if err != nil {
+		return status.Error(codes.Internal, "failed to read the blockchain info")
+	}
+	if err := validateBlockRange(request, info.Height); err != nil {
+		return err
+	}
+	for blockNum := request.StartBlock; blockNum <= request.EndBlock; blockNum++ {
+		block, err := l.GetBlockByNumber(blockNum)
+		if err != nil {
+			loggerArchive.Errorf("[%s] Failed to read block [%d] for a peer: %s", channelID, blockNum, err)
+			return status.Error(codes.Internal, "failed to read the block")
+		}
+		if err := stream.Send(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBlockRange checks that a range of blocks is ordered, holds at most maxRequestedBlocks blocks,
+// and ends below the height of the ledger
+func validateBlockRange(request *archive.ArchivedBlockRangeRequest, height uint64) error {
+	if request.StartBlock > request.EndBlock {
+		return status.Errorf(codes.InvalidArgument, "invalid range: start block [%d] is after end block [%d]", request.StartBlock, request.EndBlock)
+	}
+	if request.EndBlock-request.StartBlock >= maxRequestedBlocks {
+		return status.Errorf(codes.InvalidArgument, "invalid range: %d blocks requested, at most %d blocks are served at a time",
+			request.EndBlock-request.StartBlock+1, maxRequestedBlocks)
+	}
+	if request.EndBlock >= height {
+		return status.Errorf(codes.NotFound, "block [%d] not found", request.EndBlock)
+	}
+	return nil
+}
+
+// validate checks that a request is well formed, recent, and signed by a member of the organization,
+// and returns the ledger of its channel
+func (p *ArchivedBlockProvider) validate(ctx context.Context, env *common.Envelope, request proto.Message) (ledger.PeerLedger, string, error) {
+	// The service is registered on the client peers as well, which may acquire the archiver role
+	if !blockarchive.IsArchiver {
+		return nil, "", status.Error(codes.Unavailable, "the peer is not the archiver")
+	}
+	ch, err := validateRequest(ctx, env, request, p.ace)
+	if err != nil {
+		return nil, "", err
+	}
+	if ch.ChannelId == "" {
+		return nil, "", status.Error(codes.InvalidArgument, "empty channel")
+	}
+	l := p.getLedger(ch.ChannelId)
+	if l == nil {
+		return nil, "", status.Errorf(codes.NotFound, "channel %s not found", ch.ChannelId)
+	}
+	return l, ch.ChannelId, nil
+}
+
+// validateRequest checks that a request is well formed, recent, and signed by a requester accepted
+// by ace, and returns its channel header
+func validateRequest(ctx context.Context, env *common.Envelope, request proto.Message, ace AccessControlEvaluator) (*common.ChannelHeader, error) {
+	addr := util.ExtractRemoteAddress(ctx)
+	if env == nil {
+		return nil, status.Error(codes.InvalidArgument, "nil envelope")
+	}
+	ch, err := protoutil.UnmarshalEnvelopeOfType(env, common.HeaderType_MESSAGE, request)
+	if err != nil {
+		loggerArchive.Warningf("Request from %s is badly formed: %s", addr, err)
+		return nil, status.Errorf(codes.InvalidArgument, "bad request: %s", err)
+	}
+	if ch.Timestamp == nil {
+		return nil, status.Error(codes.InvalidArgument, "empty timestamp")
+	}
+	reqTs := time.Unix(ch.Timestamp.Seconds, int64(ch.Timestamp.Nanos))
+	now := time.Now()
+	if reqTs.Add(requestTimeDiff).Before(now) || reqTs.Add(-requestTimeDiff).After(now) {
+		loggerArchive.Warningf("Request from %s unauthorized due to incorrect time: %s", addr, reqTs)
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
+	sd, err := protoutil.EnvelopeAsSignedData(env)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad request, cannot extract signed data: %s", err)
+	}
+	if err := ace.Evaluate(sd); err != nil {
+		loggerArchive.Warningf("Request from %s unauthorized: %s", addr, err)
+		return nil, status.Error(codes.PermissionDenied, "access denied")
+	}
+	return ch, nil
+}
+
+// NewBlockfileFetcher returns a function which retrieves the blockfiles of a client peer through
+// the archiver peer at address. The connection is established, and the protocol negotiated with
+// the archiver peer, on first use.
+func NewBlockfileFetcher(address string, dialOpts func() []grpc.DialOption, signer identity.SignerSerializer) func(ledgerID string, fileNum int, w io.Writer) error {
+	var (
+		mutex  sync.Mutex
+		client *blockfileClient
+	)
+	getClient := func() (*blockfileClient, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if client == nil {
+			conn, err := grpc.Dial(address, dialOpts()...)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error connecting to the archiver peer at %s", address)
+			}
+			if client, err = newBlockfileClient(conn); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return client, nil
+	}
+
+	return func(ledgerID string, fileNum int, w io.Writer) error {
+		client, err := getClient()
+		if err != nil {
+			return err
+		}
+		env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, ledgerID, signer, &archive.ArchivedBlockfileRequest{
+			BlockfileNo:        uint64(fileNum),
+			ChecksumAlgorithms: client.checksumAlgorithms,
+		}, 0, 0)
+		if err != nil {
+			return errors.WithMessage(err, "error creating the blockfile request")
+		}
+		stream, err := client.getBlockfile(context.Background(), env)
+		if err != nil {
+			return err
+		}
+		var verifier *blockarchive.ChecksumWriter
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				return errors.New("archiver peer sent no checksum of the blockfile")
+			}
+			if err != nil {
+				return err
+			}
+			if verifier == nil {
+				if verifier, err = blockarchive.NewChecksumWriter(chunk.ChecksumAlgorithm); err != nil {
+					return errors.WithMessage(err, "archiver peer negotiated no valid checksum algorithm")
+				}
+			}
+			if _, err := w.Write(chunk.Content); err != nil {
+				return err
+			}
+			verifier.Write(chunk.Content)
+			if chunk.Checksum != "" {
+				expected, err := blockarchive.ParseChecksum(chunk.Checksum)
+				if err != nil {
+					return err
+				}
+				return verifier.Verify(expected)
+			}
+		}
+	}
+}
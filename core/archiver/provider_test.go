@@ -0,0 +1,153 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blocksLedger serves a fixed chain of blocks
+type blocksLedger struct {
+	ledger.PeerLedger
+	blocks []*common.Block
+}
+
+func (l *blocksLedger) GetBlockchainInfo() (*common.BlockchainInfo, error) {
+	return &common.BlockchainInfo{Height: uint64(len(l.blocks))}, nil
+}
+
+func (l *blocksLedger) GetBlockByNumber(blockNumber uint64) (*common.Block, error) {
+	return l.blocks[blockNumber], nil
+}
+
+// receiveBlocks requests a range of blocks of a channel and returns the blocks received
+func receiveBlocks(t *testing.T, client archive.ArchivedBlockProviderClient, channelID string, request *archive.ArchivedBlockRangeRequest) ([]*common.Block, error) {
+	env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, channelID, fakeSigner{}, request, 0, 0)
+	require.NoError(t, err)
+	stream, err := client.GetBlocks(context.Background(), env)
+	require.NoError(t, err)
+	var blocks []*common.Block
+	for {
+		block, err := stream.Recv()
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+func TestArchivedBlockProviderGetBlocks(t *testing.T) {
+	defer func(isArchiver bool) { blockarchive.IsArchiver = isArchiver }(blockarchive.IsArchiver)
+	blockarchive.IsArchiver = true
+	blocks := testutil.ConstructTestBlocks(t, 1200)
+	members := &fakeAdmins{}
+	provider := NewArchivedBlockProvider(func(channelID string) ledger.PeerLedger {
+		if channelID != "testLedger" {
+			return nil
+		}
+		return &blocksLedger{blocks: blocks}
+	}, members)
+	address, stop := startServer(t, func(server *grpc.Server) { archive.RegisterArchivedBlockProviderServer(server, provider) })
+	defer stop()
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := archive.NewArchivedBlockProviderClient(conn)
+
+	// The blocks of the range are streamed in order, both ends included
+	received, err := receiveBlocks(t, client, "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 5, EndBlock: 7})
+	require.NoError(t, err)
+	require.Len(t, received, 3)
+	for i, block := range received {
+		assert.True(t, proto.Equal(blocks[5+i], block))
+	}
+	received, err = receiveBlocks(t, client, "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 100, EndBlock: 100 + maxRequestedBlocks - 1})
+	require.NoError(t, err)
+	assert.Len(t, received, maxRequestedBlocks)
+
+	// The invalid requests are rejected before any block is sent
+	for _, test := range []struct {
+		name      string
+		channelID string
+		request   *archive.ArchivedBlockRangeRequest
+		code      codes.Code
+		message   string
+	}{
+		{"empty channel", "", &archive.ArchivedBlockRangeRequest{StartBlock: 0, EndBlock: 1}, codes.InvalidArgument, "empty channel"},
+		{"unknown channel", "otherLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 0, EndBlock: 1}, codes.NotFound, "channel otherLedger not found"},
+		{"inverted range", "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 10, EndBlock: 9}, codes.InvalidArgument,
+			"invalid range: start block [10] is after end block [9]"},
+		{"range too large", "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 0, EndBlock: maxRequestedBlocks}, codes.InvalidArgument,
+			"invalid range: 1001 blocks requested, at most 1000 blocks are served at a time"},
+		{"range beyond the height", "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 1190, EndBlock: 1200}, codes.NotFound, "block [1200] not found"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received, err := receiveBlocks(t, client, test.channelID, test.request)
+			assert.Empty(t, received)
+			assert.Equal(t, test.code, status.Code(err))
+			assert.Equal(t, test.message, status.Convert(err).Message())
+		})
+	}
+
+	// Only the requesters accepted are served
+	members.rejected = true
+	received, err = receiveBlocks(t, client, "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 0, EndBlock: 1})
+	assert.Empty(t, received)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	members.rejected = false
+	stream, err := client.GetBlocks(context.Background(), &common.Envelope{Payload: []byte("garbage")})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The peer serves the blocks only while it is the archiver
+	blockarchive.IsArchiver = false
+	_, err = receiveBlocks(t, client, "testLedger", &archive.ArchivedBlockRangeRequest{StartBlock: 0, EndBlock: 1})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestValidateBlockRange(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		start, end uint64
+		err        string
+	}{
+		{"single block", 3, 3, ""},
+		{"largest range", 0, maxRequestedBlocks - 1, ""},
+		{"last block", 1999, 1999, ""},
+		{"inverted range", 4, 3, "invalid range: start block [4] is after end block [3]"},
+		{"range too large", 0, maxRequestedBlocks, "invalid range: 1001 blocks requested, at most 1000 blocks are served at a time"},
+		{"range too large at the end of the ledger", 0, 1999, "invalid range: 2000 blocks requested, at most 1000 blocks are served at a time"},
+		{"range beyond the height", 1999, 2000, "block [2000] not found"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateBlockRange(&archive.ArchivedBlockRangeRequest{StartBlock: test.start, EndBlock: test.end}, 2000)
+			if test.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.err, status.Convert(err).Message())
+		})
+	}
+}
@@ -0,0 +1,280 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/ledger"
+	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// BlockfileHandler serves the blockfiles of an archiver peer to the client peers of its organization,
+// which retrieve through it the blockfiles they have discarded. The blockfiles which the archiver
+// peer has discarded itself are read from the repository. The requests are signed by the client
+// peers, and served only if they are authorized against the policy of their channel.
+type BlockfileHandler struct {
+	// GetLedger returns the ledger of a channel, nil if the peer has not joined the channel
+	GetLedger func(channelID string) ledger.PeerLedger
+	// Authorizer authorizes the requests, which are all denied if it is nil
+	Authorizer *ChannelAuthorizer
+	// AccessAudit records the blockfiles served, nil if they are not audited
+	AccessAudit *AccessAuditLog
+}
+
+// ServeHTTP serves GET <ProxyBlockfilesPath><channel>/<blockfileNo>
+func (h *BlockfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	access := h.AccessAudit.beginHTTP(w, r, blockarchive.ProxyBlockfilesPath)
+	defer access.end()
+	w = access
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !blockarchive.IsArchiver {
+		http.Error(w, "the peer is not the archiver", http.StatusServiceUnavailable)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, blockarchive.ProxyBlockfilesPath), "/")
+	if len(parts) != 2 {
+		http.Error(w, "expected "+blockarchive.ProxyBlockfilesPath+"<channel>/<blockfileNo>", http.StatusBadRequest)
+		return
+	}
+	channelID := parts[0]
+	fileNum, err := strconv.Atoi(parts[1])
+	if err != nil || fileNum < 0 {
+		http.Error(w, "invalid blockfile number "+parts[1], http.StatusBadRequest)
+		return
+	}
+	access.entry.ChannelID = channelID
+	access.entry.setBlockfile(uint64(fileNum))
+	creator, err := h.Authorizer.AuthorizeHTTP(r, channelID)
+	if err != nil {
+		replyUnauthorized(w, err)
+		return
+	}
+	access.entry.Requester = requesterOf(creator)
+
+	l := h.GetLedger(channelID)
+	if l == nil {
+		http.Error(w, "channel "+channelID+" not found", http.StatusNotFound)
+		return
+	}
+	catalog, err := l.GetArchiveCatalog()
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed to read the archive catalog: %s", channelID, err)
+		http.Error(w, "failed to read the archive catalog", http.StatusInternalServerError)
+		return
+	}
+	// A client peer reading a single block requests only its bytes
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		access.entry.ByteRange = strings.TrimPrefix(rangeHeader, "bytes=")
+		h.serveRange(w, r, channelID, fileNum, catalog, rangeHeader)
+		return
+	}
+	blockfile, err := fsblkstorage.OpenBlockfileForProxy(channelID, fileNum, catalog)
+	if err == fsblkstorage.ErrBlockfileNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed to open blockfile [%d] for a client peer: %s", channelID, fileNum, err)
+		http.Error(w, "failed to open the blockfile", http.StatusInternalServerError)
+		return
+	}
+	defer blockfile.Close()
+
+	// The checksum of the blockfile is computed with the algorithm negotiated with the client peer,
+	// and sent in a trailer. It is omitted when the blockfile can't be served entirely.
+	algorithm := blockarchive.NegotiateChecksumAlgorithm(strings.Split(r.Header.Get(blockarchive.ProxyChecksumAlgorithmsHeader), ","))
+	checksumWriter, err := blockarchive.NewChecksumWriter(algorithm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log := loggerRetrieve.With(blockarchive.LogKeyChannel, channelID, blockarchive.LogKeyBlockfile, fileNum, "peer", r.RemoteAddr)
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(blockarchive.ProxyChecksumAlgorithmHeader, algorithm)
+	w.Header().Set("Trailer", blockarchive.ProxyChecksumTrailer)
+	written, err := io.Copy(io.MultiWriter(w, checksumWriter), blockfile)
+	if err != nil {
+		log.Warnw("Failed serving blockfile", blockarchive.LogKeyBytes, written, "error", err)
+		access.fail(err.Error())
+		return
+	}
+	w.Header().Set(blockarchive.ProxyChecksumTrailer, checksumWriter.Checksum().String())
+	log.Infow("Served blockfile", blockarchive.LogKeyBytes, written, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+}
+
+// serveRange serves a byte range of a blockfile, "bytes=<first>-<last>" or "bytes=<first>-"
+func (h *BlockfileHandler) serveRange(w http.ResponseWriter, r *http.Request, channelID string, fileNum int, catalog blockarchive.Catalog, rangeHeader string) {
+	first, last, err := parseByteRange(rangeHeader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	blockfile, size, err := fsblkstorage.OpenBlockfileRangeForProxy(channelID, fileNum, first, catalog)
+	if err == fsblkstorage.ErrBlockfileNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err == fsblkstorage.ErrEncodedBlockfileRange {
+		// The client peer retrieves the blockfile entirely instead
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed to open blockfile [%d] for a client peer: %s", channelID, fileNum, err)
+		http.Error(w, "failed to open the blockfile", http.StatusInternalServerError)
+		return
+	}
+	defer blockfile.Close()
+	if first >= size {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		http.Error(w, fmt.Sprintf("offset [%d] is beyond the end of the blockfile", first), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if last < 0 || last >= size {
+		last = size - 1
+	}
+
+	log := loggerRetrieve.With(blockarchive.LogKeyChannel, channelID, blockarchive.LogKeyBlockfile, fileNum, "peer", r.RemoteAddr)
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, size))
+	w.Header().Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	written, err := io.Copy(w, io.LimitReader(blockfile, last-first+1))
+	if err != nil {
+		log.Warnw("Failed serving blockfile range", "offset", first, blockarchive.LogKeyBytes, written, "error", err)
+		return
+	}
+	log.Debugw("Served blockfile range", "offset", first, blockarchive.LogKeyBytes, written, blockarchive.LogKeyDurationMs, blockarchive.LogDurationMs(start))
+}
+
+// parseByteRange parses a single byte range of a Range header. last is -1 if the range extends to the end.
+func parseByteRange(header string) (first, last int64, err error) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	bounds := strings.Split(spec, "-")
+	if spec == header || strings.Contains(spec, ",") || len(bounds) != 2 {
+		return 0, 0, errors.Errorf("unsupported range %s, expected bytes=<first>-<last>", header)
+	}
+	if first, err = strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64); err != nil || first < 0 {
+		return 0, 0, errors.Errorf("invalid range %s", header)
+	}
+	if strings.TrimSpace(bounds[1]) == "" {
+		return first, -1, nil
+	}
+	if last, err = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64); err != nil || last < first {
+		return 0, 0, errors.Errorf("invalid range %s", header)
+	}
+	return first, last, nil
+}
+
+// initFetchThroughParams initializes the retrieval of the discarded blockfiles of a client peer
+// through the archiver peer of its organization
+func initFetchThroughParams() {
+	blockarchive.ProxyEndpoint = viper.GetString("peer.archiving.proxyEndpoint")
+	blockarchive.FetchCacheSize = viper.GetInt("peer.archiving.cacheSize")
+	blockarchive.PrefetchWindow = viper.GetInt("peer.archiving.prefetchWindow")
+	blockarchive.RangeRetrieval = viper.GetBool("peer.archiving.rangeRetrieval")
+	initRetrievalOrder()
+	if blockarchive.ProxyEndpoint == "" {
+		return
+	}
+	tlsConfig, err := loadProxyTLSConfig()
+	if err != nil {
+		loggerArchive.Panicf("Invalid peer.archiving.proxyTLS: %s", err)
+	}
+	blockarchive.ProxyTLSConfig = tlsConfig
+	// The archiver peer authorizes the requests signed by the peer against the policies of their channel
+	signer := mspmgmt.GetLocalSigningIdentityOrPanic()
+	blockarchive.SignProxyRequest = func(req *http.Request, channelID string) error {
+		return SignHTTPRequest(req, channelID, signer)
+	}
+	loggerArchive.Infof("Discarded blockfiles are retrieved through the archiver peer at %s", blockarchive.ProxyEndpoint)
+}
+
+// initRetrievalOrder initializes the stages through which a client peer retrieves the discarded blockfiles,
+// their timeouts and retries, and the circuit breakers of the archiver peer and the repository
+func initRetrievalOrder() {
+	order, err := blockarchive.ParseRetrievalOrder(viper.GetStringSlice("peer.archiving.retrieval.order"))
+	if err != nil {
+		loggerArchive.Panicf("Invalid peer.archiving.retrieval.order: %s", err)
+	}
+	blockarchive.RetrievalOrder = order
+	blockarchive.RetrievalTimeouts = make(map[string]time.Duration)
+	for _, stage := range []string{blockarchive.RetrievalStageCache, blockarchive.RetrievalStageArchiver, blockarchive.RetrievalStageRepository} {
+		timeout := viper.GetDuration("peer.archiving.retrieval.timeouts." + stage)
+		if timeout < 0 {
+			loggerArchive.Panicf("Invalid peer.archiving.retrieval.timeouts.%s: %s", stage, timeout)
+		}
+		blockarchive.RetrievalTimeouts[stage] = timeout
+	}
+	blockarchive.RetrievalRetries = viper.GetInt("peer.archiving.retrieval.retries")
+	if blockarchive.RetrievalRetries < 0 {
+		loggerArchive.Panicf("Invalid peer.archiving.retrieval.retries: %d", blockarchive.RetrievalRetries)
+	}
+	blockarchive.RetrievalRetryBackoff = viper.GetDuration("peer.archiving.retrieval.retryBackoff")
+	if blockarchive.RetrievalRetryBackoff < 0 {
+		loggerArchive.Panicf("Invalid peer.archiving.retrieval.retryBackoff: %s", blockarchive.RetrievalRetryBackoff)
+	}
+	blockarchive.RetrievalBreakerThreshold = viper.GetInt("peer.archiving.retrieval.circuitBreaker.threshold")
+	if blockarchive.RetrievalBreakerThreshold < 0 {
+		loggerArchive.Panicf("Invalid peer.archiving.retrieval.circuitBreaker.threshold: %d", blockarchive.RetrievalBreakerThreshold)
+	}
+	blockarchive.RetrievalBreakerCooldown = viper.GetDuration("peer.archiving.retrieval.circuitBreaker.cooldown")
+	if blockarchive.RetrievalBreakerCooldown <= 0 && blockarchive.RetrievalBreakerThreshold > 0 {
+		loggerArchive.Panicf("Invalid peer.archiving.retrieval.circuitBreaker.cooldown: %s", blockarchive.RetrievalBreakerCooldown)
+	}
+	if len(order) > 0 {
+		loggerArchive.Infof("Discarded blockfiles are retrieved through the stages %s", strings.Join(order, ", "))
+	}
+}
+
+// loadProxyTLSConfig loads the TLS configuration used to connect to the archiver peer
+func loadProxyTLSConfig() (*tls.Config, error) {
+	if !strings.HasPrefix(blockarchive.ProxyEndpoint, "https://") {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{}
+	if files := viper.GetStringSlice("peer.archiving.proxyTLS.rootCAs.files"); len(files) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		for _, file := range files {
+			pem, err := ioutil.ReadFile(config.TranslatePath(filepath.Dir(viper.ConfigFileUsed()), file))
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading root CA %s", file)
+			}
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, errors.Errorf("no certificate found in root CA %s", file)
+			}
+		}
+	}
+	certFile := config.GetPath("peer.archiving.proxyTLS.clientCert.file")
+	keyFile := config.GetPath("peer.archiving.proxyTLS.clientKey.file")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,96 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	archivertest "github.com/hyperledger/fabric/core/archiver/testutil"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockfileHandlerAuthorization(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+	h, err := archivertest.NewHarness(archivertest.HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	info, err := h.WaitForArchived(store, 1, true, 10*time.Second)
+	require.NoError(t, err)
+	archived, err := h.Repository.ReadFile(info.Location)
+	require.NoError(t, err)
+
+	// The signers of the requests are readers of the channel unless they are rejected
+	var rejected bool
+	authorizer := NewChannelAuthorizer(func(env *common.Envelope, channelID string) error {
+		if rejected {
+			return errors.Errorf("not a reader of channel %s", channelID)
+		}
+		return nil
+	})
+	handler := &BlockfileHandler{Authorizer: authorizer, GetLedger: func(channelID string) ledger.PeerLedger {
+		if channelID != "testLedger" {
+			return nil
+		}
+		return &storeLedger{store: store}
+	}}
+	get := func(url, channelID, byteRange string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+		if channelID != "" {
+			require.NoError(t, SignHTTPRequest(req, channelID, fakeSigner{}))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	url := blockarchive.ProxyBlockfilesPath + "testLedger/1"
+
+	// The blockfiles and their byte ranges are served on the requests of the readers of the channel
+	rec := get(url, "testLedger", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, archived, rec.Body.Bytes())
+	rec = get(url, "testLedger", "bytes=10-19")
+	require.Equal(t, http.StatusPartialContent, rec.Code, rec.Body.String())
+	assert.Equal(t, archived[10:20], rec.Body.Bytes())
+	assert.Equal(t, http.StatusNotFound, get(blockarchive.ProxyBlockfilesPath+"otherLedger/0", "otherLedger", "").Code)
+
+	// The unsigned requests, including the byte ranges, are not served
+	assert.Equal(t, http.StatusUnauthorized, get(url, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(url, "", "bytes=10-19").Code)
+	// Neither are the requests signed for another channel, or by a requester who is not a reader
+	assert.Equal(t, http.StatusForbidden, get(url, "otherLedger", "").Code)
+	rejected = true
+	assert.Equal(t, http.StatusForbidden, get(url, "testLedger", "").Code)
+	assert.Equal(t, http.StatusForbidden, get(url, "testLedger", "bytes=10-19").Code)
+	rejected = false
+
+	// All the requests are denied without an authorizer
+	handler.Authorizer = nil
+	assert.Equal(t, http.StatusForbidden, get(url, "testLedger", "").Code)
+}
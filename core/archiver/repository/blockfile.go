@@ -0,0 +1,124 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	ledgerutil "github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+// blockfilePrefix is the prefix of the names of the blockfiles, archived as laid out on the local file
+// system of the peers in <archive dir>/chains/<channel>/blockfile_<number>. The repository reads them
+// itself rather than through the block storage of the peer, which uploads to the repository.
+const blockfilePrefix = "blockfile_"
+
+// listArchivedBlockfiles returns the numbers of the blockfiles of a channel in ascending order
+func listArchivedBlockfiles(archiveDir, channel string) ([]int, error) {
+	channelDir := filepath.Join(archiveDir, chainsDir, channel)
+	fileInfos, err := ioutil.ReadDir(channelDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading directory %s", channelDir)
+	}
+	var fileNums []int
+	for _, fileInfo := range fileInfos {
+		name := fileInfo.Name()
+		if fileInfo.IsDir() || !strings.HasPrefix(name, blockfilePrefix) {
+			continue
+		}
+		fileNum, err := strconv.Atoi(strings.TrimPrefix(name, blockfilePrefix))
+		if err != nil {
+			continue
+		}
+		fileNums = append(fileNums, fileNum)
+	}
+	sort.Ints(fileNums)
+	return fileNums, nil
+}
+
+// archivedBlockfilePath returns the path of a blockfile of a channel
+func archivedBlockfilePath(archiveDir, channel string, fileNum int) string {
+	return filepath.Join(archiveDir, chainsDir, channel, fmt.Sprintf("%s%06d", blockfilePrefix, fileNum))
+}
+
+// scanArchivedBlockfile parses the length-prefixed blocks of a blockfile and invokes handle with each block in order
+func scanArchivedBlockfile(filePath string, handle func(block *common.Block) error) error {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "error reading blockfile %s", filePath)
+	}
+	return scanArchivedBlocks(content, filePath, handle)
+}
+
+// scanArchivedBlocks parses the content of a blockfile, named filePath in the errors
+func scanArchivedBlocks(content []byte, filePath string, handle func(block *common.Block) error) error {
+	for offset := 0; offset < len(content); {
+		length, n := proto.DecodeVarint(content[offset:])
+		if n == 0 || uint64(len(content)-offset-n) < length {
+			return errors.Errorf("blockfile %s is truncated or corrupted at offset [%d]", filePath, offset)
+		}
+		block, err := decodeArchivedBlock(content[offset+n : offset+n+int(length)])
+		if err != nil {
+			return errors.WithMessagef(err, "error decoding block of blockfile %s at offset [%d]", filePath, offset)
+		}
+		if err := handle(block); err != nil {
+			return err
+		}
+		offset += n + int(length)
+	}
+	return nil
+}
+
+// decodeArchivedBlock decodes a block in the serialization of the block storage: the header fields,
+// then the transactions and the metadata entries, each list prefixed by its length
+func decodeArchivedBlock(b []byte) (*common.Block, error) {
+	buf := ledgerutil.NewBuffer(b)
+	header := &common.BlockHeader{}
+	var err error
+	if header.Number, err = buf.DecodeVarint(); err != nil {
+		return nil, err
+	}
+	if header.DataHash, err = buf.DecodeRawBytes(false); err != nil {
+		return nil, err
+	}
+	if header.PreviousHash, err = buf.DecodeRawBytes(false); err != nil {
+		return nil, err
+	}
+	if len(header.PreviousHash) == 0 {
+		header.PreviousHash = nil
+	}
+	block := &common.Block{Header: header, Data: &common.BlockData{}, Metadata: &common.BlockMetadata{}}
+	if block.Data.Data, err = decodeRawBytesList(buf); err != nil {
+		return nil, err
+	}
+	if block.Metadata.Metadata, err = decodeRawBytesList(buf); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func decodeRawBytesList(buf *ledgerutil.Buffer) ([][]byte, error) {
+	numItems, err := buf.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	var items [][]byte
+	for i := uint64(0); i < numItems; i++ {
+		item, err := buf.DecodeRawBytes(false)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
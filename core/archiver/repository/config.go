@@ -0,0 +1,160 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// QuotaActionReject rejects the uploads which would exceed a quota
+	QuotaActionReject = "reject"
+	// QuotaActionAlert accepts the uploads which exceed a quota but raises an alert
+	QuotaActionAlert = "alert"
+)
+
+// Config is the configuration of the repository server
+type Config struct {
+	// ListenAddress is the address the SFTP server listens on
+	ListenAddress string `yaml:"listenAddress"`
+	// RootDir is the directory under which the archived blockfiles are stored
+	RootDir string `yaml:"rootDir"`
+	// DataDir is the directory where the repository keeps its own metadata
+	DataDir string `yaml:"dataDir"`
+	// HostKeyFile is the PEM encoded private key of the SSH host.
+	// An ephemeral key is generated when it is empty.
+	HostKeyFile string `yaml:"hostKeyFile"`
+	// Users are the accounts allowed to connect to the repository
+	Users []User `yaml:"users"`
+	// TokenAuth enables the authentication with the API tokens issued by
+	// "blkarchiver-repo token", for the peers without an account
+	TokenAuth bool `yaml:"tokenAuth"`
+	// Quota is the storage quota configuration
+	Quota QuotaConfig `yaml:"quota"`
+	// Tiering holds the policies migrating the blockfiles to colder storage classes.
+	// The blockfiles stay in RootDir when no tier is configured.
+	Tiering TieringConfig `yaml:"tiering"`
+	// ObjectLock holds the retention of the blockfiles, which are locked against deletion and
+	// overwrite once uploaded
+	ObjectLock ObjectLockConfig `yaml:"objectLock"`
+	// Retention holds the retention policies of the organizations which upload or reference the blockfiles,
+	// which must all allow the deletion of a blockfile
+	Retention RetentionConfig `yaml:"retention"`
+	// Metadata is the store of the API tokens, the legal holds and the records of the blockfiles,
+	// which is shared by the instances of the repository running behind a load balancer
+	Metadata MetadataConfig `yaml:"metadata"`
+	// UsageListenAddress is the address of the usage reporting API, which is served over TLS
+	// and authenticates its clients with the accounts and API tokens of the SFTP server.
+	// The API is disabled when it is empty.
+	UsageListenAddress string `yaml:"usageListenAddress"`
+	// UsageTLS holds the server certificate of the usage reporting API
+	UsageTLS UsageTLSConfig `yaml:"usageTLS"`
+	// Webhooks are the HTTP endpoints notified of the uploads, deletions and integrity failures
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+	// Proxy makes the repository a read-only caching proxy of an upstream repository,
+	// whose objects are cached in RootDir
+	Proxy ProxyConfig `yaml:"proxy"`
+}
+
+// User is an account of the repository. All the uploads of the account are
+// accounted to its organization.
+type User struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
+	Org      string `yaml:"org"`
+}
+
+// QuotaConfig holds the storage quotas, in bytes, of the channels and organizations.
+// A channel or organization without an entry is not limited.
+type QuotaConfig struct {
+	// Action is taken when an upload exceeds a quota, either "reject" or "alert"
+	Action   string           `yaml:"action"`
+	Channels map[string]int64 `yaml:"channels"`
+	Orgs     map[string]int64 `yaml:"orgs"`
+}
+
+// LoadConfig reads the configuration of the repository server from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	configBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading configuration file %s", path)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(configBytes, config); err != nil {
+		return nil, errors.Wrapf(err, "error parsing configuration file %s", path)
+	}
+	return config, nil
+}
+
+// validate checks the configuration and fills in the defaults
+func (c *Config) validate() error {
+	if c.ListenAddress == "" {
+		c.ListenAddress = "0.0.0.0:222"
+	}
+	if c.RootDir == "" {
+		return errors.New("rootDir is not configured")
+	}
+	if c.DataDir == "" {
+		return errors.New("dataDir is not configured")
+	}
+	if len(c.Users) == 0 && !c.TokenAuth {
+		return errors.New("no user is configured and tokenAuth is disabled")
+	}
+	for _, user := range c.Users {
+		if user.Name == TokenUser {
+			return errors.Errorf("user name [%s] is reserved for the token authentication", TokenUser)
+		}
+	}
+	if err := c.Tiering.validate(); err != nil {
+		return err
+	}
+	if err := c.ObjectLock.validate(); err != nil {
+		return err
+	}
+	if err := c.Retention.validate(); err != nil {
+		return err
+	}
+	if err := c.Metadata.validate(); err != nil {
+		return err
+	}
+	if c.UsageListenAddress != "" && (c.UsageTLS.CertFile == "" || c.UsageTLS.KeyFile == "") {
+		return errors.New("the certificate and key of the usage reporting API must be configured")
+	}
+	for i := range c.Webhooks {
+		if err := c.Webhooks[i].validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Proxy.validate(); err != nil {
+		return err
+	}
+	if c.Proxy.enabled() {
+		// The proxy manages the content of RootDir as a cache of the upstream repository
+		if len(c.Quota.Channels) > 0 || len(c.Quota.Orgs) > 0 {
+			return errors.New("storage quotas cannot be enforced by a caching proxy")
+		}
+		if len(c.Tiering.Tiers) > 0 {
+			return errors.New("storage tiering cannot be enabled on a caching proxy")
+		}
+		if c.ObjectLock.Retention > 0 || len(c.ObjectLock.Channels) > 0 {
+			return errors.New("object lock cannot be enabled on a caching proxy")
+		}
+		if c.Retention.GCInterval > 0 {
+			return errors.New("garbage collection cannot be enabled on a caching proxy")
+		}
+	}
+	switch c.Quota.Action {
+	case "":
+		c.Quota.Action = QuotaActionReject
+	case QuotaActionReject, QuotaActionAlert:
+	default:
+		return errors.Errorf("invalid quota action [%s], must be either %s or %s",
+			c.Quota.Action, QuotaActionReject, QuotaActionAlert)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExportFormatTar is a tarball with an entry <channel>/<block number>.block per block,
+	// holding the protobuf encoding of the block
+	ExportFormatTar = "tar"
+	// ExportFormatNDJSON is newline-delimited JSON, one block per line in the protobuf JSON mapping
+	ExportFormatNDJSON = "ndjson"
+)
+
+// errExportDone stops the scan of the blockfiles once the end of the range has been exported
+var errExportDone = errors.New("export done")
+
+// ExportRequest selects the archived blocks to export
+type ExportRequest struct {
+	// Dir is the directory of the archived blockfiles below the root directory of the
+	// repository, i.e. ledger.blockArchiver.dir of the archiver peers
+	Dir string
+	// Channel is the channel whose blocks are exported
+	Channel string
+	// Format is either ExportFormatTar or ExportFormatNDJSON
+	Format string
+	// StartBlock and EndBlock are the range of the blocks to export, both inclusive
+	StartBlock uint64
+	EndBlock   uint64
+}
+
+// Export converts the archived blockfiles of a channel into a portable format for the analytics
+// systems and regulators, and writes the blocks of the range to w. It returns the number of blocks
+// exported. Only the blockfiles archived under their local paths are read, not the ones archived
+// under an objectKeyTemplate or by content.
+func Export(rootDir string, req *ExportRequest, w io.Writer) (int, error) {
+	if req.Channel == "" || strings.ContainsAny(req.Channel, `/\`) || req.Channel == ".." {
+		return 0, errors.Errorf("invalid channel [%s]", req.Channel)
+	}
+	if req.StartBlock > req.EndBlock {
+		return 0, errors.Errorf("invalid block range [%d-%d]", req.StartBlock, req.EndBlock)
+	}
+	var write func(*common.Block) error
+	var flush func() error
+	switch req.Format {
+	case ExportFormatTar:
+		tw := tar.NewWriter(w)
+		write = func(block *common.Block) error { return blockarchive.WriteTarBlock(tw, req.Channel, block) }
+		flush = tw.Close
+	case ExportFormatNDJSON:
+		bw := bufio.NewWriter(w)
+		marshaler := &jsonpb.Marshaler{OrigName: true}
+		write = func(block *common.Block) error {
+			if err := marshaler.Marshal(bw, block); err != nil {
+				return errors.Wrapf(err, "error marshaling block [%d]", block.Header.Number)
+			}
+			return bw.WriteByte('\n')
+		}
+		flush = bw.Flush
+	default:
+		return 0, errors.Errorf("invalid export format [%s], must be either %s or %s",
+			req.Format, ExportFormatTar, ExportFormatNDJSON)
+	}
+
+	archiveDir := filepath.Join(rootDir, filepath.Clean("/"+req.Dir))
+	fileNums, err := listArchivedBlockfiles(archiveDir, req.Channel)
+	if err != nil {
+		return 0, err
+	}
+	exported := 0
+	for _, fileNum := range fileNums {
+		err := scanArchivedBlockfile(archivedBlockfilePath(archiveDir, req.Channel, fileNum),
+			func(block *common.Block) error {
+				number := block.Header.Number
+				if number > req.EndBlock {
+					return errExportDone
+				}
+				if number < req.StartBlock {
+					return nil
+				}
+				exported++
+				return write(block)
+			})
+		if err == errExportDone {
+			break
+		}
+		if err != nil {
+			return exported, err
+		}
+	}
+	if err := flush(); err != nil {
+		return exported, err
+	}
+	return exported, nil
+}
+
+// exportHandler serves the export API:
+//
+//	GET /export/<channel>?dir=<dir>&format=tar|ndjson&start=<block>&end=<block>
+//
+// The whole channel is exported when the range is omitted.
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	req := &ExportRequest{
+		Dir:      query.Get("dir"),
+		Channel:  strings.TrimPrefix(r.URL.Path, "/export/"),
+		Format:   query.Get("format"),
+		EndBlock: math.MaxUint64,
+	}
+	if req.Format == "" {
+		req.Format = ExportFormatNDJSON
+	}
+	var err error
+	if start := query.Get("start"); start != "" {
+		if req.StartBlock, err = strconv.ParseUint(start, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid start block [%s]", start), http.StatusBadRequest)
+			return
+		}
+	}
+	if end := query.Get("end"); end != "" {
+		if req.EndBlock, err = strconv.ParseUint(end, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid end block [%s]", end), http.StatusBadRequest)
+			return
+		}
+	}
+	switch req.Format {
+	case ExportFormatTar:
+		w.Header().Set("Content-Type", "application/x-tar")
+	case ExportFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		http.Error(w, fmt.Sprintf("invalid export format [%s]", req.Format), http.StatusBadRequest)
+		return
+	}
+
+	// The errors can only be reported in the status before anything is written
+	ew := &exportWriter{w: w}
+	exported, err := Export(s.config.RootDir, req, ew)
+	if err != nil {
+		logger.Warningf("Export of channel [%s] failed after %d blocks: %s", req.Channel, exported, err)
+		switch {
+		case ew.written:
+		case os.IsNotExist(errors.Cause(err)):
+			http.NotFound(w, r)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	logger.Infof("Exported %d blocks of channel [%s] as %s", exported, req.Channel, req.Format)
+}
+
+type exportWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.written = true
+	return e.w.Write(p)
+}
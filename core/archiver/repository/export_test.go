@@ -0,0 +1,143 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeArchivedBlocks lays out the blockfiles of a channel under <rootDir>/blkstore as archived by a peer
+func writeArchivedBlocks(t *testing.T, rootDir, channel string, numBlocks int) []*common.Block {
+	conf := fsblkstorage.NewConf(filepath.Join(rootDir, "blkstore"), 0, "", "")
+	indexConfig := &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{blkstorage.IndexableAttrBlockNum}}
+	provider := fsblkstorage.NewProvider(conf, indexConfig)
+	defer provider.Close()
+	store, err := provider.OpenBlockStore(channel)
+	require.NoError(t, err)
+	defer store.Shutdown()
+
+	blocks := testutil.ConstructTestBlocks(t, numBlocks)
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	return blocks
+}
+
+func TestExportNDJSON(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	blocks := writeArchivedBlocks(t, testDir, "ch1", 5)
+
+	buf := &bytes.Buffer{}
+	req := &ExportRequest{Dir: "/blkstore", Channel: "ch1", Format: ExportFormatNDJSON, StartBlock: 1, EndBlock: 3}
+	exported, err := Export(testDir, req, buf)
+	require.NoError(t, err)
+	assert.Equal(t, 3, exported)
+
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(nil, 10*1024*1024)
+	var numbers []uint64
+	for scanner.Scan() {
+		block := &common.Block{}
+		require.NoError(t, jsonpb.UnmarshalString(scanner.Text(), block))
+		assert.True(t, proto.Equal(blocks[block.Header.Number], block))
+		numbers = append(numbers, block.Header.Number)
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, numbers)
+}
+
+func TestExportTar(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	blocks := writeArchivedBlocks(t, testDir, "ch1", 3)
+
+	buf := &bytes.Buffer{}
+	req := &ExportRequest{Dir: "blkstore", Channel: "ch1", Format: ExportFormatTar, EndBlock: 10}
+	exported, err := Export(testDir, req, buf)
+	require.NoError(t, err)
+	assert.Equal(t, 3, exported)
+
+	tr := tar.NewReader(buf)
+	for i := 0; ; i++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			assert.Equal(t, 3, i)
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join("ch1", []string{
+			"00000000000000000000.block", "00000000000000000001.block", "00000000000000000002.block"}[i]), header.Name)
+		b, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		block := &common.Block{}
+		require.NoError(t, proto.Unmarshal(b, block))
+		assert.True(t, proto.Equal(blocks[i], block))
+	}
+}
+
+func TestExportInvalidRequests(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	_, err = Export(testDir, &ExportRequest{Channel: "../ch1", Format: ExportFormatTar}, ioutil.Discard)
+	assert.EqualError(t, err, "invalid channel [../ch1]")
+	_, err = Export(testDir, &ExportRequest{Channel: "ch1", Format: "csv"}, ioutil.Discard)
+	assert.EqualError(t, err, "invalid export format [csv], must be either tar or ndjson")
+	_, err = Export(testDir, &ExportRequest{Channel: "ch1", Format: ExportFormatTar, StartBlock: 2, EndBlock: 1}, ioutil.Discard)
+	assert.EqualError(t, err, "invalid block range [2-1]")
+}
+
+func TestExportAPI(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	writeArchivedBlocks(t, server.config.RootDir, "ch1", 3)
+
+	api := httptest.NewServer(server.usageHandler())
+	defer api.Close()
+
+	resp, err := http.Get(api.URL + "/export/ch1?dir=/blkstore&start=1")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(t, 2, bytes.Count(body, []byte("\n")))
+
+	resp, err = http.Get(api.URL + "/export/unknown?dir=/blkstore")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(api.URL + "/export/ch1?format=csv")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,389 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+)
+
+// chainsDir is the name of the directory containing the blockfiles of all channels,
+// as laid out by the block store of the peers
+const chainsDir = "chains"
+
+// fileSystem serves the SFTP requests of a user session from the root directory
+// of the repository, accounting the uploads to the organization of the user.
+// The blockfiles migrated to other tiers are served from their tier.
+// The blockfiles under legal hold or object lock cannot be deleted or overwritten, nor can the blockfiles
+// referenced or retained by one of their owners be deleted.
+// A caching proxy serves the objects of its upstream repository read-only.
+type fileSystem struct {
+	rootDir string
+	org     string
+	quota   *quotaManager
+	tiers   *tierManager
+	// holds prevents the deletion of the blockfiles under legal hold
+	holds *HoldStore
+	// locks locks the blockfiles as they are uploaded and prevents the deletion of the locked ones
+	locks *ObjectLocker
+	// owners records the organizations which upload or reference the blockfiles and prevents the deletion
+	// of the blockfiles still referenced or retained by one of them
+	owners *OwnerTracker
+	// webhooks are notified of the uploads and deletions of the blockfiles and of the integrity failures
+	webhooks *webhookNotifier
+	// proxy serves the objects of the upstream repository when the repository is a caching proxy
+	proxy *cacheProxy
+}
+
+func (fs *fileSystem) handlers() sftp.Handlers {
+	return sftp.Handlers{FileGet: fs, FilePut: fs, FileCmd: fs, FileList: fs}
+}
+
+// localPath maps a path of the repository to the local file system, preventing
+// the client from escaping the root directory
+func (fs *fileSystem) localPath(p string) string {
+	return filepath.Join(fs.rootDir, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// Fileread opens a file for download
+func (fs *fileSystem) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	if fs.proxy != nil {
+		return fs.proxy.open(r.Filepath)
+	}
+	if fs.tiers != nil {
+		return fs.tiers.open(r.Filepath)
+	}
+	return os.Open(fs.localPath(r.Filepath))
+}
+
+// Filewrite opens a file for upload
+func (fs *fileSystem) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	if fs.proxy != nil {
+		return nil, fs.proxy.readOnly(r.Filepath)
+	}
+	if err := fs.checkObjectLock(r.Filepath); err != nil {
+		return nil, err
+	}
+	if err := fs.checkOwnersRecord(r.Filepath); err != nil {
+		return nil, err
+	}
+	pflags := r.Pflags()
+	flags := os.O_WRONLY
+	if pflags.Creat {
+		flags |= os.O_CREATE
+	}
+	if pflags.Excl {
+		flags |= os.O_EXCL
+	}
+	if pflags.Trunc {
+		flags |= os.O_TRUNC
+		if err := fs.checkLegalHold(r.Filepath); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(fs.localPath(r.Filepath), flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if pflags.Trunc {
+		if err := fs.quota.release(r.Filepath); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w := &quotaWriter{
+		file:    file,
+		path:    r.Filepath,
+		channel: channelOfPath(r.Filepath),
+		org:     fs.org,
+		size:    info.Size(),
+		quota:   fs.quota,
+	}
+	w.onClose = func() error {
+		if fs.tiers != nil && isTierable(path.Base(r.Filepath)) {
+			if err := fs.tiers.uploaded(r.Filepath); err != nil {
+				return err
+			}
+		}
+		if err := fs.lock(r.Filepath); err != nil {
+			return err
+		}
+		if err := fs.recordOwner(r.Filepath); err != nil {
+			return err
+		}
+		fs.notifyBlockfile(&Event{Kind: EventUpload, Path: r.Filepath, Size: w.size})
+		return nil
+	}
+	return w, nil
+}
+
+// Filecmd handles the commands modifying the file system
+func (fs *fileSystem) Filecmd(r *sftp.Request) error {
+	if fs.proxy != nil && r.Method != "Setstat" {
+		return fs.proxy.readOnly(r.Filepath)
+	}
+	switch r.Method {
+	case "Setstat":
+		return nil
+	case "Rename":
+		if err := fs.checkLegalHold(r.Target); err != nil {
+			return err
+		}
+		if err := fs.checkObjectLock(r.Filepath); err != nil {
+			return err
+		}
+		if err := fs.checkObjectLock(r.Target); err != nil {
+			return err
+		}
+		if err := fs.checkOwnersRecord(r.Filepath); err != nil {
+			return err
+		}
+		if err := fs.checkOwnersRecord(r.Target); err != nil {
+			return err
+		}
+		if err := fs.verifyChecksum(r.Filepath, r.Target); err != nil {
+			logger.Warningf("Rejected the upload of %s: %s", r.Target, err)
+			fs.notifyBlockfile(&Event{Kind: EventIntegrityFailure, Path: r.Target, Error: err.Error()})
+			return err
+		}
+		if err := os.Rename(fs.localPath(r.Filepath), fs.localPath(r.Target)); err != nil {
+			return err
+		}
+		if fs.tiers != nil && isTierable(path.Base(r.Target)) {
+			if err := fs.tiers.uploaded(r.Target); err != nil {
+				return err
+			}
+		}
+		if err := fs.quota.rename(r.Filepath, r.Target, channelOfPath(r.Target)); err != nil {
+			return err
+		}
+		if err := fs.lock(r.Target); err != nil {
+			return err
+		}
+		if err := fs.recordOwner(r.Target); err != nil {
+			return err
+		}
+		event := &Event{Kind: EventUpload, Path: r.Target}
+		if info, err := os.Stat(fs.localPath(r.Target)); err == nil {
+			event.Size = info.Size()
+		}
+		fs.notifyBlockfile(event)
+		return nil
+	case "Rmdir":
+		// The SFTP clients fall back to Rmdir when Remove fails, which must not remove a file
+		if info, err := os.Stat(fs.localPath(r.Filepath)); err == nil && !info.IsDir() {
+			return errors.Errorf("%s is not a directory", r.Filepath)
+		}
+		return os.Remove(fs.localPath(r.Filepath))
+	case "Mkdir":
+		return os.Mkdir(fs.localPath(r.Filepath), 0755)
+	case "Remove":
+		return fs.remove(r.Filepath)
+	}
+	return errors.Errorf("unsupported command: %s", r.Method)
+}
+
+// remove deletes the object at the path unless it is under legal hold or object lock, or is
+// a blockfile still referenced or retained by one of its owners
+func (fs *fileSystem) remove(p string) error {
+	if err := fs.checkLegalHold(p); err != nil {
+		return err
+	}
+	if err := fs.checkObjectLock(p); err != nil {
+		return err
+	}
+	if err := fs.checkOwners(p); err != nil {
+		return err
+	}
+	remove := os.Remove
+	if fs.tiers != nil {
+		remove = func(string) error { return fs.tiers.remove(p) }
+	}
+	if err := remove(fs.localPath(p)); err != nil {
+		return err
+	}
+	if err := fs.quota.release(p); err != nil {
+		return err
+	}
+	if err := fs.forgetOwner(p); err != nil {
+		return err
+	}
+	fs.notifyBlockfile(&Event{Kind: EventDelete, Path: p})
+	return nil
+}
+
+// checkObjectLock returns an error if the object at the path cannot be modified because of an object lock.
+// The lock records are written by the repository only.
+func (fs *fileSystem) checkObjectLock(p string) error {
+	if fs.locks == nil {
+		return nil
+	}
+	if strings.HasSuffix(p, blockarchive.ObjectLockSuffix) {
+		return errors.Errorf("the object locks are managed by the repository: %s", p)
+	}
+	stat := os.Stat
+	if fs.tiers != nil {
+		stat = func(string) (os.FileInfo, error) { return fs.tiers.stat(p) }
+	}
+	_, err := stat(fs.localPath(p))
+	if err := fs.locks.check(p, err == nil); err != nil {
+		logger.Warningf("Rejected the modification of %s: %s", p, err)
+		return err
+	}
+	return nil
+}
+
+// lock locks the blockfile which has just been uploaded at the path
+func (fs *fileSystem) lock(p string) error {
+	if fs.locks == nil {
+		return nil
+	}
+	return fs.locks.lock(p)
+}
+
+// verifyChecksum verifies an uploaded blockfile against the checksum stored next to its target path, if any,
+// before the blockfile is renamed to its target path. An encoded blockfile is verified once decoded.
+func (fs *fileSystem) verifyChecksum(uploadedPath, target string) error {
+	content, err := ioutil.ReadFile(fs.localPath(target) + blockarchive.ChecksumSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	expected, err := blockarchive.ParseChecksum(string(content))
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(fs.localPath(uploadedPath))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	verifier, err := blockarchive.NewChecksumWriter(expected.Algorithm)
+	if err != nil {
+		return err
+	}
+	// The checksum of an encoded blockfile is the one of the blockfile once decoded
+	decoder := blockarchive.NewDecodingWriter(verifier)
+	_, err = io.Copy(decoder, file)
+	if closeErr := decoder.Close(); err == nil {
+		err = closeErr
+	}
+	if blockarchive.IsEncryptionKeyMissing(err) {
+		// The blockfiles encrypted with the keys of the peers are verified by the peers once decrypted
+		logger.Debugf("Not verifying %s encrypted with a key of the peers: %s", target, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(expected)
+}
+
+// Filelist handles the commands listing the file system
+func (fs *fileSystem) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		readDir := ioutil.ReadDir
+		if fs.tiers != nil {
+			readDir = func(string) ([]os.FileInfo, error) { return fs.tiers.list(r.Filepath) }
+		}
+		if fs.proxy != nil {
+			readDir = func(string) ([]os.FileInfo, error) { return fs.proxy.list(r.Filepath) }
+		}
+		files, err := readDir(fs.localPath(r.Filepath))
+		if err != nil {
+			return nil, err
+		}
+		return listerAt(files), nil
+	case "Stat":
+		stat := os.Stat
+		if fs.tiers != nil {
+			stat = func(string) (os.FileInfo, error) { return fs.tiers.stat(r.Filepath) }
+		}
+		if fs.proxy != nil {
+			stat = func(string) (os.FileInfo, error) { return fs.proxy.stat(r.Filepath) }
+		}
+		info, err := stat(fs.localPath(r.Filepath))
+		if err != nil {
+			return nil, err
+		}
+		return listerAt([]os.FileInfo{info}), nil
+	}
+	return nil, errors.Errorf("unsupported command: %s", r.Method)
+}
+
+// quotaWriter writes an uploaded file after checking that it stays within the quotas
+type quotaWriter struct {
+	file    *os.File
+	path    string
+	channel string
+	org     string
+	size    int64
+	quota   *quotaManager
+	// onClose is called once the upload is complete
+	onClose func() error
+}
+
+func (w *quotaWriter) WriteAt(b []byte, off int64) (int, error) {
+	if end := off + int64(len(b)); end > w.size {
+		if err := w.quota.reserve(w.path, w.channel, w.org, end); err != nil {
+			return 0, err
+		}
+		w.size = end
+	}
+	return w.file.WriteAt(b, off)
+}
+
+func (w *quotaWriter) Close() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if w.onClose != nil {
+		if err := w.onClose(); err != nil {
+			return err
+		}
+	}
+	return w.quota.commit(w.path)
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// channelOfPath returns the channel of an object of the repository from its path,
+// which ends with chains/<channel>/<file>
+func channelOfPath(p string) string {
+	elements := strings.Split(path.Clean("/"+p), "/")
+	for i := len(elements) - 3; i >= 0; i-- {
+		if elements[i] == chainsDir {
+			return elements[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,388 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// holdsFileName is the name of the file in the data directory which holds the legal holds
+	holdsFileName = "holds.json"
+	// holdsAuditFileName is the name of the append-only audit log of the legal holds, one JSON entry per line
+	holdsAuditFileName = "holds-audit.log"
+
+	// HoldActionPlace and HoldActionRelease are the actions recorded in the audit log
+	HoldActionPlace   = "hold"
+	HoldActionRelease = "release"
+)
+
+// LegalHold blocks the deletion of the archived blockfiles holding blocks of a range of a channel,
+// e.g. by the garbage collection of the content-addressed blockfiles, until it is released
+type LegalHold struct {
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+	// StartBlock and EndBlock are the range of the blocks held, both inclusive
+	StartBlock uint64 `json:"startBlock"`
+	EndBlock   uint64 `json:"endBlock"`
+	// Reason is e.g. the reference of the litigation
+	Reason    string    `json:"reason"`
+	PlacedBy  string    `json:"placedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// overlaps tells if the hold covers some blocks of the range of a channel
+func (h *LegalHold) overlaps(channel string, first, last uint64) bool {
+	return h.Channel == channel && h.StartBlock <= last && first <= h.EndBlock
+}
+
+// HoldAuditEntry records an action on a legal hold
+type HoldAuditEntry struct {
+	Time   time.Time  `json:"time"`
+	Action string     `json:"action"`
+	Actor  string     `json:"actor"`
+	Hold   *LegalHold `json:"hold"`
+}
+
+// HoldStore holds the legal holds of the repository in a JSON file of the data directory, or in the shared
+// metadata store, so that they can be placed and released by the CLI while the server is running, and
+// records the actions in an audit log
+type HoldStore struct {
+	auditLog appendLog
+	// closer closes the connections to the shared metadata store, nil for a file
+	closer io.Closer
+
+	lock  sync.Mutex
+	file  document
+	holds []*LegalHold
+}
+
+// NewHoldStore opens the hold store of the data directory
+func NewHoldStore(dataDir string) *HoldStore {
+	return &HoldStore{
+		auditLog: &fileLog{path: filepath.Join(dataDir, holdsAuditFileName), what: "legal hold audit"},
+		file:     &jsonFile{path: filepath.Join(dataDir, holdsFileName), what: "legal hold"},
+	}
+}
+
+// Close releases the connections of the store to the shared metadata store
+func (s *HoldStore) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// Place places a legal hold on the blocks of a range of a channel
+func (s *HoldStore) Place(channel string, startBlock, endBlock uint64, reason, actor string) (*LegalHold, error) {
+	if channel == "" {
+		return nil, errors.New("the channel of the legal hold is empty")
+	}
+	if startBlock > endBlock {
+		return nil, errors.Errorf("invalid block range [%d-%d]", startBlock, endBlock)
+	}
+	if actor == "" {
+		return nil, errors.New("the actor placing the legal hold is empty")
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrap(err, "error generating the id of the legal hold")
+	}
+	hold := &LegalHold{
+		ID:         hex.EncodeToString(id),
+		Channel:    channel,
+		StartBlock: startBlock,
+		EndBlock:   endBlock,
+		Reason:     reason,
+		PlacedBy:   actor,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	holds, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	// The action is audited before it takes effect
+	if err := s.audit(HoldActionPlace, actor, hold); err != nil {
+		return nil, err
+	}
+	if err := s.file.save(append(holds, hold)); err != nil {
+		return nil, err
+	}
+	logger.Infof("Legal hold [%s] placed by [%s] on blocks [%d-%d] of channel [%s]", hold.ID, actor, startBlock, endBlock, channel)
+	return hold, nil
+}
+
+// Release releases a legal hold
+func (s *HoldStore) Release(id, actor string) error {
+	if actor == "" {
+		return errors.New("the actor releasing the legal hold is empty")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	holds, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := []*LegalHold{}
+	var released *LegalHold
+	for _, h := range holds {
+		if h.ID == id {
+			released = h
+			continue
+		}
+		kept = append(kept, h)
+	}
+	if released == nil {
+		return errors.Errorf("no legal hold [%s]", id)
+	}
+	if err := s.audit(HoldActionRelease, actor, released); err != nil {
+		return err
+	}
+	if err := s.file.save(kept); err != nil {
+		return err
+	}
+	logger.Infof("Legal hold [%s] on blocks [%d-%d] of channel [%s] released by [%s]",
+		id, released.StartBlock, released.EndBlock, released.Channel, actor)
+	return nil
+}
+
+// List returns the legal holds in effect, ordered by channel and range
+func (s *HoldStore) List() ([]*LegalHold, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	holds, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]*LegalHold{}, holds...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Channel != sorted[j].Channel {
+			return sorted[i].Channel < sorted[j].Channel
+		}
+		return sorted[i].StartBlock < sorted[j].StartBlock
+	})
+	return sorted, nil
+}
+
+// Audit returns the entries of the audit log of the legal holds, oldest first
+func (s *HoldStore) Audit() ([]*HoldAuditEntry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	lines, err := s.auditLog.entries()
+	if err != nil {
+		return nil, err
+	}
+	var entries []*HoldAuditEntry
+	for i, line := range lines {
+		entry := &HoldAuditEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, errors.Wrapf(err, "error parsing entry %d of legal hold audit log", i+1)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// holding returns a legal hold covering some blocks of the range of a channel, or nil. If the range
+// is unknown, i.e. first > last, any legal hold on the channel is returned, and any legal hold at all
+// if the channel is unknown too.
+func (s *HoldStore) holding(channel string, first, last uint64) (*LegalHold, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	holds, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range holds {
+		switch {
+		case first > last && (channel == "" || h.Channel == channel):
+			return h, nil
+		case h.overlaps(channel, first, last):
+			return h, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *HoldStore) load() ([]*LegalHold, error) {
+	var holds []*LegalHold
+	loaded, err := s.file.load(&holds)
+	if err != nil {
+		return nil, err
+	}
+	if loaded {
+		s.holds = holds
+	}
+	return s.holds, nil
+}
+
+// audit appends an entry to the audit log, synced before the action is applied
+func (s *HoldStore) audit(action, actor string, hold *LegalHold) error {
+	b, err := json.Marshal(&HoldAuditEntry{Time: time.Now().UTC(), Action: action, Actor: actor, Hold: hold})
+	if err != nil {
+		return errors.Wrap(err, "error marshaling legal hold audit entry")
+	}
+	return s.auditLog.append(b)
+}
+
+// checkLegalHold returns an error if the object of the repository at p must not be deleted or overwritten
+// because it is, or belongs to, a blockfile holding blocks under legal hold. The checksum, the manifest and
+// the references of a blockfile are held along with it.
+func (fs *fileSystem) checkLegalHold(p string) error {
+	if fs.holds == nil {
+		return nil
+	}
+	blockfilePath := path.Clean("/" + p)
+	if strings.HasSuffix(blockfilePath, uploadingSuffix) {
+		return nil
+	}
+	if i := strings.Index(blockfilePath, blockarchive.RefsSuffix+"/"); i >= 0 {
+		blockfilePath = blockfilePath[:i]
+	}
+	blockfilePath = strings.TrimSuffix(blockfilePath, blockarchive.RefsSuffix)
+	blockfilePath = strings.TrimSuffix(blockfilePath, blockarchive.ManifestSuffix)
+	blockfilePath = strings.TrimSuffix(blockfilePath, blockarchive.SummarySuffix)
+	blockfilePath = strings.TrimSuffix(blockfilePath, blockarchive.ChecksumSuffix)
+
+	channel, first, last, err := fs.blockRangeOf(blockfilePath)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil
+	}
+	if err != nil {
+		// The blocks of the blockfile are unknown, it is held if it may belong to a channel under legal hold
+		logger.Warningf("Failed to read the block range of %s for the legal holds: %s", blockfilePath, err)
+		first, last = 1, 0
+	}
+	hold, err := fs.holds.holding(channel, first, last)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		logger.Warningf("Refused to delete or overwrite %s, under legal hold [%s] of channel [%s]", p, hold.ID, hold.Channel)
+		return errors.Errorf("%s is under legal hold [%s]", p, hold.ID)
+	}
+	return nil
+}
+
+// blockRangeOf returns the channel and the range of the blocks of an archived blockfile. The channel is
+// taken from the path of the blockfile, or from its first block when it is content-addressed.
+func (fs *fileSystem) blockRangeOf(p string) (string, uint64, uint64, error) {
+	var content []byte
+	var err error
+	if fs.tiers != nil {
+		content, err = fs.tiers.readAll(p)
+	} else {
+		content, err = ioutil.ReadFile(fs.localPath(p))
+	}
+	if err != nil {
+		return channelOfPath(p), 1, 0, err
+	}
+	channel := channelOfPath(p)
+	first, last := uint64(math.MaxUint64), uint64(0)
+	err = scanArchivedBlocks(content, p, func(block *common.Block) error {
+		if channel == "" {
+			channel = channelOfBlock(block)
+		}
+		if block.Header.Number < first {
+			first = block.Header.Number
+		}
+		if block.Header.Number > last {
+			last = block.Header.Number
+		}
+		return nil
+	})
+	if err != nil {
+		return channel, 1, 0, err
+	}
+	return channel, first, last, nil
+}
+
+// channelOfBlock returns the channel of a block from the header of its first transaction
+func channelOfBlock(block *common.Block) string {
+	env, err := protoutil.ExtractEnvelope(block, 0)
+	if err != nil {
+		return ""
+	}
+	chdr, err := protoutil.ChannelHeader(env)
+	if err != nil {
+		return ""
+	}
+	return chdr.ChannelId
+}
+
+// holdsHandler serves the administration API of the legal holds:
+//
+//	GET    /holds              - legal holds in effect
+//	POST   /holds              - place a legal hold, {"channel", "startBlock", "endBlock", "reason", "actor"}
+//	DELETE /holds/<id>?actor=  - release a legal hold
+//	GET    /holds/audit        - audit log of the legal holds
+func (s *Server) holdsHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/holds"), "/")
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		holds, err := s.holds.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, holds)
+	case r.Method == http.MethodGet && id == "audit":
+		entries, err := s.holds.Audit()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, entries)
+	case r.Method == http.MethodPost && id == "":
+		req := &struct {
+			Channel    string `json:"channel"`
+			StartBlock uint64 `json:"startBlock"`
+			EndBlock   uint64 `json:"endBlock"`
+			Reason     string `json:"reason"`
+			Actor      string `json:"actor"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, "invalid legal hold request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		hold, err := s.holds.Place(req.Channel, req.StartBlock, req.EndBlock, req.Reason, req.Actor)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, hold)
+	case r.Method == http.MethodDelete && id != "":
+		if err := s.holds.Release(id, r.URL.Query().Get("actor")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
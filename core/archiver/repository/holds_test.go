@@ -0,0 +1,160 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldStore(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataDir)
+	store := NewHoldStore(dataDir)
+
+	_, err = store.Place("ch1", 5, 2, "case", "alice")
+	assert.EqualError(t, err, "invalid block range [5-2]")
+	_, err = store.Place("ch1", 0, 2, "case", "")
+	assert.EqualError(t, err, "the actor placing the legal hold is empty")
+
+	hold1, err := store.Place("ch1", 10, 20, "case 1", "alice")
+	require.NoError(t, err)
+	hold2, err := store.Place("ch1", 0, 5, "case 2", "bob")
+	require.NoError(t, err)
+
+	// The holds placed by the CLI are seen by another store, e.g. the one of the server
+	holds, err := NewHoldStore(dataDir).List()
+	require.NoError(t, err)
+	require.Len(t, holds, 2)
+	assert.Equal(t, hold2.ID, holds[0].ID)
+	assert.Equal(t, hold1.ID, holds[1].ID)
+
+	hold, err := store.holding("ch1", 15, 30)
+	require.NoError(t, err)
+	assert.Equal(t, hold1.ID, hold.ID)
+	hold, err = store.holding("ch1", 6, 9)
+	require.NoError(t, err)
+	assert.Nil(t, hold)
+	hold, err = store.holding("ch2", 0, 30)
+	require.NoError(t, err)
+	assert.Nil(t, hold)
+	// Unknown range
+	hold, err = store.holding("ch1", 1, 0)
+	require.NoError(t, err)
+	assert.NotNil(t, hold)
+
+	assert.EqualError(t, store.Release("unknown", "alice"), "no legal hold [unknown]")
+	require.NoError(t, store.Release(hold1.ID, "carol"))
+	holds, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, holds, 1)
+	assert.Equal(t, hold2.ID, holds[0].ID)
+
+	entries, err := store.Audit()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, HoldActionPlace, entries[0].Action)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, hold1.ID, entries[0].Hold.ID)
+	assert.Equal(t, HoldActionRelease, entries[2].Action)
+	assert.Equal(t, "carol", entries[2].Actor)
+	assert.Equal(t, "case 1", entries[2].Hold.Reason)
+}
+
+func TestLegalHoldBlocksDeletion(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	writeArchivedBlocks(t, server.config.RootDir, "ch1", 5)
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".checksum", []byte("sha256:00")))
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000001", []byte("garbage")))
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch2/blockfile_000000", []byte("garbage")))
+
+	hold, err := server.holds.Place("ch1", 3, 8, "case", "alice")
+	require.NoError(t, err)
+	sshConn, client := openSFTP(t, server)
+	defer sshConn.Close()
+	defer client.Close()
+
+	// Neither the blockfile holding blocks under legal hold nor its checksum can be deleted or overwritten
+	assert.Error(t, client.Remove(path))
+	assert.Error(t, client.Remove(path+".checksum"))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", []byte("other")))
+	assert.Error(t, client.Rename(path+".uploading", path))
+	// The blocks of an unreadable blockfile of a channel under legal hold are presumed held
+	assert.Error(t, client.Remove("/blkstore/chains/ch1/blockfile_000001"))
+	// The channels without legal hold are not affected
+	require.NoError(t, client.Remove("/blkstore/chains/ch2/blockfile_000000"))
+	_, err = os.Stat(filepath.Join(server.config.RootDir, path))
+	require.NoError(t, err)
+
+	require.NoError(t, server.holds.Release(hold.ID, "alice"))
+	require.NoError(t, client.Remove(path+".checksum"))
+	require.NoError(t, client.Remove(path))
+	assertNotExist(t, filepath.Join(server.config.RootDir, path))
+}
+
+func TestHoldsAPI(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	api := httptest.NewServer(server.usageHandler())
+	defer api.Close()
+
+	resp, err := http.Post(api.URL+"/holds", "application/json",
+		bytes.NewBufferString(`{"channel":"ch1","startBlock":1,"endBlock":9,"reason":"case","actor":"alice"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	hold := &LegalHold{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(hold))
+	resp.Body.Close()
+	assert.Equal(t, "ch1", hold.Channel)
+	assert.Equal(t, "alice", hold.PlacedBy)
+
+	resp, err = http.Post(api.URL+"/holds", "application/json", bytes.NewBufferString(`{"channel":"ch1"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(api.URL + "/holds")
+	require.NoError(t, err)
+	var holds []*LegalHold
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&holds))
+	resp.Body.Close()
+	require.Len(t, holds, 1)
+	assert.Equal(t, hold.ID, holds[0].ID)
+
+	req, err := http.NewRequest(http.MethodDelete, api.URL+"/holds/"+hold.ID+"?actor=bob", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.Get(api.URL + "/holds/audit")
+	require.NoError(t, err)
+	var entries []*HoldAuditEntry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	resp.Body.Close()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "bob", entries[1].Actor)
+	assert.Equal(t, HoldActionRelease, entries[1].Action)
+}
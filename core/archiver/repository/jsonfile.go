@@ -0,0 +1,74 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// jsonFile is a JSON file of the data directory, shared by the server and the CLI. It is replaced
+// atomically on every change and is read again only when it has been modified.
+type jsonFile struct {
+	path string
+	// what is the description of the content of the file in the errors
+	what string
+
+	modTime time.Time
+	size    int64
+	cached  bool
+}
+
+// load decodes the file into v unless it hasn't been modified since the last load, in which case it
+// returns false and v is left unchanged. A missing file is loaded as nothing.
+func (f *jsonFile) load(v interface{}) (bool, error) {
+	stat, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		f.modTime, f.size, f.cached = time.Time{}, 0, false
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading %s file %s", f.what, f.path)
+	}
+	if f.cached && stat.ModTime().Equal(f.modTime) && stat.Size() == f.size {
+		return false, nil
+	}
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading %s file %s", f.what, f.path)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, errors.Wrapf(err, "error parsing %s file %s", f.what, f.path)
+	}
+	f.modTime, f.size, f.cached = stat.ModTime(), stat.Size(), true
+	return true, nil
+}
+
+// save replaces the file atomically with the encoding of v
+func (f *jsonFile) save(v interface{}) error {
+	// Force the next load to read the file again, the cached content may have been modified
+	f.cached = false
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", f.what)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return errors.Wrapf(err, "error creating directory of %s file %s", f.what, f.path)
+	}
+	tmpPath := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, b, 0600); err != nil {
+		return errors.Wrapf(err, "error writing %s file %s", f.what, tmpPath)
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrapf(err, "error replacing %s file %s", f.what, f.path)
+	}
+	return nil
+}
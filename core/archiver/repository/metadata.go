@@ -0,0 +1,418 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+)
+
+const (
+	// MetadataStoreFile keeps the metadata in the data directory of the server
+	MetadataStoreFile = "file"
+	// MetadataStoreEtcd keeps the metadata in an etcd cluster shared by the instances of the repository
+	MetadataStoreEtcd = "etcd"
+
+	defaultMetadataPrefix  = "/blkarchiver-repo/"
+	defaultMetadataTimeout = 5 * time.Second
+)
+
+// MetadataConfig is the store of the metadata shared by the server and the CLI, i.e. the API tokens and
+// the legal holds. An etcd store allows several instances of the repository to run behind a load balancer
+// on the same storage, the changes made through any of them being seen by all the others. The usage and
+// the tier records of the blockfiles are kept in the etcd store too, so that the quotas are enforced and
+// the tiers are tracked across the instances.
+type MetadataConfig struct {
+	// Type is either "file" or "etcd"
+	Type string `yaml:"type"`
+	// Endpoints are the client URLs of the etcd cluster, e.g. https://etcd0:2379
+	Endpoints []string `yaml:"endpoints"`
+	// Prefix is prepended to the keys of the repository in etcd
+	Prefix string `yaml:"prefix"`
+	// Timeout is the timeout of the connection and of the requests to etcd
+	Timeout time.Duration `yaml:"timeout"`
+	// Username and Password authenticate the repository when the authentication of etcd is enabled
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// TLS secures the connections to etcd
+	TLS MetadataTLSConfig `yaml:"tls"`
+}
+
+// MetadataTLSConfig is the TLS configuration of the connections to etcd
+type MetadataTLSConfig struct {
+	// Enabled connects to etcd with TLS, which the https endpoints require
+	Enabled bool `yaml:"enabled"`
+	// RootCertFile is the CA certificate the server certificates of etcd are verified with,
+	// the CAs of the system if empty
+	RootCertFile string `yaml:"rootCertFile"`
+	// CertFile and KeyFile are the client certificate and key of the repository,
+	// when etcd authenticates its clients by certificate
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// validate checks the metadata store configuration and fills in the defaults
+func (c *MetadataConfig) validate() error {
+	switch c.Type {
+	case "":
+		c.Type = MetadataStoreFile
+	case MetadataStoreFile:
+	case MetadataStoreEtcd:
+		if len(c.Endpoints) == 0 {
+			return errors.New("no endpoint is configured for the etcd metadata store")
+		}
+		if (c.Username == "") != (c.Password == "") {
+			return errors.New("both the username and the password of the etcd metadata store must be configured")
+		}
+		if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+			return errors.New("both the client certificate and key of the etcd metadata store must be configured")
+		}
+		for _, endpoint := range c.Endpoints {
+			if !c.TLS.Enabled && strings.HasPrefix(endpoint, "https://") {
+				return errors.Errorf("TLS must be enabled to connect to etcd endpoint %s", endpoint)
+			}
+			// The etcd client connects to the http endpoints in plaintext whatever its TLS configuration
+			if c.TLS.Enabled && strings.HasPrefix(endpoint, "http://") {
+				return errors.Errorf("etcd endpoint %s must be an https URL since TLS is enabled", endpoint)
+			}
+		}
+	default:
+		return errors.Errorf("invalid metadata store type [%s], must be either %s or %s",
+			c.Type, MetadataStoreFile, MetadataStoreEtcd)
+	}
+	if c.Prefix == "" {
+		c.Prefix = defaultMetadataPrefix
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultMetadataTimeout
+	}
+	return nil
+}
+
+// shared tells if the metadata is shared with other instances of the repository
+func (c *MetadataConfig) shared() bool {
+	return c.Type == MetadataStoreEtcd
+}
+
+// clientConfig returns the TLS configuration of the etcd client, nil if TLS is disabled
+func (c *MetadataTLSConfig) clientConfig() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.RootCertFile != "" {
+		pem, err := ioutil.ReadFile(c.RootCertFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading etcd root certificate %s", c.RootCertFile)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in etcd root certificate %s", c.RootCertFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading etcd client certificate %s", c.CertFile)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// document is a JSON document of the metadata, read again only when it has been modified
+type document interface {
+	// load decodes the document into v unless it hasn't been modified since the last load, in which
+	// case it returns false and v is left unchanged. A missing document is loaded as nothing.
+	load(v interface{}) (bool, error)
+	// save replaces the document with the encoding of v
+	save(v interface{}) error
+}
+
+// appendLog is an append-only log of JSON entries
+type appendLog interface {
+	append(entry []byte) error
+	// entries returns the entries of the log, oldest first
+	entries() ([][]byte, error)
+}
+
+// OpenTokenStore opens the token store of the metadata store of the configuration
+func OpenTokenStore(config *Config) (*TokenStore, error) {
+	if err := config.Metadata.validate(); err != nil {
+		return nil, err
+	}
+	if !config.Metadata.shared() {
+		if config.DataDir == "" {
+			return nil, errors.New("dataDir is not configured")
+		}
+		return NewTokenStore(config.DataDir), nil
+	}
+	store, err := newEtcdStore(config.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	tokens := store.tokenStore()
+	tokens.closer = store
+	return tokens, nil
+}
+
+// OpenHoldStore opens the hold store of the metadata store of the configuration
+func OpenHoldStore(config *Config) (*HoldStore, error) {
+	if err := config.Metadata.validate(); err != nil {
+		return nil, err
+	}
+	if !config.Metadata.shared() {
+		if config.DataDir == "" {
+			return nil, errors.New("dataDir is not configured")
+		}
+		return NewHoldStore(config.DataDir), nil
+	}
+	store, err := newEtcdStore(config.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	holds := store.holdStore()
+	holds.closer = store
+	return holds, nil
+}
+
+// fileLog is an append-only log file of the data directory, one JSON entry per line
+type fileLog struct {
+	path string
+	// what is the description of the content of the log in the errors
+	what string
+}
+
+func (l *fileLog) append(entry []byte) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return errors.Wrapf(err, "error creating directory of %s log %s", l.what, l.path)
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s log %s", l.what, l.path)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(entry, '\n')); err != nil {
+		return errors.Wrapf(err, "error writing %s log %s", l.what, l.path)
+	}
+	return file.Sync()
+}
+
+func (l *fileLog) entries() ([][]byte, error) {
+	content, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s log %s", l.what, l.path)
+	}
+	var entries [][]byte
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line != "" {
+			entries = append(entries, []byte(line))
+		}
+	}
+	return entries, nil
+}
+
+// etcdStore keeps the metadata in etcd, through the client of its v3 API.
+// The documents are replaced with a compare-and-swap on their revision, so that the
+// concurrent changes made through different instances of the repository are not lost.
+type etcdStore struct {
+	client  *clientv3.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func newEtcdStore(config MetadataConfig) (*etcdStore, error) {
+	tlsConfig, err := config.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   config.Endpoints,
+		DialTimeout: config.Timeout,
+		TLS:         tlsConfig,
+		Username:    config.Username,
+		Password:    config.Password,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error connecting to etcd endpoints %s", strings.Join(config.Endpoints, ", "))
+	}
+	return &etcdStore{client: client, prefix: config.Prefix, timeout: config.Timeout}, nil
+}
+
+// Close closes the connections to etcd
+func (s *etcdStore) Close() error {
+	return s.client.Close()
+}
+
+// document returns the JSON document stored at the key
+func (s *etcdStore) document(key, what string) document {
+	return &etcdDocument{store: s, key: s.prefix + key, what: what}
+}
+
+// log returns the append-only log whose entries are stored under the key
+func (s *etcdStore) log(key, what string) appendLog {
+	return &etcdLog{store: s, prefix: s.prefix + key + "/", what: what}
+}
+
+// records returns the records of the objects stored under the key
+func (s *etcdStore) records(key, what string) recordStore {
+	return &etcdRecords{store: s, prefix: s.prefix + key, what: what}
+}
+
+// tokenStore returns the token store kept in etcd
+func (s *etcdStore) tokenStore() *TokenStore {
+	return &TokenStore{file: s.document(tokensFileName, "token")}
+}
+
+// holdStore returns the hold store kept in etcd
+func (s *etcdStore) holdStore() *HoldStore {
+	return &HoldStore{
+		file:     s.document(holdsFileName, "legal hold"),
+		auditLog: s.log(holdsAuditFileName, "legal hold audit"),
+	}
+}
+
+// context returns the context of a request to etcd, bounded by the configured timeout
+func (s *etcdStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+// get returns the value and the revision of a key, zero if the key doesn't exist
+func (s *etcdStore) get(key string) ([]byte, int64, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+	return resp.Kvs[0].Value, resp.Kvs[0].ModRevision, nil
+}
+
+// list returns the values of the keys with the prefix, in ascending order of the keys
+func (s *etcdStore) list(prefix string) ([][]byte, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		values[i] = kv.Value
+	}
+	return values, nil
+}
+
+// swap sets the value of a key if its revision is still the one given, zero for a key which doesn't exist.
+// It returns false if the key has been modified in the meantime.
+func (s *etcdStore) swap(key string, value []byte, revision int64) (bool, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// etcdDocument is a JSON document stored at a key of etcd
+type etcdDocument struct {
+	store *etcdStore
+	key   string
+	what  string
+
+	revision int64
+	cached   bool
+}
+
+func (d *etcdDocument) load(v interface{}) (bool, error) {
+	value, revision, err := d.store.get(d.key)
+	if err != nil {
+		return false, errors.WithMessage(err, fmt.Sprintf("error reading %s document %s", d.what, d.key))
+	}
+	if revision == 0 {
+		d.revision, d.cached = 0, false
+		return true, nil
+	}
+	if d.cached && revision == d.revision {
+		return false, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return false, errors.Wrapf(err, "error parsing %s document %s", d.what, d.key)
+	}
+	d.revision, d.cached = revision, true
+	return true, nil
+}
+
+// save replaces the document unless it has been modified by another instance since it was loaded
+func (d *etcdDocument) save(v interface{}) error {
+	d.cached = false
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", d.what)
+	}
+	swapped, err := d.store.swap(d.key, b, d.revision)
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("error writing %s document %s", d.what, d.key))
+	}
+	if !swapped {
+		return errors.Errorf("%s document %s has been modified concurrently, try again", d.what, d.key)
+	}
+	return nil
+}
+
+// etcdLog is an append-only log with an entry per key of etcd, under a common prefix
+type etcdLog struct {
+	store  *etcdStore
+	prefix string
+	what   string
+}
+
+func (l *etcdLog) append(entry []byte) error {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return errors.Wrapf(err, "error generating the key of %s entry", l.what)
+	}
+	// The keys sort by time, the random suffix telling apart the entries appended at once
+	key := fmt.Sprintf("%s%020d-%s", l.prefix, time.Now().UnixNano(), hex.EncodeToString(id))
+	swapped, err := l.store.swap(key, entry, 0)
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("error writing %s log %s", l.what, l.prefix))
+	}
+	if !swapped {
+		return errors.Errorf("%s entry %s already exists", l.what, key)
+	}
+	return nil
+}
+
+func (l *etcdLog) entries() ([][]byte, error) {
+	entries, err := l.store.list(l.prefix)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("error reading %s log %s", l.what, l.prefix))
+	}
+	return entries, nil
+}
@@ -0,0 +1,502 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/crypto/tlsgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	pb "go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeEtcd serves the KV and the authentication APIs of etcd from memory
+type fakeEtcd struct {
+	// AuthServer is nil, only the authentication is implemented
+	pb.AuthServer
+
+	// username and password are required to authenticate when they are set
+	username string
+	password string
+
+	lock     sync.Mutex
+	revision int64
+	kvs      map[string]*mvccpb.KeyValue
+}
+
+const fakeEtcdToken = "fake-token"
+
+// startFakeEtcd serves a fake etcd, over TLS if tlsConfig is not nil, and returns its endpoint
+func startFakeEtcd(t *testing.T, tlsConfig *tls.Config, username, password string) (*fakeEtcd, string, func()) {
+	etcd := &fakeEtcd{username: username, password: password, kvs: make(map[string]*mvccpb.KeyValue)}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	var opts []grpc.ServerOption
+	scheme := "http://"
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		scheme = "https://"
+	}
+	server := grpc.NewServer(opts...)
+	pb.RegisterKVServer(server, etcd)
+	pb.RegisterAuthServer(server, etcd)
+	go server.Serve(listener)
+	return etcd, scheme + listener.Addr().String(), server.Stop
+}
+
+func (e *fakeEtcd) Authenticate(ctx context.Context, request *pb.AuthenticateRequest) (*pb.AuthenticateResponse, error) {
+	if e.username == "" {
+		return nil, rpctypes.ErrGRPCAuthNotEnabled
+	}
+	if request.Name != e.username || request.Password != e.password {
+		return nil, rpctypes.ErrGRPCAuthFailed
+	}
+	return &pb.AuthenticateResponse{Header: e.header(), Token: fakeEtcdToken}, nil
+}
+
+// authorize checks the token of the request when the authentication is enabled
+func (e *fakeEtcd) authorize(ctx context.Context) error {
+	if e.username == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if tokens := md.Get(rpctypes.TokenFieldNameGRPC); len(tokens) != 1 || tokens[0] != fakeEtcdToken {
+		return rpctypes.ErrGRPCUserEmpty
+	}
+	return nil
+}
+
+func (e *fakeEtcd) header() *pb.ResponseHeader {
+	return &pb.ResponseHeader{Revision: e.revision}
+}
+
+func (e *fakeEtcd) Range(ctx context.Context, request *pb.RangeRequest) (*pb.RangeResponse, error) {
+	if err := e.authorize(ctx); err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.rangeKeys(request), nil
+}
+
+func (e *fakeEtcd) Put(ctx context.Context, request *pb.PutRequest) (*pb.PutResponse, error) {
+	if err := e.authorize(ctx); err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.put(request), nil
+}
+
+func (e *fakeEtcd) DeleteRange(ctx context.Context, request *pb.DeleteRangeRequest) (*pb.DeleteRangeResponse, error) {
+	if err := e.authorize(ctx); err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.deleteRange(request), nil
+}
+
+// Txn supports the comparisons of the revision of a key
+func (e *fakeEtcd) Txn(ctx context.Context, request *pb.TxnRequest) (*pb.TxnResponse, error) {
+	if err := e.authorize(ctx); err != nil {
+		return nil, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	succeeded := true
+	for _, compare := range request.Compare {
+		var revision int64
+		if kv, ok := e.kvs[string(compare.Key)]; ok {
+			revision = kv.ModRevision
+		}
+		if compare.Target != pb.Compare_MOD || compare.Result != pb.Compare_EQUAL || revision != compare.GetModRevision() {
+			succeeded = false
+		}
+	}
+	ops := request.Success
+	if !succeeded {
+		ops = request.Failure
+	}
+	response := &pb.TxnResponse{Succeeded: succeeded}
+	for _, op := range ops {
+		switch {
+		case op.GetRequestRange() != nil:
+			response.Responses = append(response.Responses, &pb.ResponseOp{
+				Response: &pb.ResponseOp_ResponseRange{ResponseRange: e.rangeKeys(op.GetRequestRange())}})
+		case op.GetRequestPut() != nil:
+			response.Responses = append(response.Responses, &pb.ResponseOp{
+				Response: &pb.ResponseOp_ResponsePut{ResponsePut: e.put(op.GetRequestPut())}})
+		case op.GetRequestDeleteRange() != nil:
+			response.Responses = append(response.Responses, &pb.ResponseOp{
+				Response: &pb.ResponseOp_ResponseDeleteRange{ResponseDeleteRange: e.deleteRange(op.GetRequestDeleteRange())}})
+		}
+	}
+	response.Header = e.header()
+	return response, nil
+}
+
+func (e *fakeEtcd) Compact(ctx context.Context, request *pb.CompactionRequest) (*pb.CompactionResponse, error) {
+	return &pb.CompactionResponse{Header: e.header()}, nil
+}
+
+// match returns the keys of a range, in ascending order
+func (e *fakeEtcd) match(key, rangeEnd []byte) []string {
+	var keys []string
+	for k := range e.kvs {
+		if k == string(key) || (len(rangeEnd) > 0 && k >= string(key) && k < string(rangeEnd)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (e *fakeEtcd) rangeKeys(request *pb.RangeRequest) *pb.RangeResponse {
+	keys := e.match(request.Key, request.RangeEnd)
+	// Like etcd, the count is that of the range before the filtering of the revisions
+	response := &pb.RangeResponse{Header: e.header(), Count: int64(len(keys))}
+	if request.CountOnly {
+		return response
+	}
+	for _, k := range keys {
+		kv := *e.kvs[k]
+		if kv.ModRevision < request.MinModRevision {
+			continue
+		}
+		if request.KeysOnly {
+			kv.Value = nil
+		}
+		response.Kvs = append(response.Kvs, &kv)
+	}
+	if request.Limit > 0 && int64(len(response.Kvs)) > request.Limit {
+		response.Kvs = response.Kvs[:request.Limit]
+		response.More = true
+	}
+	return response
+}
+
+func (e *fakeEtcd) put(request *pb.PutRequest) *pb.PutResponse {
+	e.revision++
+	kv, ok := e.kvs[string(request.Key)]
+	if !ok {
+		kv = &mvccpb.KeyValue{Key: request.Key, CreateRevision: e.revision}
+		e.kvs[string(request.Key)] = kv
+	}
+	kv.Value = request.Value
+	kv.ModRevision = e.revision
+	kv.Version++
+	return &pb.PutResponse{Header: e.header()}
+}
+
+func (e *fakeEtcd) deleteRange(request *pb.DeleteRangeRequest) *pb.DeleteRangeResponse {
+	keys := e.match(request.Key, request.RangeEnd)
+	if len(keys) > 0 {
+		e.revision++
+	}
+	for _, k := range keys {
+		delete(e.kvs, k)
+	}
+	return &pb.DeleteRangeResponse{Header: e.header(), Deleted: int64(len(keys))}
+}
+
+func TestMetadataConfig(t *testing.T) {
+	config := &MetadataConfig{}
+	require.NoError(t, config.validate())
+	assert.Equal(t, MetadataStoreFile, config.Type)
+	assert.False(t, config.shared())
+
+	for _, tc := range []struct {
+		config        MetadataConfig
+		expectedError string
+	}{
+		{MetadataConfig{Type: MetadataStoreEtcd}, "no endpoint is configured for the etcd metadata store"},
+		{MetadataConfig{Type: "postgres"}, "invalid metadata store type [postgres], must be either file or etcd"},
+		{
+			MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{"http://etcd0:2379"}, Username: "repo"},
+			"both the username and the password of the etcd metadata store must be configured",
+		},
+		{
+			MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{"https://etcd0:2379"},
+				TLS: MetadataTLSConfig{Enabled: true, CertFile: "client.pem"}},
+			"both the client certificate and key of the etcd metadata store must be configured",
+		},
+		{
+			MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{"https://etcd0:2379"}},
+			"TLS must be enabled to connect to etcd endpoint https://etcd0:2379",
+		},
+		{
+			MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{"http://etcd0:2379"}, TLS: MetadataTLSConfig{Enabled: true}},
+			"etcd endpoint http://etcd0:2379 must be an https URL since TLS is enabled",
+		},
+	} {
+		assert.EqualError(t, tc.config.validate(), tc.expectedError)
+	}
+
+	// The quotas and the tiering are enforced across the instances sharing the store
+	repoConfig := &Config{
+		RootDir:   "/tmp/root",
+		DataDir:   "/tmp/data",
+		TokenAuth: true,
+		Metadata:  MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{"http://localhost:2379"}},
+		Quota:     QuotaConfig{Channels: map[string]int64{"mychannel": 1024}},
+		Tiering:   TieringConfig{Tiers: []TierConfig{{Name: "warm", Dir: "/tmp/warm", IdleAfter: time.Hour}}},
+	}
+	require.NoError(t, repoConfig.validate())
+	assert.Equal(t, defaultMetadataPrefix, repoConfig.Metadata.Prefix)
+	assert.True(t, repoConfig.Metadata.shared())
+}
+
+func TestEtcdMetadataStore(t *testing.T) {
+	_, endpoint, stop := startFakeEtcd(t, nil, "", "")
+	defer stop()
+	config := &Config{Metadata: MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{"http://127.0.0.1:1", endpoint}}}
+
+	// Two instances of the repository see the holds placed through each other
+	holds1, err := OpenHoldStore(config)
+	require.NoError(t, err)
+	defer holds1.Close()
+	holds2, err := OpenHoldStore(config)
+	require.NoError(t, err)
+	defer holds2.Close()
+	hold, err := holds1.Place("mychannel", 10, 20, "case 42", "alice")
+	require.NoError(t, err)
+	held, err := holds2.holding("mychannel", 15, 15)
+	require.NoError(t, err)
+	require.NotNil(t, held)
+	assert.Equal(t, hold.ID, held.ID)
+	require.NoError(t, holds2.Release(hold.ID, "bob"))
+	list, err := holds1.List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	entries, err := holds1.Audit()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, HoldActionPlace, entries[0].Action)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, HoldActionRelease, entries[1].Action)
+	assert.Equal(t, "bob", entries[1].Actor)
+
+	// A token issued through an instance authenticates on the other
+	tokens1, err := OpenTokenStore(config)
+	require.NoError(t, err)
+	defer tokens1.Close()
+	tokens2, err := OpenTokenStore(config)
+	require.NoError(t, err)
+	defer tokens2.Close()
+	token, err := tokens1.Issue("peer0.org1", "Org1MSP", 0)
+	require.NoError(t, err)
+	info, err := tokens2.Authenticate(token)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "Org1MSP", info.Org)
+}
+
+func TestEtcdDocumentConflict(t *testing.T) {
+	_, endpoint, stop := startFakeEtcd(t, nil, "", "")
+	defer stop()
+	store, err := newEtcdStore(MetadataConfig{Endpoints: []string{endpoint}, Prefix: "/test/", Timeout: defaultMetadataTimeout})
+	require.NoError(t, err)
+	defer store.Close()
+
+	doc1 := store.document("doc", "test")
+	doc2 := store.document("doc", "test")
+	var v []string
+	loaded, err := doc1.load(&v)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	_, err = doc2.load(&v)
+	require.NoError(t, err)
+
+	require.NoError(t, doc1.save([]string{"first"}))
+	// The change made on the basis of a stale revision is rejected rather than lost
+	assert.EqualError(t, doc2.save([]string{"second"}), "test document /test/doc has been modified concurrently, try again")
+
+	loaded, err = doc2.load(&v)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, []string{"first"}, v)
+	loaded, err = doc2.load(&v)
+	require.NoError(t, err)
+	assert.False(t, loaded)
+	require.NoError(t, doc2.save([]string{"second"}))
+}
+
+func TestEtcdMetadataStoreTLSAndAuth(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ca, err := tlsgen.NewCA()
+	require.NoError(t, err)
+	serverCert, err := ca.NewServerCertKeyPair("127.0.0.1")
+	require.NoError(t, err)
+	clientCert, err := ca.NewClientCertKeyPair()
+	require.NoError(t, err)
+	serverKeyPair, err := tls.X509KeyPair(serverCert.Cert, serverCert.Key)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(ca.CertBytes())
+	// etcd requires a client certificate signed by its CA
+	_, endpoint, stop := startFakeEtcd(t, &tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, "repo", "secret")
+	defer stop()
+
+	writeFile := func(name string, content []byte) string {
+		path := filepath.Join(testDir, name)
+		require.NoError(t, ioutil.WriteFile(path, content, 0600))
+		return path
+	}
+	metadataConfig := MetadataConfig{
+		Type:      MetadataStoreEtcd,
+		Endpoints: []string{endpoint},
+		Timeout:   time.Second,
+		Username:  "repo",
+		Password:  "secret",
+		TLS: MetadataTLSConfig{
+			Enabled:      true,
+			RootCertFile: writeFile("ca.pem", ca.CertBytes()),
+			CertFile:     writeFile("client.pem", clientCert.Cert),
+			KeyFile:      writeFile("client.key", clientCert.Key),
+		},
+	}
+	holds, err := OpenHoldStore(&Config{Metadata: metadataConfig})
+	require.NoError(t, err)
+	defer holds.Close()
+	hold, err := holds.Place("mychannel", 10, 20, "case 42", "alice")
+	require.NoError(t, err)
+	list, err := holds.List()
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, hold.ID, list[0].ID)
+
+	wrongPassword := metadataConfig
+	wrongPassword.Password = "wrong"
+	_, err = OpenHoldStore(&Config{Metadata: wrongPassword})
+	assert.Error(t, err)
+
+	noClientCert := metadataConfig
+	noClientCert.TLS.CertFile, noClientCert.TLS.KeyFile = "", ""
+	_, err = OpenHoldStore(&Config{Metadata: noClientCert})
+	assert.Error(t, err)
+
+	missingCA := metadataConfig
+	missingCA.TLS.RootCertFile = filepath.Join(testDir, "missing.pem")
+	_, err = OpenHoldStore(&Config{Metadata: missingCA})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading etcd root certificate")
+}
+
+func TestEtcdRecords(t *testing.T) {
+	_, endpoint, stop := startFakeEtcd(t, nil, "", "")
+	defer stop()
+	store, err := newEtcdStore(MetadataConfig{Endpoints: []string{endpoint}, Prefix: "/test/", Timeout: defaultMetadataTimeout})
+	require.NoError(t, err)
+	defer store.Close()
+
+	records1 := store.records("usage", "usage")
+	records2 := store.records("usage", "usage")
+	all, err := records1.all()
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	require.NoError(t, records1.put("/ch1/a", []byte("1")))
+	require.NoError(t, records1.put("/ch1/b", []byte("2")))
+	value, err := records2.get("/ch1/a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+	value, err = records2.get("/ch1/c")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// The records are modified through records1 after records2 loads them
+	all, err = records2.all()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"/ch1/a": []byte("1"), "/ch1/b": []byte("2")}, all)
+	modified, err := records2.modified()
+	require.NoError(t, err)
+	assert.False(t, modified)
+
+	require.NoError(t, records1.put("/ch1/b", []byte("3")))
+	modified, err = records2.modified()
+	require.NoError(t, err)
+	assert.True(t, modified)
+	_, err = records2.all()
+	require.NoError(t, err)
+
+	require.NoError(t, records1.delete("/ch1/a"))
+	modified, err = records2.modified()
+	require.NoError(t, err)
+	assert.True(t, modified)
+
+	require.NoError(t, records1.move("/ch1/b", "/ch2/b", []byte("4")))
+	all, err = records2.all()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"/ch2/b": []byte("4")}, all)
+
+	// The records of other kinds are kept apart
+	all, err = store.records("tiering", "tier").all()
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestSharedQuotas(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	_, endpoint, stop := startFakeEtcd(t, nil, "", "")
+	defer stop()
+
+	// Two instances of the repository on the same storage, each with its own data directory
+	newServer := func(dataDir string) *Server {
+		config := &Config{
+			ListenAddress: "127.0.0.1:0",
+			RootDir:       filepath.Join(testDir, "root"),
+			DataDir:       filepath.Join(testDir, dataDir),
+			Users:         []User{{Name: "org1", Password: "pw1", Org: "Org1MSP"}},
+			Quota:         QuotaConfig{Channels: map[string]int64{"ch1": 100}},
+			Metadata:      MetadataConfig{Type: MetadataStoreEtcd, Endpoints: []string{endpoint}},
+		}
+		require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+		server, err := NewServer(config)
+		require.NoError(t, err)
+		require.NoError(t, server.Start())
+		return server
+	}
+	server1 := newServer("data1")
+	defer server1.Stop()
+	server2 := newServer("data2")
+	defer server2.Stop()
+
+	require.NoError(t, upload(t, server1, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000000", make([]byte, 60)))
+	assert.Equal(t, &Usage{Used: 60, Quota: 100}, server2.Usage().Channels["ch1"])
+
+	// The usage recorded through the other instance counts against the quota
+	err = upload(t, server2, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000001", make([]byte, 60))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage quota of channel [ch1] exceeded")
+	require.NoError(t, upload(t, server2, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000001", make([]byte, 40)))
+	assert.Equal(t, &Usage{Used: 100, Quota: 100}, server1.Usage().Channels["ch1"])
+}
@@ -0,0 +1,224 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// ObjectLockConfig holds the retention periods of the archived blockfiles, which are locked against
+// deletion and overwrite once uploaded, like the objects of an S3 bucket with Object Lock.
+// No blockfile is locked when neither Retention nor a channel retention is set.
+type ObjectLockConfig struct {
+	// Mode is either "governance", whose locks the administrator can remove with
+	// "blkarchiver-repo lock remove", or "compliance", whose locks nobody can remove
+	Mode string `yaml:"mode"`
+	// Retention is how long the blockfiles are locked from their upload
+	Retention time.Duration `yaml:"retention"`
+	// Channels override Retention for the blockfiles of some channels
+	Channels map[string]time.Duration `yaml:"channels"`
+}
+
+// validate checks the object lock configuration and fills in the defaults
+func (c *ObjectLockConfig) validate() error {
+	switch c.Mode {
+	case "":
+		c.Mode = blockarchive.ObjectLockCompliance
+	case blockarchive.ObjectLockGovernance, blockarchive.ObjectLockCompliance:
+	default:
+		return errors.Errorf("invalid object lock mode [%s], must be either %s or %s",
+			c.Mode, blockarchive.ObjectLockGovernance, blockarchive.ObjectLockCompliance)
+	}
+	if c.Retention < 0 {
+		return errors.Errorf("invalid object lock retention %s", c.Retention)
+	}
+	for channel, retention := range c.Channels {
+		if retention < 0 {
+			return errors.Errorf("invalid object lock retention %s of channel [%s]", retention, channel)
+		}
+	}
+	return nil
+}
+
+// retention returns the retention period of the blockfiles of a channel
+func (c *ObjectLockConfig) retention(channel string) time.Duration {
+	if retention, ok := c.Channels[channel]; ok {
+		return retention
+	}
+	return c.Retention
+}
+
+// ObjectLocker locks the archived blockfiles of the repository as they are uploaded, recording each
+// lock next to its blockfile so that the peers can verify it before discarding their local copy.
+// The locks are enforced whatever the configuration, so that disabling it doesn't release them.
+type ObjectLocker struct {
+	rootDir string
+	config  ObjectLockConfig
+}
+
+// NewObjectLocker creates the locker of the blockfiles stored under the root directory
+func NewObjectLocker(rootDir string, config ObjectLockConfig) *ObjectLocker {
+	return &ObjectLocker{rootDir: rootDir, config: config}
+}
+
+// localPath maps a path of the repository to the local file system
+func (l *ObjectLocker) localPath(p string) string {
+	return filepath.Join(l.rootDir, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// Get returns the lock of the blockfile at the path, or of the blockfile of a checksum, manifest,
+// summary or lock record, nil if it is not locked
+func (l *ObjectLocker) Get(p string) (*blockarchive.ObjectLock, error) {
+	b, err := ioutil.ReadFile(l.localPath(lockedBlockfilePath(p) + blockarchive.ObjectLockSuffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the object lock of %s", p)
+	}
+	return blockarchive.ParseObjectLock(b)
+}
+
+// Remove removes the lock of a blockfile, which must be in governance mode
+func (l *ObjectLocker) Remove(p string) error {
+	lock, err := l.Get(p)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return errors.Errorf("%s is not locked", p)
+	}
+	if lock.Mode != blockarchive.ObjectLockGovernance && lock.IsActive(time.Now()) {
+		return errors.Errorf("%s is locked in %s mode until %s", p, lock.Mode, lock.RetainUntil.Format(time.RFC3339))
+	}
+	return os.Remove(l.localPath(lockedBlockfilePath(p) + blockarchive.ObjectLockSuffix))
+}
+
+// lock locks the blockfile which has just been uploaded at the path for the retention of its channel.
+// An existing lock is never shortened. The lock records the size of the blockfile once decoded, so that the
+// peers check it against their local blockfile.
+func (l *ObjectLocker) lock(p string) error {
+	if !isLockable(p) {
+		return nil
+	}
+	retention := l.config.retention(channelOfPath(p))
+	if retention == 0 {
+		return nil
+	}
+	size, err := blockfileSize(l.localPath(p))
+	if err != nil {
+		return errors.Wrapf(err, "error locking %s", p)
+	}
+	now := time.Now().UTC()
+	lock := &blockarchive.ObjectLock{
+		Mode:        l.config.Mode,
+		RetainUntil: now.Add(retention),
+		LockedAt:    now,
+		Size:        size,
+	}
+	existing, err := l.Get(p)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.IsActive(now) {
+		if existing.RetainUntil.After(lock.RetainUntil) {
+			lock.RetainUntil = existing.RetainUntil
+		}
+		if existing.Mode == blockarchive.ObjectLockCompliance {
+			lock.Mode = existing.Mode
+		}
+	}
+	b, err := json.Marshal(lock)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the object lock")
+	}
+	lockPath := l.localPath(p) + blockarchive.ObjectLockSuffix
+	if err := ioutil.WriteFile(lockPath+".tmp", b, 0444); err != nil {
+		return errors.Wrapf(err, "error locking %s", p)
+	}
+	if err := os.Rename(lockPath+".tmp", lockPath); err != nil {
+		os.Remove(lockPath + ".tmp")
+		return errors.Wrapf(err, "error locking %s", p)
+	}
+	logger.Infof("Locked %s in %s mode until %s", p, lock.Mode, lock.RetainUntil.Format(time.RFC3339))
+	return nil
+}
+
+// check returns an error if the existing object at the path is a locked blockfile, or the checksum,
+// manifest, summary or lock record of a locked blockfile. The manifest and the summary of a locked
+// blockfile can still be written once, as they are published after the blockfile.
+func (l *ObjectLocker) check(p string, exists bool) error {
+	if !exists {
+		return nil
+	}
+	lock, err := l.Get(p)
+	if err != nil {
+		// An unreadable lock is presumed active
+		return err
+	}
+	if lock != nil && lock.IsActive(time.Now()) {
+		return errors.Errorf("%s is locked until %s", lockedBlockfilePath(p), lock.RetainUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// lockedBlockfilePath returns the path of the blockfile whose lock protects the object at the path
+func lockedBlockfilePath(p string) string {
+	p = path.Clean("/" + p)
+	for _, suffix := range []string{blockarchive.ObjectLockSuffix, blockarchive.ChecksumSuffix, blockarchive.ManifestSuffix, blockarchive.SummarySuffix} {
+		if strings.HasSuffix(p, suffix) {
+			return strings.TrimSuffix(p, suffix)
+		}
+	}
+	return p
+}
+
+// isLockable tells if an object is an archived blockfile rather than a file attached to one,
+// e.g. a checksum or the references of a content-addressed blockfile, a snapshot of a blockfile
+// which is replaced as the blockfile grows, or a temporary file
+func isLockable(p string) bool {
+	if strings.Contains(p, blockarchive.RefsSuffix+"/") {
+		return false
+	}
+	for _, suffix := range []string{blockarchive.ObjectLockSuffix, blockarchive.ChecksumSuffix, blockarchive.ManifestSuffix,
+		blockarchive.SummarySuffix, blockarchive.RefsSuffix, blockarchive.OwnersSuffix, blockarchive.TailSuffix, uploadingSuffix,
+		".tiering", ".tmp"} {
+		if strings.HasSuffix(p, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// blockfileSize returns the size of the blockfile stored at localPath, the one recorded in its header if it is encoded
+func blockfileSize(localPath string) (int64, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	header, err := blockarchive.ReadObjectHeader(bufio.NewReader(file))
+	if err != nil {
+		return 0, err
+	}
+	if header != nil {
+		return header.Size, nil
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
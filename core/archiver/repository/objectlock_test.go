@@ -0,0 +1,134 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectLock(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	server.locks.config = ObjectLockConfig{
+		Mode:      blockarchive.ObjectLockCompliance,
+		Retention: time.Hour,
+		Channels:  map[string]time.Duration{"ch2": 0},
+	}
+
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", []byte("blocks")))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ChecksumSuffix, []byte("sha256:00")))
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch2/blockfile_000000", []byte("blocks")))
+	sshConn, client := openSFTP(t, server)
+	defer sshConn.Close()
+	defer client.Close()
+	// The checksum of the test is not verified
+	require.NoError(t, os.Remove(filepath.Join(server.config.RootDir, path+blockarchive.ChecksumSuffix)))
+	require.NoError(t, client.Rename(path+".uploading", path))
+
+	// The blockfile is locked once uploaded, and the peers can read the lock
+	f, err := client.Open(path + blockarchive.ObjectLockSuffix)
+	require.NoError(t, err)
+	lockBytes, err := ioutil.ReadAll(f)
+	f.Close()
+	require.NoError(t, err)
+	lock, err := blockarchive.ParseObjectLock(lockBytes)
+	require.NoError(t, err)
+	assert.Equal(t, blockarchive.ObjectLockCompliance, lock.Mode)
+	require.NoError(t, lock.Verify(6, 50*time.Minute, time.Now()))
+
+	// Neither the blockfile nor its lock can be deleted or overwritten
+	assert.Error(t, client.Remove(path))
+	assert.Error(t, client.Remove(path+blockarchive.ObjectLockSuffix))
+	_, err = client.Create(path)
+	assert.Error(t, err)
+	_, err = client.Create("/blkstore/chains/ch1/blockfile_000001" + blockarchive.ObjectLockSuffix)
+	assert.Error(t, err)
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", []byte("other")))
+	assert.Error(t, client.Rename(path+".uploading", path))
+	// The manifest of a locked blockfile is written once
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ManifestSuffix, []byte("manifest")))
+	assert.Error(t, client.Remove(path+blockarchive.ManifestSuffix))
+	_, err = client.Create(path + blockarchive.ManifestSuffix)
+	assert.Error(t, err)
+
+	// The channels without retention are not locked
+	_, err = client.Stat("/blkstore/chains/ch2/blockfile_000000" + blockarchive.ObjectLockSuffix)
+	assert.Error(t, err)
+	require.NoError(t, client.Remove("/blkstore/chains/ch2/blockfile_000000"))
+
+	// A compliance lock cannot be removed, even by the administrator
+	assert.Contains(t, server.locks.Remove(path).Error(), "is locked in compliance mode until")
+
+	// Once the lock has expired, the blockfile can be deleted
+	lock.RetainUntil = time.Now().Add(-time.Minute)
+	lock.Mode = blockarchive.ObjectLockGovernance
+	writeObjectLock(t, server, path, lock)
+	require.NoError(t, client.Remove(path))
+	assertNotExist(t, filepath.Join(server.config.RootDir, path))
+
+	// The administrator can remove a governance lock before it expires
+	lock.RetainUntil = time.Now().Add(time.Hour)
+	writeObjectLock(t, server, path, lock)
+	require.NoError(t, server.locks.Remove(path+blockarchive.ManifestSuffix))
+	require.NoError(t, client.Remove(path+blockarchive.ManifestSuffix))
+	assert.EqualError(t, server.locks.Remove(path), "/blkstore/chains/ch1/blockfile_000000 is not locked")
+}
+
+func TestObjectLockOfEncodedBlockfile(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	server.locks.config = ObjectLockConfig{Mode: blockarchive.ObjectLockGovernance, Retention: time.Hour}
+
+	// The lock of a compressed blockfile records the size of the blockfile once decoded
+	object := &bytes.Buffer{}
+	require.NoError(t, blockarchive.WriteObjectHeader(object, &blockarchive.ObjectHeader{Compression: blockarchive.CompressionGzip, Size: 4096}))
+	object.WriteString("compressed blocks")
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", object.Bytes()))
+	sshConn, client := openSFTP(t, server)
+	defer sshConn.Close()
+	defer client.Close()
+	require.NoError(t, client.Rename(path+".uploading", path))
+	lock, err := server.locks.Get(path)
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.Equal(t, int64(4096), lock.Size)
+}
+
+func writeObjectLock(t *testing.T, server *Server, path string, lock *blockarchive.ObjectLock) {
+	lockPath := filepath.Join(server.config.RootDir, path+blockarchive.ObjectLockSuffix)
+	os.Remove(lockPath)
+	b := []byte(`{"mode":"` + lock.Mode + `","retainUntil":"` + lock.RetainUntil.Format(time.RFC3339Nano) + `"}`)
+	require.NoError(t, ioutil.WriteFile(lockPath, b, 0644))
+}
+
+func TestObjectLockConfigValidation(t *testing.T) {
+	config := &ObjectLockConfig{}
+	require.NoError(t, config.validate())
+	assert.Equal(t, blockarchive.ObjectLockCompliance, config.Mode)
+	assert.Equal(t, time.Duration(0), config.retention("ch1"))
+
+	config = &ObjectLockConfig{Mode: "strict"}
+	assert.EqualError(t, config.validate(), "invalid object lock mode [strict], must be either governance or compliance")
+	config = &ObjectLockConfig{Retention: time.Hour, Channels: map[string]time.Duration{"ch1": -time.Hour}}
+	assert.EqualError(t, config.validate(), "invalid object lock retention -1h0m0s of channel [ch1]")
+	assert.Equal(t, time.Hour, config.retention("ch2"))
+}
@@ -0,0 +1,320 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// RetentionConfig holds the retention policies of the organizations sharing the repository. A blockfile
+// uploaded or referenced by several organizations, e.g. a content-addressed blockfile of a channel archived
+// by the peers of each of them, is deleted only once it is no longer referenced and the policies of all of
+// them allow it.
+type RetentionConfig struct {
+	// Orgs is how long the blockfiles uploaded or referenced by each organization, by MSP ID, are kept
+	// from its first upload or reference. The blockfiles of an organization without an entry are not retained.
+	Orgs map[string]time.Duration `yaml:"orgs"`
+	// GCInterval is the period of the garbage collection of the content-addressed blockfiles which are
+	// no longer referenced. The garbage collection is disabled when it is 0.
+	GCInterval time.Duration `yaml:"gcInterval"`
+}
+
+// validate checks the retention policies
+func (c *RetentionConfig) validate() error {
+	for org, retention := range c.Orgs {
+		if retention < 0 {
+			return errors.Errorf("invalid retention %s of organization [%s]", retention, org)
+		}
+	}
+	if c.GCInterval < 0 {
+		return errors.Errorf("invalid garbage collection interval %s", c.GCInterval)
+	}
+	return nil
+}
+
+// BlockfileOwner is an organization which has uploaded or referenced an archived blockfile
+type BlockfileOwner struct {
+	Org string `json:"org"`
+	// Since is the first upload or reference of the blockfile by the organization
+	Since time.Time `json:"since"`
+	// Refs are the references of the peers of the organization to the content-addressed blockfile
+	Refs []string `json:"refs,omitempty"`
+}
+
+// OwnerTracker records next to each archived blockfile the organizations which have referenced it, or uploaded
+// it under a retention policy, as authenticated by the repository, and prevents the deletion of the blockfile
+// until it is no longer referenced and the retention policies of all of them have elapsed
+type OwnerTracker struct {
+	rootDir string
+	config  RetentionConfig
+	lock    sync.Mutex
+}
+
+// NewOwnerTracker creates the tracker of the owners of the blockfiles stored under the root directory
+func NewOwnerTracker(rootDir string, config RetentionConfig) *OwnerTracker {
+	return &OwnerTracker{rootDir: rootDir, config: config}
+}
+
+// record returns the file of the record of the owners of the blockfile at the path
+func (o *OwnerTracker) record(p string) *jsonFile {
+	return &jsonFile{
+		path: filepath.Join(o.rootDir, filepath.FromSlash(path.Clean("/"+p))) + blockarchive.OwnersSuffix,
+		what: "blockfile owners",
+	}
+}
+
+// Get returns the owners of the blockfile at the path, ordered by organization, none if not recorded
+func (o *OwnerTracker) Get(p string) ([]*BlockfileOwner, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.load(p)
+}
+
+func (o *OwnerTracker) load(p string) ([]*BlockfileOwner, error) {
+	var owners []*BlockfileOwner
+	if _, err := o.record(p).load(&owners); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// added records an upload of the blockfile at the path by the organization, or a reference to it if ref
+// is not empty
+func (o *OwnerTracker) added(p, org, ref string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	owners, err := o.load(p)
+	if err != nil {
+		return err
+	}
+	var owner *BlockfileOwner
+	for _, existing := range owners {
+		if existing.Org == org {
+			owner = existing
+		}
+	}
+	if owner == nil {
+		owner = &BlockfileOwner{Org: org, Since: time.Now().UTC()}
+		owners = append(owners, owner)
+		sort.Slice(owners, func(i, j int) bool { return owners[i].Org < owners[j].Org })
+	}
+	if ref != "" && !containsString(owner.Refs, ref) {
+		owner.Refs = append(owner.Refs, ref)
+	}
+	return o.record(p).save(owners)
+}
+
+// released records the removal of a reference to the blockfile at the path. The organization remains
+// an owner of the blockfile for its retention.
+func (o *OwnerTracker) released(p, ref string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	owners, err := o.load(p)
+	if err != nil || owners == nil {
+		return err
+	}
+	for _, owner := range owners {
+		kept := owner.Refs[:0]
+		for _, r := range owner.Refs {
+			if r != ref {
+				kept = append(kept, r)
+			}
+		}
+		owner.Refs = kept
+	}
+	return o.record(p).save(owners)
+}
+
+// check returns an error if the blockfile at the path is still referenced by an organization, or retained
+// by the policy of an organization which has uploaded or referenced it
+func (o *OwnerTracker) check(p string, now time.Time) error {
+	owners, err := o.Get(p)
+	if err != nil {
+		// Unreadable owners are presumed to retain the blockfile
+		return err
+	}
+	for _, owner := range owners {
+		if len(owner.Refs) > 0 {
+			return errors.Errorf("%s is still referenced by organization [%s]", p, owner.Org)
+		}
+		if until := owner.Since.Add(o.config.Orgs[owner.Org]); now.Before(until) {
+			return errors.Errorf("%s is retained by organization [%s] until %s", p, owner.Org, until.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// removed forgets the owners of the blockfile at the path once it has been deleted
+func (o *OwnerTracker) removed(p string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if err := os.Remove(o.record(p).path); err != nil && !os.IsNotExist(err) {
+		logger.Warningf("Failed removing the owners of %s: %s", p, err)
+	}
+}
+
+// collectable lists the content-addressed blockfiles whose owners are recorded, which are no longer
+// referenced and no longer retained by any of their owners
+func (o *OwnerTracker) collectable(now time.Time) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(o.rootDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(localPath, blockarchive.OwnersSuffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(o.rootDir, strings.TrimSuffix(localPath, blockarchive.OwnersSuffix))
+		if err != nil {
+			return err
+		}
+		p := "/" + filepath.ToSlash(rel)
+		if !strings.Contains(p, "/"+blockarchive.ObjectsDir+"/") {
+			return nil
+		}
+		if err := o.check(p, now); err != nil {
+			logger.Debugf("Kept %s: %s", p, err)
+			return nil
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the owners of the blockfiles")
+	}
+	return paths, nil
+}
+
+// refOf returns the path of the content-addressed blockfile and the name of the reference if the object at
+// the path is a reference to a blockfile, rather than e.g. the manifest of the archiving peer
+func refOf(p string) (string, string, bool) {
+	p = path.Clean("/" + p)
+	i := strings.Index(p, blockarchive.RefsSuffix+"/")
+	if i < 0 {
+		return "", "", false
+	}
+	name := p[i+len(blockarchive.RefsSuffix)+1:]
+	if strings.Contains(name, "/") || strings.HasSuffix(name, blockarchive.ManifestSuffix) ||
+		strings.HasSuffix(name, blockarchive.SummarySuffix) || strings.HasSuffix(name, uploadingSuffix) {
+		return "", "", false
+	}
+	return p[:i], name, true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// recordOwner records the organization of the session as an owner of the content-addressed blockfile
+// referenced by the reference uploaded at the path, or of the blockfile uploaded at the path if the
+// organization has a retention policy
+func (fs *fileSystem) recordOwner(p string) error {
+	if fs.owners == nil {
+		return nil
+	}
+	if blockfilePath, ref, ok := refOf(p); ok {
+		return fs.owners.added(blockfilePath, fs.org, ref)
+	}
+	if isLockable(p) && fs.owners.config.Orgs[fs.org] > 0 {
+		return fs.owners.added(path.Clean("/"+p), fs.org, "")
+	}
+	return nil
+}
+
+// checkOwners returns an error if the object at the path cannot be deleted because of its owners.
+// The records of the owners are written by the repository only.
+func (fs *fileSystem) checkOwners(p string) error {
+	if err := fs.checkOwnersRecord(p); err != nil {
+		return err
+	}
+	if fs.owners == nil || !isLockable(p) {
+		return nil
+	}
+	if err := fs.owners.check(path.Clean("/"+p), time.Now()); err != nil {
+		logger.Warningf("Refused to delete %s: %s", p, err)
+		return err
+	}
+	return nil
+}
+
+// checkOwnersRecord returns an error if the object at the path is the record of the owners of a blockfile
+func (fs *fileSystem) checkOwnersRecord(p string) error {
+	if fs.owners != nil && strings.HasSuffix(p, blockarchive.OwnersSuffix) {
+		return errors.Errorf("the owners of the blockfiles are managed by the repository: %s", p)
+	}
+	return nil
+}
+
+// forgetOwner updates the owners of a blockfile once the blockfile or a reference to it has been deleted
+func (fs *fileSystem) forgetOwner(p string) error {
+	if fs.owners == nil {
+		return nil
+	}
+	if blockfilePath, ref, ok := refOf(p); ok {
+		return fs.owners.released(blockfilePath, ref)
+	}
+	if isLockable(p) {
+		fs.owners.removed(path.Clean("/" + p))
+	}
+	return nil
+}
+
+// CollectGarbage deletes the content-addressed blockfiles which are no longer referenced and no longer
+// retained by any of the organizations which uploaded or referenced them, along with their checksum,
+// unless they are under legal hold or object lock. It returns the paths of the deleted blockfiles.
+func (s *Server) CollectGarbage() ([]string, error) {
+	paths, err := s.owners.collectable(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	fs := &fileSystem{rootDir: s.config.RootDir, quota: s.quota, tiers: s.tiers, holds: s.holds, locks: s.locks,
+		owners: s.owners, webhooks: s.webhooks}
+	var deleted []string
+	for _, p := range paths {
+		if err := fs.remove(p); err != nil {
+			logger.Infof("Garbage collection kept %s: %s", p, err)
+			continue
+		}
+		if err := fs.remove(p + blockarchive.ChecksumSuffix); err != nil && !os.IsNotExist(err) {
+			logger.Warningf("Failed removing the checksum of %s: %s", p, err)
+		}
+		os.Remove(fs.localPath(p) + blockarchive.ObjectLockSuffix)
+		os.Remove(fs.localPath(p + blockarchive.RefsSuffix))
+		logger.Infof("Garbage collection deleted %s", p)
+		deleted = append(deleted, p)
+	}
+	return deleted, nil
+}
+
+// collectGarbagePeriodically runs the garbage collection every interval until the server stops
+func (s *Server) collectGarbagePeriodically(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.CollectGarbage(); err != nil {
+				logger.Errorf("Garbage collection of the blockfiles failed: %s", err)
+			}
+		case <-s.stopGC:
+			return
+		}
+	}
+}
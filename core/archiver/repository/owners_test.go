@@ -0,0 +1,88 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedBlockfileOwners(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	server.owners.config = RetentionConfig{Orgs: map[string]time.Duration{"Org2MSP": time.Hour}}
+
+	// Both organizations archive the same content-addressed blockfile, the second one only references it
+	path := "/blkstore/" + blockarchive.ObjectsDir + "/ab/abcdef"
+	ref1 := blockarchive.BlockfileRefName("peer0.org1", "ch1", 0)
+	ref2 := blockarchive.BlockfileRefName("peer0.org2", "ch1", 0)
+	require.NoError(t, upload(t, server, "org1", "pw1", path, []byte("blocks")))
+	require.NoError(t, upload(t, server, "org1", "pw1", blockarchive.BlockfileRefPath(path, ref1), nil))
+	require.NoError(t, upload(t, server, "org1", "pw1", blockarchive.BlockfileRefPath(path, ref1)+blockarchive.ManifestSuffix, []byte("m")))
+	require.NoError(t, upload(t, server, "org2", "pw2", blockarchive.BlockfileRefPath(path, ref2), nil))
+
+	owners, err := server.owners.Get(path)
+	require.NoError(t, err)
+	require.Len(t, owners, 2)
+	assert.Equal(t, "Org1MSP", owners[0].Org)
+	assert.Equal(t, []string{ref1}, owners[0].Refs)
+	assert.Equal(t, "Org2MSP", owners[1].Org)
+	assert.Equal(t, []string{ref2}, owners[1].Refs)
+
+	// The record of the owners is managed by the repository
+	sshConn, client := openSFTP(t, server)
+	defer sshConn.Close()
+	defer client.Close()
+	assert.Error(t, client.Remove(path+blockarchive.OwnersSuffix))
+	_, err = client.Create(path + blockarchive.OwnersSuffix)
+	assert.Error(t, err)
+
+	// The blockfile is not deleted while it is referenced
+	assert.Error(t, client.Remove(path))
+	require.NoError(t, client.Remove(blockarchive.BlockfileRefPath(path, ref1)))
+	assert.Error(t, client.Remove(path))
+
+	// nor while the policy of one of its owners retains it, and the garbage collection keeps it too
+	require.NoError(t, client.Remove(blockarchive.BlockfileRefPath(path, ref2)))
+	owners, err = server.owners.Get(path)
+	require.NoError(t, err)
+	assert.Empty(t, owners[0].Refs)
+	assert.Empty(t, owners[1].Refs)
+	assert.Error(t, client.Remove(path))
+	deleted, err := server.CollectGarbage()
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	// Once all the policies allow it, the garbage collection deletes the blockfile and its records
+	owners[1].Since = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, server.owners.record(path).save(owners))
+	deleted, err = server.CollectGarbage()
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, deleted)
+	for _, p := range []string{path, path + blockarchive.OwnersSuffix} {
+		_, err = os.Stat(filepath.Join(server.config.RootDir, p))
+		assert.True(t, os.IsNotExist(err), p)
+	}
+
+	// The blockfiles of the channels are not collected, but their owners still retain them
+	chainPath := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org2", "pw2", chainPath, []byte("blocks")))
+	deleted, err = server.CollectGarbage()
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+	assert.Error(t, client.Remove(chainPath))
+	_, err = os.Stat(filepath.Join(server.config.RootDir, chainPath))
+	assert.NoError(t, err)
+}
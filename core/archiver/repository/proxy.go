@@ -0,0 +1,475 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultProxyTimeout = 30 * time.Second
+
+	// cachingSuffix is the suffix of the objects being fetched from the upstream repository
+	cachingSuffix = ".caching"
+)
+
+// ProxyConfig makes the repository a read-through caching proxy of an upstream repository, e.g. a
+// regional cache of the central archive of a geo-distributed consortium. The objects read through the
+// proxy are fetched from the upstream repository on their first read and served from rootDir afterwards.
+// The proxy is read-only: the archiver peers upload to the upstream repository.
+type ProxyConfig struct {
+	// Upstream is the address of the upstream repository. The proxy mode is disabled when it is empty.
+	Upstream string `yaml:"upstream"`
+	// User and Password are the account of the proxy on the upstream repository, or the user "token"
+	// and an API token of the upstream repository
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// CacheSize is the size in bytes of the cached objects, above which the least recently read ones
+	// are evicted. The cache is not limited when it is 0.
+	CacheSize int64 `yaml:"cacheSize"`
+	// Timeout is the timeout of the connection to the upstream repository, 30s by default
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// enabled tells if the repository is a caching proxy
+func (c *ProxyConfig) enabled() bool {
+	return c.Upstream != ""
+}
+
+// validate checks the proxy configuration and fills in the defaults
+func (c *ProxyConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if c.User == "" {
+		return errors.New("no user is configured for the upstream repository")
+	}
+	if c.CacheSize < 0 {
+		return errors.Errorf("invalid cache size: %d", c.CacheSize)
+	}
+	if c.Timeout < 0 {
+		return errors.Errorf("invalid upstream timeout: %s", c.Timeout)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultProxyTimeout
+	}
+	return nil
+}
+
+// cacheEntry is an object cached from the upstream repository
+type cacheEntry struct {
+	size int64
+	// modTime is the modification time of the object on the upstream repository when it was fetched
+	modTime    time.Time
+	lastAccess time.Time
+}
+
+// cacheFetch is a fetch of an object from the upstream repository in progress,
+// which the concurrent reads of the object wait for
+type cacheFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// cacheProxy serves the objects of an upstream repository from a cache in the root directory.
+// A cached object is checked against the upstream one on every read, and fetched again when it has
+// been replaced, e.g. by a repair of the archive. The cached objects are still served when the
+// upstream repository is unreachable.
+type cacheProxy struct {
+	rootDir string
+	config  ProxyConfig
+
+	connLock sync.Mutex
+	sshConn  *ssh.Client
+	client   *sftp.Client
+
+	lock     sync.Mutex
+	entries  map[string]*cacheEntry
+	size     int64
+	fetching map[string]*cacheFetch
+}
+
+// newCacheProxy creates a proxy whose cache is the content of rootDir left by a previous run. The objects
+// of the previous run are considered to be last read when they were modified on the upstream repository.
+func newCacheProxy(rootDir string, config ProxyConfig) (*cacheProxy, error) {
+	c := &cacheProxy{
+		rootDir:  rootDir,
+		config:   config,
+		entries:  make(map[string]*cacheEntry),
+		fetching: make(map[string]*cacheFetch),
+	}
+	err := filepath.Walk(rootDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(info.Name(), cachingSuffix) {
+			// Interrupted fetch
+			return os.Remove(localPath)
+		}
+		rel, err := filepath.Rel(rootDir, localPath)
+		if err != nil {
+			return err
+		}
+		c.entries["/"+filepath.ToSlash(rel)] = &cacheEntry{size: info.Size(), modTime: info.ModTime(), lastAccess: info.ModTime()}
+		c.size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error indexing the cache in %s", rootDir)
+	}
+	c.evict("")
+	logger.Infof("Caching proxy of upstream repository %s, %d object(s) of %d bytes cached", config.Upstream, len(c.entries), c.size)
+	return c, nil
+}
+
+func (c *cacheProxy) localPath(p string) string {
+	return filepath.Join(c.rootDir, filepath.FromSlash(p))
+}
+
+// upstream returns the SFTP session to the upstream repository, which is opened on first use
+func (c *cacheProxy) upstream() (*sftp.Client, error) {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	config := &ssh.ClientConfig{
+		User:            c.config.User,
+		Auth:            []ssh.AuthMethod{ssh.Password(c.config.Password)},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+		Timeout:         c.config.Timeout,
+	}
+	sshConn, err := ssh.Dial("tcp", c.config.Upstream, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "upstream repository %s is unreachable", c.config.Upstream)
+	}
+	client, err := sftp.NewClient(sshConn)
+	if err != nil {
+		sshConn.Close()
+		return nil, errors.Wrapf(err, "error opening SFTP session to upstream repository %s", c.config.Upstream)
+	}
+	c.sshConn, c.client = sshConn, client
+	return client, nil
+}
+
+// disconnect closes the session to the upstream repository after it failed, so that the next request reconnects.
+// Only the SSH connection is closed, the SFTP client closing itself once its connection is lost.
+func (c *cacheProxy) disconnect(client *sftp.Client) {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	if c.client != client {
+		return
+	}
+	c.sshConn.Close()
+	c.client, c.sshConn = nil, nil
+}
+
+// close closes the session to the upstream repository
+func (c *cacheProxy) close() {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+	if c.client != nil {
+		c.client.Close()
+		c.sshConn.Close()
+		c.client, c.sshConn = nil, nil
+	}
+}
+
+// answered tells if an error was returned by the upstream repository, rather than by the session to it
+func answered(err error) bool {
+	if os.IsNotExist(err) {
+		return true
+	}
+	_, ok := errors.Cause(err).(*sftp.StatusError)
+	return ok
+}
+
+// upstreamStat returns the information of an object on the upstream repository. It returns
+// unreachable set when the upstream repository could not be reached.
+func (c *cacheProxy) upstreamStat(p string) (info os.FileInfo, unreachable bool, err error) {
+	client, err := c.upstream()
+	if err != nil {
+		return nil, true, err
+	}
+	info, err = client.Stat(p)
+	if err != nil && !answered(err) {
+		c.disconnect(client)
+		return nil, true, errors.Wrapf(err, "error reaching upstream repository %s", c.config.Upstream)
+	}
+	return info, false, err
+}
+
+// open opens an object of the repository for a download, fetching it from the upstream repository
+// unless the cached copy is up to date
+func (c *cacheProxy) open(p string) (*os.File, error) {
+	p = path.Clean("/" + p)
+	info, unreachable, err := c.upstreamStat(p)
+	if unreachable {
+		if file, cacheErr := c.openCached(p, nil); cacheErr == nil {
+			logger.Warningf("Serving %s from the cache: %s", p, err)
+			return file, nil
+		}
+		return nil, err
+	}
+	if os.IsNotExist(err) {
+		c.drop(p)
+		// ENOENT is reported to the SFTP clients as no such file
+		return nil, &os.PathError{Op: "open", Path: p, Err: syscall.ENOENT}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errors.Errorf("%s is a directory", p)
+	}
+	for {
+		if file, err := c.openCached(p, info); err == nil {
+			return file, nil
+		}
+		c.lock.Lock()
+		fetch, ok := c.fetching[p]
+		if !ok {
+			fetch = &cacheFetch{done: make(chan struct{})}
+			c.fetching[p] = fetch
+			c.lock.Unlock()
+			fetch.err = c.fetch(p, info)
+			c.lock.Lock()
+			delete(c.fetching, p)
+			close(fetch.done)
+		}
+		c.lock.Unlock()
+		<-fetch.done
+		if fetch.err != nil {
+			return nil, fetch.err
+		}
+	}
+}
+
+// openCached opens the cached copy of an object and records the access. The copy must match the
+// upstream object described by info, unless info is nil.
+func (c *cacheProxy) openCached(p string, info os.FileInfo) (*os.File, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[p]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+	}
+	if info != nil && (entry.size != info.Size() || !entry.modTime.Equal(info.ModTime())) {
+		return nil, errors.Errorf("cached copy of %s is stale", p)
+	}
+	file, err := os.Open(c.localPath(p))
+	if err != nil {
+		return nil, err
+	}
+	entry.lastAccess = time.Now()
+	return file, nil
+}
+
+// fetch downloads an object from the upstream repository to the cache. A blockfile is verified
+// against the checksum stored next to it on the upstream repository, if any.
+func (c *cacheProxy) fetch(p string, info os.FileInfo) error {
+	client, err := c.upstream()
+	if err != nil {
+		return err
+	}
+	var verifier *blockarchive.ChecksumWriter
+	var expected *blockarchive.Checksum
+	if !strings.HasSuffix(p, blockarchive.ChecksumSuffix) {
+		if expected, err = c.upstreamChecksum(client, p); err != nil {
+			return err
+		}
+		if expected != nil {
+			if verifier, err = blockarchive.NewChecksumWriter(expected.Algorithm); err != nil {
+				return err
+			}
+		}
+	}
+	remote, err := client.Open(p)
+	if err != nil {
+		if !answered(err) {
+			c.disconnect(client)
+		}
+		return errors.Wrapf(err, "error fetching %s from upstream repository %s", p, c.config.Upstream)
+	}
+	defer remote.Close()
+
+	localPath := c.localPath(p)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	tmp := localPath + cachingSuffix
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	var decoder io.WriteCloser
+	if verifier != nil {
+		// The checksum of an encoded blockfile is the one of the blockfile once decoded
+		decoder = blockarchive.NewDecodingWriter(verifier)
+		w = io.MultiWriter(out, decoder)
+	}
+	size, err := io.Copy(w, remote)
+	if decoder != nil {
+		if closeErr := decoder.Close(); blockarchive.IsEncryptionKeyMissing(closeErr) {
+			// The blockfiles encrypted with the keys of the peers are verified by the peers once decrypted
+			verifier = nil
+		} else if err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		c.disconnect(client)
+		return errors.Wrapf(err, "error fetching %s from upstream repository %s", p, c.config.Upstream)
+	}
+	if verifier != nil {
+		if err := verifier.Verify(expected); err != nil {
+			os.Remove(tmp)
+			logger.Errorf("Fetched %s does not match its checksum on upstream repository %s: %s", p, c.config.Upstream, err)
+			return errors.WithMessagef(err, "%s fetched from upstream repository %s is corrupted", p, c.config.Upstream)
+		}
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := os.Rename(tmp, localPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if previous, ok := c.entries[p]; ok {
+		c.size -= previous.size
+	}
+	c.entries[p] = &cacheEntry{size: size, modTime: info.ModTime()}
+	c.size += size
+	c.evict(p)
+	logger.Debugf("Cached %s (%d bytes) from upstream repository %s", p, size, c.config.Upstream)
+	return nil
+}
+
+// upstreamChecksum reads the checksum stored next to an object on the upstream repository, nil if there is none
+func (c *cacheProxy) upstreamChecksum(client *sftp.Client, p string) (*blockarchive.Checksum, error) {
+	file, err := client.Open(p + blockarchive.ChecksumSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		if !answered(err) {
+			c.disconnect(client)
+		}
+		return nil, errors.Wrapf(err, "error reading the checksum of %s from upstream repository %s", p, c.config.Upstream)
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		c.disconnect(client)
+		return nil, errors.Wrapf(err, "error reading the checksum of %s from upstream repository %s", p, c.config.Upstream)
+	}
+	return blockarchive.ParseChecksum(string(content))
+}
+
+// evict removes the least recently read objects until the cache fits its size, but the object at keep.
+// It must be called with the lock held.
+func (c *cacheProxy) evict(keep string) {
+	for c.config.CacheSize > 0 && c.size > c.config.CacheSize {
+		var oldest string
+		for p, entry := range c.entries {
+			if p != keep && (oldest == "" || entry.lastAccess.Before(c.entries[oldest].lastAccess)) {
+				oldest = p
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		c.remove(oldest)
+		logger.Debugf("Evicted %s from the cache", oldest)
+	}
+}
+
+// drop removes the cached copy of an object which no longer exists on the upstream repository
+func (c *cacheProxy) drop(p string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.entries[p]; ok {
+		c.remove(p)
+	}
+}
+
+// remove removes a cached object. It must be called with the lock held.
+func (c *cacheProxy) remove(p string) {
+	if err := os.Remove(c.localPath(p)); err != nil && !os.IsNotExist(err) {
+		logger.Warningf("Could not remove %s from the cache: %s", p, err)
+	}
+	c.size -= c.entries[p].size
+	delete(c.entries, p)
+}
+
+// stat returns the information of an object on the upstream repository, or of its cached copy when the
+// upstream repository is unreachable
+func (c *cacheProxy) stat(p string) (os.FileInfo, error) {
+	p = path.Clean("/" + p)
+	info, unreachable, err := c.upstreamStat(p)
+	if !unreachable {
+		return info, err
+	}
+	logger.Warningf("Serving the information of %s from the cache: %s", p, err)
+	return os.Stat(c.localPath(p))
+}
+
+// list returns the content of a directory of the upstream repository, or of the cache when the upstream
+// repository is unreachable
+func (c *cacheProxy) list(p string) ([]os.FileInfo, error) {
+	p = path.Clean("/" + p)
+	client, err := c.upstream()
+	if err == nil {
+		files, listErr := client.ReadDir(p)
+		if listErr == nil || answered(listErr) {
+			return files, listErr
+		}
+		c.disconnect(client)
+		err = listErr
+	}
+	logger.Warningf("Listing %s from the cache: %s", p, err)
+	files, err := ioutil.ReadDir(c.localPath(p))
+	if err != nil {
+		return nil, err
+	}
+	cached := files[:0]
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), cachingSuffix) {
+			cached = append(cached, f)
+		}
+	}
+	return cached, nil
+}
+
+// readOnly is the error of the modifications requested to the proxy
+func (c *cacheProxy) readOnly(p string) error {
+	return errors.Errorf("the repository is a read-only caching proxy of %s, %s cannot be modified", c.config.Upstream, p)
+}
@@ -0,0 +1,192 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProxyTestServer(t *testing.T, testDir string, upstream *Server, cacheSize int64) *Server {
+	config := &Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       filepath.Join(testDir, "root"),
+		DataDir:       filepath.Join(testDir, "data"),
+		Users:         []User{{Name: "org1", Password: "pw1", Org: "Org1MSP"}},
+		Proxy:         ProxyConfig{Upstream: upstream.Addr().String(), User: "org2", Password: "pw2", CacheSize: cacheSize},
+	}
+	require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+	return server
+}
+
+func uploadWithChecksum(t *testing.T, server *Server, path string, content []byte) {
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ChecksumSuffix, []byte(checksumOf(content))))
+	require.NoError(t, upload(t, server, "org1", "pw1", path, content))
+}
+
+func TestProxyCachesUpstreamBlockfiles(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	upstream := newTestServer(t, filepath.Join(testDir, "upstream"), QuotaConfig{})
+	upstreamStopped := false
+	defer func() {
+		if !upstreamStopped {
+			upstream.Stop()
+		}
+	}()
+	proxy := newProxyTestServer(t, filepath.Join(testDir, "proxy"), upstream, 150)
+	defer proxy.Stop()
+	cacheDir := filepath.Join(testDir, "proxy", "root")
+
+	paths := []string{"/blkstore/chains/ch1/blockfile_000000", "/blkstore/chains/ch1/blockfile_000001", "/blkstore/chains/ch1/blockfile_000002"}
+	for i, path := range paths {
+		uploadWithChecksum(t, upstream, path, bytes60(byte(i)))
+	}
+
+	sshConn, client := openSFTP(t, proxy)
+	defer sshConn.Close()
+	defer client.Close()
+
+	// The blockfiles are fetched on their first read and served from the cache afterwards
+	content, err := download(client, paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, bytes60(0), content)
+	cached, err := ioutil.ReadFile(filepath.Join(cacheDir, paths[0]))
+	require.NoError(t, err)
+	assert.Equal(t, bytes60(0), cached)
+	_, err = download(client, paths[1])
+	require.NoError(t, err)
+	_, err = download(client, paths[0])
+	require.NoError(t, err)
+
+	// The least recently read blockfile is evicted once the cache is full
+	_, err = download(client, paths[2])
+	require.NoError(t, err)
+	assertNotExist(t, filepath.Join(cacheDir, paths[1]))
+	assert.FileExists(t, filepath.Join(cacheDir, paths[0]))
+	assert.FileExists(t, filepath.Join(cacheDir, paths[2]))
+
+	// The directories and the information of the objects are those of the upstream repository
+	files, err := client.ReadDir("/blkstore/chains/ch1")
+	require.NoError(t, err)
+	assert.Len(t, files, 6)
+	info, err := client.Stat(paths[1])
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), info.Size())
+
+	// A blockfile replaced on the upstream repository is fetched again
+	replaced := append(bytes60(9), 1, 2, 3)
+	require.NoError(t, upload(t, upstream, "org1", "pw1", paths[0]+blockarchive.ChecksumSuffix, []byte(checksumOf(replaced))))
+	require.NoError(t, upload(t, upstream, "org1", "pw1", paths[0], replaced))
+	content, err = download(client, paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, replaced, content)
+
+	// A blockfile corrupted on the upstream repository is not cached
+	corruptedPath := "/blkstore/chains/ch1/blockfile_000003"
+	uploadWithChecksum(t, upstream, corruptedPath, bytes60(3))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(testDir, "upstream", "root", corruptedPath), bytes60(4), 0644))
+	_, err = download(client, corruptedPath)
+	assert.Error(t, err)
+	assertNotExist(t, filepath.Join(cacheDir, corruptedPath))
+	_, err = download(client, "/blkstore/chains/ch1/blockfile_000009")
+	assert.True(t, os.IsNotExist(err))
+
+	// The verification API reads through the cache, evicting blockfile 2
+	verification, err := proxy.VerifyBlockfile(paths[1], blockarchive.ChecksumSHA256, false)
+	require.NoError(t, err)
+	assert.Equal(t, checksumOf(bytes60(1)), verification.Checksum)
+	assertNotExist(t, filepath.Join(cacheDir, paths[2]))
+
+	// The proxy is read-only
+	_, err = client.Create("/blkstore/chains/ch1/blockfile_000004")
+	assert.Contains(t, err.Error(), "the repository is a read-only caching proxy")
+	assert.Error(t, client.Remove(paths[2]))
+
+	// The cached blockfiles are still served when the upstream repository is unreachable
+	upstream.Stop()
+	upstreamStopped = true
+	content, err = download(client, paths[1])
+	require.NoError(t, err)
+	assert.Equal(t, bytes60(1), content)
+	_, err = download(client, paths[2])
+	assert.Contains(t, err.Error(), "upstream repository")
+}
+
+func TestProxyIndexesCacheOnRestart(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	upstream := newTestServer(t, filepath.Join(testDir, "upstream"), QuotaConfig{})
+	defer upstream.Stop()
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	uploadWithChecksum(t, upstream, path, bytes60(0))
+
+	proxy := newProxyTestServer(t, filepath.Join(testDir, "proxy"), upstream, 0)
+	sshConn, client := openSFTP(t, proxy)
+	_, err = download(client, path)
+	require.NoError(t, err)
+	client.Close()
+	sshConn.Close()
+	proxy.Stop()
+
+	// An interrupted fetch is removed and the cached blockfile is served without being fetched again
+	cacheDir := filepath.Join(testDir, "proxy", "root")
+	interrupted := filepath.Join(cacheDir, "/blkstore/chains/ch1/blockfile_000001"+cachingSuffix)
+	require.NoError(t, ioutil.WriteFile(interrupted, bytes60(1), 0644))
+	proxy = newProxyTestServer(t, filepath.Join(testDir, "proxy"), upstream, 0)
+	defer proxy.Stop()
+	assertNotExist(t, interrupted)
+	require.Contains(t, proxy.proxy.entries, path)
+	cachedFile, err := proxy.proxy.openCached(path, nil)
+	require.NoError(t, err)
+	cachedFile.Close()
+}
+
+func TestProxyConfig(t *testing.T) {
+	config := &Config{
+		RootDir: "/root",
+		DataDir: "/data",
+		Users:   []User{{Name: "org1", Password: "pw1"}},
+		Proxy:   ProxyConfig{Upstream: "central:222"},
+	}
+	assert.EqualError(t, config.validate(), "no user is configured for the upstream repository")
+	config.Proxy.User = "org1"
+	config.Quota.Orgs = map[string]int64{"Org1MSP": 100}
+	assert.EqualError(t, config.validate(), "storage quotas cannot be enforced by a caching proxy")
+	config.Quota.Orgs = nil
+	config.Tiering.Tiers = []TierConfig{{Name: "warm", Dir: "/warm", IdleAfter: 1}}
+	assert.EqualError(t, config.validate(), "storage tiering cannot be enabled on a caching proxy")
+	config.Tiering.Tiers = nil
+	require.NoError(t, config.validate())
+	assert.Equal(t, defaultProxyTimeout, config.Proxy.Timeout)
+}
+
+func bytes60(b byte) []byte {
+	content := make([]byte, 60)
+	for i := range content {
+		content[i] = b
+	}
+	return content
+}
+
+func checksumOf(content []byte) string {
+	digest := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,271 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// QuotaExceededError is returned when an upload would exceed the quota of a channel or an organization
+type QuotaExceededError struct {
+	// Kind is either "channel" or "org"
+	Kind  string
+	Name  string
+	Quota int64
+	Used  int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("storage quota of %s [%s] exceeded: %d bytes would be used out of %d", e.Kind, e.Name, e.Used, e.Quota)
+}
+
+// Usage is the storage used by a channel or an organization
+type Usage struct {
+	Used     int64 `json:"used"`
+	Quota    int64 `json:"quota,omitempty"`
+	Exceeded bool  `json:"exceeded"`
+}
+
+// UsageReport is the storage used by all the channels and organizations
+type UsageReport struct {
+	Channels map[string]*Usage `json:"channels"`
+	Orgs     map[string]*Usage `json:"orgs"`
+}
+
+// objectUsage is the persisted record of the storage used by an object of the repository
+type objectUsage struct {
+	Channel string `json:"channel"`
+	Org     string `json:"org"`
+	Size    int64  `json:"size"`
+}
+
+// quotaManager keeps track of the storage used by each channel and organization
+// and enforces their quotas
+type quotaManager struct {
+	action        string
+	channelQuotas map[string]int64
+	orgQuotas     map[string]int64
+	records       recordStore
+
+	lock         sync.Mutex
+	objects      map[string]*objectUsage
+	channelUsage map[string]int64
+	orgUsage     map[string]int64
+	// uploading are the objects being uploaded, whose usage is not recorded yet
+	uploading map[string]bool
+}
+
+// newQuotaManager creates a quota manager and loads the usage records
+func newQuotaManager(conf QuotaConfig, records recordStore) (*quotaManager, error) {
+	m := &quotaManager{
+		action:        conf.Action,
+		channelQuotas: conf.Channels,
+		orgQuotas:     conf.Orgs,
+		records:       records,
+		uploading:     make(map[string]bool),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// load computes the usage from the usage records, and from the objects being uploaded.
+// It must be called with the lock held.
+func (m *quotaManager) load() error {
+	records, err := m.records.all()
+	if err != nil {
+		return errors.WithMessage(err, "error loading usage records")
+	}
+	objects := make(map[string]*objectUsage, len(records))
+	for path, record := range records {
+		obj := &objectUsage{}
+		if err := json.Unmarshal(record, obj); err != nil {
+			return errors.Wrapf(err, "error unmarshaling usage record of %s", path)
+		}
+		objects[path] = obj
+	}
+	for path := range m.uploading {
+		objects[path] = m.objects[path]
+	}
+	m.objects = objects
+	m.channelUsage = make(map[string]int64)
+	m.orgUsage = make(map[string]int64)
+	for _, obj := range objects {
+		m.channelUsage[obj.Channel] += obj.Size
+		m.orgUsage[obj.Org] += obj.Size
+	}
+	return nil
+}
+
+// refresh loads the usage again when the usage records have been modified by the other instances of
+// the repository sharing the metadata store. It must be called with the lock held.
+func (m *quotaManager) refresh() error {
+	modified, err := m.records.modified()
+	if err != nil || !modified {
+		return err
+	}
+	return m.load()
+}
+
+// reserve accounts the object at path as having grown to size on behalf of the channel and organization.
+// It fails if a quota would be exceeded and the configured action is to reject the upload. The usage
+// recorded by the other instances of the repository is taken into account when an upload starts.
+func (m *quotaManager) reserve(path, channel, org string, size int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.uploading[path] {
+		if err := m.refresh(); err != nil {
+			return err
+		}
+	}
+	obj, ok := m.objects[path]
+	if !ok {
+		obj = &objectUsage{Channel: channel, Org: org}
+	}
+	if obj.Channel != channel || obj.Org != org {
+		// The object is overwritten on behalf of another owner
+		m.channelUsage[obj.Channel] -= obj.Size
+		m.orgUsage[obj.Org] -= obj.Size
+		obj = &objectUsage{Channel: channel, Org: org}
+	}
+	delta := size - obj.Size
+	if delta <= 0 {
+		return nil
+	}
+
+	if err := m.checkQuota("channel", channel, m.channelQuotas, m.channelUsage, delta); err != nil {
+		return err
+	}
+	if err := m.checkQuota("org", org, m.orgQuotas, m.orgUsage, delta); err != nil {
+		return err
+	}
+
+	obj.Size = size
+	m.objects[path] = obj
+	m.uploading[path] = true
+	m.channelUsage[channel] += delta
+	m.orgUsage[org] += delta
+	return nil
+}
+
+// checkQuota checks whether growing the usage of name by delta exceeds its quota
+func (m *quotaManager) checkQuota(kind, name string, quotas, usage map[string]int64, delta int64) error {
+	quota, ok := quotas[name]
+	if !ok || name == "" {
+		return nil
+	}
+	used := usage[name] + delta
+	if used <= quota {
+		return nil
+	}
+	err := &QuotaExceededError{Kind: kind, Name: name, Quota: quota, Used: used}
+	if m.action == QuotaActionReject {
+		logger.Warningf("Rejected upload: %s", err)
+		return err
+	}
+	// Raise the alert only once, when the quota gets exceeded
+	if usage[name] <= quota {
+		logger.Errorf("ALERT: %s", err)
+	}
+	return nil
+}
+
+// commit persists the usage record of the object at path
+func (m *quotaManager) commit(path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	obj, ok := m.objects[path]
+	if !ok {
+		return nil
+	}
+	delete(m.uploading, path)
+	objBytes, err := json.Marshal(obj)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling usage record")
+	}
+	return m.records.put(path, objBytes)
+}
+
+// release frees the storage accounted for the object at path
+func (m *quotaManager) release(path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	obj, ok := m.objects[path]
+	if !ok {
+		return nil
+	}
+	delete(m.objects, path)
+	delete(m.uploading, path)
+	m.channelUsage[obj.Channel] -= obj.Size
+	m.orgUsage[obj.Org] -= obj.Size
+	return m.records.delete(path)
+}
+
+// rename moves the usage record of the object at oldPath to newPath.
+// The quotas are not enforced since the data is already stored.
+func (m *quotaManager) rename(oldPath, newPath, channel string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	obj, ok := m.objects[oldPath]
+	if !ok {
+		return nil
+	}
+	delete(m.objects, oldPath)
+	delete(m.uploading, oldPath)
+	m.channelUsage[obj.Channel] -= obj.Size
+	m.channelUsage[channel] += obj.Size
+	obj.Channel = channel
+	m.objects[newPath] = obj
+
+	objBytes, err := json.Marshal(obj)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling usage record")
+	}
+	return m.records.move(oldPath, newPath, objBytes)
+}
+
+// report returns the storage used by every channel and organization which either
+// stores data or has a quota
+func (m *quotaManager) report() *UsageReport {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.refresh(); err != nil {
+		logger.Warningf("Reporting the usage last loaded: %s", err)
+	}
+	return &UsageReport{
+		Channels: buildUsage(m.channelQuotas, m.channelUsage),
+		Orgs:     buildUsage(m.orgQuotas, m.orgUsage),
+	}
+}
+
+func buildUsage(quotas, usage map[string]int64) map[string]*Usage {
+	result := make(map[string]*Usage)
+	for name, used := range usage {
+		if name == "" || used == 0 {
+			continue
+		}
+		result[name] = &Usage{Used: used}
+	}
+	for name, quota := range quotas {
+		u, ok := result[name]
+		if !ok {
+			u = &Usage{}
+			result[name] = u
+		}
+		u.Quota = quota
+		u.Exceeded = u.Used > quota
+	}
+	return result
+}
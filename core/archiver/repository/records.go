@@ -0,0 +1,157 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// recordStore keeps the records of the objects of the repository by path, i.e. their usage
+// and their tier
+type recordStore interface {
+	// get returns the record at key, nil if there is none
+	get(key string) ([]byte, error)
+	put(key string, value []byte) error
+	delete(key string) error
+	// move replaces the record at oldKey with the value at newKey in a single write
+	move(oldKey, newKey string, value []byte) error
+	// all returns all the records by key
+	all() (map[string][]byte, error)
+	// modified tells if the records have been modified since all was last called, which only
+	// happens when they are shared with other instances of the repository
+	modified() (bool, error)
+}
+
+// dbRecords keeps the records in a db of the index of the data directory, which is
+// only written by this instance
+type dbRecords struct {
+	db *leveldbhelper.DBHandle
+}
+
+func (r *dbRecords) get(key string) ([]byte, error) {
+	return r.db.Get([]byte(key))
+}
+
+func (r *dbRecords) put(key string, value []byte) error {
+	return r.db.Put([]byte(key), value, true)
+}
+
+func (r *dbRecords) delete(key string) error {
+	return r.db.Delete([]byte(key), true)
+}
+
+func (r *dbRecords) move(oldKey, newKey string, value []byte) error {
+	batch := leveldbhelper.NewUpdateBatch()
+	batch.Delete([]byte(oldKey))
+	batch.Put([]byte(newKey), value)
+	return r.db.WriteBatch(batch, true)
+}
+
+func (r *dbRecords) all() (map[string][]byte, error) {
+	records := make(map[string][]byte)
+	itr := r.db.GetIterator(nil, nil)
+	defer itr.Release()
+	for itr.Next() {
+		// The iterator reuses its buffers
+		records[string(itr.Key())] = append([]byte(nil), itr.Value()...)
+	}
+	if err := itr.Error(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (r *dbRecords) modified() (bool, error) {
+	return false, nil
+}
+
+// etcdRecords keeps the records under a prefix of the etcd metadata store, shared by the
+// instances of the repository
+type etcdRecords struct {
+	store  *etcdStore
+	prefix string
+	what   string
+
+	// revision and count are those of the records when they were last loaded
+	revision int64
+	count    int64
+}
+
+func (r *etcdRecords) get(key string) ([]byte, error) {
+	value, _, err := r.store.get(r.prefix + key)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("error reading %s record %s", r.what, key))
+	}
+	return value, nil
+}
+
+func (r *etcdRecords) put(key string, value []byte) error {
+	ctx, cancel := r.store.context()
+	defer cancel()
+	if _, err := r.store.client.Put(ctx, r.prefix+key, string(value)); err != nil {
+		return errors.Wrapf(err, "error writing %s record %s", r.what, key)
+	}
+	return nil
+}
+
+func (r *etcdRecords) delete(key string) error {
+	ctx, cancel := r.store.context()
+	defer cancel()
+	if _, err := r.store.client.Delete(ctx, r.prefix+key); err != nil {
+		return errors.Wrapf(err, "error deleting %s record %s", r.what, key)
+	}
+	return nil
+}
+
+func (r *etcdRecords) move(oldKey, newKey string, value []byte) error {
+	ctx, cancel := r.store.context()
+	defer cancel()
+	_, err := r.store.client.Txn(ctx).Then(
+		clientv3.OpDelete(r.prefix+oldKey),
+		clientv3.OpPut(r.prefix+newKey, string(value)),
+	).Commit()
+	if err != nil {
+		return errors.Wrapf(err, "error moving %s record %s to %s", r.what, oldKey, newKey)
+	}
+	return nil
+}
+
+func (r *etcdRecords) all() (map[string][]byte, error) {
+	ctx, cancel := r.store.context()
+	defer cancel()
+	resp, err := r.store.client.Get(ctx, r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s records %s", r.what, r.prefix)
+	}
+	records := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		records[strings.TrimPrefix(string(kv.Key), r.prefix)] = kv.Value
+	}
+	r.revision, r.count = resp.Header.Revision, resp.Count
+	return records, nil
+}
+
+// modified counts the records and looks for one modified after they were last loaded. A record
+// which has been deleted changes the count, any other change sets the revision of a record.
+func (r *etcdRecords) modified() (bool, error) {
+	ctx, cancel := r.store.context()
+	defer cancel()
+	resp, err := r.store.client.Txn(ctx).Then(
+		clientv3.OpGet(r.prefix, clientv3.WithPrefix(), clientv3.WithCountOnly()),
+		clientv3.OpGet(r.prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithLimit(1),
+			clientv3.WithMinModRev(r.revision+1)),
+	).Commit()
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading %s records %s", r.what, r.prefix)
+	}
+	count := resp.Responses[0].GetResponseRange().Count
+	newer := resp.Responses[1].GetResponseRange().Kvs
+	return count != r.count || len(newer) > 0, nil
+}
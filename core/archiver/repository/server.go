@@ -0,0 +1,333 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var logger = flogging.MustGetLogger("archiver.repository")
+
+const (
+	usageDBName  = "usage"
+	orgExtension = "org"
+)
+
+// Server is a repository of archived blockfiles which is shared by the peers of a consortium.
+// It serves the blockfiles over SFTP and enforces the storage quotas of the channels and organizations,
+// or serves them from a cache as a proxy of an upstream repository.
+type Server struct {
+	config      *Config
+	sshConfig   *ssh.ServerConfig
+	dbProvider  *leveldbhelper.Provider
+	metadata    *etcdStore
+	quota       *quotaManager
+	tokens      *TokenStore
+	holds       *HoldStore
+	locks       *ObjectLocker
+	owners      *OwnerTracker
+	tiers       *tierManager
+	webhooks    *webhookNotifier
+	proxy       *cacheProxy
+	listener    net.Listener
+	usageTLS    *tls.Config
+	usageServer *http.Server
+	usageAddr   net.Addr
+
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+	// stopGC stops the garbage collection of the content-addressed blockfiles
+	stopGC chan struct{}
+}
+
+// NewServer creates a repository server from the configuration
+func NewServer(config *Config) (*Server, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	hostKey, err := loadHostKey(config.HostKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		config: config,
+		conns:  make(map[net.Conn]struct{}),
+		stopGC: make(chan struct{}),
+	}
+	s.sshConfig = &ssh.ServerConfig{PasswordCallback: s.authenticate}
+	s.sshConfig.AddHostKey(hostKey)
+	if config.UsageListenAddress != "" {
+		if s.usageTLS, err = config.UsageTLS.serverConfig(); err != nil {
+			return nil, err
+		}
+	}
+	var usageRecords, tierRecords recordStore
+	if config.Metadata.shared() {
+		if s.metadata, err = newEtcdStore(config.Metadata); err != nil {
+			return nil, err
+		}
+		if config.TokenAuth {
+			s.tokens = s.metadata.tokenStore()
+		}
+		s.holds = s.metadata.holdStore()
+		usageRecords = s.metadata.records(usageDBName, "usage")
+		tierRecords = s.metadata.records(tieringDBName, "tier")
+	} else {
+		if config.TokenAuth {
+			s.tokens = NewTokenStore(config.DataDir)
+		}
+		s.holds = NewHoldStore(config.DataDir)
+		s.dbProvider = leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: filepath.Join(config.DataDir, "index")})
+		usageRecords = &dbRecords{db: s.dbProvider.GetDBHandle(usageDBName)}
+		tierRecords = &dbRecords{db: s.dbProvider.GetDBHandle(tieringDBName)}
+	}
+	s.locks = NewObjectLocker(config.RootDir, config.ObjectLock)
+	s.owners = NewOwnerTracker(config.RootDir, config.Retention)
+	if config.Proxy.enabled() {
+		if s.proxy, err = newCacheProxy(config.RootDir, config.Proxy); err != nil {
+			s.closeStores()
+			return nil, err
+		}
+	}
+
+	s.quota, err = newQuotaManager(config.Quota, usageRecords)
+	if err != nil {
+		s.closeStores()
+		return nil, err
+	}
+	if len(config.Tiering.Tiers) > 0 {
+		s.tiers = newTierManager(config.RootDir, config.Tiering, tierRecords)
+	}
+	if len(config.Webhooks) > 0 {
+		s.webhooks = newWebhookNotifier(config.Webhooks)
+	}
+	return s, nil
+}
+
+// Start starts serving the SFTP requests and the usage reporting API
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.ListenAddress)
+	if err != nil {
+		return errors.Wrapf(err, "error listening on %s", s.config.ListenAddress)
+	}
+	s.listener = listener
+	logger.Infof("Repository serving %s on %s", s.config.RootDir, listener.Addr())
+
+	if s.config.UsageListenAddress != "" {
+		usageListener, err := net.Listen("tcp", s.config.UsageListenAddress)
+		if err != nil {
+			listener.Close()
+			return errors.Wrapf(err, "error listening on %s", s.config.UsageListenAddress)
+		}
+		s.usageServer = &http.Server{Handler: s.authenticateAPI(s.usageHandler()), TLSConfig: s.usageTLS}
+		s.usageAddr = usageListener.Addr()
+		logger.Infof("Usage reporting API listening on %s", s.usageAddr)
+		go s.usageServer.ServeTLS(usageListener, "", "")
+	}
+
+	if s.tiers != nil {
+		s.tiers.start()
+	}
+	if s.config.Retention.GCInterval > 0 {
+		s.wg.Add(1)
+		go s.collectGarbagePeriodically(s.config.Retention.GCInterval)
+	}
+	s.wg.Add(1)
+	go s.acceptConns()
+	return nil
+}
+
+// Addr returns the address the SFTP server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// UsageAddr returns the address the usage reporting API listens on, nil if it is disabled
+func (s *Server) UsageAddr() net.Addr {
+	return s.usageAddr
+}
+
+// Usage returns the storage used by every channel and organization
+func (s *Server) Usage() *UsageReport {
+	return s.quota.report()
+}
+
+// Stop closes all the connections and releases the resources of the server
+func (s *Server) Stop() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.usageServer != nil {
+		s.usageServer.Close()
+	}
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+	close(s.stopGC)
+	s.wg.Wait()
+	if s.tiers != nil {
+		s.tiers.close()
+	}
+	if s.proxy != nil {
+		s.proxy.close()
+	}
+	// The events of the completed requests are delivered before the server stops
+	s.webhooks.close()
+	s.closeStores()
+}
+
+// closeStores closes the index of the data directory or the connections to the shared metadata store
+func (s *Server) closeStores() {
+	if s.dbProvider != nil {
+		s.dbProvider.Close()
+	}
+	if s.metadata != nil {
+		if err := s.metadata.Close(); err != nil {
+			logger.Warningf("Failed to close the metadata store: %s", err)
+		}
+	}
+}
+
+func (s *Server) acceptConns() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			logger.Debugf("Stopped accepting connections: %s", err)
+			return
+		}
+		s.lock.Lock()
+		s.conns[conn] = struct{}{}
+		s.lock.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+// authenticate checks the password of the user, or the API token passed as the password of
+// TokenUser, and attaches its organization to the connection
+func (s *Server) authenticate(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	org, err := s.checkCredentials(meta.User(), password, meta.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Permissions{Extensions: map[string]string{orgExtension: org}}, nil
+}
+
+// checkCredentials checks the password of the user, or the API token passed as the password of
+// TokenUser, and returns its organization
+func (s *Server) checkCredentials(user string, password []byte, remoteAddr string) (string, error) {
+	if user == TokenUser && s.tokens != nil {
+		token, err := s.tokens.Authenticate(string(password))
+		if err != nil {
+			logger.Errorf("Could not check the token from %s: %s", remoteAddr, err)
+		}
+		if token != nil {
+			logger.Debugf("Token of [%s] authenticated from %s", token.Name, remoteAddr)
+			return token.Org, nil
+		}
+		logger.Warningf("Token authentication failed from %s", remoteAddr)
+		return "", errors.New("token authentication failed")
+	}
+	for _, u := range s.config.Users {
+		if u.Name == user && subtle.ConstantTimeCompare([]byte(u.Password), password) == 1 {
+			return u.Org, nil
+		}
+	}
+	logger.Warningf("Authentication failed for user [%s] from %s", user, remoteAddr)
+	return "", errors.Errorf("authentication failed for user [%s]", user)
+}
+
+func (s *Server) handleConn(nConn net.Conn) {
+	conn, chans, reqs, err := ssh.NewServerConn(nConn, s.sshConfig)
+	if err != nil {
+		logger.Debugf("SSH handshake with %s failed: %s", nConn.RemoteAddr(), err)
+		nConn.Close()
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	org := conn.Permissions.Extensions[orgExtension]
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			logger.Warningf("Could not accept channel from %s: %s", conn.RemoteAddr(), err)
+			continue
+		}
+		go s.handleSession(channel, requests, org)
+	}
+}
+
+// handleSession serves the sftp subsystem of an SSH session
+func (s *Server) handleSession(channel ssh.Channel, requests <-chan *ssh.Request, org string) {
+	defer channel.Close()
+	for req := range requests {
+		isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(isSFTP, nil)
+		if !isSFTP {
+			continue
+		}
+		fs := &fileSystem{rootDir: s.config.RootDir, org: org, quota: s.quota, tiers: s.tiers, holds: s.holds, locks: s.locks,
+			owners: s.owners, webhooks: s.webhooks, proxy: s.proxy}
+		server := sftp.NewRequestServer(channel, fs.handlers())
+		if err := server.Serve(); err != nil && err != io.EOF {
+			logger.Warningf("SFTP session ended with error: %s", err)
+		}
+		server.Close()
+		return
+	}
+}
+
+// loadHostKey reads the host key of the server, or generates an ephemeral one
+func loadHostKey(hostKeyFile string) (ssh.Signer, error) {
+	if hostKeyFile == "" {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "error generating host key")
+		}
+		return ssh.NewSignerFromKey(key)
+	}
+	keyBytes, err := ioutil.ReadFile(hostKeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading host key %s", hostKeyFile)
+	}
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing host key %s", hostKeyFile)
+	}
+	return signer, nil
+}
@@ -0,0 +1,391 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/crypto/tlsgen"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestServer(t *testing.T, testDir string, quota QuotaConfig) *Server {
+	config := &Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       filepath.Join(testDir, "root"),
+		DataDir:       filepath.Join(testDir, "data"),
+		Users: []User{
+			{Name: "org1", Password: "pw1", Org: "Org1MSP"},
+			{Name: "org2", Password: "pw2", Org: "Org2MSP"},
+		},
+		Quota: quota,
+	}
+	require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+	return server
+}
+
+func upload(t *testing.T, server *Server, user, password, path string, content []byte) error {
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+	}
+	sshConn, err := ssh.Dial("tcp", server.Addr().String(), config)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	client, err := sftp.NewClient(sshConn)
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.MkdirAll(filepath.Dir(path)))
+	file, err := client.Create(path)
+	require.NoError(t, err)
+	_, err = file.Write(content)
+	file.Close()
+	return err
+}
+
+func TestUploadWithinQuota(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{
+		Channels: map[string]int64{"ch1": 100},
+		Orgs:     map[string]int64{"Org1MSP": 150},
+	})
+	defer server.Stop()
+
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path, make([]byte, 60)))
+	stored, err := ioutil.ReadFile(filepath.Join(testDir, "root", path))
+	require.NoError(t, err)
+	assert.Len(t, stored, 60)
+
+	usage := server.Usage()
+	assert.Equal(t, &Usage{Used: 60, Quota: 100}, usage.Channels["ch1"])
+	assert.Equal(t, &Usage{Used: 60, Quota: 150}, usage.Orgs["Org1MSP"])
+
+	// Overwriting the object does not count twice
+	require.NoError(t, upload(t, server, "org1", "pw1", path, make([]byte, 80)))
+	assert.Equal(t, int64(80), server.Usage().Channels["ch1"].Used)
+}
+
+func TestUploadRejected(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{
+		Action: QuotaActionReject,
+		Orgs:   map[string]int64{"Org1MSP": 100},
+	})
+	defer server.Stop()
+
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000000", make([]byte, 80)))
+	err = upload(t, server, "org1", "pw1", "/blkstore/chains/ch2/blockfile_000000", make([]byte, 80))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage quota of org [Org1MSP] exceeded")
+
+	// The other organizations are not limited
+	require.NoError(t, upload(t, server, "org2", "pw2", "/blkstore/chains/ch2/blockfile_000000", make([]byte, 80)))
+	usage := server.Usage()
+	assert.Equal(t, int64(80), usage.Orgs["Org1MSP"].Used)
+	assert.False(t, usage.Orgs["Org1MSP"].Exceeded)
+	assert.Equal(t, int64(80), usage.Orgs["Org2MSP"].Used)
+}
+
+func TestUploadAlert(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{
+		Action:   QuotaActionAlert,
+		Channels: map[string]int64{"ch1": 100},
+	})
+	defer server.Stop()
+
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000000", make([]byte, 80)))
+	require.NoError(t, upload(t, server, "org2", "pw2", "/blkstore/chains/ch1/blockfile_000001", make([]byte, 80)))
+	assert.Equal(t, &Usage{Used: 160, Quota: 100, Exceeded: true}, server.Usage().Channels["ch1"])
+}
+
+func TestUploadChecksumVerified(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+
+	content := []byte("blockfile content")
+	checksum, err := blockarchive.ComputeChecksum(bytes.NewReader(content), blockarchive.ChecksumSHA256)
+	require.NoError(t, err)
+
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ChecksumSuffix, []byte(checksum.String())))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", []byte("corrupted content")))
+	err = rename(t, server, "org1", "pw1", path+".uploading", path)
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(testDir, "root", path))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", content))
+	require.NoError(t, rename(t, server, "org1", "pw1", path+".uploading", path))
+	stored, err := ioutil.ReadFile(filepath.Join(testDir, "root", path))
+	require.NoError(t, err)
+	assert.Equal(t, content, stored)
+}
+
+func TestUploadEncryptedWithoutKey(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+
+	content := bytes.Repeat([]byte("\x0ablock content"), 1000)
+	checksum, err := blockarchive.ComputeChecksum(bytes.NewReader(content), blockarchive.ChecksumSHA256)
+	require.NoError(t, err)
+	keyring, err := blockarchive.NewEncryptionKeyring(map[string][]byte{"key1": bytes.Repeat([]byte{1}, blockarchive.EncryptionKeySize)})
+	require.NoError(t, err)
+	prevKeys := blockarchive.EncryptionKeys
+	blockarchive.EncryptionKeys = keyring
+	defer func() { blockarchive.EncryptionKeys = prevKeys }()
+	object := &bytes.Buffer{}
+	w, err := blockarchive.NewBlockfileWriter(object, &blockarchive.ObjectHeader{Size: int64(len(content)), Checksum: checksum.String()}, 0, "key1")
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// The repository does not hold the keys of the peers, the encrypted blockfile is stored unverified
+	blockarchive.EncryptionKeys = nil
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ChecksumSuffix, []byte(checksum.String())))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", object.Bytes()))
+	require.NoError(t, rename(t, server, "org1", "pw1", path+".uploading", path))
+	stored, err := ioutil.ReadFile(filepath.Join(testDir, "root", path))
+	require.NoError(t, err)
+	assert.Equal(t, object.Bytes(), stored)
+}
+
+func rename(t *testing.T, server *Server, user, password, oldPath, newPath string) error {
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+	}
+	sshConn, err := ssh.Dial("tcp", server.Addr().String(), config)
+	require.NoError(t, err)
+	defer sshConn.Close()
+	client, err := sftp.NewClient(sshConn)
+	require.NoError(t, err)
+	defer client.Close()
+	return client.Rename(oldPath, newPath)
+}
+
+func TestUsagePersistedAndReleased(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	quota := QuotaConfig{Channels: map[string]int64{"ch1": 100}}
+	server := newTestServer(t, testDir, quota)
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path, make([]byte, 50)))
+	server.Stop()
+
+	server = newTestServer(t, testDir, quota)
+	defer server.Stop()
+	assert.Equal(t, int64(50), server.Usage().Channels["ch1"].Used)
+	assert.Equal(t, int64(50), server.Usage().Orgs["Org1MSP"].Used)
+
+	fs := &fileSystem{rootDir: server.config.RootDir, org: "Org1MSP", quota: server.quota}
+	require.NoError(t, fs.Filecmd(&sftp.Request{Method: "Remove", Filepath: path}))
+	assert.Equal(t, &Usage{Quota: 100}, server.Usage().Channels["ch1"])
+	assert.NotContains(t, server.Usage().Orgs, "Org1MSP")
+}
+
+func TestAuthenticationFailure(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+
+	config := &ssh.ClientConfig{
+		User:            "org1",
+		Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+	}
+	_, err = ssh.Dial("tcp", server.Addr().String(), config)
+	assert.Error(t, err)
+}
+
+func TestUsageAPI(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{Orgs: map[string]int64{"Org2MSP": 10}})
+	defer server.Stop()
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000000", make([]byte, 42)))
+
+	api := httptest.NewServer(server.usageHandler())
+	defer api.Close()
+
+	resp, err := http.Get(api.URL + "/usage")
+	require.NoError(t, err)
+	report := &UsageReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(report))
+	resp.Body.Close()
+	assert.Equal(t, &Usage{Used: 42}, report.Channels["ch1"])
+	assert.Equal(t, &Usage{Quota: 10}, report.Orgs["Org2MSP"])
+
+	resp, err = http.Get(api.URL + "/usage/orgs/Org1MSP")
+	require.NoError(t, err)
+	usage := &Usage{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(usage))
+	resp.Body.Close()
+	assert.Equal(t, &Usage{Used: 42}, usage)
+
+	resp, err = http.Get(api.URL + "/usage/channels/unknown")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestUsageAPITLSAndAuth(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ca, err := tlsgen.NewCA()
+	require.NoError(t, err)
+	serverCert, err := ca.NewServerCertKeyPair("127.0.0.1")
+	require.NoError(t, err)
+	writeFile := func(name string, content []byte) string {
+		path := filepath.Join(testDir, name)
+		require.NoError(t, ioutil.WriteFile(path, content, 0600))
+		return path
+	}
+	config := &Config{
+		ListenAddress:      "127.0.0.1:0",
+		RootDir:            filepath.Join(testDir, "root"),
+		DataDir:            filepath.Join(testDir, "data"),
+		Users:              []User{{Name: "org1", Password: "pw1", Org: "Org1MSP"}},
+		TokenAuth:          true,
+		UsageListenAddress: "127.0.0.1:0",
+	}
+	require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+
+	// The API is not served without a certificate
+	_, err = NewServer(config)
+	assert.EqualError(t, err, "the certificate and key of the usage reporting API must be configured")
+	config.UsageTLS = UsageTLSConfig{CertFile: writeFile("cert.pem", serverCert.Cert), KeyFile: filepath.Join(testDir, "missing.pem")}
+	_, err = NewServer(config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error loading usage API certificate")
+
+	config.UsageTLS.KeyFile = writeFile("key.pem", serverCert.Key)
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+	token, err := server.tokens.Issue("peer0", "Org1MSP", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000000", make([]byte, 42)))
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AppendCertsFromPEM(ca.CertBytes())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}}
+	apiURL := "https://" + server.UsageAddr().String()
+	get := func(user, password string) int {
+		req, err := http.NewRequest(http.MethodGet, apiURL+"/usage/channels/ch1", nil)
+		require.NoError(t, err)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			usage := &Usage{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(usage))
+			assert.Equal(t, &Usage{Used: 42}, usage)
+		}
+		return resp.StatusCode
+	}
+
+	// The accounts and the API tokens of the SFTP server authenticate the requests
+	assert.Equal(t, http.StatusOK, get("org1", "pw1"))
+	assert.Equal(t, http.StatusOK, get(TokenUser, token))
+	assert.Equal(t, http.StatusUnauthorized, get("", ""))
+	assert.Equal(t, http.StatusUnauthorized, get("org1", "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, get(TokenUser, "bat_unknown"))
+
+	// The API is not served over plain HTTP
+	resp, err := http.Get("http://" + server.UsageAddr().String() + "/usage")
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// The peers verify the archived blockfiles with the credentials of the repository
+	defer func(apiURL, tokenFile string, tlsConfig *tls.Config) {
+		blockarchive.RepositoryAPIURL, blockarchive.RepositoryTokenFile, blockarchive.RepositoryTLSConfig = apiURL, tokenFile, tlsConfig
+	}(blockarchive.RepositoryAPIURL, blockarchive.RepositoryTokenFile, blockarchive.RepositoryTLSConfig)
+	blockarchive.RepositoryAPIURL = apiURL
+	blockarchive.RepositoryTLSConfig = &tls.Config{RootCAs: rootCAs}
+	blockarchive.RepositoryTokenFile = writeFile("repo.token", []byte(token))
+	verification, err := blockarchive.VerifyBlockfile("/blkstore/chains/ch1/blockfile_000000", blockarchive.ChecksumSHA256, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), verification.Size)
+	blockarchive.RepositoryTokenFile = writeFile("repo.token", []byte("bat_unknown"))
+	_, err = blockarchive.VerifyBlockfile("/blkstore/chains/ch1/blockfile_000000", blockarchive.ChecksumSHA256, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+}
+
+func TestChannelOfPath(t *testing.T) {
+	assert.Equal(t, "ch1", channelOfPath("/blkstore/chains/ch1/blockfile_000001"))
+	assert.Equal(t, "ch1", channelOfPath("blkstore/chains/ch1/blockfile_000001.manifest"))
+	assert.Equal(t, "", channelOfPath("/blkstore/chains/ch1"))
+	assert.Equal(t, "", channelOfPath("/blkstore/other"))
+}
+
+func TestConfigValidation(t *testing.T) {
+	config := &Config{RootDir: "/root", DataDir: "/data", Users: []User{{Name: "u"}}}
+	require.NoError(t, config.validate())
+	assert.Equal(t, QuotaActionReject, config.Quota.Action)
+	assert.Equal(t, "0.0.0.0:222", config.ListenAddress)
+
+	config.Quota.Action = "ignore"
+	assert.Error(t, config.validate())
+	assert.Error(t, (&Config{DataDir: "/data", Users: []User{{Name: "u"}}}).validate())
+	assert.Error(t, (&Config{RootDir: "/root", DataDir: "/data"}).validate())
+}
@@ -0,0 +1,501 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+const (
+	tieringDBName = "tiering"
+
+	// HotTier is the name of the tier of the root directory, where the blockfiles are uploaded
+	HotTier = "hot"
+
+	defaultTieringInterval = time.Hour
+
+	// uploadingSuffix is the suffix of the blockfiles being uploaded by the peers
+	uploadingSuffix = ".uploading"
+)
+
+// ErrRestoreInProgress is returned when a blockfile of a deep archive tier is read. The restore of
+// the blockfile to the hot tier is then started, and the read succeeds once it has completed.
+var ErrRestoreInProgress = errors.New(blockarchive.RestoreInProgressMessage)
+
+// TieringConfig holds the policies migrating the blockfiles from the root directory of the
+// repository, the hot tier, to colder storage classes as they are accessed less often
+type TieringConfig struct {
+	// Interval is the period of the migrations, 1h by default
+	Interval time.Duration `yaml:"interval"`
+	// Tiers are the storage classes from the warmest to the coldest
+	Tiers []TierConfig `yaml:"tiers"`
+}
+
+// TierConfig is a storage class of the repository
+type TierConfig struct {
+	Name string `yaml:"name"`
+	// Dir is where the blockfiles of the tier are stored, e.g. the mount point of a NAS
+	// or of a file gateway to an archive storage service
+	Dir string `yaml:"dir"`
+	// IdleAfter is how long a blockfile is not accessed before being migrated to the tier
+	IdleAfter time.Duration `yaml:"idleAfter"`
+	// DeepArchive indicates that the blockfiles of the tier cannot be read directly.
+	// Reading one restores it to the hot tier first, and fails until it has been restored.
+	DeepArchive bool `yaml:"deepArchive"`
+}
+
+// validate checks the tiering policies and fills in the defaults
+func (c *TieringConfig) validate() error {
+	if c.Interval == 0 {
+		c.Interval = defaultTieringInterval
+	}
+	if c.Interval < 0 {
+		return errors.Errorf("invalid tiering interval: %s", c.Interval)
+	}
+	names := map[string]bool{HotTier: true}
+	var idleAfter time.Duration
+	for _, tier := range c.Tiers {
+		if tier.Name == "" || names[tier.Name] {
+			return errors.Errorf("tier name [%s] is empty or not unique", tier.Name)
+		}
+		names[tier.Name] = true
+		if tier.Dir == "" {
+			return errors.Errorf("dir of tier [%s] is not configured", tier.Name)
+		}
+		if tier.IdleAfter <= idleAfter {
+			return errors.Errorf("idleAfter of tier [%s] must be greater than the one of the warmer tiers", tier.Name)
+		}
+		idleAfter = tier.IdleAfter
+	}
+	return nil
+}
+
+// tierRecord is the persisted record of the tier of a blockfile and of its accesses
+type tierRecord struct {
+	Tier       string    `json:"tier"`
+	LastAccess time.Time `json:"lastAccess"`
+	Accesses   uint64    `json:"accesses"`
+}
+
+type tier struct {
+	name      string
+	dir       string
+	idleAfter time.Duration
+	deep      bool
+}
+
+// tierManager places the blockfiles of the repository in the tiers according to the time since
+// they were last accessed, and resolves the paths of the repository to the tier of the blockfiles,
+// so that the tiering is transparent to the peers
+type tierManager struct {
+	tiers    []*tier
+	interval time.Duration
+	records  recordStore
+
+	lock      sync.Mutex
+	restoring map[string]bool
+	started   bool
+	wg        sync.WaitGroup
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newTierManager(rootDir string, config TieringConfig, records recordStore) *tierManager {
+	tiers := []*tier{{name: HotTier, dir: rootDir}}
+	for _, t := range config.Tiers {
+		tiers = append(tiers, &tier{name: t.Name, dir: t.Dir, idleAfter: t.IdleAfter, deep: t.DeepArchive})
+	}
+	return &tierManager{
+		tiers:     tiers,
+		interval:  config.Interval,
+		records:   records,
+		restoring: make(map[string]bool),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// start runs the migrations periodically
+func (m *tierManager) start() {
+	m.started = true
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.migrate(time.Now()); err != nil {
+					logger.Errorf("Tiering of the blockfiles failed: %s", err)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// close stops the migrations and waits for the restores in progress
+func (m *tierManager) close() {
+	close(m.stop)
+	if m.started {
+		<-m.done
+	}
+	m.wg.Wait()
+}
+
+func (m *tierManager) hot() *tier {
+	return m.tiers[0]
+}
+
+func (m *tierManager) tierByName(name string) *tier {
+	for _, t := range m.tiers {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (t *tier) localPath(p string) string {
+	return filepath.Join(t.dir, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (m *tierManager) getRecord(p string) (*tierRecord, error) {
+	b, err := m.records.get(path.Clean("/" + p))
+	if err != nil || b == nil {
+		return nil, err
+	}
+	record := &tierRecord{}
+	if err := json.Unmarshal(b, record); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling tier record of %s", p)
+	}
+	return record, nil
+}
+
+func (m *tierManager) putRecord(p string, record *tierRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return m.records.put(path.Clean("/"+p), b)
+}
+
+// locate returns the tier holding the object of the repository, the hot tier if it is not tiered
+func (m *tierManager) locate(p string) (*tier, *tierRecord, error) {
+	record, err := m.getRecord(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record == nil {
+		return m.hot(), nil, nil
+	}
+	t := m.tierByName(record.Tier)
+	if t == nil {
+		// The tier has been removed from the configuration, the blockfile is expected to be back in the hot tier
+		t = m.hot()
+	}
+	return t, record, nil
+}
+
+// open opens an object of the repository for a download and records the access. A blockfile of a
+// deep archive tier is restored to the hot tier asynchronously, and ErrRestoreInProgress is returned.
+func (m *tierManager) open(p string) (*os.File, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	t, record, err := m.locate(p)
+	if err != nil {
+		return nil, err
+	}
+	if t.deep {
+		m.startRestore(p, t)
+		return nil, ErrRestoreInProgress
+	}
+	file, err := os.Open(t.localPath(p))
+	if err != nil || !isTierable(path.Base(p)) {
+		return file, err
+	}
+	if record == nil {
+		record = &tierRecord{Tier: t.name}
+	}
+	record.LastAccess = time.Now()
+	record.Accesses++
+	if err := m.putRecord(p, record); err != nil {
+		logger.Warningf("Could not record the access to %s: %s", p, err)
+	}
+	return file, nil
+}
+
+// readAll reads the content of an object of the repository without recording an access,
+// except from a deep archive tier which cannot be read without restoring the object
+func (m *tierManager) readAll(p string) ([]byte, error) {
+	m.lock.Lock()
+	t, _, err := m.locate(p)
+	m.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if t.deep {
+		return nil, errors.Errorf("%s is in deep archive tier [%s]", p, t.name)
+	}
+	return ioutil.ReadFile(t.localPath(p))
+}
+
+// startRestore copies a blockfile of a deep archive tier back to the hot tier, unless it is
+// already being restored. It must be called with the lock held.
+func (m *tierManager) startRestore(p string, from *tier) {
+	if m.restoring[p] {
+		return
+	}
+	m.restoring[p] = true
+	logger.Infof("Restoring %s from tier [%s]", p, from.name)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		err := m.move(p, from, m.hot(), time.Now())
+		m.lock.Lock()
+		delete(m.restoring, p)
+		m.lock.Unlock()
+		if err != nil {
+			logger.Errorf("Restore of %s from tier [%s] failed: %s", p, from.name, err)
+			return
+		}
+		logger.Infof("Restored %s from tier [%s]", p, from.name)
+	}()
+}
+
+// move copies an object to another tier, records its new tier and removes it from the previous one.
+// The access time of the record is set to lastAccess.
+func (m *tierManager) move(p string, from, to *tier, lastAccess time.Time) error {
+	if err := copyFile(from.localPath(p), to.localPath(p)); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	record, err := m.getRecord(p)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &tierRecord{}
+	}
+	record.Tier = to.name
+	record.LastAccess = lastAccess
+	if err := m.putRecord(p, record); err != nil {
+		os.Remove(to.localPath(p))
+		return err
+	}
+	if err := os.Remove(from.localPath(p)); err != nil && !os.IsNotExist(err) {
+		logger.Warningf("Could not remove %s from tier [%s]: %s", p, from.name, err)
+	}
+	return nil
+}
+
+// copyFile copies a file durably, through a temporary file renamed once complete. The modification
+// time is preserved, so that the idle time of the blockfiles is not reset by the migrations.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	// The temporary file is unique since the instances of the repository sharing the storage
+	// may migrate the same blockfile at once
+	out, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".*.tiering")
+	if err != nil {
+		return err
+	}
+	tmp := out.Name()
+	if err := out.Chmod(0644); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// stat returns the information of an object of the repository in whichever tier it is
+func (m *tierManager) stat(p string) (os.FileInfo, error) {
+	t, _, err := m.locate(p)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(t.localPath(p))
+	if os.IsNotExist(err) && t != m.hot() {
+		return os.Stat(m.hot().localPath(p))
+	}
+	return info, err
+}
+
+// list returns the content of a directory of the repository merged across the tiers
+func (m *tierManager) list(p string) ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(m.hot().localPath(p))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, f := range files {
+		seen[f.Name()] = true
+	}
+	for _, t := range m.tiers[1:] {
+		tierFiles, err := ioutil.ReadDir(t.localPath(p))
+		if err != nil {
+			continue
+		}
+		for _, f := range tierFiles {
+			if !f.IsDir() && !seen[f.Name()] && !strings.HasSuffix(f.Name(), ".tiering") {
+				seen[f.Name()] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// remove removes an object of the repository from all the tiers
+func (m *tierManager) remove(p string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	err := os.Remove(m.hot().localPath(p))
+	removed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, t := range m.tiers[1:] {
+		if err := os.Remove(t.localPath(p)); err == nil {
+			removed = true
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if !removed {
+		return &os.PathError{Op: "remove", Path: p, Err: os.ErrNotExist}
+	}
+	return m.records.delete(path.Clean("/" + p))
+}
+
+// uploaded records that an object has been uploaded to the hot tier, replacing its copies in the other tiers
+func (m *tierManager) uploaded(p string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, t := range m.tiers[1:] {
+		if err := os.Remove(t.localPath(p)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return m.putRecord(p, &tierRecord{Tier: HotTier, LastAccess: time.Now()})
+}
+
+// isTierable tells if an object is migrated between the tiers. The checksums and the references
+// of the blockfiles are small and read along with every blockfile, so they stay in the hot tier,
+// as do the summaries, which are read to audit the archive without reading the blockfiles, and the
+// records of the object locks and of the owners. The snapshots of the blockfiles still being written
+// are replaced until the blockfiles are archived, so they stay in the hot tier too.
+func isTierable(name string) bool {
+	return !strings.HasSuffix(name, blockarchive.ChecksumSuffix) &&
+		!strings.HasSuffix(name, blockarchive.SummarySuffix) &&
+		!strings.HasSuffix(name, blockarchive.ObjectLockSuffix) &&
+		!strings.HasSuffix(name, blockarchive.RefsSuffix) &&
+		!strings.HasSuffix(name, blockarchive.OwnersSuffix) &&
+		!strings.HasSuffix(name, blockarchive.TailSuffix) &&
+		!strings.HasSuffix(name, uploadingSuffix) &&
+		!strings.HasSuffix(name, ".tiering")
+}
+
+// migrate moves the blockfiles idle for long enough to colder tiers, and returns the number of
+// blockfiles moved
+func (m *tierManager) migrate(now time.Time) (int, error) {
+	moved := 0
+	for i, from := range m.tiers[:len(m.tiers)-1] {
+		err := filepath.Walk(from.dir, func(localPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() || !isTierable(info.Name()) {
+				return nil
+			}
+			rel, err := filepath.Rel(from.dir, localPath)
+			if err != nil {
+				return err
+			}
+			p := "/" + filepath.ToSlash(rel)
+
+			m.lock.Lock()
+			record, err := m.getRecord(p)
+			restoring := m.restoring[p]
+			m.lock.Unlock()
+			if err != nil {
+				return err
+			}
+			if restoring || (record != nil && record.Tier != from.name) {
+				return nil
+			}
+			lastAccess := info.ModTime()
+			if record != nil && record.LastAccess.After(lastAccess) {
+				lastAccess = record.LastAccess
+			}
+			idle := now.Sub(lastAccess)
+			var to *tier
+			for _, t := range m.tiers[i+1:] {
+				if idle >= t.idleAfter {
+					to = t
+				}
+			}
+			if to == nil {
+				return nil
+			}
+			if err := m.move(p, from, to, lastAccess); err != nil {
+				logger.Warningf("Could not migrate %s from tier [%s] to tier [%s]: %s", p, from.name, to.name, err)
+				return nil
+			}
+			logger.Infof("Migrated %s from tier [%s] to tier [%s] after %s idle", p, from.name, to.name, idle)
+			moved++
+			return nil
+		})
+		if err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,177 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTieredTestServer(t *testing.T, testDir string) *Server {
+	config := &Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       filepath.Join(testDir, "root"),
+		DataDir:       filepath.Join(testDir, "data"),
+		Users:         []User{{Name: "org1", Password: "pw1", Org: "Org1MSP"}},
+		Tiering: TieringConfig{
+			Tiers: []TierConfig{
+				{Name: "warm", Dir: filepath.Join(testDir, "warm"), IdleAfter: time.Hour},
+				{Name: "cold", Dir: filepath.Join(testDir, "cold"), IdleAfter: 24 * time.Hour, DeepArchive: true},
+			},
+		},
+	}
+	require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+	return server
+}
+
+func openSFTP(t *testing.T, server *Server) (*ssh.Client, *sftp.Client) {
+	config := &ssh.ClientConfig{
+		User:            "org1",
+		Auth:            []ssh.AuthMethod{ssh.Password("pw1")},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+	}
+	sshConn, err := ssh.Dial("tcp", server.Addr().String(), config)
+	require.NoError(t, err)
+	client, err := sftp.NewClient(sshConn)
+	require.NoError(t, err)
+	return sshConn, client
+}
+
+func download(client *sftp.Client, path string) ([]byte, error) {
+	file, err := client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+func assertNotExist(t *testing.T, path string) {
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "%s should not exist", path)
+}
+
+func TestTieringMigratesAndServesTransparently(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTieredTestServer(t, testDir)
+	defer server.Stop()
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	content := []byte("blockfile content")
+	require.NoError(t, upload(t, server, "org1", "pw1", path, content))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ChecksumSuffix, []byte("checksum")))
+
+	// Not idle for long enough yet
+	moved, err := server.tiers.migrate(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, moved)
+
+	moved, err = server.tiers.migrate(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assertNotExist(t, filepath.Join(testDir, "root", path))
+	assert.FileExists(t, filepath.Join(testDir, "warm", path))
+	// The checksums stay in the hot tier
+	assert.FileExists(t, filepath.Join(testDir, "root", path+blockarchive.ChecksumSuffix))
+
+	sshConn, client := openSFTP(t, server)
+	defer sshConn.Close()
+	defer client.Close()
+	read, err := download(client, path)
+	require.NoError(t, err)
+	assert.Equal(t, content, read)
+	info, err := client.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size())
+	files, err := client.ReadDir("/blkstore/chains/ch1")
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// The idle time restarts from the last access
+	moved, err = server.tiers.migrate(time.Now().Add(12 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, moved)
+	moved, err = server.tiers.migrate(time.Now().Add(48 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.FileExists(t, filepath.Join(testDir, "cold", path))
+
+	// The blockfiles of a deep archive tier are restored before being served
+	_, err = download(client, path)
+	require.Error(t, err)
+	assert.True(t, blockarchive.IsRestoreInProgress(err))
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if read, err = download(client, path); err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+	assert.Equal(t, content, read)
+	assert.FileExists(t, filepath.Join(testDir, "root", path))
+	assertNotExist(t, filepath.Join(testDir, "cold", path))
+}
+
+func TestTieringRemoveAndReupload(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTieredTestServer(t, testDir)
+	defer server.Stop()
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path, []byte("first")))
+	_, err = server.tiers.migrate(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+
+	// A new upload replaces the copy of the colder tier
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", []byte("second")))
+	require.NoError(t, rename(t, server, "org1", "pw1", path+".uploading", path))
+	assertNotExist(t, filepath.Join(testDir, "warm", path))
+
+	sshConn, client := openSFTP(t, server)
+	defer sshConn.Close()
+	defer client.Close()
+	read, err := download(client, path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), read)
+
+	_, err = server.tiers.migrate(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, client.Remove(path))
+	assertNotExist(t, filepath.Join(testDir, "warm", path))
+	_, err = client.Stat(path)
+	assert.Error(t, err)
+}
+
+func TestTieringConfigValidation(t *testing.T) {
+	config := &TieringConfig{}
+	require.NoError(t, config.validate())
+	assert.Equal(t, time.Hour, config.Interval)
+
+	config.Tiers = []TierConfig{{Name: "hot", Dir: "/nas", IdleAfter: time.Hour}}
+	assert.EqualError(t, config.validate(), "tier name [hot] is empty or not unique")
+	config.Tiers = []TierConfig{{Name: "warm", IdleAfter: time.Hour}}
+	assert.EqualError(t, config.validate(), "dir of tier [warm] is not configured")
+	config.Tiers = []TierConfig{
+		{Name: "warm", Dir: "/nas", IdleAfter: time.Hour},
+		{Name: "cold", Dir: "/glacier", IdleAfter: time.Hour},
+	}
+	assert.EqualError(t, config.validate(), "idleAfter of tier [cold] must be greater than the one of the warmer tiers")
+}
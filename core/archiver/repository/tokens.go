@@ -0,0 +1,249 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TokenUser is the SSH user name with which the peers authenticate with a bearer token,
+	// passed as the password. The organization is the one the token has been issued for.
+	TokenUser = blockarchive.RepositoryTokenUser
+
+	// tokensFileName is the name of the file in the data directory which holds the tokens
+	tokensFileName = "tokens.json"
+
+	// tokenPrefix makes the tokens recognizable, e.g. by secret scanners
+	tokenPrefix = "bat_"
+)
+
+// TokenInfo describes an API token of the repository. The token itself is never stored,
+// only its SHA-256 hash.
+type TokenInfo struct {
+	// Name identifies the holder of the token, e.g. the peer it has been issued to
+	Name string `json:"name"`
+	// Org is the organization the uploads made with the token are accounted to
+	Org string `json:"org"`
+	// Hash is the hex encoded SHA-256 hash of the token
+	Hash string `json:"hash"`
+	// IssuedAt is when the token has been issued
+	IssuedAt time.Time `json:"issuedAt"`
+	// ExpiresAt is when the token expires, never if nil
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Expired tells if the token has expired at the time
+func (t *TokenInfo) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// TokenStore holds the API tokens of the repository in a JSON file of the data directory,
+// or in the shared metadata store. The file is replaced atomically on every change and is read again by the server when it
+// has been modified, so that the tokens can be issued, rotated and revoked by the CLI
+// while the server is running.
+type TokenStore struct {
+	// closer closes the connections to the shared metadata store, nil for a file
+	closer io.Closer
+
+	lock   sync.Mutex
+	file   document
+	tokens []*TokenInfo
+}
+
+// NewTokenStore opens the token store of the data directory
+func NewTokenStore(dataDir string) *TokenStore {
+	return &TokenStore{file: &jsonFile{path: filepath.Join(dataDir, tokensFileName), what: "token"}}
+}
+
+// Close releases the connections of the store to the shared metadata store
+func (s *TokenStore) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// Issue creates a new token for the holder, valid for ttl or forever if ttl is 0.
+// It returns the token, which cannot be recovered from the store afterwards.
+func (s *TokenStore) Issue(name, org string, ttl time.Duration) (string, error) {
+	if name == "" {
+		return "", errors.New("the name of the token holder is empty")
+	}
+	if org == "" {
+		return "", errors.New("the organization of the token holder is empty")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tokens {
+		if t.Name == name {
+			return "", errors.Errorf("a token is already issued to [%s], rotate it instead", name)
+		}
+	}
+	token, info, err := newToken(name, org, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := s.save(append(tokens, info)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Rotate issues a new token to the holder. The current tokens of the holder remain valid for
+// the grace period, so that the new token can be rolled out before they expire.
+func (s *TokenStore) Rotate(name string, grace, ttl time.Duration) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	graceEnd := now.Add(grace)
+	org := ""
+	for _, t := range tokens {
+		if t.Name != name || t.Expired(now) {
+			continue
+		}
+		org = t.Org
+		if t.ExpiresAt == nil || t.ExpiresAt.After(graceEnd) {
+			t.ExpiresAt = &graceEnd
+		}
+	}
+	if org == "" {
+		return "", errors.Errorf("no valid token is issued to [%s]", name)
+	}
+	token, info, err := newToken(name, org, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := s.save(append(tokens, info)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke removes all the tokens of the holder
+func (s *TokenStore) Revoke(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	var kept []*TokenInfo
+	for _, t := range tokens {
+		if t.Name != name {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(tokens) {
+		return errors.Errorf("no token is issued to [%s]", name)
+	}
+	return s.save(kept)
+}
+
+// List returns the tokens of the store, ordered by holder and issuance, including the expired ones
+func (s *TokenStore) List() ([]*TokenInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].Name != tokens[j].Name {
+			return tokens[i].Name < tokens[j].Name
+		}
+		return tokens[i].IssuedAt.Before(tokens[j].IssuedAt)
+	})
+	return tokens, nil
+}
+
+// Authenticate returns the valid token which matches, or nil
+func (s *TokenStore) Authenticate(token string) (*TokenInfo, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	hash := hashToken(token)
+	now := time.Now()
+	var match *TokenInfo
+	for _, t := range tokens {
+		// Compare all the tokens so that the time taken doesn't tell which one matches
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1 && !t.Expired(now) {
+			match = t
+		}
+	}
+	return match, nil
+}
+
+// load returns the tokens of the file, which is read again only if it has been modified
+func (s *TokenStore) load() ([]*TokenInfo, error) {
+	var tokens []*TokenInfo
+	loaded, err := s.file.load(&tokens)
+	if err != nil {
+		return nil, err
+	}
+	if loaded {
+		s.tokens = tokens
+	}
+	return s.tokens, nil
+}
+
+// save replaces the token file atomically
+func (s *TokenStore) save(tokens []*TokenInfo) error {
+	if tokens == nil {
+		tokens = []*TokenInfo{}
+	}
+	return s.file.save(tokens)
+}
+
+func newToken(name, org string, ttl time.Duration) (string, *TokenInfo, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, errors.Wrap(err, "error generating token")
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	info := &TokenInfo{
+		Name:     name,
+		Org:      org,
+		Hash:     hashToken(token),
+		IssuedAt: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		expiresAt := info.IssuedAt.Add(ttl)
+		info.ExpiresAt = &expiresAt
+	}
+	return token, info, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
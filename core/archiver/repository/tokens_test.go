@@ -0,0 +1,136 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestTokenStoreLifecycle(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	store := NewTokenStore(testDir)
+	token, err := store.Issue("peer0.org1", "Org1MSP", 0)
+	require.NoError(t, err)
+	_, err = store.Issue("peer0.org1", "Org1MSP", 0)
+	assert.EqualError(t, err, "a token is already issued to [peer0.org1], rotate it instead")
+
+	info, err := store.Authenticate(token)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "Org1MSP", info.Org)
+	assert.Nil(t, info.ExpiresAt)
+	info, err = store.Authenticate("bat_wrong")
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	// The token is stored hashed
+	b, err := ioutil.ReadFile(filepath.Join(testDir, tokensFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), token)
+
+	// The previous token remains valid during the grace period
+	rotated, err := store.Rotate("peer0.org1", time.Hour, 0)
+	require.NoError(t, err)
+	info, err = store.Authenticate(token)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.NotNil(t, info.ExpiresAt)
+	info, err = store.Authenticate(rotated)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, "Org1MSP", info.Org)
+
+	// and not afterwards
+	_, err = store.Rotate("peer0.org1", 0, 0)
+	require.NoError(t, err)
+	info, err = store.Authenticate(rotated)
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	tokens, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, tokens, 3)
+
+	_, err = store.Rotate("peer1.org1", 0, 0)
+	assert.EqualError(t, err, "no valid token is issued to [peer1.org1]")
+	require.NoError(t, store.Revoke("peer0.org1"))
+	tokens, err = store.List()
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+	assert.EqualError(t, store.Revoke("peer0.org1"), "no token is issued to [peer0.org1]")
+}
+
+func TestTokenExpiry(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	store := NewTokenStore(testDir)
+	token, err := store.Issue("peer0.org1", "Org1MSP", time.Nanosecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	info, err := store.Authenticate(token)
+	require.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	config := &Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       filepath.Join(testDir, "root"),
+		DataDir:       filepath.Join(testDir, "data"),
+		TokenAuth:     true,
+	}
+	require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+
+	// The tokens issued while the server is running are picked up
+	token, err := NewTokenStore(config.DataDir).Issue("peer0.org2", "Org2MSP", 0)
+	require.NoError(t, err)
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, TokenUser, token, path, make([]byte, 30)))
+	assert.Equal(t, int64(30), server.Usage().Orgs["Org2MSP"].Used)
+
+	sshConfig := &ssh.ClientConfig{
+		User:            TokenUser,
+		Auth:            []ssh.AuthMethod{ssh.Password("bat_wrong")},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+	}
+	_, err = ssh.Dial("tcp", server.Addr().String(), sshConfig)
+	assert.Error(t, err)
+
+	require.NoError(t, NewTokenStore(config.DataDir).Revoke("peer0.org2"))
+	sshConfig.Auth = []ssh.AuthMethod{ssh.Password(token)}
+	_, err = ssh.Dial("tcp", server.Addr().String(), sshConfig)
+	assert.Error(t, err)
+}
+
+func TestConfigWithoutUsers(t *testing.T) {
+	config := &Config{RootDir: "root", DataDir: "data"}
+	assert.EqualError(t, config.validate(), "no user is configured and tokenAuth is disabled")
+	config.TokenAuth = true
+	assert.NoError(t, config.validate())
+	config.Users = []User{{Name: TokenUser, Password: "pw", Org: "Org1MSP"}}
+	assert.EqualError(t, config.validate(), "user name [token] is reserved for the token authentication")
+}
@@ -0,0 +1,98 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// UsageTLSConfig is the server certificate of the usage reporting API
+type UsageTLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// serverConfig returns the TLS configuration of the usage reporting API
+func (c *UsageTLSConfig) serverConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error loading usage API certificate %s", c.CertFile)
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+}
+
+// usageHandler serves the usage reporting API:
+//
+//	GET /usage                  - usage of all the channels and organizations
+//	GET /usage/channels/<name>  - usage of a channel
+//	GET /usage/orgs/<name>      - usage of an organization
+//	GET /export/<channel>       - archived blocks of a channel, see exportHandler
+//	/holds                      - administration of the legal holds, see holdsHandler
+//	GET /verify/<path>          - digest of an archived blockfile, see verifyHandler
+func (s *Server) usageHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/usage", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, s.Usage())
+	})
+	mux.HandleFunc("/usage/channels/", func(w http.ResponseWriter, r *http.Request) {
+		serveUsageOf(w, r, "/usage/channels/", s.Usage().Channels)
+	})
+	mux.HandleFunc("/usage/orgs/", func(w http.ResponseWriter, r *http.Request) {
+		serveUsageOf(w, r, "/usage/orgs/", s.Usage().Orgs)
+	})
+	mux.HandleFunc("/export/", s.exportHandler)
+	mux.HandleFunc("/holds", s.holdsHandler)
+	mux.HandleFunc("/holds/", s.holdsHandler)
+	mux.HandleFunc(blockarchive.VerifyPath+"/", s.verifyHandler)
+	return mux
+}
+
+// authenticateAPI requires the requests to carry the credentials of an account or an API token in
+// their Basic authorization header, which are checked as those of the SFTP connections
+func (s *Server) authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if ok {
+			_, err := s.checkCredentials(user, []byte(password), r.RemoteAddr)
+			ok = err == nil
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="blkarchiver-repo"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serveUsageOf(w http.ResponseWriter, r *http.Request, prefix string, usage map[string]*Usage) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, ok := usage[strings.TrimPrefix(r.URL.Path, prefix)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, u)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warningf("Failed to write usage report: %s", err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// VerifyBlockfile computes the checksum of the stored content of the blockfile at the path of the repository with
+// the algorithm, once decoded if the blockfile is stored encoded, and reads the header hashes of the blocks from the
+// summary stored next to it if withBlockHashes is set, so that the peers audit the integrity of the archive without
+// downloading the blockfile.
+// The verification is not recorded as an access to the blockfile by the tiering.
+func (s *Server) VerifyBlockfile(p, algorithm string, withBlockHashes bool) (*blockarchive.BlockfileVerification, error) {
+	p = path.Clean("/" + p)
+	if p == "/" || strings.HasSuffix(p, blockarchive.SummarySuffix) {
+		return nil, errors.Errorf("invalid blockfile path %s", p)
+	}
+	content, err := s.openObject(p)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	w, err := blockarchive.NewChecksumWriter(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	// The checksum of an encoded blockfile is the one of the blockfile once decoded
+	decoder := blockarchive.NewDecodingWriter(w)
+	size, err := io.Copy(decoder, content)
+	if closeErr := decoder.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading blockfile %s", p)
+	}
+	verification := &blockarchive.BlockfileVerification{Path: p, Size: size, Checksum: w.Checksum().String()}
+	if withBlockHashes {
+		if verification.Blocks, err = s.readSummaryHashes(p); err != nil {
+			return nil, err
+		}
+	}
+	return verification, nil
+}
+
+// readSummaryHashes returns the block hashes of the summary of the blockfile at the path, nil if it has no summary
+func (s *Server) readSummaryHashes(p string) (*blockarchive.VerifiedBlockHashes, error) {
+	content, err := s.openObject(p + blockarchive.SummarySuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	b, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the summary of blockfile %s", p)
+	}
+	summary := &archive.BlockfileSummary{}
+	if err := proto.Unmarshal(b, summary); err != nil {
+		return nil, errors.Wrapf(err, "error parsing the summary of blockfile %s", p)
+	}
+	if err := blockarchive.VerifySummary(summary); err != nil {
+		return nil, errors.WithMessagef(err, "invalid summary of blockfile %s", p)
+	}
+	return &blockarchive.VerifiedBlockHashes{
+		FirstBlockNum: summary.FirstBlockNum,
+		LastBlockNum:  summary.LastBlockNum,
+		BlockHashes:   summary.BlockHashes,
+		MerkleRoot:    summary.MerkleRoot,
+	}, nil
+}
+
+// openObject opens an object of the repository for reading in whichever tier it is,
+// or through the cache of a caching proxy
+func (s *Server) openObject(p string) (io.ReadCloser, error) {
+	if s.proxy != nil {
+		return s.proxy.open(p)
+	}
+	if s.tiers == nil {
+		return os.Open((&fileSystem{rootDir: s.config.RootDir}).localPath(p))
+	}
+	b, err := s.tiers.readAll(p)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// verifyHandler serves the verification API:
+//
+//	GET /verify/<path>?algorithm=<algorithm>&blocks=true
+//
+// It returns the size and the checksum of the stored content of the blockfile, sha256 by default, and with
+// blocks=true the header hashes of the blocks from its summary.
+func (s *Server) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	algorithm := query.Get("algorithm")
+	if algorithm == "" {
+		algorithm = blockarchive.ChecksumSHA256
+	}
+	if _, err := blockarchive.NewChecksumHash(algorithm); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withBlockHashes := false
+	if blocks := query.Get("blocks"); blocks != "" {
+		var err error
+		if withBlockHashes, err = strconv.ParseBool(blocks); err != nil {
+			http.Error(w, "invalid blocks ["+blocks+"]", http.StatusBadRequest)
+			return
+		}
+	}
+	p := strings.TrimPrefix(r.URL.Path, blockarchive.VerifyPath)
+	if strings.Trim(p, "/") == "" {
+		http.Error(w, "no blockfile path", http.StatusBadRequest)
+		return
+	}
+	verification, err := s.VerifyBlockfile(p, algorithm, withBlockHashes)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			http.NotFound(w, r)
+			return
+		}
+		logger.Warningf("Could not verify blockfile %s: %s", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, verification)
+}
@@ -0,0 +1,76 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAPI(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	server := newTestServer(t, testDir, QuotaConfig{})
+	defer server.Stop()
+	blocks := writeArchivedBlocks(t, server.config.RootDir, "ch1", 3)
+	location := "/blkstore/chains/ch1/blockfile_000000"
+	localPath := filepath.Join(server.config.RootDir, filepath.FromSlash(location))
+	expected, err := blockarchive.ComputeBlockfileChecksum(localPath, blockarchive.ChecksumSHA256)
+	require.NoError(t, err)
+	info, err := os.Stat(localPath)
+	require.NoError(t, err)
+
+	api := httptest.NewServer(server.usageHandler())
+	defer api.Close()
+	defer func(prev string) { blockarchive.RepositoryAPIURL = prev }(blockarchive.RepositoryAPIURL)
+	blockarchive.RepositoryAPIURL = api.URL
+
+	// No summary next to the blockfile
+	verification, err := blockarchive.VerifyBlockfile(location, blockarchive.ChecksumSHA256, true)
+	require.NoError(t, err)
+	assert.Equal(t, &blockarchive.BlockfileVerification{Path: location, Size: info.Size(), Checksum: expected.String()}, verification)
+
+	var hashes [][]byte
+	for _, block := range blocks {
+		hashes = append(hashes, protoutil.BlockHeaderHash(block.Header))
+	}
+	summary, err := proto.Marshal(blockarchive.NewBlockfileSummary("ch1", 0, 0, hashes, expected.Digest))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(localPath+blockarchive.SummarySuffix, summary, 0644))
+	verification, err = blockarchive.VerifyBlockfile(location, blockarchive.ChecksumSHA256, true)
+	require.NoError(t, err)
+	require.NotNil(t, verification.Blocks)
+	assert.Equal(t, uint64(0), verification.Blocks.FirstBlockNum)
+	assert.Equal(t, uint64(2), verification.Blocks.LastBlockNum)
+	assert.Equal(t, hashes, verification.Blocks.BlockHashes)
+	assert.Equal(t, blockarchive.ComputeMerkleRoot(hashes), verification.Blocks.MerkleRoot)
+
+	verification, err = blockarchive.VerifyBlockfile(location, blockarchive.ChecksumSHA256, false)
+	require.NoError(t, err)
+	assert.Nil(t, verification.Blocks)
+
+	_, err = blockarchive.VerifyBlockfile("/blkstore/chains/ch1/blockfile_000009", blockarchive.ChecksumSHA256, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404 Not Found")
+
+	for _, query := range []string{location + "?algorithm=md5", location + "?blocks=maybe", "/"} {
+		resp, err := http.Get(api.URL + blockarchive.VerifyPath + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/errors"
+)
+
+// The kinds of the events of the repository posted to the webhooks
+const (
+	// EventUpload is posted once a blockfile has been stored in the repository
+	EventUpload = "upload"
+	// EventDelete is posted once a blockfile has been deleted from the repository
+	EventDelete = "delete"
+	// EventIntegrityFailure is posted when an uploaded blockfile does not match its checksum and is rejected
+	EventIntegrityFailure = "integrityFailure"
+)
+
+const (
+	// WebhookEventHeader is the HTTP header holding the kind of the event posted to a webhook
+	WebhookEventHeader = "X-Blkarchiver-Event"
+	// WebhookSignatureHeader is the HTTP header holding the signature of the payload posted to a webhook,
+	// "sha256=" followed by the hex encoded HMAC-SHA256 of the payload keyed with the secret of the webhook
+	WebhookSignatureHeader = "X-Blkarchiver-Signature"
+
+	defaultWebhookTimeout     = 5 * time.Second
+	defaultWebhookMaxAttempts = 3
+	// webhookQueueSize is the number of events waiting for their delivery to a webhook,
+	// beyond which the events are dropped
+	webhookQueueSize = 1000
+	signaturePrefix  = "sha256="
+)
+
+// webhookRetryInterval is the delay before the second attempt to deliver an event, doubled at each attempt
+var webhookRetryInterval = time.Second
+
+// WebhookConfig is an HTTP endpoint to which the events of the repository are posted,
+// so that external systems track the archive without polling it
+type WebhookConfig struct {
+	// URL is the http or https endpoint the events are posted to
+	URL string `yaml:"url"`
+	// Secret is the key with which the payloads are signed
+	Secret string `yaml:"secret"`
+	// Events are the kinds of events posted, all of them when empty
+	Events []string `yaml:"events"`
+	// Timeout is the timeout of each attempt to post an event, 5s by default
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is the number of attempts to post an event before it is dropped, 3 by default
+	MaxAttempts int `yaml:"maxAttempts"`
+}
+
+// validate checks the webhook configuration and fills in the defaults
+func (c *WebhookConfig) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid webhook url [%s]", c.URL)
+	}
+	if c.Secret == "" {
+		return errors.Errorf("secret of webhook [%s] is not configured", c.URL)
+	}
+	for _, event := range c.Events {
+		switch event {
+		case EventUpload, EventDelete, EventIntegrityFailure:
+		default:
+			return errors.Errorf("invalid event [%s] of webhook [%s], must be %s, %s or %s",
+				event, c.URL, EventUpload, EventDelete, EventIntegrityFailure)
+		}
+	}
+	if c.Timeout < 0 || c.MaxAttempts < 0 {
+		return errors.Errorf("invalid timeout or maxAttempts of webhook [%s]", c.URL)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultWebhookTimeout
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	return nil
+}
+
+// subscribed tells if the events of a kind are posted to the webhook
+func (c *WebhookConfig) subscribed(kind string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, event := range c.Events {
+		if event == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON payload posted to the webhooks
+type Event struct {
+	Kind string `json:"kind"`
+	// Path is the path of the blockfile in the repository
+	Path    string `json:"path"`
+	Channel string `json:"channel,omitempty"`
+	// Org is the organization of the user which has uploaded or deleted the blockfile
+	Org  string `json:"org,omitempty"`
+	Size int64  `json:"size,omitempty"`
+	// Error is the reason of an integrity failure
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// SignWebhookPayload returns the signature of a payload posted to a webhook with the secret
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookPayload tells if the signature of a payload received by a webhook was made with the secret
+func VerifyWebhookPayload(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, payload)), []byte(signature))
+}
+
+// webhookNotifier posts the events of the repository to the configured webhooks. Each webhook has its
+// own queue, delivered in order in the background, so that a slow endpoint delays neither the SFTP
+// requests nor the other webhooks.
+type webhookNotifier struct {
+	webhooks []*webhook
+	wg       sync.WaitGroup
+
+	// the SFTP sessions may still be ending when the server stops
+	lock   sync.RWMutex
+	closed bool
+}
+
+type webhook struct {
+	config WebhookConfig
+	client *http.Client
+	events chan *Event
+}
+
+func newWebhookNotifier(configs []WebhookConfig) *webhookNotifier {
+	n := &webhookNotifier{}
+	for _, config := range configs {
+		w := &webhook{
+			config: config,
+			client: &http.Client{Timeout: config.Timeout},
+			events: make(chan *Event, webhookQueueSize),
+		}
+		n.webhooks = append(n.webhooks, w)
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			for event := range w.events {
+				w.deliver(event)
+			}
+		}()
+	}
+	return n
+}
+
+// notify queues an event for the webhooks subscribed to its kind
+func (n *webhookNotifier) notify(event *Event) {
+	if n == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if n.closed {
+		logger.Warningf("Dropped the %s event of %s, the repository is stopping", event.Kind, event.Path)
+		return
+	}
+	for _, w := range n.webhooks {
+		if !w.config.subscribed(event.Kind) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			logger.Warningf("Dropped the %s event of %s, the queue of webhook [%s] is full", event.Kind, event.Path, w.config.URL)
+		}
+	}
+}
+
+// close delivers the queued events and stops the notifier
+func (n *webhookNotifier) close() {
+	if n == nil {
+		return
+	}
+	n.lock.Lock()
+	n.closed = true
+	for _, w := range n.webhooks {
+		close(w.events)
+	}
+	n.lock.Unlock()
+	n.wg.Wait()
+}
+
+// deliver posts an event to the webhook, retrying with an exponential backoff
+func (w *webhook) deliver(event *Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logger.Errorf("Could not marshal the %s event of %s: %s", event.Kind, event.Path, err)
+		return
+	}
+	signature := SignWebhookPayload(w.config.Secret, payload)
+	interval := webhookRetryInterval
+	for attempt := 1; ; attempt++ {
+		err = w.post(event.Kind, payload, signature)
+		if err == nil {
+			logger.Debugf("Posted the %s event of %s to webhook [%s]", event.Kind, event.Path, w.config.URL)
+			return
+		}
+		if attempt >= w.config.MaxAttempts {
+			break
+		}
+		logger.Debugf("Attempt %d to post the %s event of %s to webhook [%s] failed: %s", attempt, event.Kind, event.Path, w.config.URL, err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+	logger.Errorf("Could not post the %s event of %s to webhook [%s] after %d attempts: %s",
+		event.Kind, event.Path, w.config.URL, w.config.MaxAttempts, err)
+}
+
+func (w *webhook) post(kind string, payload []byte, signature string) error {
+	req, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, kind)
+	req.Header.Set(WebhookSignatureHeader, signature)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// isBlockfile tells if an object of the repository is an archived blockfile, whose upload and
+// deletion are posted to the webhooks, rather than one of the records stored next to it
+func isBlockfile(name string) bool {
+	return isTierable(name) && !strings.HasSuffix(name, blockarchive.ManifestSuffix)
+}
+
+// notifyBlockfile posts an event about the object at its path if it is a blockfile
+func (fs *fileSystem) notifyBlockfile(event *Event) {
+	if fs.webhooks == nil || !isBlockfile(path.Base(event.Path)) {
+		return
+	}
+	event.Channel = channelOfPath(event.Path)
+	event.Org = fs.org
+	fs.webhooks.notify(event)
+}
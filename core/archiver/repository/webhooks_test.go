@@ -0,0 +1,150 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// webhookReceiver records the events posted to it, failing its first requests as many times as failures
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+
+	lock     sync.Mutex
+	requests int
+	events   []*Event
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requests++
+	if r.requests <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	payload, err := ioutil.ReadAll(req.Body)
+	require.NoError(r.t, err)
+	assert.True(r.t, VerifyWebhookPayload(r.secret, payload, req.Header.Get(WebhookSignatureHeader)))
+	event := &Event{}
+	require.NoError(r.t, json.Unmarshal(payload, event))
+	assert.Equal(r.t, event.Kind, req.Header.Get(WebhookEventHeader))
+	r.events = append(r.events, event)
+}
+
+func TestWebhooks(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "repository")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	defer func(interval time.Duration) { webhookRetryInterval = interval }(webhookRetryInterval)
+	webhookRetryInterval = time.Millisecond
+
+	all := &webhookReceiver{t: t, secret: "secret1", failures: 1}
+	allServer := httptest.NewServer(all)
+	defer allServer.Close()
+	failures := &webhookReceiver{t: t, secret: "secret2"}
+	failuresServer := httptest.NewServer(failures)
+	defer failuresServer.Close()
+
+	config := &Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       filepath.Join(testDir, "root"),
+		DataDir:       filepath.Join(testDir, "data"),
+		Users:         []User{{Name: "org1", Password: "pw1", Org: "Org1MSP"}},
+		Webhooks: []WebhookConfig{
+			{URL: allServer.URL, Secret: "secret1"},
+			{URL: failuresServer.URL, Secret: "secret2", Events: []string{EventIntegrityFailure}},
+		},
+	}
+	require.NoError(t, os.MkdirAll(config.RootDir, 0755))
+	server, err := NewServer(config)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+
+	content := []byte("blockfile content")
+	checksum, err := blockarchive.ComputeChecksum(bytes.NewReader(content), blockarchive.ChecksumSHA256)
+	require.NoError(t, err)
+	path := "/blkstore/chains/ch1/blockfile_000000"
+	require.NoError(t, upload(t, server, "org1", "pw1", path+blockarchive.ChecksumSuffix, []byte(checksum.String())))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", []byte("corrupted content")))
+	assert.Error(t, rename(t, server, "org1", "pw1", path+".uploading", path))
+	require.NoError(t, upload(t, server, "org1", "pw1", path+".uploading", content))
+	require.NoError(t, rename(t, server, "org1", "pw1", path+".uploading", path))
+	require.NoError(t, upload(t, server, "org1", "pw1", "/blkstore/chains/ch1/blockfile_000001", make([]byte, 10)))
+
+	sshConn, err := ssh.Dial("tcp", server.Addr().String(), &ssh.ClientConfig{
+		User:            "org1",
+		Auth:            []ssh.AuthMethod{ssh.Password("pw1")},
+		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error { return nil },
+	})
+	require.NoError(t, err)
+	client, err := sftp.NewClient(sshConn)
+	require.NoError(t, err)
+	require.NoError(t, client.Remove("/blkstore/chains/ch1/blockfile_000001"))
+	client.Close()
+	sshConn.Close()
+
+	// The queued events are delivered when the server stops, the first one after a retry
+	server.Stop()
+	kinds := []string{}
+	for _, event := range all.events {
+		kinds = append(kinds, event.Kind)
+		assert.Equal(t, "ch1", event.Channel)
+		assert.Equal(t, "Org1MSP", event.Org)
+		assert.False(t, event.Time.IsZero())
+	}
+	assert.Equal(t, []string{EventIntegrityFailure, EventUpload, EventUpload, EventDelete}, kinds)
+	assert.Equal(t, path, all.events[0].Path)
+	assert.NotEmpty(t, all.events[0].Error)
+	assert.Equal(t, int64(len(content)), all.events[1].Size)
+	assert.Equal(t, "/blkstore/chains/ch1/blockfile_000001", all.events[2].Path)
+	assert.Equal(t, int64(10), all.events[2].Size)
+	assert.Equal(t, 5, all.requests)
+
+	require.Len(t, failures.events, 1)
+	assert.Equal(t, EventIntegrityFailure, failures.events[0].Kind)
+}
+
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"kind":"upload"}`)
+	signature := SignWebhookPayload("secret", payload)
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, VerifyWebhookPayload("secret", payload, signature))
+	assert.False(t, VerifyWebhookPayload("other", payload, signature))
+	assert.False(t, VerifyWebhookPayload("secret", []byte(`{"kind":"delete"}`), signature))
+}
+
+func TestWebhookConfigValidation(t *testing.T) {
+	config := &WebhookConfig{URL: "https://cmdb.example.com/hooks", Secret: "s"}
+	require.NoError(t, config.validate())
+	assert.Equal(t, defaultWebhookTimeout, config.Timeout)
+	assert.Equal(t, defaultWebhookMaxAttempts, config.MaxAttempts)
+
+	assert.Error(t, (&WebhookConfig{URL: "cmdb.example.com", Secret: "s"}).validate())
+	assert.Error(t, (&WebhookConfig{URL: "https://cmdb.example.com/hooks"}).validate())
+	assert.Error(t, (&WebhookConfig{URL: "https://cmdb.example.com/hooks", Secret: "s", Events: []string{"rename"}}).validate())
+	assert.Error(t, (&WebhookConfig{URL: "https://cmdb.example.com/hooks", Secret: "s", MaxAttempts: -1}).validate())
+	assert.Error(t, (&Config{RootDir: "/root", DataDir: "/data", Users: []User{{Name: "u"}},
+		Webhooks: []WebhookConfig{{URL: "ftp://cmdb.example.com", Secret: "s"}}}).validate())
+}
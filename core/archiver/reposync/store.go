@@ -0,0 +1,184 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package reposync
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Store is a repository of archived blockfiles, or a local directory holding a copy of one.
+// All the paths are relative to the root of the store and use slashes as separators.
+type Store interface {
+	// Walk calls fn for every file of the store
+	Walk(fn func(path string) error) error
+	// Open opens a file for reading
+	Open(path string) (io.ReadCloser, error)
+	// Create creates or truncates a file, creating its parent directories if necessary
+	Create(path string) (io.WriteCloser, error)
+	// Rename renames a file, replacing the target if it exists
+	Rename(oldPath, newPath string) error
+	// Remove removes a file
+	Remove(path string) error
+	// Close releases the resources of the store
+	Close() error
+}
+
+// OpenStore opens the store described by spec, which is either
+// sftp://<user>:<password>@<host>:<port>/<dir> for a repository, or the path of a local directory
+func OpenStore(spec string) (Store, error) {
+	if !strings.HasPrefix(spec, "sftp://") {
+		info, err := os.Stat(spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error opening local store %s", spec)
+		}
+		if !info.IsDir() {
+			return nil, errors.Errorf("local store %s is not a directory", spec)
+		}
+		return &localStore{rootDir: spec}, nil
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid repository URL %s", spec)
+	}
+	password, _ := u.User.Password()
+	config := &ssh.ClientConfig{
+		User: u.User.Username(),
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+		Auth: []ssh.AuthMethod{ssh.Password(password)},
+	}
+	sshConn, err := ssh.Dial("tcp", u.Host, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "repository %s is unreachable", u.Host)
+	}
+	client, err := sftp.NewClient(sshConn)
+	if err != nil {
+		sshConn.Close()
+		return nil, errors.Wrapf(err, "error opening SFTP session to %s", u.Host)
+	}
+	rootDir := u.Path
+	if rootDir == "" {
+		rootDir = "/"
+	}
+	return &sftpStore{rootDir: rootDir, sshConn: sshConn, client: client}, nil
+}
+
+type localStore struct {
+	rootDir string
+}
+
+func (s *localStore) localPath(p string) string {
+	return filepath.Join(s.rootDir, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (s *localStore) Walk(fn func(path string) error) error {
+	return filepath.Walk(s.rootDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.rootDir, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel))
+	})
+}
+
+func (s *localStore) Open(p string) (io.ReadCloser, error) {
+	return os.Open(s.localPath(p))
+}
+
+func (s *localStore) Create(p string) (io.WriteCloser, error) {
+	localPath := s.localPath(p)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(localPath)
+}
+
+func (s *localStore) Rename(oldPath, newPath string) error {
+	return os.Rename(s.localPath(oldPath), s.localPath(newPath))
+}
+
+func (s *localStore) Remove(p string) error {
+	return os.Remove(s.localPath(p))
+}
+
+func (s *localStore) Close() error {
+	return nil
+}
+
+type sftpStore struct {
+	rootDir string
+	sshConn *ssh.Client
+	client  *sftp.Client
+}
+
+func (s *sftpStore) remotePath(p string) string {
+	return path.Join(s.rootDir, path.Clean("/"+p))
+}
+
+func (s *sftpStore) Walk(fn func(path string) error) error {
+	walker := s.client.Walk(s.rootDir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), s.rootDir), "/")
+		if err := fn(rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sftpStore) Open(p string) (io.ReadCloser, error) {
+	return s.client.Open(s.remotePath(p))
+}
+
+func (s *sftpStore) Create(p string) (io.WriteCloser, error) {
+	remotePath := s.remotePath(p)
+	if err := s.client.MkdirAll(path.Dir(remotePath)); err != nil {
+		return nil, err
+	}
+	return s.client.Create(remotePath)
+}
+
+func (s *sftpStore) Rename(oldPath, newPath string) error {
+	// SFTP does not replace an existing target
+	if _, err := s.client.Stat(s.remotePath(newPath)); err == nil {
+		if err := s.client.Remove(s.remotePath(newPath)); err != nil {
+			return err
+		}
+	}
+	return s.client.Rename(s.remotePath(oldPath), s.remotePath(newPath))
+}
+
+func (s *sftpStore) Remove(p string) error {
+	return s.client.Remove(s.remotePath(p))
+}
+
+func (s *sftpStore) Close() error {
+	s.client.Close()
+	return s.sshConn.Close()
+}
@@ -0,0 +1,272 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+// Package reposync replicates archived blockfiles between repositories and local directories.
+// The blockfiles are described by the archive manifests stored next to them, which provide
+// their channel, block range and digest.
+package reposync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("archiver.sync")
+
+// partialSuffix is appended to the path of a blockfile while it is being copied
+const partialSuffix = ".part"
+
+// Filter selects the archived blockfiles to replicate
+type Filter struct {
+	// Channels to replicate. All the channels are replicated when empty.
+	Channels []string
+	// ExcludeChannels are not replicated
+	ExcludeChannels []string
+	// FromBlock and ToBlock delimit the blocks to replicate. The blockfiles
+	// which contain at least one block of the range are replicated.
+	FromBlock uint64
+	ToBlock   uint64
+}
+
+// NewFilter returns a filter which selects all the archived blockfiles
+func NewFilter() *Filter {
+	return &Filter{ToBlock: math.MaxUint64}
+}
+
+func (f *Filter) matches(manifest *archive.ArchiveManifest) bool {
+	if len(f.Channels) > 0 && !contains(f.Channels, manifest.ChannelID) {
+		return false
+	}
+	if contains(f.ExcludeChannels, manifest.ChannelID) {
+		return false
+	}
+	return manifest.LastBlockNum >= f.FromBlock && manifest.FirstBlockNum <= f.ToBlock
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Report summarizes a replication
+type Report struct {
+	// Copied is the number of blockfiles copied to the destination
+	Copied int
+	// UpToDate is the number of blockfiles which were already on the destination
+	UpToDate int
+	// Filtered is the number of blockfiles excluded by the filter
+	Filtered int
+	// Failed are the paths of the blockfiles which could not be replicated
+	Failed []string
+}
+
+// Syncer replicates the archived blockfiles of a source store to a destination store
+type Syncer struct {
+	Source      Store
+	Destination Store
+	Filter      *Filter
+	// DryRun only reports what would be replicated
+	DryRun bool
+}
+
+// Sync replicates the archived blockfiles selected by the filter along with their manifests.
+// The digest of each blockfile is verified against its manifest both when it is read from the
+// source and once it has been written to the destination.
+func (s *Syncer) Sync() (*Report, error) {
+	var manifestPaths []string
+	err := s.Source.Walk(func(path string) error {
+		if strings.HasSuffix(path, blockarchive.ManifestSuffix) {
+			manifestPaths = append(manifestPaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing the source")
+	}
+
+	report := &Report{}
+	for _, manifestPath := range manifestPaths {
+		blockfilePath := strings.TrimSuffix(manifestPath, blockarchive.ManifestSuffix)
+		manifestBytes, manifest, err := s.readManifest(manifestPath)
+		if err != nil {
+			logger.Errorf("Skipping %s: %s", blockfilePath, err)
+			report.Failed = append(report.Failed, blockfilePath)
+			continue
+		}
+		if !s.Filter.matches(manifest) {
+			report.Filtered++
+			continue
+		}
+
+		if s.isUpToDate(blockfilePath, manifest) {
+			logger.Debugf("%s is up to date", blockfilePath)
+			report.UpToDate++
+			continue
+		}
+		if s.DryRun {
+			logger.Infof("Would copy %s (channel [%s], blocks [%d-%d])", blockfilePath,
+				manifest.ChannelID, manifest.FirstBlockNum, manifest.LastBlockNum)
+			report.Copied++
+			continue
+		}
+		if err := s.copyBlockfile(blockfilePath, manifestPath, manifestBytes, manifest); err != nil {
+			logger.Errorf("Failed to copy %s: %s", blockfilePath, err)
+			report.Failed = append(report.Failed, blockfilePath)
+			continue
+		}
+		logger.Infof("Copied %s (channel [%s], blocks [%d-%d])", blockfilePath,
+			manifest.ChannelID, manifest.FirstBlockNum, manifest.LastBlockNum)
+		report.Copied++
+	}
+	return report, nil
+}
+
+// readManifest reads a signed manifest from the source
+func (s *Syncer) readManifest(manifestPath string) ([]byte, *archive.ArchiveManifest, error) {
+	reader, err := s.Source.Open(manifestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+	manifestBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	signed := &archive.SignedArchiveManifest{}
+	if err := proto.Unmarshal(manifestBytes, signed); err != nil {
+		return nil, nil, errors.Wrap(err, "error unmarshaling signed manifest")
+	}
+	manifest := &archive.ArchiveManifest{}
+	if err := proto.Unmarshal(signed.Manifest, manifest); err != nil {
+		return nil, nil, errors.Wrap(err, "error unmarshaling manifest")
+	}
+	return manifestBytes, manifest, nil
+}
+
+// isUpToDate returns whether the destination already holds the blockfile with the digest of the manifest
+func (s *Syncer) isUpToDate(blockfilePath string, manifest *archive.ArchiveManifest) bool {
+	hash, err := hashOf(s.Destination, blockfilePath)
+	return err == nil && bytes.Equal(hash, manifest.BlockfileHash)
+}
+
+// copyBlockfile copies a blockfile, its manifest and its summary to the destination
+func (s *Syncer) copyBlockfile(blockfilePath, manifestPath string, manifestBytes []byte, manifest *archive.ArchiveManifest) error {
+	reader, err := s.Source.Open(blockfilePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	partialPath := blockfilePath + partialSuffix
+	writer, err := s.Destination.Create(partialPath)
+	if err != nil {
+		return err
+	}
+	// The digest of the manifest is the one of the blockfile once decoded when it is stored encoded
+	h := sha256.New()
+	decoder := blockarchive.NewDecodingWriter(h)
+	_, err = io.Copy(writer, io.TeeReader(reader, decoder))
+	if closeErr := decoder.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && !bytes.Equal(h.Sum(nil), manifest.BlockfileHash) {
+		err = errors.Errorf("digest of the source [%x] does not match the manifest [%x]", h.Sum(nil), manifest.BlockfileHash)
+	}
+	if err == nil {
+		err = s.verifyDestination(partialPath, manifest)
+	}
+	if err != nil {
+		s.Destination.Remove(partialPath)
+		return err
+	}
+	if err := s.Destination.Rename(partialPath, blockfilePath); err != nil {
+		return err
+	}
+
+	if err := s.writeFile(manifestPath, manifestBytes); err != nil {
+		return err
+	}
+	return s.copySummary(blockfilePath, manifest)
+}
+
+// copySummary copies the summary of a blockfile to the destination once it has been checked
+// against the manifest. The blockfiles archived before the summaries were introduced have none.
+func (s *Syncer) copySummary(blockfilePath string, manifest *archive.ArchiveManifest) error {
+	summaryPath := blockfilePath + blockarchive.SummarySuffix
+	reader, err := s.Source.Open(summaryPath)
+	if err != nil {
+		logger.Debugf("No summary of %s: %s", blockfilePath, err)
+		return nil
+	}
+	defer reader.Close()
+	summaryBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	summary := &archive.BlockfileSummary{}
+	if err := proto.Unmarshal(summaryBytes, summary); err != nil {
+		return errors.Wrap(err, "error unmarshaling blockfile summary")
+	}
+	if err := blockarchive.VerifySummaryAgainstManifest(summary, manifest); err != nil {
+		return errors.WithMessage(err, "invalid blockfile summary")
+	}
+	return s.writeFile(summaryPath, summaryBytes)
+}
+
+// writeFile writes a small file on the destination
+func (s *Syncer) writeFile(path string, content []byte) error {
+	writer, err := s.Destination.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(content)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// verifyDestination reads back a copied blockfile and checks its digest
+func (s *Syncer) verifyDestination(path string, manifest *archive.ArchiveManifest) error {
+	hash, err := hashOf(s.Destination, path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, manifest.BlockfileHash) {
+		return errors.Errorf("digest of the copy [%x] does not match the manifest [%x]", hash, manifest.BlockfileHash)
+	}
+	return nil
+}
+
+// hashOf returns the digest of the blockfile at the path of the store, once decoded if it is stored encoded
+func hashOf(store Store, path string) ([]byte, error) {
+	reader, err := store.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	content, _, err := blockarchive.NewBlockfileReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return blockarchive.ComputeHash(content)
+}
@@ -0,0 +1,221 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+)
+
+// RestorePath is the path of the operations endpoint which starts the restores of archived blocks,
+// and which is followed by /<id> to monitor a restore
+const RestorePath = "/archiver/restore"
+
+// maxFinishedRestoreJobs is the number of finished restores which are kept for monitoring
+const maxFinishedRestoreJobs = 100
+
+// Statuses of a restore job
+const (
+	RestoreRunning   = "running"
+	RestoreSucceeded = "succeeded"
+	RestoreFailed    = "failed"
+)
+
+// RestoreRequest is the body of POST /archiver/restore
+type RestoreRequest struct {
+	Channel string `json:"channel"`
+	From    uint64 `json:"from"`
+	To      uint64 `json:"to"`
+	// TTL is how long the restored blockfiles are kept, e.g. "24h", ledger.blockArchiver.restoreTTL if empty
+	TTL string `json:"ttl,omitempty"`
+}
+
+// RestoreJob is the state of a restore returned by the restore endpoints
+type RestoreJob struct {
+	ID          string     `json:"id"`
+	Channel     string     `json:"channel"`
+	From        uint64     `json:"from"`
+	To          uint64     `json:"to"`
+	TTL         string     `json:"ttl,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+
+	// Blockfiles is the number of discarded blockfiles of the range to restore, and RestoredBlockfiles
+	// the number of them restored so far, in the order of their blocks
+	Blockfiles         int `json:"blockfiles"`
+	RestoredBlockfiles int `json:"restoredBlockfiles"`
+}
+
+// RestoreHandler serves the restores of archived blocks on the operations endpoint, so that they can be
+// automated by the tools operating the peer. A restore runs in the background:
+//
+//	POST /archiver/restore with a RestoreRequest starts a restore and returns its RestoreJob
+//	GET /archiver/restore/<id> returns the RestoreJob of a restore
+//	GET /archiver/restore returns the RestoreJobs of the running and recent restores
+//
+// The requests must be signed by an administrator of the peer. A channel is restored by one restore at a time.
+type RestoreHandler struct {
+	// GetLedger returns the ledger of a channel, nil if the peer has not joined the channel
+	GetLedger func(channelID string) ledger.PeerLedger
+	// Admins authorizes the requests, which are all denied if it is nil
+	Admins AccessControlEvaluator
+
+	mutex    sync.Mutex
+	nextID   int
+	jobs     map[string]*RestoreJob
+	finished []string
+}
+
+// NewRestoreHandler creates a RestoreHandler serving the requests of the administrators accepted by admins
+func NewRestoreHandler(getLedger func(channelID string) ledger.PeerLedger, admins AccessControlEvaluator) *RestoreHandler {
+	return &RestoreHandler{GetLedger: getLedger, Admins: admins, jobs: make(map[string]*RestoreJob)}
+}
+
+// ServeHTTP serves the restore endpoints
+func (h *RestoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	creator, err := authorizeAdminHTTP(r, h.Admins)
+	if err != nil {
+		replyUnauthorized(w, err)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, RestorePath), "/")
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		h.startRestore(w, r, requesterOf(creator))
+	case r.Method == http.MethodGet && id == "":
+		h.mutex.Lock()
+		jobs := make([]RestoreJob, 0, len(h.jobs))
+		for i := 1; i <= h.nextID; i++ {
+			if job, ok := h.jobs[fmt.Sprint(i)]; ok {
+				jobs = append(jobs, *job)
+			}
+		}
+		h.mutex.Unlock()
+		writeJSON(w, http.StatusOK, jobs)
+	case r.Method == http.MethodGet:
+		h.mutex.Lock()
+		job, ok := h.jobs[id]
+		var state RestoreJob
+		if ok {
+			state = *job
+		}
+		h.mutex.Unlock()
+		if !ok {
+			http.Error(w, "restore "+id+" not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startRestore validates a restore request and starts the restore in the background, unless the channel is
+// being restored
+func (h *RestoreHandler) startRestore(w http.ResponseWriter, r *http.Request, requester string) {
+	req := &RestoreRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "invalid restore request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Channel == "" {
+		http.Error(w, "the channel is missing", http.StatusBadRequest)
+		return
+	}
+	if req.From > req.To {
+		http.Error(w, fmt.Sprintf("invalid block range [%d-%d]", req.From, req.To), http.StatusBadRequest)
+		return
+	}
+	ttl := ledgerconfig.GetRestoreTTL()
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl < 0 {
+			http.Error(w, "invalid ttl "+req.TTL, http.StatusBadRequest)
+			return
+		}
+	}
+	l := h.GetLedger(req.Channel)
+	if l == nil {
+		http.Error(w, "channel "+req.Channel+" not found", http.StatusNotFound)
+		return
+	}
+
+	h.mutex.Lock()
+	for _, running := range h.jobs {
+		if running.Channel == req.Channel && running.Status == RestoreRunning {
+			h.mutex.Unlock()
+			http.Error(w, fmt.Sprintf("restore %s of channel %s is running", running.ID, req.Channel), http.StatusConflict)
+			return
+		}
+	}
+	h.nextID++
+	job := &RestoreJob{
+		ID:        fmt.Sprint(h.nextID),
+		Channel:   req.Channel,
+		From:      req.From,
+		To:        req.To,
+		TTL:       req.TTL,
+		Status:    RestoreRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	h.jobs[job.ID] = job
+	state := *job
+	h.mutex.Unlock()
+
+	loggerArchive.Infof("[%s] Restoring archived blocks [%d-%d], restore %s requested by %s", req.Channel, req.From, req.To, job.ID, requester)
+	go h.runRestore(l, job, ttl)
+	w.Header().Set("Location", RestorePath+"/"+job.ID)
+	writeJSON(w, http.StatusAccepted, state)
+}
+
+// runRestore restores the blocks of a job, tracking its progress, and records its outcome
+func (h *RestoreHandler) runRestore(l ledger.PeerLedger, job *RestoreJob, ttl time.Duration) {
+	err := l.RestoreRange(job.From, job.To, ttl, func(restored, total int) {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		job.RestoredBlockfiles, job.Blockfiles = restored, total
+	})
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	completedAt := time.Now().UTC()
+	job.CompletedAt = &completedAt
+	if err != nil {
+		job.Status, job.Error = RestoreFailed, err.Error()
+		loggerArchive.Errorf("[%s] Failed to restore archived blocks [%d-%d], restore %s: %s", job.Channel, job.From, job.To, job.ID, err)
+	} else {
+		job.Status = RestoreSucceeded
+		loggerArchive.Infof("[%s] Restored archived blocks [%d-%d], restore %s", job.Channel, job.From, job.To, job.ID)
+	}
+	outcome := job.Status
+	if job.Error != "" {
+		outcome += ": " + job.Error
+	}
+	blockarchive.RaiseAlert(blockarchive.AlertRestoreCompleted, job.Channel, "restore %s of blocks [%d-%d] %s", job.ID, job.From, job.To, outcome)
+	h.finished = append(h.finished, job.ID)
+	if len(h.finished) > maxFinishedRestoreJobs {
+		delete(h.jobs, h.finished[0])
+		h.finished = h.finished[1:]
+	}
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		loggerArchive.Warningf("Failed to write the response: %s", err)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	archivertest "github.com/hyperledger/fabric/core/archiver/testutil"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restoringLedger restores the ranges of blocks with restore
+type restoringLedger struct {
+	ledger.PeerLedger
+	restore func(from, to uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error
+}
+
+func (l *restoringLedger) RestoreRange(from, to uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error {
+	return l.restore(from, to, ttl, progress)
+}
+
+// fakeAdmins accepts the signers of the requests as administrators unless they are rejected
+type fakeAdmins struct{ rejected bool }
+
+func (a *fakeAdmins) Evaluate(signatureSet []*protoutil.SignedData) error {
+	if a.rejected {
+		return errors.New("not an administrator")
+	}
+	return nil
+}
+
+// restoreClient sends the signed requests of an administrator to a RestoreHandler
+type restoreClient struct {
+	t       *testing.T
+	handler *RestoreHandler
+}
+
+func (c *restoreClient) send(method, url, body string, signed bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if signed {
+		require.NoError(c.t, SignHTTPRequest(req, "testLedger", fakeSigner{}))
+	}
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// start starts a restore and returns its job
+func (c *restoreClient) start(body string) *RestoreJob {
+	rec := c.send(http.MethodPost, RestorePath, body, true)
+	require.Equal(c.t, http.StatusAccepted, rec.Code, rec.Body.String())
+	job := &RestoreJob{}
+	require.NoError(c.t, json.Unmarshal(rec.Body.Bytes(), job))
+	assert.Equal(c.t, RestorePath+"/"+job.ID, rec.Header().Get("Location"))
+	return job
+}
+
+// wait waits for a restore to complete and returns its job
+func (c *restoreClient) wait(id string) *RestoreJob {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := c.send(http.MethodGet, RestorePath+"/"+id, "", true)
+		require.Equal(c.t, http.StatusOK, rec.Code, rec.Body.String())
+		job := &RestoreJob{}
+		require.NoError(c.t, json.Unmarshal(rec.Body.Bytes(), job))
+		if job.Status != RestoreRunning {
+			return job
+		}
+		require.True(c.t, time.Now().Before(deadline), "restore %s still running", id)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestoreHandler(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 50)
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+	h, err := archivertest.NewHarness(archivertest.HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	info1, err := h.WaitForArchived(store, 1, true, 10*time.Second)
+	require.NoError(t, err)
+	info2, err := h.WaitForArchived(store, 2, true, 10*time.Second)
+	require.NoError(t, err)
+
+	handler := NewRestoreHandler(func(channelID string) ledger.PeerLedger {
+		if channelID != "testLedger" {
+			return nil
+		}
+		return &restoringLedger{restore: store.RestoreRange}
+	}, &fakeAdmins{})
+	client := &restoreClient{t: t, handler: handler}
+
+	// The discarded blockfile of the range is restored onto the local file system
+	job := client.start(`{"channel": "testLedger", "from": 12, "to": 15}`)
+	assert.Equal(t, "testLedger", job.Channel)
+	job = client.wait(job.ID)
+	require.Equal(t, RestoreSucceeded, job.Status, job.Error)
+	assert.Equal(t, 1, job.Blockfiles)
+	assert.Equal(t, 1, job.RestoredBlockfiles)
+	assert.NotNil(t, job.CompletedAt)
+	_, err = os.Stat(h.BlockfilePath("testLedger", int(info1.BlockfileNo)))
+	assert.NoError(t, err)
+
+	// The restore of a blockfile missing from the archive fails
+	require.NoError(t, h.Repository.Remove(info2.Location))
+	job = client.wait(client.start(fmt.Sprintf(`{"channel": "testLedger", "from": %d, "to": %d}`, info2.FirstBlockNum, info2.FirstBlockNum)).ID)
+	assert.Equal(t, RestoreFailed, job.Status)
+	assert.NotEmpty(t, job.Error)
+	assert.Equal(t, 0, job.RestoredBlockfiles)
+	_, err = os.Stat(h.BlockfilePath("testLedger", int(info2.BlockfileNo)))
+	assert.True(t, os.IsNotExist(err))
+
+	// Both restores are listed
+	rec := client.send(http.MethodGet, RestorePath, "", true)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var jobs []RestoreJob
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jobs))
+	assert.Len(t, jobs, 2)
+}
+
+func TestRestoreHandlerRequests(t *testing.T) {
+	release := make(chan struct{})
+	restored := make(chan uint64, 2)
+	admins := &fakeAdmins{}
+	handler := NewRestoreHandler(func(channelID string) ledger.PeerLedger {
+		if channelID != "testLedger" && channelID != "otherLedger" {
+			return nil
+		}
+		return &restoringLedger{restore: func(from, to uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error {
+			<-release
+			restored <- from
+			return nil
+		}}
+	}, admins)
+	client := &restoreClient{t: t, handler: handler}
+
+	// A channel is restored by one restore at a time, while the other channels can be restored
+	first := client.start(`{"channel": "testLedger", "from": 0, "to": 5}`)
+	rec := client.send(http.MethodPost, RestorePath, `{"channel": "testLedger", "from": 10, "to": 20}`, true)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "restore "+first.ID+" of channel testLedger is running")
+	other := client.start(`{"channel": "otherLedger", "from": 0, "to": 5}`)
+	close(release)
+	assert.Equal(t, RestoreSucceeded, client.wait(first.ID).Status)
+	assert.Equal(t, RestoreSucceeded, client.wait(other.ID).Status)
+	assert.Equal(t, uint64(0), <-restored)
+	assert.Equal(t, uint64(0), <-restored)
+	// Once it has completed, the channel can be restored again
+	assert.Equal(t, RestoreSucceeded, client.wait(client.start(`{"channel": "testLedger", "from": 10, "to": 20}`).ID).Status)
+	assert.Equal(t, uint64(10), <-restored)
+
+	// The bad requests are rejected
+	for _, test := range []struct {
+		body   string
+		status int
+	}{
+		{`{"from": 0, "to": 5}`, http.StatusBadRequest},
+		{`{"channel": "unknownLedger", "from": 0, "to": 5}`, http.StatusNotFound},
+		{`{"channel": "testLedger", "from": 5, "to": 0}`, http.StatusBadRequest},
+		{`{"channel": "testLedger", "from": 0, "to": 5, "ttl": "1 day"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	} {
+		assert.Equal(t, test.status, client.send(http.MethodPost, RestorePath, test.body, true).Code, test.body)
+	}
+	assert.Equal(t, http.StatusNotFound, client.send(http.MethodGet, RestorePath+"/100", "", true).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, client.send(http.MethodDelete, RestorePath+"/1", "", true).Code)
+
+	// The requests are only served to the administrators
+	body := `{"channel": "testLedger", "from": 0, "to": 5}`
+	rec = client.send(http.MethodPost, RestorePath, body, false)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, blockarchive.RequestAuthorizationHeader, rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, client.send(http.MethodGet, RestorePath+"/1", "", false).Code)
+	admins.rejected = true
+	assert.Equal(t, http.StatusForbidden, client.send(http.MethodPost, RestorePath, body, true).Code)
+	assert.Equal(t, http.StatusForbidden, client.send(http.MethodGet, RestorePath, "", true).Code)
+	admins.rejected = false
+	handler.Admins = nil
+	assert.Equal(t, http.StatusForbidden, client.send(http.MethodPost, RestorePath, body, true).Code)
+	select {
+	case from := <-restored:
+		t.Fatalf("unexpected restore from block [%d]", from)
+	default:
+	}
+}
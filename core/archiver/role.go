@@ -0,0 +1,268 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// archiverRoleFile is the file, in the ledgers data directory, which records the archiver role of the
+// peer once it has been handed off. It overrides peer.archiver.enabled and peer.archiving.enabled,
+// so that the role survives the restarts of the peer.
+const archiverRoleFile = "archiverRole.json"
+
+// archiverRole is the content of archiverRoleFile
+type archiverRole struct {
+	Archiver bool `json:"archiver"`
+	// Address of the peer the role was handed off to or taken over from
+	Peer string    `json:"peer"`
+	Time time.Time `json:"time"`
+}
+
+func archiverRolePath() string {
+	return filepath.Join(ledgerconfig.GetRootPath(), archiverRoleFile)
+}
+
+// loadArchiverRole returns the archiver role recorded by the last handoff, nil if none
+func loadArchiverRole() (*archiverRole, error) {
+	b, err := ioutil.ReadFile(archiverRolePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the archiver role")
+	}
+	role := &archiverRole{}
+	if err := json.Unmarshal(b, role); err != nil {
+		return nil, errors.Wrapf(err, "error parsing the archiver role file %s", archiverRolePath())
+	}
+	return role, nil
+}
+
+// saveArchiverRole replaces the archiver role file atomically
+func saveArchiverRole(role *archiverRole) error {
+	b, err := json.Marshal(role)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the archiver role")
+	}
+	path := archiverRolePath()
+	if err := ioutil.WriteFile(path+".tmp", b, 0600); err != nil {
+		return errors.Wrap(err, "error writing the archiver role")
+	}
+	return errors.Wrap(os.Rename(path+".tmp", path), "error writing the archiver role")
+}
+
+// initArchiverRole sets the parameters of the archiver or client role of the peer
+func initArchiverRole(isArchiver, isClient bool) {
+	blockarchive.IsArchiver = isArchiver
+	blockarchive.IsClient = false
+	blockarchive.IsSidecarArchiving = isArchiver && ledgerconfig.IsSidecarArchiving()
+	if isArchiver {
+		blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = ledgerconfig.GetArchivingParameters()
+		// The archive manifests are signed by the local MSP identity of the archiver peer
+		blockarchive.ManifestSigner = mspmgmt.GetLocalSigningIdentityOrPanic()
+		// The archiver peer reads the repository itself
+		blockarchive.ProxyEndpoint, blockarchive.FetchBlockfile = "", nil
+		blockarchive.RetrievalOrder, blockarchive.RetrievalTimeouts = nil, nil
+		blockarchive.RetrievalRetries, blockarchive.RetrievalBreakerThreshold = 0, 0
+	} else {
+		blockarchive.IsClient = isClient
+		if isClient {
+			initFetchThroughParams()
+		}
+	}
+}
+
+// ArchiverRoleService hands the archiver role off between the peers of an organization without
+// restarting them. The administrator asks the new archiver peer to acquire the role: it stops the
+// archiving on the current archiver peer, verifies the archived blockfiles against the repository
+// and its own blockfiles, imports the archive catalog, and then both peers switch their roles.
+type ArchiverRoleService struct {
+	admins    AccessControlEvaluator
+	members   AccessControlEvaluator
+	dialOpts  func() []grpc.DialOption
+	signer    identity.SignerSerializer
+	advertise func()
+
+	lock sync.Mutex
+	// Whether the archiving is stopped for a handoff in progress
+	releasing bool
+}
+
+// NewArchiverRoleService creates an ArchiverRoleService. The role is acquired on the request of the
+// admins and released on the request of the members of the organization, i.e. the peer acquiring it.
+// advertise publishes the archive information of the channels again once the role has changed.
+func NewArchiverRoleService(admins, members AccessControlEvaluator, dialOpts func() []grpc.DialOption,
+	signer identity.SignerSerializer, advertise func()) *ArchiverRoleService {
+	return &ArchiverRoleService{admins: admins, members: members, dialOpts: dialOpts, signer: signer, advertise: advertise}
+}
+
+// AcquireRole takes the archiver role over from the peer of the request
+func (s *ArchiverRoleService) AcquireRole(ctx context.Context, env *common.Envelope) (*archive.ArchiverState, error) {
+	request := &archive.AcquireRoleRequest{}
+	if _, err := validateRequest(ctx, env, request, s.admins); err != nil {
+		return nil, err
+	}
+	if request.SourceAddress == "" {
+		return nil, status.Error(codes.InvalidArgument, "the address of the archiver peer is empty")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if blockarchive.IsArchiver {
+		return nil, status.Error(codes.FailedPrecondition, "the peer is already the archiver")
+	}
+	if !blockarchive.IsClient {
+		return nil, status.Error(codes.FailedPrecondition, "the archiving is not enabled on the peer")
+	}
+
+	conn, err := grpc.Dial(request.SourceAddress, s.dialOpts()...)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "error connecting to the archiver peer at %s: %s", request.SourceAddress, err)
+	}
+	defer conn.Close()
+	client := archive.NewArchiverRoleClient(conn)
+	loggerArchive.Infof("Taking the archiver role over from %s", request.SourceAddress)
+	// The archiving is resumed on the archiver peer whatever the context of the request
+	abort := func(cause error) error {
+		if _, err := s.releaseRole(context.Background(), client, archive.ReleaseRoleRequest_ABORT); err != nil {
+			loggerArchive.Errorf("Failed resuming the archiving on %s: %s", request.SourceAddress, err)
+		}
+		return cause
+	}
+	state, err := s.releaseRole(ctx, client, archive.ReleaseRoleRequest_PREPARE)
+	if err != nil {
+		return nil, abort(status.Errorf(codes.Unavailable, "error stopping the archiving on %s: %s", request.SourceAddress, err))
+	}
+	for _, channel := range state.Channels {
+		if err := fsblkstorage.VerifyArchiverState(channel); err != nil {
+			return nil, abort(status.Errorf(codes.FailedPrecondition, "inconsistent archive of channel %s: %s", channel.ChannelId, err))
+		}
+	}
+	for _, channel := range state.Channels {
+		if err := fsblkstorage.ImportArchiverState(channel); err != nil {
+			return nil, abort(status.Errorf(codes.Internal, "error importing the archive catalog of channel %s: %s", channel.ChannelId, err))
+		}
+	}
+	if _, err := s.releaseRole(ctx, client, archive.ReleaseRoleRequest_COMMIT); err != nil {
+		return nil, abort(status.Errorf(codes.Aborted, "error releasing the archiver role on %s: %s", request.SourceAddress, err))
+	}
+	if err := s.switchRole(true, request.SourceAddress); err != nil {
+		loggerArchive.Errorf("Failed acquiring the archiver role, no peer is archiving: %s", err)
+		return nil, status.Errorf(codes.Internal, "error acquiring the archiver role: %s", err)
+	}
+	loggerArchive.Infof("Took the archiver role over from %s", request.SourceAddress)
+	return state, nil
+}
+
+// ReleaseRole goes through a step of the handoff of the archiver role to the requesting peer
+func (s *ArchiverRoleService) ReleaseRole(ctx context.Context, env *common.Envelope) (*archive.ArchiverState, error) {
+	request := &archive.ReleaseRoleRequest{}
+	if _, err := validateRequest(ctx, env, request, s.members); err != nil {
+		return nil, err
+	}
+	addr := util.ExtractRemoteAddress(ctx)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch request.Phase {
+	case archive.ReleaseRoleRequest_PREPARE:
+		if !blockarchive.IsArchiver {
+			return nil, status.Error(codes.FailedPrecondition, "the peer is not the archiver")
+		}
+		loggerArchive.Infof("Stopping the archiving to hand the archiver role off to %s", addr)
+		fsblkstorage.StopArchiving()
+		s.releasing = true
+		channels, err := fsblkstorage.ExportArchiverState()
+		if err != nil {
+			loggerArchive.Errorf("Failed exporting the archive catalog: %s", err)
+			return nil, status.Error(codes.Internal, "failed to export the archive catalog")
+		}
+		return &archive.ArchiverState{Channels: channels}, nil
+	case archive.ReleaseRoleRequest_COMMIT:
+		if !s.releasing {
+			return nil, status.Error(codes.FailedPrecondition, "no handoff of the archiver role is in progress")
+		}
+		if err := s.switchRole(false, addr); err != nil {
+			return nil, status.Errorf(codes.Internal, "error releasing the archiver role: %s", err)
+		}
+		s.releasing = false
+		loggerArchive.Infof("Handed the archiver role off to %s", addr)
+	case archive.ReleaseRoleRequest_ABORT:
+		if blockarchive.IsArchiver && !s.releasing {
+			return &archive.ArchiverState{}, nil
+		}
+		loggerArchive.Warningf("The handoff of the archiver role to %s failed, resuming the archiving", addr)
+		if err := s.switchRole(true, addr); err != nil {
+			return nil, status.Errorf(codes.Internal, "error resuming the archiving: %s", err)
+		}
+		s.releasing = false
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown phase %s", request.Phase)
+	}
+	return &archive.ArchiverState{}, nil
+}
+
+// releaseRole requests a step of the handoff to the archiver peer
+func (s *ArchiverRoleService) releaseRole(ctx context.Context, client archive.ArchiverRoleClient, phase archive.ReleaseRoleRequest_Phase) (*archive.ArchiverState, error) {
+	env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, "", s.signer, &archive.ReleaseRoleRequest{Phase: phase}, 0, 0)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error creating the request")
+	}
+	return client.ReleaseRole(ctx, env)
+}
+
+// switchRole records the new role of the peer, then starts the archiving if it becomes the archiver.
+// A peer which releases the role becomes a client peer of the repository.
+func (s *ArchiverRoleService) switchRole(isArchiver bool, peer string) error {
+	if err := saveArchiverRole(&archiverRole{Archiver: isArchiver, Peer: peer, Time: time.Now().UTC()}); err != nil {
+		return err
+	}
+	initArchiverRole(isArchiver, true)
+	if isArchiver {
+		if err := fsblkstorage.StartArchiving(); err != nil {
+			return err
+		}
+	}
+	s.advertise()
+	return nil
+}
+
+// roleFromConfig returns the archiver and client roles of the peer, from the configuration
+// unless the role has been handed off
+func roleFromConfig() (bool, bool) {
+	isArchiver := viper.GetBool("peer.archiver.enabled")
+	isClient := !isArchiver && viper.GetBool("peer.archiving.enabled")
+	role, err := loadArchiverRole()
+	if err != nil {
+		loggerArchive.Panicf("Invalid archiver role: %s", err)
+	}
+	if role != nil && role.Archiver != isArchiver {
+		loggerArchive.Warningf("The archiver role was handed off with %s on %s, archiver=%t overrides the configuration",
+			role.Peer, role.Time.Format(time.RFC3339), role.Archiver)
+		return role.Archiver, !role.Archiver
+	}
+	return isArchiver, isClient
+}
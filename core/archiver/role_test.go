@@ -0,0 +1,180 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	archivertest "github.com/hyperledger/fabric/core/archiver/testutil"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeRoleSource hands the archiver role off like an archiver peer, with a fixed state of the archiving
+type fakeRoleSource struct {
+	state *archive.ArchiverState
+
+	lock   sync.Mutex
+	phases []archive.ReleaseRoleRequest_Phase
+}
+
+func (s *fakeRoleSource) AcquireRole(ctx context.Context, env *common.Envelope) (*archive.ArchiverState, error) {
+	return nil, status.Error(codes.Unimplemented, "not implemented")
+}
+
+func (s *fakeRoleSource) ReleaseRole(ctx context.Context, env *common.Envelope) (*archive.ArchiverState, error) {
+	request := &archive.ReleaseRoleRequest{}
+	if _, err := protoutil.UnmarshalEnvelopeOfType(env, common.HeaderType_MESSAGE, request); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.phases = append(s.phases, request.Phase)
+	if request.Phase == archive.ReleaseRoleRequest_PREPARE {
+		return s.state, nil
+	}
+	return &archive.ArchiverState{}, nil
+}
+
+func (s *fakeRoleSource) requested() []archive.ReleaseRoleRequest_Phase {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	phases := s.phases
+	s.phases = nil
+	return phases
+}
+
+// signedRoleRequest returns a request of the role service signed by a peer of the organization
+func signedRoleRequest(t *testing.T, request proto.Message) *common.Envelope {
+	env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, "", fakeSigner{}, request, 0, 0)
+	require.NoError(t, err)
+	return env
+}
+
+func TestArchiverRoleService(t *testing.T) {
+	require.NoError(t, msptesttools.LoadMSPSetupForTesting())
+	blocks := testutil.ConstructTestBlocks(t, 50)
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+	h, err := archivertest.NewHarness(archivertest.HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	prevSidecar, prevSigner := blockarchive.IsSidecarArchiving, blockarchive.ManifestSigner
+	prevRetrievalOrder := blockarchive.RetrievalOrder
+	defer func() {
+		blockarchive.IsSidecarArchiving, blockarchive.ManifestSigner = prevSidecar, prevSigner
+		blockarchive.RetrievalOrder = prevRetrievalOrder
+	}()
+	defer viper.Reset()
+	viper.Set("peer.fileSystemPath", h.BlockStorePath)
+	viper.Set("peer.archiver.enabled", true)
+	viper.Set("peer.archiver.each", 1)
+	viper.Set("peer.archiver.keep", 1)
+	require.NoError(t, os.MkdirAll(ledgerconfig.GetRootPath(), 0755))
+
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	_, err = h.WaitForArchived(store, 1, true, 10*time.Second)
+	require.NoError(t, err)
+
+	admins, members := &fakeAdmins{}, &fakeAdmins{}
+	advertised := 0
+	dialOpts := func() []grpc.DialOption {
+		return []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5 * time.Second)}
+	}
+	service := NewArchiverRoleService(admins, members, dialOpts, fakeSigner{}, func() { advertised++ })
+	ctx := context.Background()
+	release := func(phase archive.ReleaseRoleRequest_Phase) (*archive.ArchiverState, error) {
+		return service.ReleaseRole(ctx, signedRoleRequest(t, &archive.ReleaseRoleRequest{Phase: phase}))
+	}
+
+	// The archiver peer stops the archiving and exports its state, then resumes it when the handoff is aborted
+	state, err := release(archive.ReleaseRoleRequest_PREPARE)
+	require.NoError(t, err)
+	require.Len(t, state.Channels, 1)
+	assert.Equal(t, "testLedger", state.Channels[0].ChannelId)
+	assert.NotEmpty(t, state.Channels[0].Blockfiles)
+	_, err = release(archive.ReleaseRoleRequest_ABORT)
+	require.NoError(t, err)
+	assert.True(t, blockarchive.IsArchiver)
+	assert.Equal(t, 1, advertised)
+
+	// The archiver peer becomes a client peer once the handoff is committed, which survives a restart
+	_, err = release(archive.ReleaseRoleRequest_PREPARE)
+	require.NoError(t, err)
+	_, err = release(archive.ReleaseRoleRequest_COMMIT)
+	require.NoError(t, err)
+	assert.False(t, blockarchive.IsArchiver)
+	assert.True(t, blockarchive.IsClient)
+	assert.Equal(t, 2, advertised)
+	isArchiver, isClient := roleFromConfig()
+	assert.False(t, isArchiver)
+	assert.True(t, isClient)
+	_, err = release(archive.ReleaseRoleRequest_COMMIT)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// The client peer takes the role over from the archiver peer, whose archive it verifies and imports
+	source := &fakeRoleSource{state: state}
+	address, stop := startServer(t, func(server *grpc.Server) { archive.RegisterArchiverRoleServer(server, source) })
+	defer stop()
+	acquired, err := service.AcquireRole(ctx, signedRoleRequest(t, &archive.AcquireRoleRequest{SourceAddress: address}))
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(state, acquired))
+	assert.Equal(t, []archive.ReleaseRoleRequest_Phase{archive.ReleaseRoleRequest_PREPARE, archive.ReleaseRoleRequest_COMMIT}, source.requested())
+	assert.True(t, blockarchive.IsArchiver)
+	assert.Equal(t, 3, advertised)
+	role, err := loadArchiverRole()
+	require.NoError(t, err)
+	assert.True(t, role.Archiver)
+	assert.Equal(t, address, role.Peer)
+	_, err = os.Stat(filepath.Join(ledgerconfig.GetRootPath(), archiverRoleFile))
+	assert.NoError(t, err)
+
+	// The invalid requests are rejected
+	_, err = service.AcquireRole(ctx, signedRoleRequest(t, &archive.AcquireRoleRequest{SourceAddress: address}))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "the peer is already the archiver")
+	_, err = service.AcquireRole(ctx, signedRoleRequest(t, &archive.AcquireRoleRequest{}))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = release(archive.ReleaseRoleRequest_Phase(100))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = service.AcquireRole(ctx, &common.Envelope{Payload: []byte("garbage")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The role is acquired on the request of an administrator and released on the one of a member
+	admins.rejected = true
+	_, err = service.AcquireRole(ctx, signedRoleRequest(t, &archive.AcquireRoleRequest{SourceAddress: address}))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	admins.rejected, members.rejected = false, true
+	_, err = release(archive.ReleaseRoleRequest_PREPARE)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.True(t, blockarchive.IsArchiver)
+	assert.Empty(t, source.requested())
+	assert.Equal(t, 3, advertised)
+}
@@ -0,0 +1,48 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SidecarService receives the reports of the peer-archiver sidecar, which archives the blockfiles of the
+// archiver peer out of process so that the uploads don't weigh on the peer. The sidecar never modifies the
+// ledgers data directory: the peer records the reported blockfiles and discards its local copies itself.
+type SidecarService struct {
+	ace AccessControlEvaluator
+}
+
+// NewSidecarService creates a SidecarService accepting the reports signed by the requesters accepted by ace
+func NewSidecarService(ace AccessControlEvaluator) *SidecarService {
+	return &SidecarService{ace: ace}
+}
+
+// ReportArchived records the blockfiles archived by the sidecar in the archive catalog of the channel of the request
+func (s *SidecarService) ReportArchived(ctx context.Context, env *common.Envelope) (*archive.ReportArchivedResponse, error) {
+	request := &archive.ReportArchivedRequest{}
+	ch, err := validateRequest(ctx, env, request, s.ace)
+	if err != nil {
+		return nil, err
+	}
+	if !blockarchive.IsArchiver || !blockarchive.IsSidecarArchiving {
+		return nil, status.Error(codes.FailedPrecondition, "the peer is not archiving through a sidecar")
+	}
+	recorded, discarded, err := fsblkstorage.RecordSidecarArchived(ch.ChannelId, request.Blockfiles)
+	if err != nil {
+		loggerArchive.Errorf("[%s] Failed recording the blockfiles archived by the sidecar at %s: %s",
+			ch.ChannelId, util.ExtractRemoteAddress(ctx), err)
+		return nil, status.Errorf(codes.FailedPrecondition, "error recording the archived blockfiles: %s", err)
+	}
+	return &archive.ReportArchivedResponse{Recorded: recorded, Discarded: uint32(discarded)}, nil
+}
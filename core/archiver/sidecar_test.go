@@ -0,0 +1,104 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	archivertest "github.com/hyperledger/fabric/core/archiver/testutil"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSidecarService(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 50)
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+	h, err := archivertest.NewHarness(archivertest.HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	defer func(isSidecarArchiving bool) { blockarchive.IsSidecarArchiving = isSidecarArchiving }(blockarchive.IsSidecarArchiving)
+
+	// The blockfiles of another ledger holding the same blocks are archived by the peer itself, those of
+	// the ledger of the sidecar are archived as by the sidecar
+	blockarchive.IsSidecarArchiving = false
+	archived, err := h.OpenBlockStore("archivedLedger")
+	require.NoError(t, err)
+	defer archived.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, archived.AddBlock(block))
+	}
+	info, err := h.WaitForArchived(archived, 1, false, 10*time.Second)
+	require.NoError(t, err)
+	blockarchive.IsSidecarArchiving = true
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+	reported := proto.Clone(info).(*archive.ArchivedBlockfileInfo)
+	reported.ChannelID = "testLedger"
+
+	ace := &fakeAdmins{}
+	service := NewSidecarService(ace)
+	report := func(channelID string, infos ...*archive.ArchivedBlockfileInfo) (*archive.ReportArchivedResponse, error) {
+		env, err := protoutil.CreateSignedEnvelope(common.HeaderType_MESSAGE, channelID, fakeSigner{}, &archive.ReportArchivedRequest{Blockfiles: infos}, 0, 0)
+		require.NoError(t, err)
+		return service.ReportArchived(context.Background(), env)
+	}
+
+	// The blockfile archived by the sidecar is recorded in the archive catalog of the ledger
+	resp, err := report("testLedger", reported)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{info.BlockfileNo}, resp.Recorded)
+	recorded, err := store.GetArchiveCatalog().ListArchivedBlockfiles()
+	require.NoError(t, err)
+	require.Len(t, recorded, 1)
+	assert.Equal(t, info.BlockfileNo, recorded[0].BlockfileNo)
+	assert.Equal(t, info.Checksum, recorded[0].Checksum)
+	// and is reported again without effect
+	resp, err = report("testLedger", reported)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{info.BlockfileNo}, resp.Recorded)
+
+	// The reports which don't match the ledger are rejected
+	corrupted := proto.Clone(reported).(*archive.ArchivedBlockfileInfo)
+	corrupted.BlockfileNo++
+	_, err = report("testLedger", corrupted)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "differs from the archived one")
+	_, err = report("otherLedger", reported)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "ledger [otherLedger] is not open on this peer")
+	_, err = service.ReportArchived(context.Background(), &common.Envelope{Payload: []byte("garbage")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Only the requesters accepted are heard, while the peer is archiving through a sidecar
+	ace.rejected = true
+	_, err = report("testLedger", reported)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	ace.rejected = false
+	blockarchive.IsSidecarArchiving = false
+	_, err = report("testLedger", reported)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "the peer is not archiving through a sidecar")
+	recorded, err = store.GetArchiveCatalog().ListArchivedBlockfiles()
+	require.NoError(t, err)
+	assert.Len(t, recorded, 1)
+}
@@ -0,0 +1,137 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+)
+
+// HarnessConfig is the configuration of a Harness
+type HarnessConfig struct {
+	// MaxBlockfileSize is the size at which the blockfiles of the block stores roll over
+	MaxBlockfileSize int
+	// Each is the number of blockfiles archived on each archiving opportunity
+	Each int
+	// Keep is the least number of blockfiles kept on the local file system
+	Keep int
+	// ArchiveDir is the directory of the archived blockfiles on the repository, /blkstore if empty
+	ArchiveDir string
+	// ConfigureRepository, if not nil, adjusts the configuration of the repository
+	ConfigureRepository func(*repository.Config)
+}
+
+// Harness runs the block stores of an archiver peer against an in-process Repository. The block stores
+// archive and discard their blockfiles as they roll over, like on a peer, and read the discarded blocks
+// back from the repository. The harness configures the blockarchive package variables, which are
+// restored by Close, so the tests using it must not run in parallel.
+type Harness struct {
+	Repository *Repository
+	// BlockStorePath is the directory of the block stores
+	BlockStorePath string
+	Provider       blkstorage.BlockStoreProvider
+	restore        func()
+}
+
+// NewHarness starts a Repository and creates a provider of block stores archiving into it
+func NewHarness(config HarnessConfig) (*Harness, error) {
+	if config.MaxBlockfileSize <= 0 {
+		return nil, errors.Errorf("the maximum blockfile size must be positive, got %d", config.MaxBlockfileSize)
+	}
+	if config.Each <= 0 {
+		config.Each = 1
+	}
+	if config.ArchiveDir == "" {
+		config.ArchiveDir = "/blkstore"
+	}
+	repo, err := NewRepository(config.ConfigureRepository)
+	if err != nil {
+		return nil, err
+	}
+	blockStorePath, err := ioutil.TempDir("", "blkarchiver-harness")
+	if err != nil {
+		repo.Close()
+		return nil, errors.Wrap(err, "error creating the directory of the block stores")
+	}
+
+	h := &Harness{Repository: repo, BlockStorePath: blockStorePath, restore: saveArchiverParams()}
+	blockarchive.IsArchiver, blockarchive.IsClient = true, false
+	blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir = repo.URL(), config.ArchiveDir
+	blockarchive.BlockStorePath = blockStorePath
+	blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = config.Each, config.Keep
+
+	conf := fsblkstorage.NewConf(blockStorePath, config.MaxBlockfileSize, repo.URL(), config.ArchiveDir)
+	h.Provider = fsblkstorage.NewProvider(conf, &blkstorage.IndexConfig{AttrsToIndex: []blkstorage.IndexableAttr{
+		blkstorage.IndexableAttrBlockHash,
+		blkstorage.IndexableAttrBlockNum,
+		blkstorage.IndexableAttrTxID,
+		blkstorage.IndexableAttrBlockNumTranNum,
+		blkstorage.IndexableAttrBlockTxID,
+		blkstorage.IndexableAttrTxValidationCode,
+	}})
+	return h, nil
+}
+
+// saveArchiverParams returns a function restoring the blockarchive package variables set by the harness
+func saveArchiverParams() func() {
+	isArchiver, isClient := blockarchive.IsArchiver, blockarchive.IsClient
+	url, dir := blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir
+	blockStorePath := blockarchive.BlockStorePath
+	each, keep := blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks
+	return func() {
+		blockarchive.IsArchiver, blockarchive.IsClient = isArchiver, isClient
+		blockarchive.BlockArchiverURL, blockarchive.BlockArchiverDir = url, dir
+		blockarchive.BlockStorePath = blockStorePath
+		blockarchive.NumBlockfileEachArchiving, blockarchive.NumKeepLatestBlocks = each, keep
+	}
+}
+
+// OpenBlockStore opens the block store of a ledger
+func (h *Harness) OpenBlockStore(ledgerID string) (blkstorage.BlockStore, error) {
+	return h.Provider.OpenBlockStore(ledgerID)
+}
+
+// BlockfilePath returns the local path of a blockfile of a ledger
+func (h *Harness) BlockfilePath(ledgerID string, fileNum int) string {
+	return fsblkstorage.RawBlockfilePath(h.BlockStorePath, ledgerID, fileNum)
+}
+
+// WaitForArchived waits until a blockfile of the block store has been archived, and discarded from
+// the local file system if discarded is set. It returns the record of the blockfile in the catalog.
+func (h *Harness) WaitForArchived(store blkstorage.BlockStore, fileNum int, discarded bool, timeout time.Duration) (*archive.ArchivedBlockfileInfo, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		infos, err := store.GetArchiveCatalog().ListArchivedBlockfiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if int(info.BlockfileNo) == fileNum && (info.Discarded || !discarded) {
+				return info, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("blockfile [%d] not archived after %s", fileNum, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Close closes the block stores, stops the repository, removes their files and restores the
+// blockarchive package variables
+func (h *Harness) Close() {
+	h.Provider.Close()
+	h.Repository.Close()
+	os.RemoveAll(h.BlockStorePath)
+	h.restore()
+}
@@ -0,0 +1,93 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package testutil
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarnessArchiveDiscardRestore(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 40)
+	// Blockfiles of 10 blocks
+	size := 0
+	for _, block := range blocks[:10] {
+		b := protoutil.MarshalOrPanic(block)
+		size += len(b) + len(proto.EncodeVarint(uint64(len(b)))) + 64
+	}
+
+	h, err := NewHarness(HarnessConfig{MaxBlockfileSize: size, Each: 1, Keep: 1})
+	require.NoError(t, err)
+	defer h.Close()
+	assert.True(t, blockarchive.IsArchiver)
+
+	store, err := h.OpenBlockStore("testLedger")
+	require.NoError(t, err)
+	defer store.Shutdown()
+	for _, block := range blocks {
+		require.NoError(t, store.AddBlock(block))
+	}
+
+	// The blockfile rolled over is archived and discarded in the background
+	info, err := h.WaitForArchived(store, 1, true, 10*time.Second)
+	require.NoError(t, err)
+	assert.True(t, h.Repository.Exists(info.Location))
+	_, err = os.Stat(h.BlockfilePath("testLedger", 1))
+	assert.True(t, os.IsNotExist(err))
+	locations, err := h.Repository.List("/")
+	require.NoError(t, err)
+	assert.Contains(t, locations, info.Location)
+
+	// The discarded blocks are read back from the repository
+	for blockNum := info.FirstBlockNum; blockNum <= info.LastBlockNum; blockNum++ {
+		block, err := store.RetrieveBlockByNumber(blockNum)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(blocks[blockNum], block))
+	}
+
+	// The discarded blockfile is brought back onto the local file system
+	require.NoError(t, store.RestoreRange(info.FirstBlockNum, info.LastBlockNum, 0, nil))
+	_, err = os.Stat(h.BlockfilePath("testLedger", 1))
+	assert.NoError(t, err)
+
+	_, err = h.WaitForArchived(store, 100, false, 50*time.Millisecond)
+	assert.EqualError(t, err, "blockfile [100] not archived after 50ms")
+}
+
+func TestRepository(t *testing.T) {
+	repo, err := NewRepository(nil)
+	require.NoError(t, err)
+	defer repo.Close()
+	assert.NotEmpty(t, repo.URL())
+
+	require.NoError(t, os.MkdirAll(repo.LocalPath("/blkstore/chains/ch"), 0755))
+	f, err := os.Create(repo.LocalPath("/blkstore/chains/ch/blockfile_000001"))
+	require.NoError(t, err)
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	locations, err := repo.List("/blkstore")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/blkstore/chains/ch/blockfile_000001"}, locations)
+	locations, err = repo.List("/missing")
+	require.NoError(t, err)
+	assert.Empty(t, locations)
+
+	require.NoError(t, repo.Corrupt("/blkstore/chains/ch/blockfile_000001"))
+	b, err := repo.ReadFile("/blkstore/chains/ch/blockfile_000001")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xfe, 2, 3}, b)
+
+	require.NoError(t, repo.Remove("/blkstore/chains/ch/blockfile_000001"))
+	assert.False(t, repo.Exists("/blkstore/chains/ch/blockfile_000001"))
+}
@@ -0,0 +1,132 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/archiver/repository"
+	"github.com/pkg/errors"
+)
+
+// Repository is an in-process repository of archived blockfiles backed by a temporary directory, so that
+// the tests of the archive, discard and restore flows run without an external repository. It accepts the
+// default account of the peers.
+type Repository struct {
+	*repository.Server
+	// RootDir is the local directory holding the files of the repository
+	RootDir string
+	dir     string
+}
+
+// NewRepository starts a Repository listening on a random local port. configure, if not nil,
+// adjusts the configuration of the repository before it starts, e.g. to enable the object lock.
+func NewRepository(configure func(*repository.Config)) (*Repository, error) {
+	dir, err := ioutil.TempDir("", "blkarchiver-repo")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the directory of the repository")
+	}
+	rootDir := filepath.Join(dir, "root")
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.Wrapf(err, "error creating directory %s", rootDir)
+	}
+	config := &repository.Config{
+		ListenAddress: "127.0.0.1:0",
+		RootDir:       rootDir,
+		DataDir:       filepath.Join(dir, "data"),
+		Users:         []repository.User{{Name: "root", Password: "blkstore"}},
+	}
+	if configure != nil {
+		configure(config)
+	}
+	server, err := repository.NewServer(config)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := server.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &Repository{Server: server, RootDir: config.RootDir, dir: dir}, nil
+}
+
+// URL returns the address the peers connect to
+func (r *Repository) URL() string {
+	return r.Addr().String()
+}
+
+// LocalPath returns the local path of the file at location on the repository
+func (r *Repository) LocalPath(location string) string {
+	return filepath.Join(r.RootDir, filepath.FromSlash(path.Clean("/"+location)))
+}
+
+// Exists tells if there is a file at location on the repository
+func (r *Repository) Exists(location string) bool {
+	_, err := os.Stat(r.LocalPath(location))
+	return err == nil
+}
+
+// ReadFile returns the content of the file at location on the repository
+func (r *Repository) ReadFile(location string) ([]byte, error) {
+	return ioutil.ReadFile(r.LocalPath(location))
+}
+
+// Remove deletes the file at location from the repository, e.g. to simulate its loss
+func (r *Repository) Remove(location string) error {
+	return os.Remove(r.LocalPath(location))
+}
+
+// Corrupt alters the first byte of the file at location on the repository
+func (r *Repository) Corrupt(location string) error {
+	localPath := r.LocalPath(location)
+	b, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return errors.Errorf("file %s is empty", location)
+	}
+	b[0] ^= 0xff
+	return ioutil.WriteFile(localPath, b, 0644)
+}
+
+// List returns the locations of the files below dir on the repository, sorted
+func (r *Repository) List(dir string) ([]string, error) {
+	var locations []string
+	err := filepath.Walk(r.LocalPath(dir), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(r.RootDir, p)
+		if err != nil {
+			return err
+		}
+		locations = append(locations, "/"+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing %s on the repository", dir)
+	}
+	sort.Strings(locations)
+	return locations, nil
+}
+
+// Close stops the repository and removes its files
+func (r *Repository) Close() {
+	r.Stop()
+	os.RemoveAll(r.dir)
+}
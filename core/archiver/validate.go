@@ -0,0 +1,175 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// The durations of ledger.blockArchiver.* and peer.archiver.* which are disabled with 0 rather than a negative value
+var nonNegativeDurations = []string{
+	"ledger.blockArchiver.restoreTTL",
+	"ledger.blockArchiver.drainTimeout",
+	"ledger.blockArchiver.backpressure.maxCommitPause",
+	"ledger.blockArchiver.minBlockAgeBeforeDiscard",
+	"ledger.blockArchiver.objectLock.minRetention",
+	"ledger.blockArchiver.reconciliation.interval",
+	"ledger.blockArchiver.catalog.snapshots.interval",
+	"peer.archiver.tailIdleTime",
+	"peer.archiver.alerts.timeout",
+	"peer.archiver.alerts.interval",
+}
+
+// The numbers of ledger.blockArchiver.* and peer.archiver.* which are replaced by their default when 0
+var nonNegativeNumbers = []string{
+	"ledger.blockArchiver.maxConcurrentRetrievals",
+	"ledger.blockArchiver.restoreParallelism",
+	"ledger.blockArchiver.maxBufferedRetrievals",
+	"ledger.blockArchiver.bandwidth",
+	"ledger.blockArchiver.backpressure.minFreeDiskSpace",
+	"ledger.blockArchiver.catalog.snapshots.keep",
+	"ledger.blockArchiver.catchUp.threshold",
+	"ledger.blockArchiver.catchUp.parallelism",
+	"ledger.blockArchiver.catchUp.maxBandwidth",
+	"ledger.blockArchiver.accessAudit.maxSize",
+}
+
+// ConfigErrors lists the invalid settings of the archiver
+type ConfigErrors []string
+
+func (e ConfigErrors) Error() string {
+	return fmt.Sprintf("%d invalid setting(s) of the archiver:\n  - %s", len(e), strings.Join(e, "\n  - "))
+}
+
+// ValidateConfig checks the settings of ledger.blockArchiver.* and peer.archiver.* once they have been loaded,
+// beyond the syntax checked as they are loaded: the ranges of the durations and the numbers, the counts of
+// blockfiles of peer.archiver.each and peer.archiver.keep, and that the directories in which the peer writes the
+// blockfiles retrieved from the archive, the manifests and the snapshots of the catalogs are writable. The
+// repositories are probed with the credentials of the peer if ledger.blockArchiver.validation.probeRepository
+// is set. It returns all the invalid settings at once, as ConfigErrors, so that they are fixed in one go.
+func ValidateConfig() error {
+	var errs ConfigErrors
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	for _, key := range nonNegativeDurations {
+		if d := viper.GetDuration(key); d < 0 {
+			invalid(key, "%s is negative, set 0 to disable it", d)
+		}
+	}
+	for _, key := range nonNegativeNumbers {
+		if n := viper.GetInt(key); n < 0 {
+			invalid(key, "%d is negative, set 0 for the default", n)
+		}
+	}
+	if n := viper.GetInt("ledger.blockArchiver.discardVerification.fullEvery"); n < 0 {
+		invalid("ledger.blockArchiver.discardVerification.fullEvery", "%d is negative, set 0 to verify by the digest of the repository only", n)
+	}
+	if n := viper.GetInt("ledger.blockArchiver.accessAudit.maxBackups"); n < 0 {
+		invalid("ledger.blockArchiver.accessAudit.maxBackups", "%d is negative, set 0 to keep all the rotated logs", n)
+	}
+
+	if blockarchive.IsArchiver {
+		each, keep := ledgerconfig.GetArchivingParameters()
+		if each < 1 {
+			invalid("peer.archiver.each", "%d blockfiles are archived at a time, at least 1 is required", each)
+		}
+		if keep < 0 {
+			invalid("peer.archiver.keep", "%d blockfiles are kept on the local file system, it must not be negative", keep)
+		}
+	}
+
+	if blockarchive.IsArchiver || blockarchive.IsClient {
+		dirs := []string{
+			blockarchive.BlockStorePath,
+			filepath.Join(blockarchive.BlockStorePath, fsblkstorage.ManifestsDir),
+		}
+		if ledgerconfig.GetCatalogSnapshotInterval() > 0 || blockarchive.CatalogRecovery {
+			dirs = append(dirs, filepath.Join(blockarchive.BlockStorePath, fsblkstorage.CatalogSnapshotsDir))
+		}
+		for _, dir := range dirs {
+			if err := checkWritableDir(dir); err != nil {
+				invalid("peer.fileSystemPath", "%s", err)
+			}
+		}
+		if blockarchive.RepositoryTokenFile != "" {
+			if _, err := ioutil.ReadFile(blockarchive.RepositoryTokenFile); err != nil {
+				invalid("ledger.blockArchiver.tokenFile", "%s", err)
+			}
+		}
+	}
+
+	if ledgerconfig.IsRepositoryProbeEnabled() {
+		urls := repositoriesToProbe()
+		var keys []string
+		for key := range urls {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			url := urls[key]
+			if err := fsblkstorage.ProbeRepository(url); err != nil {
+				invalid(key, "repository %s is unreachable with the credentials of the peer: %s", url, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// repositoriesToProbe returns the URLs of the repositories accessed directly by the peer, by setting,
+// i.e. all of them for the archiver peer and none for a client peer retrieving through the archiver peer
+func repositoriesToProbe() map[string]string {
+	if !blockarchive.IsArchiver && (!blockarchive.IsClient || blockarchive.ProxyEndpoint != "") {
+		return nil
+	}
+	// The HDFS cluster is probed once, by the setting of its namenodes
+	const hdfsKey = "ledger.blockArchiver.hdfs.namenodes"
+	urls := map[string]string{}
+	if strings.ToLower(blockarchive.RepositoryType) == blockarchive.RepositoryTypeHDFS {
+		urls[hdfsKey] = blockarchive.HDFS.URL()
+	} else {
+		urls["ledger.blockArchiver.url"] = blockarchive.BlockArchiverURL
+	}
+	for _, channelID := range ledgerconfig.GetRepositoryChannels() {
+		if blockarchive.ChannelRepositoryOf(channelID) == nil {
+			continue
+		}
+		if blockarchive.RepositoryTypeOf(channelID) == blockarchive.RepositoryTypeHDFS {
+			urls[hdfsKey] = blockarchive.RepositoryURLOf(channelID)
+		} else {
+			urls["ledger.blockArchiver.channels."+channelID+".url"] = blockarchive.RepositoryURLOf(channelID)
+		}
+	}
+	return urls
+}
+
+// checkWritableDir creates a directory if it doesn't exist, and checks that a file can be created in it
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "cannot create directory %s", dir)
+	}
+	file, err := ioutil.TempFile(dir, ".writable")
+	if err != nil {
+		return errors.Wrapf(err, "directory %s is not writable", dir)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,79 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archiver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	h, err := testutil.NewHarness(testutil.HarnessConfig{MaxBlockfileSize: 1024})
+	require.NoError(t, err)
+	defer h.Close()
+	defer viper.Reset()
+
+	viper.Set("peer.archiver.each", 1)
+	viper.Set("peer.archiver.keep", 0)
+	viper.Set("ledger.blockArchiver.validation.probeRepository", true)
+	require.NoError(t, ValidateConfig())
+
+	// All the invalid settings are reported at once
+	viper.Set("peer.archiver.each", 0)
+	viper.Set("peer.archiver.keep", -1)
+	viper.Set("ledger.blockArchiver.drainTimeout", "-1s")
+	viper.Set("ledger.blockArchiver.maxConcurrentRetrievals", -2)
+	blockarchive.BlockArchiverURL = "127.0.0.1:1"
+	err = ValidateConfig()
+	require.IsType(t, ConfigErrors{}, err)
+	errs := err.(ConfigErrors)
+	require.Len(t, errs, 5)
+	assert.Equal(t, "ledger.blockArchiver.drainTimeout: -1s is negative, set 0 to disable it", errs[0])
+	assert.Equal(t, "ledger.blockArchiver.maxConcurrentRetrievals: -2 is negative, set 0 for the default", errs[1])
+	assert.Contains(t, errs[2], "peer.archiver.each: 0 blockfiles are archived at a time")
+	assert.Contains(t, errs[3], "peer.archiver.keep: -1 blockfiles are kept")
+	assert.Contains(t, errs[4], "ledger.blockArchiver.url: repository 127.0.0.1:1 is unreachable")
+	assert.Contains(t, err.Error(), "5 invalid setting(s) of the archiver")
+
+	// The repository is not probed unless requested
+	viper.Reset()
+	require.NoError(t, ValidateConfig())
+}
+
+func TestValidateConfigUnwritableDirectories(t *testing.T) {
+	h, err := testutil.NewHarness(testutil.HarnessConfig{MaxBlockfileSize: 1024})
+	require.NoError(t, err)
+	defer h.Close()
+	defer viper.Reset()
+
+	// The block store path is a file, in which no directory is created
+	blockStorePath := filepath.Join(h.BlockStorePath, "file")
+	require.NoError(t, ioutil.WriteFile(blockStorePath, nil, 0644))
+	blockarchive.BlockStorePath = blockStorePath
+	err = ValidateConfig()
+	require.Error(t, err)
+	errs := err.(ConfigErrors)
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0], "peer.fileSystemPath: cannot create directory "+blockStorePath)
+	assert.Contains(t, errs[1], "peer.fileSystemPath: cannot create directory "+filepath.Join(blockStorePath, "manifests"))
+
+	// The token file must be readable
+	blockarchive.BlockStorePath = h.BlockStorePath
+	defer func(tokenFile string) { blockarchive.RepositoryTokenFile = tokenFile }(blockarchive.RepositoryTokenFile)
+	blockarchive.RepositoryTokenFile = filepath.Join(h.BlockStorePath, "missing-token")
+	err = ValidateConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ledger.blockArchiver.tokenFile: open "+blockarchive.RepositoryTokenFile)
+	_, err = os.Stat(filepath.Join(h.BlockStorePath, "manifests"))
+	assert.NoError(t, err)
+}
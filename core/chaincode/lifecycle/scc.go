@@ -553,6 +553,7 @@ func validateNameVersion(name, version string) error {
 	}
 
 	systemChaincodeNames := map[string]struct{}{
+		"ascc": {},
 		"cscc": {},
 		"escc": {},
 		"lscc": {},
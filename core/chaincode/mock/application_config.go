@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/protos/peer"
 )
 
 type ApplicationConfig struct {
@@ -18,6 +19,16 @@ type ApplicationConfig struct {
 	aPIPolicyMapperReturnsOnCall map[int]struct {
 		result1 channelconfig.PolicyMapper
 	}
+	BlockArchivingStub        func() *peer.BlockArchiving
+	blockArchivingMutex       sync.RWMutex
+	blockArchivingArgsForCall []struct {
+	}
+	blockArchivingReturns struct {
+		result1 *peer.BlockArchiving
+	}
+	blockArchivingReturnsOnCall map[int]struct {
+		result1 *peer.BlockArchiving
+	}
 	CapabilitiesStub        func() channelconfig.ApplicationCapabilities
 	capabilitiesMutex       sync.RWMutex
 	capabilitiesArgsForCall []struct {
@@ -94,6 +105,58 @@ func (fake *ApplicationConfig) APIPolicyMapperReturnsOnCall(i int, result1 chann
 	}{result1}
 }
 
+func (fake *ApplicationConfig) BlockArchiving() *peer.BlockArchiving {
+	fake.blockArchivingMutex.Lock()
+	ret, specificReturn := fake.blockArchivingReturnsOnCall[len(fake.blockArchivingArgsForCall)]
+	fake.blockArchivingArgsForCall = append(fake.blockArchivingArgsForCall, struct {
+	}{})
+	fake.recordInvocation("BlockArchiving", []interface{}{})
+	fake.blockArchivingMutex.Unlock()
+	if fake.BlockArchivingStub != nil {
+		return fake.BlockArchivingStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.blockArchivingReturns
+	return fakeReturns.result1
+}
+
+func (fake *ApplicationConfig) BlockArchivingCallCount() int {
+	fake.blockArchivingMutex.RLock()
+	defer fake.blockArchivingMutex.RUnlock()
+	return len(fake.blockArchivingArgsForCall)
+}
+
+func (fake *ApplicationConfig) BlockArchivingCalls(stub func() *peer.BlockArchiving) {
+	fake.blockArchivingMutex.Lock()
+	defer fake.blockArchivingMutex.Unlock()
+	fake.BlockArchivingStub = stub
+}
+
+func (fake *ApplicationConfig) BlockArchivingReturns(result1 *peer.BlockArchiving) {
+	fake.blockArchivingMutex.Lock()
+	defer fake.blockArchivingMutex.Unlock()
+	fake.BlockArchivingStub = nil
+	fake.blockArchivingReturns = struct {
+		result1 *peer.BlockArchiving
+	}{result1}
+}
+
+func (fake *ApplicationConfig) BlockArchivingReturnsOnCall(i int, result1 *peer.BlockArchiving) {
+	fake.blockArchivingMutex.Lock()
+	defer fake.blockArchivingMutex.Unlock()
+	fake.BlockArchivingStub = nil
+	if fake.blockArchivingReturnsOnCall == nil {
+		fake.blockArchivingReturnsOnCall = make(map[int]struct {
+			result1 *peer.BlockArchiving
+		})
+	}
+	fake.blockArchivingReturnsOnCall[i] = struct {
+		result1 *peer.BlockArchiving
+	}{result1}
+}
+
 func (fake *ApplicationConfig) Capabilities() channelconfig.ApplicationCapabilities {
 	fake.capabilitiesMutex.Lock()
 	ret, specificReturn := fake.capabilitiesReturnsOnCall[len(fake.capabilitiesArgsForCall)]
@@ -203,6 +266,8 @@ func (fake *ApplicationConfig) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.aPIPolicyMapperMutex.RLock()
 	defer fake.aPIPolicyMapperMutex.RUnlock()
+	fake.blockArchivingMutex.RLock()
+	defer fake.blockArchivingMutex.RUnlock()
 	fake.capabilitiesMutex.RLock()
 	defer fake.capabilitiesMutex.RUnlock()
 	fake.organizationsMutex.RLock()
@@ -589,7 +589,7 @@ func acquireTxSimulator(chainID string, ccid *pb.ChaincodeID) bool {
 	// Don't get a simulator for the query and config system chaincode.
 	// These don't need the simulator and its read lock results in deadlocks.
 	switch ccid.Name {
-	case "qscc", "cscc":
+	case "qscc", "cscc", "ascc":
 		return false
 	default:
 		return true
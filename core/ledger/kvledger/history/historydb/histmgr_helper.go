@@ -59,3 +59,52 @@ func SplitCompositeHistoryKey(bytesToSplit []byte, separator []byte) ([]byte, []
 	split := bytes.SplitN(bytesToSplit, separator, 2)
 	return split[0], split[1]
 }
+
+//DecodeHistoryKeyHeight extracts the blocknum and trannum from a History Key of namespace~key~blocknum~trannum.
+// As the key may contain the separator, the first separator following the namespace which
+// is followed by exactly two encoded numbers is taken as the one preceding the height.
+// ok is false if bytesToDecode is not a History Key
+func DecodeHistoryKeyHeight(bytesToDecode []byte) (blocknum uint64, trannum uint64, ok bool) {
+	nsEnd := bytes.Index(bytesToDecode, CompositeKeySep)
+	if nsEnd < 0 {
+		return 0, 0, false
+	}
+	for i := nsEnd + 1; i < len(bytesToDecode); i++ {
+		if bytesToDecode[i] != CompositeKeySep[0] {
+			continue
+		}
+		if blocknum, trannum, ok = decodeHeight(bytesToDecode[i+1:]); ok {
+			return blocknum, trannum, true
+		}
+	}
+	return 0, 0, false
+}
+
+// decodeHeight decodes the bytes consisting of exactly two numbers
+// encoded by util.EncodeOrderPreservingVarUint64
+func decodeHeight(heightBytes []byte) (uint64, uint64, bool) {
+	blockNumLen, ok := orderPreservingVarUint64Len(heightBytes)
+	if !ok {
+		return 0, 0, false
+	}
+	tranNumLen, ok := orderPreservingVarUint64Len(heightBytes[blockNumLen:])
+	if !ok || blockNumLen+tranNumLen != len(heightBytes) {
+		return 0, 0, false
+	}
+	blocknum, _ := util.DecodeOrderPreservingVarUint64(heightBytes)
+	trannum, _ := util.DecodeOrderPreservingVarUint64(heightBytes[blockNumLen:])
+	return blocknum, trannum, true
+}
+
+// orderPreservingVarUint64Len returns the length of the number encoded at the head of the bytes
+// if it is a valid output of util.EncodeOrderPreservingVarUint64
+func orderPreservingVarUint64Len(encoded []byte) (int, bool) {
+	if len(encoded) == 0 || encoded[0] > 8 {
+		return 0, false
+	}
+	size := int(encoded[0])
+	if len(encoded) < size+1 || (size > 0 && encoded[1] == 0x00) {
+		return 0, false
+	}
+	return size + 1, true
+}
@@ -46,3 +46,27 @@ func TestSplitCompositeKey(t *testing.T) {
 	// second position should hold the extra bytes that were split off
 	assert.Equal(t, []byte("extra bytes to split"), extraBytes)
 }
+
+func TestDecodeHistoryKeyHeight(t *testing.T) {
+	testCases := []struct {
+		ns, key           string
+		blockNum, tranNum uint64
+	}{
+		{"ns1", "key1", 0, 0},
+		{"ns1", "key1", 65536, 0},
+		{"ns1", "key1", 1, 256},
+		{"ns1", strKeySep + "composite" + strKeySep + "key" + strKeySep, 12, 3},
+		{"ns1", "", 5, 1},
+	}
+	for _, tc := range testCases {
+		blockNum, tranNum, ok := DecodeHistoryKeyHeight(ConstructCompositeHistoryKey(tc.ns, tc.key, tc.blockNum, tc.tranNum))
+		assert.True(t, ok)
+		assert.Equal(t, tc.blockNum, blockNum)
+		assert.Equal(t, tc.tranNum, tranNum)
+	}
+
+	_, _, ok := DecodeHistoryKeyHeight([]byte{0x00})
+	assert.False(t, ok)
+	_, _, ok = DecodeHistoryKeyHeight([]byte("ns1" + strKeySep + "key1"))
+	assert.False(t, ok)
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/version"
 	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
 )
 
 // HistoryDBProvider provides an instance of a history DB
@@ -39,3 +40,11 @@ type HistoryDB interface {
 	ShouldRecover(lastAvailableBlock uint64) (bool, uint64, error)
 	CommitLostBlock(blockAndPvtdata *ledger.BlockAndPvtData) error
 }
+
+// Prunable is implemented by the history databases which support pruning
+// of the history records written by a range of blocks
+type Prunable interface {
+	// PruneBlocks removes the history records written by the blocks within the given ranges
+	// and returns the number of records removed
+	PruneBlocks(ranges []*archive.ArchivedBlockRange) (int, error)
+}
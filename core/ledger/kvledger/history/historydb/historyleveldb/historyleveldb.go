@@ -17,7 +17,9 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
 	"github.com/hyperledger/fabric/core/ledger/util"
 	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
 	protoutil "github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
 )
 
 var logger = flogging.MustGetLogger("historyleveldb")
@@ -52,11 +54,13 @@ func (provider *HistoryDBProvider) Close() {
 type historyDB struct {
 	db     *leveldbhelper.DBHandle
 	dbName string
+	// the blocks retrieved from the archive to answer history queries
+	archivedBlocks *archivedBlockCache
 }
 
 // newHistoryDB constructs an instance of HistoryDB
 func newHistoryDB(db *leveldbhelper.DBHandle, dbName string) *historyDB {
-	return &historyDB{db, dbName}
+	return &historyDB{db, dbName, newArchivedBlockCache(ledgerconfig.GetArchivedBlockCacheSize())}
 }
 
 // Open implements method in HistoryDB interface
@@ -209,3 +213,52 @@ func (historyDB *historyDB) CommitLostBlock(blockAndPvtdata *ledger.BlockAndPvtD
 	}
 	return nil
 }
+
+// maxPruneBatchSize is the maximum number of history records deleted in a single db batch
+const maxPruneBatchSize = 1000
+
+// PruneBlocks implements method in interface historydb.Prunable
+func (historyDB *historyDB) PruneBlocks(ranges []*archive.ArchivedBlockRange) (int, error) {
+	if len(ranges) == 0 {
+		return 0, nil
+	}
+	logger.Infof("Channel [%s]: Pruning history records of blocks %v", historyDB.dbName, ranges)
+
+	itr := historyDB.db.GetIterator(nil, nil)
+	defer itr.Release()
+
+	numPruned := 0
+	dbBatch := leveldbhelper.NewUpdateBatch()
+	for itr.Next() {
+		blockNo, _, ok := historydb.DecodeHistoryKeyHeight(itr.Key())
+		if !ok || !withinRanges(blockNo, ranges) {
+			continue
+		}
+		dbBatch.Delete(itr.Key())
+		numPruned++
+		if dbBatch.Len() >= maxPruneBatchSize {
+			if err := historyDB.db.WriteBatch(dbBatch, true); err != nil {
+				return numPruned, err
+			}
+			dbBatch = leveldbhelper.NewUpdateBatch()
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return numPruned, errors.Wrap(err, "error while iterating history records")
+	}
+	if err := historyDB.db.WriteBatch(dbBatch, true); err != nil {
+		return numPruned, err
+	}
+
+	logger.Infof("Channel [%s]: Pruned [%d] history records", historyDB.dbName, numPruned)
+	return numPruned, nil
+}
+
+func withinRanges(blockNo uint64, ranges []*archive.ArchivedBlockRange) bool {
+	for _, r := range ranges {
+		if r.FirstBlockNum <= blockNo && blockNo <= r.LastBlockNum {
+			return true
+		}
+	}
+	return false
+}
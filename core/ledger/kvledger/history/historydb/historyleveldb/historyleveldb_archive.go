@@ -0,0 +1,98 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package historyleveldb
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	protoutil "github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// archivedBlockCache keeps in memory the most recently used blocks which have been retrieved from the
+// archive to answer history queries. The history records of a key are often written by the same blocks,
+// so a block discarded from the local file system is not retrieved again for each of its transactions.
+type archivedBlockCache struct {
+	capacity int
+
+	mutex    sync.Mutex
+	lru      *list.List
+	elements map[uint64]*list.Element
+}
+
+func newArchivedBlockCache(capacity int) *archivedBlockCache {
+	return &archivedBlockCache{
+		capacity: capacity,
+		lru:      list.New(),
+		elements: make(map[uint64]*list.Element),
+	}
+}
+
+// get returns a block from the cache, or retrieves it and adds it to the cache. The lock is not held
+// while the block is retrieved, concurrent queries may retrieve the same block.
+func (c *archivedBlockCache) get(blockNum uint64, retrieve func(blockNum uint64) (*common.Block, error)) (*common.Block, error) {
+	c.mutex.Lock()
+	if element, ok := c.elements[blockNum]; ok {
+		c.lru.MoveToFront(element)
+		c.mutex.Unlock()
+		return element.Value.(*common.Block), nil
+	}
+	c.mutex.Unlock()
+
+	block, err := retrieve(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if c.capacity <= 0 {
+		return block, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.elements[blockNum]; !ok {
+		c.elements[blockNum] = c.lru.PushFront(block)
+	}
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.elements, oldest.Value.(*common.Block).Header.Number)
+	}
+	return block, nil
+}
+
+// discardedRanges returns the ranges of blocks of the block store which have been archived and discarded
+func discardedRanges(blockStore blkstorage.BlockStore) ([]*archive.ArchivedBlockRange, error) {
+	catalog := blockStore.GetArchiveCatalog()
+	if catalog == nil {
+		return nil, nil
+	}
+	ranges, err := catalog.GetDiscardedRanges()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error reading the discarded blocks from the archive catalog")
+	}
+	return ranges, nil
+}
+
+// retrieveTx returns the transaction written at blockNum~tranNum. The index of the block store locates
+// the transactions within the local blockfiles only, so the transactions of the discarded blocks are
+// extracted from their blocks, which are retrieved from the archive by the block store.
+func (scanner *historyScanner) retrieveTx(blockNum uint64, tranNum uint64) (*common.Envelope, error) {
+	if !withinRanges(blockNum, scanner.discarded) {
+		return scanner.blockStore.RetrieveTxByBlockNumTranNum(blockNum, tranNum)
+	}
+	logger.Debugf("Retrieving archived block [%d] for the history of namespace:%s key:%s", blockNum, scanner.namespace, scanner.key)
+	block, err := scanner.archivedBlocks.get(blockNum, scanner.blockStore.RetrieveBlockByNumber)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error retrieving archived block [%d]", blockNum)
+	}
+	if tranNum >= uint64(len(block.Data.Data)) {
+		return nil, errors.Errorf("transaction [%d] not found in archived block [%d] of %d transactions", tranNum, blockNum, len(block.Data.Data))
+	}
+	return protoutil.GetEnvelopeFromBlock(block.Data.Data[tranNum])
+}
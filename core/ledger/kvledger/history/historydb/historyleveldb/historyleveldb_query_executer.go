@@ -16,6 +16,7 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
 	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
 	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
 	"github.com/hyperledger/fabric/protos/ledger/queryresult"
 	protoutil "github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
@@ -40,9 +41,15 @@ func (q *LevelHistoryDBQueryExecutor) GetHistoryForKey(namespace string, key str
 	compositeStartKey = historydb.ConstructPartialCompositeHistoryKey(namespace, key, false)
 	compositeEndKey = historydb.ConstructPartialCompositeHistoryKey(namespace, key, true)
 
+	// the transactions of the blocks discarded when the query starts are retrieved from the archive
+	discarded, err := discardedRanges(q.blockStore)
+	if err != nil {
+		return nil, err
+	}
+
 	// range scan to find any history records starting with namespace~key
 	dbItr := q.historyDB.db.GetIterator(compositeStartKey, compositeEndKey)
-	return newHistoryScanner(compositeStartKey, namespace, key, dbItr, q.blockStore), nil
+	return newHistoryScanner(compositeStartKey, namespace, key, dbItr, q.blockStore, discarded, q.historyDB.archivedBlocks), nil
 }
 
 //historyScanner implements ResultsIterator for iterating through history results
@@ -52,11 +59,14 @@ type historyScanner struct {
 	key                 string
 	dbItr               iterator.Iterator
 	blockStore          blkstorage.BlockStore
+	discarded           []*archive.ArchivedBlockRange
+	archivedBlocks      *archivedBlockCache
 }
 
 func newHistoryScanner(compositePartialKey []byte, namespace string, key string,
-	dbItr iterator.Iterator, blockStore blkstorage.BlockStore) *historyScanner {
-	return &historyScanner{compositePartialKey, namespace, key, dbItr, blockStore}
+	dbItr iterator.Iterator, blockStore blkstorage.BlockStore, discarded []*archive.ArchivedBlockRange,
+	archivedBlocks *archivedBlockCache) *historyScanner {
+	return &historyScanner{compositePartialKey, namespace, key, dbItr, blockStore, discarded, archivedBlocks}
 }
 
 func (scanner *historyScanner) Next() (commonledger.QueryResult, error) {
@@ -93,7 +103,7 @@ func (scanner *historyScanner) Next() (commonledger.QueryResult, error) {
 			scanner.namespace, scanner.key, blockNum, tranNum)
 
 		// Get the transaction from block storage that is associated with this history record
-		tranEnvelope, err := scanner.blockStore.RetrieveTxByBlockNumTranNum(blockNum, tranNum)
+		tranEnvelope, err := scanner.retrieveTx(blockNum, tranNum)
 		if err != nil {
 			return nil, err
 		}
@@ -13,13 +13,18 @@ import (
 
 	configtxtest "github.com/hyperledger/fabric/common/configtx/test"
 	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/ledger/testutil"
 	util2 "github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/history/historydb"
 	"github.com/hyperledger/fabric/core/ledger/util"
 	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
 	"github.com/hyperledger/fabric/protos/ledger/queryresult"
 	"github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -321,6 +326,163 @@ func TestHistoryWithKeyContainingNilBytes(t *testing.T) {
 	testutilVerifyResults(t, qhistory, "ns1", "\x00key\x00\x01\x01\x15", []string{"dummyVal2"})
 }
 
+// TestPruneBlocks tests that the history records written by the blocks within the archived ranges are pruned
+func TestPruneBlocks(t *testing.T) {
+	env := newTestHistoryEnv(t)
+	defer env.cleanup()
+	provider := env.testBlockStorageEnv.provider
+	ledger1id := "ledger1"
+	store1, err := provider.OpenBlockStore(ledger1id)
+	assert.NoError(t, err, "Error upon provider.OpenBlockStore()")
+	defer store1.Shutdown()
+
+	bg, gb := testutil.NewBlockGenerator(t, ledger1id, false)
+	assert.NoError(t, store1.AddBlock(gb))
+	assert.NoError(t, env.testHistoryDB.Commit(gb))
+
+	//blocks 1 to 4 each updating key7 and a key of composite form
+	for i := 1; i <= 4; i++ {
+		txid := util2.GenerateUUID()
+		simulator, _ := env.txmgr.NewTxSimulator(txid)
+		simulator.SetState("ns1", "key7", []byte("value"+strconv.Itoa(i)))
+		simulator.SetState("ns1", "\x00key\x00"+strconv.Itoa(i)+"\x00", []byte("value"+strconv.Itoa(i)))
+		simulator.Done()
+		simRes, _ := simulator.GetTxSimulationResults()
+		pubSimResBytes, _ := simRes.GetPubSimulationBytes()
+		block := bg.NextBlock([][]byte{pubSimResBytes})
+		assert.NoError(t, store1.AddBlock(block))
+		assert.NoError(t, env.testHistoryDB.Commit(block))
+	}
+
+	numPruned, err := env.testHistoryDB.(historydb.Prunable).PruneBlocks([]*archive.ArchivedBlockRange{
+		{FirstBlockNum: 0, LastBlockNum: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, numPruned)
+
+	qhistory, err := env.testHistoryDB.NewHistoryQueryExecutor(store1)
+	assert.NoError(t, err, "Error upon NewHistoryQueryExecutor")
+	testutilVerifyResults(t, qhistory, "ns1", "key7", []string{"value3", "value4"})
+	testutilVerifyResults(t, qhistory, "ns1", "\x00key\x002\x00", []string{})
+	testutilVerifyResults(t, qhistory, "ns1", "\x00key\x003\x00", []string{"value3"})
+
+	// the savepoint is not affected by pruning
+	savepoint, err := env.testHistoryDB.GetLastSavepoint()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), savepoint.BlockNum)
+
+	// pruning is idempotent
+	numPruned, err = env.testHistoryDB.(historydb.Prunable).PruneBlocks([]*archive.ArchivedBlockRange{
+		{FirstBlockNum: 0, LastBlockNum: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numPruned)
+}
+
+// discardedBlockStore behaves like a block store whose blockfiles holding the discarded blocks have been
+// deleted: their transactions are no longer located by the index, their blocks are retrieved from the archive
+type discardedBlockStore struct {
+	blkstorage.BlockStore
+	discarded       []*archive.ArchivedBlockRange
+	retrievedBlocks []uint64
+}
+
+func (s *discardedBlockStore) RetrieveTxByBlockNumTranNum(blockNum uint64, tranNum uint64) (*common.Envelope, error) {
+	if withinRanges(blockNum, s.discarded) {
+		return nil, errors.Errorf("blockfile of block [%d] discarded", blockNum)
+	}
+	return s.BlockStore.RetrieveTxByBlockNumTranNum(blockNum, tranNum)
+}
+
+func (s *discardedBlockStore) RetrieveBlockByNumber(blockNum uint64) (*common.Block, error) {
+	s.retrievedBlocks = append(s.retrievedBlocks, blockNum)
+	return s.BlockStore.RetrieveBlockByNumber(blockNum)
+}
+
+func (s *discardedBlockStore) GetArchiveCatalog() blockarchive.Catalog {
+	return &discardedCatalog{s.BlockStore.GetArchiveCatalog(), s.discarded}
+}
+
+type discardedCatalog struct {
+	blockarchive.Catalog
+	discarded []*archive.ArchivedBlockRange
+}
+
+func (c *discardedCatalog) GetDiscardedRanges() ([]*archive.ArchivedBlockRange, error) {
+	return c.discarded, nil
+}
+
+func TestHistoryOfDiscardedBlocks(t *testing.T) {
+	env := newTestHistoryEnv(t)
+	defer env.cleanup()
+	provider := env.testBlockStorageEnv.provider
+	ledger1id := "ledger1"
+	store1, err := provider.OpenBlockStore(ledger1id)
+	assert.NoError(t, err, "Error upon provider.OpenBlockStore()")
+	defer store1.Shutdown()
+
+	bg, gb := testutil.NewBlockGenerator(t, ledger1id, false)
+	assert.NoError(t, store1.AddBlock(gb))
+	assert.NoError(t, env.testHistoryDB.Commit(gb))
+
+	//blocks 1 to 4 each with a transaction updating key7 and another one updating key8
+	for i := 1; i <= 4; i++ {
+		simulationResults := [][]byte{}
+		for _, key := range []string{"key7", "key8"} {
+			simulator, _ := env.txmgr.NewTxSimulator(util2.GenerateUUID())
+			simulator.SetState("ns1", key, []byte("value"+strconv.Itoa(i)))
+			simulator.Done()
+			simRes, _ := simulator.GetTxSimulationResults()
+			pubSimResBytes, _ := simRes.GetPubSimulationBytes()
+			simulationResults = append(simulationResults, pubSimResBytes)
+		}
+		block := bg.NextBlock(simulationResults)
+		assert.NoError(t, store1.AddBlock(block))
+		assert.NoError(t, env.testHistoryDB.Commit(block))
+	}
+
+	store := &discardedBlockStore{BlockStore: store1, discarded: []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 2}}}
+	qhistory, err := env.testHistoryDB.NewHistoryQueryExecutor(store)
+	assert.NoError(t, err, "Error upon NewHistoryQueryExecutor")
+	testutilVerifyResults(t, qhistory, "ns1", "key7", []string{"value1", "value2", "value3", "value4"})
+	assert.Equal(t, []uint64{1, 2}, store.retrievedBlocks)
+
+	// the archived blocks are cached
+	testutilVerifyResults(t, qhistory, "ns1", "key8", []string{"value1", "value2", "value3", "value4"})
+	assert.Equal(t, []uint64{1, 2}, store.retrievedBlocks)
+}
+
+func TestArchivedBlockCache(t *testing.T) {
+	blocks := testutil.ConstructTestBlocks(t, 3)
+	retrieved := 0
+	retrieve := func(blockNum uint64) (*common.Block, error) {
+		retrieved++
+		return blocks[blockNum], nil
+	}
+
+	cache := newArchivedBlockCache(2)
+	for _, blockNum := range []uint64{0, 1, 0, 2, 0, 1} {
+		block, err := cache.get(blockNum, retrieve)
+		assert.NoError(t, err)
+		assert.Equal(t, blocks[blockNum], block)
+	}
+	// block 1 is evicted by block 2, the least recently used being block 1
+	assert.Equal(t, 4, retrieved)
+
+	// a cache of no capacity retrieves the blocks each time
+	cache = newArchivedBlockCache(0)
+	for i := 0; i < 2; i++ {
+		_, err := cache.get(0, retrieve)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 6, retrieved)
+
+	_, err := cache.get(0, func(blockNum uint64) (*common.Block, error) {
+		return nil, errors.New("repository unreachable")
+	})
+	assert.EqualError(t, err, "repository unreachable")
+}
+
 func testutilVerifyResults(t *testing.T, hqe ledger.HistoryQueryExecutor, ns, key string, expectedVals []string) {
 	itr, err := hqe.GetHistoryForKey(ns, key)
 	assert.NoError(t, err, "Error upon GetHistoryForKey()")
@@ -106,7 +106,7 @@ func newBlockStorageTestEnv(t testing.TB) *testBlockStoreEnv {
 	if err != nil {
 		panic(err)
 	}
-	conf := fsblkstorage.NewConf(testPath, 0)
+	conf := fsblkstorage.NewConf(testPath, 0, "", "")
 
 	attrsToIndex := []blkstorage.IndexableAttr{
 		blkstorage.IndexableAttrBlockHash,
@@ -41,6 +41,7 @@ type kvLedger struct {
 	configHistoryRetriever ledger.ConfigHistoryRetriever
 	blockAPIsRWLock        *sync.RWMutex
 	stats                  *ledgerStats
+	discardJobs            []*discardJob
 }
 
 // NewKVLedger constructs new `KVLedger`
@@ -73,6 +74,7 @@ func newKVLedger(
 		return nil, err
 	}
 	l.initBlockStore(btlPolicy)
+	l.initDiscardJobs(versionedDB, historyDB)
 	//Recover both state DB and history DB if they are out of sync with block storage
 	if err := l.recoverDBs(); err != nil {
 		panic(errors.WithMessage(err, "error during state DB recovery"))
@@ -199,6 +201,9 @@ func (l *kvLedger) syncStateDBWithPvtdatastore() error {
 //state DB or history DB or both
 func (l *kvLedger) recommitLostBlocks(firstBlockNum uint64, lastBlockNum uint64, recoverables ...recoverable) error {
 	logger.Infof("Recommitting lost blocks - firstBlockNum=%d, lastBlockNum=%d, recoverables=%#v", firstBlockNum, lastBlockNum, recoverables)
+	if err := l.ensureBlocksNotDiscarded(firstBlockNum, lastBlockNum); err != nil {
+		return err
+	}
 	var err error
 	var blockAndPvtdata *ledger.BlockAndPvtData
 	for blockNumber := firstBlockNum; blockNumber <= lastBlockNum; blockNumber++ {
@@ -435,6 +440,9 @@ func (l *kvLedger) GetMissingPvtDataTracker() (ledger.MissingPvtDataTracker, err
 
 // Close closes `KVLedger`
 func (l *kvLedger) Close() {
+	for _, job := range l.discardJobs {
+		job.stop()
+	}
 	l.blockStore.Shutdown()
 	l.txtmgmt.Shutdown()
 }
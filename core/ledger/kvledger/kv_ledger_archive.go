@@ -5,8 +5,15 @@ COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
 package kvledger
 
 import (
+	"time"
+
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/history/historydb"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/privacyenabledstate"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/statedb"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/pkg/errors"
 )
 
 var loggerArchive = flogging.MustGetLogger("archiver.archive")
@@ -21,3 +28,73 @@ func (l *kvLedger) SetArchived(blockFileNo int, deleteTheFile bool) error {
 
 	return nil
 }
+
+// GetArchiveCatalog returns the records of the blockfiles which have been archived
+func (l *kvLedger) GetArchiveCatalog() (blockarchive.Catalog, error) {
+	return l.blockStore.GetArchiveCatalog(), nil
+}
+
+// RestoreRange brings back onto the local file system the archived blocks of the range
+// which have been discarded, for ttl if not zero
+func (l *kvLedger) RestoreRange(firstBlockNum, lastBlockNum uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error {
+	return l.blockStore.RestoreRange(firstBlockNum, lastBlockNum, ttl, progress)
+}
+
+// ensureBlocksNotDiscarded makes sure that none of the blocks of the range, which are about to be replayed
+// to rebuild the state and history databases, has been archived and discarded. The discarded blocks are
+// restored from the repository if auto-restore is enabled, otherwise the rebuild is refused.
+func (l *kvLedger) ensureBlocksNotDiscarded(firstBlockNum, lastBlockNum uint64) error {
+	ranges, err := l.blockStore.GetArchiveCatalog().GetDiscardedRanges()
+	if err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		if r.LastBlockNum < firstBlockNum || r.FirstBlockNum > lastBlockNum {
+			continue
+		}
+		from, to := r.FirstBlockNum, r.LastBlockNum
+		if from < firstBlockNum {
+			from = firstBlockNum
+		}
+		if to > lastBlockNum {
+			to = lastBlockNum
+		}
+		if !ledgerconfig.IsAutoRestoreOnRebuildEnabled() {
+			return errors.Errorf("blocks [%d-%d] of ledger [%s] have been archived and discarded, "+
+				"restore them or enable ledger.blockArchiver.autoRestoreOnRebuild to rebuild the databases", from, to, l.ledgerID)
+		}
+		loggerArchive.Infof("[%s] Restoring archived blocks [%d-%d] to rebuild the databases", l.ledgerID, from, to)
+		if err := l.RestoreRange(from, to, ledgerconfig.GetRestoreTTL(), nil); err != nil {
+			return errors.WithMessage(err, "error restoring archived blocks")
+		}
+	}
+	return nil
+}
+
+// initDiscardJobs registers the jobs which are run each time blocks of this ledger
+// have been archived and discarded
+func (l *kvLedger) initDiscardJobs(versionedDB privacyenabledstate.DB, historyDB historydb.HistoryDB) {
+	if compactable, ok := versionedDB.(statedb.Compactable); ok && ledgerconfig.IsCouchDBEnabled() && ledgerconfig.IsCompactOnDiscardEnabled() {
+		l.addDiscardJob(newDiscardJob(l.ledgerID, "state DB compaction", ledgerconfig.GetCompactAfterNDiscards(), compactable.Compact))
+	}
+	if prunable, ok := historyDB.(historydb.Prunable); ok && ledgerconfig.IsHistoryDBEnabled() && ledgerconfig.IsHistoryPruningEnabled(l.ledgerID) {
+		l.addDiscardJob(newDiscardJob(l.ledgerID, "history DB pruning", 1, func() error {
+			return l.pruneHistoryDB(prunable)
+		}))
+	}
+}
+
+func (l *kvLedger) addDiscardJob(job *discardJob) {
+	l.discardJobs = append(l.discardJobs, job)
+	l.blockStore.AddDiscardListener(job)
+}
+
+// pruneHistoryDB removes the history records written by the blocks which have been archived and discarded
+func (l *kvLedger) pruneHistoryDB(prunable historydb.Prunable) error {
+	ranges, err := l.blockStore.GetArchiveCatalog().GetDiscardedRanges()
+	if err != nil {
+		return err
+	}
+	_, err = prunable.PruneBlocks(ranges)
+	return err
+}
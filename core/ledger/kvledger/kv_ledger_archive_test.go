@@ -0,0 +1,57 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package kvledger
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	lgr "github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuildRefusedOverDiscardedBlocks(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	// Each block goes to a blockfile of its own
+	prevMaxBlockfileSize := viper.Get("ledger.maxBlockfileSize")
+	viper.Set("ledger.maxBlockfileSize", 1)
+	defer viper.Set("ledger.maxBlockfileSize", prevMaxBlockfileSize)
+	prevBlockStorePath := blockarchive.BlockStorePath
+	blockarchive.BlockStorePath = ledgerconfig.GetBlockStorePath()
+	defer func() { blockarchive.BlockStorePath = prevBlockStorePath }()
+	provider := testutilNewProvider(t)
+	defer provider.Close()
+
+	bg, gb := testutil.NewBlockGenerator(t, "testLedger", false)
+	l, err := provider.Create(gb)
+	require.NoError(t, err)
+	defer l.Close()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, l.CommitWithPvtData(&lgr.BlockAndPvtData{Block: bg.NextBlock([][]byte{{byte(i)}})}))
+	}
+
+	prevIsClient := blockarchive.IsClient
+	blockarchive.IsClient = true
+	defer func() { blockarchive.IsClient = prevIsClient }()
+	require.NoError(t, l.SetArchived(2, true))
+
+	kvl := l.(*kvLedger)
+	ranges, err := kvl.blockStore.GetArchiveCatalog().GetDiscardedRanges()
+	require.NoError(t, err)
+	require.Len(t, ranges, 1)
+	discarded := ranges[0].FirstBlockNum
+
+	err = kvl.recommitLostBlocks(0, 3)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "have been archived and discarded")
+
+	assert.NoError(t, kvl.ensureBlocksNotDiscarded(discarded+1, 3))
+	assert.NoError(t, kvl.ensureBlocksNotDiscarded(0, discarded-1))
+}
@@ -0,0 +1,82 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package kvledger
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+)
+
+// discardJob runs a job in the background after blocks of a channel have been archived and discarded,
+// so that the resources bound to the discarded blocks are reclaimed in step with the blockstore archiving.
+// It implements interface blockarchive.DiscardListener.
+type discardJob struct {
+	ledgerID     string
+	name         string
+	run          func() error
+	runAfterN    int
+	numDiscarded int
+	trigger      chan struct{}
+	done         chan struct{}
+	mutex        sync.Mutex
+}
+
+func newDiscardJob(ledgerID, name string, runAfterN int, run func() error) *discardJob {
+	if runAfterN < 1 {
+		runAfterN = 1
+	}
+	j := &discardJob{
+		ledgerID:  ledgerID,
+		name:      name,
+		run:       run,
+		runAfterN: runAfterN,
+		trigger:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	go j.loop()
+	return j
+}
+
+// HandleBlockfileDiscarded implements function from interface blockarchive.DiscardListener.
+// The job is triggered every runAfterN discarded blockfiles. The triggers received
+// while the job is in progress are coalesced into a single subsequent run.
+func (j *discardJob) HandleBlockfileDiscarded(info *archive.ArchivedBlockfileInfo) {
+	j.mutex.Lock()
+	j.numDiscarded++
+	if j.numDiscarded < j.runAfterN {
+		j.mutex.Unlock()
+		return
+	}
+	j.numDiscarded = 0
+	j.mutex.Unlock()
+
+	loggerArchive.Infof("[%s] Blocks [%d-%d] have been discarded, triggering %s",
+		j.ledgerID, info.FirstBlockNum, info.LastBlockNum, j.name)
+	select {
+	case j.trigger <- struct{}{}:
+	default:
+		loggerArchive.Debugf("[%s] %s is already pending", j.ledgerID, j.name)
+	}
+}
+
+func (j *discardJob) loop() {
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-j.trigger:
+			if err := j.run(); err != nil {
+				loggerArchive.Errorf("[%s] Failed %s: %+v", j.ledgerID, j.name, err)
+				continue
+			}
+			loggerArchive.Infof("[%s] Completed %s", j.ledgerID, j.name)
+		}
+	}
+}
+
+func (j *discardJob) stop() {
+	close(j.done)
+}
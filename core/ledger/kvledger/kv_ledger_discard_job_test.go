@@ -0,0 +1,41 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package kvledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscardJob(t *testing.T) {
+	runs := make(chan struct{}, 10)
+	job := newDiscardJob("testLedger", "test job", 2, func() error {
+		runs <- struct{}{}
+		return nil
+	})
+	defer job.stop()
+
+	info := &archive.ArchivedBlockfileInfo{ChannelID: "testLedger", FirstBlockNum: 0, LastBlockNum: 9}
+
+	// The first discard does not reach the threshold
+	job.HandleBlockfileDiscarded(info)
+	select {
+	case <-runs:
+		t.Fatal("job should not have been run")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The second discard triggers the job
+	job.HandleBlockfileDiscarded(info)
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job should have been run")
+	}
+	assert.Equal(t, 0, job.numDiscarded)
+}
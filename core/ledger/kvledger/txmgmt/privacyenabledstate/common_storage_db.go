@@ -145,6 +145,15 @@ func (s *CommonStorageDB) ClearCachedVersions() {
 	}
 }
 
+// Compact compacts the underlying db if it supports compaction
+func (s *CommonStorageDB) Compact() error {
+	compactable, ok := s.VersionedDB.(statedb.Compactable)
+	if !ok {
+		return nil
+	}
+	return compactable.Compact()
+}
+
 // GetChaincodeEventListener implements corresponding function in interface DB
 func (s *CommonStorageDB) GetChaincodeEventListener() cceventmgmt.ChaincodeLifecycleEventListener {
 	_, ok := s.VersionedDB.(statedb.IndexCapable)
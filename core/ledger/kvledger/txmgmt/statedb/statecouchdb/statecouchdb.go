@@ -606,6 +606,34 @@ func (vdb *VersionedDB) ClearCachedVersions() {
 	vdb.committedDataCache = newVersionCache()
 }
 
+// Compact implements method in Compactable interface.
+// It requests CouchDB to compact the metadata database and all the namespace databases of the channel,
+// including the ones not opened by this instance since the peer started, and to clean up the indexes
+// no longer in use.
+func (vdb *VersionedDB) Compact() error {
+	dbNames, err := vdb.couchInstance.RetrieveApplicationDBNames()
+	if err != nil {
+		return errors.WithMessagef(err, "failed to list the databases of channel [%s]", vdb.chainName)
+	}
+	dbs := []*couchdb.CouchDatabase{vdb.metadataDB}
+	for _, dbName := range dbNames {
+		if dbName != vdb.metadataDB.DBName && couchdb.IsNamespaceDBNameOf(vdb.chainName, dbName) {
+			dbs = append(dbs, &couchdb.CouchDatabase{CouchInstance: vdb.couchInstance, DBName: dbName})
+		}
+	}
+
+	for _, db := range dbs {
+		logger.Debugf("[%s] Compacting database [%s]", vdb.chainName, db.DBName)
+		if _, err := db.CompactDatabase(); err != nil {
+			return errors.WithMessagef(err, "failed to compact database [%s]", db.DBName)
+		}
+		if _, err := db.CleanupViews(); err != nil {
+			return errors.WithMessagef(err, "failed to clean up views of database [%s]", db.DBName)
+		}
+	}
+	return nil
+}
+
 // Open implements method in VersionedDB interface
 func (vdb *VersionedDB) Open() error {
 	// no need to open db since a shared couch instance is used
@@ -88,6 +88,12 @@ type IndexCapable interface {
 	ProcessIndexesForChaincodeDeploy(namespace string, fileEntries []*ccprovider.TarFileEntry) error
 }
 
+//Compactable interface provides additional functions for
+//databases capable of reclaiming the space used by the stale data
+type Compactable interface {
+	Compact() error
+}
+
 // CompositeKey encloses Namespace and Key components
 type CompositeKey struct {
 	Namespace string
@@ -8,10 +8,12 @@ package ledger
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric-lib-go/healthz"
 	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/metrics"
 	"github.com/hyperledger/fabric/protos/common"
 	"github.com/hyperledger/fabric/protos/ledger/rwset"
@@ -94,6 +96,12 @@ type PeerLedger interface {
 	// - (Not implemented) Leave a persist record which indicate that N th data chunk has been archived
 	// This interface is used from gossip when receiving a message notifying that an archive has been done.
 	SetArchived(dataChunkNo int, deleteTheChunk bool) error
+	// GetArchiveCatalog returns the records of the data chunks which have been archived
+	GetArchiveCatalog() (blockarchive.Catalog, error)
+	// RestoreRange brings back onto the local file system the data chunks containing the blocks
+	// of the range which have been archived and discarded. If ttl is not zero, the data chunks
+	// are discarded again once it has elapsed. progress, if not nil, is notified as the data chunks are restored.
+	RestoreRange(firstBlockNum, lastBlockNum uint64, ttl time.Duration, progress blockarchive.RestoreProgress) error
 }
 
 // ValidatedLedger represents the 'final ledger' after filtering out invalid transactions from PeerLedger.
@@ -8,8 +8,11 @@ package ledgerconfig
 
 import (
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/hyperledger/fabric/core/config"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
@@ -35,32 +38,227 @@ const confPvtdataStore = "pvtdataStore"
 const confTotalQueryLimit = "ledger.state.totalQueryLimit"
 const confInternalQueryLimit = "ledger.state.couchDBConfig.internalQueryLimit"
 const confEnableHistoryDatabase = "ledger.history.enableHistoryDatabase"
+const confPruneArchivedHistory = "ledger.history.pruneArchivedBlocks"
+const confHistoryChannels = "ledger.history.channels"
+const confArchivedBlockCacheSize = "ledger.history.archivedBlockCacheSize"
 const confMaxBatchSize = "ledger.state.couchDBConfig.maxBatchUpdateSize"
 const confAutoWarmIndexes = "ledger.state.couchDBConfig.autoWarmIndexes"
 const confWarmIndexesAfterNBlocks = "ledger.state.couchDBConfig.warmIndexesAfterNBlocks"
+const confCompactOnDiscard = "ledger.state.couchDBConfig.compactOnDiscard"
+const confCompactAfterNDiscards = "ledger.state.couchDBConfig.compactAfterNDiscards"
 
 var confCollElgProcMaxDbBatchSize = &conf{"ledger.pvtdataStore.collElgProcMaxDbBatchSize", 5000}
 var confCollElgProcDbBatchesInterval = &conf{"ledger.pvtdataStore.collElgProcDbBatchesInterval", 1000}
 
+// The maximum number of archived data chunks retrieved from the block archiving repository at the same time
+var confMaxConcurrentRetrievals = &conf{"ledger.blockArchiver.maxConcurrentRetrievals", 4}
+
+// The number of archived data chunks of a range downloaded at the same time by a restore
+var confRestoreParallelism = &conf{"ledger.blockArchiver.restoreParallelism", 4}
+
+// The maximum number of retrievals of archived data chunks buffered in memory at the same time
+var confMaxBufferedRetrievals = &conf{"ledger.blockArchiver.maxBufferedRetrievals", 8}
+
+// The expected bandwidth to the repository in MB/s, used to estimate the time of archiving
+var confArchivingBandwidth = &conf{"ledger.blockArchiver.bandwidth", 10}
+
 // The maximum size of each data chunk which puts together a certain amount of blocks
 const confMaxBlockfileSize = "ledger.maxBlockfileSize"
 
+// The channel specific settings of the block archiver, in ledger.blockArchiver.channels.<channel>
+const confBlockArchiverChannels = "ledger.blockArchiver.channels"
+
 // URL of the block archiving repository
 const confBlockArchiverURL = "ledger.blockArchiver.url"
 
 // PATH where archived data chunks are stored on the block archiving repository
 const confBlockArchiverDir = "ledger.blockArchiver.dir"
 
+// Template of the paths of the archived data chunks on the block archiving repository
+const confBlockArchiverObjectKeyTemplate = "ledger.blockArchiver.objectKeyTemplate"
+
+// Whether the archived blockfiles are stored on the repository under the hash of their content
+const confContentAddressed = "ledger.blockArchiver.contentAddressed"
+
+// The logical environment of the network, e.g. dev, test or prod, recorded with the archived blockfiles
+const confBlockArchiverEnvironment = "ledger.blockArchiver.environment"
+
+// Whether the blockfiles are archived under the network ID and the environment on the repository
+const confNamespaceByNetwork = "ledger.blockArchiver.namespaceByNetwork"
+
+// The algorithm of the checksums of the archived blockfiles
+const confChecksumAlgorithm = "ledger.blockArchiver.checksumAlgorithm"
+
+const defaultChecksumAlgorithm = "sha256"
+
+// Whether the archived data chunks needed to rebuild the state and history databases are restored automatically
+const confAutoRestoreOnRebuild = "ledger.blockArchiver.autoRestoreOnRebuild"
+
+// How long the restored data chunks are kept on the local file system before being discarded again
+const confRestoreTTL = "ledger.blockArchiver.restoreTTL"
+
+// How long the shutdown of the peer waits for the data chunks being archived
+const confDrainTimeout = "ledger.blockArchiver.drainTimeout"
+
+// The file holding the API token with which the peer authenticates to the block archiving repository
+const confBlockArchiverTokenFile = "ledger.blockArchiver.tokenFile"
+
+// The free disk space of the block store in MB below which the archiving is considered to fall behind
+const confMinFreeDiskSpace = "ledger.blockArchiver.backpressure.minFreeDiskSpace"
+
+// Whether the commit of the blocks pauses while the free disk space is below minFreeDiskSpace
+const confThrottleCommit = "ledger.blockArchiver.backpressure.throttleCommit"
+
+// The longest a commit pauses for the free disk space
+const confMaxCommitPause = "ledger.blockArchiver.backpressure.maxCommitPause"
+
+// The least time since the last block of an archived data chunk was committed for the local one to be discarded
+const confMinBlockAgeBeforeDiscard = "ledger.blockArchiver.minBlockAgeBeforeDiscard"
+
+// Whether the local data chunks are discarded only once the repository has locked the archived ones
+const confObjectLockRequired = "ledger.blockArchiver.objectLock.required"
+
+// The least time an archived data chunk must remain locked on the repository for the local one to be discarded
+const confObjectLockMinRetention = "ledger.blockArchiver.objectLock.minRetention"
+
+// Whether the data chunks read from the repository are verified against their signed manifest
+const confVerifySignatures = "ledger.blockArchiver.verifySignatures"
+
+// Whether the config blocks and the genesis block are kept locally when their data chunk is discarded
+const confRetainConfigBlocks = "ledger.blockArchiver.retainConfigBlocks"
+
+// The interval at which the archive catalog is reconciled with the repository
+const confReconciliationInterval = "ledger.blockArchiver.reconciliation.interval"
+
+// Whether the archived data chunks missing from the repository are uploaded again by the reconciliation
+const confReconciliationAutoHeal = "ledger.blockArchiver.reconciliation.autoHeal"
+
+// The database storing the archive catalog, goleveldb or CouchDB
+const confCatalogDatabase = "ledger.blockArchiver.catalog.database"
+
+// The interval at which a snapshot of the archive catalog is taken
+const confCatalogSnapshotInterval = "ledger.blockArchiver.catalog.snapshots.interval"
+
+// The number of snapshots of the archive catalog kept per channel
+const confCatalogSnapshotsKept = "ledger.blockArchiver.catalog.snapshots.keep"
+
+// Whether a corrupted archive catalog is recovered from its last snapshot and the repository when a channel is opened
+const confCatalogRecovery = "ledger.blockArchiver.catalog.recovery"
+
+// The number of data chunks eligible for archiving beyond the kept ones from which the archiver catches up with its backlog
+const confCatchUpThreshold = "ledger.blockArchiver.catchUp.threshold"
+
+// The number of data chunks uploaded at the same time while the archiver catches up with its backlog
+var confCatchUpParallelism = &conf{"ledger.blockArchiver.catchUp.parallelism", 4}
+
+// The bandwidth in MB/s shared by the uploads of the catch-up
+const confCatchUpMaxBandwidth = "ledger.blockArchiver.catchUp.maxBandwidth"
+
+// Whether the archived data chunks are verified against their checksums before their local copy is discarded
+const confDiscardVerificationEnabled = "ledger.blockArchiver.discardVerification.enabled"
+
+// The interval of the data chunks verified in full by downloading them, the others are verified by the digest of the repository
+var confDiscardVerificationFullEvery = &conf{"ledger.blockArchiver.discardVerification.fullEvery", 1}
+
+// The algorithm the data chunks are compressed with before they are uploaded, none, gzip or snappy
+const confCompressionAlgorithm = "ledger.blockArchiver.compression.algorithm"
+
+// The compression level of the data chunks, auto to pick it from the upload bandwidth and the compression speed
+const confCompressionLevel = "ledger.blockArchiver.compression.level"
+
+// The directory of the keys the data chunks are encrypted with, a file per key named by the key ID
+const confEncryptionKeyDir = "ledger.blockArchiver.encryption.keyDir"
+
+// The ID of the key the data chunks are encrypted with before they are uploaded, not encrypted when empty
+const confEncryptionActiveKey = "ledger.blockArchiver.encryption.activeKey"
+
+// Whether the archive catalog and the local data chunks are checked to cover all the blocks when a channel is opened
+const confCoverageCheckEnabled = "ledger.blockArchiver.coverageCheck.enabled"
+
+// Whether a channel whose blocks are not all covered is refused to start
+const confCoverageCheckStrict = "ledger.blockArchiver.coverageCheck.strict"
+
+// Whether the repositories are probed with the credentials of the peer when it starts
+const confRepositoryProbe = "ledger.blockArchiver.validation.probeRepository"
+
+// Whether the retrievals of the archived blocks served by the peer are recorded in the access audit log
+const confAccessAuditEnabled = "ledger.blockArchiver.accessAudit.enabled"
+
+// The file of the access audit log
+const confAccessAuditFile = "ledger.blockArchiver.accessAudit.file"
+
+// The size in MB beyond which the access audit log is rotated
+var confAccessAuditMaxSize = &conf{"ledger.blockArchiver.accessAudit.maxSize", 100}
+
+// The number of rotated access audit logs kept, 0 to keep all of them
+const confAccessAuditMaxBackups = "ledger.blockArchiver.accessAudit.maxBackups"
+
+// Whether the retrievals are also logged and counted on the operations endpoint
+const confAccessAuditEmit = "ledger.blockArchiver.accessAudit.emit"
+
+// The URL of the HTTP API of the repository through which the archived data chunks are verified
+const confBlockArchiverAPIURL = "ledger.blockArchiver.apiURL"
+
+// The URL of the HTTP proxy through which the data chunks are transferred to and from the repository
+const confBlockArchiverProxyURL = "ledger.blockArchiver.proxy.url"
+
+// The hosts of the repositories reached without the proxy
+const confBlockArchiverNoProxy = "ledger.blockArchiver.proxy.noProxy"
+
+// The type of the block archiving repository, sftp or hdfs
+const confBlockArchiverType = "ledger.blockArchiver.type"
+
+// The HDFS cluster the data chunks are archived to through its WebHDFS API
+const confBlockArchiverHDFSNamenodes = "ledger.blockArchiver.hdfs.namenodes"
+const confBlockArchiverHDFSBasePath = "ledger.blockArchiver.hdfs.basePath"
+const confBlockArchiverHDFSUser = "ledger.blockArchiver.hdfs.user"
+
+// The Kerberos authentication to the namenodes of the HDFS cluster
+const confBlockArchiverHDFSKeytab = "ledger.blockArchiver.hdfs.kerberos.keytab"
+const confBlockArchiverHDFSPrincipal = "ledger.blockArchiver.hdfs.kerberos.principal"
+const confBlockArchiverHDFSKrb5Conf = "ledger.blockArchiver.hdfs.kerberos.krb5Conf"
+const confBlockArchiverHDFSServicePrincipal = "ledger.blockArchiver.hdfs.kerberos.servicePrincipal"
+
+// The bundle of root CAs trusted by the client transport of the repository
+const confBlockArchiverCABundle = "ledger.blockArchiver.caBundle"
+
+// The TLS settings of the client transport of the repository
+const confBlockArchiverTLSMinVersion = "ledger.blockArchiver.tls.minVersion"
+const confBlockArchiverTLSCipherSuites = "ledger.blockArchiver.tls.cipherSuites"
+const confBlockArchiverTLSClientCert = "ledger.blockArchiver.tls.clientCert.file"
+const confBlockArchiverTLSClientKey = "ledger.blockArchiver.tls.clientKey.file"
+const confBlockArchiverTLSCABundle = "ledger.blockArchiver.tls.caBundle"
+
 // The number of data chunks archived on each archiving opportunity at once
 const confArchiverEach = "peer.archiver.each"
 
 // The least number of data chunks which a peer node should keep on local file system
 const confArchiverKeep = "peer.archiver.keep"
 
+// The cron schedule of the archiving passes
+const confArchiverSchedule = "peer.archiver.schedule"
+
+// Whether the data chunks are archived only by the scheduled archiving passes
+const confArchiverScheduleOnly = "peer.archiver.scheduleOnly"
+
+// The cron schedule of the discards of the archived data chunks, deferred after their archiving
+const confArchiverDiscardSchedule = "peer.archiver.discard.schedule"
+
+// The usage in percent of the file system of the block store above which the archived data chunks are discarded
+const confArchiverDiscardDiskUsageThreshold = "peer.archiver.discard.diskUsageThreshold"
+
+// The idle time after which the tail of the data chunk being written is archived
+const confArchiverTailIdleTime = "peer.archiver.tailIdleTime"
+
+// Whether the data chunks are archived by the peer-archiver sidecar rather than by the peer
+const confArchiverSidecar = "peer.archiver.sidecar.enabled"
+
 const defaultBlockArchiverURL = "ledger-bank:222"
 const defaultBlockArchiverDir = "/tmp"
 const defaultArchiverEach = 30
 const defaultArchiverKeep = 10
+const defaultDrainTimeout = 30 * time.Second
+const defaultMaxCommitPause = 5 * time.Minute
 
 // GetRootPath returns the filesystem path.
 // All ledger related contents are expected to be stored under this path
@@ -118,6 +316,52 @@ func GetMaxBlockfileSize() int {
 	return maxBlockfileSize
 }
 
+// GetChannelMaxBlockfileSize returns the maximum size of the block files of a channel.
+// The channel specific value in ledger.blockArchiver.channels.<channel>.maxBlockfileSize
+// takes precedence over ledger.maxBlockfileSize
+func GetChannelMaxBlockfileSize(channelID string) int {
+	channelKey := confBlockArchiverChannels + "." + channelID + ".maxBlockfileSize"
+	if viper.IsSet(channelKey) {
+		if maxBlockfileSize := viper.GetInt(channelKey); maxBlockfileSize > 0 {
+			return maxBlockfileSize
+		}
+	}
+	return GetMaxBlockfileSize()
+}
+
+// IsChannelFetchEnabled tells if a client peer may retrieve the archived blocks of a channel which it has
+// discarded, from the repository or through the archiver peer. The channel specific value in
+// ledger.blockArchiver.channels.<channel>.fetchEnabled defaults to true.
+func IsChannelFetchEnabled(channelID string) bool {
+	channelKey := confBlockArchiverChannels + "." + channelID + ".fetchEnabled"
+	if viper.IsSet(channelKey) {
+		return viper.GetBool(channelKey)
+	}
+	return true
+}
+
+// GetRepositoryChannels returns the channels which have their own repository in
+// ledger.blockArchiver.channels.<channel>.url, or of their own type, sorted by name
+func GetRepositoryChannels() []string {
+	var channelIDs []string
+	for channelID, settings := range viper.GetStringMap(confBlockArchiverChannels) {
+		settings := cast.ToStringMap(settings)
+		if cast.ToString(settings["url"]) != "" || cast.ToString(settings["type"]) != "" {
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+	sort.Strings(channelIDs)
+	return channelIDs
+}
+
+// GetChannelRepository returns the URL and the type of the repository of a channel in
+// ledger.blockArchiver.channels.<channel>.url and type, empty when the channel is archived to
+// the repository of ledger.blockArchiver.url
+func GetChannelRepository(channelID string) (url string, repositoryType string) {
+	channelKey := confBlockArchiverChannels + "." + channelID
+	return viper.GetString(channelKey + ".url"), viper.GetString(channelKey + ".type")
+}
+
 // GetTotalQueryLimit exposes the totalLimit variable
 func GetTotalQueryLimit() int {
 	totalQueryLimit := viper.GetInt(confTotalQueryLimit)
@@ -183,6 +427,31 @@ func IsHistoryDBEnabled() bool {
 	return viper.GetBool(confEnableHistoryDatabase)
 }
 
+//IsHistoryPruningEnabled exposes the pruneArchivedBlocks variable for a channel.
+//The channel specific value in ledger.history.channels.<channel> takes precedence
+//over the value for all the channels, and if neither is set, the return false
+func IsHistoryPruningEnabled(channelID string) bool {
+	channelKey := confHistoryChannels + "." + channelID + ".pruneArchivedBlocks"
+	if viper.IsSet(channelKey) {
+		return viper.GetBool(channelKey)
+	}
+	return viper.GetBool(confPruneArchivedHistory)
+}
+
+//GetArchivedBlockCacheSize returns the number of archived blocks kept in memory by each channel
+//to answer the history queries about the blocks discarded from the local file system.
+//If unset, it defaults to 16, and 0 disables the cache.
+func GetArchivedBlockCacheSize() int {
+	if !viper.IsSet(confArchivedBlockCacheSize) {
+		return 16
+	}
+	archivedBlockCacheSize := viper.GetInt(confArchivedBlockCacheSize)
+	if archivedBlockCacheSize < 0 {
+		return 0
+	}
+	return archivedBlockCacheSize
+}
+
 // IsQueryReadsHashingEnabled enables or disables computing of hash
 // of range query results for phantom item validation
 func IsQueryReadsHashingEnabled() bool {
@@ -216,6 +485,25 @@ func GetWarmIndexesAfterNBlocks() int {
 	return warmAfterNBlocks
 }
 
+//IsCompactOnDiscardEnabled exposes the compactOnDiscard variable
+func IsCompactOnDiscardEnabled() bool {
+	//Return the value set in core.yaml, if not set, the return false
+	if viper.IsSet(confCompactOnDiscard) {
+		return viper.GetBool(confCompactOnDiscard)
+	}
+	return false
+}
+
+//GetCompactAfterNDiscards exposes the compactAfterNDiscards variable
+func GetCompactAfterNDiscards() int {
+	compactAfterNDiscards := viper.GetInt(confCompactAfterNDiscards)
+	// if compactAfterNDiscards was unset, default to 1
+	if !viper.IsSet(confCompactAfterNDiscards) {
+		compactAfterNDiscards = 1
+	}
+	return compactAfterNDiscards
+}
+
 type conf struct {
 	Name       string
 	DefaultVal int
@@ -239,6 +527,442 @@ func GetBlockArchiverDir() string {
 	return dir
 }
 
+//GetBlockArchiverObjectKeyTemplate exposes the objectKeyTemplate variable.
+//An empty template means that the paths of the local blockfiles are reused on the repository.
+func GetBlockArchiverObjectKeyTemplate() string {
+	return viper.GetString(confBlockArchiverObjectKeyTemplate)
+}
+
+//IsAutoRestoreOnRebuildEnabled exposes the autoRestoreOnRebuild variable
+func IsAutoRestoreOnRebuildEnabled() bool {
+	//Return the value set in core.yaml, if not set, the return false
+	if viper.IsSet(confAutoRestoreOnRebuild) {
+		return viper.GetBool(confAutoRestoreOnRebuild)
+	}
+	return false
+}
+
+// GetRestoreTTL returns how long the archived blockfiles restored onto the local file system
+// are kept before being discarded again, 0 if they are kept until discarded by other means
+func GetRestoreTTL() time.Duration {
+	ttl := viper.GetDuration(confRestoreTTL)
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// GetDrainTimeout returns how long the shutdown of the peer waits for the blockfiles being
+// archived before aborting their transfers
+func GetDrainTimeout() time.Duration {
+	if !viper.IsSet(confDrainTimeout) {
+		return defaultDrainTimeout
+	}
+	timeout := viper.GetDuration(confDrainTimeout)
+	if timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
+// GetMinFreeDiskSpace returns the free disk space of the block store in bytes below which the health
+// of the peer is degraded, 0 if the check is disabled
+func GetMinFreeDiskSpace() int64 {
+	minFreeDiskSpace := int64(viper.GetInt(confMinFreeDiskSpace))
+	if minFreeDiskSpace < 0 {
+		return 0
+	}
+	return minFreeDiskSpace * 1024 * 1024
+}
+
+// IsCommitThrottlingEnabled returns whether the commit of the blocks pauses while the free disk
+// space is below the minimum
+func IsCommitThrottlingEnabled() bool {
+	return viper.GetBool(confThrottleCommit)
+}
+
+// GetMaxCommitPause returns the longest a commit pauses for the free disk space before it fails,
+// 5 minutes by default
+func GetMaxCommitPause() time.Duration {
+	pause := viper.GetDuration(confMaxCommitPause)
+	if pause <= 0 {
+		return defaultMaxCommitPause
+	}
+	return pause
+}
+
+// GetMinBlockAgeBeforeDiscard returns the least time since the last block of an archived blockfile was
+// committed for the local blockfile to be discarded, 0 if the blockfiles are discarded as soon as archived
+func GetMinBlockAgeBeforeDiscard() time.Duration {
+	age := viper.GetDuration(confMinBlockAgeBeforeDiscard)
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+// IsObjectLockRequired returns whether the local blockfiles are discarded only once the repository
+// has locked the archived ones
+func IsObjectLockRequired() bool {
+	return viper.GetBool(confObjectLockRequired)
+}
+
+// GetObjectLockMinRetention returns the least time an archived blockfile must remain locked on the
+// repository for the local one to be discarded
+func GetObjectLockMinRetention() time.Duration {
+	retention := viper.GetDuration(confObjectLockMinRetention)
+	if retention < 0 {
+		return 0
+	}
+	return retention
+}
+
+// IsSignatureVerificationEnabled returns whether the archived blockfiles read from the repository are
+// verified against their manifest signed by a member of the organization
+func IsSignatureVerificationEnabled() bool {
+	return viper.GetBool(confVerifySignatures)
+}
+
+// IsRetainConfigBlocksEnabled returns whether the config blocks and the genesis block of the discarded
+// blockfiles are kept locally, so that the channel config is read without accessing the repository
+func IsRetainConfigBlocksEnabled() bool {
+	return viper.GetBool(confRetainConfigBlocks)
+}
+
+// GetReconciliationInterval returns the interval at which the archiver peer reconciles the archive
+// catalogs with the repository, 0 if the reconciliation is not scheduled
+func GetReconciliationInterval() time.Duration {
+	interval := viper.GetDuration(confReconciliationInterval)
+	if interval < 0 {
+		return 0
+	}
+	return interval
+}
+
+// IsReconciliationAutoHealEnabled returns whether the reconciliation uploads again the archived
+// blockfiles missing from the repository which are still on the local file system
+func IsReconciliationAutoHealEnabled() bool {
+	return viper.GetBool(confReconciliationAutoHeal)
+}
+
+// GetArchiveCatalogDatabase returns the database storing the archive catalog, goleveldb by default.
+// The CouchDB database is reached with the configuration of the CouchDB state database.
+func GetArchiveCatalogDatabase() string {
+	if database := viper.GetString(confCatalogDatabase); database != "" {
+		return database
+	}
+	return "goleveldb"
+}
+
+// GetCatalogSnapshotInterval returns the interval at which a snapshot of the archive catalogs is
+// taken, 0 if no snapshot is taken
+func GetCatalogSnapshotInterval() time.Duration {
+	interval := viper.GetDuration(confCatalogSnapshotInterval)
+	if interval < 0 {
+		return 0
+	}
+	return interval
+}
+
+// GetCatalogSnapshotsKept returns the number of snapshots of the archive catalog kept per channel, 3 by default
+func GetCatalogSnapshotsKept() int {
+	if kept := viper.GetInt(confCatalogSnapshotsKept); kept > 0 {
+		return kept
+	}
+	return 3
+}
+
+// IsCatalogRecoveryEnabled returns whether the archive catalog of a channel is checked when the channel is
+// opened, and recovered from its last snapshot and the listing of the repository if it is corrupted
+func IsCatalogRecoveryEnabled() bool {
+	return viper.GetBool(confCatalogRecovery)
+}
+
+// GetCatchUpThreshold returns the number of blockfiles eligible for archiving beyond the kept ones from which
+// the archiver peer catches up with its backlog when it starts archiving, 0 if the catch-up is disabled
+func GetCatchUpThreshold() int {
+	if threshold := viper.GetInt(confCatchUpThreshold); threshold > 0 {
+		return threshold
+	}
+	return 0
+}
+
+// GetCatchUpParallelism returns the number of blockfiles uploaded at the same time while catching up
+func GetCatchUpParallelism() int {
+	parallelism := viper.GetInt(confCatchUpParallelism.Name)
+	if parallelism <= 0 {
+		parallelism = confCatchUpParallelism.DefaultVal
+	}
+	return parallelism
+}
+
+// GetCatchUpMaxBandwidth returns the bandwidth in bytes per second shared by the uploads of the catch-up,
+// 0 if it is unlimited
+func GetCatchUpMaxBandwidth() int64 {
+	maxBandwidth := int64(viper.GetInt(confCatchUpMaxBandwidth))
+	if maxBandwidth < 0 {
+		return 0
+	}
+	return maxBandwidth * 1024 * 1024
+}
+
+// GetDiscardVerification returns whether the archived blockfiles of a channel are verified against their
+// checksums before their local copy is discarded, and the interval of the blockfiles verified in full by
+// downloading them, 0 if all of them are verified by the digest of the repository. The settings of
+// ledger.blockArchiver.channels.<channel>.discardVerification override the ones of the peer.
+func GetDiscardVerification(channelID string) (bool, int) {
+	enabledKey, fullEveryKey := confDiscardVerificationEnabled, confDiscardVerificationFullEvery.Name
+	channelKey := confBlockArchiverChannels + "." + channelID + ".discardVerification"
+	if viper.IsSet(channelKey + ".enabled") {
+		enabledKey = channelKey + ".enabled"
+	}
+	if viper.IsSet(channelKey + ".fullEvery") {
+		fullEveryKey = channelKey + ".fullEvery"
+	}
+	fullEvery := confDiscardVerificationFullEvery.DefaultVal
+	if viper.IsSet(fullEveryKey) {
+		fullEvery = viper.GetInt(fullEveryKey)
+	}
+	if fullEvery < 0 {
+		fullEvery = 0
+	}
+	return viper.GetBool(enabledKey), fullEvery
+}
+
+// GetCompression returns the algorithm and the level with which the blockfiles of a channel are compressed before
+// they are uploaded, empty when they are uploaded as is or at the default level of the algorithm. The settings of
+// ledger.blockArchiver.channels.<channel>.compression override the ones of the peer.
+func GetCompression(channelID string) (algorithm string, level string) {
+	algorithmKey, levelKey := confCompressionAlgorithm, confCompressionLevel
+	channelKey := confBlockArchiverChannels + "." + channelID + ".compression"
+	if viper.IsSet(channelKey + ".algorithm") {
+		algorithmKey = channelKey + ".algorithm"
+	}
+	if viper.IsSet(channelKey + ".level") {
+		levelKey = channelKey + ".level"
+	}
+	return viper.GetString(algorithmKey), viper.GetString(levelKey)
+}
+
+// GetCompressionChannels returns the channels which have their own compression settings in
+// ledger.blockArchiver.channels.<channel>.compression, sorted by name
+func GetCompressionChannels() []string {
+	var channelIDs []string
+	for channelID, settings := range viper.GetStringMap(confBlockArchiverChannels) {
+		if _, ok := cast.ToStringMap(settings)["compression"]; ok {
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+	sort.Strings(channelIDs)
+	return channelIDs
+}
+
+// GetEncryptionKeyDir returns the directory of the keys the archived blockfiles are encrypted and decrypted with,
+// empty if no key is configured
+func GetEncryptionKeyDir() string {
+	return config.GetPath(confEncryptionKeyDir)
+}
+
+// GetEncryptionActiveKey returns the ID of the key the blockfiles are encrypted with before they are uploaded,
+// empty when they are uploaded unencrypted
+func GetEncryptionActiveKey() string {
+	return viper.GetString(confEncryptionActiveKey)
+}
+
+// IsAccessAuditEnabled returns whether the retrievals of the archived blocks and blockfiles served by the peer
+// are recorded in the access audit log
+func IsAccessAuditEnabled() bool {
+	return viper.GetBool(confAccessAuditEnabled)
+}
+
+// GetAccessAuditFile returns the file of the access audit log, accessAudit/access.log under the ledgers data
+// of the peer when it is not set
+func GetAccessAuditFile() string {
+	if file := config.GetPath(confAccessAuditFile); file != "" {
+		return file
+	}
+	return filepath.Join(GetRootPath(), "accessAudit", "access.log")
+}
+
+// GetAccessAuditRotation returns the size in bytes beyond which the access audit log is rotated, and the
+// number of rotated logs kept, 0 if all of them are kept
+func GetAccessAuditRotation() (int64, int) {
+	maxSize := viper.GetInt(confAccessAuditMaxSize.Name)
+	if maxSize <= 0 {
+		maxSize = confAccessAuditMaxSize.DefaultVal
+	}
+	maxBackups := viper.GetInt(confAccessAuditMaxBackups)
+	if maxBackups < 0 {
+		maxBackups = 0
+	}
+	return int64(maxSize) * 1024 * 1024, maxBackups
+}
+
+// IsAccessAuditEmitEnabled returns whether the retrievals recorded in the access audit log are also logged
+// by the archiver.access logger and counted by the metrics of the operations endpoint
+func IsAccessAuditEmitEnabled() bool {
+	return viper.GetBool(confAccessAuditEmit)
+}
+
+// IsRepositoryProbeEnabled returns whether the peer opens a session to each repository it archives to or
+// retrieves from when it starts, so that an unreachable repository or refused credentials fail the startup
+func IsRepositoryProbeEnabled() bool {
+	return viper.GetBool(confRepositoryProbe)
+}
+
+// IsCoverageCheckEnabled returns whether the archive catalog and the local blockfiles of a channel are
+// checked to cover all its blocks when the channel is opened
+func IsCoverageCheckEnabled() bool {
+	return viper.GetBool(confCoverageCheckEnabled)
+}
+
+// IsCoverageCheckStrict returns whether a channel whose blocks are not all covered by the archive catalog
+// and the local blockfiles is refused to start, rather than started with the gaps reported
+func IsCoverageCheckStrict() bool {
+	return viper.GetBool(confCoverageCheckStrict)
+}
+
+// GetBlockArchiverProxyURL returns the URL of the HTTP proxy through which the repository is reached,
+// empty if the repository is reached directly
+func GetBlockArchiverProxyURL() string {
+	return viper.GetString(confBlockArchiverProxyURL)
+}
+
+// GetBlockArchiverAPIURL returns the URL of the HTTP API of the repository, empty if it is not used
+func GetBlockArchiverAPIURL() string {
+	return viper.GetString(confBlockArchiverAPIURL)
+}
+
+// GetBlockArchiverNoProxy returns the hosts of the repositories which are reached without the proxy
+func GetBlockArchiverNoProxy() []string {
+	return viper.GetStringSlice(confBlockArchiverNoProxy)
+}
+
+// GetBlockArchiverType returns the type of the repository, sftp or hdfs, empty for sftp
+func GetBlockArchiverType() string {
+	return viper.GetString(confBlockArchiverType)
+}
+
+// GetBlockArchiverHDFSNamenodes returns the WebHDFS endpoints of the namenodes of the HDFS cluster, e.g.
+// https://namenode1:9871, empty if no cluster is configured
+func GetBlockArchiverHDFSNamenodes() []string {
+	return viper.GetStringSlice(confBlockArchiverHDFSNamenodes)
+}
+
+// GetBlockArchiverHDFSBasePath returns the directory of HDFS the data chunks are archived under
+func GetBlockArchiverHDFSBasePath() string {
+	return viper.GetString(confBlockArchiverHDFSBasePath)
+}
+
+// GetBlockArchiverHDFSUser returns the user the requests to the HDFS cluster are made as without Kerberos
+func GetBlockArchiverHDFSUser() string {
+	return viper.GetString(confBlockArchiverHDFSUser)
+}
+
+// GetBlockArchiverHDFSKerberos returns the keytab and the principal the peer logs in to Kerberos with to
+// access the HDFS cluster, empty if the cluster doesn't require Kerberos, along with the Kerberos configuration
+// and the service principal of the namenodes, empty for the defaults
+func GetBlockArchiverHDFSKerberos() (keytab, principal, krb5Conf, servicePrincipal string) {
+	return config.GetPath(confBlockArchiverHDFSKeytab), viper.GetString(confBlockArchiverHDFSPrincipal),
+		config.GetPath(confBlockArchiverHDFSKrb5Conf), viper.GetString(confBlockArchiverHDFSServicePrincipal)
+}
+
+// GetBlockArchiverCABundle returns the path of the PEM bundle of root CAs trusted by the client transport
+// of the repository, tls.caBundle or else caBundle, empty if the system roots are trusted
+func GetBlockArchiverCABundle() string {
+	if caBundle := config.GetPath(confBlockArchiverTLSCABundle); caBundle != "" {
+		return caBundle
+	}
+	return config.GetPath(confBlockArchiverCABundle)
+}
+
+// GetBlockArchiverTLSMinVersion returns the minimum TLS version of the client transport of the repository,
+// empty for the default
+func GetBlockArchiverTLSMinVersion() string {
+	return viper.GetString(confBlockArchiverTLSMinVersion)
+}
+
+// GetBlockArchiverTLSCipherSuites returns the names of the TLS cipher suites of the client transport of the
+// repository, none for the defaults
+func GetBlockArchiverTLSCipherSuites() []string {
+	return viper.GetStringSlice(confBlockArchiverTLSCipherSuites)
+}
+
+// GetBlockArchiverTLSClientCert returns the paths of the certificate and the key with which the client transport
+// of the repository authenticates, empty if it doesn't
+func GetBlockArchiverTLSClientCert() (string, string) {
+	return config.GetPath(confBlockArchiverTLSClientCert), config.GetPath(confBlockArchiverTLSClientKey)
+}
+
+// GetBlockArchiverTokenFile returns the path of the file holding the API token with which the peer
+// authenticates to the repository, empty if the default account of the repository is used
+func GetBlockArchiverTokenFile() string {
+	return config.GetPath(confBlockArchiverTokenFile)
+}
+
+//IsContentAddressedEnabled exposes the contentAddressed variable
+func IsContentAddressedEnabled() bool {
+	return viper.GetBool(confContentAddressed)
+}
+
+//GetBlockArchiverEnvironment exposes the environment variable, empty if the network has a single environment
+func GetBlockArchiverEnvironment() string {
+	return viper.GetString(confBlockArchiverEnvironment)
+}
+
+//IsNamespaceByNetworkEnabled exposes the namespaceByNetwork variable
+func IsNamespaceByNetworkEnabled() bool {
+	return viper.GetBool(confNamespaceByNetwork)
+}
+
+//GetChecksumAlgorithm exposes the checksumAlgorithm variable, sha256 if not set
+func GetChecksumAlgorithm() string {
+	algorithm := viper.GetString(confChecksumAlgorithm)
+	if algorithm == "" {
+		algorithm = defaultChecksumAlgorithm
+	}
+	return algorithm
+}
+
+// GetMaxConcurrentRetrievals returns the maximum number of archived blockfiles
+// which are read from the repository at the same time
+func GetMaxConcurrentRetrievals() int {
+	maxConcurrentRetrievals := viper.GetInt(confMaxConcurrentRetrievals.Name)
+	if maxConcurrentRetrievals <= 0 {
+		maxConcurrentRetrievals = confMaxConcurrentRetrievals.DefaultVal
+	}
+	return maxConcurrentRetrievals
+}
+
+// GetRestoreParallelism returns the number of archived blockfiles of a range
+// which a restore downloads from the repository at the same time
+func GetRestoreParallelism() int {
+	restoreParallelism := viper.GetInt(confRestoreParallelism.Name)
+	if restoreParallelism <= 0 {
+		restoreParallelism = confRestoreParallelism.DefaultVal
+	}
+	return restoreParallelism
+}
+
+// GetMaxBufferedRetrievals returns the maximum number of retrievals of archived blockfiles and blocks
+// which buffer their content in memory at the same time
+func GetMaxBufferedRetrievals() int {
+	maxBufferedRetrievals := viper.GetInt(confMaxBufferedRetrievals.Name)
+	if maxBufferedRetrievals <= 0 {
+		maxBufferedRetrievals = confMaxBufferedRetrievals.DefaultVal
+	}
+	return maxBufferedRetrievals
+}
+
+// GetArchivingBandwidth returns the expected bandwidth to the repository in MB/s
+func GetArchivingBandwidth() int {
+	bandwidth := viper.GetInt(confArchivingBandwidth.Name)
+	if bandwidth <= 0 {
+		bandwidth = confArchivingBandwidth.DefaultVal
+	}
+	return bandwidth
+}
+
 //GetArchivingParameters exposes parameters related to archiving/discarding
 func GetArchivingParameters() (int, int) {
 	numArchiving := viper.GetInt(confArchiverEach)
@@ -251,3 +975,43 @@ func GetArchivingParameters() (int, int) {
 	}
 	return numArchiving, numKeeping
 }
+
+// GetArchivingSchedule returns the cron expression of the schedule of the archiving passes,
+// empty if the blockfiles are only archived as they are finalized
+func GetArchivingSchedule() string {
+	return viper.GetString(confArchiverSchedule)
+}
+
+// IsArchivingScheduleOnly returns whether the blockfiles are archived only by the scheduled
+// archiving passes, rather than also each time a blockfile is finalized
+func IsArchivingScheduleOnly() bool {
+	return viper.GetBool(confArchiverScheduleOnly)
+}
+
+// GetDiscardSchedule returns the cron expression of the schedule of the discards of the archived
+// blockfiles, empty if they are not discarded on a schedule
+func GetDiscardSchedule() string {
+	return viper.GetString(confArchiverDiscardSchedule)
+}
+
+// GetDiscardDiskUsageThreshold returns the usage in percent of the file system of the block store
+// above which the archived blockfiles are discarded, 0 if the discard doesn't depend on the disk usage
+func GetDiscardDiskUsageThreshold() int {
+	return viper.GetInt(confArchiverDiscardDiskUsageThreshold)
+}
+
+// GetTailIdleTime returns the time without any block committed after which a snapshot of the blockfile
+// being written is archived, 0 if the blockfiles are archived only once finalized
+func GetTailIdleTime() time.Duration {
+	idle := viper.GetDuration(confArchiverTailIdleTime)
+	if idle < 0 {
+		return 0
+	}
+	return idle
+}
+
+// IsSidecarArchiving returns whether the blockfiles of the archiver peer are archived by the peer-archiver
+// sidecar, the peer recording and discarding the blockfiles the sidecar reports
+func IsSidecarArchiving() bool {
+	return viper.GetBool(confArchiverSidecar)
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package ledgerconfig
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
 	ledgertestutil "github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/spf13/viper"
@@ -196,6 +198,31 @@ func TestIsHistoryDBEnabledFalse(t *testing.T) {
 	assert.False(t, updatedValue) //test config returns false
 }
 
+func TestIsHistoryPruningEnabledDefault(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defaultValue := IsHistoryPruningEnabled("testchannel")
+	assert.False(t, defaultValue) //test default config is false
+}
+
+func TestIsHistoryPruningEnabledPerChannel(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	viper.Set("ledger.history.pruneArchivedBlocks", true)
+	viper.Set("ledger.history.channels.testchannel.pruneArchivedBlocks", false)
+	assert.False(t, IsHistoryPruningEnabled("testchannel"))
+	assert.True(t, IsHistoryPruningEnabled("otherchannel"))
+}
+
+func TestGetArchivedBlockCacheSize(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 16, GetArchivedBlockCacheSize())
+	viper.Set("ledger.history.archivedBlockCacheSize", 0)
+	assert.Equal(t, 0, GetArchivedBlockCacheSize())
+	viper.Set("ledger.history.archivedBlockCacheSize", 64)
+	assert.Equal(t, 64, GetArchivedBlockCacheSize())
+}
+
 func TestIsAutoWarmIndexesEnabledDefault(t *testing.T) {
 	setUpCoreYAMLConfig()
 	defaultValue := IsAutoWarmIndexesEnabled()
@@ -244,11 +271,553 @@ func TestGetWarmIndexesAfterNBlocks(t *testing.T) {
 	assert.Equal(t, 10, updatedValue)
 }
 
+func TestIsCompactOnDiscardEnabledDefault(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defaultValue := IsCompactOnDiscardEnabled()
+	assert.False(t, defaultValue) //test default config is false
+}
+
+func TestIsCompactOnDiscardEnabledUnset(t *testing.T) {
+	viper.Reset()
+	defaultValue := IsCompactOnDiscardEnabled()
+	assert.False(t, defaultValue) //test default config is false
+}
+
+func TestIsCompactOnDiscardEnabledTrue(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	viper.Set("ledger.state.couchDBConfig.compactOnDiscard", true)
+	updatedValue := IsCompactOnDiscardEnabled()
+	assert.True(t, updatedValue) //test config returns true
+}
+
+func TestGetCompactAfterNDiscardsUnset(t *testing.T) {
+	viper.Reset()
+	defaultValue := GetCompactAfterNDiscards()
+	assert.Equal(t, 1, defaultValue)
+}
+
+func TestGetCompactAfterNDiscards(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	viper.Set("ledger.state.couchDBConfig.compactAfterNDiscards", 5)
+	updatedValue := GetCompactAfterNDiscards()
+	assert.Equal(t, 5, updatedValue)
+}
+
 func TestGetMaxBlockfileSize(t *testing.T) {
 	assert.Equal(t, 67108864, GetMaxBlockfileSize())
 }
 
+func TestGetChannelMaxBlockfileSize(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, GetMaxBlockfileSize(), GetChannelMaxBlockfileSize("testchannel"))
+	viper.Set("ledger.maxBlockfileSize", 1024)
+	viper.Set("ledger.blockArchiver.channels.testchannel.maxBlockfileSize", 4096)
+	assert.Equal(t, 4096, GetChannelMaxBlockfileSize("testchannel"))
+	assert.Equal(t, 1024, GetChannelMaxBlockfileSize("otherchannel"))
+}
+
+func TestIsChannelFetchEnabled(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.True(t, IsChannelFetchEnabled("testchannel"))
+	viper.Set("ledger.blockArchiver.channels.testchannel.fetchEnabled", false)
+	assert.False(t, IsChannelFetchEnabled("testchannel"))
+	assert.True(t, IsChannelFetchEnabled("otherchannel"))
+}
+
+func TestGetChannelRepository(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Empty(t, GetRepositoryChannels())
+	url, repositoryType := GetChannelRepository("testchannel")
+	assert.Empty(t, url)
+	assert.Empty(t, repositoryType)
+	// The channels are listed from the section of the config file, whose settings are read by their keys
+	viper.Set("ledger.blockArchiver.channels", map[string]interface{}{
+		"testchannel":  map[interface{}]interface{}{"url": "eu-bank:222", "type": "sftp"},
+		"otherchannel": map[interface{}]interface{}{"fetchEnabled": false},
+	})
+	viper.Set("ledger.blockArchiver.channels.testchannel.url", "eu-bank:222")
+	viper.Set("ledger.blockArchiver.channels.testchannel.type", "sftp")
+	assert.Equal(t, []string{"testchannel"}, GetRepositoryChannels())
+	url, repositoryType = GetChannelRepository("testchannel")
+	assert.Equal(t, "eu-bank:222", url)
+	assert.Equal(t, "sftp", repositoryType)
+	// A channel archived to HDFS has a type but no URL
+	viper.Set("ledger.blockArchiver.channels", map[string]interface{}{
+		"testchannel":  map[interface{}]interface{}{"url": "eu-bank:222", "type": "sftp"},
+		"otherchannel": map[interface{}]interface{}{"type": "hdfs"},
+	})
+	assert.Equal(t, []string{"otherchannel", "testchannel"}, GetRepositoryChannels())
+}
+
 func setUpCoreYAMLConfig() {
 	//call a helper method to load the core.yaml
 	ledgertestutil.SetupCoreYAMLConfig()
 }
+
+func TestIsAutoRestoreOnRebuildEnabledDefault(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defaultValue := IsAutoRestoreOnRebuildEnabled()
+	assert.False(t, defaultValue) //test default config is false
+}
+
+func TestIsAutoRestoreOnRebuildEnabledUnset(t *testing.T) {
+	viper.Reset()
+	defaultValue := IsAutoRestoreOnRebuildEnabled()
+	assert.False(t, defaultValue) //test default config is false
+}
+
+func TestIsAutoRestoreOnRebuildEnabledTrue(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	viper.Set("ledger.blockArchiver.autoRestoreOnRebuild", true)
+	updatedValue := IsAutoRestoreOnRebuildEnabled()
+	assert.True(t, updatedValue) //test config returns true
+}
+
+func TestIsContentAddressedEnabled(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsContentAddressedEnabled())
+	viper.Set("ledger.blockArchiver.contentAddressed", true)
+	assert.True(t, IsContentAddressedEnabled())
+}
+
+func TestBlockArchiverNamespace(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverEnvironment())
+	assert.False(t, IsNamespaceByNetworkEnabled())
+	viper.Set("ledger.blockArchiver.environment", "prod")
+	viper.Set("ledger.blockArchiver.namespaceByNetwork", true)
+	assert.Equal(t, "prod", GetBlockArchiverEnvironment())
+	assert.True(t, IsNamespaceByNetworkEnabled())
+}
+
+func TestGetChecksumAlgorithm(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "sha256", GetChecksumAlgorithm())
+	viper.Set("ledger.blockArchiver.checksumAlgorithm", "blake3")
+	assert.Equal(t, "blake3", GetChecksumAlgorithm())
+	viper.Set("ledger.blockArchiver.checksumAlgorithm", "")
+	assert.Equal(t, "sha256", GetChecksumAlgorithm())
+}
+
+func TestGetRestoreTTL(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, time.Duration(0), GetRestoreTTL())
+	viper.Set("ledger.blockArchiver.restoreTTL", "72h")
+	assert.Equal(t, 72*time.Hour, GetRestoreTTL())
+	viper.Set("ledger.blockArchiver.restoreTTL", "-1h")
+	assert.Equal(t, time.Duration(0), GetRestoreTTL())
+}
+
+func TestGetDrainTimeout(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 30*time.Second, GetDrainTimeout())
+	viper.Set("ledger.blockArchiver.drainTimeout", "2m")
+	assert.Equal(t, 2*time.Minute, GetDrainTimeout())
+	viper.Set("ledger.blockArchiver.drainTimeout", "-1s")
+	assert.Equal(t, time.Duration(0), GetDrainTimeout())
+}
+
+func TestGetBlockArchiverTokenFile(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverTokenFile())
+	viper.Set("ledger.blockArchiver.tokenFile", "/etc/hyperledger/fabric/repo.token")
+	assert.Equal(t, "/etc/hyperledger/fabric/repo.token", GetBlockArchiverTokenFile())
+}
+
+func TestGetArchivingBandwidth(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 10, GetArchivingBandwidth())
+	viper.Set("ledger.blockArchiver.bandwidth", 100)
+	assert.Equal(t, 100, GetArchivingBandwidth())
+	viper.Set("ledger.blockArchiver.bandwidth", 0)
+	assert.Equal(t, 10, GetArchivingBandwidth())
+}
+
+func TestGetMaxConcurrentRetrievals(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 4, GetMaxConcurrentRetrievals())
+	viper.Set("ledger.blockArchiver.maxConcurrentRetrievals", 10)
+	assert.Equal(t, 10, GetMaxConcurrentRetrievals())
+	viper.Set("ledger.blockArchiver.maxConcurrentRetrievals", 0)
+	assert.Equal(t, 4, GetMaxConcurrentRetrievals())
+}
+
+func TestGetArchivingSchedule(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetArchivingSchedule())
+	assert.False(t, IsArchivingScheduleOnly())
+	viper.Set("peer.archiver.schedule", "30 2 * * *")
+	viper.Set("peer.archiver.scheduleOnly", true)
+	assert.Equal(t, "30 2 * * *", GetArchivingSchedule())
+	assert.True(t, IsArchivingScheduleOnly())
+}
+
+func TestGetDiscardSchedule(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetDiscardSchedule())
+	assert.Equal(t, 0, GetDiscardDiskUsageThreshold())
+	viper.Set("peer.archiver.discard.schedule", "0 3 * * 6")
+	viper.Set("peer.archiver.discard.diskUsageThreshold", 70)
+	assert.Equal(t, "0 3 * * 6", GetDiscardSchedule())
+	assert.Equal(t, 70, GetDiscardDiskUsageThreshold())
+}
+
+func TestGetTailIdleTime(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, time.Duration(0), GetTailIdleTime())
+	viper.Set("peer.archiver.tailIdleTime", "24h")
+	assert.Equal(t, 24*time.Hour, GetTailIdleTime())
+	viper.Set("peer.archiver.tailIdleTime", "-1h")
+	assert.Equal(t, time.Duration(0), GetTailIdleTime())
+}
+
+func TestIsSidecarArchiving(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsSidecarArchiving())
+	viper.Set("peer.archiver.sidecar.enabled", true)
+	assert.True(t, IsSidecarArchiving())
+}
+
+func TestGetRestoreParallelism(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 4, GetRestoreParallelism())
+	viper.Set("ledger.blockArchiver.restoreParallelism", 16)
+	assert.Equal(t, 16, GetRestoreParallelism())
+	viper.Set("ledger.blockArchiver.restoreParallelism", 0)
+	assert.Equal(t, 4, GetRestoreParallelism())
+}
+
+func TestGetMaxBufferedRetrievals(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 8, GetMaxBufferedRetrievals())
+	viper.Set("ledger.blockArchiver.maxBufferedRetrievals", 16)
+	assert.Equal(t, 16, GetMaxBufferedRetrievals())
+	viper.Set("ledger.blockArchiver.maxBufferedRetrievals", -1)
+	assert.Equal(t, 8, GetMaxBufferedRetrievals())
+}
+
+func TestGetBlockArchiverObjectKeyTemplate(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverObjectKeyTemplate())
+	viper.Set("ledger.blockArchiver.objectKeyTemplate", "{channel}/{firstBlock}-{lastBlock}.blk")
+	assert.Equal(t, "{channel}/{firstBlock}-{lastBlock}.blk", GetBlockArchiverObjectKeyTemplate())
+}
+
+func TestGetBackpressureParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, int64(0), GetMinFreeDiskSpace())
+	assert.False(t, IsCommitThrottlingEnabled())
+	assert.Equal(t, 5*time.Minute, GetMaxCommitPause())
+	viper.Set("ledger.blockArchiver.backpressure.maxCommitPause", "0s")
+	assert.Equal(t, 5*time.Minute, GetMaxCommitPause())
+
+	viper.Set("ledger.blockArchiver.backpressure.minFreeDiskSpace", 512)
+	viper.Set("ledger.blockArchiver.backpressure.throttleCommit", true)
+	viper.Set("ledger.blockArchiver.backpressure.maxCommitPause", "10m")
+	assert.Equal(t, int64(512*1024*1024), GetMinFreeDiskSpace())
+	assert.True(t, IsCommitThrottlingEnabled())
+	assert.Equal(t, 10*time.Minute, GetMaxCommitPause())
+}
+
+func TestGetMinBlockAgeBeforeDiscard(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, time.Duration(0), GetMinBlockAgeBeforeDiscard())
+
+	viper.Set("ledger.blockArchiver.minBlockAgeBeforeDiscard", "1h")
+	assert.Equal(t, time.Hour, GetMinBlockAgeBeforeDiscard())
+	viper.Set("ledger.blockArchiver.minBlockAgeBeforeDiscard", "-1h")
+	assert.Equal(t, time.Duration(0), GetMinBlockAgeBeforeDiscard())
+}
+
+func TestGetObjectLockParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsObjectLockRequired())
+	assert.Equal(t, time.Duration(0), GetObjectLockMinRetention())
+
+	viper.Set("ledger.blockArchiver.objectLock.required", true)
+	viper.Set("ledger.blockArchiver.objectLock.minRetention", "8760h")
+	assert.True(t, IsObjectLockRequired())
+	assert.Equal(t, 8760*time.Hour, GetObjectLockMinRetention())
+	viper.Set("ledger.blockArchiver.objectLock.minRetention", "-1h")
+	assert.Equal(t, time.Duration(0), GetObjectLockMinRetention())
+}
+
+func TestIsSignatureVerificationEnabled(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsSignatureVerificationEnabled())
+	viper.Set("ledger.blockArchiver.verifySignatures", true)
+	assert.True(t, IsSignatureVerificationEnabled())
+}
+
+func TestGetReconciliationParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, time.Duration(0), GetReconciliationInterval())
+	assert.False(t, IsReconciliationAutoHealEnabled())
+	viper.Set("ledger.blockArchiver.reconciliation.interval", "24h")
+	viper.Set("ledger.blockArchiver.reconciliation.autoHeal", true)
+	assert.Equal(t, 24*time.Hour, GetReconciliationInterval())
+	assert.True(t, IsReconciliationAutoHealEnabled())
+	viper.Set("ledger.blockArchiver.reconciliation.interval", "-1h")
+	assert.Equal(t, time.Duration(0), GetReconciliationInterval())
+}
+
+func TestGetArchiveCatalogDatabase(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "goleveldb", GetArchiveCatalogDatabase())
+	viper.Set("ledger.blockArchiver.catalog.database", "CouchDB")
+	assert.Equal(t, "CouchDB", GetArchiveCatalogDatabase())
+}
+
+func TestGetCatalogRecoveryParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, time.Duration(0), GetCatalogSnapshotInterval())
+	assert.Equal(t, 3, GetCatalogSnapshotsKept())
+	assert.False(t, IsCatalogRecoveryEnabled())
+	viper.Set("ledger.blockArchiver.catalog.snapshots.interval", "1h")
+	viper.Set("ledger.blockArchiver.catalog.snapshots.keep", 5)
+	viper.Set("ledger.blockArchiver.catalog.recovery", true)
+	assert.Equal(t, time.Hour, GetCatalogSnapshotInterval())
+	assert.Equal(t, 5, GetCatalogSnapshotsKept())
+	assert.True(t, IsCatalogRecoveryEnabled())
+	viper.Set("ledger.blockArchiver.catalog.snapshots.keep", 0)
+	assert.Equal(t, 3, GetCatalogSnapshotsKept())
+}
+
+func TestGetCatchUpParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, 0, GetCatchUpThreshold())
+	assert.Equal(t, 4, GetCatchUpParallelism())
+	assert.Equal(t, int64(0), GetCatchUpMaxBandwidth())
+	viper.Set("ledger.blockArchiver.catchUp.threshold", 20)
+	viper.Set("ledger.blockArchiver.catchUp.parallelism", 8)
+	viper.Set("ledger.blockArchiver.catchUp.maxBandwidth", 50)
+	assert.Equal(t, 20, GetCatchUpThreshold())
+	assert.Equal(t, 8, GetCatchUpParallelism())
+	assert.Equal(t, int64(50*1024*1024), GetCatchUpMaxBandwidth())
+	viper.Set("ledger.blockArchiver.catchUp.threshold", -1)
+	viper.Set("ledger.blockArchiver.catchUp.parallelism", 0)
+	assert.Equal(t, 0, GetCatchUpThreshold())
+	assert.Equal(t, 4, GetCatchUpParallelism())
+}
+
+func TestGetDiscardVerification(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	enabled, fullEvery := GetDiscardVerification("mychannel")
+	assert.False(t, enabled)
+	assert.Equal(t, 1, fullEvery)
+	viper.Set("ledger.blockArchiver.discardVerification.enabled", true)
+	viper.Set("ledger.blockArchiver.discardVerification.fullEvery", 10)
+	enabled, fullEvery = GetDiscardVerification("mychannel")
+	assert.True(t, enabled)
+	assert.Equal(t, 10, fullEvery)
+
+	// The settings of a channel override the ones of the peer
+	viper.Set("ledger.blockArchiver.channels.mychannel.discardVerification.fullEvery", 0)
+	enabled, fullEvery = GetDiscardVerification("mychannel")
+	assert.True(t, enabled)
+	assert.Equal(t, 0, fullEvery)
+	viper.Set("ledger.blockArchiver.channels.otherchannel.discardVerification.enabled", false)
+	enabled, fullEvery = GetDiscardVerification("otherchannel")
+	assert.False(t, enabled)
+	assert.Equal(t, 10, fullEvery)
+	viper.Set("ledger.blockArchiver.discardVerification.fullEvery", -1)
+	_, fullEvery = GetDiscardVerification("otherchannel")
+	assert.Equal(t, 0, fullEvery)
+}
+
+func TestGetCompression(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	algorithm, level := GetCompression("mychannel")
+	assert.Equal(t, "none", algorithm)
+	assert.Equal(t, "", level)
+	assert.Empty(t, GetCompressionChannels())
+	viper.Set("ledger.blockArchiver.compression.algorithm", "gzip")
+	viper.Set("ledger.blockArchiver.compression.level", "auto")
+
+	// The settings of a channel override the ones of the peer, the channels are listed from the section of the config file
+	viper.Set("ledger.blockArchiver.channels", map[string]interface{}{
+		"mychannel":    map[interface{}]interface{}{"compression": map[interface{}]interface{}{"level": 9}},
+		"otherchannel": map[interface{}]interface{}{"compression": map[interface{}]interface{}{"algorithm": "snappy", "level": ""}},
+		"thirdchannel": map[interface{}]interface{}{"maxBlockfileSize": 1024},
+	})
+	viper.Set("ledger.blockArchiver.channels.mychannel.compression.level", 9)
+	viper.Set("ledger.blockArchiver.channels.otherchannel.compression.algorithm", "snappy")
+	viper.Set("ledger.blockArchiver.channels.otherchannel.compression.level", "")
+	viper.Set("ledger.blockArchiver.channels.thirdchannel.maxBlockfileSize", 1024)
+	algorithm, level = GetCompression("mychannel")
+	assert.Equal(t, "gzip", algorithm)
+	assert.Equal(t, "9", level)
+	algorithm, level = GetCompression("otherchannel")
+	assert.Equal(t, "snappy", algorithm)
+	assert.Equal(t, "", level)
+	algorithm, level = GetCompression("thirdchannel")
+	assert.Equal(t, "gzip", algorithm)
+	assert.Equal(t, "auto", level)
+	assert.Equal(t, []string{"mychannel", "otherchannel"}, GetCompressionChannels())
+}
+
+func TestGetEncryptionParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetEncryptionKeyDir())
+	assert.Equal(t, "", GetEncryptionActiveKey())
+	viper.Set("ledger.blockArchiver.encryption.keyDir", "/etc/hyperledger/archive-keys")
+	viper.Set("ledger.blockArchiver.encryption.activeKey", "key-2026")
+	assert.Equal(t, "/etc/hyperledger/archive-keys", GetEncryptionKeyDir())
+	assert.Equal(t, "key-2026", GetEncryptionActiveKey())
+}
+
+func TestGetAccessAuditParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsAccessAuditEnabled())
+	assert.False(t, IsAccessAuditEmitEnabled())
+	assert.Equal(t, filepath.Join(GetRootPath(), "accessAudit", "access.log"), GetAccessAuditFile())
+	maxSize, maxBackups := GetAccessAuditRotation()
+	assert.Equal(t, int64(100*1024*1024), maxSize)
+	assert.Equal(t, 10, maxBackups)
+	viper.Set("ledger.blockArchiver.accessAudit.enabled", true)
+	viper.Set("ledger.blockArchiver.accessAudit.emit", true)
+	viper.Set("ledger.blockArchiver.accessAudit.file", "/var/log/peer/access.log")
+	viper.Set("ledger.blockArchiver.accessAudit.maxSize", 20)
+	viper.Set("ledger.blockArchiver.accessAudit.maxBackups", 0)
+	assert.True(t, IsAccessAuditEnabled())
+	assert.True(t, IsAccessAuditEmitEnabled())
+	assert.Equal(t, "/var/log/peer/access.log", GetAccessAuditFile())
+	maxSize, maxBackups = GetAccessAuditRotation()
+	assert.Equal(t, int64(20*1024*1024), maxSize)
+	assert.Equal(t, 0, maxBackups)
+	viper.Set("ledger.blockArchiver.accessAudit.maxSize", 0)
+	maxSize, _ = GetAccessAuditRotation()
+	assert.Equal(t, int64(100*1024*1024), maxSize)
+}
+
+func TestIsRepositoryProbeEnabled(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsRepositoryProbeEnabled())
+	viper.Set("ledger.blockArchiver.validation.probeRepository", true)
+	assert.True(t, IsRepositoryProbeEnabled())
+}
+
+func TestGetCoverageCheckParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.False(t, IsCoverageCheckEnabled())
+	assert.False(t, IsCoverageCheckStrict())
+	viper.Set("ledger.blockArchiver.coverageCheck.enabled", true)
+	viper.Set("ledger.blockArchiver.coverageCheck.strict", true)
+	assert.True(t, IsCoverageCheckEnabled())
+	assert.True(t, IsCoverageCheckStrict())
+}
+
+func TestGetBlockArchiverProxyParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverProxyURL())
+	assert.Empty(t, GetBlockArchiverNoProxy())
+	assert.Equal(t, "", GetBlockArchiverCABundle())
+	viper.Set("ledger.blockArchiver.proxy.url", "http://proxy.example.com:3128")
+	viper.Set("ledger.blockArchiver.proxy.noProxy", []string{"repo.example.com", ".internal"})
+	viper.Set("ledger.blockArchiver.caBundle", "/etc/ssl/proxy-ca.pem")
+	assert.Equal(t, "http://proxy.example.com:3128", GetBlockArchiverProxyURL())
+	assert.Equal(t, []string{"repo.example.com", ".internal"}, GetBlockArchiverNoProxy())
+	assert.Equal(t, "/etc/ssl/proxy-ca.pem", GetBlockArchiverCABundle())
+}
+
+func TestGetBlockArchiverHDFSParameters(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverType())
+	assert.Empty(t, GetBlockArchiverHDFSNamenodes())
+	keytab, principal, krb5Conf, servicePrincipal := GetBlockArchiverHDFSKerberos()
+	assert.Equal(t, []string{"", "", "", ""}, []string{keytab, principal, krb5Conf, servicePrincipal})
+	viper.Set("ledger.blockArchiver.type", "hdfs")
+	viper.Set("ledger.blockArchiver.hdfs.namenodes", []string{"https://nn1.example.com:9871", "https://nn2.example.com:9871"})
+	viper.Set("ledger.blockArchiver.hdfs.basePath", "/data/fabric")
+	viper.Set("ledger.blockArchiver.hdfs.user", "fabric")
+	viper.Set("ledger.blockArchiver.hdfs.kerberos.keytab", "/etc/security/keytabs/peer.keytab")
+	viper.Set("ledger.blockArchiver.hdfs.kerberos.principal", "peer@EXAMPLE.COM")
+	viper.Set("ledger.blockArchiver.hdfs.kerberos.krb5Conf", "/etc/krb5.conf")
+	viper.Set("ledger.blockArchiver.hdfs.kerberos.servicePrincipal", "HTTP/_HOST@EXAMPLE.COM")
+	assert.Equal(t, "hdfs", GetBlockArchiverType())
+	assert.Equal(t, []string{"https://nn1.example.com:9871", "https://nn2.example.com:9871"}, GetBlockArchiverHDFSNamenodes())
+	assert.Equal(t, "/data/fabric", GetBlockArchiverHDFSBasePath())
+	assert.Equal(t, "fabric", GetBlockArchiverHDFSUser())
+	keytab, principal, krb5Conf, servicePrincipal = GetBlockArchiverHDFSKerberos()
+	assert.Equal(t, "/etc/security/keytabs/peer.keytab", keytab)
+	assert.Equal(t, "peer@EXAMPLE.COM", principal)
+	assert.Equal(t, "/etc/krb5.conf", krb5Conf)
+	assert.Equal(t, "HTTP/_HOST@EXAMPLE.COM", servicePrincipal)
+}
+
+func TestGetBlockArchiverAPIURL(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverAPIURL())
+	viper.Set("ledger.blockArchiver.apiURL", "https://blkarchiver-repo:9445")
+	assert.Equal(t, "https://blkarchiver-repo:9445", GetBlockArchiverAPIURL())
+}
+
+func TestGetBlockArchiverTLSSettings(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.Equal(t, "", GetBlockArchiverTLSMinVersion())
+	assert.Empty(t, GetBlockArchiverTLSCipherSuites())
+	cert, key := GetBlockArchiverTLSClientCert()
+	assert.Equal(t, "", cert)
+	assert.Equal(t, "", key)
+
+	viper.Set("ledger.blockArchiver.tls.minVersion", "1.3")
+	viper.Set("ledger.blockArchiver.tls.cipherSuites", []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"})
+	viper.Set("ledger.blockArchiver.tls.clientCert.file", "/etc/archiver/tls/client.crt")
+	viper.Set("ledger.blockArchiver.tls.clientKey.file", "/etc/archiver/tls/client.key")
+	viper.Set("ledger.blockArchiver.caBundle", "/etc/ssl/proxy-ca.pem")
+	viper.Set("ledger.blockArchiver.tls.caBundle", "/etc/archiver/tls/ca.pem")
+	assert.Equal(t, "1.3", GetBlockArchiverTLSMinVersion())
+	assert.Equal(t, []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}, GetBlockArchiverTLSCipherSuites())
+	cert, key = GetBlockArchiverTLSClientCert()
+	assert.Equal(t, "/etc/archiver/tls/client.crt", cert)
+	assert.Equal(t, "/etc/archiver/tls/client.key", key)
+	// tls.caBundle takes precedence over caBundle
+	assert.Equal(t, "/etc/archiver/tls/ca.pem", GetBlockArchiverCABundle())
+}
+
+func TestIsRetainConfigBlocksEnabled(t *testing.T) {
+	setUpCoreYAMLConfig()
+	defer ledgertestutil.ResetConfigToDefaultValues()
+	assert.True(t, IsRetainConfigBlocksEnabled())
+	viper.Set("ledger.blockArchiver.retainConfigBlocks", false)
+	assert.False(t, IsRetainConfigBlocksEnabled())
+}
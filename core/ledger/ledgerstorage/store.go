@@ -54,7 +54,7 @@ func NewProvider() *Provider {
 			ledgerconfig.GetMaxBlockfileSize(),
 			ledgerconfig.GetBlockArchiverURL(),
 			ledgerconfig.GetBlockArchiverDir(),
-		),
+		).WithChannelMaxBlockfileSize(ledgerconfig.GetChannelMaxBlockfileSize),
 		indexConfig)
 
 	pvtStoreProvider := pvtdatastorage.NewProvider()
@@ -80,6 +80,34 @@ func ResetConfigToDefaultValues() {
 	viper.Set("ledger.history.enableHistoryDatabase", false)
 	viper.Set("ledger.state.couchDBConfig.autoWarmIndexes", true)
 	viper.Set("ledger.state.couchDBConfig.warmIndexesAfterNBlocks", 1)
+	viper.Set("ledger.state.couchDBConfig.compactOnDiscard", false)
+	viper.Set("ledger.state.couchDBConfig.compactAfterNDiscards", 1)
+	viper.Set("ledger.history.pruneArchivedBlocks", false)
+	viper.Set("ledger.blockArchiver.autoRestoreOnRebuild", false)
+	viper.Set("ledger.blockArchiver.objectKeyTemplate", "")
+	viper.Set("ledger.blockArchiver.maxConcurrentRetrievals", 4)
+	viper.Set("ledger.blockArchiver.contentAddressed", false)
+	viper.Set("ledger.blockArchiver.checksumAlgorithm", "sha256")
+	viper.Set("ledger.blockArchiver.bandwidth", 10)
+	viper.Set("ledger.blockArchiver.restoreTTL", 0)
+	viper.Set("ledger.blockArchiver.drainTimeout", "30s")
+	viper.Set("ledger.blockArchiver.tokenFile", "")
+	viper.Set("ledger.blockArchiver.backpressure.minFreeDiskSpace", 0)
+	viper.Set("ledger.blockArchiver.backpressure.throttleCommit", false)
+	viper.Set("ledger.blockArchiver.backpressure.maxCommitPause", "5m")
+	viper.Set("ledger.blockArchiver.minBlockAgeBeforeDiscard", "0s")
+	viper.Set("ledger.blockArchiver.objectLock.required", false)
+	viper.Set("ledger.blockArchiver.objectLock.minRetention", "0s")
+	viper.Set("ledger.blockArchiver.retainConfigBlocks", true)
+	viper.Set("ledger.blockArchiver.verifySignatures", false)
+	viper.Set("ledger.blockArchiver.reconciliation.interval", "0s")
+	viper.Set("ledger.blockArchiver.reconciliation.autoHeal", false)
+	viper.Set("ledger.blockArchiver.proxy.url", "")
+	viper.Set("ledger.blockArchiver.proxy.noProxy", []string{})
+	viper.Set("ledger.blockArchiver.caBundle", "")
+	viper.Set("ledger.history.channels", map[string]interface{}{})
+	viper.Set("ledger.maxBlockfileSize", 64*1024*1024)
+	viper.Set("ledger.blockArchiver.channels", map[string]interface{}{})
 	viper.Set("peer.fileSystemPath", "/var/hyperledger/production")
 }
 
@@ -442,6 +442,34 @@ func (couchInstance *CouchInstance) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
+// RetrieveApplicationDBNames returns the names of the databases of the CouchDB instance,
+// except the system databases whose names start with an underscore
+func (couchInstance *CouchInstance) RetrieveApplicationDBNames() ([]string, error) {
+	connectURL, err := url.Parse(couchInstance.URL())
+	if err != nil {
+		logger.Errorf("URL parse error: %s", err)
+		return nil, errors.Wrapf(err, "error parsing CouchDB URL: %s", couchInstance.URL())
+	}
+	resp, _, err := couchInstance.handleRequest(context.Background(), http.MethodGet, "", "RetrieveApplicationDBNames", connectURL, nil,
+		"", "", couchInstance.conf.MaxRetries, true, nil, "_all_dbs")
+	if err != nil {
+		return nil, errors.WithMessage(err, "error retrieving the names of the databases")
+	}
+	defer closeResponseBody(resp)
+
+	var dbNames []string
+	if err := json.NewDecoder(resp.Body).Decode(&dbNames); err != nil {
+		return nil, errors.Wrap(err, "error decoding response body")
+	}
+	var applicationDBNames []string
+	for _, dbName := range dbNames {
+		if !strings.HasPrefix(dbName, "_") {
+			applicationDBNames = append(applicationDBNames, dbName)
+		}
+	}
+	return applicationDBNames, nil
+}
+
 // URL returns the URL for the CouchDB instance.
 func (couchInstance *CouchInstance) URL() string {
 	URL := &url.URL{
@@ -548,6 +576,54 @@ func (dbclient *CouchDatabase) EnsureFullCommit() (*DBOperationResponse, error)
 	return dbResponse, errors.New("error syncing database")
 }
 
+// CompactDatabase calls _compact to reclaim the disk space used by old document revisions.
+// The compaction runs in the background on CouchDB after the request has been accepted.
+func (dbclient *CouchDatabase) CompactDatabase() (*DBOperationResponse, error) {
+	return dbclient.postMaintenanceRequest("CompactDatabase", "_compact")
+}
+
+// CleanupViews calls _view_cleanup to remove the index files which are no longer
+// required by any design document of the database
+func (dbclient *CouchDatabase) CleanupViews() (*DBOperationResponse, error) {
+	return dbclient.postMaintenanceRequest("CleanupViews", "_view_cleanup")
+}
+
+func (dbclient *CouchDatabase) postMaintenanceRequest(functionName, endpoint string) (*DBOperationResponse, error) {
+	dbName := dbclient.DBName
+
+	logger.Debugf("[%s] Entering %s()", dbName, functionName)
+
+	connectURL, err := url.Parse(dbclient.CouchInstance.URL())
+	if err != nil {
+		logger.Errorf("URL parse error: %s", err)
+		return nil, errors.Wrapf(err, "error parsing CouchDB URL: %s", dbclient.CouchInstance.URL())
+	}
+
+	//get the number of retries
+	maxRetries := dbclient.CouchInstance.conf.MaxRetries
+
+	resp, _, err := dbclient.handleRequest(http.MethodPost, functionName, connectURL, nil, "", "", maxRetries, true, nil, endpoint)
+	if err != nil {
+		logger.Errorf("Failed to invoke couchdb %s. Error: %+v", endpoint, err)
+		return nil, err
+	}
+	defer closeResponseBody(resp)
+
+	dbResponse := &DBOperationResponse{}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&dbResponse)
+	if decodeErr != nil {
+		return nil, errors.Wrap(decodeErr, "error decoding response body")
+	}
+
+	logger.Debugf("[%s] Exiting %s()", dbName, functionName)
+
+	if dbResponse.Ok == true {
+		return dbResponse, nil
+	}
+
+	return dbResponse, errors.Errorf("error invoking %s on database", endpoint)
+}
+
 //SaveDoc method provides a function to save a document, id and byte array
 func (dbclient *CouchDatabase) SaveDoc(id string, rev string, couchDoc *CouchDoc) (string, error) {
 	dbName := dbclient.DBName
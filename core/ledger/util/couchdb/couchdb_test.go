@@ -291,6 +291,39 @@ func TestDBCreateEnsureFullCommit(t *testing.T) {
 	assert.NoError(t, commiterr, "Error when trying to ensure a full commit")
 }
 
+func TestDBCompactAndCleanupViews(t *testing.T) {
+
+	database := "testdbcompact"
+	err := cleanup(database)
+	assert.NoError(t, err, "Error when trying to cleanup  Error: %s", err)
+	defer cleanup(database)
+
+	//create a new instance and database object
+	couchInstance, err := CreateCouchInstance(couchDBDef, &disabled.Provider{})
+	assert.NoError(t, err, "Error when trying to create couch instance")
+	db := CouchDatabase{CouchInstance: couchInstance, DBName: database}
+
+	//create a new database
+	errdb := db.CreateDatabaseIfNotExist()
+	assert.NoError(t, errdb, "Error when trying to create database")
+
+	//Save the test document
+	_, saveerr := db.SaveDoc("2", "", &CouchDoc{JSONValue: assetJSON, Attachments: nil})
+	assert.NoError(t, saveerr, "Error when trying to save a document")
+
+	//Compact the database and clean up the indexes
+	_, compacterr := db.CompactDatabase()
+	assert.NoError(t, compacterr, "Error when trying to compact the database")
+	_, cleanuperr := db.CleanupViews()
+	assert.NoError(t, cleanuperr, "Error when trying to clean up the views")
+
+	//The database is listed among the application databases
+	dbNames, listerr := couchInstance.RetrieveApplicationDBNames()
+	assert.NoError(t, listerr, "Error when trying to retrieve the names of the databases")
+	assert.Contains(t, dbNames, database)
+	assert.NotContains(t, dbNames, "_users")
+}
+
 func TestDBBadDatabaseName(t *testing.T) {
 
 	//create a new instance and database object using a valid database name mixed case
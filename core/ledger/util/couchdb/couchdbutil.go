@@ -248,6 +248,18 @@ func ConstructNamespaceDBName(chainName, namespace string) string {
 	return namespaceDBName
 }
 
+// IsNamespaceDBNameOf returns whether dbName is the name of a namespace database of the chain, as
+// constructed by ConstructNamespaceDBName and mapped by CreateCouchDatabase, whether truncated or not.
+// The metadata database of the chain, named as the empty namespace unless truncated, matches as well.
+func IsNamespaceDBNameOf(chainName, dbName string) bool {
+	chainName = strings.Replace(chainName, ".", "$", -1)
+	if strings.HasPrefix(dbName, chainName+"_") {
+		return true
+	}
+	return len(chainName) > chainNameAllowedLength &&
+		strings.HasPrefix(dbName, chainName[:chainNameAllowedLength]+"_") && strings.HasSuffix(dbName, ")")
+}
+
 //mapAndValidateDatabaseName checks to see if the database name contains illegal characters
 //CouchDB Rules: Only lowercase characters (a-z), digits (0-9), and any of the characters
 //_, $, (, ), +, -, and / are allowed. Must begin with a letter.
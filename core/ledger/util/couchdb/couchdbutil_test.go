@@ -7,6 +7,7 @@ package couchdb
 
 import (
 	"encoding/hex"
+	"strings"
 	"testing"
 
 	"github.com/hyperledger/fabric/common/metrics/disabled"
@@ -185,3 +186,21 @@ func TestConstructedNamespaceDBName(t *testing.T) {
 	assert.Equal(t, expectedDBNameLength, len(constructedDBName))
 	assert.Equal(t, expectedDBName, constructedDBName)
 }
+
+func TestIsNamespaceDBNameOf(t *testing.T) {
+	longChainName := "tob2g.y-z0f.qwp-rq5g4-ogid5g6oucyryg9sc16mz0t4vuake5q557esz7sn493nf0ghch0xih6dwuirokyoi4jvs67gh6r5v6mhz3"
+	mapped := func(dbName string) string {
+		mappedName, err := mapAndValidateDatabaseName(dbName)
+		assert.NoError(t, err)
+		return mappedName
+	}
+
+	assert.True(t, IsNamespaceDBNameOf("my.channel", mapped(ConstructNamespaceDBName("my.channel", "mycc"))))
+	assert.True(t, IsNamespaceDBNameOf("my.channel", mapped(ConstructNamespaceDBName("my.channel", "mycc$$pcoll"))))
+	assert.True(t, IsNamespaceDBNameOf(longChainName, mapped(ConstructNamespaceDBName(longChainName, "mycc"))))
+	assert.True(t, IsNamespaceDBNameOf(longChainName, mapped(ConstructNamespaceDBName(longChainName, strings.Repeat("a", 200)))))
+
+	assert.False(t, IsNamespaceDBNameOf("my.channel", mapped(ConstructNamespaceDBName("other.channel", "mycc"))))
+	assert.False(t, IsNamespaceDBNameOf("my", mapped(ConstructNamespaceDBName("my.channel", "mycc"))))
+	assert.False(t, IsNamespaceDBNameOf(longChainName, mapped(ConstructNamespaceDBName("x"+longChainName, strings.Repeat("a", 200)))))
+}
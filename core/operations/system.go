@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	kitstatsd "github.com/go-kit/kit/metrics/statsd"
@@ -54,6 +55,9 @@ type Options struct {
 	Metrics       MetricsOptions
 	TLS           TLS
 	Version       string
+	// WriteTimeout is the time allowed to write a response, or a write of a streaming response.
+	// It defaults to 2 minutes.
+	WriteTimeout time.Duration
 }
 
 type System struct {
@@ -69,8 +73,16 @@ type System struct {
 	mux             *http.ServeMux
 	addr            string
 	versionGauge    metrics.Gauge
+
+	// conns are the open connections by remote address, whose write deadline is extended by the
+	// streaming handlers
+	connsMutex sync.Mutex
+	conns      map[string]net.Conn
 }
 
+// defaultWriteTimeout is the time allowed to write a response when the options don't set it
+const defaultWriteTimeout = 2 * time.Minute
+
 func NewSystem(o Options) *System {
 	logger := o.Logger
 	if logger == nil {
@@ -142,13 +154,73 @@ func (s *System) RegisterChecker(component string, checker healthz.HealthChecker
 	return s.healthHandler.RegisterChecker(component, checker)
 }
 
+// RegisterHandler registers an HTTP handler for the pattern. The client certificate is required
+// when TLS is enabled.
+func (s *System) RegisterHandler(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, s.handlerChain(handler, s.options.TLS.Enabled))
+}
+
+// RegisterStreamingHandler registers an HTTP handler for the pattern, whose response may be streamed for
+// longer than the write timeout. The write deadline of the connection is extended on every write instead,
+// so that the response is only cut off when the client stops reading. The client certificate is required
+// when TLS is enabled.
+func (s *System) RegisterStreamingHandler(pattern string, handler http.Handler) {
+	streaming := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.connsMutex.Lock()
+		conn := s.conns[r.RemoteAddr]
+		s.connsMutex.Unlock()
+		if conn != nil {
+			w = &streamingWriter{ResponseWriter: w, conn: conn, timeout: s.httpServer.WriteTimeout}
+		}
+		handler.ServeHTTP(w, r)
+	})
+	s.mux.Handle(pattern, s.handlerChain(streaming, s.options.TLS.Enabled))
+}
+
 func (s *System) initializeServer() {
 	s.mux = http.NewServeMux()
+	s.conns = map[string]net.Conn{}
+	writeTimeout := s.options.WriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = defaultWriteTimeout
+	}
 	s.httpServer = &http.Server{
 		Addr:         s.options.ListenAddress,
 		Handler:      s.mux,
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 2 * time.Minute,
+		WriteTimeout: writeTimeout,
+		ConnState:    s.trackConn,
+	}
+}
+
+// trackConn tracks the open connections for the streaming handlers
+func (s *System) trackConn(conn net.Conn, state http.ConnState) {
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+	switch state {
+	case http.StateNew:
+		s.conns[conn.RemoteAddr().String()] = conn
+	case http.StateHijacked, http.StateClosed:
+		delete(s.conns, conn.RemoteAddr().String())
+	}
+}
+
+// streamingWriter extends the write deadline of the connection before every write of the response
+type streamingWriter struct {
+	http.ResponseWriter
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w *streamingWriter) Write(b []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *streamingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+		f.Flush()
 	}
 }
 
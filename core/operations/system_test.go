@@ -377,6 +377,49 @@ var _ = Describe("System", func() {
 		})
 	})
 
+	Context("when a response is written for longer than the write timeout", func() {
+		var slowHandler http.HandlerFunc
+
+		BeforeEach(func() {
+			options.WriteTimeout = 300 * time.Millisecond
+			system = operations.NewSystem(options)
+			slowHandler = func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 8; i++ {
+					if _, err := fmt.Fprintf(w, "chunk %d\n", i); err != nil {
+						return
+					}
+					w.(http.Flusher).Flush()
+					time.Sleep(100 * time.Millisecond)
+				}
+			}
+		})
+
+		It("cuts off the response of a handler", func() {
+			system.RegisterHandler("/slow", slowHandler)
+			err := system.Start()
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := client.Get(fmt.Sprintf("https://%s/slow", system.Addr()))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			_, err = ioutil.ReadAll(resp.Body)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("streams the response of a streaming handler", func() {
+			system.RegisterStreamingHandler("/slow", slowHandler)
+			err := system.Start()
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := client.Get(fmt.Sprintf("https://%s/slow", system.Addr()))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(HaveSuffix("chunk 7\n"))
+		})
+	})
+
 	It("supports ifrit", func() {
 		process := ifrit.Invoke(system)
 		Eventually(process.Ready()).Should(BeClosed())
@@ -11,6 +11,7 @@ import (
 	"net"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/hyperledger/fabric/common/channelconfig"
 	cc "github.com/hyperledger/fabric/common/config"
@@ -18,6 +19,7 @@ import (
 	"github.com/hyperledger/fabric/common/deliver"
 	"github.com/hyperledger/fabric/common/flogging"
 	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/ledger/blockledger"
 	fileledger "github.com/hyperledger/fabric/common/ledger/blockledger/file"
 	"github.com/hyperledger/fabric/common/metrics"
@@ -38,10 +40,14 @@ import (
 	"github.com/hyperledger/fabric/core/ledger/ledgermgmt"
 	"github.com/hyperledger/fabric/core/transientstore"
 	"github.com/hyperledger/fabric/gossip/api"
+	gossiparchive "github.com/hyperledger/fabric/gossip/archive"
+	gossipcommon "github.com/hyperledger/fabric/gossip/common"
 	"github.com/hyperledger/fabric/gossip/service"
 	"github.com/hyperledger/fabric/msp"
 	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
 	"github.com/hyperledger/fabric/protos/common"
+	proto "github.com/hyperledger/fabric/protos/gossip"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/hyperledger/fabric/token/tms/manager"
@@ -232,6 +238,11 @@ func Initialize(init func(string), sccp sysccprovider.SystemChaincodeProvider,
 	pluginMapper = pm
 	chainInitializer = init
 
+	// The blocks available locally are advertised again each time a blockfile is discarded
+	if blockarchive.IsArchiver || blockarchive.IsClient {
+		blockarchive.PeerDiscardListener = archiveInfoAdvertiser{}
+	}
+
 	var cb *common.Block
 	var ledger ledger.PeerLedger
 	ledgermgmt.Initialize(&ledgermgmt.Initializer{
@@ -393,12 +404,17 @@ func createChain(cid string, ledger ledger.PeerLedger, cb *common.Block,
 		cs.Resources = bundle
 	}
 
+	blockArchivingCallback := func(bundle *channelconfig.Bundle) {
+		updateBlockArchivingPolicy(cid, bundle)
+	}
+
 	cs.bundleSource = channelconfig.NewBundleSource(
 		bundle,
 		gossipCallbackWrapper,
 		trustedRootsCallbackWrapper,
 		mspCallback,
 		peerSingletonCallback,
+		blockArchivingCallback,
 	)
 
 	vInfoShim := &vir.ValidationInfoRetrieveShim{
@@ -442,6 +458,10 @@ func createChain(cid string, ledger ledger.PeerLedger, cb *common.Block,
 		IdDeserializeFactory: csStoreSupport,
 	})
 
+	if blockarchive.IsArchiver || blockarchive.IsClient {
+		advertiseArchiveInfo(cid, ledger)
+	}
+
 	chains.Lock()
 	defer chains.Unlock()
 	chains.list[cid] = &chain{
@@ -453,6 +473,80 @@ func createChain(cid string, ledger ledger.PeerLedger, cb *common.Block,
 	return nil
 }
 
+// updateBlockArchivingPolicy applies the block archiving policy of the channel configuration to the archiving
+// of the ledger of the channel, or removes it when the configuration no longer defines one
+func updateBlockArchivingPolicy(cid string, bundle *channelconfig.Bundle) {
+	var policy *blockarchive.ChannelPolicy
+	if ac, ok := bundle.ApplicationConfig(); ok {
+		if ba := ac.BlockArchiving(); ba != nil {
+			policy = &blockarchive.ChannelPolicy{
+				MinLocalBlockfiles: int(ba.MinLocalBlockfiles),
+				MinBlockAge:        time.Duration(ba.MinBlockAgeSeconds) * time.Second,
+				ObjectLockRequired: ba.ObjectLockRequired,
+			}
+		}
+	}
+	prev := blockarchive.ChannelPolicyOf(cid)
+	blockarchive.SetChannelPolicy(cid, policy)
+	switch {
+	case policy != nil && (prev == nil || *prev != *policy):
+		peerLogger.Infof("[channel %s] Block archiving policy of the channel: keep at least %d blockfiles locally, "+
+			"discard blocks older than %s, object lock required: %t",
+			cid, policy.MinLocalBlockfiles, policy.MinBlockAge, policy.ObjectLockRequired)
+	case policy == nil && prev != nil:
+		peerLogger.Infof("[channel %s] The channel no longer defines a block archiving policy", cid)
+	}
+}
+
+// advertiseArchiveInfo publishes to the other peers of the channel the oldest block this peer
+// has on its local file system, and if this peer is the archiver of the channel, the ranges
+// of blocks it has archived so far
+func advertiseArchiveInfo(cid string, ledger ledger.PeerLedger) {
+	catalog, err := ledger.GetArchiveCatalog()
+	if err != nil {
+		peerLogger.Errorf("[channel %s] Failed retrieving the archive catalog: %s", cid, err)
+		return
+	}
+	var info *proto.ArchiveInfo
+	if blockarchive.IsArchiver {
+		info, err = gossiparchive.NewArchiveInfo(cid, catalog)
+	} else {
+		info, err = gossiparchive.NewLocalArchiveInfo(cid, catalog)
+	}
+	if err != nil {
+		peerLogger.Errorf("[channel %s] Failed retrieving the archived block ranges: %s", cid, err)
+		return
+	}
+	service.GetGossipService().UpdateArchiveInfo(info, gossipcommon.ChainID(cid))
+}
+
+// AdvertiseArchiveInfo publishes again the archive information of all the channels,
+// once the archiver role of this peer has been handed off
+func AdvertiseArchiveInfo() {
+	chains.RLock()
+	cids := make([]string, 0, len(chains.list))
+	for cid := range chains.list {
+		cids = append(cids, cid)
+	}
+	chains.RUnlock()
+	for _, cid := range cids {
+		if ledger := GetLedger(cid); ledger != nil {
+			advertiseArchiveInfo(cid, ledger)
+		}
+	}
+}
+
+// archiveInfoAdvertiser advertises the archive information of a channel again
+// once a blockfile of the channel has been discarded
+type archiveInfoAdvertiser struct{}
+
+// HandleBlockfileDiscarded implements blockarchive.DiscardListener
+func (archiveInfoAdvertiser) HandleBlockfileDiscarded(info *archive.ArchivedBlockfileInfo) {
+	if ledger := GetLedger(info.ChannelID); ledger != nil {
+		advertiseArchiveInfo(info.ChannelID, ledger)
+	}
+}
+
 // CreateChainFromBlock creates a new chain from config block
 func CreateChainFromBlock(
 	cb *common.Block,
@@ -11,8 +11,11 @@ import (
 	"math/rand"
 	"net"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric/common/channelconfig"
 	configtxtest "github.com/hyperledger/fabric/common/configtx/test"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/metrics/disabled"
 	mscc "github.com/hyperledger/fabric/common/mocks/scc"
 	"github.com/hyperledger/fabric/core/chaincode/platforms"
@@ -29,6 +32,9 @@ import (
 	"github.com/hyperledger/fabric/internal/peer/gossip/mocks"
 	"github.com/hyperledger/fabric/msp/mgmt"
 	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
+	cb "github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric/protoutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -241,3 +247,35 @@ func TestDeliverSupportManager(t *testing.T) {
 	chainSupport = manager.GetChain("testchain")
 	assert.NotNil(t, chainSupport, "chain support should not be nil")
 }
+
+func TestUpdateBlockArchivingPolicy(t *testing.T) {
+	block, err := configtxtest.MakeGenesisBlock("ch1")
+	require.NoError(t, err)
+	env, err := protoutil.ExtractEnvelope(block, 0)
+	require.NoError(t, err)
+	configEnv := &cb.ConfigEnvelope{}
+	_, err = protoutil.UnmarshalEnvelopeOfType(env, cb.HeaderType_CONFIG, configEnv)
+	require.NoError(t, err)
+	defer blockarchive.SetChannelPolicy("ch1", nil)
+
+	bundle, err := channelconfig.NewBundle("ch1", configEnv.Config)
+	require.NoError(t, err)
+	updateBlockArchivingPolicy("ch1", bundle)
+	assert.Nil(t, blockarchive.ChannelPolicyOf("ch1"))
+
+	policy := &pb.BlockArchiving{MinLocalBlockfiles: 4, MinBlockAgeSeconds: 3600, ObjectLockRequired: true}
+	configEnv.Config.ChannelGroup.Groups[channelconfig.ApplicationGroupKey].Values[channelconfig.BlockArchivingKey] = &cb.ConfigValue{
+		Value: protoutil.MarshalOrPanic(channelconfig.BlockArchivingValue(policy).Value()),
+	}
+	bundle, err = channelconfig.NewBundle("ch1", configEnv.Config)
+	require.NoError(t, err)
+	updateBlockArchivingPolicy("ch1", bundle)
+	assert.Equal(t, &blockarchive.ChannelPolicy{MinLocalBlockfiles: 4, MinBlockAge: time.Hour, ObjectLockRequired: true},
+		blockarchive.ChannelPolicyOf("ch1"))
+
+	delete(configEnv.Config.ChannelGroup.Groups[channelconfig.ApplicationGroupKey].Values, channelconfig.BlockArchivingKey)
+	bundle, err = channelconfig.NewBundle("ch1", configEnv.Config)
+	require.NoError(t, err)
+	updateBlockArchivingPolicy("ch1", bundle)
+	assert.Nil(t, blockarchive.ChannelPolicyOf("ch1"))
+}
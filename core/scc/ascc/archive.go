@@ -0,0 +1,187 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package ascc
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/aclmgmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric/protoutil"
+)
+
+// New returns an instance of ASCC.
+// Typically this is called once per peer.
+func New(aclProvider aclmgmt.ACLProvider) *ArchiveQuerier {
+	return &ArchiveQuerier{
+		aclProvider: aclProvider,
+	}
+}
+
+func (e *ArchiveQuerier) Name() string              { return "ascc" }
+func (e *ArchiveQuerier) Path() string              { return "github.com/hyperledger/fabric/core/scc/ascc" }
+func (e *ArchiveQuerier) InitArgs() [][]byte        { return nil }
+func (e *ArchiveQuerier) Chaincode() shim.Chaincode { return e }
+func (e *ArchiveQuerier) InvokableExternal() bool   { return true }
+func (e *ArchiveQuerier) InvokableCC2CC() bool      { return true }
+func (e *ArchiveQuerier) Enabled() bool             { return true }
+
+// ArchiveQuerier implements the archive catalog query functions, including:
+// - IsBlockArchived returns whether a block has been archived
+// - GetArchiveLocation returns where the blockfile containing a block is archived
+// - GetArchivedRanges returns the ranges of archived blocks
+type ArchiveQuerier struct {
+	aclProvider aclmgmt.ACLProvider
+}
+
+var asccLogger = flogging.MustGetLogger("ascc")
+
+// These are function names from Invoke first parameter
+const (
+	IsBlockArchived    string = "IsBlockArchived"
+	GetArchiveLocation string = "GetArchiveLocation"
+	GetArchivedRanges  string = "GetArchivedRanges"
+)
+
+// Init is called once per chain when the chain is created.
+func (e *ArchiveQuerier) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	asccLogger.Info("Init ASCC")
+
+	return shim.Success(nil)
+}
+
+// Invoke is called with args[0] contains the query function name, args[1]
+// contains the chain ID. Each function requires additional parameters as described below:
+// # IsBlockArchived: Return a BlockArchiveStatus object for the block number in args[2]
+// # GetArchiveLocation: Return the ArchivedBlockfileInfo object for the block number in args[2]
+// # GetArchivedRanges: Return an ArchivedBlockRanges object
+func (e *ArchiveQuerier) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	args := stub.GetArgs()
+
+	if len(args) < 2 {
+		return shim.Error(fmt.Sprintf("Incorrect number of arguments, %d", len(args)))
+	}
+	fname := string(args[0])
+	cid := string(args[1])
+
+	if fname != GetArchivedRanges && len(args) < 3 {
+		return shim.Error(fmt.Sprintf("missing 3rd argument for %s", fname))
+	}
+
+	targetLedger := peer.GetLedger(cid)
+	if targetLedger == nil {
+		return shim.Error(fmt.Sprintf("Invalid chain ID, %s", cid))
+	}
+
+	asccLogger.Debugf("Invoke function: %s on chain: %s", fname, cid)
+
+	// Handle ACL:
+	// 1. get the signed proposal
+	sp, err := stub.GetSignedProposal()
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed getting signed proposal from stub, %s: %s", cid, err))
+	}
+
+	// 2. check the channel reader policy
+	res := getACLResource(fname)
+	if err = e.aclProvider.CheckACL(res, cid, sp); err != nil {
+		return shim.Error(fmt.Sprintf("access denied for [%s][%s]: [%s]", fname, cid, err))
+	}
+
+	catalog, err := targetLedger.GetArchiveCatalog()
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to get archive catalog of chain %s, error %s", cid, err))
+	}
+
+	switch fname {
+	case IsBlockArchived:
+		return isBlockArchived(cid, catalog, args[2])
+	case GetArchiveLocation:
+		return getArchiveLocation(catalog, args[2])
+	case GetArchivedRanges:
+		return getArchivedRanges(cid, catalog)
+	}
+
+	return shim.Error(fmt.Sprintf("Requested function %s not found.", fname))
+}
+
+func isBlockArchived(cid string, catalog blockarchive.Catalog, number []byte) pb.Response {
+	bnum, err := parseBlockNumber(number)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	info, err := catalog.GetArchiveLocation(bnum)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to look up block number %d in archive catalog, error %s", bnum, err))
+	}
+	status := &archive.BlockArchiveStatus{ChannelID: cid, BlockNum: bnum}
+	if info != nil {
+		status.Archived = true
+		status.Discarded = info.Discarded
+	}
+
+	bytes, err := protoutil.Marshal(status)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(bytes)
+}
+
+func getArchiveLocation(catalog blockarchive.Catalog, number []byte) pb.Response {
+	bnum, err := parseBlockNumber(number)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	info, err := catalog.GetArchiveLocation(bnum)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to look up block number %d in archive catalog, error %s", bnum, err))
+	}
+	if info == nil {
+		return shim.Error(fmt.Sprintf("Block number %d has not been archived", bnum))
+	}
+
+	bytes, err := protoutil.Marshal(info)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(bytes)
+}
+
+func getArchivedRanges(cid string, catalog blockarchive.Catalog) pb.Response {
+	ranges, err := catalog.GetArchivedRanges()
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to get archived ranges with error %s", err))
+	}
+
+	bytes, err := protoutil.Marshal(&archive.ArchivedBlockRanges{ChannelID: cid, Ranges: ranges})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(bytes)
+}
+
+func parseBlockNumber(number []byte) (uint64, error) {
+	if number == nil {
+		return 0, fmt.Errorf("Block number must not be nil.")
+	}
+	bnum, err := strconv.ParseUint(string(number), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse block number with error %s", err)
+	}
+	return bnum, nil
+}
+
+func getACLResource(fname string) string {
+	return "ascc/" + fname
+}
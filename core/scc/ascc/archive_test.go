@@ -0,0 +1,138 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package ascc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/aclmgmt/mocks"
+	"github.com/hyperledger/fabric/core/aclmgmt/resources"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	peer2 "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mockAclProvider *mocks.MockACLProvider
+
+func setupTestLedger(chainid string, path string) (*shim.MockStub, error) {
+	mockAclProvider.Reset()
+
+	viper.Set("peer.fileSystemPath", path)
+	peer.MockInitialize()
+	peer.MockCreateChain(chainid)
+
+	aq := New(mockAclProvider)
+	stub := shim.NewMockStub("ArchiveQuerier", aq)
+	if res := stub.MockInit("1", nil); res.Status != shim.OK {
+		return nil, fmt.Errorf("Init failed for test ledger [%s] with message: %s", chainid, string(res.Message))
+	}
+	return stub, nil
+}
+
+// pass the prop so we can conveniently inline it in the call and get it back
+func resetProvider(res, chainid string, prop *peer2.SignedProposal, retErr error) *peer2.SignedProposal {
+	mockAclProvider.Reset()
+	mockAclProvider.On("CheckACL", res, chainid, prop).Return(retErr)
+	return prop
+}
+
+func tempDir(t *testing.T, stem string) string {
+	path, err := ioutil.TempDir("", "ascc-"+stem)
+	require.NoError(t, err)
+	return path
+}
+
+func TestIsBlockArchived(t *testing.T) {
+	chainid := "mytestchainid1"
+	path := tempDir(t, "test1")
+	defer os.RemoveAll(path)
+
+	stub, err := setupTestLedger(chainid, path)
+	require.NoError(t, err)
+
+	args := [][]byte{[]byte(IsBlockArchived), []byte(chainid), []byte("0")}
+	prop := resetProvider(resources.Ascc_IsBlockArchived, chainid, &peer2.SignedProposal{}, nil)
+	res := stub.MockInvokeWithSignedProposal("1", args, prop)
+	require.Equal(t, int32(shim.OK), res.Status, "IsBlockArchived failed with err: %s", res.Message)
+	status := &archive.BlockArchiveStatus{}
+	require.NoError(t, proto.Unmarshal(res.Payload, status))
+	assert.Equal(t, chainid, status.ChannelID)
+	assert.False(t, status.Archived)
+
+	args = [][]byte{[]byte(IsBlockArchived), []byte(chainid)}
+	res = stub.MockInvokeWithSignedProposal("2", args, prop)
+	assert.Equal(t, int32(shim.ERROR), res.Status, "IsBlockArchived should have failed because no block number was provided")
+
+	args = [][]byte{[]byte(IsBlockArchived), []byte(chainid), []byte("foo")}
+	res = stub.MockInvokeWithSignedProposal("3", args, prop)
+	assert.Equal(t, int32(shim.ERROR), res.Status, "IsBlockArchived should have failed because of an invalid block number")
+}
+
+func TestGetArchiveLocation(t *testing.T) {
+	chainid := "mytestchainid2"
+	path := tempDir(t, "test2")
+	defer os.RemoveAll(path)
+
+	stub, err := setupTestLedger(chainid, path)
+	require.NoError(t, err)
+
+	args := [][]byte{[]byte(GetArchiveLocation), []byte(chainid), []byte("0")}
+	prop := resetProvider(resources.Ascc_GetArchiveLocation, chainid, &peer2.SignedProposal{}, nil)
+	res := stub.MockInvokeWithSignedProposal("1", args, prop)
+	assert.Equal(t, int32(shim.ERROR), res.Status, "GetArchiveLocation should have failed because the block has not been archived")
+	assert.Contains(t, res.Message, "has not been archived")
+}
+
+func TestGetArchivedRanges(t *testing.T) {
+	chainid := "mytestchainid3"
+	path := tempDir(t, "test3")
+	defer os.RemoveAll(path)
+
+	stub, err := setupTestLedger(chainid, path)
+	require.NoError(t, err)
+
+	args := [][]byte{[]byte(GetArchivedRanges), []byte(chainid)}
+	prop := resetProvider(resources.Ascc_GetArchivedRanges, chainid, &peer2.SignedProposal{}, nil)
+	res := stub.MockInvokeWithSignedProposal("1", args, prop)
+	require.Equal(t, int32(shim.OK), res.Status, "GetArchivedRanges failed with err: %s", res.Message)
+	ranges := &archive.ArchivedBlockRanges{}
+	require.NoError(t, proto.Unmarshal(res.Payload, ranges))
+	assert.Empty(t, ranges.Ranges)
+
+	args = [][]byte{[]byte(GetArchivedRanges), []byte("fakechainid")}
+	res = stub.MockInvokeWithSignedProposal("2", args, prop)
+	assert.Equal(t, int32(shim.ERROR), res.Status, "GetArchivedRanges should have failed because the channel id does not exist")
+}
+
+func TestArchiveQuerierAccessDenied(t *testing.T) {
+	chainid := "mytestchainid4"
+	path := tempDir(t, "test4")
+	defer os.RemoveAll(path)
+
+	stub, err := setupTestLedger(chainid, path)
+	require.NoError(t, err)
+
+	args := [][]byte{[]byte(GetArchivedRanges), []byte(chainid)}
+	prop := resetProvider(resources.Ascc_GetArchivedRanges, chainid, &peer2.SignedProposal{}, errors.New("Failed authorization"))
+	res := stub.MockInvokeWithSignedProposal("1", args, prop)
+	assert.Equal(t, int32(shim.ERROR), res.Status, "GetArchivedRanges should have failed because of ACL")
+	assert.Contains(t, res.Message, "access denied")
+}
+
+func TestMain(m *testing.M) {
+	mockAclProvider = &mocks.MockACLProvider{}
+	mockAclProvider.Reset()
+
+	os.Exit(m.Run())
+}
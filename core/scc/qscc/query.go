@@ -11,10 +11,12 @@ import (
 	"strconv"
 
 	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/core/aclmgmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/protos/common"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/hyperledger/fabric/protoutil"
 )
@@ -112,7 +114,7 @@ func (e *LedgerQuerier) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	case GetBlockByHash:
 		return getBlockByHash(targetLedger, args[2])
 	case GetChainInfo:
-		return getChainInfo(targetLedger)
+		return getChainInfo(cid, targetLedger)
 	case GetBlockByTxID:
 		return getBlockByTxID(targetLedger, args[2])
 	}
@@ -184,11 +186,14 @@ func getBlockByHash(vledger ledger.PeerLedger, hash []byte) pb.Response {
 	return shim.Success(bytes)
 }
 
-func getChainInfo(vledger ledger.PeerLedger) pb.Response {
+func getChainInfo(cid string, vledger ledger.PeerLedger) pb.Response {
 	binfo, err := vledger.GetBlockchainInfo()
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Failed to get block info with error %s", err))
 	}
+	if binfo, err = withArchiveInfo(cid, vledger, binfo); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to get archive info with error %s", err))
+	}
 	bytes, err := protoutil.Marshal(binfo)
 	if err != nil {
 		return shim.Error(err.Error())
@@ -197,6 +202,25 @@ func getChainInfo(vledger ledger.PeerLedger) pb.Response {
 	return shim.Success(bytes)
 }
 
+// withArchiveInfo returns a copy of the BlockchainInfo of the ledger with its archiving statistics,
+// which let client SDKs expect a higher latency for the blocks fetched from the archive
+func withArchiveInfo(cid string, vledger ledger.PeerLedger, binfo *common.BlockchainInfo) (*common.BlockchainInfo, error) {
+	catalog, err := vledger.GetArchiveCatalog()
+	if err != nil {
+		return nil, err
+	}
+	archiveInfo, err := blockarchive.NewBlockchainArchiveInfo(cid, catalog)
+	if err != nil {
+		return nil, err
+	}
+	return &common.BlockchainInfo{
+		Height:            binfo.Height,
+		CurrentBlockHash:  binfo.CurrentBlockHash,
+		PreviousBlockHash: binfo.PreviousBlockHash,
+		ArchiveInfo:       archiveInfo,
+	}, nil
+}
+
 func getBlockByTxID(vledger ledger.PeerLedger, rawTxID []byte) pb.Response {
 	txID := string(rawTxID)
 	block, err := vledger.GetBlockByTxID(txID)
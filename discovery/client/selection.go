@@ -106,6 +106,31 @@ func ExcludeByHost(reject func(host string) bool) ExclusionFilter {
 	})
 }
 
+// ExcludeNonArchivers returns a ExclusionFilter that excludes the peers which
+// haven't published themselves as an archiver that has archived the given block
+func ExcludeNonArchivers(blockNum uint64) ExclusionFilter {
+	return selectionFunc(func(p Peer) bool {
+		return !protoext.HasArchivedBlock(p.StateInfoMessage.GetStateInfo().GetProperties(), blockNum)
+	})
+}
+
+// ExcludeDiscardedBlock returns a ExclusionFilter that excludes the peers which have
+// discarded the given block from their local file system, and would need to fetch it
+// from the archive to serve it
+func ExcludeDiscardedBlock(blockNum uint64) ExclusionFilter {
+	return selectionFunc(func(p Peer) bool {
+		return protoext.OldestLocalBlock(p.StateInfoMessage.GetStateInfo().GetProperties()) > blockNum
+	})
+}
+
+// ExcludeUnservableBlock returns a ExclusionFilter that excludes the peers which don't have
+// the given block and have discarded it without fetching the discarded blocks from the archive
+func ExcludeUnservableBlock(blockNum uint64) ExclusionFilter {
+	return selectionFunc(func(p Peer) bool {
+		return !protoext.CanServeBlock(p.StateInfoMessage.GetStateInfo().GetProperties(), blockNum)
+	})
+}
+
 // Filter filters the endorsers according to the given ExclusionFilter
 func (endorsers Endorsers) Filter(f ExclusionFilter) Endorsers {
 	var res Endorsers
@@ -93,6 +93,80 @@ func TestExcludeEndpoints(t *testing.T) {
 	assert.False(t, s.Exclude(p3))
 }
 
+func TestExcludeNonArchivers(t *testing.T) {
+	archiver := stateInfoWithHeight(100)
+	archiver.GetStateInfo().Properties.ArchiveInfo = &gossip.ArchiveInfo{
+		Archiver:       true,
+		ArchivedRanges: []*gossip.BlockRange{{FirstBlock: 0, LastBlock: 49}},
+	}
+	p1 := Peer{
+		StateInfoMessage: archiver,
+	}
+	p2 := Peer{
+		StateInfoMessage: stateInfoWithHeight(100),
+	}
+
+	s := ExcludeNonArchivers(10)
+	assert.False(t, s.Exclude(p1))
+	assert.True(t, s.Exclude(p2))
+
+	s = ExcludeNonArchivers(50)
+	assert.True(t, s.Exclude(p1))
+	assert.True(t, s.Exclude(p2))
+}
+
+func TestExcludeDiscardedBlock(t *testing.T) {
+	discarding := stateInfoWithHeight(100)
+	discarding.GetStateInfo().Properties.ArchiveInfo = &gossip.ArchiveInfo{
+		OldestLocalBlock: 50,
+	}
+	p1 := Peer{
+		StateInfoMessage: discarding,
+	}
+	p2 := Peer{
+		StateInfoMessage: stateInfoWithHeight(100),
+	}
+
+	s := ExcludeDiscardedBlock(10)
+	assert.True(t, s.Exclude(p1))
+	assert.False(t, s.Exclude(p2))
+
+	s = ExcludeDiscardedBlock(50)
+	assert.False(t, s.Exclude(p1))
+	assert.False(t, s.Exclude(p2))
+}
+
+func TestExcludeUnservableBlock(t *testing.T) {
+	discarding := stateInfoWithHeight(100)
+	discarding.GetStateInfo().Properties.ArchiveInfo = &gossip.ArchiveInfo{
+		OldestLocalBlock: 50,
+	}
+	fetching := stateInfoWithHeight(100)
+	fetching.GetStateInfo().Properties.ArchiveInfo = &gossip.ArchiveInfo{
+		OldestLocalBlock: 50,
+		FetchEnabled:     true,
+	}
+	p1 := Peer{
+		StateInfoMessage: discarding,
+	}
+	p2 := Peer{
+		StateInfoMessage: fetching,
+	}
+	p3 := Peer{
+		StateInfoMessage: stateInfoWithHeight(40),
+	}
+
+	s := ExcludeUnservableBlock(10)
+	assert.True(t, s.Exclude(p1))
+	assert.False(t, s.Exclude(p2))
+	assert.False(t, s.Exclude(p3))
+
+	s = ExcludeUnservableBlock(50)
+	assert.False(t, s.Exclude(p1))
+	assert.False(t, s.Exclude(p2))
+	assert.True(t, s.Exclude(p3))
+}
+
 func TestNoPriorities(t *testing.T) {
 	s1 := stateInfoWithHeight(100)
 	s2 := stateInfoWithHeight(200)
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package discovery
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/cmd/common"
 	"github.com/hyperledger/fabric/discovery/client"
+	"github.com/hyperledger/fabric/gossip/protoext"
 	"github.com/hyperledger/fabric/protos/msp"
 	"github.com/pkg/errors"
 )
@@ -112,11 +114,20 @@ func assemblePeers(peers []*discovery.Peer, withChannelState bool) interface{} {
 }
 
 type channelPeer struct {
-	MSPID        string
-	LedgerHeight uint64
-	Endpoint     string
-	Identity     string
-	Chaincodes   []string
+	MSPID            string
+	LedgerHeight     uint64
+	OldestLocalBlock uint64 `json:",omitempty"`
+	// ArchivingEnabled and FetchEnabled tell clients whether the blocks older than OldestLocalBlock
+	// are served by the peer, with a higher latency since they are fetched from the archive.
+	// ArchivedUpTo and CatalogDigest digest the archive catalog of the peer, so that the lag and
+	// the divergence of the catalogs of the peers are told apart without querying them
+	ArchivingEnabled bool   `json:",omitempty"`
+	FetchEnabled     bool   `json:",omitempty"`
+	ArchivedUpTo     uint64 `json:",omitempty"`
+	CatalogDigest    string `json:",omitempty"`
+	Endpoint         string
+	Identity         string
+	Chaincodes       []string
 }
 
 type localPeer struct {
@@ -138,11 +149,19 @@ func (scr *simpleChannelResponse) Peers() ([]*discovery.Peer, error) {
 }
 
 func rawPeerToChannelPeer(p *discovery.Peer) channelPeer {
-	var ledgerHeight uint64
+	var ledgerHeight, oldestLocalBlock uint64
+	var archivingEnabled, fetchEnabled bool
+	var archivedUpTo uint64
+	var catalogDigest string
 	var ccs []string
 	if p.StateInfoMessage != nil && p.StateInfoMessage.GetStateInfo() != nil && p.StateInfoMessage.GetStateInfo().Properties != nil {
 		properties := p.StateInfoMessage.GetStateInfo().Properties
 		ledgerHeight = properties.LedgerHeight
+		oldestLocalBlock = protoext.OldestLocalBlock(properties)
+		archivingEnabled = protoext.IsArchivingEnabled(properties)
+		fetchEnabled = protoext.IsFetchEnabled(properties)
+		archivedUpTo = protoext.ArchivedUpTo(properties)
+		catalogDigest = hex.EncodeToString(protoext.CatalogDigest(properties))
 		for _, cc := range properties.Chaincodes {
 			if cc == nil {
 				continue
@@ -157,11 +176,16 @@ func rawPeerToChannelPeer(p *discovery.Peer) channelPeer {
 	sID := &msp.SerializedIdentity{}
 	proto.Unmarshal(p.Identity, sID)
 	return channelPeer{
-		MSPID:        p.MSPID,
-		Endpoint:     endpoint,
-		LedgerHeight: ledgerHeight,
-		Identity:     string(sID.IdBytes),
-		Chaincodes:   ccs,
+		MSPID:            p.MSPID,
+		Endpoint:         endpoint,
+		LedgerHeight:     ledgerHeight,
+		OldestLocalBlock: oldestLocalBlock,
+		ArchivingEnabled: archivingEnabled,
+		FetchEnabled:     fetchEnabled,
+		ArchivedUpTo:     archivedUpTo,
+		CatalogDigest:    catalogDigest,
+		Identity:         string(sID.IdBytes),
+		Chaincodes:       ccs,
 	}
 }
 
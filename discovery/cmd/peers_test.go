@@ -105,6 +105,32 @@ func TestParsePeers(t *testing.T) {
 	}
 }
 
+func TestParseArchivingPeers(t *testing.T) {
+	buff := &bytes.Buffer{}
+	parser := &discovery.PeerResponseParser{Writer: buff}
+	res := &mocks.ServiceResponse{}
+
+	archivingPeer := &Peer{
+		MSPID:            "Org1MSP",
+		AliveMessage:     aliveMessage(0),
+		StateInfoMessage: stateInfoMessage(100),
+	}
+	archivingPeer.StateInfoMessage.GetStateInfo().Properties.ArchiveInfo = &gossip.ArchiveInfo{
+		OldestLocalBlock: 50,
+		FetchEnabled:     true,
+		ArchivedUpTo:     49,
+		CatalogDigest:    []byte{0xca, 0xfe},
+	}
+	chanRes := &mocks.ChannelResponse{}
+	chanRes.On("Peers").Return([]*Peer{archivingPeer}, nil)
+	res.On("ForChannel", "mychannel").Return(chanRes)
+
+	err := parser.ParseResponse("mychannel", res)
+	assert.NoError(t, err)
+	expected := "[\n\t{\n\t\t\"MSPID\": \"Org1MSP\",\n\t\t\"LedgerHeight\": 100,\n\t\t\"OldestLocalBlock\": 50,\n\t\t\"ArchivingEnabled\": true,\n\t\t\"FetchEnabled\": true,\n\t\t\"ArchivedUpTo\": 49,\n\t\t\"CatalogDigest\": \"cafe\",\n\t\t\"Endpoint\": \"p0\",\n\t\t\"Identity\": \"\",\n\t\t\"Chaincodes\": [\n\t\t\t\"mycc\",\n\t\t\t\"mycc2\"\n\t\t]\n\t}\n]"
+	assert.Equal(t, fmt.Sprintf("%s\n", expected), buff.String())
+}
+
 func aliveMessage(id int) *protoext.SignedGossipMessage {
 	g := &gossip.GossipMessage{
 		Content: &gossip.GossipMessage_AliveMsg{
@@ -90,6 +90,12 @@ type Gossip struct {
 		chaincode []*proto.Chaincode
 		chainID   common.ChainID
 	}
+	UpdateArchiveInfoStub        func(archiveInfo *proto.ArchiveInfo, chainID common.ChainID)
+	updateArchiveInfoMutex       sync.RWMutex
+	updateArchiveInfoArgsForCall []struct {
+		archiveInfo *proto.ArchiveInfo
+		chainID     common.ChainID
+	}
 	GossipStub        func(msg *proto.GossipMessage)
 	gossipMutex       sync.RWMutex
 	gossipArgsForCall []struct {
@@ -489,6 +495,31 @@ func (fake *Gossip) UpdateChaincodesArgsForCall(i int) ([]*proto.Chaincode, comm
 	return fake.updateChaincodesArgsForCall[i].chaincode, fake.updateChaincodesArgsForCall[i].chainID
 }
 
+func (fake *Gossip) UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo, chainID common.ChainID) {
+	fake.updateArchiveInfoMutex.Lock()
+	fake.updateArchiveInfoArgsForCall = append(fake.updateArchiveInfoArgsForCall, struct {
+		archiveInfo *proto.ArchiveInfo
+		chainID     common.ChainID
+	}{archiveInfo, chainID})
+	fake.recordInvocation("UpdateArchiveInfo", []interface{}{archiveInfo, chainID})
+	fake.updateArchiveInfoMutex.Unlock()
+	if fake.UpdateArchiveInfoStub != nil {
+		fake.UpdateArchiveInfoStub(archiveInfo, chainID)
+	}
+}
+
+func (fake *Gossip) UpdateArchiveInfoCallCount() int {
+	fake.updateArchiveInfoMutex.RLock()
+	defer fake.updateArchiveInfoMutex.RUnlock()
+	return len(fake.updateArchiveInfoArgsForCall)
+}
+
+func (fake *Gossip) UpdateArchiveInfoArgsForCall(i int) (*proto.ArchiveInfo, common.ChainID) {
+	fake.updateArchiveInfoMutex.RLock()
+	defer fake.updateArchiveInfoMutex.RUnlock()
+	return fake.updateArchiveInfoArgsForCall[i].archiveInfo, fake.updateArchiveInfoArgsForCall[i].chainID
+}
+
 func (fake *Gossip) Gossip(msg *proto.GossipMessage) {
 	fake.gossipMutex.Lock()
 	fake.gossipArgsForCall = append(fake.gossipArgsForCall, struct {
@@ -767,6 +798,8 @@ func (fake *Gossip) Invocations() map[string][][]interface{} {
 	defer fake.updateLedgerHeightMutex.RUnlock()
 	fake.updateChaincodesMutex.RLock()
 	defer fake.updateChaincodesMutex.RUnlock()
+	fake.updateArchiveInfoMutex.RLock()
+	defer fake.updateArchiveInfoMutex.RUnlock()
 	fake.gossipMutex.RLock()
 	defer fake.gossipMutex.RUnlock()
 	fake.peerFilterMutex.RLock()
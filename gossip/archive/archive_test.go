@@ -66,7 +66,7 @@ func TestNewArchiveService(t *testing.T) {
 	resource := &resource{}
 	chainid := gossipCommon.ChainID("mychannel")
 
-	_ = NewArchiveService(mockGossip, chainid, resource)
+	_ = NewService(mockGossip, chainid, resource)
 
 	runtime.Gosched()
 
@@ -0,0 +1,157 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archive
+
+import (
+	"bytes"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/gossip/discovery"
+	"github.com/hyperledger/fabric/gossip/protoext"
+	proto "github.com/hyperledger/fabric/protos/gossip"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+)
+
+// NewArchiveInfo builds the archive information an archiver peer publishes
+// in its StateInfo for a channel out of the catalog of the blockfiles it has archived
+func NewArchiveInfo(channelID string, catalog blockarchive.Catalog) (*proto.ArchiveInfo, error) {
+	ranges, err := catalog.GetArchivedRanges()
+	if err != nil {
+		return nil, err
+	}
+	info, err := NewLocalArchiveInfo(channelID, catalog)
+	if err != nil {
+		return nil, err
+	}
+	info.Archiver = true
+	for _, r := range ranges {
+		info.ArchivedRanges = append(info.ArchivedRanges, &proto.BlockRange{
+			FirstBlock: r.FirstBlockNum,
+			LastBlock:  r.LastBlockNum,
+		})
+	}
+	return info, nil
+}
+
+// NewLocalArchiveInfo builds the archive information a peer which is not the archiver
+// publishes in its StateInfo for a channel, which advertises the oldest block available locally,
+// whether the older blocks are fetched from the archive when they are requested, and the digest
+// of its archive catalog
+func NewLocalArchiveInfo(channelID string, catalog blockarchive.Catalog) (*proto.ArchiveInfo, error) {
+	discarded, err := catalog.GetDiscardedRanges()
+	if err != nil {
+		return nil, err
+	}
+	archivedUpTo, digest, err := blockarchive.DigestCatalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ArchiveInfo{
+		OldestLocalBlock: oldestLocalBlock(discarded),
+		FetchEnabled:     blockarchive.IsFetchEnabled(channelID),
+		ArchivedUpTo:     archivedUpTo,
+		CatalogDigest:    digest,
+	}, nil
+}
+
+// oldestLocalBlock returns the first block which doesn't belong to the leading
+// discarded ranges, the ranges being sorted and merged
+func oldestLocalBlock(discarded []*archive.ArchivedBlockRange) uint64 {
+	var oldest uint64
+	for _, r := range discarded {
+		if r.FirstBlockNum > oldest {
+			break
+		}
+		if r.LastBlockNum >= oldest {
+			oldest = r.LastBlockNum + 1
+		}
+	}
+	return oldest
+}
+
+// Archivers returns the members of the channel which publish themselves as archiver
+func Archivers(members []discovery.NetworkMember) []discovery.NetworkMember {
+	var archivers []discovery.NetworkMember
+	for _, member := range members {
+		if protoext.IsArchiver(member.Properties) {
+			archivers = append(archivers, member)
+		}
+	}
+	return archivers
+}
+
+// ArchiversOfBlock returns the members of the channel which publish themselves
+// as archiver and have archived the given block into the repository
+func ArchiversOfBlock(members []discovery.NetworkMember, blockNum uint64) []discovery.NetworkMember {
+	var archivers []discovery.NetworkMember
+	for _, member := range members {
+		if protoext.HasArchivedBlock(member.Properties, blockNum) {
+			archivers = append(archivers, member)
+		}
+	}
+	return archivers
+}
+
+// LaggingMembers returns the members of the channel, other than the archivers, whose archive catalog
+// has been archived up to an older block than the most advanced catalog of the archivers
+func LaggingMembers(members []discovery.NetworkMember) []discovery.NetworkMember {
+	var archivedUpTo uint64
+	archived := false
+	for _, archiver := range Archivers(members) {
+		if len(protoext.CatalogDigest(archiver.Properties)) == 0 {
+			continue
+		}
+		if upTo := protoext.ArchivedUpTo(archiver.Properties); !archived || upTo > archivedUpTo {
+			archivedUpTo, archived = upTo, true
+		}
+	}
+	if !archived {
+		return nil
+	}
+	var lagging []discovery.NetworkMember
+	for _, member := range members {
+		props := member.Properties
+		if protoext.IsArchiver(props) || !protoext.IsArchivingEnabled(props) {
+			continue
+		}
+		if len(protoext.CatalogDigest(props)) == 0 || protoext.ArchivedUpTo(props) < archivedUpTo {
+			lagging = append(lagging, member)
+		}
+	}
+	return lagging
+}
+
+// DivergentMembers returns the members of the channel, other than the archivers, whose archive catalog
+// diverges from the ones of the archivers: it has been archived up to the same block as the catalog of an
+// archiver, but its digest matches none of the archivers archived up to that block
+func DivergentMembers(members []discovery.NetworkMember) []discovery.NetworkMember {
+	digests := map[uint64][][]byte{}
+	for _, archiver := range Archivers(members) {
+		if digest := protoext.CatalogDigest(archiver.Properties); len(digest) > 0 {
+			upTo := protoext.ArchivedUpTo(archiver.Properties)
+			digests[upTo] = append(digests[upTo], digest)
+		}
+	}
+	var divergent []discovery.NetworkMember
+	for _, member := range members {
+		props := member.Properties
+		digest := protoext.CatalogDigest(props)
+		if protoext.IsArchiver(props) || len(digest) == 0 {
+			continue
+		}
+		archiverDigests, ok := digests[protoext.ArchivedUpTo(props)]
+		if !ok {
+			continue
+		}
+		matches := false
+		for _, archiverDigest := range archiverDigests {
+			matches = matches || bytes.Equal(digest, archiverDigest)
+		}
+		if !matches {
+			divergent = append(divergent, member)
+		}
+	}
+	return divergent
+}
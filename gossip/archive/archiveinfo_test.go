@@ -0,0 +1,184 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package archive
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/gossip/discovery"
+	proto "github.com/hyperledger/fabric/protos/gossip"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCatalog struct {
+	ranges     []*archive.ArchivedBlockRange
+	discarded  []*archive.ArchivedBlockRange
+	blockfiles []*archive.ArchivedBlockfileInfo
+}
+
+func (c *mockCatalog) IsBlockArchived(blockNum uint64) (bool, error) {
+	return false, nil
+}
+
+func (c *mockCatalog) GetArchiveLocation(blockNum uint64) (*archive.ArchivedBlockfileInfo, error) {
+	return nil, nil
+}
+
+func (c *mockCatalog) GetArchivedRanges() ([]*archive.ArchivedBlockRange, error) {
+	return c.ranges, nil
+}
+
+func (c *mockCatalog) GetDiscardedRanges() ([]*archive.ArchivedBlockRange, error) {
+	return c.discarded, nil
+}
+
+func (c *mockCatalog) ListArchivedBlockfiles() ([]*archive.ArchivedBlockfileInfo, error) {
+	return c.blockfiles, nil
+}
+
+func (c *mockCatalog) GetArchivedBlock(blockNum uint64) (*archive.ArchivedBlockInfo, error) {
+	return nil, nil
+}
+
+func (c *mockCatalog) GetArchivedBlockByHash(headerHash []byte) (*archive.ArchivedBlockInfo, error) {
+	return nil, nil
+}
+
+func TestNewArchiveInfo(t *testing.T) {
+	info, err := NewArchiveInfo("ch1", &mockCatalog{})
+	assert.NoError(t, err)
+	assert.True(t, info.Archiver)
+	assert.Empty(t, info.ArchivedRanges)
+
+	info, err = NewArchiveInfo("ch1", &mockCatalog{ranges: []*archive.ArchivedBlockRange{
+		{FirstBlockNum: 0, LastBlockNum: 19},
+		{FirstBlockNum: 40, LastBlockNum: 59},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []*proto.BlockRange{
+		{FirstBlock: 0, LastBlock: 19},
+		{FirstBlock: 40, LastBlock: 59},
+	}, info.ArchivedRanges)
+}
+
+func TestOldestLocalBlock(t *testing.T) {
+	info, err := NewLocalArchiveInfo("ch1", &mockCatalog{})
+	assert.NoError(t, err)
+	assert.False(t, info.Archiver)
+	assert.Equal(t, uint64(0), info.OldestLocalBlock)
+
+	// Only the leading discarded ranges make blocks unavailable from the start of the ledger
+	info, err = NewLocalArchiveInfo("ch1", &mockCatalog{discarded: []*archive.ArchivedBlockRange{
+		{FirstBlockNum: 0, LastBlockNum: 19},
+		{FirstBlockNum: 40, LastBlockNum: 59},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), info.OldestLocalBlock)
+
+	info, err = NewLocalArchiveInfo("ch1", &mockCatalog{discarded: []*archive.ArchivedBlockRange{
+		{FirstBlockNum: 10, LastBlockNum: 19},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), info.OldestLocalBlock)
+
+	info, err = NewArchiveInfo("ch1", &mockCatalog{
+		ranges:    []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 59}},
+		discarded: []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 39}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, info.Archiver)
+	assert.Equal(t, uint64(40), info.OldestLocalBlock)
+}
+
+func TestFetchEnabled(t *testing.T) {
+	defer func(isClient bool, fetchEnabled func(string) bool) {
+		blockarchive.IsClient, blockarchive.FetchEnabled = isClient, fetchEnabled
+	}(blockarchive.IsClient, blockarchive.FetchEnabled)
+	blockarchive.IsClient = true
+	blockarchive.FetchEnabled = func(ledgerID string) bool { return ledgerID == "ch1" }
+
+	info, err := NewLocalArchiveInfo("ch1", &mockCatalog{})
+	assert.NoError(t, err)
+	assert.True(t, info.FetchEnabled)
+	info, err = NewLocalArchiveInfo("ch2", &mockCatalog{})
+	assert.NoError(t, err)
+	assert.False(t, info.FetchEnabled)
+
+	// The archiver always fetches the discarded blocks from the repository
+	blockarchive.IsClient = false
+	info, err = NewArchiveInfo("ch2", &mockCatalog{})
+	assert.NoError(t, err)
+	assert.True(t, info.FetchEnabled)
+}
+
+func TestArchiversOfBlock(t *testing.T) {
+	info, _ := NewArchiveInfo("ch1", &mockCatalog{ranges: []*archive.ArchivedBlockRange{
+		{FirstBlockNum: 0, LastBlockNum: 19},
+	}})
+	members := []discovery.NetworkMember{
+		{Endpoint: "p0", Properties: &proto.Properties{LedgerHeight: 30}},
+		{Endpoint: "p1", Properties: &proto.Properties{LedgerHeight: 30, ArchiveInfo: info}},
+		{Endpoint: "p2"},
+	}
+
+	archivers := Archivers(members)
+	assert.Len(t, archivers, 1)
+	assert.Equal(t, "p1", archivers[0].Endpoint)
+
+	assert.Len(t, ArchiversOfBlock(members, 10), 1)
+	assert.Empty(t, ArchiversOfBlock(members, 20))
+}
+
+func TestCatalogDigest(t *testing.T) {
+	info, err := NewLocalArchiveInfo("ch1", &mockCatalog{})
+	assert.NoError(t, err)
+	assert.Empty(t, info.CatalogDigest)
+
+	catalog := &mockCatalog{
+		ranges: []*archive.ArchivedBlockRange{{FirstBlockNum: 0, LastBlockNum: 19}},
+		blockfiles: []*archive.ArchivedBlockfileInfo{
+			{BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9, Checksum: "sha256:aa"},
+			{BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19, Checksum: "sha256:bb"},
+		},
+	}
+	info, err = NewArchiveInfo("ch1", catalog)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(19), info.ArchivedUpTo)
+	assert.Len(t, info.CatalogDigest, 32)
+}
+
+func TestLaggingAndDivergentMembers(t *testing.T) {
+	member := func(endpoint string, archiver bool, archivedUpTo uint64, digest ...byte) discovery.NetworkMember {
+		return discovery.NetworkMember{Endpoint: endpoint, Properties: &proto.Properties{ArchiveInfo: &proto.ArchiveInfo{
+			Archiver:      archiver,
+			ArchivedUpTo:  archivedUpTo,
+			CatalogDigest: digest,
+		}}}
+	}
+	members := []discovery.NetworkMember{
+		member("archiver", true, 29, 1),
+		member("insync", false, 29, 1),
+		member("lagging", false, 19, 2),
+		member("empty", false, 0),
+		member("divergent", false, 29, 3),
+		{Endpoint: "disabled", Properties: &proto.Properties{}},
+	}
+	endpoints := func(members []discovery.NetworkMember) []string {
+		var endpoints []string
+		for _, m := range members {
+			endpoints = append(endpoints, m.Endpoint)
+		}
+		return endpoints
+	}
+	assert.Equal(t, []string{"lagging", "empty"}, endpoints(LaggingMembers(members)))
+	assert.Equal(t, []string{"divergent"}, endpoints(DivergentMembers(members)))
+
+	// Nothing is reported until the archiver has archived blockfiles
+	members[0] = member("archiver", true, 0)
+	assert.Empty(t, LaggingMembers(members))
+	assert.Empty(t, DivergentMembers(members))
+}
@@ -75,6 +75,10 @@ type GossipChannel interface {
 	// to other peers in the channel
 	UpdateChaincodes(chaincode []*proto.Chaincode)
 
+	// UpdateArchiveInfo updates the archiver role and the archived block ranges
+	// the peer publishes to other peers in the channel
+	UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo)
+
 	// IsOrgInChannel returns whether the given organization is in the channel
 	IsOrgInChannel(membersOrg api.OrgIdentityType) bool
 
@@ -344,11 +348,13 @@ func (gc *gossipChannel) LeaveChannel() {
 
 	var chaincodes []*proto.Chaincode
 	var height uint64
+	var archiveInfo *proto.ArchiveInfo
 	if prevMsg := gc.stateInfoMsg; prevMsg != nil {
 		chaincodes = prevMsg.GetStateInfo().Properties.Chaincodes
 		height = prevMsg.GetStateInfo().Properties.LedgerHeight
+		archiveInfo = prevMsg.GetStateInfo().Properties.ArchiveInfo
 	}
-	gc.updateProperties(height, chaincodes, true)
+	gc.updateProperties(height, chaincodes, true, archiveInfo)
 }
 
 func (gc *gossipChannel) hasLeftChannel() bool {
@@ -879,11 +885,13 @@ func (gc *gossipChannel) UpdateLedgerHeight(height uint64) {
 
 	var chaincodes []*proto.Chaincode
 	var leftChannel bool
+	var archiveInfo *proto.ArchiveInfo
 	if prevMsg := gc.stateInfoMsg; prevMsg != nil {
 		leftChannel = prevMsg.GetStateInfo().Properties.LeftChannel
 		chaincodes = prevMsg.GetStateInfo().Properties.Chaincodes
+		archiveInfo = prevMsg.GetStateInfo().Properties.ArchiveInfo
 	}
-	gc.updateProperties(height, chaincodes, leftChannel)
+	gc.updateProperties(height, chaincodes, leftChannel, archiveInfo)
 }
 
 // UpdateChaincodes updates the chaincodes the peer publishes
@@ -894,11 +902,30 @@ func (gc *gossipChannel) UpdateChaincodes(chaincodes []*proto.Chaincode) {
 
 	var ledgerHeight uint64 = 1
 	var leftChannel bool
+	var archiveInfo *proto.ArchiveInfo
+	if prevMsg := gc.stateInfoMsg; prevMsg != nil {
+		ledgerHeight = prevMsg.GetStateInfo().Properties.LedgerHeight
+		leftChannel = prevMsg.GetStateInfo().Properties.LeftChannel
+		archiveInfo = prevMsg.GetStateInfo().Properties.ArchiveInfo
+	}
+	gc.updateProperties(ledgerHeight, chaincodes, leftChannel, archiveInfo)
+}
+
+// UpdateArchiveInfo updates the archiver role and the archived block ranges
+// the peer publishes to other peers in the channel
+func (gc *gossipChannel) UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo) {
+	gc.Lock()
+	defer gc.Unlock()
+
+	var ledgerHeight uint64 = 1
+	var leftChannel bool
+	var chaincodes []*proto.Chaincode
 	if prevMsg := gc.stateInfoMsg; prevMsg != nil {
 		ledgerHeight = prevMsg.GetStateInfo().Properties.LedgerHeight
 		leftChannel = prevMsg.GetStateInfo().Properties.LeftChannel
+		chaincodes = prevMsg.GetStateInfo().Properties.Chaincodes
 	}
-	gc.updateProperties(ledgerHeight, chaincodes, leftChannel)
+	gc.updateProperties(ledgerHeight, chaincodes, leftChannel, archiveInfo)
 }
 
 // UpdateStateInfo updates this channel's StateInfo message
@@ -910,7 +937,7 @@ func (gc *gossipChannel) updateStateInfo(msg *protoext.SignedGossipMessage) {
 	atomic.StoreInt32(&gc.shouldGossipStateInfo, int32(1))
 }
 
-func (gc *gossipChannel) updateProperties(ledgerHeight uint64, chaincodes []*proto.Chaincode, leftChannel bool) {
+func (gc *gossipChannel) updateProperties(ledgerHeight uint64, chaincodes []*proto.Chaincode, leftChannel bool, archiveInfo *proto.ArchiveInfo) {
 	stateInfMsg := &proto.StateInfo{
 		Channel_MAC: GenerateMAC(gc.pkiID, gc.chainID),
 		PkiId:       gc.pkiID,
@@ -922,6 +949,7 @@ func (gc *gossipChannel) updateProperties(ledgerHeight uint64, chaincodes []*pro
 			LeftChannel:  leftChannel,
 			LedgerHeight: ledgerHeight,
 			Chaincodes:   chaincodes,
+			ArchiveInfo:  archiveInfo,
 		},
 	}
 	m := &proto.GossipMessage{
@@ -328,6 +328,37 @@ func TestSelf(t *testing.T) {
 	assert.Equal(t, gMsg.GetStateInfo().PkiId, []byte("1"))
 }
 
+func TestUpdateArchiveInfo(t *testing.T) {
+	t.Parallel()
+
+	cs := &cryptoService{}
+	pkiID1 := common.PKIidType("1")
+	jcm := &joinChanMsg{
+		members2AnchorPeers: map[string][]api.AnchorPeer{
+			string(orgInChannelA): {},
+		},
+	}
+	adapter := new(gossipAdapterMock)
+	configureAdapter(adapter)
+	adapter.On("Gossip", mock.Anything)
+	gc := NewGossipChannel(pkiID1, orgInChannelA, cs, channelA, adapter, jcm, disabledMetrics, nil)
+	gc.UpdateLedgerHeight(10)
+	archiveInfo := &proto.ArchiveInfo{
+		Archiver:       true,
+		ArchivedRanges: []*proto.BlockRange{{FirstBlock: 0, LastBlock: 4}},
+	}
+	gc.UpdateArchiveInfo(archiveInfo)
+	props := gc.Self().GetStateInfo().Properties
+	assert.Equal(t, uint64(10), props.LedgerHeight)
+	assert.True(t, gproto.Equal(archiveInfo, props.ArchiveInfo))
+
+	// The archive info is kept when the ledger height changes
+	gc.UpdateLedgerHeight(11)
+	props = gc.Self().GetStateInfo().Properties
+	assert.Equal(t, uint64(11), props.LedgerHeight)
+	assert.True(t, gproto.Equal(archiveInfo, props.ArchiveInfo))
+}
+
 func TestMsgStoreNotExpire(t *testing.T) {
 	t.Parallel()
 
@@ -53,6 +53,10 @@ type Gossip interface {
 	// to other peers in the channel
 	UpdateChaincodes(chaincode []*proto.Chaincode, chainID common.ChainID)
 
+	// UpdateArchiveInfo updates the archiver role and the archived block ranges
+	// the peer publishes to other peers in the channel
+	UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo, chainID common.ChainID)
+
 	// Gossip sends a message to other peers to the network
 	Gossip(msg *proto.GossipMessage)
 
@@ -785,6 +785,17 @@ func (g *gossipServiceImpl) UpdateChaincodes(chaincodes []*proto.Chaincode, chai
 	gc.UpdateChaincodes(chaincodes)
 }
 
+// UpdateArchiveInfo updates the archiver role and the archived block ranges
+// the peer publishes to other peers in the channel
+func (g *gossipServiceImpl) UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo, chainID common.ChainID) {
+	gc := g.chanState.getGossipChannelByChainID(chainID)
+	if gc == nil {
+		g.logger.Warning("No such channel", chainID)
+		return
+	}
+	gc.UpdateArchiveInfo(archiveInfo)
+}
+
 // Accept returns a dedicated read-only channel for messages sent by other nodes that match a certain predicate.
 // If passThrough is false, the messages are processed by the gossip layer beforehand.
 // If passThrough is true, the gossip layer doesn't intervene and the messages
@@ -54,6 +54,65 @@ func IsArchivedBlockfileMsg(m *gossip.GossipMessage) bool {
 	return m.GetArchivedBlockfile() != nil
 }
 
+// IsArchiver returns whether the properties published by a peer
+// advertise it as the archiver of the channel
+func IsArchiver(props *gossip.Properties) bool {
+	return props.GetArchiveInfo().GetArchiver()
+}
+
+// OldestLocalBlock returns the oldest block the properties published by a peer advertise
+// as available on its local file system. The older blocks are fetched from the archive.
+func OldestLocalBlock(props *gossip.Properties) uint64 {
+	return props.GetArchiveInfo().GetOldestLocalBlock()
+}
+
+// IsArchivingEnabled returns whether the properties published by a peer
+// advertise that block archiving is enabled on it for the channel
+func IsArchivingEnabled(props *gossip.Properties) bool {
+	return props.GetArchiveInfo() != nil
+}
+
+// IsFetchEnabled returns whether the properties published by a peer advertise that it serves
+// the blocks older than its oldest local block by fetching them from the archive
+func IsFetchEnabled(props *gossip.Properties) bool {
+	return props.GetArchiveInfo().GetFetchEnabled()
+}
+
+// ArchivedUpTo returns the last block the properties published by a peer advertise such that it
+// and all the blocks before it are recorded in the archive catalog of the peer
+func ArchivedUpTo(props *gossip.Properties) uint64 {
+	return props.GetArchiveInfo().GetArchivedUpTo()
+}
+
+// CatalogDigest returns the digest of the archive catalog of the peer advertised by the properties
+// it publishes, empty while nothing has been archived
+func CatalogDigest(props *gossip.Properties) []byte {
+	return props.GetArchiveInfo().GetCatalogDigest()
+}
+
+// CanServeBlock returns whether the properties published by a peer advertise that it serves the given
+// block, either from its local file system or, with a higher latency, by fetching it from the archive
+func CanServeBlock(props *gossip.Properties, blockNum uint64) bool {
+	if blockNum >= props.GetLedgerHeight() {
+		return false
+	}
+	return blockNum >= OldestLocalBlock(props) || IsFetchEnabled(props)
+}
+
+// HasArchivedBlock returns whether the properties published by a peer
+// advertise it as an archiver which has archived the given block
+func HasArchivedBlock(props *gossip.Properties, blockNum uint64) bool {
+	if !IsArchiver(props) {
+		return false
+	}
+	for _, r := range props.GetArchiveInfo().GetArchivedRanges() {
+		if r.FirstBlock <= blockNum && blockNum <= r.LastBlock {
+			return true
+		}
+	}
+	return false
+}
+
 // GetPullMsgType returns the phase of the pull mechanism this GossipMessage belongs to
 // for example: Hello, Digest, etc.
 // If this isn't a pull message, PullMsgType_UNDEFINED is returned.
@@ -436,3 +436,56 @@ func TestGossipMessageLeadershipMessageTagType(t *testing.T) {
 	}
 	assert.Error(t, protoext.IsTagLegal(msg))
 }
+
+func TestHasArchivedBlock(t *testing.T) {
+	assert.False(t, protoext.IsArchiver(nil))
+	assert.False(t, protoext.HasArchivedBlock(&gossip.Properties{LedgerHeight: 10}, 0))
+
+	props := &gossip.Properties{
+		ArchiveInfo: &gossip.ArchiveInfo{
+			Archiver: true,
+			ArchivedRanges: []*gossip.BlockRange{
+				{FirstBlock: 0, LastBlock: 9},
+				{FirstBlock: 20, LastBlock: 29},
+			},
+		},
+	}
+	assert.True(t, protoext.IsArchiver(props))
+	assert.True(t, protoext.HasArchivedBlock(props, 0))
+	assert.True(t, protoext.HasArchivedBlock(props, 9))
+	assert.False(t, protoext.HasArchivedBlock(props, 15))
+	assert.True(t, protoext.HasArchivedBlock(props, 25))
+	assert.False(t, protoext.HasArchivedBlock(props, 30))
+
+	props.ArchiveInfo.Archiver = false
+	assert.False(t, protoext.HasArchivedBlock(props, 0))
+}
+
+func TestOldestLocalBlock(t *testing.T) {
+	assert.Equal(t, uint64(0), protoext.OldestLocalBlock(nil))
+	assert.Equal(t, uint64(0), protoext.OldestLocalBlock(&gossip.Properties{LedgerHeight: 10}))
+	assert.Equal(t, uint64(20), protoext.OldestLocalBlock(&gossip.Properties{
+		ArchiveInfo: &gossip.ArchiveInfo{OldestLocalBlock: 20},
+	}))
+}
+
+func TestCanServeBlock(t *testing.T) {
+	assert.False(t, protoext.IsArchivingEnabled(nil))
+	assert.False(t, protoext.CanServeBlock(nil, 0))
+
+	props := &gossip.Properties{LedgerHeight: 30}
+	assert.False(t, protoext.IsArchivingEnabled(props))
+	assert.False(t, protoext.IsFetchEnabled(props))
+	assert.True(t, protoext.CanServeBlock(props, 0))
+	assert.False(t, protoext.CanServeBlock(props, 30))
+
+	props.ArchiveInfo = &gossip.ArchiveInfo{OldestLocalBlock: 20}
+	assert.True(t, protoext.IsArchivingEnabled(props))
+	assert.False(t, protoext.CanServeBlock(props, 19))
+	assert.True(t, protoext.CanServeBlock(props, 20))
+
+	props.ArchiveInfo.FetchEnabled = true
+	assert.True(t, protoext.IsFetchEnabled(props))
+	assert.True(t, protoext.CanServeBlock(props, 0))
+	assert.False(t, protoext.CanServeBlock(props, 30))
+}
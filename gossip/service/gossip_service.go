@@ -211,6 +211,12 @@ func GetGossipService() GossipService {
 	return gossipServiceInstance
 }
 
+// IsGossipServiceInitialized tells if the gossip service has been initialized, which is not the case
+// when the ledgers are opened outside of a running peer
+func IsGossipServiceInitialized() bool {
+	return gossipServiceInstance != nil
+}
+
 // DistributePrivateData distribute private read write set inside the channel based on the collections policies
 func (g *gossipServiceImpl) DistributePrivateData(chainID string, txID string, privData *transientstore.TxPvtReadWriteSetWithConfigInfo, blkHt uint64) error {
 	g.lock.RLock()
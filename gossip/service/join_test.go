@@ -85,6 +85,12 @@ func (*gossipMock) UpdateChaincodes(chaincode []*proto.Chaincode, chainID common
 	panic("implement me")
 }
 
+// UpdateArchiveInfo updates the archiver role and the archived block ranges
+// the peer publishes to other peers in the channel
+func (*gossipMock) UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo, chainID common.ChainID) {
+	panic("implement me")
+}
+
 func (*gossipMock) Gossip(msg *proto.GossipMessage) {
 	panic("implement me")
 }
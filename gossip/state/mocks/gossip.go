@@ -50,6 +50,12 @@ func (g *GossipMock) UpdateChaincodes(chaincode []*proto.Chaincode, chainID comm
 
 }
 
+// UpdateArchiveInfo updates the archiver role and the archived block ranges
+// the peer publishes to other peers in the channel
+func (g *GossipMock) UpdateArchiveInfo(archiveInfo *proto.ArchiveInfo, chainID common.ChainID) {
+
+}
+
 func (g *GossipMock) LeaveChan(_ common.ChainID) {
 	panic("implement me")
 }
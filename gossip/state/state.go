@@ -510,14 +510,16 @@ func (s *GossipStateProviderImpl) handleStateRequest(msg protoext.ReceivedMessag
 		}
 		block, pvtData, err := s.ledger.GetPvtDataAndBlockByNum(seqNum, peerAuthInfo)
 
+		// Stop at the first block which can't be read, e.g. when it has been discarded after being archived
+		// and the repository can't be reached, so that the requester asks the following blocks to another peer
 		if err != nil {
-			logger.Errorf("cannot read block number %d from ledger, because %+v, skipping...", seqNum, err)
-			continue
+			logger.Errorf("cannot read block number %d from ledger, because %+v, stopping...", seqNum, err)
+			break
 		}
 
 		if block == nil {
-			logger.Errorf("Wasn't able to read block with sequence number %d from ledger, skipping....", seqNum)
-			continue
+			logger.Errorf("Wasn't able to read block with sequence number %d from ledger, stopping....", seqNum)
+			break
 		}
 
 		blockBytes, err := pb.Marshal(block)
@@ -738,6 +740,13 @@ func (s *GossipStateProviderImpl) requestBlocksInRange(start uint64, end uint64)
 					prev, next, errors.WithStack(err))
 				return
 			}
+			if tryCounts > 0 {
+				// The peer asked before may have discarded the blocks after they have been archived,
+				// so rather ask an archiver which serves them from the repository if there is one
+				if archiver := s.selectArchiverToRequestFrom(prev, next); archiver != nil {
+					peer = archiver
+				}
+			}
 
 			logger.Debugf("State transfer, with peer %s, requesting blocks in range [%d...%d), "+
 				"for chainID %s", peer.Endpoint, prev, next, s.chainID)
@@ -798,6 +807,21 @@ func (s *GossipStateProviderImpl) selectPeerToRequestFrom(height uint64) (*comm.
 	return peers[util.RandomInt(n)], nil
 }
 
+// selectArchiverToRequestFrom selects a peer among the archivers which have archived the given block
+// into the repository and posses the required height, or returns nil if there is no such peer
+func (s *GossipStateProviderImpl) selectArchiverToRequestFrom(blockNum uint64, height uint64) *comm.RemotePeer {
+	hasRequiredHeight := s.hasRequiredHeight(height)
+	peers := s.filterPeers(func(peer discovery.NetworkMember) bool {
+		return hasRequiredHeight(peer) && protoext.HasArchivedBlock(peer.Properties, blockNum)
+	})
+
+	n := len(peers)
+	if n == 0 {
+		return nil
+	}
+	return peers[util.RandomInt(n)]
+}
+
 // filterPeers returns list of peers which aligns the predicate provided
 func (s *GossipStateProviderImpl) filterPeers(predicate func(peer discovery.NetworkMember) bool) []*comm.RemotePeer {
 	var peers []*comm.RemotePeer
@@ -254,6 +254,10 @@ func (*mockCommitter) CommitPvtDataOfOldBlocks(blockPvtData []*ledger.BlockPvtDa
 	panic("implement me")
 }
 
+func (*mockCommitter) SetArchived(blockFileNo int, deleteTheFile bool) error {
+	return nil
+}
+
 func (*mockCommitter) Close() {
 }
 
@@ -323,6 +327,10 @@ func (mock *ramLedger) GetBlockByNumber(blockNumber uint64) (*pcomm.Block, error
 	}
 }
 
+func (mock *ramLedger) SetArchived(blockFileNo int, deleteTheFile bool) error {
+	return nil
+}
+
 func (mock *ramLedger) Close() {
 
 }
@@ -943,6 +951,42 @@ func TestLedgerHeightFromProperties(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSelectArchiverToRequestFrom(t *testing.T) {
+	t.Parallel()
+	archiver := discovery.NetworkMember{
+		PKIid:            common.PKIidType("archiver"),
+		InternalEndpoint: "archiver",
+		Properties: &proto.Properties{
+			LedgerHeight: 100,
+			ArchiveInfo: &proto.ArchiveInfo{
+				Archiver:       true,
+				ArchivedRanges: []*proto.BlockRange{{FirstBlock: 0, LastBlock: 49}},
+			},
+		},
+	}
+	peer := discovery.NetworkMember{
+		PKIid:            common.PKIidType("peer"),
+		InternalEndpoint: "peer",
+		Properties: &proto.Properties{
+			LedgerHeight: 100,
+		},
+	}
+	g := &mocks.GossipMock{}
+	g.On("PeersOfChannel", mock.Anything).Return([]discovery.NetworkMember{peer, archiver})
+	s := &GossipStateProviderImpl{
+		chainID:  util.GetTestChainID(),
+		mediator: &ServicesMediator{GossipAdapter: g},
+	}
+
+	selected := s.selectArchiverToRequestFrom(10, 20)
+	assert.NotNil(t, selected)
+	assert.Equal(t, archiver.PKIid, selected.PKIID)
+	// The block has not been archived
+	assert.Nil(t, s.selectArchiverToRequestFrom(50, 60))
+	// The archiver doesn't posses the required height
+	assert.Nil(t, s.selectArchiverToRequestFrom(10, 101))
+}
+
 func TestAccessControl(t *testing.T) {
 	t.Parallel()
 	bootstrapSetSize := 5
@@ -102,7 +102,7 @@ func getinfo(cmd *cobra.Command, cf *ChannelCmdFactory) error {
 	if err != nil {
 		return err
 	}
-	jsonBytes, err := json.Marshal(blockChainInfo)
+	jsonBytes, err := json.Marshal(newBlockchainInfoOutput(blockChainInfo))
 	if err != nil {
 		return err
 	}
@@ -111,3 +111,26 @@ func getinfo(cmd *cobra.Command, cf *ChannelCmdFactory) error {
 
 	return nil
 }
+
+// blockchainInfoOutput is the BlockchainInfo printed by getinfo, whose archiving
+// statistics are printed even when they are zero
+type blockchainInfoOutput struct {
+	*cb.BlockchainInfo
+	ArchiveInfo *archiveInfoOutput `json:"archiveInfo,omitempty"`
+}
+
+type archiveInfoOutput struct {
+	OldestLocalBlock uint64 `json:"oldestLocalBlock"`
+	ArchivedUpTo     uint64 `json:"archivedUpTo"`
+}
+
+func newBlockchainInfoOutput(info *cb.BlockchainInfo) *blockchainInfoOutput {
+	output := &blockchainInfoOutput{BlockchainInfo: info}
+	if archiveInfo := info.GetArchiveInfo(); archiveInfo != nil {
+		output.ArchiveInfo = &archiveInfoOutput{
+			OldestLocalBlock: archiveInfo.OldestLocalBlock,
+			ArchivedUpTo:     archiveInfo.ArchivedUpTo,
+		}
+	}
+	return output
+}
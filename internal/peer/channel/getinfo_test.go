@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package channel
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -54,6 +55,18 @@ func TestGetChannelInfo(t *testing.T) {
 	assert.NoError(t, cmd.Execute())
 }
 
+func TestBlockchainInfoOutput(t *testing.T) {
+	info := &cb.BlockchainInfo{Height: 30, CurrentBlockHash: []byte("CurrentBlockHash")}
+	b, err := json.Marshal(newBlockchainInfoOutput(info))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"height":30,"currentBlockHash":"Q3VycmVudEJsb2NrSGFzaA=="}`, string(b))
+
+	info.ArchiveInfo = &cb.BlockchainArchiveInfo{ArchivedUpTo: 19}
+	b, err = json.Marshal(newBlockchainInfoOutput(info))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"height":30,"currentBlockHash":"Q3VycmVudEJsb2NrSGFzaA==","archiveInfo":{"oldestLocalBlock":0,"archivedUpTo":19}}`, string(b))
+}
+
 func TestGetChannelInfoMissingChannelID(t *testing.T) {
 	InitMSP()
 	resetFlags()
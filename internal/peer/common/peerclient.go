@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 
 	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/pkg/errors"
 )
@@ -103,6 +104,15 @@ func (pc *PeerClient) Admin() (pb.AdminClient, error) {
 	return pb.NewAdminClient(conn), nil
 }
 
+// ArchiverRole returns a client for the ArchiverRole service
+func (pc *PeerClient) ArchiverRole() (archive.ArchiverRoleClient, error) {
+	conn, err := pc.commonClient.NewConnection(pc.address, pc.sn)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "archiver role client failed to connect to %s", pc.address)
+	}
+	return archive.NewArchiverRoleClient(conn), nil
+}
+
 // Certificate returns the TLS client certificate (if available)
 func (pc *PeerClient) Certificate() tls.Certificate {
 	return pc.commonClient.Certificate()
@@ -145,6 +155,16 @@ func GetAdminClient() (pb.AdminClient, error) {
 	return peerClient.Admin()
 }
 
+// GetArchiverRoleClient returns a new client of the ArchiverRole service. The target address
+// for the client is taken from the configuration setting "peer.address"
+func GetArchiverRoleClient() (archive.ArchiverRoleClient, error) {
+	peerClient, err := NewPeerClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return peerClient.ArchiverRole()
+}
+
 // GetDeliverClient returns a new deliver client. If both the address and
 // tlsRootCertFile are not provided, the target values for the client are taken
 // from the configuration settings for "peer.address" and
@@ -0,0 +1,567 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/hyperledger/fabric/internal/peer/common"
+	common2 "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	archiveChannelID  string
+	archiveTargetKeep int
+	archiveEach       int
+	archiveBandwidth  int
+	archiveFrom       string
+	archiveOutput     string
+	archiveInput      string
+	archiveHeal       bool
+	archiveFromBlock  int64
+	archiveToBlock    int64
+	archiveSince      string
+	archiveUntil      string
+	archiveStartAfter int64
+	archiveLimit      int
+	archiveVerify     bool
+	archiveFormat     string
+	archiveAudit      bool
+	archiveReportType string
+	archiveMethod     string
+	archiveData       string
+	archiveCAFile     string
+	archiveCertFile   string
+	archiveKeyFile    string
+	archiveKeyID      string
+)
+
+func archiveCmd() *cobra.Command {
+	nodeArchiveCmd.AddCommand(archivePlanCmd())
+	nodeArchiveCmd.AddCommand(archiveAcquireCmd())
+	nodeArchiveCmd.AddCommand(archiveExportCatalogCmd())
+	nodeArchiveCmd.AddCommand(archiveImportCatalogCmd())
+	nodeArchiveCmd.AddCommand(archiveReconcileCmd())
+	nodeArchiveCmd.AddCommand(archiveRekeyCmd())
+	nodeArchiveCmd.AddCommand(archiveListCmd())
+	nodeArchiveCmd.AddCommand(archiveCustodyReportCmd())
+	nodeArchiveCmd.AddCommand(archiveSnapshotCmd())
+	nodeArchiveCmd.AddCommand(archiveVerifySnapshotCmd())
+	nodeArchiveCmd.AddCommand(archiveRequestCmd())
+	return nodeArchiveCmd
+}
+
+var nodeArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Block archiving tools: plan, acquire, export-catalog, import-catalog, reconcile, list, custody-report, snapshot, verify-snapshot, request.",
+	Long:  `Block archiving tools: plan, acquire, export-catalog, import-catalog, reconcile, list, custody-report, snapshot, verify-snapshot, request.`,
+}
+
+func archivePlanCmd() *cobra.Command {
+	flags := nodeArchivePlanCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose blockstore is analyzed")
+	flags.IntVar(&archiveTargetKeep, "target-keep", -1, "Least number of blockfiles kept on the local file system (default peer.archiver.keep)")
+	flags.IntVar(&archiveEach, "each", 0, "Number of blockfiles archived at once (default peer.archiver.each)")
+	flags.IntVar(&archiveBandwidth, "bandwidth", 0, "Bandwidth to the repository in MB/s (default ledger.blockArchiver.bandwidth)")
+	return nodeArchivePlanCmd
+}
+
+var nodeArchivePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Projects the archiving of the blockfiles of a channel.",
+	Long: `Analyzes the blockstore of a channel and prints the blockfiles which would be archived, ` +
+		`the projected local disk usage and the estimated transfer time, for the given archiving parameters. ` +
+		`The peer does not need to be running.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		each, keep := ledgerconfig.GetArchivingParameters()
+		if archiveEach > 0 {
+			each = archiveEach
+		}
+		if archiveTargetKeep >= 0 {
+			keep = archiveTargetKeep
+		}
+		bandwidth := ledgerconfig.GetArchivingBandwidth()
+		if archiveBandwidth > 0 {
+			bandwidth = archiveBandwidth
+		}
+		plan, err := fsblkstorage.PlanArchiving(ledgerconfig.GetBlockStorePath(), archiveChannelID, each, keep, ledgerconfig.GetChannelMaxBlockfileSize(archiveChannelID))
+		if err != nil {
+			return err
+		}
+		printArchivePlan(os.Stdout, plan, bandwidth)
+		return nil
+	},
+}
+
+func archiveAcquireCmd() *cobra.Command {
+	flags := nodeArchiveAcquireCmd.Flags()
+	flags.StringVar(&archiveFrom, "from", "", "Address of the current archiver peer of the organization")
+	return nodeArchiveAcquireCmd
+}
+
+var nodeArchiveAcquireCmd = &cobra.Command{
+	Use:   "acquire",
+	Short: "Makes the peer the archiver of its organization.",
+	Long: `Hands the archiver role off from the archiver peer given with --from to the running peer at peer.address, ` +
+		`which must be a client peer of the same organization. The archiving is stopped on the archiver peer, ` +
+		`the archived blockfiles are verified against the repository and the blockfiles of the peer, ` +
+		`then the archive catalog is transferred and both peers switch their roles without restarting.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveFrom == "" {
+			return errors.New("the archiver peer must be specified with --from")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+		return acquireArchiverRole(archiveFrom)
+	},
+}
+
+func archiveExportCatalogCmd() *cobra.Command {
+	flags := nodeArchiveExportCatalogCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose archive catalog is exported")
+	flags.StringVarP(&archiveOutput, "output", "o", "", "File the archive catalog is written to (default standard output)")
+	return nodeArchiveExportCatalogCmd
+}
+
+var nodeArchiveExportCatalogCmd = &cobra.Command{
+	Use:   "export-catalog",
+	Short: "Exports the archive catalog of a channel.",
+	Long: `Writes the archive catalog of a channel, i.e. the archived blockfiles with their block range and location ` +
+		`on the repository, to a portable JSON file which can be imported on another peer with import-catalog. ` +
+		`The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		state, err := fsblkstorage.ExportArchiveCatalog(ledgerconfig.GetBlockStorePath(), archiveChannelID)
+		if err != nil {
+			return err
+		}
+		if archiveOutput == "" {
+			return writeArchiveCatalog(os.Stdout, state)
+		}
+		file, err := os.Create(archiveOutput)
+		if err != nil {
+			return errors.Wrapf(err, "error creating %s", archiveOutput)
+		}
+		if err := writeArchiveCatalog(file, state); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	},
+}
+
+func archiveImportCatalogCmd() *cobra.Command {
+	flags := nodeArchiveImportCatalogCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose archive catalog is imported (default the channel of the file)")
+	flags.StringVarP(&archiveInput, "input", "i", "", "File written by export-catalog")
+	return nodeArchiveImportCatalogCmd
+}
+
+var nodeArchiveImportCatalogCmd = &cobra.Command{
+	Use:   "import-catalog",
+	Short: "Imports the archive catalog of a channel exported from another peer.",
+	Long: `Records the archived blockfiles of a file written by export-catalog in the archive catalog of the channel, ` +
+		`so that a rebuilt or replaced peer knows which blocks are archived and where without rescanning the repository, ` +
+		`and archives the next blockfiles from where the previous peer stopped. ` +
+		`The blockfiles on the local file system must hold the blocks recorded for them. The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveInput == "" {
+			return errors.New("the file to import must be specified with --input")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		file, err := os.Open(archiveInput)
+		if err != nil {
+			return errors.Wrapf(err, "error opening %s", archiveInput)
+		}
+		defer file.Close()
+		state := &archive.ChannelArchiverState{}
+		if err := jsonpb.Unmarshal(file, state); err != nil {
+			return errors.Wrapf(err, "error parsing the archive catalog %s", archiveInput)
+		}
+		if archiveChannelID != "" && archiveChannelID != state.ChannelId {
+			return errors.Errorf("%s holds the archive catalog of channel [%s], not [%s]", archiveInput, state.ChannelId, archiveChannelID)
+		}
+		// The entries recorded by the peers of another network are rejected
+		blockarchive.NetworkID = viper.GetString("peer.networkId")
+		blockarchive.Environment = ledgerconfig.GetBlockArchiverEnvironment()
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		n, err := fsblkstorage.ImportArchiveCatalog(ledgerconfig.GetBlockStorePath(), state)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d archived blockfile(s) of channel %s\n", n, state.ChannelId)
+		return nil
+	},
+}
+
+func archiveReconcileCmd() *cobra.Command {
+	flags := nodeArchiveReconcileCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose archive catalog is reconciled")
+	flags.BoolVar(&archiveHeal, "heal", false, "Upload again the missing and mismatched blockfiles which are still on the local file system")
+	return nodeArchiveReconcileCmd
+}
+
+var nodeArchiveReconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Checks the archive catalog of a channel against the repository.",
+	Long: `Compares the archived blockfiles of the archive catalog of a channel with the content of the repository, ` +
+		`and reports the blockfiles missing from the repository, the ones whose content doesn't match their checksum, ` +
+		`and the blockfiles next to them which are not in the catalog. With --heal, the missing and mismatched blockfiles ` +
+		`which are still on the local file system are uploaded again. It fails if differences remain. The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		archiver.InitRepositoryAccess()
+		report, err := fsblkstorage.ReconcileArchive(ledgerconfig.GetBlockStorePath(), archiveChannelID, archiveHeal)
+		if err != nil {
+			return err
+		}
+		printReconcileReport(os.Stdout, report)
+		if !report.IsConsistent() {
+			return errors.Errorf("the archive catalog of channel %s does not match the repository", archiveChannelID)
+		}
+		return nil
+	},
+}
+
+func archiveRekeyCmd() *cobra.Command {
+	flags := nodeArchiveRekeyCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose archived blockfiles are encrypted again")
+	flags.StringVar(&archiveKeyID, "key", "", "ID of the key of ledger.blockArchiver.encryption.keyDir the blockfiles are encrypted with (default ledger.blockArchiver.encryption.activeKey)")
+	return nodeArchiveRekeyCmd
+}
+
+var nodeArchiveRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Encrypts again the archived blockfiles of a channel with a new key.",
+	Long: `Encrypts again the archived blockfiles of the archive catalog of a channel with a key of ` +
+		`ledger.blockArchiver.encryption.keyDir, for the periodic rotations of the keys. Each blockfile is streamed from ` +
+		`the repository, decrypted with the key it is encrypted with, which must still be in the key directory, ` +
+		`encrypted with the new key, uploaded next to the blockfile and verified against its checksum before it replaces ` +
+		`the blockfile. The key is then recorded in the catalog. The blockfiles already encrypted with the key are ` +
+		`skipped, so that an interrupted rotation is resumed by running the command again. It fails if blockfiles ` +
+		`remain, such as the ones kept by an object lock. The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		archiver.InitRepositoryAccess()
+		keyID := archiveKeyID
+		if keyID == "" {
+			keyID = blockarchive.EncryptionKeyID
+		}
+		if keyID == "" {
+			return errors.New("the key must be specified with --key or ledger.blockArchiver.encryption.activeKey")
+		}
+		report, err := fsblkstorage.RekeyArchive(ledgerconfig.GetBlockStorePath(), archiveChannelID, keyID)
+		if report != nil {
+			printRekeyReport(os.Stdout, report)
+		}
+		if err != nil {
+			return err
+		}
+		if !report.IsComplete() {
+			return errors.Errorf("some archived blockfiles of channel %s are not encrypted with key %s", archiveChannelID, keyID)
+		}
+		return nil
+	},
+}
+
+func archiveListCmd() *cobra.Command {
+	flags := nodeArchiveListCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose archived blockfiles are listed")
+	flags.Int64Var(&archiveFromBlock, "from-block", 0, "Lists the blockfiles holding blocks from this block number")
+	flags.Int64Var(&archiveToBlock, "to-block", -1, "Lists the blockfiles holding blocks up to this block number (default the last block)")
+	flags.StringVar(&archiveSince, "since", "", "Lists the blockfiles archived from this time, RFC 3339 or YYYY-MM-DD")
+	flags.StringVar(&archiveUntil, "until", "", "Lists the blockfiles archived up to this time, RFC 3339 or YYYY-MM-DD (the whole day)")
+	flags.Int64Var(&archiveStartAfter, "start-after", -1, "Lists the blockfiles after this blockfile number, printed at the end of the previous page")
+	flags.IntVar(&archiveLimit, "limit", 0, "Maximum number of blockfiles listed (default all)")
+	flags.BoolVar(&archiveVerify, "verify", false, "Checks that the listed blockfiles are on the repository")
+	flags.StringVarP(&archiveFormat, "output-format", "f", "text", "Output format, text or json")
+	return nodeArchiveListCmd
+}
+
+var nodeArchiveListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the archived blockfiles of a channel.",
+	Long: `Lists the archived blockfiles of the archive catalog of a channel with their block range, location on the ` +
+		`repository and archive time, filtered by block range with --from-block and --to-block and by archive time ` +
+		`with --since and --until. The blockfiles archived before their archive time was recorded are only listed ` +
+		`without a time filter. The listing is paged with --limit and --start-after. With --verify, the presence of ` +
+		`the listed blockfiles on the repository is checked. The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		if archiveFormat != "text" && archiveFormat != "json" {
+			return errors.Errorf("unsupported output format [%s], expected text or json", archiveFormat)
+		}
+		filter, err := archiveListFilter()
+		if err != nil {
+			return err
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		if archiveVerify {
+			archiver.InitRepositoryAccess()
+		}
+		listing, err := fsblkstorage.ListArchivedBlockfiles(ledgerconfig.GetBlockStorePath(), archiveChannelID, filter, archiveVerify)
+		if err != nil {
+			return err
+		}
+		if archiveFormat == "json" {
+			return writeArchiveListing(os.Stdout, listing)
+		}
+		printArchiveListing(os.Stdout, listing)
+		return nil
+	},
+}
+
+// archiveListFilter builds the filter of the list command from its flags
+func archiveListFilter() (*fsblkstorage.ArchiveListFilter, error) {
+	filter := fsblkstorage.AllArchivedBlockfiles()
+	if archiveFromBlock < 0 {
+		return nil, errors.Errorf("invalid --from-block [%d]", archiveFromBlock)
+	}
+	filter.FromBlock = uint64(archiveFromBlock)
+	if archiveToBlock >= 0 {
+		if archiveToBlock < archiveFromBlock {
+			return nil, errors.Errorf("--to-block [%d] is lower than --from-block [%d]", archiveToBlock, archiveFromBlock)
+		}
+		filter.ToBlock = uint64(archiveToBlock)
+	}
+	var err error
+	if filter.Since, err = parseArchiveTime(archiveSince, false); err != nil {
+		return nil, errors.WithMessage(err, "invalid --since")
+	}
+	if filter.Until, err = parseArchiveTime(archiveUntil, true); err != nil {
+		return nil, errors.WithMessage(err, "invalid --until")
+	}
+	if archiveLimit < 0 {
+		return nil, errors.Errorf("invalid --limit [%d]", archiveLimit)
+	}
+	filter.StartAfter = archiveStartAfter
+	filter.Limit = archiveLimit
+	return filter, nil
+}
+
+// parseArchiveTime parses a time given as RFC 3339 or as a date, the zero time when empty. A date is the start
+// of the day in UTC, or its last nanosecond when endOfDay is set.
+func parseArchiveTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("[%s], expected RFC 3339 or YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// printArchiveListing prints a page of archived blockfiles as a table
+func printArchiveListing(w io.Writer, listing *fsblkstorage.ArchiveListing) {
+	fmt.Fprintf(w, "Channel %s: %d archived blockfile(s) listed\n", listing.LedgerID, len(listing.Blockfiles))
+	if len(listing.Blockfiles) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "BLOCKFILE\tBLOCKS\tARCHIVED AT\tDISCARDED\tLOCATION\tREPOSITORY")
+		for _, listed := range listing.Blockfiles {
+			archivedAt := "-"
+			if listed.ArchivedAt != nil {
+				archivedAt = listed.ArchivedAt.Format(time.RFC3339)
+			}
+			status := listed.RepositoryStatus
+			if status == "" {
+				status = "-"
+			}
+			fmt.Fprintf(tw, "%d\t%d-%d\t%s\t%t\t%s\t%s\n", listed.BlockfileNo, listed.FirstBlockNum, listed.LastBlockNum,
+				archivedAt, listed.Discarded, listed.Location, status)
+		}
+		tw.Flush()
+	}
+	if listing.NextStartAfter >= 0 {
+		fmt.Fprintf(w, "More blockfiles are archived, continue with --start-after %d\n", listing.NextStartAfter)
+	}
+}
+
+// writeArchiveListing writes a page of archived blockfiles as JSON
+func writeArchiveListing(w io.Writer, listing *fsblkstorage.ArchiveListing) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(listing); err != nil {
+		return errors.Wrap(err, "error writing the archived blockfiles")
+	}
+	return nil
+}
+
+// printReconcileReport prints the differences between the archive catalog and the repository
+func printReconcileReport(w io.Writer, report *fsblkstorage.ReconcileReport) {
+	fmt.Fprintf(w, "Channel %s: %d archived blockfile(s) checked\n", report.LedgerID, report.Checked)
+	for _, info := range report.Healed {
+		fmt.Fprintf(w, "Uploaded again:  blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Repaired {
+		fmt.Fprintf(w, "Repaired:        blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Missing {
+		fmt.Fprintf(w, "Missing:         blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Mismatched {
+		fmt.Fprintf(w, "Digest mismatch: blockfile [%d] at %s, expected %s\n", info.BlockfileNo, info.Location, info.Checksum)
+	}
+	for _, location := range report.Orphaned {
+		fmt.Fprintf(w, "Orphaned:        %s\n", location)
+	}
+}
+
+func printRekeyReport(w io.Writer, report *fsblkstorage.RekeyReport) {
+	fmt.Fprintf(w, "Channel %s: %d archived blockfile(s) checked, %d already encrypted with key %s\n",
+		report.LedgerID, report.Checked, report.UpToDate, report.KeyID)
+	for _, info := range report.Rekeyed {
+		fmt.Fprintf(w, "Encrypted again: blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Locked {
+		fmt.Fprintf(w, "Locked:          blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Missing {
+		fmt.Fprintf(w, "Missing:         blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+}
+
+// writeArchiveCatalog writes an exported archive catalog as JSON
+func writeArchiveCatalog(w io.Writer, state *archive.ChannelArchiverState) error {
+	m := &jsonpb.Marshaler{Indent: "  "}
+	if err := m.Marshal(w, state); err != nil {
+		return errors.Wrap(err, "error writing the archive catalog")
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func acquireArchiverRole(sourceAddress string) error {
+	client, err := common.GetArchiverRoleClient()
+	if err != nil {
+		return err
+	}
+	signer, err := common.GetDefaultSignerFnc()
+	if err != nil {
+		return errors.Errorf("failed obtaining default signer: %v", err)
+	}
+	env, err := protoutil.CreateSignedEnvelope(common2.HeaderType_MESSAGE, "", signer, &archive.AcquireRoleRequest{SourceAddress: sourceAddress}, 0, 0)
+	if err != nil {
+		return errors.WithMessage(err, "error creating the request")
+	}
+	state, err := client.AcquireRole(context.Background(), env)
+	if err != nil {
+		return errors.WithMessagef(err, "error acquiring the archiver role from %s", sourceAddress)
+	}
+	printArchiverState(os.Stdout, sourceAddress, state)
+	return nil
+}
+
+// printArchiverState prints the state of the archiving taken over from the peer at sourceAddress
+func printArchiverState(w io.Writer, sourceAddress string, state *archive.ArchiverState) {
+	fmt.Fprintf(w, "The peer is now the archiver, taken over from %s\n", sourceAddress)
+	for _, channel := range state.Channels {
+		fmt.Fprintf(w, "Channel %s: %d archived blockfile(s), next blockfile to archive [%d]\n",
+			channel.ChannelId, len(channel.Blockfiles), channel.NextBlockfileNo)
+	}
+}
+
+// printArchivePlan prints a plan with the transfer time estimated at bandwidth MB/s
+func printArchivePlan(w io.Writer, plan *fsblkstorage.ArchivePlan, bandwidth int) {
+	fmt.Fprintf(w, "Channel:                      %s\n", plan.LedgerID)
+	fmt.Fprintf(w, "Archiving parameters:         each=%d keep=%d\n", plan.Each, plan.Keep)
+	fmt.Fprintf(w, "Local blockfiles:             %d (%s)\n", plan.NumLocalBlockfiles, formatSize(plan.LocalSize))
+	if plan.NumToArchive == 0 {
+		fmt.Fprintf(w, "Blockfiles to archive:        0\n")
+	} else {
+		fmt.Fprintf(w, "Blockfiles to archive:        %d (%s), blockfile [%d] to [%d]\n",
+			plan.NumToArchive, formatSize(plan.SizeToArchive), plan.FirstToArchive, plan.LastToArchive)
+	}
+	fmt.Fprintf(w, "Projected local disk usage:   %s\n", formatSize(plan.ProjectedLocalSize))
+	fmt.Fprintf(w, "Steady local disk usage:      %s to %s\n", formatSize(plan.MinSteadyLocalSize), formatSize(plan.MaxSteadyLocalSize))
+	transferTime := time.Duration(float64(plan.SizeToArchive) / float64(bandwidth*1024*1024) * float64(time.Second))
+	fmt.Fprintf(w, "Estimated transfer time:      %s at %d MB/s\n", transferTime.Round(time.Second), bandwidth)
+}
+
+// formatSize formats a number of bytes with a binary unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,273 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/hyperledger/fabric/internal/peer/common"
+	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
+	mspprotos "github.com/hyperledger/fabric/protos/msp"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func archiveCustodyReportCmd() *cobra.Command {
+	flags := nodeArchiveCustodyReportCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose blocks are reported")
+	flags.Int64Var(&archiveFromBlock, "from-block", 0, "First block of the report")
+	flags.Int64Var(&archiveToBlock, "to-block", -1, "Last block of the report (default the last block)")
+	flags.BoolVar(&archiveAudit, "audit", false, "Verifies the archived blockfiles on the repository against their checksum")
+	flags.StringVarP(&archiveReportType, "output-format", "f", "json", "Output format, json or html")
+	flags.StringVarP(&archiveOutput, "output", "o", "", "File the report is written to (default standard output)")
+	return nodeArchiveCustodyReportCmd
+}
+
+var nodeArchiveCustodyReportCmd = &cobra.Command{
+	Use:   "custody-report",
+	Short: "Produces the signed chain-of-custody report of a block range of a channel.",
+	Long: `Reports for each block of the range of a channel whether it resides on the local file system or in the archive, ` +
+		`with the digests of the blocks and of their blockfiles, the signed manifests of the archived blockfiles, ` +
+		`the audit of the archived blockfiles on the repository with --audit, and the verification of the hash chain ` +
+		`of the blocks as ledgerfsck does. The report is signed with the local MSP identity of the peer and written as ` +
+		`JSON, or as HTML to be printed to PDF, which embeds the signed JSON. It fails if a check doesn't pass. ` +
+		`The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		if archiveReportType != "json" && archiveReportType != "html" {
+			return errors.Errorf("unsupported output format [%s], expected json or html", archiveReportType)
+		}
+		if archiveFromBlock < 0 {
+			return errors.Errorf("invalid --from-block [%d]", archiveFromBlock)
+		}
+		toBlock := uint64(math.MaxUint64)
+		if archiveToBlock >= 0 {
+			if archiveToBlock < archiveFromBlock {
+				return errors.Errorf("--to-block [%d] is lower than --from-block [%d]", archiveToBlock, archiveFromBlock)
+			}
+			toBlock = uint64(archiveToBlock)
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		signer, err := common.GetDefaultSignerFnc()
+		if err != nil {
+			return errors.Errorf("failed obtaining default signer: %v", err)
+		}
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		archiver.InitRepositoryAccess()
+		// The manifests are signed by the archiver peer of the organization
+		blockarchive.ManifestVerifier = mspmgmt.GetLocalMSP()
+		report, err := fsblkstorage.CustodyReportOf(ledgerconfig.GetBlockStorePath(), archiveChannelID,
+			uint64(archiveFromBlock), toBlock, archiveAudit)
+		if err != nil {
+			return err
+		}
+		signed, err := signCustodyReport(report, signer)
+		if err != nil {
+			return err
+		}
+		if err := writeCustodyReportTo(archiveOutput, signed, archiveReportType); err != nil {
+			return err
+		}
+		if !report.Passed() {
+			return errors.Errorf("the chain of custody of blocks [%d-%d] of channel %s did not pass all the checks",
+				report.FromBlock, report.ToBlock, archiveChannelID)
+		}
+		return nil
+	},
+}
+
+// custodySigner signs the chain-of-custody reports, usually the local MSP identity of the peer
+type custodySigner interface {
+	Sign(message []byte) ([]byte, error)
+	Serialize() ([]byte, error)
+	GetMSPIdentifier() string
+}
+
+// signedCustodyReport is a chain-of-custody report along with its signature, which covers the compact
+// JSON encoding of the report field, i.e. the field once passed through json.Compact
+type signedCustodyReport struct {
+	Report    *fsblkstorage.CustodyReport `json:"report"`
+	Signer    *custodyReportSigner        `json:"signer"`
+	Signature []byte                      `json:"signature"`
+}
+
+// custodyReportSigner identifies the signer of a chain-of-custody report
+type custodyReportSigner struct {
+	MSPID string `json:"mspId"`
+	// Certificate is the PEM encoded certificate of the signer
+	Certificate string `json:"certificate"`
+}
+
+// signCustodyReport signs the compact JSON encoding of the report
+func signCustodyReport(report *fsblkstorage.CustodyReport, signer custodySigner) (*signedCustodyReport, error) {
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling the chain-of-custody report")
+	}
+	signature, err := signer.Sign(reportBytes)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error signing the chain-of-custody report")
+	}
+	creator, err := signer.Serialize()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error serializing the identity of the signer")
+	}
+	sID := &mspprotos.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, sID); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling the identity of the signer")
+	}
+	return &signedCustodyReport{
+		Report:    report,
+		Signer:    &custodyReportSigner{MSPID: signer.GetMSPIdentifier(), Certificate: string(sID.IdBytes)},
+		Signature: signature,
+	}, nil
+}
+
+// writeCustodyReportTo writes a signed report in the format to the file at path, to the standard output if empty
+func writeCustodyReportTo(path string, signed *signedCustodyReport, format string) error {
+	write := writeCustodyReport
+	if format == "html" {
+		write = writeCustodyReportHTML
+	}
+	if path == "" {
+		return write(os.Stdout, signed)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "error creating %s", path)
+	}
+	if err := write(file, signed); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeCustodyReport writes a signed report as JSON
+func writeCustodyReport(w io.Writer, signed *signedCustodyReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(signed); err != nil {
+		return errors.Wrap(err, "error writing the chain-of-custody report")
+	}
+	return nil
+}
+
+// writeCustodyReportHTML writes a signed report as a self-contained HTML document laid out for printing,
+// which embeds the signed report as JSON so that the signature can be verified from the document
+func writeCustodyReportHTML(w io.Writer, signed *signedCustodyReport) error {
+	data := struct {
+		Report    *fsblkstorage.CustodyReport
+		Signer    *custodyReportSigner
+		Signature []byte
+		Passed    bool
+		// Signed is rendered as JSON in the script element
+		Signed *signedCustodyReport
+	}{signed.Report, signed.Signer, signed.Signature, signed.Report.Passed(), signed}
+	if err := custodyReportTemplate.Execute(w, data); err != nil {
+		return errors.Wrap(err, "error writing the chain-of-custody report")
+	}
+	return nil
+}
+
+var custodyReportTemplate = template.Must(template.New("custody-report").Funcs(template.FuncMap{
+	"time": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	},
+}).Parse(custodyReportHTML))
+
+const custodyReportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Chain of custody of blocks {{.Report.FromBlock}}-{{.Report.ToBlock}} of channel {{.Report.LedgerID}}</title>
+<style>
+@page { size: A4 landscape; margin: 15mm; }
+body { font-family: Helvetica, Arial, sans-serif; font-size: 9pt; color: #000; }
+h1 { font-size: 16pt; }
+h2 { font-size: 12pt; margin-top: 18pt; page-break-after: avoid; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #666; padding: 2pt 4pt; text-align: left; vertical-align: top; }
+th { background: #ddd; }
+tr { page-break-inside: avoid; }
+thead { display: table-header-group; }
+.digest { font-family: "Courier New", monospace; font-size: 7pt; word-break: break-all; }
+.PASS { color: #060; font-weight: bold; }
+.FAIL { color: #b00; font-weight: bold; }
+.NOT_CHECKED { color: #666; }
+pre { font-size: 7pt; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Chain of custody report</h1>
+<table>
+<tr><th>Channel</th><td>{{.Report.LedgerID}}</td></tr>
+<tr><th>Blocks</th><td>{{.Report.FromBlock}} to {{.Report.ToBlock}}</td></tr>
+<tr><th>Generated at</th><td>{{.Report.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}}</td></tr>
+<tr><th>Repository</th><td>{{if .Report.Repository}}{{.Report.Repository}}{{else}}-{{end}}</td></tr>
+<tr><th>Outcome</th><td>{{if .Passed}}<span class="PASS">PASS</span>{{else}}<span class="FAIL">FAIL</span>{{end}}</td></tr>
+<tr><th>Block verification</th><td><span class="{{.Report.Verification.Status}}">{{.Report.Verification.Status}}</span>, {{.Report.Verification.Checked}} block(s) checked{{with .Report.Verification.Error}}: {{.}}{{end}}</td></tr>
+</table>
+
+<h2>Blockfiles</h2>
+<table>
+<thead><tr><th>Blockfile</th><th>Blocks</th><th>Residence</th><th>Local SHA-256</th><th>Location</th><th>Checksum</th><th>Archived at</th><th>Manifest</th><th>Audit</th></tr></thead>
+<tbody>
+{{range .Report.Blockfiles}}<tr>
+<td>{{.BlockfileNo}}</td>
+<td>{{.FirstBlockNum}}-{{.LastBlockNum}}</td>
+<td>{{.Residence}}</td>
+<td class="digest">{{if .LocalHash}}{{.LocalHash}}{{else}}-{{end}}</td>
+<td>{{if .Location}}{{.Location}}{{else}}-{{end}}</td>
+<td class="digest">{{if .Checksum}}{{.Checksum}}{{else}}-{{end}}</td>
+<td>{{time .ArchivedAt}}</td>
+<td>{{with .Manifest}}<span class="{{.Status}}">{{.Status}}</span>{{with .SignerMSPID}}, signed by {{.}}{{end}}, archived at {{time .ArchivedAt}}<br><span class="digest">SHA-256 {{.BlockfileHash}}{{with .MerkleRoot}}<br>Merkle root {{.}}{{end}}</span>{{with .Error}}<br>{{.}}{{end}}{{else}}-{{end}}</td>
+<td>{{with .Audit}}<span class="{{.Status}}">{{.Status}}</span>{{with .Error}}: {{.}}{{end}}{{else}}-{{end}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+
+<h2>Blocks</h2>
+<table>
+<thead><tr><th>Block</th><th>Blockfile</th><th>Residence</th><th>Header hash</th><th>Previous hash</th><th>Data hash</th></tr></thead>
+<tbody>
+{{range .Report.Blocks}}<tr><td>{{.BlockNum}}</td><td>{{.BlockfileNo}}</td><td>{{.Residence}}</td><td class="digest">{{.HeaderHash}}</td><td class="digest">{{.PreviousHash}}</td><td class="digest">{{.DataHash}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>Signature</h2>
+<table>
+<tr><th>Signer MSP</th><td>{{.Signer.MSPID}}</td></tr>
+<tr><th>Signer certificate</th><td><pre>{{.Signer.Certificate}}</pre></td></tr>
+<tr><th>Signature</th><td class="digest">{{printf "%x" .Signature}}</td></tr>
+</table>
+<p>The signature covers the compact JSON encoding of the report field of the signed report below.</p>
+<script type="application/json" id="signed-report">
+{{.Signed}}
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,147 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/internal/peer/common"
+	"github.com/hyperledger/fabric/internal/pkg/identity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func archiveRequestCmd() *cobra.Command {
+	flags := nodeArchiveRequestCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel the request is signed for")
+	flags.StringVarP(&archiveMethod, "method", "X", http.MethodGet, "Method of the request")
+	flags.StringVarP(&archiveData, "data", "d", "", "Body of the request")
+	flags.StringVarP(&archiveOutput, "output", "o", "", "File the response is written to (default standard output)")
+	flags.StringVar(&archiveCAFile, "cafile", "", "PEM-encoded CA certificates of the TLS server certificate of the operations endpoint")
+	flags.StringVar(&archiveCertFile, "certfile", "", "PEM-encoded TLS client certificate for the operations endpoint")
+	flags.StringVar(&archiveKeyFile, "keyfile", "", "PEM-encoded TLS client key for the operations endpoint")
+	return nodeArchiveRequestCmd
+}
+
+var nodeArchiveRequestCmd = &cobra.Command{
+	Use:   "request <url>",
+	Short: "Sends a signed request to the archiving services of the operations endpoint of a peer.",
+	Long: `Sends a request to the archiving services of the operations endpoint of a peer, e.g. ` +
+		`https://peer0.org1.example.com:9443/archiver/blocks/mychannel, signed for the channel with the local MSP identity, ` +
+		`against which the peer authorizes it. The response is written to --output, and the exports are checked against ` +
+		`their trailers, so that the command fails if an export is truncated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("the URL of the request must be specified")
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		var body io.Reader
+		if archiveData != "" {
+			body = strings.NewReader(archiveData)
+		}
+		req, err := http.NewRequest(strings.ToUpper(archiveMethod), args[0], body)
+		if err != nil {
+			return errors.Wrap(err, "invalid request")
+		}
+		if archiveData != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		client, err := operationsClient(archiveCAFile, archiveCertFile, archiveKeyFile)
+		if err != nil {
+			return err
+		}
+		signer, err := common.GetDefaultSignerFnc()
+		if err != nil {
+			return errors.Errorf("failed obtaining default signer: %v", err)
+		}
+		out := io.Writer(os.Stdout)
+		if archiveOutput != "" {
+			f, err := os.Create(archiveOutput)
+			if err != nil {
+				return errors.Wrapf(err, "error creating %s", archiveOutput)
+			}
+			defer f.Close()
+			out = f
+		}
+		return sendArchiveRequest(client, req, archiveChannelID, signer, out)
+	},
+}
+
+// operationsClient returns the HTTP client of the operations endpoint, with the TLS configuration of the files
+// which are set
+func operationsClient(caFile, certFile, keyFile string) (*http.Client, error) {
+	if caFile == "" && certFile == "" {
+		return &http.Client{}, nil
+	}
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading %s", caFile)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in %s", caFile)
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading the TLS client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, nil
+}
+
+// sendArchiveRequest sends the request signed by signer for the channel, and writes the body of the response to
+// out. A response declaring the trailers of an export is checked against them once it is read.
+func sendArchiveRequest(client *http.Client, req *http.Request, channelID string, signer identity.SignerSerializer, out io.Writer) error {
+	if err := archiver.SignHTTPRequest(req, channelID, signer); err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "error sending the request to %s", req.URL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return errors.Errorf("the request to %s failed with %s: %s", req.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), resp.Body); err != nil {
+		return errors.Wrapf(err, "error reading the response of %s", req.URL)
+	}
+	if _, export := resp.Trailer[http.CanonicalHeaderKey(archiver.ExportSHA256Trailer)]; !export {
+		return nil
+	}
+	expected := resp.Trailer.Get(archiver.ExportSHA256Trailer)
+	if expected == "" {
+		return errors.Errorf("the export of %s is truncated", req.URL)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != expected {
+		return errors.Errorf("the export of %s is corrupted, its SHA-256 is %s instead of %s", req.URL, sum, expected)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s block(s), SHA-256 %s\n", resp.Trailer.Get(archiver.ExportBlocksTrailer), expected)
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/hyperledger/fabric/internal/peer/common"
+	mspmgmt "github.com/hyperledger/fabric/msp/mgmt"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func archiveSnapshotCmd() *cobra.Command {
+	flags := nodeArchiveSnapshotCmd.Flags()
+	flags.StringVarP(&archiveChannelID, "channel", "c", "", "Channel whose archived history is described")
+	flags.StringVarP(&archiveOutput, "output", "o", "", "File the signed snapshot is written to")
+	return nodeArchiveSnapshotCmd
+}
+
+var nodeArchiveSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Takes the signed snapshot of the archived history of a channel.",
+	Long: `Describes in a single manifest the entire archived history of a channel at this time: the archived ` +
+		`blockfiles with their location and checksum on the repository, their block range and the digests of their ` +
+		`summaries, along with the hash of their records in the archive catalog. The snapshot is signed with the ` +
+		`local MSP identity of the peer and is the unit of mirroring, restore and audit, see verify-snapshot. ` +
+		`The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		if archiveOutput == "" {
+			return errors.New("the output file must be specified with --output")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		signer, err := common.GetDefaultSignerFnc()
+		if err != nil {
+			return errors.Errorf("failed obtaining default signer: %v", err)
+		}
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		archiver.InitRepositoryAccess()
+		snapshot, err := fsblkstorage.ArchiveSnapshotOf(ledgerconfig.GetBlockStorePath(), archiveChannelID)
+		if err != nil {
+			return err
+		}
+		signed, err := blockarchive.SignSnapshot(snapshot, signer)
+		if err != nil {
+			return err
+		}
+		signedBytes, err := proto.Marshal(signed)
+		if err != nil {
+			return errors.Wrap(err, "error marshaling signed archive snapshot")
+		}
+		if err := ioutil.WriteFile(archiveOutput, signedBytes, 0644); err != nil {
+			return errors.Wrapf(err, "error writing %s", archiveOutput)
+		}
+		printArchiveSnapshot(os.Stdout, snapshot, signer.GetMSPIdentifier(), nil)
+		return nil
+	},
+}
+
+func archiveVerifySnapshotCmd() *cobra.Command {
+	flags := nodeArchiveVerifySnapshotCmd.Flags()
+	flags.StringVarP(&archiveInput, "input", "i", "", "File written by snapshot")
+	flags.BoolVar(&archiveAudit, "audit", false, "Verifies the archived blockfiles on the repository against their checksum")
+	return nodeArchiveVerifySnapshotCmd
+}
+
+var nodeArchiveVerifySnapshotCmd = &cobra.Command{
+	Use:   "verify-snapshot",
+	Short: "Verifies a signed snapshot of the archived history of a channel.",
+	Long: `Verifies the signature of a snapshot taken with snapshot against the local MSP, and that its archived ` +
+		`blockfiles hold a contiguous range of blocks. When the peer has a ledger of the channel, the records of the ` +
+		`archived blockfiles in its archive catalog are compared with the snapshot. With --audit, the archived ` +
+		`blockfiles are verified on the repository against their checksum. It fails if a check doesn't pass. ` +
+		`The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if archiveInput == "" {
+			return errors.New("the snapshot must be specified with --input")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		signedBytes, err := ioutil.ReadFile(archiveInput)
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s", archiveInput)
+		}
+		signed := &archive.SignedArchiveSnapshot{}
+		if err := proto.Unmarshal(signedBytes, signed); err != nil {
+			return errors.Wrapf(err, "error unmarshaling %s", archiveInput)
+		}
+		snapshot, identity, err := blockarchive.VerifySnapshot(signed, mspmgmt.GetLocalMSP())
+		if err != nil {
+			return err
+		}
+		blockarchive.CatalogDatabase = ledgerconfig.GetArchiveCatalogDatabase()
+		if archiveAudit {
+			archiver.InitRepositoryAccess()
+		}
+		verification, err := fsblkstorage.VerifyArchiveSnapshot(ledgerconfig.GetBlockStorePath(), snapshot, archiveAudit)
+		if err != nil {
+			return err
+		}
+		printArchiveSnapshot(os.Stdout, snapshot, identity.GetMSPIdentifier(), verification)
+		if !verification.Passed() {
+			return errors.Errorf("the archive snapshot of channel %s did not pass all the checks", snapshot.ChannelID)
+		}
+		return nil
+	},
+}
+
+// printArchiveSnapshot prints a snapshot signed by a member of the MSP as a table of its archived blockfiles,
+// along with the verification of the snapshot if not nil
+func printArchiveSnapshot(out io.Writer, snapshot *archive.ArchiveSnapshot, mspID string, verification *fsblkstorage.SnapshotVerification) {
+	timestamp := "-"
+	if t, err := ptypes.Timestamp(snapshot.Timestamp); err == nil {
+		timestamp = t.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	fmt.Fprintf(out, "Channel:      %s\n", snapshot.ChannelID)
+	fmt.Fprintf(out, "Taken at:     %s by %s\n", timestamp, mspID)
+	fmt.Fprintf(out, "Repository:   %s\n", snapshot.Repository)
+	fmt.Fprintf(out, "Blocks:       %d-%d in %d blockfile(s)\n", snapshot.FirstBlockNum, snapshot.LastBlockNum, len(snapshot.Objects))
+	fmt.Fprintf(out, "Catalog hash: %x\n", snapshot.CatalogHash)
+	if verification != nil {
+		fmt.Fprintf(out, "Catalog:      %s\n", formatCustodyAudit(verification.Catalog))
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := "BLOCKFILE\tBLOCKS\tLOCATION\tCHECKSUM\tMERKLE ROOT"
+	if verification != nil && verification.Objects != nil {
+		header += "\tAUDIT"
+	}
+	fmt.Fprintln(w, header)
+	for _, object := range snapshot.Objects {
+		line := fmt.Sprintf("%d\t%d-%d\t%s\t%s\t%x", object.BlockfileNo, object.FirstBlockNum, object.LastBlockNum,
+			object.Location, object.Checksum, object.MerkleRoot)
+		if verification != nil && verification.Objects != nil {
+			line += "\t" + formatCustodyAudit(verification.Objects[object.BlockfileNo])
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+}
+
+func formatCustodyAudit(audit *fsblkstorage.CustodyAudit) string {
+	if audit.Error == "" {
+		return audit.Status
+	}
+	return audit.Status + ": " + audit.Error
+}
@@ -0,0 +1,443 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	mspprotos "github.com/hyperledger/fabric/protos/msp"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintArchivePlan(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printArchivePlan(buf, &fsblkstorage.ArchivePlan{
+		LedgerID:           "mychannel",
+		Each:               30,
+		Keep:               10,
+		NumLocalBlockfiles: 50,
+		LocalSize:          50 * 64 * 1024 * 1024,
+		NumToArchive:       30,
+		SizeToArchive:      30 * 64 * 1024 * 1024,
+		FirstToArchive:     1,
+		LastToArchive:      30,
+		ProjectedLocalSize: 20 * 64 * 1024 * 1024,
+		MinSteadyLocalSize: 11 * 64 * 1024 * 1024,
+		MaxSteadyLocalSize: 41 * 64 * 1024 * 1024,
+	}, 16)
+	assert.Equal(t, `Channel:                      mychannel
+Archiving parameters:         each=30 keep=10
+Local blockfiles:             50 (3.1 GiB)
+Blockfiles to archive:        30 (1.9 GiB), blockfile [1] to [30]
+Projected local disk usage:   1.2 GiB
+Steady local disk usage:      704.0 MiB to 2.6 GiB
+Estimated transfer time:      2m0s at 16 MB/s
+`, buf.String())
+}
+
+func TestArchivePlanCmd(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "archiveplan")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	defer viper.Reset()
+	viper.Set("peer.fileSystemPath", testDir)
+
+	dir := filepath.Join(testDir, "ledgersData", "chains", fsblkstorage.ChainsDir, "mychannel")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "blockfile_000000"), []byte("block"), 0644))
+
+	cmd := archiveCmd()
+	cmd.SetArgs([]string{"plan"})
+	assert.EqualError(t, cmd.Execute(), "the channel must be specified with --channel")
+
+	cmd.SetArgs([]string{"plan", "--channel", "mychannel", "--target-keep", "5"})
+	assert.NoError(t, cmd.Execute())
+
+	cmd.SetArgs([]string{"plan", "--channel", "otherchannel"})
+	err = cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ledger [otherchannel] not found")
+}
+
+func TestPrintArchiverState(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printArchiverState(buf, "peer0.org1:7051", &archive.ArchiverState{Channels: []*archive.ChannelArchiverState{
+		{ChannelId: "ch1", NextBlockfileNo: 3, Blockfiles: []*archive.ArchivedBlockfileInfo{{BlockfileNo: 1}, {BlockfileNo: 2}}},
+		{ChannelId: "ch2", NextBlockfileNo: 1},
+	}})
+	assert.Equal(t, `The peer is now the archiver, taken over from peer0.org1:7051
+Channel ch1: 2 archived blockfile(s), next blockfile to archive [3]
+Channel ch2: 0 archived blockfile(s), next blockfile to archive [1]
+`, buf.String())
+}
+
+func TestArchiveAcquireCmd(t *testing.T) {
+	archiveFrom = ""
+	assert.EqualError(t, nodeArchiveAcquireCmd.RunE(nodeArchiveAcquireCmd, nil), "the archiver peer must be specified with --from")
+	assert.EqualError(t, nodeArchiveAcquireCmd.RunE(nodeArchiveAcquireCmd, []string{"peer1"}), "trailing args detected: [peer1]")
+}
+
+func TestArchiveCatalogCmds(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "archivecatalog")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	defer viper.Reset()
+	viper.Set("peer.fileSystemPath", testDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(testDir, "ledgersData", "chains", fsblkstorage.ChainsDir, "mychannel"), 0755))
+
+	archiveChannelID, archiveInput, archiveOutput = "", "", ""
+	assert.EqualError(t, nodeArchiveExportCatalogCmd.RunE(nodeArchiveExportCatalogCmd, nil), "the channel must be specified with --channel")
+	assert.EqualError(t, nodeArchiveImportCatalogCmd.RunE(nodeArchiveImportCatalogCmd, nil), "the file to import must be specified with --input")
+
+	// The blockfile 1 archived by the previous peer has been discarded
+	archiveInput = filepath.Join(testDir, "catalog.json")
+	require.NoError(t, ioutil.WriteFile(archiveInput, []byte(`{
+  "channelId": "mychannel",
+  "nextBlockfileNo": "2",
+  "blockfiles": [{"channelID": "mychannel", "blockfileNo": "1", "firstBlockNum": "10", "lastBlockNum": "19", "location": "/blkstore/mychannel/blockfile_000001"}]
+}`), 0644))
+	archiveChannelID = "otherchannel"
+	assert.EqualError(t, nodeArchiveImportCatalogCmd.RunE(nodeArchiveImportCatalogCmd, nil),
+		archiveInput+" holds the archive catalog of channel [mychannel], not [otherchannel]")
+	archiveChannelID = ""
+	require.NoError(t, nodeArchiveImportCatalogCmd.RunE(nodeArchiveImportCatalogCmd, nil))
+
+	archiveChannelID, archiveOutput = "mychannel", filepath.Join(testDir, "exported.json")
+	require.NoError(t, nodeArchiveExportCatalogCmd.RunE(nodeArchiveExportCatalogCmd, nil))
+	file, err := os.Open(archiveOutput)
+	require.NoError(t, err)
+	defer file.Close()
+	state := &archive.ChannelArchiverState{}
+	require.NoError(t, jsonpb.Unmarshal(file, state))
+	assert.Equal(t, uint64(2), state.NextBlockfileNo)
+	require.Len(t, state.Blockfiles, 1)
+	assert.Equal(t, "/blkstore/mychannel/blockfile_000001", state.Blockfiles[0].Location)
+	assert.True(t, state.Blockfiles[0].Discarded)
+}
+
+func TestPrintReconcileReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printReconcileReport(buf, &fsblkstorage.ReconcileReport{
+		LedgerID:   "mychannel",
+		Checked:    5,
+		Healed:     []*archive.ArchivedBlockfileInfo{{BlockfileNo: 1, Location: "/blkstore/mychannel/blockfile_000001"}},
+		Repaired:   []*archive.ArchivedBlockfileInfo{{BlockfileNo: 4, Location: "/blkstore/mychannel/blockfile_000004"}},
+		Missing:    []*archive.ArchivedBlockfileInfo{{BlockfileNo: 2, Location: "/blkstore/mychannel/blockfile_000002"}},
+		Mismatched: []*archive.ArchivedBlockfileInfo{{BlockfileNo: 3, Location: "/blkstore/mychannel/blockfile_000003", Checksum: "sha256:00"}},
+		Orphaned:   []string{"/blkstore/mychannel/blockfile_000009"},
+	})
+	assert.Equal(t, `Channel mychannel: 5 archived blockfile(s) checked
+Uploaded again:  blockfile [1] at /blkstore/mychannel/blockfile_000001
+Repaired:        blockfile [4] at /blkstore/mychannel/blockfile_000004
+Missing:         blockfile [2] at /blkstore/mychannel/blockfile_000002
+Digest mismatch: blockfile [3] at /blkstore/mychannel/blockfile_000003, expected sha256:00
+Orphaned:        /blkstore/mychannel/blockfile_000009
+`, buf.String())
+}
+
+func TestArchiveReconcileCmd(t *testing.T) {
+	archiveChannelID = ""
+	assert.EqualError(t, nodeArchiveReconcileCmd.RunE(nodeArchiveReconcileCmd, nil), "the channel must be specified with --channel")
+	assert.EqualError(t, nodeArchiveReconcileCmd.RunE(nodeArchiveReconcileCmd, []string{"mychannel"}), "trailing args detected: [mychannel]")
+}
+
+func TestPrintRekeyReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printRekeyReport(buf, &fsblkstorage.RekeyReport{
+		LedgerID: "mychannel",
+		KeyID:    "key-2026",
+		Checked:  5,
+		UpToDate: 2,
+		Rekeyed:  []*archive.ArchivedBlockfileInfo{{BlockfileNo: 1, Location: "/blkstore/mychannel/blockfile_000001"}},
+		Locked:   []*archive.ArchivedBlockfileInfo{{BlockfileNo: 2, Location: "/blkstore/mychannel/blockfile_000002"}},
+		Missing:  []*archive.ArchivedBlockfileInfo{{BlockfileNo: 3, Location: "/blkstore/mychannel/blockfile_000003"}},
+	})
+	assert.Equal(t, `Channel mychannel: 5 archived blockfile(s) checked, 2 already encrypted with key key-2026
+Encrypted again: blockfile [1] at /blkstore/mychannel/blockfile_000001
+Locked:          blockfile [2] at /blkstore/mychannel/blockfile_000002
+Missing:         blockfile [3] at /blkstore/mychannel/blockfile_000003
+`, buf.String())
+}
+
+func TestArchiveRekeyCmd(t *testing.T) {
+	archiveChannelID, archiveKeyID = "", ""
+	assert.EqualError(t, nodeArchiveRekeyCmd.RunE(nodeArchiveRekeyCmd, nil), "the channel must be specified with --channel")
+	assert.EqualError(t, nodeArchiveRekeyCmd.RunE(nodeArchiveRekeyCmd, []string{"mychannel"}), "trailing args detected: [mychannel]")
+}
+
+func TestPrintArchiveListing(t *testing.T) {
+	archivedAt := time.Date(2026, 10, 2, 8, 30, 0, 0, time.UTC)
+	listing := &fsblkstorage.ArchiveListing{
+		LedgerID: "mychannel",
+		Blockfiles: []*fsblkstorage.ListedBlockfile{
+			{BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9, Location: "/blkstore/mychannel/blockfile_000000", Discarded: true},
+			{BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19, Location: "/blkstore/mychannel/blockfile_000001",
+				ArchivedAt: &archivedAt, RepositoryStatus: fsblkstorage.RepositoryStatusPresent},
+		},
+		NextStartAfter: 1,
+	}
+	buf := &bytes.Buffer{}
+	printArchiveListing(buf, listing)
+	assert.Equal(t, `Channel mychannel: 2 archived blockfile(s) listed
+BLOCKFILE  BLOCKS  ARCHIVED AT           DISCARDED  LOCATION                              REPOSITORY
+0          0-9     -                     true       /blkstore/mychannel/blockfile_000000  -
+1          10-19   2026-10-02T08:30:00Z  false      /blkstore/mychannel/blockfile_000001  present
+More blockfiles are archived, continue with --start-after 1
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeArchiveListing(buf, listing))
+	decoded := &fsblkstorage.ArchiveListing{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), decoded))
+	assert.Equal(t, listing, decoded)
+}
+
+func TestArchiveListFilter(t *testing.T) {
+	defer func() {
+		archiveFromBlock, archiveToBlock, archiveSince, archiveUntil, archiveStartAfter, archiveLimit = 0, -1, "", "", -1, 0
+	}()
+	archiveFromBlock, archiveToBlock, archiveSince, archiveUntil, archiveStartAfter, archiveLimit = 10, 19, "2026-10-01T12:00:00+02:00", "2026-10-02", 3, 5
+	filter, err := archiveListFilter()
+	require.NoError(t, err)
+	assert.Equal(t, &fsblkstorage.ArchiveListFilter{
+		FromBlock:  10,
+		ToBlock:    19,
+		Since:      time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+		Until:      time.Date(2026, 10, 2, 23, 59, 59, 999999999, time.UTC),
+		StartAfter: 3,
+		Limit:      5,
+	}, normalizeFilterTimes(filter))
+
+	archiveToBlock = 5
+	_, err = archiveListFilter()
+	assert.EqualError(t, err, "--to-block [5] is lower than --from-block [10]")
+	archiveToBlock, archiveSince = -1, "yesterday"
+	_, err = archiveListFilter()
+	assert.EqualError(t, err, "invalid --since: [yesterday], expected RFC 3339 or YYYY-MM-DD")
+}
+
+// normalizeFilterTimes converts the times of the filter to UTC so that they compare equal
+func normalizeFilterTimes(filter *fsblkstorage.ArchiveListFilter) *fsblkstorage.ArchiveListFilter {
+	filter.Since, filter.Until = filter.Since.UTC(), filter.Until.UTC()
+	return filter
+}
+
+func TestArchiveListCmd(t *testing.T) {
+	archiveChannelID, archiveFormat = "", "text"
+	assert.EqualError(t, nodeArchiveListCmd.RunE(nodeArchiveListCmd, nil), "the channel must be specified with --channel")
+	assert.EqualError(t, nodeArchiveListCmd.RunE(nodeArchiveListCmd, []string{"mychannel"}), "trailing args detected: [mychannel]")
+	archiveChannelID, archiveFormat = "mychannel", "yaml"
+	assert.EqualError(t, nodeArchiveListCmd.RunE(nodeArchiveListCmd, nil), "unsupported output format [yaml], expected text or json")
+	archiveChannelID, archiveFormat = "", "text"
+}
+
+func TestArchiveCustodyReportCmd(t *testing.T) {
+	defer func() {
+		archiveChannelID, archiveReportType, archiveFromBlock, archiveToBlock = "", "json", 0, -1
+	}()
+	archiveChannelID, archiveReportType, archiveFromBlock, archiveToBlock = "", "json", 0, -1
+	assert.EqualError(t, nodeArchiveCustodyReportCmd.RunE(nodeArchiveCustodyReportCmd, nil), "the channel must be specified with --channel")
+	assert.EqualError(t, nodeArchiveCustodyReportCmd.RunE(nodeArchiveCustodyReportCmd, []string{"mychannel"}), "trailing args detected: [mychannel]")
+	archiveChannelID, archiveReportType = "mychannel", "pdf"
+	assert.EqualError(t, nodeArchiveCustodyReportCmd.RunE(nodeArchiveCustodyReportCmd, nil), "unsupported output format [pdf], expected json or html")
+	archiveReportType, archiveFromBlock = "html", -2
+	assert.EqualError(t, nodeArchiveCustodyReportCmd.RunE(nodeArchiveCustodyReportCmd, nil), "invalid --from-block [-2]")
+	archiveFromBlock, archiveToBlock = 10, 5
+	assert.EqualError(t, nodeArchiveCustodyReportCmd.RunE(nodeArchiveCustodyReportCmd, nil), "--to-block [5] is lower than --from-block [10]")
+}
+
+type fakeCustodySigner struct{}
+
+func (fakeCustodySigner) Sign(message []byte) ([]byte, error) {
+	return append([]byte("signed:"), message...), nil
+}
+
+func (fakeCustodySigner) Serialize() ([]byte, error) {
+	return proto.Marshal(&mspprotos.SerializedIdentity{Mspid: "SampleOrg", IdBytes: []byte("-----BEGIN CERTIFICATE-----")})
+}
+
+func (fakeCustodySigner) GetMSPIdentifier() string {
+	return "SampleOrg"
+}
+
+func TestWriteCustodyReport(t *testing.T) {
+	archivedAt := time.Date(2026, 10, 2, 8, 30, 0, 0, time.UTC)
+	report := &fsblkstorage.CustodyReport{
+		LedgerID:    "mychannel",
+		FromBlock:   9,
+		ToBlock:     10,
+		GeneratedAt: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		Blockfiles: []*fsblkstorage.CustodyBlockfile{
+			{
+				BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9, Residence: fsblkstorage.ResidenceArchive,
+				Location: "/blkstore/mychannel/blockfile_000000", Checksum: "sha256:0a", ArchivedAt: &archivedAt,
+				Manifest: &fsblkstorage.CustodyManifest{Path: "/manifests/000000", BlockfileHash: "0a", SignerMSPID: "SampleOrg", Status: fsblkstorage.CustodyPassed},
+				Audit:    &fsblkstorage.CustodyAudit{Status: fsblkstorage.CustodyFailed, Error: "the archived blockfile does not match its checksum"},
+			},
+			{BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 10, Residence: fsblkstorage.ResidenceLocal, LocalHash: "1b"},
+		},
+		Blocks: []*fsblkstorage.CustodyBlock{
+			{BlockNum: 9, BlockfileNo: 0, Residence: fsblkstorage.ResidenceArchive, HeaderHash: "09", PreviousHash: "08", DataHash: "d9"},
+			{BlockNum: 10, BlockfileNo: 1, Residence: fsblkstorage.ResidenceLocal, HeaderHash: "10", PreviousHash: "09", DataHash: "d10"},
+		},
+		Verification: &fsblkstorage.CustodyVerification{Status: fsblkstorage.CustodyPassed, Checked: 2},
+	}
+	signed, err := signCustodyReport(report, fakeCustodySigner{})
+	require.NoError(t, err)
+	assert.Equal(t, &custodyReportSigner{MSPID: "SampleOrg", Certificate: "-----BEGIN CERTIFICATE-----"}, signed.Signer)
+
+	// The signature covers the compact encoding of the report as written
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeCustodyReport(buf, signed))
+	var written struct {
+		Report    json.RawMessage `json:"report"`
+		Signature []byte          `json:"signature"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &written))
+	compact := &bytes.Buffer{}
+	require.NoError(t, json.Compact(compact, written.Report))
+	assert.Equal(t, append([]byte("signed:"), compact.Bytes()...), written.Signature)
+
+	buf.Reset()
+	require.NoError(t, writeCustodyReportHTML(buf, signed))
+	html := buf.String()
+	assert.Contains(t, html, "mychannel")
+	assert.Contains(t, html, "/blkstore/mychannel/blockfile_000000")
+	assert.Contains(t, html, "2026-10-02T08:30:00Z")
+	assert.Contains(t, html, "the archived blockfile does not match its checksum")
+	assert.Contains(t, html, `<script type="application/json" id="signed-report">`+"\n"+`{"report":{"channel":"mychannel"`)
+}
+
+func TestArchiveSnapshotCmds(t *testing.T) {
+	defer func() { archiveChannelID, archiveOutput, archiveInput = "", "", "" }()
+	archiveChannelID, archiveOutput, archiveInput = "", "", ""
+	assert.EqualError(t, nodeArchiveSnapshotCmd.RunE(nodeArchiveSnapshotCmd, nil), "the channel must be specified with --channel")
+	archiveChannelID = "mychannel"
+	assert.EqualError(t, nodeArchiveSnapshotCmd.RunE(nodeArchiveSnapshotCmd, nil), "the output file must be specified with --output")
+	assert.EqualError(t, nodeArchiveSnapshotCmd.RunE(nodeArchiveSnapshotCmd, []string{"mychannel"}), "trailing args detected: [mychannel]")
+	assert.EqualError(t, nodeArchiveVerifySnapshotCmd.RunE(nodeArchiveVerifySnapshotCmd, nil), "the snapshot must be specified with --input")
+
+	testDir, err := ioutil.TempDir("", "archive-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	archiveInput = filepath.Join(testDir, "mychannel.snapshot")
+	require.NoError(t, ioutil.WriteFile(archiveInput, []byte("not a snapshot"), 0644))
+	err = nodeArchiveVerifySnapshotCmd.RunE(nodeArchiveVerifySnapshotCmd, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error unmarshaling "+archiveInput)
+}
+
+func TestPrintArchiveSnapshot(t *testing.T) {
+	snapshot := &archive.ArchiveSnapshot{
+		ChannelID:     "mychannel",
+		Timestamp:     &timestamp.Timestamp{Seconds: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC).Unix()},
+		Repository:    "archive.example.com:2222",
+		FirstBlockNum: 0,
+		LastBlockNum:  19,
+		CatalogHash:   []byte{0xca, 0x7a},
+		Objects: []*archive.ArchiveSnapshotObject{
+			{BlockfileNo: 0, FirstBlockNum: 0, LastBlockNum: 9, Location: "/blkstore/mychannel/blockfile_000000", Checksum: "sha256:0a", MerkleRoot: []byte{0x01}},
+			{BlockfileNo: 1, FirstBlockNum: 10, LastBlockNum: 19, Location: "/blkstore/mychannel/blockfile_000001", Checksum: "sha256:1b", MerkleRoot: []byte{0x02}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	printArchiveSnapshot(buf, snapshot, "SampleOrg", nil)
+	assert.Equal(t, "Channel:      mychannel\n"+
+		"Taken at:     2026-10-16T09:00:00Z by SampleOrg\n"+
+		"Repository:   archive.example.com:2222\n"+
+		"Blocks:       0-19 in 2 blockfile(s)\n"+
+		"Catalog hash: ca7a\n"+
+		"BLOCKFILE  BLOCKS  LOCATION                              CHECKSUM   MERKLE ROOT\n"+
+		"0          0-9     /blkstore/mychannel/blockfile_000000  sha256:0a  01\n"+
+		"1          10-19   /blkstore/mychannel/blockfile_000001  sha256:1b  02\n", buf.String())
+
+	buf.Reset()
+	printArchiveSnapshot(buf, snapshot, "SampleOrg", &fsblkstorage.SnapshotVerification{
+		Catalog: &fsblkstorage.CustodyAudit{Status: fsblkstorage.CustodyPassed},
+		Objects: map[uint64]*fsblkstorage.CustodyAudit{
+			0: {Status: fsblkstorage.CustodyFailed, Error: "the archived blockfile does not match its checksum"},
+			1: {Status: fsblkstorage.CustodyPassed},
+		},
+	})
+	assert.Contains(t, buf.String(), "Catalog:      PASS\n")
+	assert.Contains(t, buf.String(), "MERKLE ROOT  AUDIT\n")
+	assert.Contains(t, buf.String(), "01           FAIL: the archived blockfile does not match its checksum\n")
+	assert.Contains(t, buf.String(), "02           PASS\n")
+}
+
+func TestArchiveRequestCmd(t *testing.T) {
+	defer func() { archiveChannelID = "" }()
+	archiveChannelID = ""
+	assert.EqualError(t, nodeArchiveRequestCmd.RunE(nodeArchiveRequestCmd, nil), "the URL of the request must be specified")
+	assert.EqualError(t, nodeArchiveRequestCmd.RunE(nodeArchiveRequestCmd, []string{"http://peer0:9443/archiver/blocks/mychannel"}),
+		"the channel must be specified with --channel")
+}
+
+func TestSendArchiveRequest(t *testing.T) {
+	body := []byte("block 0\nblock 1\n")
+	sum := sha256.Sum256(body)
+	var trailerSum string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(blockarchive.RequestAuthorizationHeader) == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/archiver/blocks/mychannel" {
+			w.Write(body)
+			return
+		}
+		w.Header().Set("Trailer", archiver.ExportBlocksTrailer+", "+archiver.ExportSHA256Trailer)
+		w.Write(body)
+		if trailerSum != "" {
+			w.Header().Set(archiver.ExportBlocksTrailer, "2")
+			w.Header().Set(archiver.ExportSHA256Trailer, trailerSum)
+		}
+	}))
+	defer server.Close()
+	send := func(path string) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		buf := &bytes.Buffer{}
+		err = sendArchiveRequest(server.Client(), req, "mychannel", fakeCustodySigner{}, buf)
+		return buf.String(), err
+	}
+
+	// The complete exports are checked against their trailers
+	trailerSum = hex.EncodeToString(sum[:])
+	out, err := send("/archiver/blocks/mychannel")
+	require.NoError(t, err)
+	assert.Equal(t, string(body), out)
+	// The responses without the trailers of an export are written as is
+	out, err = send("/archiver/restore/1")
+	require.NoError(t, err)
+	assert.Equal(t, string(body), out)
+
+	// The truncated and corrupted exports fail
+	trailerSum = ""
+	_, err = send("/archiver/blocks/mychannel")
+	assert.EqualError(t, err, "the export of "+server.URL+"/archiver/blocks/mychannel is truncated")
+	trailerSum = "0a"
+	_, err = send("/archiver/blocks/mychannel")
+	assert.Contains(t, err.Error(), "is corrupted")
+}
@@ -16,7 +16,7 @@ import (
 
 const (
 	nodeFuncName = "node"
-	nodeCmdDes   = "Operate a peer node: start|status."
+	nodeCmdDes   = "Operate a peer node: start|status|archive|prune."
 )
 
 var logger = flogging.MustGetLogger("nodeCmd")
@@ -25,6 +25,8 @@ var logger = flogging.MustGetLogger("nodeCmd")
 func Cmd() *cobra.Command {
 	nodeCmd.AddCommand(startCmd())
 	nodeCmd.AddCommand(statusCmd())
+	nodeCmd.AddCommand(archiveCmd())
+	nodeCmd.AddCommand(pruneCmd())
 
 	return nodeCmd
 }
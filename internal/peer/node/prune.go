@@ -0,0 +1,123 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/core/archiver"
+	"github.com/hyperledger/fabric/core/ledger/ledgerconfig"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneChannelID    string
+	pruneRetainLatest int64
+	pruneYes          bool
+)
+
+func pruneCmd() *cobra.Command {
+	flags := nodePruneCmd.Flags()
+	flags.StringVarP(&pruneChannelID, "channel", "c", "", "Channel whose blockfiles are discarded")
+	flags.Int64Var(&pruneRetainLatest, "retain-latest", -1, "Number of the latest blocks kept on the local file system")
+	flags.BoolVarP(&pruneYes, "yes", "y", false, "Discard the blockfiles without asking for confirmation")
+	return nodePruneCmd
+}
+
+var nodePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Discards the blocks of a channel older than the latest ones.",
+	Long: `Archives the blockfiles of a channel which hold only blocks older than the latest --retain-latest blocks, ` +
+		`if they have not been archived yet, verifies them against their checksum on the repository, and discards ` +
+		`them from the local file system. The first blockfile and the blockfile being written are kept. ` +
+		`The blockfiles to discard are listed for confirmation first. A client peer only discards the blockfiles ` +
+		`already archived by the archiver peer. The peer must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("trailing args detected: %s", args)
+		}
+		if pruneChannelID == "" {
+			return errors.New("the channel must be specified with --channel")
+		}
+		if pruneRetainLatest < 0 {
+			return errors.New("the number of blocks to retain must be specified with --retain-latest")
+		}
+		// Parsing of the command line is done so silence cmd usage
+		cmd.SilenceUsage = true
+
+		archiver.InitPeerRole()
+		return prune(os.Stdin, os.Stdout, ledgerconfig.GetBlockStorePath(), pruneChannelID, uint64(pruneRetainLatest), pruneYes)
+	},
+}
+
+// prune discards the blockfiles of a channel older than the latest retainLatest blocks, once confirmed on in
+func prune(in io.Reader, out io.Writer, blockStorePath, channelID string, retainLatest uint64, yes bool) error {
+	plan, err := fsblkstorage.PlanPrune(blockStorePath, channelID, retainLatest)
+	if err != nil {
+		return err
+	}
+	printPrunePlan(out, plan)
+	if len(plan.Blockfiles) == 0 {
+		return nil
+	}
+	if !yes && !confirm(in, out, "Discard these blockfiles from the local file system?") {
+		fmt.Fprintln(out, "Aborted, no blockfile has been discarded")
+		return nil
+	}
+	report, err := fsblkstorage.Prune(blockStorePath, plan)
+	if report != nil {
+		printPruneReport(out, report)
+	}
+	return err
+}
+
+// confirm asks a yes or no question on out and reads the answer from in, no by default
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// printPrunePlan prints the blockfiles which a prune discards
+func printPrunePlan(w io.Writer, plan *fsblkstorage.PrunePlan) {
+	fmt.Fprintf(w, "Channel %s: height %d, retaining the latest %d block(s)\n", plan.LedgerID, plan.Height, plan.RetainLatest)
+	if len(plan.Blockfiles) == 0 {
+		fmt.Fprintln(w, "No blockfile to discard")
+		return
+	}
+	for _, candidate := range plan.Blockfiles {
+		state := "to archive"
+		if candidate.Archived {
+			state = "archived"
+		}
+		fmt.Fprintf(w, "  blockfile [%d]: blocks [%d-%d], %s, %s\n", candidate.BlockfileNo,
+			candidate.FirstBlockNum, candidate.LastBlockNum, formatSize(candidate.Size), state)
+	}
+	fmt.Fprintf(w, "%d blockfile(s) to discard (%s), the oldest local block becomes [%d]\n",
+		len(plan.Blockfiles), formatSize(plan.Size), plan.OldestLocalBlock)
+}
+
+// printPruneReport prints the outcome of a prune
+func printPruneReport(w io.Writer, report *fsblkstorage.PruneReport) {
+	for _, info := range report.Archived {
+		fmt.Fprintf(w, "Archived:  blockfile [%d] at %s\n", info.BlockfileNo, info.Location)
+	}
+	for _, info := range report.Discarded {
+		fmt.Fprintf(w, "Discarded: blockfile [%d], blocks [%d-%d]\n", info.BlockfileNo, info.FirstBlockNum, info.LastBlockNum)
+	}
+	fmt.Fprintf(w, "Channel %s: %d blockfile(s) archived, %d discarded, %s freed",
+		report.LedgerID, len(report.Archived), len(report.Discarded), formatSize(report.FreedSize))
+	if len(report.Discarded) > 0 {
+		fmt.Fprintf(w, ", the oldest local block is [%d]", report.OldestLocalBlock)
+	}
+	fmt.Fprintln(w)
+}
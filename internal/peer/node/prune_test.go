@@ -0,0 +1,90 @@
+/*
+COPYRIGHT Fujitsu Software Technologies Limited 2018 All Rights Reserved.
+*/
+
+package node
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage/fsblkstorage"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintPrunePlan(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printPrunePlan(buf, &fsblkstorage.PrunePlan{
+		LedgerID:     "mychannel",
+		Height:       1000,
+		RetainLatest: 500,
+		Blockfiles: []*fsblkstorage.PruneCandidate{
+			{BlockfileNo: 1, FirstBlockNum: 100, LastBlockNum: 299, Size: 64 * 1024 * 1024, Archived: true},
+			{BlockfileNo: 2, FirstBlockNum: 300, LastBlockNum: 499, Size: 64 * 1024 * 1024},
+		},
+		Size:             128 * 1024 * 1024,
+		OldestLocalBlock: 500,
+	})
+	assert.Equal(t, `Channel mychannel: height 1000, retaining the latest 500 block(s)
+  blockfile [1]: blocks [100-299], 64.0 MiB, archived
+  blockfile [2]: blocks [300-499], 64.0 MiB, to archive
+2 blockfile(s) to discard (128.0 MiB), the oldest local block becomes [500]
+`, buf.String())
+
+	buf.Reset()
+	printPrunePlan(buf, &fsblkstorage.PrunePlan{LedgerID: "mychannel", Height: 10, RetainLatest: 500})
+	assert.Equal(t, `Channel mychannel: height 10, retaining the latest 500 block(s)
+No blockfile to discard
+`, buf.String())
+}
+
+func TestPrintPruneReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printPruneReport(buf, &fsblkstorage.PruneReport{
+		LedgerID:         "mychannel",
+		Archived:         []*archive.ArchivedBlockfileInfo{{BlockfileNo: 2, Location: "/blkstore/mychannel/blockfile_000002"}},
+		Discarded:        []*archive.ArchivedBlockfileInfo{{BlockfileNo: 1, FirstBlockNum: 100, LastBlockNum: 299}, {BlockfileNo: 2, FirstBlockNum: 300, LastBlockNum: 499}},
+		FreedSize:        128 * 1024 * 1024,
+		OldestLocalBlock: 500,
+	})
+	assert.Equal(t, `Archived:  blockfile [2] at /blkstore/mychannel/blockfile_000002
+Discarded: blockfile [1], blocks [100-299]
+Discarded: blockfile [2], blocks [300-499]
+Channel mychannel: 1 blockfile(s) archived, 2 discarded, 128.0 MiB freed, the oldest local block is [500]
+`, buf.String())
+
+	buf.Reset()
+	printPruneReport(buf, &fsblkstorage.PruneReport{LedgerID: "mychannel"})
+	assert.Equal(t, "Channel mychannel: 0 blockfile(s) archived, 0 discarded, 0 B freed\n", buf.String())
+}
+
+func TestConfirm(t *testing.T) {
+	for answer, expected := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		buf := &bytes.Buffer{}
+		assert.Equal(t, expected, confirm(strings.NewReader(answer), buf, "Proceed?"), answer)
+		assert.Equal(t, "Proceed? [y/N] ", buf.String())
+	}
+}
+
+func TestPrune(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "prune")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	err = prune(strings.NewReader(""), ioutil.Discard, testDir, "mychannel", 10, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ledger [mychannel] not found")
+}
+
+func TestPruneCmd(t *testing.T) {
+	pruneChannelID, pruneRetainLatest = "", -1
+	assert.EqualError(t, nodePruneCmd.RunE(nodePruneCmd, nil), "the channel must be specified with --channel")
+	pruneChannelID = "mychannel"
+	assert.EqualError(t, nodePruneCmd.RunE(nodePruneCmd, nil), "the number of blocks to retain must be specified with --retain-latest")
+	assert.EqualError(t, nodePruneCmd.RunE(nodePruneCmd, []string{"mychannel"}), "trailing args detected: [mychannel]")
+}
@@ -26,6 +26,7 @@ import (
 	floggingmetrics "github.com/hyperledger/fabric/common/flogging/metrics"
 	"github.com/hyperledger/fabric/common/grpclogging"
 	"github.com/hyperledger/fabric/common/grpcmetrics"
+	"github.com/hyperledger/fabric/common/ledger/blockarchive"
 	"github.com/hyperledger/fabric/common/metadata"
 	"github.com/hyperledger/fabric/common/metrics"
 	"github.com/hyperledger/fabric/common/policies"
@@ -61,6 +62,7 @@ import (
 	"github.com/hyperledger/fabric/core/operations"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/scc"
+	"github.com/hyperledger/fabric/core/scc/ascc"
 	"github.com/hyperledger/fabric/core/scc/cscc"
 	"github.com/hyperledger/fabric/core/scc/lscc"
 	"github.com/hyperledger/fabric/core/scc/qscc"
@@ -79,6 +81,8 @@ import (
 	"github.com/hyperledger/fabric/msp/mgmt"
 	cb "github.com/hyperledger/fabric/protos/common"
 	discprotos "github.com/hyperledger/fabric/protos/discovery"
+	"github.com/hyperledger/fabric/protos/ledger/archive"
+	archivev1 "github.com/hyperledger/fabric/protos/ledger/archive/v1"
 	pb "github.com/hyperledger/fabric/protos/peer"
 	"github.com/hyperledger/fabric/protos/token"
 	"github.com/hyperledger/fabric/protos/transientstore"
@@ -89,6 +93,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
@@ -254,15 +259,28 @@ func serve(args []string) error {
 
 	serverConfig.Logger = flogging.MustGetLogger("core.comm").With("server", "PeerServer")
 	serverConfig.MetricsProvider = metricsProvider
+	// The retrievals of the archived blocks are recorded for the security reviews, the denied ones included
+	accessAudit, err := archiver.OpenAccessAuditLog(metricsProvider)
+	if err != nil {
+		logger.Panicf("Invalid ledger.blockArchiver.accessAudit: %s", err)
+	}
+	// The archived blocks of a channel are served to the requesters allowed to receive its blocks
+	archiveAuthorizer := archiver.NewChannelAuthorizer(func(env *cb.Envelope, channelID string) error {
+		return aclProvider.CheckACL(resources.Event_Block, channelID, env)
+	})
 	serverConfig.UnaryInterceptors = append(
 		serverConfig.UnaryInterceptors,
 		grpcmetrics.UnaryServerInterceptor(grpcmetrics.NewUnaryMetrics(metricsProvider)),
 		grpclogging.UnaryServerInterceptor(flogging.MustGetLogger("comm.grpc.server").Zap()),
+		accessAudit.UnaryServerInterceptor(),
+		archiveAuthorizer.UnaryServerInterceptor(),
 	)
 	serverConfig.StreamInterceptors = append(
 		serverConfig.StreamInterceptors,
 		grpcmetrics.StreamServerInterceptor(grpcmetrics.NewStreamMetrics(metricsProvider)),
 		grpclogging.StreamServerInterceptor(flogging.MustGetLogger("comm.grpc.server").Zap()),
+		accessAudit.StreamServerInterceptor(),
+		archiveAuthorizer.StreamServerInterceptor(),
 	)
 
 	peerServer, err := peer.NewPeerServer(listenAddr, serverConfig)
@@ -400,9 +418,11 @@ func serve(args []string) error {
 		qsccInst = scc.Throttle(maxConcurrency, qsccInst)
 	}
 
+	asccInst := scc.SelfDescribingSysCC(ascc.New(aclProvider))
+
 	//Now that chaincode is initialized, register all system chaincodes.
 	sccs := scc.CreatePluginSysCCs(sccp)
-	for _, cc := range append([]scc.SelfDescribingSysCC{lsccInst, csccInst, qsccInst, lifecycleSCC}, sccs...) {
+	for _, cc := range append([]scc.SelfDescribingSysCC{lsccInst, csccInst, qsccInst, asccInst, lifecycleSCC}, sccs...) {
 		sccp.RegisterSysCC(cc)
 	}
 	pb.RegisterChaincodeSupportServer(ccSrv.Server(), ccSupSrv)
@@ -411,7 +431,48 @@ func serve(args []string) error {
 	go ccSrv.Start()
 
 	// initialize archiving parameters
+	blockarchive.MetricsProvider = metricsProvider
 	archiver.InitBlockArchiver()
+	if blockarchive.MinFreeDiskSpace > 0 {
+		if err := opsSystem.RegisterChecker("archiver.disk", archiver.DiskHealthChecker{}); err != nil {
+			logger.Panicf("failed to register archiver disk health check: %s", err)
+		}
+	}
+	if blockarchive.IsArchiver || blockarchive.IsClient {
+		// Serve the blockfiles to the client peers of the organization which retrieve them through this peer,
+		// on the requests they sign which are authorized against the policy of their channel.
+		// The services are registered on the client peers as well, as they may acquire the archiver role.
+		opsSystem.RegisterHandler(blockarchive.ProxyBlockfilesPath, &archiver.BlockfileHandler{
+			GetLedger: peer.GetLedger, Authorizer: archiveAuthorizer, AccessAudit: accessAudit})
+		// Restore the discarded blocks on the request of the administrators operating the peer
+		restoreHandler := archiver.NewRestoreHandler(peer.GetLedger, localPolicy(cauthdsl.SignedByAnyAdmin([]string{mspID})))
+		opsSystem.RegisterHandler(archiver.RestorePath, restoreHandler)
+		opsSystem.RegisterHandler(archiver.RestorePath+"/", restoreHandler)
+		// Stream the blocks of the channels, local or archived, to the data-lake ingestion jobs which read them,
+		// for longer than the write timeout of the operations endpoint
+		opsSystem.RegisterStreamingHandler(archiver.BlockExportPath, &archiver.BlockExportHandler{
+			GetLedger: peer.GetLedger, Authorizer: archiveAuthorizer, AccessAudit: accessAudit})
+		// Stream a range of blocks of a channel as a tar.gz for the operators who read them
+		opsSystem.RegisterStreamingHandler(archiver.RangeExportPath, &archiver.RangeExportHandler{
+			GetLedger: peer.GetLedger, Authorizer: archiveAuthorizer, AccessAudit: accessAudit})
+		// Serve the archived blocks to the members of the organization through gRPC, with the versioned
+		// protocol and the unversioned one of the peers predating it
+		blockProvider := archiver.NewArchivedBlockProvider(peer.GetLedger, localPolicy(cauthdsl.SignedByAnyMember([]string{mspID})))
+		archive.RegisterArchivedBlockProviderServer(peerServer.Server(), blockProvider)
+		archivev1.RegisterArchiverServiceServer(peerServer.Server(), archiver.NewArchiverService(blockProvider))
+		if viper.GetBool("peer.archiving.grpcReflection") {
+			reflection.Register(peerServer.Server())
+		}
+		// Hand the archiver role off between the peers of the organization on the request of an admin
+		archive.RegisterArchiverRoleServer(peerServer.Server(), archiver.NewArchiverRoleService(
+			localPolicy(cauthdsl.SignedByAnyAdmin([]string{mspID})), localPolicy(cauthdsl.SignedByAnyMember([]string{mspID})),
+			secureDialOpts, mgmt.GetLocalSigningIdentityOrPanic(), peer.AdvertiseArchiveInfo))
+		// Record and discard the blockfiles archived by the peer-archiver sidecar, on its report signed by an admin
+		archive.RegisterArchiverSidecarServer(peerServer.Server(), archiver.NewSidecarService(localPolicy(cauthdsl.SignedByAnyAdmin([]string{mspID}))))
+	}
+	if address := viper.GetString("peer.archiving.providerAddress"); blockarchive.IsClient && address != "" {
+		blockarchive.FetchBlockfile = archiver.NewBlockfileFetcher(address, secureDialOpts, mgmt.GetLocalSigningIdentityOrPanic())
+	}
 
 	logger.Debugf("Running peer")
 
@@ -542,8 +603,8 @@ func serve(args []string) error {
 	}
 
 	go handleSignals(addPlatformSignals(map[os.Signal]func(){
-		syscall.SIGINT:  func() { serve <- nil },
-		syscall.SIGTERM: func() { serve <- nil },
+		syscall.SIGINT:  func() { archiver.Shutdown(); accessAudit.Close(); serve <- nil },
+		syscall.SIGTERM: func() { archiver.Shutdown(); accessAudit.Close(); serve <- nil },
 	}))
 
 	logger.Infof("Started peer with ID=[%s], network ID=[%s], address=[%s]", peerEndpoint.Id, networkID, peerEndpoint.Address)
@@ -114,8 +114,10 @@ type Profile struct {
 
 // FileLedger contains configuration for the file-based ledger.
 type FileLedger struct {
-	Location string
-	Prefix   string
+	Location   string
+	Prefix     string
+	ArchiveURL string
+	ArchiveDir string
 }
 
 // RAMLedger contains configuration for the RAM ledger.
@@ -21,19 +21,22 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 // Contains information about the blockchain ledger such as height, current
 // block hash, and previous block hash.
 type BlockchainInfo struct {
-	Height               uint64   `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
-	CurrentBlockHash     []byte   `protobuf:"bytes,2,opt,name=currentBlockHash,proto3" json:"currentBlockHash,omitempty"`
-	PreviousBlockHash    []byte   `protobuf:"bytes,3,opt,name=previousBlockHash,proto3" json:"previousBlockHash,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Height            uint64 `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	CurrentBlockHash  []byte `protobuf:"bytes,2,opt,name=currentBlockHash,proto3" json:"currentBlockHash,omitempty"`
+	PreviousBlockHash []byte `protobuf:"bytes,3,opt,name=previousBlockHash,proto3" json:"previousBlockHash,omitempty"`
+	// The archiving statistics of the ledger, set when archiving is enabled on the peer
+	// or once blocks have been archived
+	ArchiveInfo          *BlockchainArchiveInfo `protobuf:"bytes,4,opt,name=archiveInfo,proto3" json:"archiveInfo,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
 }
 
 func (m *BlockchainInfo) Reset()         { *m = BlockchainInfo{} }
 func (m *BlockchainInfo) String() string { return proto.CompactTextString(m) }
 func (*BlockchainInfo) ProtoMessage()    {}
 func (*BlockchainInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_ledger_2eb5a49d77a3d612, []int{0}
+	return fileDescriptor_ledger_7174f1acde3728cc, []int{0}
 }
 func (m *BlockchainInfo) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_BlockchainInfo.Unmarshal(m, b)
@@ -74,24 +77,108 @@ func (m *BlockchainInfo) GetPreviousBlockHash() []byte {
 	return nil
 }
 
+func (m *BlockchainInfo) GetArchiveInfo() *BlockchainArchiveInfo {
+	if m != nil {
+		return m.ArchiveInfo
+	}
+	return nil
+}
+
+// Contains the archiving statistics of a blockchain ledger whose blocks are
+// archived into a repository.
+type BlockchainArchiveInfo struct {
+	// The number of the oldest block stored on the local file system of the peer
+	OldestLocalBlock uint64 `protobuf:"varint,1,opt,name=oldestLocalBlock,proto3" json:"oldestLocalBlock,omitempty"`
+	// The number of the last block such that it and all the blocks before it
+	// have been archived, 0 while the genesis block has not been archived
+	ArchivedUpTo uint64 `protobuf:"varint,2,opt,name=archivedUpTo,proto3" json:"archivedUpTo,omitempty"`
+	// Whether block archiving is enabled on the peer for the ledger
+	ArchivingEnabled bool `protobuf:"varint,3,opt,name=archivingEnabled,proto3" json:"archivingEnabled,omitempty"`
+	// Whether the peer serves the blocks older than oldestLocalBlock by fetching
+	// them from the archive, with a higher latency than the local blocks
+	FetchEnabled         bool     `protobuf:"varint,4,opt,name=fetchEnabled,proto3" json:"fetchEnabled,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockchainArchiveInfo) Reset()         { *m = BlockchainArchiveInfo{} }
+func (m *BlockchainArchiveInfo) String() string { return proto.CompactTextString(m) }
+func (*BlockchainArchiveInfo) ProtoMessage()    {}
+func (*BlockchainArchiveInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_ledger_7174f1acde3728cc, []int{1}
+}
+func (m *BlockchainArchiveInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockchainArchiveInfo.Unmarshal(m, b)
+}
+func (m *BlockchainArchiveInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockchainArchiveInfo.Marshal(b, m, deterministic)
+}
+func (dst *BlockchainArchiveInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockchainArchiveInfo.Merge(dst, src)
+}
+func (m *BlockchainArchiveInfo) XXX_Size() int {
+	return xxx_messageInfo_BlockchainArchiveInfo.Size(m)
+}
+func (m *BlockchainArchiveInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockchainArchiveInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockchainArchiveInfo proto.InternalMessageInfo
+
+func (m *BlockchainArchiveInfo) GetOldestLocalBlock() uint64 {
+	if m != nil {
+		return m.OldestLocalBlock
+	}
+	return 0
+}
+
+func (m *BlockchainArchiveInfo) GetArchivedUpTo() uint64 {
+	if m != nil {
+		return m.ArchivedUpTo
+	}
+	return 0
+}
+
+func (m *BlockchainArchiveInfo) GetArchivingEnabled() bool {
+	if m != nil {
+		return m.ArchivingEnabled
+	}
+	return false
+}
+
+func (m *BlockchainArchiveInfo) GetFetchEnabled() bool {
+	if m != nil {
+		return m.FetchEnabled
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterType((*BlockchainInfo)(nil), "common.BlockchainInfo")
+	proto.RegisterType((*BlockchainArchiveInfo)(nil), "common.BlockchainArchiveInfo")
 }
 
-func init() { proto.RegisterFile("common/ledger.proto", fileDescriptor_ledger_2eb5a49d77a3d612) }
-
-var fileDescriptor_ledger_2eb5a49d77a3d612 = []byte{
-	// 186 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x4e, 0xce, 0xcf, 0xcd,
-	0xcd, 0xcf, 0xd3, 0xcf, 0x49, 0x4d, 0x49, 0x4f, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17,
-	0x62, 0x83, 0x08, 0x2a, 0x35, 0x31, 0x72, 0xf1, 0x39, 0xe5, 0xe4, 0x27, 0x67, 0x27, 0x67, 0x24,
-	0x66, 0xe6, 0x79, 0xe6, 0xa5, 0xe5, 0x0b, 0x89, 0x71, 0xb1, 0x65, 0xa4, 0x66, 0xa6, 0x67, 0x94,
-	0x48, 0x30, 0x2a, 0x30, 0x6a, 0xb0, 0x04, 0x41, 0x79, 0x42, 0x5a, 0x5c, 0x02, 0xc9, 0xa5, 0x45,
-	0x45, 0xa9, 0x79, 0x25, 0x60, 0x0d, 0x1e, 0x89, 0xc5, 0x19, 0x12, 0x4c, 0x0a, 0x8c, 0x1a, 0x3c,
-	0x41, 0x18, 0xe2, 0x42, 0x3a, 0x5c, 0x82, 0x05, 0x45, 0xa9, 0x65, 0x99, 0xf9, 0xa5, 0xc5, 0x08,
-	0xc5, 0xcc, 0x60, 0xc5, 0x98, 0x12, 0x4e, 0xc1, 0x5c, 0x2a, 0xf9, 0x45, 0xe9, 0x7a, 0x19, 0x95,
-	0x05, 0xa9, 0x45, 0x50, 0x57, 0xa6, 0x25, 0x26, 0x15, 0x65, 0x26, 0x43, 0x1c, 0x5b, 0xac, 0x07,
-	0x71, 0x6c, 0x94, 0x76, 0x7a, 0x66, 0x49, 0x46, 0x69, 0x12, 0x88, 0xab, 0x8f, 0xa4, 0x58, 0x1f,
-	0xa2, 0x58, 0x1f, 0xa2, 0x58, 0x1f, 0xa2, 0x38, 0x89, 0x0d, 0xcc, 0x35, 0x06, 0x04, 0x00, 0x00,
-	0xff, 0xff, 0x9f, 0xcc, 0x05, 0xd1, 0xff, 0x00, 0x00, 0x00,
+func init() { proto.RegisterFile("common/ledger.proto", fileDescriptor_ledger_7174f1acde3728cc) }
+
+var fileDescriptor_ledger_7174f1acde3728cc = []byte{
+	// 284 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0xc1, 0x4a, 0xc4, 0x30,
+	0x14, 0x45, 0x89, 0x96, 0x22, 0x99, 0x41, 0x34, 0xa2, 0x74, 0x23, 0x94, 0xe2, 0xa2, 0xa8, 0xb4,
+	0xa0, 0x1f, 0x20, 0x0e, 0x08, 0x0a, 0xae, 0xaa, 0x6e, 0xdc, 0xa5, 0x69, 0xda, 0x04, 0x3b, 0x79,
+	0x25, 0x4d, 0x07, 0xfc, 0x32, 0x3f, 0xc0, 0x1f, 0x93, 0x26, 0x91, 0xce, 0xd0, 0x59, 0xde, 0x9b,
+	0xf3, 0x1e, 0xf7, 0xe6, 0xe1, 0x33, 0x06, 0xeb, 0x35, 0xa8, 0xbc, 0xe5, 0x55, 0xc3, 0x75, 0xd6,
+	0x69, 0x30, 0x40, 0x42, 0x67, 0x26, 0xbf, 0x08, 0x1f, 0xaf, 0x5a, 0x60, 0x5f, 0x4c, 0x50, 0xa9,
+	0x5e, 0x54, 0x0d, 0xe4, 0x02, 0x87, 0x82, 0xcb, 0x46, 0x98, 0x08, 0xc5, 0x28, 0x0d, 0x0a, 0xaf,
+	0xc8, 0x35, 0x3e, 0x61, 0x83, 0xd6, 0x5c, 0x19, 0x3b, 0xf0, 0x4c, 0x7b, 0x11, 0x1d, 0xc4, 0x28,
+	0x5d, 0x16, 0x33, 0x9f, 0xdc, 0xe2, 0xd3, 0x4e, 0xf3, 0x8d, 0x84, 0xa1, 0x9f, 0xe0, 0x43, 0x0b,
+	0xcf, 0x1f, 0xc8, 0x03, 0x5e, 0x50, 0xcd, 0x84, 0xdc, 0xf0, 0x31, 0x40, 0x14, 0xc4, 0x28, 0x5d,
+	0xdc, 0x5d, 0x66, 0x2e, 0x62, 0x36, 0xc5, 0x7b, 0x9c, 0xa0, 0x62, 0x7b, 0x22, 0xf9, 0x41, 0xf8,
+	0x7c, 0x2f, 0x36, 0x86, 0x86, 0xb6, 0xe2, 0xbd, 0x79, 0x05, 0x46, 0x5b, 0xcb, 0xf8, 0x5a, 0x33,
+	0x9f, 0x24, 0x78, 0xe9, 0x97, 0x56, 0x1f, 0xdd, 0x3b, 0xd8, 0x72, 0x41, 0xb1, 0xe3, 0x8d, 0xfb,
+	0x9c, 0x96, 0xaa, 0x79, 0x52, 0xb4, 0x6c, 0x79, 0x65, 0x7b, 0x1d, 0x15, 0x33, 0x7f, 0xdc, 0x57,
+	0x73, 0xc3, 0xc4, 0x3f, 0x17, 0x58, 0x6e, 0xc7, 0x5b, 0xbd, 0xe1, 0x2b, 0xd0, 0x4d, 0x26, 0xbe,
+	0x3b, 0xae, 0xfd, 0x81, 0x6a, 0x5a, 0x6a, 0xc9, 0xdc, 0x9d, 0x7a, 0xff, 0x09, 0x9f, 0x37, 0x8d,
+	0x34, 0x62, 0x28, 0x47, 0x99, 0x6f, 0xc1, 0xb9, 0x83, 0x73, 0x07, 0xe7, 0x0e, 0x2e, 0x43, 0x2b,
+	0xef, 0xff, 0x06, 0x00, 0x0f, 0xb6, 0xc1, 0x50, 0xfa, 0x01, 0x00, 0x00,
 }
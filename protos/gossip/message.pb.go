@@ -47,7 +47,7 @@ func (x PullMsgType) String() string {
 	return proto.EnumName(PullMsgType_name, int32(x))
 }
 func (PullMsgType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{0}
+	return fileDescriptor_message_41ae86731fc95a23, []int{0}
 }
 
 type GossipMessage_Tag int32
@@ -82,7 +82,7 @@ func (x GossipMessage_Tag) String() string {
 	return proto.EnumName(GossipMessage_Tag_name, int32(x))
 }
 func (GossipMessage_Tag) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{3, 0}
+	return fileDescriptor_message_41ae86731fc95a23, []int{3, 0}
 }
 
 // Envelope contains a marshalled
@@ -102,7 +102,7 @@ func (m *Envelope) Reset()         { *m = Envelope{} }
 func (m *Envelope) String() string { return proto.CompactTextString(m) }
 func (*Envelope) ProtoMessage()    {}
 func (*Envelope) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{0}
+	return fileDescriptor_message_41ae86731fc95a23, []int{0}
 }
 func (m *Envelope) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Envelope.Unmarshal(m, b)
@@ -160,7 +160,7 @@ func (m *SecretEnvelope) Reset()         { *m = SecretEnvelope{} }
 func (m *SecretEnvelope) String() string { return proto.CompactTextString(m) }
 func (*SecretEnvelope) ProtoMessage()    {}
 func (*SecretEnvelope) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{1}
+	return fileDescriptor_message_41ae86731fc95a23, []int{1}
 }
 func (m *SecretEnvelope) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SecretEnvelope.Unmarshal(m, b)
@@ -210,7 +210,7 @@ func (m *Secret) Reset()         { *m = Secret{} }
 func (m *Secret) String() string { return proto.CompactTextString(m) }
 func (*Secret) ProtoMessage()    {}
 func (*Secret) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{2}
+	return fileDescriptor_message_41ae86731fc95a23, []int{2}
 }
 func (m *Secret) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Secret.Unmarshal(m, b)
@@ -350,7 +350,7 @@ func (m *GossipMessage) Reset()         { *m = GossipMessage{} }
 func (m *GossipMessage) String() string { return proto.CompactTextString(m) }
 func (*GossipMessage) ProtoMessage()    {}
 func (*GossipMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{3}
+	return fileDescriptor_message_41ae86731fc95a23, []int{3}
 }
 func (m *GossipMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GossipMessage.Unmarshal(m, b)
@@ -1161,7 +1161,7 @@ func (m *StateInfo) Reset()         { *m = StateInfo{} }
 func (m *StateInfo) String() string { return proto.CompactTextString(m) }
 func (*StateInfo) ProtoMessage()    {}
 func (*StateInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{4}
+	return fileDescriptor_message_41ae86731fc95a23, []int{4}
 }
 func (m *StateInfo) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StateInfo.Unmarshal(m, b)
@@ -1213,6 +1213,7 @@ type Properties struct {
 	LedgerHeight         uint64       `protobuf:"varint,1,opt,name=ledger_height,json=ledgerHeight,proto3" json:"ledger_height,omitempty"`
 	LeftChannel          bool         `protobuf:"varint,2,opt,name=left_channel,json=leftChannel,proto3" json:"left_channel,omitempty"`
 	Chaincodes           []*Chaincode `protobuf:"bytes,3,rep,name=chaincodes,proto3" json:"chaincodes,omitempty"`
+	ArchiveInfo          *ArchiveInfo `protobuf:"bytes,4,opt,name=archive_info,json=archiveInfo,proto3" json:"archive_info,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
 	XXX_unrecognized     []byte       `json:"-"`
 	XXX_sizecache        int32        `json:"-"`
@@ -1222,7 +1223,7 @@ func (m *Properties) Reset()         { *m = Properties{} }
 func (m *Properties) String() string { return proto.CompactTextString(m) }
 func (*Properties) ProtoMessage()    {}
 func (*Properties) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{5}
+	return fileDescriptor_message_41ae86731fc95a23, []int{5}
 }
 func (m *Properties) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Properties.Unmarshal(m, b)
@@ -1263,6 +1264,13 @@ func (m *Properties) GetChaincodes() []*Chaincode {
 	return nil
 }
 
+func (m *Properties) GetArchiveInfo() *ArchiveInfo {
+	if m != nil {
+		return m.ArchiveInfo
+	}
+	return nil
+}
+
 // StateInfoSnapshot is an aggregation of StateInfo messages
 type StateInfoSnapshot struct {
 	Elements             []*Envelope `protobuf:"bytes,1,rep,name=elements,proto3" json:"elements,omitempty"`
@@ -1275,7 +1283,7 @@ func (m *StateInfoSnapshot) Reset()         { *m = StateInfoSnapshot{} }
 func (m *StateInfoSnapshot) String() string { return proto.CompactTextString(m) }
 func (*StateInfoSnapshot) ProtoMessage()    {}
 func (*StateInfoSnapshot) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{6}
+	return fileDescriptor_message_41ae86731fc95a23, []int{6}
 }
 func (m *StateInfoSnapshot) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StateInfoSnapshot.Unmarshal(m, b)
@@ -1318,7 +1326,7 @@ func (m *StateInfoPullRequest) Reset()         { *m = StateInfoPullRequest{} }
 func (m *StateInfoPullRequest) String() string { return proto.CompactTextString(m) }
 func (*StateInfoPullRequest) ProtoMessage()    {}
 func (*StateInfoPullRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{7}
+	return fileDescriptor_message_41ae86731fc95a23, []int{7}
 }
 func (m *StateInfoPullRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StateInfoPullRequest.Unmarshal(m, b)
@@ -1361,7 +1369,7 @@ func (m *ConnEstablish) Reset()         { *m = ConnEstablish{} }
 func (m *ConnEstablish) String() string { return proto.CompactTextString(m) }
 func (*ConnEstablish) ProtoMessage()    {}
 func (*ConnEstablish) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{8}
+	return fileDescriptor_message_41ae86731fc95a23, []int{8}
 }
 func (m *ConnEstablish) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ConnEstablish.Unmarshal(m, b)
@@ -1418,7 +1426,7 @@ func (m *PeerIdentity) Reset()         { *m = PeerIdentity{} }
 func (m *PeerIdentity) String() string { return proto.CompactTextString(m) }
 func (*PeerIdentity) ProtoMessage()    {}
 func (*PeerIdentity) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{9}
+	return fileDescriptor_message_41ae86731fc95a23, []int{9}
 }
 func (m *PeerIdentity) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PeerIdentity.Unmarshal(m, b)
@@ -1474,7 +1482,7 @@ func (m *DataRequest) Reset()         { *m = DataRequest{} }
 func (m *DataRequest) String() string { return proto.CompactTextString(m) }
 func (*DataRequest) ProtoMessage()    {}
 func (*DataRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{10}
+	return fileDescriptor_message_41ae86731fc95a23, []int{10}
 }
 func (m *DataRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DataRequest.Unmarshal(m, b)
@@ -1530,7 +1538,7 @@ func (m *GossipHello) Reset()         { *m = GossipHello{} }
 func (m *GossipHello) String() string { return proto.CompactTextString(m) }
 func (*GossipHello) ProtoMessage()    {}
 func (*GossipHello) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{11}
+	return fileDescriptor_message_41ae86731fc95a23, []int{11}
 }
 func (m *GossipHello) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_GossipHello.Unmarshal(m, b)
@@ -1586,7 +1594,7 @@ func (m *DataUpdate) Reset()         { *m = DataUpdate{} }
 func (m *DataUpdate) String() string { return proto.CompactTextString(m) }
 func (*DataUpdate) ProtoMessage()    {}
 func (*DataUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{12}
+	return fileDescriptor_message_41ae86731fc95a23, []int{12}
 }
 func (m *DataUpdate) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DataUpdate.Unmarshal(m, b)
@@ -1642,7 +1650,7 @@ func (m *DataDigest) Reset()         { *m = DataDigest{} }
 func (m *DataDigest) String() string { return proto.CompactTextString(m) }
 func (*DataDigest) ProtoMessage()    {}
 func (*DataDigest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{13}
+	return fileDescriptor_message_41ae86731fc95a23, []int{13}
 }
 func (m *DataDigest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DataDigest.Unmarshal(m, b)
@@ -1695,7 +1703,7 @@ func (m *DataMessage) Reset()         { *m = DataMessage{} }
 func (m *DataMessage) String() string { return proto.CompactTextString(m) }
 func (*DataMessage) ProtoMessage()    {}
 func (*DataMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{14}
+	return fileDescriptor_message_41ae86731fc95a23, []int{14}
 }
 func (m *DataMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DataMessage.Unmarshal(m, b)
@@ -1736,7 +1744,7 @@ func (m *PrivateDataMessage) Reset()         { *m = PrivateDataMessage{} }
 func (m *PrivateDataMessage) String() string { return proto.CompactTextString(m) }
 func (*PrivateDataMessage) ProtoMessage()    {}
 func (*PrivateDataMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{15}
+	return fileDescriptor_message_41ae86731fc95a23, []int{15}
 }
 func (m *PrivateDataMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PrivateDataMessage.Unmarshal(m, b)
@@ -1777,7 +1785,7 @@ func (m *Payload) Reset()         { *m = Payload{} }
 func (m *Payload) String() string { return proto.CompactTextString(m) }
 func (*Payload) ProtoMessage()    {}
 func (*Payload) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{16}
+	return fileDescriptor_message_41ae86731fc95a23, []int{16}
 }
 func (m *Payload) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Payload.Unmarshal(m, b)
@@ -1837,7 +1845,7 @@ func (m *PrivatePayload) Reset()         { *m = PrivatePayload{} }
 func (m *PrivatePayload) String() string { return proto.CompactTextString(m) }
 func (*PrivatePayload) ProtoMessage()    {}
 func (*PrivatePayload) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{17}
+	return fileDescriptor_message_41ae86731fc95a23, []int{17}
 }
 func (m *PrivatePayload) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PrivatePayload.Unmarshal(m, b)
@@ -1914,7 +1922,7 @@ func (m *AliveMessage) Reset()         { *m = AliveMessage{} }
 func (m *AliveMessage) String() string { return proto.CompactTextString(m) }
 func (*AliveMessage) ProtoMessage()    {}
 func (*AliveMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{18}
+	return fileDescriptor_message_41ae86731fc95a23, []int{18}
 }
 func (m *AliveMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_AliveMessage.Unmarshal(m, b)
@@ -1970,7 +1978,7 @@ func (m *LeadershipMessage) Reset()         { *m = LeadershipMessage{} }
 func (m *LeadershipMessage) String() string { return proto.CompactTextString(m) }
 func (*LeadershipMessage) ProtoMessage()    {}
 func (*LeadershipMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{19}
+	return fileDescriptor_message_41ae86731fc95a23, []int{19}
 }
 func (m *LeadershipMessage) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_LeadershipMessage.Unmarshal(m, b)
@@ -2024,7 +2032,7 @@ func (m *PeerTime) Reset()         { *m = PeerTime{} }
 func (m *PeerTime) String() string { return proto.CompactTextString(m) }
 func (*PeerTime) ProtoMessage()    {}
 func (*PeerTime) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{20}
+	return fileDescriptor_message_41ae86731fc95a23, []int{20}
 }
 func (m *PeerTime) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PeerTime.Unmarshal(m, b)
@@ -2072,7 +2080,7 @@ func (m *MembershipRequest) Reset()         { *m = MembershipRequest{} }
 func (m *MembershipRequest) String() string { return proto.CompactTextString(m) }
 func (*MembershipRequest) ProtoMessage()    {}
 func (*MembershipRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{21}
+	return fileDescriptor_message_41ae86731fc95a23, []int{21}
 }
 func (m *MembershipRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MembershipRequest.Unmarshal(m, b)
@@ -2119,7 +2127,7 @@ func (m *MembershipResponse) Reset()         { *m = MembershipResponse{} }
 func (m *MembershipResponse) String() string { return proto.CompactTextString(m) }
 func (*MembershipResponse) ProtoMessage()    {}
 func (*MembershipResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{22}
+	return fileDescriptor_message_41ae86731fc95a23, []int{22}
 }
 func (m *MembershipResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_MembershipResponse.Unmarshal(m, b)
@@ -2168,7 +2176,7 @@ func (m *Member) Reset()         { *m = Member{} }
 func (m *Member) String() string { return proto.CompactTextString(m) }
 func (*Member) ProtoMessage()    {}
 func (*Member) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{23}
+	return fileDescriptor_message_41ae86731fc95a23, []int{23}
 }
 func (m *Member) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Member.Unmarshal(m, b)
@@ -2220,7 +2228,7 @@ func (m *Empty) Reset()         { *m = Empty{} }
 func (m *Empty) String() string { return proto.CompactTextString(m) }
 func (*Empty) ProtoMessage()    {}
 func (*Empty) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{24}
+	return fileDescriptor_message_41ae86731fc95a23, []int{24}
 }
 func (m *Empty) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Empty.Unmarshal(m, b)
@@ -2254,7 +2262,7 @@ func (m *RemoteStateRequest) Reset()         { *m = RemoteStateRequest{} }
 func (m *RemoteStateRequest) String() string { return proto.CompactTextString(m) }
 func (*RemoteStateRequest) ProtoMessage()    {}
 func (*RemoteStateRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{25}
+	return fileDescriptor_message_41ae86731fc95a23, []int{25}
 }
 func (m *RemoteStateRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RemoteStateRequest.Unmarshal(m, b)
@@ -2301,7 +2309,7 @@ func (m *RemoteStateResponse) Reset()         { *m = RemoteStateResponse{} }
 func (m *RemoteStateResponse) String() string { return proto.CompactTextString(m) }
 func (*RemoteStateResponse) ProtoMessage()    {}
 func (*RemoteStateResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{26}
+	return fileDescriptor_message_41ae86731fc95a23, []int{26}
 }
 func (m *RemoteStateResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RemoteStateResponse.Unmarshal(m, b)
@@ -2341,7 +2349,7 @@ func (m *RemotePvtDataRequest) Reset()         { *m = RemotePvtDataRequest{} }
 func (m *RemotePvtDataRequest) String() string { return proto.CompactTextString(m) }
 func (*RemotePvtDataRequest) ProtoMessage()    {}
 func (*RemotePvtDataRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{27}
+	return fileDescriptor_message_41ae86731fc95a23, []int{27}
 }
 func (m *RemotePvtDataRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RemotePvtDataRequest.Unmarshal(m, b)
@@ -2384,7 +2392,7 @@ func (m *PvtDataDigest) Reset()         { *m = PvtDataDigest{} }
 func (m *PvtDataDigest) String() string { return proto.CompactTextString(m) }
 func (*PvtDataDigest) ProtoMessage()    {}
 func (*PvtDataDigest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{28}
+	return fileDescriptor_message_41ae86731fc95a23, []int{28}
 }
 func (m *PvtDataDigest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PvtDataDigest.Unmarshal(m, b)
@@ -2452,7 +2460,7 @@ func (m *RemotePvtDataResponse) Reset()         { *m = RemotePvtDataResponse{} }
 func (m *RemotePvtDataResponse) String() string { return proto.CompactTextString(m) }
 func (*RemotePvtDataResponse) ProtoMessage()    {}
 func (*RemotePvtDataResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{29}
+	return fileDescriptor_message_41ae86731fc95a23, []int{29}
 }
 func (m *RemotePvtDataResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_RemotePvtDataResponse.Unmarshal(m, b)
@@ -2492,7 +2500,7 @@ func (m *PvtDataElement) Reset()         { *m = PvtDataElement{} }
 func (m *PvtDataElement) String() string { return proto.CompactTextString(m) }
 func (*PvtDataElement) ProtoMessage()    {}
 func (*PvtDataElement) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{30}
+	return fileDescriptor_message_41ae86731fc95a23, []int{30}
 }
 func (m *PvtDataElement) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PvtDataElement.Unmarshal(m, b)
@@ -2542,7 +2550,7 @@ func (m *PvtDataPayload) Reset()         { *m = PvtDataPayload{} }
 func (m *PvtDataPayload) String() string { return proto.CompactTextString(m) }
 func (*PvtDataPayload) ProtoMessage()    {}
 func (*PvtDataPayload) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{31}
+	return fileDescriptor_message_41ae86731fc95a23, []int{31}
 }
 func (m *PvtDataPayload) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PvtDataPayload.Unmarshal(m, b)
@@ -2587,7 +2595,7 @@ func (m *Acknowledgement) Reset()         { *m = Acknowledgement{} }
 func (m *Acknowledgement) String() string { return proto.CompactTextString(m) }
 func (*Acknowledgement) ProtoMessage()    {}
 func (*Acknowledgement) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{32}
+	return fileDescriptor_message_41ae86731fc95a23, []int{32}
 }
 func (m *Acknowledgement) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Acknowledgement.Unmarshal(m, b)
@@ -2629,7 +2637,7 @@ func (m *Chaincode) Reset()         { *m = Chaincode{} }
 func (m *Chaincode) String() string { return proto.CompactTextString(m) }
 func (*Chaincode) ProtoMessage()    {}
 func (*Chaincode) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{33}
+	return fileDescriptor_message_41ae86731fc95a23, []int{33}
 }
 func (m *Chaincode) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Chaincode.Unmarshal(m, b)
@@ -2684,7 +2692,7 @@ func (m *ArchivedBlockfile) Reset()         { *m = ArchivedBlockfile{} }
 func (m *ArchivedBlockfile) String() string { return proto.CompactTextString(m) }
 func (*ArchivedBlockfile) ProtoMessage()    {}
 func (*ArchivedBlockfile) Descriptor() ([]byte, []int) {
-	return fileDescriptor_message_54ef24e3a90750d7, []int{34}
+	return fileDescriptor_message_41ae86731fc95a23, []int{34}
 }
 func (m *ArchivedBlockfile) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ArchivedBlockfile.Unmarshal(m, b)
@@ -2711,6 +2719,147 @@ func (m *ArchivedBlockfile) GetBlockfileNo() uint64 {
 	return 0
 }
 
+// ArchiveInfo is published by a peer in the archiver role
+// in its StateInfo, so that other peers and clients know
+// where to route requests for historical blocks.
+// The peers which discard archived blocks publish it as well,
+// with the oldest block still available on their local file
+// system, so that clients can avoid the peers which would
+// need to fetch older blocks from the archive.
+// fetch_enabled tells if the peer serves the blocks older than
+// oldest_local_block by fetching them from the archive, with a
+// higher latency than the local blocks, rather than failing
+// archived_up_to and catalog_digest are a digest of the archive
+// catalog of the peer for the channel: the last block such that
+// it and all the blocks before it have been archived, and the
+// SHA-256 of the records of the catalog, empty while nothing has
+// been archived. They tell the lag or the divergence of the
+// catalogs of the peers of an organization without comparing them.
+type ArchiveInfo struct {
+	Archiver             bool          `protobuf:"varint,1,opt,name=archiver,proto3" json:"archiver,omitempty"`
+	ArchivedRanges       []*BlockRange `protobuf:"bytes,2,rep,name=archived_ranges,json=archivedRanges,proto3" json:"archived_ranges,omitempty"`
+	OldestLocalBlock     uint64        `protobuf:"varint,3,opt,name=oldest_local_block,json=oldestLocalBlock,proto3" json:"oldest_local_block,omitempty"`
+	FetchEnabled         bool          `protobuf:"varint,4,opt,name=fetch_enabled,json=fetchEnabled,proto3" json:"fetch_enabled,omitempty"`
+	ArchivedUpTo         uint64        `protobuf:"varint,5,opt,name=archived_up_to,json=archivedUpTo,proto3" json:"archived_up_to,omitempty"`
+	CatalogDigest        []byte        `protobuf:"bytes,6,opt,name=catalog_digest,json=catalogDigest,proto3" json:"catalog_digest,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ArchiveInfo) Reset()         { *m = ArchiveInfo{} }
+func (m *ArchiveInfo) String() string { return proto.CompactTextString(m) }
+func (*ArchiveInfo) ProtoMessage()    {}
+func (*ArchiveInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_message_41ae86731fc95a23, []int{35}
+}
+func (m *ArchiveInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ArchiveInfo.Unmarshal(m, b)
+}
+func (m *ArchiveInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ArchiveInfo.Marshal(b, m, deterministic)
+}
+func (dst *ArchiveInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ArchiveInfo.Merge(dst, src)
+}
+func (m *ArchiveInfo) XXX_Size() int {
+	return xxx_messageInfo_ArchiveInfo.Size(m)
+}
+func (m *ArchiveInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_ArchiveInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ArchiveInfo proto.InternalMessageInfo
+
+func (m *ArchiveInfo) GetArchiver() bool {
+	if m != nil {
+		return m.Archiver
+	}
+	return false
+}
+
+func (m *ArchiveInfo) GetArchivedRanges() []*BlockRange {
+	if m != nil {
+		return m.ArchivedRanges
+	}
+	return nil
+}
+
+func (m *ArchiveInfo) GetOldestLocalBlock() uint64 {
+	if m != nil {
+		return m.OldestLocalBlock
+	}
+	return 0
+}
+
+func (m *ArchiveInfo) GetFetchEnabled() bool {
+	if m != nil {
+		return m.FetchEnabled
+	}
+	return false
+}
+
+func (m *ArchiveInfo) GetArchivedUpTo() uint64 {
+	if m != nil {
+		return m.ArchivedUpTo
+	}
+	return 0
+}
+
+func (m *ArchiveInfo) GetCatalogDigest() []byte {
+	if m != nil {
+		return m.CatalogDigest
+	}
+	return nil
+}
+
+// BlockRange is a contiguous range of blocks
+type BlockRange struct {
+	FirstBlock           uint64   `protobuf:"varint,1,opt,name=first_block,json=firstBlock,proto3" json:"first_block,omitempty"`
+	LastBlock            uint64   `protobuf:"varint,2,opt,name=last_block,json=lastBlock,proto3" json:"last_block,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockRange) Reset()         { *m = BlockRange{} }
+func (m *BlockRange) String() string { return proto.CompactTextString(m) }
+func (*BlockRange) ProtoMessage()    {}
+func (*BlockRange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_message_41ae86731fc95a23, []int{36}
+}
+func (m *BlockRange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockRange.Unmarshal(m, b)
+}
+func (m *BlockRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockRange.Marshal(b, m, deterministic)
+}
+func (dst *BlockRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockRange.Merge(dst, src)
+}
+func (m *BlockRange) XXX_Size() int {
+	return xxx_messageInfo_BlockRange.Size(m)
+}
+func (m *BlockRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockRange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockRange proto.InternalMessageInfo
+
+func (m *BlockRange) GetFirstBlock() uint64 {
+	if m != nil {
+		return m.FirstBlock
+	}
+	return 0
+}
+
+func (m *BlockRange) GetLastBlock() uint64 {
+	if m != nil {
+		return m.LastBlock
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Envelope)(nil), "gossip.Envelope")
 	proto.RegisterType((*SecretEnvelope)(nil), "gossip.SecretEnvelope")
@@ -2747,6 +2896,8 @@ func init() {
 	proto.RegisterType((*Acknowledgement)(nil), "gossip.Acknowledgement")
 	proto.RegisterType((*Chaincode)(nil), "gossip.Chaincode")
 	proto.RegisterType((*ArchivedBlockfile)(nil), "gossip.ArchivedBlockfile")
+	proto.RegisterType((*ArchiveInfo)(nil), "gossip.ArchiveInfo")
+	proto.RegisterType((*BlockRange)(nil), "gossip.BlockRange")
 	proto.RegisterEnum("gossip.PullMsgType", PullMsgType_name, PullMsgType_value)
 	proto.RegisterEnum("gossip.GossipMessage_Tag", GossipMessage_Tag_name, GossipMessage_Tag_value)
 }
@@ -2893,129 +3044,139 @@ var _Gossip_serviceDesc = grpc.ServiceDesc{
 	Metadata: "gossip/message.proto",
 }
 
-func init() { proto.RegisterFile("gossip/message.proto", fileDescriptor_message_54ef24e3a90750d7) }
-
-var fileDescriptor_message_54ef24e3a90750d7 = []byte{
-	// 1929 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x58, 0x5b, 0x53, 0x23, 0xc7,
-	0xf5, 0x97, 0x40, 0x12, 0xd2, 0xd1, 0x05, 0xd1, 0xb0, 0xbb, 0x63, 0xec, 0xbf, 0x8d, 0xe7, 0x9f,
-	0xb5, 0x37, 0x61, 0x0d, 0x1b, 0x9c, 0x8b, 0xab, 0x9c, 0x64, 0x0b, 0x04, 0x46, 0xc4, 0x8b, 0x96,
-	0x0c, 0x6c, 0x25, 0xe4, 0x65, 0xaa, 0x99, 0x69, 0x46, 0x1d, 0x66, 0x7a, 0x86, 0xe9, 0x06, 0xc3,
-	0x63, 0x2a, 0x0f, 0xa9, 0xca, 0x4b, 0x3e, 0x43, 0x9e, 0xf2, 0x81, 0xf2, 0x85, 0x52, 0x7d, 0x99,
-	0x9b, 0x04, 0x5b, 0xb5, 0xae, 0xca, 0xdb, 0x9c, 0x7b, 0xf7, 0xe9, 0xd3, 0xbf, 0x73, 0x7a, 0x60,
-	0x2d, 0x88, 0x39, 0xa7, 0xc9, 0x76, 0x44, 0x38, 0xc7, 0x01, 0xd9, 0x4a, 0xd2, 0x58, 0xc4, 0xa8,
-	0xa5, 0xb9, 0xeb, 0xcf, 0xbc, 0x38, 0x8a, 0x62, 0xb6, 0xed, 0xc5, 0x61, 0x48, 0x3c, 0x41, 0x63,
-	0xa6, 0x15, 0xec, 0xbf, 0xd5, 0xa1, 0x7d, 0xc0, 0x6e, 0x49, 0x18, 0x27, 0x04, 0x59, 0xb0, 0x94,
-	0xe0, 0xfb, 0x30, 0xc6, 0xbe, 0x55, 0xdf, 0xa8, 0xbf, 0xe8, 0x39, 0x19, 0x89, 0x3e, 0x81, 0x0e,
-	0xa7, 0x01, 0xc3, 0xe2, 0x26, 0x25, 0xd6, 0x82, 0x92, 0x15, 0x0c, 0xf4, 0x1a, 0x96, 0x39, 0xf1,
-	0x52, 0x22, 0x5c, 0x62, 0x5c, 0x59, 0x8b, 0x1b, 0xf5, 0x17, 0xdd, 0x9d, 0xa7, 0x5b, 0x3a, 0xfe,
-	0xd6, 0xa9, 0x12, 0x67, 0x81, 0x9c, 0x01, 0xaf, 0xd0, 0xf6, 0x18, 0x06, 0x55, 0x8d, 0x1f, 0xbb,
-	0x14, 0x7b, 0x17, 0x5a, 0xda, 0x13, 0x7a, 0x09, 0x43, 0xca, 0x04, 0x49, 0x19, 0x0e, 0x0f, 0x98,
-	0x9f, 0xc4, 0x94, 0x09, 0xe5, 0xaa, 0x33, 0xae, 0x39, 0x73, 0x92, 0xbd, 0x0e, 0x2c, 0x79, 0x31,
-	0x13, 0x84, 0x09, 0xfb, 0x3f, 0x5d, 0xe8, 0x1f, 0xaa, 0x65, 0x1f, 0xeb, 0x5c, 0xa2, 0x35, 0x68,
-	0xb2, 0x98, 0x79, 0x44, 0xd9, 0x37, 0x1c, 0x4d, 0xc8, 0x25, 0x7a, 0x53, 0xcc, 0x18, 0x09, 0xcd,
-	0x32, 0x32, 0x12, 0x6d, 0xc2, 0xa2, 0xc0, 0x81, 0xca, 0xc1, 0x60, 0xe7, 0xa3, 0x2c, 0x07, 0x15,
-	0x9f, 0x5b, 0x67, 0x38, 0x70, 0xa4, 0x16, 0xfa, 0x1a, 0x3a, 0x38, 0xa4, 0xb7, 0xc4, 0x8d, 0x78,
-	0x60, 0x35, 0x55, 0xda, 0xd6, 0x32, 0x93, 0x5d, 0x29, 0x30, 0x16, 0xe3, 0x9a, 0xd3, 0x56, 0x8a,
-	0xc7, 0x3c, 0x40, 0xbf, 0x80, 0xa5, 0x88, 0x44, 0x6e, 0x4a, 0xae, 0xad, 0x96, 0x32, 0xc9, 0xa3,
-	0x1c, 0x93, 0xe8, 0x82, 0xa4, 0x7c, 0x4a, 0x13, 0x87, 0x5c, 0xdf, 0x10, 0x2e, 0xc6, 0x35, 0xa7,
-	0x15, 0x91, 0xc8, 0x21, 0xd7, 0xe8, 0x97, 0x99, 0x15, 0xb7, 0x96, 0x94, 0xd5, 0xfa, 0x43, 0x56,
-	0x3c, 0x89, 0x19, 0x27, 0xb9, 0x19, 0x47, 0xaf, 0xa0, 0xed, 0x63, 0x81, 0xd5, 0x02, 0xdb, 0xca,
-	0x6e, 0x35, 0xb3, 0xdb, 0xc7, 0x02, 0x17, 0xeb, 0x5b, 0x92, 0x6a, 0x72, 0x79, 0x9b, 0xd0, 0x9c,
-	0x92, 0x30, 0x8c, 0xad, 0x4e, 0x55, 0x5d, 0xa7, 0x60, 0x2c, 0x45, 0xe3, 0x9a, 0xa3, 0x75, 0xd0,
-	0xb6, 0x71, 0xef, 0xd3, 0xc0, 0x02, 0xa5, 0x8f, 0xca, 0xee, 0xf7, 0x69, 0xa0, 0x77, 0xa1, 0xbc,
-	0xef, 0xd3, 0x20, 0x5f, 0x8f, 0xdc, 0x7d, 0x77, 0x7e, 0x3d, 0xc5, 0xbe, 0x95, 0x85, 0xde, 0x78,
-	0x57, 0x59, 0xdc, 0x24, 0x3e, 0x16, 0xc4, 0xea, 0xcd, 0x47, 0x79, 0xa7, 0x24, 0xe3, 0x9a, 0x03,
-	0x7e, 0x4e, 0xa1, 0xe7, 0xd0, 0x24, 0x51, 0x22, 0xee, 0xad, 0xbe, 0x32, 0xe8, 0x67, 0x06, 0x07,
-	0x92, 0x29, 0x37, 0xa0, 0xa4, 0x68, 0x13, 0x1a, 0x5e, 0xcc, 0x98, 0x35, 0x50, 0x5a, 0x4f, 0x32,
-	0xad, 0x51, 0xcc, 0xd8, 0x01, 0x17, 0xf8, 0x22, 0xa4, 0x7c, 0x3a, 0xae, 0x39, 0x4a, 0x09, 0xed,
-	0x00, 0x70, 0x81, 0x05, 0x71, 0x29, 0xbb, 0x8c, 0xad, 0x65, 0x65, 0xb2, 0x92, 0x5f, 0x13, 0x29,
-	0x39, 0x62, 0x97, 0x32, 0x3b, 0x1d, 0x9e, 0x11, 0x68, 0x0f, 0x06, 0xda, 0x86, 0x33, 0x9c, 0xf0,
-	0x69, 0x2c, 0xac, 0x61, 0xf5, 0xd0, 0x73, 0xbb, 0x53, 0xa3, 0x30, 0xae, 0x39, 0x7d, 0x65, 0x92,
-	0x31, 0xd0, 0x31, 0xac, 0x16, 0x71, 0xdd, 0xe4, 0x26, 0x0c, 0x55, 0xfe, 0x56, 0x94, 0xa3, 0x4f,
-	0xe6, 0x1c, 0x9d, 0xdc, 0x84, 0x61, 0x91, 0xc8, 0x21, 0x9f, 0xe1, 0xa3, 0x5d, 0xd0, 0xfe, 0xa5,
-	0x13, 0xa9, 0x64, 0xa1, 0x6a, 0x41, 0x39, 0x24, 0x8a, 0x05, 0x51, 0xee, 0x0a, 0x37, 0x3d, 0x5e,
-	0xa2, 0xd1, 0x7e, 0xb6, 0xab, 0xd4, 0x94, 0x9c, 0xb5, 0xaa, 0x7c, 0x7c, 0xfc, 0xa0, 0x8f, 0xbc,
-	0x2a, 0xfb, 0xbc, 0xcc, 0x90, 0xb9, 0x09, 0x09, 0xf6, 0x75, 0xf1, 0xaa, 0x12, 0x5d, 0xab, 0xe6,
-	0xe6, 0x4d, 0x2e, 0x2d, 0x0a, 0xb5, 0x5f, 0x98, 0xc8, 0x72, 0xfd, 0x16, 0xfa, 0x09, 0x21, 0xa9,
-	0x4b, 0x7d, 0xc2, 0x04, 0x15, 0xf7, 0xd6, 0x93, 0xea, 0x35, 0x3c, 0x21, 0x24, 0x3d, 0x32, 0x32,
-	0xb9, 0x8d, 0xa4, 0x44, 0xcb, 0xcb, 0x8e, 0xbd, 0x2b, 0xeb, 0xa9, 0x32, 0x79, 0x96, 0xdf, 0x5c,
-	0xef, 0x8a, 0xc5, 0x3f, 0x84, 0xc4, 0x0f, 0x48, 0x44, 0x98, 0xdc, 0xbc, 0xd4, 0x42, 0xbf, 0x03,
-	0x48, 0x52, 0x7a, 0xab, 0xb3, 0x60, 0x3d, 0xab, 0x26, 0x5f, 0xef, 0xf7, 0xe4, 0x56, 0x54, 0xab,
-	0xb8, 0x64, 0x81, 0x5e, 0x97, 0xec, 0xb9, 0x65, 0x29, 0xfb, 0xff, 0x7b, 0xc4, 0x3e, 0xcf, 0x58,
-	0xc9, 0x04, 0xbd, 0x86, 0x9e, 0xa1, 0x5c, 0x59, 0xe8, 0xd6, 0x47, 0xd5, 0x63, 0x3b, 0xd1, 0xb2,
-	0xea, 0xb5, 0xee, 0x26, 0x05, 0x17, 0xfd, 0x1e, 0x10, 0x4e, 0xbd, 0x29, 0xbd, 0x25, 0xbe, 0x7b,
-	0x11, 0xc6, 0xde, 0xd5, 0x25, 0x0d, 0x89, 0xb5, 0x5e, 0xcd, 0xf9, 0xae, 0xd1, 0xd8, 0xcb, 0x14,
-	0xc6, 0x35, 0x67, 0x05, 0xcf, 0x32, 0x6d, 0x17, 0x16, 0xcf, 0x70, 0x80, 0xfa, 0xd0, 0x79, 0x37,
-	0xd9, 0x3f, 0xf8, 0xee, 0x68, 0x72, 0xb0, 0x3f, 0xac, 0xa1, 0x0e, 0x34, 0x0f, 0x8e, 0x4f, 0xce,
-	0xce, 0x87, 0x75, 0xd4, 0x83, 0xf6, 0x5b, 0xe7, 0xd0, 0x7d, 0x3b, 0x79, 0x73, 0x3e, 0x5c, 0x90,
-	0x7a, 0xa3, 0xf1, 0xee, 0x44, 0x93, 0x8b, 0x68, 0x08, 0x3d, 0x45, 0xee, 0x4e, 0xf6, 0xdd, 0xb7,
-	0xce, 0xe1, 0xb0, 0x81, 0x96, 0xa1, 0xab, 0x15, 0x1c, 0xc5, 0x68, 0x96, 0x51, 0xfd, 0xdf, 0x75,
-	0xe8, 0xe4, 0xd5, 0x8d, 0xb6, 0xa0, 0x23, 0x68, 0x44, 0xb8, 0xc0, 0x51, 0xa2, 0xd0, 0xbb, 0xbb,
-	0x33, 0x2c, 0x9f, 0xf6, 0x19, 0x8d, 0x88, 0x53, 0xa8, 0xa0, 0x27, 0xd0, 0x4a, 0xae, 0xa8, 0x4b,
-	0x7d, 0x05, 0xea, 0x3d, 0xa7, 0x99, 0x5c, 0xd1, 0x23, 0x1f, 0x7d, 0x06, 0x5d, 0x83, 0xf9, 0xee,
-	0xf1, 0xee, 0xc8, 0x6a, 0x28, 0x19, 0x18, 0xd6, 0xf1, 0xee, 0x48, 0xde, 0xf6, 0x24, 0x8d, 0x13,
-	0x92, 0x0a, 0x4a, 0xb8, 0x41, 0x77, 0x54, 0x24, 0x3b, 0x93, 0x38, 0x25, 0x2d, 0xfb, 0xef, 0x75,
-	0x80, 0x42, 0x84, 0xfe, 0x1f, 0xfa, 0xaa, 0x8c, 0x52, 0x77, 0x4a, 0x68, 0x30, 0x15, 0xa6, 0x09,
-	0xf5, 0x34, 0x73, 0xac, 0x78, 0xe8, 0x73, 0xe8, 0x85, 0xe4, 0x52, 0xb8, 0xe5, 0x86, 0xd4, 0x76,
-	0xba, 0x92, 0x37, 0x32, 0x4d, 0xe9, 0xe7, 0x20, 0x17, 0x46, 0x99, 0x17, 0xfb, 0x84, 0x5b, 0x8b,
-	0x1b, 0x8b, 0x65, 0xe0, 0x19, 0x65, 0x12, 0xa7, 0xa4, 0x64, 0xef, 0xc2, 0xca, 0x1c, 0xb2, 0xa0,
-	0x97, 0xd0, 0x26, 0xa1, 0x2a, 0x6a, 0x6e, 0xd5, 0x95, 0x97, 0x3c, 0x73, 0x79, 0x7f, 0xcf, 0x35,
-	0xec, 0x5f, 0xc3, 0xda, 0x43, 0x98, 0x32, 0x9b, 0xb9, 0xfa, 0x6c, 0xe6, 0xec, 0x4b, 0xe8, 0x57,
-	0x00, 0xb4, 0x74, 0x04, 0xf5, 0xf2, 0x11, 0xac, 0x43, 0x3b, 0xbf, 0xb6, 0xba, 0x0d, 0xe7, 0x34,
-	0xb2, 0xa1, 0x2f, 0x42, 0xee, 0x7a, 0x24, 0x15, 0xee, 0x14, 0xf3, 0xa9, 0x39, 0xbc, 0xae, 0x08,
-	0xf9, 0x88, 0xa4, 0x62, 0x8c, 0xf9, 0xd4, 0x7e, 0x07, 0xbd, 0xf2, 0xf5, 0x7e, 0x2c, 0x0c, 0x82,
-	0x86, 0x74, 0x63, 0x42, 0xa8, 0x6f, 0x19, 0x3a, 0x22, 0x02, 0xab, 0x7b, 0xa4, 0x3d, 0xe7, 0xb4,
-	0x1d, 0x41, 0xb7, 0x74, 0x8b, 0x1f, 0x9f, 0x20, 0x7c, 0xd5, 0xdd, 0xb8, 0xb5, 0xb0, 0xb1, 0x28,
-	0x27, 0x08, 0x43, 0xa2, 0x2d, 0x68, 0x47, 0x3c, 0x70, 0xc5, 0xbd, 0x19, 0xa5, 0x06, 0x45, 0x8b,
-	0x93, 0x59, 0x3c, 0xe6, 0xc1, 0xd9, 0x7d, 0x42, 0x9c, 0xa5, 0x48, 0x7f, 0xd8, 0x31, 0x74, 0x4b,
-	0xbd, 0xf5, 0x91, 0x70, 0xe5, 0xf5, 0x2e, 0x54, 0xd7, 0xfb, 0xc1, 0x01, 0xef, 0x00, 0x8a, 0xb6,
-	0xf9, 0x48, 0xbc, 0x9f, 0x40, 0xc3, 0xc4, 0x7a, 0xb8, 0x4a, 0x1a, 0x3f, 0x2a, 0x72, 0xa8, 0x23,
-	0xeb, 0xb1, 0xe0, 0x7f, 0x9e, 0xd8, 0x6f, 0xf4, 0x39, 0x66, 0x93, 0xe0, 0x4f, 0xab, 0x63, 0x69,
-	0x77, 0x67, 0x39, 0xb7, 0xd6, 0xec, 0x7c, 0x4e, 0xb5, 0xbf, 0x03, 0x34, 0x8f, 0xa6, 0xe8, 0xd5,
-	0xac, 0x83, 0xa7, 0x33, 0xd0, 0x3b, 0xe7, 0xe7, 0x1c, 0x96, 0x0c, 0x0f, 0x3d, 0x83, 0x25, 0x4e,
-	0xae, 0x5d, 0x76, 0x13, 0x99, 0xed, 0xb6, 0x38, 0xb9, 0x9e, 0xdc, 0x44, 0xb2, 0x3a, 0x4b, 0xa7,
-	0xaa, 0xf3, 0xfa, 0xf9, 0x0c, 0xd2, 0x2f, 0xaa, 0x44, 0x94, 0xb1, 0xdc, 0xfe, 0xe7, 0x02, 0x0c,
-	0xaa, 0x61, 0xd1, 0x97, 0xb0, 0x5c, 0xbc, 0x11, 0x5c, 0x86, 0x23, 0x9d, 0xd9, 0x8e, 0x33, 0x28,
-	0xd8, 0x13, 0x1c, 0x11, 0x39, 0x86, 0x4b, 0x29, 0x4f, 0xb0, 0xa7, 0xc7, 0xf0, 0x8e, 0x53, 0x30,
-	0xd0, 0x2a, 0x34, 0xc5, 0x5d, 0x06, 0x97, 0x1d, 0xa7, 0x21, 0xee, 0x8e, 0x7c, 0x89, 0x64, 0xd9,
-	0x8a, 0xd2, 0x1f, 0x38, 0x11, 0x06, 0x2f, 0xb3, 0x65, 0x3a, 0x92, 0x87, 0x5e, 0x02, 0xca, 0x94,
-	0x38, 0x8d, 0x32, 0xcc, 0x6b, 0xaa, 0xed, 0x0e, 0x8d, 0xe4, 0x94, 0x46, 0x06, 0xf7, 0x26, 0x80,
-	0x4a, 0xcb, 0xf5, 0x62, 0x76, 0x49, 0x03, 0x6e, 0x46, 0xe2, 0xcf, 0xb6, 0xf4, 0xa3, 0x67, 0x6b,
-	0x94, 0x6b, 0x8c, 0x94, 0xc2, 0x09, 0xf6, 0xae, 0x70, 0x40, 0x9c, 0x15, 0x6f, 0x46, 0xc0, 0xed,
-	0x7f, 0xd4, 0xa1, 0x57, 0x1e, 0xba, 0xd1, 0x16, 0x40, 0x94, 0xcf, 0xc6, 0xe6, 0xc8, 0x06, 0xd5,
-	0xa9, 0xd9, 0x29, 0x69, 0x7c, 0x70, 0x63, 0x29, 0xc3, 0x57, 0xa3, 0x0a, 0x5f, 0xf6, 0x5f, 0xeb,
-	0xb0, 0x32, 0x37, 0xbd, 0x3c, 0x06, 0x50, 0x1f, 0x1a, 0xf8, 0x39, 0x0c, 0x28, 0x77, 0x7d, 0xe2,
-	0x85, 0x38, 0xc5, 0x32, 0x05, 0xea, 0xa8, 0xda, 0x4e, 0x9f, 0xf2, 0xfd, 0x82, 0x69, 0xff, 0x06,
-	0xda, 0x99, 0xb5, 0x2c, 0x3f, 0xca, 0xbc, 0x72, 0xf9, 0x51, 0xe6, 0xc9, 0xf2, 0x2b, 0xd5, 0xe5,
-	0x42, 0xb9, 0x2e, 0xed, 0x4b, 0x58, 0x99, 0x7b, 0x8f, 0xa0, 0x6f, 0x61, 0xc8, 0x49, 0x78, 0xa9,
-	0x06, 0xd1, 0x34, 0xd2, 0xb1, 0xeb, 0xd5, 0x05, 0xe7, 0x10, 0xb1, 0x2c, 0x35, 0x8f, 0x0a, 0x45,
-	0x79, 0xdf, 0xe5, 0x60, 0xc5, 0xcc, 0xbd, 0xd6, 0x84, 0x7d, 0x01, 0x68, 0xfe, 0x05, 0x83, 0xbe,
-	0x80, 0xa6, 0x7a, 0x30, 0x3d, 0xda, 0xa6, 0xb4, 0x58, 0xe1, 0x14, 0xc1, 0xfe, 0x7b, 0x70, 0x8a,
-	0x60, 0xdf, 0xfe, 0x23, 0xb4, 0x74, 0x0c, 0x79, 0x66, 0xa4, 0xf2, 0xa2, 0x74, 0x72, 0xfa, 0xbd,
-	0x18, 0xfb, 0xf0, 0x10, 0x61, 0x2f, 0x41, 0x53, 0x3d, 0x28, 0xec, 0x3f, 0x01, 0x9a, 0x1f, 0x9b,
-	0x65, 0x13, 0xe3, 0x02, 0xa7, 0xc2, 0xad, 0x5e, 0xfd, 0xae, 0x62, 0x9e, 0xea, 0xfb, 0xff, 0x29,
-	0x74, 0x09, 0xf3, 0xdd, 0xea, 0x21, 0x74, 0x08, 0xf3, 0xb5, 0xdc, 0xde, 0x83, 0xd5, 0x07, 0x86,
-	0x69, 0xb4, 0x09, 0x6d, 0x83, 0x32, 0x59, 0x2b, 0x9f, 0x83, 0xb3, 0x5c, 0xc1, 0x3e, 0x84, 0xb5,
-	0x87, 0x06, 0x54, 0xb4, 0x5d, 0x60, 0xad, 0xf6, 0x91, 0x3f, 0x80, 0x8c, 0xa2, 0x46, 0xea, 0x1c,
-	0x82, 0xed, 0x7f, 0xd5, 0xa1, 0x5f, 0x11, 0x15, 0x68, 0x51, 0x2f, 0xa1, 0xc5, 0xfb, 0x01, 0xe6,
-	0x53, 0x80, 0xe2, 0xf6, 0x1a, 0x94, 0x29, 0x71, 0xd0, 0xc7, 0xd0, 0x51, 0xd3, 0xa9, 0xcc, 0x89,
-	0xba, 0x58, 0x0d, 0xa7, 0xad, 0x18, 0xa7, 0xe4, 0x1a, 0x6d, 0x40, 0x4f, 0xa6, 0x8a, 0x32, 0x3d,
-	0xc1, 0x1a, 0x74, 0x01, 0x4e, 0xae, 0x8f, 0x98, 0x9a, 0x4e, 0xed, 0xef, 0xe1, 0xc9, 0x83, 0xd3,
-	0x34, 0xda, 0x99, 0x9b, 0x7e, 0x9e, 0xce, 0x6c, 0xf7, 0x40, 0x8b, 0x4b, 0x33, 0xd0, 0x39, 0x0c,
-	0xaa, 0x32, 0xf4, 0x15, 0xb4, 0x74, 0x36, 0x4c, 0xe1, 0x3f, 0x92, 0x32, 0xa3, 0x54, 0xfe, 0x19,
-	0x62, 0xda, 0x59, 0xd6, 0x1c, 0xfe, 0x90, 0xbb, 0xce, 0x00, 0xfc, 0x39, 0x2c, 0x8b, 0x3b, 0xb7,
-	0xb2, 0x3d, 0x33, 0x30, 0x8a, 0xbb, 0xd3, 0x7c, 0x83, 0x55, 0x97, 0xe5, 0xff, 0x2b, 0xf6, 0x97,
-	0xb0, 0x3c, 0xf3, 0x78, 0x91, 0x97, 0x8e, 0xa4, 0x69, 0x9c, 0x9a, 0xf3, 0xd1, 0x84, 0xfd, 0x0e,
-	0x3a, 0xf9, 0xd8, 0x28, 0x3b, 0x50, 0xa9, 0x59, 0xa8, 0x6f, 0x19, 0xe3, 0x96, 0xa4, 0x5c, 0x1e,
-	0x90, 0x3e, 0xbf, 0x8c, 0x7c, 0xef, 0xe4, 0xf4, 0x2b, 0x58, 0x99, 0x7b, 0x3e, 0xc8, 0x66, 0x96,
-	0x3f, 0x36, 0x5c, 0x16, 0x67, 0x77, 0x20, 0xe7, 0x4d, 0xe2, 0x9f, 0xfd, 0x16, 0xba, 0xa5, 0x0e,
-	0x3e, 0xfb, 0xa8, 0xe8, 0x43, 0x67, 0xef, 0xcd, 0xdb, 0xd1, 0xf7, 0xee, 0xf1, 0xe9, 0xe1, 0xb0,
-	0x2e, 0xdf, 0x0e, 0x47, 0xfb, 0x07, 0x93, 0xb3, 0xa3, 0xb3, 0x73, 0xc5, 0x59, 0xd8, 0xf9, 0x0b,
-	0xb4, 0xf4, 0x04, 0x85, 0xbe, 0x81, 0x9e, 0xfe, 0x3a, 0x15, 0x29, 0xc1, 0x11, 0x9a, 0x03, 0x84,
-	0xf5, 0x39, 0x8e, 0x5d, 0x7b, 0x51, 0x7f, 0x55, 0x47, 0x5f, 0x40, 0xe3, 0x84, 0xb2, 0x00, 0x55,
-	0x7f, 0x14, 0xac, 0x57, 0x49, 0xbb, 0xb6, 0xf7, 0xd5, 0x9f, 0x37, 0x03, 0x2a, 0xa6, 0x37, 0x17,
-	0xb2, 0x43, 0x6d, 0x4f, 0xef, 0x13, 0x92, 0xea, 0x69, 0x7e, 0xfb, 0x12, 0x5f, 0xa4, 0xd4, 0xdb,
-	0x56, 0xff, 0xe6, 0xf8, 0xb6, 0x36, 0xbb, 0x68, 0x29, 0xf2, 0xeb, 0xff, 0x06, 0x00, 0x00, 0xff,
-	0xff, 0xe7, 0x7f, 0x88, 0xcd, 0xe3, 0x13, 0x00, 0x00,
+func init() { proto.RegisterFile("gossip/message.proto", fileDescriptor_message_41ae86731fc95a23) }
+
+var fileDescriptor_message_41ae86731fc95a23 = []byte{
+	// 2091 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x58, 0x5b, 0x53, 0xe4, 0xc6,
+	0x15, 0x9e, 0x61, 0x2e, 0xcc, 0x9c, 0xb9, 0x30, 0xf4, 0xb2, 0xbb, 0x32, 0xbe, 0x11, 0xd9, 0x6b,
+	0x6f, 0xb2, 0x6b, 0xd8, 0xe0, 0xc4, 0x71, 0xd5, 0x26, 0xd9, 0x82, 0x61, 0xcc, 0x10, 0xc3, 0x2c,
+	0x11, 0x50, 0x09, 0x79, 0x51, 0x35, 0x52, 0x8f, 0x46, 0x41, 0x6a, 0x09, 0x75, 0x83, 0xe1, 0x31,
+	0x95, 0xaa, 0x3c, 0xe4, 0x25, 0xbf, 0x21, 0x4f, 0xf9, 0x19, 0xf9, 0x11, 0xf9, 0x43, 0xa9, 0xbe,
+	0xe8, 0x36, 0x03, 0x5b, 0xb5, 0xae, 0xf2, 0x9b, 0xce, 0xbd, 0xfb, 0xf4, 0xe9, 0xef, 0x9c, 0x16,
+	0xac, 0x79, 0x11, 0x63, 0x7e, 0xbc, 0x15, 0x12, 0xc6, 0xb0, 0x47, 0x36, 0xe3, 0x24, 0xe2, 0x11,
+	0x6a, 0x2a, 0xee, 0xfa, 0x53, 0x27, 0x0a, 0xc3, 0x88, 0x6e, 0x39, 0x51, 0x10, 0x10, 0x87, 0xfb,
+	0x11, 0x55, 0x0a, 0xe6, 0xdf, 0xab, 0xd0, 0x1a, 0xd1, 0x1b, 0x12, 0x44, 0x31, 0x41, 0x06, 0x2c,
+	0xc7, 0xf8, 0x2e, 0x88, 0xb0, 0x6b, 0x54, 0x37, 0xaa, 0xcf, 0xbb, 0x56, 0x4a, 0xa2, 0x8f, 0xa0,
+	0xcd, 0x7c, 0x8f, 0x62, 0x7e, 0x9d, 0x10, 0x63, 0x49, 0xca, 0x72, 0x06, 0x7a, 0x03, 0x2b, 0x8c,
+	0x38, 0x09, 0xe1, 0x36, 0xd1, 0xae, 0x8c, 0xda, 0x46, 0xf5, 0x79, 0x67, 0xfb, 0xc9, 0xa6, 0x8a,
+	0xbf, 0x79, 0x22, 0xc5, 0x69, 0x20, 0xab, 0xcf, 0x4a, 0xb4, 0x39, 0x86, 0x7e, 0x59, 0xe3, 0xc7,
+	0x2e, 0xc5, 0xdc, 0x81, 0xa6, 0xf2, 0x84, 0x5e, 0xc2, 0xc0, 0xa7, 0x9c, 0x24, 0x14, 0x07, 0x23,
+	0xea, 0xc6, 0x91, 0x4f, 0xb9, 0x74, 0xd5, 0x1e, 0x57, 0xac, 0x05, 0xc9, 0x6e, 0x1b, 0x96, 0x9d,
+	0x88, 0x72, 0x42, 0xb9, 0xf9, 0xbf, 0x0e, 0xf4, 0xf6, 0xe5, 0xb2, 0x8f, 0x54, 0x2e, 0xd1, 0x1a,
+	0x34, 0x68, 0x44, 0x1d, 0x22, 0xed, 0xeb, 0x96, 0x22, 0xc4, 0x12, 0x9d, 0x19, 0xa6, 0x94, 0x04,
+	0x7a, 0x19, 0x29, 0x89, 0x5e, 0x40, 0x8d, 0x63, 0x4f, 0xe6, 0xa0, 0xbf, 0xfd, 0x41, 0x9a, 0x83,
+	0x92, 0xcf, 0xcd, 0x53, 0xec, 0x59, 0x42, 0x0b, 0x7d, 0x0d, 0x6d, 0x1c, 0xf8, 0x37, 0xc4, 0x0e,
+	0x99, 0x67, 0x34, 0x64, 0xda, 0xd6, 0x52, 0x93, 0x1d, 0x21, 0xd0, 0x16, 0xe3, 0x8a, 0xd5, 0x92,
+	0x8a, 0x47, 0xcc, 0x43, 0xbf, 0x82, 0xe5, 0x90, 0x84, 0x76, 0x42, 0xae, 0x8c, 0xa6, 0x34, 0xc9,
+	0xa2, 0x1c, 0x91, 0xf0, 0x82, 0x24, 0x6c, 0xe6, 0xc7, 0x16, 0xb9, 0xba, 0x26, 0x8c, 0x8f, 0x2b,
+	0x56, 0x33, 0x24, 0xa1, 0x45, 0xae, 0xd0, 0xaf, 0x53, 0x2b, 0x66, 0x2c, 0x4b, 0xab, 0xf5, 0xfb,
+	0xac, 0x58, 0x1c, 0x51, 0x46, 0x32, 0x33, 0x86, 0x5e, 0x41, 0xcb, 0xc5, 0x1c, 0xcb, 0x05, 0xb6,
+	0xa4, 0xdd, 0xa3, 0xd4, 0x6e, 0x0f, 0x73, 0x9c, 0xaf, 0x6f, 0x59, 0xa8, 0x89, 0xe5, 0xbd, 0x80,
+	0xc6, 0x8c, 0x04, 0x41, 0x64, 0xb4, 0xcb, 0xea, 0x2a, 0x05, 0x63, 0x21, 0x1a, 0x57, 0x2c, 0xa5,
+	0x83, 0xb6, 0xb4, 0x7b, 0xd7, 0xf7, 0x0c, 0x90, 0xfa, 0xa8, 0xe8, 0x7e, 0xcf, 0xf7, 0xd4, 0x2e,
+	0xa4, 0xf7, 0x3d, 0xdf, 0xcb, 0xd6, 0x23, 0x76, 0xdf, 0x59, 0x5c, 0x4f, 0xbe, 0x6f, 0x69, 0xa1,
+	0x36, 0xde, 0x91, 0x16, 0xd7, 0xb1, 0x8b, 0x39, 0x31, 0xba, 0x8b, 0x51, 0xce, 0xa4, 0x64, 0x5c,
+	0xb1, 0xc0, 0xcd, 0x28, 0xf4, 0x0c, 0x1a, 0x24, 0x8c, 0xf9, 0x9d, 0xd1, 0x93, 0x06, 0xbd, 0xd4,
+	0x60, 0x24, 0x98, 0x62, 0x03, 0x52, 0x8a, 0x5e, 0x40, 0xdd, 0x89, 0x28, 0x35, 0xfa, 0x52, 0xeb,
+	0x71, 0xaa, 0x35, 0x8c, 0x28, 0x1d, 0x31, 0x8e, 0x2f, 0x02, 0x9f, 0xcd, 0xc6, 0x15, 0x4b, 0x2a,
+	0xa1, 0x6d, 0x00, 0xc6, 0x31, 0x27, 0xb6, 0x4f, 0xa7, 0x91, 0xb1, 0x22, 0x4d, 0x56, 0xb3, 0x6b,
+	0x22, 0x24, 0x07, 0x74, 0x2a, 0xb2, 0xd3, 0x66, 0x29, 0x81, 0x76, 0xa1, 0xaf, 0x6c, 0x18, 0xc5,
+	0x31, 0x9b, 0x45, 0xdc, 0x18, 0x94, 0x0f, 0x3d, 0xb3, 0x3b, 0xd1, 0x0a, 0xe3, 0x8a, 0xd5, 0x93,
+	0x26, 0x29, 0x03, 0x1d, 0xc1, 0xa3, 0x3c, 0xae, 0x1d, 0x5f, 0x07, 0x81, 0xcc, 0xdf, 0xaa, 0x74,
+	0xf4, 0xd1, 0x82, 0xa3, 0xe3, 0xeb, 0x20, 0xc8, 0x13, 0x39, 0x60, 0x73, 0x7c, 0xb4, 0x03, 0xca,
+	0xbf, 0x9d, 0x28, 0x25, 0x03, 0x95, 0x0b, 0xca, 0x22, 0x61, 0xc4, 0x89, 0x74, 0x97, 0xbb, 0xe9,
+	0xb2, 0x02, 0x8d, 0xf6, 0xd2, 0x5d, 0x25, 0xba, 0xe4, 0x8c, 0x47, 0xd2, 0xc7, 0x87, 0xf7, 0xfa,
+	0xc8, 0xaa, 0xb2, 0xc7, 0x8a, 0x0c, 0x91, 0x9b, 0x80, 0x60, 0x57, 0x15, 0xaf, 0x2c, 0xd1, 0xb5,
+	0x72, 0x6e, 0x0e, 0x33, 0x69, 0x5e, 0xa8, 0xbd, 0xdc, 0x44, 0x94, 0xeb, 0x6b, 0xe8, 0xc5, 0x84,
+	0x24, 0xb6, 0xef, 0x12, 0xca, 0x7d, 0x7e, 0x67, 0x3c, 0x2e, 0x5f, 0xc3, 0x63, 0x42, 0x92, 0x03,
+	0x2d, 0x13, 0xdb, 0x88, 0x0b, 0xb4, 0xb8, 0xec, 0xd8, 0xb9, 0x34, 0x9e, 0x48, 0x93, 0xa7, 0xd9,
+	0xcd, 0x75, 0x2e, 0x69, 0xf4, 0x43, 0x40, 0x5c, 0x8f, 0x84, 0x84, 0x8a, 0xcd, 0x0b, 0x2d, 0xf4,
+	0x7b, 0x80, 0x38, 0xf1, 0x6f, 0x54, 0x16, 0x8c, 0xa7, 0xe5, 0xe4, 0xab, 0xfd, 0x1e, 0xdf, 0xf0,
+	0x72, 0x15, 0x17, 0x2c, 0xd0, 0x9b, 0x82, 0x3d, 0x33, 0x0c, 0x69, 0xff, 0xf1, 0x03, 0xf6, 0x59,
+	0xc6, 0x0a, 0x26, 0xe8, 0x0d, 0x74, 0x35, 0x65, 0x8b, 0x42, 0x37, 0x3e, 0x28, 0x1f, 0xdb, 0xb1,
+	0x92, 0x95, 0xaf, 0x75, 0x27, 0xce, 0xb9, 0xe8, 0x0f, 0x80, 0x70, 0xe2, 0xcc, 0xfc, 0x1b, 0xe2,
+	0xda, 0x17, 0x41, 0xe4, 0x5c, 0x4e, 0xfd, 0x80, 0x18, 0xeb, 0xe5, 0x9c, 0xef, 0x68, 0x8d, 0xdd,
+	0x54, 0x61, 0x5c, 0xb1, 0x56, 0xf1, 0x3c, 0xd3, 0xb4, 0xa1, 0x76, 0x8a, 0x3d, 0xd4, 0x83, 0xf6,
+	0xd9, 0x64, 0x6f, 0xf4, 0xdd, 0xc1, 0x64, 0xb4, 0x37, 0xa8, 0xa0, 0x36, 0x34, 0x46, 0x47, 0xc7,
+	0xa7, 0xe7, 0x83, 0x2a, 0xea, 0x42, 0xeb, 0xad, 0xb5, 0x6f, 0xbf, 0x9d, 0x1c, 0x9e, 0x0f, 0x96,
+	0x84, 0xde, 0x70, 0xbc, 0x33, 0x51, 0x64, 0x0d, 0x0d, 0xa0, 0x2b, 0xc9, 0x9d, 0xc9, 0x9e, 0xfd,
+	0xd6, 0xda, 0x1f, 0xd4, 0xd1, 0x0a, 0x74, 0x94, 0x82, 0x25, 0x19, 0x8d, 0x22, 0xaa, 0xff, 0xa7,
+	0x0a, 0xed, 0xac, 0xba, 0xd1, 0x26, 0xb4, 0xb9, 0x1f, 0x12, 0xc6, 0x71, 0x18, 0x4b, 0xf4, 0xee,
+	0x6c, 0x0f, 0x8a, 0xa7, 0x7d, 0xea, 0x87, 0xc4, 0xca, 0x55, 0xd0, 0x63, 0x68, 0xc6, 0x97, 0xbe,
+	0xed, 0xbb, 0x12, 0xd4, 0xbb, 0x56, 0x23, 0xbe, 0xf4, 0x0f, 0x5c, 0xf4, 0x29, 0x74, 0x34, 0xe6,
+	0xdb, 0x47, 0x3b, 0x43, 0xa3, 0x2e, 0x65, 0xa0, 0x59, 0x47, 0x3b, 0x43, 0x71, 0xdb, 0xe3, 0x24,
+	0x8a, 0x49, 0xc2, 0x7d, 0xc2, 0x8c, 0x46, 0x19, 0x77, 0x8e, 0x33, 0x89, 0x55, 0xd0, 0x32, 0xff,
+	0x5b, 0x05, 0xc8, 0x45, 0xe8, 0x33, 0xe8, 0xc9, 0x32, 0x4a, 0xec, 0x19, 0xf1, 0xbd, 0x19, 0xd7,
+	0x4d, 0xa8, 0xab, 0x98, 0x63, 0xc9, 0x43, 0x3f, 0x83, 0x6e, 0x40, 0xa6, 0xdc, 0x2e, 0x36, 0xa4,
+	0x96, 0xd5, 0x11, 0xbc, 0xa1, 0x62, 0xa1, 0x5f, 0x82, 0x58, 0x98, 0x4f, 0x9d, 0xc8, 0x25, 0xcc,
+	0xa8, 0x6d, 0xd4, 0x8a, 0xc0, 0x33, 0x4c, 0x25, 0x56, 0x41, 0x09, 0x7d, 0x03, 0x5d, 0x7d, 0x68,
+	0x0a, 0xad, 0xea, 0x65, 0xb0, 0xd5, 0xa7, 0x2c, 0x12, 0x6a, 0x75, 0x70, 0x4e, 0x98, 0x3b, 0xb0,
+	0xba, 0x80, 0x48, 0xe8, 0x25, 0xb4, 0x48, 0x20, 0x2f, 0x03, 0x33, 0xaa, 0x1b, 0xb5, 0x62, 0xc6,
+	0xb3, 0xb9, 0x20, 0xd3, 0x30, 0x7f, 0x03, 0x6b, 0xf7, 0x61, 0xd1, 0x7c, 0xc6, 0xab, 0xf3, 0x19,
+	0x37, 0xa7, 0xd0, 0x2b, 0x01, 0x6f, 0xe1, 0xe8, 0xaa, 0xc5, 0xa3, 0x5b, 0x87, 0x56, 0x76, 0xdd,
+	0x55, 0xfb, 0xce, 0x68, 0x64, 0x42, 0x8f, 0x07, 0xcc, 0x76, 0x48, 0xc2, 0xed, 0x19, 0x66, 0x33,
+	0x7d, 0xe8, 0x1d, 0x1e, 0xb0, 0x21, 0x49, 0xf8, 0x18, 0xb3, 0x99, 0x79, 0x06, 0xdd, 0x22, 0x2c,
+	0x3c, 0x14, 0x06, 0x41, 0x5d, 0xb8, 0xd1, 0x21, 0xe4, 0xb7, 0x08, 0x1d, 0x12, 0x8e, 0xe5, 0xfd,
+	0x53, 0x9e, 0x33, 0xda, 0x0c, 0xa1, 0x53, 0xb8, 0xfd, 0x0f, 0x4f, 0x1e, 0xae, 0xec, 0x8a, 0xcc,
+	0x58, 0xda, 0xa8, 0x89, 0xc9, 0x43, 0x93, 0x68, 0x13, 0x5a, 0x21, 0xf3, 0x6c, 0x7e, 0xa7, 0x47,
+	0xb0, 0x7e, 0x7e, 0x5a, 0x22, 0x8b, 0x47, 0xcc, 0x3b, 0xbd, 0x8b, 0x89, 0xb5, 0x1c, 0xaa, 0x0f,
+	0x33, 0x82, 0x4e, 0xa1, 0x27, 0x3f, 0x10, 0xae, 0xb8, 0xde, 0xa5, 0xf2, 0x7a, 0xdf, 0x3b, 0xe0,
+	0x2d, 0x40, 0xde, 0x6e, 0x1f, 0x88, 0xf7, 0x39, 0xd4, 0x75, 0xac, 0xfb, 0xab, 0xa4, 0xfe, 0xa3,
+	0x22, 0x07, 0x00, 0xf9, 0x38, 0xf1, 0x93, 0x27, 0xf6, 0x5b, 0xe8, 0x14, 0x40, 0x14, 0xfd, 0xbc,
+	0x3c, 0xce, 0x76, 0xb6, 0x57, 0x32, 0x6b, 0xc5, 0xce, 0xe6, 0x5b, 0xf3, 0x3b, 0x40, 0x8b, 0x28,
+	0x8c, 0x5e, 0xcd, 0x3b, 0x78, 0x32, 0x07, 0xd9, 0x0b, 0x7e, 0xce, 0x61, 0x59, 0xf3, 0xd0, 0x53,
+	0x58, 0x66, 0xe4, 0xca, 0xa6, 0xd7, 0xa1, 0xde, 0x6e, 0x93, 0x91, 0xab, 0xc9, 0x75, 0x28, 0xaa,
+	0xb3, 0x70, 0xaa, 0xf2, 0x5b, 0x40, 0x49, 0xa9, 0x43, 0xd4, 0x64, 0x22, 0x8a, 0x3d, 0xc0, 0xfc,
+	0xd7, 0x12, 0xf4, 0xcb, 0x61, 0xd1, 0x97, 0xb0, 0x92, 0xbf, 0x2d, 0x6c, 0x8a, 0x43, 0x95, 0xd9,
+	0xb6, 0xd5, 0xcf, 0xd9, 0x13, 0x1c, 0x12, 0x31, 0xbe, 0x0b, 0x29, 0x8b, 0xb1, 0xa3, 0xc6, 0xf7,
+	0xb6, 0x95, 0x33, 0xd0, 0x23, 0x68, 0xf0, 0xdb, 0x14, 0x66, 0xdb, 0x56, 0x9d, 0xdf, 0x1e, 0xb8,
+	0x02, 0x01, 0xd3, 0x15, 0x25, 0x3f, 0x30, 0xc2, 0x35, 0xce, 0xa6, 0xcb, 0xb4, 0x04, 0x0f, 0xbd,
+	0x04, 0x94, 0x2a, 0x31, 0x3f, 0x4c, 0xb1, 0xb2, 0x21, 0xb7, 0x3b, 0xd0, 0x92, 0x13, 0x3f, 0xd4,
+	0x78, 0x39, 0x01, 0x54, 0x58, 0xae, 0x13, 0xd1, 0xa9, 0xef, 0x31, 0x3d, 0x4a, 0x7f, 0xba, 0xa9,
+	0x1e, 0x4b, 0x9b, 0xc3, 0x4c, 0x63, 0x28, 0x15, 0x8e, 0xb1, 0x73, 0x89, 0x3d, 0x62, 0xad, 0x3a,
+	0x73, 0x02, 0x66, 0xfe, 0xb3, 0x0a, 0xdd, 0xe2, 0xb0, 0x8e, 0x36, 0x01, 0xc2, 0x6c, 0xa6, 0xd6,
+	0x47, 0xd6, 0x2f, 0x4f, 0xdb, 0x56, 0x41, 0xe3, 0xbd, 0x1b, 0x52, 0x11, 0xbe, 0xea, 0x65, 0xf8,
+	0x32, 0xff, 0x56, 0x85, 0xd5, 0x85, 0xa9, 0xe7, 0x21, 0x80, 0x7a, 0xdf, 0xc0, 0xcf, 0xa0, 0xef,
+	0x33, 0xdb, 0x25, 0x4e, 0x80, 0x13, 0x2c, 0x52, 0x20, 0x8f, 0xaa, 0x65, 0xf5, 0x7c, 0xb6, 0x97,
+	0x33, 0xcd, 0xdf, 0x42, 0x2b, 0xb5, 0x16, 0xe5, 0xe7, 0x53, 0xa7, 0x58, 0x7e, 0x3e, 0x75, 0x44,
+	0xf9, 0x15, 0xea, 0x72, 0xa9, 0x58, 0x97, 0xe6, 0x14, 0x56, 0x17, 0xde, 0x31, 0xe8, 0x35, 0x0c,
+	0x18, 0x09, 0xa6, 0xb2, 0x15, 0x25, 0xa1, 0x8a, 0x5d, 0xdd, 0xa8, 0xde, 0x0b, 0x11, 0x2b, 0x42,
+	0xf3, 0x20, 0x57, 0x14, 0xf7, 0x5d, 0x0c, 0x64, 0x54, 0xdf, 0x6b, 0x45, 0x98, 0x17, 0x80, 0x16,
+	0x5f, 0x3e, 0xe8, 0x0b, 0x68, 0xc8, 0x87, 0xd6, 0x83, 0x6d, 0x4a, 0x89, 0x25, 0x4e, 0x11, 0xec,
+	0xbe, 0x03, 0xa7, 0x08, 0x76, 0xcd, 0x3f, 0x41, 0x53, 0xc5, 0x10, 0x67, 0x46, 0x4a, 0x2f, 0x51,
+	0x2b, 0xa3, 0xdf, 0x89, 0xb1, 0xf7, 0x0f, 0x1f, 0xe6, 0x32, 0x34, 0xe4, 0x43, 0xc4, 0xfc, 0x33,
+	0xa0, 0xc5, 0x71, 0x5b, 0x34, 0x31, 0xc6, 0x71, 0xc2, 0xed, 0xf2, 0xd5, 0xef, 0x48, 0xe6, 0x89,
+	0xba, 0xff, 0x9f, 0x40, 0x87, 0x50, 0xd7, 0x2e, 0x1f, 0x42, 0x9b, 0x50, 0x57, 0xc9, 0xcd, 0x5d,
+	0x78, 0x74, 0xcf, 0x10, 0x8e, 0x5e, 0x40, 0x4b, 0xa3, 0x4c, 0xda, 0xca, 0x17, 0xe0, 0x2c, 0x53,
+	0x30, 0xf7, 0x61, 0xed, 0xbe, 0xc1, 0x16, 0x6d, 0xe5, 0x58, 0xab, 0x7c, 0x64, 0x0f, 0x27, 0xad,
+	0xa8, 0x90, 0x3a, 0x83, 0x60, 0xf3, 0xdf, 0x55, 0xe8, 0x95, 0x44, 0x39, 0x5a, 0x54, 0x0b, 0x68,
+	0xf1, 0x6e, 0x80, 0xf9, 0x04, 0x20, 0xbf, 0xbd, 0x1a, 0x65, 0x0a, 0x1c, 0xf4, 0x21, 0xb4, 0xe5,
+	0x54, 0x2b, 0x72, 0x22, 0x2f, 0x56, 0xdd, 0x6a, 0x49, 0xc6, 0x09, 0xb9, 0x42, 0x1b, 0xd0, 0x15,
+	0xa9, 0xf2, 0xa9, 0x9a, 0x7c, 0x35, 0xba, 0x00, 0x23, 0x57, 0x07, 0x54, 0x4e, 0xb5, 0xe6, 0xf7,
+	0xf0, 0xf8, 0xde, 0x29, 0x1c, 0x6d, 0x2f, 0x4c, 0x3f, 0x4f, 0xe6, 0xb6, 0x3b, 0x52, 0xe2, 0xc2,
+	0x0c, 0x74, 0x0e, 0xfd, 0xb2, 0x0c, 0x7d, 0x05, 0x4d, 0x95, 0x0d, 0x5d, 0xf8, 0x0f, 0xa4, 0x4c,
+	0x2b, 0x15, 0x7f, 0xa2, 0xe8, 0x76, 0xa6, 0x49, 0xf3, 0x8f, 0x99, 0xeb, 0x14, 0xc0, 0x9f, 0xc1,
+	0x0a, 0xbf, 0xb5, 0x4b, 0xdb, 0xd3, 0x83, 0x26, 0xbf, 0x3d, 0xc9, 0x36, 0x58, 0x76, 0x59, 0xfc,
+	0x2f, 0x63, 0x7e, 0x09, 0x2b, 0x73, 0x8f, 0x1e, 0x71, 0xe9, 0x48, 0x92, 0x44, 0x89, 0x3e, 0x1f,
+	0x45, 0x98, 0x67, 0xd0, 0xce, 0xc6, 0x4d, 0xd1, 0x81, 0x0a, 0xcd, 0x42, 0x7e, 0x8b, 0x18, 0x37,
+	0x24, 0x61, 0xe2, 0x80, 0xd4, 0xf9, 0xa5, 0xe4, 0x3b, 0x27, 0xa7, 0x6f, 0x60, 0x75, 0xe1, 0xd9,
+	0x21, 0x9a, 0x59, 0xf6, 0x48, 0xb1, 0x69, 0x94, 0xde, 0x81, 0x8c, 0x37, 0x89, 0xcc, 0x7f, 0x2c,
+	0x41, 0xa7, 0x30, 0xc9, 0x8a, 0x18, 0x7a, 0x96, 0x55, 0xeb, 0x6e, 0x59, 0x19, 0x8d, 0x5e, 0xc3,
+	0x4a, 0xf6, 0xf8, 0x49, 0x30, 0xf5, 0x08, 0xd3, 0x97, 0x3f, 0x9b, 0xe9, 0x65, 0x68, 0x4b, 0x88,
+	0xac, 0x7e, 0xaa, 0x2a, 0x49, 0x26, 0x3a, 0x54, 0x14, 0xb8, 0x84, 0x71, 0x3b, 0x88, 0x1c, 0x1c,
+	0xe8, 0x24, 0xd7, 0x54, 0x87, 0x52, 0x92, 0x43, 0x21, 0x50, 0x89, 0xfe, 0x0c, 0x7a, 0x53, 0xc2,
+	0x9d, 0x99, 0x4d, 0x28, 0xbe, 0x08, 0x88, 0x2b, 0x8b, 0xb1, 0x65, 0x75, 0x25, 0x73, 0xa4, 0x78,
+	0xe8, 0x73, 0xc8, 0x82, 0xd8, 0xd7, 0xb1, 0xcd, 0x23, 0x5d, 0x92, 0xe9, 0xd8, 0xee, 0x9e, 0xc5,
+	0xa7, 0x91, 0x80, 0x6c, 0x07, 0x73, 0x1c, 0x44, 0x9e, 0xad, 0xab, 0xa7, 0x29, 0x73, 0xd7, 0xd3,
+	0x5c, 0x55, 0x35, 0xe6, 0x21, 0x40, 0xbe, 0x7a, 0x31, 0x68, 0x4f, 0xfd, 0x84, 0xf1, 0x52, 0x2d,
+	0x80, 0x64, 0xa9, 0x05, 0x7e, 0x0c, 0x10, 0xe0, 0x4c, 0xae, 0xa1, 0x23, 0xc0, 0x5a, 0xfc, 0x8b,
+	0xdf, 0x41, 0xa7, 0x30, 0x18, 0xcd, 0xbf, 0xf1, 0x7a, 0xd0, 0xde, 0x3d, 0x7c, 0x3b, 0xfc, 0xde,
+	0x3e, 0x3a, 0xd9, 0x1f, 0x54, 0xc5, 0x53, 0xee, 0x60, 0x6f, 0x34, 0x39, 0x3d, 0x38, 0x3d, 0x97,
+	0x9c, 0xa5, 0xed, 0xbf, 0x42, 0x53, 0x0d, 0xa6, 0xe8, 0x5b, 0xe8, 0xaa, 0xaf, 0x13, 0x9e, 0x10,
+	0x1c, 0xa2, 0x05, 0x9c, 0x5d, 0x5f, 0xe0, 0x98, 0x95, 0xe7, 0xd5, 0x57, 0x55, 0xf4, 0x05, 0xd4,
+	0x8f, 0x7d, 0xea, 0xa1, 0xf2, 0x7f, 0x9b, 0xf5, 0x32, 0x69, 0x56, 0x76, 0xbf, 0xfa, 0xcb, 0x0b,
+	0xcf, 0xe7, 0xb3, 0xeb, 0x0b, 0xd1, 0xf8, 0xb7, 0x66, 0x77, 0x31, 0x49, 0xd4, 0xe3, 0x6a, 0x6b,
+	0x8a, 0x2f, 0x12, 0xdf, 0xd9, 0x92, 0xbf, 0x4a, 0xd9, 0x96, 0x32, 0xbb, 0x68, 0x4a, 0xf2, 0xeb,
+	0xff, 0x0f, 0x00, 0x0a, 0xd3, 0x0b, 0x35, 0x72, 0x15, 0x00, 0x00,
 }
@@ -30,7 +30,7 @@ func (m *AnchorPeers) Reset()         { *m = AnchorPeers{} }
 func (m *AnchorPeers) String() string { return proto.CompactTextString(m) }
 func (*AnchorPeers) ProtoMessage()    {}
 func (*AnchorPeers) Descriptor() ([]byte, []int) {
-	return fileDescriptor_configuration_b4b920cf60a7bfc1, []int{0}
+	return fileDescriptor_configuration_0939c35b14435a22, []int{0}
 }
 func (m *AnchorPeers) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_AnchorPeers.Unmarshal(m, b)
@@ -73,7 +73,7 @@ func (m *AnchorPeer) Reset()         { *m = AnchorPeer{} }
 func (m *AnchorPeer) String() string { return proto.CompactTextString(m) }
 func (*AnchorPeer) ProtoMessage()    {}
 func (*AnchorPeer) Descriptor() ([]byte, []int) {
-	return fileDescriptor_configuration_b4b920cf60a7bfc1, []int{1}
+	return fileDescriptor_configuration_0939c35b14435a22, []int{1}
 }
 func (m *AnchorPeer) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_AnchorPeer.Unmarshal(m, b)
@@ -120,7 +120,7 @@ func (m *APIResource) Reset()         { *m = APIResource{} }
 func (m *APIResource) String() string { return proto.CompactTextString(m) }
 func (*APIResource) ProtoMessage()    {}
 func (*APIResource) Descriptor() ([]byte, []int) {
-	return fileDescriptor_configuration_b4b920cf60a7bfc1, []int{2}
+	return fileDescriptor_configuration_0939c35b14435a22, []int{2}
 }
 func (m *APIResource) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_APIResource.Unmarshal(m, b)
@@ -160,7 +160,7 @@ func (m *ACLs) Reset()         { *m = ACLs{} }
 func (m *ACLs) String() string { return proto.CompactTextString(m) }
 func (*ACLs) ProtoMessage()    {}
 func (*ACLs) Descriptor() ([]byte, []int) {
-	return fileDescriptor_configuration_b4b920cf60a7bfc1, []int{3}
+	return fileDescriptor_configuration_0939c35b14435a22, []int{3}
 }
 func (m *ACLs) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ACLs.Unmarshal(m, b)
@@ -187,37 +187,104 @@ func (m *ACLs) GetAcls() map[string]*APIResource {
 	return nil
 }
 
+// BlockArchiving is the block archiving policy of a channel, agreed by its organizations in the channel
+// configuration. It tightens the local archiving settings of every peer of the channel.
+type BlockArchiving struct {
+	// The least number of the latest blockfiles every peer keeps on its local file system
+	MinLocalBlockfiles uint32 `protobuf:"varint,1,opt,name=min_local_blockfiles,json=minLocalBlockfiles,proto3" json:"min_local_blockfiles,omitempty"`
+	// The least time, in seconds, since the commit of the last block of a blockfile before the
+	// peers discard it from their local file system
+	MinBlockAgeSeconds uint64 `protobuf:"varint,2,opt,name=min_block_age_seconds,json=minBlockAgeSeconds,proto3" json:"min_block_age_seconds,omitempty"`
+	// Whether the peers discard a local blockfile only once the repository has locked the archived one
+	ObjectLockRequired   bool     `protobuf:"varint,3,opt,name=object_lock_required,json=objectLockRequired,proto3" json:"object_lock_required,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockArchiving) Reset()         { *m = BlockArchiving{} }
+func (m *BlockArchiving) String() string { return proto.CompactTextString(m) }
+func (*BlockArchiving) ProtoMessage()    {}
+func (*BlockArchiving) Descriptor() ([]byte, []int) {
+	return fileDescriptor_configuration_0939c35b14435a22, []int{4}
+}
+func (m *BlockArchiving) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockArchiving.Unmarshal(m, b)
+}
+func (m *BlockArchiving) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockArchiving.Marshal(b, m, deterministic)
+}
+func (dst *BlockArchiving) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockArchiving.Merge(dst, src)
+}
+func (m *BlockArchiving) XXX_Size() int {
+	return xxx_messageInfo_BlockArchiving.Size(m)
+}
+func (m *BlockArchiving) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockArchiving.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockArchiving proto.InternalMessageInfo
+
+func (m *BlockArchiving) GetMinLocalBlockfiles() uint32 {
+	if m != nil {
+		return m.MinLocalBlockfiles
+	}
+	return 0
+}
+
+func (m *BlockArchiving) GetMinBlockAgeSeconds() uint64 {
+	if m != nil {
+		return m.MinBlockAgeSeconds
+	}
+	return 0
+}
+
+func (m *BlockArchiving) GetObjectLockRequired() bool {
+	if m != nil {
+		return m.ObjectLockRequired
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterType((*AnchorPeers)(nil), "protos.AnchorPeers")
 	proto.RegisterType((*AnchorPeer)(nil), "protos.AnchorPeer")
 	proto.RegisterType((*APIResource)(nil), "protos.APIResource")
 	proto.RegisterType((*ACLs)(nil), "protos.ACLs")
 	proto.RegisterMapType((map[string]*APIResource)(nil), "protos.ACLs.AclsEntry")
+	proto.RegisterType((*BlockArchiving)(nil), "protos.BlockArchiving")
 }
 
 func init() {
-	proto.RegisterFile("peer/configuration.proto", fileDescriptor_configuration_b4b920cf60a7bfc1)
-}
-
-var fileDescriptor_configuration_b4b920cf60a7bfc1 = []byte{
-	// 295 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x91, 0xdf, 0x4b, 0xfb, 0x30,
-	0x14, 0xc5, 0xe9, 0x7e, 0x7c, 0x61, 0xb7, 0xdf, 0x07, 0x89, 0x20, 0x45, 0x10, 0x46, 0x9f, 0x36,
-	0x91, 0x14, 0xa6, 0x82, 0xf8, 0x56, 0xa7, 0x0f, 0xc2, 0xc0, 0x91, 0x47, 0x5f, 0x46, 0x16, 0x6f,
-	0x7f, 0x60, 0x6d, 0xca, 0x4d, 0x2a, 0xf4, 0xcd, 0x3f, 0x5d, 0x9a, 0x6c, 0xab, 0x4f, 0x39, 0x39,
-	0xf9, 0x9c, 0xcb, 0x21, 0x17, 0xa2, 0x06, 0x91, 0x12, 0xa5, 0xeb, 0xac, 0xcc, 0x5b, 0x92, 0xb6,
-	0xd4, 0x35, 0x6f, 0x48, 0x5b, 0xcd, 0xfe, 0xb9, 0xc3, 0xc4, 0xcf, 0x10, 0xa6, 0xb5, 0x2a, 0x34,
-	0x6d, 0x11, 0xc9, 0xb0, 0x7b, 0xf8, 0x2f, 0xdd, 0x75, 0xd7, 0x27, 0x4d, 0x14, 0xcc, 0xc7, 0x8b,
-	0x70, 0xc5, 0x7c, 0xc8, 0xf0, 0x01, 0x15, 0xa1, 0x1c, 0x62, 0xf1, 0x1d, 0xc0, 0xf0, 0xc4, 0x18,
-	0x4c, 0x0a, 0x6d, 0x6c, 0x14, 0xcc, 0x83, 0xc5, 0x4c, 0x38, 0xdd, 0x7b, 0x8d, 0x26, 0x1b, 0x8d,
-	0xe6, 0xc1, 0x62, 0x2a, 0x9c, 0x8e, 0x6f, 0x20, 0x4c, 0xb7, 0xaf, 0x02, 0x8d, 0x6e, 0x49, 0x21,
-	0xbb, 0x02, 0x68, 0x74, 0x55, 0xaa, 0x6e, 0x47, 0x98, 0x1d, 0xc2, 0x33, 0xef, 0x08, 0xcc, 0xe2,
-	0x9f, 0x00, 0x26, 0xe9, 0x7a, 0x63, 0xd8, 0x35, 0x4c, 0xa4, 0xaa, 0x8e, 0xdd, 0x2e, 0x4e, 0xdd,
-	0xd6, 0x1b, 0xc3, 0x53, 0x55, 0x99, 0x97, 0xda, 0x52, 0x27, 0x1c, 0x73, 0xb9, 0x81, 0xd9, 0xc9,
-	0x62, 0x67, 0x30, 0xfe, 0xc4, 0xee, 0x30, 0xb9, 0x97, 0x6c, 0x09, 0xd3, 0x6f, 0x59, 0xb5, 0xe8,
-	0x6a, 0x85, 0xab, 0xf3, 0xd3, 0xac, 0xa1, 0x96, 0xf0, 0xc4, 0xe3, 0xe8, 0x21, 0x78, 0x7a, 0x83,
-	0x58, 0x53, 0xce, 0x8b, 0xae, 0x41, 0xaa, 0xf0, 0x23, 0x47, 0xe2, 0x99, 0xdc, 0x53, 0xa9, 0x8e,
-	0xb9, 0xfe, 0xd3, 0xde, 0x97, 0x79, 0x69, 0x8b, 0x76, 0xcf, 0x95, 0xfe, 0x4a, 0xfe, 0xa0, 0x89,
-	0x47, 0x13, 0x8f, 0x26, 0x3d, 0xba, 0xf7, 0x5b, 0xb8, 0xfd, 0x0d, 0x00, 0x00, 0xff, 0xff, 0xae,
-	0x0a, 0x1d, 0x41, 0xa8, 0x01, 0x00, 0x00,
+	proto.RegisterFile("peer/configuration.proto", fileDescriptor_configuration_0939c35b14435a22)
+}
+
+var fileDescriptor_configuration_0939c35b14435a22 = []byte{
+	// 393 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x92, 0x5f, 0x8b, 0xd4, 0x30,
+	0x14, 0xc5, 0xe9, 0xce, 0xac, 0x38, 0xb7, 0x2a, 0x12, 0xff, 0x50, 0x04, 0x61, 0xe8, 0xd3, 0xac,
+	0x48, 0xab, 0xab, 0x82, 0xf8, 0xd6, 0x59, 0x7d, 0x10, 0x0a, 0x2e, 0xf1, 0xcd, 0x97, 0xd2, 0x66,
+	0x6e, 0xdb, 0x38, 0x99, 0xa4, 0xde, 0xb4, 0x0b, 0xf3, 0xe6, 0xb7, 0xf1, 0x6b, 0x4a, 0x92, 0xf9,
+	0xb3, 0x4f, 0xbd, 0xbd, 0xe7, 0x77, 0xee, 0x3d, 0x21, 0x81, 0x64, 0x40, 0xa4, 0x5c, 0x18, 0xdd,
+	0xca, 0x6e, 0xa2, 0x7a, 0x94, 0x46, 0x67, 0x03, 0x99, 0xd1, 0xb0, 0x07, 0xfe, 0x63, 0xd3, 0xaf,
+	0x10, 0x17, 0x5a, 0xf4, 0x86, 0x6e, 0x11, 0xc9, 0xb2, 0x4f, 0xf0, 0xa8, 0xf6, 0xbf, 0x95, 0x73,
+	0xda, 0x24, 0x5a, 0xce, 0x56, 0xf1, 0x35, 0x0b, 0x26, 0x9b, 0x9d, 0x51, 0x1e, 0xd7, 0x67, 0x5b,
+	0xfa, 0x11, 0xe0, 0x2c, 0x31, 0x06, 0xf3, 0xde, 0xd8, 0x31, 0x89, 0x96, 0xd1, 0x6a, 0xc1, 0x7d,
+	0xed, 0x7a, 0x83, 0xa1, 0x31, 0xb9, 0x58, 0x46, 0xab, 0x4b, 0xee, 0xeb, 0xf4, 0x2d, 0xc4, 0xc5,
+	0xed, 0x77, 0x8e, 0xd6, 0x4c, 0x24, 0x90, 0xbd, 0x06, 0x18, 0x8c, 0x92, 0x62, 0x5f, 0x11, 0xb6,
+	0x07, 0xf3, 0x22, 0x74, 0x38, 0xb6, 0xe9, 0xdf, 0x08, 0xe6, 0xc5, 0x4d, 0x69, 0xd9, 0x1b, 0x98,
+	0xd7, 0x42, 0x1d, 0xb3, 0xbd, 0x3c, 0x65, 0xbb, 0x29, 0x6d, 0x56, 0x08, 0x65, 0xbf, 0xe9, 0x91,
+	0xf6, 0xdc, 0x33, 0xaf, 0x4a, 0x58, 0x9c, 0x5a, 0xec, 0x29, 0xcc, 0xb6, 0xb8, 0x3f, 0x4c, 0x76,
+	0x25, 0xbb, 0x82, 0xcb, 0xbb, 0x5a, 0x4d, 0xe8, 0x63, 0xc5, 0xd7, 0xcf, 0x4e, 0xb3, 0xce, 0xb1,
+	0x78, 0x20, 0xbe, 0x5c, 0x7c, 0x8e, 0xd2, 0x7f, 0x11, 0x3c, 0x59, 0x2b, 0x23, 0xb6, 0x05, 0x89,
+	0x5e, 0xde, 0x49, 0xdd, 0xb1, 0x77, 0xf0, 0x7c, 0x27, 0x75, 0xa5, 0x8c, 0xa8, 0x55, 0xd5, 0x38,
+	0xad, 0x95, 0x0a, 0xad, 0x5f, 0xf2, 0x98, 0xb3, 0x9d, 0xd4, 0xa5, 0x93, 0xd6, 0x27, 0x85, 0xbd,
+	0x87, 0x17, 0xce, 0xe1, 0xd9, 0xaa, 0xee, 0xb0, 0xb2, 0x28, 0x8c, 0xde, 0x58, 0x9f, 0x61, 0xee,
+	0x2d, 0x61, 0x47, 0x87, 0x3f, 0x83, 0xe2, 0x96, 0x98, 0xe6, 0x37, 0x8a, 0xb1, 0xf2, 0x26, 0xc2,
+	0x3f, 0x93, 0x24, 0xdc, 0x24, 0xb3, 0x65, 0xb4, 0x7a, 0xc8, 0x59, 0xd0, 0x4a, 0x23, 0xb6, 0xfc,
+	0xa0, 0xac, 0x7f, 0x40, 0x6a, 0xa8, 0xcb, 0xfa, 0xfd, 0x80, 0xa4, 0x70, 0xd3, 0x21, 0x65, 0x6d,
+	0xdd, 0x90, 0x14, 0xc7, 0x13, 0xba, 0xeb, 0xfd, 0x75, 0xd5, 0xc9, 0xb1, 0x9f, 0x9a, 0x4c, 0x98,
+	0x5d, 0x7e, 0x0f, 0xcd, 0x03, 0x9a, 0x07, 0x34, 0x77, 0x68, 0x13, 0xde, 0xcb, 0x87, 0xff, 0x03,
+	0x00, 0x3f, 0xe0, 0xbf, 0xf8, 0x52, 0x02, 0x00, 0x00,
 }